@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/ingest"
+	"github.com/snarg/tr-engine/pkg/trcorpus"
+)
+
+// runConformance parses trunk-recorder messages from a corpus directory (or
+// the built-in corpus) and checks them against their golden files. No config
+// or database is needed. Exits 1 if any message would be quarantined or
+// rejected, or parses differently from its golden file.
+func runConformance(args []string) {
+	fs := flag.NewFlagSet("conformance", flag.ExitOnError)
+	dir := fs.String("dir", "", "Corpus directory laid out like pkg/trcorpus/corpus (default: the built-in corpus)")
+	update := fs.Bool("update", false, "Write golden files for messages in -dir that have none or differ")
+	verbose := fs.Bool("v", false, "Print every parse result")
+	fs.Parse(args)
+
+	if *update && *dir == "" {
+		fmt.Fprintln(os.Stderr, "error: -update requires -dir")
+		os.Exit(1)
+	}
+
+	var cases []trcorpus.Case
+	var err error
+	if *dir != "" {
+		cases, err = trcorpus.Load(os.DirFS(*dir))
+	} else {
+		cases, err = trcorpus.Embedded()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(cases) == 0 {
+		fmt.Fprintln(os.Stderr, "error: no messages found")
+		os.Exit(1)
+	}
+
+	now := time.Now()
+	var failed, changed, written int
+	for _, c := range cases {
+		res := ingest.ParseMessage(c.Topic, c.Payload, now)
+		got, err := json.Marshal(res)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s: %v\n", c.Name, err)
+			os.Exit(1)
+		}
+
+		status := "ok"
+		diff := ""
+		switch {
+		case res.Error != "" || len(res.Problems) > 0:
+			status = "FAIL"
+			failed++
+		case c.Golden == nil:
+			status = "new"
+		default:
+			if diff = trcorpus.Compare(c.Golden, got); diff != "" {
+				status = "DIFF"
+				changed++
+			}
+		}
+		fmt.Printf("%-4s  %s\n", status, c.Name)
+		if res.Error != "" {
+			fmt.Printf("      error: %s\n", res.Error)
+		}
+		for _, p := range res.Problems {
+			fmt.Printf("      invalid: %s\n", p)
+		}
+		if diff != "" {
+			fmt.Printf("      %s\n", diff)
+		}
+		if *verbose {
+			os.Stdout.Write(trcorpus.Format(got))
+		}
+
+		if *update && (status == "new" || status == "DIFF") {
+			if err := trcorpus.WriteGolden(*dir, c, got); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			written++
+		}
+	}
+
+	fmt.Printf("\n%d messages: %d invalid, %d differ from golden files", len(cases), failed, changed)
+	if *update {
+		fmt.Printf(", %d golden files written", written)
+	}
+	fmt.Println()
+	if failed > 0 || (changed > 0 && !*update) {
+		os.Exit(1)
+	}
+}
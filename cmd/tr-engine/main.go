@@ -13,14 +13,33 @@ import (
 	"github.com/rs/zerolog"
 	trengine "github.com/snarg/tr-engine"
 	"github.com/snarg/tr-engine/internal/api"
+	"github.com/snarg/tr-engine/internal/ask"
+	"github.com/snarg/tr-engine/internal/audioarchive"
+	"github.com/snarg/tr-engine/internal/audiorecon"
 	"github.com/snarg/tr-engine/internal/audio"
+	"github.com/snarg/tr-engine/internal/bridge"
+	"github.com/snarg/tr-engine/internal/cadmail"
 	"github.com/snarg/tr-engine/internal/config"
 	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/dupaudit"
+	"github.com/snarg/tr-engine/internal/embed"
+	"github.com/snarg/tr-engine/internal/expr"
+	"github.com/snarg/tr-engine/internal/forward"
+	"github.com/snarg/tr-engine/internal/icecast"
 	"github.com/snarg/tr-engine/internal/ingest"
 	"github.com/snarg/tr-engine/internal/mqttclient"
+	"github.com/snarg/tr-engine/internal/replicate"
+	"github.com/snarg/tr-engine/internal/restamp"
+	"github.com/snarg/tr-engine/internal/rtcaudio"
+	"github.com/snarg/tr-engine/internal/selfupdate"
 	"github.com/snarg/tr-engine/internal/storage"
 	"github.com/snarg/tr-engine/internal/transcribe"
 	"github.com/snarg/tr-engine/internal/trconfig"
+	"github.com/snarg/tr-engine/internal/unitalias"
+	"github.com/snarg/tr-engine/internal/unitsessions"
+	"github.com/snarg/tr-engine/internal/unitsync"
+	"github.com/snarg/tr-engine/internal/warehouse"
+	"github.com/snarg/tr-engine/internal/webhook"
 )
 
 // version, commit, and buildTime are injected at build time via ldflags.
@@ -53,13 +72,15 @@ func main() {
 		os.Exit(0)
 	}
 
-	// Check for subcommands (export, import)
+	// Check for subcommands (export, import, conformance)
 	if args := flag.Args(); len(args) > 0 {
 		switch args[0] {
 		case "export":
 			runExport(args[1:], overrides)
 		case "import":
 			runImport(args[1:], overrides)
+		case "conformance":
+			runConformance(args[1:])
 		default:
 			fmt.Fprintf(os.Stderr, "unknown subcommand: %s\n", args[0])
 			os.Exit(1)
@@ -110,6 +131,46 @@ func main() {
 		Str("log_level", level.String()).
 		Msg("tr-engine starting")
 
+	_, dockerErr := os.Stat("/.dockerenv")
+	isDocker := dockerErr == nil
+
+	// Self-update (binary installs): a pending update is verified, or rolled
+	// back after a failed start, before anything touches the database. The
+	// restart into a swapped binary is deferred first so it runs after every
+	// other shutdown step.
+	var updater *selfupdate.Updater
+	var verifyUpdate bool
+	if cfg.SelfUpdatePublicKey != "" {
+		if isDocker {
+			log.Warn().Msg("SELF_UPDATE_PUBLIC_KEY ignored in Docker — pull the new image instead")
+		} else if updater, err = selfupdate.New(selfupdate.Options{
+			ReleasesURL: cfg.SelfUpdateReleasesURL,
+			PublicKey:   cfg.SelfUpdatePublicKey,
+			Version:     version,
+			Log:         log.With().Str("component", "selfupdate").Logger(),
+		}); err != nil {
+			log.Fatal().Err(err).Msg("invalid SELF_UPDATE_PUBLIC_KEY")
+		}
+	}
+	if updater != nil {
+		action, bootErr := updater.Boot()
+		if bootErr != nil {
+			log.Error().Err(bootErr).Msg("self-update startup check failed")
+		}
+		switch action {
+		case selfupdate.BootRolledBack:
+			restartInto(updater, log)
+		case selfupdate.BootVerify:
+			verifyUpdate = true
+			log.Warn().Dur("grace", cfg.SelfUpdateHealthGrace).Msg("running a newly applied update — confirming after health check")
+		}
+		defer func() {
+			if updater.RestartRequested() {
+				restartInto(updater, log)
+			}
+		}()
+	}
+
 	// Context for graceful shutdown
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
@@ -121,6 +182,10 @@ func main() {
 		log.Fatal().Err(err).Msg("failed to connect to database")
 	}
 	defer db.Close()
+	if cfg.DBExplainSample > 0 {
+		db.SetExplainSampling(cfg.DBExplainSample)
+		log.Info().Float64("rate", cfg.DBExplainSample).Msg("query plan sampling enabled")
+	}
 
 	// Auto-apply schema on fresh database (no-op if tables already exist)
 	if err := db.InitSchema(ctx, trengine.SchemaSQL); err != nil {
@@ -132,8 +197,8 @@ func main() {
 		log.Fatal().Err(err).Msg("schema migration failed (run ALTER TABLE manually or grant ALTER privileges)")
 	}
 
-	// Audio storage (local disk default, optional S3)
-	store, bgServices, err := storage.New(cfg.S3, cfg.AudioDir, log)
+	// Audio storage (local disk default, optional S3, Azure Blob or GCS)
+	store, bgServices, err := storage.New(cfg, log)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to initialize audio storage")
 	}
@@ -146,8 +211,11 @@ func main() {
 	// Async uploader (only for tiered stores in async mode)
 	var s3Uploader *storage.AsyncUploader
 	if tiered, ok := store.(*storage.TieredStore); ok && cfg.S3.UploadMode == "async" {
-		s3Uploader = storage.NewAsyncUploader(tiered.S3Store(), 500, log)
-		s3Uploader.Start(2)
+		s3Uploader = storage.NewAsyncUploader(tiered, db, storage.UploaderOptions{
+			Workers:     cfg.S3.UploadWorkers,
+			MaxAttempts: cfg.S3.UploadMaxAttempts,
+		}, log)
+		s3Uploader.Start()
 		// Stopped by pipeline.Stop()
 	}
 
@@ -162,12 +230,17 @@ func main() {
 			Username:  cfg.MQTTUsername,
 			Password:  cfg.MQTTPassword,
 			Log:       mqttLog,
+			InstanceMap:     mqttclient.ParseInstanceMap(cfg.MQTTInstanceMap),
+			SubscribeMapped: cfg.MQTTSubscribeMapped,
+			AckMode:         cfg.MQTTAckMode,
+			MaxInflight:     cfg.MQTTMaxInflight,
 		})
 		if err != nil {
 			log.Fatal().Err(err).Msg("failed to connect to mqtt broker")
 		}
 		defer mqtt.Close()
-		log.Info().Str("broker", cfg.MQTTBrokerURL).Str("client_id", cfg.MQTTClientID).Msg("mqtt connected")
+		log.Info().Str("broker", cfg.MQTTBrokerURL).Str("client_id", cfg.MQTTClientID).
+			Str("ack_mode", cfg.MQTTAckMode).Msg("mqtt connected")
 	} else {
 		log.Info().Msg("mqtt not configured (watch-only mode)")
 	}
@@ -190,13 +263,27 @@ func main() {
 			log.Fatal().Msg("STT_PROVIDER=deepinfra requires DEEPINFRA_STT_API_KEY")
 		}
 		sttProvider = transcribe.NewDeepInfraClient(cfg.DeepInfraAPIKey, cfg.DeepInfraModel, cfg.WhisperTimeout)
+	case "custom":
+		if cfg.CustomSTTURL == "" {
+			log.Fatal().Msg("STT_PROVIDER=custom requires CUSTOM_STT_URL")
+		}
+		headers, err := transcribe.ParseHeaders(cfg.CustomSTTHeaders)
+		if err != nil {
+			log.Fatal().Err(err).Msg("invalid CUSTOM_STT_HEADERS")
+		}
+		sttProvider = transcribe.NewCustomClient(cfg.CustomSTTURL, cfg.CustomSTTModel, headers, cfg.WhisperTimeout)
 	case "none", "":
 		// Transcription explicitly disabled
 	default:
-		log.Fatal().Str("provider", cfg.STTProvider).Msg("unknown STT_PROVIDER (valid: whisper, elevenlabs, deepinfra, none)")
+		log.Fatal().Str("provider", cfg.STTProvider).Msg("unknown STT_PROVIDER (valid: whisper, elevenlabs, deepinfra, custom, none)")
 	}
 
 	if sttProvider != nil {
+		if cfg.WhisperPromptTemplate != "" {
+			if _, err := transcribe.ParsePromptTemplate(cfg.WhisperPromptTemplate); err != nil {
+				log.Fatal().Err(err).Msg("invalid WHISPER_PROMPT_TEMPLATE")
+			}
+		}
 		transcribeOpts = &transcribe.WorkerPoolOptions{
 			DB:              db,
 			AudioDir:        cfg.AudioDir,
@@ -214,6 +301,8 @@ func main() {
 			QueueSize:       cfg.TranscribeQueueSize,
 			MinDuration:     cfg.TranscribeMinDuration,
 			MaxDuration:     cfg.TranscribeMaxDuration,
+			JobDeadline:     cfg.TranscribeJobDeadline,
+			MaxRetries:      cfg.TranscribeMaxRetries,
 			Log:             log.With().Str("component", "transcribe").Logger(),
 
 			RepetitionPenalty:             cfg.WhisperRepetitionPenalty,
@@ -223,6 +312,32 @@ func main() {
 			HallucinationSilenceThreshold: cfg.WhisperHallucinationThreshold,
 			MaxNewTokens:                  cfg.WhisperMaxTokens,
 			VadFilter:                     cfg.WhisperVadFilter,
+
+			PromptAuto:          cfg.WhisperPromptAuto,
+			PromptTemplate:      cfg.WhisperPromptTemplate,
+			PromptContext:       cfg.WhisperPromptContext,
+			PromptContextWindow: cfg.WhisperPromptContextWindow,
+		}
+		switch cfg.UrgencyClassifier {
+		case "keyword":
+			transcribeOpts.Classifier = transcribe.NewKeywordClassifier()
+		case "model":
+			headers, err := transcribe.ParseHeaders(cfg.UrgencyModelHeaders)
+			if err != nil {
+				log.Fatal().Err(err).Msg("invalid URGENCY_MODEL_HEADERS")
+			}
+			transcribeOpts.Classifier = transcribe.NewModelClassifier(cfg.UrgencyModelURL, headers, cfg.UrgencyModelTimeout)
+		}
+		if transcribeOpts.Classifier != nil {
+			log.Info().Str("classifier", transcribeOpts.Classifier.Name()).Msg("urgency classification enabled")
+		}
+		if cfg.TranscribeLongCalls == "segment" {
+			transcribeOpts.SegmentLength = cfg.TranscribeSegmentLength
+			transcribeOpts.SegmentOverlap = cfg.TranscribeSegmentOverlap
+			transcribeOpts.SegmentMaxDuration = cfg.TranscribeSegmentMaxDuration
+			if !audio.CheckFFmpeg() {
+				log.Warn().Msg("TRANSCRIBE_LONG_CALLS=segment: ffmpeg not found, only long WAV calls can be segmented")
+			}
 		}
 		log.Info().
 			Str("provider", sttProvider.Name()).
@@ -231,7 +346,132 @@ func main() {
 			Msg("transcription enabled")
 	}
 
+	// API response cache — shared so the pipeline can invalidate entries
+	var respCache *api.ResponseCache
+	if cfg.APICache {
+		respCache = api.NewResponseCache(cfg.APICacheMaxEntries)
+	}
+
+	var durationTolerance time.Duration
+	if cfg.AudioDurationCheck {
+		durationTolerance = cfg.AudioDurationTolerance
+	}
+
+	filenamePatterns, err := ingest.ParseFilenamePatterns(cfg.FilenamePatterns)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid FILENAME_PATTERNS")
+	}
+
+	rawRedact, err := ingest.ParseRawRedact(cfg.RawRedact)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid RAW_REDACT")
+	}
+
+	maintenanceDisabled := splitCSV(cfg.MaintenanceDisabledTasks)
+	for _, task := range maintenanceDisabled {
+		if !database.ValidMaintenanceTask(task) {
+			log.Fatal().Str("task", task).Strs("valid", database.MaintenanceTasks).Msg("invalid MAINTENANCE_DISABLED_TASKS")
+		}
+	}
+
 	// Ingest Pipeline
+	// Event bridge to Kafka/NATS (optional). Created before the pipeline so
+	// it sees every event; stopped after the pipeline so queued events drain.
+	var eventBridge *bridge.Bridge
+	if cfg.BridgeDriver != "" {
+		urls := splitCSV(cfg.BridgeURLs)
+		var pub bridge.Publisher
+		switch cfg.BridgeDriver {
+		case "nats":
+			pub = bridge.NewNATSPublisher(bridge.NATSOptions{
+				URLs: urls, Username: cfg.BridgeUsername, Password: cfg.BridgePassword, Token: cfg.BridgeToken,
+			})
+		case "kafka-rest":
+			pub = bridge.NewKafkaRESTPublisher(bridge.KafkaRESTOptions{
+				URLs: urls, Username: cfg.BridgeUsername, Password: cfg.BridgePassword,
+			})
+		}
+		var bridgeFilter *expr.Program
+		if cfg.BridgeFilter != "" {
+			bridgeFilter, err = expr.Compile(cfg.BridgeFilter)
+			if err != nil {
+				log.Fatal().Err(err).Msg("invalid BRIDGE_FILTER")
+			}
+		}
+		eventBridge = bridge.New(pub, bridge.Options{
+			Events:       splitCSV(cfg.BridgeEvents),
+			TopicPrefix:  cfg.BridgeTopicPrefix,
+			PartitionKey: cfg.BridgePartitionKey,
+			Subjects:     cfg.BridgeDriver == "nats",
+			Buffer:       cfg.BridgeBuffer,
+			Filter:       bridgeFilter,
+		}, log)
+		eventBridge.Start()
+		defer eventBridge.Stop(10 * time.Second)
+		log.Info().
+			Str("driver", cfg.BridgeDriver).
+			Strs("urls", urls).
+			Str("events", cfg.BridgeEvents).
+			Str("filter", cfg.BridgeFilter).
+			Msg("event bridge enabled")
+	}
+
+	// Webhooks: POST call_end, transcription and alert events to the
+	// endpoints managed at /api/v1/webhooks
+	webhooks := webhook.New(db, webhook.Options{
+		Timeout:     cfg.WebhookTimeout,
+		MaxAttempts: cfg.WebhookMaxAttempts,
+	}, log)
+	if err := webhooks.Reload(ctx); err != nil {
+		log.Warn().Err(err).Msg("failed to load webhooks")
+	}
+	webhooks.Start()
+	defer webhooks.Stop()
+
+	// Icecast output (optional): stream finished calls to Icecast mounts,
+	// one per talkgroup group
+	var icecastStreamer *icecast.Streamer
+	var icecastStatus func() any
+	if cfg.IcecastURL != "" {
+		mounts, err := icecast.LoadMounts(cfg.IcecastMounts)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to load ICECAST_MOUNTS")
+		}
+		icecastStreamer = icecast.New(db, store, mounts, icecast.Options{
+			URL:        cfg.IcecastURL,
+			Username:   cfg.IcecastUsername,
+			Password:   cfg.IcecastPassword,
+			Bitrate:    cfg.IcecastBitrate,
+			Silence:    cfg.IcecastSilence,
+			AudioDir:   cfg.AudioDir,
+			TRAudioDir: cfg.TRAudioDir,
+		}, log)
+		if err := icecastStreamer.Start(); err != nil {
+			log.Error().Err(err).Msg("icecast output disabled (needs ffmpeg with libmp3lame)")
+			icecastStreamer = nil
+		} else {
+			defer icecastStreamer.Stop()
+			icecastStatus = func() any { return icecastStreamer.Status() }
+			log.Info().
+				Str("url", cfg.IcecastURL).
+				Int("mounts", len(mounts)).
+				Msg("icecast output enabled")
+		}
+	}
+
+	sinks := []func(api.SSEEvent){webhooks.Enqueue}
+	if eventBridge != nil {
+		sinks = append(sinks, eventBridge.Enqueue)
+	}
+	if icecastStreamer != nil {
+		sinks = append(sinks, icecastStreamer.Enqueue)
+	}
+	eventSink := func(e api.SSEEvent) {
+		for _, sink := range sinks {
+			sink(e)
+		}
+	}
+
 	pipeline := ingest.NewPipeline(ingest.PipelineOptions{
 		DB:               db,
 		AudioDir:         cfg.AudioDir,
@@ -239,19 +479,50 @@ func main() {
 		RawStore:         cfg.RawStore,
 		RawIncludeTopics:  cfg.RawIncludeTopics,
 		RawExcludeTopics:  cfg.RawExcludeTopics,
+		RawRedact:         rawRedact,
 		MergeP25Systems:   cfg.MergeP25Systems,
 		MQTTInstanceMap:   cfg.MQTTInstanceMap,
+		IngestValidation:  cfg.IngestValidation,
+		DenyAutoCreate:    cfg.IngestAutoCreate == "deny",
+		InvalidateCache:   respCache.Invalidate,
 		TranscribeOpts:    transcribeOpts,
 		TranscribeInclude: cfg.TranscribeIncludeTGIDs,
 		TranscribeExclude: cfg.TranscribeExcludeTGIDs,
+		AudioDurationTolerance: durationTolerance,
+		AudioDurationCorrect:   cfg.AudioDurationCorrect,
+		AudioUnusableCheck:     cfg.AudioUnusableCheck,
+		AudioSilenceLevel:      cfg.AudioSilenceLevel,
+		AudioUnusableDelete:    cfg.AudioUnusableDelete,
+		StuckMicMinDuration:       cfg.StuckMicMinDuration,
+		StuckMicMaxSpeechRatio:    cfg.StuckMicMaxSpeechRatio,
+		StuckMicSkipTranscription: cfg.StuckMicSkipTranscription,
+		SplitCallMaxGap:           cfg.SplitCallMaxGap,
+		AudioDedupWindow:          cfg.AudioDedupWindow,
+		MQTTAckRetryInterval:      cfg.MQTTAckRetryInterval,
+		FilenamePatterns:          filenamePatterns,
 		RetentionRawMessages:  cfg.RetentionRawMessages,
 		RetentionConsoleLogs:  cfg.RetentionConsoleLogs,
 		RetentionPluginStatus: cfg.RetentionPluginStatus,
 		RetentionCheckpoints:  cfg.RetentionCheckpoints,
 		RetentionStaleCalls:   cfg.RetentionStaleCalls,
+		RetentionInactiveUnits: cfg.RetentionInactiveUnits,
+		RetentionQuarantine:    cfg.RetentionQuarantine,
+		RetentionExternalEvents: cfg.RetentionExternalEvents,
+		RetentionWebhookDeliveries: cfg.RetentionWebhookDeliveries,
+		RetentionTimeseries:    cfg.RetentionTimeseries,
+		RetentionUnitEvents:    cfg.RetentionUnitEvents,
+		RetentionUnitSessions:  cfg.RetentionUnitSessions,
+		RetentionCalls:         cfg.RetentionCalls,
+		RetentionCallAudio:     cfg.RetentionCallAudio,
+		AudioDiskMaxPercent:    cfg.AudioDiskMaxPercent,
+		AudioRetentionKeepPinned: cfg.AudioRetentionKeepPinned,
+		MaintenanceDryRun:        cfg.MaintenanceDryRun,
+		MaintenanceObserveCycles: cfg.MaintenanceObserveCycles,
+		MaintenanceDisabledTasks: maintenanceDisabled,
 		StreamListen:      cfg.StreamListen,
 		StreamIdleTimeout: cfg.StreamIdleTimeout,
 		StreamOpusBitrate: cfg.StreamOpusBitrate,
+		EventSink:         eventSink,
 		Store:            store,
 		S3Uploader:       s3Uploader,
 		Log:              log,
@@ -263,19 +534,24 @@ func main() {
 
 	// Wire MQTT → Pipeline
 	if mqtt != nil {
-		mqtt.SetMessageHandler(pipeline.HandleMessage)
+		if cfg.MQTTAckMode == mqttclient.AckOnProcessed {
+			mqtt.SetProcessHandler(pipeline.ProcessMessage)
+		} else {
+			mqtt.SetMessageHandler(pipeline.HandleMessage)
+		}
 	}
 
 	// Import talkgroup directory from TR's CSV files (if TR_DIR discovery found any)
 	// Also build CSV path maps for talkgroup and unit writeback on edit
 	tgCSVPaths := make(map[int]string)
 	unitCSVPaths := make(map[int]string)
+	unitCSVFiles := make(map[int]string) // all discovered unit CSVs, for sync
 	if discovered != nil {
 		for _, sys := range discovered.Systems {
 			// Resolve identity once per system (needed for both TG and unit imports)
 			var systemID int
 			var identityResolved bool
-			if len(sys.Talkgroups) > 0 || len(sys.Units) > 0 {
+			if len(sys.Talkgroups) > 0 || sys.UnitCSVPath != "" {
 				identity, idErr := pipeline.ResolveIdentity(ctx, cfg.WatchInstanceID, sys.ShortName)
 				if idErr != nil {
 					log.Warn().Err(idErr).Str("system", sys.ShortName).Msg("failed to resolve system for CSV import")
@@ -318,24 +594,12 @@ func main() {
 				}
 			}
 
-			// Import unit tags
-			if len(sys.Units) > 0 {
-				if cfg.CSVWriteback && sys.UnitCSVPath != "" {
+			// Unit tags are imported by the unit CSV syncer below
+			if sys.UnitCSVPath != "" {
+				unitCSVFiles[systemID] = sys.UnitCSVPath
+				if cfg.CSVWriteback {
 					unitCSVPaths[systemID] = sys.UnitCSVPath
 				}
-				imported := 0
-				for _, u := range sys.Units {
-					if uErr := db.ImportUnitTag(ctx, systemID, u.UnitID, u.AlphaTag); uErr != nil {
-						log.Warn().Err(uErr).Int("unit_id", u.UnitID).Msg("failed to import unit tag")
-						continue
-					}
-					imported++
-				}
-				log.Info().
-					Str("system", sys.ShortName).
-					Int("imported", imported).
-					Int("total", len(sys.Units)).
-					Msg("unit tags imported")
 			}
 		}
 	}
@@ -344,12 +608,75 @@ func main() {
 			Msg("CSV writeback enabled — edits will be written back to TR's CSV files")
 	}
 
+	// Register TR's SDR sources from config.json. A running instance's config
+	// message refreshes them via MQTT too.
+	if discovered != nil && len(discovered.Sources) > 0 {
+		sources := make([]database.Source, len(discovered.Sources))
+		for i, src := range discovered.Sources {
+			minHz, maxHz := src.Band()
+			sources[i] = database.Source{
+				SourceNum:        i,
+				Device:           src.Device,
+				Driver:           src.Driver,
+				Antenna:          src.Antenna,
+				CenterHz:         int64(src.Center),
+				RateHz:           int64(src.Rate),
+				MinHz:            minHz,
+				MaxHz:            maxHz,
+				ErrorHz:          int(src.Error),
+				Gain:             trconfig.FormatGain(src.Gain),
+				AnalogRecorders:  src.AnalogRecorders,
+				DigitalRecorders: src.DigitalRecorders,
+			}
+		}
+		if err := db.SyncSources(ctx, cfg.WatchInstanceID, "tr_dir", sources, time.Now()); err != nil {
+			log.Warn().Err(err).Msg("failed to register TR sources")
+		} else {
+			log.Info().Int("sources", len(sources)).Str("instance_id", cfg.WatchInstanceID).Msg("TR sources registered")
+		}
+	}
+
+	// Unit tag sync: initial import now, then periodic re-sync
+	if len(unitCSVFiles) > 0 {
+		unitSync := unitsync.NewSyncer(db, unitCSVFiles, cfg.UnitCSVWriteback, cfg.UnitCSVSyncInterval, log)
+		unitSync.SyncAll(ctx)
+		unitSync.Start()
+		defer unitSync.Stop()
+		log.Info().
+			Int("unit_csvs", len(unitCSVFiles)).
+			Dur("interval", cfg.UnitCSVSyncInterval).
+			Bool("writeback", cfg.UnitCSVWriteback).
+			Msg("unit CSV sync enabled")
+	}
+
+	// Unit alias inference: propose alpha tags for untagged units from transcripts
+	if cfg.UnitAliasInterval > 0 {
+		aliasInferrer := unitalias.NewInferrer(db, cfg.UnitAliasInterval, cfg.UnitAliasLookback, log)
+		aliasInferrer.Start()
+		defer aliasInferrer.Stop()
+		log.Info().
+			Dur("interval", cfg.UnitAliasInterval).
+			Dur("lookback", cfg.UnitAliasLookback).
+			Msg("unit alias inference enabled")
+	}
+
+	// Unit session compaction: fold unit_events into unit_sessions
+	if cfg.UnitSessionInterval > 0 {
+		sessionCompactor := unitsessions.NewCompactor(db, cfg.UnitSessionInterval, cfg.UnitSessionIdle, cfg.UnitSessionBackfill, log)
+		sessionCompactor.Start()
+		defer sessionCompactor.Stop()
+		log.Info().
+			Dur("interval", cfg.UnitSessionInterval).
+			Dur("idle", cfg.UnitSessionIdle).
+			Msg("unit session compaction enabled")
+	}
+
 	// File watcher (optional — alternative to MQTT ingest)
 	if cfg.WatchDir != "" {
-		if err := pipeline.StartWatcher(cfg.WatchDir, cfg.WatchInstanceID, cfg.WatchBackfillDays); err != nil {
+		if err := pipeline.StartWatcher(cfg.WatchDir, cfg.WatchInstanceID, cfg.WatchBackfillDays, cfg.WatchAudioOnly); err != nil {
 			log.Fatal().Err(err).Msg("failed to start file watcher")
 		}
-		log.Info().Str("watch_dir", cfg.WatchDir).Str("instance_id", cfg.WatchInstanceID).Msg("file watcher started")
+		log.Info().Str("watch_dir", cfg.WatchDir).Str("instance_id", cfg.WatchInstanceID).Bool("audio_only", cfg.WatchAudioOnly).Msg("file watcher started")
 	}
 
 	// Start live audio streaming if configured
@@ -365,6 +692,42 @@ func main() {
 		}
 	}
 
+	// WebRTC live audio (optional): the same frames sent to browser peers
+	var webrtcPub *rtcaudio.Publisher
+	if cfg.WebRTCEnabled && pipeline.AudioStreamEnabled() {
+		var iceServers []string
+		for _, s := range strings.Split(cfg.WebRTCICEServers, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				iceServers = append(iceServers, s)
+			}
+		}
+		portMin, portMax, _ := config.PortRange(cfg.WebRTCUDPPorts) // checked by Validate
+		maxPeers := cfg.WebRTCMaxPeers
+		if maxPeers == 0 {
+			maxPeers = cfg.StreamMaxClients
+		}
+		var err error
+		webrtcPub, err = rtcaudio.New(pipeline, rtcaudio.Options{
+			ICEServers:    iceServers,
+			ICEUsername:   cfg.WebRTCICEUsername,
+			ICECredential: cfg.WebRTCICECredential,
+			PortMin:       portMin,
+			PortMax:       portMax,
+			PublicIP:      cfg.WebRTCPublicIP,
+			MaxPeers:      maxPeers,
+			Lanes:         cfg.WebRTCLanes,
+		}, log)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to start WebRTC live audio")
+		}
+		defer webrtcPub.Stop()
+		log.Info().
+			Str("udp_ports", cfg.WebRTCUDPPorts).
+			Int("max_peers", maxPeers).
+			Int("lanes", cfg.WebRTCLanes).
+			Msg("WebRTC live audio enabled")
+	}
+
 	// Auth status
 	if !cfg.AuthEnabled {
 		log.Warn().Msg("AUTH_ENABLED=false — API authentication is disabled, all endpoints are open")
@@ -388,11 +751,258 @@ func main() {
 		ingestModes = append(ingestModes, "watch")
 	}
 	ingestModes = append(ingestModes, "upload") // always available
-	_, dockerErr := os.Stat("/.dockerenv")
-	isDocker := dockerErr == nil
 
 	// HTTP Server
 	httpLog := log.With().Str("component", "http").Logger()
+	// Transcript embeddings (optional): semantic search over transcripts
+	var embedder *embed.Embedder
+	if cfg.EmbedURL != "" {
+		headers, err := transcribe.ParseHeaders(cfg.EmbedHeaders)
+		if err != nil {
+			log.Fatal().Err(err).Msg("invalid EMBED_HEADERS")
+		}
+		if err := db.EnsureEmbeddingSchema(ctx, cfg.EmbedDimensions); err != nil {
+			log.Fatal().Err(err).Msg("failed to set up transcript embeddings")
+		}
+		client := embed.NewClient(cfg.EmbedURL, cfg.EmbedModel, cfg.EmbedDimensions, headers, cfg.EmbedTimeout)
+		embedder = embed.NewEmbedder(db, client, cfg.EmbedDimensions, embed.Options{
+			Interval:  cfg.EmbedInterval,
+			Lookback:  cfg.EmbedLookback,
+			BatchSize: cfg.EmbedBatchSize,
+		}, log)
+		embedder.Start()
+		defer embedder.Stop()
+		log.Info().
+			Str("model", cfg.EmbedModel).
+			Int("dimensions", cfg.EmbedDimensions).
+			Dur("interval", cfg.EmbedInterval).
+			Msg("transcript embeddings enabled")
+	}
+
+	// Archive Q&A (optional): answer questions from transcripts with an LLM
+	var asker *ask.Service
+	if cfg.LLMUrl != "" {
+		headers, err := transcribe.ParseHeaders(cfg.LLMHeaders)
+		if err != nil {
+			log.Fatal().Err(err).Msg("invalid LLM_HEADERS")
+		}
+		llm := ask.NewChatClient(cfg.LLMUrl, cfg.LLMModel, headers, cfg.LLMTimeout)
+		asker = ask.NewService(db, llm, ask.Options{MaxContext: cfg.AskMaxContext, Embedder: embedder}, log)
+		if cfg.LLMTimeout >= cfg.WriteTimeout {
+			log.Warn().
+				Dur("llm_timeout", cfg.LLMTimeout).
+				Dur("write_timeout", cfg.WriteTimeout).
+				Msg("LLM_TIMEOUT is not below HTTP_WRITE_TIMEOUT; slow answers will time out as 503")
+		}
+		log.Info().
+			Str("model", cfg.LLMModel).
+			Float64("rate_limit_per_min", cfg.AskRateLimit).
+			Msg("archive Q&A enabled")
+	}
+
+	// TR_AUDIO_DIR archiving (optional): copy TR-served audio into our store
+	// before trunk-recorder purges its capture dir
+	var audioArchiver *audioarchive.Archiver
+	if cfg.TRAudioArchive != "off" {
+		if cfg.TRAudioDir == "" {
+			log.Warn().Msg("TR_AUDIO_ARCHIVE is set but TR_AUDIO_DIR is not; audio is already saved by tr-engine, archiving disabled")
+		} else {
+			audioArchiver = audioarchive.New(db, store, audioarchive.Options{
+				Mode:       cfg.TRAudioArchive,
+				AudioDir:   cfg.AudioDir,
+				TRAudioDir: cfg.TRAudioDir,
+				Delay:      cfg.TRAudioArchiveDelay,
+				Window:     cfg.TRAudioPurgeWindow,
+				Interval:   cfg.TRAudioArchiveInterval,
+			}, log)
+			audioArchiver.Start()
+			defer audioArchiver.Stop()
+			log.Info().
+				Str("mode", cfg.TRAudioArchive).
+				Dur("delay", cfg.TRAudioArchiveDelay).
+				Dur("purge_window", cfg.TRAudioPurgeWindow).
+				Msg("TR audio archiving enabled")
+		}
+	}
+
+	// Late-audio reconciliation (optional): attach capture-dir files to calls
+	// whose audio MQTT message was lost
+	var audioReconciler *audiorecon.Reconciler
+	if cfg.AudioReconcile {
+		if cfg.WatchDir == "" && cfg.TRAudioDir == "" {
+			log.Warn().Msg("AUDIO_RECONCILE is set but neither WATCH_DIR nor TR_AUDIO_DIR is; nowhere to look for audio, reconciliation disabled")
+		} else {
+			audioReconciler = audiorecon.New(db, pipeline, audiorecon.Options{
+				Dirs:        []string{cfg.WatchDir, cfg.TRAudioDir},
+				Delay:       cfg.AudioReconcileDelay,
+				Window:      cfg.AudioReconcileWindow,
+				Interval:    cfg.AudioReconcileInterval,
+				Tolerance:   cfg.AudioReconcileTolerance,
+				MaxAttempts: cfg.AudioReconcileAttempts,
+			}, log)
+			audioReconciler.Start()
+			defer audioReconciler.Stop()
+			log.Info().
+				Dur("delay", cfg.AudioReconcileDelay).
+				Dur("window", cfg.AudioReconcileWindow).
+				Dur("tolerance", cfg.AudioReconcileTolerance).
+				Msg("late-audio reconciliation enabled")
+		}
+	}
+
+	// Edge-to-central replication (optional): push finished calls to a
+	// central tr-engine's upload API
+	var replicator *replicate.Replicator
+	if cfg.ReplicateURL != "" {
+		window, err := replicate.ParseWindow(cfg.ReplicateWindow)
+		if err != nil {
+			log.Fatal().Err(err).Msg("invalid REPLICATE_WINDOW")
+		}
+		replicator = replicate.New(db, store, replicate.Options{
+			URL:      cfg.ReplicateURL,
+			Token:    cfg.ReplicateToken,
+			MaxKbps:  cfg.ReplicateMaxKbps,
+			Window:   window,
+			Delay:    cfg.ReplicateDelay,
+			Backfill: cfg.ReplicateBackfill,
+			Interval: cfg.ReplicateInterval,
+		}, log)
+		replicator.Start()
+		defer replicator.Stop()
+		log.Info().
+			Str("url", cfg.ReplicateURL).
+			Int("max_kbps", cfg.ReplicateMaxKbps).
+			Str("window", window.String()).
+			Dur("backfill", cfg.ReplicateBackfill).
+			Msg("call replication enabled")
+	}
+
+	// rdio-scanner forwarding (optional): relay finished calls on enabled
+	// systems to a downstream rdio-scanner
+	var forwarder *forward.Forwarder
+	if cfg.ForwardURL != "" {
+		forwarder = forward.New(db, store, forward.Options{
+			URL:      cfg.ForwardURL,
+			APIKey:   cfg.ForwardAPIKey,
+			Delay:    cfg.ForwardDelay,
+			Backfill: cfg.ForwardBackfill,
+			Interval: cfg.ForwardInterval,
+		}, log)
+		forwarder.Start()
+		defer forwarder.Stop()
+		log.Info().
+			Str("url", cfg.ForwardURL).
+			Dur("backfill", cfg.ForwardBackfill).
+			Msg("call forwarding enabled")
+	}
+
+	// Duplicate call audit: nightly scan for overlapping calls ingest didn't group
+	var duplicateAuditor *dupaudit.Auditor
+	if cfg.DuplicateAudit {
+		duplicateAuditor = dupaudit.New(db, dupaudit.Options{
+			Hour:          cfg.DuplicateAuditHour,
+			Lookback:      cfg.DuplicateAuditLookback,
+			MaxStartGap:   cfg.DuplicateAuditMaxStartGap,
+			MinConfidence: cfg.DuplicateAuditMinConfidence,
+			AutoGroup:     cfg.DuplicateAuditAutoGroup,
+		}, log)
+		duplicateAuditor.Start()
+		defer duplicateAuditor.Stop()
+		log.Info().
+			Int("hour", cfg.DuplicateAuditHour).
+			Float64("auto_group", cfg.DuplicateAuditAutoGroup).
+			Msg("duplicate call audit enabled")
+	}
+
+	// Call re-stamping: rewrite talkgroup/unit names on stored calls on demand,
+	// and after directory imports when CALL_RESTAMP_AFTER_IMPORT is set
+	restamper := restamp.New(db, cfg.CallRestampAfterImport, log)
+	defer restamper.Stop()
+
+	// CAD pages by email (optional): parse dispatch pages, link incidents to calls
+	var cadIngester *cadmail.Ingester
+	if cfg.CADPageFormats != "" {
+		formats, err := cadmail.LoadFormats(cfg.CADPageFormats)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to load CAD_PAGE_FORMATS")
+		}
+		cadIngester = cadmail.NewIngester(db, formats, log)
+		cadIngester.Start()
+		defer cadIngester.Stop()
+		var allowed []string
+		for _, s := range strings.Split(cfg.CADAllowedSenders, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				allowed = append(allowed, s)
+			}
+		}
+		if cfg.CADSMTPListen != "" {
+			hostname, _ := os.Hostname()
+			smtpSrv := cadmail.NewSMTPServer(hostname, cfg.CADMaxMessageSize, allowed, cadIngester.Deliver, log)
+			if err := smtpSrv.Listen(cfg.CADSMTPListen); err != nil {
+				log.Fatal().Err(err).Str("listen", cfg.CADSMTPListen).Msg("failed to start CAD SMTP listener")
+			}
+			defer smtpSrv.Close()
+		}
+		log.Info().
+			Int("formats", len(formats)).
+			Str("smtp_listen", cfg.CADSMTPListen).
+			Int("allowed_senders", len(allowed)).
+			Msg("CAD page ingestion enabled")
+	}
+
+	// Bulk re-transcription: jobs run on the transcription pool's options
+	// with any configured provider
+	var retranscriber *transcribe.Retranscriber
+	if pool := pipeline.Transcriber(); pool != nil {
+		costRates, err := transcribe.ParseCostRates(cfg.STTCostPerMinute)
+		if err != nil {
+			log.Fatal().Err(err).Msg("invalid STT_COST_PER_MINUTE")
+		}
+		retranscriber = transcribe.NewRetranscriber(transcribe.RetranscriberOptions{
+			Pool: pool,
+			DB:   db,
+			NewProvider: func(name, model string) (transcribe.Provider, error) {
+				return newSTTProvider(cfg, name, model)
+			},
+			CostPerMinute:  costRates,
+			MaxConcurrency: cfg.RetranscribeMaxConcurrency,
+			Log:            log.With().Str("component", "retranscribe").Logger(),
+		})
+		retranscriber.Start()
+		defer retranscriber.Stop()
+	}
+
+	// Data warehouse export (optional): completed days to Parquet on disk or S3
+	var warehouseExporter *warehouse.Exporter
+	if cfg.WarehouseExport != "off" {
+		var sink warehouse.Sink
+		switch cfg.WarehouseExport {
+		case "local":
+			sink = warehouse.NewLocalSink(cfg.WarehouseDir)
+		case "s3":
+			s3cfg := cfg.S3
+			if cfg.WarehouseS3Bucket != "" {
+				s3cfg.Bucket = cfg.WarehouseS3Bucket
+			}
+			s3store, err := storage.NewS3Store(s3cfg, log)
+			if err != nil {
+				log.Fatal().Err(err).Msg("warehouse S3 init failed")
+			}
+			sink = warehouse.NewS3Sink(s3store, s3cfg.Bucket, cfg.WarehouseS3Prefix)
+		}
+		warehouseExporter = warehouse.New(db, sink, warehouse.Options{
+			Delay:        cfg.WarehouseExportDelay,
+			BackfillDays: cfg.WarehouseBackfillDays,
+		}, log)
+		warehouseExporter.Start()
+		defer warehouseExporter.Stop()
+		log.Info().
+			Str("sink", sink.Type()).
+			Str("location", sink.Location("")).
+			Dur("delay", cfg.WarehouseExportDelay).
+			Msg("warehouse export enabled")
+	}
+
 	srv := api.NewServer(api.ServerOptions{
 		Config:         cfg,
 		DB:             db,
@@ -400,6 +1010,7 @@ func main() {
 		Live:           pipeline,
 		Uploader:       pipeline, // Pipeline implements CallUploader via ProcessUpload
 		AudioStreamer:  pipeline, // Pipeline implements AudioStreamer via AudioBus
+		WebRTC:         webrtcPub,
 		Store:          store,
 		WebFiles:       trengine.WebFiles,
 		OpenAPISpec:    trengine.OpenAPISpec,
@@ -407,13 +1018,60 @@ func main() {
 		StartTime:      startTime,
 		Log:            httpLog,
 		OnSystemMerge:  pipeline.RewriteSystemID,
+		OnUnitMerge:    pipeline.RewriteUnitID,
+		OnIdentityPolicyChange: pipeline.ReloadIdentityPolicies,
+		OnEncryptionPolicyChange: pipeline.ReloadEncryptionPolicies,
+		OnStoragePolicyChange: pipeline.ReloadStoragePolicies,
+		OnEmbargoChange: pipeline.ReloadEmbargoes,
+		OnIngestPolicyChange: pipeline.ReloadIngestPolicies,
+		OnLegalHoldChange: pipeline.ReloadLegalHolds,
+		OnEnrichmentHookChange: pipeline.ReloadEnrichmentHooks,
+		OnMetricsTalkgroupChange: pipeline.ReloadMetricsTalkgroups,
+		OnAlertRuleChange: pipeline.ReloadAlertRules,
+		OnWebhookChange: webhooks.Reload,
+		CheckWebhookTemplate: webhook.CheckTemplate,
+		OnDirectoryImport: restamper.AfterImport,
+		Cache:          respCache,
 		TGCSVPaths:     tgCSVPaths,
 		UnitCSVPaths:   unitCSVPaths,
+		Asker:          asker,
+		Embedder:       embedder,
+		AudioArchiver:  audioArchiver,
+		AudioReconciler: audioReconciler,
+		Replicator:     replicator,
+		Forwarder:      forwarder,
+		IcecastStatus:  icecastStatus,
+		DuplicateAuditor: duplicateAuditor,
+		Warehouse:      warehouseExporter,
+		CADIngester:    cadIngester,
+		S3Uploader:     s3Uploader,
+		Retranscriber:  retranscriber,
+		Restamper:      restamper,
 		UpdateCheckURL: func() string { if cfg.UpdateCheck { return cfg.UpdateCheckURL }; return "" }(),
 		IngestModes:    strings.Join(ingestModes, ","),
 		IsDocker:       isDocker,
+		Updater:        updater,
+		OnRestart:      stop,
 	})
 	srv.StartUpdateChecker(ctx)
+	if verifyUpdate {
+		go func() {
+			err := updater.Verify(ctx, cfg.SelfUpdateHealthGrace, func(ctx context.Context) error {
+				if err := db.HealthCheck(ctx); err != nil {
+					return fmt.Errorf("database: %w", err)
+				}
+				if mqtt != nil && !mqtt.IsConnected() {
+					return fmt.Errorf("mqtt not connected")
+				}
+				return nil
+			})
+			if err != nil && updater.RestartRequested() {
+				log.Error().Err(err).Msg("update failed its health check — restarting into the previous version")
+				stop()
+			}
+		}()
+	}
+	srv.StartStatusSampler(ctx)
 
 	// Start HTTP server in background
 	errCh := make(chan error, 1)
@@ -447,3 +1105,70 @@ func main() {
 
 	log.Info().Msg("tr-engine stopped")
 }
+
+// newSTTProvider builds an STT provider by name from the credentials
+// configured for STT_PROVIDER, for re-transcription jobs. An empty model
+// selects the configured one.
+func newSTTProvider(cfg *config.Config, name, model string) (transcribe.Provider, error) {
+	switch name {
+	case "whisper":
+		if cfg.WhisperURL == "" {
+			return nil, fmt.Errorf("provider whisper requires WHISPER_URL")
+		}
+		if model == "" {
+			model = cfg.WhisperModel
+		}
+		return transcribe.NewWhisperClient(cfg.WhisperURL, model, cfg.WhisperAPIKey, cfg.WhisperTimeout), nil
+	case "elevenlabs":
+		if cfg.ElevenLabsAPIKey == "" {
+			return nil, fmt.Errorf("provider elevenlabs requires ELEVENLABS_API_KEY")
+		}
+		if model == "" {
+			model = cfg.ElevenLabsModel
+		}
+		return transcribe.NewElevenLabsClient(cfg.ElevenLabsAPIKey, model, cfg.ElevenLabsKeyterms, cfg.WhisperTimeout), nil
+	case "deepinfra":
+		if cfg.DeepInfraAPIKey == "" {
+			return nil, fmt.Errorf("provider deepinfra requires DEEPINFRA_STT_API_KEY")
+		}
+		if model == "" {
+			model = cfg.DeepInfraModel
+		}
+		return transcribe.NewDeepInfraClient(cfg.DeepInfraAPIKey, model, cfg.WhisperTimeout), nil
+	case "custom":
+		if cfg.CustomSTTURL == "" {
+			return nil, fmt.Errorf("provider custom requires CUSTOM_STT_URL")
+		}
+		headers, err := transcribe.ParseHeaders(cfg.CustomSTTHeaders)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CUSTOM_STT_HEADERS: %w", err)
+		}
+		if model == "" {
+			model = cfg.CustomSTTModel
+		}
+		return transcribe.NewCustomClient(cfg.CustomSTTURL, model, headers, cfg.WhisperTimeout), nil
+	}
+	return nil, fmt.Errorf("unknown provider %q (valid: whisper, elevenlabs, deepinfra, custom)", name)
+}
+
+// restartInto replaces the process with the binary the updater swapped in.
+// Where that isn't possible it exits, leaving the restart to the service
+// manager.
+func restartInto(u *selfupdate.Updater, log zerolog.Logger) {
+	log.Warn().Msg("restarting into swapped binary")
+	if err := u.Restart(); err != nil {
+		log.Warn().Err(err).Msg("exiting for the service manager to restart tr-engine")
+		os.Exit(1)
+	}
+}
+
+// splitCSV splits a comma-separated setting, dropping blanks.
+func splitCSV(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
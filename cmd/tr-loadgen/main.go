@@ -0,0 +1,147 @@
+// tr-loadgen publishes synthetic trunk-recorder MQTT traffic to a broker a
+// tr-engine instance subscribes to, and reports the throughput it achieved
+// and, with a database URL, how long calls took to land in the database.
+// Use it against a test database to size hardware before going live.
+//
+//	tr-loadgen -mqtt-url tcp://localhost:1883 -calls-per-day 50000 -duration 10m \
+//	    -database-url postgres://...
+//
+// See docs/load-testing.md.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/snarg/tr-engine/internal/loadgen"
+)
+
+func main() {
+	var cfg loadgen.Config
+	var (
+		brokerURL = flag.String("mqtt-url", envOr("MQTT_BROKER_URL", "tcp://localhost:1883"), "MQTT broker URL")
+		username  = flag.String("mqtt-username", os.Getenv("MQTT_USERNAME"), "MQTT username")
+		password  = flag.String("mqtt-password", os.Getenv("MQTT_PASSWORD"), "MQTT password")
+		qos       = flag.Int("qos", 0, "MQTT QoS (0, 1 or 2)")
+		dbURL     = flag.String("database-url", os.Getenv("DATABASE_URL"), "PostgreSQL URL of the tr-engine database, to measure ingest lag (optional)")
+		probeN    = flag.Int("probe-every", 10, "Measure ingest lag for every Nth call")
+		duration  = flag.Duration("duration", 5*time.Minute, "How long to run (0 = until interrupted)")
+		report    = flag.Duration("report", 10*time.Second, "Report interval")
+	)
+	flag.StringVar(&cfg.TopicPrefix, "topic-prefix", loadgen.DefaultTopicPrefix, "Topic prefix: publishes to {prefix}/feeds/..., {prefix}/units/..., {prefix}/messages/...")
+	flag.StringVar(&cfg.InstanceID, "instance-id", loadgen.DefaultInstanceID, "instance_id in generated messages")
+	flag.IntVar(&cfg.Systems, "systems", 1, "Number of systems")
+	flag.IntVar(&cfg.Talkgroups, "talkgroups", 200, "Talkgroups per system")
+	flag.IntVar(&cfg.Units, "units", 2000, "Units per system")
+	flag.Float64Var(&cfg.CallsPerDay, "calls-per-day", 50000, "Call rate, all systems")
+	flag.Float64Var(&cfg.TrunkingRate, "trunking-rate", 20, "Trunking messages per second, all systems")
+	flag.Float64Var(&cfg.UnitEventRate, "unit-event-rate", 2, "Unit registrations/affiliations per second, besides call events")
+	flag.BoolVar(&cfg.Audio, "audio", true, "Send an audio message (WAV) with each call")
+	flag.Int64Var(&cfg.Seed, "seed", 0, "Random seed (0 = time-based)")
+	flag.Parse()
+
+	if *qos < 0 || *qos > 2 {
+		fatal("qos must be 0, 1 or 2")
+	}
+	if *probeN < 1 {
+		*probeN = 1
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(*brokerURL).
+		SetClientID(fmt.Sprintf("tr-loadgen-%d", os.Getpid())).
+		SetAutoReconnect(true)
+	if *username != "" {
+		opts.SetUsername(*username)
+	}
+	if *password != "" {
+		opts.SetPassword(*password)
+	}
+	client := mqtt.NewClient(opts)
+	if t := client.Connect(); t.Wait() && t.Error() != nil {
+		fatal("mqtt connect: " + t.Error().Error())
+	}
+	defer client.Disconnect(1000)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	st := newStats()
+	var probe *prober
+	if *dbURL != "" {
+		pool, err := pgxpool.New(context.Background(), *dbURL)
+		if err != nil {
+			fatal("database: " + err.Error())
+		}
+		defer pool.Close()
+		// The prober outlives ctx so it can wait for the last calls to land
+		probeCtx, probeCancel := context.WithCancel(context.Background())
+		defer probeCancel()
+		probe = newProber(pool, st)
+		go probe.run(probeCtx)
+	}
+
+	fmt.Printf("tr-loadgen: %s, %d system(s), %.0f calls/day, %.1f trunking msg/s, audio=%v\n",
+		*brokerURL, cfg.Systems, cfg.CallsPerDay, cfg.TrunkingRate, cfg.Audio)
+
+	gen := loadgen.New(cfg)
+	publish := func(msgs []loadgen.Message) {
+		for _, m := range msgs {
+			start := time.Now()
+			t := client.Publish(m.Topic, byte(*qos), false, m.Payload)
+			ok := t.WaitTimeout(10*time.Second) && t.Error() == nil
+			st.published(m, time.Since(start), ok)
+			if ok && probe != nil && m.CallID != "" && st.calls()%int64(*probeN) == 0 {
+				probe.add(m.CallID, m.StartTime)
+			}
+		}
+	}
+
+	begin := time.Now()
+	publish(gen.Start(begin))
+	tick := time.NewTicker(20 * time.Millisecond)
+	defer tick.Stop()
+	rep := time.NewTicker(*report)
+	defer rep.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			elapsed := time.Since(begin)
+			if probe != nil {
+				// Give ingest a moment to catch up on the last probed calls
+				probe.drain(15 * time.Second)
+			}
+			st.summary(elapsed, gen.Active())
+			return
+		case now := <-tick.C:
+			publish(gen.Tick(now))
+		case <-rep.C:
+			st.report(gen.Active())
+		}
+	}
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func fatal(msg string) {
+	fmt.Fprintln(os.Stderr, "tr-loadgen: "+msg)
+	os.Exit(1)
+}
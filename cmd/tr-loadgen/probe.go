@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	probeInterval = 250 * time.Millisecond
+	probeTimeout  = 2 * time.Minute // give up on a call not completed by then
+	maxProbes     = 1000
+)
+
+type probeCall struct {
+	callID string
+	start  time.Time
+	sent   time.Time
+}
+
+// prober polls the database for sampled calls until their call_end has been
+// applied, measuring ingest lag (call_end published → stop_time set) and the
+// query round trip.
+type prober struct {
+	pool *pgxpool.Pool
+	st   *stats
+
+	mu      sync.Mutex
+	pending []probeCall
+}
+
+func newProber(pool *pgxpool.Pool, st *stats) *prober {
+	return &prober{pool: pool, st: st}
+}
+
+// add samples a call whose call_end was just published.
+func (p *prober) add(callID string, start time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.pending) < maxProbes {
+		p.pending = append(p.pending, probeCall{callID: callID, start: start, sent: time.Now()})
+	}
+}
+
+func (p *prober) run(ctx context.Context) {
+	t := time.NewTicker(probeInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			p.poll(ctx)
+		}
+	}
+}
+
+func (p *prober) poll(ctx context.Context) {
+	p.mu.Lock()
+	calls := p.pending
+	p.pending = nil
+	p.mu.Unlock()
+
+	var keep []probeCall
+	for _, c := range calls {
+		qctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		t0 := time.Now()
+		var done bool
+		err := p.pool.QueryRow(qctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM calls
+				WHERE tr_call_id = $1 AND start_time = $2 AND stop_time IS NOT NULL
+			)`, c.callID, c.start).Scan(&done)
+		cancel()
+		now := time.Now()
+		if err == nil {
+			p.st.queried(now.Sub(t0))
+		}
+		switch {
+		case err == nil && done:
+			p.st.probed(now.Sub(c.sent))
+		case now.Sub(c.sent) > probeTimeout:
+			p.st.timedOut()
+		default:
+			keep = append(keep, c)
+		}
+	}
+
+	p.mu.Lock()
+	p.pending = append(keep, p.pending...)
+	p.mu.Unlock()
+}
+
+// drain waits up to max for pending probes to complete.
+func (p *prober) drain(max time.Duration) {
+	deadline := time.Now().Add(max)
+	for time.Now().Before(deadline) {
+		p.mu.Lock()
+		n := len(p.pending)
+		p.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(probeInterval)
+	}
+}
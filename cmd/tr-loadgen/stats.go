@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/loadgen"
+)
+
+// stats counts published messages and collects latency samples, both per
+// report interval and for the whole run.
+type stats struct {
+	mu sync.Mutex
+
+	interval, total counters
+	start, last     time.Time
+}
+
+type counters struct {
+	msgs     map[string]int64
+	bytes    int64
+	errors   int64
+	publish  []time.Duration // MQTT publish round trips
+	lag      []time.Duration // call_end published → call completed in the database
+	db       []time.Duration // probe query round trips
+	timeouts int64           // probed calls that never completed
+}
+
+func newStats() *stats {
+	now := time.Now()
+	s := &stats{start: now, last: now}
+	s.interval.reset()
+	s.total.reset()
+	return s
+}
+
+func (c *counters) reset() {
+	*c = counters{msgs: make(map[string]int64)}
+}
+
+func (s *stats) published(m loadgen.Message, d time.Duration, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range []*counters{&s.interval, &s.total} {
+		if !ok {
+			c.errors++
+			continue
+		}
+		c.msgs[m.Kind]++
+		c.bytes += int64(len(m.Payload))
+		c.publish = append(c.publish, d)
+	}
+}
+
+// calls returns the number of call_end messages published so far.
+func (s *stats) calls() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.total.msgs["call_end"]
+}
+
+func (s *stats) probed(lag time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interval.lag = append(s.interval.lag, lag)
+	s.total.lag = append(s.total.lag, lag)
+}
+
+func (s *stats) queried(db time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interval.db = append(s.interval.db, db)
+	s.total.db = append(s.total.db, db)
+}
+
+func (s *stats) timedOut() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interval.timeouts++
+	s.total.timeouts++
+}
+
+// report prints and resets the interval counters.
+func (s *stats) report(active int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	fmt.Printf("[%6s] %s active=%d\n", now.Sub(s.start).Truncate(time.Second), s.interval.line(now.Sub(s.last)), active)
+	s.interval.reset()
+	s.last = now
+}
+
+// summary prints totals for the run.
+func (s *stats) summary(elapsed time.Duration, active int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := &s.total
+	fmt.Printf("\n── Summary (%s) ──\n", elapsed.Truncate(time.Second))
+	fmt.Println(c.line(elapsed))
+
+	kinds := make([]string, 0, len(c.msgs))
+	for k := range c.msgs {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	for _, k := range kinds {
+		fmt.Printf("  %-18s %8d  %8.2f/s\n", k, c.msgs[k], float64(c.msgs[k])/elapsed.Seconds())
+	}
+	calls := float64(c.msgs["call_end"])
+	fmt.Printf("  calls completed    %8.0f  (%.0f/day at this rate), %d still active\n", calls, calls/elapsed.Seconds()*86400, active)
+	if len(c.lag) > 0 || c.timeouts > 0 {
+		fmt.Printf("  ingest lag         p50 %s  p95 %s  p99 %s  max %s  (%d probed, %d never completed)\n",
+			pct(c.lag, 50), pct(c.lag, 95), pct(c.lag, 99), pct(c.lag, 100), len(c.lag), c.timeouts)
+		fmt.Printf("  db query           p50 %s  p95 %s  max %s\n", pct(c.db, 50), pct(c.db, 95), pct(c.db, 100))
+	}
+	if c.errors > 0 {
+		fmt.Printf("  publish errors     %d\n", c.errors)
+	}
+}
+
+func (c *counters) line(d time.Duration) string {
+	var n int64
+	for _, v := range c.msgs {
+		n += v
+	}
+	secs := d.Seconds()
+	if secs <= 0 {
+		secs = 1
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "msgs %d (%.1f/s) calls %d  %.2f MB/s  publish p95 %s",
+		n, float64(n)/secs, c.msgs["call_end"], float64(c.bytes)/secs/1e6, pct(c.publish, 95))
+	if len(c.lag) > 0 || c.timeouts > 0 {
+		fmt.Fprintf(&b, "  lag p50 %s p95 %s  db p95 %s", pct(c.lag, 50), pct(c.lag, 95), pct(c.db, 95))
+	}
+	if c.timeouts > 0 {
+		fmt.Fprintf(&b, "  timeouts %d", c.timeouts)
+	}
+	if c.errors > 0 {
+		fmt.Fprintf(&b, "  errors %d", c.errors)
+	}
+	return b.String()
+}
+
+// pct returns the p-th percentile of ds (p=100 is the max), rounded for
+// display, or "-" when empty.
+func pct(ds []time.Duration, p int) string {
+	if len(ds) == 0 {
+		return "-"
+	}
+	sorted := append([]time.Duration(nil), ds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := (len(sorted)*p + 99) / 100
+	if i > 0 {
+		i--
+	}
+	d := sorted[i]
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(100 * time.Microsecond).String()
+	}
+	return d.Round(time.Microsecond).String()
+}
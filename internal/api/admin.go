@@ -2,19 +2,25 @@ package api
 
 import (
 	"net/http"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/hlog"
 	"github.com/snarg/tr-engine/internal/database"
 )
 
 type AdminHandler struct {
 	db            *database.DB
 	live          LiveDataSource
+	cache         *ResponseCache
 	onSystemMerge func(sourceID, targetID int)
+	onUnitMerge   func(*database.UnitMergeResult)
 }
 
-func NewAdminHandler(db *database.DB, live LiveDataSource, onSystemMerge func(int, int)) *AdminHandler {
-	return &AdminHandler{db: db, live: live, onSystemMerge: onSystemMerge}
+func NewAdminHandler(db *database.DB, live LiveDataSource, cache *ResponseCache, onSystemMerge func(int, int), onUnitMerge func(*database.UnitMergeResult)) *AdminHandler {
+	return &AdminHandler{db: db, live: live, cache: cache, onSystemMerge: onSystemMerge, onUnitMerge: onUnitMerge}
 }
 
 // MergeSystems merges two systems.
@@ -61,23 +67,97 @@ func (h *AdminHandler) MergeSystems(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// MergeUnits merges one unit record into another within a system, e.g. when
+// an agency re-issues a radio ID.
+func (h *AdminHandler) MergeUnits(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SystemID     int    `json:"system_id"`
+		SourceUnitID int    `json:"source_unit_id"`
+		TargetUnitID int    `json:"target_unit_id"`
+		Actor        string `json:"actor"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+
+	if req.SystemID == 0 || req.SourceUnitID == 0 || req.TargetUnitID == 0 {
+		WriteError(w, http.StatusBadRequest, "system_id, source_unit_id, and target_unit_id are required")
+		return
+	}
+	if req.SourceUnitID == req.TargetUnitID {
+		WriteError(w, http.StatusBadRequest, "source_unit_id and target_unit_id must be different")
+		return
+	}
+	actor := strings.TrimSpace(req.Actor)
+	if me := userFrom(r); me != nil && actor == "" {
+		actor = me.Username
+	}
+	if actor == "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "actor is required")
+		return
+	}
+
+	result, err := h.db.MergeUnits(r.Context(), req.SystemID, req.SourceUnitID, req.TargetUnitID, actor)
+	if err != nil {
+		if err.Error() == "source unit not found" {
+			WriteError(w, http.StatusNotFound, "source unit not found")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "merge failed: "+err.Error())
+		return
+	}
+
+	// Drop the source unit from the ingest caches so it is rediscovered
+	if h.onUnitMerge != nil {
+		h.onUnitMerge(result)
+	}
+	WriteJSON(w, http.StatusOK, result)
+}
+
+// ArchivedUnitCounts returns the number of units archived for inactivity, per system.
+func (h *AdminHandler) ArchivedUnitCounts(w http.ResponseWriter, r *http.Request) {
+	counts, err := h.db.CountArchivedUnits(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to count archived units")
+		return
+	}
+	type systemCount struct {
+		SystemID int   `json:"system_id"`
+		Archived int64 `json:"archived"`
+	}
+	systems := make([]systemCount, 0, len(counts))
+	var total int64
+	for id, n := range counts {
+		systems = append(systems, systemCount{SystemID: id, Archived: n})
+		total += n
+	}
+	sort.Slice(systems, func(i, j int) bool { return systems[i].SystemID < systems[j].SystemID })
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"systems": systems,
+		"total":   total,
+	})
+}
+
 // GetMaintenance returns current maintenance config and last run results.
 func (h *AdminHandler) GetMaintenance(w http.ResponseWriter, r *http.Request) {
 	if h.live == nil {
 		WriteError(w, http.StatusServiceUnavailable, "pipeline not running")
 		return
 	}
-	status := h.live.MaintenanceStatus()
+	status := h.live.MaintenanceStatus(r.Context())
 	WriteJSON(w, http.StatusOK, status)
 }
 
-// RunMaintenance triggers an immediate maintenance run.
+// RunMaintenance triggers an immediate maintenance run. With ?dry_run=true,
+// destructive steps are only recorded in the maintenance audit.
 func (h *AdminHandler) RunMaintenance(w http.ResponseWriter, r *http.Request) {
 	if h.live == nil {
 		WriteError(w, http.StatusServiceUnavailable, "pipeline not running")
 		return
 	}
-	result, err := h.live.RunMaintenance(r.Context())
+	dryRun, _ := QueryBool(r, "dry_run")
+	result, err := h.live.RunMaintenance(r.Context(), dryRun)
 	if err != nil {
 		WriteError(w, http.StatusConflict, err.Error())
 		return
@@ -85,9 +165,350 @@ func (h *AdminHandler) RunMaintenance(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, http.StatusOK, result)
 }
 
+// ListMaintenanceAudit returns the write-ahead log of destructive maintenance
+// actions, newest first.
+func (h *AdminHandler) ListMaintenanceAudit(w http.ResponseWriter, r *http.Request) {
+	p, err := ParsePagination(r)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+	filter := database.MaintenanceAuditFilter{Limit: p.Limit, Offset: p.Offset}
+	if v, ok := QueryInt(r, "run_id"); ok {
+		id := int64(v)
+		filter.RunID = &id
+	}
+	filter.Task, _ = QueryString(r, "task")
+	filter.Status, _ = QueryString(r, "status")
+	if t, ok := QueryTime(r, "start_time"); ok {
+		filter.StartTime = &t
+	}
+	if t, ok := QueryTime(r, "end_time"); ok {
+		filter.EndTime = &t
+	}
+	if msg := ValidateTimeRange(filter.StartTime, filter.EndTime); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+
+	entries, total, err := h.db.ListMaintenanceAudit(r.Context(), filter)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list maintenance audit")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"entries": entries,
+		"total":   total,
+	})
+}
+
+// SetMaintenanceTask overrides a destructive task's configured state.
+// Body: {"enabled": bool, "dry_run": bool}.
+func (h *AdminHandler) SetMaintenanceTask(w http.ResponseWriter, r *http.Request) {
+	task := chi.URLParam(r, "task")
+	if !database.ValidMaintenanceTask(task) {
+		WriteError(w, http.StatusNotFound, "unknown maintenance task")
+		return
+	}
+	var req struct {
+		Enabled *bool `json:"enabled"`
+		DryRun  bool  `json:"dry_run"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	if req.Enabled == nil {
+		WriteError(w, http.StatusBadRequest, "enabled is required")
+		return
+	}
+	setting, err := h.db.UpsertMaintenanceTaskSetting(r.Context(), task, *req.Enabled, req.DryRun)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to save maintenance task setting")
+		return
+	}
+	hlog.FromRequest(r).Info().Str("maintenance_task", task).Bool("enabled", setting.Enabled).
+		Bool("dry_run", setting.DryRun).Msg("maintenance task override set")
+	WriteJSON(w, http.StatusOK, setting)
+}
+
+// ResetMaintenanceTask removes a task's override, returning it to its
+// configured state (MAINTENANCE_DISABLED_TASKS).
+func (h *AdminHandler) ResetMaintenanceTask(w http.ResponseWriter, r *http.Request) {
+	task := chi.URLParam(r, "task")
+	if !database.ValidMaintenanceTask(task) {
+		WriteError(w, http.StatusNotFound, "unknown maintenance task")
+		return
+	}
+	found, err := h.db.DeleteMaintenanceTaskSetting(r.Context(), task)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to reset maintenance task")
+		return
+	}
+	if !found {
+		WriteError(w, http.StatusNotFound, "maintenance task has no override")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetRetention returns each retention target's configured retention, admin
+// override and the retention maintenance applies.
+func (h *AdminHandler) GetRetention(w http.ResponseWriter, r *http.Request) {
+	if h.live == nil {
+		WriteError(w, http.StatusServiceUnavailable, "pipeline not running")
+		return
+	}
+	targets, err := h.live.RetentionStatus(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to load retention settings")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"targets": targets})
+}
+
+// SetRetention overrides a target's configured retention from the next
+// maintenance run on. Body: {"retention": "2160h"}; "0s" keeps an optional
+// target's data forever.
+func (h *AdminHandler) SetRetention(w http.ResponseWriter, r *http.Request) {
+	target, ok := database.LookupRetentionTarget(chi.URLParam(r, "target"))
+	if !ok {
+		WriteError(w, http.StatusNotFound, "unknown retention target")
+		return
+	}
+	var req struct {
+		Retention string `json:"retention"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	retention, err := time.ParseDuration(req.Retention)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "retention must be a duration such as 720h")
+		return
+	}
+	if msg := validateRetention(target, retention); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, msg)
+		return
+	}
+	setting, err := h.db.UpsertRetentionSetting(r.Context(), target.Name, retention)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to save retention setting")
+		return
+	}
+	hlog.FromRequest(r).Info().Str("retention_target", target.Name).
+		Dur("retention", setting.Retention).Msg("retention override set")
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"target":     setting.Target,
+		"retention":  setting.Retention.String(),
+		"source":     "admin",
+		"updated_at": setting.UpdatedAt,
+	})
+}
+
+// validateRetention returns why retention can't be set for target, or "".
+func validateRetention(target database.RetentionTarget, retention time.Duration) string {
+	switch {
+	case retention < 0:
+		return "retention must not be negative"
+	case retention == 0 && !target.Optional:
+		return target.Name + " can't be kept forever: retention must be at least 1h"
+	case retention > 0 && retention < time.Hour:
+		return "retention must be at least 1h"
+	}
+	return ""
+}
+
+// ResetRetention removes a target's override, returning it to its
+// configured retention (RETENTION_*).
+func (h *AdminHandler) ResetRetention(w http.ResponseWriter, r *http.Request) {
+	target, ok := database.LookupRetentionTarget(chi.URLParam(r, "target"))
+	if !ok {
+		WriteError(w, http.StatusNotFound, "unknown retention target")
+		return
+	}
+	found, err := h.db.DeleteRetentionSetting(r.Context(), target.Name)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to reset retention setting")
+		return
+	}
+	if !found {
+		WriteError(w, http.StatusNotFound, "retention target has no override")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RebuildUnitEncryptionRollup rebuilds unit_encryption_daily for a window,
+// e.g. after importing old calls. Body (optional): {"start_time", "end_time"};
+// defaults to the last 90 days. Runs synchronously.
+func (h *AdminHandler) RebuildUnitEncryptionRollup(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		StartTime *time.Time `json:"start_time"`
+		EndTime   *time.Time `json:"end_time"`
+	}
+	if r.ContentLength != 0 {
+		if err := DecodeJSON(r, &req); err != nil {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+			return
+		}
+	}
+	end := time.Now()
+	if req.EndTime != nil {
+		end = *req.EndTime
+	}
+	start := end.Add(-90 * 24 * time.Hour)
+	if req.StartTime != nil {
+		start = *req.StartTime
+	}
+	if msg := ValidateTimeRange(&start, &end); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+
+	rows, err := h.db.RefreshUnitEncryptionRollup(r.Context(), start, end)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("unit encryption rollup rebuild failed")
+		WriteError(w, http.StatusInternalServerError, "rollup rebuild failed")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"start_day": start.UTC().Format("2006-01-02"),
+		"end_day":   end.UTC().Format("2006-01-02"),
+		"rows":      rows,
+	})
+}
+
+// RebuildUnitCensusRollup rebuilds unit_census_daily for a window, e.g.
+// after a merge or an import of old unit events. Body (optional):
+// {"start_time", "end_time"}; defaults to the last 90 days. Runs
+// synchronously.
+func (h *AdminHandler) RebuildUnitCensusRollup(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		StartTime *time.Time `json:"start_time"`
+		EndTime   *time.Time `json:"end_time"`
+	}
+	if r.ContentLength != 0 {
+		if err := DecodeJSON(r, &req); err != nil {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+			return
+		}
+	}
+	end := time.Now()
+	if req.EndTime != nil {
+		end = *req.EndTime
+	}
+	start := end.Add(-90 * 24 * time.Hour)
+	if req.StartTime != nil {
+		start = *req.StartTime
+	}
+	if msg := ValidateTimeRange(&start, &end); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+
+	rows, err := h.db.RefreshUnitCensusRollup(r.Context(), start, end)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("unit census rollup rebuild failed")
+		WriteError(w, http.StatusInternalServerError, "rollup rebuild failed")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"start_day": start.UTC().Format("2006-01-02"),
+		"end_day":   end.UTC().Format("2006-01-02"),
+		"rows":      rows,
+	})
+}
+
+// CorrectCallDurations replaces the duration and stop_time of calls whose
+// measured audio length differs from the recorded duration by more than
+// threshold seconds (default 2), keeping the originals in original_duration
+// and original_stop_time. Body (optional): {"start_time", "end_time",
+// "system_ids", "threshold", "dry_run"}; the window defaults to the last 7
+// days. Runs synchronously.
+func (h *AdminHandler) CorrectCallDurations(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		StartTime *time.Time `json:"start_time"`
+		EndTime   *time.Time `json:"end_time"`
+		SystemIDs []int      `json:"system_ids"`
+		Threshold *float64   `json:"threshold"`
+		DryRun    bool       `json:"dry_run"`
+	}
+	if r.ContentLength != 0 {
+		if err := DecodeJSON(r, &req); err != nil {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+			return
+		}
+	}
+	filter := database.DurationCorrectionFilter{
+		SystemIDs: req.SystemIDs,
+		StartTime: time.Now().Add(-7 * 24 * time.Hour),
+		EndTime:   req.EndTime,
+		Threshold: 2,
+		DryRun:    req.DryRun,
+	}
+	if req.StartTime != nil {
+		filter.StartTime = *req.StartTime
+	}
+	if msg := ValidateTimeRange(&filter.StartTime, filter.EndTime); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+	if req.Threshold != nil {
+		if *req.Threshold < 0 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "threshold must be a non-negative number of seconds")
+			return
+		}
+		filter.Threshold = *req.Threshold
+	}
+
+	res, err := h.db.CorrectCallDurations(r.Context(), filter)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("call duration correction failed")
+		WriteError(w, http.StatusInternalServerError, "duration correction failed")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"calls":         res.Calls,
+		"airtime_delta": res.AirtimeDelta,
+		"dry_run":       req.DryRun,
+	})
+}
+
 // Routes registers admin routes on the given router.
 func (h *AdminHandler) Routes(r chi.Router) {
 	r.Post("/admin/systems/merge", h.MergeSystems)
+	r.Post("/admin/units/merge", h.MergeUnits)
+	r.Get("/admin/identity-map", h.ListIdentityMap)
+	r.Post("/admin/identity-map/remap", h.RemapIdentity)
+	r.Post("/admin/identity-map/invalidate", h.InvalidateIdentityMap)
+	r.Get("/admin/units/archived", h.ArchivedUnitCounts)
+	r.Get("/admin/units/csv-conflicts", h.ListUnitCSVConflicts)
+	r.Post("/admin/units/csv-conflicts/{id}/resolve", h.ResolveUnitCSVConflict)
+	r.Get("/admin/units/alias-suggestions", h.ListUnitAliasSuggestions)
+	r.Post("/admin/units/alias-suggestions/{id}/accept", h.AcceptUnitAliasSuggestion)
+	r.Post("/admin/units/alias-suggestions/{id}/reject", h.RejectUnitAliasSuggestion)
+	r.Get("/admin/directory/snapshot", h.GetDirectorySnapshot)
+	r.Post("/admin/directory/diff", h.DiffDirectorySnapshot)
+	r.Post("/admin/directory/apply", h.ApplyDirectorySnapshot)
+	r.Get("/admin/ask/audit", h.ListAskAudit)
 	r.Get("/admin/maintenance", h.GetMaintenance)
 	r.Post("/admin/maintenance", h.RunMaintenance)
+	r.Get("/admin/maintenance/audit", h.ListMaintenanceAudit)
+	r.Put("/admin/maintenance/tasks/{task}", h.SetMaintenanceTask)
+	r.Delete("/admin/maintenance/tasks/{task}", h.ResetMaintenanceTask)
+	r.Get("/admin/retention", h.GetRetention)
+	r.Put("/admin/retention/{target}", h.SetRetention)
+	r.Delete("/admin/retention/{target}", h.ResetRetention)
+	r.Post("/admin/rollups/unit-encryption", h.RebuildUnitEncryptionRollup)
+	r.Post("/admin/rollups/unit-census", h.RebuildUnitCensusRollup)
+	r.Post("/admin/calls/correct-durations", h.CorrectCallDurations)
+	r.Get("/admin/quarantine", h.ListQuarantine)
+	r.Get("/admin/quarantine/{id}", h.GetQuarantined)
+	r.Post("/admin/quarantine/{id}/reprocess", h.ReprocessQuarantined)
+	r.Delete("/admin/quarantine/{id}", h.DeleteQuarantined)
+	r.Get("/admin/watch-backfill", h.GetWatchBackfillReport)
+	r.Get("/admin/watch-backfill/files", h.ListWatchBackfillFiles)
+	r.Post("/admin/watch-backfill/retry", h.RetryWatchBackfill)
 }
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestMergeUnitsValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid_json", `{`},
+		{"missing_system", `{"source_unit_id":1,"target_unit_id":2}`},
+		{"missing_source", `{"system_id":1,"target_unit_id":2}`},
+		{"same_unit", `{"system_id":1,"source_unit_id":5,"target_unit_id":5}`},
+		{"missing_actor", `{"system_id":1,"source_unit_id":5,"target_unit_id":6}`},
+	}
+	h := NewAdminHandler(nil, nil, nil, nil, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/admin/units/merge", strings.NewReader(tt.body))
+			h.MergeUnits(rec, req)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestCorrectCallDurationsValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid_json", `{`},
+		{"negative_threshold", `{"threshold":-1}`},
+		{"reversed_range", `{"start_time":"2026-10-02T00:00:00Z","end_time":"2026-10-01T00:00:00Z"}`},
+	}
+	h := NewAdminHandler(nil, nil, nil, nil, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "/admin/calls/correct-durations", strings.NewReader(tt.body))
+			h.CorrectCallDurations(rec, req)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestSetRetentionValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		want   int
+	}{
+		{"unknown_target", "nope", `{"retention":"720h"}`, http.StatusNotFound},
+		{"invalid_json", "calls", `{`, http.StatusBadRequest},
+		{"bad_duration", "calls", `{"retention":"30 days"}`, http.StatusBadRequest},
+		{"negative", "calls", `{"retention":"-1h"}`, http.StatusBadRequest},
+		{"too_short", "call_audio", `{"retention":"30m"}`, http.StatusBadRequest},
+		{"required_forever", "console_messages", `{"retention":"0s"}`, http.StatusBadRequest},
+	}
+	r := chi.NewRouter()
+	NewAdminHandler(nil, nil, nil, nil, nil).Routes(r)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("PUT", "/admin/retention/"+tt.target, strings.NewReader(tt.body))
+			r.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
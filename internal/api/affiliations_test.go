@@ -7,6 +7,8 @@ import (
 	"net/http/httptest"
 	"testing"
 	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
 )
 
 // mockLiveData implements LiveDataSource for testing affiliations.
@@ -21,12 +23,28 @@ func (m *mockLiveData) UnitAffiliations() []UnitAffiliationData         { return
 func (m *mockLiveData) Subscribe(EventFilter) (<-chan SSEEvent, func()) { return nil, func() {} }
 func (m *mockLiveData) ReplaySince(string, EventFilter) []SSEEvent      { return nil }
 func (m *mockLiveData) WatcherStatus() *WatcherStatusData               { return nil }
+func (m *mockLiveData) RetryBackfillFailures(context.Context, *time.Time, *time.Time) (int, error) {
+	return 0, nil
+}
 func (m *mockLiveData) TranscriptionStatus() *TranscriptionStatusData   { return nil }
-func (m *mockLiveData) EnqueueTranscription(int64) bool                 { return false }
+func (m *mockLiveData) EnqueueTranscription(database.CallRef) bool      { return false }
 func (m *mockLiveData) TranscriptionQueueStats() *TranscriptionQueueStatsData { return nil }
+func (m *mockLiveData) TranscriptionDeadLetters() []TranscriptionDeadLetterData { return nil }
+func (m *mockLiveData) ClassifyTranscript(context.Context, *database.CallTranscriptionInfo, string) *database.TranscriptUrgency {
+	return nil
+}
 func (m *mockLiveData) IngestMetrics() *IngestMetricsData                     { return nil }
-func (m *mockLiveData) MaintenanceStatus() *MaintenanceStatusData             { return nil }
-func (m *mockLiveData) RunMaintenance(context.Context) (*MaintenanceRunData, error) { return nil, nil }
+func (m *mockLiveData) MaintenanceStatus(context.Context) *MaintenanceStatusData { return nil }
+func (m *mockLiveData) RetentionStatus(context.Context) ([]RetentionStatus, error) { return nil, nil }
+func (m *mockLiveData) RunMaintenance(context.Context, bool) (*MaintenanceRunData, error) {
+	return nil, nil
+}
+func (m *mockLiveData) ReprocessQuarantined(context.Context, int64, bool) (*QuarantineReprocessData, error) {
+	return nil, nil
+}
+func (m *mockLiveData) IdentityMap() []IdentityMapEntryData      { return nil }
+func (m *mockLiveData) InvalidateIdentity(string, string) int { return 0 }
+func (m *mockLiveData) TopicMap() *TopicMapData               { return nil }
 
 // affiliationsResponse matches the JSON shape returned by ListAffiliations.
 type affiliationsResponse struct {
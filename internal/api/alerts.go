@@ -0,0 +1,288 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// Alert rule limits.
+const (
+	maxAlertKeywords    = 50
+	maxAlertKeywordLen  = 100
+	maxAlertPatternLen  = 500
+	maxAlertRuleNameLen = 100
+	maxAlertRuleUnitIDs = 1000
+)
+
+// AlertsHandler manages alert rules, checked against each new transcript,
+// and serves the history of alerts they raised.
+type AlertsHandler struct {
+	db       *database.DB
+	onChange func(ctx context.Context) error // reloads the ingest rule cache; may be nil
+}
+
+func NewAlertsHandler(db *database.DB, onChange func(context.Context) error) *AlertsHandler {
+	return &AlertsHandler{db: db, onChange: onChange}
+}
+
+// changed tells ingest about a rule change. The change is already committed,
+// so a failed reload is only logged; it is picked up on restart.
+func (h *AlertsHandler) changed(r *http.Request) {
+	if h.onChange == nil {
+		return
+	}
+	if err := h.onChange(r.Context()); err != nil {
+		hlog.FromRequest(r).Warn().Err(err).Msg("failed to reload alert rules")
+	}
+}
+
+// validateAlertRule returns why a rule is unusable, or "".
+func validateAlertRule(rule *database.AlertRule) string {
+	switch {
+	case rule.Name == "":
+		return "name is required"
+	case len(rule.Name) > maxAlertRuleNameLen:
+		return fmt.Sprintf("name must be at most %d characters", maxAlertRuleNameLen)
+	case len(rule.Keywords) == 0 && rule.Pattern == "":
+		return "keywords or pattern is required"
+	case len(rule.Keywords) > maxAlertKeywords:
+		return fmt.Sprintf("at most %d keywords", maxAlertKeywords)
+	case len(rule.Pattern) > maxAlertPatternLen:
+		return fmt.Sprintf("pattern must be at most %d characters", maxAlertPatternLen)
+	case rule.SystemID != nil && *rule.SystemID <= 0:
+		return "system_id must be positive"
+	case len(rule.UnitIDs) > maxAlertRuleUnitIDs:
+		return fmt.Sprintf("at most %d unit_ids", maxAlertRuleUnitIDs)
+	}
+	for _, kw := range rule.Keywords {
+		if kw == "" {
+			return "keywords must not be empty"
+		}
+		if len(kw) > maxAlertKeywordLen {
+			return fmt.Sprintf("keywords must be at most %d characters", maxAlertKeywordLen)
+		}
+	}
+	if rule.Pattern != "" {
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return "invalid pattern: " + err.Error()
+		}
+	}
+	return ""
+}
+
+// decodeAlertRule reads and validates a rule body (enabled by default).
+func decodeAlertRule(w http.ResponseWriter, r *http.Request) (*database.AlertRule, bool) {
+	rule := database.AlertRule{Enabled: true}
+	if err := DecodeJSON(r, &rule); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return nil, false
+	}
+	rule.Name = strings.TrimSpace(rule.Name)
+	for i, kw := range rule.Keywords {
+		rule.Keywords[i] = strings.Join(strings.Fields(kw), " ")
+	}
+	if msg := validateAlertRule(&rule); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, msg)
+		return nil, false
+	}
+	if rule.Keywords == nil {
+		rule.Keywords = []string{}
+	}
+	if rule.Tgids == nil {
+		rule.Tgids = []int{}
+	}
+	if rule.UnitIDs == nil {
+		rule.UnitIDs = []int{}
+	}
+	return &rule, true
+}
+
+// ListAlertRules returns all alert rules.
+// GET /api/v1/alert-rules
+func (h *AlertsHandler) ListAlertRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.db.ListAlertRules(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list alert rules")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"rules": rules,
+		"total": len(rules),
+	})
+}
+
+// GetAlertRule returns one rule.
+// GET /api/v1/alert-rules/{id}
+func (h *AlertsHandler) GetAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid alert rule ID")
+		return
+	}
+	rule, err := h.db.GetAlertRule(r.Context(), id)
+	if err != nil {
+		h.writeRuleError(w, err, "failed to get alert rule")
+		return
+	}
+	WriteJSON(w, http.StatusOK, rule)
+}
+
+// CreateAlertRule adds a rule; it applies to transcripts stored from now on.
+// POST /api/v1/alert-rules
+func (h *AlertsHandler) CreateAlertRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := decodeAlertRule(w, r)
+	if !ok {
+		return
+	}
+	id, err := h.db.CreateAlertRule(r.Context(), rule)
+	if err != nil {
+		h.writeRuleError(w, err, "failed to create alert rule")
+		return
+	}
+	h.changed(r)
+	h.writeRule(w, r, id, http.StatusCreated)
+}
+
+// UpdateAlertRule replaces a rule.
+// PUT /api/v1/alert-rules/{id}
+func (h *AlertsHandler) UpdateAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid alert rule ID")
+		return
+	}
+	rule, ok := decodeAlertRule(w, r)
+	if !ok {
+		return
+	}
+	rule.ID = id
+	if err := h.db.UpdateAlertRule(r.Context(), rule); err != nil {
+		h.writeRuleError(w, err, "failed to update alert rule")
+		return
+	}
+	h.changed(r)
+	h.writeRule(w, r, id, http.StatusOK)
+}
+
+// DeleteAlertRule removes a rule. Its alerts stay in the history.
+// DELETE /api/v1/alert-rules/{id}
+func (h *AlertsHandler) DeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid alert rule ID")
+		return
+	}
+	if err := h.db.DeleteAlertRule(r.Context(), id); err != nil {
+		h.writeRuleError(w, err, "failed to delete alert rule")
+		return
+	}
+	h.changed(r)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeRule returns a rule as stored.
+func (h *AlertsHandler) writeRule(w http.ResponseWriter, r *http.Request, id, status int) {
+	rule, err := h.db.GetAlertRule(r.Context(), id)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get alert rule")
+		return
+	}
+	WriteJSON(w, status, rule)
+}
+
+func (h *AlertsHandler) writeRuleError(w http.ResponseWriter, err error, msg string) {
+	switch err.Error() {
+	case "alert rule not found":
+		WriteError(w, http.StatusNotFound, err.Error())
+	case "alert rule name already exists":
+		WriteError(w, http.StatusConflict, err.Error())
+	default:
+		WriteError(w, http.StatusInternalServerError, msg)
+	}
+}
+
+// ListAlerts returns alert history, newest first. Filters: rule_id,
+// system_id, tgid, unit_id (comma-separated), emergency, start_time/end_time
+// (when the alert was raised). Alerts on restricted or embargoed calls are
+// left out for non-admin tokens.
+// GET /api/v1/alerts
+func (h *AlertsHandler) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	p, err := ParsePagination(r)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+	f := database.AlertFilter{
+		RuleIDs:           QueryIntList(r, "rule_id"),
+		SystemIDs:         QueryIntListAliased(r, "system_id", "systems"),
+		Tgids:             QueryIntListAliased(r, "tgid", "tgids"),
+		UnitIDs:           QueryIntListAliased(r, "unit_id", "units"),
+		IncludeRestricted: isAdmin(r),
+		Limit:             p.Limit,
+		Offset:            p.Offset,
+	}
+	if v, ok := QueryBool(r, "emergency"); ok {
+		f.Emergency = &v
+	}
+	if t, ok := QueryTime(r, "start_time"); ok {
+		f.Start = &t
+	}
+	if t, ok := QueryTime(r, "end_time"); ok {
+		f.End = &t
+	}
+	if msg := ValidateTimeRange(f.Start, f.End); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+
+	alerts, total, err := h.db.ListAlerts(r.Context(), f)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list alerts")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"alerts": alerts,
+		"total":  total,
+		"limit":  p.Limit,
+		"offset": p.Offset,
+	})
+}
+
+// GetAlert returns one alert.
+// GET /api/v1/alerts/{id}
+func (h *AlertsHandler) GetAlert(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt64(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid alert ID")
+		return
+	}
+	alert, err := h.db.GetAlert(r.Context(), id, isAdmin(r))
+	if err != nil {
+		if err.Error() == "alert not found" {
+			WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to get alert")
+		return
+	}
+	WriteJSON(w, http.StatusOK, alert)
+}
+
+// Routes registers alert rule and alert history routes on the given router.
+func (h *AlertsHandler) Routes(r chi.Router) {
+	r.Get("/alert-rules", h.ListAlertRules)
+	r.Post("/alert-rules", h.CreateAlertRule)
+	r.Get("/alert-rules/{id}", h.GetAlertRule)
+	r.Put("/alert-rules/{id}", h.UpdateAlertRule)
+	r.Delete("/alert-rules/{id}", h.DeleteAlertRule)
+	r.Get("/alerts", h.ListAlerts)
+	r.Get("/alerts/{id}", h.GetAlert)
+}
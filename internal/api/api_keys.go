@@ -0,0 +1,474 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+	"golang.org/x/time/rate"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// API keys are named bearer tokens stored in api_keys, each with a scope:
+// stream (live event and audio streams only), read (what AUTH_TOKEN can do),
+// write (reads, and writes outside /admin) or admin (what WRITE_TOKEN can
+// do). They work alongside AUTH_TOKEN and WRITE_TOKEN: APIKeyAuth recognizes
+// a key and puts it in the request context, where BearerAuth, WriteAuth and
+// AdminContext check its scope instead of comparing tokens. A key may carry
+// its own rate limit in place of RATE_LIMIT_TOKEN_RPS.
+
+const (
+	apiKeyPrefix        = "tre_"
+	apiKeyShownChars    = 12 // stored prefix: "tre_" and 8 characters
+	apiKeyTouchInterval = time.Minute
+	apiKeyMaxRPS        = 10000
+)
+
+// APIKeyStore loads active API keys and records their use.
+type APIKeyStore interface {
+	ListAPIKeys(ctx context.Context, activeOnly bool) ([]database.APIKey, error)
+	TouchAPIKey(ctx context.Context, id int, at time.Time, ip string) error
+}
+
+// APIKeys holds the active API keys in memory, by hash. Reload after a key
+// is created, changed or revoked.
+type APIKeys struct {
+	db  APIKeyStore
+	log zerolog.Logger
+
+	mu     sync.RWMutex
+	byHash map[string]*database.APIKey
+
+	limitMu  sync.Mutex
+	limiters map[int]*limiterSet // keys with a rate limit of their own
+
+	touchMu sync.Mutex
+	touched map[int]time.Time // last use recorded per key
+}
+
+func NewAPIKeys(db APIKeyStore, log zerolog.Logger) *APIKeys {
+	return &APIKeys{
+		db:       db,
+		log:      log.With().Str("component", "api-keys").Logger(),
+		byHash:   make(map[string]*database.APIKey),
+		limiters: make(map[int]*limiterSet),
+		touched:  make(map[int]time.Time),
+	}
+}
+
+// Reload replaces the in-memory keys with the active keys in the database.
+// Rate limit buckets start over, so changed limits apply at once.
+func (k *APIKeys) Reload(ctx context.Context) error {
+	keys, err := k.db.ListAPIKeys(ctx, true)
+	if err != nil {
+		return err
+	}
+	byHash := make(map[string]*database.APIKey, len(keys))
+	for i := range keys {
+		byHash[keys[i].Hash] = &keys[i]
+	}
+	k.mu.Lock()
+	k.byHash = byHash
+	k.mu.Unlock()
+
+	k.limitMu.Lock()
+	k.limiters = make(map[int]*limiterSet)
+	k.limitMu.Unlock()
+	return nil
+}
+
+// lookup returns the active key matching token, or nil.
+func (k *APIKeys) lookup(token string) *database.APIKey {
+	if k == nil || !strings.HasPrefix(token, apiKeyPrefix) {
+		return nil
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.byHash[hashAPIKey(token)]
+}
+
+// touch records a key's use, writing to the database at most once per
+// apiKeyTouchInterval per key.
+func (k *APIKeys) touch(id int, ip string) {
+	now := time.Now()
+	k.touchMu.Lock()
+	if now.Sub(k.touched[id]) < apiKeyTouchInterval {
+		k.touchMu.Unlock()
+		return
+	}
+	k.touched[id] = now
+	k.touchMu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := k.db.TouchAPIKey(ctx, id, now, ip); err != nil {
+			k.log.Warn().Err(err).Int("api_key_id", id).Msg("failed to record api key use")
+		}
+	}()
+}
+
+// limiter returns the bucket for a key with its own rate limit, or nil.
+// Without a burst the key gets its rps, rounded up.
+func (k *APIKeys) limiter(key *database.APIKey) *limiterSet {
+	if key.RateLimitRPS == nil {
+		return nil
+	}
+	k.limitMu.Lock()
+	defer k.limitMu.Unlock()
+	if s, ok := k.limiters[key.ID]; ok {
+		return s
+	}
+	rps := *key.RateLimitRPS
+	burst := int(math.Ceil(rps))
+	if key.RateLimitBurst != nil {
+		burst = *key.RateLimitBurst
+	}
+	s := &limiterSet{scope: "api_key", rps: rps, burst: max(burst, 1), limiters: make(map[string]*rate.Limiter)}
+	k.limiters[key.ID] = s
+	return s
+}
+
+// hashAPIKey returns the hex SHA-256 stored for a key.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// newAPIKey returns a random key: "tre_" and 32 bytes base64url.
+func newAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+type apiKeyCtxKey struct{}
+
+// apiKeyFrom returns the API key APIKeyAuth found on r, or nil for requests
+// made with AUTH_TOKEN, WRITE_TOKEN, a share link or no token.
+func apiKeyFrom(r *http.Request) *database.APIKey {
+	k, _ := r.Context().Value(apiKeyCtxKey{}).(*database.APIKey)
+	return k
+}
+
+// apiKeyUploads reports whether a key's scope may upload calls.
+func apiKeyUploads(k *database.APIKey) bool {
+	return k != nil && (k.Scope == database.APIKeyScopeWrite || k.Scope == database.APIKeyScopeAdmin)
+}
+
+// streamAllowed reports whether a stream-only key may be used for a request:
+// GET on the event streams, call audio, the live audio streams or
+// /capabilities, and any method on WebRTC live audio signaling.
+func streamAllowed(r *http.Request) bool {
+	if strings.HasPrefix(r.URL.Path, "/api/v1/audio/webrtc/") {
+		return true
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	switch r.URL.Path {
+	case "/api/v1/calls/stream", "/api/v1/audio/live", "/api/v1/capabilities":
+		return true
+	case "/api/v1/calls":
+		return false
+	}
+	return shareAllowed(r)
+}
+
+// APIKeyAuth recognizes API keys in the bearer token (or ?token=) and puts
+// the key in the request context for BearerAuth, WriteAuth and AdminContext.
+// Stream-only keys are refused outside the streaming endpoints. Other
+// tokens pass through untouched.
+func APIKeyAuth(keys *APIKeys) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if shareScopeFrom(r) != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key := keys.lookup(extractBearerToken(r))
+			if key == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if key.Scope == database.APIKeyScopeStream && !streamAllowed(r) {
+				WriteErrorWithCode(w, http.StatusForbidden, ErrForbidden,
+					"stream API keys only grant the event streams, call audio and live audio")
+				return
+			}
+			keys.touch(key.ID, clientIP(r))
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyCtxKey{}, key)))
+		})
+	}
+}
+
+// APIKeyRateLimiter applies each API key's own rate limit. Keys without one
+// are left to TokenRateLimiter.
+func APIKeyRateLimiter(keys *APIKeys) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key := apiKeyFrom(r); key != nil {
+				if s := keys.limiter(key); s != nil && !s.allow(w, "key") {
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// APIKeysHandler creates, changes and revokes API keys. Every route needs
+// admin access, reads included, since AUTH_TOKEN may read /admin.
+type APIKeysHandler struct {
+	db   *database.DB
+	keys *APIKeys
+}
+
+func NewAPIKeysHandler(db *database.DB, keys *APIKeys) *APIKeysHandler {
+	return &APIKeysHandler{db: db, keys: keys}
+}
+
+func (h *APIKeysHandler) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if !isAdmin(r) {
+		WriteErrorWithCode(w, http.StatusForbidden, ErrForbidden, "API keys are managed with an admin API key or WRITE_TOKEN")
+		return false
+	}
+	return true
+}
+
+func (h *APIKeysHandler) reload(r *http.Request) {
+	if err := h.keys.Reload(r.Context()); err != nil {
+		hlog.FromRequest(r).Warn().Err(err).Msg("failed to reload api keys")
+	}
+}
+
+// validateRateLimit checks a key's rate limit. Returns "" if valid.
+func validateRateLimit(rps *float64, burst *int) string {
+	if rps != nil && (*rps < 0 || *rps > apiKeyMaxRPS) {
+		return "rate_limit_rps must be between 0 and 10000"
+	}
+	if burst != nil && *burst < 1 {
+		return "rate_limit_burst must be at least 1"
+	}
+	return ""
+}
+
+// ListAPIKeys returns API keys, newest first. ?active=false includes revoked
+// keys. Keys themselves are only returned at creation.
+func (h *APIKeysHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	activeOnly := true
+	if v := r.URL.Query().Get("active"); v != "" {
+		switch v {
+		case "true":
+		case "false":
+			activeOnly = false
+		default:
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "active must be true or false")
+			return
+		}
+	}
+	keys, err := h.db.ListAPIKeys(r.Context(), activeOnly)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list api keys")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"api_keys": keys,
+		"total":    len(keys),
+	})
+}
+
+// CreateAPIKey issues a key with a name, scope and optional rate limit. The
+// response carries the key, which can't be retrieved later.
+func (h *APIKeysHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	var req struct {
+		Name           string   `json:"name"`
+		Actor          string   `json:"actor"`
+		Scope          string   `json:"scope"`
+		RateLimitRPS   *float64 `json:"rate_limit_rps"`
+		RateLimitBurst *int     `json:"rate_limit_burst"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	key := database.APIKey{
+		Name:           strings.TrimSpace(req.Name),
+		CreatedBy:      strings.TrimSpace(req.Actor),
+		Scope:          req.Scope,
+		RateLimitRPS:   req.RateLimitRPS,
+		RateLimitBurst: req.RateLimitBurst,
+	}
+	if key.Name == "" || key.CreatedBy == "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "name and actor are required")
+		return
+	}
+	if len(key.Name) > 100 {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "name must be at most 100 characters")
+		return
+	}
+	if !database.ValidAPIKeyScope(key.Scope) {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "scope must be stream, read, write or admin")
+		return
+	}
+	if msg := validateRateLimit(key.RateLimitRPS, key.RateLimitBurst); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, msg)
+		return
+	}
+	if key.RateLimitRPS != nil && *key.RateLimitRPS == 0 {
+		key.RateLimitRPS = nil
+	}
+	if key.RateLimitBurst != nil && key.RateLimitRPS == nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "rate_limit_burst requires rate_limit_rps")
+		return
+	}
+
+	secret, err := newAPIKey()
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to generate api key")
+		return
+	}
+	key.Prefix = secret[:apiKeyShownChars]
+	key.Hash = hashAPIKey(secret)
+	if err := h.db.CreateAPIKey(r.Context(), &key); err != nil {
+		h.writeKeyError(w, err, "failed to create api key")
+		return
+	}
+	h.reload(r)
+	WriteJSON(w, http.StatusCreated, map[string]any{
+		"api_key": key,
+		"key":     secret,
+	})
+}
+
+// GetAPIKey returns an API key, active or not.
+func (h *APIKeysHandler) GetAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid api key ID")
+		return
+	}
+	key, err := h.db.GetAPIKey(r.Context(), id)
+	if err != nil {
+		h.writeKeyError(w, err, "failed to get api key")
+		return
+	}
+	WriteJSON(w, http.StatusOK, key)
+}
+
+// UpdateAPIKey renames an active key or changes its scope or rate limit.
+// rate_limit_rps 0 drops the key's own limit.
+func (h *APIKeysHandler) UpdateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid api key ID")
+		return
+	}
+	var req struct {
+		Name           *string  `json:"name"`
+		Scope          *string  `json:"scope"`
+		RateLimitRPS   *float64 `json:"rate_limit_rps"`
+		RateLimitBurst *int     `json:"rate_limit_burst"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	u := database.APIKeyUpdate{Scope: req.Scope, RateLimitRPS: req.RateLimitRPS, RateLimitBurst: req.RateLimitBurst}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || len(name) > 100 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "name must be 1 to 100 characters")
+			return
+		}
+		u.Name = &name
+	}
+	if u.Scope != nil && !database.ValidAPIKeyScope(*u.Scope) {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "scope must be stream, read, write or admin")
+		return
+	}
+	if msg := validateRateLimit(u.RateLimitRPS, u.RateLimitBurst); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, msg)
+		return
+	}
+	if u.RateLimitRPS != nil && *u.RateLimitRPS == 0 {
+		u.RateLimitRPS, u.RateLimitBurst, u.ClearRateLimit = nil, nil, true
+	}
+
+	key, err := h.db.UpdateAPIKey(r.Context(), id, u)
+	if err != nil {
+		h.writeKeyError(w, err, "failed to update api key")
+		return
+	}
+	h.reload(r)
+	WriteJSON(w, http.StatusOK, key)
+}
+
+// RevokeAPIKey revokes a key (?actor= is required). Requests with it are
+// refused from then on.
+func (h *APIKeysHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid api key ID")
+		return
+	}
+	actor := strings.TrimSpace(r.URL.Query().Get("actor"))
+	if actor == "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "actor is required")
+		return
+	}
+	key, err := h.db.RevokeAPIKey(r.Context(), id, actor)
+	if err != nil {
+		h.writeKeyError(w, err, "failed to revoke api key")
+		return
+	}
+	h.reload(r)
+	WriteJSON(w, http.StatusOK, key)
+}
+
+func (h *APIKeysHandler) writeKeyError(w http.ResponseWriter, err error, msg string) {
+	switch err.Error() {
+	case "api key not found":
+		WriteError(w, http.StatusNotFound, err.Error())
+	case "api key is revoked", "api key name already exists":
+		WriteError(w, http.StatusConflict, err.Error())
+	default:
+		WriteError(w, http.StatusInternalServerError, msg)
+	}
+}
+
+func (h *APIKeysHandler) Routes(r chi.Router) {
+	r.Get("/admin/api-keys", h.ListAPIKeys)
+	r.Post("/admin/api-keys", h.CreateAPIKey)
+	r.Get("/admin/api-keys/{id}", h.GetAPIKey)
+	r.Patch("/admin/api-keys/{id}", h.UpdateAPIKey)
+	r.Delete("/admin/api-keys/{id}", h.RevokeAPIKey)
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+type mockAPIKeyStore struct {
+	keys []database.APIKey
+
+	mu      sync.Mutex
+	touched []int
+}
+
+func (m *mockAPIKeyStore) ListAPIKeys(ctx context.Context, activeOnly bool) ([]database.APIKey, error) {
+	return m.keys, nil
+}
+
+func (m *mockAPIKeyStore) TouchAPIKey(ctx context.Context, id int, at time.Time, ip string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.touched = append(m.touched, id)
+	return nil
+}
+
+// testAPIKeys returns keys "tre_<scope>" for each scope, with ID 1-4.
+func testAPIKeys(t *testing.T) (*APIKeys, *mockAPIKeyStore) {
+	t.Helper()
+	store := &mockAPIKeyStore{}
+	for i, scope := range []string{"stream", "read", "write", "admin"} {
+		store.keys = append(store.keys, database.APIKey{ID: i + 1, Name: scope, Scope: scope, Hash: hashAPIKey("tre_" + scope)})
+	}
+	keys := NewAPIKeys(store, zerolog.Nop())
+	if err := keys.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return keys, store
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	keys, _ := testAPIKeys(t)
+	handler := APIKeyAuth(keys)(BearerAuth("reader", "writer")(
+		WriteAuth("writer", "reader")(AdminContext(true, "writer")(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if isAdmin(r) {
+					w.WriteHeader(http.StatusAccepted)
+				}
+			})))))
+	serve := func(method, path, token string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, tc := range []struct {
+		method, path, token string
+		want                int
+	}{
+		{"GET", "/api/v1/events/stream", "tre_stream", http.StatusOK},
+		{"GET", "/api/v1/calls/42/audio", "tre_stream", http.StatusOK},
+		{"GET", "/api/v1/audio/live", "tre_stream", http.StatusOK},
+		{"POST", "/api/v1/audio/webrtc/sessions", "tre_stream", http.StatusOK},
+		{"PATCH", "/api/v1/audio/webrtc/sessions/abc", "tre_stream", http.StatusOK},
+		{"GET", "/api/v1/calls", "tre_stream", http.StatusForbidden},
+		{"GET", "/api/v1/talkgroups", "tre_stream", http.StatusForbidden},
+		{"GET", "/api/v1/calls", "tre_read", http.StatusOK},
+		{"POST", "/api/v1/talkgroups/1/notes", "tre_read", http.StatusForbidden},
+		{"POST", "/api/v1/saved-queries", "tre_read", http.StatusOK},
+		{"POST", "/api/v1/talkgroups/1/notes", "tre_write", http.StatusOK},
+		{"POST", "/api/v1/admin/feeds", "tre_write", http.StatusForbidden},
+		{"POST", "/api/v1/admin/feeds", "tre_admin", http.StatusAccepted},
+		{"GET", "/api/v1/calls", "tre_admin", http.StatusAccepted},
+		{"GET", "/api/v1/calls", "writer", http.StatusAccepted},
+		{"GET", "/api/v1/calls", "tre_unknown", http.StatusUnauthorized},
+	} {
+		if got := serve(tc.method, tc.path, tc.token); got != tc.want {
+			t.Errorf("%s %s with %s: %d, want %d", tc.method, tc.path, tc.token, got, tc.want)
+		}
+	}
+}
+
+func TestAPIKeyReloadRevokes(t *testing.T) {
+	keys, store := testAPIKeys(t)
+	if keys.lookup("tre_read") == nil {
+		t.Fatal("read key not found")
+	}
+	store.keys = store.keys[2:]
+	if err := keys.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if keys.lookup("tre_read") != nil {
+		t.Error("revoked key still found after reload")
+	}
+	if k := keys.lookup("tre_admin"); k == nil || k.ID != 4 {
+		t.Errorf("admin key = %+v", k)
+	}
+}
+
+func TestAPIKeyTouch(t *testing.T) {
+	keys, store := testAPIKeys(t)
+	keys.touch(2, "10.0.0.1")
+	keys.touch(2, "10.0.0.1") // within the interval: not written again
+	deadline := time.Now().Add(time.Second)
+	for {
+		store.mu.Lock()
+		n := len(store.touched)
+		store.mu.Unlock()
+		if n > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if len(store.touched) != 1 || store.touched[0] != 2 {
+		t.Errorf("touched = %v, want [2]", store.touched)
+	}
+}
+
+func TestAPIKeyRateLimiter(t *testing.T) {
+	keys, store := testAPIKeys(t)
+	rps, burst := 1.0, 2
+	store.keys[1].RateLimitRPS, store.keys[1].RateLimitBurst = &rps, &burst
+	if err := keys.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	handler := APIKeyAuth(keys)(APIKeyRateLimiter(keys)(okHandler))
+	serve := func(token string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/v1/calls", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := serve("tre_read"); code != http.StatusOK {
+			t.Fatalf("request %d: %d, want 200", i+1, code)
+		}
+	}
+	if code := serve("tre_read"); code != http.StatusTooManyRequests {
+		t.Errorf("over the key's burst: %d, want 429", code)
+	}
+	// Keys without a limit of their own aren't limited here
+	for i := 0; i < 5; i++ {
+		if code := serve("tre_write"); code != http.StatusOK {
+			t.Fatalf("write key request %d: %d, want 200", i+1, code)
+		}
+	}
+}
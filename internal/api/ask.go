@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/snarg/tr-engine/internal/ask"
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// maxQuestionLength bounds questions to the archive, in characters.
+const maxQuestionLength = 500
+
+type AskHandler struct {
+	db        *database.DB
+	svc       *ask.Service // nil when LLM_URL is not configured
+	rateLimit float64      // questions per minute per client IP; 0 = no extra limit
+}
+
+func NewAskHandler(db *database.DB, svc *ask.Service, rateLimit float64) *AskHandler {
+	return &AskHandler{db: db, svc: svc, rateLimit: rateLimit}
+}
+
+// Ask answers a natural-language question from the transcript archive, citing
+// the calls it draws on. Every question is recorded in the ask audit log,
+// including ones that fail.
+func (h *AskHandler) Ask(w http.ResponseWriter, r *http.Request) {
+	if h.svc == nil {
+		WriteError(w, http.StatusServiceUnavailable, "archive Q&A not configured (set LLM_URL)")
+		return
+	}
+
+	var req ask.Request
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	req.Question = strings.TrimSpace(req.Question)
+	if req.Question == "" {
+		WriteError(w, http.StatusBadRequest, "question is required")
+		return
+	}
+	if utf8.RuneCountInString(req.Question) > maxQuestionLength {
+		WriteError(w, http.StatusBadRequest, "question must be at most 500 characters")
+		return
+	}
+	if ask.Keywords(req.Question) == "" {
+		WriteError(w, http.StatusBadRequest, "question has no searchable words")
+		return
+	}
+	if msg := ValidateTimeRange(req.StartTime, req.EndTime); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+
+	start := time.Now()
+	ans, err := h.svc.Ask(r.Context(), req)
+
+	entry := database.AskAuditEntry{
+		ClientIP:  clientIP(r),
+		Question:  req.Question,
+		Model:     h.svc.Model(),
+		LatencyMs: int(time.Since(start).Milliseconds()),
+	}
+	if req.SystemIDs != nil || req.Tgids != nil || req.StartTime != nil || req.EndTime != nil {
+		entry.Filters, _ = json.Marshal(map[string]any{
+			"system_ids": req.SystemIDs, "tgids": req.Tgids,
+			"start_time": req.StartTime, "end_time": req.EndTime,
+		})
+	}
+	if err != nil {
+		msg := err.Error()
+		entry.Error = &msg
+	} else {
+		entry.Retrieved = ans.Retrieved
+		entry.Answer = &ans.Answer
+		for _, c := range ans.Citations {
+			entry.CitedCalls = append(entry.CitedCalls, c.CallID)
+		}
+	}
+	// Record the question even if the client has gone away.
+	auditID, auditErr := h.db.InsertAskAudit(context.WithoutCancel(r.Context()), entry)
+	if auditErr != nil {
+		hlog.FromRequest(r).Error().Err(auditErr).Msg("failed to write ask audit log")
+	}
+
+	if err != nil {
+		hlog.FromRequest(r).Warn().Err(err).Str("question", req.Question).Msg("ask failed")
+		if errors.Is(err, ask.ErrModel) {
+			WriteErrorWithCodeDetail(w, http.StatusBadGateway, ErrServiceUnavail, "language model request failed", err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to answer question")
+		return
+	}
+
+	resp := map[string]any{
+		"answer":    ans.Answer,
+		"citations": ans.Citations,
+		"model":     ans.Model,
+		"retrieved": ans.Retrieved,
+	}
+	if auditErr == nil {
+		resp["audit_id"] = auditID
+	}
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+func (h *AskHandler) Routes(r chi.Router) {
+	// Each question costs a model call: limit per client on top of the global limiter.
+	if h.rateLimit > 0 {
+		r = r.With(RateLimiter(h.rateLimit/60, max(1, int(h.rateLimit))))
+	}
+	r.Post("/ask", h.Ask)
+}
+
+// ListAskAudit returns questions asked of the archive, newest first.
+// Filters: start_time, end_time, client_ip.
+func (h *AdminHandler) ListAskAudit(w http.ResponseWriter, r *http.Request) {
+	p, err := ParsePagination(r)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+	filter := database.AskAuditFilter{Limit: p.Limit, Offset: p.Offset}
+	if t, ok := QueryTime(r, "start_time"); ok {
+		filter.StartTime = &t
+	}
+	if t, ok := QueryTime(r, "end_time"); ok {
+		filter.EndTime = &t
+	}
+	filter.ClientIP, _ = QueryString(r, "client_ip")
+
+	entries, total, err := h.db.ListAskAudit(r.Context(), filter)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list ask audit log")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"entries": entries,
+		"total":   total,
+	})
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/audioarchive"
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+type AudioArchiveHandler struct {
+	db       *database.DB
+	archiver *audioarchive.Archiver // nil when TR_AUDIO_ARCHIVE is off
+}
+
+func NewAudioArchiveHandler(db *database.DB, archiver *audioarchive.Archiver) *AudioArchiveHandler {
+	return &AudioArchiveHandler{db: db, archiver: archiver}
+}
+
+func (h *AudioArchiveHandler) available(w http.ResponseWriter) bool {
+	if h.archiver == nil {
+		WriteError(w, http.StatusServiceUnavailable, "audio archiving not enabled (set TR_AUDIO_DIR and TR_AUDIO_ARCHIVE)")
+		return false
+	}
+	return true
+}
+
+// GetAudioArchiveStatus reports how much TR-served audio has been copied, how
+// much is pending or failing, and when the oldest pending call will be purged.
+func (h *AudioArchiveHandler) GetAudioArchiveStatus(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	status, err := h.archiver.Status(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get audio archive status")
+		return
+	}
+	WriteJSON(w, http.StatusOK, status)
+}
+
+// RunAudioArchive starts an archive run now instead of waiting for the next
+// interval.
+func (h *AudioArchiveHandler) RunAudioArchive(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	if err := h.archiver.Run(); err != nil {
+		WriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+	status, err := h.archiver.Status(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get audio archive status")
+		return
+	}
+	WriteJSON(w, http.StatusAccepted, status)
+}
+
+// ListAudioArchiveFailures returns calls whose audio could not be copied.
+func (h *AudioArchiveHandler) ListAudioArchiveFailures(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	limit := 100
+	if v, ok := QueryInt(r, "limit"); ok {
+		if v < 1 || v > 1000 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "limit must be between 1 and 1000")
+			return
+		}
+		limit = v
+	}
+	failures, err := h.db.ListAudioArchiveFailures(r.Context(), limit)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list audio archive failures")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"failures": failures,
+		"total":    len(failures),
+	})
+}
+
+// ResetAudioArchiveFailures clears recorded failures so those calls are
+// retried (including ones that gave up) on the next run.
+func (h *AudioArchiveHandler) ResetAudioArchiveFailures(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	n, err := h.db.ResetAudioArchiveFailures(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to reset audio archive failures")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"reset": n})
+}
+
+func (h *AudioArchiveHandler) Routes(r chi.Router) {
+	r.Get("/admin/audio-archive", h.GetAudioArchiveStatus)
+	r.Post("/admin/audio-archive/run", h.RunAudioArchive)
+	r.Get("/admin/audio-archive/failures", h.ListAudioArchiveFailures)
+	r.Post("/admin/audio-archive/failures/reset", h.ResetAudioArchiveFailures)
+}
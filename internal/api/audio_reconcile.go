@@ -0,0 +1,114 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/audiorecon"
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+type AudioReconcileHandler struct {
+	db         *database.DB
+	reconciler *audiorecon.Reconciler // nil when AUDIO_RECONCILE is off
+}
+
+func NewAudioReconcileHandler(db *database.DB, reconciler *audiorecon.Reconciler) *AudioReconcileHandler {
+	return &AudioReconcileHandler{db: db, reconciler: reconciler}
+}
+
+func (h *AudioReconcileHandler) available(w http.ResponseWriter) bool {
+	if h.reconciler == nil {
+		WriteError(w, http.StatusServiceUnavailable, "audio reconciliation not enabled (set AUDIO_RECONCILE and WATCH_DIR or TR_AUDIO_DIR)")
+		return false
+	}
+	return true
+}
+
+// GetAudioReconcileStatus reports how many calls missing audio were recovered,
+// are still being searched for or are permanently missing.
+func (h *AudioReconcileHandler) GetAudioReconcileStatus(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	status, err := h.reconciler.Status(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get audio reconciliation status")
+		return
+	}
+	WriteJSON(w, http.StatusOK, status)
+}
+
+// RunAudioReconcile starts a reconciliation run now instead of waiting for
+// the next interval.
+func (h *AudioReconcileHandler) RunAudioReconcile(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	if err := h.reconciler.Run(); err != nil {
+		WriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+	status, err := h.reconciler.Status(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get audio reconciliation status")
+		return
+	}
+	WriteJSON(w, http.StatusAccepted, status)
+}
+
+// ListAudioReconcileCalls returns the calls checked within the window,
+// optionally only those with one outcome.
+func (h *AudioReconcileHandler) ListAudioReconcileCalls(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", database.ReconcileRecovered, database.ReconcileMissing, database.ReconcileSearching, database.ReconcileSkipped:
+	default:
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter,
+			"status must be recovered, missing, searching or skipped")
+		return
+	}
+	limit := 100
+	if v, ok := QueryInt(r, "limit"); ok {
+		if v < 1 || v > 1000 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "limit must be between 1 and 1000")
+			return
+		}
+		limit = v
+	}
+	calls, err := h.db.ListReconciledCalls(r.Context(), status, time.Now().Add(-h.reconciler.Window()), limit)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list reconciled calls")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"calls": calls,
+		"total": len(calls),
+	})
+}
+
+// ResetMissingAudio makes calls recorded as missing eligible for another
+// search, e.g. after their files were restored.
+func (h *AudioReconcileHandler) ResetMissingAudio(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	n, err := h.db.ResetMissingAudio(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to reset missing audio")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"reset": n})
+}
+
+func (h *AudioReconcileHandler) Routes(r chi.Router) {
+	r.Get("/admin/audio-reconcile", h.GetAudioReconcileStatus)
+	r.Post("/admin/audio-reconcile/run", h.RunAudioReconcile)
+	r.Get("/admin/audio-reconcile/calls", h.ListAudioReconcileCalls)
+	r.Post("/admin/audio-reconcile/missing/reset", h.ResetMissingAudio)
+}
@@ -70,6 +70,7 @@ func (h *AudioStreamHandler) HandleStream(w http.ResponseWriter, r *http.Request
 	defer h.clients.Add(-1)
 
 	connStart := time.Now()
+	admin := isAdmin(r) // non-admins never hear embargoed or restricted talkgroups
 
 	// Subscribe with empty filter (receives nothing until client sends subscribe)
 	frameCh, cancel := h.streamer.SubscribeAudio(audio.AudioFilter{TGIDs: []int{-1}})
@@ -117,8 +118,9 @@ func (h *AudioStreamHandler) HandleStream(w http.ResponseWriter, r *http.Request
 			switch ctrl.Type {
 			case "subscribe":
 				filter := audio.AudioFilter{
-					SystemIDs: ctrl.Systems,
-					TGIDs:     ctrl.TGIDs,
+					SystemIDs:         ctrl.Systems,
+					TGIDs:             ctrl.TGIDs,
+					IncludeRestricted: admin,
 				}
 				h.streamer.UpdateAudioFilter(frameCh, filter)
 				h.log.Debug().
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -17,16 +18,33 @@ import (
 type mockAudioStreamer struct {
 	bus     *audio.AudioBus
 	enabled bool
+
+	mu     sync.Mutex
+	filter audio.AudioFilter // last filter subscribed or updated
 }
 
 func (m *mockAudioStreamer) SubscribeAudio(filter audio.AudioFilter) (<-chan audio.AudioFrame, func()) {
+	m.setFilter(filter)
 	return m.bus.Subscribe(filter)
 }
 
 func (m *mockAudioStreamer) UpdateAudioFilter(ch <-chan audio.AudioFrame, filter audio.AudioFilter) {
+	m.setFilter(filter)
 	m.bus.UpdateFilter(ch, filter)
 }
 
+func (m *mockAudioStreamer) setFilter(f audio.AudioFilter) {
+	m.mu.Lock()
+	m.filter = f
+	m.mu.Unlock()
+}
+
+func (m *mockAudioStreamer) lastFilter() audio.AudioFilter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.filter
+}
+
 func (m *mockAudioStreamer) AudioStreamEnabled() bool { return m.enabled }
 
 func (m *mockAudioStreamer) AudioStreamStatus() *AudioStreamStatusData {
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/hlog"
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// audioVariantNameRe limits variant names to something safe in a storage key.
+var audioVariantNameRe = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// audioVariantKey returns the storage key for a variant stored next to the
+// call's default audio: "sys/2026-10-16/123-456.m4a" with variant "redacted"
+// and ext ".m4a" becomes "sys/2026-10-16/123-456.redacted.m4a". Calls without
+// stored audio get "variants/{call_id}/{variant}{ext}".
+func audioVariantKey(defaultPath string, callID int64, variant, ext string) string {
+	if defaultPath == "" {
+		return fmt.Sprintf("variants/%d/%s%s", callID, variant, ext)
+	}
+	base := strings.TrimSuffix(defaultPath, path.Ext(defaultPath))
+	return base + "." + variant + ext
+}
+
+// ListCallAudioVariants lists the stored versions of a call's audio.
+// Non-admins only see the default variant.
+// GET /api/v1/calls/{id}/audio-variants
+func (h *CallsHandler) ListCallAudioVariants(w http.ResponseWriter, r *http.Request) {
+	ref, err := ParseCallRef(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid call ID")
+		return
+	}
+	if h.hideRestricted(w, r, ref, "call not found") {
+		return
+	}
+	variants, err := h.db.ListCallAudioVariants(r.Context(), ref)
+	if err != nil {
+		if err.Error() == "call not found" {
+			WriteError(w, http.StatusNotFound, "call not found")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to list audio variants")
+		return
+	}
+	if !isAdmin(r) {
+		shown := variants[:0]
+		for _, v := range variants {
+			if v.IsDefault {
+				shown = append(shown, v)
+			}
+		}
+		variants = shown
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"variants": variants,
+		"total":    len(variants),
+	})
+}
+
+// serveCallAudioVariant streams one named variant of a call's audio. Only the
+// default variant is served to non-admins, so a redacted default keeps the
+// original private.
+func (h *CallsHandler) serveCallAudioVariant(w http.ResponseWriter, r *http.Request, ref database.CallRef, variant string) {
+	if h.hideRestricted(w, r, ref, "audio not found") {
+		return
+	}
+	v, err := h.db.GetCallAudioVariant(r.Context(), ref, variant)
+	if err != nil || (!v.IsDefault && !isAdmin(r)) {
+		WriteError(w, http.StatusNotFound, "audio variant not found")
+		return
+	}
+	h.serveStoredAudio(w, r, v.CallID, v.AudioPath, "")
+}
+
+// UploadCallAudioVariant stores a processed version of a call's audio (e.g.
+// redacted) as a named variant, optionally making it the default.
+// POST /api/v1/calls/{id}/audio-variants
+// Content-Type: multipart/form-data (fields: "file", "variant", optional "default")
+func (h *CallsHandler) UploadCallAudioVariant(w http.ResponseWriter, r *http.Request) {
+	ref, err := ParseCallRef(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid call ID")
+		return
+	}
+	if h.store == nil {
+		WriteError(w, http.StatusServiceUnavailable, "audio storage not configured")
+		return
+	}
+	// 50 MB max upload
+	if err := r.ParseMultipartForm(50 << 20); err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid multipart form (50 MB max)")
+		return
+	}
+	variant := r.FormValue("variant")
+	if !audioVariantNameRe.MatchString(variant) {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "variant must be 1-32 characters of a-z, 0-9, _ or -")
+		return
+	}
+	makeDefault := r.FormValue("default") == "true"
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "missing 'file' field in multipart form")
+		return
+	}
+	defer file.Close()
+	ext := strings.ToLower(path.Ext(header.Filename))
+	contentType, ok := audioContentTypes[ext]
+	if !ok {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "file must be .m4a, .mp3, .wav, .ogg or .opus")
+		return
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "failed to read uploaded file")
+		return
+	}
+
+	ref, err = h.db.ResolveCallRef(r.Context(), ref)
+	if err != nil {
+		WriteError(w, http.StatusNotFound, "call not found")
+		return
+	}
+	defaultPath, _, err := h.db.GetCallAudioPath(r.Context(), ref)
+	if err != nil {
+		WriteError(w, http.StatusNotFound, "call not found")
+		return
+	}
+	key := audioVariantKey(defaultPath, ref.CallID, variant, ext)
+	if key == defaultPath {
+		WriteErrorWithCode(w, http.StatusConflict, ErrInvalidParameter, "variant would overwrite the default audio")
+		return
+	}
+	if err := h.store.Save(r.Context(), key, data, contentType); err != nil {
+		hlog.FromRequest(r).Error().Err(err).Str("key", key).Msg("failed to save audio variant")
+		WriteError(w, http.StatusInternalServerError, "failed to save audio")
+		return
+	}
+	if err := h.db.AddCallAudioVariant(r.Context(), database.AudioVariant{
+		CallID:        ref.CallID,
+		CallStartTime: ref.StartTime,
+		Variant:       variant,
+		AudioPath:     key,
+		AudioType:     strings.TrimPrefix(ext, "."),
+		AudioSize:     len(data),
+		Source:        "api",
+	}, makeDefault); err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to record audio variant")
+		return
+	}
+	if makeDefault {
+		h.removeSpectrograms(ref.CallID, "")
+	}
+	v, err := h.db.GetCallAudioVariant(r.Context(), ref, variant)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to load audio variant")
+		return
+	}
+	WriteJSON(w, http.StatusCreated, v)
+}
+
+// SetDefaultCallAudioVariant selects which variant /calls/{id}/audio serves.
+// POST /api/v1/calls/{id}/audio-variants/{variant}/default
+func (h *CallsHandler) SetDefaultCallAudioVariant(w http.ResponseWriter, r *http.Request) {
+	ref, err := ParseCallRef(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid call ID")
+		return
+	}
+	v, err := h.db.SetDefaultCallAudioVariant(r.Context(), ref, chi.URLParam(r, "variant"))
+	if err != nil {
+		switch err.Error() {
+		case "call not found", "audio variant not found":
+			WriteError(w, http.StatusNotFound, err.Error())
+		default:
+			WriteError(w, http.StatusInternalServerError, "failed to set default audio variant")
+		}
+		return
+	}
+	// The cached spectrogram was rendered from the previous default
+	h.removeSpectrograms(v.CallID, "")
+	WriteJSON(w, http.StatusOK, v)
+}
@@ -0,0 +1,36 @@
+package api
+
+import "testing"
+
+func TestAudioVariantKey(t *testing.T) {
+	tests := []struct {
+		defaultPath string
+		variant     string
+		ext         string
+		want        string
+	}{
+		{"butco/2026-10-16/9044-1760600000_851.2625-call_42.m4a", "redacted", ".m4a",
+			"butco/2026-10-16/9044-1760600000_851.2625-call_42.redacted.m4a"},
+		{"butco/2026-10-16/9044-1760600000.opus", "original", ".wav",
+			"butco/2026-10-16/9044-1760600000.original.wav"},
+		{"", "redacted", ".mp3", "variants/42/redacted.mp3"},
+	}
+	for _, tt := range tests {
+		if got := audioVariantKey(tt.defaultPath, 42, tt.variant, tt.ext); got != tt.want {
+			t.Errorf("audioVariantKey(%q, %q, %q) = %q, want %q", tt.defaultPath, tt.variant, tt.ext, got, tt.want)
+		}
+	}
+}
+
+func TestAudioVariantName(t *testing.T) {
+	for _, name := range []string{"original", "redacted", "noise_reduced", "v2-eq"} {
+		if !audioVariantNameRe.MatchString(name) {
+			t.Errorf("%q rejected", name)
+		}
+	}
+	for _, name := range []string{"", "Redacted", "../x", "a.b", "thirty-three-characters-is-too-lo"} {
+		if audioVariantNameRe.MatchString(name) {
+			t.Errorf("%q accepted", name)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/audio"
+	"github.com/snarg/tr-engine/internal/rtcaudio"
+)
+
+// AudioWebRTCHandler signals WebRTC live audio sessions. Media flows over
+// the peer connection; these endpoints only exchange SDP and filters.
+type AudioWebRTCHandler struct {
+	pub *rtcaudio.Publisher
+}
+
+func NewAudioWebRTCHandler(pub *rtcaudio.Publisher) *AudioWebRTCHandler {
+	return &AudioWebRTCHandler{pub: pub}
+}
+
+func (h *AudioWebRTCHandler) Routes(r chi.Router) {
+	r.Post("/audio/webrtc/sessions", h.OpenSession)
+	r.Post("/audio/webrtc/sessions/{id}/answer", h.AnswerSession)
+	r.Patch("/audio/webrtc/sessions/{id}", h.UpdateSession)
+	r.Delete("/audio/webrtc/sessions/{id}", h.CloseSession)
+}
+
+// webrtcFilter is the talkgroup/system filter, as in the WebSocket's
+// subscribe message. Empty lists match everything.
+type webrtcFilter struct {
+	TGIDs   []int `json:"tgids"`
+	Systems []int `json:"systems"`
+}
+
+func (f webrtcFilter) audio() audio.AudioFilter {
+	return audio.AudioFilter{TGIDs: f.TGIDs, SystemIDs: f.Systems}
+}
+
+// OpenSession creates a peer and returns its offer. Non-admin sessions
+// skip embargoed and restricted talkgroups for as long as they stay open.
+func (h *AudioWebRTCHandler) OpenSession(w http.ResponseWriter, r *http.Request) {
+	var req webrtcFilter
+	if err := DecodeJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	filter := req.audio()
+	filter.IncludeRestricted = isAdmin(r)
+	s, err := h.pub.Open(filter)
+	switch {
+	case errors.Is(err, rtcaudio.ErrTooManyPeers):
+		WriteError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	case err != nil:
+		WriteError(w, http.StatusInternalServerError, "failed to open webrtc session")
+		return
+	}
+	WriteJSON(w, http.StatusCreated, s)
+}
+
+// AnswerSession applies the client's SDP answer.
+func (h *AudioWebRTCHandler) AnswerSession(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SDP string `json:"sdp"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	if req.SDP == "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "sdp is required")
+		return
+	}
+	h.writeResult(w, h.pub.Answer(chi.URLParam(r, "id"), req.SDP))
+}
+
+// UpdateSession replaces a session's talkgroup/system filter.
+func (h *AudioWebRTCHandler) UpdateSession(w http.ResponseWriter, r *http.Request) {
+	var req webrtcFilter
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	h.writeResult(w, h.pub.UpdateFilter(chi.URLParam(r, "id"), req.audio()))
+}
+
+// CloseSession ends a session.
+func (h *AudioWebRTCHandler) CloseSession(w http.ResponseWriter, r *http.Request) {
+	h.writeResult(w, h.pub.Close(chi.URLParam(r, "id")))
+}
+
+func (h *AudioWebRTCHandler) writeResult(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, rtcaudio.ErrSessionNotFound):
+		WriteError(w, http.StatusNotFound, "session not found")
+	case errors.Is(err, rtcaudio.ErrInvalidAnswer):
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, err.Error())
+	case err != nil:
+		WriteError(w, http.StatusInternalServerError, "webrtc session error")
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
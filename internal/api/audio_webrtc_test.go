@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+	"github.com/snarg/tr-engine/internal/audio"
+	"github.com/snarg/tr-engine/internal/rtcaudio"
+)
+
+// newTestWebRTCServer serves the signaling routes to admins or non-admins
+// (see serveTranscripts).
+func newTestWebRTCServer(t *testing.T, maxPeers int, admin bool) (*httptest.Server, *mockAudioStreamer) {
+	t.Helper()
+	streamer := &mockAudioStreamer{bus: audio.NewAudioBus(), enabled: true}
+	pub, err := rtcaudio.New(streamer, rtcaudio.Options{MaxPeers: maxPeers, Lanes: 2}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pub.Stop)
+	r := chi.NewRouter()
+	r.Use(AdminContext(!admin, "writer"))
+	r.Route("/api/v1", func(r chi.Router) {
+		NewAudioWebRTCHandler(pub).Routes(r)
+	})
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv, streamer
+}
+
+// webrtcDo sends a signaling request under /audio/webrtc/sessions.
+func webrtcDo(t *testing.T, srv *httptest.Server, method, path, body string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(method, srv.URL+"/api/v1/audio/webrtc/sessions"+path, strings.NewReader(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestAudioWebRTCSessions(t *testing.T) {
+	srv, _ := newTestWebRTCServer(t, 1, true)
+	do := func(method, path, body string) *http.Response {
+		t.Helper()
+		return webrtcDo(t, srv, method, path, body)
+	}
+
+	resp := do("POST", "", `{"tgids":[9001]}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("open: status %d, want 201", resp.StatusCode)
+	}
+	var s rtcaudio.Session
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	if s.ID == "" || !strings.HasPrefix(s.SDP, "v=0") || len(s.Lanes) != 2 {
+		t.Errorf("session = %+v", s)
+	}
+
+	if code := do("POST", "", `{}`).StatusCode; code != http.StatusServiceUnavailable {
+		t.Errorf("open past max peers: status %d, want 503", code)
+	}
+	if code := do("POST", "", `{"tgids":`).StatusCode; code != http.StatusBadRequest {
+		t.Errorf("open with bad body: status %d, want 400", code)
+	}
+	if code := do("PATCH", "/"+s.ID, `{"systems":[1]}`).StatusCode; code != http.StatusNoContent {
+		t.Errorf("update: status %d, want 204", code)
+	}
+	if code := do("POST", "/"+s.ID+"/answer", `{"sdp":""}`).StatusCode; code != http.StatusBadRequest {
+		t.Errorf("empty answer: status %d, want 400", code)
+	}
+	if code := do("POST", "/"+s.ID+"/answer", `{"sdp":"garbage"}`).StatusCode; code != http.StatusBadRequest {
+		t.Errorf("bad answer: status %d, want 400", code)
+	}
+	if code := do("POST", "/nope/answer", `{"sdp":"v=0"}`).StatusCode; code != http.StatusNotFound {
+		t.Errorf("unknown session answer: status %d, want 404", code)
+	}
+	if code := do("DELETE", "/"+s.ID, "").StatusCode; code != http.StatusNoContent {
+		t.Errorf("close: status %d, want 204", code)
+	}
+	if code := do("DELETE", "/"+s.ID, "").StatusCode; code != http.StatusNotFound {
+		t.Errorf("close again: status %d, want 404", code)
+	}
+}
+
+// TestAudioWebRTCRestricted checks that a session's bus filter includes
+// restricted talkgroups only for admins, and that a filter update can't
+// change that.
+func TestAudioWebRTCRestricted(t *testing.T) {
+	for _, admin := range []bool{false, true} {
+		t.Run(fmt.Sprintf("admin=%v", admin), func(t *testing.T) {
+			srv, streamer := newTestWebRTCServer(t, 1, admin)
+			resp := webrtcDo(t, srv, "POST", "", `{"tgids":[9001]}`)
+			if resp.StatusCode != http.StatusCreated {
+				t.Fatalf("open: status %d, want 201", resp.StatusCode)
+			}
+			if got := streamer.lastFilter().IncludeRestricted; got != admin {
+				t.Errorf("open: IncludeRestricted = %v, want %v", got, admin)
+			}
+
+			var s rtcaudio.Session
+			if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+				t.Fatal(err)
+			}
+			if code := webrtcDo(t, srv, "PATCH", "/"+s.ID, `{"tgids":[9002]}`).StatusCode; code != http.StatusNoContent {
+				t.Fatalf("update: status %d, want 204", code)
+			}
+			if f := streamer.lastFilter(); f.IncludeRestricted != admin || len(f.TGIDs) != 1 || f.TGIDs[0] != 9002 {
+				t.Errorf("update: filter = %+v, want tgids [9002] and IncludeRestricted %v", f, admin)
+			}
+		})
+	}
+}
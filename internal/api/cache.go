@@ -0,0 +1,224 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/metrics"
+)
+
+// Cache tags. Cached responses are tagged with what they depend on; the
+// pipeline and mutating API handlers invalidate by tag.
+const (
+	CacheTagSystems    = "systems"
+	CacheTagTalkgroups = "talkgroups"
+)
+
+// CacheTagTalkgroup tags responses for a single talkgroup (any system).
+// The pipeline fires it when a new call is recorded on tgid.
+func CacheTagTalkgroup(tgid int) string {
+	return "tg:" + strconv.Itoa(tgid)
+}
+
+// ResponseCache is an in-memory cache of successful GET responses for hot
+// dashboard endpoints, keyed by path and normalized query string. Entries
+// expire after a per-endpoint TTL or when one of their tags is invalidated.
+//
+// A nil *ResponseCache is valid and caches nothing, so handlers can use it
+// unconditionally.
+type ResponseCache struct {
+	mu         sync.Mutex
+	entries    map[string]*cacheEntry
+	maxEntries int
+	// gen is bumped on every invalidation. A response computed across an
+	// invalidation is not stored, since it may predate the change.
+	gen atomic.Uint64
+}
+
+type cacheEntry struct {
+	contentType string
+	body        []byte
+	expires     time.Time
+	tags        []string
+}
+
+func NewResponseCache(maxEntries int) *ResponseCache {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &ResponseCache{entries: make(map[string]*cacheEntry), maxEntries: maxEntries}
+}
+
+// Cached wraps a GET handler. endpoint labels metrics; tags returns the
+// invalidation tags for the request (nil = TTL only).
+func (c *ResponseCache) Cached(endpoint string, ttl time.Duration, tags func(*http.Request) []string, next http.HandlerFunc) http.HandlerFunc {
+	if c == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Path + "?" + r.URL.Query().Encode()
+		now := time.Now()
+
+		c.mu.Lock()
+		e, ok := c.entries[key]
+		if ok && now.After(e.expires) {
+			delete(c.entries, key)
+			ok = false
+		}
+		c.mu.Unlock()
+
+		if ok {
+			metrics.APICacheRequestsTotal.WithLabelValues(endpoint, "hit").Inc()
+			w.Header().Set("Content-Type", e.contentType)
+			w.Header().Set("X-Cache", "HIT")
+			w.WriteHeader(http.StatusOK)
+			w.Write(e.body)
+			return
+		}
+
+		metrics.APICacheRequestsTotal.WithLabelValues(endpoint, "miss").Inc()
+		gen := c.gen.Load()
+		w.Header().Set("X-Cache", "MISS")
+		rec := &cacheRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+
+		if rec.status != http.StatusOK || c.gen.Load() != gen {
+			return
+		}
+		var t []string
+		if tags != nil {
+			t = tags(r)
+		}
+		c.store(key, &cacheEntry{
+			contentType: w.Header().Get("Content-Type"),
+			body:        rec.body.Bytes(),
+			expires:     now.Add(ttl),
+			tags:        t,
+		})
+	}
+}
+
+// Invalidating wraps a mutating handler so that a 2xx response invalidates
+// the tags returned by tags.
+func (c *ResponseCache) Invalidating(tags func(*http.Request) []string, next http.HandlerFunc) http.HandlerFunc {
+	if c == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(sw, r)
+		if sw.status >= 200 && sw.status < 300 {
+			c.Invalidate(tags(r)...)
+		}
+	}
+}
+
+// Invalidate drops every entry carrying any of tags.
+func (c *ResponseCache) Invalidate(tags ...string) {
+	if c == nil || len(tags) == 0 {
+		return
+	}
+	c.gen.Add(1)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, e := range c.entries {
+		if hasAnyTag(e.tags, tags) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Purge drops all entries (e.g. after a system merge renumbers IDs).
+func (c *ResponseCache) Purge() {
+	if c == nil {
+		return
+	}
+	c.gen.Add(1)
+
+	c.mu.Lock()
+	c.entries = make(map[string]*cacheEntry)
+	c.mu.Unlock()
+}
+
+// Len returns the number of cached entries, including expired ones not yet evicted.
+func (c *ResponseCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+func (c *ResponseCache) store(key string, e *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= c.maxEntries {
+		now := time.Now()
+		for k, old := range c.entries {
+			if now.After(old.expires) {
+				delete(c.entries, k)
+			}
+		}
+		// Still full: evict arbitrary entries. Keys are few and short-lived,
+		// so LRU bookkeeping isn't worth it.
+		for k := range c.entries {
+			if len(c.entries) < c.maxEntries {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = e
+}
+
+func hasAnyTag(have, want []string) bool {
+	for _, h := range have {
+		for _, w := range want {
+			if h == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// staticTags returns a tags func that always yields tags.
+func staticTags(tags ...string) func(*http.Request) []string {
+	return func(*http.Request) []string { return tags }
+}
+
+// cacheRecorder tees the response body so it can be stored.
+type cacheRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *cacheRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *cacheRecorder) Write(b []byte) (int, error) {
+	if r.status == http.StatusOK {
+		r.body.Write(b)
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// statusRecorder captures the response status code.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
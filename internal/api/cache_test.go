@@ -0,0 +1,143 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestResponseCache(t *testing.T) {
+	newCountingHandler := func(status int) (http.HandlerFunc, *int) {
+		calls := 0
+		return func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			WriteJSON(w, status, map[string]int{"calls": calls})
+		}, &calls
+	}
+	get := func(h http.HandlerFunc, url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		return rec
+	}
+
+	t.Run("hit_after_miss", func(t *testing.T) {
+		c := NewResponseCache(10)
+		next, calls := newCountingHandler(http.StatusOK)
+		h := c.Cached("test", time.Minute, staticTags(CacheTagSystems), next)
+
+		first := get(h, "/systems?b=2&a=1")
+		second := get(h, "/systems?a=1&b=2") // same query, different order
+		if *calls != 1 {
+			t.Fatalf("handler called %d times, want 1", *calls)
+		}
+		if first.Header().Get("X-Cache") != "MISS" || second.Header().Get("X-Cache") != "HIT" {
+			t.Errorf("X-Cache = %q, %q; want MISS, HIT", first.Header().Get("X-Cache"), second.Header().Get("X-Cache"))
+		}
+		if second.Body.String() != first.Body.String() || second.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("cached response differs: %q vs %q", second.Body.String(), first.Body.String())
+		}
+	})
+
+	t.Run("errors_not_cached", func(t *testing.T) {
+		c := NewResponseCache(10)
+		next, calls := newCountingHandler(http.StatusInternalServerError)
+		h := c.Cached("test", time.Minute, nil, next)
+		get(h, "/stats")
+		get(h, "/stats")
+		if *calls != 2 {
+			t.Errorf("handler called %d times, want 2", *calls)
+		}
+	})
+
+	t.Run("expires", func(t *testing.T) {
+		c := NewResponseCache(10)
+		next, calls := newCountingHandler(http.StatusOK)
+		h := c.Cached("test", -time.Second, nil, next)
+		get(h, "/stats")
+		get(h, "/stats")
+		if *calls != 2 {
+			t.Errorf("handler called %d times, want 2", *calls)
+		}
+	})
+
+	t.Run("invalidate_by_tag", func(t *testing.T) {
+		c := NewResponseCache(10)
+		sysNext, sysCalls := newCountingHandler(http.StatusOK)
+		tgNext, tgCalls := newCountingHandler(http.StatusOK)
+		sys := c.Cached("systems", time.Minute, staticTags(CacheTagSystems), sysNext)
+		tg := c.Cached("talkgroup", time.Minute, staticTags(CacheTagTalkgroups, CacheTagTalkgroup(9131)), tgNext)
+
+		get(sys, "/systems")
+		get(tg, "/talkgroups/9131")
+		c.Invalidate(CacheTagTalkgroup(9131))
+		get(sys, "/systems")
+		get(tg, "/talkgroups/9131")
+		if *sysCalls != 1 || *tgCalls != 2 {
+			t.Errorf("calls systems=%d talkgroup=%d, want 1 and 2", *sysCalls, *tgCalls)
+		}
+	})
+
+	t.Run("invalidating_wrapper", func(t *testing.T) {
+		c := NewResponseCache(10)
+		next, calls := newCountingHandler(http.StatusOK)
+		list := c.Cached("systems", time.Minute, staticTags(CacheTagSystems), next)
+		failing := c.Invalidating(staticTags(CacheTagSystems), func(w http.ResponseWriter, r *http.Request) {
+			WriteError(w, http.StatusBadRequest, "nope")
+		})
+		ok := c.Invalidating(staticTags(CacheTagSystems), func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+
+		get(list, "/systems")
+		failing(httptest.NewRecorder(), httptest.NewRequest(http.MethodPatch, "/systems/1", nil))
+		get(list, "/systems")
+		if *calls != 1 {
+			t.Fatalf("failed mutation invalidated cache: %d calls", *calls)
+		}
+		ok(httptest.NewRecorder(), httptest.NewRequest(http.MethodPatch, "/systems/1", nil))
+		get(list, "/systems")
+		if *calls != 2 {
+			t.Errorf("successful mutation did not invalidate cache: %d calls", *calls)
+		}
+	})
+
+	t.Run("skip_store_across_invalidation", func(t *testing.T) {
+		c := NewResponseCache(10)
+		calls := 0
+		h := c.Cached("systems", time.Minute, staticTags(CacheTagSystems), func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			c.Invalidate(CacheTagSystems) // change lands while the response is being built
+			WriteJSON(w, http.StatusOK, calls)
+		})
+		get(h, "/systems")
+		if c.Len() != 0 {
+			t.Errorf("stored %d entries computed across an invalidation", c.Len())
+		}
+	})
+
+	t.Run("capacity", func(t *testing.T) {
+		c := NewResponseCache(2)
+		next, _ := newCountingHandler(http.StatusOK)
+		h := c.Cached("test", time.Minute, nil, next)
+		for _, u := range []string{"/a", "/b", "/c", "/d"} {
+			get(h, u)
+		}
+		if c.Len() > 2 {
+			t.Errorf("cache holds %d entries, want <= 2", c.Len())
+		}
+	})
+
+	t.Run("nil_cache_passthrough", func(t *testing.T) {
+		var c *ResponseCache
+		next, calls := newCountingHandler(http.StatusOK)
+		h := c.Cached("test", time.Minute, nil, next)
+		get(h, "/stats")
+		get(h, "/stats")
+		c.Invalidate(CacheTagSystems)
+		c.Purge()
+		if *calls != 2 {
+			t.Errorf("nil cache cached responses: %d calls", *calls)
+		}
+	})
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/cadmail"
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+type CADIncidentsHandler struct {
+	db       *database.DB
+	ingester *cadmail.Ingester // nil when CAD_PAGE_FORMATS is unset
+}
+
+func NewCADIncidentsHandler(db *database.DB, ingester *cadmail.Ingester) *CADIncidentsHandler {
+	return &CADIncidentsHandler{db: db, ingester: ingester}
+}
+
+// ListCADIncidents searches CAD incidents, newest dispatch first. q is a
+// case-insensitive substring match on the incident number, type, address,
+// units and page text.
+// GET /api/v1/cad-incidents?q=&system_id=&start_time=&end_time=
+func (h *CADIncidentsHandler) ListCADIncidents(w http.ResponseWriter, r *http.Request) {
+	p, err := ParsePagination(r)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+	filter := database.CADIncidentFilter{Limit: p.Limit, Offset: p.Offset}
+	filter.Query, _ = QueryString(r, "q")
+	if v, ok := QueryInt(r, "system_id"); ok {
+		filter.SystemID = &v
+	}
+	if t, ok := QueryTime(r, "start_time"); ok {
+		filter.StartTime = &t
+	}
+	if t, ok := QueryTime(r, "end_time"); ok {
+		filter.EndTime = &t
+	}
+	if msg := ValidateTimeRange(filter.StartTime, filter.EndTime); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+
+	incidents, total, err := h.db.ListCADIncidents(r.Context(), filter)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list cad incidents")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"incidents": incidents,
+		"total":     total,
+		"limit":     p.Limit,
+		"offset":    p.Offset,
+	})
+}
+
+// GetCADIncident returns an incident with its page text, linked calls and
+// the external events linked to those calls.
+// GET /api/v1/cad-incidents/{id}
+func (h *CADIncidentsHandler) GetCADIncident(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt64(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid incident ID")
+		return
+	}
+	inc, err := h.db.GetCADIncident(r.Context(), id, isAdmin(r))
+	if err != nil {
+		if err.Error() == "cad incident not found" {
+			WriteError(w, http.StatusNotFound, "cad incident not found")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to get cad incident")
+		return
+	}
+	if events, err := h.db.ListCADIncidentExternalEvents(r.Context(), id, isAdmin(r)); err == nil && len(events) > 0 {
+		inc.ExternalEvents = events
+	}
+	WriteJSON(w, http.StatusOK, inc)
+}
+
+// DeleteCADIncident removes an incident and its call links.
+// DELETE /api/v1/cad-incidents/{id}
+func (h *CADIncidentsHandler) DeleteCADIncident(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt64(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid incident ID")
+		return
+	}
+	if err := h.db.DeleteCADIncident(r.Context(), id); err != nil {
+		if err.Error() == "cad incident not found" {
+			WriteError(w, http.StatusNotFound, "cad incident not found")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to delete cad incident")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// IngestCADPage parses a raw RFC 5322 email (the request body) as a CAD page,
+// for mail relays that forward by webhook and for testing page formats.
+// Returns 201 with the incident, or 200 if its Message-ID was already ingested.
+// POST /api/v1/cad-incidents/ingest
+func (h *CADIncidentsHandler) IngestCADPage(w http.ResponseWriter, r *http.Request) {
+	if h.ingester == nil {
+		WriteError(w, http.StatusServiceUnavailable, "cad page ingestion not enabled (set CAD_PAGE_FORMATS)")
+		return
+	}
+	inc, inserted, err := h.ingester.Ingest(r.Context(), r.Body)
+	if err != nil {
+		if errors.Is(err, cadmail.ErrNoFormat) {
+			WriteErrorWithCode(w, http.StatusUnprocessableEntity, ErrInvalidBody, err.Error())
+			return
+		}
+		var parseErr *cadmail.ParseError
+		if errors.As(err, &parseErr) {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to ingest cad page")
+		return
+	}
+	if !inserted {
+		WriteJSON(w, http.StatusOK, inc)
+		return
+	}
+	WriteJSON(w, http.StatusCreated, inc)
+}
+
+// ListCallCADIncidents returns the CAD incidents a call is linked to.
+// GET /api/v1/calls/{id}/cad-incidents
+func (h *CADIncidentsHandler) ListCallCADIncidents(w http.ResponseWriter, r *http.Request) {
+	ref, err := ParseCallRef(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid call ID")
+		return
+	}
+	if !isAdmin(r) {
+		if restricted, err := h.db.CallRestricted(r.Context(), ref); err != nil || restricted {
+			WriteError(w, http.StatusNotFound, "call not found")
+			return
+		}
+	}
+	incidents, err := h.db.ListCallCADIncidents(r.Context(), ref)
+	if err != nil {
+		if err.Error() == "call not found" {
+			WriteError(w, http.StatusNotFound, "call not found")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to list cad incidents")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"incidents": incidents,
+		"total":     len(incidents),
+	})
+}
+
+// Routes registers CAD incident routes on the given router.
+func (h *CADIncidentsHandler) Routes(r chi.Router) {
+	r.Get("/cad-incidents", h.ListCADIncidents)
+	r.Post("/cad-incidents/ingest", h.IngestCADPage)
+	r.Get("/cad-incidents/{id}", h.GetCADIncident)
+	r.Delete("/cad-incidents/{id}", h.DeleteCADIncident)
+	r.Get("/calls/{id}/cad-incidents", h.ListCallCADIncidents)
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// annotationTypeRe is the form of an annotation type: a short slug such as
+// tone_detected or plate.match, so GET /calls?annotation_type= can match it.
+var annotationTypeRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+
+const (
+	maxAnnotationLabel = 500
+	maxAnnotationData  = 16 << 10
+)
+
+// CallAnnotationsHandler serves the typed annotations bots and external
+// decoders attach to calls.
+type CallAnnotationsHandler struct {
+	db *database.DB
+}
+
+func NewCallAnnotationsHandler(db *database.DB) *CallAnnotationsHandler {
+	return &CallAnnotationsHandler{db: db}
+}
+
+// validateCallAnnotation returns why a posted annotation is unusable, or "".
+func validateCallAnnotation(a *database.CallAnnotation) string {
+	switch {
+	case !annotationTypeRe.MatchString(a.Type):
+		return "type is required: lowercase letters, digits, '_', '.' or '-' (at most 64 characters)"
+	case a.Source == "" || len(a.Source) > 64:
+		return "source is required (at most 64 characters)"
+	case len(a.Label) > maxAnnotationLabel:
+		return "label must be at most 500 characters"
+	case a.Confidence != nil && (*a.Confidence < 0 || *a.Confidence > 1):
+		return "confidence must be between 0 and 1"
+	case len(a.Data) > maxAnnotationData:
+		return "data must be at most 16 KiB"
+	}
+	return ""
+}
+
+// resolveCall resolves the {id} call ref, writing a 404 for unknown calls
+// and, for non-admins, calls hidden by a restricted encryption policy.
+func resolveCall(db *database.DB, w http.ResponseWriter, r *http.Request) (database.CallRef, bool) {
+	ref, err := ParseCallRef(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid call ID")
+		return ref, false
+	}
+	if !isAdmin(r) {
+		if restricted, err := db.CallRestricted(r.Context(), ref); err != nil || restricted {
+			WriteError(w, http.StatusNotFound, "call not found")
+			return ref, false
+		}
+	}
+	ref, err = db.ResolveCallRef(r.Context(), ref)
+	if err != nil {
+		if err.Error() == "call not found" {
+			WriteError(w, http.StatusNotFound, "call not found")
+			return ref, false
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to resolve call")
+		return ref, false
+	}
+	return ref, true
+}
+
+// ListCallAnnotations returns a call's annotations, oldest first.
+// GET /api/v1/calls/{id}/annotations
+func (h *CallAnnotationsHandler) ListCallAnnotations(w http.ResponseWriter, r *http.Request) {
+	ref, ok := resolveCall(h.db, w, r)
+	if !ok {
+		return
+	}
+	annotations, err := h.db.ListCallsAnnotations(r.Context(), []database.CallRef{ref})
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list annotations")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"annotations": annotations,
+		"total":       len(annotations),
+	})
+}
+
+// CreateCallAnnotation adds an annotation to a call.
+// POST /api/v1/calls/{id}/annotations
+// Body: {"type": "tone_detected", "source": "tone-bot", "label", "confidence", "data": {...}}
+func (h *CallAnnotationsHandler) CreateCallAnnotation(w http.ResponseWriter, r *http.Request) {
+	var a database.CallAnnotation
+	if err := DecodeJSON(r, &a); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	if msg := validateCallAnnotation(&a); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, msg)
+		return
+	}
+	ref, ok := resolveCall(h.db, w, r)
+	if !ok {
+		return
+	}
+	created, err := h.db.CreateCallAnnotation(r.Context(), ref, &a)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to create annotation")
+		return
+	}
+	WriteJSON(w, http.StatusCreated, created)
+}
+
+// DeleteCallAnnotation removes one of a call's annotations.
+// DELETE /api/v1/calls/{id}/annotations/{annotation_id}
+func (h *CallAnnotationsHandler) DeleteCallAnnotation(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt64(r, "annotation_id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid annotation ID")
+		return
+	}
+	ref, ok := resolveCall(h.db, w, r)
+	if !ok {
+		return
+	}
+	if err := h.db.DeleteCallAnnotation(r.Context(), ref, id); err != nil {
+		if err.Error() == "annotation not found" {
+			WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to delete annotation")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Routes registers call annotation routes on the given router.
+func (h *CallAnnotationsHandler) Routes(r chi.Router) {
+	r.Get("/calls/{id}/annotations", h.ListCallAnnotations)
+	r.Post("/calls/{id}/annotations", h.CreateCallAnnotation)
+	r.Delete("/calls/{id}/annotations/{annotation_id}", h.DeleteCallAnnotation)
+}
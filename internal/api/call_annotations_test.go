@@ -0,0 +1,33 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+func TestValidateCallAnnotation(t *testing.T) {
+	conf, over := float32(0.8), float32(1.5)
+	tests := []struct {
+		name string
+		a    database.CallAnnotation
+		ok   bool
+	}{
+		{"minimal", database.CallAnnotation{Type: "tone_detected", Source: "tone-bot"}, true},
+		{"full", database.CallAnnotation{Type: "plate.match", Source: "alpr", Label: "ABC1234", Confidence: &conf,
+			Data: json.RawMessage(`{"plate":"ABC1234"}`)}, true},
+		{"no type", database.CallAnnotation{Source: "tone-bot"}, false},
+		{"uppercase type", database.CallAnnotation{Type: "Tone", Source: "tone-bot"}, false},
+		{"spaces in type", database.CallAnnotation{Type: "tone detected", Source: "tone-bot"}, false},
+		{"no source", database.CallAnnotation{Type: "tone_detected"}, false},
+		{"confidence out of range", database.CallAnnotation{Type: "tone_detected", Source: "tone-bot", Confidence: &over}, false},
+		{"long label", database.CallAnnotation{Type: "note", Source: "bot", Label: strings.Repeat("x", maxAnnotationLabel+1)}, false},
+	}
+	for _, tt := range tests {
+		if msg := validateCallAnnotation(&tt.a); (msg == "") != tt.ok {
+			t.Errorf("%s: validateCallAnnotation = %q, want ok=%v", tt.name, msg, tt.ok)
+		}
+	}
+}
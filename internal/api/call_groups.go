@@ -69,6 +69,19 @@ func (h *CallGroupsHandler) GetCallGroup(w http.ResponseWriter, r *http.Request)
 		WriteError(w, http.StatusNotFound, "call group not found")
 		return
 	}
+	if !isAdmin(r) {
+		visible := calls[:0]
+		for _, c := range calls {
+			if !c.Restricted {
+				visible = append(visible, c)
+			}
+		}
+		if len(visible) == 0 && len(calls) > 0 {
+			WriteError(w, http.StatusNotFound, "call group not found")
+			return
+		}
+		calls = visible
+	}
 	if h.trAudioDir != "" {
 		for i := range calls {
 			if calls[i].AudioURL == nil && calls[i].CallFilename != "" {
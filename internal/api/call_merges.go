@@ -0,0 +1,85 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// CallMergesHandler reports split calls that ingest merged into the call
+// they continue (SPLIT_CALL_MAX_GAP) and undoes merges.
+type CallMergesHandler struct {
+	db *database.DB
+}
+
+func NewCallMergesHandler(db *database.DB) *CallMergesHandler {
+	return &CallMergesHandler{db: db}
+}
+
+// ListCallMerges returns merges, newest first. Filters: system_id, tgid,
+// start_time/end_time (merged call's start), include_undone.
+func (h *CallMergesHandler) ListCallMerges(w http.ResponseWriter, r *http.Request) {
+	p, err := ParsePagination(r)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+	f := database.CallMergeFilter{
+		SystemIDs: QueryIntListAliased(r, "system_id", "systems"),
+		Tgids:     QueryIntListAliased(r, "tgid", "tgids"),
+		Limit:     p.Limit,
+		Offset:    p.Offset,
+	}
+	if t, ok := QueryTime(r, "start_time"); ok {
+		f.Start = &t
+	}
+	if t, ok := QueryTime(r, "end_time"); ok {
+		f.End = &t
+	}
+	if msg := ValidateTimeRange(f.Start, f.End); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+	if v, ok := QueryBool(r, "include_undone"); ok {
+		f.IncludeUndone = v
+	}
+
+	merges, total, err := h.db.ListCallMerges(r.Context(), f)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list call merges")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"merges": merges,
+		"total":  total,
+		"limit":  p.Limit,
+		"offset": p.Offset,
+	})
+}
+
+// UndoCallMerge reverses a merge; the merged call shows in call lists again.
+func (h *CallMergesHandler) UndoCallMerge(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt64(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid merge ID")
+		return
+	}
+	m, err := h.db.UndoCallMerge(r.Context(), id, "api")
+	if err != nil {
+		if err.Error() == "call merge not found or already undone" {
+			WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to undo call merge")
+		return
+	}
+	WriteJSON(w, http.StatusOK, m)
+}
+
+// Routes registers call merge routes on the given router.
+func (h *CallMergesHandler) Routes(r chi.Router) {
+	r.Get("/call-merges", h.ListCallMerges)
+	r.Post("/call-merges/{id}/undo", h.UndoCallMerge)
+}
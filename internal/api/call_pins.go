@@ -0,0 +1,100 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+const maxPinNote = 500
+
+// CallPinsHandler pins calls an operator wants kept. With
+// AUDIO_RETENTION_KEEP_PINNED (the default), audio retention, the disk-usage
+// audio purge and the call purge skip pinned calls.
+type CallPinsHandler struct {
+	db *database.DB
+}
+
+func NewCallPinsHandler(db *database.DB) *CallPinsHandler {
+	return &CallPinsHandler{db: db}
+}
+
+// ListCallPins returns pinned calls, most recently pinned first.
+// GET /api/v1/calls/pinned
+func (h *CallPinsHandler) ListCallPins(w http.ResponseWriter, r *http.Request) {
+	p, err := ParsePagination(r)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+	pins, total, err := h.db.ListCallPins(r.Context(), p.Limit, p.Offset)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list pinned calls")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"pins":   pins,
+		"total":  total,
+		"limit":  p.Limit,
+		"offset": p.Offset,
+	})
+}
+
+// PinCall pins a call, or updates the note of its pin.
+// PUT /api/v1/calls/{id}/pin
+// Body (optional): {"note": "...", "actor": "..."}
+func (h *CallPinsHandler) PinCall(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Note  string `json:"note"`
+		Actor string `json:"actor"`
+	}
+	if r.ContentLength != 0 {
+		if err := DecodeJSON(r, &req); err != nil {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+			return
+		}
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	if len(req.Note) > maxPinNote {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "note must be at most 500 characters")
+		return
+	}
+	ref, ok := resolveCall(h.db, w, r)
+	if !ok {
+		return
+	}
+	pin, err := h.db.PinCall(r.Context(), ref, req.Note, strings.TrimSpace(req.Actor))
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to pin call")
+		return
+	}
+	WriteJSON(w, http.StatusOK, pin)
+}
+
+// UnpinCall removes a call's pin; retention treats it like any other call.
+// DELETE /api/v1/calls/{id}/pin
+func (h *CallPinsHandler) UnpinCall(w http.ResponseWriter, r *http.Request) {
+	ref, ok := resolveCall(h.db, w, r)
+	if !ok {
+		return
+	}
+	if err := h.db.UnpinCall(r.Context(), ref); err != nil {
+		if err.Error() == "call is not pinned" {
+			WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to unpin call")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Routes registers call pin routes on the given router.
+func (h *CallPinsHandler) Routes(r chi.Router) {
+	r.Get("/calls/pinned", h.ListCallPins)
+	r.Put("/calls/{id}/pin", h.PinCall)
+	r.Delete("/calls/{id}/pin", h.UnpinCall)
+}
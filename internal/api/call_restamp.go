@@ -0,0 +1,77 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/restamp"
+)
+
+// CallRestampHandler re-stamps the talkgroup and unit names stored on calls
+// from the current talkgroup and unit data, and reports progress.
+type CallRestampHandler struct {
+	restamper *restamp.Restamper
+}
+
+func NewCallRestampHandler(restamper *restamp.Restamper) *CallRestampHandler {
+	return &CallRestampHandler{restamper: restamper}
+}
+
+// GetCallRestampStatus reports whether a re-stamp is running and the last
+// run's scope, progress and row counts.
+func (h *CallRestampHandler) GetCallRestampStatus(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, http.StatusOK, h.restamper.Status())
+}
+
+// RunCallRestamp starts a re-stamp. Body:
+// {"system_id": 1, "tgids": [9131], "start_time": "...", "end_time": "..."},
+// all optional except that tgids needs system_id and one of system_id or
+// start_time is required. start_time defaults to the first call in scope and
+// end_time to now.
+func (h *CallRestampHandler) RunCallRestamp(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SystemID  int        `json:"system_id"`
+		Tgids     []int      `json:"tgids"`
+		StartTime *time.Time `json:"start_time"`
+		EndTime   *time.Time `json:"end_time"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	if msg := ValidateTimeRange(req.StartTime, req.EndTime); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+	if req.SystemID < 0 {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "invalid system_id")
+		return
+	}
+	if req.SystemID == 0 && req.StartTime == nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "system_id or start_time is required")
+		return
+	}
+	scope := restamp.Scope{SystemID: req.SystemID, Tgids: req.Tgids}
+	if req.StartTime != nil {
+		scope.Start = *req.StartTime
+	}
+	if req.EndTime != nil {
+		scope.End = *req.EndTime
+	}
+	if err := scope.Validate(); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+	if err := h.restamper.Run(scope, "api"); err != nil {
+		WriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+	WriteJSON(w, http.StatusAccepted, h.restamper.Status())
+}
+
+func (h *CallRestampHandler) Routes(r chi.Router) {
+	r.Get("/admin/call-restamp", h.GetCallRestampStatus)
+	r.Post("/admin/call-restamp/run", h.RunCallRestamp)
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/snarg/tr-engine/internal/audio"
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/pkg/events"
+)
+
+// Live listen stream settings.
+const (
+	callStreamBitrate    = 32000
+	callStreamSampleRate = 16000
+	callStreamMaxTgids   = 50
+	callStreamQueue      = 20               // finished calls waiting to be streamed
+	callStreamAudioWait  = 30 * time.Second // how long after call_end its audio may arrive
+	callStreamAudioPoll  = time.Second
+)
+
+var errNoCallAudio = errors.New("call has no audio")
+
+// StreamCallAudio streams the audio of calls on the given talkgroups as they
+// finish, as one continuous MP3 stream an <audio> element can play:
+// /calls/stream?tgid=1234&token=... Filters: tgid (required,
+// comma-separated), system_id, emergency_only. Encrypted calls are skipped;
+// a call's audio is waited for up to 30s after its call_end, and when
+// playback falls behind by more than 20 calls the newest ones are dropped.
+// Needs ffmpeg to transcode each call.
+// GET /api/v1/calls/stream
+func (h *CallsHandler) StreamCallAudio(w http.ResponseWriter, r *http.Request) {
+	if h.live == nil {
+		WriteError(w, http.StatusServiceUnavailable, "event streaming not available")
+		return
+	}
+	filter := EventFilter{
+		Systems:           QueryIntListAliased(r, "system_id", "systems"),
+		Tgids:             QueryIntListAliased(r, "tgid", "tgids"),
+		Types:             []string{events.TypeCallEnd},
+		IncludeRestricted: isAdmin(r),
+	}
+	if len(filter.Tgids) == 0 {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "tgid is required")
+		return
+	}
+	if len(filter.Tgids) > callStreamMaxTgids {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter,
+			fmt.Sprintf("at most %d tgids", callStreamMaxTgids))
+		return
+	}
+	if v, ok := QueryBool(r, "emergency_only"); ok {
+		filter.EmergencyOnly = v
+	}
+	if !audio.CheckFFmpeg() {
+		WriteError(w, http.StatusServiceUnavailable, "live call audio requires ffmpeg")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		WriteError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	w.Header().Set("Content-Type", "audio/mpeg")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ch, cancel := h.live.Subscribe(filter)
+	defer cancel()
+
+	log := hlog.FromRequest(r)
+	log.Info().Ints("tgids", filter.Tgids).Msg("live call audio client connected")
+
+	// Calls are streamed one at a time while new call_ends keep arriving.
+	ctx, stop := context.WithCancel(r.Context())
+	defer stop()
+	pending := make(chan events.CallEnd, callStreamQueue)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer stop()
+		for c := range pending {
+			data, err := h.liveCallAudio(ctx, c)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Debug().Err(err).Int64("call_id", c.CallID).Msg("live call audio skipped")
+				}
+				continue
+			}
+			if _, err := w.Write(data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}()
+	defer func() {
+		stop()
+		close(pending)
+		<-done
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("live call audio client disconnected")
+			return
+		case event, ok := <-ch:
+			if !ok {
+				return
+			}
+			var c events.CallEnd
+			if json.Unmarshal(event.Data, &c) != nil || c.Encrypted || c.CallID == 0 {
+				continue
+			}
+			select {
+			case pending <- c:
+			default:
+				log.Debug().Int64("call_id", c.CallID).Msg("live call audio behind, call dropped")
+			}
+		}
+	}
+}
+
+// liveCallAudio waits for a finished call's audio to be stored and returns
+// it as MP3.
+func (h *CallsHandler) liveCallAudio(ctx context.Context, c events.CallEnd) ([]byte, error) {
+	ref := database.CallRef{CallID: c.CallID, StartTime: c.StartTime}
+	deadline := time.Now().Add(callStreamAudioWait)
+	for {
+		data, ext, err := h.readCallAudio(ctx, ref)
+		if err == nil {
+			return audio.TranscodeMP3(ctx, data, ext, callStreamBitrate, callStreamSampleRate)
+		}
+		if !errors.Is(err, errNoCallAudio) || time.Now().After(deadline) {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(callStreamAudioPoll):
+		}
+	}
+}
+
+// readCallAudio reads a call's audio from the audio store, or from TR's
+// audio directory, returning it with its file extension.
+func (h *CallsHandler) readCallAudio(ctx context.Context, ref database.CallRef) ([]byte, string, error) {
+	audioPath, callFilename, err := h.db.GetCallAudioPath(ctx, ref)
+	if err != nil {
+		return nil, "", errNoCallAudio
+	}
+	if audioPath != "" && h.store != nil {
+		if rc, err := h.store.Open(ctx, audioPath); err == nil {
+			defer rc.Close()
+			data, err := io.ReadAll(rc)
+			return data, filepath.Ext(audioPath), err
+		}
+	}
+	if path := h.resolveAudioFile(audioPath, callFilename); path != "" {
+		data, err := os.ReadFile(path)
+		return data, filepath.Ext(path), err
+	}
+	return nil, "", errNoCallAudio
+}
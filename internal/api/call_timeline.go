@@ -0,0 +1,242 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/timeline"
+	"github.com/snarg/tr-engine/internal/transcribe"
+)
+
+// maxTimelineCalls caps how many calls one timeline export stitches.
+const maxTimelineCalls = 200
+
+// ExportCallTimeline stitches a set of calls into one review package: a zip
+// with timeline.wav (calls in chronological order, gap_ms of silence between
+// them), transcript.vtt and transcript.txt (speaker = unit tag, absolute
+// timestamps) and manifest.json. External events linked to the calls are
+// listed in the manifest and at the end of transcript.txt.
+//
+// The calls are either call_ids (an arbitrary set) or an incident window
+// given by the /calls filters (system_id, tgid, unit_id, start_time,
+// end_time, emergency, deduplicate), which is required to have a start_time.
+func (h *CallsHandler) ExportCallTimeline(w http.ResponseWriter, r *http.Request) {
+	admin := isAdmin(r)
+	var calls []database.CallAPI
+
+	if ids := QueryInt64List(r, "call_ids"); len(ids) > 0 {
+		if len(ids) > maxTimelineCalls {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter,
+				fmt.Sprintf("at most %d call_ids", maxTimelineCalls))
+			return
+		}
+		for _, id := range ids {
+			call, err := h.db.GetCallByID(r.Context(), database.CallRef{CallID: id})
+			if err != nil || (call.Restricted && !admin) {
+				WriteError(w, http.StatusNotFound, fmt.Sprintf("call %d not found", id))
+				return
+			}
+			calls = append(calls, *call)
+		}
+	} else {
+		filter := database.CallFilter{
+			Limit:             maxTimelineCalls + 1,
+			Sort:              "c.start_time ASC",
+			IncludeRestricted: admin,
+			IncludeMerged:     true, // split continuations carry the rest of the audio
+		}
+		filter.SystemIDs = QueryIntListAliased(r, "system_id", "systems")
+		filter.Tgids = QueryIntListAliased(r, "tgid", "tgids")
+		filter.UnitIDs = QueryIntListAliased(r, "unit_id", "units", "unit_ids")
+		if v, ok := QueryBool(r, "emergency"); ok {
+			filter.Emergency = &v
+		}
+		filter.Deduplicate = true
+		if v, ok := QueryBool(r, "deduplicate"); ok {
+			filter.Deduplicate = v
+		}
+		t, ok := QueryTime(r, "start_time")
+		if !ok {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "call_ids or start_time is required")
+			return
+		}
+		filter.StartTime = &t
+		if t, ok := QueryTime(r, "end_time"); ok {
+			filter.EndTime = &t
+		}
+		if msg := ValidateTimeRange(filter.StartTime, filter.EndTime); msg != "" {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+			return
+		}
+		filter.Encrypted = new(bool) // encrypted calls have no audio to stitch
+
+		var err error
+		calls, _, err = h.db.ListCalls(r.Context(), filter)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to list calls")
+			return
+		}
+		if len(calls) > maxTimelineCalls {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter,
+				fmt.Sprintf("more than %d calls match; narrow the filters or time range", maxTimelineCalls))
+			return
+		}
+	}
+	if len(calls) == 0 {
+		WriteError(w, http.StatusNotFound, "no calls match")
+		return
+	}
+
+	opts := timeline.Options{}
+	if v, ok := QueryInt(r, "gap_ms"); ok {
+		if v < 0 || v > 60000 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "gap_ms must be between 0 and 60000")
+			return
+		}
+		opts.Gap = time.Duration(v) * time.Millisecond
+		if v == 0 {
+			opts.Gap = -1
+		}
+	}
+
+	ids := make([]int64, len(calls))
+	for i, c := range calls {
+		ids[i] = c.CallID
+	}
+	transcripts, err := h.db.GetBatchTranscriptions(r.Context(), ids, true) // calls already checked above
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to load transcriptions")
+		return
+	}
+	byCall := make(map[int64]database.BatchTranscriptionRow, len(transcripts))
+	for _, t := range transcripts {
+		byCall[t.CallID] = t
+	}
+
+	items := make([]timeline.Call, len(calls))
+	for i, c := range calls {
+		item := timeline.Call{
+			CallID:     c.CallID,
+			SystemName: c.SystemName,
+			Tgid:       c.Tgid,
+			TgAlphaTag: c.TgAlphaTag,
+			StartTime:  c.StartTime,
+		}
+		if c.Duration != nil {
+			item.Duration = float64(*c.Duration)
+		}
+		if t, ok := byCall[c.CallID]; ok {
+			item.Text = t.Text
+			if len(t.Segments) > 0 {
+				var segs []transcribe.Segment
+				if json.Unmarshal(t.Segments, &segs) == nil {
+					item.Segments = segs
+				}
+			}
+		}
+		path, cleanup := h.localAudio(r, database.CallRef{CallID: c.CallID, StartTime: c.StartTime})
+		defer cleanup()
+		item.AudioPath = path
+		items[i] = item
+	}
+
+	pkg, err := timeline.Build(r.Context(), items, opts)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+
+	refs := make([]database.CallRef, len(calls))
+	for i, c := range calls {
+		refs[i] = database.CallRef{CallID: c.CallID, StartTime: c.StartTime}
+	}
+	external, err := h.db.ListCallsExternalEvents(r.Context(), refs)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to load external events")
+		return
+	}
+	for _, e := range external {
+		pkg.ExternalEvents = append(pkg.ExternalEvents, timeline.ExternalEvent{
+			Time:      e.EventTime,
+			Source:    e.Source,
+			Kind:      e.Kind,
+			Subject:   e.Subject,
+			Label:     e.Label,
+			Latitude:  e.Latitude,
+			Longitude: e.Longitude,
+			CallIDs:   e.CallIDs,
+			Data:      e.Data,
+		})
+	}
+
+	annotations, err := h.db.ListCallsAnnotations(r.Context(), refs)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to load annotations")
+		return
+	}
+	for _, a := range annotations {
+		pkg.Annotations = append(pkg.Annotations, timeline.Annotation{
+			CallID:     a.CallID,
+			Time:       a.CreatedAt,
+			Type:       a.Type,
+			Source:     a.Source,
+			Label:      a.Label,
+			Confidence: a.Confidence,
+			Data:       a.Data,
+		})
+	}
+
+	name := fmt.Sprintf("timeline-%s.zip", calls[0].StartTime.UTC().Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	if err := pkg.WriteZip(w); err != nil {
+		hlog.FromRequest(r).Warn().Err(err).Msg("timeline export interrupted")
+	}
+}
+
+// localAudio returns a local file for a call's audio, downloading it from
+// the audio store to a temp file when it isn't cached. The cleanup func
+// removes any temp file. Returns "" if the audio can't be found.
+func (h *CallsHandler) localAudio(r *http.Request, ref database.CallRef) (string, func()) {
+	audioPath, callFilename, err := h.db.GetCallAudioPath(r.Context(), ref)
+	if err != nil {
+		return "", func() {}
+	}
+	return h.localAudioFile(r, audioPath, callFilename)
+}
+
+// localAudioFile is localAudio for a call whose audio path and TR filename
+// are already known.
+func (h *CallsHandler) localAudioFile(r *http.Request, audioPath, callFilename string) (string, func()) {
+	noop := func() {}
+	if audioPath != "" && h.store != nil {
+		if local := h.store.LocalPath(audioPath); local != "" {
+			return local, noop
+		}
+		if rc, err := h.store.Open(r.Context(), audioPath); err == nil {
+			defer rc.Close()
+			// Keep the extension; decoding picks the format from it.
+			tmp, err := os.CreateTemp("", "tr-timeline-*"+strings.ToLower(filepath.Ext(audioPath)))
+			if err != nil {
+				return "", noop
+			}
+			_, cpErr := io.Copy(tmp, rc)
+			tmp.Close()
+			if cpErr != nil {
+				os.Remove(tmp.Name())
+				return "", noop
+			}
+			return tmp.Name(), func() { os.Remove(tmp.Name()) }
+		}
+	}
+	return h.resolveAudioFile(audioPath, callFilename), noop
+}
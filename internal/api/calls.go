@@ -39,6 +39,15 @@ func (h *CallsHandler) enrichAudioURLs(calls []database.CallAPI) {
 	}
 }
 
+// audioContentTypes maps audio file extensions to their MIME types.
+var audioContentTypes = map[string]string{
+	".m4a":  "audio/mp4",
+	".mp3":  "audio/mpeg",
+	".wav":  "audio/wav",
+	".ogg":  "audio/ogg",
+	".opus": "audio/ogg",
+}
+
 var callSortFields = map[string]string{
 	"start_time": "c.start_time",
 	"stop_time":  "c.stop_time",
@@ -47,6 +56,38 @@ var callSortFields = map[string]string{
 	"freq":       "c.freq",
 }
 
+// callExpansions are the ?expand= values: related records embedded in each
+// call (see database.ExpandCalls).
+var callExpansions = []string{"transcription", "transmissions", "frequencies", "group", "unit_tags"}
+
+// parseCallExpansion reads the comma-separated ?expand= list. Returns an
+// error message naming the first unknown value.
+func parseCallExpansion(r *http.Request) (database.CallExpansion, string) {
+	x := database.CallExpansion{IncludeRestricted: isAdmin(r)}
+	v, ok := QueryString(r, "expand")
+	if !ok {
+		return x, ""
+	}
+	for _, name := range strings.Split(v, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "transcription":
+			x.Transcription = true
+		case "transmissions":
+			x.Transmissions = true
+		case "frequencies":
+			x.Frequencies = true
+		case "group":
+			x.Group = true
+		case "unit_tags":
+			x.UnitTags = true
+		default:
+			return x, fmt.Sprintf("invalid expand value %q (valid: %s)", strings.TrimSpace(name), strings.Join(callExpansions, ", "))
+		}
+	}
+	return x, ""
+}
+
 // ListCalls returns calls with comprehensive filters.
 func (h *CallsHandler) ListCalls(w http.ResponseWriter, r *http.Request) {
 	p, err := ParsePagination(r)
@@ -55,6 +96,15 @@ func (h *CallsHandler) ListCalls(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	sort := ParseSort(r, "-start_time", callSortFields)
+	expand, msg := parseCallExpansion(r)
+	if msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, msg)
+		return
+	}
+	if expand.Group {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "expand=group is only supported on a single call")
+		return
+	}
 
 	filter := database.CallFilter{
 		Limit:  p.Limit,
@@ -73,9 +123,25 @@ func (h *CallsHandler) ListCalls(w http.ResponseWriter, r *http.Request) {
 	if v, ok := QueryBool(r, "encrypted"); ok {
 		filter.Encrypted = &v
 	}
+	if v, ok := QueryBool(r, "duration_mismatch"); ok {
+		filter.DurationMismatch = &v
+	}
+	if v, ok := QueryBool(r, "interconnect"); ok {
+		filter.Interconnect = &v
+	}
+	if v, ok := QueryBool(r, "stuck_mic"); ok {
+		filter.StuckMic = &v
+	}
+	if v, ok := QueryBool(r, "audio_unusable"); ok {
+		filter.AudioUnusable = &v
+	}
+	filter.AnnotationType, _ = QueryString(r, "annotation_type")
 	if v, ok := QueryBool(r, "deduplicate"); ok {
 		filter.Deduplicate = v
 	}
+	if v, ok := QueryBool(r, "include_merged"); ok {
+		filter.IncludeMerged = v
+	}
 	if t, ok := QueryTime(r, "start_time"); ok {
 		filter.StartTime = &t
 	}
@@ -87,12 +153,49 @@ func (h *CallsHandler) ListCalls(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	filter.IncludeRestricted = isAdmin(r)
+
+	share := shareScopeFrom(r)
+	if share != nil && !share.restrictCalls(&filter) {
+		WriteJSON(w, http.StatusOK, map[string]any{
+			"calls":  []database.CallAPI{},
+			"total":  0,
+			"limit":  p.Limit,
+			"offset": p.Offset,
+		})
+		return
+	}
+
+	filter.Omit = RequestFieldset(r).Omitted(database.OmittableCallFields)
+	// Expansions are built from these columns, even when the fieldset
+	// leaves them out of the response.
+	if expand.Transmissions {
+		delete(filter.Omit, "src_list")
+	}
+	if expand.Frequencies {
+		delete(filter.Omit, "freq_list")
+	}
+	if expand.UnitTags {
+		delete(filter.Omit, "unit_ids")
+	}
 	calls, total, err := h.db.ListCalls(r.Context(), filter)
 	if err != nil {
 		WriteError(w, http.StatusInternalServerError, "failed to list calls")
 		return
 	}
+	if err := h.db.ExpandCalls(r.Context(), calls, expand); err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to expand calls")
+		return
+	}
 	h.enrichAudioURLs(calls)
+	if share != nil {
+		for i := range calls {
+			if calls[i].AudioURL != nil {
+				u := share.audioURL(*calls[i].AudioURL)
+				calls[i].AudioURL = &u
+			}
+		}
+	}
 	WriteJSON(w, http.StatusOK, map[string]any{
 		"calls":  calls,
 		"total":  total,
@@ -118,10 +221,14 @@ func (h *CallsHandler) ListActiveCalls(w http.ResponseWriter, r *http.Request) {
 	tgid, hasTgid := QueryInt(r, "tgid")
 	emergency, hasEmergency := QueryBool(r, "emergency")
 	encrypted, hasEncrypted := QueryBool(r, "encrypted")
+	admin := isAdmin(r)
 
-	if hasSysid || hasTgid || hasEmergency || hasEncrypted {
+	if hasSysid || hasTgid || hasEmergency || hasEncrypted || !admin {
 		filtered := make([]ActiveCallData, 0, len(calls))
 		for _, c := range calls {
+			if c.Restricted && !admin {
+				continue
+			}
 			if hasSysid && c.Sysid != sysid {
 				continue
 			}
@@ -145,16 +252,22 @@ func (h *CallsHandler) ListActiveCalls(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// GetCall returns a single call by ID.
+// GetCall returns a single call by ID, with the related records named by
+// ?expand= embedded.
 func (h *CallsHandler) GetCall(w http.ResponseWriter, r *http.Request) {
-	id, err := PathInt64(r, "id")
+	ref, err := ParseCallRef(r, "id")
 	if err != nil {
 		WriteError(w, http.StatusBadRequest, "invalid call ID")
 		return
 	}
+	expand, msg := parseCallExpansion(r)
+	if msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, msg)
+		return
+	}
 
-	call, err := h.db.GetCallByID(r.Context(), id)
-	if err != nil {
+	call, err := h.db.GetCallByID(r.Context(), ref)
+	if err != nil || (call.Restricted && !isAdmin(r)) {
 		WriteError(w, http.StatusNotFound, "call not found")
 		return
 	}
@@ -162,23 +275,80 @@ func (h *CallsHandler) GetCall(w http.ResponseWriter, r *http.Request) {
 		url := fmt.Sprintf("/api/v1/calls/%d/audio", call.CallID)
 		call.AudioURL = &url
 	}
+	if incidents, err := h.db.ListCallCADIncidents(r.Context(), database.CallRef{CallID: call.CallID, StartTime: call.StartTime}); err == nil && len(incidents) > 0 {
+		call.CADIncidents = incidents
+	}
+	if events, err := h.db.ListCallsExternalEvents(r.Context(), []database.CallRef{{CallID: call.CallID, StartTime: call.StartTime}}); err == nil && len(events) > 0 {
+		call.ExternalEvents = events
+	}
+	if annotations, err := h.db.ListCallsAnnotations(r.Context(), []database.CallRef{{CallID: call.CallID, StartTime: call.StartTime}}); err == nil && len(annotations) > 0 {
+		call.Annotations = annotations
+	}
+	if !expand.Empty() {
+		calls := []database.CallAPI{*call}
+		if err := h.db.ExpandCalls(r.Context(), calls, expand); err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to expand call")
+			return
+		}
+		call = &calls[0]
+	}
 	WriteJSON(w, http.StatusOK, call)
 }
 
-// GetCallAudio streams the audio file for a call.
+// GetCallAudio streams the audio file for a call: the default variant, or
+// the one named by ?variant=.
 func (h *CallsHandler) GetCallAudio(w http.ResponseWriter, r *http.Request) {
-	id, err := PathInt64(r, "id")
+	ref, err := ParseCallRef(r, "id")
 	if err != nil {
 		WriteError(w, http.StatusBadRequest, "invalid call ID")
 		return
 	}
+	if share := shareScopeFrom(r); share != nil {
+		// Shared clients get the default audio of calls in scope only.
+		call, err := h.db.GetCallByID(r.Context(), ref)
+		if err != nil || !share.allows(call.SystemID, call.Tgid, call.StartTime) {
+			WriteError(w, http.StatusNotFound, "audio not found")
+			return
+		}
+		h.serveCallAudio(w, r, database.CallRef{CallID: call.CallID, StartTime: call.StartTime})
+		return
+	}
+	if variant, ok := QueryString(r, "variant"); ok && variant != "" {
+		h.serveCallAudioVariant(w, r, ref, variant)
+		return
+	}
+	h.serveCallAudio(w, r, ref)
+}
+
+// hideRestricted writes a 404 with msg and returns true if the call is
+// hidden from this client by a restricted encryption policy.
+func (h *CallsHandler) hideRestricted(w http.ResponseWriter, r *http.Request, ref database.CallRef, msg string) bool {
+	if isAdmin(r) {
+		return false
+	}
+	if restricted, err := h.db.CallRestricted(r.Context(), ref); err != nil || restricted {
+		WriteError(w, http.StatusNotFound, msg)
+		return true
+	}
+	return false
+}
 
-	audioPath, callFilename, err := h.db.GetCallAudioPath(r.Context(), id)
+// serveCallAudio streams the audio for the referenced call from storage or disk.
+func (h *CallsHandler) serveCallAudio(w http.ResponseWriter, r *http.Request, ref database.CallRef) {
+	if h.hideRestricted(w, r, ref, "audio not found") {
+		return
+	}
+	audioPath, callFilename, err := h.db.GetCallAudioPath(r.Context(), ref)
 	if err != nil {
 		WriteError(w, http.StatusNotFound, "audio not found")
 		return
 	}
+	h.serveStoredAudio(w, r, ref.CallID, audioPath, callFilename)
+}
 
+// serveStoredAudio streams audio by storage key, falling back to TR's
+// call_filename in TR_AUDIO_DIR mode.
+func (h *CallsHandler) serveStoredAudio(w http.ResponseWriter, r *http.Request, id int64, audioPath, callFilename string) {
 	// 1. Try storage layer (local cache for tiered, local disk for local-only)
 	if audioPath != "" && h.store != nil {
 		if localFile := h.store.LocalPath(audioPath); localFile != "" {
@@ -192,13 +362,7 @@ func (h *CallsHandler) GetCallAudio(w http.ResponseWriter, r *http.Request) {
 		if rc, openErr := h.store.Open(r.Context(), audioPath); openErr == nil {
 			defer rc.Close()
 			ext := strings.ToLower(filepath.Ext(audioPath))
-			contentTypes := map[string]string{
-				".m4a": "audio/mp4",
-				".mp3": "audio/mpeg",
-				".wav": "audio/wav",
-				".ogg": "audio/ogg",
-			}
-			if ct, ok := contentTypes[ext]; ok {
+			if ct, ok := audioContentTypes[ext]; ok {
 				w.Header().Set("Content-Type", ct)
 			} else {
 				w.Header().Set("Content-Type", "application/octet-stream")
@@ -221,13 +385,7 @@ func (h *CallsHandler) GetCallAudio(w http.ResponseWriter, r *http.Request) {
 
 func (h *CallsHandler) serveLocalFile(w http.ResponseWriter, r *http.Request, path string, callID int64) {
 	ext := strings.ToLower(filepath.Ext(path))
-	contentTypes := map[string]string{
-		".m4a": "audio/mp4",
-		".mp3": "audio/mpeg",
-		".wav": "audio/wav",
-		".ogg": "audio/ogg",
-	}
-	if ct, ok := contentTypes[ext]; ok {
+	if ct, ok := audioContentTypes[ext]; ok {
 		w.Header().Set("Content-Type", ct)
 	} else {
 		w.Header().Set("Content-Type", "application/octet-stream")
@@ -243,13 +401,16 @@ func (h *CallsHandler) resolveAudioFile(audioPath, callFilename string) string {
 
 // GetCallFrequencies returns frequency entries for a call.
 func (h *CallsHandler) GetCallFrequencies(w http.ResponseWriter, r *http.Request) {
-	id, err := PathInt64(r, "id")
+	ref, err := ParseCallRef(r, "id")
 	if err != nil {
 		WriteError(w, http.StatusBadRequest, "invalid call ID")
 		return
 	}
 
-	freqs, err := h.db.GetCallFrequencies(r.Context(), id)
+	if h.hideRestricted(w, r, ref, "call not found") {
+		return
+	}
+	freqs, err := h.db.GetCallFrequencies(r.Context(), ref)
 	if err != nil {
 		WriteError(w, http.StatusNotFound, "call not found")
 		return
@@ -279,13 +440,16 @@ func (h *CallsHandler) GetCallFrequencies(w http.ResponseWriter, r *http.Request
 
 // GetCallTransmissions returns transmission entries for a call.
 func (h *CallsHandler) GetCallTransmissions(w http.ResponseWriter, r *http.Request) {
-	id, err := PathInt64(r, "id")
+	ref, err := ParseCallRef(r, "id")
 	if err != nil {
 		WriteError(w, http.StatusBadRequest, "invalid call ID")
 		return
 	}
 
-	txs, err := h.db.GetCallTransmissions(r.Context(), id)
+	if h.hideRestricted(w, r, ref, "call not found") {
+		return
+	}
+	txs, err := h.db.GetCallTransmissions(r.Context(), ref)
 	if err != nil {
 		WriteError(w, http.StatusNotFound, "call not found")
 		return
@@ -317,8 +481,14 @@ func (h *CallsHandler) GetCallTransmissions(w http.ResponseWriter, r *http.Reque
 func (h *CallsHandler) Routes(r chi.Router) {
 	r.Get("/calls", h.ListCalls)
 	r.Get("/calls/active", h.ListActiveCalls)
+	r.Get("/calls/timeline", h.ExportCallTimeline)
+	r.Get("/calls/stream", h.StreamCallAudio)
 	r.Get("/calls/{id}", h.GetCall)
 	r.Get("/calls/{id}/audio", h.GetCallAudio)
+	r.Get("/calls/{id}/audio-variants", h.ListCallAudioVariants)
+	r.Post("/calls/{id}/audio-variants", h.UploadCallAudioVariant)
+	r.Post("/calls/{id}/audio-variants/{variant}/default", h.SetDefaultCallAudioVariant)
+	r.Get("/calls/{id}/spectrogram", h.GetCallSpectrogram)
 	r.Get("/calls/{id}/frequencies", h.GetCallFrequencies)
 	r.Get("/calls/{id}/transmissions", h.GetCallTransmissions)
 }
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+func TestParseCallExpansion(t *testing.T) {
+	x, msg := parseCallExpansion(httptest.NewRequest("GET", "/calls/1?expand=transcription,+unit_tags,,group", nil))
+	want := database.CallExpansion{Transcription: true, UnitTags: true, Group: true}
+	if msg != "" || x != want {
+		t.Errorf("got %+v %q, want %+v", x, msg, want)
+	}
+	if x, _ := parseCallExpansion(httptest.NewRequest("GET", "/calls/1", nil)); !x.Empty() {
+		t.Errorf("no expand: %+v", x)
+	}
+	if _, msg := parseCallExpansion(httptest.NewRequest("GET", "/calls/1?expand=transcription,audio", nil)); msg == "" {
+		t.Error("expected error for unknown value")
+	}
+}
+
+func TestListCallsRejectsGroupExpansion(t *testing.T) {
+	h := NewCallsHandler(nil, "", "", nil, nil)
+	rec := httptest.NewRecorder()
+	h.ListCalls(rec, httptest.NewRequest("GET", "/calls?expand=group", nil))
+	if rec.Code != 400 {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestStreamCallAudioValidation(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewCallsHandler(nil, "", "", nil, nil).StreamCallAudio(rec, httptest.NewRequest("GET", "/calls/stream?tgid=1", nil))
+	if rec.Code != 503 {
+		t.Errorf("without live data: status = %d, want 503", rec.Code)
+	}
+
+	h := NewCallsHandler(nil, "", "", nil, &mockLiveData{})
+	for _, target := range []string{"/calls/stream", "/calls/stream?system_id=1", "/calls/stream?tgid=" + strings.Repeat("1,", 51) + "1"} {
+		rec := httptest.NewRecorder()
+		h.StreamCallAudio(rec, httptest.NewRequest("GET", target, nil))
+		if rec.Code != 400 {
+			t.Errorf("%s: status = %d, want 400", target, rec.Code)
+		}
+	}
+}
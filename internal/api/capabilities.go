@@ -0,0 +1,141 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// CapabilityFeatures lists the optional server features that are enabled.
+// Fixed at startup, except transcription which follows the live provider.
+type CapabilityFeatures struct {
+	MQTT           bool   `json:"mqtt"`
+	Watch          bool   `json:"watch"` // WATCH_DIR file ingest
+	Upload         bool   `json:"upload"`
+	S3             bool   `json:"s3"`
+	Transcription  bool   `json:"transcription"`
+	AudioStream    bool   `json:"audio_stream"`
+	AudioWebRTC    bool   `json:"audio_webrtc"`
+	EventBridge    string `json:"event_bridge,omitempty"` // BRIDGE_DRIVER forwarder; empty = off
+	Ask            bool   `json:"ask"`
+	SemanticSearch bool   `json:"semantic_search"`
+	AudioArchive   bool   `json:"audio_archive"`
+	AudioReconcile bool   `json:"audio_reconcile"`
+	Replication    bool   `json:"replication"`
+	Forwarding     bool   `json:"forwarding"`
+	Icecast        bool   `json:"icecast"`
+	DuplicateAudit bool   `json:"duplicate_audit"`
+	Warehouse      bool   `json:"warehouse"`
+	CADPages       bool   `json:"cad_pages"`
+	CSVWriteback   bool   `json:"csv_writeback"`
+	Metrics        bool   `json:"metrics"`
+}
+
+// CapabilityPermissions is what the requesting token may do.
+type CapabilityPermissions struct {
+	Read           bool `json:"read"`
+	Write          bool `json:"write"`           // POST/PATCH/PUT/DELETE outside /subscriptions
+	Admin          bool `json:"admin"`           // writes under /admin, API key management
+	Upload         bool `json:"upload"`          // POST /call-upload
+	ViewRestricted bool `json:"view_restricted"` // calls hidden by a restricted encryption policy
+	Subscriptions  bool `json:"subscriptions"`   // saved subscription profiles
+	SavedQueries   bool `json:"saved_queries"`   // saved call/search queries
+}
+
+type CapabilitiesHandler struct {
+	authEnabled bool
+	writeToken  string
+	live        LiveDataSource
+	features    CapabilityFeatures
+}
+
+// NewCapabilitiesHandler reports features from opts. Transcription is read
+// from live on each request.
+func NewCapabilitiesHandler(opts ServerOptions) *CapabilitiesHandler {
+	cfg := opts.Config
+	return &CapabilitiesHandler{
+		authEnabled: cfg.AuthEnabled,
+		writeToken:  cfg.WriteToken,
+		live:        opts.Live,
+		features: CapabilityFeatures{
+			MQTT:           cfg.MQTTBrokerURL != "",
+			Watch:          cfg.WatchDir != "",
+			Upload:         opts.Uploader != nil,
+			S3:             cfg.StorageBackendName() == "s3",
+			AudioStream:    opts.AudioStreamer != nil,
+			AudioWebRTC:    opts.WebRTC != nil,
+			EventBridge:    cfg.BridgeDriver,
+			Ask:            opts.Asker != nil,
+			SemanticSearch: opts.Embedder != nil,
+			AudioArchive:   opts.AudioArchiver != nil,
+			AudioReconcile: opts.AudioReconciler != nil,
+			Replication:    opts.Replicator != nil,
+			Forwarding:     opts.Forwarder != nil,
+			Icecast:        opts.IcecastStatus != nil,
+			DuplicateAudit: opts.DuplicateAuditor != nil,
+			Warehouse:      opts.Warehouse != nil,
+			CADPages:       opts.CADIngester != nil,
+			CSVWriteback:   cfg.CSVWriteback || cfg.UnitCSVWriteback,
+			Metrics:        cfg.MetricsEnabled,
+		},
+	}
+}
+
+// GetCapabilities returns the requesting token's role and permissions and the
+// enabled server features, so clients can hide actions they'd get a 403 for.
+// API keys report their scope as the role, signed-in users theirs.
+// GET /api/v1/capabilities
+func (h *CapabilitiesHandler) GetCapabilities(w http.ResponseWriter, r *http.Request) {
+	admin := isAdmin(r)
+	key := apiKeyFrom(r)
+	user := userFrom(r)
+	scope, scoped := credentialScope(r)
+	write := admin || (scoped && scope == database.APIKeyScopeWrite)
+	read := !scoped || scope != database.APIKeyScopeStream
+	role := "read"
+	switch {
+	case !h.authEnabled:
+		role = "open"
+	case scoped:
+		role = scope
+	case write:
+		role = "write"
+	}
+	// Uploads check WRITE_TOKEN only when one is set (see UploadAuth).
+	upload := h.features.Upload && (h.writeToken == "" || apiKeyUploads(key) ||
+		subtle.ConstantTimeCompare([]byte(extractBearerToken(r)), []byte(h.writeToken)) == 1)
+
+	features := h.features
+	features.Transcription = h.live != nil && h.live.TranscriptionStatus() != nil
+
+	w.Header().Set("Cache-Control", "no-store")
+	resp := map[string]any{
+		"auth_enabled": h.authEnabled,
+		"role":         role,
+		"permissions": CapabilityPermissions{
+			Read:           read,
+			Write:          write,
+			Admin:          admin,
+			Upload:         upload,
+			ViewRestricted: admin,
+			Subscriptions:  read,
+			SavedQueries:   read,
+		},
+		"features": features,
+	}
+	if key != nil {
+		resp["api_key"] = map[string]any{"id": key.ID, "name": key.Name}
+	}
+	if user != nil {
+		resp["user"] = map[string]any{"id": user.ID, "username": user.Username, "display_name": user.DisplayName}
+	}
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+// Routes registers the capabilities route on the given router.
+func (h *CapabilitiesHandler) Routes(r chi.Router) {
+	r.Get("/capabilities", h.GetCapabilities)
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/snarg/tr-engine/internal/config"
+)
+
+func TestGetCapabilities(t *testing.T) {
+	cfg := &config.Config{AuthEnabled: true, AuthToken: "read", WriteToken: "write", WatchDir: "/tr/audio"}
+	h := NewCapabilitiesHandler(ServerOptions{Config: cfg, Live: &mockLiveData{}, Uploader: &mockCallUploader{}})
+	handler := AdminContext(cfg.AuthEnabled, cfg.WriteToken)(http.HandlerFunc(h.GetCapabilities))
+
+	type response struct {
+		Role        string                `json:"role"`
+		Permissions CapabilityPermissions `json:"permissions"`
+		Features    CapabilityFeatures    `json:"features"`
+	}
+	get := func(token string) response {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/v1/capabilities", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d", rec.Code)
+		}
+		var resp response
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	read := get("read")
+	if read.Role != "read" || read.Permissions.Write || read.Permissions.Upload || read.Permissions.ViewRestricted {
+		t.Errorf("read token = %+v", read)
+	}
+	if !read.Features.Watch || !read.Features.Upload || read.Features.MQTT || read.Features.Transcription {
+		t.Errorf("features = %+v", read.Features)
+	}
+
+	write := get("write")
+	if write.Role != "write" || !write.Permissions.Write || !write.Permissions.Upload {
+		t.Errorf("write token = %+v", write)
+	}
+}
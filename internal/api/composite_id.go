@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/snarg/tr-engine/internal/database"
@@ -74,3 +75,32 @@ func WriteAmbiguous(w http.ResponseWriter, entityID int, matches []database.Ambi
 		Matches: matches,
 	})
 }
+
+// ParseCallRef parses a call path parameter as either a plain "call_id" or a
+// composite "call_id:start_time" (or "call_id-start_time"), where start_time is
+// the call's start as unix seconds. The composite form lets the lookup go
+// straight to the call's partition; the plain form is resolved via call_index.
+func ParseCallRef(r *http.Request, param string) (database.CallRef, error) {
+	raw, _ := url.PathUnescape(chi.URLParam(r, param))
+	if raw == "" {
+		return database.CallRef{}, fmt.Errorf("missing path parameter: %s", param)
+	}
+
+	idPart, startPart, composite := raw, "", false
+	if idx := strings.IndexAny(raw, ":-"); idx > 0 {
+		idPart, startPart, composite = raw[:idx], raw[idx+1:], true
+	}
+	callID, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil || callID <= 0 {
+		return database.CallRef{}, fmt.Errorf("invalid call ID: %s", raw)
+	}
+	ref := database.CallRef{CallID: callID}
+	if composite {
+		start, err := strconv.ParseInt(startPart, 10, 64)
+		if err != nil || start <= 0 {
+			return database.CallRef{}, fmt.Errorf("invalid start_time in call ID: %s", raw)
+		}
+		ref.StartTime = time.Unix(start, 0)
+	}
+	return ref, nil
+}
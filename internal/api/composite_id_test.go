@@ -101,3 +101,56 @@ func TestParseCompositeID(t *testing.T) {
 		})
 	}
 }
+
+func TestParseCallRef(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		wantID    int64
+		wantStart int64 // unix seconds; 0 = unset
+		wantErr   bool
+	}{
+		{"plain", "48213377", 48213377, 0, false},
+		{"composite", "48213377:1718000000", 48213377, 1718000000, false},
+		{"composite_dash", "48213377-1718000000", 48213377, 1718000000, false},
+		{"missing_param", "", 0, 0, true},
+		{"non_numeric", "abc", 0, 0, true},
+		{"zero_id", "0", 0, 0, true},
+		{"invalid_start", "48213377:abc", 0, 0, true},
+		{"empty_start", "48213377:", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req *http.Request
+			if tt.value == "" {
+				req = httptest.NewRequest("GET", "/", nil)
+				rctx := chi.NewRouteContext()
+				req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			} else {
+				req = newRequestWithChiParam("id", tt.value)
+			}
+
+			ref, err := ParseCallRef(req, "id")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ref.CallID != tt.wantID {
+				t.Errorf("CallID = %d, want %d", ref.CallID, tt.wantID)
+			}
+			if tt.wantStart == 0 {
+				if !ref.StartTime.IsZero() {
+					t.Errorf("StartTime = %v, want zero", ref.StartTime)
+				}
+			} else if ref.StartTime.Unix() != tt.wantStart {
+				t.Errorf("StartTime = %d, want %d", ref.StartTime.Unix(), tt.wantStart)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// ConsoleHandler serves trunk-recorder's console log (console_messages):
+// a searchable message list and a view that clusters similar messages.
+type ConsoleHandler struct {
+	db *database.DB
+}
+
+func NewConsoleHandler(db *database.DB) *ConsoleHandler {
+	return &ConsoleHandler{db: db}
+}
+
+// consoleLevels are trunk-recorder's log severities, least severe first.
+var consoleLevels = []string{"trace", "debug", "info", "warning", "error", "fatal"}
+
+const (
+	defaultConsoleClusterLimit = 50
+	maxConsoleClusterLimit     = 500
+	maxConsoleBuckets          = 1000
+)
+
+// parseConsoleFilter reads the filters shared by the list and cluster views:
+// instance_id, severity (alias level; comma-separated), min_level, q and
+// start_time/end_time.
+func parseConsoleFilter(r *http.Request) (database.ConsoleMessageFilter, string) {
+	var f database.ConsoleMessageFilter
+	if v, ok := QueryString(r, "instance_id"); ok {
+		f.InstanceID = &v
+	}
+	f.Severities = QueryStringListAliased(r, "severity", "level")
+	if v, ok := QueryString(r, "min_level"); ok {
+		levels, err := consoleLevelsFrom(v)
+		if err != nil {
+			return f, err.Error()
+		}
+		if f.Severities != nil {
+			return f, "min_level can't be combined with severity"
+		}
+		f.Severities = levels
+	}
+	if v, ok := QueryString(r, "q"); ok {
+		f.Search = &v
+	}
+	if t, ok := QueryTime(r, "start_time"); ok {
+		f.StartTime = &t
+	}
+	if t, ok := QueryTime(r, "end_time"); ok {
+		f.EndTime = &t
+	}
+	return f, ""
+}
+
+// consoleLevelsFrom returns min and every more severe level.
+func consoleLevelsFrom(min string) ([]string, error) {
+	for i, l := range consoleLevels {
+		if strings.EqualFold(l, min) {
+			return consoleLevels[i:], nil
+		}
+	}
+	return nil, fmt.Errorf("min_level must be one of %s", strings.Join(consoleLevels, ", "))
+}
+
+// ListConsoleMessages returns console messages, newest first, matching the
+// filters in parseConsoleFilter. q is a case-insensitive substring match on
+// the message text.
+func (h *ConsoleHandler) ListConsoleMessages(w http.ResponseWriter, r *http.Request) {
+	p, err := ParsePagination(r)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+	filter, msg := parseConsoleFilter(r)
+	if msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, msg)
+		return
+	}
+	filter.Limit = p.Limit
+	filter.Offset = p.Offset
+	if msg := ValidateTimeRange(filter.StartTime, filter.EndTime); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+
+	messages, total, err := h.db.ListConsoleMessages(r.Context(), filter)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list console messages")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"messages": messages,
+		"total":    total,
+	})
+}
+
+// ListConsoleClusters groups console messages by template (numbers and hex
+// values masked) with per-bucket counts, most frequent first, so spikes of
+// one error stand out. Defaults: the last 24h, min_level=warning unless a
+// severity is given, bucket sized for ~48 buckets, limit 50.
+func (h *ConsoleHandler) ListConsoleClusters(w http.ResponseWriter, r *http.Request) {
+	filter, msg := parseConsoleFilter(r)
+	if msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, msg)
+		return
+	}
+	if filter.Severities == nil {
+		filter.Severities, _ = consoleLevelsFrom("warning")
+	}
+	end := time.Now()
+	if filter.EndTime != nil {
+		end = *filter.EndTime
+	}
+	start := end.Add(-24 * time.Hour)
+	if filter.StartTime != nil {
+		start = *filter.StartTime
+	}
+	if msg := ValidateTimeRange(&start, &end); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+	filter.StartTime, filter.EndTime = &start, &end
+
+	span := end.Sub(start)
+	bucket := (span/48 + time.Minute - 1).Truncate(time.Minute)
+	if v, ok := QueryString(r, "bucket"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Minute {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "bucket must be a duration of at least 1m")
+			return
+		}
+		bucket = d.Truncate(time.Minute)
+	}
+	if bucket < time.Minute {
+		bucket = time.Minute
+	}
+	if span/bucket > maxConsoleBuckets {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter,
+			fmt.Sprintf("bucket too small: at most %d buckets", maxConsoleBuckets))
+		return
+	}
+
+	filter.Limit = defaultConsoleClusterLimit
+	if v, ok := QueryInt(r, "limit"); ok {
+		if v < 1 || v > maxConsoleClusterLimit {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "limit must be between 1 and 500")
+			return
+		}
+		filter.Limit = v
+	}
+
+	clusters, total, err := h.db.ConsoleClusters(r.Context(), filter, bucket)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to cluster console messages")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"clusters":   clusters,
+		"total":      total,
+		"start_time": start,
+		"end_time":   end,
+		"bucket":     bucket.String(),
+	})
+}
+
+func (h *ConsoleHandler) Routes(r chi.Router) {
+	r.Get("/console", h.ListConsoleMessages)
+	r.Get("/console/clusters", h.ListConsoleClusters)
+	r.Get("/console-messages", h.ListConsoleMessages)
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseConsoleFilter(t *testing.T) {
+	r := httptest.NewRequest("GET", "/console?instance_id=tr1&level=error,fatal&q=retune&start_time=2026-03-01T00:00:00Z", nil)
+	f, msg := parseConsoleFilter(r)
+	if msg != "" {
+		t.Fatal(msg)
+	}
+	if f.InstanceID == nil || *f.InstanceID != "tr1" || f.Search == nil || *f.Search != "retune" || f.StartTime == nil {
+		t.Errorf("filter = %+v", f)
+	}
+	if strings.Join(f.Severities, ",") != "error,fatal" {
+		t.Errorf("severities = %v", f.Severities)
+	}
+
+	r = httptest.NewRequest("GET", "/console?min_level=Warning", nil)
+	f, msg = parseConsoleFilter(r)
+	if msg != "" || strings.Join(f.Severities, ",") != "warning,error,fatal" {
+		t.Errorf("min_level: %v, %q", f.Severities, msg)
+	}
+
+	for _, q := range []string{"min_level=loud", "min_level=info&severity=error"} {
+		if _, msg := parseConsoleFilter(httptest.NewRequest("GET", "/console?"+q, nil)); msg == "" {
+			t.Errorf("%s: expected error", q)
+		}
+	}
+}
+
+func TestListConsoleClustersValidation(t *testing.T) {
+	h := NewConsoleHandler(nil)
+	for _, q := range []string{
+		"bucket=30s",
+		"bucket=soon",
+		"start_time=2026-03-01T00:00:00Z&end_time=2026-03-08T00:00:00Z&bucket=1m",
+		"limit=0",
+		"limit=501",
+		"min_level=loud",
+		"start_time=2026-03-02T00:00:00Z&end_time=2026-03-01T00:00:00Z",
+	} {
+		w := httptest.NewRecorder()
+		h.ListConsoleClusters(w, httptest.NewRequest("GET", "/console/clusters?"+q, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", q, w.Code)
+		}
+	}
+}
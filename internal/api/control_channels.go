@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// ControlChannelsHandler serves each site's control channel and its history
+// of changes (failovers). Live changes go out as "control_channel" events.
+type ControlChannelsHandler struct {
+	db *database.DB
+}
+
+func NewControlChannelsHandler(db *database.DB) *ControlChannelsHandler {
+	return &ControlChannelsHandler{db: db}
+}
+
+const (
+	defaultControlChannelLimit = 500
+	maxControlChannelLimit     = 5000
+)
+
+// ListControlChannels returns each site's current control channel and how
+// long it has been on it. Filter: system_id.
+func (h *ControlChannelsHandler) ListControlChannels(w http.ResponseWriter, r *http.Request) {
+	list, err := h.db.ListCurrentControlChannels(r.Context(), QueryIntListAliased(r, "system_id", "systems"))
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list control channels")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"control_channels": list,
+		"total":            len(list),
+	})
+}
+
+// ListControlChannelHistory returns control channel changes, newest first,
+// each with when the site moved off the channel. Filters: system_id,
+// site_id, start_time, end_time, limit.
+func (h *ControlChannelsHandler) ListControlChannelHistory(w http.ResponseWriter, r *http.Request) {
+	f := database.ControlChannelFilter{
+		SystemIDs: QueryIntListAliased(r, "system_id", "systems"),
+		SiteIDs:   QueryIntListAliased(r, "site_id", "sites"),
+		Limit:     defaultControlChannelLimit,
+	}
+	if t, ok := QueryTime(r, "start_time"); ok {
+		f.Start = &t
+	}
+	if t, ok := QueryTime(r, "end_time"); ok {
+		f.End = &t
+	}
+	if msg := ValidateTimeRange(f.Start, f.End); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+	if v, ok := QueryInt(r, "limit"); ok {
+		if v < 1 || v > maxControlChannelLimit {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "limit must be between 1 and 5000")
+			return
+		}
+		f.Limit = v
+	}
+
+	list, err := h.db.ListControlChannelHistory(r.Context(), f)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list control channel history")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"changes": list,
+		"total":   len(list),
+	})
+}
+
+func (h *ControlChannelsHandler) Routes(r chi.Router) {
+	r.Get("/control-channels", h.ListControlChannels)
+	r.Get("/control-channels/history", h.ListControlChannelHistory)
+}
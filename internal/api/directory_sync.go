@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/rs/zerolog/hlog"
+	"github.com/snarg/tr-engine/internal/export"
+)
+
+// GetDirectorySnapshot exports this instance's curated directory (named
+// talkgroups, talkgroup directory, named units) with per-entry and per-system
+// hashes. ?system_id limits it to some systems.
+func (h *AdminHandler) GetDirectorySnapshot(w http.ResponseWriter, r *http.Request) {
+	snap, err := export.BuildDirectorySnapshot(r.Context(), h.db, QueryIntList(r, "system_id"))
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to build directory snapshot")
+		return
+	}
+	WriteJSON(w, http.StatusOK, snap)
+}
+
+// DiffDirectorySnapshot compares a snapshot from another instance (the request
+// body) against this instance's directory without changing anything.
+func (h *AdminHandler) DiffDirectorySnapshot(w http.ResponseWriter, r *http.Request) {
+	remote, ok := decodeDirectorySnapshot(w, r)
+	if !ok {
+		return
+	}
+	local, err := export.BuildDirectorySnapshot(r.Context(), h.db, nil)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to build directory snapshot")
+		return
+	}
+	WriteJSON(w, http.StatusOK, export.DiffDirectory(local, remote))
+}
+
+// ApplyDirectorySnapshot upserts added and changed entries from a snapshot
+// (the request body) into matching local systems. ?base_hash rejects the
+// apply with 409 if the local directory no longer has that hash (i.e. it
+// changed since the diff was reviewed). ?dry_run=true reports without writing.
+func (h *AdminHandler) ApplyDirectorySnapshot(w http.ResponseWriter, r *http.Request) {
+	dryRun, _ := QueryBool(r, "dry_run")
+	remote, ok := decodeDirectorySnapshot(w, r)
+	if !ok {
+		return
+	}
+	local, err := export.BuildDirectorySnapshot(r.Context(), h.db, nil)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to build directory snapshot")
+		return
+	}
+	if base, ok := QueryString(r, "base_hash"); ok && base != local.Hash {
+		WriteErrorDetail(w, http.StatusConflict,
+			"local directory changed since base_hash; diff again", "local_hash: "+local.Hash)
+		return
+	}
+
+	result, err := export.ApplyDirectory(r.Context(), h.db, local, remote, dryRun, *hlog.FromRequest(r))
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "apply failed: "+err.Error())
+		return
+	}
+	if !dryRun && len(result.Diff.Systems) > 0 {
+		h.cache.Purge()
+	}
+	WriteJSON(w, http.StatusOK, result)
+}
+
+func decodeDirectorySnapshot(w http.ResponseWriter, r *http.Request) (*export.DirectorySnapshot, bool) {
+	var snap export.DirectorySnapshot
+	if err := DecodeJSON(r, &snap); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return nil, false
+	}
+	if err := snap.Validate(); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, err.Error())
+		return nil, false
+	}
+	return &snap, true
+}
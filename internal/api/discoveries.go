@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// DiscoveriesHandler lists talkgroups and units heard for the first time,
+// e.g. to spot new channels after a system reconfiguration. Live
+// announcements go out as "discovery" events on the event stream.
+type DiscoveriesHandler struct {
+	db *database.DB
+}
+
+func NewDiscoveriesHandler(db *database.DB) *DiscoveriesHandler {
+	return &DiscoveriesHandler{db: db}
+}
+
+const (
+	defaultDiscoveryLimit = 500
+	maxDiscoveryLimit     = 5000
+)
+
+// ListDiscoveries returns entities whose first_seen is between start_time and
+// end_time (default the last 7 days), newest first, with the first call each
+// was heard on. Filters: system_id, type (talkgroup|unit), limit.
+func (h *DiscoveriesHandler) ListDiscoveries(w http.ResponseWriter, r *http.Request) {
+	f := database.DiscoveryFilter{
+		End:       time.Now(),
+		SystemIDs: QueryIntListAliased(r, "system_id", "systems"),
+		Limit:     defaultDiscoveryLimit,
+	}
+	f.Start = f.End.Add(-7 * 24 * time.Hour)
+	if t, ok := QueryTime(r, "start_time"); ok {
+		f.Start = t
+	}
+	if t, ok := QueryTime(r, "end_time"); ok {
+		f.End = t
+	}
+	if msg := ValidateTimeRange(&f.Start, &f.End); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+	if v, ok := QueryString(r, "type"); ok {
+		if v != database.DiscoveryTalkgroup && v != database.DiscoveryUnit {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "type must be talkgroup or unit")
+			return
+		}
+		f.Kind = v
+	}
+	if v, ok := QueryInt(r, "limit"); ok {
+		if v < 1 || v > maxDiscoveryLimit {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "limit must be between 1 and 5000")
+			return
+		}
+		f.Limit = v
+	}
+
+	list, err := h.db.ListDiscoveries(r.Context(), f)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list discoveries")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"discoveries": list,
+		"total":       len(list),
+		"start_time":  f.Start,
+		"end_time":    f.End,
+	})
+}
+
+func (h *DiscoveriesHandler) Routes(r chi.Router) {
+	r.Get("/discoveries", h.ListDiscoveries)
+}
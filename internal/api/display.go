@@ -0,0 +1,60 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// Display metadata limits.
+const (
+	maxDisplayIconLen    = 64
+	maxDisplayShortLabel = 16
+	maxDisplayOrder      = 1000000
+)
+
+var displayColorRe = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// displayInput is the display metadata a system, site or talkgroup group
+// PATCH body may set. "" clears a text field.
+type displayInput struct {
+	Color        *string `json:"color"`
+	Icon         *string `json:"icon"`
+	ShortLabel   *string `json:"short_label"`
+	DisplayOrder *int    `json:"display_order"`
+}
+
+// validate checks the input and returns it as a database patch, or why it
+// is invalid.
+func (in displayInput) validate() (database.DisplayPatch, string) {
+	p := database.DisplayPatch{DisplayOrder: in.DisplayOrder}
+	if in.Color != nil {
+		c := strings.ToLower(strings.TrimSpace(*in.Color))
+		if c != "" && !displayColorRe.MatchString(c) {
+			return p, `color must be "#rrggbb"`
+		}
+		p.Color = &c
+	}
+	if in.Icon != nil {
+		icon := strings.TrimSpace(*in.Icon)
+		if len(icon) > maxDisplayIconLen || strings.ContainsFunc(icon, unicode.IsControl) {
+			return p, fmt.Sprintf("icon must be at most %d bytes without control characters", maxDisplayIconLen)
+		}
+		p.Icon = &icon
+	}
+	if in.ShortLabel != nil {
+		label := strings.TrimSpace(*in.ShortLabel)
+		if utf8.RuneCountInString(label) > maxDisplayShortLabel || strings.ContainsFunc(label, unicode.IsControl) {
+			return p, fmt.Sprintf("short_label must be at most %d characters", maxDisplayShortLabel)
+		}
+		p.ShortLabel = &label
+	}
+	if in.DisplayOrder != nil && (*in.DisplayOrder < -maxDisplayOrder || *in.DisplayOrder > maxDisplayOrder) {
+		return p, fmt.Sprintf("display_order must be between %d and %d", -maxDisplayOrder, maxDisplayOrder)
+	}
+	return p, ""
+}
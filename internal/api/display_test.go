@@ -0,0 +1,41 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDisplayInputValidate(t *testing.T) {
+	s := func(v string) *string { return &v }
+	n := func(v int) *int { return &v }
+
+	p, msg := displayInput{Color: s(" #FF8800 "), Icon: s("fire-truck"), ShortLabel: s(" Fire "), DisplayOrder: n(-5)}.validate()
+	if msg != "" {
+		t.Fatalf("valid input rejected: %s", msg)
+	}
+	if *p.Color != "#ff8800" || *p.Icon != "fire-truck" || *p.ShortLabel != "Fire" || *p.DisplayOrder != -5 {
+		t.Errorf("patch = %q %q %q %d", *p.Color, *p.Icon, *p.ShortLabel, *p.DisplayOrder)
+	}
+	if p, msg := (displayInput{Color: s("")}).validate(); msg != "" || *p.Color != "" || p.Icon != nil || p.IsZero() {
+		t.Errorf("clearing color: %+v, %q", p, msg)
+	}
+	if p, _ := (displayInput{}).validate(); !p.IsZero() {
+		t.Errorf("empty input gave %+v", p)
+	}
+
+	for name, tc := range map[string]struct {
+		in   displayInput
+		want string
+	}{
+		"named color":      {displayInput{Color: s("red")}, "color"},
+		"short hex":        {displayInput{Color: s("#f80")}, "color"},
+		"long icon":        {displayInput{Icon: s(strings.Repeat("a", 65))}, "icon"},
+		"control in label": {displayInput{ShortLabel: s("a\nb")}, "short_label"},
+		"long label":       {displayInput{ShortLabel: s("Fire Tactical Ops")}, "short_label"},
+		"order":            {displayInput{DisplayOrder: n(2000000)}, "display_order"},
+	} {
+		if _, msg := tc.in.validate(); !strings.Contains(msg, tc.want) {
+			t.Errorf("%s: message %q, want %q", name, msg, tc.want)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/dupaudit"
+)
+
+// DuplicateAuditHandler reports likely duplicate calls found by the nightly
+// audit and groups, dismisses or ungroups them.
+type DuplicateAuditHandler struct {
+	db      *database.DB
+	auditor *dupaudit.Auditor // nil when DUPLICATE_AUDIT is off
+}
+
+func NewDuplicateAuditHandler(db *database.DB, auditor *dupaudit.Auditor) *DuplicateAuditHandler {
+	return &DuplicateAuditHandler{db: db, auditor: auditor}
+}
+
+func (h *DuplicateAuditHandler) available(w http.ResponseWriter) bool {
+	if h.auditor == nil {
+		WriteError(w, http.StatusServiceUnavailable, "duplicate audit not enabled (set DUPLICATE_AUDIT=true)")
+		return false
+	}
+	return true
+}
+
+// GetDuplicateAuditStatus reports the audit's settings, finding counts and
+// the last run.
+func (h *DuplicateAuditHandler) GetDuplicateAuditStatus(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	status, err := h.auditor.Status(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get duplicate audit status")
+		return
+	}
+	WriteJSON(w, http.StatusOK, status)
+}
+
+// RunDuplicateAudit starts an audit now. Body (optional):
+// {"start_time": "...", "end_time": "..."} to audit an older range; the
+// default is the nightly DUPLICATE_AUDIT_LOOKBACK.
+func (h *DuplicateAuditHandler) RunDuplicateAudit(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	var req struct {
+		StartTime *time.Time `json:"start_time"`
+		EndTime   *time.Time `json:"end_time"`
+	}
+	if r.ContentLength != 0 {
+		if err := DecodeJSON(r, &req); err != nil {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+			return
+		}
+	}
+	if msg := ValidateTimeRange(req.StartTime, req.EndTime); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+	var from, to time.Time
+	if req.StartTime != nil {
+		from = *req.StartTime
+	}
+	if req.EndTime != nil {
+		to = *req.EndTime
+	}
+	if err := h.auditor.Run(from, to); err != nil {
+		WriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+	status, err := h.auditor.Status(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get duplicate audit status")
+		return
+	}
+	WriteJSON(w, http.StatusAccepted, status)
+}
+
+// ListDuplicateFindings returns findings, newest call first. Filters:
+// status (open, grouped, dismissed; comma-separated), system_id, tgid,
+// min_confidence, start_time/end_time (the earlier call's start).
+func (h *DuplicateAuditHandler) ListDuplicateFindings(w http.ResponseWriter, r *http.Request) {
+	p, err := ParsePagination(r)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+	f := database.DuplicateFindingFilter{
+		SystemIDs: QueryIntListAliased(r, "system_id", "systems"),
+		Tgids:     QueryIntListAliased(r, "tgid", "tgids"),
+		Limit:     p.Limit,
+		Offset:    p.Offset,
+	}
+	for _, s := range QueryStringList(r, "status") {
+		switch s {
+		case database.DuplicateOpen, database.DuplicateGrouped, database.DuplicateDismissed:
+			f.Statuses = append(f.Statuses, s)
+		default:
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter,
+				"status must be open, grouped, or dismissed")
+			return
+		}
+	}
+	if v := r.URL.Query().Get("min_confidence"); v != "" {
+		c, err := strconv.ParseFloat(v, 64)
+		if err != nil || c < 0 || c > 1 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "min_confidence must be between 0 and 1")
+			return
+		}
+		f.MinConfidence = c
+	}
+	if t, ok := QueryTime(r, "start_time"); ok {
+		f.Start = &t
+	}
+	if t, ok := QueryTime(r, "end_time"); ok {
+		f.End = &t
+	}
+	if msg := ValidateTimeRange(f.Start, f.End); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+
+	findings, total, err := h.db.ListDuplicateFindings(r.Context(), f)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list duplicate findings")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"findings": findings,
+		"total":    total,
+		"limit":    p.Limit,
+		"offset":   p.Offset,
+	})
+}
+
+// findingAction applies a grouping, dismissal or ungrouping to finding {id}.
+func (h *DuplicateAuditHandler) findingAction(w http.ResponseWriter, r *http.Request,
+	apply func(*database.DB, *http.Request, int64) (*database.DuplicateFinding, error), what string) {
+	id, err := PathInt64(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid finding ID")
+		return
+	}
+	f, err := apply(h.db, r, id)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "duplicate finding not found") || err.Error() == "call not found" {
+			WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to "+what+" duplicate")
+		return
+	}
+	WriteJSON(w, http.StatusOK, f)
+}
+
+// GroupDuplicate moves an open finding's later call into the earlier
+// call's group, so it's treated as a copy of it.
+func (h *DuplicateAuditHandler) GroupDuplicate(w http.ResponseWriter, r *http.Request) {
+	h.findingAction(w, r, func(db *database.DB, r *http.Request, id int64) (*database.DuplicateFinding, error) {
+		return db.GroupDuplicate(r.Context(), id, "api")
+	}, "group")
+}
+
+// DismissDuplicate marks an open finding as not a duplicate.
+func (h *DuplicateAuditHandler) DismissDuplicate(w http.ResponseWriter, r *http.Request) {
+	h.findingAction(w, r, func(db *database.DB, r *http.Request, id int64) (*database.DuplicateFinding, error) {
+		return db.DismissDuplicate(r.Context(), id, "api")
+	}, "dismiss")
+}
+
+// UngroupDuplicate reverses a grouping (automatic or manual): the later call
+// returns to its previous group and the finding is dismissed.
+func (h *DuplicateAuditHandler) UngroupDuplicate(w http.ResponseWriter, r *http.Request) {
+	h.findingAction(w, r, func(db *database.DB, r *http.Request, id int64) (*database.DuplicateFinding, error) {
+		return db.UngroupDuplicate(r.Context(), id, "api")
+	}, "ungroup")
+}
+
+// ListDuplicateActions returns the audit trail of groupings, dismissals
+// and ungroupings, newest first. Filter: finding_id.
+func (h *DuplicateAuditHandler) ListDuplicateActions(w http.ResponseWriter, r *http.Request) {
+	p, err := ParsePagination(r)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+	var findingID int64
+	if v := r.URL.Query().Get("finding_id"); v != "" {
+		findingID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || findingID < 1 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "invalid finding_id")
+			return
+		}
+	}
+	actions, total, err := h.db.ListDuplicateActions(r.Context(), findingID, p.Limit, p.Offset)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list duplicate actions")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"actions": actions,
+		"total":   total,
+		"limit":   p.Limit,
+		"offset":  p.Offset,
+	})
+}
+
+func (h *DuplicateAuditHandler) Routes(r chi.Router) {
+	r.Get("/admin/duplicate-audit", h.GetDuplicateAuditStatus)
+	r.Post("/admin/duplicate-audit/run", h.RunDuplicateAudit)
+	r.Get("/admin/duplicate-audit/findings", h.ListDuplicateFindings)
+	r.Post("/admin/duplicate-audit/findings/{id}/group", h.GroupDuplicate)
+	r.Post("/admin/duplicate-audit/findings/{id}/dismiss", h.DismissDuplicate)
+	r.Post("/admin/duplicate-audit/findings/{id}/ungroup", h.UngroupDuplicate)
+	r.Get("/admin/duplicate-audit/actions", h.ListDuplicateActions)
+}
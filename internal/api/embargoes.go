@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// EmbargoesHandler manages talkgroup embargoes: a listener delay before
+// calls, audio, transcripts and live events reach non-admin tokens and
+// public feeds. Admins (WRITE_TOKEN) are unaffected.
+type EmbargoesHandler struct {
+	db       *database.DB
+	onChange func(ctx context.Context) error // reloads the ingest embargo cache; may be nil
+}
+
+func NewEmbargoesHandler(db *database.DB, onChange func(context.Context) error) *EmbargoesHandler {
+	return &EmbargoesHandler{db: db, onChange: onChange}
+}
+
+// changed tells ingest about an embargo change. The change is already
+// committed, so a failed reload is only logged; it is picked up on restart.
+func (h *EmbargoesHandler) changed(r *http.Request) {
+	if h.onChange == nil {
+		return
+	}
+	if err := h.onChange(r.Context()); err != nil {
+		hlog.FromRequest(r).Warn().Err(err).Msg("failed to reload talkgroup embargoes")
+	}
+}
+
+// ListEmbargoes returns every embargoed talkgroup.
+func (h *EmbargoesHandler) ListEmbargoes(w http.ResponseWriter, r *http.Request) {
+	embargoes, err := h.db.ListEmbargoes(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list embargoes")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"embargoes": embargoes,
+		"total":     len(embargoes),
+	})
+}
+
+// GetEmbargo returns a talkgroup's embargo.
+func (h *EmbargoesHandler) GetEmbargo(w http.ResponseWriter, r *http.Request) {
+	systemID, tgid, ok := talkgroupTarget(h.db, w, r)
+	if !ok {
+		return
+	}
+	e, err := h.db.GetEmbargo(r.Context(), systemID, tgid)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get embargo")
+		return
+	}
+	if e == nil {
+		WriteError(w, http.StatusNotFound, "embargo not found")
+		return
+	}
+	WriteJSON(w, http.StatusOK, e)
+}
+
+// PutEmbargo sets a talkgroup's embargo.
+// Body: {"delay_minutes": 30, "note": "..."}. Applies at once to calls
+// already recorded and to live events published from now on.
+func (h *EmbargoesHandler) PutEmbargo(w http.ResponseWriter, r *http.Request) {
+	systemID, tgid, ok := talkgroupTarget(h.db, w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		DelayMinutes int    `json:"delay_minutes"`
+		Note         string `json:"note"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	if req.DelayMinutes < 1 || req.DelayMinutes > database.MaxEmbargoMinutes {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter,
+			fmt.Sprintf("delay_minutes must be between 1 and %d", database.MaxEmbargoMinutes))
+		return
+	}
+
+	e, err := h.db.UpsertEmbargo(r.Context(), systemID, tgid, req.DelayMinutes, req.Note)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to save embargo")
+		return
+	}
+	h.changed(r)
+	WriteJSON(w, http.StatusOK, e)
+}
+
+// DeleteEmbargo lifts a talkgroup's embargo. Live events already held back
+// are still delivered on their original schedule.
+func (h *EmbargoesHandler) DeleteEmbargo(w http.ResponseWriter, r *http.Request) {
+	systemID, tgid, ok := talkgroupTarget(h.db, w, r)
+	if !ok {
+		return
+	}
+	found, err := h.db.DeleteEmbargo(r.Context(), systemID, tgid)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to delete embargo")
+		return
+	}
+	if !found {
+		WriteError(w, http.StatusNotFound, "embargo not found")
+		return
+	}
+	h.changed(r)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *EmbargoesHandler) Routes(r chi.Router) {
+	r.Get("/talkgroups/embargoes", h.ListEmbargoes)
+	r.Get("/talkgroups/{id}/embargo", h.GetEmbargo)
+	r.Put("/talkgroups/{id}/embargo", h.PutEmbargo)
+	r.Delete("/talkgroups/{id}/embargo", h.DeleteEmbargo)
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/embed"
+)
+
+// embeddingService is the part of embed.Embedder the handler uses.
+type embeddingService interface {
+	Model() string
+	Status(ctx context.Context) (embed.Status, error)
+	Backfill(start, end *time.Time) error
+	Search(ctx context.Context, query string, filter database.SemanticSearchFilter) ([]database.SemanticSearchHit, error)
+}
+
+type EmbeddingsHandler struct {
+	embedder embeddingService // nil when EMBED_URL is not configured
+}
+
+func NewEmbeddingsHandler(embedder *embed.Embedder) *EmbeddingsHandler {
+	h := &EmbeddingsHandler{}
+	if embedder != nil {
+		h.embedder = embedder
+	}
+	return h
+}
+
+func (h *EmbeddingsHandler) available(w http.ResponseWriter) bool {
+	if h.embedder == nil {
+		WriteError(w, http.StatusServiceUnavailable, "semantic search not configured (set EMBED_URL)")
+		return false
+	}
+	return true
+}
+
+// SemanticSearch ranks transcripts by a blend of embedding similarity to q and
+// full-text rank, so paraphrases ("house fire" for "structure fire") are found.
+// ?vector_weight=0..1 sets the blend (default 0.7; 1 = vectors only).
+func (h *EmbeddingsHandler) SemanticSearch(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		WriteError(w, http.StatusBadRequest, "q parameter is required")
+		return
+	}
+
+	filter := database.SemanticSearchFilter{
+		SystemIDs:    QueryIntListAliased(r, "system_id", "systems"),
+		Tgids:        QueryIntListAliased(r, "tgid", "tgids"),
+		Limit:        20,
+		VectorWeight: 0.7,
+
+		IncludeRestricted: isAdmin(r),
+	}
+	if v, ok := QueryInt(r, "limit"); ok {
+		if v < 1 || v > 100 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "limit must be between 1 and 100")
+			return
+		}
+		filter.Limit = v
+	}
+	if s, ok := QueryString(r, "vector_weight"); ok {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v < 0 || v > 1 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "vector_weight must be between 0 and 1")
+			return
+		}
+		filter.VectorWeight = v
+	}
+	if t, ok := QueryTime(r, "start_time"); ok {
+		filter.StartTime = &t
+	}
+	if t, ok := QueryTime(r, "end_time"); ok {
+		filter.EndTime = &t
+	}
+	if msg := ValidateTimeRange(filter.StartTime, filter.EndTime); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+
+	hits, err := h.embedder.Search(r.Context(), q, filter)
+	if err != nil {
+		hlog.FromRequest(r).Warn().Err(err).Msg("semantic search failed")
+		WriteError(w, http.StatusInternalServerError, "search failed")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"results":       hits,
+		"model":         h.embedder.Model(),
+		"vector_weight": filter.VectorWeight,
+	})
+}
+
+// GetEmbeddingStatus reports embedding coverage and the last backfill.
+func (h *EmbeddingsHandler) GetEmbeddingStatus(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	status, err := h.embedder.Status(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get embedding status")
+		return
+	}
+	WriteJSON(w, http.StatusOK, status)
+}
+
+// StartEmbeddingBackfill embeds older transcripts in the background. Body:
+// optional {"start_time", "end_time"}; omitted bounds are unbounded.
+func (h *EmbeddingsHandler) StartEmbeddingBackfill(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	var req struct {
+		StartTime *time.Time `json:"start_time"`
+		EndTime   *time.Time `json:"end_time"`
+	}
+	if r.ContentLength != 0 {
+		if err := DecodeJSON(r, &req); err != nil {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+			return
+		}
+	}
+	if msg := ValidateTimeRange(req.StartTime, req.EndTime); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+	if err := h.embedder.Backfill(req.StartTime, req.EndTime); err != nil {
+		WriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+	status, err := h.embedder.Status(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get embedding status")
+		return
+	}
+	WriteJSON(w, http.StatusAccepted, status)
+}
+
+func (h *EmbeddingsHandler) Routes(r chi.Router) {
+	r.Get("/search/semantic", h.SemanticSearch)
+	r.Get("/admin/embeddings", h.GetEmbeddingStatus)
+	r.Post("/admin/embeddings/backfill", h.StartEmbeddingBackfill)
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/embed"
+)
+
+// mockEmbeddings implements embeddingService for testing. Call 7 is
+// embargoed: it only matches when restricted calls are included.
+type mockEmbeddings struct {
+	filter database.SemanticSearchFilter
+}
+
+func (m *mockEmbeddings) Model() string { return "test-embed" }
+
+func (m *mockEmbeddings) Status(context.Context) (embed.Status, error) { return embed.Status{}, nil }
+
+func (m *mockEmbeddings) Backfill(start, end *time.Time) error { return nil }
+
+func (m *mockEmbeddings) Search(_ context.Context, query string, filter database.SemanticSearchFilter) ([]database.SemanticSearchHit, error) {
+	m.filter = filter
+	hits := []database.SemanticSearchHit{{TranscriptionSearchHit: database.TranscriptionSearchHit{TranscriptionAPI: database.TranscriptionAPI{CallID: 1, Text: "structure fire"}}}}
+	if filter.IncludeRestricted {
+		hits = append(hits, database.SemanticSearchHit{TranscriptionSearchHit: database.TranscriptionSearchHit{TranscriptionAPI: database.TranscriptionAPI{CallID: 7, Text: "house fire"}}})
+	}
+	return hits, nil
+}
+
+func TestSemanticSearchRestricted(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		admin     bool
+		embargoed bool
+	}{
+		{"non_admin", false, false},
+		{"admin", true, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := &mockEmbeddings{}
+			h := &EmbeddingsHandler{embedder: mock}
+			// With auth disabled everyone is an admin; with it on and no
+			// write token presented, nobody is.
+			handler := AdminContext(!tc.admin, "writer")(http.HandlerFunc(h.SemanticSearch))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/search/semantic?q=fire", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+			}
+			if mock.filter.IncludeRestricted != tc.admin {
+				t.Errorf("IncludeRestricted = %v, want %v", mock.filter.IncludeRestricted, tc.admin)
+			}
+			if got := strings.Contains(rec.Body.String(), "house fire"); got != tc.embargoed {
+				t.Errorf("embargoed hit returned = %v, want %v: %s", got, tc.embargoed, rec.Body.String())
+			}
+		})
+	}
+}
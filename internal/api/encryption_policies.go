@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// EncryptionPoliciesHandler manages what is kept for encrypted calls per
+// system or talkgroup: metadata (default), suppress or restricted.
+type EncryptionPoliciesHandler struct {
+	db       *database.DB
+	onChange func(ctx context.Context) error // reloads the ingest policy cache; may be nil
+}
+
+func NewEncryptionPoliciesHandler(db *database.DB, onChange func(context.Context) error) *EncryptionPoliciesHandler {
+	return &EncryptionPoliciesHandler{db: db, onChange: onChange}
+}
+
+// changed tells ingest about a policy change. The change is already
+// committed, so a failed reload is only logged; it is picked up on restart.
+func (h *EncryptionPoliciesHandler) changed(r *http.Request) {
+	if h.onChange == nil {
+		return
+	}
+	if err := h.onChange(r.Context()); err != nil {
+		hlog.FromRequest(r).Warn().Err(err).Msg("failed to reload encryption policies")
+	}
+}
+
+// ListEncryptionPolicies returns all system and talkgroup policies.
+func (h *EncryptionPoliciesHandler) ListEncryptionPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.db.ListEncryptionPolicies(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list encryption policies")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"default":  database.EncryptionPolicyMetadata,
+		"policies": policies,
+		"total":    len(policies),
+	})
+}
+
+// policyTarget parses the {system_id} and {tgid} path parameters. tgid 0
+// addresses the system-wide policy.
+func policyTarget(w http.ResponseWriter, r *http.Request) (systemID, tgid int, ok bool) {
+	systemID, err := PathInt(r, "system_id")
+	if err != nil || systemID <= 0 {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "invalid system_id")
+		return 0, 0, false
+	}
+	tgid, err = PathInt(r, "tgid")
+	if err != nil || tgid < 0 {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "invalid tgid")
+		return 0, 0, false
+	}
+	return systemID, tgid, true
+}
+
+// PutEncryptionPolicy sets the policy for a system (tgid 0) or talkgroup.
+// Body: {"policy": "metadata|suppress|restricted", "note": "...", "purge": bool}.
+// purge (suppress only) also deletes what was already recorded.
+func (h *EncryptionPoliciesHandler) PutEncryptionPolicy(w http.ResponseWriter, r *http.Request) {
+	systemID, tgid, ok := policyTarget(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Policy string `json:"policy"`
+		Note   string `json:"note"`
+		Purge  bool   `json:"purge"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	if !database.ValidEncryptionPolicy(req.Policy) {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter,
+			"policy must be metadata, suppress, or restricted")
+		return
+	}
+	if req.Purge && req.Policy != database.EncryptionPolicySuppress {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "purge requires policy suppress")
+		return
+	}
+
+	p, err := h.db.UpsertEncryptionPolicy(r.Context(), systemID, tgid, req.Policy, req.Note)
+	if err != nil {
+		if err.Error() == "system not found" {
+			WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to save encryption policy")
+		return
+	}
+	h.changed(r)
+
+	if !req.Purge {
+		WriteJSON(w, http.StatusOK, p)
+		return
+	}
+	purged, err := h.db.PurgeSuppressedEncrypted(r.Context(), systemID)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Int("system_id", systemID).Msg("failed to purge encrypted calls")
+		WriteError(w, http.StatusInternalServerError, "policy saved but purge failed; retry the request")
+		return
+	}
+	hlog.FromRequest(r).Info().
+		Int("system_id", systemID).
+		Int("tgid", tgid).
+		Int64("calls", purged.Calls).
+		Int64("unit_events", purged.UnitEvents).
+		Msg("purged suppressed encrypted traffic")
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"system_id":  p.SystemID,
+		"tgid":       p.Tgid,
+		"policy":     p.Policy,
+		"note":       p.Note,
+		"updated_at": p.UpdatedAt,
+		"purged":     purged,
+	})
+}
+
+// DeleteEncryptionPolicy removes a policy; a talkgroup falls back to its
+// system's policy, a system to metadata.
+func (h *EncryptionPoliciesHandler) DeleteEncryptionPolicy(w http.ResponseWriter, r *http.Request) {
+	systemID, tgid, ok := policyTarget(w, r)
+	if !ok {
+		return
+	}
+	found, err := h.db.DeleteEncryptionPolicy(r.Context(), systemID, tgid)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to delete encryption policy")
+		return
+	}
+	if !found {
+		WriteError(w, http.StatusNotFound, "encryption policy not found")
+		return
+	}
+	h.changed(r)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *EncryptionPoliciesHandler) Routes(r chi.Router) {
+	r.Get("/admin/encryption-policies", h.ListEncryptionPolicies)
+	r.Put("/admin/encryption-policies/{system_id}/{tgid}", h.PutEncryptionPolicy)
+	r.Delete("/admin/encryption-policies/{system_id}/{tgid}", h.DeleteEncryptionPolicy)
+}
@@ -0,0 +1,243 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/expr"
+)
+
+// redactedHeader replaces header values in responses. Sending it back in an
+// update keeps the stored value.
+const redactedHeader = "********"
+
+// Enrichment hook limits. Hooks run synchronously before call_end is
+// published, so their timeout bounds how late the event can be.
+const (
+	maxEnrichmentTimeoutMs = 10000
+	maxEnrichmentCooldown  = 86400
+)
+
+// EnrichmentHooksHandler manages enrichment hooks: URLs called on each
+// call_end whose returned fields are merged into the call's metadata_json.
+type EnrichmentHooksHandler struct {
+	db       *database.DB
+	onChange func(ctx context.Context) error // reloads the ingest hook cache; may be nil
+}
+
+func NewEnrichmentHooksHandler(db *database.DB, onChange func(context.Context) error) *EnrichmentHooksHandler {
+	return &EnrichmentHooksHandler{db: db, onChange: onChange}
+}
+
+// changed tells ingest about a hook change. The change is already committed,
+// so a failed reload is only logged; it is picked up on restart.
+func (h *EnrichmentHooksHandler) changed(r *http.Request) {
+	if h.onChange == nil {
+		return
+	}
+	if err := h.onChange(r.Context()); err != nil {
+		hlog.FromRequest(r).Warn().Err(err).Msg("failed to reload enrichment hooks")
+	}
+}
+
+// validateEnrichmentHook returns why a hook is unusable, or "".
+func validateEnrichmentHook(hook *database.EnrichmentHook) string {
+	u, err := url.Parse(hook.URL)
+	switch {
+	case hook.Name == "":
+		return "name is required"
+	case err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "":
+		return "url must be an absolute http or https URL"
+	case hook.SystemID != nil && *hook.SystemID <= 0:
+		return "system_id must be positive"
+	case hook.TimeoutMs < 1 || hook.TimeoutMs > maxEnrichmentTimeoutMs:
+		return fmt.Sprintf("timeout_ms must be between 1 and %d", maxEnrichmentTimeoutMs)
+	case hook.FailureThreshold < 1:
+		return "failure_threshold must be at least 1"
+	case hook.Cooldown < 1 || hook.Cooldown > maxEnrichmentCooldown:
+		return fmt.Sprintf("cooldown_s must be between 1 and %d", maxEnrichmentCooldown)
+	}
+	for name := range hook.Headers {
+		if strings.TrimSpace(name) == "" || strings.ContainsAny(name, " :\r\n") {
+			return fmt.Sprintf("invalid header name %q", name)
+		}
+	}
+	if hook.Condition != "" {
+		if _, err := expr.Compile(hook.Condition); err != nil {
+			return "invalid condition " + err.Error()
+		}
+	}
+	return ""
+}
+
+// redactHook hides header values, which often hold credentials.
+func redactHook(hook *database.EnrichmentHook) {
+	for name := range hook.Headers {
+		hook.Headers[name] = redactedHeader
+	}
+}
+
+// ListEnrichmentHooks returns all enrichment hooks, header values redacted.
+// GET /api/v1/admin/enrichment-hooks
+func (h *EnrichmentHooksHandler) ListEnrichmentHooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := h.db.ListEnrichmentHooks(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list enrichment hooks")
+		return
+	}
+	for i := range hooks {
+		redactHook(&hooks[i])
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"hooks": hooks,
+		"total": len(hooks),
+	})
+}
+
+// decodeEnrichmentHook reads and validates a hook body, applying defaults
+// (enabled, 2s timeout, circuit opening after 5 failures for 60s).
+func decodeEnrichmentHook(w http.ResponseWriter, r *http.Request) (*database.EnrichmentHook, bool) {
+	hook := database.EnrichmentHook{Enabled: true, TimeoutMs: 2000, FailureThreshold: 5, Cooldown: 60}
+	if err := DecodeJSON(r, &hook); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return nil, false
+	}
+	hook.Name = strings.TrimSpace(hook.Name)
+	hook.Condition = strings.TrimSpace(hook.Condition)
+	if msg := validateEnrichmentHook(&hook); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, msg)
+		return nil, false
+	}
+	if hook.Headers == nil {
+		hook.Headers = map[string]string{}
+	}
+	if hook.Tgids == nil {
+		hook.Tgids = []int{}
+	}
+	return &hook, true
+}
+
+// writeHook returns a hook as stored, header values redacted.
+func (h *EnrichmentHooksHandler) writeHook(w http.ResponseWriter, r *http.Request, id, status int) {
+	hook, err := h.db.GetEnrichmentHook(r.Context(), id)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get enrichment hook")
+		return
+	}
+	redactHook(hook)
+	WriteJSON(w, status, hook)
+}
+
+// GetEnrichmentHook returns one hook, header values redacted.
+// GET /api/v1/admin/enrichment-hooks/{id}
+func (h *EnrichmentHooksHandler) GetEnrichmentHook(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid enrichment hook ID")
+		return
+	}
+	hook, err := h.db.GetEnrichmentHook(r.Context(), id)
+	if err != nil {
+		h.writeHookError(w, err, "failed to get enrichment hook")
+		return
+	}
+	redactHook(hook)
+	WriteJSON(w, http.StatusOK, hook)
+}
+
+// CreateEnrichmentHook adds a hook; it applies to calls ending from now on.
+// POST /api/v1/admin/enrichment-hooks
+func (h *EnrichmentHooksHandler) CreateEnrichmentHook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := decodeEnrichmentHook(w, r)
+	if !ok {
+		return
+	}
+	id, err := h.db.CreateEnrichmentHook(r.Context(), hook)
+	if err != nil {
+		h.writeHookError(w, err, "failed to create enrichment hook")
+		return
+	}
+	h.changed(r)
+	h.writeHook(w, r, id, http.StatusCreated)
+}
+
+// UpdateEnrichmentHook replaces a hook. A header sent with the redacted
+// placeholder keeps its stored value. Saving resets the hook's circuit.
+// PUT /api/v1/admin/enrichment-hooks/{id}
+func (h *EnrichmentHooksHandler) UpdateEnrichmentHook(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid enrichment hook ID")
+		return
+	}
+	hook, ok := decodeEnrichmentHook(w, r)
+	if !ok {
+		return
+	}
+	current, err := h.db.GetEnrichmentHook(r.Context(), id)
+	if err != nil {
+		h.writeHookError(w, err, "failed to get enrichment hook")
+		return
+	}
+	for name, value := range hook.Headers {
+		if value == redactedHeader {
+			stored, ok := current.Headers[name]
+			if !ok {
+				WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter,
+					fmt.Sprintf("header %q has no stored value to keep", name))
+				return
+			}
+			hook.Headers[name] = stored
+		}
+	}
+	hook.ID = id
+	if err := h.db.UpdateEnrichmentHook(r.Context(), hook); err != nil {
+		h.writeHookError(w, err, "failed to update enrichment hook")
+		return
+	}
+	h.changed(r)
+	h.writeHook(w, r, id, http.StatusOK)
+}
+
+// DeleteEnrichmentHook removes a hook. Fields it already added to calls stay.
+// DELETE /api/v1/admin/enrichment-hooks/{id}
+func (h *EnrichmentHooksHandler) DeleteEnrichmentHook(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid enrichment hook ID")
+		return
+	}
+	if err := h.db.DeleteEnrichmentHook(r.Context(), id); err != nil {
+		h.writeHookError(w, err, "failed to delete enrichment hook")
+		return
+	}
+	h.changed(r)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *EnrichmentHooksHandler) writeHookError(w http.ResponseWriter, err error, msg string) {
+	switch err.Error() {
+	case "enrichment hook not found":
+		WriteError(w, http.StatusNotFound, err.Error())
+	case "enrichment hook name already exists":
+		WriteError(w, http.StatusConflict, err.Error())
+	default:
+		WriteError(w, http.StatusInternalServerError, msg)
+	}
+}
+
+// Routes registers enrichment hook routes on the given router.
+func (h *EnrichmentHooksHandler) Routes(r chi.Router) {
+	r.Get("/admin/enrichment-hooks", h.ListEnrichmentHooks)
+	r.Post("/admin/enrichment-hooks", h.CreateEnrichmentHook)
+	r.Get("/admin/enrichment-hooks/{id}", h.GetEnrichmentHook)
+	r.Put("/admin/enrichment-hooks/{id}", h.UpdateEnrichmentHook)
+	r.Delete("/admin/enrichment-hooks/{id}", h.DeleteEnrichmentHook)
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+func TestValidateEnrichmentHook(t *testing.T) {
+	zero := 0
+	valid := database.EnrichmentHook{Name: "cad", URL: "https://cad.example/enrich", TimeoutMs: 2000, FailureThreshold: 5, Cooldown: 60,
+		Headers: map[string]string{"Authorization": "Bearer x"}}
+	if msg := validateEnrichmentHook(&valid); msg != "" {
+		t.Errorf("valid hook: %q", msg)
+	}
+	for name, mutate := range map[string]func(h *database.EnrichmentHook){
+		"no name":      func(h *database.EnrichmentHook) { h.Name = "" },
+		"relative url": func(h *database.EnrichmentHook) { h.URL = "/enrich" },
+		"ftp url":      func(h *database.EnrichmentHook) { h.URL = "ftp://cad.example/" },
+		"zero system":  func(h *database.EnrichmentHook) { h.SystemID = &zero },
+		"timeout":      func(h *database.EnrichmentHook) { h.TimeoutMs = maxEnrichmentTimeoutMs + 1 },
+		"threshold":    func(h *database.EnrichmentHook) { h.FailureThreshold = 0 },
+		"cooldown":     func(h *database.EnrichmentHook) { h.Cooldown = 0 },
+		"header name":  func(h *database.EnrichmentHook) { h.Headers = map[string]string{"X Bad": "1"} },
+	} {
+		h := valid
+		mutate(&h)
+		if validateEnrichmentHook(&h) == "" {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -8,14 +8,17 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/rs/zerolog/hlog"
+	"github.com/snarg/tr-engine/pkg/events"
 )
 
 type EventsHandler struct {
-	live LiveDataSource
+	live        LiveDataSource
+	profiles    ProfileStore
+	authEnabled bool
 }
 
-func NewEventsHandler(live LiveDataSource) *EventsHandler {
-	return &EventsHandler{live: live}
+func NewEventsHandler(live LiveDataSource, profiles ProfileStore, authEnabled bool) *EventsHandler {
+	return &EventsHandler{live: live, profiles: profiles, authEnabled: authEnabled}
 }
 
 // StreamEvents opens an SSE connection and pushes filtered events.
@@ -44,6 +47,47 @@ func (h *EventsHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
 	if v, ok := QueryBool(r, "emergency_only"); ok {
 		filter.EmergencyOnly = v
 	}
+	if v, ok := QueryString(r, "expr"); ok {
+		filter.Expr = v
+	}
+	filter.IncludeRestricted = isAdmin(r)
+
+	// Saved profiles: the event must match one of them (and any filters above)
+	if v, ok := QueryString(r, "profile"); ok {
+		var names []string
+		for _, n := range strings.Split(v, ",") {
+			if n = strings.TrimSpace(n); n != "" {
+				names = append(names, n)
+			}
+		}
+		if len(names) > 0 {
+			alts, err := resolveProfiles(r.Context(), h.profiles, profileOwner(r, h.authEnabled), names)
+			if err != nil {
+				if strings.HasPrefix(err.Error(), "subscription profile not found") {
+					WriteError(w, http.StatusNotFound, err.Error())
+					return
+				}
+				WriteError(w, http.StatusInternalServerError, "failed to load subscription profiles")
+				return
+			}
+			filter.Any = alts
+		}
+	}
+	share := shareScopeFrom(r)
+	if share != nil {
+		if filter.Any != nil {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "profile cannot be used with a share link")
+			return
+		}
+		if !share.restrictEvents(&filter) {
+			WriteErrorWithCode(w, http.StatusForbidden, ErrForbidden, "requested systems or talkgroups are outside the share link")
+			return
+		}
+	}
+	if err := filter.CompileExpr(); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "invalid expr "+err.Error())
+		return
+	}
 
 	// Set SSE headers
 	w.Header().Set("Content-Type", "text/event-stream")
@@ -72,11 +116,22 @@ func (h *EventsHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
 	log := hlog.FromRequest(r)
 	log.Info().Msg("SSE client connected")
 
+	// Share link streams end when the link expires or is revoked.
+	var expired <-chan time.Time
+	if share != nil {
+		t := time.NewTimer(time.Until(share.Expires))
+		defer t.Stop()
+		expired = t.C
+	}
+
 	for {
 		select {
 		case <-r.Context().Done():
 			log.Info().Msg("SSE client disconnected")
 			return
+		case <-expired:
+			log.Info().Msg("SSE share link expired")
+			return
 		case event, ok := <-ch:
 			if !ok {
 				return
@@ -84,13 +139,27 @@ func (h *EventsHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
 			fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
 			flusher.Flush()
 		case <-keepalive.C:
+			if share != nil {
+				if revoked, err := share.revoked(r.Context()); revoked {
+					log.Info().Err(err).Msg("SSE share link revoked")
+					return
+				}
+			}
 			fmt.Fprint(w, ": keepalive\n\n")
 			flusher.Flush()
 		}
 	}
 }
 
+// GetEventSchema returns the JSON Schema for event stream payloads, generated
+// from the pkg/events types the pipeline publishes.
+func (h *EventsHandler) GetEventSchema(w http.ResponseWriter, r *http.Request) {
+	WriteJSON(w, http.StatusOK, events.Schema())
+}
+
 // Routes registers event routes on the given router.
 func (h *EventsHandler) Routes(r chi.Router) {
 	r.Get("/events/stream", h.StreamEvents)
+	r.Get("/events/ws", h.StreamEventsWS)
+	r.Get("/events/schema", h.GetEventSchema)
 }
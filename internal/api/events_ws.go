@@ -0,0 +1,271 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/hlog"
+)
+
+// WebSocket event stream: the same events as /events/stream for clients
+// behind proxies that buffer or drop SSE. One connection carries any number
+// of named subscriptions (up to maxWSSubscriptions), each with its own
+// EventFilter, saved profiles and replay point, added and removed with
+// control messages:
+//
+//	{"type":"subscribe","id":"fire","filter":{"tgids":[9131]},"profiles":["ems"],"since":"<event id>"}
+//	{"type":"unsubscribe","id":"fire"}
+//	{"type":"ping"}
+//
+// Events arrive as {"type":"event","subscription":"fire","id":...,"event":"call_start","data":{...}};
+// an event matching several subscriptions is sent once for each.
+
+const (
+	maxWSSubscriptions = 16
+	maxWSControlBytes  = 64 << 10
+	wsWriteTimeout     = 10 * time.Second
+)
+
+// wsControl is a control message from the client.
+type wsControl struct {
+	Type     string          `json:"type"` // subscribe, unsubscribe or ping
+	ID       string          `json:"id"`
+	Filter   json.RawMessage `json:"filter"`
+	Profiles []string        `json:"profiles"`
+	Since    string          `json:"since"` // replay buffered events after this event ID
+}
+
+// wsEvent is an event sent to the client for one of its subscriptions.
+type wsEvent struct {
+	Type         string          `json:"type"`
+	Subscription string          `json:"subscription"`
+	ID           string          `json:"id"`
+	Event        string          `json:"event"`
+	Data         json.RawMessage `json:"data"`
+
+	from *wsSubscription
+}
+
+// wsReply acknowledges a control message or reports why it failed.
+type wsReply struct {
+	Type     string `json:"type"`
+	ID       string `json:"id,omitempty"`
+	Replayed int    `json:"replayed,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// wsSubscription is one live subscription on a connection.
+type wsSubscription struct {
+	cancel func()
+	stop   chan struct{}
+}
+
+func (s *wsSubscription) close() {
+	close(s.stop)
+	s.cancel()
+}
+
+// wsFilter builds the EventFilter for a subscribe message with the same
+// rules as /events/stream query parameters. Returns an error message for
+// the client if the subscription is invalid.
+func (h *EventsHandler) wsFilter(r *http.Request, share *shareScope, msg wsControl) (EventFilter, string) {
+	var filter EventFilter
+	if len(msg.Filter) > 0 && string(msg.Filter) != "null" {
+		if err := json.Unmarshal(msg.Filter, &filter); err != nil {
+			return filter, "invalid filter"
+		}
+	}
+	filter.IncludeRestricted = isAdmin(r)
+
+	var names []string
+	for _, n := range msg.Profiles {
+		if n = strings.TrimSpace(n); n != "" {
+			names = append(names, n)
+		}
+	}
+	if len(names) > 0 {
+		if share != nil {
+			return filter, "profiles cannot be used with a share link"
+		}
+		alts, err := resolveProfiles(r.Context(), h.profiles, profileOwner(r, h.authEnabled), names)
+		if err != nil {
+			if strings.HasPrefix(err.Error(), "subscription profile not found") {
+				return filter, err.Error()
+			}
+			return filter, "failed to load subscription profiles"
+		}
+		filter.Any = alts
+	}
+	if share != nil && !share.restrictEvents(&filter) {
+		return filter, "requested systems or talkgroups are outside the share link"
+	}
+	if err := filter.CompileExpr(); err != nil {
+		return filter, "invalid expr " + err.Error()
+	}
+	return filter, ""
+}
+
+// StreamEventsWS upgrades to a WebSocket and streams events for the
+// subscriptions the client adds. Nothing is sent until the first subscribe.
+func (h *EventsHandler) StreamEventsWS(w http.ResponseWriter, r *http.Request) {
+	if h.live == nil {
+		WriteError(w, http.StatusServiceUnavailable, "event streaming not available")
+		return
+	}
+	log := hlog.FromRequest(r)
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("websocket upgrade failed")
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(maxWSControlBytes)
+
+	send := func(v any) error {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		return conn.WriteJSON(v)
+	}
+
+	// Reader goroutine: control messages from the client
+	controlCh := make(chan wsControl, 4)
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var msg wsControl
+			if err := json.Unmarshal(data, &msg); err != nil {
+				msg = wsControl{Type: "invalid"}
+			}
+			select {
+			case controlCh <- msg:
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}()
+
+	share := shareScopeFrom(r)
+
+	// Each subscription's events are forwarded here so one loop does all writes.
+	events := make(chan wsEvent, 64)
+	subs := map[string]*wsSubscription{}
+	defer func() {
+		for _, s := range subs {
+			s.close()
+		}
+	}()
+
+	subscribe := func(msg wsControl) error {
+		if msg.ID == "" || len(msg.ID) > 64 {
+			return send(wsReply{Type: "error", ID: msg.ID, Error: "id is required and must be at most 64 characters"})
+		}
+		if _, ok := subs[msg.ID]; !ok && len(subs) >= maxWSSubscriptions {
+			return send(wsReply{Type: "error", ID: msg.ID, Error: fmt.Sprintf("at most %d subscriptions per connection", maxWSSubscriptions)})
+		}
+		filter, errMsg := h.wsFilter(r, share, msg)
+		if errMsg != "" {
+			return send(wsReply{Type: "error", ID: msg.ID, Error: errMsg})
+		}
+		// Subscribing with an existing id replaces that subscription.
+		if old, ok := subs[msg.ID]; ok {
+			old.close()
+			delete(subs, msg.ID)
+		}
+
+		var replay []SSEEvent
+		if msg.Since != "" {
+			replay = h.live.ReplaySince(msg.Since, filter)
+		}
+		if err := send(wsReply{Type: "subscribed", ID: msg.ID, Replayed: len(replay)}); err != nil {
+			return err
+		}
+		for _, e := range replay {
+			if err := send(wsEvent{Type: "event", Subscription: msg.ID, ID: e.ID, Event: e.Type, Data: e.Data}); err != nil {
+				return err
+			}
+		}
+
+		ch, cancel := h.live.Subscribe(filter)
+		sub := &wsSubscription{cancel: cancel, stop: make(chan struct{})}
+		subs[msg.ID] = sub
+		go func(id string) {
+			for e := range ch {
+				select {
+				case events <- wsEvent{Type: "event", Subscription: id, ID: e.ID, Event: e.Type, Data: e.Data, from: sub}:
+				case <-sub.stop:
+					return
+				}
+			}
+		}(msg.ID)
+		return nil
+	}
+
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+
+	// Share link streams end when the link expires or is revoked.
+	var expired <-chan time.Time
+	if share != nil {
+		t := time.NewTimer(time.Until(share.Expires))
+		defer t.Stop()
+		expired = t.C
+	}
+
+	log.Info().Msg("WebSocket event client connected")
+
+	for {
+		var err error
+		select {
+		case <-doneCh:
+			log.Info().Msg("WebSocket event client disconnected")
+			return
+		case <-expired:
+			log.Info().Msg("WebSocket share link expired")
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "share link expired"),
+				time.Now().Add(wsWriteTimeout))
+			return
+		case msg := <-controlCh:
+			switch msg.Type {
+			case "subscribe":
+				err = subscribe(msg)
+			case "unsubscribe":
+				if s, ok := subs[msg.ID]; ok {
+					s.close()
+					delete(subs, msg.ID)
+				}
+				err = send(wsReply{Type: "unsubscribed", ID: msg.ID})
+			case "ping":
+				err = send(wsReply{Type: "pong"})
+			default:
+				err = send(wsReply{Type: "error", Error: "unknown message type; expected subscribe, unsubscribe or ping"})
+			}
+		case e := <-events:
+			// Skip events queued before their subscription was removed or replaced
+			if subs[e.Subscription] == e.from {
+				err = send(e)
+			}
+		case <-keepalive.C:
+			if share != nil {
+				if revoked, err := share.revoked(r.Context()); revoked {
+					log.Info().Err(err).Msg("WebSocket share link revoked")
+					return
+				}
+			}
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+		}
+		if err != nil {
+			log.Debug().Err(err).Msg("WebSocket event write failed")
+			return
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+)
+
+// mockEventSource is a LiveDataSource that hands every published event to
+// all subscribers (no filtering) and records their filters.
+type mockEventSource struct {
+	mockLiveData
+	mu      sync.Mutex
+	subs    map[chan SSEEvent]EventFilter
+	replay  []SSEEvent
+	replays []string
+}
+
+func (m *mockEventSource) Subscribe(f EventFilter) (<-chan SSEEvent, func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ch := make(chan SSEEvent, 8)
+	m.subs[ch] = f
+	return ch, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.subs, ch)
+		close(ch)
+	}
+}
+
+func (m *mockEventSource) ReplaySince(id string, _ EventFilter) []SSEEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.replays = append(m.replays, id)
+	return m.replay
+}
+
+func (m *mockEventSource) publish(e SSEEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for ch := range m.subs {
+		ch <- e
+	}
+}
+
+func (m *mockEventSource) filters() []EventFilter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var fs []EventFilter
+	for _, f := range m.subs {
+		fs = append(fs, f)
+	}
+	return fs
+}
+
+func TestStreamEventsWS(t *testing.T) {
+	live := &mockEventSource{
+		subs:   map[chan SSEEvent]EventFilter{},
+		replay: []SSEEvent{{ID: "100-2", Type: "call_end", Data: []byte(`{"tgid":9131}`)}},
+	}
+	r := chi.NewRouter()
+	NewEventsHandler(live, newMockProfileStore(), false).Routes(r)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(srv, "/events/ws"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var msg map[string]any
+	roundTrip := func(ctrl string) map[string]any {
+		t.Helper()
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(ctrl)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		return readWSJSON(t, conn)
+	}
+
+	// Subscribe with a filter and a replay point: ack, then the replayed event
+	msg = roundTrip(`{"type":"subscribe","id":"fire","filter":{"tgids":[9131],"types":["call_start","call_end"]},"since":"100-1"}`)
+	if msg["type"] != "subscribed" || msg["id"] != "fire" || msg["replayed"] != float64(1) {
+		t.Fatalf("subscribe reply = %v", msg)
+	}
+	if msg = readWSJSON(t, conn); msg["type"] != "event" || msg["subscription"] != "fire" || msg["id"] != "100-2" || msg["event"] != "call_end" {
+		t.Errorf("replayed event = %v", msg)
+	}
+	fs := live.filters()
+	if len(fs) != 1 || len(fs[0].Tgids) != 1 || fs[0].Tgids[0] != 9131 || len(fs[0].Types) != 2 {
+		t.Errorf("subscribed filters = %+v", fs)
+	}
+	if len(live.replays) != 1 || live.replays[0] != "100-1" {
+		t.Errorf("replays = %v", live.replays)
+	}
+
+	live.publish(SSEEvent{ID: "100-3", Type: "call_start", Data: []byte(`{"tgid":9131}`)})
+	if msg = readWSJSON(t, conn); msg["subscription"] != "fire" || msg["id"] != "100-3" || msg["data"].(map[string]any)["tgid"] != float64(9131) {
+		t.Errorf("live event = %v", msg)
+	}
+
+	// Invalid subscriptions are reported without closing the connection
+	for _, ctrl := range []string{
+		`{"type":"subscribe","filter":{}}`,
+		`{"type":"subscribe","id":"bad","filter":{"expr":"tgid =="}}`,
+		`{"type":"subscribe","id":"bad","profiles":["nope"]}`,
+		`{"type":"resubscribe"}`,
+	} {
+		if msg = roundTrip(ctrl); msg["type"] != "error" || msg["error"] == "" {
+			t.Errorf("%s: reply = %v", ctrl, msg)
+		}
+	}
+
+	if msg = roundTrip(`{"type":"ping"}`); msg["type"] != "pong" {
+		t.Errorf("ping reply = %v", msg)
+	}
+	if msg = roundTrip(`{"type":"unsubscribe","id":"fire"}`); msg["type"] != "unsubscribed" {
+		t.Errorf("unsubscribe reply = %v", msg)
+	}
+	if fs := live.filters(); len(fs) != 0 {
+		t.Errorf("%d subscriptions left after unsubscribe", len(fs))
+	}
+}
+
+func readWSJSON(t *testing.T, conn *websocket.Conn) map[string]any {
+	t.Helper()
+	var msg map[string]any
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return msg
+}
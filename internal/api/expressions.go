@@ -0,0 +1,192 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/expr"
+)
+
+// Filter expressions (internal/expr) are evaluated against an event's
+// payload fields, plus event_type, event_subtype, event_id and timestamp
+// from its envelope. system_id, site_id, tgid, unit_id and emergency come
+// from the envelope when the payload lacks them.
+
+// EventVars lazily builds an event's expression variables, so an event
+// matched against many filters is decoded at most once. Not safe for
+// concurrent use.
+type EventVars struct {
+	e    *SSEEvent
+	vars map[string]any
+}
+
+func NewEventVars(e *SSEEvent) *EventVars {
+	return &EventVars{e: e}
+}
+
+// Get returns the variables, decoding the payload on first use.
+func (v *EventVars) Get() map[string]any {
+	if v.vars != nil {
+		return v.vars
+	}
+	e := v.e
+	vars := map[string]any{}
+	if len(e.Data) > 0 && json.Unmarshal(e.Data, &vars) != nil {
+		vars = map[string]any{}
+	}
+	vars["event_type"] = e.Type
+	vars["event_subtype"] = e.SubType
+	vars["event_id"] = e.ID
+	vars["timestamp"] = e.Timestamp
+	for k, n := range map[string]int{"system_id": e.SystemID, "site_id": e.SiteID, "tgid": e.Tgid, "unit_id": e.UnitID} {
+		if _, ok := vars[k]; !ok && n != 0 {
+			vars[k] = float64(n)
+		}
+	}
+	if _, ok := vars["emergency"]; !ok {
+		vars["emergency"] = e.Emergency
+	}
+	v.vars = vars
+	return vars
+}
+
+// CompileExpr compiles the filter's Expr, and those of its Any
+// alternatives, into Program.
+func (f *EventFilter) CompileExpr() error {
+	f.Program = nil
+	if f.Expr = strings.TrimSpace(f.Expr); f.Expr != "" {
+		p, err := expr.Compile(f.Expr)
+		if err != nil {
+			return err
+		}
+		f.Program = p
+	}
+	for i := range f.Any {
+		if err := f.Any[i].CompileExpr(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExpressionsHandler validates filter expressions and test-evaluates them
+// against a sample payload or recently published events.
+type ExpressionsHandler struct {
+	live LiveDataSource
+}
+
+func NewExpressionsHandler(live LiveDataSource) *ExpressionsHandler {
+	return &ExpressionsHandler{live: live}
+}
+
+// exprErrorBody describes an expression error for clients to highlight.
+func exprErrorBody(err error) any {
+	var e *expr.Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return map[string]any{"message": err.Error()}
+}
+
+// ValidateExpression compiles an expression and lists the fields it reads.
+// Invalid expressions are a 200 with valid=false and the error position.
+// POST /api/v1/expressions/validate
+func (h *ExpressionsHandler) ValidateExpression(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Expr string `json:"expr"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	p, err := expr.Compile(req.Expr)
+	if err != nil {
+		WriteJSON(w, http.StatusOK, map[string]any{"valid": false, "error": exprErrorBody(err)})
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"valid": true, "fields": p.Fields()})
+}
+
+// maxEvaluateSamples caps the matching events returned by EvaluateExpression.
+const maxEvaluateSamples = 100
+
+// EvaluateExpression test-evaluates an expression. With "event" (the
+// variables as a filter sees them: payload fields plus event_type etc.), it
+// returns the result. Without, it runs the expression over the events still in the
+// replay buffer (about the last minute), optionally only those of
+// "event_types", and returns the matches.
+// POST /api/v1/expressions/evaluate
+func (h *ExpressionsHandler) EvaluateExpression(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Expr       string         `json:"expr"`
+		Event      map[string]any `json:"event"`
+		EventTypes []string       `json:"event_types"`
+		Limit      int            `json:"limit"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	p, err := expr.Compile(req.Expr)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "invalid expression "+err.Error())
+		return
+	}
+
+	if req.Event != nil {
+		result, err := p.Eval(req.Event)
+		resp := map[string]any{"result": result, "matched": err == nil && result == true}
+		if err != nil {
+			resp["error"] = exprErrorBody(err)
+		}
+		WriteJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	if h.live == nil {
+		WriteError(w, http.StatusServiceUnavailable, "pipeline not running")
+		return
+	}
+	if req.Limit <= 0 || req.Limit > maxEvaluateSamples {
+		req.Limit = 20
+	}
+	recent := h.live.ReplaySince("", EventFilter{Types: req.EventTypes, IncludeRestricted: isAdmin(r)})
+	matches := []map[string]any{}
+	matched, errored := 0, 0
+	for i := range recent {
+		e := &recent[i]
+		v, err := p.Eval(NewEventVars(e).Get())
+		if err != nil {
+			errored++
+			continue
+		}
+		if b, _ := v.(bool); !b {
+			continue
+		}
+		matched++
+		if len(matches) < req.Limit {
+			matches = append(matches, map[string]any{
+				"event_id":   e.ID,
+				"event_type": e.Type,
+				"sub_type":   e.SubType,
+				"timestamp":  e.Timestamp,
+				"data":       json.RawMessage(e.Data),
+			})
+		}
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"evaluated": len(recent),
+		"matched":   matched,
+		"errors":    errored,
+		"events":    matches,
+	})
+}
+
+func (h *ExpressionsHandler) Routes(r chi.Router) {
+	r.Post("/expressions/validate", h.ValidateExpression)
+	r.Post("/expressions/evaluate", h.EvaluateExpression)
+}
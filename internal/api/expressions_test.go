@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// replayLiveData serves fixed events from ReplaySince.
+type replayLiveData struct {
+	*mockLiveData
+	events []SSEEvent
+}
+
+func (m *replayLiveData) ReplaySince(_ string, f EventFilter) []SSEEvent {
+	var out []SSEEvent
+	for _, e := range m.events {
+		if len(f.Types) == 0 || f.Types[0] == e.Type {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func postJSON(h http.HandlerFunc, body string) (*httptest.ResponseRecorder, map[string]any) {
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+	var resp map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec, resp
+}
+
+func TestValidateExpression(t *testing.T) {
+	h := NewExpressionsHandler(nil)
+
+	rec, resp := postJSON(h.ValidateExpression, `{"expr":"tgid in [1] && text.contains('x')"}`)
+	if rec.Code != http.StatusOK || resp["valid"] != true || len(resp["fields"].([]any)) != 2 {
+		t.Errorf("valid: %d %v", rec.Code, resp)
+	}
+	rec, resp = postJSON(h.ValidateExpression, `{"expr":"tgid =="}`)
+	e, _ := resp["error"].(map[string]any)
+	if rec.Code != http.StatusOK || resp["valid"] != false || e["position"] != 7.0 {
+		t.Errorf("invalid: %d %v", rec.Code, resp)
+	}
+}
+
+func TestEvaluateExpression(t *testing.T) {
+	live := &replayLiveData{mockLiveData: &mockLiveData{}, events: []SSEEvent{
+		{ID: "1", Type: "call_end", Tgid: 4001, Data: []byte(`{"duration":20}`)},
+		{ID: "2", Type: "call_end", Tgid: 4002, Data: []byte(`{"duration":5}`)},
+		{ID: "3", Type: "call_start", Tgid: 4001, Data: []byte(`{}`)},
+	}}
+	h := NewExpressionsHandler(live)
+
+	rec, resp := postJSON(h.EvaluateExpression, `{"expr":"duration > 15","event":{"duration":16}}`)
+	if rec.Code != http.StatusOK || resp["result"] != true || resp["matched"] != true {
+		t.Errorf("event: %d %v", rec.Code, resp)
+	}
+	_, resp = postJSON(h.EvaluateExpression, `{"expr":"duration > 15","event":{}}`)
+	if resp["matched"] != false || resp["error"] == nil {
+		t.Errorf("event error: %v", resp)
+	}
+
+	rec, resp = postJSON(h.EvaluateExpression, `{"expr":"duration > 15"}`)
+	if rec.Code != http.StatusOK || resp["evaluated"] != 3.0 || resp["matched"] != 1.0 || resp["errors"] != 1.0 {
+		t.Errorf("recent: %d %v", rec.Code, resp)
+	}
+	_, resp = postJSON(h.EvaluateExpression, `{"expr":"tgid == 4001","event_types":["call_start"]}`)
+	if resp["evaluated"] != 1.0 || resp["matched"] != 1.0 {
+		t.Errorf("recent by type: %v", resp)
+	}
+
+	if rec, _ = postJSON(h.EvaluateExpression, `{"expr":"tgid ~ 1"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid expr status = %d", rec.Code)
+	}
+}
+
+func TestStreamEventsInvalidExpr(t *testing.T) {
+	h := NewEventsHandler(&mockLiveData{}, newMockProfileStore(), false)
+	rec := httptest.NewRecorder()
+	h.StreamEvents(rec, httptest.NewRequest("GET", "/events/stream?expr=tgid+%3D%3D", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,341 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+const (
+	// maxExternalEventBatch is the most events accepted by one POST.
+	maxExternalEventBatch = 1000
+	// maxCorrelationWindow bounds a rule's window on either side of an event.
+	maxCorrelationWindow = 24 * 60 * 60
+	// ruleBackfill is how far back a new or changed rule is applied.
+	ruleBackfill = 24 * time.Hour
+)
+
+// ExternalEventsHandler ingests events from other receivers (ADS-B, AIS,
+// ACARS, ...) and manages the rules that link them to calls.
+type ExternalEventsHandler struct {
+	db *database.DB
+}
+
+func NewExternalEventsHandler(db *database.DB) *ExternalEventsHandler {
+	return &ExternalEventsHandler{db: db}
+}
+
+// validateExternalEvent returns why an ingested event is unusable, or "".
+func validateExternalEvent(e *database.ExternalEvent) string {
+	switch {
+	case e.Source == "" || len(e.Source) > 64:
+		return "source is required (at most 64 characters)"
+	case e.EventTime.IsZero():
+		return "event_time is required"
+	case (e.Latitude == nil) != (e.Longitude == nil):
+		return "latitude and longitude must be given together"
+	case e.Latitude != nil && (*e.Latitude < -90 || *e.Latitude > 90):
+		return "latitude must be between -90 and 90"
+	case e.Longitude != nil && (*e.Longitude < -180 || *e.Longitude > 180):
+		return "longitude must be between -180 and 180"
+	}
+	return ""
+}
+
+// validateCorrelationRule returns why a rule is unusable, or "".
+func validateCorrelationRule(r *database.ExternalCorrelationRule) string {
+	switch {
+	case r.Name == "":
+		return "name is required"
+	case r.Source == "":
+		return "source is required"
+	case r.SystemID <= 0:
+		return "system_id is required"
+	case r.WindowBefore < 0 || r.WindowBefore > maxCorrelationWindow:
+		return fmt.Sprintf("window_before_s must be between 0 and %d", maxCorrelationWindow)
+	case r.WindowAfter < 0 || r.WindowAfter > maxCorrelationWindow:
+		return fmt.Sprintf("window_after_s must be between 0 and %d", maxCorrelationWindow)
+	case r.MaxDistanceKm != nil && *r.MaxDistanceKm <= 0:
+		return "max_distance_km must be positive"
+	}
+	return ""
+}
+
+// IngestExternalEvents stores a batch of events and links them to calls by
+// the enabled correlation rules. Events repeating a stored event_key for
+// their source are not stored again.
+// POST /api/v1/external-events
+// Body: {"events": [{"source": "adsb", "kind", "event_key", "subject", "label",
+// "event_time", "latitude", "longitude", "data": {...}}, ...]}
+func (h *ExternalEventsHandler) IngestExternalEvents(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Events []database.ExternalEvent `json:"events"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	if len(req.Events) == 0 || len(req.Events) > maxExternalEventBatch {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody,
+			fmt.Sprintf("events must contain 1 to %d events", maxExternalEventBatch))
+		return
+	}
+	for i := range req.Events {
+		if msg := validateExternalEvent(&req.Events[i]); msg != "" {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, fmt.Sprintf("events[%d]: %s", i, msg))
+			return
+		}
+	}
+
+	since := time.Now()
+	ids, inserted, err := h.db.InsertExternalEvents(r.Context(), req.Events)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to store external events")
+		return
+	}
+	var linked int64
+	if inserted > 0 {
+		// Calls that arrive later are linked by the ingest pipeline's periodic pass.
+		if linked, err = h.db.CorrelateExternalEvents(r.Context(), since, 0); err != nil {
+			hlog.FromRequest(r).Warn().Err(err).Msg("external event correlation failed")
+		}
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"ids":        ids,
+		"inserted":   inserted,
+		"duplicates": len(ids) - inserted,
+		"linked":     linked,
+	})
+}
+
+// ListExternalEvents returns events, newest first.
+// GET /api/v1/external-events?source=&kind=&subject=&start_time=&end_time=
+func (h *ExternalEventsHandler) ListExternalEvents(w http.ResponseWriter, r *http.Request) {
+	p, err := ParsePagination(r)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+	filter := database.ExternalEventFilter{Limit: p.Limit, Offset: p.Offset}
+	filter.Source, _ = QueryString(r, "source")
+	filter.Kind, _ = QueryString(r, "kind")
+	filter.Subject, _ = QueryString(r, "subject")
+	if t, ok := QueryTime(r, "start_time"); ok {
+		filter.StartTime = &t
+	}
+	if t, ok := QueryTime(r, "end_time"); ok {
+		filter.EndTime = &t
+	}
+	if msg := ValidateTimeRange(filter.StartTime, filter.EndTime); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+
+	events, total, err := h.db.ListExternalEvents(r.Context(), filter)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list external events")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"events": events,
+		"total":  total,
+		"limit":  p.Limit,
+		"offset": p.Offset,
+	})
+}
+
+// GetExternalEvent returns an event with its linked calls.
+// GET /api/v1/external-events/{id}
+func (h *ExternalEventsHandler) GetExternalEvent(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt64(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid event ID")
+		return
+	}
+	e, err := h.db.GetExternalEvent(r.Context(), id, isAdmin(r))
+	if err != nil {
+		if err.Error() == "external event not found" {
+			WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to get external event")
+		return
+	}
+	WriteJSON(w, http.StatusOK, e)
+}
+
+// DeleteExternalEvent removes an event and its call links.
+// DELETE /api/v1/external-events/{id}
+func (h *ExternalEventsHandler) DeleteExternalEvent(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt64(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid event ID")
+		return
+	}
+	if err := h.db.DeleteExternalEvent(r.Context(), id); err != nil {
+		if err.Error() == "external event not found" {
+			WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to delete external event")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListCallExternalEvents returns the external events a call is linked to.
+// GET /api/v1/calls/{id}/external-events
+func (h *ExternalEventsHandler) ListCallExternalEvents(w http.ResponseWriter, r *http.Request) {
+	ref, err := ParseCallRef(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid call ID")
+		return
+	}
+	if !isAdmin(r) {
+		if restricted, err := h.db.CallRestricted(r.Context(), ref); err != nil || restricted {
+			WriteError(w, http.StatusNotFound, "call not found")
+			return
+		}
+	}
+	ref, err = h.db.ResolveCallRef(r.Context(), ref)
+	if err != nil {
+		if err.Error() == "call not found" {
+			WriteError(w, http.StatusNotFound, "call not found")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to list external events")
+		return
+	}
+	events, err := h.db.ListCallsExternalEvents(r.Context(), []database.CallRef{ref})
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list external events")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"events": events,
+		"total":  len(events),
+	})
+}
+
+// ListCorrelationRules returns all external event correlation rules.
+// GET /api/v1/admin/external-correlation-rules
+func (h *ExternalEventsHandler) ListCorrelationRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.db.ListExternalCorrelationRules(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list correlation rules")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"rules": rules,
+		"total": len(rules),
+	})
+}
+
+// decodeCorrelationRule reads and validates a rule body. enabled defaults to true.
+func decodeCorrelationRule(w http.ResponseWriter, r *http.Request) (*database.ExternalCorrelationRule, bool) {
+	rule := database.ExternalCorrelationRule{Enabled: true}
+	if err := DecodeJSON(r, &rule); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return nil, false
+	}
+	if msg := validateCorrelationRule(&rule); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, msg)
+		return nil, false
+	}
+	if rule.Tgids == nil {
+		rule.Tgids = []int{}
+	}
+	return &rule, true
+}
+
+// applyRule links the last ruleBackfill of events by a new or changed rule
+// and returns the rule as stored. The rule is already saved, so a failed
+// correlation is only logged; the periodic pass retries recent events.
+func (h *ExternalEventsHandler) applyRule(w http.ResponseWriter, r *http.Request, id, status int) {
+	if _, err := h.db.CorrelateExternalEvents(r.Context(), time.Now().Add(-ruleBackfill), id); err != nil {
+		hlog.FromRequest(r).Warn().Err(err).Int("rule_id", id).Msg("external event correlation failed")
+	}
+	rule, err := h.db.GetExternalCorrelationRule(r.Context(), id)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get correlation rule")
+		return
+	}
+	WriteJSON(w, status, rule)
+}
+
+// CreateCorrelationRule adds a rule and applies it to the last day of events.
+// POST /api/v1/admin/external-correlation-rules
+func (h *ExternalEventsHandler) CreateCorrelationRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := decodeCorrelationRule(w, r)
+	if !ok {
+		return
+	}
+	id, err := h.db.CreateExternalCorrelationRule(r.Context(), rule)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to create correlation rule")
+		return
+	}
+	h.applyRule(w, r, id, http.StatusCreated)
+}
+
+// UpdateCorrelationRule replaces a rule. Its links are rebuilt for the last
+// day of events; older links made under the previous settings are dropped.
+// PUT /api/v1/admin/external-correlation-rules/{id}
+func (h *ExternalEventsHandler) UpdateCorrelationRule(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid rule ID")
+		return
+	}
+	rule, ok := decodeCorrelationRule(w, r)
+	if !ok {
+		return
+	}
+	rule.ID = id
+	if err := h.db.UpdateExternalCorrelationRule(r.Context(), rule); err != nil {
+		if err.Error() == "correlation rule not found" {
+			WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to update correlation rule")
+		return
+	}
+	h.applyRule(w, r, id, http.StatusOK)
+}
+
+// DeleteCorrelationRule removes a rule and the links it made.
+// DELETE /api/v1/admin/external-correlation-rules/{id}
+func (h *ExternalEventsHandler) DeleteCorrelationRule(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid rule ID")
+		return
+	}
+	if err := h.db.DeleteExternalCorrelationRule(r.Context(), id); err != nil {
+		if err.Error() == "correlation rule not found" {
+			WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to delete correlation rule")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Routes registers external event routes on the given router.
+func (h *ExternalEventsHandler) Routes(r chi.Router) {
+	r.Get("/external-events", h.ListExternalEvents)
+	r.Post("/external-events", h.IngestExternalEvents)
+	r.Get("/external-events/{id}", h.GetExternalEvent)
+	r.Delete("/external-events/{id}", h.DeleteExternalEvent)
+	r.Get("/calls/{id}/external-events", h.ListCallExternalEvents)
+	r.Get("/admin/external-correlation-rules", h.ListCorrelationRules)
+	r.Post("/admin/external-correlation-rules", h.CreateCorrelationRule)
+	r.Put("/admin/external-correlation-rules/{id}", h.UpdateCorrelationRule)
+	r.Delete("/admin/external-correlation-rules/{id}", h.DeleteCorrelationRule)
+}
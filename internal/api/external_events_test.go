@@ -0,0 +1,50 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+func TestValidateExternalEvent(t *testing.T) {
+	lat, lon, bad := 39.1, -84.5, 91.0
+	now := time.Now()
+	tests := []struct {
+		name  string
+		event database.ExternalEvent
+		ok    bool
+	}{
+		{"minimal", database.ExternalEvent{Source: "adsb", EventTime: now}, true},
+		{"positioned", database.ExternalEvent{Source: "adsb", EventTime: now, Latitude: &lat, Longitude: &lon}, true},
+		{"no source", database.ExternalEvent{EventTime: now}, false},
+		{"no time", database.ExternalEvent{Source: "ais"}, false},
+		{"latitude only", database.ExternalEvent{Source: "ais", EventTime: now, Latitude: &lat}, false},
+		{"latitude out of range", database.ExternalEvent{Source: "ais", EventTime: now, Latitude: &bad, Longitude: &lon}, false},
+	}
+	for _, tt := range tests {
+		if msg := validateExternalEvent(&tt.event); (msg == "") != tt.ok {
+			t.Errorf("%s: validateExternalEvent = %q, want ok=%v", tt.name, msg, tt.ok)
+		}
+	}
+}
+
+func TestValidateCorrelationRule(t *testing.T) {
+	zero := 0.0
+	valid := database.ExternalCorrelationRule{Name: "air-to-ground", Source: "adsb", SystemID: 1, WindowBefore: 300, WindowAfter: 300}
+	if msg := validateCorrelationRule(&valid); msg != "" {
+		t.Errorf("valid rule: %q", msg)
+	}
+	for name, mutate := range map[string]func(r *database.ExternalCorrelationRule){
+		"no name":       func(r *database.ExternalCorrelationRule) { r.Name = "" },
+		"no system":     func(r *database.ExternalCorrelationRule) { r.SystemID = 0 },
+		"window":        func(r *database.ExternalCorrelationRule) { r.WindowAfter = maxCorrelationWindow + 1 },
+		"zero distance": func(r *database.ExternalCorrelationRule) { r.MaxDistanceKm = &zero },
+	} {
+		r := valid
+		mutate(&r)
+		if validateCorrelationRule(&r) == "" {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
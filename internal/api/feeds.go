@@ -0,0 +1,601 @@
+package api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// Public talkgroup feeds.
+//
+// A feed is a named talkgroup set with a publication delay and item cap,
+// rendered at stable unauthenticated URLs as JSON Feed 1.1, RSS 2.0, or Atom:
+//
+//	/api/v1/feeds/{slug}.json|.rss|.atom
+//	/api/v1/feeds/{slug}/audio/{call_id}   (enclosure target)
+//
+// Responses carry Cache-Control/ETag headers so they can sit behind a CDN.
+// Feed configuration is managed under /api/v1/admin/feeds.
+
+const (
+	feedSnippetLen  = 280
+	feedMaxItemsCap = 500
+	feedDefaultMax  = 50
+)
+
+var feedSlugRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+type FeedsHandler struct {
+	db      *database.DB
+	calls   *CallsHandler
+	baseURL string        // absolute URL prefix for links; derived from the request when empty
+	maxAge  time.Duration // Cache-Control max-age for rendered feeds
+}
+
+func NewFeedsHandler(db *database.DB, calls *CallsHandler, baseURL string, maxAge time.Duration) *FeedsHandler {
+	return &FeedsHandler{db: db, calls: calls, baseURL: strings.TrimRight(baseURL, "/"), maxAge: maxAge}
+}
+
+// feedRequest is the body for creating or updating a feed.
+type feedRequest struct {
+	Slug         string  `json:"slug"`
+	Title        *string `json:"title"`
+	Description  *string `json:"description"`
+	SystemID     *int    `json:"system_id"`
+	Tgids        []int   `json:"tgids"`
+	DelaySeconds *int    `json:"delay_seconds"`
+	MaxItems     *int    `json:"max_items"`
+}
+
+// validate checks the fields present in req. create requires slug, title, and tgids.
+func (req *feedRequest) validate(create bool) string {
+	if create {
+		if !feedSlugRe.MatchString(req.Slug) {
+			return "slug must be 1-64 lowercase letters, digits, '-' or '_'"
+		}
+		if req.Title == nil || len(req.Tgids) == 0 {
+			return "title and tgids are required"
+		}
+	}
+	if req.Title != nil && strings.TrimSpace(*req.Title) == "" {
+		return "title must not be empty"
+	}
+	for _, tg := range req.Tgids {
+		if tg <= 0 {
+			return fmt.Sprintf("invalid tgid %d", tg)
+		}
+	}
+	if req.SystemID != nil && *req.SystemID < 0 {
+		return "system_id must be positive"
+	}
+	if req.DelaySeconds != nil && *req.DelaySeconds < 0 {
+		return "delay_seconds must be >= 0"
+	}
+	if req.MaxItems != nil && (*req.MaxItems < 1 || *req.MaxItems > feedMaxItemsCap) {
+		return fmt.Sprintf("max_items must be between 1 and %d", feedMaxItemsCap)
+	}
+	return ""
+}
+
+// ListFeeds returns all feed configurations.
+func (h *FeedsHandler) ListFeeds(w http.ResponseWriter, r *http.Request) {
+	feeds, err := h.db.ListFeeds(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list feeds")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"feeds": feeds,
+		"total": len(feeds),
+	})
+}
+
+// GetFeed returns a single feed configuration.
+func (h *FeedsHandler) GetFeed(w http.ResponseWriter, r *http.Request) {
+	f, err := h.db.GetFeed(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		if err.Error() == "feed not found" {
+			WriteError(w, http.StatusNotFound, "feed not found")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to get feed")
+		return
+	}
+	WriteJSON(w, http.StatusOK, f)
+}
+
+// CreateFeed creates a new feed.
+func (h *FeedsHandler) CreateFeed(w http.ResponseWriter, r *http.Request) {
+	var req feedRequest
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	if msg := req.validate(true); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, msg)
+		return
+	}
+
+	f := &database.Feed{
+		Slug:     req.Slug,
+		Title:    strings.TrimSpace(*req.Title),
+		Tgids:    req.Tgids,
+		MaxItems: feedDefaultMax,
+	}
+	if req.Description != nil {
+		f.Description = *req.Description
+	}
+	if req.SystemID != nil && *req.SystemID > 0 {
+		f.SystemID = req.SystemID
+	}
+	if req.DelaySeconds != nil {
+		f.DelaySeconds = *req.DelaySeconds
+	}
+	if req.MaxItems != nil {
+		f.MaxItems = *req.MaxItems
+	}
+
+	if err := h.db.CreateFeed(r.Context(), f); err != nil {
+		if err.Error() == "feed already exists" {
+			WriteError(w, http.StatusConflict, "feed already exists")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to create feed")
+		return
+	}
+	WriteJSON(w, http.StatusCreated, f)
+}
+
+// UpdateFeed partially updates a feed. system_id 0 removes the system restriction.
+func (h *FeedsHandler) UpdateFeed(w http.ResponseWriter, r *http.Request) {
+	var req feedRequest
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	if msg := req.validate(false); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, msg)
+		return
+	}
+	if req.Title != nil {
+		t := strings.TrimSpace(*req.Title)
+		req.Title = &t
+	}
+
+	f, err := h.db.UpdateFeed(r.Context(), chi.URLParam(r, "slug"), database.FeedPatch{
+		Title:        req.Title,
+		Description:  req.Description,
+		SystemID:     req.SystemID,
+		Tgids:        req.Tgids,
+		DelaySeconds: req.DelaySeconds,
+		MaxItems:     req.MaxItems,
+	})
+	if err != nil {
+		if err.Error() == "feed not found" {
+			WriteError(w, http.StatusNotFound, "feed not found")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to update feed")
+		return
+	}
+	WriteJSON(w, http.StatusOK, f)
+}
+
+// DeleteFeed removes a feed.
+func (h *FeedsHandler) DeleteFeed(w http.ResponseWriter, r *http.Request) {
+	if err := h.db.DeleteFeed(r.Context(), chi.URLParam(r, "slug")); err != nil {
+		if err.Error() == "feed not found" {
+			WriteError(w, http.StatusNotFound, "feed not found")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to delete feed")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RenderFeed serves a feed as JSON Feed, RSS, or Atom depending on the URL extension.
+func (h *FeedsHandler) RenderFeed(w http.ResponseWriter, r *http.Request) {
+	format := chi.URLParam(r, "format")
+	if format != "json" && format != "rss" && format != "atom" {
+		WriteError(w, http.StatusNotFound, "unknown feed format")
+		return
+	}
+
+	f, err := h.db.GetFeed(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		if err.Error() == "feed not found" {
+			WriteError(w, http.StatusNotFound, "feed not found")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to get feed")
+		return
+	}
+
+	cutoff := time.Now().Add(-time.Duration(f.DelaySeconds) * time.Second)
+	items, err := h.db.ListFeedItems(r.Context(), f, cutoff)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list feed items")
+		return
+	}
+
+	updated := f.UpdatedAt
+	if len(items) > 0 && items[0].StartTime.After(updated) {
+		updated = items[0].StartTime
+	}
+	etag := feedETag(f, items, format)
+	h.setCacheHeaders(w, etag, updated)
+	if match := r.Header.Get("If-None-Match"); match != "" && match == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	b := feedBuilder{feed: f, items: items, base: h.base(r), updated: updated, ttl: h.maxAge}
+	switch format {
+	case "json":
+		WriteJSON(w, http.StatusOK, b.jsonFeed())
+	case "rss":
+		writeFeedXML(w, "application/rss+xml; charset=utf-8", b.rss())
+	case "atom":
+		writeFeedXML(w, "application/atom+xml; charset=utf-8", b.atom())
+	}
+}
+
+// FeedAudio serves audio for a call published in the feed. Calls outside the
+// feed's talkgroups or still inside its delay window are reported as not found.
+func (h *FeedsHandler) FeedAudio(w http.ResponseWriter, r *http.Request) {
+	callID, err := PathInt64(r, "call_id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid call ID")
+		return
+	}
+	f, err := h.db.GetFeed(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		WriteError(w, http.StatusNotFound, "feed not found")
+		return
+	}
+	cutoff := time.Now().Add(-time.Duration(f.DelaySeconds) * time.Second)
+	ok, err := h.db.FeedIncludesCall(r.Context(), f, callID, cutoff)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to look up call")
+		return
+	}
+	if !ok {
+		WriteError(w, http.StatusNotFound, "audio not found")
+		return
+	}
+	// Recorded audio never changes once published.
+	w.Header().Set("Cache-Control", "public, max-age=86400, immutable")
+	h.calls.serveCallAudio(w, r, database.CallRef{CallID: callID})
+}
+
+func (h *FeedsHandler) setCacheHeaders(w http.ResponseWriter, etag string, updated time.Time) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.maxAge.Seconds())))
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", updated.UTC().Format(http.TimeFormat))
+}
+
+// base returns the absolute URL prefix for links in rendered feeds.
+func (h *FeedsHandler) base(r *http.Request) string {
+	if h.baseURL != "" {
+		return h.baseURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if p := r.Header.Get("X-Forwarded-Proto"); p != "" {
+		scheme = p
+	}
+	host := r.Host
+	if fh := r.Header.Get("X-Forwarded-Host"); fh != "" {
+		host = fh
+	}
+	return scheme + "://" + host
+}
+
+// feedETag identifies a rendered feed by its config version and newest item.
+func feedETag(f *database.Feed, items []database.FeedItem, format string) string {
+	var newest int64
+	if len(items) > 0 {
+		newest = items[0].CallID
+	}
+	return fmt.Sprintf(`W/"%s-%s-%d-%d-%d"`, f.Slug, format, f.UpdatedAt.Unix(), newest, len(items))
+}
+
+// feedSnippet shortens a transcript to at most feedSnippetLen runes, cutting at a word boundary.
+func feedSnippet(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= feedSnippetLen {
+		return s
+	}
+	cut := string([]rune(s)[:feedSnippetLen])
+	if i := strings.LastIndexByte(cut, ' '); i > feedSnippetLen/2 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
+
+// feedBuilder renders a feed and its items in each supported format.
+type feedBuilder struct {
+	feed    *database.Feed
+	items   []database.FeedItem
+	base    string
+	updated time.Time
+	ttl     time.Duration
+}
+
+func (b *feedBuilder) url(format string) string {
+	return fmt.Sprintf("%s/api/v1/feeds/%s.%s", b.base, b.feed.Slug, format)
+}
+
+func (b *feedBuilder) audioURL(it *database.FeedItem) string {
+	return fmt.Sprintf("%s/api/v1/feeds/%s/audio/%d", b.base, b.feed.Slug, it.CallID)
+}
+
+func (b *feedBuilder) itemID(it *database.FeedItem) string {
+	return fmt.Sprintf("urn:tr-engine:call:%d", it.CallID)
+}
+
+func (b *feedBuilder) itemTitle(it *database.FeedItem) string {
+	name := it.TgAlphaTag
+	if name == "" {
+		name = "TG " + strconv.Itoa(it.Tgid)
+	}
+	title := name + " — " + it.StartTime.UTC().Format("2006-01-02 15:04:05 MST")
+	if it.Emergency {
+		title = "EMERGENCY: " + title
+	}
+	return title
+}
+
+func itemMIMEType(it *database.FeedItem) string {
+	if ct, ok := audioContentTypes[strings.ToLower(filepath.Ext(it.AudioPath))]; ok {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+func itemSize(it *database.FeedItem) int {
+	if it.AudioFileSize != nil {
+		return *it.AudioFileSize
+	}
+	return 0
+}
+
+// JSON Feed 1.1 (https://jsonfeed.org/version/1.1). Call details go in the
+// "_tr_engine" extension object.
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	FeedURL     string         `json:"feed_url"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string               `json:"id"`
+	Title         string               `json:"title"`
+	ContentText   string               `json:"content_text"`
+	DatePublished string               `json:"date_published"`
+	Attachments   []jsonFeedAttachment `json:"attachments"`
+	Call          jsonFeedCall         `json:"_tr_engine"`
+}
+
+type jsonFeedAttachment struct {
+	URL               string   `json:"url"`
+	MIMEType          string   `json:"mime_type"`
+	SizeInBytes       int      `json:"size_in_bytes,omitempty"`
+	DurationInSeconds *float32 `json:"duration_in_seconds,omitempty"`
+}
+
+type jsonFeedCall struct {
+	CallID        int64  `json:"call_id"`
+	SystemID      int    `json:"system_id"`
+	SystemName    string `json:"system_name,omitempty"`
+	Tgid          int    `json:"tgid"`
+	TgAlphaTag    string `json:"tg_alpha_tag,omitempty"`
+	TgDescription string `json:"tg_description,omitempty"`
+	Emergency     bool   `json:"emergency"`
+}
+
+func (b *feedBuilder) jsonFeed() jsonFeed {
+	out := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       b.feed.Title,
+		Description: b.feed.Description,
+		FeedURL:     b.url("json"),
+		Items:       make([]jsonFeedItem, 0, len(b.items)),
+	}
+	for i := range b.items {
+		it := &b.items[i]
+		out.Items = append(out.Items, jsonFeedItem{
+			ID:            b.itemID(it),
+			Title:         b.itemTitle(it),
+			ContentText:   feedSnippet(it.Transcript),
+			DatePublished: it.StartTime.UTC().Format(time.RFC3339),
+			Attachments: []jsonFeedAttachment{{
+				URL:               b.audioURL(it),
+				MIMEType:          itemMIMEType(it),
+				SizeInBytes:       itemSize(it),
+				DurationInSeconds: it.Duration,
+			}},
+			Call: jsonFeedCall{
+				CallID:        it.CallID,
+				SystemID:      it.SystemID,
+				SystemName:    it.SystemName,
+				Tgid:          it.Tgid,
+				TgAlphaTag:    it.TgAlphaTag,
+				TgDescription: it.TgDescription,
+				Emergency:     it.Emergency,
+			},
+		})
+	}
+	return out
+}
+
+// RSS 2.0
+
+type rssDoc struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	TTL           int       `xml:"ttl,omitempty"`
+	Self          rssLink   `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string       `xml:"title"`
+	GUID        rssGUID      `xml:"guid"`
+	PubDate     string       `xml:"pubDate"`
+	Description string       `xml:"description,omitempty"`
+	Enclosure   rssEnclosure `xml:"enclosure"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int    `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+func (b *feedBuilder) rss() rssDoc {
+	desc := b.feed.Description
+	if desc == "" {
+		desc = b.feed.Title
+	}
+	ch := rssChannel{
+		Title:         b.feed.Title,
+		Link:          b.url("rss"),
+		Description:   desc,
+		LastBuildDate: b.updated.UTC().Format(time.RFC1123Z),
+		TTL:           int(b.ttl.Minutes()),
+		Self:          rssLink{Href: b.url("rss"), Rel: "self", Type: "application/rss+xml"},
+		Items:         make([]rssItem, 0, len(b.items)),
+	}
+	for i := range b.items {
+		it := &b.items[i]
+		ch.Items = append(ch.Items, rssItem{
+			Title:       b.itemTitle(it),
+			GUID:        rssGUID{Value: b.itemID(it)},
+			PubDate:     it.StartTime.UTC().Format(time.RFC1123Z),
+			Description: feedSnippet(it.Transcript),
+			Enclosure:   rssEnclosure{URL: b.audioURL(it), Length: itemSize(it), Type: itemMIMEType(it)},
+		})
+	}
+	return rssDoc{Version: "2.0", AtomNS: "http://www.w3.org/2005/Atom", Channel: ch}
+}
+
+// Atom (RFC 4287)
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length int    `xml:"length,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published"`
+	Summary   string     `xml:"summary,omitempty"`
+	Links     []atomLink `xml:"link"`
+}
+
+func (b *feedBuilder) atom() atomFeed {
+	out := atomFeed{
+		ID:       "urn:tr-engine:feed:" + b.feed.Slug,
+		Title:    b.feed.Title,
+		Subtitle: b.feed.Description,
+		Updated:  b.updated.UTC().Format(time.RFC3339),
+		Links:    []atomLink{{Href: b.url("atom"), Rel: "self", Type: "application/atom+xml"}},
+		Entries:  make([]atomEntry, 0, len(b.items)),
+	}
+	for i := range b.items {
+		it := &b.items[i]
+		ts := it.StartTime.UTC().Format(time.RFC3339)
+		out.Entries = append(out.Entries, atomEntry{
+			ID:        b.itemID(it),
+			Title:     b.itemTitle(it),
+			Updated:   ts,
+			Published: ts,
+			Summary:   feedSnippet(it.Transcript),
+			Links: []atomLink{{
+				Href:   b.audioURL(it),
+				Rel:    "enclosure",
+				Type:   itemMIMEType(it),
+				Length: itemSize(it),
+			}},
+		})
+	}
+	return out
+}
+
+func writeFeedXML(w http.ResponseWriter, contentType string, doc any) {
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to render feed")
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	w.Write(out)
+}
+
+// Routes registers feed management routes (authenticated).
+func (h *FeedsHandler) Routes(r chi.Router) {
+	r.Get("/admin/feeds", h.ListFeeds)
+	r.Post("/admin/feeds", h.CreateFeed)
+	r.Get("/admin/feeds/{slug}", h.GetFeed)
+	r.Patch("/admin/feeds/{slug}", h.UpdateFeed)
+	r.Delete("/admin/feeds/{slug}", h.DeleteFeed)
+}
+
+// PublicRoutes registers the rendered feed and enclosure routes. These are
+// mounted outside the authenticated group so feeds can be embedded and cached.
+func (h *FeedsHandler) PublicRoutes(r chi.Router) {
+	r.Get("/api/v1/feeds/{slug}.{format}", h.RenderFeed)
+	r.Get("/api/v1/feeds/{slug}/audio/{call_id}", h.FeedAudio)
+}
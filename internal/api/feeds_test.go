@@ -0,0 +1,154 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+func TestFeedRequestValidate(t *testing.T) {
+	str := func(s string) *string { return &s }
+	tests := []struct {
+		name   string
+		req    feedRequest
+		create bool
+		ok     bool
+	}{
+		{"valid_create", feedRequest{Slug: "fire-dispatch", Title: str("Fire"), Tgids: []int{9131}}, true, true},
+		{"bad_slug", feedRequest{Slug: "Fire.Dispatch", Title: str("Fire"), Tgids: []int{9131}}, true, false},
+		{"missing_tgids", feedRequest{Slug: "fire", Title: str("Fire")}, true, false},
+		{"missing_title", feedRequest{Slug: "fire", Tgids: []int{1}}, true, false},
+		{"bad_tgid", feedRequest{Slug: "fire", Title: str("Fire"), Tgids: []int{0}}, true, false},
+		{"negative_delay", feedRequest{DelaySeconds: intPtr(-1)}, false, false},
+		{"max_items_too_large", feedRequest{MaxItems: intPtr(501)}, false, false},
+		{"empty_patch", feedRequest{}, false, true},
+		{"blank_title_patch", feedRequest{Title: str("  ")}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := tt.req.validate(tt.create)
+			if (msg == "") != tt.ok {
+				t.Errorf("validate() = %q, want ok=%v", msg, tt.ok)
+			}
+		})
+	}
+}
+
+func TestFeedSnippet(t *testing.T) {
+	if got := feedSnippet("  engine 5\n respond  "); got != "engine 5 respond" {
+		t.Errorf("feedSnippet() = %q, want whitespace collapsed", got)
+	}
+	long := strings.Repeat("dispatch ", 100)
+	got := feedSnippet(long)
+	if n := utf8.RuneCountInString(got); n > feedSnippetLen+1 {
+		t.Errorf("snippet has %d runes, want <= %d", n, feedSnippetLen+1)
+	}
+	if !strings.HasSuffix(got, "dispatch…") {
+		t.Errorf("snippet %q should end on a word boundary with an ellipsis", got)
+	}
+}
+
+func testFeedBuilder() feedBuilder {
+	size := 48213
+	dur := float32(12.5)
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	return feedBuilder{
+		feed:    &database.Feed{Slug: "fire", Title: "Fire Dispatch", Tgids: []int{9131}, UpdatedAt: start.Add(-time.Hour)},
+		base:    "https://radio.example.com",
+		updated: start,
+		ttl:     time.Minute,
+		items: []database.FeedItem{{
+			CallID: 42, SystemID: 1, Tgid: 9131, TgAlphaTag: "Fire Dispatch", StartTime: start,
+			Duration: &dur, Emergency: true, AudioPath: "butco/2024-03-01/9131-1709294400.m4a",
+			AudioFileSize: &size, Transcript: "Engine 5 respond to <Main & 1st>",
+		}},
+	}
+}
+
+func TestFeedRSS(t *testing.T) {
+	b := testFeedBuilder()
+	out, err := xml.Marshal(b.rss())
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var doc struct {
+		Channel struct {
+			Items []struct {
+				Title       string `xml:"title"`
+				GUID        string `xml:"guid"`
+				Description string `xml:"description"`
+				Enclosure   struct {
+					URL    string `xml:"url,attr"`
+					Length int    `xml:"length,attr"`
+					Type   string `xml:"type,attr"`
+				} `xml:"enclosure"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("unmarshal: %v\n%s", err, out)
+	}
+	if len(doc.Channel.Items) != 1 {
+		t.Fatalf("got %d items, want 1", len(doc.Channel.Items))
+	}
+	it := doc.Channel.Items[0]
+	if it.Enclosure.URL != "https://radio.example.com/api/v1/feeds/fire/audio/42" {
+		t.Errorf("enclosure url = %q", it.Enclosure.URL)
+	}
+	if it.Enclosure.Type != "audio/mp4" || it.Enclosure.Length != 48213 {
+		t.Errorf("enclosure type/length = %q/%d, want audio/mp4/48213", it.Enclosure.Type, it.Enclosure.Length)
+	}
+	if it.Description != "Engine 5 respond to <Main & 1st>" {
+		t.Errorf("description = %q (transcript should round-trip through XML escaping)", it.Description)
+	}
+	if !strings.HasPrefix(it.Title, "EMERGENCY: Fire Dispatch") || it.GUID != "urn:tr-engine:call:42" {
+		t.Errorf("title/guid = %q/%q", it.Title, it.GUID)
+	}
+}
+
+func TestFeedAtom(t *testing.T) {
+	b := testFeedBuilder()
+	out, err := xml.Marshal(b.atom())
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !strings.Contains(string(out), `xmlns="http://www.w3.org/2005/Atom"`) {
+		t.Errorf("atom output missing namespace: %s", out)
+	}
+	if !strings.Contains(string(out), `rel="enclosure"`) {
+		t.Errorf("atom output missing enclosure link: %s", out)
+	}
+}
+
+func TestFeedJSON(t *testing.T) {
+	b := testFeedBuilder()
+	jf := b.jsonFeed()
+	if jf.FeedURL != "https://radio.example.com/api/v1/feeds/fire.json" {
+		t.Errorf("feed_url = %q", jf.FeedURL)
+	}
+	if len(jf.Items) != 1 || jf.Items[0].Attachments[0].DurationInSeconds == nil || jf.Items[0].Call.Tgid != 9131 {
+		t.Fatalf("unexpected items: %+v", jf.Items)
+	}
+}
+
+func TestFeedBaseURL(t *testing.T) {
+	h := NewFeedsHandler(nil, nil, "", time.Minute)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/feeds/fire.rss", nil)
+	req.Host = "internal:8080"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "radio.example.com")
+	if got := h.base(req); got != "https://radio.example.com" {
+		t.Errorf("base() = %q, want forwarded host", got)
+	}
+
+	h = NewFeedsHandler(nil, nil, "https://feeds.example.com/", time.Minute)
+	if got := h.base(req); got != "https://feeds.example.com" {
+		t.Errorf("base() = %q, want configured FEED_BASE_URL without trailing slash", got)
+	}
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Fieldset is a request's sparse fieldset: ?fields= lists the only fields to
+// return, ?exclude= lists fields to drop. Both are comma-separated JSON field
+// names. For list responses ({"calls": [...], "total": N}) they apply to each
+// item; otherwise to the top-level object.
+type Fieldset struct {
+	include map[string]bool // nil = all fields
+	exclude map[string]bool
+}
+
+// ParseFieldset reads ?fields= and ?exclude= from r. Returns an error message
+// naming the first invalid field name.
+func ParseFieldset(r *http.Request) (Fieldset, string) {
+	var fs Fieldset
+	var bad string
+	fs.include, bad = parseFieldNames(r.URL.Query().Get("fields"))
+	if bad != "" {
+		return Fieldset{}, "invalid field name in fields: " + bad
+	}
+	fs.exclude, bad = parseFieldNames(r.URL.Query().Get("exclude"))
+	if bad != "" {
+		return Fieldset{}, "invalid field name in exclude: " + bad
+	}
+	return fs, ""
+}
+
+// parseFieldNames splits a comma-separated field list. Returns nil for an
+// empty list, or the offending name if one isn't [a-z0-9_].
+func parseFieldNames(s string) (map[string]bool, string) {
+	if s == "" {
+		return nil, ""
+	}
+	names := make(map[string]bool)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		for _, c := range name {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_') {
+				return nil, name
+			}
+		}
+		names[name] = true
+	}
+	if len(names) == 0 {
+		return nil, ""
+	}
+	return names, ""
+}
+
+// Empty reports whether the fieldset leaves responses unchanged.
+func (fs Fieldset) Empty() bool {
+	return fs.include == nil && len(fs.exclude) == 0
+}
+
+// Wants reports whether field is returned.
+func (fs Fieldset) Wants(field string) bool {
+	if fs.exclude[field] {
+		return false
+	}
+	return fs.include == nil || fs.include[field]
+}
+
+// Omitted returns the candidates that are not returned, or nil if all are.
+// Handlers use it to skip selecting heavy columns.
+func (fs Fieldset) Omitted(candidates []string) map[string]bool {
+	var omit map[string]bool
+	for _, f := range candidates {
+		if !fs.Wants(f) {
+			if omit == nil {
+				omit = make(map[string]bool)
+			}
+			omit[f] = true
+		}
+	}
+	return omit
+}
+
+// filter applies the fieldset to a JSON response body. Bodies that aren't a
+// JSON object are returned unchanged.
+func (fs Fieldset) filter(body []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var obj map[string]any
+	if err := dec.Decode(&obj); err != nil {
+		return body, nil
+	}
+
+	if _, isList := obj["total"]; isList {
+		// List envelope: filter the items of each array of objects.
+		for _, v := range obj {
+			items, ok := v.([]any)
+			if !ok {
+				continue
+			}
+			for _, item := range items {
+				if m, ok := item.(map[string]any); ok {
+					fs.filterObject(m)
+				}
+			}
+		}
+	} else {
+		fs.filterObject(obj)
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(obj); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (fs Fieldset) filterObject(m map[string]any) {
+	for k := range m {
+		if !fs.Wants(k) {
+			delete(m, k)
+		}
+	}
+}
+
+type fieldsetKey struct{}
+
+// RequestFieldset returns the fieldset SparseFields parsed for r.
+func RequestFieldset(r *http.Request) Fieldset {
+	fs, _ := r.Context().Value(fieldsetKey{}).(Fieldset)
+	return fs
+}
+
+// SparseFields applies ?fields= and ?exclude= to successful JSON responses.
+// The parsed fieldset is stored in the request context so handlers can also
+// leave unrequested columns out of their queries (see RequestFieldset).
+// Non-JSON responses (audio, SSE) and errors pass through untouched.
+func SparseFields(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.Method != http.MethodGet || (!q.Has("fields") && !q.Has("exclude")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		fs, msg := ParseFieldset(r)
+		if msg != "" {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, msg)
+			return
+		}
+		if fs.Empty() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &fieldsRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), fieldsetKey{}, fs)))
+		if !rec.buffering {
+			return
+		}
+		body, err := fs.filter(rec.body.Bytes())
+		if err != nil {
+			body = rec.body.Bytes()
+		}
+		w.Header().Del("Content-Length")
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	})
+}
+
+// fieldsRecorder holds back a 200 JSON response so it can be filtered;
+// anything else is written straight through.
+type fieldsRecorder struct {
+	http.ResponseWriter
+	wroteHeader bool
+	buffering   bool
+	body        bytes.Buffer
+}
+
+func (r *fieldsRecorder) WriteHeader(code int) {
+	if r.wroteHeader {
+		return
+	}
+	r.wroteHeader = true
+	if code == http.StatusOK && strings.HasPrefix(r.Header().Get("Content-Type"), "application/json") {
+		r.buffering = true
+		return
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *fieldsRecorder) Write(b []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	if r.buffering {
+		return r.body.Write(b)
+	}
+	return r.ResponseWriter.Write(b)
+}
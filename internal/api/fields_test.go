@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSparseFields(t *testing.T) {
+	list := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, map[string]any{
+			"calls": []map[string]any{
+				{"call_id": 1, "tgid": 9131, "src_list": []int{1, 2}, "unit_ids": []int{5}},
+				{"call_id": 2, "tgid": 9132, "src_list": []int{3}},
+			},
+			"total": 2,
+		})
+	})
+	single := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, map[string]any{"call_id": 1, "tgid": 9131, "src_list": []int{1}})
+	})
+
+	serve := func(h http.Handler, url string) (*httptest.ResponseRecorder, map[string]any) {
+		rec := httptest.NewRecorder()
+		SparseFields(h).ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+		var body map[string]any
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		return rec, body
+	}
+	keys := func(m any) []string {
+		var k []string
+		for key := range m.(map[string]any) {
+			k = append(k, key)
+		}
+		sort.Strings(k)
+		return k
+	}
+
+	t.Run("fields_on_list_items", func(t *testing.T) {
+		_, body := serve(list, "/calls?fields=call_id,tgid")
+		items := body["calls"].([]any)
+		for _, item := range items {
+			if got := keys(item); !reflect.DeepEqual(got, []string{"call_id", "tgid"}) {
+				t.Errorf("item keys = %v", got)
+			}
+		}
+		if body["total"] != float64(2) {
+			t.Errorf("total = %v, want envelope kept", body["total"])
+		}
+	})
+
+	t.Run("exclude_on_list_items", func(t *testing.T) {
+		_, body := serve(list, "/calls?exclude=src_list,unit_ids")
+		for _, item := range body["calls"].([]any) {
+			if got := keys(item); !reflect.DeepEqual(got, []string{"call_id", "tgid"}) {
+				t.Errorf("item keys = %v", got)
+			}
+		}
+	})
+
+	t.Run("fields_on_object", func(t *testing.T) {
+		_, body := serve(single, "/calls/1?fields=tgid")
+		if got := keys(body); !reflect.DeepEqual(got, []string{"tgid"}) {
+			t.Errorf("keys = %v", got)
+		}
+	})
+
+	t.Run("invalid_name", func(t *testing.T) {
+		rec, _ := serve(list, "/calls?fields=call_id,Bad-Name")
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", rec.Code)
+		}
+	})
+
+	t.Run("errors_pass_through", func(t *testing.T) {
+		notFound := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			WriteError(w, http.StatusNotFound, "call not found")
+		})
+		rec, body := serve(notFound, "/calls/1?fields=tgid")
+		if rec.Code != http.StatusNotFound || body["error"] != "call not found" {
+			t.Errorf("got %d %v", rec.Code, body)
+		}
+	})
+
+	t.Run("non_json_pass_through", func(t *testing.T) {
+		audio := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "audio/mp4")
+			w.Write([]byte("m4a"))
+		})
+		rec, _ := serve(audio, "/calls/1/audio?fields=tgid")
+		if rec.Body.String() != "m4a" {
+			t.Errorf("body = %q", rec.Body.String())
+		}
+	})
+
+	t.Run("context_fieldset", func(t *testing.T) {
+		var omit map[string]bool
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			omit = RequestFieldset(r).Omitted([]string{"src_list", "unit_ids", "tgid"})
+			WriteJSON(w, http.StatusOK, map[string]any{})
+		})
+		serve(h, "/calls?fields=tgid,unit_ids")
+		if !reflect.DeepEqual(omit, map[string]bool{"src_list": true}) {
+			t.Errorf("omitted = %v", omit)
+		}
+	})
+}
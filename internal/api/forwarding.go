@@ -0,0 +1,160 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/forward"
+)
+
+type ForwardingHandler struct {
+	db        *database.DB
+	forwarder *forward.Forwarder // nil when FORWARD_URL is unset
+}
+
+func NewForwardingHandler(db *database.DB, forwarder *forward.Forwarder) *ForwardingHandler {
+	return &ForwardingHandler{db: db, forwarder: forwarder}
+}
+
+func (h *ForwardingHandler) available(w http.ResponseWriter) bool {
+	if h.forwarder == nil {
+		WriteError(w, http.StatusServiceUnavailable, "call forwarding not enabled (set FORWARD_URL)")
+		return false
+	}
+	return true
+}
+
+// GetForwardingStatus reports how many calls have reached the downstream
+// rdio-scanner, how many are pending, failing or rejected, the configured
+// systems and run progress.
+func (h *ForwardingHandler) GetForwardingStatus(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	status, err := h.forwarder.Status(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get forwarding status")
+		return
+	}
+	WriteJSON(w, http.StatusOK, status)
+}
+
+// ListForwardSystems returns the per-system forwarding settings. Systems
+// without a row are not forwarded.
+func (h *ForwardingHandler) ListForwardSystems(w http.ResponseWriter, r *http.Request) {
+	systems, err := h.db.ListForwardSystems(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list forwarding systems")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"systems": systems,
+		"total":   len(systems),
+	})
+}
+
+// PutForwardSystem enables or disables forwarding for a system.
+// Body: {"enabled": true, "remote_system": 12}. remote_system is the
+// system ID on the downstream (default: the same system_id). Only calls
+// that start after forwarding is enabled are sent.
+func (h *ForwardingHandler) PutForwardSystem(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid system ID")
+		return
+	}
+	var req struct {
+		Enabled      *bool `json:"enabled"`
+		RemoteSystem *int  `json:"remote_system"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	if req.RemoteSystem != nil && *req.RemoteSystem < 1 {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "remote_system must be positive")
+		return
+	}
+	enabled := req.Enabled == nil || *req.Enabled
+
+	fs, err := h.db.UpsertForwardSystem(r.Context(), id, enabled, req.RemoteSystem)
+	if err != nil {
+		if err.Error() == "system not found" {
+			WriteError(w, http.StatusNotFound, "system not found")
+		} else {
+			WriteError(w, http.StatusInternalServerError, "failed to save forwarding system")
+		}
+		return
+	}
+	WriteJSON(w, http.StatusOK, fs)
+}
+
+// DeleteForwardSystem stops forwarding a system and removes its settings.
+// Calls already queued for it are no longer sent.
+func (h *ForwardingHandler) DeleteForwardSystem(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid system ID")
+		return
+	}
+	found, err := h.db.DeleteForwardSystem(r.Context(), id)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to delete forwarding system")
+		return
+	}
+	if !found {
+		WriteError(w, http.StatusNotFound, "forwarding system not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListForwardFailures returns calls whose forwarding is failing or was
+// rejected by the downstream.
+func (h *ForwardingHandler) ListForwardFailures(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	limit := 100
+	if v, ok := QueryInt(r, "limit"); ok {
+		if v < 1 || v > 1000 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "limit must be between 1 and 1000")
+			return
+		}
+		limit = v
+	}
+	failures, err := h.db.ListForwardFailures(r.Context(), limit)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list forwarding failures")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"failures": failures,
+		"total":    len(failures),
+	})
+}
+
+// ResetForwardFailures makes failing and rejected calls eligible again,
+// e.g. after fixing FORWARD_API_KEY or adding talkgroups downstream.
+func (h *ForwardingHandler) ResetForwardFailures(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	n, err := h.db.ResetForwardFailures(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to reset forwarding failures")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"reset": n})
+}
+
+func (h *ForwardingHandler) Routes(r chi.Router) {
+	r.Get("/admin/forwarding", h.GetForwardingStatus)
+	r.Get("/admin/forwarding/systems", h.ListForwardSystems)
+	r.Put("/admin/forwarding/systems/{id}", h.PutForwardSystem)
+	r.Delete("/admin/forwarding/systems/{id}", h.DeleteForwardSystem)
+	r.Get("/admin/forwarding/failures", h.ListForwardFailures)
+	r.Post("/admin/forwarding/failures/reset", h.ResetForwardFailures)
+}
@@ -0,0 +1,461 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// GeoHandler serves site/system geodata management and map-ready GeoJSON layers.
+type GeoHandler struct {
+	db   *database.DB
+	live LiveDataSource
+}
+
+func NewGeoHandler(db *database.DB, live LiveDataSource) *GeoHandler {
+	return &GeoHandler{db: db, live: live}
+}
+
+// GeoJSON types. Geometry and properties are kept loose so uploaded
+// boundaries round-trip without loss.
+type geoFeature struct {
+	Type       string          `json:"type"`
+	Geometry   json.RawMessage `json:"geometry"`
+	Properties map[string]any  `json:"properties"`
+}
+
+type geoFeatureCollection struct {
+	Type     string       `json:"type"`
+	Features []geoFeature `json:"features"`
+}
+
+func newFeatureCollection() geoFeatureCollection {
+	return geoFeatureCollection{Type: "FeatureCollection", Features: []geoFeature{}}
+}
+
+// pointFeature builds a GeoJSON Point feature. GeoJSON coordinate order is [lon, lat].
+func pointFeature(lat, lon float64, props map[string]any) geoFeature {
+	geom, _ := json.Marshal(map[string]any{
+		"type":        "Point",
+		"coordinates": []float64{lon, lat},
+	})
+	return geoFeature{Type: "Feature", Geometry: geom, Properties: props}
+}
+
+// geometryTypes lists GeoJSON geometry types accepted for system boundaries.
+var geometryTypes = map[string]bool{
+	"Polygon":         true,
+	"MultiPolygon":    true,
+	"LineString":      true,
+	"MultiLineString": true,
+	"Point":           true,
+	"MultiPoint":      true,
+}
+
+// normalizeBoundary validates an uploaded GeoJSON document and returns it as a
+// FeatureCollection. Bare geometries and single Features are wrapped.
+func normalizeBoundary(raw []byte) (geoFeatureCollection, error) {
+	var head struct {
+		Type     string            `json:"type"`
+		Features []json.RawMessage `json:"features"`
+	}
+	if err := json.Unmarshal(raw, &head); err != nil {
+		return geoFeatureCollection{}, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	fc := newFeatureCollection()
+	switch {
+	case head.Type == "FeatureCollection":
+		for i, f := range head.Features {
+			feat, err := parseFeature(f)
+			if err != nil {
+				return geoFeatureCollection{}, fmt.Errorf("feature %d: %w", i, err)
+			}
+			fc.Features = append(fc.Features, feat)
+		}
+	case head.Type == "Feature":
+		feat, err := parseFeature(raw)
+		if err != nil {
+			return geoFeatureCollection{}, err
+		}
+		fc.Features = append(fc.Features, feat)
+	case geometryTypes[head.Type]:
+		if err := validateGeometry(raw); err != nil {
+			return geoFeatureCollection{}, err
+		}
+		fc.Features = append(fc.Features, geoFeature{Type: "Feature", Geometry: raw, Properties: map[string]any{}})
+	default:
+		return geoFeatureCollection{}, fmt.Errorf("unsupported GeoJSON type %q", head.Type)
+	}
+
+	if len(fc.Features) == 0 {
+		return geoFeatureCollection{}, fmt.Errorf("GeoJSON contains no features")
+	}
+	return fc, nil
+}
+
+func parseFeature(raw []byte) (geoFeature, error) {
+	var f geoFeature
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return geoFeature{}, fmt.Errorf("invalid feature: %w", err)
+	}
+	if f.Type != "Feature" {
+		return geoFeature{}, fmt.Errorf("expected type Feature, got %q", f.Type)
+	}
+	if err := validateGeometry(f.Geometry); err != nil {
+		return geoFeature{}, err
+	}
+	if f.Properties == nil {
+		f.Properties = map[string]any{}
+	}
+	return f, nil
+}
+
+func validateGeometry(raw json.RawMessage) error {
+	var g struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return fmt.Errorf("feature has no geometry")
+	}
+	if err := json.Unmarshal(raw, &g); err != nil {
+		return fmt.Errorf("invalid geometry: %w", err)
+	}
+	if !geometryTypes[g.Type] {
+		return fmt.Errorf("unsupported geometry type %q", g.Type)
+	}
+	if len(g.Coordinates) == 0 {
+		return fmt.Errorf("%s geometry has no coordinates", g.Type)
+	}
+	return nil
+}
+
+// writeGeoJSON writes a FeatureCollection with the GeoJSON media type.
+func writeGeoJSON(w http.ResponseWriter, fc geoFeatureCollection) {
+	w.Header().Set("Content-Type", "application/geo+json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(fc)
+}
+
+// UpdateSiteLocation sets a site's coordinates and range rings.
+func (h *GeoHandler) UpdateSiteLocation(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid site ID")
+		return
+	}
+
+	var req struct {
+		Latitude     *float64  `json:"latitude"`
+		Longitude    *float64  `json:"longitude"`
+		RangeRingsKm []float32 `json:"range_rings_km"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	if (req.Latitude == nil) != (req.Longitude == nil) {
+		WriteError(w, http.StatusBadRequest, "latitude and longitude must be set together")
+		return
+	}
+	if req.Latitude != nil && (*req.Latitude < -90 || *req.Latitude > 90) {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "latitude must be between -90 and 90")
+		return
+	}
+	if req.Longitude != nil && (*req.Longitude < -180 || *req.Longitude > 180) {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "longitude must be between -180 and 180")
+		return
+	}
+	for _, km := range req.RangeRingsKm {
+		if km <= 0 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "range_rings_km values must be positive")
+			return
+		}
+	}
+
+	if err := h.db.UpdateSiteLocation(r.Context(), id, req.Latitude, req.Longitude, req.RangeRingsKm); err != nil {
+		if err.Error() == "site not found" {
+			WriteError(w, http.StatusNotFound, "site not found")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to update site location")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"site_id":        id,
+		"latitude":       req.Latitude,
+		"longitude":      req.Longitude,
+		"range_rings_km": req.RangeRingsKm,
+	})
+}
+
+// PutSystemBoundary uploads GeoJSON coverage geometry (e.g. county polygons) for a system.
+func (h *GeoHandler) PutSystemBoundary(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid system ID")
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "failed to read request body")
+		return
+	}
+	fc, err := normalizeBoundary(body)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, err.Error())
+		return
+	}
+	stored, _ := json.Marshal(fc)
+	if err := h.db.SetSystemBoundary(r.Context(), id, stored); err != nil {
+		if err.Error() == "system not found" {
+			WriteError(w, http.StatusNotFound, "system not found")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to store boundary")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"system_id": id,
+		"features":  len(fc.Features),
+	})
+}
+
+// DeleteSystemBoundary removes a system's coverage geometry.
+func (h *GeoHandler) DeleteSystemBoundary(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid system ID")
+		return
+	}
+	if err := h.db.SetSystemBoundary(r.Context(), id, nil); err != nil {
+		if err.Error() == "system not found" {
+			WriteError(w, http.StatusNotFound, "system not found")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to delete boundary")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MapSites returns placed sites as Point features with range ring radii in properties.
+func (h *GeoHandler) MapSites(w http.ResponseWriter, r *http.Request) {
+	sites, err := h.db.ListSiteLocations(r.Context(), QueryIntList(r, "systems"))
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list site locations")
+		return
+	}
+	writeGeoJSON(w, siteFeatures(sites))
+}
+
+func siteFeatures(sites []database.SiteLocation) geoFeatureCollection {
+	fc := newFeatureCollection()
+	for _, s := range sites {
+		if s.Latitude == nil || s.Longitude == nil {
+			continue
+		}
+		rings := s.RangeRingsKm
+		if rings == nil {
+			rings = []float32{}
+		}
+		fc.Features = append(fc.Features, pointFeature(*s.Latitude, *s.Longitude, map[string]any{
+			"kind":           "site",
+			"site_id":        s.SiteID,
+			"system_id":      s.SystemID,
+			"system_name":    s.SystemName,
+			"short_name":     s.ShortName,
+			"instance_id":    s.InstanceID,
+			"nac":            s.Nac,
+			"range_rings_km": rings,
+		}))
+	}
+	return fc
+}
+
+// MapBoundaries returns all uploaded system boundaries merged into one collection.
+// Each feature is tagged with its owning system_id.
+func (h *GeoHandler) MapBoundaries(w http.ResponseWriter, r *http.Request) {
+	boundaries, err := h.db.ListSystemBoundaries(r.Context(), QueryIntList(r, "systems"))
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list boundaries")
+		return
+	}
+	fc := newFeatureCollection()
+	for _, b := range boundaries {
+		var stored geoFeatureCollection
+		if err := json.Unmarshal(b.GeoJSON, &stored); err != nil {
+			continue
+		}
+		for _, f := range stored.Features {
+			if f.Properties == nil {
+				f.Properties = map[string]any{}
+			}
+			f.Properties["kind"] = "boundary"
+			f.Properties["system_id"] = b.SystemID
+			f.Properties["system_name"] = b.Name
+			fc.Features = append(fc.Features, f)
+		}
+	}
+	writeGeoJSON(w, fc)
+}
+
+// MapUnits returns recently active units positioned at the site that last heard them.
+func (h *GeoHandler) MapUnits(w http.ResponseWriter, r *http.Request) {
+	activeWithin := 60
+	if v, ok := QueryInt(r, "active_within"); ok {
+		if v < 1 || v > 1440 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "active_within must be between 1 and 1440 minutes")
+			return
+		}
+		activeWithin = v
+	}
+	units, err := h.db.ListUnitLocations(r.Context(), QueryIntList(r, "systems"), activeWithin)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list unit locations")
+		return
+	}
+	fc := newFeatureCollection()
+	for _, u := range units {
+		props := map[string]any{
+			"kind":            "unit",
+			"system_id":       u.SystemID,
+			"unit_id":         u.UnitID,
+			"alpha_tag":       u.AlphaTag,
+			"site_id":         u.SiteID,
+			"site_short_name": u.SiteShortName,
+			"event_type":      u.EventType,
+			"time":            u.Time,
+		}
+		if u.Tgid != nil {
+			props["tgid"] = *u.Tgid
+			props["tg_alpha_tag"] = u.TgAlphaTag
+		}
+		fc.Features = append(fc.Features, pointFeature(u.Latitude, u.Longitude, props))
+	}
+	writeGeoJSON(w, fc)
+}
+
+// MapActiveCalls returns in-progress calls positioned at their recording site.
+// Restricted calls (encryption policy or embargo) are omitted for non-admins.
+func (h *GeoHandler) MapActiveCalls(w http.ResponseWriter, r *http.Request) {
+	fc := newFeatureCollection()
+	if h.live == nil {
+		writeGeoJSON(w, fc)
+		return
+	}
+	sites, err := h.db.ListSiteLocations(r.Context(), nil)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list site locations")
+		return
+	}
+	writeGeoJSON(w, activeCallFeatures(h.live.ActiveCalls(), sites, QueryIntList(r, "systems"), isAdmin(r)))
+}
+
+func activeCallFeatures(calls []ActiveCallData, sites []database.SiteLocation, systems []int, admin bool) geoFeatureCollection {
+	byID := make(map[int]database.SiteLocation, len(sites))
+	for _, s := range sites {
+		if s.Latitude != nil && s.Longitude != nil {
+			byID[s.SiteID] = s
+		}
+	}
+	fc := newFeatureCollection()
+	for _, c := range calls {
+		if c.Restricted && !admin {
+			continue
+		}
+		if len(systems) > 0 && !intSliceContains(systems, c.SystemID) {
+			continue
+		}
+		if c.SiteID == nil {
+			continue
+		}
+		s, ok := byID[*c.SiteID]
+		if !ok {
+			continue
+		}
+		fc.Features = append(fc.Features, pointFeature(*s.Latitude, *s.Longitude, map[string]any{
+			"kind":            "call",
+			"call_id":         c.CallID,
+			"system_id":       c.SystemID,
+			"site_id":         s.SiteID,
+			"site_short_name": s.ShortName,
+			"tgid":            c.Tgid,
+			"tg_alpha_tag":    c.TgAlphaTag,
+			"start_time":      c.StartTime,
+			"emergency":       c.Emergency,
+			"encrypted":       c.Encrypted,
+		}))
+	}
+	return fc
+}
+
+// MapConfig returns the map viewport derived from placed sites plus the layer URLs.
+func (h *GeoHandler) MapConfig(w http.ResponseWriter, r *http.Request) {
+	sites, err := h.db.ListSiteLocations(r.Context(), nil)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list site locations")
+		return
+	}
+	resp := mapConfig(sites)
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+type mapConfigResponse struct {
+	Center        *[2]float64       `json:"center"` // [lon, lat]
+	Bounds        *[4]float64       `json:"bounds"` // [min_lon, min_lat, max_lon, max_lat]
+	PlacedSites   int               `json:"placed_sites"`
+	UnplacedSites []int             `json:"unplaced_sites"`
+	Layers        map[string]string `json:"layers"`
+}
+
+func mapConfig(sites []database.SiteLocation) mapConfigResponse {
+	resp := mapConfigResponse{
+		UnplacedSites: []int{},
+		Layers: map[string]string{
+			"sites":        "/api/v1/map/sites",
+			"boundaries":   "/api/v1/map/boundaries",
+			"units":        "/api/v1/map/units",
+			"active_calls": "/api/v1/map/calls/active",
+		},
+	}
+	var minLon, minLat, maxLon, maxLat float64
+	for _, s := range sites {
+		if s.Latitude == nil || s.Longitude == nil {
+			resp.UnplacedSites = append(resp.UnplacedSites, s.SiteID)
+			continue
+		}
+		lat, lon := *s.Latitude, *s.Longitude
+		if resp.PlacedSites == 0 {
+			minLon, maxLon, minLat, maxLat = lon, lon, lat, lat
+		} else {
+			minLon = min(minLon, lon)
+			maxLon = max(maxLon, lon)
+			minLat = min(minLat, lat)
+			maxLat = max(maxLat, lat)
+		}
+		resp.PlacedSites++
+	}
+	if resp.PlacedSites > 0 {
+		resp.Bounds = &[4]float64{minLon, minLat, maxLon, maxLat}
+		resp.Center = &[2]float64{(minLon + maxLon) / 2, (minLat + maxLat) / 2}
+	}
+	return resp
+}
+
+// Routes registers geodata and map routes on the given router.
+func (h *GeoHandler) Routes(r chi.Router) {
+	r.Put("/sites/{id}/location", h.UpdateSiteLocation)
+	r.Put("/systems/{id}/boundary", h.PutSystemBoundary)
+	r.Delete("/systems/{id}/boundary", h.DeleteSystemBoundary)
+	r.Get("/map/config", h.MapConfig)
+	r.Get("/map/sites", h.MapSites)
+	r.Get("/map/boundaries", h.MapBoundaries)
+	r.Get("/map/units", h.MapUnits)
+	r.Get("/map/calls/active", h.MapActiveCalls)
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+func TestNormalizeBoundary(t *testing.T) {
+	t.Run("wraps_bare_polygon", func(t *testing.T) {
+		fc, err := normalizeBoundary([]byte(`{"type":"Polygon","coordinates":[[[-84.5,39.3],[-84.2,39.3],[-84.2,39.5],[-84.5,39.3]]]}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if fc.Type != "FeatureCollection" || len(fc.Features) != 1 {
+			t.Fatalf("got type=%q features=%d, want FeatureCollection with 1 feature", fc.Type, len(fc.Features))
+		}
+		if fc.Features[0].Properties == nil {
+			t.Error("wrapped feature should have non-nil properties")
+		}
+	})
+
+	t.Run("keeps_feature_collection_properties", func(t *testing.T) {
+		fc, err := normalizeBoundary([]byte(`{"type":"FeatureCollection","features":[
+			{"type":"Feature","properties":{"county":"Butler"},"geometry":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,0]]]}},
+			{"type":"Feature","geometry":{"type":"MultiPolygon","coordinates":[[[[0,0],[1,0],[1,1],[0,0]]]]}}
+		]}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(fc.Features) != 2 {
+			t.Fatalf("got %d features, want 2", len(fc.Features))
+		}
+		if fc.Features[0].Properties["county"] != "Butler" {
+			t.Errorf("county property = %v, want Butler", fc.Features[0].Properties["county"])
+		}
+	})
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{"invalid_json", `{not json`, "invalid JSON"},
+		{"unknown_type", `{"type":"Circle"}`, "unsupported GeoJSON type"},
+		{"empty_collection", `{"type":"FeatureCollection","features":[]}`, "no features"},
+		{"feature_without_geometry", `{"type":"Feature","properties":{}}`, "no geometry"},
+		{"geometry_without_coordinates", `{"type":"Polygon"}`, "no coordinates"},
+		{"bad_nested_geometry", `{"type":"FeatureCollection","features":[{"type":"Feature","geometry":{"type":"GeometryCollection","coordinates":[]}}]}`, "feature 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := normalizeBoundary([]byte(tt.input))
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want containing %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func floatPtr(v float64) *float64 { return &v }
+
+func TestMapConfig(t *testing.T) {
+	sites := []database.SiteLocation{
+		{SiteID: 1, Latitude: floatPtr(39.4), Longitude: floatPtr(-84.5)},
+		{SiteID: 2, Latitude: floatPtr(39.2), Longitude: floatPtr(-84.1)},
+		{SiteID: 3},
+	}
+	cfg := mapConfig(sites)
+
+	if cfg.PlacedSites != 2 {
+		t.Errorf("placed_sites = %d, want 2", cfg.PlacedSites)
+	}
+	if len(cfg.UnplacedSites) != 1 || cfg.UnplacedSites[0] != 3 {
+		t.Errorf("unplaced_sites = %v, want [3]", cfg.UnplacedSites)
+	}
+	if cfg.Bounds == nil || *cfg.Bounds != [4]float64{-84.5, 39.2, -84.1, 39.4} {
+		t.Errorf("bounds = %v, want [-84.5 39.2 -84.1 39.4]", cfg.Bounds)
+	}
+	if cfg.Center == nil || (*cfg.Center)[0] != -84.3 {
+		t.Errorf("center = %v, want lon -84.3", cfg.Center)
+	}
+
+	empty := mapConfig(nil)
+	if empty.Center != nil || empty.Bounds != nil {
+		t.Error("center/bounds should be nil when no sites are placed")
+	}
+}
+
+func TestActiveCallFeatures(t *testing.T) {
+	site1, site2 := 1, 2
+	sites := []database.SiteLocation{
+		{SiteID: 1, ShortName: "butco", Latitude: floatPtr(39.4), Longitude: floatPtr(-84.5)},
+		{SiteID: 2, ShortName: "warco"}, // unplaced
+	}
+	calls := []ActiveCallData{
+		{CallID: 10, SystemID: 1, SiteID: &site1, Tgid: 100, StartTime: time.Now()},
+		{CallID: 11, SystemID: 1, SiteID: &site2, Tgid: 200, StartTime: time.Now()},
+		{CallID: 12, SystemID: 1, Tgid: 300, StartTime: time.Now()},
+		{CallID: 13, SystemID: 2, SiteID: &site1, Tgid: 400, StartTime: time.Now()},
+		{CallID: 14, SystemID: 1, SiteID: &site1, Tgid: 500, StartTime: time.Now(), Restricted: true}, // embargoed
+	}
+
+	fc := activeCallFeatures(calls, sites, nil, false)
+	if len(fc.Features) != 2 {
+		t.Fatalf("got %d features, want 2 (only calls at placed sites)", len(fc.Features))
+	}
+
+	fc = activeCallFeatures(calls, sites, []int{2}, false)
+	if len(fc.Features) != 1 || fc.Features[0].Properties["call_id"] != int64(13) {
+		t.Errorf("system filter: got %d features, want call 13 only", len(fc.Features))
+	}
+
+	fc = activeCallFeatures(calls, sites, nil, true)
+	if len(fc.Features) != 3 || fc.Features[2].Properties["call_id"] != int64(14) {
+		t.Errorf("admin: got %d features, want the restricted call too", len(fc.Features))
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"net/http"
 	"runtime"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -14,6 +13,8 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/snarg/tr-engine/internal/database"
 	"github.com/snarg/tr-engine/internal/mqttclient"
+	"github.com/snarg/tr-engine/internal/rtcaudio"
+	"github.com/snarg/tr-engine/internal/selfupdate"
 )
 
 type HealthResponse struct {
@@ -49,7 +50,8 @@ type HealthHandler struct {
 	db            *database.DB
 	mqtt          *mqttclient.Client
 	live          LiveDataSource
-	audioStreamer AudioStreamer // nil if live audio streaming not configured
+	audioStreamer AudioStreamer       // nil if live audio streaming not configured
+	webrtc        *rtcaudio.Publisher // nil when WEBRTC_ENABLED is off
 	version       string
 	startTime     time.Time
 
@@ -145,7 +147,7 @@ func (h *HealthHandler) checkForUpdate(url string, firstCheck bool) {
 	if idx := strings.Index(currentVer, " "); idx > 0 {
 		currentVer = currentVer[:idx]
 	}
-	available := compareVersions(result.Latest, currentVer) > 0
+	available := selfupdate.CompareVersions(result.Latest, currentVer) > 0
 
 	h.mu.Lock()
 	h.update = &updateStatus{
@@ -164,36 +166,6 @@ func (h *HealthHandler) checkForUpdate(url string, firstCheck bool) {
 	}
 }
 
-// compareVersions compares two version strings like "v0.8.8.1" or "0.8.8".
-// Returns >0 if a > b, <0 if a < b, 0 if equal.
-// Handles variable-length segments (0.8.8 < 0.8.8.1).
-func compareVersions(a, b string) int {
-	a = strings.TrimPrefix(a, "v")
-	b = strings.TrimPrefix(b, "v")
-
-	aParts := strings.Split(a, ".")
-	bParts := strings.Split(b, ".")
-
-	maxLen := len(aParts)
-	if len(bParts) > maxLen {
-		maxLen = len(bParts)
-	}
-
-	for i := 0; i < maxLen; i++ {
-		var aNum, bNum int
-		if i < len(aParts) {
-			aNum, _ = strconv.Atoi(aParts[i])
-		}
-		if i < len(bParts) {
-			bNum, _ = strconv.Atoi(bParts[i])
-		}
-		if aNum != bNum {
-			return aNum - bNum
-		}
-	}
-	return 0
-}
-
 func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	checks := make(map[string]string)
 	status := "healthy"
@@ -260,6 +232,9 @@ func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var audioStreamStatus *AudioStreamStatusData
 	if h.audioStreamer != nil && h.audioStreamer.AudioStreamEnabled() {
 		audioStreamStatus = h.audioStreamer.AudioStreamStatus()
+		if h.webrtc != nil {
+			audioStreamStatus.WebRTC = h.webrtc.Status()
+		}
 	}
 
 	resp := HealthResponse{
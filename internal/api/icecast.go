@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// IcecastHandler reports the Icecast output's mounts. The streamer imports
+// this package for its events, so its status is passed in as a func.
+type IcecastHandler struct {
+	status func() any // nil when ICECAST_URL is unset
+}
+
+func NewIcecastHandler(status func() any) *IcecastHandler {
+	return &IcecastHandler{status: status}
+}
+
+func (h *IcecastHandler) available(w http.ResponseWriter) bool {
+	if h.status == nil {
+		WriteError(w, http.StatusServiceUnavailable, "icecast output not enabled (set ICECAST_URL)")
+		return false
+	}
+	return true
+}
+
+// GetIcecastStatus reports each mount's source connection, queue, the call
+// being streamed and play/drop counts.
+// GET /api/v1/admin/icecast
+func (h *IcecastHandler) GetIcecastStatus(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	WriteJSON(w, http.StatusOK, h.status())
+}
+
+func (h *IcecastHandler) Routes(r chi.Router) {
+	r.Get("/admin/icecast", h.GetIcecastStatus)
+}
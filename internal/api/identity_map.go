@@ -0,0 +1,106 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/hlog"
+)
+
+// ListIdentityMap returns the identity resolver's cache: which system/site
+// each (instance_id, sys_name) pair resolves to, how it got there and how
+// often it has been used. Optional instance_id filter.
+func (h *AdminHandler) ListIdentityMap(w http.ResponseWriter, r *http.Request) {
+	if h.live == nil {
+		WriteError(w, http.StatusServiceUnavailable, "pipeline not running")
+		return
+	}
+	instanceID, _ := QueryString(r, "instance_id")
+	entries := []IdentityMapEntryData{}
+	for _, e := range h.live.IdentityMap() {
+		if instanceID == "" || e.InstanceID == instanceID {
+			entries = append(entries, e)
+		}
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"entries": entries,
+		"total":   len(entries),
+	})
+}
+
+// RemapIdentity repoints an (instance_id, sys_name) pair to another system.
+// Body: {"instance_id", "sys_name", "system_id", "rewrite_calls": bool,
+// "since": RFC 3339}. rewrite_calls also moves the site's calls already
+// recorded under another system (only those since since, if given). The
+// cache entry is dropped so the next message resolves to the new system.
+func (h *AdminHandler) RemapIdentity(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		InstanceID   string     `json:"instance_id"`
+		SysName      string     `json:"sys_name"`
+		SystemID     int        `json:"system_id"`
+		RewriteCalls bool       `json:"rewrite_calls"`
+		Since        *time.Time `json:"since"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	if req.InstanceID == "" || req.SysName == "" || req.SystemID <= 0 {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "instance_id, sys_name and system_id are required")
+		return
+	}
+	if req.Since != nil && !req.RewriteCalls {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "since requires rewrite_calls")
+		return
+	}
+
+	res, err := h.db.RemapSite(r.Context(), req.InstanceID, req.SysName, req.SystemID, req.RewriteCalls, req.Since)
+	if err != nil {
+		if msg := err.Error(); msg == "site not found" || msg == "system not found" {
+			WriteError(w, http.StatusNotFound, msg)
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "remap failed: "+err.Error())
+		return
+	}
+	if h.live != nil {
+		h.live.InvalidateIdentity(req.InstanceID, req.SysName)
+	}
+	if res.CallsMoved > 0 || res.OldSystemID != res.NewSystemID {
+		h.cache.Purge()
+	}
+	hlog.FromRequest(r).Info().
+		Str("instance_id", req.InstanceID).
+		Str("sys_name", req.SysName).
+		Int("old_system_id", res.OldSystemID).
+		Int("new_system_id", res.NewSystemID).
+		Int64("calls_moved", res.CallsMoved).
+		Msg("identity remapped")
+	WriteJSON(w, http.StatusOK, res)
+}
+
+// InvalidateIdentityMap drops identity cache entries without a restart so
+// they re-resolve from the database on the next message.
+// Body: {"instance_id", "sys_name"}; both optional, empty = everything.
+func (h *AdminHandler) InvalidateIdentityMap(w http.ResponseWriter, r *http.Request) {
+	if h.live == nil {
+		WriteError(w, http.StatusServiceUnavailable, "pipeline not running")
+		return
+	}
+	var req struct {
+		InstanceID string `json:"instance_id"`
+		SysName    string `json:"sys_name"`
+	}
+	if r.ContentLength != 0 {
+		if err := DecodeJSON(r, &req); err != nil {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+			return
+		}
+	}
+	if req.SysName != "" && req.InstanceID == "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "sys_name requires instance_id")
+		return
+	}
+	n := h.live.InvalidateIdentity(req.InstanceID, req.SysName)
+	WriteJSON(w, http.StatusOK, map[string]any{"invalidated": n})
+}
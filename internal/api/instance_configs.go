@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// InstanceConfigsHandler serves the configuration history of TR instances.
+type InstanceConfigsHandler struct {
+	db *database.DB
+}
+
+func NewInstanceConfigsHandler(db *database.DB) *InstanceConfigsHandler {
+	return &InstanceConfigsHandler{db: db}
+}
+
+// ListConfigHistory returns an instance's config versions, newest first,
+// each with the settings changed since the version before it. Full configs
+// are only included with include_config=true.
+// GET /api/v1/instances/{instance_id}/config-history
+func (h *InstanceConfigsHandler) ListConfigHistory(w http.ResponseWriter, r *http.Request) {
+	p, err := ParsePagination(r)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+	withConfig, _ := QueryBool(r, "include_config")
+
+	instanceID := chi.URLParam(r, "instance_id")
+	versions, total, err := h.db.ListInstanceConfigVersions(r.Context(), instanceID, withConfig, p.Limit, p.Offset)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list config history")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"instance_id": instanceID,
+		"versions":    versions,
+		"total":       total,
+		"limit":       p.Limit,
+		"offset":      p.Offset,
+	})
+}
+
+// GetConfigVersion returns one config version with its full config.
+// GET /api/v1/instances/{instance_id}/config-history/{version}
+func (h *InstanceConfigsHandler) GetConfigVersion(w http.ResponseWriter, r *http.Request) {
+	version, err := PathInt(r, "version")
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "invalid version")
+		return
+	}
+	v, err := h.db.GetInstanceConfigVersion(r.Context(), chi.URLParam(r, "instance_id"), version)
+	if err != nil {
+		if err.Error() == "config version not found" {
+			WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to get config version")
+		return
+	}
+	WriteJSON(w, http.StatusOK, v)
+}
+
+// Routes registers instance config history routes on the given router.
+func (h *InstanceConfigsHandler) Routes(r chi.Router) {
+	r.Get("/instances/{instance_id}/config-history", h.ListConfigHistory)
+	r.Get("/instances/{instance_id}/config-history/{version}", h.GetConfigVersion)
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// InstancePoliciesHandler manages which instances may auto-create systems and
+// sites, and the sys_names staged while they may not.
+type InstancePoliciesHandler struct {
+	db          *database.DB
+	defaultMode string                          // INGEST_AUTO_CREATE: "allow" or "deny"
+	onChange    func(ctx context.Context) error // reloads the ingest identity resolver; may be nil
+}
+
+func NewInstancePoliciesHandler(db *database.DB, defaultMode string, onChange func(context.Context) error) *InstancePoliciesHandler {
+	return &InstancePoliciesHandler{db: db, defaultMode: defaultMode, onChange: onChange}
+}
+
+// changed tells ingest about a policy change or approval. The change is
+// already committed, so a failed reload is only logged; it is picked up on
+// the next recheck or restart.
+func (h *InstancePoliciesHandler) changed(r *http.Request) {
+	if h.onChange == nil {
+		return
+	}
+	if err := h.onChange(r.Context()); err != nil {
+		hlog.FromRequest(r).Warn().Err(err).Msg("failed to reload instance policies")
+	}
+}
+
+// ListInstancePolicies returns the default mode and per-instance policies.
+func (h *InstancePoliciesHandler) ListInstancePolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.db.ListInstancePolicies(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list instance policies")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"default":  h.defaultMode,
+		"policies": policies,
+	})
+}
+
+// PutInstancePolicy sets whether an instance may auto-create systems/sites.
+// Body: {"auto_create": bool, "note": "..."}.
+func (h *InstancePoliciesHandler) PutInstancePolicy(w http.ResponseWriter, r *http.Request) {
+	instanceID := chi.URLParam(r, "instance_id")
+	if strings.TrimSpace(instanceID) == "" {
+		WriteError(w, http.StatusBadRequest, "instance_id is required")
+		return
+	}
+	var req struct {
+		AutoCreate *bool  `json:"auto_create"`
+		Note       string `json:"note"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	if req.AutoCreate == nil {
+		WriteError(w, http.StatusBadRequest, "auto_create is required")
+		return
+	}
+
+	p, err := h.db.UpsertInstancePolicy(r.Context(), instanceID, *req.AutoCreate, req.Note)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to save instance policy")
+		return
+	}
+	h.changed(r)
+	WriteJSON(w, http.StatusOK, p)
+}
+
+// DeleteInstancePolicy reverts an instance to the default mode.
+func (h *InstancePoliciesHandler) DeleteInstancePolicy(w http.ResponseWriter, r *http.Request) {
+	instanceID := chi.URLParam(r, "instance_id")
+	found, err := h.db.DeleteInstancePolicy(r.Context(), instanceID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to delete instance policy")
+		return
+	}
+	if !found {
+		WriteError(w, http.StatusNotFound, "instance policy not found")
+		return
+	}
+	h.changed(r)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListPendingIdentities returns sys_names staged because their instance may
+// not auto-create. ?status= filters (default pending; "all" for every status).
+func (h *InstancePoliciesHandler) ListPendingIdentities(w http.ResponseWriter, r *http.Request) {
+	status := "pending"
+	if v, ok := QueryString(r, "status"); ok {
+		switch v {
+		case "pending", "approved", "mapped", "rejected":
+			status = v
+		case "all":
+			status = ""
+		default:
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter,
+				"status must be pending, approved, mapped, rejected, or all")
+			return
+		}
+	}
+	pending, err := h.db.ListPendingIdentities(r.Context(), status)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list pending identities")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"identities": pending,
+		"total":      len(pending),
+	})
+}
+
+// ApprovePendingIdentity lets a staged sys_name through. Body (optional):
+// {"system_id": N} maps it to an existing system as a new site; without it, a
+// new system is created as auto-create would have.
+func (h *InstancePoliciesHandler) ApprovePendingIdentity(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid pending identity ID")
+		return
+	}
+	var req struct {
+		SystemID *int `json:"system_id"`
+	}
+	if r.ContentLength != 0 {
+		if err := DecodeJSON(r, &req); err != nil {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+			return
+		}
+	}
+
+	p, err := h.db.ResolvePendingIdentity(r.Context(), id, req.SystemID)
+	if err != nil {
+		switch {
+		case err.Error() == "pending identity not found", err.Error() == "system not found":
+			WriteError(w, http.StatusNotFound, err.Error())
+		case strings.HasPrefix(err.Error(), "pending identity already"):
+			WriteError(w, http.StatusConflict, err.Error())
+		default:
+			hlog.FromRequest(r).Error().Err(err).Int("id", id).Msg("failed to approve pending identity")
+			WriteError(w, http.StatusInternalServerError, "failed to approve pending identity")
+		}
+		return
+	}
+	h.changed(r)
+	WriteJSON(w, http.StatusOK, p)
+}
+
+// RejectPendingIdentity keeps dropping a staged sys_name's messages and
+// removes it from the pending list.
+func (h *InstancePoliciesHandler) RejectPendingIdentity(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid pending identity ID")
+		return
+	}
+	p, err := h.db.RejectPendingIdentity(r.Context(), id)
+	if err != nil {
+		if err.Error() == "pending identity not found or already approved" {
+			WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to reject pending identity")
+		return
+	}
+	WriteJSON(w, http.StatusOK, p)
+}
+
+func (h *InstancePoliciesHandler) Routes(r chi.Router) {
+	r.Get("/admin/instances/policies", h.ListInstancePolicies)
+	r.Put("/admin/instances/{instance_id}/policy", h.PutInstancePolicy)
+	r.Delete("/admin/instances/{instance_id}/policy", h.DeleteInstancePolicy)
+	r.Get("/admin/identities/pending", h.ListPendingIdentities)
+	r.Post("/admin/identities/pending/{id}/approve", h.ApprovePendingIdentity)
+	r.Post("/admin/identities/pending/{id}/reject", h.RejectPendingIdentity)
+}
@@ -0,0 +1,257 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// LegalHoldsHandler manages legal holds: calls, talkgroups or time ranges
+// exempt from retention purges, unusable-audio deletion and storage policies
+// until released. Every change records the actor who made it.
+type LegalHoldsHandler struct {
+	db       *database.DB
+	onChange func(ctx context.Context) error // reloads the ingest hold cache; may be nil
+}
+
+func NewLegalHoldsHandler(db *database.DB, onChange func(context.Context) error) *LegalHoldsHandler {
+	return &LegalHoldsHandler{db: db, onChange: onChange}
+}
+
+// changed tells ingest about a hold change. The change is already committed,
+// so a failed reload is only logged; it is picked up on restart.
+func (h *LegalHoldsHandler) changed(r *http.Request) {
+	if h.onChange == nil {
+		return
+	}
+	if err := h.onChange(r.Context()); err != nil {
+		hlog.FromRequest(r).Warn().Err(err).Msg("failed to reload legal holds")
+	}
+}
+
+// ListLegalHolds returns holds, newest first. ?active=false includes
+// released holds.
+func (h *LegalHoldsHandler) ListLegalHolds(w http.ResponseWriter, r *http.Request) {
+	activeOnly := true
+	if v := r.URL.Query().Get("active"); v != "" {
+		switch v {
+		case "true":
+		case "false":
+			activeOnly = false
+		default:
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "active must be true or false")
+			return
+		}
+	}
+	holds, err := h.db.ListLegalHolds(r.Context(), activeOnly)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list legal holds")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"holds": holds,
+		"total": len(holds),
+	})
+}
+
+// CreateLegalHold places a hold on one call (call_id), or on the calls
+// matching system_id, tgid and [start_time, end_time); unset fields match
+// anything, but at least one must be set.
+func (h *LegalHoldsHandler) CreateLegalHold(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name      string     `json:"name"`
+		Reason    string     `json:"reason"`
+		Actor     string     `json:"actor"`
+		SystemID  *int       `json:"system_id"`
+		Tgid      *int       `json:"tgid"`
+		CallID    *int64     `json:"call_id"`
+		StartTime *time.Time `json:"start_time"`
+		EndTime   *time.Time `json:"end_time"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	hold := database.LegalHold{
+		Name:      strings.TrimSpace(req.Name),
+		Reason:    strings.TrimSpace(req.Reason),
+		CreatedBy: strings.TrimSpace(req.Actor),
+	}
+	if hold.Name == "" || hold.CreatedBy == "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "name and actor are required")
+		return
+	}
+
+	if req.CallID != nil {
+		if req.SystemID != nil || req.Tgid != nil || req.StartTime != nil || req.EndTime != nil {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "call_id cannot be combined with system_id, tgid or a time range")
+			return
+		}
+		ref, err := h.db.ResolveCallRef(r.Context(), database.CallRef{CallID: *req.CallID})
+		if err != nil {
+			if err.Error() == "call not found" {
+				WriteError(w, http.StatusNotFound, "call not found")
+				return
+			}
+			WriteError(w, http.StatusInternalServerError, "failed to look up call")
+			return
+		}
+		hold.CallID, hold.CallStartTime = &ref.CallID, &ref.StartTime
+	} else {
+		if req.SystemID == nil && req.StartTime == nil && req.EndTime == nil {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "one of call_id, system_id or a time range is required")
+			return
+		}
+		if req.Tgid != nil && req.SystemID == nil {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "tgid requires system_id")
+			return
+		}
+		if req.StartTime != nil && req.EndTime != nil && !req.StartTime.Before(*req.EndTime) {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, "start_time must be before end_time")
+			return
+		}
+		hold.SystemID, hold.Tgid = req.SystemID, req.Tgid
+		hold.StartTime, hold.EndTime = req.StartTime, req.EndTime
+	}
+
+	if err := h.db.CreateLegalHold(r.Context(), &hold); err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to create legal hold")
+		return
+	}
+	h.changed(r)
+	WriteJSON(w, http.StatusCreated, hold)
+}
+
+// GetLegalHold returns a hold, active or released.
+func (h *LegalHoldsHandler) GetLegalHold(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid legal hold ID")
+		return
+	}
+	hold, err := h.db.GetLegalHold(r.Context(), id)
+	if err != nil {
+		h.writeHoldError(w, err, "failed to get legal hold")
+		return
+	}
+	WriteJSON(w, http.StatusOK, hold)
+}
+
+// UpdateLegalHold changes an active hold's name, reason or end time. The
+// covered calls are otherwise fixed; create another hold to widen it.
+func (h *LegalHoldsHandler) UpdateLegalHold(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid legal hold ID")
+		return
+	}
+	var req struct {
+		Name    *string    `json:"name"`
+		Reason  *string    `json:"reason"`
+		EndTime *time.Time `json:"end_time"`
+		Actor   string     `json:"actor"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	actor := strings.TrimSpace(req.Actor)
+	if actor == "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "actor is required")
+		return
+	}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "name cannot be empty")
+			return
+		}
+		req.Name = &name
+	}
+	if req.EndTime != nil {
+		hold, err := h.db.GetLegalHold(r.Context(), id)
+		if err != nil {
+			h.writeHoldError(w, err, "failed to get legal hold")
+			return
+		}
+		if hold.CallID != nil {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "a call hold has no time range")
+			return
+		}
+		if hold.StartTime != nil && !hold.StartTime.Before(*req.EndTime) {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, "end_time must be after start_time")
+			return
+		}
+	}
+
+	hold, err := h.db.UpdateLegalHold(r.Context(), id, database.LegalHoldPatch{
+		Name:    req.Name,
+		Reason:  req.Reason,
+		EndTime: req.EndTime,
+	}, actor)
+	if err != nil {
+		h.writeHoldError(w, err, "failed to update legal hold")
+		return
+	}
+	h.changed(r)
+	WriteJSON(w, http.StatusOK, hold)
+}
+
+// ReleaseLegalHold releases a hold (?actor= is required). The hold is kept
+// as a record; its calls become subject to retention again.
+func (h *LegalHoldsHandler) ReleaseLegalHold(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid legal hold ID")
+		return
+	}
+	actor := strings.TrimSpace(r.URL.Query().Get("actor"))
+	if actor == "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "actor is required")
+		return
+	}
+	hold, err := h.db.ReleaseLegalHold(r.Context(), id, actor)
+	if err != nil {
+		h.writeHoldError(w, err, "failed to release legal hold")
+		return
+	}
+	h.changed(r)
+	WriteJSON(w, http.StatusOK, hold)
+}
+
+// GetLegalHoldReport returns the calls and storage each active hold keeps,
+// with totals that count a call under several holds once.
+func (h *LegalHoldsHandler) GetLegalHoldReport(w http.ResponseWriter, r *http.Request) {
+	report, err := h.db.GetLegalHoldReport(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to build legal hold report")
+		return
+	}
+	WriteJSON(w, http.StatusOK, report)
+}
+
+func (h *LegalHoldsHandler) writeHoldError(w http.ResponseWriter, err error, msg string) {
+	switch err.Error() {
+	case "legal hold not found":
+		WriteError(w, http.StatusNotFound, err.Error())
+	case "legal hold is released":
+		WriteError(w, http.StatusConflict, err.Error())
+	default:
+		WriteError(w, http.StatusInternalServerError, msg)
+	}
+}
+
+func (h *LegalHoldsHandler) Routes(r chi.Router) {
+	r.Get("/admin/legal-holds", h.ListLegalHolds)
+	r.Post("/admin/legal-holds", h.CreateLegalHold)
+	r.Get("/admin/legal-holds/report", h.GetLegalHoldReport)
+	r.Get("/admin/legal-holds/{id}", h.GetLegalHold)
+	r.Patch("/admin/legal-holds/{id}", h.UpdateLegalHold)
+	r.Delete("/admin/legal-holds/{id}", h.ReleaseLegalHold)
+}
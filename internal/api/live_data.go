@@ -5,6 +5,9 @@ import (
 	"time"
 
 	"github.com/snarg/tr-engine/internal/audio"
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/expr"
+	"github.com/snarg/tr-engine/internal/rtcaudio"
 )
 
 // LiveDataSource provides real-time data from the ingest pipeline to the API layer.
@@ -32,26 +35,60 @@ type LiveDataSource interface {
 	// WatcherStatus returns the file watcher status, or nil if not active.
 	WatcherStatus() *WatcherStatusData
 
+	// RetryBackfillFailures re-ingests, in the background, the files the
+	// file-watch backfill report lists as errors, optionally only those
+	// from days in [startDay, endDay]. Returns how many were queued.
+	RetryBackfillFailures(ctx context.Context, startDay, endDay *time.Time) (int, error)
+
 	// TranscriptionStatus returns the transcription service status, or nil if not configured.
 	TranscriptionStatus() *TranscriptionStatusData
 
 	// EnqueueTranscription adds a call to the transcription queue.
 	// Returns false if the queue is full or transcription is disabled.
-	EnqueueTranscription(callID int64) bool
+	EnqueueTranscription(ref database.CallRef) bool
 
 	// TranscriptionQueueStats returns queue statistics, or nil if not configured.
 	TranscriptionQueueStats() *TranscriptionQueueStatsData
 
+	// TranscriptionDeadLetters returns jobs given up on after repeated stuck
+	// or panicked attempts, newest first, or nil if not configured.
+	TranscriptionDeadLetters() []TranscriptionDeadLetterData
+
+	// ClassifyTranscript scores text (e.g. a human correction) for urgency.
+	// Returns nil if classification is not configured.
+	ClassifyTranscript(ctx context.Context, call *database.CallTranscriptionInfo, text string) *database.TranscriptUrgency
+
 	// IngestMetrics returns pipeline metrics for the Prometheus collector.
 	// Returns nil if the pipeline is not running.
 	IngestMetrics() *IngestMetricsData
 
-	// MaintenanceStatus returns the current maintenance config and last run results.
-	MaintenanceStatus() *MaintenanceStatusData
+	// MaintenanceStatus returns the current maintenance config, mode, task
+	// states and last run results.
+	MaintenanceStatus(ctx context.Context) *MaintenanceStatusData
 
-	// RunMaintenance triggers an immediate maintenance run.
+	// RunMaintenance triggers an immediate maintenance run. With dryRun set,
+	// destructive steps are only recorded in the maintenance audit.
 	// Returns the results, or an error if maintenance is already running.
-	RunMaintenance(ctx context.Context) (*MaintenanceRunData, error)
+	RunMaintenance(ctx context.Context, dryRun bool) (*MaintenanceRunData, error)
+
+	// RetentionStatus returns each retention target's configured retention,
+	// admin override and the retention maintenance applies.
+	RetentionStatus(ctx context.Context) ([]RetentionStatus, error)
+
+	// ReprocessQuarantined re-validates a quarantined message and, if it now
+	// passes (or force is set), runs it through its handler.
+	ReprocessQuarantined(ctx context.Context, id int64, force bool) (*QuarantineReprocessData, error)
+
+	// IdentityMap returns the identity resolver's cache entries.
+	IdentityMap() []IdentityMapEntryData
+
+	// InvalidateIdentity drops identity cache entries (all when instanceID is
+	// empty, an instance's when sysName is empty) so they re-resolve from the
+	// database. Returns how many were dropped.
+	InvalidateIdentity(instanceID, sysName string) int
+
+	// TopicMap returns the MQTT topic prefix → instance mapping in use.
+	TopicMap() *TopicMapData
 }
 
 // CallUploader processes an uploaded call (audio + metadata).
@@ -59,7 +96,8 @@ type LiveDataSource interface {
 type CallUploader interface {
 	// ProcessUpload handles an HTTP-uploaded call. fields contains the parsed
 	// form field values, audioData is the raw audio bytes, audioFilename is the
-	// original filename from the upload. format is "rdio-scanner" or "openmhz".
+	// original filename from the upload. format is "rdio-scanner", "openmhz",
+	// "filename" or "issi" (gateway JSON record in fields["issi"]).
 	// Returns the result or an error (containing "duplicate call" for 409s).
 	ProcessUpload(ctx context.Context, instanceID string, format string, fields map[string]string, audioData []byte, audioFilename string) (*UploadCallResult, error)
 }
@@ -103,6 +141,7 @@ type ActiveCallData struct {
 	Conventional  bool      `json:"conventional"`
 	Phase2TDMA    bool      `json:"phase2_tdma"`
 	AudioType     string    `json:"audio_type,omitempty"`
+	Restricted    bool      `json:"-"` // hidden from non-admins by a restricted encryption policy
 }
 
 // RecorderStateData represents a recorder's current state.
@@ -158,10 +197,69 @@ type TranscriptionStatusData struct {
 
 // TranscriptionQueueStatsData reports transcription queue statistics.
 type TranscriptionQueueStatsData struct {
-	Pending     int                           `json:"pending"`
-	Completed   int64                         `json:"completed"`
-	Failed      int64                         `json:"failed"`
-	Performance *TranscriptionPerformanceData `json:"performance,omitempty"`
+	Pending          int                           `json:"pending"`
+	PendingEmergency int                           `json:"pending_emergency"` // of Pending, emergency jobs at the front
+	InFlight         int                           `json:"in_flight"`
+	Completed        int64                         `json:"completed"`
+	Failed           int64                         `json:"failed"`
+	Requeued         int64                         `json:"requeued"`      // stuck or panicked jobs re-queued
+	DeadLettered     int64                         `json:"dead_lettered"` // jobs given up on since startup
+	InFlightJobs     []TranscriptionInFlightData   `json:"in_flight_jobs"`
+	Performance      *TranscriptionPerformanceData `json:"performance,omitempty"`
+}
+
+// TranscriptionInFlightData describes a job a worker is processing.
+type TranscriptionInFlightData struct {
+	CallID   int64     `json:"call_id"`
+	SystemID int       `json:"system_id"`
+	Tgid     int       `json:"tgid"`
+	Worker   int       `json:"worker"`
+	Attempt  int       `json:"attempt"`
+	Started  time.Time `json:"started"`
+	Deadline time.Time `json:"deadline"` // the watchdog abandons and re-queues it after this
+}
+
+// TranscriptionDeadLetterData is a transcription job given up on.
+type TranscriptionDeadLetterData struct {
+	CallID        int64     `json:"call_id"`
+	CallStartTime time.Time `json:"call_start_time"`
+	SystemID      int       `json:"system_id"`
+	Tgid          int       `json:"tgid"`
+	Attempts      int       `json:"attempts"`
+	Reason        string    `json:"reason"` // stuck, panic, queue_full, stopped
+	Error         string    `json:"error,omitempty"`
+	Time          time.Time `json:"time"`
+}
+
+// IdentityMapEntryData is one cached (instance, sys_name) → system/site
+// mapping.
+type IdentityMapEntryData struct {
+	InstanceID string     `json:"instance_id"`
+	SysName    string     `json:"sys_name"`
+	SystemID   int        `json:"system_id"`
+	SiteID     int        `json:"site_id"`
+	Sysid      string     `json:"sysid,omitempty"`
+	Source     string     `json:"source"` // loaded (startup), resolved, approved
+	CachedAt   time.Time  `json:"cached_at"`
+	Hits       int64      `json:"hits"` // cache hits since cached
+	LastHit    *time.Time `json:"last_hit,omitempty"`
+}
+
+// TopicMapData is the MQTT_INSTANCE_MAP in use: which TR instance messages
+// under each topic prefix are attributed to.
+type TopicMapData struct {
+	Mappings         []TopicMappingData `json:"mappings"`          // longest prefix first, the order they are tried
+	Subscriptions    []string           `json:"subscriptions"`     // MQTT topic filters subscribed to
+	UnmappedMessages int64              `json:"unmapped_messages"` // messages matching no prefix (only counted when mappings exist)
+}
+
+// TopicMappingData is one prefix → instance mapping and what it matched
+// since startup.
+type TopicMappingData struct {
+	Prefix        string     `json:"prefix"`
+	InstanceID    string     `json:"instance_id"`
+	Messages      int64      `json:"messages"`
+	LastMessageAt *time.Time `json:"last_message_at,omitempty"`
 }
 
 // TranscriptionPerformanceData reports aggregate STT performance.
@@ -189,8 +287,20 @@ type IngestMetricsData struct {
 
 // MaintenanceStatusData reports the current maintenance configuration and last run results.
 type MaintenanceStatusData struct {
-	Config  MaintenanceConfigData `json:"config"`
-	LastRun *MaintenanceRunData   `json:"last_run"`
+	Config         MaintenanceConfigData   `json:"config"`
+	Mode           string                  `json:"mode"` // "enforce", "dry_run", or "observe"
+	ObserveCycles  int                     `json:"observe_cycles"`
+	ObservedCycles int                     `json:"observed_cycles"`
+	Tasks          []MaintenanceTaskStatus `json:"tasks"`
+	LastRun        *MaintenanceRunData     `json:"last_run"`
+}
+
+// MaintenanceTaskStatus reports whether a destructive maintenance task runs.
+type MaintenanceTaskStatus struct {
+	Task    string `json:"task"`
+	Enabled bool   `json:"enabled"`
+	DryRun  bool   `json:"dry_run"` // recorded only, even when the run enforces
+	Source  string `json:"source"`  // "config" or "admin"
 }
 
 // MaintenanceConfigData reports the active retention settings.
@@ -200,17 +310,54 @@ type MaintenanceConfigData struct {
 	RetentionPluginStatus string `json:"retention_plugin_status"`
 	RetentionCheckpoints  string `json:"retention_checkpoints"`
 	RetentionStaleCalls   string `json:"retention_stale_calls"`
+	RetentionInactiveUnits string `json:"retention_inactive_units"` // "0s" = disabled
+	RetentionQuarantine    string `json:"retention_quarantine"`
+	RetentionExternalEvents string `json:"retention_external_events"` // "0s" = kept forever
+	RetentionWebhookDeliveries string `json:"retention_webhook_deliveries"` // "0s" = kept forever
+	RetentionTimeseries    string `json:"retention_timeseries"` // "0s" = not collected
+	RetentionUnitEvents    string `json:"retention_unit_events"`   // "0s" = kept forever
+	RetentionUnitSessions  string `json:"retention_unit_sessions"` // "0s" = kept forever
+	RetentionCalls         string `json:"retention_calls"`         // "0s" = kept forever
+	RetentionCallAudio     string `json:"retention_call_audio"`    // "0s" = kept until the call is purged
+	AudioDiskMaxPercent    int    `json:"audio_disk_max_percent"`  // 0 = no disk usage threshold
+	KeepPinned             bool   `json:"keep_pinned"`             // audio and call purges skip pinned calls
 	Schedule              string `json:"schedule"`
 }
 
+// RetentionStatus reports the retention of one target (a table, or
+// call_audio): RETENTION_* config, and the admin override if any.
+type RetentionStatus struct {
+	Target    string     `json:"target"`
+	Optional  bool       `json:"optional"`  // "0s" keeps data forever; otherwise retention must be positive
+	Config    string     `json:"config"`    // configured retention
+	Retention string     `json:"retention"` // applied by maintenance; "0s" = kept forever
+	Source    string     `json:"source"`    // "config" or "admin"
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	Note      string     `json:"note,omitempty"`
+}
+
 // MaintenanceRunData reports the results of a single maintenance run.
 type MaintenanceRunData struct {
+	RunID             int64                       `json:"run_id"`
+	Mode              string                      `json:"mode"`
+	DryRun            bool                        `json:"dry_run"`
 	StartedAt         time.Time                   `json:"started_at"`
 	DurationMs        int64                       `json:"duration_ms"`
 	Decimation        map[string]DecimationResult `json:"decimation"`
 	Purged            map[string]int64            `json:"purged"`
 	PartitionsCreated int                         `json:"partitions_created"`
 	PartitionsDropped []string                    `json:"partitions_dropped"`
+	DurationsCorrected int64                      `json:"durations_corrected"` // see AUDIO_DURATION_CORRECT
+	Observed          map[string]int64            `json:"observed,omitempty"`      // dry-run rows by "task:target"
+	SkippedTasks      []string                    `json:"skipped_tasks,omitempty"` // disabled tasks
+}
+
+// QuarantineReprocessData reports the outcome of reprocessing a quarantined message.
+type QuarantineReprocessData struct {
+	ID      int64    `json:"id"`
+	Status  string   `json:"status"` // "reprocessed", "failed", or "pending" (still invalid)
+	Reasons []string `json:"reasons,omitempty"`
+	Error   string   `json:"error,omitempty"`
 }
 
 // DecimationResult reports rows deleted in each decimation phase.
@@ -229,33 +376,59 @@ type AudioStreamer interface {
 
 // AudioStreamStatusData reports the status of the live audio streaming subsystem.
 type AudioStreamStatusData struct {
-	Enabled          bool   `json:"enabled"`
-	Listen           string `json:"listen,omitempty"`
-	ActiveEncoders   int    `json:"active_encoders"`
-	ConnectedClients int    `json:"connected_clients"`
-	LastChunkReceived string `json:"last_chunk_received,omitempty"`
+	Enabled           bool             `json:"enabled"`
+	Listen            string           `json:"listen,omitempty"`
+	ActiveEncoders    int              `json:"active_encoders"`
+	ConnectedClients  int              `json:"connected_clients"`
+	LastChunkReceived string           `json:"last_chunk_received,omitempty"`
+	WebRTC            *rtcaudio.Status `json:"webrtc,omitempty"` // nil when WEBRTC_ENABLED is off
 }
 
 // EventFilter specifies which events an SSE subscriber wants to receive.
+// Saved subscription profiles store it as JSON.
 type EventFilter struct {
-	Systems       []int
-	Sites         []int
-	Tgids         []int
-	Units         []int
-	Types         []string
-	EmergencyOnly bool
+	Systems       []int    `json:"systems,omitempty"`
+	Sites         []int    `json:"sites,omitempty"`
+	Tgids         []int    `json:"tgids,omitempty"`
+	Units         []int    `json:"units,omitempty"`
+	Types         []string `json:"types,omitempty"`
+	EmergencyOnly bool     `json:"emergency_only,omitempty"`
+
+	// IncludeRestricted passes events hidden by a restricted encryption
+	// policy; set for admin subscribers only.
+	IncludeRestricted bool `json:"-"`
+
+	// Scoped drops events without a talkgroup (or without a system, when
+	// Systems is set), which the filters above otherwise let through. Set
+	// for share link subscribers.
+	Scoped bool `json:"-"`
+
+	// Expr is an expression (internal/expr) the event must also satisfy,
+	// evaluated against its payload fields — see NewEventVars. Program is
+	// Expr compiled by CompileExpr.
+	Expr    string        `json:"expr,omitempty"`
+	Program *expr.Program `json:"-"`
+
+	// Any, when set, additionally requires the event to match at least one
+	// of these filters (subscribing to several profiles at once).
+	Any []EventFilter `json:"-"`
 }
 
 // SSEEvent represents a server-sent event ready for transmission.
 type SSEEvent struct {
-	ID        string `json:"event_id"`
-	Type      string `json:"event_type"`
-	SubType   string `json:"sub_type,omitempty"`
-	Timestamp string `json:"timestamp"`
-	SystemID  int    `json:"system_id,omitempty"`
-	SiteID    int    `json:"site_id,omitempty"`
-	Tgid      int    `json:"tgid,omitempty"`
-	UnitID    int    `json:"unit_id,omitempty"`
-	Emergency bool   `json:"-"` // used for server-side filtering only
-	Data      []byte `json:"-"` // pre-serialized JSON payload
+	ID         string `json:"event_id"`
+	Type       string `json:"event_type"`
+	SubType    string `json:"sub_type,omitempty"`
+	Timestamp  string `json:"timestamp"`
+	SystemID   int    `json:"system_id,omitempty"`
+	SiteID     int    `json:"site_id,omitempty"`
+	Tgid       int    `json:"tgid,omitempty"`
+	UnitID     int    `json:"unit_id,omitempty"`
+	Emergency  bool   `json:"-"` // used for server-side filtering only
+	Restricted bool   `json:"-"` // restricted encryption policy: admin subscribers only
+	Data       []byte `json:"-"` // pre-serialized JSON payload
+
+	// VisibleAt, when set, holds the event back from non-admin subscribers
+	// until then (talkgroup embargo).
+	VisibleAt time.Time `json:"-"`
 }
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/subtle"
 	"encoding/hex"
@@ -8,12 +9,12 @@ import (
 	"net"
 	"net/http"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/hlog"
-	"golang.org/x/time/rate"
+
+	"github.com/snarg/tr-engine/internal/database"
 )
 
 func RequestID(next http.Handler) http.Handler {
@@ -90,6 +91,7 @@ func CORSWithOrigins(origins []string) func(http.Handler) http.Handler {
 
 			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, Last-Event-ID")
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Expose-Headers", "RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy, Retry-After")
 			if r.Method == http.MethodOptions {
 				w.WriteHeader(http.StatusNoContent)
 				return
@@ -100,48 +102,6 @@ func CORSWithOrigins(origins []string) func(http.Handler) http.Handler {
 }
 
 
-// RateLimiter returns middleware that applies per-IP rate limiting.
-// rps is requests per second, burst is the maximum burst size.
-func RateLimiter(rps float64, burst int) func(http.Handler) http.Handler {
-	var mu sync.Mutex
-	limiters := make(map[string]*rate.Limiter)
-
-	getLimiter := func(ip string) *rate.Limiter {
-		mu.Lock()
-		defer mu.Unlock()
-		if lim, ok := limiters[ip]; ok {
-			return lim
-		}
-		lim := rate.NewLimiter(rate.Limit(rps), burst)
-		limiters[ip] = lim
-		return lim
-	}
-
-	// Background cleanup of stale entries every 5 minutes
-	go func() {
-		for {
-			time.Sleep(5 * time.Minute)
-			mu.Lock()
-			// Simple strategy: clear the map periodically.
-			// Active clients will re-create their limiter on next request.
-			limiters = make(map[string]*rate.Limiter)
-			mu.Unlock()
-		}
-	}()
-
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := clientIP(r)
-			if !getLimiter(ip).Allow() {
-				w.Header().Set("Retry-After", "1")
-				WriteErrorWithCode(w, http.StatusTooManyRequests, ErrRateLimited, "rate limit exceeded")
-				return
-			}
-			next.ServeHTTP(w, r)
-		})
-	}
-}
-
 // ResponseTimeout wraps non-streaming handlers with a write deadline.
 // SSE and audio endpoints are excluded since they stream indefinitely.
 func ResponseTimeout(timeout time.Duration) func(http.Handler) http.Handler {
@@ -149,6 +109,8 @@ func ResponseTimeout(timeout time.Duration) func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Skip streaming endpoints
 			if strings.HasSuffix(r.URL.Path, "/events/stream") ||
+				strings.HasSuffix(r.URL.Path, "/events/ws") ||
+				strings.HasSuffix(r.URL.Path, "/calls/stream") ||
 				strings.HasSuffix(r.URL.Path, "/audio") ||
 				strings.HasSuffix(r.URL.Path, "/audio/live") {
 				next.ServeHTTP(w, r)
@@ -208,7 +170,8 @@ func extractBearerToken(r *http.Request) string {
 // in multipart uploads. This supports trunk-recorder upload plugins (rdio-scanner, OpenMHz)
 // which send the API key as a form field rather than an Authorization header.
 // Check order: Authorization header → ?token= query param → form field "key" → form field "api_key"
-func UploadAuth(token string) func(http.Handler) http.Handler {
+// Write and admin API keys from keys (may be nil) are accepted in place of token.
+func UploadAuth(token string, keys *APIKeys) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if token == "" {
@@ -218,7 +181,7 @@ func UploadAuth(token string) func(http.Handler) http.Handler {
 
 			// 1. Check Authorization header / ?token= query param
 			if provided := extractBearerToken(r); provided != "" {
-				if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1 {
+				if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1 || apiKeyUploads(keys.lookup(provided)) {
 					next.ServeHTTP(w, r)
 					return
 				}
@@ -228,7 +191,7 @@ func UploadAuth(token string) func(http.Handler) http.Handler {
 			if err := r.ParseMultipartForm(32 << 20); err == nil {
 				for _, fieldName := range []string{"key", "api_key"} {
 					if val := r.FormValue(fieldName); val != "" {
-						if subtle.ConstantTimeCompare([]byte(val), []byte(token)) == 1 {
+						if subtle.ConstantTimeCompare([]byte(val), []byte(token)) == 1 || apiKeyUploads(keys.lookup(val)) {
 							next.ServeHTTP(w, r)
 							return
 						}
@@ -243,6 +206,8 @@ func UploadAuth(token string) func(http.Handler) http.Handler {
 
 // BearerAuth requires a valid bearer token matching any of the provided tokens.
 // Empty tokens in the list are skipped. If all tokens are empty, all requests pass through.
+// Requests APIKeyAuth found an API key on, or SessionAuth a signed-in user,
+// pass as well.
 func BearerAuth(tokens ...string) func(http.Handler) http.Handler {
 	// Filter to non-empty tokens
 	var valid []string
@@ -254,7 +219,7 @@ func BearerAuth(tokens ...string) func(http.Handler) http.Handler {
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if len(valid) == 0 {
+			if len(valid) == 0 || shareScopeFrom(r) != nil || apiKeyFrom(r) != nil || userFrom(r) != nil {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -272,11 +237,44 @@ func BearerAuth(tokens ...string) func(http.Handler) http.Handler {
 	}
 }
 
+type adminKey struct{}
+
+// AdminContext marks requests from admins: the WRITE_TOKEN holder, admin API
+// keys, admin users, or every client when auth is disabled. Handlers check isAdmin to show data hidden
+// by a restricted encryption policy. Share link requests are never admin.
+func AdminContext(authEnabled bool, writeToken string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scope, scoped := credentialScope(r)
+			admin := !authEnabled ||
+				(scoped && scope == database.APIKeyScopeAdmin) ||
+				(!scoped && writeToken != "" && subtle.ConstantTimeCompare([]byte(extractBearerToken(r)), []byte(writeToken)) == 1)
+			if admin && shareScopeFrom(r) == nil {
+				r = r.WithContext(context.WithValue(r.Context(), adminKey{}, true))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isAdmin reports whether AdminContext marked r as coming from an admin.
+func isAdmin(r *http.Request) bool {
+	admin, _ := r.Context().Value(adminKey{}).(bool)
+	return admin
+}
+
 // WriteAuth requires the write token for mutating HTTP methods (POST, PATCH, PUT, DELETE).
 // Read methods (GET, HEAD, OPTIONS) pass through unconditionally.
 //   - writeToken set: mutations must provide it
 //   - writeToken empty + authToken set: mutations blocked (read-only mode)
 //   - both empty: all methods pass through (no auth configured)
+//
+// Per-token state (saved subscription profiles under /api/v1/subscriptions)
+// is exempt: it belongs to whichever token BearerAuth accepted.
+//
+// API keys go by scope instead, and signed-in users by role: admin may make
+// any change, write any outside /api/v1/admin/, and read none. A user's own
+// account under /api/v1/auth/me is exempt.
 func WriteAuth(writeToken, authToken string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -290,6 +288,49 @@ func WriteAuth(writeToken, authToken string) func(http.Handler) http.Handler {
 				next.ServeHTTP(w, r)
 				return
 			}
+			if r.URL.Path == "/api/v1/subscriptions" || strings.HasPrefix(r.URL.Path, "/api/v1/subscriptions/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if r.URL.Path == "/api/v1/saved-queries" || strings.HasPrefix(r.URL.Path, "/api/v1/saved-queries/") {
+				// Per-token like subscription profiles; the handler checks ownership.
+				next.ServeHTTP(w, r)
+				return
+			}
+			if userFrom(r) != nil && strings.HasPrefix(r.URL.Path, "/api/v1/auth/me/") {
+				// The signed-in user's own preferences and password.
+				next.ServeHTTP(w, r)
+				return
+			}
+			if strings.HasPrefix(r.URL.Path, "/api/v1/expressions/") {
+				// Read-only: validates/test-evaluates filter expressions.
+				next.ServeHTTP(w, r)
+				return
+			}
+			if strings.HasPrefix(r.URL.Path, "/api/v1/audio/webrtc/") {
+				// Listening: WebRTC signaling for live audio, the same read
+				// as GET /audio/live.
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if scope, ok := credentialScope(r); ok {
+				switch {
+				case scope == database.APIKeyScopeAdmin:
+					next.ServeHTTP(w, r)
+				case scope == database.APIKeyScopeWrite && !strings.HasPrefix(r.URL.Path, "/api/v1/admin/"):
+					next.ServeHTTP(w, r)
+				case scope == database.APIKeyScopeWrite && userFrom(r) != nil:
+					WriteErrorWithCode(w, http.StatusForbidden, ErrForbidden, "admin operations require the admin role")
+				case scope == database.APIKeyScopeWrite:
+					WriteErrorWithCode(w, http.StatusForbidden, ErrForbidden, "admin operations require an admin API key or WRITE_TOKEN")
+				case userFrom(r) != nil:
+					WriteErrorWithCode(w, http.StatusForbidden, ErrForbidden, "write operations require the write or admin role")
+				default:
+					WriteErrorWithCode(w, http.StatusForbidden, ErrForbidden, "write operations require a write or admin API key")
+				}
+				return
+			}
 
 			if writeToken == "" {
 				// Auth enabled but no WRITE_TOKEN → read-only
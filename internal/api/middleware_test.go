@@ -234,6 +234,76 @@ func TestBearerAuth(t *testing.T) {
 	})
 }
 
+func TestWriteAuth(t *testing.T) {
+	serve := func(method, path, token string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		WriteAuth("writer", "reader")(okHandler).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve("GET", "/api/v1/calls", "reader"); code != http.StatusOK {
+		t.Errorf("GET with read token: %d, want 200", code)
+	}
+	if code := serve("POST", "/api/v1/admin/feeds", "reader"); code != http.StatusForbidden {
+		t.Errorf("POST with read token: %d, want 403", code)
+	}
+	if code := serve("POST", "/api/v1/admin/feeds", "writer"); code != http.StatusOK {
+		t.Errorf("POST with write token: %d, want 200", code)
+	}
+	// Subscription profiles are per-token, so the read token manages its own
+	for _, path := range []string{"/api/v1/subscriptions", "/api/v1/subscriptions/fire"} {
+		if code := serve("POST", path, "reader"); code != http.StatusOK {
+			t.Errorf("POST %s with read token: %d, want 200", path, code)
+		}
+	}
+	for _, path := range []string{"/api/v1/saved-queries", "/api/v1/saved-queries/3"} {
+		if code := serve("POST", path, "reader"); code != http.StatusOK {
+			t.Errorf("POST %s with read token: %d, want 200", path, code)
+		}
+	}
+	// WebRTC live audio signaling is listening, not a change
+	if code := serve("POST", "/api/v1/audio/webrtc/sessions", "reader"); code != http.StatusOK {
+		t.Errorf("POST webrtc session with read token: %d, want 200", code)
+	}
+	if code := serve("POST", "/api/v1/subscriptionsx", "reader"); code != http.StatusForbidden {
+		t.Errorf("POST to lookalike path: %d, want 403", code)
+	}
+}
+
+func TestAdminContext(t *testing.T) {
+	admin := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isAdmin(r) {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	})
+	serve := func(authEnabled bool, writeToken, token string) bool {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/v1/calls", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		AdminContext(authEnabled, writeToken)(admin).ServeHTTP(rec, req)
+		return rec.Code == http.StatusOK
+	}
+
+	if !serve(true, "writer", "writer") {
+		t.Error("write token should be admin")
+	}
+	if serve(true, "writer", "reader") {
+		t.Error("read token should not be admin")
+	}
+	if serve(true, "", "") {
+		t.Error("no WRITE_TOKEN: nobody should be admin")
+	}
+	if !serve(false, "", "") {
+		t.Error("auth disabled: everyone should be admin")
+	}
+}
+
 func TestUploadAuth(t *testing.T) {
 	token := "test-secret-token"
 
@@ -241,7 +311,7 @@ func TestUploadAuth(t *testing.T) {
 		rec := httptest.NewRecorder()
 		req := httptest.NewRequest("POST", "/api/v1/call-upload", nil)
 		req.Header.Set("Authorization", "Bearer "+token)
-		UploadAuth(token)(okHandler).ServeHTTP(rec, req)
+		UploadAuth(token, nil)(okHandler).ServeHTTP(rec, req)
 		if rec.Code != http.StatusOK {
 			t.Errorf("status = %d, want 200", rec.Code)
 		}
@@ -250,7 +320,7 @@ func TestUploadAuth(t *testing.T) {
 	t.Run("query_param_token", func(t *testing.T) {
 		rec := httptest.NewRecorder()
 		req := httptest.NewRequest("POST", "/api/v1/call-upload?token="+token, nil)
-		UploadAuth(token)(okHandler).ServeHTTP(rec, req)
+		UploadAuth(token, nil)(okHandler).ServeHTTP(rec, req)
 		if rec.Code != http.StatusOK {
 			t.Errorf("status = %d, want 200", rec.Code)
 		}
@@ -259,7 +329,7 @@ func TestUploadAuth(t *testing.T) {
 	t.Run("no_auth", func(t *testing.T) {
 		rec := httptest.NewRecorder()
 		req := httptest.NewRequest("POST", "/api/v1/call-upload", nil)
-		UploadAuth(token)(okHandler).ServeHTTP(rec, req)
+		UploadAuth(token, nil)(okHandler).ServeHTTP(rec, req)
 		if rec.Code != http.StatusUnauthorized {
 			t.Errorf("status = %d, want 401", rec.Code)
 		}
@@ -269,7 +339,7 @@ func TestUploadAuth(t *testing.T) {
 		rec := httptest.NewRecorder()
 		req := httptest.NewRequest("POST", "/api/v1/call-upload", nil)
 		req.Header.Set("Authorization", "Bearer wrong-token")
-		UploadAuth(token)(okHandler).ServeHTTP(rec, req)
+		UploadAuth(token, nil)(okHandler).ServeHTTP(rec, req)
 		if rec.Code != http.StatusUnauthorized {
 			t.Errorf("status = %d, want 401", rec.Code)
 		}
@@ -285,7 +355,7 @@ func TestUploadAuth(t *testing.T) {
 		rec := httptest.NewRecorder()
 		req := httptest.NewRequest("POST", "/api/v1/call-upload", body)
 		req.Header.Set("Content-Type", writer.FormDataContentType())
-		UploadAuth(token)(okHandler).ServeHTTP(rec, req)
+		UploadAuth(token, nil)(okHandler).ServeHTTP(rec, req)
 		if rec.Code != http.StatusOK {
 			t.Errorf("status = %d, want 200 (rdio-scanner key field)", rec.Code)
 		}
@@ -301,7 +371,7 @@ func TestUploadAuth(t *testing.T) {
 		rec := httptest.NewRecorder()
 		req := httptest.NewRequest("POST", "/api/v1/call-upload", body)
 		req.Header.Set("Content-Type", writer.FormDataContentType())
-		UploadAuth(token)(okHandler).ServeHTTP(rec, req)
+		UploadAuth(token, nil)(okHandler).ServeHTTP(rec, req)
 		if rec.Code != http.StatusOK {
 			t.Errorf("status = %d, want 200 (OpenMHz api_key field)", rec.Code)
 		}
@@ -316,7 +386,7 @@ func TestUploadAuth(t *testing.T) {
 		rec := httptest.NewRecorder()
 		req := httptest.NewRequest("POST", "/api/v1/call-upload", body)
 		req.Header.Set("Content-Type", writer.FormDataContentType())
-		UploadAuth(token)(okHandler).ServeHTTP(rec, req)
+		UploadAuth(token, nil)(okHandler).ServeHTTP(rec, req)
 		if rec.Code != http.StatusUnauthorized {
 			t.Errorf("status = %d, want 401", rec.Code)
 		}
@@ -325,7 +395,7 @@ func TestUploadAuth(t *testing.T) {
 	t.Run("empty_token_passes_all", func(t *testing.T) {
 		rec := httptest.NewRecorder()
 		req := httptest.NewRequest("POST", "/api/v1/call-upload", nil)
-		UploadAuth("")(okHandler).ServeHTTP(rec, req)
+		UploadAuth("", nil)(okHandler).ServeHTTP(rec, req)
 		if rec.Code != http.StatusOK {
 			t.Errorf("status = %d, want 200", rec.Code)
 		}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/mqttclient"
+)
+
+// MQTTHandler serves MQTT ingest introspection.
+type MQTTHandler struct {
+	mqtt *mqttclient.Client // nil when MQTT ingest is off
+	live LiveDataSource
+}
+
+func NewMQTTHandler(mqtt *mqttclient.Client, live LiveDataSource) *MQTTHandler {
+	return &MQTTHandler{mqtt: mqtt, live: live}
+}
+
+// GetTopicMap returns the MQTT_INSTANCE_MAP in use — which TR instance
+// messages under each topic prefix are attributed to, with message counts —
+// and the topic filters subscribed to.
+// GET /api/v1/admin/mqtt/topic-map
+func (h *MQTTHandler) GetTopicMap(w http.ResponseWriter, r *http.Request) {
+	if h.live == nil {
+		WriteError(w, http.StatusServiceUnavailable, "pipeline not running")
+		return
+	}
+	data := h.live.TopicMap()
+	if data == nil {
+		data = &TopicMapData{}
+	}
+	if data.Mappings == nil {
+		data.Mappings = []TopicMappingData{}
+	}
+	data.Subscriptions = []string{}
+	if h.mqtt != nil {
+		data.Subscriptions = h.mqtt.Topics()
+	}
+	WriteJSON(w, http.StatusOK, data)
+}
+
+// Routes registers MQTT introspection routes on the given router.
+func (h *MQTTHandler) Routes(r chi.Router) {
+	r.Get("/admin/mqtt/topic-map", h.GetTopicMap)
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// maxOccupancyWindow bounds heard_within.
+const maxOccupancyWindow = 24 * time.Hour
+
+// TalkgroupOccupancy is one talkgroup's row on the occupancy board.
+type TalkgroupOccupancy struct {
+	SystemID        int             `json:"system_id"`
+	SystemName      string          `json:"system_name,omitempty"`
+	Tgid            int             `json:"tgid"`
+	AlphaTag        string          `json:"alpha_tag,omitempty"`
+	Description     string          `json:"description,omitempty"`
+	Tag             string          `json:"tag,omitempty"`
+	Group           string          `json:"group,omitempty"`
+	State           string          `json:"state"`             // "active" or "idle"
+	ActiveCall      *ActiveCallData `json:"active_call"`       // longest-running in-progress call
+	ActiveCallCount int             `json:"active_call_count"` // several sites may carry the talkgroup
+	ActiveSeconds   *float64        `json:"active_seconds"`    // since the longest-running call started
+	Emergency       bool            `json:"emergency"`         // any in-progress call is an emergency
+	LastCallAt      *time.Time      `json:"last_call_at"`      // start of the most recent call
+	IdleSeconds     *float64        `json:"idle_seconds"`      // since last_call_at; null while active
+	Calls1h         int             `json:"calls_1h"`
+}
+
+type OccupancyHandler struct {
+	db   *database.DB
+	live LiveDataSource
+}
+
+func NewOccupancyHandler(db *database.DB, live LiveDataSource) *OccupancyHandler {
+	return &OccupancyHandler{db: db, live: live}
+}
+
+// GetOccupancy returns the current state of every talkgroup heard within
+// heard_within seconds (default 3600) or in progress now: active ones first,
+// longest-running first, then idle ones, most recent first. Live updates are
+// the occupancy SSE events.
+func (h *OccupancyHandler) GetOccupancy(w http.ResponseWriter, r *http.Request) {
+	window := time.Hour
+	if v, ok := QueryInt(r, "heard_within"); ok {
+		if v < 1 || time.Duration(v)*time.Second > maxOccupancyWindow {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "heard_within must be between 1 and 86400 seconds")
+			return
+		}
+		window = time.Duration(v) * time.Second
+	}
+	systemIDs := QueryIntList(r, "system_id")
+	tgids := QueryIntList(r, "tgid")
+
+	now := time.Now()
+	activity, err := h.db.ListTalkgroupOccupancy(r.Context(), now.Add(-window), systemIDs, tgids)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to load talkgroup activity")
+		return
+	}
+
+	type key struct{ systemID, tgid int }
+	board := make(map[key]*TalkgroupOccupancy, len(activity))
+	for _, a := range activity {
+		board[key{a.SystemID, a.Tgid}] = &TalkgroupOccupancy{
+			SystemID:    a.SystemID,
+			SystemName:  a.SystemName,
+			Tgid:        a.Tgid,
+			AlphaTag:    a.AlphaTag,
+			Description: a.Description,
+			Tag:         a.Tag,
+			Group:       a.Group,
+			LastCallAt:  a.LastSeen,
+			Calls1h:     a.Calls1h,
+		}
+	}
+
+	if h.live != nil {
+		admin := isAdmin(r)
+		for _, c := range h.live.ActiveCalls() {
+			if c.Restricted && !admin {
+				continue
+			}
+			if len(systemIDs) > 0 && !intSliceContains(systemIDs, c.SystemID) {
+				continue
+			}
+			if len(tgids) > 0 && !intSliceContains(tgids, c.Tgid) {
+				continue
+			}
+			o, ok := board[key{c.SystemID, c.Tgid}]
+			if !ok {
+				// Not yet in the talkgroup table (first call on a new talkgroup)
+				o = &TalkgroupOccupancy{
+					SystemID:    c.SystemID,
+					SystemName:  c.SystemName,
+					Tgid:        c.Tgid,
+					AlphaTag:    c.TgAlphaTag,
+					Description: c.TgDescription,
+					Tag:         c.TgTag,
+					Group:       c.TgGroup,
+				}
+				board[key{c.SystemID, c.Tgid}] = o
+			}
+			o.ActiveCallCount++
+			o.Emergency = o.Emergency || c.Emergency
+			if o.ActiveCall == nil || c.StartTime.Before(o.ActiveCall.StartTime) {
+				call := c
+				o.ActiveCall = &call
+			}
+			if o.LastCallAt == nil || c.StartTime.After(*o.LastCallAt) {
+				t := c.StartTime
+				o.LastCallAt = &t
+			}
+		}
+	}
+
+	result := make([]TalkgroupOccupancy, 0, len(board))
+	active := 0
+	for _, o := range board {
+		if o.ActiveCall != nil {
+			o.State = "active"
+			secs := now.Sub(o.ActiveCall.StartTime).Seconds()
+			o.ActiveSeconds = &secs
+			active++
+		} else {
+			o.State = "idle"
+			if o.LastCallAt != nil {
+				secs := now.Sub(*o.LastCallAt).Seconds()
+				o.IdleSeconds = &secs
+			}
+		}
+		result = append(result, *o)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if (a.ActiveCall != nil) != (b.ActiveCall != nil) {
+			return a.ActiveCall != nil
+		}
+		if a.ActiveCall != nil && !a.ActiveCall.StartTime.Equal(b.ActiveCall.StartTime) {
+			return a.ActiveCall.StartTime.Before(b.ActiveCall.StartTime)
+		}
+		if a.LastCallAt != nil && b.LastCallAt != nil && !a.LastCallAt.Equal(*b.LastCallAt) {
+			return a.LastCallAt.After(*b.LastCallAt)
+		}
+		if a.SystemID != b.SystemID {
+			return a.SystemID < b.SystemID
+		}
+		return a.Tgid < b.Tgid
+	})
+
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"talkgroups": result,
+		"total":      len(result),
+		"active":     active,
+		"as_of":      now,
+	})
+}
+
+func (h *OccupancyHandler) Routes(r chi.Router) {
+	r.Get("/live/occupancy", h.GetOccupancy)
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+var quarantineStatuses = map[string]bool{"pending": true, "reprocessed": true, "failed": true}
+
+// ListQuarantine returns messages rejected by ingest validation, newest first.
+func (h *AdminHandler) ListQuarantine(w http.ResponseWriter, r *http.Request) {
+	p, err := ParsePagination(r)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+	filter := database.QuarantineFilter{Limit: p.Limit, Offset: p.Offset}
+	if v, ok := QueryString(r, "handler"); ok {
+		filter.Handler = &v
+	}
+	if v, ok := QueryString(r, "status"); ok {
+		if !quarantineStatuses[v] {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "status must be pending, reprocessed, or failed")
+			return
+		}
+		filter.Status = &v
+	}
+
+	msgs, total, err := h.db.ListQuarantinedMessages(r.Context(), filter)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list quarantined messages")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"messages": msgs,
+		"total":    total,
+	})
+}
+
+// GetQuarantined returns a single quarantined message including its payload.
+// JSON payloads are embedded as-is; anything else is returned as a string.
+func (h *AdminHandler) GetQuarantined(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt64(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid quarantine ID")
+		return
+	}
+	msg, err := h.db.GetQuarantinedMessage(r.Context(), id)
+	if err != nil {
+		if err.Error() == "quarantined message not found" {
+			WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to get quarantined message")
+		return
+	}
+
+	var payload any = string(msg.Payload)
+	if json.Valid(msg.Payload) {
+		payload = json.RawMessage(msg.Payload)
+	}
+	WriteJSON(w, http.StatusOK, struct {
+		*database.QuarantinedMessage
+		Payload any `json:"payload"`
+	}{msg, payload})
+}
+
+// ReprocessQuarantined re-validates a quarantined message and runs it through
+// its handler. ?force=true skips validation.
+func (h *AdminHandler) ReprocessQuarantined(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt64(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid quarantine ID")
+		return
+	}
+	if h.live == nil {
+		WriteError(w, http.StatusServiceUnavailable, "pipeline not running")
+		return
+	}
+	force, _ := QueryBool(r, "force")
+	result, err := h.live.ReprocessQuarantined(r.Context(), id, force)
+	if err != nil {
+		if err.Error() == "quarantined message not found" {
+			WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	WriteJSON(w, http.StatusOK, result)
+}
+
+// DeleteQuarantined discards a quarantined message.
+func (h *AdminHandler) DeleteQuarantined(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt64(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid quarantine ID")
+		return
+	}
+	if err := h.db.DeleteQuarantinedMessage(r.Context(), id); err != nil {
+		if err.Error() == "quarantined message not found" {
+			WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to delete quarantined message")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/snarg/tr-engine/internal/metrics"
+)
+
+// Rate limits are token buckets layered from broadest to most specific:
+// per client IP on every route (RATE_LIMIT_RPS), then on the authenticated
+// API all clients combined (RATE_LIMIT_GLOBAL_RPS), per bearer token
+// (RATE_LIMIT_TOKEN_RPS, or an API key's own limit), and per client IP on expensive endpoints such as
+// search and exports (RATE_LIMIT_EXPENSIVE_PER_MIN). Every limited response
+// carries RateLimit-Limit/Remaining/Reset/Policy headers for the most
+// specific limit that applied; rejected requests get a 429 with Retry-After.
+
+// limiterSet holds a token bucket per client key.
+type limiterSet struct {
+	scope string // metrics label and 429 detail, e.g. "ip" or "token"
+	rps   float64
+	burst int
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+func newLimiterSet(scope string, rps float64, burst int) *limiterSet {
+	s := &limiterSet{
+		scope:    scope,
+		rps:      rps,
+		burst:    max(burst, 1),
+		limiters: make(map[string]*rate.Limiter),
+	}
+
+	// Background cleanup of stale entries every 5 minutes
+	go func() {
+		for {
+			time.Sleep(5 * time.Minute)
+			s.mu.Lock()
+			// Simple strategy: clear the map periodically.
+			// Active clients will re-create their limiter on next request.
+			s.limiters = make(map[string]*rate.Limiter)
+			s.mu.Unlock()
+		}
+	}()
+	return s
+}
+
+func (s *limiterSet) get(key string) *rate.Limiter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if lim, ok := s.limiters[key]; ok {
+		return lim
+	}
+	lim := rate.NewLimiter(rate.Limit(s.rps), s.burst)
+	s.limiters[key] = lim
+	return lim
+}
+
+// allow takes a token for key and sets the RateLimit headers. When the
+// bucket is empty it writes a 429 with Retry-After and returns false.
+func (s *limiterSet) allow(w http.ResponseWriter, key string) bool {
+	lim := s.get(key)
+	now := time.Now()
+	res := lim.ReserveN(now, 1)
+	delay := res.DelayFrom(now)
+	if delay > 0 {
+		res.CancelAt(now)
+	}
+	s.setHeaders(w, lim.TokensAt(now))
+	if delay <= 0 {
+		return true
+	}
+
+	retry := int(math.Ceil(delay.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retry))
+	metrics.RateLimitedRequestsTotal.WithLabelValues(s.scope).Inc()
+	WriteErrorWithCodeDetail(w, http.StatusTooManyRequests, ErrRateLimited, "rate limit exceeded",
+		fmt.Sprintf("%s limit of %s; retry after %ds", s.scope, s.policy(), retry))
+	return false
+}
+
+// policy describes the bucket as "burst;w=seconds": burst requests, refilled
+// over the window.
+func (s *limiterSet) policy() string {
+	return fmt.Sprintf("%d;w=%d", s.burst, int(math.Ceil(float64(s.burst)/s.rps)))
+}
+
+// setHeaders writes the IETF RateLimit fields for a bucket holding tokens.
+func (s *limiterSet) setHeaders(w http.ResponseWriter, tokens float64) {
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(s.burst))
+	h.Set("RateLimit-Remaining", strconv.Itoa(max(0, int(tokens))))
+	h.Set("RateLimit-Reset", strconv.Itoa(max(0, int(math.Ceil((float64(s.burst)-tokens)/s.rps)))))
+	h.Set("RateLimit-Policy", s.policy())
+}
+
+// limitBy returns middleware that rate limits requests by key(r). Requests
+// for which key returns "" are not limited.
+func limitBy(s *limiterSet, key func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if k := key(r); k != "" && !s.allow(w, k) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RateLimiter returns middleware that applies per-IP rate limiting.
+// rps is requests per second, burst is the maximum burst size.
+func RateLimiter(rps float64, burst int) func(http.Handler) http.Handler {
+	return limitBy(newLimiterSet("ip", rps, burst), clientIP)
+}
+
+// GlobalRateLimiter limits all requests combined, protecting the database
+// from many clients at once.
+func GlobalRateLimiter(rps float64, burst int) func(http.Handler) http.Handler {
+	return limitBy(newLimiterSet("global", rps, burst), func(*http.Request) string { return "all" })
+}
+
+// TokenRateLimiter limits requests per bearer token. Requests without a token
+// are left to the per-IP limit. Note that web UI pages all share AUTH_TOKEN.
+// Each share link counts as one token, however many people use it. API keys
+// with a rate limit of their own are left to APIKeyRateLimiter. Signed-in
+// users are limited per user.
+func TokenRateLimiter(rps float64, burst int) func(http.Handler) http.Handler {
+	return limitBy(newLimiterSet("token", rps, burst), func(r *http.Request) string {
+		if share := shareScopeFrom(r); share != nil {
+			return "share-" + strconv.Itoa(share.LinkID)
+		}
+		if key := apiKeyFrom(r); key != nil && key.RateLimitRPS != nil {
+			return ""
+		}
+		if user := userFrom(r); user != nil {
+			return "user-" + strconv.Itoa(user.ID)
+		}
+		tok := extractBearerToken(r)
+		if tok == "" {
+			return ""
+		}
+		sum := sha256.Sum256([]byte(tok))
+		return hex.EncodeToString(sum[:16])
+	})
+}
+
+// ExpensiveRateLimiter applies a per-IP limit of perMinute requests (burst
+// burst) to requests whose path starts with one of prefixes.
+func ExpensiveRateLimiter(perMinute float64, burst int, prefixes []string) func(http.Handler) http.Handler {
+	return limitBy(newLimiterSet("expensive", perMinute/60, burst), func(r *http.Request) string {
+		for _, p := range prefixes {
+			if strings.HasPrefix(r.URL.Path, p) {
+				return clientIP(r)
+			}
+		}
+		return ""
+	})
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func doLimited(h http.Handler, path, ip, token string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", path, nil)
+	req.RemoteAddr = ip + ":1234"
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	h := RateLimiter(1, 3)(okHandler)
+
+	rec := doLimited(h, "/", "1.1.1.1", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	for name, want := range map[string]string{
+		"RateLimit-Limit":     "3",
+		"RateLimit-Remaining": "2",
+		"RateLimit-Reset":     "1",
+		"RateLimit-Policy":    "3;w=3",
+	} {
+		if got := rec.Header().Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	doLimited(h, "/", "1.1.1.1", "")
+	doLimited(h, "/", "1.1.1.1", "")
+	rec = doLimited(h, "/", "1.1.1.1", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("RateLimit-Remaining") != "0" || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("remaining = %q, retry-after = %q", rec.Header().Get("RateLimit-Remaining"), rec.Header().Get("Retry-After"))
+	}
+	var body ErrorResponse
+	json.NewDecoder(rec.Body).Decode(&body)
+	if body.Code != ErrRateLimited || !strings.Contains(body.Detail, "ip limit") {
+		t.Errorf("body = %+v", body)
+	}
+}
+
+func TestTokenRateLimiter(t *testing.T) {
+	h := TokenRateLimiter(1, 1)(okHandler)
+
+	if rec := doLimited(h, "/", "1.1.1.1", "tok-a"); rec.Code != http.StatusOK {
+		t.Fatalf("first request: expected 200, got %d", rec.Code)
+	}
+	// Same token from another IP shares the bucket
+	if rec := doLimited(h, "/", "2.2.2.2", "tok-a"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("same token: expected 429, got %d", rec.Code)
+	}
+	if rec := doLimited(h, "/", "1.1.1.1", "tok-b"); rec.Code != http.StatusOK {
+		t.Errorf("other token: expected 200, got %d", rec.Code)
+	}
+	// No token: not limited here
+	for i := 0; i < 3; i++ {
+		if rec := doLimited(h, "/", "1.1.1.1", ""); rec.Code != http.StatusOK {
+			t.Errorf("tokenless request %d: expected 200, got %d", i, rec.Code)
+		}
+	}
+}
+
+func TestGlobalRateLimiter(t *testing.T) {
+	h := GlobalRateLimiter(1, 1)(okHandler)
+	doLimited(h, "/", "1.1.1.1", "")
+	if rec := doLimited(h, "/", "2.2.2.2", ""); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 across clients, got %d", rec.Code)
+	}
+}
+
+func TestExpensiveRateLimiter(t *testing.T) {
+	h := ExpensiveRateLimiter(6, 1, []string{"/api/v1/transcriptions/search", "/api/v1/query"})(okHandler)
+
+	if rec := doLimited(h, "/api/v1/transcriptions/search", "1.1.1.1", ""); rec.Code != http.StatusOK {
+		t.Fatalf("first search: expected 200, got %d", rec.Code)
+	}
+	rec := doLimited(h, "/api/v1/query", "1.1.1.1", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second expensive request: expected 429, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "10" {
+		t.Errorf("Retry-After = %q, want 10", rec.Header().Get("Retry-After"))
+	}
+	// Cheap endpoints and other clients are unaffected
+	if rec := doLimited(h, "/api/v1/calls", "1.1.1.1", ""); rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("cheap endpoint: code %d, RateLimit-Limit %q", rec.Code, rec.Header().Get("RateLimit-Limit"))
+	}
+	if rec := doLimited(h, "/api/v1/query", "2.2.2.2", ""); rec.Code != http.StatusOK {
+		t.Errorf("other IP: expected 200, got %d", rec.Code)
+	}
+}
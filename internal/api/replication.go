@@ -0,0 +1,106 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/replicate"
+)
+
+type ReplicationHandler struct {
+	db         *database.DB
+	replicator *replicate.Replicator // nil when REPLICATE_URL is unset
+}
+
+func NewReplicationHandler(db *database.DB, replicator *replicate.Replicator) *ReplicationHandler {
+	return &ReplicationHandler{db: db, replicator: replicator}
+}
+
+func (h *ReplicationHandler) available(w http.ResponseWriter) bool {
+	if h.replicator == nil {
+		WriteError(w, http.StatusServiceUnavailable, "replication not enabled (set REPLICATE_URL)")
+		return false
+	}
+	return true
+}
+
+// GetReplicationStatus reports how many calls have reached the central
+// instance, how many are pending, failing or rejected, and run progress.
+func (h *ReplicationHandler) GetReplicationStatus(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	status, err := h.replicator.Status(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get replication status")
+		return
+	}
+	WriteJSON(w, http.StatusOK, status)
+}
+
+// RunReplication starts a replication run now, outside REPLICATE_WINDOW if
+// need be.
+func (h *ReplicationHandler) RunReplication(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	if err := h.replicator.Run(); err != nil {
+		WriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+	status, err := h.replicator.Status(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get replication status")
+		return
+	}
+	WriteJSON(w, http.StatusAccepted, status)
+}
+
+// ListReplicationFailures returns calls whose replication is failing or was
+// rejected by the central instance.
+func (h *ReplicationHandler) ListReplicationFailures(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	limit := 100
+	if v, ok := QueryInt(r, "limit"); ok {
+		if v < 1 || v > 1000 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "limit must be between 1 and 1000")
+			return
+		}
+		limit = v
+	}
+	failures, err := h.db.ListReplicationFailures(r.Context(), limit)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list replication failures")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"failures": failures,
+		"total":    len(failures),
+	})
+}
+
+// ResetReplicationFailures makes failing and rejected calls eligible again,
+// e.g. after fixing the central's REPLICATE_TOKEN or approving the edge's
+// systems there.
+func (h *ReplicationHandler) ResetReplicationFailures(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	n, err := h.db.ResetReplicationFailures(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to reset replication failures")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"reset": n})
+}
+
+func (h *ReplicationHandler) Routes(r chi.Router) {
+	r.Get("/admin/replication", h.GetReplicationStatus)
+	r.Post("/admin/replication/run", h.RunReplication)
+	r.Get("/admin/replication/failures", h.ListReplicationFailures)
+	r.Post("/admin/replication/failures/reset", h.ResetReplicationFailures)
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/transcribe"
+)
+
+// RetranscribeHandler manages bulk re-transcription jobs: a time range and
+// talkgroups re-run through another STT provider or model.
+type RetranscribeHandler struct {
+	db *database.DB
+	rt *transcribe.Retranscriber // nil when transcription is disabled
+}
+
+func NewRetranscribeHandler(db *database.DB, rt *transcribe.Retranscriber) *RetranscribeHandler {
+	return &RetranscribeHandler{db: db, rt: rt}
+}
+
+// retranscribeJobResponse adds the estimated time left to a job.
+type retranscribeJobResponse struct {
+	*database.RetranscribeJob
+	EstimatedSeconds *float64 `json:"estimated_seconds"` // from the provider's recent real-time ratio
+}
+
+func (h *RetranscribeHandler) available(w http.ResponseWriter) bool {
+	if h.rt == nil {
+		WriteError(w, http.StatusServiceUnavailable, "transcription not enabled (set STT_PROVIDER)")
+		return false
+	}
+	return true
+}
+
+func (h *RetranscribeHandler) response(j *database.RetranscribeJob) retranscribeJobResponse {
+	resp := retranscribeJobResponse{RetranscribeJob: j}
+	if j.Status == database.RetranscribePending || j.Status == database.RetranscribeRunning {
+		resp.EstimatedSeconds = h.rt.EstimatedSeconds(j)
+	}
+	return resp
+}
+
+// CreateRetranscribeJob creates a pending job and returns it with its
+// estimate: calls selected, audio duration and cost. The job runs once
+// started.
+func (h *RetranscribeHandler) CreateRetranscribeJob(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	var req struct {
+		StartTime   time.Time  `json:"start_time"`
+		EndTime     *time.Time `json:"end_time"`
+		SystemIDs   []int      `json:"system_ids"`
+		Tgids       []int      `json:"tgids"`
+		Provider    string     `json:"provider"`
+		Model       string     `json:"model"`
+		MakePrimary *bool      `json:"make_primary"`
+		Concurrency int        `json:"concurrency"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	now := time.Now()
+	end := now
+	if req.EndTime != nil && req.EndTime.Before(now) {
+		end = *req.EndTime
+	}
+	if req.StartTime.IsZero() || !req.StartTime.Before(end) {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, "start_time is required and must be before end_time")
+		return
+	}
+	req.Provider = strings.TrimSpace(req.Provider)
+	if req.Provider == "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "provider is required")
+		return
+	}
+	if req.Concurrency == 0 {
+		req.Concurrency = 1
+	}
+	if req.Concurrency < 1 || req.Concurrency > h.rt.MaxConcurrency() {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "concurrency must be between 1 and RETRANSCRIBE_MAX_CONCURRENCY")
+		return
+	}
+
+	if len(req.SystemIDs) == 0 {
+		req.SystemIDs = nil
+	}
+	if len(req.Tgids) == 0 {
+		req.Tgids = nil
+	}
+
+	job := &database.RetranscribeJob{
+		StartTime:   req.StartTime,
+		EndTime:     end,
+		SystemIDs:   req.SystemIDs,
+		Tgids:       req.Tgids,
+		Provider:    req.Provider,
+		Model:       strings.TrimSpace(req.Model),
+		MakePrimary: req.MakePrimary == nil || *req.MakePrimary,
+		Concurrency: req.Concurrency,
+	}
+	if err := h.rt.Prepare(r.Context(), job); err != nil {
+		if errors.Is(err, transcribe.ErrProviderUnavailable) {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to estimate re-transcription job")
+		return
+	}
+	if err := h.db.CreateRetranscribeJob(r.Context(), job); err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to create re-transcription job")
+		return
+	}
+	WriteJSON(w, http.StatusCreated, h.response(job))
+}
+
+// ListRetranscribeJobs returns recent jobs, newest first.
+func (h *RetranscribeHandler) ListRetranscribeJobs(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	limit := 50
+	if v, ok := QueryInt(r, "limit"); ok {
+		if v < 1 || v > 500 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "limit must be between 1 and 500")
+			return
+		}
+		limit = v
+	}
+	jobs, err := h.db.ListRetranscribeJobs(r.Context(), limit)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list re-transcription jobs")
+		return
+	}
+	result := make([]retranscribeJobResponse, len(jobs))
+	for i := range jobs {
+		result[i] = h.response(&jobs[i])
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"jobs":  result,
+		"total": len(result),
+	})
+}
+
+// GetRetranscribeJob returns a job and its progress.
+func (h *RetranscribeHandler) GetRetranscribeJob(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid job ID")
+		return
+	}
+	job, err := h.db.GetRetranscribeJob(r.Context(), id)
+	if err != nil {
+		h.writeJobError(w, err, "failed to get re-transcription job")
+		return
+	}
+	WriteJSON(w, http.StatusOK, h.response(job))
+}
+
+// StartRetranscribeJob starts a pending job. Jobs run one at a time in the
+// order started.
+func (h *RetranscribeHandler) StartRetranscribeJob(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid job ID")
+		return
+	}
+	job, err := h.db.StartRetranscribeJob(r.Context(), id)
+	if err != nil {
+		h.writeJobError(w, err, "failed to start re-transcription job")
+		return
+	}
+	h.rt.Wake()
+	WriteJSON(w, http.StatusOK, h.response(job))
+}
+
+// CancelRetranscribeJob cancels a pending or running job. Transcripts
+// already stored are kept.
+func (h *RetranscribeHandler) CancelRetranscribeJob(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid job ID")
+		return
+	}
+	job, err := h.db.CancelRetranscribeJob(r.Context(), id)
+	if err != nil {
+		h.writeJobError(w, err, "failed to cancel re-transcription job")
+		return
+	}
+	h.rt.Cancel(id)
+	WriteJSON(w, http.StatusOK, h.response(job))
+}
+
+func (h *RetranscribeHandler) writeJobError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case err.Error() == "retranscribe job not found":
+		WriteError(w, http.StatusNotFound, err.Error())
+	case strings.HasPrefix(err.Error(), "retranscribe job is "):
+		WriteError(w, http.StatusConflict, err.Error())
+	default:
+		WriteError(w, http.StatusInternalServerError, msg)
+	}
+}
+
+func (h *RetranscribeHandler) Routes(r chi.Router) {
+	r.Get("/admin/retranscribe-jobs", h.ListRetranscribeJobs)
+	r.Post("/admin/retranscribe-jobs", h.CreateRetranscribeJob)
+	r.Get("/admin/retranscribe-jobs/{id}", h.GetRetranscribeJob)
+	r.Post("/admin/retranscribe-jobs/{id}/start", h.StartRetranscribeJob)
+	r.Post("/admin/retranscribe-jobs/{id}/cancel", h.CancelRetranscribeJob)
+}
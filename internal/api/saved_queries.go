@@ -0,0 +1,319 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// Saved queries: named call/search filters teams rebuild constantly
+// ("overnight fire traffic county-wide"), stored as query parameters and run
+// by ID with ?saved_query= on the endpoints in savedQueryPaths. Like
+// subscription profiles they belong to the token that created them and any
+// valid token may manage its own; shared ones are visible to every token.
+
+// SavedQueryStore loads and manages saved queries.
+type SavedQueryStore interface {
+	ListSavedQueries(ctx context.Context, owner, search string, limit, offset int) ([]database.SavedQuery, int, error)
+	GetSavedQuery(ctx context.Context, owner string, id int) (*database.SavedQuery, error)
+	CreateSavedQuery(ctx context.Context, owner string, q *database.SavedQuery) error
+	UpdateSavedQuery(ctx context.Context, owner string, id int, u database.SavedQueryUpdate) (*database.SavedQuery, error)
+	DeleteSavedQuery(ctx context.Context, owner string, id int, anyOwner bool) error
+}
+
+type SavedQueriesHandler struct {
+	db          SavedQueryStore
+	authEnabled bool
+}
+
+func NewSavedQueriesHandler(db SavedQueryStore, authEnabled bool) *SavedQueriesHandler {
+	return &SavedQueriesHandler{db: db, authEnabled: authEnabled}
+}
+
+const (
+	maxSavedQueryName   = 100
+	maxSavedQueryParams = 50
+)
+
+// savedQueryPaths are the endpoints that run a saved query.
+var savedQueryPaths = map[string]bool{
+	"/api/v1/calls":                 true,
+	"/api/v1/calls/timeline":        true,
+	"/api/v1/transcriptions/search": true,
+}
+
+var savedQueryParamRe = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// savedQueryTimeParams may hold a duration relative to when the query runs
+// ("-12h") instead of an RFC 3339 time.
+var savedQueryTimeParams = map[string]bool{"start_time": true, "end_time": true}
+
+type savedQueryRequest struct {
+	Name        *string           `json:"name"`
+	Description *string           `json:"description"`
+	Filter      map[string]string `json:"filter"`
+	Shared      *bool             `json:"shared"`
+}
+
+// validateSavedQueryFilter checks a saved query's parameters. Returns "" if
+// valid.
+func validateSavedQueryFilter(filter map[string]string) string {
+	if len(filter) > maxSavedQueryParams {
+		return fmt.Sprintf("filter may have at most %d parameters", maxSavedQueryParams)
+	}
+	for k, v := range filter {
+		if !savedQueryParamRe.MatchString(k) {
+			return fmt.Sprintf("filter parameter %q must be a lowercase query parameter name", k)
+		}
+		if k == "saved_query" {
+			return "filter may not contain saved_query"
+		}
+		if savedQueryTimeParams[k] {
+			if _, err := time.Parse(time.RFC3339, v); err == nil {
+				continue
+			}
+			if _, err := time.ParseDuration(v); err != nil {
+				return fmt.Sprintf("filter.%s must be an RFC 3339 time or a duration relative to now (e.g. -12h)", k)
+			}
+		}
+	}
+	return ""
+}
+
+func validateSavedQueryName(name string) string {
+	if name == "" || len(name) > maxSavedQueryName {
+		return fmt.Sprintf("name is required and must be at most %d characters", maxSavedQueryName)
+	}
+	return ""
+}
+
+// savedQueryID parses the {id} path parameter, writing a 400 if invalid.
+func savedQueryID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id < 1 {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "invalid saved query id")
+		return 0, false
+	}
+	return id, true
+}
+
+// ListSavedQueries returns the caller's saved queries and shared ones by
+// name; ?q= matches name or description.
+func (h *SavedQueriesHandler) ListSavedQueries(w http.ResponseWriter, r *http.Request) {
+	p, err := ParsePagination(r)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+	search, _ := QueryString(r, "q")
+	queries, total, err := h.db.ListSavedQueries(r.Context(), profileOwner(r, h.authEnabled),
+		strings.TrimSpace(search), p.Limit, p.Offset)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list saved queries")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"saved_queries": queries,
+		"total":         total,
+		"limit":         p.Limit,
+		"offset":        p.Offset,
+	})
+}
+
+// GetSavedQuery returns one saved query the caller can see.
+func (h *SavedQueriesHandler) GetSavedQuery(w http.ResponseWriter, r *http.Request) {
+	id, ok := savedQueryID(w, r)
+	if !ok {
+		return
+	}
+	q, err := h.db.GetSavedQuery(r.Context(), profileOwner(r, h.authEnabled), id)
+	if err != nil {
+		if err.Error() == "saved query not found" {
+			WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to get saved query")
+		return
+	}
+	WriteJSON(w, http.StatusOK, q)
+}
+
+// CreateSavedQuery saves a query for the caller.
+// Body: {"name": "...", "description": "...", "filter": {"param": "value"}, "shared": false}
+func (h *SavedQueriesHandler) CreateSavedQuery(w http.ResponseWriter, r *http.Request) {
+	var req savedQueryRequest
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	q := &database.SavedQuery{Filter: req.Filter}
+	if req.Name != nil {
+		q.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		q.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.Shared != nil {
+		q.Shared = *req.Shared
+	}
+	if q.Filter == nil {
+		q.Filter = map[string]string{}
+	}
+	if msg := validateSavedQueryName(q.Name); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, msg)
+		return
+	}
+	if msg := validateSavedQueryFilter(q.Filter); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, msg)
+		return
+	}
+	if err := h.db.CreateSavedQuery(r.Context(), profileOwner(r, h.authEnabled), q); err != nil {
+		if err.Error() == "saved query already exists" {
+			WriteErrorWithCode(w, http.StatusConflict, ErrDuplicate, "you already have a saved query with this name")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to create saved query")
+		return
+	}
+	WriteJSON(w, http.StatusCreated, q)
+}
+
+// UpdateSavedQuery changes one of the caller's saved queries. A filter
+// replaces the whole filter.
+func (h *SavedQueriesHandler) UpdateSavedQuery(w http.ResponseWriter, r *http.Request) {
+	id, ok := savedQueryID(w, r)
+	if !ok {
+		return
+	}
+	var req savedQueryRequest
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	u := database.SavedQueryUpdate{Filter: req.Filter, Shared: req.Shared}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if msg := validateSavedQueryName(name); msg != "" {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, msg)
+			return
+		}
+		u.Name = &name
+	}
+	if req.Description != nil {
+		desc := strings.TrimSpace(*req.Description)
+		u.Description = &desc
+	}
+	if msg := validateSavedQueryFilter(req.Filter); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, msg)
+		return
+	}
+
+	owner := profileOwner(r, h.authEnabled)
+	q, err := h.db.UpdateSavedQuery(r.Context(), owner, id, u)
+	if err != nil {
+		switch err.Error() {
+		case "saved query not found":
+			// A shared query the caller can see but doesn't own
+			if _, getErr := h.db.GetSavedQuery(r.Context(), owner, id); getErr == nil {
+				WriteErrorWithCode(w, http.StatusForbidden, ErrForbidden, "only the owner can change a saved query")
+				return
+			}
+			WriteError(w, http.StatusNotFound, err.Error())
+		case "saved query already exists":
+			WriteErrorWithCode(w, http.StatusConflict, ErrDuplicate, "you already have a saved query with this name")
+		default:
+			WriteError(w, http.StatusInternalServerError, "failed to update saved query")
+		}
+		return
+	}
+	WriteJSON(w, http.StatusOK, q)
+}
+
+// DeleteSavedQuery removes one of the caller's saved queries. Admins
+// (WRITE_TOKEN) may remove any shared query.
+func (h *SavedQueriesHandler) DeleteSavedQuery(w http.ResponseWriter, r *http.Request) {
+	id, ok := savedQueryID(w, r)
+	if !ok {
+		return
+	}
+	owner := profileOwner(r, h.authEnabled)
+	anyOwner := false
+	if isAdmin(r) {
+		q, err := h.db.GetSavedQuery(r.Context(), owner, id)
+		anyOwner = err == nil && q.Shared
+	}
+	if err := h.db.DeleteSavedQuery(r.Context(), owner, id, anyOwner); err != nil {
+		if err.Error() == "saved query not found" {
+			WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to delete saved query")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SavedQueryParams runs ?saved_query={id} on the endpoints in
+// savedQueryPaths: the saved query's parameters are added to the request's,
+// and parameters given explicitly win, so a saved view can be narrowed
+// (?saved_query=3&tgid=100) or paged. Relative start_time/end_time are
+// resolved against now.
+func SavedQueryParams(db SavedQueryStore, authEnabled bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			v := r.URL.Query().Get("saved_query")
+			if v == "" || !savedQueryPaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			id, err := strconv.Atoi(v)
+			if err != nil || id < 1 {
+				WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "invalid saved_query id")
+				return
+			}
+			q, err := db.GetSavedQuery(r.Context(), profileOwner(r, authEnabled), id)
+			if err != nil {
+				if err.Error() == "saved query not found" {
+					WriteError(w, http.StatusNotFound, err.Error())
+					return
+				}
+				WriteError(w, http.StatusInternalServerError, "failed to load saved query")
+				return
+			}
+
+			params := r.URL.Query()
+			now := time.Now()
+			for k, val := range q.Filter {
+				if params.Has(k) {
+					continue
+				}
+				if savedQueryTimeParams[k] {
+					if d, err := time.ParseDuration(val); err == nil {
+						val = now.Add(d).UTC().Format(time.RFC3339)
+					}
+				}
+				params.Set(k, val)
+			}
+			params.Del("saved_query")
+			r2 := r.Clone(r.Context())
+			r2.URL.RawQuery = params.Encode()
+			next.ServeHTTP(w, r2)
+		})
+	}
+}
+
+func (h *SavedQueriesHandler) Routes(r chi.Router) {
+	r.Get("/saved-queries", h.ListSavedQueries)
+	r.Post("/saved-queries", h.CreateSavedQuery)
+	r.Get("/saved-queries/{id}", h.GetSavedQuery)
+	r.Patch("/saved-queries/{id}", h.UpdateSavedQuery)
+	r.Delete("/saved-queries/{id}", h.DeleteSavedQuery)
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// mockSavedQueryStore implements SavedQueryStore in memory.
+type mockSavedQueryStore struct {
+	queries map[int]database.SavedQuery
+	owners  map[int]string
+	nextID  int
+}
+
+func newMockSavedQueryStore() *mockSavedQueryStore {
+	return &mockSavedQueryStore{queries: map[int]database.SavedQuery{}, owners: map[int]string{}}
+}
+
+func (m *mockSavedQueryStore) view(owner string, id int) (*database.SavedQuery, bool) {
+	q, ok := m.queries[id]
+	if !ok || (m.owners[id] != owner && !q.Shared) {
+		return nil, false
+	}
+	q.Mine = m.owners[id] == owner
+	return &q, true
+}
+
+func (m *mockSavedQueryStore) ListSavedQueries(_ context.Context, owner, search string, _, _ int) ([]database.SavedQuery, int, error) {
+	list := []database.SavedQuery{}
+	for id := range m.queries {
+		if q, ok := m.view(owner, id); ok && strings.Contains(q.Name, search) {
+			list = append(list, *q)
+		}
+	}
+	return list, len(list), nil
+}
+
+func (m *mockSavedQueryStore) GetSavedQuery(_ context.Context, owner string, id int) (*database.SavedQuery, error) {
+	q, ok := m.view(owner, id)
+	if !ok {
+		return nil, fmt.Errorf("saved query not found")
+	}
+	return q, nil
+}
+
+func (m *mockSavedQueryStore) CreateSavedQuery(_ context.Context, owner string, q *database.SavedQuery) error {
+	for id, existing := range m.queries {
+		if m.owners[id] == owner && existing.Name == q.Name {
+			return fmt.Errorf("saved query already exists")
+		}
+	}
+	m.nextID++
+	q.ID, q.Mine = m.nextID, true
+	m.queries[q.ID], m.owners[q.ID] = *q, owner
+	return nil
+}
+
+func (m *mockSavedQueryStore) UpdateSavedQuery(_ context.Context, owner string, id int, u database.SavedQueryUpdate) (*database.SavedQuery, error) {
+	q, ok := m.queries[id]
+	if !ok || m.owners[id] != owner {
+		return nil, fmt.Errorf("saved query not found")
+	}
+	if u.Name != nil {
+		q.Name = *u.Name
+	}
+	if u.Filter != nil {
+		q.Filter = u.Filter
+	}
+	if u.Shared != nil {
+		q.Shared = *u.Shared
+	}
+	m.queries[id] = q
+	return &q, nil
+}
+
+func (m *mockSavedQueryStore) DeleteSavedQuery(_ context.Context, owner string, id int, anyOwner bool) error {
+	if _, ok := m.queries[id]; !ok || (m.owners[id] != owner && !anyOwner) {
+		return fmt.Errorf("saved query not found")
+	}
+	delete(m.queries, id)
+	return nil
+}
+
+func TestSavedQueries(t *testing.T) {
+	store := newMockSavedQueryStore()
+	r := chi.NewRouter()
+	NewSavedQueriesHandler(store, true).Routes(r)
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("POST", "/saved-queries", "alice",
+		`{"name":"Overnight fire","filter":{"tgids":"9131,9132","start_time":"-12h"}}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do("POST", "/saved-queries", "alice", `{"name":"Overnight fire"}`); rec.Code != http.StatusConflict {
+		t.Errorf("duplicate create: %d, want 409", rec.Code)
+	}
+	for _, body := range []string{
+		`{"name":""}`,
+		`{"name":"x","filter":{"Bad Key":"1"}}`,
+		`{"name":"x","filter":{"saved_query":"1"}}`,
+		`{"name":"x","filter":{"start_time":"yesterday"}}`,
+	} {
+		if rec := do("POST", "/saved-queries", "alice", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("create %s: %d, want 400", body, rec.Code)
+		}
+	}
+
+	// Private until shared; once shared, others can see but not change it
+	if rec := do("GET", "/saved-queries/1", "bob", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("private get by other token: %d, want 404", rec.Code)
+	}
+	if rec := do("PATCH", "/saved-queries/1", "alice", `{"shared":true}`); rec.Code != http.StatusOK {
+		t.Fatalf("share: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do("GET", "/saved-queries?q=fire", "bob", ""); !strings.Contains(rec.Body.String(), `"mine":false`) {
+		t.Errorf("shared list for other token: %s", rec.Body)
+	}
+	if rec := do("PATCH", "/saved-queries/1", "bob", `{"name":"mine now"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("update by other token: %d, want 403", rec.Code)
+	}
+	if rec := do("DELETE", "/saved-queries/1", "bob", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("delete by other token: %d, want 404", rec.Code)
+	}
+	if rec := do("DELETE", "/saved-queries/1", "alice", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("delete: %d, want 204", rec.Code)
+	}
+}
+
+func TestSavedQueryParams(t *testing.T) {
+	store := newMockSavedQueryStore()
+	store.CreateSavedQuery(context.Background(), "", &database.SavedQuery{
+		Name:   "overnight fire",
+		Filter: map[string]string{"tgids": "9131,9132", "emergency": "true", "start_time": "-12h"},
+	})
+
+	var got url.Values
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = r.URL.Query() })
+	serve := func(target string) int {
+		got = nil
+		rec := httptest.NewRecorder()
+		SavedQueryParams(store, false)(next).ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return rec.Code
+	}
+
+	// Explicit parameters win over the saved ones
+	if code := serve("/api/v1/calls?saved_query=1&emergency=false&limit=5"); code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	if got.Get("tgids") != "9131,9132" || got.Get("emergency") != "false" || got.Get("limit") != "5" || got.Has("saved_query") {
+		t.Errorf("params = %v", got)
+	}
+	start, err := time.Parse(time.RFC3339, got.Get("start_time"))
+	if err != nil || time.Since(start) < 11*time.Hour || time.Since(start) > 13*time.Hour {
+		t.Errorf("relative start_time resolved to %q", got.Get("start_time"))
+	}
+
+	if code := serve("/api/v1/calls?saved_query=9"); code != http.StatusNotFound {
+		t.Errorf("unknown saved query: %d, want 404", code)
+	}
+	if code := serve("/api/v1/calls?saved_query=x"); code != http.StatusBadRequest {
+		t.Errorf("invalid saved query id: %d, want 400", code)
+	}
+	// Only the listed endpoints run saved queries
+	if serve("/api/v1/units?saved_query=1"); got.Has("tgids") {
+		t.Errorf("saved query applied to /units: %v", got)
+	}
+}
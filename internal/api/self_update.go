@@ -0,0 +1,104 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/snarg/tr-engine/internal/selfupdate"
+)
+
+// SelfUpdateHandler serves the self-update admin endpoints. updater is nil
+// when self-update is disabled.
+type SelfUpdateHandler struct {
+	updater   *selfupdate.Updater
+	onRestart func() // shuts the server down; main then restarts into the new binary
+}
+
+func NewSelfUpdateHandler(updater *selfupdate.Updater, onRestart func()) *SelfUpdateHandler {
+	return &SelfUpdateHandler{updater: updater, onRestart: onRestart}
+}
+
+const selfUpdateDisabled = "self-update is disabled (set SELF_UPDATE_PUBLIC_KEY on a binary install)"
+
+// GetStatus returns the running version, the latest release for this
+// platform and the state of the last update.
+// GET /api/v1/admin/update
+func (h *SelfUpdateHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	if h.updater == nil {
+		WriteJSON(w, http.StatusOK, map[string]any{"enabled": false})
+		return
+	}
+	resp := map[string]any{
+		"enabled":         true,
+		"current_version": h.updater.Version(),
+		"platform":        h.updater.Platform(),
+	}
+	if st, err := h.updater.State(); err != nil {
+		resp["state_error"] = err.Error()
+	} else if st != nil {
+		resp["state"] = st
+	}
+	if rel, err := h.updater.Latest(r.Context()); err != nil {
+		resp["check_error"] = err.Error()
+	} else {
+		resp["latest"] = rel
+		resp["update_available"] = h.updater.Newer(rel)
+	}
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+// StageUpdate downloads and verifies the latest release's binary.
+// POST /api/v1/admin/update/stage
+func (h *SelfUpdateHandler) StageUpdate(w http.ResponseWriter, r *http.Request) {
+	if h.updater == nil {
+		WriteError(w, http.StatusConflict, selfUpdateDisabled)
+		return
+	}
+	st, err := h.updater.Stage(r.Context())
+	if errors.Is(err, selfupdate.ErrUpToDate) {
+		WriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("self-update stage failed")
+		WriteError(w, http.StatusBadGateway, "failed to stage update: "+err.Error())
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{"state": st})
+}
+
+// ApplyUpdate swaps the staged binary in and restarts. The new binary is
+// rolled back if it fails its health check.
+// POST /api/v1/admin/update/apply
+func (h *SelfUpdateHandler) ApplyUpdate(w http.ResponseWriter, r *http.Request) {
+	if h.updater == nil {
+		WriteError(w, http.StatusConflict, selfUpdateDisabled)
+		return
+	}
+	st, err := h.updater.Apply()
+	if errors.Is(err, selfupdate.ErrNothingStaged) {
+		WriteError(w, http.StatusConflict, "no update staged (POST /admin/update/stage first)")
+		return
+	}
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("self-update apply failed")
+		WriteError(w, http.StatusInternalServerError, "failed to apply update: "+err.Error())
+		return
+	}
+	WriteJSON(w, http.StatusAccepted, map[string]any{"state": st, "restarting": true})
+	if h.onRestart != nil {
+		// Let the response go out before the server shuts down.
+		time.AfterFunc(500*time.Millisecond, h.onRestart)
+	}
+}
+
+// Routes registers self-update routes on the given router.
+func (h *SelfUpdateHandler) Routes(r chi.Router) {
+	r.Get("/admin/update", h.GetStatus)
+	r.Post("/admin/update/stage", h.StageUpdate)
+	r.Post("/admin/update/apply", h.ApplyUpdate)
+}
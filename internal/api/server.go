@@ -14,17 +14,31 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
+	"github.com/snarg/tr-engine/internal/ask"
+	"github.com/snarg/tr-engine/internal/audioarchive"
+	"github.com/snarg/tr-engine/internal/audiorecon"
+	"github.com/snarg/tr-engine/internal/cadmail"
 	"github.com/snarg/tr-engine/internal/config"
 	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/dupaudit"
+	"github.com/snarg/tr-engine/internal/embed"
+	"github.com/snarg/tr-engine/internal/forward"
 	"github.com/snarg/tr-engine/internal/metrics"
 	"github.com/snarg/tr-engine/internal/mqttclient"
+	"github.com/snarg/tr-engine/internal/replicate"
+	"github.com/snarg/tr-engine/internal/restamp"
+	"github.com/snarg/tr-engine/internal/rtcaudio"
+	"github.com/snarg/tr-engine/internal/selfupdate"
 	"github.com/snarg/tr-engine/internal/storage"
+	"github.com/snarg/tr-engine/internal/transcribe"
+	"github.com/snarg/tr-engine/internal/warehouse"
 )
 
 type Server struct {
 	http   *http.Server
 	log    zerolog.Logger
 	health *HealthHandler
+	status *StatusHandler
 }
 
 type ServerOptions struct {
@@ -34,6 +48,7 @@ type ServerOptions struct {
 	Live          LiveDataSource
 	Uploader      CallUploader      // nil if upload ingest not available
 	AudioStreamer AudioStreamer      // nil if live audio streaming not configured
+	WebRTC        *rtcaudio.Publisher // nil when WEBRTC_ENABLED is off
 	Store         storage.AudioStore // audio storage backend (local, S3, or tiered)
 	WebFiles      fs.FS              // embedded web/ directory
 	OpenAPISpec   []byte       // embedded openapi.yaml
@@ -41,13 +56,44 @@ type ServerOptions struct {
 	StartTime     time.Time
 	Log           zerolog.Logger
 	OnSystemMerge func(sourceID, targetID int) // called after successful system merge to invalidate caches
+	OnUnitMerge   func(*database.UnitMergeResult) // called after successful unit merge to invalidate caches
+	OnIdentityPolicyChange func(ctx context.Context) error // reloads ingest instance policies after admin changes
+	OnEncryptionPolicyChange func(ctx context.Context) error // reloads ingest encryption policies after admin changes
+	OnStoragePolicyChange func(ctx context.Context) error // reloads ingest talkgroup storage policies after admin changes
+	OnEmbargoChange func(ctx context.Context) error // reloads ingest talkgroup embargoes after admin changes
+	OnIngestPolicyChange func(ctx context.Context) error // reloads ingest system ingest policies after admin changes
+	OnLegalHoldChange func(ctx context.Context) error // reloads ingest legal holds after admin changes
+	OnEnrichmentHookChange func(ctx context.Context) error // reloads ingest enrichment hooks after admin changes
+	OnMetricsTalkgroupChange func(ctx context.Context) error // reloads the per-talkgroup metrics allowlist after admin changes
+	OnAlertRuleChange func(ctx context.Context) error // reloads ingest alert rules after changes
+	OnWebhookChange func(ctx context.Context) error // reloads the webhook dispatcher after changes
+	CheckWebhookTemplate func(text string) error    // validates webhook payload templates
+	OnDirectoryImport func(systemID int) // called after a talkgroup directory or unit import; nil = no-op
+	Cache         *ResponseCache               // nil disables API response caching
 	TGCSVPaths    map[int]string               // system_id → CSV file path for talkgroup writeback
 	UnitCSVPaths  map[int]string               // system_id → CSV file path for unit tag writeback
+	Asker         *ask.Service                 // nil when LLM_URL is unset (archive Q&A disabled)
+	Embedder      *embed.Embedder              // nil when EMBED_URL is unset (semantic search disabled)
+	AudioArchiver *audioarchive.Archiver       // nil when TR_AUDIO_ARCHIVE is off
+	AudioReconciler *audiorecon.Reconciler     // nil when AUDIO_RECONCILE is off
+	Replicator    *replicate.Replicator        // nil when REPLICATE_URL is unset
+	Forwarder     *forward.Forwarder           // nil when FORWARD_URL is unset
+	IcecastStatus func() any                   // Icecast output status; nil when ICECAST_URL is unset
+	DuplicateAuditor *dupaudit.Auditor         // nil when DUPLICATE_AUDIT is off
+	Warehouse     *warehouse.Exporter          // nil when WAREHOUSE_EXPORT is off
+	CADIngester   *cadmail.Ingester            // nil when CAD_PAGE_FORMATS is unset
+	S3Uploader    *storage.AsyncUploader       // nil unless S3 with local cache and S3_UPLOAD_MODE=async
+	Retranscriber *transcribe.Retranscriber    // nil when transcription is disabled
+	Restamper     *restamp.Restamper           // re-stamps talkgroup/unit names on stored calls
 
 	// Update checker (opt-in)
 	UpdateCheckURL string // base URL for version check API
 	IngestModes    string // comma-separated active ingest modes
 	IsDocker       bool   // running inside Docker container
+
+	// Self-update (binary installs)
+	Updater   *selfupdate.Updater // nil unless SELF_UPDATE_PUBLIC_KEY is set
+	OnRestart func()              // shuts down so main can restart into an applied update
 }
 
 func NewServer(opts ServerOptions) *Server {
@@ -72,11 +118,17 @@ func NewServer(opts ServerOptions) *Server {
 
 	// Unauthenticated endpoints
 	health := NewHealthHandler(opts.DB, opts.MQTT, opts.Live, opts.AudioStreamer, opts.Version, opts.StartTime)
+	health.webrtc = opts.WebRTC
 	if opts.UpdateCheckURL != "" {
 		health.ConfigureUpdateChecker(opts.UpdateCheckURL, opts.IngestModes, opts.IsDocker, opts.Log)
 	}
 	r.Get("/api/v1/health", health.ServeHTTP)
 
+	// Public status page (unauthenticated, like /health)
+	status := NewStatusHandler(opts.DB, opts.MQTT, opts.Live, opts.Store, opts.Log)
+	r.Get("/api/v1/status", status.ServeJSON)
+	r.Get("/status", status.ServeHTML)
+
 	// Prometheus metrics endpoint (unauthenticated, like /health)
 	if opts.Config.MetricsEnabled {
 		var ingestStats metrics.IngestStats
@@ -90,7 +142,8 @@ func NewServer(opts ServerOptions) *Server {
 
 	// Web auth bootstrap — returns the token for web UI pages.
 	// No file extension in the URL so CDNs (Cloudflare) won't cache it.
-	if opts.Config.AuthToken != "" {
+	// AUTH_INIT_ENABLED=false leaves pages to sign in instead.
+	if opts.Config.AuthToken != "" && opts.Config.AuthInitEnabled {
 		tokenJSON := fmt.Sprintf(`{"token":"%s"}`, strings.ReplaceAll(opts.Config.AuthToken, `"`, `\"`))
 		r.Get("/api/v1/auth-init", func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Content-Type", "application/json")
@@ -99,6 +152,24 @@ func NewServer(opts ServerOptions) *Server {
 		})
 	}
 
+	// Named API keys (api_keys table), checked alongside AUTH_TOKEN/WRITE_TOKEN
+	apiKeys := NewAPIKeys(opts.DB, opts.Log)
+	{
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := apiKeys.Reload(ctx); err != nil {
+			opts.Log.Error().Err(err).Msg("failed to load api keys")
+		}
+		cancel()
+	}
+
+	// Web UI accounts: sign-in and sign-out need no credentials
+	sessions := NewSessions(opts.DB, opts.Config.LoginSessionTTL, opts.Log)
+	auth := NewAuthHandler(opts.DB, sessions, opts.Config.LoginSessionTTL)
+	r.Group(func(r chi.Router) {
+		r.Use(MaxBodySize(1 << 16))
+		auth.PublicRoutes(r)
+	})
+
 	// Upload endpoint with custom auth (accepts form field key/api_key)
 	// Uploads are write operations — require WRITE_TOKEN when set.
 	// When auth is enabled but WRITE_TOKEN is not set, uploads are blocked
@@ -106,13 +177,53 @@ func NewServer(opts ServerOptions) *Server {
 	if opts.Uploader != nil {
 		uploadToken := opts.Config.WriteToken
 		uploadHandler := NewUploadHandler(opts.Uploader, opts.Config.UploadInstanceID, opts.Log)
+		sessionDir := opts.Config.UploadSessionDir
+		if sessionDir == "" {
+			sessionDir = filepath.Join(os.TempDir(), "tr-engine-uploads")
+		}
+		sessions, err := NewUploadSessionsHandler(uploadHandler, sessionDir, opts.Config.UploadSessionTTL, opts.Config.UploadMaxSize)
+		if err != nil {
+			opts.Log.Error().Err(err).Msg("resumable uploads disabled")
+		}
 		r.Group(func(r chi.Router) {
 			r.Use(MaxBodySize(50 << 20)) // 50 MB for audio uploads
-			r.Use(UploadAuth(uploadToken))
+			r.Use(UploadAuth(uploadToken, apiKeys))
 			r.Post("/api/v1/call-upload", uploadHandler.Upload)
+			r.Post("/api/v1/call-upload/issi", uploadHandler.UploadISSI)
+			if sessions != nil {
+				sessions.Routes(r)
+			}
 		})
 	}
 
+	// Public talkgroup feeds (unauthenticated, CDN-cacheable)
+	feeds := NewFeedsHandler(opts.DB,
+		NewCallsHandler(opts.DB, opts.Config.AudioDir, opts.Config.TRAudioDir, opts.Store, opts.Live),
+		opts.Config.FeedBaseURL, opts.Config.FeedCacheMaxAge)
+	r.Group(func(r chi.Router) {
+		if opts.Config.MetricsEnabled {
+			r.Use(metrics.InstrumentHandler)
+		}
+		r.Use(ResponseTimeout(opts.Config.WriteTimeout))
+		feeds.PublicRoutes(r)
+	})
+
+	// System merges renumber IDs throughout cached responses
+	onSystemMerge := func(sourceID, targetID int) {
+		opts.Cache.Purge()
+		if opts.OnSystemMerge != nil {
+			opts.OnSystemMerge(sourceID, targetID)
+		}
+	}
+
+	// Unit merges rewrite unit IDs and tags in cached responses too
+	onUnitMerge := func(result *database.UnitMergeResult) {
+		opts.Cache.Purge()
+		if opts.OnUnitMerge != nil {
+			opts.OnUnitMerge(result)
+		}
+	}
+
 	// Detect web directory: prefer local web/ on disk for dev, fall back to embedded
 	var webFSys fs.FS
 	var webDir string
@@ -126,38 +237,114 @@ func NewServer(opts ServerOptions) *Server {
 		webFSys, _ = fs.Sub(opts.WebFiles, "web")
 	}
 
+	// Share links: signed, expiring ?share= tokens scoped to talkgroups
+	shareSigner := NewShareSigner(opts.Config.ShareSigningKey, opts.Config.WriteToken)
+
 	// Authenticated routes
 	r.Group(func(r chi.Router) {
 		r.Use(MaxBodySize(10 << 20)) // 10 MB for regular API requests
 		if opts.Config.MetricsEnabled {
 			r.Use(metrics.InstrumentHandler)
 		}
+		r.Use(ShareAuth(shareSigner, opts.DB.ShareLinkActive))
+		r.Use(SessionAuth(sessions))
 		if opts.Config.AuthEnabled {
+			r.Use(APIKeyAuth(apiKeys))
 			r.Use(BearerAuth(opts.Config.AuthToken, opts.Config.WriteToken))
 			r.Use(WriteAuth(opts.Config.WriteToken, opts.Config.AuthToken))
 		}
+		r.Use(AdminContext(opts.Config.AuthEnabled, opts.Config.WriteToken))
+		if rps := opts.Config.RateLimitGlobalRPS; rps > 0 {
+			r.Use(GlobalRateLimiter(rps, opts.Config.RateLimitGlobalBurst))
+		}
+		if rps := opts.Config.RateLimitTokenRPS; rps > 0 {
+			r.Use(TokenRateLimiter(rps, opts.Config.RateLimitTokenBurst))
+		}
+		r.Use(APIKeyRateLimiter(apiKeys))
+		if perMin := opts.Config.RateLimitExpensivePerMin; perMin > 0 {
+			var paths []string
+			for _, p := range strings.Split(opts.Config.RateLimitExpensivePaths, ",") {
+				if p = strings.TrimSpace(p); p != "" {
+					paths = append(paths, p)
+				}
+			}
+			r.Use(ExpensiveRateLimiter(perMin, opts.Config.RateLimitExpensiveBurst, paths))
+		}
 		r.Use(ResponseTimeout(opts.Config.WriteTimeout))
+		r.Use(SavedQueryParams(opts.DB, opts.Config.AuthEnabled))
+		r.Use(SparseFields)
 
 		// All API routes under /api/v1
 		r.Route("/api/v1", func(r chi.Router) {
-			NewSystemsHandler(opts.DB).Routes(r)
-			NewTalkgroupsHandler(opts.DB, opts.TGCSVPaths).Routes(r)
-			NewUnitsHandler(opts.DB, opts.UnitCSVPaths).Routes(r)
+			NewSystemsHandler(opts.DB, opts.Cache, opts.OnIngestPolicyChange).Routes(r)
+			NewGeoHandler(opts.DB, opts.Live).Routes(r)
+			NewTalkgroupsHandler(opts.DB, opts.TGCSVPaths, opts.Cache, opts.OnDirectoryImport).Routes(r)
+			NewUnitsHandler(opts.DB, opts.UnitCSVPaths, opts.OnDirectoryImport).Routes(r)
 			NewCallsHandler(opts.DB, opts.Config.AudioDir, opts.Config.TRAudioDir, opts.Store, opts.Live).Routes(r)
 			NewCallGroupsHandler(opts.DB, opts.Config.TRAudioDir).Routes(r)
-			NewStatsHandler(opts.DB).Routes(r)
+			NewStatsHandler(opts.DB, opts.Cache).Routes(r)
 			NewRecordersHandler(opts.Live).Routes(r)
-			NewEventsHandler(opts.Live).Routes(r)
+			NewEventsHandler(opts.Live, opts.DB, opts.Config.AuthEnabled).Routes(r)
+			NewExpressionsHandler(opts.Live).Routes(r)
+			NewSubscriptionsHandler(opts.DB, opts.Config.AuthEnabled).Routes(r)
+			NewSavedQueriesHandler(opts.DB, opts.Config.AuthEnabled).Routes(r)
 			if opts.AudioStreamer != nil {
 				NewAudioStreamHandler(opts.AudioStreamer, opts.Config.StreamMaxClients).Routes(r)
 			}
+			if opts.WebRTC != nil {
+				NewAudioWebRTCHandler(opts.WebRTC).Routes(r)
+			}
 			NewUnitEventsHandler(opts.DB).Routes(r)
+			NewUnitSessionsHandler(opts.DB).Routes(r)
+			NewUnitCensusHandler(opts.DB).Routes(r)
 			NewAffiliationsHandler(opts.Live).Routes(r)
-			NewTranscriptionsHandler(opts.DB, opts.Live).Routes(r)
-			NewAdminHandler(opts.DB, opts.Live, opts.OnSystemMerge).Routes(r)
+			NewOccupancyHandler(opts.DB, opts.Live).Routes(r)
+			NewTranscriptionsHandler(opts.DB, opts.Live, opts.Retranscriber).Routes(r)
+			NewAdminHandler(opts.DB, opts.Live, opts.Cache, onSystemMerge, onUnitMerge).Routes(r)
+			NewMQTTHandler(opts.MQTT, opts.Live).Routes(r)
+			NewInstancePoliciesHandler(opts.DB, opts.Config.IngestAutoCreate, opts.OnIdentityPolicyChange).Routes(r)
+			NewInstanceConfigsHandler(opts.DB).Routes(r)
+			NewSourcesHandler(opts.DB, opts.Live).Routes(r)
+			NewEncryptionPoliciesHandler(opts.DB, opts.OnEncryptionPolicyChange).Routes(r)
+			NewSTTPromptsHandler(opts.DB).Routes(r)
+			NewStoragePoliciesHandler(opts.DB, opts.OnStoragePolicyChange).Routes(r)
+			NewEmbargoesHandler(opts.DB, opts.OnEmbargoChange).Routes(r)
+			NewLegalHoldsHandler(opts.DB, opts.OnLegalHoldChange).Routes(r)
+			NewShareLinksHandler(opts.DB, shareSigner).Routes(r)
+			NewAPIKeysHandler(opts.DB, apiKeys).Routes(r)
+			NewUsersHandler(opts.DB, sessions).Routes(r)
+			auth.Routes(r)
+			NewSelfUpdateHandler(opts.Updater, opts.OnRestart).Routes(r)
+			NewEnrichmentHooksHandler(opts.DB, opts.OnEnrichmentHookChange).Routes(r)
+			NewTalkgroupMetricsHandler(opts.DB, opts.Config.MetricsTalkgroupLimit, opts.OnMetricsTalkgroupChange).Routes(r)
+			NewAlertsHandler(opts.DB, opts.OnAlertRuleChange).Routes(r)
+			NewWebhooksHandler(opts.DB, opts.OnWebhookChange, opts.CheckWebhookTemplate).Routes(r)
+			NewTimeseriesHandler(opts.DB).Routes(r)
+			NewDiscoveriesHandler(opts.DB).Routes(r)
+			NewConsoleHandler(opts.DB).Routes(r)
+			NewControlChannelsHandler(opts.DB).Routes(r)
+			NewCallMergesHandler(opts.DB).Routes(r)
+			feeds.Routes(r)
 			r.Post("/pages", SavePageHandler(webDir))
 
 			NewQueryHandler(opts.DB).Routes(r)
+			NewAskHandler(opts.DB, opts.Asker, opts.Config.AskRateLimit).Routes(r)
+			NewEmbeddingsHandler(opts.Embedder).Routes(r)
+			NewAudioArchiveHandler(opts.DB, opts.AudioArchiver).Routes(r)
+			NewAudioReconcileHandler(opts.DB, opts.AudioReconciler).Routes(r)
+			NewReplicationHandler(opts.DB, opts.Replicator).Routes(r)
+			NewForwardingHandler(opts.DB, opts.Forwarder).Routes(r)
+			NewIcecastHandler(opts.IcecastStatus).Routes(r)
+			NewDuplicateAuditHandler(opts.DB, opts.DuplicateAuditor).Routes(r)
+			NewStorageStatusHandler(opts.DB, opts.Store, opts.S3Uploader).Routes(r)
+			NewRetranscribeHandler(opts.DB, opts.Retranscriber).Routes(r)
+			NewCallRestampHandler(opts.Restamper).Routes(r)
+			NewWarehouseHandler(opts.DB, opts.Warehouse).Routes(r)
+			NewCADIncidentsHandler(opts.DB, opts.CADIngester).Routes(r)
+			NewExternalEventsHandler(opts.DB).Routes(r)
+			NewCallAnnotationsHandler(opts.DB).Routes(r)
+			NewCallPinsHandler(opts.DB).Routes(r)
+			NewCapabilitiesHandler(opts).Routes(r)
 		})
 	})
 
@@ -191,6 +378,7 @@ func NewServer(opts ServerOptions) *Server {
 		http:   srv,
 		log:    opts.Log,
 		health: health,
+		status: status,
 	}
 }
 
@@ -199,6 +387,11 @@ func (s *Server) StartUpdateChecker(ctx context.Context) {
 	s.health.StartUpdateChecker(ctx)
 }
 
+// StartStatusSampler begins recording component status for the status page.
+func (s *Server) StartStatusSampler(ctx context.Context) {
+	s.status.Start(ctx)
+}
+
 func (s *Server) Start() error {
 	s.log.Info().Str("addr", s.http.Addr).Msg("http server starting")
 	err := s.http.ListenAndServe()
@@ -0,0 +1,553 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// Users sign in to the web UI with a username and password
+// (POST /auth/login) instead of sharing a bearer token. A login sets an
+// HttpOnly session cookie; SessionAuth recognizes it and puts the user in
+// the request context, where BearerAuth, WriteAuth and AdminContext check
+// the user's role as they would an API key's scope. Changes made with a
+// session must carry its CSRF token in X-CSRF-Token. An explicit bearer
+// token takes precedence over the cookie.
+
+const (
+	sessionCookie        = "tre_session"
+	csrfHeader           = "X-CSRF-Token"
+	sessionCacheTTL      = 30 * time.Second
+	sessionTouchInterval = time.Minute
+	loginAttemptsPerMin  = 10
+)
+
+// SessionStore loads signed-in sessions and records their use.
+type SessionStore interface {
+	GetUserSession(ctx context.Context, id string) (*database.UserSession, *database.User, error)
+	TouchUserSession(ctx context.Context, id string, at, expires time.Time) error
+}
+
+// sessionEntry is a session and its user as last loaded.
+type sessionEntry struct {
+	session *database.UserSession
+	user    *database.User
+	loaded  time.Time
+	touched time.Time
+}
+
+// Sessions looks up session cookies, caching each for sessionCacheTTL so
+// every request doesn't hit the database. Sign-outs, disabled users and
+// password changes made through this server apply at once; Forget drops
+// them from the cache.
+type Sessions struct {
+	db  SessionStore
+	ttl time.Duration
+	log zerolog.Logger
+
+	mu    sync.Mutex
+	cache map[string]*sessionEntry // by session ID (cookie hash)
+}
+
+func NewSessions(db SessionStore, ttl time.Duration, log zerolog.Logger) *Sessions {
+	return &Sessions{
+		db:    db,
+		ttl:   ttl,
+		log:   log.With().Str("component", "sessions").Logger(),
+		cache: make(map[string]*sessionEntry),
+	}
+}
+
+// lookup returns the session for a cookie value and its user, or nils.
+func (s *Sessions) lookup(ctx context.Context, cookie string) (*database.UserSession, *database.User) {
+	if s == nil || cookie == "" {
+		return nil, nil
+	}
+	id := hashSessionToken(cookie)
+	now := time.Now()
+
+	s.mu.Lock()
+	e, ok := s.cache[id]
+	s.mu.Unlock()
+	if ok && now.Sub(e.loaded) < sessionCacheTTL && now.Before(e.session.ExpiresAt) {
+		s.touch(e, now)
+		return e.session, e.user
+	}
+
+	sess, user, err := s.db.GetUserSession(ctx, id)
+	if err != nil {
+		if err.Error() != "session not found" {
+			s.log.Warn().Err(err).Msg("failed to load session")
+		}
+		s.Forget(id)
+		return nil, nil
+	}
+	e = &sessionEntry{session: sess, user: user, loaded: now, touched: sess.LastSeenAt}
+	s.mu.Lock()
+	s.cache[id] = e
+	s.mu.Unlock()
+	s.touch(e, now)
+	return sess, user
+}
+
+// touch records a session's use and extends it, writing to the database at
+// most once per sessionTouchInterval per session.
+func (s *Sessions) touch(e *sessionEntry, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(e.touched) < sessionTouchInterval {
+		s.mu.Unlock()
+		return
+	}
+	e.touched = now
+	s.mu.Unlock()
+
+	id, expires := e.session.ID, now.Add(s.ttl)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.db.TouchUserSession(ctx, id, now, expires); err != nil {
+			s.log.Warn().Err(err).Int("user_id", e.user.ID).Msg("failed to record session use")
+		}
+	}()
+}
+
+// Forget drops a session from the cache.
+func (s *Sessions) Forget(id string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	delete(s.cache, id)
+	s.mu.Unlock()
+}
+
+// ForgetUser drops every cached session of a user.
+func (s *Sessions) ForgetUser(userID int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	for id, e := range s.cache {
+		if e.user.ID == userID {
+			delete(s.cache, id)
+		}
+	}
+	s.mu.Unlock()
+}
+
+// hashSessionToken returns the hex SHA-256 stored for a session cookie.
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomToken returns n random bytes, base64url.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+type sessionCtxKey struct{}
+
+// sessionAuth is what SessionAuth puts in the request context.
+type sessionAuth struct {
+	session *database.UserSession
+	user    *database.User
+}
+
+// userFrom returns the signed-in user SessionAuth found on r, or nil.
+func userFrom(r *http.Request) *database.User {
+	if a, ok := r.Context().Value(sessionCtxKey{}).(*sessionAuth); ok {
+		return a.user
+	}
+	return nil
+}
+
+// sessionFrom returns the session SessionAuth found on r, or nil.
+func sessionFrom(r *http.Request) *database.UserSession {
+	if a, ok := r.Context().Value(sessionCtxKey{}).(*sessionAuth); ok {
+		return a.session
+	}
+	return nil
+}
+
+// credentialScope returns the scope of the API key or the role of the user
+// a request is made with, and false for AUTH_TOKEN, WRITE_TOKEN, share links
+// and no credentials.
+func credentialScope(r *http.Request) (string, bool) {
+	if key := apiKeyFrom(r); key != nil {
+		return key.Scope, true
+	}
+	if user := userFrom(r); user != nil {
+		return user.Role, true
+	}
+	return "", false
+}
+
+// SessionAuth recognizes the session cookie on requests without a bearer
+// token and puts the user in the request context. Changes (POST, PATCH, PUT,
+// DELETE) must carry the session's CSRF token in X-CSRF-Token. Requests with
+// an unknown or expired cookie pass through untouched.
+func SessionAuth(sessions *Sessions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if shareScopeFrom(r) != nil || extractBearerToken(r) != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			c, err := r.Cookie(sessionCookie)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			sess, user := sessions.lookup(r.Context(), c.Value)
+			if sess == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			switch r.Method {
+			case "GET", "HEAD", "OPTIONS":
+			default:
+				if subtle.ConstantTimeCompare([]byte(r.Header.Get(csrfHeader)), []byte(sess.CSRFToken)) != 1 {
+					WriteErrorWithCode(w, http.StatusForbidden, ErrForbidden, "missing or invalid "+csrfHeader+" header")
+					return
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionCtxKey{}, &sessionAuth{session: sess, user: user})))
+		})
+	}
+}
+
+// AuthStore is the subset of database.DB used by AuthHandler.
+type AuthStore interface {
+	GetUserByUsername(ctx context.Context, username string) (*database.User, error)
+	RecordUserLogin(ctx context.Context, id int, at time.Time, ip string) error
+	CreateUserSession(ctx context.Context, s *database.UserSession) error
+	DeleteUserSession(ctx context.Context, id string) error
+	SetUserPreferences(ctx context.Context, id int, prefs database.UserPreferences) (*database.User, error)
+	UpdateUser(ctx context.Context, id int, u database.UserUpdate) (*database.User, error)
+}
+
+// AuthHandler signs users in and out and serves their own account: profile,
+// preferences and password.
+type AuthHandler struct {
+	db       AuthStore
+	sessions *Sessions
+	ttl      time.Duration
+	logins   *limiterSet // login attempts per client IP
+
+	dummyOnce sync.Once
+	dummyHash []byte // compared against for unknown usernames
+}
+
+func NewAuthHandler(db AuthStore, sessions *Sessions, ttl time.Duration) *AuthHandler {
+	return &AuthHandler{
+		db:       db,
+		sessions: sessions,
+		ttl:      ttl,
+		logins:   newLimiterSet("login", loginAttemptsPerMin/60.0, loginAttemptsPerMin),
+	}
+}
+
+// checkPassword reports whether password matches u's hash. For a nil user
+// it compares against a throwaway hash, so unknown usernames take as long
+// to refuse as wrong passwords.
+func (h *AuthHandler) checkPassword(u *database.User, password string) bool {
+	if u == nil {
+		h.dummyOnce.Do(func() {
+			h.dummyHash, _ = bcrypt.GenerateFromPassword([]byte("tr-engine"), bcrypt.DefaultCost)
+		})
+		bcrypt.CompareHashAndPassword(h.dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
+}
+
+// setSessionCookie sets (or, with an empty value, clears) the session cookie.
+// It's Secure when the request came over HTTPS, directly or via a proxy.
+func setSessionCookie(w http.ResponseWriter, r *http.Request, value string, expires time.Time) {
+	c := &http.Cookie{
+		Name:     sessionCookie,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https"),
+	}
+	if value == "" {
+		c.MaxAge = -1
+	} else {
+		c.Expires = expires
+	}
+	http.SetCookie(w, c)
+}
+
+// Login checks a username and password and starts a session. The response
+// sets the session cookie and carries the CSRF token for changes. Attempts
+// are limited per client IP.
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	if !h.logins.allow(w, clientIP(r)) {
+		return
+	}
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	if strings.TrimSpace(req.Username) == "" || req.Password == "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "username and password are required")
+		return
+	}
+
+	user, err := h.db.GetUserByUsername(r.Context(), strings.TrimSpace(req.Username))
+	if err != nil && err.Error() != "user not found" {
+		WriteError(w, http.StatusInternalServerError, "failed to sign in")
+		return
+	}
+	if err != nil {
+		user = nil
+	}
+	if !h.checkPassword(user, req.Password) || user.Disabled {
+		hlog.FromRequest(r).Info().Str("username", req.Username).Msg("failed login")
+		WriteError(w, http.StatusUnauthorized, "invalid username or password")
+		return
+	}
+
+	sess, err := h.startSession(w, r, user)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("failed to create session")
+		WriteError(w, http.StatusInternalServerError, "failed to sign in")
+		return
+	}
+	if err := h.db.RecordUserLogin(r.Context(), user.ID, sess.CreatedAt, clientIP(r)); err != nil {
+		hlog.FromRequest(r).Warn().Err(err).Msg("failed to record login")
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"user":       user,
+		"csrf_token": sess.CSRFToken,
+		"expires_at": sess.ExpiresAt,
+	})
+}
+
+// startSession creates a session for user and sets its cookie.
+func (h *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, user *database.User) (*database.UserSession, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	csrf, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	sess := &database.UserSession{
+		ID:        hashSessionToken(token),
+		UserID:    user.ID,
+		CSRFToken: csrf,
+		ExpiresAt: time.Now().Add(h.ttl),
+		IP:        clientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+	if err := h.db.CreateUserSession(r.Context(), sess); err != nil {
+		return nil, err
+	}
+	setSessionCookie(w, r, token, sess.ExpiresAt)
+	return sess, nil
+}
+
+// Logout ends the session in the cookie, if any, and clears the cookie.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(sessionCookie); err == nil && c.Value != "" {
+		id := hashSessionToken(c.Value)
+		if err := h.db.DeleteUserSession(r.Context(), id); err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to sign out")
+			return
+		}
+		h.sessions.Forget(id)
+	}
+	setSessionCookie(w, r, "", time.Time{})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// requireUser returns the signed-in user, or writes a 401.
+func requireUser(w http.ResponseWriter, r *http.Request) *database.User {
+	user := userFrom(r)
+	if user == nil {
+		WriteError(w, http.StatusUnauthorized, "not signed in")
+	}
+	return user
+}
+
+// GetMe returns the signed-in user with the session's CSRF token, so pages
+// can pick it up again after a reload.
+func (h *AuthHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	sess := sessionFrom(r)
+	w.Header().Set("Cache-Control", "no-store")
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"user":       user,
+		"csrf_token": sess.CSRFToken,
+		"expires_at": sess.ExpiresAt,
+	})
+}
+
+const (
+	maxPinnedTalkgroups = 500
+	maxDefaultFilters   = 64
+	maxPreferencesBytes = 64 << 10
+)
+
+// validatePreferences checks and normalizes preferences. Returns "" if valid.
+func validatePreferences(p *database.UserPreferences) string {
+	if p.PinnedTalkgroups == nil {
+		p.PinnedTalkgroups = []string{}
+	}
+	if p.DefaultFilters == nil {
+		p.DefaultFilters = map[string]json.RawMessage{}
+	}
+	if len(p.PinnedTalkgroups) > maxPinnedTalkgroups {
+		return "at most 500 pinned_talkgroups"
+	}
+	seen := make(map[string]bool, len(p.PinnedTalkgroups))
+	pinned := p.PinnedTalkgroups[:0]
+	for _, id := range p.PinnedTalkgroups {
+		sys, tg, ok := strings.Cut(strings.TrimSpace(id), ":")
+		s, err1 := strconv.Atoi(sys)
+		t, err2 := strconv.Atoi(tg)
+		if !ok || err1 != nil || err2 != nil || s <= 0 || t <= 0 {
+			return "pinned_talkgroups entries must be system_id:tgid"
+		}
+		id = strconv.Itoa(s) + ":" + strconv.Itoa(t)
+		if !seen[id] {
+			seen[id] = true
+			pinned = append(pinned, id)
+		}
+	}
+	p.PinnedTalkgroups = pinned
+	if len(p.DefaultFilters) > maxDefaultFilters {
+		return "at most 64 default_filters"
+	}
+	for page := range p.DefaultFilters {
+		if page == "" || len(page) > 64 {
+			return "default_filters keys must be 1 to 64 characters"
+		}
+	}
+	if b, _ := json.Marshal(p); len(b) > maxPreferencesBytes {
+		return "preferences must be at most 64 KB"
+	}
+	return ""
+}
+
+// SetMyPreferences replaces the signed-in user's preferences: pinned
+// talkgroups ("system_id:tgid") and default filters per page.
+func (h *AuthHandler) SetMyPreferences(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	var prefs database.UserPreferences
+	if err := DecodeJSON(r, &prefs); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	if msg := validatePreferences(&prefs); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, msg)
+		return
+	}
+	updated, err := h.db.SetUserPreferences(r.Context(), user.ID, prefs)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to save preferences")
+		return
+	}
+	h.sessions.ForgetUser(user.ID)
+	WriteJSON(w, http.StatusOK, updated.Preferences)
+}
+
+// ChangeMyPassword changes the signed-in user's password, given the current
+// one. Every session of the user ends; the response starts a new one for
+// this browser.
+func (h *AuthHandler) ChangeMyPassword(w http.ResponseWriter, r *http.Request) {
+	user := requireUser(w, r)
+	if user == nil {
+		return
+	}
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	if !h.checkPassword(user, req.CurrentPassword) {
+		WriteErrorWithCode(w, http.StatusForbidden, ErrForbidden, "current_password is incorrect")
+		return
+	}
+	if msg := validatePassword(req.NewPassword); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, msg)
+		return
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to change password")
+		return
+	}
+	s := string(hash)
+	if _, err := h.db.UpdateUser(r.Context(), user.ID, database.UserUpdate{PasswordHash: &s}); err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to change password")
+		return
+	}
+	h.sessions.ForgetUser(user.ID)
+
+	// Sign this browser back in
+	sess, err := h.startSession(w, r, user)
+	if err != nil {
+		hlog.FromRequest(r).Warn().Err(err).Msg("failed to create session after password change")
+		setSessionCookie(w, r, "", time.Time{})
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"csrf_token": sess.CSRFToken,
+		"expires_at": sess.ExpiresAt,
+	})
+}
+
+// PublicRoutes registers sign-in and sign-out, which need no credentials.
+func (h *AuthHandler) PublicRoutes(r chi.Router) {
+	r.Post("/api/v1/auth/login", h.Login)
+	r.Post("/api/v1/auth/logout", h.Logout)
+}
+
+// Routes registers the signed-in user's own account routes.
+func (h *AuthHandler) Routes(r chi.Router) {
+	r.Get("/auth/me", h.GetMe)
+	r.Put("/auth/me/preferences", h.SetMyPreferences)
+	r.Post("/auth/me/password", h.ChangeMyPassword)
+}
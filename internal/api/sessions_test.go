@@ -0,0 +1,241 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// mockSessionStore holds sessions "sess_<role>" for users 1-3 with roles
+// read, write and admin, each with CSRF token "csrf".
+type mockSessionStore struct {
+	users    map[string]*database.User // by username
+	sessions map[string]*database.UserSession
+	created  []*database.UserSession
+	deleted  []string
+	prefs    database.UserPreferences
+}
+
+func newMockSessionStore(t *testing.T) *mockSessionStore {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockSessionStore{users: map[string]*database.User{}, sessions: map[string]*database.UserSession{}}
+	for i, role := range []string{"read", "write", "admin"} {
+		u := &database.User{ID: i + 1, Username: role + "er", Role: role, PasswordHash: string(hash)}
+		m.users[u.Username] = u
+		id := hashSessionToken("sess_" + role)
+		m.sessions[id] = &database.UserSession{ID: id, UserID: u.ID, CSRFToken: "csrf", ExpiresAt: time.Now().Add(time.Hour)}
+	}
+	return m
+}
+
+func (m *mockSessionStore) userByID(id int) *database.User {
+	for _, u := range m.users {
+		if u.ID == id {
+			return u
+		}
+	}
+	return nil
+}
+
+func (m *mockSessionStore) GetUserSession(ctx context.Context, id string) (*database.UserSession, *database.User, error) {
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, nil, fmt.Errorf("session not found")
+	}
+	return s, m.userByID(s.UserID), nil
+}
+
+func (m *mockSessionStore) TouchUserSession(ctx context.Context, id string, at, expires time.Time) error {
+	return nil
+}
+
+func (m *mockSessionStore) GetUserByUsername(ctx context.Context, username string) (*database.User, error) {
+	if u, ok := m.users[strings.ToLower(username)]; ok {
+		return u, nil
+	}
+	return nil, fmt.Errorf("user not found")
+}
+
+func (m *mockSessionStore) RecordUserLogin(ctx context.Context, id int, at time.Time, ip string) error {
+	return nil
+}
+
+func (m *mockSessionStore) CreateUserSession(ctx context.Context, s *database.UserSession) error {
+	s.CreatedAt = time.Now()
+	m.created = append(m.created, s)
+	m.sessions[s.ID] = s
+	return nil
+}
+
+func (m *mockSessionStore) DeleteUserSession(ctx context.Context, id string) error {
+	m.deleted = append(m.deleted, id)
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *mockSessionStore) SetUserPreferences(ctx context.Context, id int, prefs database.UserPreferences) (*database.User, error) {
+	m.prefs = prefs
+	u := *m.userByID(id)
+	u.Preferences = prefs
+	return &u, nil
+}
+
+func (m *mockSessionStore) UpdateUser(ctx context.Context, id int, u database.UserUpdate) (*database.User, error) {
+	return m.userByID(id), nil
+}
+
+func TestSessionAuth(t *testing.T) {
+	store := newMockSessionStore(t)
+	sessions := NewSessions(store, time.Hour, zerolog.Nop())
+	handler := SessionAuth(sessions)(BearerAuth("reader", "writer")(
+		WriteAuth("writer", "reader")(AdminContext(true, "writer")(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if isAdmin(r) {
+					w.WriteHeader(http.StatusAccepted)
+				}
+			})))))
+	serve := func(method, path, cookie, csrf, token string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: sessionCookie, Value: cookie})
+		}
+		if csrf != "" {
+			req.Header.Set(csrfHeader, csrf)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, tc := range []struct {
+		name                            string
+		method, path, cookie, csrf, tok string
+		want                            int
+	}{
+		{"read", "GET", "/api/v1/calls", "sess_read", "", "", http.StatusOK},
+		{"read_write", "POST", "/api/v1/talkgroups/1/notes", "sess_read", "csrf", "", http.StatusForbidden},
+		{"read_own_prefs", "PUT", "/api/v1/auth/me/preferences", "sess_read", "csrf", "", http.StatusOK},
+		{"write", "POST", "/api/v1/talkgroups/1/notes", "sess_write", "csrf", "", http.StatusOK},
+		{"write_no_csrf", "POST", "/api/v1/talkgroups/1/notes", "sess_write", "", "", http.StatusForbidden},
+		{"write_bad_csrf", "POST", "/api/v1/talkgroups/1/notes", "sess_write", "nope", "", http.StatusForbidden},
+		{"write_admin", "POST", "/api/v1/admin/feeds", "sess_write", "csrf", "", http.StatusForbidden},
+		{"admin", "POST", "/api/v1/admin/feeds", "sess_admin", "csrf", "", http.StatusAccepted},
+		{"unknown_cookie", "GET", "/api/v1/calls", "sess_gone", "", "", http.StatusUnauthorized},
+		{"no_credentials", "GET", "/api/v1/calls", "", "", "", http.StatusUnauthorized},
+		{"token_wins", "GET", "/api/v1/calls", "sess_admin", "", "reader", http.StatusOK},
+	} {
+		if got := serve(tc.method, tc.path, tc.cookie, tc.csrf, tc.tok); got != tc.want {
+			t.Errorf("%s: %s %s: %d, want %d", tc.name, tc.method, tc.path, got, tc.want)
+		}
+	}
+
+	// Signing out applies at once, cache or not
+	id := hashSessionToken("sess_write")
+	delete(store.sessions, id)
+	sessions.Forget(id)
+	if got := serve("GET", "/api/v1/calls", "sess_write", "", ""); got != http.StatusUnauthorized {
+		t.Errorf("after sign-out: %d, want 401", got)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	store := newMockSessionStore(t)
+	store.users["disabled"] = &database.User{ID: 9, Username: "disabled", Role: "read",
+		PasswordHash: store.users["reader"].PasswordHash, Disabled: true}
+	h := NewAuthHandler(store, NewSessions(store, time.Hour, zerolog.Nop()), time.Hour)
+	login := func(username, password string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		body := fmt.Sprintf(`{"username":%q,"password":%q}`, username, password)
+		h.Login(rec, httptest.NewRequest("POST", "/api/v1/auth/login", strings.NewReader(body)))
+		return rec
+	}
+
+	for _, tc := range []struct {
+		username, password string
+		want               int
+	}{
+		{"Reader", "wrong password", http.StatusUnauthorized},
+		{"nobody", "correct horse", http.StatusUnauthorized},
+		{"disabled", "correct horse", http.StatusUnauthorized},
+		{"", "", http.StatusBadRequest},
+	} {
+		if rec := login(tc.username, tc.password); rec.Code != tc.want {
+			t.Errorf("login %q: %d, want %d", tc.username, rec.Code, tc.want)
+		}
+	}
+	if len(store.created) != 0 {
+		t.Fatalf("failed logins created %d sessions", len(store.created))
+	}
+
+	rec := login("Reader", "correct horse")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: %d: %s", rec.Code, rec.Body.String())
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != sessionCookie || !cookies[0].HttpOnly {
+		t.Fatalf("cookies = %+v", cookies)
+	}
+	if len(store.created) != 1 || store.created[0].ID != hashSessionToken(cookies[0].Value) || store.created[0].UserID != 1 {
+		t.Errorf("session = %+v", store.created)
+	}
+	if !strings.Contains(rec.Body.String(), `"csrf_token":"`+store.created[0].CSRFToken+`"`) {
+		t.Errorf("body = %s", rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "$2a$") {
+		t.Errorf("password hash in response: %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/v1/auth/logout", nil)
+	req.AddCookie(cookies[0])
+	h.Logout(rec, req)
+	if rec.Code != http.StatusNoContent || len(store.deleted) != 1 || store.deleted[0] != store.created[0].ID {
+		t.Errorf("logout: %d, deleted %v", rec.Code, store.deleted)
+	}
+}
+
+func TestLoginRateLimit(t *testing.T) {
+	store := newMockSessionStore(t)
+	h := NewAuthHandler(store, NewSessions(store, time.Hour, zerolog.Nop()), time.Hour)
+	var code int
+	for i := 0; i <= loginAttemptsPerMin; i++ {
+		rec := httptest.NewRecorder()
+		h.Login(rec, httptest.NewRequest("POST", "/api/v1/auth/login",
+			strings.NewReader(`{"username":"reader","password":"guess"}`)))
+		code = rec.Code
+	}
+	if code != http.StatusTooManyRequests {
+		t.Errorf("attempt %d: %d, want 429", loginAttemptsPerMin+1, code)
+	}
+}
+
+func TestValidatePreferences(t *testing.T) {
+	p := database.UserPreferences{PinnedTalkgroups: []string{"1:9044", " 01:9044", "2:100"}}
+	if msg := validatePreferences(&p); msg != "" {
+		t.Fatal(msg)
+	}
+	if len(p.PinnedTalkgroups) != 2 || p.PinnedTalkgroups[1] != "2:100" || p.DefaultFilters == nil {
+		t.Errorf("normalized = %+v", p)
+	}
+	for _, bad := range [][]string{{"9044"}, {"1:abc"}, {"0:9044"}} {
+		if msg := validatePreferences(&database.UserPreferences{PinnedTalkgroups: bad}); msg == "" {
+			t.Errorf("%v: expected an error", bad)
+		}
+	}
+}
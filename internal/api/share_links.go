@@ -0,0 +1,467 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// Share links give someone without a token temporary read access to a few
+// talkgroups: their calls list, call audio and live events. The scope and
+// expiry are carried in an HMAC-signed ?share= token, so verifying one needs
+// no lookup beyond the revocation check against share_links.
+
+const (
+	shareMaxTTL         = 30 * 24 * time.Hour
+	shareDefaultTTL     = 24 * time.Hour
+	shareDefaultHistory = 24 * time.Hour
+)
+
+// shareClaims is the signed part of a share token.
+type shareClaims struct {
+	LinkID   int   `json:"id"`
+	SystemID int   `json:"sys,omitempty"`
+	Tgids    []int `json:"tg"`
+	Since    int64 `json:"since"` // unix seconds
+	Expires  int64 `json:"exp"`
+}
+
+// shareScope is what a verified share token grants.
+type shareScope struct {
+	LinkID   int
+	SystemID int // 0 = any system
+	Tgids    []int
+	Since    time.Time // oldest call visible
+	Expires  time.Time
+
+	token  string                                          // as presented, for links in responses
+	active func(ctx context.Context, id int) (bool, error) // revocation check, from ShareAuth
+}
+
+// revoked reports whether the link was revoked since ShareAuth checked it.
+// A failed lookup counts as revoked and is returned, since ShareAuth refuses
+// the request when it can't check.
+func (s *shareScope) revoked(ctx context.Context) (bool, error) {
+	if s.active == nil {
+		return false, nil
+	}
+	ok, err := s.active(ctx, s.LinkID)
+	return err != nil || !ok, err
+}
+
+// allows reports whether a call is inside the scope.
+func (s *shareScope) allows(systemID, tgid int, start time.Time) bool {
+	if s.SystemID != 0 && systemID != s.SystemID {
+		return false
+	}
+	return containsInt(s.Tgids, tgid) && !start.Before(s.Since)
+}
+
+// restrictCalls narrows a calls filter to the scope. Requested systems and
+// talkgroups are intersected with it; false means nothing can match.
+func (s *shareScope) restrictCalls(f *database.CallFilter) bool {
+	if s.SystemID != 0 {
+		if len(f.SystemIDs) > 0 && !containsInt(f.SystemIDs, s.SystemID) {
+			return false
+		}
+		f.SystemIDs = []int{s.SystemID}
+	}
+	f.Tgids = intersectInts(f.Tgids, s.Tgids)
+	if len(f.Tgids) == 0 {
+		return false
+	}
+	if f.StartTime == nil || f.StartTime.Before(s.Since) {
+		since := s.Since
+		f.StartTime = &since
+	}
+	if f.EndTime != nil && !f.EndTime.After(*f.StartTime) {
+		return false
+	}
+	f.IncludeRestricted = false
+	return true
+}
+
+// restrictEvents narrows an SSE filter to the scope, dropping events that
+// don't name a talkgroup (and system, when the scope has one). False means
+// nothing can match.
+func (s *shareScope) restrictEvents(f *EventFilter) bool {
+	if s.SystemID != 0 {
+		if len(f.Systems) > 0 && !containsInt(f.Systems, s.SystemID) {
+			return false
+		}
+		f.Systems = []int{s.SystemID}
+	}
+	f.Tgids = intersectInts(f.Tgids, s.Tgids)
+	if len(f.Tgids) == 0 {
+		return false
+	}
+	f.Scoped = true
+	f.IncludeRestricted = false
+	return true
+}
+
+// audioURL appends the share token to a call's audio URL so a shared client
+// can play it.
+func (s *shareScope) audioURL(u string) string {
+	sep := "?"
+	if strings.Contains(u, "?") {
+		sep = "&"
+	}
+	return u + sep + "share=" + url.QueryEscape(s.token)
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// intersectInts returns the values of scope also in requested, or all of
+// scope when nothing was requested.
+func intersectInts(requested, scope []int) []int {
+	if len(requested) == 0 {
+		return scope
+	}
+	var out []int
+	for _, v := range requested {
+		if containsInt(scope, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// ShareSigner signs and verifies share tokens.
+type ShareSigner struct {
+	key []byte
+}
+
+// NewShareSigner returns a signer keyed by SHARE_SIGNING_KEY, or by a key
+// derived from WRITE_TOKEN when that is empty. Returns nil (share links
+// disabled) when both are.
+func NewShareSigner(signingKey, writeToken string) *ShareSigner {
+	if signingKey != "" {
+		return &ShareSigner{key: []byte(signingKey)}
+	}
+	if writeToken == "" {
+		return nil
+	}
+	mac := hmac.New(sha256.New, []byte(writeToken))
+	mac.Write([]byte("tr-engine share links"))
+	return &ShareSigner{key: mac.Sum(nil)}
+}
+
+// Sign returns the token for a share link: base64url JSON scope, a dot,
+// and the base64url HMAC-SHA256 of the first part.
+func (s *ShareSigner) Sign(l *database.ShareLink) string {
+	c := shareClaims{
+		LinkID:  l.ID,
+		Tgids:   l.Tgids,
+		Since:   l.Since().Unix(),
+		Expires: l.ExpiresAt.Unix(),
+	}
+	if l.SystemID != nil {
+		c.SystemID = *l.SystemID
+	}
+	payload, _ := json.Marshal(c)
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return body + "." + base64.RawURLEncoding.EncodeToString(s.mac(body))
+}
+
+// Verify checks a token's signature and expiry and returns its scope.
+func (s *ShareSigner) Verify(token string, now time.Time) (*shareScope, error) {
+	body, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, fmt.Errorf("malformed share token")
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, s.mac(body)) {
+		return nil, fmt.Errorf("invalid share token signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return nil, fmt.Errorf("malformed share token")
+	}
+	var c shareClaims
+	if err := json.Unmarshal(payload, &c); err != nil || c.LinkID == 0 || len(c.Tgids) == 0 {
+		return nil, fmt.Errorf("malformed share token")
+	}
+	sc := &shareScope{
+		LinkID:   c.LinkID,
+		SystemID: c.SystemID,
+		Tgids:    c.Tgids,
+		Since:    time.Unix(c.Since, 0),
+		Expires:  time.Unix(c.Expires, 0),
+		token:    token,
+	}
+	if !now.Before(sc.Expires) {
+		return nil, fmt.Errorf("share token expired")
+	}
+	return sc, nil
+}
+
+func (s *ShareSigner) mac(body string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(body))
+	return mac.Sum(nil)
+}
+
+type shareKey struct{}
+
+// shareScopeFrom returns the scope ShareAuth granted r, or nil for requests
+// authenticated normally.
+func shareScopeFrom(r *http.Request) *shareScope {
+	s, _ := r.Context().Value(shareKey{}).(*shareScope)
+	return s
+}
+
+// shareAllowed reports whether a share token may be used for a request:
+// GET on the calls list, a call's audio or the event stream (SSE or
+// WebSocket).
+func shareAllowed(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	switch p := r.URL.Path; p {
+	case "/api/v1/calls", "/api/v1/events/stream", "/api/v1/events/ws":
+		return true
+	default:
+		id, ok := strings.CutPrefix(p, "/api/v1/calls/")
+		if !ok {
+			return false
+		}
+		id, ok = strings.CutSuffix(id, "/audio")
+		return ok && id != "" && !strings.Contains(id, "/")
+	}
+}
+
+// ShareAuth authenticates requests carrying ?share=. A valid, unrevoked
+// token on an allowed endpoint puts its scope in the request context, which
+// BearerAuth accepts in place of a token; anything else is rejected.
+// Requests without ?share= pass through untouched. active reports whether a
+// link is still unrevoked (database.DB.ShareLinkActive).
+func ShareAuth(signer *ShareSigner, active func(ctx context.Context, id int) (bool, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.URL.Query().Get("share")
+			if token == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if signer == nil {
+				WriteError(w, http.StatusUnauthorized, "share links are disabled")
+				return
+			}
+			scope, err := signer.Verify(token, time.Now())
+			if err != nil {
+				WriteError(w, http.StatusUnauthorized, "invalid or expired share link")
+				return
+			}
+			if !shareAllowed(r) {
+				WriteErrorWithCode(w, http.StatusForbidden, ErrForbidden,
+					"share links only grant the calls list, call audio and the event stream")
+				return
+			}
+			ok, err := active(r.Context(), scope.LinkID)
+			if err != nil {
+				WriteError(w, http.StatusInternalServerError, "failed to check share link")
+				return
+			}
+			if !ok {
+				WriteError(w, http.StatusUnauthorized, "share link has been revoked")
+				return
+			}
+			scope.active = active
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), shareKey{}, scope)))
+		})
+	}
+}
+
+// ShareLinksHandler issues and revokes share links.
+type ShareLinksHandler struct {
+	db     *database.DB
+	signer *ShareSigner // nil when share links are disabled
+}
+
+func NewShareLinksHandler(db *database.DB, signer *ShareSigner) *ShareLinksHandler {
+	return &ShareLinksHandler{db: db, signer: signer}
+}
+
+// ListShareLinks returns share links, newest first. ?active=false includes
+// revoked and expired links. Tokens are only returned at creation.
+func (h *ShareLinksHandler) ListShareLinks(w http.ResponseWriter, r *http.Request) {
+	activeOnly := true
+	if v := r.URL.Query().Get("active"); v != "" {
+		switch v {
+		case "true":
+		case "false":
+			activeOnly = false
+		default:
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "active must be true or false")
+			return
+		}
+	}
+	links, err := h.db.ListShareLinks(r.Context(), activeOnly)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list share links")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"share_links": links,
+		"total":       len(links),
+	})
+}
+
+// CreateShareLink issues a link to tgids (on system_id, or any system) that
+// expires after expires_in (default 24h, at most 30 days) and shows calls
+// from history (default 24h) before now. The response carries the token and
+// ready-made URLs; the token can't be retrieved later.
+func (h *ShareLinksHandler) CreateShareLink(w http.ResponseWriter, r *http.Request) {
+	if h.signer == nil {
+		WriteError(w, http.StatusServiceUnavailable, "share links require SHARE_SIGNING_KEY or WRITE_TOKEN")
+		return
+	}
+	var req struct {
+		Label     string `json:"label"`
+		Actor     string `json:"actor"`
+		SystemID  *int   `json:"system_id"`
+		Tgids     []int  `json:"tgids"`
+		ExpiresIn string `json:"expires_in"`
+		History   string `json:"history"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	link := database.ShareLink{
+		Label:     strings.TrimSpace(req.Label),
+		CreatedBy: strings.TrimSpace(req.Actor),
+		SystemID:  req.SystemID,
+	}
+	if link.Label == "" || link.CreatedBy == "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "label and actor are required")
+		return
+	}
+	for _, tg := range req.Tgids {
+		if tg <= 0 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "tgids must be positive")
+			return
+		}
+		if !containsInt(link.Tgids, tg) {
+			link.Tgids = append(link.Tgids, tg)
+		}
+	}
+	if len(link.Tgids) == 0 {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "tgids is required")
+		return
+	}
+	if link.SystemID != nil && *link.SystemID <= 0 {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "system_id must be positive")
+		return
+	}
+
+	ttl, history := shareDefaultTTL, shareDefaultHistory
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 || d > shareMaxTTL {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "expires_in must be a duration between 0 and 720h")
+			return
+		}
+		ttl = d
+	}
+	if req.History != "" {
+		d, err := time.ParseDuration(req.History)
+		if err != nil || d < 0 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "history must be a non-negative duration")
+			return
+		}
+		history = d
+	}
+	link.HistorySeconds = int(history / time.Second)
+	link.ExpiresAt = time.Now().Add(ttl)
+
+	if err := h.db.CreateShareLink(r.Context(), &link); err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to create share link")
+		return
+	}
+	token := h.signer.Sign(&link)
+	q := url.QueryEscape(token)
+	WriteJSON(w, http.StatusCreated, map[string]any{
+		"share_link": link,
+		"token":      token,
+		"urls": map[string]string{
+			"calls":  "/api/v1/calls?share=" + q,
+			"audio":  "/api/v1/calls/{call_id}/audio?share=" + q,
+			"events": "/api/v1/events/stream?share=" + q,
+		},
+	})
+}
+
+// GetShareLink returns a share link, active or not.
+func (h *ShareLinksHandler) GetShareLink(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid share link ID")
+		return
+	}
+	link, err := h.db.GetShareLink(r.Context(), id)
+	if err != nil {
+		h.writeLinkError(w, err, "failed to get share link")
+		return
+	}
+	WriteJSON(w, http.StatusOK, link)
+}
+
+// RevokeShareLink revokes a link (?actor= is required). Requests with its
+// token are refused from then on, and open event streams end at their next
+// keepalive.
+func (h *ShareLinksHandler) RevokeShareLink(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid share link ID")
+		return
+	}
+	actor := strings.TrimSpace(r.URL.Query().Get("actor"))
+	if actor == "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "actor is required")
+		return
+	}
+	link, err := h.db.RevokeShareLink(r.Context(), id, actor)
+	if err != nil {
+		h.writeLinkError(w, err, "failed to revoke share link")
+		return
+	}
+	WriteJSON(w, http.StatusOK, link)
+}
+
+func (h *ShareLinksHandler) writeLinkError(w http.ResponseWriter, err error, msg string) {
+	switch err.Error() {
+	case "share link not found":
+		WriteError(w, http.StatusNotFound, err.Error())
+	case "share link is revoked":
+		WriteError(w, http.StatusConflict, err.Error())
+	default:
+		WriteError(w, http.StatusInternalServerError, msg)
+	}
+}
+
+func (h *ShareLinksHandler) Routes(r chi.Router) {
+	r.Get("/admin/share-links", h.ListShareLinks)
+	r.Post("/admin/share-links", h.CreateShareLink)
+	r.Get("/admin/share-links/{id}", h.GetShareLink)
+	r.Delete("/admin/share-links/{id}", h.RevokeShareLink)
+}
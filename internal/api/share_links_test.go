@@ -0,0 +1,178 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+func testShareLink() *database.ShareLink {
+	sys := 3
+	now := time.Now()
+	return &database.ShareLink{
+		ID:             7,
+		SystemID:       &sys,
+		Tgids:          []int{100, 101},
+		HistorySeconds: 3600,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(time.Hour),
+	}
+}
+
+func TestShareSigner(t *testing.T) {
+	if NewShareSigner("", "") != nil {
+		t.Fatal("expected no signer without a key or write token")
+	}
+	signer := NewShareSigner("", "write-secret")
+	link := testShareLink()
+	token := signer.Sign(link)
+
+	sc, err := signer.Verify(token, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.LinkID != 7 || sc.SystemID != 3 || len(sc.Tgids) != 2 ||
+		sc.Since.Unix() != link.Since().Unix() || sc.Expires.Unix() != link.ExpiresAt.Unix() {
+		t.Errorf("scope = %+v", sc)
+	}
+
+	if _, err := signer.Verify(token, link.ExpiresAt.Add(time.Second)); err == nil {
+		t.Error("expected expired token to fail")
+	}
+	if _, err := NewShareSigner("other-key", "").Verify(token, time.Now()); err == nil {
+		t.Error("expected token signed with another key to fail")
+	}
+	tampered := "X" + token[1:]
+	if _, err := signer.Verify(tampered, time.Now()); err == nil {
+		t.Error("expected tampered token to fail")
+	}
+	if _, err := signer.Verify("garbage", time.Now()); err == nil {
+		t.Error("expected malformed token to fail")
+	}
+}
+
+func TestShareAuth(t *testing.T) {
+	signer := NewShareSigner("key", "")
+	token := url.QueryEscape(signer.Sign(testShareLink()))
+	revoked := false
+	active := func(ctx context.Context, id int) (bool, error) { return !revoked, nil }
+
+	var got *shareScope
+	h := ShareAuth(signer, active)(BearerAuth("read-token")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = shareScopeFrom(r)
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		want   int
+	}{
+		{"calls_list", "GET", "/api/v1/calls?share=" + token, http.StatusOK},
+		{"call_audio", "GET", "/api/v1/calls/42/audio?share=" + token, http.StatusOK},
+		{"event_stream", "GET", "/api/v1/events/stream?share=" + token, http.StatusOK},
+		{"event_websocket", "GET", "/api/v1/events/ws?share=" + token, http.StatusOK},
+		{"other_endpoint", "GET", "/api/v1/units?share=" + token, http.StatusForbidden},
+		{"call_detail", "GET", "/api/v1/calls/42?share=" + token, http.StatusForbidden},
+		{"write", "POST", "/api/v1/calls?share=" + token, http.StatusForbidden},
+		{"bad_token", "GET", "/api/v1/calls?share=abc.def", http.StatusUnauthorized},
+		{"no_share_no_token", "GET", "/api/v1/calls", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusOK && (got == nil || got.LinkID != 7) {
+				t.Errorf("scope = %+v", got)
+			}
+		})
+	}
+
+	revoked = true
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/calls?share="+token, nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("revoked link: status = %d, want 401", rec.Code)
+	}
+}
+
+func TestShareAuthNotAdmin(t *testing.T) {
+	signer := NewShareSigner("key", "")
+	token := url.QueryEscape(signer.Sign(testShareLink()))
+	active := func(ctx context.Context, id int) (bool, error) { return true, nil }
+
+	var admin bool
+	h := ShareAuth(signer, active)(AdminContext(false, "")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		admin = isAdmin(r)
+	})))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/calls?share="+token, nil))
+	if admin {
+		t.Error("share link request marked admin with auth disabled")
+	}
+}
+
+func TestShareScopeRestrict(t *testing.T) {
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	sc := &shareScope{LinkID: 1, SystemID: 3, Tgids: []int{100, 101}, Since: since}
+
+	f := database.CallFilter{Tgids: []int{101, 200}, IncludeRestricted: true}
+	if !sc.restrictCalls(&f) {
+		t.Fatal("expected calls filter to match")
+	}
+	if len(f.SystemIDs) != 1 || f.SystemIDs[0] != 3 || len(f.Tgids) != 1 || f.Tgids[0] != 101 ||
+		f.StartTime == nil || !f.StartTime.Equal(since) || f.IncludeRestricted {
+		t.Errorf("filter = %+v", f)
+	}
+	if sc.restrictCalls(&database.CallFilter{SystemIDs: []int{4}}) {
+		t.Error("expected other system to match nothing")
+	}
+	if sc.restrictCalls(&database.CallFilter{Tgids: []int{200}}) {
+		t.Error("expected other talkgroup to match nothing")
+	}
+
+	ef := EventFilter{}
+	if !sc.restrictEvents(&ef) || !ef.Scoped || len(ef.Tgids) != 2 || len(ef.Systems) != 1 {
+		t.Errorf("event filter = %+v", ef)
+	}
+
+	if !sc.allows(3, 100, since) || sc.allows(3, 100, since.Add(-time.Second)) ||
+		sc.allows(4, 100, since) || sc.allows(3, 102, since) {
+		t.Error("allows gave wrong answer")
+	}
+
+	sc.token = "a.b"
+	if got := sc.audioURL("/api/v1/calls/5/audio"); got != "/api/v1/calls/5/audio?share=a.b" {
+		t.Errorf("audioURL = %q", got)
+	}
+}
+
+func TestShareScopeRevoked(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		active  func(ctx context.Context, id int) (bool, error)
+		want    bool
+		wantErr bool
+	}{
+		{"no check", nil, false, false},
+		{"active", func(context.Context, int) (bool, error) { return true, nil }, false, false},
+		{"revoked", func(context.Context, int) (bool, error) { return false, nil }, true, false},
+		{"lookup error", func(context.Context, int) (bool, error) { return true, errors.New("connection refused") }, true, true},
+	} {
+		sc := &shareScope{LinkID: 1, active: tc.active}
+		got, err := sc.revoked(context.Background())
+		if got != tc.want || (err != nil) != tc.wantErr {
+			t.Errorf("%s: revoked = %v, %v; want %v, error %v", tc.name, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
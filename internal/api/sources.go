@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// SourcesHandler serves the SDR source registry of TR instances.
+type SourcesHandler struct {
+	db   *database.DB
+	live LiveDataSource
+}
+
+func NewSourcesHandler(db *database.DB, live LiveDataSource) *SourcesHandler {
+	return &SourcesHandler{db: db, live: live}
+}
+
+// sourceView is a source with its call statistics and the recorders
+// currently tuned through it.
+type sourceView struct {
+	database.SourceReport
+	Recorders []RecorderStateData `json:"recorders"`
+}
+
+// ListSources returns an instance's sources with per-source and
+// per-frequency call counts and error rates, defaulting to the last 24 hours.
+// GET /api/v1/instances/{instance_id}/sources
+func (h *SourcesHandler) ListSources(w http.ResponseWriter, r *http.Request) {
+	end := time.Now()
+	if t, ok := QueryTime(r, "end_time"); ok {
+		end = t
+	}
+	start := end.Add(-24 * time.Hour)
+	if t, ok := QueryTime(r, "start_time"); ok {
+		start = t
+	}
+	if msg := ValidateTimeRange(&start, &end); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+
+	instanceID := chi.URLParam(r, "instance_id")
+	rep, err := h.db.GetSourcesReport(r.Context(), instanceID, start, end)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list sources")
+		return
+	}
+	var recorders []RecorderStateData
+	if h.live != nil {
+		recorders = h.live.LatestRecorders()
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"instance_id":      rep.InstanceID,
+		"start_time":       rep.StartTime,
+		"end_time":         rep.EndTime,
+		"sources":          sourceViews(rep, recorders),
+		"unassigned_calls": rep.UnassignedCalls,
+	})
+}
+
+// sourceViews pairs each source in the report with the instance's live
+// recorders on the same source number.
+func sourceViews(rep *database.SourcesReport, recorders []RecorderStateData) []sourceView {
+	views := make([]sourceView, len(rep.Sources))
+	bySource := make(map[int]*sourceView, len(rep.Sources))
+	for i, s := range rep.Sources {
+		views[i] = sourceView{SourceReport: s, Recorders: []RecorderStateData{}}
+		bySource[s.SourceNum] = &views[i]
+	}
+	for _, rec := range recorders {
+		if rec.InstanceID != rep.InstanceID {
+			continue
+		}
+		if v := bySource[int(rec.SrcNum)]; v != nil {
+			v.Recorders = append(v.Recorders, rec)
+		}
+	}
+	return views
+}
+
+// Routes registers source registry routes on the given router.
+func (h *SourcesHandler) Routes(r chi.Router) {
+	r.Get("/instances/{instance_id}/sources", h.ListSources)
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+func TestSourceViews(t *testing.T) {
+	rep := &database.SourcesReport{
+		InstanceID: "tr-1",
+		Sources: []database.SourceReport{
+			{Source: database.Source{InstanceID: "tr-1", SourceNum: 0}},
+			{Source: database.Source{InstanceID: "tr-1", SourceNum: 1}},
+		},
+	}
+	recorders := []RecorderStateData{
+		{ID: "tr-1/0/0", InstanceID: "tr-1", SrcNum: 0, RecNum: 0},
+		{ID: "tr-1/1/4", InstanceID: "tr-1", SrcNum: 1, RecNum: 4},
+		{ID: "tr-1/1/5", InstanceID: "tr-1", SrcNum: 1, RecNum: 5},
+		{ID: "tr-2/0/0", InstanceID: "tr-2", SrcNum: 0, RecNum: 0},
+		{ID: "tr-1/2/0", InstanceID: "tr-1", SrcNum: 2, RecNum: 0},
+	}
+
+	views := sourceViews(rep, recorders)
+	if len(views) != 2 {
+		t.Fatalf("views = %d, want 2", len(views))
+	}
+	if len(views[0].Recorders) != 1 || views[0].Recorders[0].ID != "tr-1/0/0" {
+		t.Errorf("source 0 recorders = %+v", views[0].Recorders)
+	}
+	if len(views[1].Recorders) != 2 {
+		t.Errorf("source 1 recorders = %+v", views[1].Recorders)
+	}
+
+	if views := sourceViews(rep, nil); views[0].Recorders == nil {
+		t.Error("expected empty, not nil, recorders without live data")
+	}
+}
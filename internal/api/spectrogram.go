@@ -0,0 +1,169 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/zerolog/hlog"
+	"github.com/snarg/tr-engine/internal/audio"
+)
+
+// spectrogramDir is where rendered spectrograms are cached, under AUDIO_DIR.
+const spectrogramDir = ".spectrograms"
+
+// spectrogramSlots bounds concurrent renders; each decodes a whole call,
+// possibly through ffmpeg, and a call browser requests a page of them at once.
+var spectrogramSlots = make(chan struct{}, 2)
+
+// GetCallSpectrogram serves a PNG spectrogram of a call's audio, rendering
+// it on first request and caching it on disk. The cache is keyed on the audio
+// as well as the call, so audio replaced later (reconciliation, variants,
+// merges) renders anew.
+func (h *CallsHandler) GetCallSpectrogram(w http.ResponseWriter, r *http.Request) {
+	ref, err := ParseCallRef(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid call ID")
+		return
+	}
+	if h.hideRestricted(w, r, ref, "audio not found") {
+		return
+	}
+
+	audioPath, callFilename, err := h.db.GetCallAudioPath(r.Context(), ref)
+	if err != nil || (audioPath == "" && callFilename == "") {
+		WriteError(w, http.StatusNotFound, "audio not found")
+		return
+	}
+	cached := h.spectrogramPath(ref.CallID, spectrogramKey(audioPath, callFilename, h.audioModTime(audioPath, callFilename)))
+	if _, err := os.Stat(cached); err == nil {
+		h.serveSpectrogram(w, r, cached)
+		return
+	}
+
+	select {
+	case spectrogramSlots <- struct{}{}:
+		defer func() { <-spectrogramSlots }()
+	case <-r.Context().Done():
+		return
+	}
+	// Another request may have rendered it while this one waited
+	if _, err := os.Stat(cached); err == nil {
+		h.serveSpectrogram(w, r, cached)
+		return
+	}
+
+	path, cleanup := h.localAudioFile(r, audioPath, callFilename)
+	defer cleanup()
+	if path == "" {
+		WriteError(w, http.StatusNotFound, "audio not found")
+		return
+	}
+	samples, err := audio.DecodeFile(r.Context(), path, audio.SpectrogramRate)
+	if err != nil {
+		hlog.FromRequest(r).Warn().Err(err).Int64("call_id", ref.CallID).Msg("spectrogram: decode failed")
+		WriteError(w, http.StatusUnprocessableEntity, "audio could not be decoded")
+		return
+	}
+	var buf bytes.Buffer
+	if err := audio.WriteSpectrogramPNG(&buf, samples); err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to render spectrogram")
+		return
+	}
+
+	if err := writeFileAtomic(cached, buf.Bytes()); err != nil {
+		// Still serve it; the next request renders again
+		hlog.FromRequest(r).Warn().Err(err).Str("path", cached).Msg("spectrogram: cache write failed")
+	} else {
+		h.removeSpectrograms(ref.CallID, cached)
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.Write(buf.Bytes())
+}
+
+// spectrogramKey identifies the audio a spectrogram was rendered from: its
+// stored path, TR's filename and, when the file is on local disk, its
+// modification time.
+func spectrogramKey(audioPath, callFilename string, modTime time.Time) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%d", audioPath, callFilename, modTime.UnixNano())))
+	return hex.EncodeToString(sum[:6])
+}
+
+// audioModTime returns the modification time of a call's audio if it is on
+// local disk, else the zero time.
+func (h *CallsHandler) audioModTime(audioPath, callFilename string) time.Time {
+	path := ""
+	if audioPath != "" && h.store != nil {
+		path = h.store.LocalPath(audioPath)
+	}
+	if path == "" {
+		path = h.resolveAudioFile(audioPath, callFilename)
+	}
+	if path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// spectrogramPath returns the cache file for a call's audio, sharded by ID
+// so no directory grows past 10,000 calls.
+func (h *CallsHandler) spectrogramPath(callID int64, key string) string {
+	return filepath.Join(h.spectrogramShard(callID), fmt.Sprintf("%d-%s.png", callID, key))
+}
+
+func (h *CallsHandler) spectrogramShard(callID int64) string {
+	return filepath.Join(h.audioDir, spectrogramDir, fmt.Sprint(callID/10000))
+}
+
+// removeSpectrograms deletes a call's cached spectrograms other than keep
+// (all of them when keep is empty).
+func (h *CallsHandler) removeSpectrograms(callID int64, keep string) {
+	shard := h.spectrogramShard(callID)
+	matches, _ := filepath.Glob(filepath.Join(shard, fmt.Sprintf("%d-*.png", callID)))
+	// Spectrograms cached before they were keyed on the audio
+	matches = append(matches, filepath.Join(shard, fmt.Sprintf("%d.png", callID)))
+	for _, m := range matches {
+		if m != keep {
+			os.Remove(m)
+		}
+	}
+}
+
+func (h *CallsHandler) serveSpectrogram(w http.ResponseWriter, r *http.Request, path string) {
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	http.ServeFile(w, r, path)
+}
+
+// writeFileAtomic writes data to path via a temp file and rename, creating
+// the parent directory, so readers never see a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
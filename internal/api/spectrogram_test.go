@@ -0,0 +1,46 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSpectrogramCacheKey(t *testing.T) {
+	mtime := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	base := spectrogramKey("1/2026/03/01/call.m4a", "", mtime)
+	for name, key := range map[string]string{
+		"new path":   spectrogramKey("1/2026/03/01/call-redacted.m4a", "", mtime),
+		"reconciled": spectrogramKey("", "/tr/audio/call.wav", time.Time{}),
+		"rewritten":  spectrogramKey("1/2026/03/01/call.m4a", "", mtime.Add(time.Second)),
+	} {
+		if key == base {
+			t.Errorf("%s: key unchanged", name)
+		}
+	}
+	if spectrogramKey("1/2026/03/01/call.m4a", "", mtime) != base {
+		t.Error("key not stable")
+	}
+
+	h := &CallsHandler{audioDir: t.TempDir()}
+	keep := h.spectrogramPath(123456, base)
+	stale := []string{h.spectrogramPath(123456, "000000000000"), filepath.Join(filepath.Dir(keep), "123456.png")}
+	other := h.spectrogramPath(123457, base)
+	for _, p := range append(stale, keep, other) {
+		if err := writeFileAtomic(p, []byte("png")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.removeSpectrograms(123456, keep)
+	for _, p := range stale {
+		if _, err := os.Stat(p); err == nil {
+			t.Errorf("%s not removed", p)
+		}
+	}
+	for _, p := range []string{keep, other} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("%s removed", p)
+		}
+	}
+}
@@ -2,18 +2,21 @@ package api
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/snarg/tr-engine/internal/database"
 )
 
 type StatsHandler struct {
-	db *database.DB
+	db    *database.DB
+	cache *ResponseCache
 }
 
-func NewStatsHandler(db *database.DB) *StatsHandler {
-	return &StatsHandler{db: db}
+func NewStatsHandler(db *database.DB, cache *ResponseCache) *StatsHandler {
+	return &StatsHandler{db: db, cache: cache}
 }
 
 // GetStats returns overall system statistics.
@@ -99,45 +102,6 @@ func (h *StatsHandler) ListTrunkingMessages(w http.ResponseWriter, r *http.Reque
 	})
 }
 
-// ListConsoleMessages returns paginated console log messages.
-func (h *StatsHandler) ListConsoleMessages(w http.ResponseWriter, r *http.Request) {
-	p, err := ParsePagination(r)
-	if err != nil {
-		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
-		return
-	}
-	filter := database.ConsoleMessageFilter{
-		Limit:  p.Limit,
-		Offset: p.Offset,
-	}
-	if v, ok := QueryString(r, "instance_id"); ok {
-		filter.InstanceID = &v
-	}
-	if v, ok := QueryString(r, "severity"); ok {
-		filter.Severity = &v
-	}
-	if t, ok := QueryTime(r, "start_time"); ok {
-		filter.StartTime = &t
-	}
-	if t, ok := QueryTime(r, "end_time"); ok {
-		filter.EndTime = &t
-	}
-	if msg := ValidateTimeRange(filter.StartTime, filter.EndTime); msg != "" {
-		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
-		return
-	}
-
-	messages, total, err := h.db.ListConsoleMessages(r.Context(), filter)
-	if err != nil {
-		WriteError(w, http.StatusInternalServerError, "failed to list console messages")
-		return
-	}
-	WriteJSON(w, http.StatusOK, map[string]any{
-		"messages": messages,
-		"total":    total,
-	})
-}
-
 // GetTalkgroupActivity returns call counts grouped by talkgroup for a time range.
 func (h *StatsHandler) GetTalkgroupActivity(w http.ResponseWriter, r *http.Request) {
 	p, err := ParsePagination(r)
@@ -284,20 +248,258 @@ func (h *StatsHandler) GetCallHeatmap(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, http.StatusOK, map[string]any{"cells": cells, "timezone": tz})
 }
 
+// GetDurationDiscrepancies reports, per recorder, how often TR's reported call
+// length disagreed with the measured length of the saved audio. Recorders that
+// keep producing truncated files rise to the top.
+func (h *StatsHandler) GetDurationDiscrepancies(w http.ResponseWriter, r *http.Request) {
+	filter := database.DurationDiscrepancyFilter{
+		SystemIDs: QueryIntListAliased(r, "system_id", "systems"),
+		StartTime: time.Now().Add(-7 * 24 * time.Hour),
+		MinCalls:  1,
+	}
+	if t, ok := QueryTime(r, "start_time"); ok {
+		filter.StartTime = t
+	}
+	if t, ok := QueryTime(r, "end_time"); ok {
+		filter.EndTime = &t
+	}
+	if msg := ValidateTimeRange(&filter.StartTime, filter.EndTime); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+	if v := r.URL.Query().Get("tolerance"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "tolerance must be a non-negative number of seconds")
+			return
+		}
+		filter.Tolerance = &f
+	}
+	if v, ok := QueryInt(r, "min_calls"); ok {
+		if v < 1 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "min_calls must be at least 1")
+			return
+		}
+		filter.MinCalls = v
+	}
+
+	recorders, err := h.db.GetDurationDiscrepancies(r.Context(), filter)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get duration discrepancies")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"recorders": recorders,
+		"total":     len(recorders),
+	})
+}
+
+// GetUnusableAudio reports, per recorder, the calls whose recording was
+// empty or silent, to help track down a misbehaving recorder or source.
+func (h *StatsHandler) GetUnusableAudio(w http.ResponseWriter, r *http.Request) {
+	filter := database.UnusableAudioFilter{
+		SystemIDs: QueryIntListAliased(r, "system_id", "systems"),
+		StartTime: time.Now().Add(-7 * 24 * time.Hour),
+	}
+	if t, ok := QueryTime(r, "start_time"); ok {
+		filter.StartTime = t
+	}
+	if t, ok := QueryTime(r, "end_time"); ok {
+		filter.EndTime = &t
+	}
+	if msg := ValidateTimeRange(&filter.StartTime, filter.EndTime); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+
+	recorders, err := h.db.GetUnusableAudio(r.Context(), filter)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get unusable audio report")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"recorders": recorders,
+		"total":     len(recorders),
+	})
+}
+
 // isInvalidTimezone checks if a PG error is due to an invalid timezone name.
 func isInvalidTimezone(err error) bool {
 	return strings.Contains(err.Error(), "time zone")
 }
 
+// GetInterconnectUsage reports telephone interconnect calls per unit over a
+// time range (default: the last 7 days), heaviest users first.
+func (h *StatsHandler) GetInterconnectUsage(w http.ResponseWriter, r *http.Request) {
+	filter := database.InterconnectUsageFilter{
+		SystemIDs: QueryIntListAliased(r, "system_id", "systems"),
+		UnitIDs:   QueryIntListAliased(r, "unit_id", "units", "unit_ids"),
+		StartTime: time.Now().Add(-7 * 24 * time.Hour),
+	}
+	if t, ok := QueryTime(r, "start_time"); ok {
+		filter.StartTime = t
+	}
+	if t, ok := QueryTime(r, "end_time"); ok {
+		filter.EndTime = &t
+	}
+	if msg := ValidateTimeRange(&filter.StartTime, filter.EndTime); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+
+	units, err := h.db.GetInterconnectUsage(r.Context(), filter)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get interconnect usage")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"units": units,
+		"total": len(units),
+	})
+}
+
+// parseUnitEncryptionFilter reads the shared filters of the unit encryption
+// reports. The window defaults to the last 30 days.
+func parseUnitEncryptionFilter(w http.ResponseWriter, r *http.Request) (database.UnitEncryptionFilter, bool) {
+	filter := database.UnitEncryptionFilter{
+		SystemIDs: QueryIntListAliased(r, "system_id", "systems"),
+		UnitIDs:   QueryIntListAliased(r, "unit_id", "units", "unit_ids"),
+		StartTime: time.Now().Add(-30 * 24 * time.Hour),
+		Limit:     100,
+	}
+	if t, ok := QueryTime(r, "start_time"); ok {
+		filter.StartTime = t
+	}
+	if t, ok := QueryTime(r, "end_time"); ok {
+		filter.EndTime = &t
+	}
+	if msg := ValidateTimeRange(&filter.StartTime, filter.EndTime); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return filter, false
+	}
+	if v, ok := QueryInt(r, "limit"); ok {
+		if v < 1 || v > 1000 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "limit must be between 1 and 1000")
+			return filter, false
+		}
+		filter.Limit = v
+	}
+	return filter, true
+}
+
+// GetUnitEncryptionUsage returns per-unit encrypted airtime, attributed to the
+// unit that initiated each call, from the daily rollup.
+func (h *StatsHandler) GetUnitEncryptionUsage(w http.ResponseWriter, r *http.Request) {
+	filter, ok := parseUnitEncryptionFilter(w, r)
+	if !ok {
+		return
+	}
+	units, err := h.db.GetUnitEncryptionUsage(r.Context(), filter)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get unit encryption usage")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"units": units,
+		"total": len(units),
+	})
+}
+
+// GetEncryptionSwitchers returns units that initiated both encrypted and clear
+// calls in the window. ?min_calls=N requires at least N of each (default 1).
+func (h *StatsHandler) GetEncryptionSwitchers(w http.ResponseWriter, r *http.Request) {
+	filter, ok := parseUnitEncryptionFilter(w, r)
+	if !ok {
+		return
+	}
+	if v, ok := QueryInt(r, "min_calls"); ok {
+		if v < 1 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "min_calls must be at least 1")
+			return
+		}
+		filter.MinCalls = v
+	}
+	units, err := h.db.GetEncryptionSwitchers(r.Context(), filter)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get encryption switchers")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"units": units,
+		"total": len(units),
+	})
+}
+
+// GetCapacity reports Erlang-style channel utilization per site per hour,
+// busy hours and control channel deny rates over a time range (default: the
+// last 7 days), plus recorder utilization per trunk-recorder instance.
+func (h *StatsHandler) GetCapacity(w http.ResponseWriter, r *http.Request) {
+	filter := database.CapacityFilter{
+		SystemIDs: QueryIntListAliased(r, "system_id", "systems"),
+		SiteIDs:   QueryIntListAliased(r, "site_id", "sites"),
+		EndTime:   time.Now(),
+	}
+	filter.StartTime = filter.EndTime.Add(-7 * 24 * time.Hour)
+	if t, ok := QueryTime(r, "start_time"); ok {
+		filter.StartTime = t
+	}
+	if t, ok := QueryTime(r, "end_time"); ok {
+		filter.EndTime = t
+	}
+	if msg := ValidateTimeRange(&filter.StartTime, &filter.EndTime); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+	if filter.EndTime.Sub(filter.StartTime) > 93*24*time.Hour {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, "time range must be at most 93 days")
+		return
+	}
+	if v, ok := QueryString(r, "tz"); ok {
+		filter.Timezone = v
+	}
+
+	sites, err := h.db.GetSiteCapacity(r.Context(), filter)
+	if err != nil {
+		if isInvalidTimezone(err) {
+			WriteError(w, http.StatusBadRequest, "invalid timezone")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to get site capacity")
+		return
+	}
+	recorders, err := h.db.GetRecorderCapacity(r.Context(), filter)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get recorder capacity")
+		return
+	}
+	tz := filter.Timezone
+	if tz == "" {
+		tz = "UTC"
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"start_time": filter.StartTime,
+		"end_time":   filter.EndTime,
+		"timezone":   tz,
+		"sites":      sites,
+		"recorders":  recorders,
+		"total":      len(sites),
+	})
+}
+
 // Routes registers stats routes on the given router.
 func (h *StatsHandler) Routes(r chi.Router) {
-	r.Get("/stats", h.GetStats)
+	r.Get("/stats", h.cache.Cached("stats", 15*time.Second, nil, h.GetStats))
 	r.Get("/stats/rates", h.GetDecodeRates)
 	r.Get("/stats/talkgroup-activity", h.GetTalkgroupActivity)
 	r.Get("/stats/call-volume", h.GetCallVolume)
 	r.Get("/stats/daily-overview", h.GetDailyOverview)
+	r.Get("/stats/duration-discrepancies", h.GetDurationDiscrepancies)
+	r.Get("/stats/unusable-audio", h.GetUnusableAudio)
+	r.Get("/stats/interconnect-usage", h.GetInterconnectUsage)
+	r.Get("/stats/unit-encryption", h.GetUnitEncryptionUsage)
+	r.Get("/stats/encryption-switchers", h.GetEncryptionSwitchers)
 	r.Get("/stats/category-breakdown", h.GetCategoryBreakdown)
 	r.Get("/stats/call-heatmap", h.GetCallHeatmap)
+	r.Get("/stats/capacity", h.GetCapacity)
 	r.Get("/trunking-messages", h.ListTrunkingMessages)
-	r.Get("/console-messages", h.ListConsoleMessages)
 }
@@ -0,0 +1,417 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/mqttclient"
+	"github.com/snarg/tr-engine/internal/storage"
+)
+
+// Public status page: once a minute the sampler checks each component,
+// adds the minute to its daily bucket in status_daily and caches the result,
+// so GET /api/v1/status and GET /status never touch the components
+// themselves and are safe to expose and poll.
+
+const (
+	statusOperational = "operational"
+	statusDegraded    = "degraded"
+	statusDown        = "down"
+
+	// statusHistoryDays is how much uptime history is kept and reported.
+	statusHistoryDays = 90
+
+	// statusStaleAfter is how long ingest may see no MQTT messages, or a
+	// trunk-recorder instance go unheard, before it counts against uptime.
+	// TR publishes rates and recorder updates every few seconds.
+	statusStaleAfter = 5 * time.Minute
+)
+
+// StatusResponse is the public status summary.
+type StatusResponse struct {
+	Status     string            `json:"status"` // worst component status
+	UpdatedAt  time.Time         `json:"updated_at"`
+	Components []StatusComponent `json:"components"`
+}
+
+// StatusComponent is one component's current state and uptime history.
+type StatusComponent struct {
+	Name      string          `json:"name"`
+	Status    string          `json:"status"`
+	Detail    string          `json:"detail,omitempty"`
+	Uptime90d *float64        `json:"uptime_90d"` // percent; nil until a minute has been recorded
+	History   []StatusDayData `json:"history"`
+}
+
+// StatusDayData is a component's uptime for one UTC day. Degraded minutes
+// count as up.
+type StatusDayData struct {
+	Date   string  `json:"date"`
+	Uptime float64 `json:"uptime"`
+	database.StatusDay
+}
+
+// componentState is a component's status at one sample.
+type componentState struct {
+	name   string
+	status string
+	detail string
+}
+
+// StatusHandler samples component health and serves the status page.
+type StatusHandler struct {
+	db    *database.DB
+	mqtt  *mqttclient.Client
+	live  LiveDataSource
+	store storage.AudioStore
+	log   zerolog.Logger
+
+	sampling      sync.Mutex // serializes sample; guards the fields below
+	lastMsgCount  int64
+	lastMsgChange time.Time
+	lastPrune     time.Time
+
+	mu   sync.RWMutex
+	snap *StatusResponse
+}
+
+func NewStatusHandler(db *database.DB, mqtt *mqttclient.Client, live LiveDataSource, store storage.AudioStore, log zerolog.Logger) *StatusHandler {
+	return &StatusHandler{
+		db:    db,
+		mqtt:  mqtt,
+		live:  live,
+		store: store,
+		log:   log.With().Str("component", "status").Logger(),
+	}
+}
+
+// Start samples immediately, then records a sample at the start of every
+// minute until ctx is cancelled.
+func (h *StatusHandler) Start(ctx context.Context) {
+	go func() {
+		h.sample(ctx, time.Now(), false)
+		for {
+			now := time.Now()
+			next := now.Truncate(time.Minute).Add(time.Minute)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(next.Sub(now)):
+			}
+			h.sample(ctx, next, true)
+		}
+	}()
+}
+
+// sample checks every component, records the minute when record is set,
+// and rebuilds the cached response.
+func (h *StatusHandler) sample(ctx context.Context, now time.Time, record bool) {
+	h.sampling.Lock()
+	defer h.sampling.Unlock()
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	states := h.checkComponents(ctx, now)
+	if record {
+		m := make(map[string]string, len(states))
+		for _, s := range states {
+			m[s.name] = s.status
+		}
+		if err := h.db.AddStatusMinute(ctx, now, m); err != nil {
+			h.log.Warn().Err(err).Msg("failed to record status minute")
+		}
+		if now.Sub(h.lastPrune) >= time.Hour {
+			h.lastPrune = now
+			if _, err := h.db.PruneStatusDays(ctx, statusHistoryStart(now)); err != nil {
+				h.log.Warn().Err(err).Msg("failed to prune status history")
+			}
+		}
+	}
+
+	days, err := h.db.ListStatusDays(ctx, statusHistoryStart(now))
+	if err != nil {
+		// Keep serving current states with the previous history
+		h.log.Warn().Err(err).Msg("failed to load status history")
+		h.mu.RLock()
+		if h.snap != nil {
+			days = snapshotDays(h.snap)
+		}
+		h.mu.RUnlock()
+	}
+	resp := buildStatus(states, days, now)
+
+	h.mu.Lock()
+	h.snap = resp
+	h.mu.Unlock()
+}
+
+// checkComponents returns the state of each configured component. Components
+// that are not configured are left out.
+func (h *StatusHandler) checkComponents(ctx context.Context, now time.Time) []componentState {
+	var states []componentState
+
+	db := componentState{name: "database", status: statusOperational}
+	if err := h.db.HealthCheck(ctx); err != nil {
+		db.status, db.detail = statusDown, "unreachable"
+	}
+	states = append(states, db)
+
+	if h.mqtt != nil {
+		mq := componentState{name: "mqtt", status: statusOperational}
+		if !h.mqtt.IsConnected() {
+			mq.status, mq.detail = statusDown, "disconnected"
+		}
+		states = append(states, mq)
+	}
+
+	if h.live != nil {
+		ws := h.live.WatcherStatus()
+		if h.mqtt != nil || ws != nil {
+			var msgCount int64
+			if m := h.live.IngestMetrics(); m != nil {
+				msgCount = m.MsgCount
+			}
+			if msgCount != h.lastMsgCount || h.lastMsgChange.IsZero() {
+				h.lastMsgCount, h.lastMsgChange = msgCount, now
+			}
+			states = append(states, ingestState(h.mqtt != nil && h.mqtt.IsConnected(), ws, now.Sub(h.lastMsgChange)))
+		}
+	}
+
+	if h.store != nil {
+		st := componentState{name: "storage", status: statusOperational}
+		if err := storage.Check(ctx, h.store); err != nil {
+			st.status, st.detail = statusDown, "audio storage unavailable"
+		}
+		states = append(states, st)
+	}
+
+	if h.live != nil {
+		if ts := h.live.TranscriptionStatus(); ts != nil && ts.Status != "not_configured" {
+			tr := componentState{name: "transcription", status: statusOperational}
+			if ts.Status != "ok" {
+				tr.status, tr.detail = statusDown, ts.Status
+			}
+			states = append(states, tr)
+		}
+		if inst := h.live.TRInstanceStatus(); len(inst) > 0 {
+			states = append(states, trunkRecordersState(inst, now))
+		}
+	}
+	return states
+}
+
+// ingestState is degraded when MQTT is connected but no message has arrived
+// for statusStaleAfter, and down when the file watcher has stopped.
+func ingestState(mqttConnected bool, ws *WatcherStatusData, quiet time.Duration) componentState {
+	s := componentState{name: "ingest", status: statusOperational}
+	if ws != nil && ws.Status == "stopped" {
+		s.status, s.detail = statusDown, "file watcher stopped"
+	} else if mqttConnected && quiet >= statusStaleAfter {
+		s.status, s.detail = statusDegraded, "no messages for "+quiet.Truncate(time.Minute).String()
+	}
+	return s
+}
+
+// trunkRecordersState is operational when every instance is connected and
+// heard from recently, down when none is, and degraded otherwise.
+func trunkRecordersState(instances []TRInstanceStatusData, now time.Time) componentState {
+	up := 0
+	for _, in := range instances {
+		if in.Status == "connected" && now.Sub(in.LastSeen) < statusStaleAfter {
+			up++
+		}
+	}
+	s := componentState{name: "trunk_recorders", status: statusOperational}
+	switch {
+	case up == 0:
+		s.status, s.detail = statusDown, "no instances reporting"
+	case up < len(instances):
+		s.status = statusDegraded
+		s.detail = fmt.Sprintf("%d of %d instances not reporting", len(instances)-up, len(instances))
+	}
+	return s
+}
+
+// buildStatus combines the current states with stored daily history.
+func buildStatus(states []componentState, days []database.StatusDay, now time.Time) *StatusResponse {
+	byComponent := make(map[string][]database.StatusDay)
+	for _, d := range days {
+		byComponent[d.Component] = append(byComponent[d.Component], d)
+	}
+
+	resp := &StatusResponse{
+		Status:     statusOperational,
+		UpdatedAt:  now,
+		Components: make([]StatusComponent, 0, len(states)),
+	}
+	for _, s := range states {
+		c := StatusComponent{Name: s.name, Status: s.status, Detail: s.detail, History: []StatusDayData{}}
+		var up, total int
+		for _, d := range byComponent[s.name] {
+			dayTotal := d.Operational + d.Degraded + d.Down
+			if dayTotal == 0 {
+				continue
+			}
+			up += d.Operational + d.Degraded
+			total += dayTotal
+			c.History = append(c.History, StatusDayData{
+				Date:      d.Day.Format("2006-01-02"),
+				Uptime:    uptimePercent(d.Operational+d.Degraded, dayTotal),
+				StatusDay: d,
+			})
+		}
+		if total > 0 {
+			u := uptimePercent(up, total)
+			c.Uptime90d = &u
+		}
+		resp.Components = append(resp.Components, c)
+		resp.Status = worseStatus(resp.Status, s.status)
+	}
+	return resp
+}
+
+// snapshotDays recovers the stored history from a cached response.
+func snapshotDays(resp *StatusResponse) []database.StatusDay {
+	var days []database.StatusDay
+	for _, c := range resp.Components {
+		for _, d := range c.History {
+			days = append(days, d.StatusDay)
+		}
+	}
+	return days
+}
+
+// statusHistoryStart is the first UTC day reported at now.
+func statusHistoryStart(now time.Time) time.Time {
+	return now.UTC().Truncate(24*time.Hour).AddDate(0, 0, -(statusHistoryDays - 1))
+}
+
+func uptimePercent(up, total int) float64 {
+	return float64(int64(float64(up)*10000/float64(total))) / 100 // 2 decimals, never rounded up to 100
+}
+
+var statusRank = map[string]int{statusOperational: 0, statusDegraded: 1, statusDown: 2}
+
+func worseStatus(a, b string) string {
+	if statusRank[b] > statusRank[a] {
+		return b
+	}
+	return a
+}
+
+// snapshot returns the cached response, sampling once if the sampler has
+// not run yet.
+func (h *StatusHandler) snapshot(ctx context.Context) *StatusResponse {
+	h.mu.RLock()
+	snap := h.snap
+	h.mu.RUnlock()
+	if snap == nil {
+		h.sample(ctx, time.Now(), false)
+		h.mu.RLock()
+		snap = h.snap
+		h.mu.RUnlock()
+	}
+	return snap
+}
+
+// ServeJSON returns the status summary. Responds 503 when any component is
+// down so uptime monitors can watch this one URL.
+func (h *StatusHandler) ServeJSON(w http.ResponseWriter, r *http.Request) {
+	snap := h.snapshot(r.Context())
+	w.Header().Set("Cache-Control", "no-cache")
+	code := http.StatusOK
+	if snap.Status == statusDown {
+		code = http.StatusServiceUnavailable
+	}
+	WriteJSON(w, code, snap)
+}
+
+// ServeHTML renders the status summary as a minimal standalone page.
+func (h *StatusHandler) ServeHTML(w http.ResponseWriter, r *http.Request) {
+	snap := h.snapshot(r.Context())
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	if snap.Status == statusDown {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	statusPage.Execute(w, statusPageData{StatusResponse: snap, Days: statusPageDays(snap)})
+}
+
+type statusPageData struct {
+	*StatusResponse
+	Days []string // UTC dates, oldest first, for the history bars
+}
+
+// statusPageDays lists the 90 days shown as bars, oldest first.
+func statusPageDays(snap *StatusResponse) []string {
+	start := statusHistoryStart(snap.UpdatedAt)
+	days := make([]string, statusHistoryDays)
+	for i := range days {
+		days[i] = start.AddDate(0, 0, i).Format("2006-01-02")
+	}
+	return days
+}
+
+var statusPage = template.Must(template.New("status").Funcs(template.FuncMap{
+	"day": func(c StatusComponent, date string) *StatusDayData {
+		i := sort.Search(len(c.History), func(i int) bool { return c.History[i].Date >= date })
+		if i < len(c.History) && c.History[i].Date == date {
+			return &c.History[i]
+		}
+		return nil
+	},
+	"deref": func(f *float64) float64 { return *f },
+	"barClass": func(d *StatusDayData) string {
+		switch {
+		case d == nil:
+			return "none"
+		case d.Down == 0 && d.Degraded == 0:
+			return statusOperational
+		case d.Uptime >= 99:
+			return statusDegraded
+		default:
+			return statusDown
+		}
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="60">
+<title>tr-engine status</title>
+<style>
+body { font-family: system-ui, sans-serif; background: #111; color: #ddd; max-width: 760px; margin: 2em auto; padding: 0 1em; }
+h1 { font-size: 1.3em; }
+.banner { padding: .8em 1em; border-radius: 6px; margin-bottom: 1.5em; font-weight: 600; }
+.component { margin-bottom: 1.2em; }
+.row { display: flex; justify-content: space-between; }
+.detail { color: #999; font-size: .9em; }
+.bars { display: flex; gap: 1px; height: 24px; margin-top: .3em; }
+.bars span { flex: 1; border-radius: 1px; }
+.operational { background: #2e7d32; } .degraded { background: #f9a825; } .down { background: #c62828; } .none { background: #333; }
+.banner.operational, .banner.degraded, .banner.down { color: #fff; }
+.st-operational { color: #66bb6a; } .st-degraded { color: #fbc02d; } .st-down { color: #ef5350; }
+footer { color: #777; font-size: .8em; }
+</style>
+</head>
+<body>
+<h1>tr-engine status</h1>
+<div class="banner {{.Status}}">{{if eq .Status "operational"}}All systems operational{{else if eq .Status "degraded"}}Degraded performance{{else}}Service disruption{{end}}</div>
+{{range $c := .Components}}<div class="component">
+<div class="row"><strong>{{$c.Name}}</strong><span class="st-{{$c.Status}}">{{$c.Status}}</span></div>
+<div class="row detail"><span>{{$c.Detail}}</span><span>{{with $c.Uptime90d}}{{printf "%.2f" (deref .)}}% uptime, 90 days{{end}}</span></div>
+<div class="bars">{{range $.Days}}{{$d := day $c .}}<span class="{{barClass $d}}" title="{{.}}{{with $d}}: {{printf "%.2f" .Uptime}}%{{end}}"></span>{{end}}</div>
+</div>
+{{end}}<footer>Updated {{.UpdatedAt.UTC.Format "2006-01-02 15:04"}} UTC</footer>
+</body>
+</html>
+`))
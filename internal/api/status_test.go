@@ -0,0 +1,107 @@
+package api
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+func TestIngestState(t *testing.T) {
+	tests := []struct {
+		connected bool
+		ws        *WatcherStatusData
+		quiet     time.Duration
+		want      string
+	}{
+		{true, nil, time.Minute, statusOperational},
+		{true, nil, 7 * time.Minute, statusDegraded},
+		{false, nil, time.Hour, statusOperational}, // reported by the mqtt component instead
+		{false, &WatcherStatusData{Status: "watching"}, time.Hour, statusOperational},
+		{true, &WatcherStatusData{Status: "stopped"}, 0, statusDown},
+	}
+	for i, tt := range tests {
+		if got := ingestState(tt.connected, tt.ws, tt.quiet); got.status != tt.want {
+			t.Errorf("%d: status = %q, want %q", i, got.status, tt.want)
+		}
+	}
+}
+
+func TestTrunkRecordersState(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	fresh := TRInstanceStatusData{InstanceID: "a", Status: "connected", LastSeen: now.Add(-10 * time.Second)}
+	stale := TRInstanceStatusData{InstanceID: "b", Status: "connected", LastSeen: now.Add(-time.Hour)}
+	offline := TRInstanceStatusData{InstanceID: "c", Status: "offline", LastSeen: now}
+
+	if s := trunkRecordersState([]TRInstanceStatusData{fresh}, now); s.status != statusOperational {
+		t.Errorf("one fresh: %+v", s)
+	}
+	if s := trunkRecordersState([]TRInstanceStatusData{fresh, stale, offline}, now); s.status != statusDegraded || s.detail != "2 of 3 instances not reporting" {
+		t.Errorf("mixed: %+v", s)
+	}
+	if s := trunkRecordersState([]TRInstanceStatusData{stale, offline}, now); s.status != statusDown {
+		t.Errorf("none: %+v", s)
+	}
+}
+
+func TestBuildStatus(t *testing.T) {
+	now := time.Date(2026, 10, 2, 12, 0, 0, 0, time.UTC)
+	day1 := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	day2 := time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC)
+	states := []componentState{
+		{name: "database", status: statusOperational},
+		{name: "mqtt", status: statusDown, detail: "disconnected"},
+		{name: "storage", status: statusDegraded},
+	}
+	days := []database.StatusDay{
+		{Day: day1, Component: "database", Operational: 1440},
+		{Day: day1, Component: "mqtt", Operational: 1380, Down: 60},
+		{Day: day2, Component: "mqtt", Operational: 700, Degraded: 20},
+	}
+	resp := buildStatus(states, days, now)
+
+	if resp.Status != statusDown {
+		t.Errorf("overall = %q, want down", resp.Status)
+	}
+	if len(resp.Components) != 3 {
+		t.Fatalf("components = %d", len(resp.Components))
+	}
+	db, mq, st := resp.Components[0], resp.Components[1], resp.Components[2]
+	if db.Uptime90d == nil || *db.Uptime90d != 100 {
+		t.Errorf("database uptime = %v", db.Uptime90d)
+	}
+	// (1380 + 700 + 20) / 2160
+	if mq.Uptime90d == nil || *mq.Uptime90d != 97.22 {
+		t.Errorf("mqtt uptime = %v", mq.Uptime90d)
+	}
+	if len(mq.History) != 2 || mq.History[0].Date != "2026-10-01" || mq.History[0].Uptime != 95.83 || mq.History[1].Uptime != 100 {
+		t.Errorf("mqtt history = %+v", mq.History)
+	}
+	if st.Uptime90d != nil || st.History == nil {
+		t.Errorf("storage without history: %+v", st)
+	}
+
+	var buf bytes.Buffer
+	if err := statusPage.Execute(&buf, statusPageData{StatusResponse: resp, Days: statusPageDays(resp)}); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	if !strings.Contains(page, "Service disruption") || !strings.Contains(page, "97.22% uptime") {
+		t.Errorf("page missing summary:\n%s", page)
+	}
+	if n := strings.Count(page, `title="2026-10-01: 95.83%"`); n != 1 {
+		t.Errorf("day bar count = %d", n)
+	}
+}
+
+func TestUptimePercent(t *testing.T) {
+	if got := uptimePercent(1439, 1440); got != 99.93 {
+		t.Errorf("uptimePercent = %v", got)
+	}
+	// Never shows 100% with any downtime
+	if got := uptimePercent(999999, 1000000); got >= 100 {
+		t.Errorf("uptimePercent = %v", got)
+	}
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// Opus bitrate bounds (bps) for transcode storage policies.
+const (
+	minTranscodeBitrate = 6000
+	maxTranscodeBitrate = 128000
+)
+
+// StoragePoliciesHandler manages how each talkgroup's call audio is stored:
+// full (default), transcode (low-bitrate Opus) or metadata (no audio).
+type StoragePoliciesHandler struct {
+	db       *database.DB
+	onChange func(ctx context.Context) error // reloads the ingest policy cache; may be nil
+}
+
+func NewStoragePoliciesHandler(db *database.DB, onChange func(context.Context) error) *StoragePoliciesHandler {
+	return &StoragePoliciesHandler{db: db, onChange: onChange}
+}
+
+// changed tells ingest about a policy change. The change is already
+// committed, so a failed reload is only logged; it is picked up on restart.
+func (h *StoragePoliciesHandler) changed(r *http.Request) {
+	if h.onChange == nil {
+		return
+	}
+	if err := h.onChange(r.Context()); err != nil {
+		hlog.FromRequest(r).Warn().Err(err).Msg("failed to reload storage policies")
+	}
+}
+
+// talkgroupTarget resolves the {id} path parameter ("system_id:tgid" or a
+// plain tgid that exists in one system) to a known talkgroup.
+func talkgroupTarget(db *database.DB, w http.ResponseWriter, r *http.Request) (systemID, tgid int, ok bool) {
+	cid, err := ParseCompositeID(r, "id")
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return 0, 0, false
+	}
+	matches, err := db.FindTalkgroupSystems(r.Context(), cid.EntityID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to look up talkgroup")
+		return 0, 0, false
+	}
+	if cid.IsPlain {
+		if len(matches) > 1 {
+			WriteAmbiguous(w, cid.EntityID, matches)
+			return 0, 0, false
+		}
+		if len(matches) == 1 {
+			return matches[0].SystemID, cid.EntityID, true
+		}
+	} else {
+		for _, m := range matches {
+			if m.SystemID == cid.SystemID {
+				return cid.SystemID, cid.EntityID, true
+			}
+		}
+	}
+	WriteError(w, http.StatusNotFound, "talkgroup not found")
+	return 0, 0, false
+}
+
+// ListStoragePolicies returns every talkgroup with a storage policy.
+func (h *StoragePoliciesHandler) ListStoragePolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.db.ListStoragePolicies(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list storage policies")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"default":  database.StoragePolicyFull,
+		"policies": policies,
+		"total":    len(policies),
+	})
+}
+
+// GetStoragePolicy returns a talkgroup's storage policy (full when none is
+// set).
+func (h *StoragePoliciesHandler) GetStoragePolicy(w http.ResponseWriter, r *http.Request) {
+	systemID, tgid, ok := talkgroupTarget(h.db, w, r)
+	if !ok {
+		return
+	}
+	p, err := h.db.GetStoragePolicy(r.Context(), systemID, tgid)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get storage policy")
+		return
+	}
+	if p == nil {
+		p = &database.StoragePolicy{SystemID: systemID, Tgid: tgid, Policy: database.StoragePolicyFull}
+	}
+	WriteJSON(w, http.StatusOK, p)
+}
+
+// PutStoragePolicy sets a talkgroup's storage policy.
+// Body: {"policy": "full|transcode|metadata", "bitrate": 16000, "note": "..."}.
+// bitrate (bps) applies to transcode only and defaults to 16000. Applies to
+// audio ingested from now on; stored audio is not rewritten.
+func (h *StoragePoliciesHandler) PutStoragePolicy(w http.ResponseWriter, r *http.Request) {
+	systemID, tgid, ok := talkgroupTarget(h.db, w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Policy  string `json:"policy"`
+		Bitrate int    `json:"bitrate"`
+		Note    string `json:"note"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	if !database.ValidStoragePolicy(req.Policy) {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter,
+			"policy must be full, transcode, or metadata")
+		return
+	}
+	if req.Bitrate != 0 {
+		if req.Policy != database.StoragePolicyTranscode {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "bitrate requires policy transcode")
+			return
+		}
+		if req.Bitrate < minTranscodeBitrate || req.Bitrate > maxTranscodeBitrate {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter,
+				"bitrate must be between 6000 and 128000")
+			return
+		}
+	}
+
+	p, err := h.db.UpsertStoragePolicy(r.Context(), systemID, tgid, req.Policy, req.Bitrate, req.Note)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to save storage policy")
+		return
+	}
+	h.changed(r)
+	WriteJSON(w, http.StatusOK, p)
+}
+
+// DeleteStoragePolicy removes a talkgroup's storage policy, reverting it to
+// full.
+func (h *StoragePoliciesHandler) DeleteStoragePolicy(w http.ResponseWriter, r *http.Request) {
+	systemID, tgid, ok := talkgroupTarget(h.db, w, r)
+	if !ok {
+		return
+	}
+	found, err := h.db.DeleteStoragePolicy(r.Context(), systemID, tgid)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to delete storage policy")
+		return
+	}
+	if !found {
+		WriteError(w, http.StatusNotFound, "storage policy not found")
+		return
+	}
+	h.changed(r)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *StoragePoliciesHandler) Routes(r chi.Router) {
+	r.Get("/talkgroups/storage-policies", h.ListStoragePolicies)
+	r.Get("/talkgroups/{id}/storage-policy", h.GetStoragePolicy)
+	r.Put("/talkgroups/{id}/storage-policy", h.PutStoragePolicy)
+	r.Delete("/talkgroups/{id}/storage-policy", h.DeleteStoragePolicy)
+}
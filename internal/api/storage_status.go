@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/storage"
+)
+
+// StorageStatusHandler reports the audio store and its async upload queue.
+type StorageStatusHandler struct {
+	db       *database.DB
+	store    storage.AudioStore
+	uploader *storage.AsyncUploader // nil unless S3_UPLOAD_MODE=async with a local cache
+}
+
+func NewStorageStatusHandler(db *database.DB, store storage.AudioStore, uploader *storage.AsyncUploader) *StorageStatusHandler {
+	return &StorageStatusHandler{db: db, store: store, uploader: uploader}
+}
+
+func (h *StorageStatusHandler) available(w http.ResponseWriter) bool {
+	if h.uploader == nil {
+		WriteError(w, http.StatusServiceUnavailable, "async uploads not enabled (set an object store backend with S3_LOCAL_CACHE and S3_UPLOAD_MODE=async)")
+		return false
+	}
+	return true
+}
+
+// GetStorageStatus reports the storage backend and, in async upload mode,
+// the upload queue: depth, retries, failures and progress since startup.
+func (h *StorageStatusHandler) GetStorageStatus(w http.ResponseWriter, r *http.Request) {
+	resp := map[string]any{
+		"type":    h.store.Type(),
+		"backend": storage.Backend(h.store),
+		"uploads": nil,
+	}
+	if h.uploader != nil {
+		status, err := h.uploader.Status(r.Context())
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to get upload queue status")
+			return
+		}
+		resp["uploads"] = status
+	}
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+// ListUploadFailures returns uploads that gave up, most recent attempt first.
+func (h *StorageStatusHandler) ListUploadFailures(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	limit := 100
+	if v, ok := QueryInt(r, "limit"); ok {
+		if v < 1 || v > 1000 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "limit must be between 1 and 1000")
+			return
+		}
+		limit = v
+	}
+	failures, total, err := h.db.ListFailedS3Uploads(r.Context(), limit)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list upload failures")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"failures": failures,
+		"total":    total,
+	})
+}
+
+// RetryUploadFailures returns every failed upload to the queue.
+func (h *StorageStatusHandler) RetryUploadFailures(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	n, err := h.db.RetryFailedS3Uploads(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to retry upload failures")
+		return
+	}
+	h.uploader.Wake()
+	WriteJSON(w, http.StatusOK, map[string]any{"reset": n})
+}
+
+func (h *StorageStatusHandler) Routes(r chi.Router) {
+	r.Get("/admin/storage", h.GetStorageStatus)
+	r.Get("/admin/storage/upload-failures", h.ListUploadFailures)
+	r.Post("/admin/storage/upload-failures/retry", h.RetryUploadFailures)
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/transcribe"
+)
+
+// maxSTTPromptLen bounds an override template.
+const maxSTTPromptLen = 4096
+
+// STTPromptsHandler manages per-system and per-talkgroup STT prompt
+// overrides. Overrides are prompt templates rendered per call with the same
+// fields as WHISPER_PROMPT_TEMPLATE.
+type STTPromptsHandler struct {
+	db *database.DB
+}
+
+func NewSTTPromptsHandler(db *database.DB) *STTPromptsHandler {
+	return &STTPromptsHandler{db: db}
+}
+
+// ListSTTPrompts returns all prompt overrides.
+func (h *STTPromptsHandler) ListSTTPrompts(w http.ResponseWriter, r *http.Request) {
+	overrides, err := h.db.ListSTTPromptOverrides(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list STT prompts")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"default_template": transcribe.DefaultPromptTemplate,
+		"overrides":        overrides,
+		"total":            len(overrides),
+	})
+}
+
+// PutSTTPrompt sets the prompt override for a system (tgid 0) or talkgroup.
+// Body: {"prompt": "..."}.
+func (h *STTPromptsHandler) PutSTTPrompt(w http.ResponseWriter, r *http.Request) {
+	systemID, tgid, ok := policyTarget(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Prompt string `json:"prompt"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	req.Prompt = strings.TrimSpace(req.Prompt)
+	if req.Prompt == "" || len(req.Prompt) > maxSTTPromptLen {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "prompt must be 1-4096 characters")
+		return
+	}
+	if _, err := transcribe.ParsePromptTemplate(req.Prompt); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "invalid prompt template: "+err.Error())
+		return
+	}
+
+	o, err := h.db.UpsertSTTPromptOverride(r.Context(), systemID, tgid, req.Prompt)
+	if err != nil {
+		if err.Error() == "system not found" {
+			WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to save STT prompt")
+		return
+	}
+	WriteJSON(w, http.StatusOK, o)
+}
+
+// DeleteSTTPrompt removes an override; a talkgroup falls back to its
+// system's override, a system to the configured prompt.
+func (h *STTPromptsHandler) DeleteSTTPrompt(w http.ResponseWriter, r *http.Request) {
+	systemID, tgid, ok := policyTarget(w, r)
+	if !ok {
+		return
+	}
+	found, err := h.db.DeleteSTTPromptOverride(r.Context(), systemID, tgid)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to delete STT prompt")
+		return
+	}
+	if !found {
+		WriteError(w, http.StatusNotFound, "STT prompt not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *STTPromptsHandler) Routes(r chi.Router) {
+	r.Get("/admin/stt-prompts", h.ListSTTPrompts)
+	r.Put("/admin/stt-prompts/{system_id}/{tgid}", h.PutSTTPrompt)
+	r.Delete("/admin/stt-prompts/{system_id}/{tgid}", h.DeleteSTTPrompt)
+}
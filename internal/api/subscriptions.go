@@ -0,0 +1,200 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// Saved subscription profiles: named EventFilters stored server-side so
+// clients can open /events/stream?profile=name instead of re-sending filter
+// query strings on every reconnect. Profiles are private to the token that
+// created them, and any valid token (including the read-only AUTH_TOKEN)
+// may manage its own — see WriteAuth.
+
+// ProfileStore loads and manages subscription profiles.
+type ProfileStore interface {
+	ListSubscriptionProfiles(ctx context.Context, owner string) ([]database.SubscriptionProfile, error)
+	GetSubscriptionProfiles(ctx context.Context, owner string, names []string) ([]database.SubscriptionProfile, error)
+	CreateSubscriptionProfile(ctx context.Context, owner string, p *database.SubscriptionProfile) error
+	UpdateSubscriptionProfile(ctx context.Context, owner, name string, description *string, filter json.RawMessage) (*database.SubscriptionProfile, error)
+	DeleteSubscriptionProfile(ctx context.Context, owner, name string) error
+}
+
+type SubscriptionsHandler struct {
+	db          ProfileStore
+	authEnabled bool
+}
+
+func NewSubscriptionsHandler(db ProfileStore, authEnabled bool) *SubscriptionsHandler {
+	return &SubscriptionsHandler{db: db, authEnabled: authEnabled}
+}
+
+// profileOwner identifies whose profiles a request sees: the signed-in user,
+// a hash of its bearer token, or "" (shared) when auth is off.
+func profileOwner(r *http.Request, authEnabled bool) string {
+	if user := userFrom(r); user != nil {
+		return "user-" + strconv.Itoa(user.ID)
+	}
+	if !authEnabled {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(extractBearerToken(r)))
+	return hex.EncodeToString(sum[:16])
+}
+
+var profileNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+type profileRequest struct {
+	Name        string       `json:"name"`
+	Description *string      `json:"description"`
+	Filter      *EventFilter `json:"filter"`
+}
+
+// validateFilter checks a profile's filter. Returns "" if valid.
+func validateFilter(f *EventFilter) string {
+	for _, t := range f.Types {
+		if strings.TrimSpace(t) == "" {
+			return "filter.types must not contain empty values"
+		}
+	}
+	if err := f.CompileExpr(); err != nil {
+		return "invalid filter.expr " + err.Error()
+	}
+	return ""
+}
+
+// ListProfiles returns the caller's subscription profiles.
+func (h *SubscriptionsHandler) ListProfiles(w http.ResponseWriter, r *http.Request) {
+	profiles, err := h.db.ListSubscriptionProfiles(r.Context(), profileOwner(r, h.authEnabled))
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list subscription profiles")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"profiles": profiles,
+		"total":    len(profiles),
+	})
+}
+
+// GetProfile returns one of the caller's profiles.
+func (h *SubscriptionsHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	profiles, err := h.db.GetSubscriptionProfiles(r.Context(), profileOwner(r, h.authEnabled), []string{chi.URLParam(r, "name")})
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "subscription profile not found") {
+			WriteError(w, http.StatusNotFound, "subscription profile not found")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to get subscription profile")
+		return
+	}
+	WriteJSON(w, http.StatusOK, profiles[0])
+}
+
+// CreateProfile saves a new profile for the caller.
+func (h *SubscriptionsHandler) CreateProfile(w http.ResponseWriter, r *http.Request) {
+	var req profileRequest
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	if !profileNameRe.MatchString(req.Name) {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody,
+			"name must be 1-64 lowercase letters, digits, '-' or '_'")
+		return
+	}
+	if req.Filter == nil {
+		req.Filter = &EventFilter{}
+	}
+	if msg := validateFilter(req.Filter); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, msg)
+		return
+	}
+	filter, _ := json.Marshal(req.Filter)
+	p := &database.SubscriptionProfile{Name: req.Name, Filter: filter}
+	if req.Description != nil {
+		p.Description = *req.Description
+	}
+	if err := h.db.CreateSubscriptionProfile(r.Context(), profileOwner(r, h.authEnabled), p); err != nil {
+		if err.Error() == "subscription profile already exists" {
+			WriteError(w, http.StatusConflict, "subscription profile already exists")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to create subscription profile")
+		return
+	}
+	WriteJSON(w, http.StatusCreated, p)
+}
+
+// UpdateProfile changes a profile's description and/or replaces its filter.
+func (h *SubscriptionsHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	var req profileRequest
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	var filter json.RawMessage
+	if req.Filter != nil {
+		if msg := validateFilter(req.Filter); msg != "" {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, msg)
+			return
+		}
+		filter, _ = json.Marshal(req.Filter)
+	}
+	p, err := h.db.UpdateSubscriptionProfile(r.Context(), profileOwner(r, h.authEnabled),
+		chi.URLParam(r, "name"), req.Description, filter)
+	if err != nil {
+		if err.Error() == "subscription profile not found" {
+			WriteError(w, http.StatusNotFound, "subscription profile not found")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to update subscription profile")
+		return
+	}
+	WriteJSON(w, http.StatusOK, p)
+}
+
+// DeleteProfile removes one of the caller's profiles.
+func (h *SubscriptionsHandler) DeleteProfile(w http.ResponseWriter, r *http.Request) {
+	if err := h.db.DeleteSubscriptionProfile(r.Context(), profileOwner(r, h.authEnabled), chi.URLParam(r, "name")); err != nil {
+		if err.Error() == "subscription profile not found" {
+			WriteError(w, http.StatusNotFound, "subscription profile not found")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to delete subscription profile")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resolveProfiles loads the named profiles as filters for the caller.
+func resolveProfiles(ctx context.Context, db ProfileStore, owner string, names []string) ([]EventFilter, error) {
+	profiles, err := db.GetSubscriptionProfiles(ctx, owner, names)
+	if err != nil {
+		return nil, err
+	}
+	filters := make([]EventFilter, len(profiles))
+	for i, p := range profiles {
+		if err := json.Unmarshal(p.Filter, &filters[i]); err != nil {
+			return nil, err
+		}
+	}
+	return filters, nil
+}
+
+func (h *SubscriptionsHandler) Routes(r chi.Router) {
+	r.Get("/subscriptions", h.ListProfiles)
+	r.Post("/subscriptions", h.CreateProfile)
+	r.Get("/subscriptions/{name}", h.GetProfile)
+	r.Patch("/subscriptions/{name}", h.UpdateProfile)
+	r.Delete("/subscriptions/{name}", h.DeleteProfile)
+}
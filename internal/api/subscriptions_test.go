@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// mockProfileStore implements ProfileStore in memory, keyed by owner.
+type mockProfileStore struct {
+	profiles map[string]map[string]database.SubscriptionProfile
+}
+
+func newMockProfileStore() *mockProfileStore {
+	return &mockProfileStore{profiles: map[string]map[string]database.SubscriptionProfile{}}
+}
+
+func (m *mockProfileStore) ListSubscriptionProfiles(_ context.Context, owner string) ([]database.SubscriptionProfile, error) {
+	list := []database.SubscriptionProfile{}
+	for _, p := range m.profiles[owner] {
+		list = append(list, p)
+	}
+	return list, nil
+}
+
+func (m *mockProfileStore) GetSubscriptionProfiles(_ context.Context, owner string, names []string) ([]database.SubscriptionProfile, error) {
+	var list []database.SubscriptionProfile
+	for _, n := range names {
+		p, ok := m.profiles[owner][n]
+		if !ok {
+			return nil, fmt.Errorf("subscription profile not found: %s", n)
+		}
+		list = append(list, p)
+	}
+	return list, nil
+}
+
+func (m *mockProfileStore) CreateSubscriptionProfile(_ context.Context, owner string, p *database.SubscriptionProfile) error {
+	if m.profiles[owner] == nil {
+		m.profiles[owner] = map[string]database.SubscriptionProfile{}
+	}
+	if _, ok := m.profiles[owner][p.Name]; ok {
+		return fmt.Errorf("subscription profile already exists")
+	}
+	m.profiles[owner][p.Name] = *p
+	return nil
+}
+
+func (m *mockProfileStore) UpdateSubscriptionProfile(_ context.Context, owner, name string, description *string, filter json.RawMessage) (*database.SubscriptionProfile, error) {
+	p, ok := m.profiles[owner][name]
+	if !ok {
+		return nil, fmt.Errorf("subscription profile not found")
+	}
+	if description != nil {
+		p.Description = *description
+	}
+	if filter != nil {
+		p.Filter = filter
+	}
+	m.profiles[owner][name] = p
+	return &p, nil
+}
+
+func (m *mockProfileStore) DeleteSubscriptionProfile(_ context.Context, owner, name string) error {
+	if _, ok := m.profiles[owner][name]; !ok {
+		return fmt.Errorf("subscription profile not found")
+	}
+	delete(m.profiles[owner], name)
+	return nil
+}
+
+func TestSubscriptionProfiles(t *testing.T) {
+	store := newMockProfileStore()
+	r := chi.NewRouter()
+	NewSubscriptionsHandler(store, true).Routes(r)
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("POST", "/subscriptions", "alice",
+		`{"name":"fire","description":"Fire dispatch","filter":{"systems":[1],"tgids":[9131],"types":["call_end"]}}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body)
+	}
+	if rec := do("POST", "/subscriptions", "alice", `{"name":"fire"}`); rec.Code != http.StatusConflict {
+		t.Errorf("duplicate create: %d, want 409", rec.Code)
+	}
+	if rec := do("POST", "/subscriptions", "alice", `{"name":"Bad Name"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad name: %d, want 400", rec.Code)
+	}
+
+	// Another token doesn't see alice's profile, and can reuse the name
+	if rec := do("GET", "/subscriptions/fire", "bob", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("other token get: %d, want 404", rec.Code)
+	}
+	if rec := do("POST", "/subscriptions", "bob", `{"name":"fire"}`); rec.Code != http.StatusCreated {
+		t.Errorf("other token create: %d, want 201", rec.Code)
+	}
+
+	rec = do("PATCH", "/subscriptions/fire", "alice", `{"filter":{"tgids":[9131,9132]}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update: %d %s", rec.Code, rec.Body)
+	}
+	filters, err := resolveProfiles(context.Background(), store, profileOwner(httptest.NewRequest("GET", "/?token=alice", nil), true), []string{"fire"})
+	if err != nil || len(filters) != 1 || len(filters[0].Tgids) != 2 || len(filters[0].Systems) != 0 {
+		t.Errorf("resolved filter = %+v, %v", filters, err)
+	}
+
+	if rec := do("DELETE", "/subscriptions/fire", "alice", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("delete: %d, want 204", rec.Code)
+	}
+	if rec := do("DELETE", "/subscriptions/fire", "alice", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("second delete: %d, want 404", rec.Code)
+	}
+}
+
+func TestStreamEventsUnknownProfile(t *testing.T) {
+	h := NewEventsHandler(&mockLiveData{}, newMockProfileStore(), false)
+	rec := httptest.NewRecorder()
+	h.StreamEvents(rec, httptest.NewRequest("GET", "/events/stream?profile=nope", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
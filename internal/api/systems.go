@@ -1,18 +1,23 @@
 package api
 
 import (
+	"context"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/hlog"
 	"github.com/snarg/tr-engine/internal/database"
 )
 
 type SystemsHandler struct {
-	db *database.DB
+	db             *database.DB
+	cache          *ResponseCache
+	onPolicyChange func(ctx context.Context) error // reloads the ingest policy cache; may be nil
 }
 
-func NewSystemsHandler(db *database.DB) *SystemsHandler {
-	return &SystemsHandler{db: db}
+func NewSystemsHandler(db *database.DB, cache *ResponseCache, onPolicyChange func(context.Context) error) *SystemsHandler {
+	return &SystemsHandler{db: db, cache: cache, onPolicyChange: onPolicyChange}
 }
 
 // ListSystems returns all active systems with embedded sites.
@@ -43,7 +48,10 @@ func (h *SystemsHandler) GetSystem(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, http.StatusOK, system)
 }
 
-// UpdateSystem patches system metadata.
+// UpdateSystem patches system metadata, display metadata (color, icon,
+// short_label, display_order) and its ingest policy (full, transcript or
+// metadata). A policy change applies to calls ingested from now on; stored
+// audio is not removed.
 func (h *SystemsHandler) UpdateSystem(w http.ResponseWriter, r *http.Request) {
 	id, err := PathInt(r, "id")
 	if err != nil {
@@ -52,19 +60,58 @@ func (h *SystemsHandler) UpdateSystem(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var patch struct {
-		Name  *string `json:"name"`
-		Sysid *string `json:"sysid"`
-		Wacn  *string `json:"wacn"`
+		Name         *string `json:"name"`
+		Sysid        *string `json:"sysid"`
+		Wacn         *string `json:"wacn"`
+		IngestPolicy *string `json:"ingest_policy"`
+		displayInput
 	}
 	if err := DecodeJSON(r, &patch); err != nil {
 		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
 		return
 	}
+	if patch.IngestPolicy != nil && !database.ValidIngestPolicy(*patch.IngestPolicy) {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter,
+			"ingest_policy must be full, transcript, or metadata")
+		return
+	}
+	display, msg := patch.validate()
+	if msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, msg)
+		return
+	}
 
 	if err := h.db.UpdateSystemFields(r.Context(), id, patch.Name, patch.Sysid, patch.Wacn); err != nil {
 		WriteError(w, http.StatusInternalServerError, "failed to update system")
 		return
 	}
+	if !display.IsZero() {
+		if err := h.db.UpdateSystemDisplay(r.Context(), id, display); err != nil {
+			if err.Error() == "system not found" {
+				WriteError(w, http.StatusNotFound, "system not found")
+			} else {
+				WriteError(w, http.StatusInternalServerError, "failed to update system")
+			}
+			return
+		}
+	}
+	if patch.IngestPolicy != nil {
+		if err := h.db.SetSystemIngestPolicy(r.Context(), id, *patch.IngestPolicy); err != nil {
+			if err.Error() == "system not found" {
+				WriteError(w, http.StatusNotFound, "system not found")
+			} else {
+				WriteError(w, http.StatusInternalServerError, "failed to update system")
+			}
+			return
+		}
+		// Already committed, so a failed reload is only logged; it is
+		// picked up on restart.
+		if h.onPolicyChange != nil {
+			if err := h.onPolicyChange(r.Context()); err != nil {
+				hlog.FromRequest(r).Warn().Err(err).Msg("failed to reload ingest policies")
+			}
+		}
+	}
 
 	system, err := h.db.GetSystemByID(r.Context(), id)
 	if err != nil {
@@ -89,7 +136,8 @@ func (h *SystemsHandler) GetSite(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, http.StatusOK, site)
 }
 
-// UpdateSite patches site metadata.
+// UpdateSite patches site metadata and display metadata (color, icon,
+// short_label, display_order).
 func (h *SystemsHandler) UpdateSite(w http.ResponseWriter, r *http.Request) {
 	id, err := PathInt(r, "id")
 	if err != nil {
@@ -103,11 +151,17 @@ func (h *SystemsHandler) UpdateSite(w http.ResponseWriter, r *http.Request) {
 		Nac        *string `json:"nac"`
 		Rfss       *int    `json:"rfss"`
 		P25SiteID  *int    `json:"p25_site_id"`
+		displayInput
 	}
 	if err := DecodeJSON(r, &patch); err != nil {
 		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
 		return
 	}
+	display, msg := patch.validate()
+	if msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, msg)
+		return
+	}
 
 	if err := h.db.UpdateSiteFields(r.Context(), id, patch.ShortName, patch.InstanceID, patch.Nac, patch.Rfss, patch.P25SiteID); err != nil {
 		if err.Error() == "site not found" {
@@ -117,6 +171,12 @@ func (h *SystemsHandler) UpdateSite(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, http.StatusInternalServerError, "failed to update site")
 		return
 	}
+	if !display.IsZero() {
+		if err := h.db.UpdateSiteDisplay(r.Context(), id, display); err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to update site")
+			return
+		}
+	}
 
 	site, err := h.db.GetSiteByID(r.Context(), id)
 	if err != nil {
@@ -141,10 +201,11 @@ func (h *SystemsHandler) ListP25Systems(w http.ResponseWriter, r *http.Request)
 
 // Routes registers system/site routes on the given router.
 func (h *SystemsHandler) Routes(r chi.Router) {
-	r.Get("/systems", h.ListSystems)
-	r.Get("/systems/{id}", h.GetSystem)
-	r.Patch("/systems/{id}", h.UpdateSystem)
+	systemsTag := staticTags(CacheTagSystems)
+	r.Get("/systems", h.cache.Cached("systems", time.Minute, systemsTag, h.ListSystems))
+	r.Get("/systems/{id}", h.cache.Cached("system", time.Minute, systemsTag, h.GetSystem))
+	r.Patch("/systems/{id}", h.cache.Invalidating(systemsTag, h.UpdateSystem))
 	r.Get("/sites/{id}", h.GetSite)
-	r.Patch("/sites/{id}", h.UpdateSite)
-	r.Get("/p25-systems", h.ListP25Systems)
+	r.Patch("/sites/{id}", h.cache.Invalidating(systemsTag, h.UpdateSite))
+	r.Get("/p25-systems", h.cache.Cached("p25_systems", time.Minute, systemsTag, h.ListP25Systems))
 }
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const maxTalkgroupGroupNameLen = 100
+
+// groupName returns the {name} path parameter, unescaped so group names
+// containing "/" can be addressed.
+func groupName(r *http.Request) (string, bool) {
+	name, err := url.PathUnescape(chi.URLParam(r, "name"))
+	name = strings.TrimSpace(name)
+	return name, err == nil && name != "" && len(name) <= maxTalkgroupGroupNameLen
+}
+
+// ListTalkgroupGroups returns talkgroup groups (the talkgroup "group", TR's
+// CSV Category) with their talkgroup counts and display metadata, by
+// display_order then name. Filter: system_id (comma-separated).
+// GET /api/v1/talkgroup-groups
+func (h *TalkgroupsHandler) ListTalkgroupGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.db.ListTalkgroupGroups(r.Context(), QueryIntListAliased(r, "system_id", "systems"))
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list talkgroup groups")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"groups": groups,
+		"total":  len(groups),
+	})
+}
+
+// UpdateTalkgroupGroup patches a talkgroup group's display metadata (color,
+// icon, short_label, display_order). The group is matched by name without
+// regard to case and need not have talkgroups yet.
+// PATCH /api/v1/talkgroup-groups/{name}
+func (h *TalkgroupsHandler) UpdateTalkgroupGroup(w http.ResponseWriter, r *http.Request) {
+	name, ok := groupName(r)
+	if !ok {
+		WriteError(w, http.StatusBadRequest, "invalid talkgroup group name")
+		return
+	}
+	var in displayInput
+	if err := DecodeJSON(r, &in); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	display, msg := in.validate()
+	if msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, msg)
+		return
+	}
+	group, err := h.db.UpdateTalkgroupGroupDisplay(r.Context(), name, display)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to update talkgroup group")
+		return
+	}
+	WriteJSON(w, http.StatusOK, group)
+}
+
+// DeleteTalkgroupGroup removes a talkgroup group's display metadata; its
+// talkgroups keep their group.
+// DELETE /api/v1/talkgroup-groups/{name}
+func (h *TalkgroupsHandler) DeleteTalkgroupGroup(w http.ResponseWriter, r *http.Request) {
+	name, ok := groupName(r)
+	if !ok {
+		WriteError(w, http.StatusBadRequest, "invalid talkgroup group name")
+		return
+	}
+	if err := h.db.DeleteTalkgroupGroupDisplay(r.Context(), name); err != nil {
+		if err.Error() == "talkgroup group not found" {
+			WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to delete talkgroup group")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// TalkgroupMetricsHandler manages the allowlist of talkgroups exported as
+// per-talkgroup Prometheus series on /metrics. Only listed talkgroups get
+// series, and the list is capped at METRICS_TALKGROUP_LIMIT, so label
+// cardinality stays bounded.
+type TalkgroupMetricsHandler struct {
+	db       *database.DB
+	limit    int                             // most talkgroups listed; <= 0 disables adding
+	onChange func(ctx context.Context) error // reloads the exporter's allowlist; may be nil
+}
+
+func NewTalkgroupMetricsHandler(db *database.DB, limit int, onChange func(context.Context) error) *TalkgroupMetricsHandler {
+	return &TalkgroupMetricsHandler{db: db, limit: limit, onChange: onChange}
+}
+
+// changed tells the exporter about an allowlist change. The change is
+// already committed, so a failed reload is only logged; it is picked up on
+// restart.
+func (h *TalkgroupMetricsHandler) changed(r *http.Request) {
+	if h.onChange == nil {
+		return
+	}
+	if err := h.onChange(r.Context()); err != nil {
+		hlog.FromRequest(r).Warn().Err(err).Msg("failed to reload metrics talkgroups")
+	}
+}
+
+// ListMetricsTalkgroups returns the talkgroups exported as metrics.
+func (h *TalkgroupMetricsHandler) ListMetricsTalkgroups(w http.ResponseWriter, r *http.Request) {
+	list, err := h.db.ListMetricsTalkgroups(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list metrics talkgroups")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"talkgroups": list,
+		"total":      len(list),
+		"limit":      h.limit,
+	})
+}
+
+// AddMetricsTalkgroup adds a talkgroup to the allowlist. Returns 201 when
+// added and 200 when it was already listed.
+func (h *TalkgroupMetricsHandler) AddMetricsTalkgroup(w http.ResponseWriter, r *http.Request) {
+	systemID, tgid, ok := talkgroupTarget(h.db, w, r)
+	if !ok {
+		return
+	}
+	list, err := h.db.ListMetricsTalkgroups(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list metrics talkgroups")
+		return
+	}
+	listed := false
+	for _, m := range list {
+		if m.SystemID == systemID && m.Tgid == tgid {
+			listed = true
+			break
+		}
+	}
+	if !listed && len(list) >= h.limit {
+		WriteErrorWithCode(w, http.StatusConflict, ErrInvalidParameter,
+			fmt.Sprintf("metrics talkgroup limit reached (%d); remove one or raise METRICS_TALKGROUP_LIMIT", h.limit))
+		return
+	}
+
+	added, err := h.db.AddMetricsTalkgroup(r.Context(), systemID, tgid)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to add metrics talkgroup")
+		return
+	}
+	status := http.StatusOK
+	if added {
+		status = http.StatusCreated
+		h.changed(r)
+	}
+	WriteJSON(w, status, map[string]any{"system_id": systemID, "tgid": tgid})
+}
+
+// DeleteMetricsTalkgroup removes a talkgroup from the allowlist. Its series
+// disappear from /metrics.
+func (h *TalkgroupMetricsHandler) DeleteMetricsTalkgroup(w http.ResponseWriter, r *http.Request) {
+	systemID, tgid, ok := talkgroupTarget(h.db, w, r)
+	if !ok {
+		return
+	}
+	found, err := h.db.DeleteMetricsTalkgroup(r.Context(), systemID, tgid)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to remove metrics talkgroup")
+		return
+	}
+	if !found {
+		WriteError(w, http.StatusNotFound, "talkgroup is not exported as metrics")
+		return
+	}
+	h.changed(r)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *TalkgroupMetricsHandler) Routes(r chi.Router) {
+	r.Get("/admin/metrics/talkgroups", h.ListMetricsTalkgroups)
+	r.Put("/admin/metrics/talkgroups/{id}", h.AddMetricsTalkgroup)
+	r.Delete("/admin/metrics/talkgroups/{id}", h.DeleteMetricsTalkgroup)
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// parseRelationsQuery reads the time range (hours back from end_time, default
+// 7 days), co-activity window and per-list limit of a related talkgroups
+// request.
+func parseRelationsQuery(r *http.Request) (database.TalkgroupRelationsQuery, string) {
+	q := database.TalkgroupRelationsQuery{End: time.Now(), WindowMinutes: 5, Limit: 10}
+	if t, ok := QueryTime(r, "end_time"); ok {
+		q.End = t
+	}
+	hours := 168
+	if v, ok := QueryInt(r, "hours"); ok {
+		if v < 1 || v > 2160 {
+			return q, "hours must be between 1 and 2160"
+		}
+		hours = v
+	}
+	q.Start = q.End.Add(-time.Duration(hours) * time.Hour)
+	if v, ok := QueryInt(r, "window_minutes"); ok {
+		if v < 1 || v > 1440 {
+			return q, "window_minutes must be between 1 and 1440"
+		}
+		q.WindowMinutes = v
+	}
+	if v, ok := QueryInt(r, "limit"); ok {
+		if v < 1 || v > 100 {
+			return q, "limit must be between 1 and 100"
+		}
+		q.Limit = v
+	}
+	return q, ""
+}
+
+// GetRelatedTalkgroups cross-references a talkgroup with the others on its
+// system: talkgroups patched with it, talkgroups sharing its units, and
+// talkgroups active in the same time windows, each scored 0-1.
+// GET /api/v1/talkgroups/{id}/related
+func (h *TalkgroupsHandler) GetRelatedTalkgroups(w http.ResponseWriter, r *http.Request) {
+	cid, err := ParseCompositeID(r, "id")
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+	q, msg := parseRelationsQuery(r)
+	if msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, msg)
+		return
+	}
+
+	if cid.IsPlain {
+		matches, err := h.db.FindTalkgroupSystems(r.Context(), cid.EntityID)
+		if err != nil || len(matches) == 0 {
+			WriteError(w, http.StatusNotFound, "talkgroup not found")
+			return
+		}
+		if len(matches) > 1 {
+			WriteAmbiguous(w, cid.EntityID, matches)
+			return
+		}
+		cid.SystemID = matches[0].SystemID
+	}
+	q.SystemID = cid.SystemID
+	q.Tgid = cid.EntityID
+
+	rel, err := h.db.GetTalkgroupRelations(r.Context(), q)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get related talkgroups")
+		return
+	}
+	WriteJSON(w, http.StatusOK, rel)
+}
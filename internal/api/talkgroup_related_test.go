@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRelationsQuery(t *testing.T) {
+	q, msg := parseRelationsQuery(httptest.NewRequest("GET", "/api/v1/talkgroups/1:100/related", nil))
+	if msg != "" {
+		t.Fatal(msg)
+	}
+	if q.End.Sub(q.Start) != 168*time.Hour || q.WindowMinutes != 5 || q.Limit != 10 {
+		t.Errorf("defaults = %+v", q)
+	}
+
+	q, msg = parseRelationsQuery(httptest.NewRequest("GET",
+		"/api/v1/talkgroups/1:100/related?end_time=2026-03-01T00:00:00Z&hours=24&window_minutes=15&limit=25", nil))
+	if msg != "" {
+		t.Fatal(msg)
+	}
+	end := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	if !q.End.Equal(end) || !q.Start.Equal(end.Add(-24*time.Hour)) || q.WindowMinutes != 15 || q.Limit != 25 {
+		t.Errorf("query = %+v", q)
+	}
+
+	for _, bad := range []string{"hours=0", "hours=2161", "window_minutes=0", "limit=101"} {
+		if _, msg := parseRelationsQuery(httptest.NewRequest("GET", "/api/v1/talkgroups/1:100/related?"+bad, nil)); msg == "" {
+			t.Errorf("%s: expected error", bad)
+		}
+	}
+}
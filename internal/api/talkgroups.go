@@ -3,6 +3,7 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/rs/zerolog/hlog"
@@ -13,10 +14,22 @@ import (
 type TalkgroupsHandler struct {
 	db       *database.DB
 	csvPaths map[int]string // system_id → CSV file path for writeback
+	cache    *ResponseCache
+	onImport func(systemID int) // after a directory import; nil = no-op
 }
 
-func NewTalkgroupsHandler(db *database.DB, csvPaths map[int]string) *TalkgroupsHandler {
-	return &TalkgroupsHandler{db: db, csvPaths: csvPaths}
+func NewTalkgroupsHandler(db *database.DB, csvPaths map[int]string, cache *ResponseCache, onImport func(systemID int)) *TalkgroupsHandler {
+	return &TalkgroupsHandler{db: db, csvPaths: csvPaths, cache: cache, onImport: onImport}
+}
+
+// talkgroupCacheTags tags a single-talkgroup response with the list tag (so
+// bulk changes like directory imports clear it) and its own tgid tag.
+func talkgroupCacheTags(r *http.Request) []string {
+	tags := []string{CacheTagTalkgroups}
+	if cid, err := ParseCompositeID(r, "id"); err == nil {
+		tags = append(tags, CacheTagTalkgroup(cid.EntityID))
+	}
+	return tags
 }
 
 var talkgroupSortFields = map[string]string{
@@ -224,6 +237,7 @@ func (h *TalkgroupsHandler) ListTalkgroupCalls(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	filter.Omit = RequestFieldset(r).Omitted(database.OmittableCallFields)
 	calls, total, err := h.db.ListCalls(r.Context(), filter)
 	if err != nil {
 		WriteError(w, http.StatusInternalServerError, "failed to list calls")
@@ -406,6 +420,9 @@ func (h *TalkgroupsHandler) ImportTalkgroupDirectory(w http.ResponseWriter, r *h
 
 	// Enrich heard talkgroups from the newly imported directory data
 	enriched, _ := h.db.EnrichTalkgroupsFromDirectory(r.Context(), systemID, 0)
+	if h.onImport != nil {
+		h.onImport(systemID)
+	}
 
 	resp := map[string]any{
 		"imported":  imported,
@@ -426,12 +443,17 @@ func (h *TalkgroupsHandler) ImportTalkgroupDirectory(w http.ResponseWriter, r *h
 
 // Routes registers talkgroup routes on the given router.
 func (h *TalkgroupsHandler) Routes(r chi.Router) {
-	r.Get("/talkgroups", h.ListTalkgroups)
-	r.Get("/talkgroups/encryption-stats", h.GetEncryptionStats)
-	r.Get("/talkgroups/{id}", h.GetTalkgroup)
-	r.Patch("/talkgroups/{id}", h.UpdateTalkgroup)
+	tgsTag := staticTags(CacheTagTalkgroups)
+	r.Get("/talkgroups", h.cache.Cached("talkgroups", 30*time.Second, tgsTag, h.ListTalkgroups))
+	r.Get("/talkgroups/encryption-stats", h.cache.Cached("talkgroup_encryption_stats", time.Minute, tgsTag, h.GetEncryptionStats))
+	r.Get("/talkgroups/{id}", h.cache.Cached("talkgroup", 15*time.Second, talkgroupCacheTags, h.GetTalkgroup))
+	r.Patch("/talkgroups/{id}", h.cache.Invalidating(talkgroupCacheTags, h.UpdateTalkgroup))
 	r.Get("/talkgroups/{id}/calls", h.ListTalkgroupCalls)
 	r.Get("/talkgroups/{id}/units", h.ListTalkgroupUnits)
+	r.Get("/talkgroups/{id}/related", h.cache.Cached("talkgroup_related", time.Minute, talkgroupCacheTags, h.GetRelatedTalkgroups))
 	r.Get("/talkgroup-directory", h.ListTalkgroupDirectory)
-	r.Post("/talkgroup-directory/import", h.ImportTalkgroupDirectory)
+	r.Post("/talkgroup-directory/import", h.cache.Invalidating(tgsTag, h.ImportTalkgroupDirectory))
+	r.Get("/talkgroup-groups", h.cache.Cached("talkgroup_groups", 30*time.Second, tgsTag, h.ListTalkgroupGroups))
+	r.Patch("/talkgroup-groups/{name}", h.cache.Invalidating(tgsTag, h.UpdateTalkgroupGroup))
+	r.Delete("/talkgroup-groups/{name}", h.cache.Invalidating(tgsTag, h.DeleteTalkgroupGroup))
 }
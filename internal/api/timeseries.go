@@ -0,0 +1,97 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// TimeseriesHandler serves the internal counters the pipeline snapshots
+// every minute (calls, messages per handler, queue depths, batcher rows).
+type TimeseriesHandler struct {
+	db *database.DB
+}
+
+func NewTimeseriesHandler(db *database.DB) *TimeseriesHandler {
+	return &TimeseriesHandler{db: db}
+}
+
+// maxTimeseriesPoints caps points per series; larger ranges need a larger step.
+const maxTimeseriesPoints = 10000
+
+// GetTimeseries returns the requested metrics between start_time and
+// end_time (default the last 24h) bucketed by step (default: the smallest
+// whole number of minutes that keeps each series under ~1440 points).
+// Without metric, lists the stored series instead.
+func (h *TimeseriesHandler) GetTimeseries(w http.ResponseWriter, r *http.Request) {
+	metrics := QueryStringList(r, "metric")
+	if len(metrics) == 0 {
+		list, err := h.db.ListTimeseriesMetrics(r.Context())
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to list time series")
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]any{
+			"metrics": list,
+			"total":   len(list),
+		})
+		return
+	}
+
+	q := database.TimeseriesQuery{
+		Metrics: metrics,
+		Labels:  QueryStringList(r, "label"),
+		End:     time.Now(),
+	}
+	q.Start = q.End.Add(-24 * time.Hour)
+	if t, ok := QueryTime(r, "start_time"); ok {
+		q.Start = t
+	}
+	if t, ok := QueryTime(r, "end_time"); ok {
+		q.End = t
+	}
+	if msg := ValidateTimeRange(&q.Start, &q.End); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+
+	span := q.End.Sub(q.Start)
+	q.Step = (span/1440 + time.Minute - 1).Truncate(time.Minute)
+	if v, ok := QueryString(r, "step"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Minute {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "step must be a duration of at least 1m")
+			return
+		}
+		q.Step = d.Truncate(time.Minute)
+	}
+	if q.Step < time.Minute {
+		q.Step = time.Minute
+	}
+	if span/q.Step > maxTimeseriesPoints {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter,
+			fmt.Sprintf("step too small: at most %d points per series", maxTimeseriesPoints))
+		return
+	}
+
+	series, err := h.db.QueryTimeseries(r.Context(), q)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to query time series")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"start_time": q.Start,
+		"end_time":   q.End,
+		"step":       q.Step.String(),
+		"series":     series,
+		"total":      len(series),
+	})
+}
+
+func (h *TimeseriesHandler) Routes(r chi.Router) {
+	r.Get("/admin/timeseries", h.GetTimeseries)
+}
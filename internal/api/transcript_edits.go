@@ -0,0 +1,224 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/transcribe"
+)
+
+// maxTranscriptEdits bounds the word edits in one PATCH.
+const maxTranscriptEdits = 500
+
+// EditTranscript applies a human edit to a call's primary transcription and
+// stores the result as a new "human" version with a word-level diff against
+// the one it replaces. Body: either "text" (the full corrected transcript)
+// or "edits" (word edits indexed against the primary's words), plus an
+// optional "base_id" that must still be the primary, and "eval" to add the
+// correction to the STT evaluation set.
+func (h *TranscriptionsHandler) EditTranscript(w http.ResponseWriter, r *http.Request) {
+	ref, err := ParseCallRef(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid call ID")
+		return
+	}
+
+	var body struct {
+		BaseID *int                  `json:"base_id"`
+		Text   *string               `json:"text"`
+		Edits  []transcribe.WordEdit `json:"edits"`
+		Eval   bool                  `json:"eval"`
+	}
+	if err := DecodeJSON(r, &body); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	if (body.Text == nil) == (len(body.Edits) == 0) {
+		WriteError(w, http.StatusBadRequest, "exactly one of text or edits is required")
+		return
+	}
+	if len(body.Edits) > maxTranscriptEdits {
+		WriteError(w, http.StatusBadRequest, "too many edits")
+		return
+	}
+
+	if h.hideRestricted(w, r, ref, "call not found") {
+		return
+	}
+	call, err := h.store.GetCallForTranscription(r.Context(), ref)
+	if err != nil {
+		WriteError(w, http.StatusNotFound, "call not found")
+		return
+	}
+	versions, err := h.store.ListTranscriptionsByCall(r.Context(), call.CallID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to load transcriptions")
+		return
+	}
+	var base, hypothesis *database.TranscriptionAPI
+	for i := range versions {
+		if versions[i].IsPrimary && base == nil {
+			base = &versions[i]
+		}
+		if versions[i].Source == "auto" && hypothesis == nil {
+			hypothesis = &versions[i] // newest STT output
+		}
+	}
+	if base == nil {
+		WriteError(w, http.StatusNotFound, "call has no transcription to edit")
+		return
+	}
+	if body.BaseID != nil && *body.BaseID != base.ID {
+		WriteError(w, http.StatusConflict, "transcript changed")
+		return
+	}
+
+	// Diff against the primary's timed words when it has them, so timing and
+	// unit attribution carry over to the new version.
+	var baseWords *transcribe.TranscriptionWords
+	if len(base.Words) > 0 {
+		var tw transcribe.TranscriptionWords
+		if json.Unmarshal(base.Words, &tw) == nil && len(tw.Words) > 0 {
+			baseWords = &tw
+		}
+	}
+	var oldTokens []string
+	if baseWords != nil {
+		oldTokens = make([]string, len(baseWords.Words))
+		for i, w := range baseWords.Words {
+			oldTokens[i] = strings.TrimSpace(w.Word)
+		}
+	} else {
+		oldTokens = strings.Fields(base.Text)
+	}
+
+	var newTokens []string
+	var text string
+	if body.Text != nil {
+		text = strings.TrimSpace(*body.Text)
+		newTokens = strings.Fields(text)
+	} else {
+		if newTokens, err = transcribe.ApplyWordEdits(oldTokens, body.Edits); err != nil {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, err.Error())
+			return
+		}
+		text = strings.Join(newTokens, " ")
+	}
+	if len(newTokens) == 0 {
+		WriteError(w, http.StatusBadRequest, "edited transcript is empty")
+		return
+	}
+
+	diff := transcribe.DiffWords(oldTokens, newTokens)
+	if len(diff.Hunks) == 0 {
+		WriteError(w, http.StatusBadRequest, "edit makes no changes")
+		return
+	}
+	diffJSON, err := json.Marshal(diff)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to encode diff")
+		return
+	}
+
+	edit := &database.TranscriptEdit{
+		Row: database.TranscriptionRow{
+			CallID:        call.CallID,
+			CallStartTime: call.StartTime,
+			Text:          text,
+			Source:        "human",
+			IsPrimary:     true,
+			Language:      base.Language,
+			WordCount:     len(newTokens),
+		},
+		ParentID: base.ID,
+		Diff:     diffJSON,
+	}
+	if baseWords != nil {
+		if edit.Row.Words, err = json.Marshal(transcribe.RemapWords(baseWords, diff, newTokens, text)); err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to encode words")
+			return
+		}
+	}
+	if h.live != nil {
+		edit.Row.Urgency = h.live.ClassifyTranscript(r.Context(), call, text)
+	}
+	if body.Eval && hypothesis != nil {
+		errs, refWords := transcribe.WordErrorRate(text, hypothesis.Text)
+		edit.Eval = &database.STTEvalPair{
+			HypothesisID:   hypothesis.ID,
+			CallID:         call.CallID,
+			CallStartTime:  call.StartTime,
+			Provider:       hypothesis.Provider,
+			Model:          hypothesis.Model,
+			Reference:      text,
+			Hypothesis:     hypothesis.Text,
+			ReferenceWords: refWords,
+			WordErrors:     errs,
+		}
+	}
+
+	if _, err := h.store.InsertTranscriptEdit(r.Context(), edit); err != nil {
+		if errors.Is(err, database.ErrTranscriptChanged) {
+			WriteError(w, http.StatusConflict, "transcript changed")
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to save edit")
+		return
+	}
+
+	t, err := h.store.GetPrimaryTranscription(r.Context(), call.CallID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to load edited transcription")
+		return
+	}
+	resp := map[string]any{"transcription": t}
+	if edit.Eval != nil {
+		resp["eval"] = edit.Eval
+	} else if body.Eval {
+		resp["eval_skipped"] = "no STT transcription to compare against"
+	}
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+// GetSTTEval reports the STT evaluation set built from human edits: word
+// error rate per provider and model, and the pairs themselves. Pairs of
+// restricted or embargoed calls are left out for non-admins.
+func (h *TranscriptionsHandler) GetSTTEval(w http.ResponseWriter, r *http.Request) {
+	p, err := ParsePagination(r)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+	filter := database.STTEvalFilter{Limit: p.Limit, Offset: p.Offset, IncludeRestricted: isAdmin(r)}
+	filter.Provider, _ = QueryString(r, "provider")
+	filter.Model, _ = QueryString(r, "model")
+	if t, ok := QueryTime(r, "start_time"); ok {
+		filter.StartTime = &t
+	}
+	if t, ok := QueryTime(r, "end_time"); ok {
+		filter.EndTime = &t
+	}
+	if msg := ValidateTimeRange(filter.StartTime, filter.EndTime); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+
+	summary, err := h.store.STTEvalSummaries(r.Context(), filter)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to summarize STT evaluation")
+		return
+	}
+	pairs, total, err := h.store.ListSTTEvalPairs(r.Context(), filter)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list STT evaluation pairs")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"summary": summary,
+		"pairs":   pairs,
+		"total":   total,
+	})
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// fakeTranscriptStore implements transcriptStore for testing. Call 7 is
+// embargoed.
+type fakeTranscriptStore struct {
+	evalFilter database.STTEvalFilter
+	loaded     bool  // GetCallForTranscription was called
+	insertErr  error // returned by InsertTranscriptEdit
+}
+
+func (f *fakeTranscriptStore) CallRestricted(_ context.Context, ref database.CallRef) (bool, error) {
+	return ref.CallID == 7, nil
+}
+
+func (f *fakeTranscriptStore) GetCallForTranscription(_ context.Context, ref database.CallRef) (*database.CallTranscriptionInfo, error) {
+	f.loaded = true
+	return &database.CallTranscriptionInfo{CallID: ref.CallID, SystemID: 1, Tgid: 500}, nil
+}
+
+func (f *fakeTranscriptStore) ListTranscriptionsByCall(_ context.Context, callID int64) ([]database.TranscriptionAPI, error) {
+	return []database.TranscriptionAPI{{ID: 1, CallID: callID, Text: "engine one responding", Source: "auto", IsPrimary: true}}, nil
+}
+
+func (f *fakeTranscriptStore) GetPrimaryTranscription(_ context.Context, callID int64) (*database.TranscriptionAPI, error) {
+	return &database.TranscriptionAPI{ID: 2, CallID: callID, Text: "engine 1 responding", Source: "human", IsPrimary: true}, nil
+}
+
+func (f *fakeTranscriptStore) InsertTranscriptEdit(context.Context, *database.TranscriptEdit) (int, error) {
+	return 2, f.insertErr
+}
+
+func (f *fakeTranscriptStore) ListSTTEvalPairs(_ context.Context, filter database.STTEvalFilter) ([]database.STTEvalPair, int, error) {
+	f.evalFilter = filter
+	pairs := []database.STTEvalPair{{CallID: 1, Reference: "engine 1 responding"}}
+	if filter.IncludeRestricted {
+		pairs = append(pairs, database.STTEvalPair{CallID: 7, Reference: "embargoed reference"})
+	}
+	return pairs, len(pairs), nil
+}
+
+func (f *fakeTranscriptStore) STTEvalSummaries(context.Context, database.STTEvalFilter) ([]database.STTEvalSummary, error) {
+	return []database.STTEvalSummary{}, nil
+}
+
+// serveTranscripts runs a request through the transcript routes as an admin
+// or not. With auth disabled everyone is an admin; with it on and no write
+// token presented, nobody is.
+func serveTranscripts(h *TranscriptionsHandler, admin bool, method, path, body string) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	r.Use(AdminContext(!admin, "writer"))
+	r.Patch("/calls/{id}/transcript", h.EditTranscript)
+	r.Get("/transcriptions/eval", h.GetSTTEval)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rec
+}
+
+func TestSTTEvalRestricted(t *testing.T) {
+	for _, admin := range []bool{false, true} {
+		t.Run(fmt.Sprintf("admin=%v", admin), func(t *testing.T) {
+			store := &fakeTranscriptStore{}
+			rec := serveTranscripts(&TranscriptionsHandler{store: store}, admin, "GET", "/transcriptions/eval", "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+			}
+			if store.evalFilter.IncludeRestricted != admin {
+				t.Errorf("IncludeRestricted = %v, want %v", store.evalFilter.IncludeRestricted, admin)
+			}
+			if got := strings.Contains(rec.Body.String(), "embargoed reference"); got != admin {
+				t.Errorf("embargoed pair returned = %v, want %v: %s", got, admin, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestEditTranscriptRestricted(t *testing.T) {
+	body := `{"text": "engine 1 responding"}`
+
+	store := &fakeTranscriptStore{}
+	rec := serveTranscripts(&TranscriptionsHandler{store: store}, false, "PATCH", "/calls/7/transcript", body)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("non-admin, embargoed call: status = %d, want 404: %s", rec.Code, rec.Body.String())
+	}
+	if store.loaded || strings.Contains(rec.Body.String(), "responding") {
+		t.Errorf("non-admin, embargoed call: call was loaded or its transcript returned: %s", rec.Body.String())
+	}
+
+	store = &fakeTranscriptStore{}
+	if rec := serveTranscripts(&TranscriptionsHandler{store: store}, false, "PATCH", "/calls/1/transcript", body); rec.Code != http.StatusOK {
+		t.Errorf("non-admin, open call: status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	store = &fakeTranscriptStore{}
+	if rec := serveTranscripts(&TranscriptionsHandler{store: store}, true, "PATCH", "/calls/7/transcript", body); rec.Code != http.StatusOK {
+		t.Errorf("admin, embargoed call: status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	store = &fakeTranscriptStore{insertErr: fmt.Errorf("insert: %w", database.ErrTranscriptChanged)}
+	if rec := serveTranscripts(&TranscriptionsHandler{store: store}, true, "PATCH", "/calls/1/transcript", body); rec.Code != http.StatusConflict {
+		t.Errorf("concurrent edit: status = %d, want 409: %s", rec.Code, rec.Body.String())
+	}
+
+	store = &fakeTranscriptStore{insertErr: errors.New("connection reset")}
+	if rec := serveTranscripts(&TranscriptionsHandler{store: store}, true, "PATCH", "/calls/1/transcript", body); rec.Code != http.StatusInternalServerError {
+		t.Errorf("database error: status = %d, want 500: %s", rec.Code, rec.Body.String())
+	}
+}
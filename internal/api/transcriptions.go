@@ -1,44 +1,88 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/transcribe"
 )
 
+// urgencyLabels are the labels assigned by the urgency classifier.
+var urgencyLabels = map[string]bool{"routine": true, "urgent": true, "emergency_language": true}
+
 type TranscriptionsHandler struct {
-	db   *database.DB
-	live LiveDataSource
+	db    *database.DB
+	store transcriptStore // db; a fake in tests
+	live  LiveDataSource
+	rt    *transcribe.Retranscriber // nil when transcription is disabled
+}
+
+// transcriptStore is the part of database.DB used by the restriction check,
+// transcript edits and the STT evaluation report.
+type transcriptStore interface {
+	CallRestricted(ctx context.Context, ref database.CallRef) (bool, error)
+	GetCallForTranscription(ctx context.Context, ref database.CallRef) (*database.CallTranscriptionInfo, error)
+	ListTranscriptionsByCall(ctx context.Context, callID int64) ([]database.TranscriptionAPI, error)
+	GetPrimaryTranscription(ctx context.Context, callID int64) (*database.TranscriptionAPI, error)
+	InsertTranscriptEdit(ctx context.Context, e *database.TranscriptEdit) (int, error)
+	ListSTTEvalPairs(ctx context.Context, f database.STTEvalFilter) ([]database.STTEvalPair, int, error)
+	STTEvalSummaries(ctx context.Context, f database.STTEvalFilter) ([]database.STTEvalSummary, error)
 }
 
-func NewTranscriptionsHandler(db *database.DB, live LiveDataSource) *TranscriptionsHandler {
-	return &TranscriptionsHandler{db: db, live: live}
+func NewTranscriptionsHandler(db *database.DB, live LiveDataSource, rt *transcribe.Retranscriber) *TranscriptionsHandler {
+	return &TranscriptionsHandler{db: db, store: db, live: live, rt: rt}
 }
 
 func (h *TranscriptionsHandler) Routes(r chi.Router) {
 	r.Get("/calls/{id}/transcription", h.GetCallTranscription)
 	r.Get("/calls/{id}/transcriptions", h.ListCallTranscriptions)
 	r.Put("/calls/{id}/transcription", h.SubmitCorrection)
+	r.Patch("/calls/{id}/transcript", h.EditTranscript)
 	r.Post("/calls/{id}/transcribe", h.TranscribeCall)
+	r.Get("/calls/{id}/transcribe/{job_id}", h.GetTranscribeJob)
 	r.Post("/calls/{id}/transcription/verify", h.VerifyTranscription)
 	r.Post("/calls/{id}/transcription/reject", h.RejectTranscription)
 	r.Post("/calls/{id}/transcription/exclude", h.ExcludeFromDataset)
 	r.Get("/transcriptions/batch", h.GetBatchTranscriptions)
 	r.Get("/transcriptions/search", h.SearchTranscriptions)
 	r.Get("/transcriptions/queue", h.GetQueueStats)
+	r.Get("/transcriptions/dead-letters", h.ListDeadLetters)
+	r.Get("/transcriptions/eval", h.GetSTTEval)
+}
+
+// hideRestricted writes a 404 with msg and returns true if the call is
+// hidden from this client by a restricted encryption policy or embargo.
+func (h *TranscriptionsHandler) hideRestricted(w http.ResponseWriter, r *http.Request, ref database.CallRef, msg string) bool {
+	if isAdmin(r) {
+		return false
+	}
+	if restricted, err := h.store.CallRestricted(r.Context(), ref); err != nil || restricted {
+		WriteError(w, http.StatusNotFound, msg)
+		return true
+	}
+	return false
 }
 
 // GetCallTranscription returns the primary transcription for a call.
 func (h *TranscriptionsHandler) GetCallTranscription(w http.ResponseWriter, r *http.Request) {
-	id, err := PathInt64(r, "id")
+	ref, err := ParseCallRef(r, "id")
 	if err != nil {
 		WriteError(w, http.StatusBadRequest, "invalid call ID")
 		return
 	}
 
-	t, err := h.db.GetPrimaryTranscription(r.Context(), id)
+	if h.hideRestricted(w, r, ref, "no transcription found") {
+		return
+	}
+
+	t, err := h.db.GetPrimaryTranscription(r.Context(), ref.CallID)
 	if err != nil {
 		WriteError(w, http.StatusNotFound, "no transcription found")
 		return
@@ -48,13 +92,17 @@ func (h *TranscriptionsHandler) GetCallTranscription(w http.ResponseWriter, r *h
 
 // ListCallTranscriptions returns all transcription variants for a call.
 func (h *TranscriptionsHandler) ListCallTranscriptions(w http.ResponseWriter, r *http.Request) {
-	id, err := PathInt64(r, "id")
+	ref, err := ParseCallRef(r, "id")
 	if err != nil {
 		WriteError(w, http.StatusBadRequest, "invalid call ID")
 		return
 	}
 
-	transcriptions, err := h.db.ListTranscriptionsByCall(r.Context(), id)
+	if h.hideRestricted(w, r, ref, "call not found") {
+		return
+	}
+
+	transcriptions, err := h.db.ListTranscriptionsByCall(r.Context(), ref.CallID)
 	if err != nil {
 		WriteError(w, http.StatusInternalServerError, "failed to list transcriptions")
 		return
@@ -67,7 +115,7 @@ func (h *TranscriptionsHandler) ListCallTranscriptions(w http.ResponseWriter, r
 
 // SubmitCorrection accepts a human correction for a call's transcription.
 func (h *TranscriptionsHandler) SubmitCorrection(w http.ResponseWriter, r *http.Request) {
-	id, err := PathInt64(r, "id")
+	ref, err := ParseCallRef(r, "id")
 	if err != nil {
 		WriteError(w, http.StatusBadRequest, "invalid call ID")
 		return
@@ -95,7 +143,7 @@ func (h *TranscriptionsHandler) SubmitCorrection(w http.ResponseWriter, r *http.
 	}
 
 	// Look up the call to get start_time for partitioned insert
-	call, err := h.db.GetCallForTranscription(r.Context(), id)
+	call, err := h.db.GetCallForTranscription(r.Context(), ref)
 	if err != nil {
 		WriteError(w, http.StatusNotFound, "call not found")
 		return
@@ -111,6 +159,9 @@ func (h *TranscriptionsHandler) SubmitCorrection(w http.ResponseWriter, r *http.
 		Language:      body.Language,
 		Words:         body.Words,
 	}
+	if h.live != nil {
+		row.Urgency = h.live.ClassifyTranscript(r.Context(), call, body.Text)
+	}
 
 	txID, err := h.db.InsertTranscription(r.Context(), row)
 	if err != nil {
@@ -124,29 +175,108 @@ func (h *TranscriptionsHandler) SubmitCorrection(w http.ResponseWriter, r *http.
 	})
 }
 
-// TranscribeCall enqueues a call for (re-)transcription.
+// TranscribeCall queues a call for transcription. Without a body the call
+// joins the live queue and its primary transcript is replaced as usual. A
+// body with provider, model or temperature re-runs the call through them
+// outside the queue and stores a non-primary variant, for when the first
+// pass hallucinated; the job is returned and can be polled at
+// GET /calls/{id}/transcribe/{job_id}.
 func (h *TranscriptionsHandler) TranscribeCall(w http.ResponseWriter, r *http.Request) {
-	id, err := PathInt64(r, "id")
+	ref, err := ParseCallRef(r, "id")
 	if err != nil {
 		WriteError(w, http.StatusBadRequest, "invalid call ID")
 		return
 	}
 
+	var req struct {
+		Provider    string   `json:"provider"`
+		Model       string   `json:"model"`
+		Temperature *float64 `json:"temperature"`
+	}
+	// An empty body means no overrides
+	if err := DecodeJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	req.Provider, req.Model = strings.TrimSpace(req.Provider), strings.TrimSpace(req.Model)
+	if req.Provider == "" && req.Model == "" && req.Temperature == nil {
+		h.enqueueTranscription(w, ref)
+		return
+	}
+
+	if h.rt == nil {
+		WriteError(w, http.StatusServiceUnavailable, "transcription not enabled (set STT_PROVIDER)")
+		return
+	}
+	if t := req.Temperature; t != nil && (*t < 0 || *t > 1) {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "temperature must be between 0 and 1")
+		return
+	}
+	call, err := h.db.GetCallForTranscription(r.Context(), ref)
+	if err != nil {
+		WriteError(w, http.StatusNotFound, "call not found")
+		return
+	}
+	if h.hideRestricted(w, r, database.CallRef{CallID: call.CallID, StartTime: call.StartTime}, "call not found") {
+		return
+	}
+	job, err := h.rt.TranscribeCall(call, req.Provider, req.Model, req.Temperature)
+	switch {
+	case errors.Is(err, transcribe.ErrProviderUnavailable):
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	case errors.Is(err, transcribe.ErrCallJobsFull):
+		WriteError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	case err != nil:
+		WriteError(w, http.StatusInternalServerError, "failed to queue transcription")
+		return
+	}
+	WriteJSON(w, http.StatusAccepted, job)
+}
+
+// enqueueTranscription adds a call to the live transcription queue.
+func (h *TranscriptionsHandler) enqueueTranscription(w http.ResponseWriter, ref database.CallRef) {
 	if h.live == nil {
 		WriteError(w, http.StatusServiceUnavailable, "transcription not available")
 		return
 	}
 
-	if !h.live.EnqueueTranscription(id) {
+	if !h.live.EnqueueTranscription(ref) {
 		WriteError(w, http.StatusServiceUnavailable, "transcription queue full or not configured")
 		return
 	}
 	WriteJSON(w, http.StatusAccepted, map[string]any{
-		"call_id": id,
+		"call_id": ref.CallID,
 		"status":  "queued",
 	})
 }
 
+// GetTranscribeJob returns a transcription job started with a provider,
+// model or temperature override. Finished jobs are kept for an hour.
+func (h *TranscriptionsHandler) GetTranscribeJob(w http.ResponseWriter, r *http.Request) {
+	ref, err := ParseCallRef(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid call ID")
+		return
+	}
+	id, err := PathInt64(r, "job_id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid job ID")
+		return
+	}
+	if h.rt == nil {
+		WriteError(w, http.StatusNotFound, "transcription job not found")
+		return
+	}
+	job, ok := h.rt.CallJob(id)
+	if !ok || job.CallID != ref.CallID {
+		WriteError(w, http.StatusNotFound, "transcription job not found")
+		return
+	}
+	WriteJSON(w, http.StatusOK, job)
+}
+
 // VerifyTranscription marks a transcription as verified.
 func (h *TranscriptionsHandler) VerifyTranscription(w http.ResponseWriter, r *http.Request) {
 	h.setTranscriptionStatus(w, r, "verified")
@@ -163,13 +293,13 @@ func (h *TranscriptionsHandler) ExcludeFromDataset(w http.ResponseWriter, r *htt
 }
 
 func (h *TranscriptionsHandler) setTranscriptionStatus(w http.ResponseWriter, r *http.Request, status string) {
-	id, err := PathInt64(r, "id")
+	ref, err := ParseCallRef(r, "id")
 	if err != nil {
 		WriteError(w, http.StatusBadRequest, "invalid call ID")
 		return
 	}
 
-	call, err := h.db.GetCallForTranscription(r.Context(), id)
+	call, err := h.db.GetCallForTranscription(r.Context(), ref)
 	if err != nil {
 		WriteError(w, http.StatusNotFound, "call not found")
 		return
@@ -197,7 +327,7 @@ func (h *TranscriptionsHandler) GetBatchTranscriptions(w http.ResponseWriter, r
 		return
 	}
 
-	results, err := h.db.GetBatchTranscriptions(r.Context(), callIDs)
+	results, err := h.db.GetBatchTranscriptions(r.Context(), callIDs, isAdmin(r))
 	if err != nil {
 		WriteError(w, http.StatusInternalServerError, "failed to get transcriptions")
 		return
@@ -208,9 +338,29 @@ func (h *TranscriptionsHandler) GetBatchTranscriptions(w http.ResponseWriter, r
 }
 
 // SearchTranscriptions performs full-text search across transcriptions.
+// q may be omitted when filtering by urgency, e.g. ?urgency=urgent,emergency_language
+// for a triage view of recent high-urgency traffic.
 func (h *TranscriptionsHandler) SearchTranscriptions(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query().Get("q")
-	if q == "" {
+
+	labels := QueryStringList(r, "urgency")
+	for _, l := range labels {
+		if !urgencyLabels[l] {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "urgency must be routine, urgent, or emergency_language")
+			return
+		}
+	}
+	var minUrgency *float32
+	if v := r.URL.Query().Get("min_urgency"); v != "" {
+		f, err := strconv.ParseFloat(v, 32)
+		if err != nil || f < 0 || f > 1 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "min_urgency must be a number between 0 and 1")
+			return
+		}
+		f32 := float32(f)
+		minUrgency = &f32
+	}
+	if q == "" && len(labels) == 0 && minUrgency == nil {
 		WriteError(w, http.StatusBadRequest, "q parameter is required")
 		return
 	}
@@ -227,6 +377,11 @@ func (h *TranscriptionsHandler) SearchTranscriptions(w http.ResponseWriter, r *h
 		Tgids:     QueryIntListAliased(r, "tgid", "tgids"),
 		Limit:     p.Limit,
 		Offset:    p.Offset,
+
+		UrgencyLabels:   labels,
+		MinUrgencyScore: minUrgency,
+
+		IncludeRestricted: isAdmin(r),
 	}
 	if t, ok := QueryTime(r, "start_time"); ok {
 		filter.StartTime = &t
@@ -279,3 +434,20 @@ func (h *TranscriptionsHandler) GetQueueStats(w http.ResponseWriter, r *http.Req
 	result["status"] = "ok"
 	WriteJSON(w, http.StatusOK, result)
 }
+
+// ListDeadLetters returns transcription jobs the worker pool gave up on after
+// repeated stuck or panicked attempts. Re-queue one with POST
+// /calls/{id}/transcribe.
+func (h *TranscriptionsHandler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	var dead []TranscriptionDeadLetterData
+	if h.live != nil {
+		dead = h.live.TranscriptionDeadLetters()
+	}
+	if dead == nil {
+		dead = []TranscriptionDeadLetterData{}
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"dead_letters": dead,
+		"total":        len(dead),
+	})
+}
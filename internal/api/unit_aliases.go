@@ -0,0 +1,99 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// ListUnitAliasSuggestions returns alpha tags inferred from transcripts for
+// untagged units, most confident first. ?status=pending (default), accepted,
+// rejected, or all; ?min_confidence=0..1.
+func (h *AdminHandler) ListUnitAliasSuggestions(w http.ResponseWriter, r *http.Request) {
+	p, err := ParsePagination(r)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+	filter := database.UnitAliasSuggestionFilter{Limit: p.Limit, Offset: p.Offset}
+	if v, ok := QueryInt(r, "system_id"); ok {
+		filter.SystemID = &v
+	}
+	if v, ok := QueryInt(r, "unit_id"); ok {
+		filter.UnitID = &v
+	}
+	status, _ := QueryString(r, "status")
+	switch status {
+	case "":
+		status = "pending"
+		filter.Status = &status
+	case "pending", "accepted", "rejected":
+		filter.Status = &status
+	case "all":
+	default:
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "status must be pending, accepted, rejected, or all")
+		return
+	}
+	if s, ok := QueryString(r, "min_confidence"); ok {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v < 0 || v > 1 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "min_confidence must be between 0 and 1")
+			return
+		}
+		filter.MinConfidence = v
+	}
+
+	suggestions, total, err := h.db.ListUnitAliasSuggestions(r.Context(), filter)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list unit alias suggestions")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"suggestions": suggestions,
+		"total":       total,
+	})
+}
+
+// AcceptUnitAliasSuggestion sets the unit's alpha_tag to the suggested alias,
+// or to an optional {"alpha_tag"} override, as a manual tag.
+func (h *AdminHandler) AcceptUnitAliasSuggestion(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		AlphaTag string `json:"alpha_tag"`
+	}
+	if r.ContentLength != 0 {
+		if err := DecodeJSON(r, &req); err != nil {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+			return
+		}
+	}
+	h.reviewUnitAliasSuggestion(w, r, true, req.AlphaTag)
+}
+
+// RejectUnitAliasSuggestion dismisses a suggestion; the alias won't be proposed
+// for that unit again.
+func (h *AdminHandler) RejectUnitAliasSuggestion(w http.ResponseWriter, r *http.Request) {
+	h.reviewUnitAliasSuggestion(w, r, false, "")
+}
+
+func (h *AdminHandler) reviewUnitAliasSuggestion(w http.ResponseWriter, r *http.Request, accept bool, alphaTag string) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid suggestion ID")
+		return
+	}
+
+	s, err := h.db.ReviewUnitAliasSuggestion(r.Context(), id, accept, alphaTag)
+	if err != nil {
+		switch err.Error() {
+		case "unit alias suggestion not found":
+			WriteError(w, http.StatusNotFound, err.Error())
+		case "unit alias suggestion already reviewed":
+			WriteError(w, http.StatusConflict, err.Error())
+		default:
+			WriteError(w, http.StatusInternalServerError, "failed to review unit alias suggestion")
+		}
+		return
+	}
+	WriteJSON(w, http.StatusOK, s)
+}
@@ -0,0 +1,222 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// unitCensusQuerier is the subset of database.DB used by UnitCensusHandler.
+type unitCensusQuerier interface {
+	GetSystemByID(ctx context.Context, systemID int) (*database.SystemAPI, error)
+	GetUnitCensus(ctx context.Context, systemID int, start, end time.Time, silentDays int) ([]database.UnitCensusDay, error)
+	GetUnitCensusSummary(ctx context.Context, systemID int, start, end time.Time, silentDays int) (database.UnitCensusSummary, error)
+	ListUnitCensusUnits(ctx context.Context, f database.UnitCensusFilter) ([]database.UnitCensusUnit, int, error)
+}
+
+type UnitCensusHandler struct {
+	db unitCensusQuerier
+}
+
+func NewUnitCensusHandler(db *database.DB) *UnitCensusHandler {
+	return &UnitCensusHandler{db: db}
+}
+
+const (
+	maxUnitCensusRange        = 366 * 24 * time.Hour
+	defaultUnitCensusSilent   = 30
+	maxUnitCensusSilentDays   = 3650
+	defaultUnitCensusUnitPage = 1000
+)
+
+// unitCensusParams holds the query parameters shared by the census endpoints.
+type unitCensusParams struct {
+	systemID   int
+	start, end time.Time
+	silentDays int
+	format     string
+}
+
+// parseUnitCensusParams reads the system ID, window (default: the last 30
+// days), silent_days and format, and checks that the system exists. Writes
+// the error response and returns false on failure.
+func (h *UnitCensusHandler) parseUnitCensusParams(w http.ResponseWriter, r *http.Request) (unitCensusParams, bool) {
+	p := unitCensusParams{silentDays: defaultUnitCensusSilent, format: "json"}
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid system ID")
+		return p, false
+	}
+	p.systemID = id
+
+	p.end = time.Now()
+	if t, ok := QueryTime(r, "end_time"); ok {
+		p.end = t
+	}
+	p.start = p.end.Add(-30 * 24 * time.Hour)
+	if t, ok := QueryTime(r, "start_time"); ok {
+		p.start = t
+	}
+	if msg := ValidateTimeRange(&p.start, &p.end); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return p, false
+	}
+	if p.end.Sub(p.start) > maxUnitCensusRange {
+		WriteError(w, http.StatusBadRequest, "time range cannot exceed 366 days")
+		return p, false
+	}
+	if v, ok := QueryInt(r, "silent_days"); ok {
+		if v < 1 || v > maxUnitCensusSilentDays {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter,
+				fmt.Sprintf("silent_days must be between 1 and %d", maxUnitCensusSilentDays))
+			return p, false
+		}
+		p.silentDays = v
+	}
+	if v, ok := QueryString(r, "format"); ok {
+		if v != "csv" && v != "json" {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "format must be csv or json")
+			return p, false
+		}
+		p.format = v
+	}
+
+	if _, err := h.db.GetSystemByID(r.Context(), p.systemID); err != nil {
+		WriteError(w, http.StatusNotFound, "system not found")
+		return p, false
+	}
+	return p, true
+}
+
+// GetUnitCensus returns a system's daily radio census: unique radios seen,
+// radios that registered, new radios and radios gone silent for silent_days,
+// plus totals for the window. ?format=csv downloads the days as CSV.
+func (h *UnitCensusHandler) GetUnitCensus(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.parseUnitCensusParams(w, r)
+	if !ok {
+		return
+	}
+
+	days, err := h.db.GetUnitCensus(r.Context(), p.systemID, p.start, p.end, p.silentDays)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get unit census")
+		return
+	}
+
+	if p.format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="unit-census-%d.csv"`, p.systemID))
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"day", "seen", "registered", "new", "went_silent", "registrations", "deregistrations"})
+		for _, d := range days {
+			cw.Write([]string{d.Day, strconv.Itoa(d.Seen), strconv.Itoa(d.Registered), strconv.Itoa(d.New),
+				strconv.Itoa(d.WentSilent), strconv.Itoa(d.Registrations), strconv.Itoa(d.Deregistrations)})
+		}
+		cw.Flush()
+		return
+	}
+
+	summary, err := h.db.GetUnitCensusSummary(r.Context(), p.systemID, p.start, p.end, p.silentDays)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get unit census")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"system_id":   p.systemID,
+		"start_day":   p.start.UTC().Format("2006-01-02"),
+		"end_day":     p.end.UTC().Format("2006-01-02"),
+		"silent_days": p.silentDays,
+		"summary":     summary,
+		"days":        days,
+	})
+}
+
+// ListUnitCensusUnits returns a system's radios with their activity in the
+// window and a census status (active, new, idle, silent or never).
+// ?format=csv downloads every matching radio as CSV.
+func (h *UnitCensusHandler) ListUnitCensusUnits(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.parseUnitCensusParams(w, r)
+	if !ok {
+		return
+	}
+	filter := database.UnitCensusFilter{
+		SystemID:   p.systemID,
+		StartTime:  p.start,
+		EndTime:    p.end,
+		SilentDays: p.silentDays,
+		Limit:      defaultUnitCensusUnitPage,
+	}
+	if v, ok := QueryString(r, "status"); ok {
+		switch v {
+		case database.UnitCensusActive, database.UnitCensusNew, database.UnitCensusIdle,
+			database.UnitCensusSilent, database.UnitCensusNever:
+			filter.Status = v
+		default:
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter,
+				"status must be active, new, idle, silent or never")
+			return
+		}
+	}
+	if p.format == "json" {
+		pg, err := ParsePagination(r)
+		if err != nil {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+			return
+		}
+		if r.URL.Query().Has("limit") {
+			filter.Limit = pg.Limit
+		}
+		filter.Offset = pg.Offset
+	} else {
+		filter.Limit = 1 << 30 // the export is every matching radio
+	}
+
+	units, total, err := h.db.ListUnitCensusUnits(r.Context(), filter)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list unit census")
+		return
+	}
+
+	if p.format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="unit-census-units-%d.csv"`, p.systemID))
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"unit_id", "alpha_tag", "status", "first_seen", "last_seen", "days_seen", "registrations", "deregistrations"})
+		for _, u := range units {
+			cw.Write([]string{strconv.Itoa(u.UnitID), u.AlphaTag, u.Status, csvTime(u.FirstSeen), csvTime(u.LastSeen),
+				strconv.Itoa(u.DaysSeen), strconv.Itoa(u.Registrations), strconv.Itoa(u.Deregistrations)})
+		}
+		cw.Flush()
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"system_id":   p.systemID,
+		"start_day":   p.start.UTC().Format("2006-01-02"),
+		"end_day":     p.end.UTC().Format("2006-01-02"),
+		"silent_days": p.silentDays,
+		"units":       units,
+		"total":       total,
+		"limit":       filter.Limit,
+		"offset":      filter.Offset,
+	})
+}
+
+// csvTime formats an optional time for CSV output; nil is empty.
+func csvTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func (h *UnitCensusHandler) Routes(r chi.Router) {
+	r.Get("/systems/{id}/unit-census", h.GetUnitCensus)
+	r.Get("/systems/{id}/unit-census/units", h.ListUnitCensusUnits)
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// mockUnitCensusQuerier implements unitCensusQuerier for testing. System 1
+// exists; every other ID is not found.
+type mockUnitCensusQuerier struct {
+	silentDays int
+	filter     database.UnitCensusFilter
+}
+
+func (m *mockUnitCensusQuerier) GetSystemByID(_ context.Context, systemID int) (*database.SystemAPI, error) {
+	if systemID != 1 {
+		return nil, fmt.Errorf("system not found")
+	}
+	return &database.SystemAPI{}, nil
+}
+
+func (m *mockUnitCensusQuerier) GetUnitCensus(_ context.Context, systemID int, start, end time.Time, silentDays int) ([]database.UnitCensusDay, error) {
+	m.silentDays = silentDays
+	return []database.UnitCensusDay{{Day: "2026-01-01", Seen: 12, Registered: 10, New: 2, WentSilent: 1, Registrations: 30, Deregistrations: 8}}, nil
+}
+
+func (m *mockUnitCensusQuerier) GetUnitCensusSummary(_ context.Context, systemID int, start, end time.Time, silentDays int) (database.UnitCensusSummary, error) {
+	return database.UnitCensusSummary{Known: 20, Seen: 12}, nil
+}
+
+func (m *mockUnitCensusQuerier) ListUnitCensusUnits(_ context.Context, f database.UnitCensusFilter) ([]database.UnitCensusUnit, int, error) {
+	m.filter = f
+	return []database.UnitCensusUnit{{UnitID: 100, AlphaTag: "E1, Driver", Status: database.UnitCensusSilent}}, 1, nil
+}
+
+func serveUnitCensus(mock *mockUnitCensusQuerier, path string) *httptest.ResponseRecorder {
+	r := chi.NewRouter()
+	(&UnitCensusHandler{db: mock}).Routes(r)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+	return rec
+}
+
+func TestGetUnitCensus(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{"unknown_system", "/systems/2/unit-census", http.StatusNotFound},
+		{"bad_silent_days", "/systems/1/unit-census?silent_days=0", http.StatusBadRequest},
+		{"bad_format", "/systems/1/unit-census?format=xml", http.StatusBadRequest},
+		{"range_too_long", "/systems/1/unit-census?start_time=2025-01-01T00:00:00Z&end_time=2026-03-01T00:00:00Z", http.StatusBadRequest},
+		{"ok", "/systems/1/unit-census?silent_days=14", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockUnitCensusQuerier{}
+			rec := serveUnitCensus(mock, tt.path)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			if tt.status == http.StatusOK && mock.silentDays != 14 {
+				t.Errorf("silent_days = %d, want 14", mock.silentDays)
+			}
+		})
+	}
+}
+
+func TestGetUnitCensusCSV(t *testing.T) {
+	rec := serveUnitCensus(&mockUnitCensusQuerier{}, "/systems/1/unit-census?format=csv")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	want := "day,seen,registered,new,went_silent,registrations,deregistrations\n2026-01-01,12,10,2,1,30,8\n"
+	if rec.Body.String() != want {
+		t.Errorf("body = %q, want %q", rec.Body.String(), want)
+	}
+}
+
+func TestListUnitCensusUnits(t *testing.T) {
+	mock := &mockUnitCensusQuerier{}
+	if rec := serveUnitCensus(mock, "/systems/1/unit-census/units?status=gone"); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad status: %d, want 400", rec.Code)
+	}
+
+	rec := serveUnitCensus(mock, "/systems/1/unit-census/units?status=silent&limit=50&offset=100")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if f := mock.filter; f.Status != "silent" || f.Limit != 50 || f.Offset != 100 || f.SilentDays != 30 {
+		t.Errorf("filter = %+v", f)
+	}
+
+	rec = serveUnitCensus(mock, "/systems/1/unit-census/units?format=csv&offset=100")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("csv status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if mock.filter.Offset != 0 || mock.filter.Limit < 1000 {
+		t.Errorf("csv export is paged: %+v", mock.filter)
+	}
+	if !strings.Contains(rec.Body.String(), `100,"E1, Driver",silent,,,0,0,0`) {
+		t.Errorf("body = %q", rec.Body.String())
+	}
+}
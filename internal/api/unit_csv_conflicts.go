@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// ListUnitCSVConflicts returns units whose CSV row changed while the database
+// held a different manual edit. ?status=open (default), resolved, or all.
+func (h *AdminHandler) ListUnitCSVConflicts(w http.ResponseWriter, r *http.Request) {
+	p, err := ParsePagination(r)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+	filter := database.UnitCSVConflictFilter{Limit: p.Limit, Offset: p.Offset}
+	if v, ok := QueryInt(r, "system_id"); ok {
+		filter.SystemID = &v
+	}
+	status, _ := QueryString(r, "status")
+	switch status {
+	case "", "open":
+		open := true
+		filter.Open = &open
+	case "resolved":
+		open := false
+		filter.Open = &open
+	case "all":
+	default:
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "status must be open, resolved, or all")
+		return
+	}
+
+	conflicts, total, err := h.db.ListUnitCSVConflicts(r.Context(), filter)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list unit CSV conflicts")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"conflicts": conflicts,
+		"total":     total,
+	})
+}
+
+// ResolveUnitCSVConflict closes a conflict by keeping either the CSV tag or the
+// database's manual tag.
+func (h *AdminHandler) ResolveUnitCSVConflict(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid conflict ID")
+		return
+	}
+	var req struct {
+		Keep string `json:"keep"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	if req.Keep != "csv" && req.Keep != "db" {
+		WriteError(w, http.StatusBadRequest, "keep must be csv or db")
+		return
+	}
+
+	conflict, err := h.db.ResolveUnitCSVConflict(r.Context(), id, req.Keep)
+	if err != nil {
+		switch err.Error() {
+		case "unit CSV conflict not found":
+			WriteError(w, http.StatusNotFound, err.Error())
+		case "unit CSV conflict already resolved":
+			WriteError(w, http.StatusConflict, err.Error())
+		default:
+			WriteError(w, http.StatusInternalServerError, "failed to resolve unit CSV conflict")
+		}
+		return
+	}
+	WriteJSON(w, http.StatusOK, conflict)
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/rs/zerolog/hlog"
+	"github.com/snarg/tr-engine/internal/trconfig"
+)
+
+// UnitImportChange is one unit an import adds or retags.
+type UnitImportChange struct {
+	UnitID          int    `json:"unit_id"`
+	AlphaTag        string `json:"alpha_tag"`
+	CurrentAlphaTag string `json:"current_alpha_tag,omitempty"`
+	CurrentSource   string `json:"current_source,omitempty"`
+	Action          string `json:"action"` // "add" or "update"
+}
+
+// ImportUnits imports a unit roster CSV in TR's unitTagsFile format (or any
+// layout ParseUnitCSV accepts) as manual alpha tags. With dry_run=true it
+// only validates and reports what would change.
+// POST /api/v1/units/import?system_id=1[&dry_run=true]
+// Content-Type: multipart/form-data (field name: "file")
+func (h *UnitsHandler) ImportUnits(w http.ResponseWriter, r *http.Request) {
+	systemID, ok := QueryInt(r, "system_id")
+	if !ok || systemID <= 0 {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "system_id query parameter is required")
+		return
+	}
+	if _, err := h.db.GetSystemByID(r.Context(), systemID); err != nil {
+		WriteError(w, http.StatusNotFound, fmt.Sprintf("system_id %d not found", systemID))
+		return
+	}
+	dryRun, _ := QueryBool(r, "dry_run")
+
+	// 10 MB max upload
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid multipart form (10 MB max)")
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "missing 'file' field in multipart form")
+		return
+	}
+	defer file.Close()
+
+	parsed, err := trconfig.ParseUnitCSVImport(file)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, fmt.Sprintf("failed to parse CSV: %v", err))
+		return
+	}
+	issues := parsed.Issues
+	if issues == nil {
+		issues = []trconfig.UnitCSVIssue{}
+	}
+	if len(parsed.Entries) == 0 && !dryRun {
+		WriteJSON(w, http.StatusBadRequest, map[string]any{
+			"error":  "CSV contains no valid unit entries",
+			"issues": issues,
+		})
+		return
+	}
+
+	current, err := h.db.GetUnitTagStates(r.Context(), systemID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to load current unit tags")
+		return
+	}
+	changes := []UnitImportChange{}
+	tags := make(map[int]string, len(parsed.Entries))
+	added, updated, unchanged := 0, 0, 0
+	for _, e := range parsed.Entries {
+		tags[e.UnitID] = e.AlphaTag
+		cur, ok := current[e.UnitID]
+		switch {
+		case !ok:
+			added++
+			changes = append(changes, UnitImportChange{UnitID: e.UnitID, AlphaTag: e.AlphaTag, Action: "add"})
+		case cur.AlphaTag != e.AlphaTag:
+			updated++
+			changes = append(changes, UnitImportChange{UnitID: e.UnitID, AlphaTag: e.AlphaTag,
+				CurrentAlphaTag: cur.AlphaTag, CurrentSource: cur.Source, Action: "update"})
+		default:
+			unchanged++
+		}
+	}
+
+	resp := map[string]any{
+		"system_id":  systemID,
+		"dry_run":    dryRun,
+		"total":      len(parsed.Entries),
+		"added":      added,
+		"updated":    updated,
+		"unchanged":  unchanged,
+		"duplicates": parsed.Duplicates,
+		"issues":     issues,
+	}
+	if dryRun {
+		resp["changes"] = changes
+		WriteJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	if _, err := h.db.ImportUnitTags(r.Context(), systemID, tags); err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to import units")
+		return
+	}
+	if h.onImport != nil {
+		h.onImport(systemID)
+	}
+
+	// Best-effort writeback to TR's unit tags CSV
+	if csvPath, ok := h.csvPaths[systemID]; ok {
+		n, csvErr := trconfig.UpdateUnitCSVTags(csvPath, tags)
+		if csvErr != nil {
+			hlog.FromRequest(r).Warn().Err(csvErr).Str("csv_path", csvPath).
+				Msg("failed to write back unit CSV")
+		} else {
+			resp["csv_rows_written"] = n
+		}
+	}
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+// ExportUnits returns a system's tagged units as a headerless
+// "unit_id,alpha_tag" CSV that trunk-recorder's unitTagsFile accepts, or as
+// JSON with format=json.
+// GET /api/v1/units/export?system_id=1[&format=csv|json]
+func (h *UnitsHandler) ExportUnits(w http.ResponseWriter, r *http.Request) {
+	systemID, ok := QueryInt(r, "system_id")
+	if !ok || systemID <= 0 {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "system_id query parameter is required")
+		return
+	}
+	format, _ := QueryString(r, "format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "format must be csv or json")
+		return
+	}
+	if _, err := h.db.GetSystemByID(r.Context(), systemID); err != nil {
+		WriteError(w, http.StatusNotFound, fmt.Sprintf("system_id %d not found", systemID))
+		return
+	}
+
+	units, err := h.db.ListUnitTags(r.Context(), systemID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to export units")
+		return
+	}
+	if format == "json" {
+		WriteJSON(w, http.StatusOK, map[string]any{
+			"system_id": systemID,
+			"units":     units,
+			"total":     len(units),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="units-%d.csv"`, systemID))
+	cw := csv.NewWriter(w)
+	for _, u := range units {
+		cw.Write([]string{strconv.Itoa(u.UnitID), u.AlphaTag})
+	}
+	cw.Flush()
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// unitSessionQuerier is the subset of database.DB used by UnitSessionsHandler.
+type unitSessionQuerier interface {
+	ListUnitSessions(ctx context.Context, filter database.UnitSessionFilter) ([]database.UnitSession, int, error)
+	SummarizeUnitSessions(ctx context.Context, filter database.UnitSessionSummaryFilter) ([]database.UnitSessionSummary, error)
+}
+
+type UnitSessionsHandler struct {
+	db unitSessionQuerier
+}
+
+func NewUnitSessionsHandler(db *database.DB) *UnitSessionsHandler {
+	return &UnitSessionsHandler{db: db}
+}
+
+var unitSessionSortFields = map[string]string{
+	"start_time": "us.start_time",
+	"end_time":   "us.end_time",
+	"unit_id":    "us.unit_rid",
+	"tgid":       "us.tgid",
+	"duration":   "(us.end_time - us.start_time)",
+}
+
+const (
+	maxUnitSessionRange        = 31 * 24 * time.Hour
+	maxUnitSessionSummaryRange = 90 * 24 * time.Hour
+)
+
+// ListUnitSessions returns compacted unit sessions overlapping the time range.
+func (h *UnitSessionsHandler) ListUnitSessions(w http.ResponseWriter, r *http.Request) {
+	systemIDs := QueryIntListAliased(r, "system_id", "systems")
+	if len(systemIDs) == 0 {
+		WriteError(w, http.StatusBadRequest, "system_id is required")
+		return
+	}
+
+	p, err := ParsePagination(r)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+	sort := ParseSort(r, "-start_time", unitSessionSortFields)
+
+	filter := database.UnitSessionFilter{
+		SystemIDs: systemIDs,
+		UnitIDs:   QueryIntListAliased(r, "unit_id", "units", "unit_ids"),
+		Tgids:     QueryIntListAliased(r, "tgid", "tgids"),
+		Limit:     p.Limit,
+		Offset:    p.Offset,
+		Sort:      sort.SQLOrderBy(unitSessionSortFields),
+	}
+	if v, ok := QueryBool(r, "open"); ok {
+		filter.Open = &v
+	}
+	if t, ok := QueryTime(r, "start_time"); ok {
+		filter.StartTime = &t
+	}
+	if t, ok := QueryTime(r, "end_time"); ok {
+		filter.EndTime = &t
+	}
+	if msg := ValidateTimeRange(filter.StartTime, filter.EndTime); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+	if filter.StartTime != nil && filter.EndTime != nil && filter.EndTime.Sub(*filter.StartTime) > maxUnitSessionRange {
+		WriteError(w, http.StatusBadRequest, "time range cannot exceed 31 days")
+		return
+	}
+
+	sessions, total, err := h.db.ListUnitSessions(r.Context(), filter)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list unit sessions")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"sessions": sessions,
+		"total":    total,
+		"limit":    p.Limit,
+		"offset":   p.Offset,
+	})
+}
+
+// SummarizeUnitSessions totals unit session time per talkgroup or per unit
+// within a required time range.
+func (h *UnitSessionsHandler) SummarizeUnitSessions(w http.ResponseWriter, r *http.Request) {
+	start, okStart := QueryTime(r, "start_time")
+	end, okEnd := QueryTime(r, "end_time")
+	if !okStart || !okEnd {
+		WriteError(w, http.StatusBadRequest, "start_time and end_time are required")
+		return
+	}
+	if msg := ValidateTimeRange(&start, &end); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+	if end.Sub(start) > maxUnitSessionSummaryRange {
+		WriteError(w, http.StatusBadRequest, "time range cannot exceed 90 days")
+		return
+	}
+
+	groupBy := "tgid"
+	if v, ok := QueryString(r, "group_by"); ok {
+		if v != "tgid" && v != "unit" {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "group_by must be tgid or unit")
+			return
+		}
+		groupBy = v
+	}
+	limit := 100
+	if v, ok := QueryInt(r, "limit"); ok {
+		if v < 1 || v > 1000 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "limit must be between 1 and 1000")
+			return
+		}
+		limit = v
+	}
+
+	summary, err := h.db.SummarizeUnitSessions(r.Context(), database.UnitSessionSummaryFilter{
+		SystemIDs: QueryIntListAliased(r, "system_id", "systems"),
+		UnitIDs:   QueryIntListAliased(r, "unit_id", "units", "unit_ids"),
+		Tgids:     QueryIntListAliased(r, "tgid", "tgids"),
+		StartTime: start,
+		EndTime:   end,
+		GroupBy:   groupBy,
+		Limit:     limit,
+	})
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to summarize unit sessions")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"summary":    summary,
+		"group_by":   groupBy,
+		"start_time": start,
+		"end_time":   end,
+	})
+}
+
+func (h *UnitSessionsHandler) Routes(r chi.Router) {
+	r.Get("/unit-sessions", h.ListUnitSessions)
+	r.Get("/unit-sessions/summary", h.SummarizeUnitSessions)
+}
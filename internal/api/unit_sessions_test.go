@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// mockUnitSessionQuerier implements unitSessionQuerier for testing.
+type mockUnitSessionQuerier struct {
+	filter        database.UnitSessionFilter
+	summaryFilter database.UnitSessionSummaryFilter
+}
+
+func (m *mockUnitSessionQuerier) ListUnitSessions(_ context.Context, filter database.UnitSessionFilter) ([]database.UnitSession, int, error) {
+	m.filter = filter
+	return []database.UnitSession{}, 0, nil
+}
+
+func (m *mockUnitSessionQuerier) SummarizeUnitSessions(_ context.Context, filter database.UnitSessionSummaryFilter) ([]database.UnitSessionSummary, error) {
+	m.summaryFilter = filter
+	return []database.UnitSessionSummary{}, nil
+}
+
+func TestListUnitSessions(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"missing_system", "", http.StatusBadRequest},
+		{"ok", "?system_id=1&unit_id=100&open=true&sort=-duration", http.StatusOK},
+		{"range_too_long", "?system_id=1&start_time=2026-01-01T00:00:00Z&end_time=2026-03-01T00:00:00Z", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockUnitSessionQuerier{}
+			h := &UnitSessionsHandler{db: mock}
+			rec := httptest.NewRecorder()
+			h.ListUnitSessions(rec, httptest.NewRequest("GET", "/unit-sessions"+tt.query, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			if tt.status == http.StatusOK {
+				f := mock.filter
+				if f.Open == nil || !*f.Open || len(f.UnitIDs) != 1 || f.Sort != "(us.end_time - us.start_time) DESC" {
+					t.Errorf("filter = %+v", f)
+				}
+			}
+		})
+	}
+}
+
+func TestSummarizeUnitSessions(t *testing.T) {
+	const rng = "start_time=2026-01-01T00:00:00Z&end_time=2026-01-02T00:00:00Z"
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"missing_range", "?group_by=unit", http.StatusBadRequest},
+		{"bad_group_by", "?group_by=site&" + rng, http.StatusBadRequest},
+		{"bad_limit", "?limit=5000&" + rng, http.StatusBadRequest},
+		{"ok", "?group_by=unit&system_id=1&" + rng, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockUnitSessionQuerier{}
+			h := &UnitSessionsHandler{db: mock}
+			rec := httptest.NewRecorder()
+			h.SummarizeUnitSessions(rec, httptest.NewRequest("GET", "/unit-sessions/summary"+tt.query, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			if tt.status == http.StatusOK && (mock.summaryFilter.GroupBy != "unit" || mock.summaryFilter.Limit != 100) {
+				t.Errorf("filter = %+v", mock.summaryFilter)
+			}
+		})
+	}
+}
@@ -11,11 +11,12 @@ import (
 
 type UnitsHandler struct {
 	db       *database.DB
-	csvPaths map[int]string // system_id → unit CSV file path for writeback
+	csvPaths map[int]string     // system_id → unit CSV file path for writeback
+	onImport func(systemID int) // after a unit import; nil = no-op
 }
 
-func NewUnitsHandler(db *database.DB, csvPaths map[int]string) *UnitsHandler {
-	return &UnitsHandler{db: db, csvPaths: csvPaths}
+func NewUnitsHandler(db *database.DB, csvPaths map[int]string, onImport func(systemID int)) *UnitsHandler {
+	return &UnitsHandler{db: db, csvPaths: csvPaths, onImport: onImport}
 }
 
 var unitSortFields = map[string]string{
@@ -181,6 +182,9 @@ func (h *UnitsHandler) ListUnitCalls(w http.ResponseWriter, r *http.Request) {
 		SystemIDs: []int{cid.SystemID},
 		UnitIDs:   []int{cid.EntityID},
 	}
+	if v, ok := QueryBool(r, "interconnect"); ok {
+		filter.Interconnect = &v
+	}
 	if t, ok := QueryTime(r, "start_time"); ok {
 		filter.StartTime = &t
 	}
@@ -192,6 +196,7 @@ func (h *UnitsHandler) ListUnitCalls(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	filter.Omit = RequestFieldset(r).Omitted(database.OmittableCallFields)
 	calls, total, err := h.db.ListCalls(r.Context(), filter)
 	if err != nil {
 		WriteError(w, http.StatusInternalServerError, "failed to list calls")
@@ -267,11 +272,74 @@ func (h *UnitsHandler) ListUnitEvents(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// ListUnitSessions returns a unit's compacted sessions, most recent first.
+// Sessions outlive raw unit events when RETENTION_UNIT_EVENTS is shorter.
+func (h *UnitsHandler) ListUnitSessions(w http.ResponseWriter, r *http.Request) {
+	cid, err := ParseCompositeID(r, "id")
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+
+	if cid.IsPlain {
+		matches, err := h.db.FindUnitSystems(r.Context(), cid.EntityID)
+		if err != nil || len(matches) == 0 {
+			WriteError(w, http.StatusNotFound, "unit not found")
+			return
+		}
+		if len(matches) > 1 {
+			WriteAmbiguous(w, cid.EntityID, matches)
+			return
+		}
+		cid.SystemID = matches[0].SystemID
+	}
+
+	p, err := ParsePagination(r)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+	filter := database.UnitSessionFilter{
+		SystemIDs: []int{cid.SystemID},
+		UnitIDs:   []int{cid.EntityID},
+		Limit:     p.Limit,
+		Offset:    p.Offset,
+	}
+	if v, ok := QueryInt(r, "talkgroup"); ok {
+		filter.Tgids = []int{v}
+	}
+	if t, ok := QueryTime(r, "start_time"); ok {
+		filter.StartTime = &t
+	}
+	if t, ok := QueryTime(r, "end_time"); ok {
+		filter.EndTime = &t
+	}
+	if msg := ValidateTimeRange(filter.StartTime, filter.EndTime); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+
+	sessions, total, err := h.db.ListUnitSessions(r.Context(), filter)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list sessions")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"sessions": sessions,
+		"total":    total,
+		"limit":    p.Limit,
+		"offset":   p.Offset,
+	})
+}
+
 // Routes registers unit routes on the given router.
 func (h *UnitsHandler) Routes(r chi.Router) {
 	r.Get("/units", h.ListUnits)
+	r.Get("/units/export", h.ExportUnits)
+	r.Post("/units/import", h.ImportUnits)
 	r.Get("/units/{id}", h.GetUnit)
 	r.Patch("/units/{id}", h.UpdateUnit)
 	r.Get("/units/{id}/calls", h.ListUnitCalls)
 	r.Get("/units/{id}/events", h.ListUnitEvents)
+	r.Get("/units/{id}/sessions", h.ListUnitSessions)
 }
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"encoding/json"
 	"io"
 	"net/http"
 	"path/filepath"
@@ -9,7 +10,8 @@ import (
 	"github.com/rs/zerolog"
 )
 
-// UploadHandler handles HTTP call uploads compatible with rdio-scanner and OpenMHz.
+// UploadHandler handles HTTP call uploads compatible with rdio-scanner and OpenMHz,
+// plus bare audio files whose metadata is derived from the filename.
 type UploadHandler struct {
 	uploader   CallUploader
 	instanceID string
@@ -27,7 +29,9 @@ func NewUploadHandler(uploader CallUploader, instanceID string, log zerolog.Logg
 
 
 // Upload handles POST /api/v1/call-upload.
-// Accepts multipart form uploads in rdio-scanner or OpenMHz format.
+// Accepts multipart form uploads in rdio-scanner or OpenMHz format, or a bare
+// audio file in a "file" field (talkgroup and start time parsed from the
+// filename via FILENAME_PATTERNS; optional "system" field).
 // Auto-detects the format from form field names.
 func (h *UploadHandler) Upload(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseMultipartForm(32 << 20); err != nil {
@@ -47,7 +51,7 @@ func (h *UploadHandler) Upload(w http.ResponseWriter, r *http.Request) {
 
 	format := detectUploadFormat(fieldNames)
 	if format == "" {
-		WriteErrorWithCode(w, http.StatusBadRequest, ErrBadRequest, "unrecognized upload format: expected rdio-scanner or OpenMHz fields")
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrBadRequest, "unrecognized upload format: expected rdio-scanner, OpenMHz, issi or file fields")
 		return
 	}
 
@@ -63,8 +67,11 @@ func (h *UploadHandler) Upload(w http.ResponseWriter, r *http.Request) {
 	var audioData []byte
 	var audioFilename string
 	audioFieldName := "audio" // rdio-scanner
-	if format == "openmhz" {
+	switch format {
+	case "openmhz":
 		audioFieldName = "call"
+	case "filename":
+		audioFieldName = "file"
 	}
 
 	if file, header, err := r.FormFile(audioFieldName); err == nil {
@@ -76,13 +83,35 @@ func (h *UploadHandler) Upload(w http.ResponseWriter, r *http.Request) {
 		}
 		audioData = data
 		audioFilename = header.Filename
+	}
+
+	h.ingest(w, r, format, fields, audioData, audioFilename)
+}
+
+// UploadISSI handles POST /api/v1/call-upload/issi: one ISSI/DFSI gateway
+// call record as a JSON body (metadata only; send multipart with an "issi"
+// field and an "audio" file to /call-upload to include audio).
+func (h *UploadHandler) UploadISSI(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "failed to read body")
+		return
+	}
+	if !json.Valid(body) {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid JSON body")
+		return
+	}
+	h.ingest(w, r, "issi", map[string]string{"issi": string(body)}, nil, "")
+}
 
-		// Infer audio type from filename if not set in form fields
-		if fields["audioType"] == "" && fields["audio_type"] == "" {
-			ext := strings.TrimPrefix(filepath.Ext(audioFilename), ".")
-			if ext != "" {
-				fields["audioType"] = ext
-			}
+// ingest runs an upload through the pipeline and writes the response. Shared
+// by single-request and resumable uploads.
+func (h *UploadHandler) ingest(w http.ResponseWriter, r *http.Request, format string, fields map[string]string, audioData []byte, audioFilename string) {
+	// Infer audio type from filename if not set in form fields
+	if audioFilename != "" && fields["audioType"] == "" && fields["audio_type"] == "" {
+		ext := strings.TrimPrefix(filepath.Ext(audioFilename), ".")
+		if ext != "" {
+			fields["audioType"] = ext
 		}
 	}
 
@@ -93,6 +122,20 @@ func (h *UploadHandler) Upload(w http.ResponseWriter, r *http.Request) {
 			WriteErrorWithCode(w, http.StatusConflict, ErrDuplicate, err.Error())
 			return
 		}
+		if strings.Contains(err.Error(), "does not match FILENAME_PATTERNS") ||
+			strings.Contains(err.Error(), "parse issi fields") {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+			return
+		}
+		if strings.Contains(err.Error(), "suppressed by encryption policy") {
+			WriteErrorWithCode(w, http.StatusForbidden, ErrForbidden, err.Error())
+			return
+		}
+		if strings.Contains(err.Error(), "identity pending approval") {
+			// INGEST_AUTO_CREATE=deny and this system isn't approved for the upload instance
+			WriteErrorWithCode(w, http.StatusForbidden, ErrForbidden, err.Error())
+			return
+		}
 		h.log.Error().Err(err).Str("format", format).Msg("upload processing failed")
 		WriteError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -102,13 +145,18 @@ func (h *UploadHandler) Upload(w http.ResponseWriter, r *http.Request) {
 }
 
 // detectUploadFormat inspects form field names to determine the upload format.
-// Returns "rdio-scanner", "openmhz", or "" if unknown.
+// Returns "issi", "rdio-scanner", "openmhz", "filename", or "" if unknown.
 func detectUploadFormat(fieldNames []string) string {
 	set := make(map[string]bool, len(fieldNames))
 	for _, name := range fieldNames {
 		set[name] = true
 	}
 
+	// ISSI/DFSI gateway record, optionally with an "audio" file
+	if set["issi"] {
+		return "issi"
+	}
+
 	// rdio-scanner indicators: "audio" file field, "audioName", "systemLabel"
 	if set["audio"] || set["audioName"] || set["systemLabel"] {
 		return "rdio-scanner"
@@ -119,5 +167,10 @@ func detectUploadFormat(fieldNames []string) string {
 		return "openmhz"
 	}
 
+	// Bare audio file: metadata comes from the filename
+	if set["file"] {
+		return "filename"
+	}
+
 	return ""
 }
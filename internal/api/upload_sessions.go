@@ -0,0 +1,446 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Resumable call uploads for large recordings on unreliable links.
+//
+// A client creates a session with the call's metadata (the same fields as a
+// multipart call-upload), the file size and optionally its SHA-256, then
+// PATCHes the audio in chunks, each at the offset the server reports. After
+// a dropped connection it asks for the offset (HEAD or GET) and continues
+// from there; bytes received before the drop are kept unless the chunk
+// carried an Upload-Checksum. Finalize verifies the size and checksum and
+// ingests the assembled file exactly like a single-request upload.
+//
+// Sessions live on disk (<dir>/<id>.json and <id>.part) so they survive a
+// restart, and are removed after finalize, DELETE or ttl of inactivity.
+
+// UploadSessionsHandler serves /api/v1/call-upload/sessions.
+type UploadSessionsHandler struct {
+	upload  *UploadHandler
+	dir     string
+	ttl     time.Duration
+	maxSize int64
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex // per-session, serializes chunk writes
+}
+
+// uploadSession is a session's metadata file.
+type uploadSession struct {
+	ID        string            `json:"session_id"`
+	Format    string            `json:"format"`
+	Fields    map[string]string `json:"fields"`
+	Filename  string            `json:"filename"`
+	Size      int64             `json:"size"`
+	SHA256    string            `json:"sha256,omitempty"`
+	Offset    int64             `json:"offset"`
+	CreatedAt time.Time         `json:"created_at"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+var uploadSessionIDRe = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// NewUploadSessionsHandler creates the resumable upload handler, storing
+// sessions in dir (created if missing). Expired sessions are swept now and
+// whenever a session is created.
+func NewUploadSessionsHandler(upload *UploadHandler, dir string, ttl time.Duration, maxSize int64) (*UploadSessionsHandler, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create upload session dir: %w", err)
+	}
+	h := &UploadSessionsHandler{
+		upload:  upload,
+		dir:     dir,
+		ttl:     ttl,
+		maxSize: maxSize,
+		locks:   make(map[string]*sync.Mutex),
+	}
+	h.sweep(time.Now())
+	return h, nil
+}
+
+func (h *UploadSessionsHandler) metaPath(id string) string { return filepath.Join(h.dir, id+".json") }
+func (h *UploadSessionsHandler) partPath(id string) string { return filepath.Join(h.dir, id+".part") }
+
+// lock serializes operations on one session.
+func (h *UploadSessionsHandler) lock(id string) func() {
+	h.mu.Lock()
+	l, ok := h.locks[id]
+	if !ok {
+		l = &sync.Mutex{}
+		h.locks[id] = l
+	}
+	h.mu.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+func (h *UploadSessionsHandler) load(id string) (*uploadSession, error) {
+	if !uploadSessionIDRe.MatchString(id) {
+		return nil, os.ErrNotExist
+	}
+	data, err := os.ReadFile(h.metaPath(id))
+	if err != nil {
+		return nil, err
+	}
+	var s uploadSession
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if time.Now().After(s.ExpiresAt) {
+		h.remove(id)
+		return nil, os.ErrNotExist
+	}
+	// The part file is the source of truth for how much arrived.
+	st, err := os.Stat(h.partPath(id))
+	if err != nil {
+		return nil, err
+	}
+	s.Offset = st.Size()
+	return &s, nil
+}
+
+func (h *UploadSessionsHandler) save(s *uploadSession) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := h.metaPath(s.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.metaPath(s.ID))
+}
+
+func (h *UploadSessionsHandler) remove(id string) {
+	os.Remove(h.metaPath(id))
+	os.Remove(h.partPath(id))
+	h.mu.Lock()
+	delete(h.locks, id)
+	h.mu.Unlock()
+}
+
+// sweep removes sessions that expired before now.
+func (h *UploadSessionsHandler) sweep(now time.Time) {
+	metas, _ := filepath.Glob(filepath.Join(h.dir, "*.json"))
+	for _, m := range metas {
+		id := strings.TrimSuffix(filepath.Base(m), ".json")
+		data, err := os.ReadFile(m)
+		if err != nil {
+			continue
+		}
+		var s uploadSession
+		if json.Unmarshal(data, &s) != nil || now.After(s.ExpiresAt) {
+			h.remove(id)
+		}
+	}
+}
+
+// loadOr404 loads a session for the {id} path parameter, writing a 404 if
+// it doesn't exist.
+func (h *UploadSessionsHandler) loadOr404(w http.ResponseWriter, r *http.Request) (*uploadSession, bool) {
+	s, err := h.load(chi.URLParam(r, "id"))
+	if err != nil {
+		WriteError(w, http.StatusNotFound, "upload session not found")
+		return nil, false
+	}
+	return s, true
+}
+
+func (h *UploadSessionsHandler) setOffsetHeaders(w http.ResponseWriter, s *uploadSession) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(s.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(s.Size, 10))
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// CreateSession starts a resumable upload.
+// Body: {"fields": {...}, "filename": "...", "size": N, "sha256": "hex", "format": "..."}.
+// fields are the multipart call-upload fields; format is detected from them
+// when omitted ("filename" when none match).
+func (h *UploadSessionsHandler) CreateSession(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Format   string            `json:"format"`
+		Fields   map[string]string `json:"fields"`
+		Filename string            `json:"filename"`
+		Size     int64             `json:"size"`
+		SHA256   string            `json:"sha256"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	if req.Size <= 0 || req.Size > h.maxSize {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter,
+			fmt.Sprintf("size must be between 1 and %d bytes", h.maxSize))
+		return
+	}
+	if req.Filename == "" || strings.ContainsAny(req.Filename, `/\`) {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "filename is required and may not contain a path")
+		return
+	}
+	req.SHA256 = strings.ToLower(req.SHA256)
+	if req.SHA256 != "" {
+		if b, err := hex.DecodeString(req.SHA256); err != nil || len(b) != sha256.Size {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "sha256 must be 64 hex characters")
+			return
+		}
+	}
+	if req.Fields == nil {
+		req.Fields = map[string]string{}
+	}
+	switch req.Format {
+	case "":
+		names := make([]string, 0, len(req.Fields))
+		for k := range req.Fields {
+			names = append(names, k)
+		}
+		if req.Format = detectUploadFormat(names); req.Format == "" {
+			req.Format = "filename"
+		}
+	case "rdio-scanner", "openmhz", "filename":
+	default:
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "format must be rdio-scanner, openmhz or filename")
+		return
+	}
+
+	h.sweep(time.Now())
+
+	var raw [16]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to create upload session")
+		return
+	}
+	now := time.Now().UTC()
+	s := &uploadSession{
+		ID:        hex.EncodeToString(raw[:]),
+		Format:    req.Format,
+		Fields:    req.Fields,
+		Filename:  req.Filename,
+		Size:      req.Size,
+		SHA256:    req.SHA256,
+		CreatedAt: now,
+		ExpiresAt: now.Add(h.ttl),
+	}
+	f, err := os.OpenFile(h.partPath(s.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to create upload session")
+		return
+	}
+	f.Close()
+	if err := h.save(s); err != nil {
+		h.remove(s.ID)
+		WriteError(w, http.StatusInternalServerError, "failed to create upload session")
+		return
+	}
+	h.upload.log.Info().Str("session_id", s.ID).Str("filename", s.Filename).Int64("size", s.Size).
+		Msg("resumable upload started")
+	h.setOffsetHeaders(w, s)
+	w.Header().Set("Location", "/api/v1/call-upload/sessions/"+s.ID)
+	WriteJSON(w, http.StatusCreated, s)
+}
+
+// GetSession reports a session's offset (in the body and the Upload-Offset
+// header; HEAD returns headers only).
+func (h *UploadSessionsHandler) GetSession(w http.ResponseWriter, r *http.Request) {
+	s, ok := h.loadOr404(w, r)
+	if !ok {
+		return
+	}
+	h.setOffsetHeaders(w, s)
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	WriteJSON(w, http.StatusOK, s)
+}
+
+// parseUploadChecksum parses an Upload-Checksum header ("sha256 <base64>").
+func parseUploadChecksum(v string) ([]byte, error) {
+	algo, sum, ok := strings.Cut(strings.TrimSpace(v), " ")
+	if !ok || !strings.EqualFold(algo, "sha256") {
+		return nil, errors.New("Upload-Checksum must be \"sha256 <base64 digest>\"")
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(sum))
+	if err != nil || len(b) != sha256.Size {
+		return nil, errors.New("Upload-Checksum digest must be a base64 SHA-256")
+	}
+	return b, nil
+}
+
+// PatchSession appends a chunk. The Upload-Offset header must equal the
+// session's current offset; an optional Upload-Checksum ("sha256 <base64>")
+// is verified, and a chunk that fails it is discarded.
+func (h *UploadSessionsHandler) PatchSession(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	defer h.lock(id)()
+	s, ok := h.loadOr404(w, r)
+	if !ok {
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "Upload-Offset header is required")
+		return
+	}
+	if offset != s.Offset {
+		h.setOffsetHeaders(w, s)
+		WriteErrorWithCode(w, http.StatusConflict, ErrConflict,
+			fmt.Sprintf("Upload-Offset %d does not match session offset %d", offset, s.Offset))
+		return
+	}
+	var want []byte
+	if v := r.Header.Get("Upload-Checksum"); v != "" {
+		if want, err = parseUploadChecksum(v); err != nil {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+			return
+		}
+	}
+
+	f, err := os.OpenFile(h.partPath(s.ID), os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to open upload session")
+		return
+	}
+	hash := sha256.New()
+	remaining := s.Size - s.Offset
+	n, copyErr := io.Copy(io.MultiWriter(f, hash), io.LimitReader(r.Body, remaining+1))
+	if n > remaining {
+		f.Truncate(s.Offset)
+		f.Close()
+		h.setOffsetHeaders(w, s)
+		WriteErrorWithCode(w, http.StatusRequestEntityTooLarge, ErrInvalidBody, "chunk extends past the declared size")
+		return
+	}
+	if want != nil && (copyErr != nil || !bytes.Equal(hash.Sum(nil), want)) {
+		f.Truncate(s.Offset)
+		f.Close()
+		h.setOffsetHeaders(w, s)
+		if copyErr != nil {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "chunk interrupted; resend it")
+			return
+		}
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "chunk checksum mismatch; resend it")
+		return
+	}
+	if err := f.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+
+	// Without a checksum, whatever arrived before an interruption is kept.
+	s.Offset += n
+	s.ExpiresAt = time.Now().UTC().Add(h.ttl)
+	if err := h.save(s); err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to update upload session")
+		return
+	}
+	h.setOffsetHeaders(w, s)
+	if copyErr != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "chunk interrupted; resume from Upload-Offset")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// FinalizeSession verifies the assembled file and ingests it like a
+// single-request upload. A size or checksum mismatch leaves the session in
+// place (size) or deletes it (checksum: the data is corrupt, start over).
+func (h *UploadSessionsHandler) FinalizeSession(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	defer h.lock(id)()
+	s, ok := h.loadOr404(w, r)
+	if !ok {
+		return
+	}
+	if s.Offset != s.Size {
+		h.setOffsetHeaders(w, s)
+		WriteErrorWithCode(w, http.StatusConflict, ErrConflict,
+			fmt.Sprintf("upload incomplete: %d of %d bytes received", s.Offset, s.Size))
+		return
+	}
+
+	data, err := os.ReadFile(h.partPath(s.ID))
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to read upload")
+		return
+	}
+	sum := sha256.Sum256(data)
+	if s.SHA256 != "" && hex.EncodeToString(sum[:]) != s.SHA256 {
+		h.remove(s.ID)
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody,
+			"sha256 of the assembled file does not match; session deleted, upload again")
+		return
+	}
+
+	fields := make(map[string]string, len(s.Fields))
+	for k, v := range s.Fields {
+		fields[k] = v
+	}
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	h.upload.ingest(rec, r, s.Format, fields, data, s.Filename)
+	// Keep the session for a retry after a server-side failure; anything
+	// else (created, duplicate, rejected) is final.
+	if rec.status < http.StatusInternalServerError {
+		h.remove(s.ID)
+	}
+}
+
+// DeleteSession aborts an upload.
+func (h *UploadSessionsHandler) DeleteSession(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	defer h.lock(id)()
+	if _, ok := h.loadOr404(w, r); !ok {
+		return
+	}
+	h.remove(id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListSessions returns the open sessions, newest first.
+func (h *UploadSessionsHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	metas, _ := filepath.Glob(filepath.Join(h.dir, "*.json"))
+	sessions := []*uploadSession{}
+	for _, m := range metas {
+		if s, err := h.load(strings.TrimSuffix(filepath.Base(m), ".json")); err == nil {
+			sessions = append(sessions, s)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.After(sessions[j].CreatedAt) })
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"sessions": sessions,
+		"total":    len(sessions),
+	})
+}
+
+// Routes registers the session routes; mount inside the call-upload group so
+// they share its auth and body limit.
+func (h *UploadSessionsHandler) Routes(r chi.Router) {
+	r.Get("/api/v1/call-upload/sessions", h.ListSessions)
+	r.Post("/api/v1/call-upload/sessions", h.CreateSession)
+	r.Get("/api/v1/call-upload/sessions/{id}", h.GetSession)
+	r.Head("/api/v1/call-upload/sessions/{id}", h.GetSession)
+	r.Patch("/api/v1/call-upload/sessions/{id}", h.PatchSession)
+	r.Post("/api/v1/call-upload/sessions/{id}/finalize", h.FinalizeSession)
+	r.Delete("/api/v1/call-upload/sessions/{id}", h.DeleteSession)
+}
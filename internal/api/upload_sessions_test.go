@@ -0,0 +1,199 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func newTestUploadSessions(t *testing.T, mock *mockCallUploader) (http.Handler, string) {
+	t.Helper()
+	dir := t.TempDir()
+	h, err := NewUploadSessionsHandler(newTestUploadHandler(mock), dir, time.Hour, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := chi.NewRouter()
+	h.Routes(r)
+	return r, dir
+}
+
+func createUploadSession(t *testing.T, srv http.Handler, body string) uploadSession {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/api/v1/call-upload/sessions", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body.String())
+	}
+	var s uploadSession
+	if err := json.Unmarshal(w.Body.Bytes(), &s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func patchUploadSession(srv http.Handler, id string, offset int, chunk []byte, checksum string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("PATCH", "/api/v1/call-upload/sessions/"+id, bytes.NewReader(chunk))
+	req.Header.Set("Upload-Offset", strconv.Itoa(offset))
+	if checksum != "" {
+		req.Header.Set("Upload-Checksum", checksum)
+	}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	return w
+}
+
+func chunkChecksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256 " + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestUploadSessions_ChunkedUpload(t *testing.T) {
+	mock := &mockCallUploader{}
+	srv, dir := newTestUploadSessions(t, mock)
+
+	audio := make([]byte, 1000)
+	for i := range audio {
+		audio[i] = byte(i * 7 / 3)
+	}
+	sum := sha256.Sum256(audio)
+	s := createUploadSession(t, srv, fmt.Sprintf(
+		`{"fields":{"talkgroup":"9044","dateTime":"1708881234","systemLabel":"butco"},"filename":"call.m4a","size":%d,"sha256":"%s"}`,
+		len(audio), hex.EncodeToString(sum[:])))
+	if s.Format != "rdio-scanner" || s.Offset != 0 {
+		t.Fatalf("session = %+v", s)
+	}
+
+	// First chunk, then a stale offset is rejected with the current one.
+	if w := patchUploadSession(srv, s.ID, 0, audio[:400], chunkChecksum(audio[:400])); w.Code != http.StatusNoContent {
+		t.Fatalf("patch 1: status %d: %s", w.Code, w.Body.String())
+	}
+	w := patchUploadSession(srv, s.ID, 0, audio[:400], "")
+	if w.Code != http.StatusConflict || w.Header().Get("Upload-Offset") != "400" {
+		t.Fatalf("stale offset: status %d, offset %q", w.Code, w.Header().Get("Upload-Offset"))
+	}
+
+	// A chunk failing its checksum is discarded.
+	w = patchUploadSession(srv, s.ID, 400, audio[400:800], chunkChecksum(audio[:400]))
+	if w.Code != http.StatusBadRequest || w.Header().Get("Upload-Offset") != "400" {
+		t.Fatalf("bad checksum: status %d, offset %q", w.Code, w.Header().Get("Upload-Offset"))
+	}
+
+	// Finalizing early is refused.
+	req := httptest.NewRequest("POST", "/api/v1/call-upload/sessions/"+s.ID+"/finalize", nil)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("early finalize: status %d", w.Code)
+	}
+
+	// Resume from the reported offset.
+	req = httptest.NewRequest("HEAD", "/api/v1/call-upload/sessions/"+s.ID, nil)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	off, _ := strconv.Atoi(w.Header().Get("Upload-Offset"))
+	if off != 400 {
+		t.Fatalf("HEAD offset = %d, want 400", off)
+	}
+	if w := patchUploadSession(srv, s.ID, off, audio[off:], ""); w.Code != http.StatusNoContent {
+		t.Fatalf("patch 2: status %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/api/v1/call-upload/sessions/"+s.ID+"/finalize", nil)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("finalize: status %d: %s", w.Code, w.Body.String())
+	}
+	if mock.lastFormat != "rdio-scanner" || mock.lastAudioLen != len(audio) || mock.lastFilename != "call.m4a" {
+		t.Errorf("ingested format=%q len=%d filename=%q", mock.lastFormat, mock.lastAudioLen, mock.lastFilename)
+	}
+	if mock.lastFields["audioType"] != "m4a" {
+		t.Errorf("audioType = %q, want m4a", mock.lastFields["audioType"])
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("session files left after finalize: %d", len(entries))
+	}
+}
+
+func TestUploadSessions_ChecksumMismatch(t *testing.T) {
+	mock := &mockCallUploader{}
+	srv, _ := newTestUploadSessions(t, mock)
+
+	s := createUploadSession(t, srv, `{"fields":{"file":""},"filename":"9044-1708881234.wav","size":4,"sha256":"`+
+		strings.Repeat("0", 64)+`"}`)
+	if w := patchUploadSession(srv, s.ID, 0, []byte("abcd"), ""); w.Code != http.StatusNoContent {
+		t.Fatalf("patch: status %d", w.Code)
+	}
+	req := httptest.NewRequest("POST", "/api/v1/call-upload/sessions/"+s.ID+"/finalize", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("finalize: status %d, want 400", w.Code)
+	}
+	if mock.lastAudioLen != 0 {
+		t.Error("corrupt upload was ingested")
+	}
+	req = httptest.NewRequest("GET", "/api/v1/call-upload/sessions/"+s.ID, nil)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("session after checksum mismatch: status %d, want 404", w.Code)
+	}
+}
+
+func TestUploadSessions_Validation(t *testing.T) {
+	srv, _ := newTestUploadSessions(t, &mockCallUploader{})
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"zero size", `{"filename":"a.wav","size":0}`},
+		{"too large", `{"filename":"a.wav","size":2097152}`},
+		{"no filename", `{"size":10}`},
+		{"path in filename", `{"filename":"../a.wav","size":10}`},
+		{"bad sha256", `{"filename":"a.wav","size":10,"sha256":"xyz"}`},
+		{"bad format", `{"filename":"a.wav","size":10,"format":"zip"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/v1/call-upload/sessions", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			srv.ServeHTTP(w, req)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status %d, want 400", w.Code)
+			}
+		})
+	}
+
+	s := createUploadSession(t, srv, `{"filename":"a.wav","size":4}`)
+	if s.Format != "filename" {
+		t.Errorf("default format = %q, want filename", s.Format)
+	}
+	if w := patchUploadSession(srv, s.ID, 0, []byte("abcdef"), ""); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized chunk: status %d, want 413", w.Code)
+	}
+	req := httptest.NewRequest("DELETE", "/api/v1/call-upload/sessions/"+s.ID, nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("delete: status %d", w.Code)
+	}
+	if w := patchUploadSession(srv, "not-a-session", 0, []byte("a"), ""); w.Code != http.StatusNotFound {
+		t.Errorf("unknown session: status %d, want 404", w.Code)
+	}
+}
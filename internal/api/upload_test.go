@@ -140,6 +140,41 @@ func TestUpload_OpenMHz_Success(t *testing.T) {
 	}
 }
 
+func TestUpload_Filename(t *testing.T) {
+	mock := &mockCallUploader{}
+	handler := newTestUploadHandler(mock)
+
+	body, ct := buildMultipartForm(t, map[string]string{"system": "butco"},
+		"file", []byte("fake-audio-data"), "9044-1708881234_859262500.0-call_1.wav")
+
+	req := httptest.NewRequest("POST", "/api/v1/call-upload", body)
+	req.Header.Set("Content-Type", ct)
+	rec := httptest.NewRecorder()
+
+	handler.Upload(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d; body = %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+	if mock.lastFormat != "filename" {
+		t.Errorf("format = %q, want %q", mock.lastFormat, "filename")
+	}
+	if mock.lastFilename != "9044-1708881234_859262500.0-call_1.wav" || mock.lastFields["system"] != "butco" {
+		t.Errorf("filename = %q, system = %q", mock.lastFilename, mock.lastFields["system"])
+	}
+
+	// A name no pattern matches is the client's problem.
+	mock.err = fmt.Errorf(`parse filename fields: filename "x.wav" does not match FILENAME_PATTERNS`)
+	body, ct = buildMultipartForm(t, nil, "file", []byte("fake-audio-data"), "x.wav")
+	req = httptest.NewRequest("POST", "/api/v1/call-upload", body)
+	req.Header.Set("Content-Type", ct)
+	rec = httptest.NewRecorder()
+	handler.Upload(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unmatched filename: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
 func TestUpload_UnknownFormat(t *testing.T) {
 	mock := &mockCallUploader{}
 	handler := newTestUploadHandler(mock)
@@ -289,6 +324,8 @@ func TestDetectUploadFormat(t *testing.T) {
 		{"rdio-scanner by systemLabel", []string{"systemLabel", "talkgroup"}, "rdio-scanner"},
 		{"openmhz by call field", []string{"call", "talkgroup_num", "freq"}, "openmhz"},
 		{"openmhz by talkgroup_num", []string{"talkgroup_num", "start_time"}, "openmhz"},
+		{"filename by file field", []string{"file", "system"}, "filename"},
+		{"issi record with audio", []string{"issi", "audio"}, "issi"},
 		{"unknown format", []string{"foo", "bar"}, ""},
 		{"empty fields", []string{}, ""},
 	}
@@ -302,3 +339,34 @@ func TestDetectUploadFormat(t *testing.T) {
 		})
 	}
 }
+
+func TestUploadISSI(t *testing.T) {
+	mock := &mockCallUploader{}
+	h := newTestUploadHandler(mock)
+
+	body := `{"sgid":"BEE003481234","start_time":"2026-03-01T12:00:00Z"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/call-upload/issi", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	h.UploadISSI(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201; body: %s", w.Code, w.Body.String())
+	}
+	if mock.lastFormat != "issi" || mock.lastFields["issi"] != body || mock.lastAudioLen != 0 {
+		t.Errorf("format %q fields %v audio %d", mock.lastFormat, mock.lastFields, mock.lastAudioLen)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/call-upload/issi", bytes.NewBufferString(`{"sgid":`))
+	w = httptest.NewRecorder()
+	h.UploadISSI(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid JSON: status = %d, want 400", w.Code)
+	}
+
+	mock.err = fmt.Errorf("parse issi fields: group_id or sgid is required")
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/call-upload/issi", bytes.NewBufferString(`{}`))
+	w = httptest.NewRecorder()
+	h.UploadISSI(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unmappable record: status = %d, want 400", w.Code)
+	}
+}
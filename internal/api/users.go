@@ -0,0 +1,266 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+var usernameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]{0,63}$`)
+
+// validatePassword checks a new password. bcrypt ignores bytes past 72, so
+// longer passwords are refused rather than silently truncated. Returns "" if
+// valid.
+func validatePassword(p string) string {
+	if len(p) < 8 || len(p) > 72 {
+		return "password must be 8 to 72 bytes"
+	}
+	return ""
+}
+
+// UsersHandler creates, changes and removes web UI accounts. Every route
+// needs admin access, reads included, since AUTH_TOKEN may read /admin.
+type UsersHandler struct {
+	db       *database.DB
+	sessions *Sessions
+}
+
+func NewUsersHandler(db *database.DB, sessions *Sessions) *UsersHandler {
+	return &UsersHandler{db: db, sessions: sessions}
+}
+
+func (h *UsersHandler) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if !isAdmin(r) {
+		WriteErrorWithCode(w, http.StatusForbidden, ErrForbidden, "users are managed by admins")
+		return false
+	}
+	return true
+}
+
+// ListUsers returns every user by username.
+func (h *UsersHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	users, err := h.db.ListUsers(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list users")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"users": users,
+		"total": len(users),
+	})
+}
+
+// CreateUser adds a user with a username, password, role and optional
+// display name. actor is required unless an admin user is signed in.
+func (h *UsersHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	var req struct {
+		Username    string `json:"username"`
+		Password    string `json:"password"`
+		DisplayName string `json:"display_name"`
+		Role        string `json:"role"`
+		Actor       string `json:"actor"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	user := database.User{
+		Username:    strings.TrimSpace(req.Username),
+		DisplayName: strings.TrimSpace(req.DisplayName),
+		Role:        req.Role,
+		CreatedBy:   strings.TrimSpace(req.Actor),
+	}
+	if me := userFrom(r); me != nil && user.CreatedBy == "" {
+		user.CreatedBy = me.Username
+	}
+	if user.CreatedBy == "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "actor is required")
+		return
+	}
+	if !usernameRe.MatchString(user.Username) {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody,
+			"username must be 1 to 64 letters, digits, '.', '_', '@' or '-'")
+		return
+	}
+	if len(user.DisplayName) > 100 {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "display_name must be at most 100 characters")
+		return
+	}
+	if !database.ValidUserRole(user.Role) {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "role must be read, write or admin")
+		return
+	}
+	if msg := validatePassword(req.Password); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, msg)
+		return
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to create user")
+		return
+	}
+	user.PasswordHash = string(hash)
+	if err := h.db.CreateUser(r.Context(), &user); err != nil {
+		h.writeUserError(w, err, "failed to create user")
+		return
+	}
+	WriteJSON(w, http.StatusCreated, user)
+}
+
+// GetUser returns a user.
+func (h *UsersHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+	user, err := h.db.GetUser(r.Context(), id)
+	if err != nil {
+		h.writeUserError(w, err, "failed to get user")
+		return
+	}
+	WriteJSON(w, http.StatusOK, user)
+}
+
+// UpdateUser changes a user's display name, role or password, or disables
+// it. A new password or disabling signs the user out everywhere. Admins
+// signed in as users can't take away their own admin access.
+func (h *UsersHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+	var req struct {
+		DisplayName *string `json:"display_name"`
+		Role        *string `json:"role"`
+		Password    *string `json:"password"`
+		Disabled    *bool   `json:"disabled"`
+	}
+	if err := DecodeJSON(r, &req); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return
+	}
+	u := database.UserUpdate{Role: req.Role, Disabled: req.Disabled}
+	if req.DisplayName != nil {
+		name := strings.TrimSpace(*req.DisplayName)
+		if len(name) > 100 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "display_name must be at most 100 characters")
+			return
+		}
+		u.DisplayName = &name
+	}
+	if u.Role != nil && !database.ValidUserRole(*u.Role) {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "role must be read, write or admin")
+		return
+	}
+	if me := userFrom(r); me != nil && me.ID == id &&
+		((u.Role != nil && *u.Role != database.UserRoleAdmin) || (u.Disabled != nil && *u.Disabled)) {
+		WriteError(w, http.StatusConflict, "can't remove your own admin access")
+		return
+	}
+	if req.Password != nil {
+		if msg := validatePassword(*req.Password); msg != "" {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, msg)
+			return
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(*req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "failed to update user")
+			return
+		}
+		s := string(hash)
+		u.PasswordHash = &s
+	}
+
+	user, err := h.db.UpdateUser(r.Context(), id, u)
+	if err != nil {
+		h.writeUserError(w, err, "failed to update user")
+		return
+	}
+	h.sessions.ForgetUser(id)
+	WriteJSON(w, http.StatusOK, user)
+}
+
+// DeleteUser removes a user and ends its sessions. Admins signed in as
+// users can't remove themselves.
+func (h *UsersHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+	if me := userFrom(r); me != nil && me.ID == id {
+		WriteError(w, http.StatusConflict, "can't remove your own account")
+		return
+	}
+	if err := h.db.DeleteUser(r.Context(), id); err != nil {
+		h.writeUserError(w, err, "failed to delete user")
+		return
+	}
+	h.sessions.ForgetUser(id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// EndUserSessions signs a user out everywhere.
+func (h *UsersHandler) EndUserSessions(w http.ResponseWriter, r *http.Request) {
+	if !h.requireAdmin(w, r) {
+		return
+	}
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid user ID")
+		return
+	}
+	if _, err := h.db.GetUser(r.Context(), id); err != nil {
+		h.writeUserError(w, err, "failed to end sessions")
+		return
+	}
+	n, err := h.db.DeleteUserSessions(r.Context(), id)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to end sessions")
+		return
+	}
+	h.sessions.ForgetUser(id)
+	WriteJSON(w, http.StatusOK, map[string]any{"ended": n})
+}
+
+func (h *UsersHandler) writeUserError(w http.ResponseWriter, err error, msg string) {
+	switch err.Error() {
+	case "user not found":
+		WriteError(w, http.StatusNotFound, err.Error())
+	case "username already exists":
+		WriteError(w, http.StatusConflict, err.Error())
+	default:
+		WriteError(w, http.StatusInternalServerError, msg)
+	}
+}
+
+func (h *UsersHandler) Routes(r chi.Router) {
+	r.Get("/admin/users", h.ListUsers)
+	r.Post("/admin/users", h.CreateUser)
+	r.Get("/admin/users/{id}", h.GetUser)
+	r.Patch("/admin/users/{id}", h.UpdateUser)
+	r.Delete("/admin/users/{id}", h.DeleteUser)
+	r.Delete("/admin/users/{id}/sessions", h.EndUserSessions)
+}
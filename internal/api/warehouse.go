@@ -0,0 +1,101 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/warehouse"
+)
+
+type WarehouseHandler struct {
+	db       *database.DB
+	exporter *warehouse.Exporter // nil when WAREHOUSE_EXPORT is off
+}
+
+func NewWarehouseHandler(db *database.DB, exporter *warehouse.Exporter) *WarehouseHandler {
+	return &WarehouseHandler{db: db, exporter: exporter}
+}
+
+func (h *WarehouseHandler) available(w http.ResponseWriter) bool {
+	if h.exporter == nil {
+		WriteError(w, http.StatusServiceUnavailable, "warehouse export not enabled (set WAREHOUSE_EXPORT)")
+		return false
+	}
+	return true
+}
+
+// GetWarehouseStatus reports where exports go and how the last run went.
+func (h *WarehouseHandler) GetWarehouseStatus(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	WriteJSON(w, http.StatusOK, h.exporter.Status())
+}
+
+// RunWarehouseExport exports pending days now, or re-exports one day.
+func (h *WarehouseHandler) RunWarehouseExport(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	var req struct {
+		Day string `json:"day"` // YYYY-MM-DD (UTC); empty = all pending days
+	}
+	if r.ContentLength != 0 {
+		if err := DecodeJSON(r, &req); err != nil {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, err.Error())
+			return
+		}
+	}
+	var day *time.Time
+	if req.Day != "" {
+		d, err := time.Parse("2006-01-02", req.Day)
+		if err != nil {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "day must be YYYY-MM-DD")
+			return
+		}
+		if !d.Add(24 * time.Hour).Before(time.Now()) {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "day has not ended yet")
+			return
+		}
+		day = &d
+	}
+	if err := h.exporter.Run(day); err != nil {
+		WriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+	WriteJSON(w, http.StatusAccepted, h.exporter.Status())
+}
+
+// ListWarehouseExports returns exported dataset-days, most recent first.
+func (h *WarehouseHandler) ListWarehouseExports(w http.ResponseWriter, r *http.Request) {
+	if !h.available(w) {
+		return
+	}
+	dataset, _ := QueryString(r, "dataset")
+	limit := 100
+	if v, ok := QueryInt(r, "limit"); ok {
+		if v < 1 || v > 1000 {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "limit must be between 1 and 1000")
+			return
+		}
+		limit = v
+	}
+	exports, err := h.db.ListWarehouseExports(r.Context(), dataset, limit)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list warehouse exports")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"exports": exports,
+		"total":   len(exports),
+	})
+}
+
+func (h *WarehouseHandler) Routes(r chi.Router) {
+	r.Get("/admin/warehouse", h.GetWarehouseStatus)
+	r.Post("/admin/warehouse/run", h.RunWarehouseExport)
+	r.Get("/admin/warehouse/exports", h.ListWarehouseExports)
+}
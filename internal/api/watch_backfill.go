@@ -0,0 +1,160 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/hlog"
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// parseDayRange reads optional start_day/end_day (YYYY-MM-DD, UTC) values.
+// Writes a 400 and returns false if either is malformed or out of order.
+func parseDayRange(w http.ResponseWriter, startDay, endDay string) (start, end *time.Time, ok bool) {
+	for _, p := range []struct {
+		v   string
+		dst **time.Time
+	}{{startDay, &start}, {endDay, &end}} {
+		if p.v == "" {
+			continue
+		}
+		d, err := time.Parse("2006-01-02", p.v)
+		if err != nil {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "start_day and end_day must be YYYY-MM-DD")
+			return nil, nil, false
+		}
+		*p.dst = &d
+	}
+	if start != nil && end != nil && start.After(*end) {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, "start_day must not be after end_day")
+		return nil, nil, false
+	}
+	return start, end, true
+}
+
+// GetWatchBackfillReport returns per-day counts of files found by file-watch
+// backfill (WATCH_BACKFILL_DAYS) and how many were imported, skipped or
+// failed, with the skip and error reasons.
+// GET /api/v1/admin/watch-backfill?start_day=&end_day=
+func (h *AdminHandler) GetWatchBackfillReport(w http.ResponseWriter, r *http.Request) {
+	startDay, _ := QueryString(r, "start_day")
+	endDay, _ := QueryString(r, "end_day")
+	start, end, ok := parseDayRange(w, startDay, endDay)
+	if !ok {
+		return
+	}
+	days, err := h.db.WatchBackfillReport(r.Context(), start, end)
+	if err != nil {
+		hlog.FromRequest(r).Error().Err(err).Msg("watch backfill report failed")
+		WriteError(w, http.StatusInternalServerError, "failed to build backfill report")
+		return
+	}
+	total := database.WatchBackfillDay{Day: "total"}
+	for _, d := range days {
+		total.Found += d.Found
+		total.Imported += d.Imported
+		total.Skipped += d.Skipped
+		total.Errors += d.Errors
+		for reason, n := range d.Reasons {
+			if total.Reasons == nil {
+				total.Reasons = make(map[string]int)
+			}
+			total.Reasons[reason] += n
+		}
+	}
+	var watcher *WatcherStatusData
+	if h.live != nil {
+		watcher = h.live.WatcherStatus()
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"days":    days,
+		"totals":  total,
+		"watcher": watcher,
+	})
+}
+
+// ListWatchBackfillFiles lists backfilled files with their last outcome.
+// status defaults to "error", the files a retry would pick up.
+// GET /api/v1/admin/watch-backfill/files?status=&start_day=&end_day=
+func (h *AdminHandler) ListWatchBackfillFiles(w http.ResponseWriter, r *http.Request) {
+	p, err := ParsePagination(r)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+	startDay, _ := QueryString(r, "start_day")
+	endDay, _ := QueryString(r, "end_day")
+	start, end, ok := parseDayRange(w, startDay, endDay)
+	if !ok {
+		return
+	}
+	status, _ := QueryString(r, "status")
+	switch status {
+	case "":
+		status = database.BackfillError
+	case "all":
+		status = ""
+	case database.BackfillImported, database.BackfillSkipped, database.BackfillError:
+	default:
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "status must be imported, skipped, error or all")
+		return
+	}
+
+	files, total, err := h.db.ListWatchBackfillFiles(r.Context(), database.WatchBackfillFilter{
+		StartDay: start,
+		EndDay:   end,
+		Status:   status,
+		Limit:    p.Limit,
+		Offset:   p.Offset,
+	})
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list backfill files")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"files":  files,
+		"total":  total,
+		"limit":  p.Limit,
+		"offset": p.Offset,
+	})
+}
+
+// RetryWatchBackfill re-ingests, in the background, the files the backfill
+// report lists as errors. Body (optional): {"start_day", "end_day"} to retry
+// only some days. Returns 202 with how many files were queued.
+// POST /api/v1/admin/watch-backfill/retry
+func (h *AdminHandler) RetryWatchBackfill(w http.ResponseWriter, r *http.Request) {
+	if h.live == nil {
+		WriteError(w, http.StatusServiceUnavailable, "pipeline not running")
+		return
+	}
+	var req struct {
+		StartDay string `json:"start_day"`
+		EndDay   string `json:"end_day"`
+	}
+	if r.ContentLength != 0 {
+		if err := DecodeJSON(r, &req); err != nil {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+			return
+		}
+	}
+	start, end, ok := parseDayRange(w, req.StartDay, req.EndDay)
+	if !ok {
+		return
+	}
+
+	queued, err := h.live.RetryBackfillFailures(r.Context(), start, end)
+	if err != nil {
+		switch err.Error() {
+		case "file watcher not active":
+			WriteError(w, http.StatusServiceUnavailable, "file watcher not active (set WATCH_DIR)")
+		case "backfill already running", "backfill retry already running":
+			WriteError(w, http.StatusConflict, err.Error())
+		default:
+			hlog.FromRequest(r).Error().Err(err).Msg("watch backfill retry failed")
+			WriteError(w, http.StatusInternalServerError, "failed to retry backfill files")
+		}
+		return
+	}
+	WriteJSON(w, http.StatusAccepted, map[string]any{"queued": queued})
+}
@@ -0,0 +1,373 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// Webhook limits.
+const (
+	maxWebhookNameLen   = 100
+	maxWebhookURLLen    = 2000
+	maxWebhookTgids     = 1000
+	maxWebhookSecretLen = 200
+	maxWebhookTemplate  = 10000
+)
+
+// WebhooksHandler manages webhooks, POSTed call_end, transcription and alert
+// events, and serves their delivery log. Webhook URLs often embed
+// credentials (Discord, Slack), so every route needs the write token.
+type WebhooksHandler struct {
+	db            *database.DB
+	onChange      func(ctx context.Context) error // reloads the dispatcher's webhooks; may be nil
+	checkTemplate func(text string) error         // validates "template" format bodies; may be nil
+}
+
+func NewWebhooksHandler(db *database.DB, onChange func(context.Context) error, checkTemplate func(string) error) *WebhooksHandler {
+	return &WebhooksHandler{db: db, onChange: onChange, checkTemplate: checkTemplate}
+}
+
+// changed tells the dispatcher about a webhook change. The change is already
+// committed, so a failed reload is only logged; it is picked up on restart.
+func (h *WebhooksHandler) changed(r *http.Request) {
+	if h.onChange == nil {
+		return
+	}
+	if err := h.onChange(r.Context()); err != nil {
+		hlog.FromRequest(r).Warn().Err(err).Msg("failed to reload webhooks")
+	}
+}
+
+// adminOnly rejects requests without the write token.
+func adminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isAdmin(r) {
+			WriteError(w, http.StatusForbidden, "webhooks require the write token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// webhookInput is a webhook create/update body. Secret is left unchanged on
+// update when omitted; "" removes it.
+type webhookInput struct {
+	Name       string   `json:"name"`
+	URL        string   `json:"url"`
+	Format     string   `json:"format"`
+	Template   string   `json:"template"`
+	EventTypes []string `json:"event_types"`
+	SystemID   *int     `json:"system_id"`
+	Tgids      []int    `json:"tgids"`
+	Secret     *string  `json:"secret"`
+	Enabled    *bool    `json:"enabled"`
+}
+
+// validateWebhook returns why a webhook is unusable, or "".
+func validateWebhook(hook *database.Webhook) string {
+	switch {
+	case hook.Name == "":
+		return "name is required"
+	case len(hook.Name) > maxWebhookNameLen:
+		return fmt.Sprintf("name must be at most %d characters", maxWebhookNameLen)
+	case hook.URL == "":
+		return "url is required"
+	case len(hook.URL) > maxWebhookURLLen:
+		return fmt.Sprintf("url must be at most %d characters", maxWebhookURLLen)
+	case !slices.Contains(database.WebhookFormats, hook.Format):
+		return "format must be " + strings.Join(database.WebhookFormats, ", ")
+	case hook.Format == "template" && strings.TrimSpace(hook.Template) == "":
+		return "template is required with format template"
+	case hook.Format != "template" && hook.Template != "":
+		return "template is only used with format template"
+	case len(hook.Template) > maxWebhookTemplate:
+		return fmt.Sprintf("template must be at most %d characters", maxWebhookTemplate)
+	case len(hook.EventTypes) == 0:
+		return "event_types is required"
+	case hook.SystemID != nil && *hook.SystemID <= 0:
+		return "system_id must be positive"
+	case len(hook.Tgids) > maxWebhookTgids:
+		return fmt.Sprintf("at most %d tgids", maxWebhookTgids)
+	case len(hook.Secret) > maxWebhookSecretLen:
+		return fmt.Sprintf("secret must be at most %d characters", maxWebhookSecretLen)
+	}
+	if u, err := url.Parse(hook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "url must be an absolute http or https URL"
+	}
+	for _, t := range hook.EventTypes {
+		if !slices.Contains(database.WebhookEventTypes, t) {
+			return "event_types must be " + strings.Join(database.WebhookEventTypes, ", ")
+		}
+	}
+	return ""
+}
+
+// decodeWebhook reads and validates a webhook body (generic format and
+// enabled by default). setSecret reports whether the body set the secret.
+func (h *WebhooksHandler) decodeWebhook(w http.ResponseWriter, r *http.Request) (hook *database.Webhook, setSecret, ok bool) {
+	var in webhookInput
+	if err := DecodeJSON(r, &in); err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidBody, "invalid request body")
+		return nil, false, false
+	}
+	hook = &database.Webhook{
+		Name:       strings.TrimSpace(in.Name),
+		URL:        strings.TrimSpace(in.URL),
+		Format:     in.Format,
+		Template:   in.Template,
+		EventTypes: slices.Compact(slices.Sorted(slices.Values(in.EventTypes))),
+		SystemID:   in.SystemID,
+		Tgids:      in.Tgids,
+		Enabled:    in.Enabled == nil || *in.Enabled,
+	}
+	if hook.Format == "" {
+		hook.Format = "generic"
+	}
+	if in.Secret != nil {
+		hook.Secret = *in.Secret
+	}
+	if msg := validateWebhook(hook); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, msg)
+		return nil, false, false
+	}
+	if hook.Format == "template" && h.checkTemplate != nil {
+		if err := h.checkTemplate(hook.Template); err != nil {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, "invalid template: "+err.Error())
+			return nil, false, false
+		}
+	}
+	if hook.Tgids == nil {
+		hook.Tgids = []int{}
+	}
+	return hook, in.Secret != nil, true
+}
+
+// ListWebhooks returns all webhooks. Secrets are never returned.
+// GET /api/v1/webhooks
+func (h *WebhooksHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := h.db.ListWebhooks(r.Context())
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list webhooks")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"webhooks": hooks,
+		"total":    len(hooks),
+	})
+}
+
+// GetWebhook returns one webhook.
+// GET /api/v1/webhooks/{id}
+func (h *WebhooksHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid webhook ID")
+		return
+	}
+	hook, err := h.db.GetWebhook(r.Context(), id)
+	if err != nil {
+		h.writeWebhookError(w, err, "failed to get webhook")
+		return
+	}
+	WriteJSON(w, http.StatusOK, hook)
+}
+
+// CreateWebhook adds a webhook; it receives events published from now on.
+// POST /api/v1/webhooks
+func (h *WebhooksHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, _, ok := h.decodeWebhook(w, r)
+	if !ok {
+		return
+	}
+	id, err := h.db.CreateWebhook(r.Context(), hook)
+	if err != nil {
+		h.writeWebhookError(w, err, "failed to create webhook")
+		return
+	}
+	h.changed(r)
+	h.writeWebhook(w, r, id, http.StatusCreated)
+}
+
+// UpdateWebhook replaces a webhook, keeping its secret unless the body sets
+// one. Deliveries already queued keep their payload.
+// PUT /api/v1/webhooks/{id}
+func (h *WebhooksHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid webhook ID")
+		return
+	}
+	hook, setSecret, ok := h.decodeWebhook(w, r)
+	if !ok {
+		return
+	}
+	hook.ID = id
+	if err := h.db.UpdateWebhook(r.Context(), hook, setSecret); err != nil {
+		h.writeWebhookError(w, err, "failed to update webhook")
+		return
+	}
+	h.changed(r)
+	h.writeWebhook(w, r, id, http.StatusOK)
+}
+
+// DeleteWebhook removes a webhook with its delivery log.
+// DELETE /api/v1/webhooks/{id}
+func (h *WebhooksHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid webhook ID")
+		return
+	}
+	if err := h.db.DeleteWebhook(r.Context(), id); err != nil {
+		h.writeWebhookError(w, err, "failed to delete webhook")
+		return
+	}
+	h.changed(r)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeWebhook returns a webhook as stored.
+func (h *WebhooksHandler) writeWebhook(w http.ResponseWriter, r *http.Request, id, status int) {
+	hook, err := h.db.GetWebhook(r.Context(), id)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to get webhook")
+		return
+	}
+	WriteJSON(w, status, hook)
+}
+
+func (h *WebhooksHandler) writeWebhookError(w http.ResponseWriter, err error, msg string) {
+	switch err.Error() {
+	case "webhook not found":
+		WriteError(w, http.StatusNotFound, err.Error())
+	case "webhook name already exists":
+		WriteError(w, http.StatusConflict, err.Error())
+	default:
+		WriteError(w, http.StatusInternalServerError, msg)
+	}
+}
+
+// ListWebhookDeliveries returns the delivery log, newest first, without
+// payloads. Filters: webhook_id, status (pending, delivered, failed),
+// event_type (comma-separated), start_time/end_time (when queued).
+// GET /api/v1/webhook-deliveries
+func (h *WebhooksHandler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	p, err := ParsePagination(r)
+	if err != nil {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter, err.Error())
+		return
+	}
+	f := database.WebhookDeliveryFilter{
+		WebhookIDs: QueryIntList(r, "webhook_id"),
+		Limit:      p.Limit,
+		Offset:     p.Offset,
+	}
+	for _, s := range QueryStringList(r, "status") {
+		switch s {
+		case database.WebhookPending, database.WebhookDelivered, database.WebhookFailed:
+			f.Statuses = append(f.Statuses, s)
+		default:
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter,
+				"status must be pending, delivered, or failed")
+			return
+		}
+	}
+	for _, t := range QueryStringList(r, "event_type") {
+		if !slices.Contains(database.WebhookEventTypes, t) {
+			WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidParameter,
+				"event_type must be "+strings.Join(database.WebhookEventTypes, ", "))
+			return
+		}
+		f.EventTypes = append(f.EventTypes, t)
+	}
+	if t, ok := QueryTime(r, "start_time"); ok {
+		f.Start = &t
+	}
+	if t, ok := QueryTime(r, "end_time"); ok {
+		f.End = &t
+	}
+	if msg := ValidateTimeRange(f.Start, f.End); msg != "" {
+		WriteErrorWithCode(w, http.StatusBadRequest, ErrInvalidTimeRange, msg)
+		return
+	}
+
+	deliveries, total, err := h.db.ListWebhookDeliveries(r.Context(), f)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "failed to list webhook deliveries")
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]any{
+		"deliveries": deliveries,
+		"total":      total,
+		"limit":      p.Limit,
+		"offset":     p.Offset,
+	})
+}
+
+// GetWebhookDelivery returns one delivery with its payload.
+// GET /api/v1/webhook-deliveries/{id}
+func (h *WebhooksHandler) GetWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt64(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid webhook delivery ID")
+		return
+	}
+	d, err := h.db.GetWebhookDelivery(r.Context(), id)
+	if err != nil {
+		if err.Error() == "webhook delivery not found" {
+			WriteError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		WriteError(w, http.StatusInternalServerError, "failed to get webhook delivery")
+		return
+	}
+	WriteJSON(w, http.StatusOK, d)
+}
+
+// RetryWebhookDelivery queues a failed delivery again with its original
+// payload and a fresh set of attempts.
+// POST /api/v1/webhook-deliveries/{id}/retry
+func (h *WebhooksHandler) RetryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := PathInt64(r, "id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid webhook delivery ID")
+		return
+	}
+	d, err := h.db.RetryWebhookDelivery(r.Context(), id)
+	if err != nil {
+		switch err.Error() {
+		case "webhook delivery not found":
+			WriteError(w, http.StatusNotFound, err.Error())
+		case "webhook delivery has not failed":
+			WriteError(w, http.StatusConflict, err.Error())
+		default:
+			WriteError(w, http.StatusInternalServerError, "failed to retry webhook delivery")
+		}
+		return
+	}
+	WriteJSON(w, http.StatusOK, d)
+}
+
+// Routes registers webhook and delivery log routes on the given router.
+func (h *WebhooksHandler) Routes(r chi.Router) {
+	r.Group(func(r chi.Router) {
+		r.Use(adminOnly)
+		r.Get("/webhooks", h.ListWebhooks)
+		r.Post("/webhooks", h.CreateWebhook)
+		r.Get("/webhooks/{id}", h.GetWebhook)
+		r.Put("/webhooks/{id}", h.UpdateWebhook)
+		r.Delete("/webhooks/{id}", h.DeleteWebhook)
+		r.Get("/webhook-deliveries", h.ListWebhookDeliveries)
+		r.Get("/webhook-deliveries/{id}", h.GetWebhookDelivery)
+		r.Post("/webhook-deliveries/{id}/retry", h.RetryWebhookDelivery)
+	})
+}
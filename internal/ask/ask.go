@@ -0,0 +1,253 @@
+// Package ask answers natural-language questions about the radio archive
+// ("when did Engine 5 last respond to Main St?").
+//
+// A question is answered in two steps. First, transcripts are retrieved with
+// hybrid semantic search when embeddings are configured, otherwise by matching
+// the question's words with full-text search (any word, best matches first).
+// Then the top transcripts are handed to an OpenAI-compatible chat completions
+// endpoint with instructions to answer only from them and cite each fact as
+// [call <id>]. Citations are checked against the transcripts that were sent,
+// so the response only links calls the model actually saw.
+package ask
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/embed"
+)
+
+// maxTranscriptRunes bounds each transcript in the prompt.
+const maxTranscriptRunes = 1000
+
+// ErrModel wraps failures of the language model endpoint.
+var ErrModel = errors.New("language model request failed")
+
+// Options configures a Service.
+type Options struct {
+	MaxContext int             // transcripts given to the model per question (default 20)
+	Embedder   *embed.Embedder // optional: retrieve with hybrid semantic search
+}
+
+// Request is a question with optional filters that narrow retrieval.
+type Request struct {
+	Question  string     `json:"question"`
+	SystemIDs []int      `json:"system_ids,omitempty"`
+	Tgids     []int      `json:"tgids,omitempty"`
+	StartTime *time.Time `json:"start_time,omitempty"`
+	EndTime   *time.Time `json:"end_time,omitempty"`
+}
+
+// Citation is a call the answer cites.
+type Citation struct {
+	CallID        int64     `json:"call_id"`
+	CallStartTime time.Time `json:"call_start_time"`
+	SystemID      int       `json:"system_id"`
+	Tgid          int       `json:"tgid"`
+	TgAlphaTag    string    `json:"tg_alpha_tag,omitempty"`
+	Text          string    `json:"text"`
+}
+
+// Answer is the synthesized answer to a question.
+type Answer struct {
+	Answer    string     `json:"answer"`
+	Citations []Citation `json:"citations"`
+	Model     string     `json:"model"`
+	Retrieved int        `json:"retrieved"` // transcripts given to the model
+}
+
+// Service answers questions from the transcript archive.
+type Service struct {
+	db   *database.DB
+	llm  *ChatClient
+	opts Options
+	log  zerolog.Logger
+}
+
+// NewService creates a service that retrieves from db and answers with llm.
+func NewService(db *database.DB, llm *ChatClient, opts Options, log zerolog.Logger) *Service {
+	if opts.MaxContext <= 0 {
+		opts.MaxContext = 20
+	}
+	return &Service{
+		db:   db,
+		llm:  llm,
+		opts: opts,
+		log:  log.With().Str("component", "ask").Logger(),
+	}
+}
+
+// Model returns the configured model name.
+func (s *Service) Model() string { return s.llm.Model() }
+
+// Ask answers req.Question. When no transcript matches, the model is not
+// called and the answer says so.
+func (s *Service) Ask(ctx context.Context, req Request) (*Answer, error) {
+	terms := Keywords(req.Question)
+	if terms == "" {
+		return nil, fmt.Errorf("question has no searchable words")
+	}
+	hits, err := s.retrieve(ctx, req, terms)
+	if err != nil {
+		return nil, fmt.Errorf("search transcripts: %w", err)
+	}
+
+	ans := &Answer{Model: s.llm.Model(), Retrieved: len(hits), Citations: []Citation{}}
+	if len(hits) == 0 {
+		ans.Answer = "No transcripts matched the question."
+		return ans, nil
+	}
+
+	// Newest first, so "last"/"latest" questions read naturally.
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].CallStartTime.After(hits[j].CallStartTime) })
+
+	text, err := s.llm.Complete(ctx, []ChatMessage{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: BuildPrompt(req.Question, hits)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrModel, err)
+	}
+	ans.Answer = text
+
+	byCall := make(map[int64]database.TranscriptionSearchHit, len(hits))
+	for _, h := range hits {
+		byCall[h.CallID] = h
+	}
+	for _, id := range ParseCitations(text) {
+		h, ok := byCall[id]
+		if !ok {
+			s.log.Debug().Int64("call_id", id).Msg("answer cites a call that was not in context")
+			continue
+		}
+		c := Citation{
+			CallID:        h.CallID,
+			CallStartTime: h.CallStartTime,
+			SystemID:      h.CallSystemID,
+			Tgid:          h.CallTgid,
+			TgAlphaTag:    h.CallTgAlphaTag,
+			Text:          h.Text,
+		}
+		ans.Citations = append(ans.Citations, c)
+	}
+	return ans, nil
+}
+
+// retrieve finds the transcripts to answer from: hybrid semantic search when
+// an embedder is configured (falling back to full-text if it fails), else
+// any-word full-text search.
+func (s *Service) retrieve(ctx context.Context, req Request, terms string) ([]database.TranscriptionSearchHit, error) {
+	if s.opts.Embedder != nil {
+		semantic, err := s.opts.Embedder.Search(ctx, req.Question, database.SemanticSearchFilter{
+			SystemIDs:    req.SystemIDs,
+			Tgids:        req.Tgids,
+			StartTime:    req.StartTime,
+			EndTime:      req.EndTime,
+			Limit:        s.opts.MaxContext,
+			VectorWeight: 0.7,
+		})
+		if err == nil {
+			hits := make([]database.TranscriptionSearchHit, len(semantic))
+			for i, h := range semantic {
+				hits[i] = h.TranscriptionSearchHit
+			}
+			return hits, nil
+		}
+		s.log.Warn().Err(err).Msg("semantic retrieval failed, using full-text search")
+	}
+	hits, _, err := s.db.SearchTranscriptions(ctx, terms, database.TranscriptionSearchFilter{
+		SystemIDs: req.SystemIDs,
+		Tgids:     req.Tgids,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		MatchAny:  true,
+		Limit:     s.opts.MaxContext,
+	})
+	return hits, err
+}
+
+const systemPrompt = `You answer questions about public-safety radio traffic using only the transcripts provided.
+Transcripts are machine-generated and may misspell names, units, and addresses.
+Cite the call behind every fact as [call <id>], e.g. [call 48531]. Give times in UTC as shown.
+If the transcripts do not answer the question, say so plainly instead of guessing.
+Answer in a few sentences.`
+
+// BuildPrompt formats the question and the retrieved transcripts for the model.
+func BuildPrompt(question string, hits []database.TranscriptionSearchHit) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Question: %s\n\nTranscripts (newest first):\n", strings.TrimSpace(question))
+	for _, h := range hits {
+		tg := strconv.Itoa(h.CallTgid)
+		if h.CallTgAlphaTag != "" {
+			tg = h.CallTgAlphaTag + " (" + tg + ")"
+		}
+		text := truncate(strings.Join(strings.Fields(h.Text), " "), maxTranscriptRunes)
+		fmt.Fprintf(&b, "[call %d] %s, system %d, talkgroup %s: %s\n",
+			h.CallID, h.CallStartTime.UTC().Format("2006-01-02 15:04:05 UTC"), h.CallSystemID, tg, text)
+	}
+	return b.String()
+}
+
+var citationRe = regexp.MustCompile(`(?i)\[\s*calls?\b([^\]]*)\]`)
+var numberRe = regexp.MustCompile(`\d+`)
+
+// ParseCitations returns the call IDs cited in text as [call 123] (also
+// [call #123], [calls 1, 2]), in order of first appearance.
+func ParseCitations(text string) []int64 {
+	var ids []int64
+	seen := map[int64]bool{}
+	for _, m := range citationRe.FindAllStringSubmatch(text, -1) {
+		for _, n := range numberRe.FindAllString(m[1], -1) {
+			id, err := strconv.ParseInt(n, 10, 64)
+			if err != nil || seen[id] {
+				continue
+			}
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// questionWords are common in questions but say nothing about which
+// transcripts are relevant. PostgreSQL's English stop words are removed by
+// the search itself.
+var questionWords = map[string]bool{
+	"anyone": true, "anything": true, "call": true, "calls": true, "happen": true,
+	"happened": true, "hear": true, "heard": true, "last": true, "latest": true,
+	"mention": true, "mentioned": true, "radio": true, "recent": true, "recently": true,
+	"said": true, "say": true, "show": true, "tell": true, "time": true, "times": true,
+	"traffic": true,
+}
+
+// Keywords reduces a question to its search terms, space-separated.
+func Keywords(question string) string {
+	words := strings.FieldsFunc(strings.ToLower(question), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var out []string
+	for _, w := range words {
+		if !questionWords[w] {
+			out = append(out, w)
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "…"
+}
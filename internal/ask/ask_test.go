@@ -0,0 +1,121 @@
+package ask
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+func TestKeywords(t *testing.T) {
+	tests := []struct {
+		question string
+		want     string
+	}{
+		{"When did Engine 5 last respond to Main St?", "when did engine 5 respond to main st"},
+		{"Any radio traffic mentioning 1234 Oak Ave recently?", "any mentioning 1234 oak ave"},
+		{"last time?", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Keywords(tt.question); got != tt.want {
+			t.Errorf("Keywords(%q) = %q, want %q", tt.question, got, tt.want)
+		}
+	}
+}
+
+func TestParseCitations(t *testing.T) {
+	tests := []struct {
+		text string
+		want []int64
+	}{
+		{"Engine 5 responded at 14:02 [call 48531].", []int64{48531}},
+		{"Twice [call 2] [Call #1], then again [call 2].", []int64{2, 1}},
+		{"See [calls 7, 9] and [call: 11]", []int64{7, 9, 11}},
+		{"Nothing cited [12] [unit 5]", nil},
+	}
+	for _, tt := range tests {
+		if got := ParseCitations(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseCitations(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestBuildPrompt(t *testing.T) {
+	start := time.Date(2026, 3, 1, 14, 2, 11, 0, time.UTC)
+	hits := []database.TranscriptionSearchHit{
+		{CallSystemID: 1, CallTgid: 9131, CallTgAlphaTag: "Fire Dispatch", CallStartTime: start},
+		{CallSystemID: 1, CallTgid: 9178, CallStartTime: start.Add(-time.Hour)},
+	}
+	hits[0].CallID = 48531
+	hits[0].Text = "Engine 5   responding\nto 100 Main St"
+	hits[1].CallID = 48000
+	hits[1].Text = strings.Repeat("x", maxTranscriptRunes+10)
+
+	got := BuildPrompt(" When did Engine 5 last respond to Main St? ", hits)
+	for _, want := range []string{
+		"Question: When did Engine 5 last respond to Main St?\n",
+		"[call 48531] 2026-03-01 14:02:11 UTC, system 1, talkgroup Fire Dispatch (9131): Engine 5 responding to 100 Main St\n",
+		"[call 48000] 2026-03-01 13:02:11 UTC, system 1, talkgroup 9178: xxx",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("prompt missing %q:\n%s", want, got)
+		}
+	}
+	if !strings.Contains(got, strings.Repeat("x", maxTranscriptRunes)+"…\n") {
+		t.Error("long transcript not truncated")
+	}
+}
+
+func TestChatClient(t *testing.T) {
+	var got struct {
+		Model    string        `json:"model"`
+		Messages []ChatMessage `json:"messages"`
+	}
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+		io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":" Engine 5 was at Main St [call 1]. "}}]}`)
+	}))
+	defer srv.Close()
+
+	c := NewChatClient(srv.URL, "test-model", http.Header{"Authorization": {"Bearer k"}}, 5*time.Second)
+	text, err := c.Complete(context.Background(), []ChatMessage{{Role: "user", Content: "q"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Engine 5 was at Main St [call 1]." {
+		t.Errorf("text = %q", text)
+	}
+	if got.Model != "test-model" || len(got.Messages) != 1 || auth != "Bearer k" {
+		t.Errorf("request model=%q messages=%v auth=%q", got.Model, got.Messages, auth)
+	}
+}
+
+func TestChatClient_Errors(t *testing.T) {
+	for name, handler := range map[string]http.HandlerFunc{
+		"status": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "model not found", http.StatusNotFound)
+		},
+		"no choices": func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, `{"choices":[]}`)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(handler)
+			defer srv.Close()
+			c := NewChatClient(srv.URL, "m", nil, 5*time.Second)
+			if _, err := c.Complete(context.Background(), nil); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package ask
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ChatMessage is one message in a chat completions request.
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatClient calls an OpenAI-compatible chat completions endpoint (OpenAI,
+// Ollama, vLLM, llama.cpp server, LiteLLM, ...). url is the full endpoint,
+// e.g. http://localhost:11434/v1/chat/completions.
+type ChatClient struct {
+	url     string
+	model   string
+	headers http.Header
+	client  *http.Client
+}
+
+// NewChatClient creates a client. headers are added to every request (e.g.
+// Authorization).
+func NewChatClient(url, model string, headers http.Header, timeout time.Duration) *ChatClient {
+	if headers == nil {
+		headers = http.Header{}
+	}
+	return &ChatClient{
+		url:     url,
+		model:   model,
+		headers: headers,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Model returns the configured model name.
+func (c *ChatClient) Model() string { return c.model }
+
+type chatResponse struct {
+	Choices []struct {
+		Message ChatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// Complete sends messages and returns the first choice's content.
+func (c *ChatClient) Complete(ctx context.Context, messages []ChatMessage) (string, error) {
+	body, err := json.Marshal(map[string]any{
+		"model":       c.model,
+		"messages":    messages,
+		"temperature": 0.2,
+		"stream":      false,
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	for name, values := range c.headers {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("llm request: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("llm error (status %d): %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var cr chatResponse
+	if err := json.Unmarshal(respBody, &cr); err != nil {
+		return "", fmt.Errorf("decode llm response: %w", err)
+	}
+	if len(cr.Choices) == 0 || strings.TrimSpace(cr.Choices[0].Message.Content) == "" {
+		return "", fmt.Errorf("llm returned no answer")
+	}
+	return strings.TrimSpace(cr.Choices[0].Message.Content), nil
+}
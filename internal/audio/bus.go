@@ -13,6 +13,10 @@ const audioSubscriberBuffer = 256
 type AudioFilter struct {
 	SystemIDs []int
 	TGIDs     []int
+
+	// IncludeRestricted also receives talkgroups the bus's restricted check
+	// hides (embargoed or restricted by encryption policy). Admins only.
+	IncludeRestricted bool
 }
 
 type audioSubscriber struct {
@@ -26,7 +30,8 @@ type AudioBus struct {
 	mu          sync.RWMutex
 	subscribers map[uint64]*audioSubscriber
 	nextID      atomic.Uint64
-	chToID      map[<-chan AudioFrame]uint64 // reverse lookup for UpdateFilter
+	chToID      map[<-chan AudioFrame]uint64  // reverse lookup for UpdateFilter
+	restricted  func(systemID, tgid int) bool // nil = nothing is hidden
 }
 
 // NewAudioBus creates a new AudioBus ready for use.
@@ -37,6 +42,14 @@ func NewAudioBus() *AudioBus {
 	}
 }
 
+// SetRestricted sets the check for talkgroups hidden from subscribers
+// without IncludeRestricted. Call before publishing.
+func (ab *AudioBus) SetRestricted(fn func(systemID, tgid int) bool) {
+	ab.mu.Lock()
+	ab.restricted = fn
+	ab.mu.Unlock()
+}
+
 // Subscribe returns a channel that receives matching audio frames and a cancel function.
 func (ab *AudioBus) Subscribe(filter AudioFilter) (<-chan AudioFrame, func()) {
 	id := ab.nextID.Add(1)
@@ -80,11 +93,21 @@ func (ab *AudioBus) Publish(frame AudioFrame) {
 	ab.mu.RLock()
 	defer ab.mu.RUnlock()
 
+	// Checked at most once per frame, and only if someone is listening.
+	checked, restricted := false, false
 	for _, sub := range ab.subscribers {
 		sub.mu.RLock()
 		match := matchesAudioFilter(frame, sub.filter)
+		include := sub.filter.IncludeRestricted
 		sub.mu.RUnlock()
 
+		if match && !include && ab.restricted != nil {
+			if !checked {
+				checked, restricted = true, ab.restricted(frame.SystemID, frame.TGID)
+			}
+			match = !restricted
+		}
+
 		if match {
 			select {
 			case sub.ch <- frame:
@@ -222,3 +222,34 @@ func TestAudioBusUpdateFilter(t *testing.T) {
 		t.Fatal("timed out waiting for frame with updated filter")
 	}
 }
+
+func TestAudioBusRestricted(t *testing.T) {
+	bus := NewAudioBus()
+	bus.SetRestricted(func(systemID, tgid int) bool { return tgid == 2002 })
+	listener, cancelListener := bus.Subscribe(AudioFilter{})
+	defer cancelListener()
+	admin, cancelAdmin := bus.Subscribe(AudioFilter{IncludeRestricted: true})
+	defer cancelAdmin()
+
+	bus.Publish(makeFrame(1, 2002))
+	bus.Publish(makeFrame(1, 1001))
+
+	select {
+	case got := <-listener:
+		if got.TGID != 1001 {
+			t.Errorf("listener got TGID %d, want only 1001", got.TGID)
+		}
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("listener timed out waiting for frame")
+	}
+	for _, want := range []int{2002, 1001} {
+		select {
+		case got := <-admin:
+			if got.TGID != want {
+				t.Errorf("admin got TGID %d, want %d", got.TGID, want)
+			}
+		case <-time.After(200 * time.Millisecond):
+			t.Fatalf("admin timed out waiting for TGID %d", want)
+		}
+	}
+}
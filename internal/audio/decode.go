@@ -0,0 +1,118 @@
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+var (
+	ffmpegOnce  sync.Once
+	ffmpegAvail bool
+)
+
+// CheckFFmpeg reports whether ffmpeg is in PATH (checked once).
+func CheckFFmpeg() bool {
+	ffmpegOnce.Do(func() {
+		_, err := exec.LookPath("ffmpeg")
+		ffmpegAvail = err == nil
+	})
+	return ffmpegAvail
+}
+
+// DecodeFile decodes the audio file at path to 16-bit mono PCM at rate Hz.
+// 16-bit PCM WAV is decoded in-process; other formats (M4A, MP3, ...) need
+// ffmpeg and return ErrUnsupportedFormat without it.
+func DecodeFile(ctx context.Context, path string, rate int) ([]int16, error) {
+	if headerFormat(filepath.Ext(path)) == "wav" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		samples, srcRate, err := wavSamples(data)
+		if err == nil {
+			return Resample(samples, srcRate, rate), nil
+		}
+		if !CheckFFmpeg() {
+			return nil, err
+		}
+	}
+	if !CheckFFmpeg() {
+		return nil, fmt.Errorf("%w: %s (ffmpeg not installed)", ErrUnsupportedFormat, filepath.Ext(path))
+	}
+	out, err := exec.CommandContext(ctx, "ffmpeg",
+		"-v", "error",
+		"-i", path,
+		"-f", "s16le", "-ac", "1", "-ar", strconv.Itoa(rate),
+		"pipe:1",
+	).Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w", err)
+	}
+	samples := make([]int16, len(out)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(out[2*i:]))
+	}
+	return samples, nil
+}
+
+// Resample converts mono samples from one rate to another by linear
+// interpolation. Good enough for narrowband voice.
+func Resample(samples []int16, from, to int) []int16 {
+	if from == to || from <= 0 || to <= 0 || len(samples) == 0 {
+		return samples
+	}
+	n := int(int64(len(samples)) * int64(to) / int64(from))
+	out := make([]int16, n)
+	step := float64(from) / float64(to)
+	for i := range out {
+		pos := float64(i) * step
+		j := int(pos)
+		if j+1 >= len(samples) {
+			out[i] = samples[len(samples)-1]
+			continue
+		}
+		frac := pos - float64(j)
+		out[i] = int16(float64(samples[j])*(1-frac) + float64(samples[j+1])*frac)
+	}
+	return out
+}
+
+// WriteWAV writes 16-bit mono PCM samples as a WAV file.
+func WriteWAV(w io.Writer, samples []int16, rate int) error {
+	dataLen := uint32(len(samples) * 2)
+	hdr := make([]byte, 0, 44)
+	hdr = append(hdr, "RIFF"...)
+	hdr = binary.LittleEndian.AppendUint32(hdr, 36+dataLen)
+	hdr = append(hdr, "WAVEfmt "...)
+	hdr = binary.LittleEndian.AppendUint32(hdr, 16)
+	hdr = binary.LittleEndian.AppendUint16(hdr, 1) // PCM
+	hdr = binary.LittleEndian.AppendUint16(hdr, 1) // mono
+	hdr = binary.LittleEndian.AppendUint32(hdr, uint32(rate))
+	hdr = binary.LittleEndian.AppendUint32(hdr, uint32(rate*2))
+	hdr = binary.LittleEndian.AppendUint16(hdr, 2)
+	hdr = binary.LittleEndian.AppendUint16(hdr, 16)
+	hdr = append(hdr, "data"...)
+	hdr = binary.LittleEndian.AppendUint32(hdr, dataLen)
+	if _, err := w.Write(hdr); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 0, 64<<10)
+	for i, s := range samples {
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(s))
+		if len(buf) == cap(buf) || i == len(samples)-1 {
+			if _, err := w.Write(buf); err != nil {
+				return err
+			}
+			buf = buf[:0]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResample(t *testing.T) {
+	in := []int16{0, 100, 200, 300}
+	if got := Resample(in, 8000, 8000); len(got) != 4 {
+		t.Errorf("same rate: len %d", len(got))
+	}
+	up := Resample(in, 8000, 16000)
+	if len(up) != 8 || up[1] != 50 || up[2] != 100 {
+		t.Errorf("upsample = %v", up)
+	}
+	down := Resample(append(in, 400, 500, 600, 700), 16000, 8000)
+	if len(down) != 4 || down[1] != 200 {
+		t.Errorf("downsample = %v", down)
+	}
+}
+
+func TestWriteWAVDecodeFile(t *testing.T) {
+	samples := make([]int16, 16000)
+	for i := range samples {
+		samples[i] = int16(i % 1000)
+	}
+	var buf bytes.Buffer
+	if err := WriteWAV(&buf, samples, 16000); err != nil {
+		t.Fatal(err)
+	}
+	if d, err := Duration(buf.Bytes(), "wav"); err != nil || d != 1 {
+		t.Errorf("Duration = %v, %v; want 1s", d, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "call.wav")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeFile(context.Background(), path, 8000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 8000 || got[1] != samples[2] {
+		t.Errorf("decoded %d samples, got[1] = %d", len(got), got[1])
+	}
+}
@@ -0,0 +1,184 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrUnsupportedFormat is returned by Duration for formats it can't parse
+// without ffprobe.
+var ErrUnsupportedFormat = errors.New("unsupported audio format")
+
+// Duration returns the playback length in seconds of an audio file held in
+// memory. WAV and M4A/MP4 are parsed directly from their headers; other
+// formats return ErrUnsupportedFormat. format is a type or extension such as
+// "m4a" or ".wav".
+func Duration(data []byte, format string) (float64, error) {
+	switch headerFormat(format) {
+	case "wav":
+		return wavDuration(data)
+	case "mp4":
+		return mp4Duration(data)
+	default:
+		return 0, ErrUnsupportedFormat
+	}
+}
+
+// headerFormat maps a type or extension to the container Duration parses, or "".
+func headerFormat(format string) string {
+	switch strings.TrimPrefix(strings.ToLower(format), ".") {
+	case "wav":
+		return "wav"
+	case "m4a", "mp4", "aac":
+		return "mp4"
+	}
+	return ""
+}
+
+// wavDuration walks the RIFF chunks for fmt (byte rate) and data (size).
+func wavDuration(data []byte) (float64, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return 0, fmt.Errorf("not a WAV file")
+	}
+	var byteRate uint32
+	pos := 12
+	for pos+8 <= len(data) {
+		id := string(data[pos : pos+4])
+		size := binary.LittleEndian.Uint32(data[pos+4 : pos+8])
+		body := pos + 8
+		switch id {
+		case "fmt ":
+			if body+12 > len(data) {
+				return 0, fmt.Errorf("truncated WAV fmt chunk")
+			}
+			byteRate = binary.LittleEndian.Uint32(data[body+8 : body+12])
+		case "data":
+			if byteRate == 0 {
+				return 0, fmt.Errorf("WAV data chunk before fmt chunk")
+			}
+			// A recording cut off mid-write can claim more data than the file
+			// holds (or 0xFFFFFFFF while streaming); measure what's actually there.
+			n := int64(size)
+			if avail := int64(len(data) - body); n > avail {
+				n = avail
+			}
+			return float64(n) / float64(byteRate), nil
+		}
+		pos = body + int(size) + int(size&1) // chunks are word-aligned
+	}
+	return 0, fmt.Errorf("WAV data chunk not found")
+}
+
+// mp4Duration reads the movie header (moov/mvhd) timescale and duration.
+func mp4Duration(data []byte) (float64, error) {
+	moov, ok := findBox(data, "moov")
+	if !ok {
+		return 0, fmt.Errorf("MP4 moov box not found")
+	}
+	mvhd, ok := findBox(moov, "mvhd")
+	if !ok || len(mvhd) < 4 {
+		return 0, fmt.Errorf("MP4 mvhd box not found")
+	}
+	var timescale uint32
+	var duration uint64
+	switch mvhd[0] { // version
+	case 0:
+		if len(mvhd) < 20 {
+			return 0, fmt.Errorf("truncated MP4 mvhd box")
+		}
+		timescale = binary.BigEndian.Uint32(mvhd[12:16])
+		duration = uint64(binary.BigEndian.Uint32(mvhd[16:20]))
+	case 1:
+		if len(mvhd) < 32 {
+			return 0, fmt.Errorf("truncated MP4 mvhd box")
+		}
+		timescale = binary.BigEndian.Uint32(mvhd[20:24])
+		duration = binary.BigEndian.Uint64(mvhd[24:32])
+	default:
+		return 0, fmt.Errorf("unknown MP4 mvhd version %d", mvhd[0])
+	}
+	if timescale == 0 {
+		return 0, fmt.Errorf("MP4 mvhd timescale is zero")
+	}
+	return float64(duration) / float64(timescale), nil
+}
+
+// findBox returns the payload of the first top-level box of the given type in data.
+func findBox(data []byte, boxType string) ([]byte, bool) {
+	pos := 0
+	for pos+8 <= len(data) {
+		size := uint64(binary.BigEndian.Uint32(data[pos : pos+4]))
+		header := 8
+		switch size {
+		case 0: // box extends to end of file
+			size = uint64(len(data) - pos)
+		case 1: // 64-bit size follows the type
+			if pos+16 > len(data) {
+				return nil, false
+			}
+			size = binary.BigEndian.Uint64(data[pos+8 : pos+16])
+			header = 16
+		}
+		if size < uint64(header) || size > uint64(len(data)-pos) {
+			return nil, false
+		}
+		if string(data[pos+4:pos+8]) == boxType {
+			return data[pos+header : pos+int(size)], true
+		}
+		pos += int(size)
+	}
+	return nil, false
+}
+
+var (
+	ffprobeOnce  sync.Once
+	ffprobeAvail bool
+)
+
+// CheckFFprobe reports whether ffprobe is in PATH (checked once).
+func CheckFFprobe() bool {
+	ffprobeOnce.Do(func() {
+		_, err := exec.LookPath("ffprobe")
+		ffprobeAvail = err == nil
+	})
+	return ffprobeAvail
+}
+
+// ProbeFile returns the playback length in seconds of the audio file at path.
+// WAV and M4A are parsed in-process; other formats use ffprobe when installed.
+func ProbeFile(ctx context.Context, path string) (float64, error) {
+	ext := filepath.Ext(path)
+	if headerFormat(ext) != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return 0, err
+		}
+		return Duration(data, ext)
+	}
+	if !CheckFFprobe() {
+		return 0, fmt.Errorf("%w: %s (ffprobe not installed)", ErrUnsupportedFormat, ext)
+	}
+	out, err := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		path,
+	).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe: %w", err)
+	}
+	secs, err := strconv.ParseFloat(string(bytes.TrimSpace(out)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe: unexpected output %q", bytes.TrimSpace(out))
+	}
+	return secs, nil
+}
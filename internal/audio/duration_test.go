@@ -0,0 +1,94 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+// buildWAV returns a 16-bit mono WAV header plus dataLen bytes of silence.
+// declared overrides the data chunk size when non-zero.
+func buildWAV(sampleRate, dataLen int, declared uint32) []byte {
+	byteRate := sampleRate * 2
+	if declared == 0 {
+		declared = uint32(dataLen)
+	}
+	b := make([]byte, 0, 44+dataLen)
+	b = append(b, "RIFF"...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(36+dataLen))
+	b = append(b, "WAVE"...)
+	b = append(b, "fmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, 1) // PCM
+	b = binary.LittleEndian.AppendUint16(b, 1) // mono
+	b = binary.LittleEndian.AppendUint32(b, uint32(sampleRate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(byteRate))
+	b = binary.LittleEndian.AppendUint16(b, 2)
+	b = binary.LittleEndian.AppendUint16(b, 16)
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, declared)
+	return append(b, make([]byte, dataLen)...)
+}
+
+func box(typ string, payload ...[]byte) []byte {
+	size := 8
+	for _, p := range payload {
+		size += len(p)
+	}
+	b := binary.BigEndian.AppendUint32(nil, uint32(size))
+	b = append(b, typ...)
+	for _, p := range payload {
+		b = append(b, p...)
+	}
+	return b
+}
+
+// buildM4A returns a minimal ftyp + moov/mvhd (version 0) file.
+func buildM4A(timescale, duration uint32) []byte {
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:16], timescale)
+	binary.BigEndian.PutUint32(mvhd[16:20], duration)
+	return append(box("ftyp", []byte("M4A \x00\x00\x00\x00")),
+		box("moov", box("mvhd", mvhd))...)
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		format  string
+		want    float64
+		wantErr bool
+	}{
+		{"wav_8k_2s", buildWAV(8000, 32000, 0), "wav", 2.0, false},
+		{"wav_dot_ext", buildWAV(16000, 16000, 0), ".WAV", 0.5, false},
+		{"wav_truncated_uses_actual_bytes", buildWAV(8000, 16000, 0xFFFFFFFF), "wav", 1.0, false},
+		{"wav_garbage", []byte("not audio at all"), "wav", 0, true},
+		{"m4a_12s", buildM4A(44100, 44100*12), "m4a", 12.0, false},
+		{"m4a_no_moov", box("ftyp", []byte("M4A ")), "m4a", 0, true},
+		{"m4a_zero_timescale", buildM4A(0, 1000), "m4a", 0, true},
+		{"mp3_unsupported", []byte{0xFF, 0xFB}, "mp3", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Duration(tt.data, tt.format)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Duration = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Duration(nil, "ogg"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("ogg: err = %v, want ErrUnsupportedFormat", err)
+	}
+}
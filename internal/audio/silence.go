@@ -0,0 +1,99 @@
+package audio
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// Reasons a recording is unusable, as stored in calls.audio_unusable.
+const (
+	UnusableEmpty  = "empty"  // 0-byte or header-only file
+	UnusableSilent = "silent" // no frame reaches the silence level
+)
+
+// silenceLevelFloor is reported for frames of digital silence.
+const silenceLevelFloor = -120.0
+
+// PeakLevel returns the RMS level, in dBFS, of the loudest 20 ms frame of a
+// mono recording. Digital silence and recordings shorter than one frame
+// report -120.
+func PeakLevel(samples []int16, rate int) float64 {
+	frameLen := rate * speechFrameMs / 1000
+	if frameLen == 0 {
+		return silenceLevelFloor
+	}
+	peak := silenceLevelFloor
+	for i := 0; i+frameLen <= len(samples); i += frameLen {
+		var sum float64
+		for _, s := range samples[i : i+frameLen] {
+			v := float64(s) / 32768
+			sum += v * v
+		}
+		if sum == 0 {
+			continue
+		}
+		if l := 10 * math.Log10(sum/float64(frameLen)); l > peak {
+			peak = l
+		}
+	}
+	return peak
+}
+
+// Unusable checks a recording held in memory. It returns UnusableEmpty for a
+// file without audio and, for 16-bit PCM WAV, UnusableSilent when no frame
+// reaches silenceLevel dBFS. checked is false when the samples of the format
+// couldn't be analyzed, so only emptiness was tested.
+func Unusable(data []byte, format string, silenceLevel float64) (reason string, checked bool) {
+	if len(data) == 0 {
+		return UnusableEmpty, true
+	}
+	if d, err := Duration(data, format); err == nil && d <= 0 {
+		return UnusableEmpty, true
+	}
+	if headerFormat(format) != "wav" {
+		return "", false
+	}
+	samples, rate, err := wavSamples(data)
+	if err != nil {
+		return "", false
+	}
+	return samplesUnusable(samples, rate, silenceLevel), true
+}
+
+// UnusableFile checks a recording on disk like Unusable, decoding formats
+// other than WAV with ffmpeg when it is installed. checked is false when only
+// emptiness could be tested.
+func UnusableFile(ctx context.Context, path string, silenceLevel float64) (reason string, checked bool, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false, err
+	}
+	if info.Size() == 0 {
+		return UnusableEmpty, true, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, err
+	}
+	if reason, checked = Unusable(data, filepath.Ext(path), silenceLevel); checked || !CheckFFmpeg() {
+		return reason, checked, nil
+	}
+	const rate = 8000
+	samples, err := DecodeFile(ctx, path, rate)
+	if err != nil {
+		return "", false, err
+	}
+	return samplesUnusable(samples, rate, silenceLevel), true, nil
+}
+
+func samplesUnusable(samples []int16, rate int, silenceLevel float64) string {
+	if len(samples) == 0 {
+		return UnusableEmpty
+	}
+	if PeakLevel(samples, rate) < silenceLevel {
+		return UnusableSilent
+	}
+	return ""
+}
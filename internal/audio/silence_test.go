@@ -0,0 +1,70 @@
+package audio
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnusable(t *testing.T) {
+	const rate = 8000
+	tone := make([]int16, rate)
+	for i := range tone {
+		tone[i] = int16(4000 * math.Sin(2*math.Pi*440*float64(i)/rate))
+	}
+	faint := make([]int16, rate)
+	for i := range faint {
+		faint[i] = int16(i%3 - 1) // ±1 LSB, about -93 dBFS
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		format  string
+		reason  string
+		checked bool
+	}{
+		{"zero bytes", nil, "m4a", UnusableEmpty, true},
+		{"header only", pcmWAV(rate, nil), "wav", UnusableEmpty, true},
+		{"digital silence", pcmWAV(rate, make([]int16, rate)), "wav", UnusableSilent, true},
+		{"faint noise", pcmWAV(rate, faint), ".wav", UnusableSilent, true},
+		{"tone", pcmWAV(rate, tone), "wav", "", true},
+		{"unparsed mp3", []byte("not really mp3"), "mp3", "", false},
+	}
+	for _, tt := range tests {
+		reason, checked := Unusable(tt.data, tt.format, -60)
+		if reason != tt.reason || checked != tt.checked {
+			t.Errorf("%s: got (%q, %v), want (%q, %v)", tt.name, reason, checked, tt.reason, tt.checked)
+		}
+	}
+
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.m4a")
+	if err := os.WriteFile(empty, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if reason, checked, err := UnusableFile(t.Context(), empty, -60); err != nil || reason != UnusableEmpty || !checked {
+		t.Errorf("empty file: got (%q, %v, %v)", reason, checked, err)
+	}
+	silent := filepath.Join(dir, "silent.wav")
+	if err := os.WriteFile(silent, pcmWAV(rate, make([]int16, rate)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if reason, _, err := UnusableFile(t.Context(), silent, -60); err != nil || reason != UnusableSilent {
+		t.Errorf("silent file: got (%q, %v)", reason, err)
+	}
+}
+
+func TestPeakLevel(t *testing.T) {
+	if got := PeakLevel(make([]int16, 800), 8000); got != -120 {
+		t.Errorf("silence = %v, want -120", got)
+	}
+	full := make([]int16, 160)
+	for i := range full {
+		full[i] = 32767
+	}
+	if got := PeakLevel(full, 8000); got < -0.1 || got > 0 {
+		t.Errorf("full scale = %v, want ~0", got)
+	}
+}
@@ -0,0 +1,141 @@
+package audio
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"math/cmplx"
+)
+
+// Spectrogram defaults: at 8 kHz a 128-point FFT gives 64 bins of 62.5 Hz
+// covering 0–4 kHz, the whole band of trunked voice audio.
+const (
+	SpectrogramRate   = 8000
+	SpectrogramWidth  = 240
+	SpectrogramHeight = 64
+
+	// Levels are mapped to colors on a fixed dBFS scale rather than
+	// relative to the loudest bin, so dead air stays dark instead of
+	// having its noise floor stretched to full brightness.
+	spectrogramFloorDB = -100.0
+	spectrogramCeilDB  = -20.0
+)
+
+// Spectrogram renders samples (mono PCM at SpectrogramRate) as a
+// SpectrogramWidth×SpectrogramHeight image: time runs left to right, frequency
+// bottom to top. Each column is the average power of the FFT frames in its
+// share of the recording, so voice shows as harmonic bands, data bursts as
+// solid blocks and dead air as a dark, flat floor.
+func Spectrogram(samples []int16) *image.Paletted {
+	const w, h = SpectrogramWidth, SpectrogramHeight
+	const n = 2 * h // FFT size
+	img := image.NewPaletted(image.Rect(0, 0, w, h), spectrogramPalette)
+
+	window := make([]float64, n)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+	}
+	// A full-scale sine through the Hann window peaks at n/4
+	ref := float64(n) / 4
+
+	buf := make([]complex128, n)
+	power := make([]float64, h)
+	for x := 0; x < w; x++ {
+		lo := x * len(samples) / w
+		hi := (x + 1) * len(samples) / w
+		// Frames overlap by half; a column shorter than one frame uses the
+		// frame centered on it.
+		var starts []int
+		if hi-lo < n {
+			starts = []int{(lo+hi)/2 - n/2}
+		} else {
+			for s := lo; s+n <= hi; s += n / 2 {
+				starts = append(starts, s)
+			}
+		}
+
+		clear(power)
+		for _, s := range starts {
+			for i := range buf {
+				var v float64
+				if j := s + i; j >= 0 && j < len(samples) {
+					v = float64(samples[j]) / 32768
+				}
+				buf[i] = complex(v*window[i], 0)
+			}
+			fft(buf)
+			for k := range power {
+				m := cmplx.Abs(buf[k]) / ref
+				power[k] += m * m
+			}
+		}
+
+		for k, p := range power {
+			db := 10 * math.Log10(p/float64(len(starts))+1e-20)
+			level := (db - spectrogramFloorDB) / (spectrogramCeilDB - spectrogramFloorDB)
+			level = math.Max(0, math.Min(1, level))
+			img.SetColorIndex(x, h-1-k, uint8(level*float64(len(spectrogramPalette)-1)+0.5))
+		}
+	}
+	return img
+}
+
+// WriteSpectrogramPNG renders samples with Spectrogram and writes a PNG.
+func WriteSpectrogramPNG(wr io.Writer, samples []int16) error {
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	return enc.Encode(wr, Spectrogram(samples))
+}
+
+// fft is an in-place iterative radix-2 FFT; len(x) must be a power of two.
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			wk := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], x[start+k+size/2]*wk
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				wk *= step
+			}
+		}
+	}
+}
+
+// spectrogramPalette runs black → purple → red → orange → pale yellow,
+// interpolated to 64 colors.
+var spectrogramPalette = func() color.Palette {
+	stops := []color.RGBA{
+		{0, 0, 4, 255},
+		{87, 16, 110, 255},
+		{188, 55, 84, 255},
+		{249, 142, 9, 255},
+		{252, 255, 164, 255},
+	}
+	const size = 64
+	p := make(color.Palette, size)
+	for i := range p {
+		t := float64(i) / (size - 1) * float64(len(stops)-1)
+		s := int(t)
+		if s >= len(stops)-1 {
+			s = len(stops) - 2
+		}
+		f := t - float64(s)
+		a, b := stops[s], stops[s+1]
+		lerp := func(u, v uint8) uint8 { return uint8(float64(u) + (float64(v)-float64(u))*f + 0.5) }
+		p[i] = color.RGBA{lerp(a.R, b.R), lerp(a.G, b.G), lerp(a.B, b.B), 255}
+	}
+	return p
+}()
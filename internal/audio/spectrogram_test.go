@@ -0,0 +1,68 @@
+package audio
+
+import (
+	"bytes"
+	"image/png"
+	"math"
+	"testing"
+)
+
+func TestSpectrogram(t *testing.T) {
+	const rate = SpectrogramRate
+	// 1 kHz tone for the first half, silence for the second
+	samples := make([]int16, 4*rate)
+	for i := 0; i < len(samples)/2; i++ {
+		samples[i] = int16(16000 * math.Sin(2*math.Pi*1000*float64(i)/rate))
+	}
+	img := Spectrogram(samples)
+	if b := img.Bounds(); b.Dx() != SpectrogramWidth || b.Dy() != SpectrogramHeight {
+		t.Fatalf("size = %v", b)
+	}
+
+	// 1 kHz is bin 16 of 64, counted from the bottom row
+	toneRow := SpectrogramHeight - 1 - 1000*2*SpectrogramHeight/rate
+	x := SpectrogramWidth / 4
+	peak := img.ColorIndexAt(x, toneRow)
+	if peak < 50 {
+		t.Errorf("tone level = %d, want bright", peak)
+	}
+	for y := 0; y < SpectrogramHeight; y++ {
+		if y < toneRow-2 || y > toneRow+2 {
+			if v := img.ColorIndexAt(x, y); v >= peak/2 {
+				t.Errorf("row %d level %d, want well below tone %d", y, v, peak)
+			}
+		}
+	}
+	// Silence renders at the floor
+	if v := img.ColorIndexAt(3*SpectrogramWidth/4, toneRow); v != 0 {
+		t.Errorf("silence level = %d, want 0", v)
+	}
+
+	// Very short and empty recordings still render
+	for _, n := range []int{0, 10} {
+		var buf bytes.Buffer
+		if err := WriteSpectrogramPNG(&buf, make([]int16, n)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := png.Decode(&buf); err != nil {
+			t.Errorf("%d samples: %v", n, err)
+		}
+	}
+}
+
+func TestFFT(t *testing.T) {
+	x := make([]complex128, 16)
+	for i := range x {
+		x[i] = complex(math.Cos(2*math.Pi*3*float64(i)/16), 0)
+	}
+	fft(x)
+	for k, v := range x {
+		want := 0.0
+		if k == 3 || k == 13 {
+			want = 8
+		}
+		if math.Abs(real(v)-want) > 1e-9 || math.Abs(imag(v)) > 1e-9 {
+			t.Errorf("X[%d] = %v, want %v", k, v, want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Speech detection thresholds. A frame counts as speech when its level is
+// well above the recording's own noise floor and above absolute silence, so
+// a constant hiss or hum (an open mic with nobody talking) scores near zero.
+const (
+	speechFrameMs     = 20
+	speechAboveFloor  = 12.0  // dB over the noise floor
+	speechMinLevel    = -50.0 // dBFS
+	noiseFloorPercent = 0.10  // quietest share of frames taken as the floor
+)
+
+// SpeechRatio estimates the share of a recording that contains speech, from
+// 0 to 1, using frame energy relative to the recording's noise floor. Only
+// 16-bit PCM WAV is analyzed; other formats return ErrUnsupportedFormat.
+func SpeechRatio(data []byte, format string) (float64, error) {
+	if headerFormat(format) != "wav" {
+		return 0, ErrUnsupportedFormat
+	}
+	samples, rate, err := wavSamples(data)
+	if err != nil {
+		return 0, err
+	}
+	frameLen := rate * speechFrameMs / 1000
+	if frameLen == 0 || len(samples) < frameLen {
+		return 0, fmt.Errorf("WAV too short to analyze")
+	}
+
+	levels := make([]float64, 0, len(samples)/frameLen)
+	for i := 0; i+frameLen <= len(samples); i += frameLen {
+		var sum float64
+		for _, s := range samples[i : i+frameLen] {
+			v := float64(s) / 32768
+			sum += v * v
+		}
+		levels = append(levels, 10*math.Log10(sum/float64(frameLen)+1e-12))
+	}
+
+	sorted := append([]float64(nil), levels...)
+	sort.Float64s(sorted)
+	floor := sorted[int(float64(len(sorted))*noiseFloorPercent)]
+
+	speech := 0
+	for _, l := range levels {
+		if l > floor+speechAboveFloor && l > speechMinLevel {
+			speech++
+		}
+	}
+	return float64(speech) / float64(len(levels)), nil
+}
+
+// wavSamples returns the first channel of a 16-bit PCM WAV and its sample rate.
+func wavSamples(data []byte) ([]int16, int, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, 0, fmt.Errorf("not a WAV file")
+	}
+	var channels, bits, rate int
+	pos := 12
+	for pos+8 <= len(data) {
+		id := string(data[pos : pos+4])
+		size := binary.LittleEndian.Uint32(data[pos+4 : pos+8])
+		body := pos + 8
+		switch id {
+		case "fmt ":
+			if body+16 > len(data) {
+				return nil, 0, fmt.Errorf("truncated WAV fmt chunk")
+			}
+			if f := binary.LittleEndian.Uint16(data[body : body+2]); f != 1 {
+				return nil, 0, fmt.Errorf("%w: WAV encoding %d", ErrUnsupportedFormat, f)
+			}
+			channels = int(binary.LittleEndian.Uint16(data[body+2 : body+4]))
+			rate = int(binary.LittleEndian.Uint32(data[body+4 : body+8]))
+			bits = int(binary.LittleEndian.Uint16(data[body+14 : body+16]))
+		case "data":
+			if rate == 0 {
+				return nil, 0, fmt.Errorf("WAV data chunk before fmt chunk")
+			}
+			if bits != 16 || channels < 1 {
+				return nil, 0, fmt.Errorf("%w: %d-bit WAV", ErrUnsupportedFormat, bits)
+			}
+			n := int(size)
+			if avail := len(data) - body; n > avail {
+				n = avail
+			}
+			stride := 2 * channels
+			samples := make([]int16, 0, n/stride)
+			for i := body; i+2 <= body+n; i += stride {
+				samples = append(samples, int16(binary.LittleEndian.Uint16(data[i:i+2])))
+			}
+			return samples, rate, nil
+		}
+		pos = body + int(size) + int(size&1)
+	}
+	return nil, 0, fmt.Errorf("WAV data chunk not found")
+}
@@ -0,0 +1,61 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// pcmWAV wraps 16-bit mono samples in a WAV header.
+func pcmWAV(rate int, samples []int16) []byte {
+	b := buildWAV(rate, 0, uint32(len(samples)*2))
+	for _, s := range samples {
+		b = binary.LittleEndian.AppendUint16(b, uint16(s))
+	}
+	return b
+}
+
+func TestSpeechRatio(t *testing.T) {
+	const rate = 8000
+	rng := rand.New(rand.NewSource(1))
+	hiss := func(n int) []int16 {
+		out := make([]int16, n)
+		for i := range out {
+			out[i] = int16(rng.Intn(200) - 100)
+		}
+		return out
+	}
+	tone := func(n int) []int16 {
+		out := make([]int16, n)
+		for i := range out {
+			out[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(i)/rate))
+		}
+		return out
+	}
+
+	// Open mic: 10 s of steady background noise
+	if r, err := SpeechRatio(pcmWAV(rate, hiss(10*rate)), "wav"); err != nil || r > 0.05 {
+		t.Errorf("open mic ratio = %.2f, %v; want ~0", r, err)
+	}
+
+	// Talking half the time
+	var talk []int16
+	for i := 0; i < 5; i++ {
+		talk = append(talk, hiss(rate)...)
+		talk = append(talk, tone(rate)...)
+	}
+	if r, err := SpeechRatio(pcmWAV(rate, talk), ".wav"); err != nil || r < 0.45 || r > 0.55 {
+		t.Errorf("half speech ratio = %.2f, %v; want ~0.5", r, err)
+	}
+
+	// Digital silence
+	if r, err := SpeechRatio(pcmWAV(rate, make([]int16, rate)), "wav"); err != nil || r != 0 {
+		t.Errorf("silence ratio = %.2f, %v; want 0", r, err)
+	}
+
+	if _, err := SpeechRatio([]byte("not audio"), "m4a"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("m4a err = %v, want ErrUnsupportedFormat", err)
+	}
+}
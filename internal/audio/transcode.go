@@ -0,0 +1,90 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TranscodeOpus re-encodes audio (format is its extension, e.g. "m4a") to
+// mono Opus in an Ogg container at bitrate bps. Needs ffmpeg with libopus;
+// returns ErrUnsupportedFormat without ffmpeg.
+func TranscodeOpus(ctx context.Context, data []byte, format string, bitrate int) ([]byte, error) {
+	return transcode(ctx, data, format, "opus",
+		"-ac", "1",
+		"-c:a", "libopus", "-b:a", strconv.Itoa(bitrate), "-application", "voip",
+		"-f", "ogg",
+	)
+}
+
+// TranscodeMP3 re-encodes audio to mono MP3 at sampleRate Hz and bitrate
+// bps, without ID3 tags or a Xing header, so that the output of several
+// calls can be concatenated into one stream. Needs ffmpeg with libmp3lame;
+// returns ErrUnsupportedFormat without ffmpeg.
+func TranscodeMP3(ctx context.Context, data []byte, format string, bitrate, sampleRate int) ([]byte, error) {
+	return transcode(ctx, data, format, "mp3",
+		"-ac", "1", "-ar", strconv.Itoa(sampleRate),
+		"-c:a", "libmp3lame", "-b:a", strconv.Itoa(bitrate),
+		"-write_xing", "0", "-id3v2_version", "0",
+		"-f", "mp3",
+	)
+}
+
+// transcode runs ffmpeg over data with the given output arguments and
+// returns what it writes to stdout.
+func transcode(ctx context.Context, data []byte, format, target string, args ...string) ([]byte, error) {
+	if !CheckFFmpeg() {
+		return nil, fmt.Errorf("%w: transcode to %s (ffmpeg not installed)", ErrUnsupportedFormat, target)
+	}
+	// MP4 input needs a seekable file (the moov atom may sit at the end).
+	tmp, err := os.CreateTemp("", "tr-transcode-*."+strings.TrimPrefix(format, "."))
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return runFFmpeg(ctx, append([]string{"-i", tmp.Name()}, args...)...)
+}
+
+// SilenceMP3 returns d of silence encoded like TranscodeMP3's output.
+// Needs ffmpeg with libmp3lame; returns ErrUnsupportedFormat without ffmpeg.
+func SilenceMP3(ctx context.Context, d time.Duration, bitrate, sampleRate int) ([]byte, error) {
+	if !CheckFFmpeg() {
+		return nil, fmt.Errorf("%w: encode silence (ffmpeg not installed)", ErrUnsupportedFormat)
+	}
+	return runFFmpeg(ctx,
+		"-f", "lavfi", "-i", "anullsrc=channel_layout=mono:sample_rate="+strconv.Itoa(sampleRate),
+		"-t", strconv.FormatFloat(d.Seconds(), 'f', 3, 64),
+		"-c:a", "libmp3lame", "-b:a", strconv.Itoa(bitrate),
+		"-write_xing", "0", "-id3v2_version", "0",
+		"-f", "mp3",
+	)
+}
+
+// runFFmpeg runs ffmpeg with args and returns what it writes to stdout.
+func runFFmpeg(ctx context.Context, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	args = append([]string{"-v", "error"}, args...)
+	cmd := exec.CommandContext(ctx, "ffmpeg", append(args, "pipe:1")...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("ffmpeg produced no output")
+	}
+	return out, nil
+}
@@ -0,0 +1,343 @@
+// Package audioarchive copies call audio out of trunk-recorder's capture
+// directory into tr-engine's audio store.
+//
+// With TR_AUDIO_DIR set, tr-engine doesn't save audio itself: calls are served
+// from the files trunk-recorder leaves behind. When TR purges its capture dir
+// that audio is gone. The archiver walks calls that only have a TR
+// call_filename, oldest first, and copies each file into the store once the
+// call is old enough that TR has finished writing it, setting audio_file_path
+// so the store is used from then on. In verify mode the stored copy is read
+// back and its SHA-256 compared with the source before the call is updated.
+// Failed copies are recorded and retried until they give up or age out of
+// TR's purge window.
+package audioarchive
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/audio"
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/storage"
+)
+
+const (
+	ModeCopy   = "copy"
+	ModeVerify = "verify"
+)
+
+// Options configures an Archiver.
+type Options struct {
+	Mode        string        // ModeCopy or ModeVerify
+	AudioDir    string        // AUDIO_DIR, for call_filenames already under it
+	TRAudioDir  string        // TR_AUDIO_DIR
+	Delay       time.Duration // minimum call age before copying
+	Window      time.Duration // TR's purge window; older calls are not attempted
+	Interval    time.Duration // how often to look for calls to copy
+	BatchSize   int           // calls per query (default 100)
+	MaxAttempts int           // failed copies before giving up on a call (default 5)
+}
+
+// RunResult summarizes one archive run.
+type RunResult struct {
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Copied     int        `json:"copied"`
+	Failed     int        `json:"failed"`
+	Bytes      int64      `json:"bytes"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Totals counts archive work since startup.
+type Totals struct {
+	Copied int64 `json:"copied"`
+	Failed int64 `json:"failed"`
+	Bytes  int64 `json:"bytes"`
+}
+
+// Status reports the archiver's configuration and progress.
+type Status struct {
+	Mode    string `json:"mode"`
+	Delay   string `json:"delay"`
+	Window  string `json:"window"`
+	Running bool   `json:"running"`
+	database.AudioArchiveStats
+	// Deadline is when the oldest uncopied call ages out of TR's window.
+	Deadline *time.Time `json:"deadline,omitempty"`
+	Totals   Totals     `json:"totals"`
+	LastRun  *RunResult `json:"last_run"`
+}
+
+// Archiver copies TR-served audio into the store in the background.
+type Archiver struct {
+	db    *database.DB
+	store storage.AudioStore
+	opts  Options
+	log   zerolog.Logger
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+
+	running atomic.Bool
+	copied  atomic.Int64
+	failed  atomic.Int64
+	bytes   atomic.Int64
+
+	mu      sync.Mutex
+	lastRun *RunResult
+}
+
+// New creates an archiver.
+func New(db *database.DB, store storage.AudioStore, opts Options, log zerolog.Logger) *Archiver {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Archiver{
+		db:     db,
+		store:  store,
+		opts:   opts,
+		log:    log.With().Str("component", "audio-archive").Logger(),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (a *Archiver) Start() { go a.loop() }
+
+// Stop ends background work, including a run in progress.
+func (a *Archiver) Stop() { a.stopOnce.Do(a.cancel) }
+
+func (a *Archiver) loop() {
+	ticker := time.NewTicker(a.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.tick()
+		case <-a.ctx.Done():
+			return
+		}
+	}
+}
+
+func (a *Archiver) tick() {
+	if !a.running.CompareAndSwap(false, true) {
+		return
+	}
+	defer a.running.Store(false)
+	a.run()
+}
+
+// Run starts a run now in the background. Returns an error if one is already
+// in progress.
+func (a *Archiver) Run() error {
+	if !a.running.CompareAndSwap(false, true) {
+		return fmt.Errorf("archive run already in progress")
+	}
+	go func() {
+		defer a.running.Store(false)
+		a.run()
+	}()
+	return nil
+}
+
+// run copies every eligible call, a batch at a time. The caller holds running.
+func (a *Archiver) run() {
+	res := &RunResult{StartedAt: time.Now()}
+	a.mu.Lock()
+	a.lastRun = res
+	a.mu.Unlock()
+
+	err := a.copyPending(res)
+
+	now := time.Now()
+	a.mu.Lock()
+	res.FinishedAt = &now
+	if err != nil {
+		res.Error = err.Error()
+	}
+	a.mu.Unlock()
+
+	switch {
+	case err != nil:
+		a.log.Warn().Err(err).Int("copied", res.Copied).Int("failed", res.Failed).Msg("audio archive run failed")
+	case res.Failed > 0:
+		a.log.Warn().Int("copied", res.Copied).Int("failed", res.Failed).Msg("audio archive run finished with failures")
+	case res.Copied > 0:
+		a.log.Info().Int("copied", res.Copied).Int64("bytes", res.Bytes).Msg("audio archived")
+	}
+}
+
+func (a *Archiver) copyPending(res *RunResult) error {
+	// Failed calls are skipped for the rest of the run (and the next few).
+	retryAfter := 5 * a.opts.Interval
+	for {
+		if err := a.ctx.Err(); err != nil {
+			return err
+		}
+		now := time.Now()
+		batch, err := a.db.ListAudioArchiveCandidates(a.ctx,
+			now.Add(-a.opts.Window), now.Add(-a.opts.Delay), a.opts.MaxAttempts, retryAfter, a.opts.BatchSize)
+		if err != nil {
+			return fmt.Errorf("list candidates: %w", err)
+		}
+		for _, c := range batch {
+			if err := a.ctx.Err(); err != nil {
+				return err
+			}
+			a.archive(c, res)
+		}
+		if len(batch) < a.opts.BatchSize {
+			return nil
+		}
+	}
+}
+
+// archive copies one call's audio and records the outcome.
+func (a *Archiver) archive(c database.AudioArchiveCandidate, res *RunResult) {
+	ctx, cancel := context.WithTimeout(a.ctx, time.Minute)
+	defer cancel()
+
+	key, size, err := a.copyFile(ctx, c)
+	if err == nil {
+		err = a.db.AddCallAudioVariant(ctx, database.AudioVariant{
+			CallID:        c.CallID,
+			CallStartTime: c.StartTime,
+			Variant:       database.AudioVariantOriginal,
+			AudioPath:     key,
+			AudioType:     strings.TrimPrefix(filepath.Ext(key), "."),
+			AudioSize:     size,
+			Source:        "archive",
+		}, true)
+	}
+	if err != nil {
+		a.log.Debug().Err(err).Int64("call_id", c.CallID).Str("call_filename", c.CallFilename).Msg("audio archive failed")
+		if recErr := a.db.RecordAudioArchiveFailure(ctx, c.CallID, c.StartTime, err.Error()); recErr != nil {
+			a.log.Warn().Err(recErr).Int64("call_id", c.CallID).Msg("failed to record audio archive failure")
+		}
+		if c.Attempts+1 == a.opts.MaxAttempts {
+			a.log.Warn().Err(err).Int64("call_id", c.CallID).Str("call_filename", c.CallFilename).
+				Msg("giving up on archiving call audio")
+		}
+		a.mu.Lock()
+		res.Failed++
+		a.mu.Unlock()
+		a.failed.Add(1)
+		return
+	}
+	if c.Attempts > 0 {
+		if err := a.db.ClearAudioArchiveFailure(ctx, c.CallID, c.StartTime); err != nil {
+			a.log.Warn().Err(err).Int64("call_id", c.CallID).Msg("failed to clear audio archive failure")
+		}
+	}
+	a.mu.Lock()
+	res.Copied++
+	res.Bytes += int64(size)
+	a.mu.Unlock()
+	a.copied.Add(1)
+	a.bytes.Add(int64(size))
+}
+
+var errNotFound = errors.New("audio file not found under TR_AUDIO_DIR")
+
+// copyFile stores the TR file for c and returns its store key and size. In
+// verify mode the stored copy is read back and compared with the source.
+func (a *Archiver) copyFile(ctx context.Context, c database.AudioArchiveCandidate) (string, int, error) {
+	path := audio.ResolveFile(a.opts.AudioDir, a.opts.TRAudioDir, "", c.CallFilename)
+	if path == "" {
+		return "", 0, errNotFound
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(data) == 0 {
+		return "", 0, fmt.Errorf("audio file is empty")
+	}
+
+	key := archiveKey(c.SiteShortName, c.StartTime, path)
+	if err := a.store.Save(ctx, key, data, storage.ContentTypeFromExt(filepath.Ext(path))); err != nil {
+		return "", 0, fmt.Errorf("save: %w", err)
+	}
+	if a.opts.Mode == ModeVerify {
+		if err := a.verify(ctx, key, data); err != nil {
+			return "", 0, err
+		}
+	}
+	return key, len(data), nil
+}
+
+// verify reads key back from the store and checks it matches data.
+func (a *Archiver) verify(ctx context.Context, key string, data []byte) error {
+	rc, err := a.store.Open(ctx, key)
+	if err != nil {
+		return fmt.Errorf("verify: open stored copy: %w", err)
+	}
+	defer rc.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, rc); err != nil {
+		return fmt.Errorf("verify: read stored copy: %w", err)
+	}
+	want := sha256.Sum256(data)
+	if !bytes.Equal(h.Sum(nil), want[:]) {
+		return fmt.Errorf("verify: stored copy of %s does not match source", key)
+	}
+	return nil
+}
+
+// archiveKey builds the store key for a call's audio, in the same
+// {sys_name}/{YYYY-MM-DD}/{filename} layout ingest uses for audio it saves.
+func archiveKey(sysName string, startTime time.Time, path string) string {
+	if sysName == "" {
+		sysName = "unknown"
+	}
+	return filepath.ToSlash(filepath.Join(sysName, startTime.Local().Format("2006-01-02"), filepath.Base(path)))
+}
+
+// Status returns the archive window's counts and run progress.
+func (a *Archiver) Status(ctx context.Context) (Status, error) {
+	stats, err := a.db.GetAudioArchiveStats(ctx, time.Now().Add(-a.opts.Window), a.opts.MaxAttempts)
+	if err != nil {
+		return Status{}, err
+	}
+	s := Status{
+		Mode:              a.opts.Mode,
+		Delay:             a.opts.Delay.String(),
+		Window:            a.opts.Window.String(),
+		Running:           a.running.Load(),
+		AudioArchiveStats: stats,
+		Totals: Totals{
+			Copied: a.copied.Load(),
+			Failed: a.failed.Load(),
+			Bytes:  a.bytes.Load(),
+		},
+	}
+	if stats.OldestPending != nil {
+		d := stats.OldestPending.Add(a.opts.Window)
+		s.Deadline = &d
+	}
+	a.mu.Lock()
+	if a.lastRun != nil {
+		r := *a.lastRun
+		s.LastRun = &r
+	}
+	a.mu.Unlock()
+	return s, nil
+}
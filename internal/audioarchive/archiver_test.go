@@ -0,0 +1,74 @@
+package audioarchive
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/storage"
+)
+
+func TestArchiveKey(t *testing.T) {
+	start := time.Date(2026, 3, 1, 14, 0, 0, 0, time.Local)
+	got := archiveKey("warco", start, "/app/tr_audio/warco/2026/3/1/9131-1772373600_851012500.m4a")
+	if want := "warco/2026-03-01/9131-1772373600_851012500.m4a"; got != want {
+		t.Errorf("archiveKey = %q, want %q", got, want)
+	}
+	if got := archiveKey("", start, "x.wav"); got != "unknown/2026-03-01/x.wav" {
+		t.Errorf("archiveKey without sys_name = %q", got)
+	}
+}
+
+func TestCopyFile(t *testing.T) {
+	trDir := t.TempDir()
+	storeDir := t.TempDir()
+	src := filepath.Join(trDir, "warco", "2026", "3", "1", "call.m4a")
+	if err := os.MkdirAll(filepath.Dir(src), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(src, []byte("not really m4a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 3, 1, 14, 0, 0, 0, time.Local)
+
+	for _, mode := range []string{ModeCopy, ModeVerify} {
+		t.Run(mode, func(t *testing.T) {
+			store := storage.NewLocalStore(storeDir)
+			a := New(nil, store, Options{Mode: mode, TRAudioDir: trDir}, zerolog.Nop())
+
+			key, size, err := a.copyFile(context.Background(), database.AudioArchiveCandidate{
+				SiteShortName: "warco",
+				StartTime:     start,
+				// TR's own path; resolved under TR_AUDIO_DIR from the sys_name dir down
+				CallFilename: "/app/tr_audio/warco/2026/3/1/call.m4a",
+			})
+			if err != nil {
+				t.Fatalf("copyFile: %v", err)
+			}
+			if key != "warco/2026-03-01/call.m4a" || size != 14 {
+				t.Errorf("key, size = %q, %d", key, size)
+			}
+			got, err := os.ReadFile(filepath.Join(storeDir, key))
+			if err != nil || string(got) != "not really m4a" {
+				t.Errorf("stored copy = %q, %v", got, err)
+			}
+		})
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		a := New(nil, storage.NewLocalStore(storeDir), Options{Mode: ModeCopy, TRAudioDir: trDir}, zerolog.Nop())
+		_, _, err := a.copyFile(context.Background(), database.AudioArchiveCandidate{
+			SiteShortName: "warco",
+			StartTime:     start,
+			CallFilename:  "/app/tr_audio/warco/2026/3/1/purged.m4a",
+		})
+		if err != errNotFound {
+			t.Errorf("err = %v, want errNotFound", err)
+		}
+	})
+}
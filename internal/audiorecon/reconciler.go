@@ -0,0 +1,449 @@
+// Package audiorecon recovers call audio whose MQTT audio message was lost.
+//
+// A call whose audio message never arrived ends with neither an
+// audio_file_path nor a call_filename, although trunk-recorder usually still
+// wrote the file to its capture directory. The reconciler walks such calls,
+// oldest first, once they are old enough that TR has finished writing, and
+// looks for a file under WATCH_DIR and TR_AUDIO_DIR in TR's
+// {short_name}/YYYY/M/D layout named for the call's talkgroup with a start
+// time within the tolerance. A file found is set as the call's call_filename
+// and the call is queued for transcription. Calls not found are searched
+// again each interval until the attempts run out and they are recorded as
+// permanently missing. Every outcome is kept in audio_reconciliation for the
+// report.
+package audiorecon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// Options configures a Reconciler.
+type Options struct {
+	Dirs        []string      // capture directories searched (WATCH_DIR, TR_AUDIO_DIR)
+	Delay       time.Duration // minimum call age before searching
+	Window      time.Duration // calls older than this are not searched
+	Interval    time.Duration // how often to look for calls without audio
+	Tolerance   time.Duration // largest difference between the file's and the call's start time
+	MaxAttempts int           // searches before a call is recorded as missing (default 3)
+	BatchSize   int           // calls per query (default 100)
+}
+
+// Pipeline is the part of the ingest pipeline the reconciler uses.
+type Pipeline interface {
+	KeepsCallAudio(systemID, tgid int, startTime time.Time) bool
+	EnqueueTranscription(ref database.CallRef) bool
+}
+
+// RunResult summarizes one reconciliation run.
+type RunResult struct {
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	Checked      int        `json:"checked"`
+	Recovered    int        `json:"recovered"`
+	Searching    int        `json:"searching"` // not found, searched again next run
+	Missing      int        `json:"missing"`   // not found on the last attempt
+	Skipped      int        `json:"skipped"`
+	Transcribing int        `json:"transcribing"` // recovered calls queued for transcription
+	Error        string     `json:"error,omitempty"`
+}
+
+// Totals counts reconciliation outcomes since startup.
+type Totals struct {
+	Recovered    int64 `json:"recovered"`
+	Missing      int64 `json:"missing"`
+	Skipped      int64 `json:"skipped"`
+	Transcribing int64 `json:"transcribing"`
+}
+
+// Status reports the reconciler's configuration and the window's outcomes.
+type Status struct {
+	Dirs        []string `json:"dirs"`
+	Delay       string   `json:"delay"`
+	Window      string   `json:"window"`
+	Tolerance   string   `json:"tolerance"`
+	MaxAttempts int      `json:"max_attempts"`
+	Running     bool     `json:"running"`
+	database.ReconcileStats
+	Totals  Totals     `json:"totals"`
+	LastRun *RunResult `json:"last_run"`
+}
+
+// Reconciler attaches capture-dir files to calls missing audio in the
+// background.
+type Reconciler struct {
+	db       *database.DB
+	pipeline Pipeline
+	opts     Options
+	log      zerolog.Logger
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+
+	running      atomic.Bool
+	recovered    atomic.Int64
+	missing      atomic.Int64
+	skipped      atomic.Int64
+	transcribing atomic.Int64
+
+	mu      sync.Mutex
+	lastRun *RunResult
+}
+
+// New creates a reconciler.
+func New(db *database.DB, pipeline Pipeline, opts Options, log zerolog.Logger) *Reconciler {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	var dirs []string
+	for _, d := range opts.Dirs {
+		if d != "" && !slices.Contains(dirs, filepath.Clean(d)) {
+			dirs = append(dirs, filepath.Clean(d))
+		}
+	}
+	opts.Dirs = dirs
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Reconciler{
+		db:       db,
+		pipeline: pipeline,
+		opts:     opts,
+		log:      log.With().Str("component", "audio-reconcile").Logger(),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+func (r *Reconciler) Start() { go r.loop() }
+
+// Stop ends background work, including a run in progress.
+func (r *Reconciler) Stop() { r.stopOnce.Do(r.cancel) }
+
+func (r *Reconciler) loop() {
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.tick()
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Reconciler) tick() {
+	if !r.running.CompareAndSwap(false, true) {
+		return
+	}
+	defer r.running.Store(false)
+	r.run()
+}
+
+// Run starts a run now in the background. Returns an error if one is already
+// in progress.
+func (r *Reconciler) Run() error {
+	if !r.running.CompareAndSwap(false, true) {
+		return fmt.Errorf("reconciliation run already in progress")
+	}
+	go func() {
+		defer r.running.Store(false)
+		r.run()
+	}()
+	return nil
+}
+
+// run searches for every eligible call, a batch at a time. The caller holds
+// running.
+func (r *Reconciler) run() {
+	res := &RunResult{StartedAt: time.Now()}
+	r.mu.Lock()
+	r.lastRun = res
+	r.mu.Unlock()
+
+	err := r.reconcilePending(res)
+
+	now := time.Now()
+	r.mu.Lock()
+	res.FinishedAt = &now
+	if err != nil {
+		res.Error = err.Error()
+	}
+	r.mu.Unlock()
+
+	switch {
+	case err != nil:
+		r.log.Warn().Err(err).Int("recovered", res.Recovered).Msg("audio reconciliation run failed")
+	case res.Recovered > 0 || res.Missing > 0:
+		r.log.Info().Int("recovered", res.Recovered).Int("missing", res.Missing).
+			Int("searching", res.Searching).Msg("audio reconciliation run finished")
+	}
+}
+
+func (r *Reconciler) reconcilePending(res *RunResult) error {
+	// Calls not found are skipped for the rest of the run.
+	retryAfter := max(r.opts.Interval/2, time.Minute)
+	idx := newCaptureIndex()
+	for {
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
+		now := time.Now()
+		batch, err := r.db.ListReconcileCandidates(r.ctx,
+			now.Add(-r.opts.Window), now.Add(-r.opts.Delay), retryAfter, r.opts.BatchSize)
+		if err != nil {
+			return fmt.Errorf("list candidates: %w", err)
+		}
+		for _, c := range batch {
+			if err := r.ctx.Err(); err != nil {
+				return err
+			}
+			if err := r.reconcile(c, idx, res); err != nil {
+				return err
+			}
+		}
+		if len(batch) < r.opts.BatchSize {
+			return nil
+		}
+	}
+}
+
+// reconcile searches for one call's audio and records the outcome.
+func (r *Reconciler) reconcile(c database.ReconcileCandidate, idx *captureIndex, res *RunResult) error {
+	ctx, cancel := context.WithTimeout(r.ctx, 30*time.Second)
+	defer cancel()
+
+	status, path := database.ReconcileSkipped, ""
+	if r.pipeline.KeepsCallAudio(c.SystemID, c.Tgid, c.StartTime) {
+		var err error
+		if path, err = r.find(ctx, c, idx); err != nil {
+			return err
+		}
+		switch {
+		case path != "":
+			status = database.ReconcileRecovered
+		case c.Attempts+1 >= r.opts.MaxAttempts:
+			status = database.ReconcileMissing
+		default:
+			status = database.ReconcileSearching
+		}
+	}
+
+	if path != "" {
+		if err := r.db.UpdateCallFilename(ctx, c.CallID, c.StartTime, path); err != nil {
+			return fmt.Errorf("attach audio to call %d: %w", c.CallID, err)
+		}
+	}
+	if err := r.db.RecordReconcileResult(ctx, c, status, path); err != nil {
+		return fmt.Errorf("record call %d: %w", c.CallID, err)
+	}
+
+	transcribing := path != "" && r.pipeline.EnqueueTranscription(database.CallRef{CallID: c.CallID, StartTime: c.StartTime})
+	if path != "" {
+		r.log.Debug().Int64("call_id", c.CallID).Str("path", path).Msg("call audio recovered")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res.Checked++
+	switch status {
+	case database.ReconcileRecovered:
+		res.Recovered++
+		r.recovered.Add(1)
+		if transcribing {
+			res.Transcribing++
+			r.transcribing.Add(1)
+		}
+	case database.ReconcileMissing:
+		res.Missing++
+		r.missing.Add(1)
+	case database.ReconcileSearching:
+		res.Searching++
+	case database.ReconcileSkipped:
+		res.Skipped++
+		r.skipped.Add(1)
+	}
+	return nil
+}
+
+// find returns the capture file for a call, or "" when there is none. A file
+// already attached to another call is passed over.
+func (r *Reconciler) find(ctx context.Context, c database.ReconcileCandidate, idx *captureIndex) (string, error) {
+	if c.SiteShortName == "" {
+		return "", nil
+	}
+	var files []captureFile
+	for _, dir := range captureDirs(r.opts.Dirs, c.SiteShortName, c.StartTime, r.opts.Tolerance) {
+		files = append(files, idx.list(dir)...)
+	}
+	for _, f := range matchFiles(files, c.Tgid, c.StartTime, r.opts.Tolerance) {
+		taken, err := r.db.CallFilenameTaken(ctx, c.SystemID, filepath.Base(f.path), c.StartTime)
+		if err != nil {
+			return "", fmt.Errorf("check %s: %w", f.path, err)
+		}
+		if !taken {
+			return f.path, nil
+		}
+	}
+	return "", nil
+}
+
+// Status returns the window's outcomes and run progress.
+func (r *Reconciler) Status(ctx context.Context) (Status, error) {
+	now := time.Now()
+	stats, err := r.db.GetReconcileStats(ctx, now.Add(-r.opts.Window), now.Add(-r.opts.Delay))
+	if err != nil {
+		return Status{}, err
+	}
+	s := Status{
+		Dirs:           r.opts.Dirs,
+		Delay:          r.opts.Delay.String(),
+		Window:         r.opts.Window.String(),
+		Tolerance:      r.opts.Tolerance.String(),
+		MaxAttempts:    r.opts.MaxAttempts,
+		Running:        r.running.Load(),
+		ReconcileStats: stats,
+		Totals: Totals{
+			Recovered:    r.recovered.Load(),
+			Missing:      r.missing.Load(),
+			Skipped:      r.skipped.Load(),
+			Transcribing: r.transcribing.Load(),
+		},
+	}
+	r.mu.Lock()
+	if r.lastRun != nil {
+		run := *r.lastRun
+		s.LastRun = &run
+	}
+	r.mu.Unlock()
+	return s, nil
+}
+
+// Window returns how far back calls are searched.
+func (r *Reconciler) Window() time.Duration { return r.opts.Window }
+
+// captureFile is an audio file in a capture directory, with the talkgroup and
+// start time from its name.
+type captureFile struct {
+	path  string
+	tgid  int
+	start int64 // unix seconds
+	rank  int   // extension preference, lower first
+}
+
+// captureNameRe matches trunk-recorder's audio file names:
+// {tgid}-{start}_{freq}[-call_{n}].{ext}.
+var captureNameRe = regexp.MustCompile(`^(\d+)-(\d{9,10})(?:_\d+(?:\.\d+)?)?(?:-call_\d+)?\.(m4a|wav|mp3)$`)
+
+// captureExts are the audio extensions in preference order, as for watched
+// files.
+var captureExts = []string{"m4a", "wav", "mp3"}
+
+func parseCaptureName(name string) (captureFile, bool) {
+	m := captureNameRe.FindStringSubmatch(strings.ToLower(name))
+	if m == nil {
+		return captureFile{}, false
+	}
+	tgid, err := strconv.Atoi(m[1])
+	if err != nil {
+		return captureFile{}, false
+	}
+	start, err := strconv.ParseInt(m[2], 10, 64)
+	if err != nil {
+		return captureFile{}, false
+	}
+	return captureFile{tgid: tgid, start: start, rank: slices.Index(captureExts, m[3])}, true
+}
+
+// captureDirs returns the directories trunk-recorder writes a call's audio
+// to: {dir}/{short_name}/YYYY/M/D for each root and each local day the
+// call's start time may fall on within the tolerance.
+func captureDirs(roots []string, shortName string, start time.Time, tolerance time.Duration) []string {
+	var dirs []string
+	for _, t := range []time.Time{start.Add(-tolerance), start, start.Add(tolerance)} {
+		t = t.Local()
+		day := filepath.Join(shortName, strconv.Itoa(t.Year()), strconv.Itoa(int(t.Month())), strconv.Itoa(t.Day()))
+		for _, root := range roots {
+			if dir := filepath.Join(root, day); !slices.Contains(dirs, dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return dirs
+}
+
+// matchFiles returns the non-empty files of a talkgroup started within the
+// tolerance, closest start first, then by extension preference.
+func matchFiles(files []captureFile, tgid int, start time.Time, tolerance time.Duration) []captureFile {
+	want, tol := start.Unix(), int64(tolerance/time.Second)
+	diff := func(f captureFile) int64 {
+		if f.start > want {
+			return f.start - want
+		}
+		return want - f.start
+	}
+	var matches []captureFile
+	for _, f := range files {
+		if f.tgid == tgid && diff(f) <= tol {
+			matches = append(matches, f)
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b captureFile) int {
+		if d := diff(a) - diff(b); d != 0 {
+			return int(d)
+		}
+		return a.rank - b.rank
+	})
+	return matches
+}
+
+// captureIndex caches directory listings for the length of a run.
+type captureIndex struct {
+	dirs map[string][]captureFile
+}
+
+func newCaptureIndex() *captureIndex {
+	return &captureIndex{dirs: make(map[string][]captureFile)}
+}
+
+// list returns a directory's non-empty capture files; a missing directory
+// has none.
+func (idx *captureIndex) list(dir string) []captureFile {
+	if files, ok := idx.dirs[dir]; ok {
+		return files
+	}
+	var files []captureFile
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		f, ok := parseCaptureName(e.Name())
+		if !ok {
+			continue
+		}
+		if info, err := e.Info(); err != nil || info.Size() == 0 {
+			continue
+		}
+		f.path = filepath.Join(dir, e.Name())
+		files = append(files, f)
+	}
+	idx.dirs[dir] = files
+	return files
+}
@@ -0,0 +1,89 @@
+package audiorecon
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseCaptureName(t *testing.T) {
+	for name, want := range map[string]captureFile{
+		"9131-1772373600_851012500.m4a":         {tgid: 9131, start: 1772373600, rank: 0},
+		"9131-1772373600_851012500.0.wav":       {tgid: 9131, start: 1772373600, rank: 1},
+		"9131-1772373600_851012500-call_12.MP3": {tgid: 9131, start: 1772373600, rank: 2},
+		"9131-1772373600.m4a":                   {tgid: 9131, start: 1772373600, rank: 0},
+	} {
+		got, ok := parseCaptureName(name)
+		if !ok || got != want {
+			t.Errorf("parseCaptureName(%q) = %+v, %v, want %+v", name, got, ok, want)
+		}
+	}
+	for _, name := range []string{
+		"9131-1772373600_851012500.json",
+		"9131-1772373600_851012500.m4a.tmp",
+		"call.m4a",
+		"9131-177237_851012500.m4a",
+	} {
+		if f, ok := parseCaptureName(name); ok {
+			t.Errorf("parseCaptureName(%q) = %+v, want no match", name, f)
+		}
+	}
+}
+
+func TestCaptureDirs(t *testing.T) {
+	start := time.Date(2026, 3, 1, 23, 59, 59, 0, time.Local)
+	got := captureDirs([]string{"/watch", "/tr"}, "warco", start, 3*time.Second)
+	want := []string{
+		"/watch/warco/2026/3/1", "/tr/warco/2026/3/1",
+		"/watch/warco/2026/3/2", "/tr/warco/2026/3/2",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("captureDirs = %v, want %v", got, want)
+	}
+}
+
+func TestMatchFiles(t *testing.T) {
+	start := time.Unix(1772373600, 0)
+	files := []captureFile{
+		{path: "a.wav", tgid: 9131, start: 1772373601, rank: 1},
+		{path: "b.wav", tgid: 9131, start: 1772373600, rank: 1},
+		{path: "b.m4a", tgid: 9131, start: 1772373600, rank: 0},
+		{path: "other-tg.m4a", tgid: 9132, start: 1772373600},
+		{path: "too-late.m4a", tgid: 9131, start: 1772373604},
+	}
+	var got []string
+	for _, f := range matchFiles(files, 9131, start, 3*time.Second) {
+		got = append(got, f.path)
+	}
+	if want := []string{"b.m4a", "b.wav", "a.wav"}; !slices.Equal(got, want) {
+		t.Errorf("matchFiles = %v, want %v", got, want)
+	}
+}
+
+func TestCaptureIndexList(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"9131-1772373600_851012500.m4a":  "audio",
+		"9131-1772373600_851012500.json": "{}",
+		"9131-1772373700_851012500.m4a":  "", // still empty
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx := newCaptureIndex()
+	files := idx.list(dir)
+	if len(files) != 1 || files[0].path != filepath.Join(dir, "9131-1772373600_851012500.m4a") {
+		t.Fatalf("list = %+v", files)
+	}
+	// Listings are cached for the run.
+	os.Remove(files[0].path)
+	if len(idx.list(dir)) != 1 {
+		t.Error("listing not cached")
+	}
+	if files := idx.list(filepath.Join(dir, "missing")); len(files) != 0 {
+		t.Errorf("missing dir listed %+v", files)
+	}
+}
@@ -0,0 +1,316 @@
+// Package bridge forwards selected events from the ingest event bus to a
+// streaming platform (NATS JetStream or Kafka via a REST Proxy) for
+// downstream analytics.
+//
+// Each event is wrapped in an events.Envelope and published to
+// <prefix><event_type> (NATS subjects add .<system_id>.<tgid>). "alert" is
+// also a derived stream: besides alert rule matches, emergency events,
+// emergency call/unit events and transcripts labelled urgent or
+// emergency_language are published there in addition to their own topic.
+//
+// Delivery is at-least-once while the process runs: events wait in a bounded
+// in-memory queue and a batch is retried with backoff until the broker acks
+// it. When the queue is full, new events are dropped and counted. Consumers
+// should deduplicate on event_id. Emergency and alert messages have their own
+// queue, which is published before anything else waiting.
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/api"
+	"github.com/snarg/tr-engine/internal/expr"
+	"github.com/snarg/tr-engine/internal/metrics"
+	"github.com/snarg/tr-engine/pkg/events"
+)
+
+// TypeAlert is the alert rule event type, also the derived stream for
+// emergencies and urgent transcripts.
+const TypeAlert = events.TypeAlert
+
+// Message is one record to publish.
+type Message struct {
+	Topic   string // Kafka topic / NATS subject
+	Key     string // partition key
+	ID      string // event_id, for broker-side dedup
+	Type    string // event type, for metrics
+	Value   []byte // JSON-encoded events.Envelope
+	Created time.Time
+}
+
+// Publisher delivers batches to a broker. Publish must return an error unless
+// the broker acknowledged every message.
+type Publisher interface {
+	Name() string
+	Publish(ctx context.Context, msgs []Message) error
+	Close() error
+}
+
+// Options configures a Bridge.
+type Options struct {
+	Events       []string // event types to forward; may include "alert"
+	TopicPrefix  string
+	PartitionKey string // "system" or "system_tgid"
+	Subjects     bool   // append .<system_id>.<tgid> to topics (NATS)
+	Buffer       int
+	BatchSize    int           // default 100
+	Filter       *expr.Program // events must match to be forwarded; nil = all
+}
+
+// Bridge queues events and publishes them in batches.
+type Bridge struct {
+	pub    Publisher
+	opts   Options
+	types  map[string]bool
+	queue  chan Message
+	urgent chan Message // emergency and alert messages, delivered first
+	log    zerolog.Logger
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// New creates a bridge. Call Start to begin publishing.
+func New(pub Publisher, opts Options, log zerolog.Logger) *Bridge {
+	if opts.Buffer <= 0 {
+		opts.Buffer = 10000
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	types := make(map[string]bool, len(opts.Events))
+	for _, t := range opts.Events {
+		if t = strings.TrimSpace(t); t != "" {
+			types[t] = true
+		}
+	}
+	return &Bridge{
+		pub:    pub,
+		opts:   opts,
+		types:  types,
+		queue:  make(chan Message, opts.Buffer),
+		urgent: make(chan Message, opts.Buffer),
+		log:    log.With().Str("component", "bridge").Str("driver", pub.Name()).Logger(),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Enqueue routes an event bus event to the bridge. It never blocks: when the
+// queue is full the message is dropped and counted.
+func (b *Bridge) Enqueue(e api.SSEEvent) {
+	for _, m := range b.route(e) {
+		queue := b.queue
+		if m.Type == TypeAlert || m.Type == events.TypeEmergency {
+			queue = b.urgent
+		}
+		select {
+		case queue <- m:
+			metrics.BridgeQueueDepth.Set(float64(b.pending()))
+		default:
+			metrics.BridgeEventsTotal.WithLabelValues(m.Type, "dropped").Inc()
+		}
+	}
+}
+
+// route returns the messages an event produces: one for its own type if
+// selected, plus one on the alert topic if it qualifies.
+func (b *Bridge) route(e api.SSEEvent) []Message {
+	own := b.types[e.Type]
+	alert := b.types[TypeAlert] && e.Type != TypeAlert && isAlert(e)
+	if !own && !alert {
+		return nil
+	}
+	if b.opts.Filter != nil && !b.opts.Filter.Match(api.NewEventVars(&e).Get()) {
+		metrics.BridgeEventsTotal.WithLabelValues(e.Type, "filtered").Inc()
+		return nil
+	}
+
+	ts, err := time.Parse(time.RFC3339, e.Timestamp)
+	if err != nil {
+		ts = time.Now().UTC()
+	}
+	value, err := json.Marshal(events.Envelope{
+		ID:            e.ID,
+		Type:          e.Type,
+		SubType:       e.SubType,
+		Timestamp:     ts,
+		SchemaVersion: events.SchemaVersion,
+		SystemID:      e.SystemID,
+		SiteID:        e.SiteID,
+		Tgid:          e.Tgid,
+		UnitID:        e.UnitID,
+		Emergency:     e.Emergency,
+		Data:          json.RawMessage(e.Data),
+	})
+	if err != nil {
+		return nil
+	}
+
+	key := fmt.Sprintf("%d", e.SystemID)
+	if b.opts.PartitionKey != "system" {
+		key += fmt.Sprintf(":%d", e.Tgid)
+	}
+
+	var out []Message
+	add := func(typ, id string) {
+		topic := b.opts.TopicPrefix + typ
+		if b.opts.Subjects {
+			topic += fmt.Sprintf(".%d.%d", e.SystemID, e.Tgid)
+		}
+		out = append(out, Message{Topic: topic, Key: key, ID: id, Type: typ, Value: value, Created: ts})
+	}
+	if own {
+		add(e.Type, e.ID)
+	}
+	if alert {
+		add(TypeAlert, e.ID+"-alert")
+	}
+	return out
+}
+
+// isAlert reports whether an event belongs on the alert stream.
+func isAlert(e api.SSEEvent) bool {
+	switch e.Type {
+	case events.TypeEmergency:
+		return true
+	case events.TypeCallStart, events.TypeCallEnd, events.TypeUnitEvent:
+		return e.Emergency
+	case events.TypeTranscription:
+		var t events.Transcription
+		if json.Unmarshal(e.Data, &t) != nil || t.Urgency == nil {
+			return false
+		}
+		return t.Urgency.Label == "urgent" || t.Urgency.Label == "emergency_language"
+	}
+	return false
+}
+
+// Start begins publishing in the background.
+func (b *Bridge) Start() { go b.loop() }
+
+// Stop stops the bridge, giving queued events up to timeout to be delivered,
+// and closes the publisher.
+func (b *Bridge) Stop(timeout time.Duration) {
+	b.stopOnce.Do(func() {
+		close(b.stop)
+		select {
+		case <-b.done:
+		case <-time.After(timeout):
+			b.log.Warn().Int("undelivered", b.pending()).Msg("bridge stop timed out")
+		}
+		b.pub.Close()
+	})
+}
+
+func (b *Bridge) loop() {
+	defer close(b.done)
+	ctx := context.Background()
+
+	var batch []Message
+	for {
+		// select picks randomly among ready cases, so check urgent alone first.
+		var m Message
+		select {
+		case m = <-b.urgent:
+		default:
+			select {
+			case m = <-b.urgent:
+			case m = <-b.queue:
+			case <-b.stop:
+				b.drain(ctx, nil)
+				return
+			}
+		}
+		batch = b.fill(append(batch[:0], m))
+		if !b.deliver(ctx, batch) {
+			b.drain(ctx, batch)
+			return
+		}
+		metrics.BridgeLagSeconds.Set(0)
+	}
+}
+
+// fill adds whatever is already queued to batch, up to BatchSize, urgent
+// messages first.
+func (b *Bridge) fill(batch []Message) []Message {
+	for len(batch) < b.opts.BatchSize {
+		select {
+		case m := <-b.urgent:
+			batch = append(batch, m)
+			continue
+		default:
+		}
+		select {
+		case m := <-b.queue:
+			batch = append(batch, m)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// pending returns the number of queued messages.
+func (b *Bridge) pending() int { return len(b.queue) + len(b.urgent) }
+
+// deliver publishes batch, retrying with backoff until it is acked. Returns
+// false if the bridge was stopped before the batch was delivered.
+func (b *Bridge) deliver(ctx context.Context, batch []Message) bool {
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		metrics.BridgeLagSeconds.Set(time.Since(batch[0].Created).Seconds())
+		err := b.publish(ctx, batch)
+		if err == nil {
+			return true
+		}
+		metrics.BridgePublishErrorsTotal.Inc()
+		b.log.Warn().Err(err).Int("batch", len(batch)).Int("attempt", attempt).
+			Dur("retry_in", backoff).Msg("bridge publish failed")
+		select {
+		case <-time.After(backoff):
+		case <-b.stop:
+			return false
+		}
+		if backoff *= 2; backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+	}
+}
+
+// drain makes one attempt to deliver pending and what is left in the queue
+// on shutdown.
+func (b *Bridge) drain(ctx context.Context, pending []Message) {
+	batch := b.fill(pending)
+	for len(batch) > 0 {
+		if err := b.publish(ctx, batch); err != nil {
+			b.log.Warn().Err(err).Int("undelivered", len(batch)+b.pending()).
+				Msg("bridge stopped with undelivered events")
+			return
+		}
+		batch = b.fill(batch[:0])
+	}
+}
+
+func (b *Bridge) publish(ctx context.Context, batch []Message) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := b.pub.Publish(ctx, batch); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, m := range batch {
+		metrics.BridgeEventsTotal.WithLabelValues(m.Type, "published").Inc()
+		metrics.BridgeDeliveryLatency.Observe(now.Sub(m.Created).Seconds())
+	}
+	metrics.BridgeQueueDepth.Set(float64(b.pending()))
+	return nil
+}
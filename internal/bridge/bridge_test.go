@@ -0,0 +1,334 @@
+package bridge
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/api"
+	"github.com/snarg/tr-engine/internal/expr"
+	"github.com/snarg/tr-engine/pkg/events"
+)
+
+func testEvent(typ string, emergency bool, data any) api.SSEEvent {
+	b, _ := json.Marshal(data)
+	return api.SSEEvent{
+		ID:        "1700000000000-1",
+		Type:      typ,
+		Timestamp: "2026-03-01T12:00:00Z",
+		SystemID:  1,
+		Tgid:      9178,
+		Emergency: emergency,
+		Data:      b,
+	}
+}
+
+func TestRoute(t *testing.T) {
+	b := New(&fakePublisher{}, Options{
+		Events:      []string{"call_end", " transcription ", "alert"},
+		TopicPrefix: "tr.",
+	}, zerolog.Nop())
+
+	if msgs := b.route(testEvent("call_start", false, events.CallStart{})); len(msgs) != 0 {
+		t.Errorf("unselected type routed: %+v", msgs)
+	}
+
+	msgs := b.route(testEvent("call_end", false, events.CallEnd{CallID: 7}))
+	if len(msgs) != 1 || msgs[0].Topic != "tr.call_end" || msgs[0].Key != "1:9178" {
+		t.Fatalf("call_end = %+v", msgs)
+	}
+	var env events.Envelope
+	if err := json.Unmarshal(msgs[0].Value, &env); err != nil {
+		t.Fatal(err)
+	}
+	if env.ID != "1700000000000-1" || env.SchemaVersion != events.SchemaVersion || env.Tgid != 9178 {
+		t.Errorf("envelope = %+v", env)
+	}
+	payload, err := events.Decode(env.Type, env.Data)
+	if err != nil || payload.(*events.CallEnd).CallID != 7 {
+		t.Errorf("decode envelope data: %v %+v", err, payload)
+	}
+
+	// Emergency call_start: not selected itself, but goes to the alert topic.
+	msgs = b.route(testEvent("call_start", true, events.CallStart{}))
+	if len(msgs) != 1 || msgs[0].Topic != "tr.alert" || msgs[0].ID != "1700000000000-1-alert" {
+		t.Errorf("emergency call_start = %+v", msgs)
+	}
+
+	urgent := events.Transcription{Urgency: &events.Urgency{Label: "urgent"}}
+	if msgs = b.route(testEvent("transcription", false, urgent)); len(msgs) != 2 {
+		t.Errorf("urgent transcription produced %d messages, want 2", len(msgs))
+	}
+	routine := events.Transcription{Urgency: &events.Urgency{Label: "routine"}}
+	if msgs = b.route(testEvent("transcription", false, routine)); len(msgs) != 1 {
+		t.Errorf("routine transcription produced %d messages, want 1", len(msgs))
+	}
+
+	// Alert rule matches are published once, on the alert topic.
+	msgs = b.route(testEvent("alert", false, events.Alert{RuleName: "fire"}))
+	if len(msgs) != 1 || msgs[0].Topic != "tr.alert" || msgs[0].ID != "1700000000000-1" {
+		t.Errorf("alert = %+v", msgs)
+	}
+}
+
+func TestRoute_SubjectsAndSystemKey(t *testing.T) {
+	b := New(&fakePublisher{}, Options{
+		Events: []string{"call_end"}, TopicPrefix: "tr-engine.", PartitionKey: "system", Subjects: true,
+	}, zerolog.Nop())
+	msgs := b.route(testEvent("call_end", false, events.CallEnd{}))
+	if len(msgs) != 1 || msgs[0].Topic != "tr-engine.call_end.1.9178" || msgs[0].Key != "1" {
+		t.Errorf("msgs = %+v", msgs)
+	}
+}
+
+func TestRoute_Filter(t *testing.T) {
+	filter, err := expr.Compile(`tgid == 9178 && duration > 15`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := New(&fakePublisher{}, Options{Events: []string{"call_end", "alert"}, Filter: filter}, zerolog.Nop())
+	if msgs := b.route(testEvent("call_end", false, events.CallEnd{Tgid: 9178, Duration: 20})); len(msgs) != 1 {
+		t.Errorf("matching call_end produced %d messages, want 1", len(msgs))
+	}
+	if msgs := b.route(testEvent("call_end", true, events.CallEnd{Tgid: 9178, Duration: 5})); len(msgs) != 0 {
+		t.Errorf("filtered call_end produced %+v", msgs)
+	}
+}
+
+type fakePublisher struct {
+	mu    sync.Mutex
+	fails int // fail this many calls first
+	calls int
+	got   []Message
+}
+
+func (f *fakePublisher) Name() string { return "fake" }
+func (f *fakePublisher) Close() error { return nil }
+func (f *fakePublisher) Publish(ctx context.Context, msgs []Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.calls <= f.fails {
+		return errors.New("broker down")
+	}
+	f.got = append(f.got, msgs...)
+	return nil
+}
+
+func (f *fakePublisher) delivered() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.got)
+}
+
+func TestBridge_RetriesUntilDelivered(t *testing.T) {
+	pub := &fakePublisher{fails: 1}
+	b := New(pub, Options{Events: []string{"call_end"}}, zerolog.Nop())
+	b.Start()
+	for i := 0; i < 3; i++ {
+		b.Enqueue(testEvent("call_end", false, events.CallEnd{CallID: int64(i)}))
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for pub.delivered() < 3 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	b.Stop(time.Second)
+	if n := pub.delivered(); n != 3 {
+		t.Errorf("delivered %d, want 3 (publish calls: %d)", n, pub.calls)
+	}
+}
+
+func TestBridge_StopDrainsQueue(t *testing.T) {
+	pub := &fakePublisher{}
+	b := New(pub, Options{Events: []string{"call_end"}, BatchSize: 2}, zerolog.Nop())
+	for i := 0; i < 5; i++ {
+		b.Enqueue(testEvent("call_end", false, events.CallEnd{}))
+	}
+	b.Start()
+	b.Stop(2 * time.Second)
+	if n := pub.delivered(); n != 5 {
+		t.Errorf("delivered %d, want 5", n)
+	}
+}
+
+func TestBridge_DropsWhenFull(t *testing.T) {
+	b := New(&fakePublisher{}, Options{Events: []string{"call_end"}, Buffer: 2}, zerolog.Nop())
+	for i := 0; i < 5; i++ {
+		b.Enqueue(testEvent("call_end", false, events.CallEnd{}))
+	}
+	if len(b.queue) != 2 {
+		t.Errorf("queue = %d, want 2", len(b.queue))
+	}
+}
+
+func TestBridge_UrgentFirst(t *testing.T) {
+	pub := &fakePublisher{}
+	b := New(pub, Options{Events: []string{"call_end", "emergency", "alert"}}, zerolog.Nop())
+	b.Enqueue(testEvent("call_end", false, events.CallEnd{}))
+	b.Enqueue(testEvent("emergency", true, events.Emergency{}))
+	b.Start()
+	b.Stop(2 * time.Second)
+	if len(pub.got) != 3 {
+		t.Fatalf("delivered %d, want 3", len(pub.got))
+	}
+	if pub.got[0].Type != "emergency" || pub.got[1].Type != TypeAlert || pub.got[2].Type != "call_end" {
+		t.Errorf("order = %s, %s, %s", pub.got[0].Type, pub.got[1].Type, pub.got[2].Type)
+	}
+}
+
+func TestKafkaRESTPublisher(t *testing.T) {
+	var gotPath, gotType string
+	var gotRecords []kafkaRecord
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotType = r.URL.Path, r.Header.Get("Content-Type")
+		var body struct {
+			Records []kafkaRecord `json:"records"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		gotRecords = body.Records
+		var offsets []string
+		for i := range body.Records {
+			offsets = append(offsets, fmt.Sprintf(`{"partition":0,"offset":%d,"error_code":null,"error":null}`, i))
+		}
+		fmt.Fprintf(w, `{"key_schema_id":null,"value_schema_id":null,"offsets":[%s]}`, strings.Join(offsets, ","))
+	}))
+	defer srv.Close()
+
+	// First URL is dead; the publisher fails over to the second.
+	p := NewKafkaRESTPublisher(KafkaRESTOptions{URLs: []string{"http://127.0.0.1:1", srv.URL}})
+	err := p.Publish(context.Background(), []Message{
+		{Topic: "tr-engine.call_end", Key: "1:9178", Value: []byte(`{"event_id":"a"}`)},
+		{Topic: "tr-engine.call_end", Key: "1:9179", Value: []byte(`{"event_id":"b"}`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotPath != "/topics/tr-engine.call_end" || gotType != kafkaJSONV2 {
+		t.Errorf("path=%s content-type=%s", gotPath, gotType)
+	}
+	if len(gotRecords) != 2 || gotRecords[1].Key != "1:9179" {
+		t.Errorf("records = %+v", gotRecords)
+	}
+}
+
+func TestKafkaRESTPublisher_RecordError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"offsets":[{"partition":null,"offset":null,"error_code":50002,"error":"Kafka error"}]}`)
+	}))
+	defer srv.Close()
+	p := NewKafkaRESTPublisher(KafkaRESTOptions{URLs: []string{srv.URL}})
+	if err := p.Publish(context.Background(), []Message{{Topic: "t", Value: []byte(`{}`)}}); err == nil {
+		t.Error("expected error for unacked record")
+	}
+}
+
+// fakeNATS speaks enough of the NATS protocol to ack JetStream publishes.
+// Subjects starting with "nostream." get a no-responders status.
+func fakeNATS(t *testing.T) (addr string, msgIDs func() []string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var mu sync.Mutex
+	var ids []string
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				io.WriteString(conn, `INFO {"server_id":"test","headers":true,"max_payload":1048576}`+"\r\n")
+				seq := 0
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					f := strings.Fields(line)
+					if len(f) == 0 {
+						continue
+					}
+					switch f[0] {
+					case "PING":
+						io.WriteString(conn, "PONG\r\n")
+					case "HPUB":
+						// HPUB <subject> <reply> <hdr_len> <total_len>
+						hdrLen, _ := strconv.Atoi(f[3])
+						total, _ := strconv.Atoi(f[4])
+						buf := make([]byte, total+2)
+						io.ReadFull(r, buf)
+						for _, h := range strings.Split(string(buf[:hdrLen]), "\r\n") {
+							if v, ok := strings.CutPrefix(h, "Nats-Msg-Id: "); ok {
+								mu.Lock()
+								ids = append(ids, v)
+								mu.Unlock()
+							}
+						}
+						if strings.HasPrefix(f[1], "nostream.") {
+							hdr := "NATS/1.0 503\r\n\r\n"
+							fmt.Fprintf(conn, "HMSG %s 1 %d %d\r\n%s\r\n", f[2], len(hdr), len(hdr), hdr)
+							continue
+						}
+						seq++
+						ack := fmt.Sprintf(`{"stream":"TR","seq":%d}`, seq)
+						fmt.Fprintf(conn, "MSG %s 1 %d\r\n%s\r\n", f[2], len(ack), ack)
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String(), func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), ids...)
+	}
+}
+
+func TestNATSPublisher(t *testing.T) {
+	addr, msgIDs := fakeNATS(t)
+	p := NewNATSPublisher(NATSOptions{URLs: []string{"nats://" + addr}})
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := p.Publish(ctx, []Message{
+		{Topic: "tr-engine.call_end.1.9178", ID: "e1", Value: []byte(`{"event_id":"e1"}`)},
+		{Topic: "tr-engine.alert.1.9178", ID: "e1-alert", Value: []byte(`{"event_id":"e1"}`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := msgIDs(); len(ids) != 2 || ids[0] != "e1" || ids[1] != "e1-alert" {
+		t.Errorf("Nats-Msg-Id headers = %v", ids)
+	}
+
+	// No stream bound to the subject: not delivered.
+	err = p.Publish(ctx, []Message{{Topic: "nostream.x", ID: "e2", Value: []byte(`{}`)}})
+	if err == nil || !strings.Contains(err.Error(), "no responders") {
+		t.Errorf("err = %v, want no responders", err)
+	}
+
+	// The publisher reconnects after a failure.
+	if err := p.Publish(ctx, []Message{{Topic: "tr-engine.call_end.1.1", ID: "e3", Value: []byte(`{}`)}}); err != nil {
+		t.Errorf("publish after reconnect: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const kafkaJSONV2 = "application/vnd.kafka.json.v2+json"
+
+// KafkaRESTOptions configures a KafkaRESTPublisher.
+type KafkaRESTOptions struct {
+	URLs     []string // REST Proxy base URLs, e.g. http://rest-proxy:8082; tried in order
+	Username string   // basic auth, optional
+	Password string
+}
+
+// KafkaRESTPublisher produces to Kafka through the REST Proxy v2 API
+// (Confluent REST Proxy, Redpanda HTTP Proxy). Records are keyed by the
+// message key so each system (or talkgroup) stays on one partition. A batch
+// counts as delivered only when the proxy returns an offset for every record;
+// retries after a partial failure may produce duplicates, which consumers
+// drop by event_id.
+type KafkaRESTPublisher struct {
+	opts   KafkaRESTOptions
+	client *http.Client
+	next   int // index of the URL to try first (last one that worked)
+}
+
+// NewKafkaRESTPublisher creates a publisher.
+func NewKafkaRESTPublisher(opts KafkaRESTOptions) *KafkaRESTPublisher {
+	return &KafkaRESTPublisher{opts: opts, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+func (p *KafkaRESTPublisher) Name() string { return "kafka-rest" }
+
+func (p *KafkaRESTPublisher) Close() error { return nil }
+
+type kafkaRecord struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+type kafkaProduceResponse struct {
+	Offsets []struct {
+		Partition *int    `json:"partition"`
+		Offset    *int64  `json:"offset"`
+		ErrorCode *int    `json:"error_code"`
+		Error     *string `json:"error"`
+	} `json:"offsets"`
+}
+
+// Publish produces msgs, one request per topic, preserving order within each.
+func (p *KafkaRESTPublisher) Publish(ctx context.Context, msgs []Message) error {
+	var topics []string
+	byTopic := map[string][]kafkaRecord{}
+	for _, m := range msgs {
+		if _, ok := byTopic[m.Topic]; !ok {
+			topics = append(topics, m.Topic)
+		}
+		byTopic[m.Topic] = append(byTopic[m.Topic], kafkaRecord{Key: m.Key, Value: m.Value})
+	}
+	for _, topic := range topics {
+		if err := p.produce(ctx, topic, byTopic[topic]); err != nil {
+			return fmt.Errorf("%s: %w", topic, err)
+		}
+	}
+	return nil
+}
+
+// produce sends records to the first proxy that accepts them.
+func (p *KafkaRESTPublisher) produce(ctx context.Context, topic string, records []kafkaRecord) error {
+	body, err := json.Marshal(map[string]any{"records": records})
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i := range p.opts.URLs {
+		idx := (p.next + i) % len(p.opts.URLs)
+		err := p.produceTo(ctx, p.opts.URLs[idx], topic, body, len(records))
+		if err == nil {
+			p.next = idx
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (p *KafkaRESTPublisher) produceTo(ctx context.Context, base, topic string, body []byte, n int) error {
+	endpoint := strings.TrimRight(base, "/") + "/topics/" + url.PathEscape(topic)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", kafkaJSONV2)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json, application/json")
+	if p.opts.Username != "" {
+		req.SetBasicAuth(p.opts.Username, p.opts.Password)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: HTTP %d: %s", base, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var pr kafkaProduceResponse
+	if err := json.Unmarshal(data, &pr); err != nil {
+		return fmt.Errorf("%s: decode response: %w", base, err)
+	}
+	if len(pr.Offsets) != n {
+		return fmt.Errorf("%s: %d offsets for %d records", base, len(pr.Offsets), n)
+	}
+	for _, o := range pr.Offsets {
+		if o.ErrorCode != nil || o.Offset == nil {
+			msg := "no offset"
+			if o.Error != nil {
+				msg = *o.Error
+			}
+			return fmt.Errorf("%s: record not acked: %s", base, msg)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,370 @@
+package bridge
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATSOptions configures a NATSPublisher.
+type NATSOptions struct {
+	URLs     []string // nats://host:4222 or tls://host:4222; tried in order
+	Username string
+	Password string
+	Token    string
+}
+
+// NATSPublisher publishes to NATS JetStream using the core client protocol.
+// Each message is sent with a reply inbox and counts as delivered only once
+// JetStream acks it; Nats-Msg-Id carries the event ID so the stream drops
+// redeliveries within its duplicate window. The subjects must be captured by
+// a stream (e.g. "tr-engine.>"), otherwise publishes fail with no responders.
+type NATSPublisher struct {
+	opts NATSOptions
+
+	mu   sync.Mutex // guards conn
+	conn *natsConn
+}
+
+// NewNATSPublisher creates a publisher. It connects on first publish.
+func NewNATSPublisher(opts NATSOptions) *NATSPublisher {
+	return &NATSPublisher{opts: opts}
+}
+
+func (p *NATSPublisher) Name() string { return "nats" }
+
+func (p *NATSPublisher) Publish(ctx context.Context, msgs []Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn == nil {
+		c, err := p.dial(ctx)
+		if err != nil {
+			return err
+		}
+		p.conn = c
+	}
+	if err := p.conn.publish(ctx, msgs); err != nil {
+		p.conn.close()
+		p.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (p *NATSPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		p.conn.close()
+		p.conn = nil
+	}
+	return nil
+}
+
+func (p *NATSPublisher) dial(ctx context.Context) (*natsConn, error) {
+	var errs []error
+	for _, raw := range p.opts.URLs {
+		c, err := dialNATS(ctx, raw, p.opts)
+		if err == nil {
+			return c, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", raw, err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// natsAck is a JetStream publish acknowledgement.
+type natsAck struct {
+	Stream    string `json:"stream"`
+	Seq       uint64 `json:"seq"`
+	Duplicate bool   `json:"duplicate"`
+	Error     *struct {
+		Code        int    `json:"code"`
+		Description string `json:"description"`
+	} `json:"error"`
+}
+
+// natsConn is one client connection. A reader goroutine answers server PINGs
+// and routes replies on the inbox to waiting publishes.
+type natsConn struct {
+	nc    net.Conn
+	w     *bufio.Writer
+	wmu   sync.Mutex
+	inbox string
+
+	mu      sync.Mutex
+	pending map[string]chan []byte // reply subject → ack payload
+	seq     uint64
+	err     error
+	closed  chan struct{}
+}
+
+func dialNATS(ctx context.Context, raw string, opts NATSOptions) (*natsConn, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	d := net.Dialer{Timeout: 10 * time.Second}
+	nc, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	nc.SetDeadline(time.Now().Add(10 * time.Second))
+
+	r := bufio.NewReader(nc)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("read INFO: %w", err)
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+		Headers     bool `json:"headers"`
+	}
+	if !strings.HasPrefix(line, "INFO ") || json.Unmarshal([]byte(line[5:]), &info) != nil {
+		nc.Close()
+		return nil, fmt.Errorf("unexpected greeting %q", strings.TrimSpace(line))
+	}
+	if !info.Headers {
+		nc.Close()
+		return nil, fmt.Errorf("server does not support headers (JetStream requires NATS 2.2+)")
+	}
+	if u.Scheme == "tls" || info.TLSRequired {
+		tc := tls.Client(nc, &tls.Config{ServerName: u.Hostname()})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("tls: %w", err)
+		}
+		nc = tc
+		r = bufio.NewReader(nc)
+	}
+
+	connect := map[string]any{
+		"verbose": false, "pedantic": false, "headers": true, "no_responders": true,
+		"name": "tr-engine-bridge", "lang": "go", "version": "1", "protocol": 1,
+	}
+	user, pass := opts.Username, opts.Password
+	if u.User != nil {
+		user = u.User.Username()
+		pass, _ = u.User.Password()
+	}
+	if user != "" {
+		connect["user"], connect["pass"] = user, pass
+	}
+	if opts.Token != "" {
+		connect["auth_token"] = opts.Token
+	}
+	cj, _ := json.Marshal(connect)
+
+	c := &natsConn{
+		nc:      nc,
+		w:       bufio.NewWriter(nc),
+		inbox:   "_INBOX." + randomID(),
+		pending: make(map[string]chan []byte),
+		closed:  make(chan struct{}),
+	}
+	fmt.Fprintf(c.w, "CONNECT %s\r\nPING\r\n", cj)
+	if err := c.w.Flush(); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("connect: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "PONG" {
+			break
+		}
+		if strings.HasPrefix(line, "-ERR") {
+			nc.Close()
+			return nil, fmt.Errorf("connect: %s", line)
+		}
+	}
+	nc.SetDeadline(time.Time{})
+
+	fmt.Fprintf(c.w, "SUB %s.* 1\r\n", c.inbox)
+	if err := c.w.Flush(); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	go c.read(r)
+	return c, nil
+}
+
+func (c *natsConn) publish(ctx context.Context, msgs []Message) error {
+	acks := make([]chan []byte, len(msgs))
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	replies := make([]string, len(msgs))
+	for i := range msgs {
+		c.seq++
+		replies[i] = c.inbox + "." + strconv.FormatUint(c.seq, 10)
+		acks[i] = make(chan []byte, 1)
+		c.pending[replies[i]] = acks[i]
+	}
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		for _, r := range replies {
+			delete(c.pending, r)
+		}
+		c.mu.Unlock()
+	}()
+
+	c.wmu.Lock()
+	for i, m := range msgs {
+		hdr := "NATS/1.0\r\nNats-Msg-Id: " + m.ID + "\r\n\r\n"
+		fmt.Fprintf(c.w, "HPUB %s %s %d %d\r\n%s", m.Topic, replies[i], len(hdr), len(hdr)+len(m.Value), hdr)
+		c.w.Write(m.Value)
+		c.w.WriteString("\r\n")
+	}
+	err := c.w.Flush()
+	c.wmu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for i, ch := range acks {
+		select {
+		case payload := <-ch:
+			if err := checkAck(payload); err != nil {
+				return fmt.Errorf("%s: %w", msgs[i].Topic, err)
+			}
+		case <-c.closed:
+			return c.failure()
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for JetStream ack: %w", ctx.Err())
+		}
+	}
+	return nil
+}
+
+// checkAck validates a reply payload. An empty payload is how the no
+// responders status (no stream captures the subject) arrives.
+func checkAck(payload []byte) error {
+	if len(payload) == 0 {
+		return fmt.Errorf("no JetStream stream for subject (no responders)")
+	}
+	var ack natsAck
+	if err := json.Unmarshal(payload, &ack); err != nil {
+		return fmt.Errorf("bad ack %q", payload)
+	}
+	if ack.Error != nil {
+		return fmt.Errorf("jetstream: %s (%d)", ack.Error.Description, ack.Error.Code)
+	}
+	if ack.Stream == "" {
+		return fmt.Errorf("bad ack %q", payload)
+	}
+	return nil
+}
+
+// read handles server traffic until the connection fails.
+func (c *natsConn) read(r *bufio.Reader) {
+	err := c.readLoop(r)
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.mu.Unlock()
+	close(c.closed)
+}
+
+func (c *natsConn) readLoop(r *bufio.Reader) error {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		op, args, _ := strings.Cut(line, " ")
+		op = strings.ToUpper(op)
+		switch op {
+		case "PING":
+			c.wmu.Lock()
+			c.w.WriteString("PONG\r\n")
+			err := c.w.Flush()
+			c.wmu.Unlock()
+			if err != nil {
+				return err
+			}
+		case "MSG", "HMSG":
+			// MSG <subject> <sid> [reply] <size>
+			// HMSG <subject> <sid> [reply] <hdr_size> <total_size>
+			f := strings.Fields(args)
+			if len(f) < 3 {
+				return fmt.Errorf("malformed %s", line)
+			}
+			total, err := strconv.Atoi(f[len(f)-1])
+			if err != nil {
+				return fmt.Errorf("malformed %s", line)
+			}
+			hdrLen := 0
+			if op == "HMSG" {
+				if hdrLen, err = strconv.Atoi(f[len(f)-2]); err != nil {
+					return fmt.Errorf("malformed %s", line)
+				}
+			}
+			buf := make([]byte, total+2) // payload + CRLF
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return err
+			}
+			c.deliver(f[0], buf[hdrLen:total])
+		case "-ERR":
+			return fmt.Errorf("server error: %s", args)
+		case "INFO", "PONG", "+OK":
+		}
+	}
+}
+
+func (c *natsConn) deliver(subject string, payload []byte) {
+	c.mu.Lock()
+	ch := c.pending[subject]
+	c.mu.Unlock()
+	if ch != nil {
+		select {
+		case ch <- payload:
+		default:
+		}
+	}
+}
+
+func (c *natsConn) failure() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return fmt.Errorf("connection lost: %w", c.err)
+	}
+	return fmt.Errorf("connection lost")
+}
+
+func (c *natsConn) close() { c.nc.Close() }
+
+func randomID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,195 @@
+// Package cadmail ingests CAD (computer-aided dispatch) pages sent by email
+// and links each incident to the radio calls it was dispatched on.
+//
+// Pages arrive on a small receive-only SMTP listener (CAD_SMTP_LISTEN) or
+// through POST /api/v1/cad-incidents/ingest. Each message is matched against
+// the page formats in CAD_PAGE_FORMATS, whose regexps pull out the incident
+// number, type, address, units and dispatch time. The incident is stored in
+// cad_incidents and linked to calls on the format's dispatch talkgroups that
+// started within its window around the dispatch time. Calls that arrive after
+// the page are picked up by a periodic re-correlation.
+package cadmail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// ErrNoFormat is returned for a message no page format matches.
+var ErrNoFormat = errors.New("no page format matches message")
+
+// correlateInterval is how often recent incidents are re-linked to calls.
+const correlateInterval = time.Minute
+
+// Ingester parses CAD page emails into incidents.
+type Ingester struct {
+	db       *database.DB
+	formats  []*Format
+	maxAfter time.Duration // longest window_after of any format
+	log      zerolog.Logger
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewIngester creates an ingester for formats (see LoadFormats).
+func NewIngester(db *database.DB, formats []*Format, log zerolog.Logger) *Ingester {
+	in := &Ingester{
+		db:      db,
+		formats: formats,
+		log:     log.With().Str("component", "cad-mail").Logger(),
+		stop:    make(chan struct{}),
+	}
+	for _, f := range formats {
+		if f.WindowAfter > in.maxAfter {
+			in.maxAfter = f.WindowAfter
+		}
+	}
+	return in
+}
+
+// Deliver is the SMTPServer DeliverFunc: unparsable pages are logged and
+// accepted, so the dispatch center's mail server doesn't retry them forever.
+func (in *Ingester) Deliver(sender string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	inc, inserted, err := in.Ingest(ctx, bytes.NewReader(data))
+	if errors.Is(err, ErrNoFormat) {
+		in.log.Warn().Str("sender", sender).Msg("cad page matched no page format, dropped")
+		return nil
+	}
+	if err != nil {
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) {
+			in.log.Warn().Err(err).Str("sender", sender).Msg("unreadable cad page, dropped")
+			return nil
+		}
+		return err
+	}
+	if !inserted {
+		in.log.Debug().Int64("incident_id", inc.ID).Msg("duplicate cad page ignored")
+		return nil
+	}
+	in.log.Info().
+		Int64("incident_id", inc.ID).
+		Str("format", inc.Format).
+		Str("incident", inc.IncidentNumber).
+		Int("calls", inc.CallCount).
+		Msg("cad page received")
+	return nil
+}
+
+// ParseError is returned for a message that isn't a readable email.
+type ParseError struct{ err error }
+
+func (e *ParseError) Error() string { return "parse message: " + e.err.Error() }
+func (e *ParseError) Unwrap() error { return e.err }
+
+// Parse turns a raw email into an incident (not yet stored). received is used
+// as the dispatch time when the page has none.
+func (in *Ingester) Parse(r io.Reader, received time.Time) (*database.CADIncident, error) {
+	msg, err := ReadMessage(r)
+	if err != nil {
+		return nil, &ParseError{err}
+	}
+	for _, f := range in.formats {
+		if !f.Matches(msg.From, msg.Subject) {
+			continue
+		}
+		fields := f.Extract(msg.Subject, msg.Text)
+		if fields == nil {
+			continue
+		}
+		ref := received
+		if !msg.Date.IsZero() {
+			ref = msg.Date
+		}
+		dispatched, ok := f.DispatchTime(fields, ref)
+		if !ok {
+			dispatched = ref
+		}
+
+		inc := &database.CADIncident{
+			Format:         f.Name,
+			IncidentNumber: fields[FieldIncident],
+			IncidentType:   fields[FieldType],
+			Address:        fields[FieldAddress],
+			Units:          fields[FieldUnits],
+			DispatchedAt:   dispatched,
+			SystemID:       f.SystemID,
+			Tgids:          f.Tgids,
+			WindowBefore:   int(f.WindowBefore / time.Second),
+			WindowAfter:    int(f.WindowAfter / time.Second),
+			Sender:         msg.From,
+			Subject:        msg.Subject,
+			Body:           msg.Text,
+			MessageID:      msg.MessageID,
+		}
+		for k, v := range fields {
+			switch k {
+			case FieldIncident, FieldType, FieldAddress, FieldUnits, FieldTime:
+			default:
+				if inc.Fields == nil {
+					inc.Fields = make(map[string]string)
+				}
+				inc.Fields[k] = v
+			}
+		}
+		return inc, nil
+	}
+	return nil, ErrNoFormat
+}
+
+// Ingest parses, stores and correlates one page. A page whose Message-ID was
+// already ingested returns the stored incident with inserted false.
+func (in *Ingester) Ingest(ctx context.Context, r io.Reader) (inc *database.CADIncident, inserted bool, err error) {
+	inc, err = in.Parse(r, time.Now())
+	if err != nil {
+		return nil, false, err
+	}
+	id, inserted, err := in.db.InsertCADIncident(ctx, inc)
+	if err != nil {
+		return nil, false, fmt.Errorf("store incident: %w", err)
+	}
+	if inserted {
+		if _, err := in.db.CorrelateCADIncidents(ctx, inc.DispatchedAt); err != nil {
+			in.log.Warn().Err(err).Int64("incident_id", id).Msg("cad incident correlation failed")
+		}
+	}
+	inc, err = in.db.GetCADIncident(ctx, id, true)
+	return inc, inserted, err
+}
+
+func (in *Ingester) Start() { go in.loop() }
+
+func (in *Ingester) Stop() { in.stopOnce.Do(func() { close(in.stop) }) }
+
+// loop re-links incidents whose window may still be receiving calls.
+func (in *Ingester) loop() {
+	ticker := time.NewTicker(correlateInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			// Calls land a little after they end, so look back past the window.
+			n, err := in.db.CorrelateCADIncidents(ctx, time.Now().Add(-in.maxAfter-10*time.Minute))
+			cancel()
+			if err != nil {
+				in.log.Warn().Err(err).Msg("cad incident correlation failed")
+			} else if n > 0 {
+				in.log.Debug().Int64("linked", n).Msg("cad incidents linked to calls")
+			}
+		case <-in.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package cadmail
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const countyFormats = `[{
+	"name": "county",
+	"from": "@cad\\.example\\.gov$",
+	"subject": "^CAD",
+	"fields": {
+		"incident": "INC#:\\s*(\\S+)",
+		"type":     "TYPE:\\s*(.+)",
+		"address":  "LOC:\\s*(.+)",
+		"units":    "UNITS:\\s*(.+)",
+		"time":     "TIME:\\s*(\\d\\d:\\d\\d:\\d\\d)",
+		"cross":    "X-ST:\\s*(.+)"
+	},
+	"time_layout": "15:04:05",
+	"time_zone": "America/Chicago",
+	"system_id": 1,
+	"tgids": [9044, 9045],
+	"window_after": "20m"
+}]`
+
+func loadTestFormats(t *testing.T) []*Format {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "formats.json")
+	if err := os.WriteFile(path, []byte(countyFormats), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	formats, err := LoadFormats(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return formats
+}
+
+const qpPage = "From: County CAD <Dispatch@CAD.example.gov>\r\n" +
+	"To: pages@tr-engine.local\r\n" +
+	"Subject: =?UTF-8?Q?CAD_Page_=E2=80=93_Structure_Fire?=\r\n" +
+	"Date: Fri, 16 Oct 2026 14:05:30 -0500\r\n" +
+	"Message-ID: <page-4821@cad.example.gov>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/alternative; boundary=\"b1\"\r\n" +
+	"\r\n" +
+	"--b1\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"INC#: 26-004821\r\n" +
+	"TYPE: STRUCTURE FIRE =E2=80=93 RESIDENTIAL\r\n" +
+	"LOC: 1200 N MAIN ST\r\n" +
+	"X-ST: ELM AVE / OAK AVE\r\n" +
+	"UNITS: E4, L4, BC1\r\n" +
+	"TIME: 14:03:12\r\n" +
+	"--b1\r\n" +
+	"Content-Type: text/html\r\n" +
+	"\r\n" +
+	"<p>ignored</p>\r\n" +
+	"--b1--\r\n"
+
+func TestParsePage(t *testing.T) {
+	in := NewIngester(nil, loadTestFormats(t), zerolog.Nop())
+	inc, err := in.Parse(strings.NewReader(qpPage), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inc.Format != "county" || inc.IncidentNumber != "26-004821" || inc.Address != "1200 N MAIN ST" ||
+		inc.Units != "E4, L4, BC1" || inc.IncidentType != "STRUCTURE FIRE – RESIDENTIAL" {
+		t.Errorf("parsed = %+v", inc)
+	}
+	if inc.Fields["cross"] != "ELM AVE / OAK AVE" || len(inc.Fields) != 1 {
+		t.Errorf("fields = %v", inc.Fields)
+	}
+	if inc.Subject != "CAD Page – Structure Fire" || inc.Sender != "dispatch@cad.example.gov" ||
+		inc.MessageID != "page-4821@cad.example.gov" {
+		t.Errorf("headers = %q %q %q", inc.Subject, inc.Sender, inc.MessageID)
+	}
+	want := time.Date(2026, 10, 16, 19, 3, 12, 0, time.UTC)
+	if !inc.DispatchedAt.Equal(want) {
+		t.Errorf("dispatched = %v, want %v", inc.DispatchedAt.UTC(), want)
+	}
+	if inc.SystemID != 1 || len(inc.Tgids) != 2 || inc.WindowBefore != 120 || inc.WindowAfter != 1200 {
+		t.Errorf("correlation = %d %v %d %d", inc.SystemID, inc.Tgids, inc.WindowBefore, inc.WindowAfter)
+	}
+
+	other := strings.Replace(qpPage, "CAD.example.gov", "example.com", 1)
+	if _, err := in.Parse(strings.NewReader(other), time.Now()); err != ErrNoFormat {
+		t.Errorf("foreign sender err = %v, want ErrNoFormat", err)
+	}
+}
+
+func TestDispatchTimeBeforeMidnight(t *testing.T) {
+	f := loadTestFormats(t)[0]
+	ref := time.Date(2026, 10, 17, 0, 2, 0, 0, f.loc)
+	got, ok := f.DispatchTime(map[string]string{FieldTime: "23:58:40"}, ref)
+	if !ok || !got.Equal(time.Date(2026, 10, 16, 23, 58, 40, 0, f.loc)) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestReadMessageHTMLOnly(t *testing.T) {
+	raw := "From: cad@example.gov\r\nSubject: page\r\nContent-Type: text/html\r\n" +
+		"Content-Transfer-Encoding: base64\r\n\r\n" +
+		"PGRpdj5JTkMjOiA3PC9kaXY+PGRpdj5MT0M6IDEgQSAmYW1wOyBCPC9k\r\naXY+\r\n"
+	msg, err := ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Text != "INC#: 7\nLOC: 1 A & B\n" {
+		t.Errorf("text = %q", msg.Text)
+	}
+}
+
+func TestLoadFormatsValidation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "formats.json")
+	for _, bad := range []string{
+		`[]`,
+		`[{"name": "x", "fields": {"incident": "(\\S+)"}}]`,
+		`[{"name": "x", "system_id": 1, "tgids": [1], "fields": {"time": "(\\S+)"}}]`,
+		`[{"name": "x", "system_id": 1, "tgids": [1], "fields": {"incident": "("}}]`,
+		`[{"name": "x", "system_id": 1, "tgids": [1], "fields": {"incident": "(\\S+)"}, "window_after": "soon"}]`,
+	} {
+		os.WriteFile(path, []byte(bad), 0o644)
+		if _, err := LoadFormats(path); err == nil {
+			t.Errorf("LoadFormats(%s) succeeded", bad)
+		}
+	}
+}
+
+func TestSMTPSession(t *testing.T) {
+	var gotSender string
+	var gotData []byte
+	srv := NewSMTPServer("cad.test", 1024, []string{"@cad.example.gov"}, func(sender string, data []byte) error {
+		gotSender, gotData = sender, data
+		return nil
+	}, zerolog.Nop())
+
+	client, server := net.Pipe()
+	go srv.Session(server)
+	defer client.Close()
+	r := bufio.NewReader(client)
+	expect := func(prefix string) {
+		t.Helper()
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if !strings.HasPrefix(line, prefix) {
+				t.Fatalf("got %q, want prefix %q", line, prefix)
+			}
+			if len(line) < 4 || line[3] != '-' {
+				return
+			}
+		}
+	}
+	send := func(line, prefix string) {
+		t.Helper()
+		if _, err := client.Write([]byte(line + "\r\n")); err != nil {
+			t.Fatal(err)
+		}
+		expect(prefix)
+	}
+
+	expect("220 ")
+	send("EHLO mta.example.gov", "250")
+	send("MAIL FROM:<spam@example.com>", "550")
+	send("RCPT TO:<pages@cad.test>", "503")
+	send("MAIL FROM:<dispatch@CAD.example.gov> SIZE=100", "250")
+	send("RCPT TO:<pages@cad.test>", "250")
+	send("DATA", "354")
+	send("Subject: test\r\n\r\n..leading dot\r\n.", "250")
+	send("MAIL FROM:<dispatch@cad.example.gov>", "250")
+	send("RCPT TO:<pages@cad.test>", "250")
+	send("DATA", "354")
+	send(strings.Repeat("x", 2000)+"\r\n.", "552")
+	send("QUIT", "221")
+
+	if gotSender != "dispatch@CAD.example.gov" {
+		t.Errorf("sender = %q", gotSender)
+	}
+	if string(gotData) != "Subject: test\n\n.leading dot\n" {
+		t.Errorf("data = %q", gotData)
+	}
+}
@@ -0,0 +1,179 @@
+package cadmail
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Fields with a meaning of their own; any other field a format extracts is
+// kept in the incident's fields map.
+const (
+	FieldIncident = "incident" // incident number
+	FieldType     = "type"     // call type / nature
+	FieldAddress  = "address"
+	FieldUnits    = "units" // dispatched units, as written in the page
+	FieldTime     = "time"  // dispatch time, parsed with TimeLayout
+)
+
+// Default correlation window around the dispatch time.
+const (
+	DefaultWindowBefore = 2 * time.Minute
+	DefaultWindowAfter  = 30 * time.Minute
+)
+
+// Format describes one CAD page layout and the talkgroups it is dispatched
+// on. Formats are tried in file order; the first whose From and Subject match
+// and that extracts at least one field parses the page.
+type Format struct {
+	Name       string            `json:"name"`
+	From       string            `json:"from"`        // regexp on the sender address; empty matches any
+	Subject    string            `json:"subject"`     // regexp on the subject; empty matches any
+	Fields     map[string]string `json:"fields"`      // field → regexp; the first group (or whole match) is the value
+	TimeLayout string            `json:"time_layout"` // Go time layout for the "time" field
+	TimeZone   string            `json:"time_zone"`   // IANA zone for TimeLayout; default local time
+	SystemID   int               `json:"system_id"`
+	Tgids      []int             `json:"tgids"`         // dispatch talkgroups
+	Before     string            `json:"window_before"` // e.g. "2m"; calls this long before dispatch are linked
+	After      string            `json:"window_after"`  // e.g. "30m"
+
+	// Parsed by LoadFormats
+	WindowBefore time.Duration `json:"-"`
+	WindowAfter  time.Duration `json:"-"`
+
+	from, subject *regexp.Regexp
+	fields        map[string]*regexp.Regexp
+	loc           *time.Location
+}
+
+// LoadFormats reads a CAD_PAGE_FORMATS JSON file: an array of Format.
+func LoadFormats(path string) ([]*Format, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var formats []*Format
+	if err := json.Unmarshal(data, &formats); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("%s defines no page formats", path)
+	}
+	for i, f := range formats {
+		if err := f.compile(); err != nil {
+			return nil, fmt.Errorf("page format %d (%q): %w", i, f.Name, err)
+		}
+	}
+	return formats, nil
+}
+
+func (f *Format) compile() error {
+	if f.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if f.SystemID <= 0 || len(f.Tgids) == 0 {
+		return fmt.Errorf("system_id and tgids are required")
+	}
+	if len(f.Fields) == 0 {
+		return fmt.Errorf("fields is required")
+	}
+	var err error
+	if f.From != "" {
+		if f.from, err = regexp.Compile("(?i)" + f.From); err != nil {
+			return fmt.Errorf("from: %w", err)
+		}
+	}
+	if f.Subject != "" {
+		if f.subject, err = regexp.Compile(f.Subject); err != nil {
+			return fmt.Errorf("subject: %w", err)
+		}
+	}
+	f.fields = make(map[string]*regexp.Regexp, len(f.Fields))
+	for name, expr := range f.Fields {
+		re, err := regexp.Compile("(?m)" + expr)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", name, err)
+		}
+		f.fields[name] = re
+	}
+	if _, ok := f.fields[FieldTime]; ok && f.TimeLayout == "" {
+		return fmt.Errorf("field time requires time_layout")
+	}
+	f.loc = time.Local
+	if f.TimeZone != "" {
+		if f.loc, err = time.LoadLocation(f.TimeZone); err != nil {
+			return fmt.Errorf("time_zone: %w", err)
+		}
+	}
+	f.WindowBefore, f.WindowAfter = DefaultWindowBefore, DefaultWindowAfter
+	if f.Before != "" {
+		if f.WindowBefore, err = time.ParseDuration(f.Before); err != nil || f.WindowBefore < 0 {
+			return fmt.Errorf("window_before must be a non-negative duration, got %q", f.Before)
+		}
+	}
+	if f.After != "" {
+		if f.WindowAfter, err = time.ParseDuration(f.After); err != nil || f.WindowAfter < 0 {
+			return fmt.Errorf("window_after must be a non-negative duration, got %q", f.After)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether a message from sender with subject is this
+// format's to parse.
+func (f *Format) Matches(sender, subject string) bool {
+	if f.from != nil && !f.from.MatchString(sender) {
+		return false
+	}
+	return f.subject == nil || f.subject.MatchString(subject)
+}
+
+// Extract applies the field expressions to the subject and body. Returns nil
+// if no field matched.
+func (f *Format) Extract(subject, body string) map[string]string {
+	text := subject + "\n" + body
+	var out map[string]string
+	for name, re := range f.fields {
+		m := re.FindStringSubmatch(text)
+		if m == nil {
+			continue
+		}
+		v := m[0]
+		if len(m) > 1 {
+			v = m[1]
+		}
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]string)
+		}
+		out[name] = v
+	}
+	return out
+}
+
+// DispatchTime parses the extracted "time" field. Layouts without a date
+// (e.g. "15:04:05") take the date of ref, the day before if that would put
+// the dispatch more than an hour after ref.
+func (f *Format) DispatchTime(fields map[string]string, ref time.Time) (time.Time, bool) {
+	v, ok := fields[FieldTime]
+	if !ok || f.TimeLayout == "" {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(f.TimeLayout, v, f.loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if t.Year() == 0 {
+		r := ref.In(f.loc)
+		t = time.Date(r.Year(), r.Month(), r.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), f.loc)
+		if t.Sub(ref) > time.Hour {
+			t = t.AddDate(0, 0, -1)
+		}
+	}
+	return t, true
+}
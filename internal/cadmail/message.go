@@ -0,0 +1,159 @@
+package cadmail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Message is the part of an email a page format works with.
+type Message struct {
+	MessageID string
+	From      string // sender address, lowercased
+	Subject   string
+	Date      time.Time // zero if missing or unparsable
+	Text      string    // text/plain body (or text/html with tags stripped)
+}
+
+// maxParts bounds how many MIME parts ReadMessage walks.
+const maxParts = 50
+
+// ReadMessage parses an RFC 5322 message, decoding encoded-word subjects,
+// quoted-printable and base64 bodies, and picking the text out of multipart
+// messages.
+func ReadMessage(r io.Reader) (*Message, error) {
+	m, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	msg := &Message{
+		MessageID: strings.Trim(strings.TrimSpace(m.Header.Get("Message-Id")), "<>"),
+	}
+	dec := new(mime.WordDecoder)
+	if s, err := dec.DecodeHeader(m.Header.Get("Subject")); err == nil {
+		msg.Subject = strings.TrimSpace(s)
+	} else {
+		msg.Subject = strings.TrimSpace(m.Header.Get("Subject"))
+	}
+	if addrs, err := m.Header.AddressList("From"); err == nil && len(addrs) > 0 {
+		msg.From = strings.ToLower(addrs[0].Address)
+	}
+	if d, err := m.Header.Date(); err == nil {
+		msg.Date = d
+	}
+
+	parts := 0
+	plain, htmlText, err := readBody(m.Header.Get("Content-Type"), m.Header.Get("Content-Transfer-Encoding"), m.Body, &parts)
+	if err != nil {
+		return nil, err
+	}
+	msg.Text = plain
+	if strings.TrimSpace(msg.Text) == "" {
+		msg.Text = stripHTML(htmlText)
+	}
+	msg.Text = strings.ReplaceAll(msg.Text, "\r\n", "\n")
+	return msg, nil
+}
+
+// readBody returns the first text/plain and text/html content under a part.
+func readBody(contentType, encoding string, body io.Reader, parts *int) (plain, htmlText string, err error) {
+	*parts++
+	if *parts > maxParts {
+		return "", "", fmt.Errorf("more than %d MIME parts", maxParts)
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextRawPart()
+			if err == io.EOF {
+				return plain, htmlText, nil
+			}
+			if err != nil {
+				return plain, htmlText, err
+			}
+			pt, ht, err := readBody(p.Header.Get("Content-Type"), p.Header.Get("Content-Transfer-Encoding"), p, parts)
+			if err != nil {
+				return plain, htmlText, err
+			}
+			if plain == "" {
+				plain = pt
+			}
+			if htmlText == "" {
+				htmlText = ht
+			}
+		}
+	}
+	if mediaType != "text/plain" && mediaType != "text/html" {
+		return "", "", nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, &newlineStripper{r: body})
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", "", err
+	}
+	if mediaType == "text/html" {
+		return "", string(data), nil
+	}
+	return string(data), "", nil
+}
+
+// newlineStripper drops CR and LF so base64 line breaks don't upset the decoder.
+type newlineStripper struct{ r io.Reader }
+
+func (n *newlineStripper) Read(p []byte) (int, error) {
+	for {
+		k, err := n.r.Read(p)
+		j := 0
+		for _, b := range p[:k] {
+			if b != '\r' && b != '\n' {
+				p[j] = b
+				j++
+			}
+		}
+		if j > 0 || err != nil {
+			return j, err
+		}
+	}
+}
+
+var (
+	htmlBreakRe = regexp.MustCompile(`(?i)<\s*(br|/p|/div|/tr|/li)\s*/?>`)
+	htmlTagRe   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// stripHTML turns an HTML page body into text, one line per block.
+func stripHTML(s string) string {
+	if s == "" {
+		return ""
+	}
+	s = htmlBreakRe.ReplaceAllString(s, "\n")
+	s = htmlTagRe.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	var b bytes.Buffer
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
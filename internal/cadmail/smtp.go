@@ -0,0 +1,244 @@
+package cadmail
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// DeliverFunc handles one received message. An error is reported to the
+// sending server as a temporary failure (451), so it retries.
+type DeliverFunc func(sender string, data []byte) error
+
+// SMTPServer is a minimal receive-only SMTP server for CAD pages: no relay,
+// no TLS, no AUTH. It accepts mail for any recipient from the allowed
+// senders and hands each message to deliver. Run it on a private network or
+// behind the MTA that receives the dispatch center's mail.
+type SMTPServer struct {
+	Hostname string
+	MaxSize  int64    // largest message accepted, in bytes
+	Allowed  []string // sender addresses or "@domain" suffixes; empty allows all
+
+	deliver DeliverFunc
+	log     zerolog.Logger
+
+	mu    sync.Mutex
+	ln    net.Listener
+	conns sync.WaitGroup
+	sem   chan struct{}
+}
+
+const (
+	smtpCommandTimeout = 5 * time.Minute
+	smtpMaxConns       = 16
+)
+
+// NewSMTPServer creates a server that passes messages to deliver.
+func NewSMTPServer(hostname string, maxSize int64, allowed []string, deliver DeliverFunc, log zerolog.Logger) *SMTPServer {
+	if hostname == "" {
+		hostname = "tr-engine"
+	}
+	return &SMTPServer{
+		Hostname: hostname,
+		MaxSize:  maxSize,
+		Allowed:  allowed,
+		deliver:  deliver,
+		log:      log,
+		sem:      make(chan struct{}, smtpMaxConns),
+	}
+}
+
+// Listen starts accepting connections on addr in the background.
+func (s *SMTPServer) Listen(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.ln = ln
+	s.mu.Unlock()
+	go s.serve(ln)
+	return nil
+}
+
+// Close stops accepting connections and waits for open sessions to finish.
+func (s *SMTPServer) Close() error {
+	s.mu.Lock()
+	ln := s.ln
+	s.mu.Unlock()
+	var err error
+	if ln != nil {
+		err = ln.Close()
+	}
+	s.conns.Wait()
+	return err
+}
+
+func (s *SMTPServer) serve(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			s.log.Warn().Err(err).Msg("smtp accept failed")
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		select {
+		case s.sem <- struct{}{}:
+		default:
+			fmt.Fprintf(conn, "421 %s too many connections\r\n", s.Hostname)
+			conn.Close()
+			continue
+		}
+		s.conns.Add(1)
+		go func() {
+			defer s.conns.Done()
+			defer func() { <-s.sem }()
+			s.Session(conn)
+		}()
+	}
+}
+
+// allowed reports whether sender may deliver pages.
+func (s *SMTPServer) allowed(sender string) bool {
+	if len(s.Allowed) == 0 {
+		return true
+	}
+	sender = strings.ToLower(sender)
+	for _, a := range s.Allowed {
+		a = strings.ToLower(a)
+		if sender == a || (strings.HasPrefix(a, "@") && strings.HasSuffix(sender, a)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Session runs the SMTP conversation on conn and closes it.
+func (s *SMTPServer) Session(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	remote := conn.RemoteAddr().String()
+	reply := func(format string, args ...any) bool {
+		conn.SetWriteDeadline(time.Now().Add(smtpCommandTimeout))
+		return tp.PrintfLine(format, args...) == nil
+	}
+
+	if !reply("220 %s ESMTP tr-engine CAD", s.Hostname) {
+		return
+	}
+	var sender string
+	var haveSender, haveRcpt bool
+	for {
+		conn.SetReadDeadline(time.Now().Add(smtpCommandTimeout))
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			reply("250 %s", s.Hostname)
+		case "EHLO":
+			reply("250-%s", s.Hostname)
+			reply("250-SIZE %d", s.MaxSize)
+			reply("250 8BITMIME")
+		case "MAIL":
+			addr, ok := pathArg(arg, "FROM:")
+			switch {
+			case !ok:
+				reply("501 syntax: MAIL FROM:<address>")
+			case !s.allowed(addr):
+				s.log.Warn().Str("sender", addr).Str("remote", remote).Msg("cad mail from sender not in CAD_ALLOWED_SENDERS")
+				reply("550 sender not allowed")
+			default:
+				sender, haveSender, haveRcpt = addr, true, false
+				reply("250 OK")
+			}
+		case "RCPT":
+			if _, ok := pathArg(arg, "TO:"); !ok {
+				reply("501 syntax: RCPT TO:<address>")
+			} else if !haveSender {
+				reply("503 MAIL first")
+			} else {
+				haveRcpt = true
+				reply("250 OK")
+			}
+		case "DATA":
+			if !haveRcpt {
+				reply("503 RCPT first")
+				continue
+			}
+			if !reply("354 end data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			data, tooBig, err := readData(tp.DotReader(), s.MaxSize)
+			if err != nil {
+				return
+			}
+			haveSender, haveRcpt = false, false
+			if tooBig {
+				reply("552 message exceeds %d bytes", s.MaxSize)
+				continue
+			}
+			if err := s.deliver(sender, data); err != nil {
+				s.log.Warn().Err(err).Str("sender", sender).Msg("cad mail delivery failed")
+				reply("451 temporary failure, try again later")
+				continue
+			}
+			reply("250 OK")
+		case "RSET":
+			haveSender, haveRcpt = false, false
+			reply("250 OK")
+		case "NOOP":
+			reply("250 OK")
+		case "VRFY":
+			reply("252 cannot verify")
+		case "QUIT":
+			reply("221 %s closing", s.Hostname)
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+// pathArg extracts the address from "FROM:<addr> [params]". The null sender
+// "<>" is accepted as "".
+func pathArg(arg, prefix string) (string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", false
+	}
+	rest := strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(rest, "<") {
+		return "", false
+	}
+	end := strings.IndexByte(rest, '>')
+	if end < 0 {
+		return "", false
+	}
+	return rest[1:end], true
+}
+
+// readData reads a dot-terminated message body, discarding it once it
+// exceeds max bytes.
+func readData(r io.Reader, max int64) (data []byte, tooBig bool, err error) {
+	data, err = io.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(data)) > max {
+		_, err = io.Copy(io.Discard, r)
+		return nil, true, err
+	}
+	return data, false, nil
+}
@@ -4,7 +4,10 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
+	"net"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/caarlos0/env/v11"
@@ -13,24 +16,163 @@ import (
 
 type Config struct {
 	DatabaseURL   string `env:"DATABASE_URL,required"`
+	// Fraction (0-1) of hot list queries EXPLAINed in the background with the
+	// plan logged, to catch plan regressions. 0 = off.
+	DBExplainSample float64 `env:"DB_EXPLAIN_SAMPLE" envDefault:"0"`
 	MQTTBrokerURL string `env:"MQTT_BROKER_URL"`
 	MQTTTopics       string `env:"MQTT_TOPICS" envDefault:"#"`
-	MQTTInstanceMap  string `env:"MQTT_INSTANCE_MAP"` // "prefix:instance_id,prefix:instance_id"
+	MQTTInstanceMap  string `env:"MQTT_INSTANCE_MAP"` // "prefix:instance_id,prefix:instance_id"; prefixes may span topic levels
+	// Subscribe to "{prefix}/#" per MQTT_INSTANCE_MAP prefix instead of a "#"
+	// in MQTT_TOPICS.
+	MQTTSubscribeMapped bool `env:"MQTT_SUBSCRIBE_MAPPED" envDefault:"false"`
 	MQTTClientID  string `env:"MQTT_CLIENT_ID" envDefault:"tr-engine"`
 	MQTTUsername  string `env:"MQTT_USERNAME"`
 	MQTTPassword  string `env:"MQTT_PASSWORD"`
+	// "receive" (QoS 0, default) or "processed": subscribe at QoS 1 with a
+	// persistent session and ack each message only after it is handled,
+	// holding messages while the database is unreachable.
+	MQTTAckMode          string        `env:"MQTT_ACK_MODE" envDefault:"receive"`
+	MQTTMaxInflight      int           `env:"MQTT_MAX_INFLIGHT" envDefault:"16"`
+	MQTTAckRetryInterval time.Duration `env:"MQTT_ACK_RETRY_INTERVAL" envDefault:"5s"`
 
 	AudioDir   string `env:"AUDIO_DIR" envDefault:"./audio"`
 	TRAudioDir string `env:"TR_AUDIO_DIR"`
 
+	// TR_AUDIO_DIR archiving: copy ("copy") or copy and hash-verify ("verify")
+	// audio served from TR's capture dir into AUDIO_DIR/S3 once a call is
+	// TR_AUDIO_ARCHIVE_DELAY old, before TR purges it after TR_AUDIO_PURGE_WINDOW.
+	TRAudioArchive         string        `env:"TR_AUDIO_ARCHIVE" envDefault:"off"`
+	TRAudioArchiveDelay    time.Duration `env:"TR_AUDIO_ARCHIVE_DELAY" envDefault:"2m"`
+	TRAudioArchiveInterval time.Duration `env:"TR_AUDIO_ARCHIVE_INTERVAL" envDefault:"1m"`
+	TRAudioPurgeWindow     time.Duration `env:"TR_AUDIO_PURGE_WINDOW" envDefault:"24h"`
+
+	// Late-audio reconciliation: every AUDIO_RECONCILE_INTERVAL, look for
+	// calls without audio (lost audio MQTT message) that are at least
+	// AUDIO_RECONCILE_DELAY and at most AUDIO_RECONCILE_WINDOW old, and attach
+	// the file TR left in WATCH_DIR/TR_AUDIO_DIR for the same talkgroup
+	// starting within AUDIO_RECONCILE_TOLERANCE. A call not found after
+	// AUDIO_RECONCILE_ATTEMPTS searches is recorded as missing.
+	AudioReconcile          bool          `env:"AUDIO_RECONCILE" envDefault:"false"`
+	AudioReconcileInterval  time.Duration `env:"AUDIO_RECONCILE_INTERVAL" envDefault:"10m"`
+	AudioReconcileDelay     time.Duration `env:"AUDIO_RECONCILE_DELAY" envDefault:"5m"`
+	AudioReconcileWindow    time.Duration `env:"AUDIO_RECONCILE_WINDOW" envDefault:"24h"`
+	AudioReconcileTolerance time.Duration `env:"AUDIO_RECONCILE_TOLERANCE" envDefault:"3s"`
+	AudioReconcileAttempts  int           `env:"AUDIO_RECONCILE_ATTEMPTS" envDefault:"3"`
+
+	// Edge-to-central replication: with REPLICATE_URL set, finished calls are
+	// pushed to that tr-engine's call-upload API (REPLICATE_TOKEN is its
+	// WRITE_TOKEN), audio as resumable uploads capped at REPLICATE_MAX_KBPS
+	// (0 = uncapped) and only during REPLICATE_WINDOW ("HH:MM-HH:MM" local,
+	// empty = any time). Calls older than REPLICATE_BACKFILL are not sent.
+	ReplicateURL      string        `env:"REPLICATE_URL"`
+	ReplicateToken    string        `env:"REPLICATE_TOKEN"`
+	ReplicateMaxKbps  int           `env:"REPLICATE_MAX_KBPS" envDefault:"0"`
+	ReplicateWindow   string        `env:"REPLICATE_WINDOW"`
+	ReplicateDelay    time.Duration `env:"REPLICATE_DELAY" envDefault:"2m"`
+	ReplicateInterval time.Duration `env:"REPLICATE_INTERVAL" envDefault:"1m"`
+	ReplicateBackfill time.Duration `env:"REPLICATE_BACKFILL" envDefault:"72h"`
+
+	// rdio-scanner forwarding: with FORWARD_URL set, finished calls with audio
+	// on systems enabled under /admin/forwarding/systems are posted to that
+	// rdio-scanner's /api/call-upload with FORWARD_API_KEY. Calls older than
+	// FORWARD_BACKFILL are not sent.
+	ForwardURL      string        `env:"FORWARD_URL"`
+	ForwardAPIKey   string        `env:"FORWARD_API_KEY"`
+	ForwardDelay    time.Duration `env:"FORWARD_DELAY" envDefault:"30s"`
+	ForwardInterval time.Duration `env:"FORWARD_INTERVAL" envDefault:"15s"`
+	ForwardBackfill time.Duration `env:"FORWARD_BACKFILL" envDefault:"6h"`
+
+	// Duplicate call audit: each night at DUPLICATE_AUDIT_HOUR (local) pair up
+	// ungrouped calls on the same talkgroup that overlap in time and record
+	// those scoring at least DUPLICATE_AUDIT_MIN_CONFIDENCE for review under
+	// /admin/duplicate-audit. Pairs scoring at least DUPLICATE_AUDIT_AUTO_GROUP
+	// are grouped right away (0 = never).
+	DuplicateAudit              bool          `env:"DUPLICATE_AUDIT" envDefault:"true"`
+	DuplicateAuditHour          int           `env:"DUPLICATE_AUDIT_HOUR" envDefault:"3"`
+	DuplicateAuditLookback      time.Duration `env:"DUPLICATE_AUDIT_LOOKBACK" envDefault:"48h"`
+	DuplicateAuditMaxStartGap   time.Duration `env:"DUPLICATE_AUDIT_MAX_START_GAP" envDefault:"10s"`
+	DuplicateAuditMinConfidence float64       `env:"DUPLICATE_AUDIT_MIN_CONFIDENCE" envDefault:"0.5"`
+	DuplicateAuditAutoGroup     float64       `env:"DUPLICATE_AUDIT_AUTO_GROUP" envDefault:"0"`
+
+	// After a talkgroup directory or unit import, re-stamp the talkgroup and
+	// unit names on the system's calls from this far back (0 = off; use
+	// /admin/call-restamp/run for older history).
+	CallRestampAfterImport time.Duration `env:"CALL_RESTAMP_AFTER_IMPORT" envDefault:"0s"`
+
+	// Webhooks (managed at /api/v1/webhooks): per-request timeout and
+	// attempts before a delivery is marked failed.
+	WebhookTimeout     time.Duration `env:"WEBHOOK_TIMEOUT" envDefault:"10s"`
+	WebhookMaxAttempts int           `env:"WEBHOOK_MAX_ATTEMPTS" envDefault:"8"`
+
+	// Icecast output (optional — disabled when ICECAST_URL is empty): finished
+	// calls are streamed to the mounts in ICECAST_MOUNTS (JSON, see
+	// internal/icecast), one continuous 16 kHz mono MP3 stream per talkgroup
+	// group. ICECAST_SILENCE is the padding after each call unless a mount
+	// sets silence_ms. Needs ffmpeg.
+	IcecastURL      string        `env:"ICECAST_URL"`
+	IcecastUsername string        `env:"ICECAST_USERNAME" envDefault:"source"`
+	IcecastPassword string        `env:"ICECAST_PASSWORD"`
+	IcecastMounts   string        `env:"ICECAST_MOUNTS"`
+	IcecastBitrate  int           `env:"ICECAST_BITRATE" envDefault:"32000"`
+	IcecastSilence  time.Duration `env:"ICECAST_SILENCE" envDefault:"1s"`
+
+	// Audio duration verification: measure each saved recording and flag calls
+	// whose TR-reported call_length differs by more than the tolerance.
+	AudioDurationCheck     bool          `env:"AUDIO_DURATION_CHECK" envDefault:"true"`
+	AudioDurationTolerance time.Duration `env:"AUDIO_DURATION_TOLERANCE" envDefault:"2s"`
+	// Replace a call's duration and stop_time with the measured audio length
+	// when they differ by more than this (0 = off). The reported values are
+	// kept in original_duration/original_stop_time.
+	AudioDurationCorrect time.Duration `env:"AUDIO_DURATION_CORRECT" envDefault:"0s"`
+
+	// Skip MQTT audio messages repeating one handled within this window
+	// (trunk-recorder republishes after a broker reconnect); 0 = off.
+	AudioDedupWindow time.Duration `env:"AUDIO_DEDUP_WINDOW" envDefault:"10m"`
+
+	// Unusable audio: flag calls whose recording is empty (0-byte or
+	// header-only) or silent (nothing reaches AUDIO_SILENCE_LEVEL dBFS) and
+	// skip transcribing them. AUDIO_UNUSABLE_DELETE also removes the file
+	// when it is on local disk.
+	AudioUnusableCheck  bool    `env:"AUDIO_UNUSABLE_CHECK" envDefault:"true"`
+	AudioSilenceLevel   float64 `env:"AUDIO_SILENCE_LEVEL" envDefault:"-60"`
+	AudioUnusableDelete bool    `env:"AUDIO_UNUSABLE_DELETE" envDefault:"false"`
+
+	// Stuck microphone detection: flag calls keyed by a single unit for longer
+	// than STUCK_MIC_MIN_DURATION (0 = off), alert on the event stream, and skip
+	// transcribing them unless their audio turns out to be mostly speech.
+	StuckMicMinDuration       time.Duration `env:"STUCK_MIC_MIN_DURATION" envDefault:"5m"`
+	StuckMicMaxSpeechRatio    float64       `env:"STUCK_MIC_MAX_SPEECH_RATIO" envDefault:"0.2"`
+	StuckMicSkipTranscription bool          `env:"STUCK_MIC_SKIP_TRANSCRIPTION" envDefault:"true"`
+
+	// Merge a call into the call it continues when TR splits one
+	// transmission in two: same talkgroup and initiating unit, starting no
+	// more than SPLIT_CALL_MAX_GAP after the other stopped (0 = off).
+	SplitCallMaxGap time.Duration `env:"SPLIT_CALL_MAX_GAP" envDefault:"0"`
+
 	// File-watch ingest mode (alternative to MQTT)
 	WatchDir          string `env:"WATCH_DIR"`
 	WatchInstanceID   string `env:"WATCH_INSTANCE_ID" envDefault:"file-watch"`
 	WatchBackfillDays int    `env:"WATCH_BACKFILL_DAYS" envDefault:"7"`
+	// Also import audio files that have no .json, deriving metadata from
+	// their paths with FILENAME_PATTERNS.
+	WatchAudioOnly bool `env:"WATCH_AUDIO_ONLY" envDefault:"false"`
+
+	// Comma-separated patterns for deriving tgid/start time/system from audio
+	// filenames when no metadata JSON exists (bare file uploads and
+	// WATCH_AUDIO_ONLY). Placeholders are documented in
+	// internal/ingest/filename_meta.go; empty disables filename metadata.
+	FilenamePatterns string `env:"FILENAME_PATTERNS" envDefault:"{system}/{#}/{#}/{#}/{tgid}-{start}_{freq}-call_{#},{tgid}-{start}_{freq}-call_{#},{tgid}-{start}_{freq},{tgid}-{start}"`
 
 	// HTTP upload ingest mode (rdio-scanner / OpenMHz compatible)
 	UploadInstanceID string `env:"UPLOAD_INSTANCE_ID" envDefault:"http-upload"`
 
+	// Resumable call uploads (/api/v1/call-upload/sessions). Sessions are
+	// kept in UploadSessionDir (default: <temp dir>/tr-engine-uploads) and
+	// removed after UploadSessionTTL without activity.
+	UploadSessionDir string        `env:"UPLOAD_SESSION_DIR"`
+	UploadSessionTTL time.Duration `env:"UPLOAD_SESSION_TTL" envDefault:"24h"`
+	UploadMaxSize    int64         `env:"UPLOAD_MAX_SIZE" envDefault:"268435456"` // 256 MB
+
 	// Live audio streaming (simplestream UDP ingest → WebSocket relay)
 	StreamListen      string        `env:"STREAM_LISTEN"`                              // UDP listen address, e.g. ":9123". Feature disabled if empty.
 	StreamSampleRate  int           `env:"STREAM_SAMPLE_RATE" envDefault:"8000"`        // Default PCM sample rate (8000 P25, 16000 analog)
@@ -38,10 +180,26 @@ type Config struct {
 	StreamMaxClients  int           `env:"STREAM_MAX_CLIENTS" envDefault:"50"`          // Max concurrent WebSocket listeners
 	StreamIdleTimeout time.Duration `env:"STREAM_IDLE_TIMEOUT" envDefault:"30s"`        // Tear down per-TG encoder after idle
 
+	// WebRTC live audio (optional — needs STREAM_LISTEN): the same frames as
+	// the WebSocket, sent to browser peers as G.711 tracks (internal/rtcaudio).
+	WebRTCEnabled       bool   `env:"WEBRTC_ENABLED"`
+	WebRTCICEServers    string `env:"WEBRTC_ICE_SERVERS"`                        // comma-separated stun:/turn: URLs
+	WebRTCICEUsername   string `env:"WEBRTC_ICE_USERNAME"`                       // TURN username
+	WebRTCICECredential string `env:"WEBRTC_ICE_CREDENTIAL"`                     // TURN password
+	WebRTCUDPPorts      string `env:"WEBRTC_UDP_PORTS" envDefault:"50000-50100"` // ICE UDP port range
+	WebRTCPublicIP      string `env:"WEBRTC_PUBLIC_IP"`                          // NAT 1:1 address advertised in host candidates
+	WebRTCMaxPeers      int    `env:"WEBRTC_MAX_PEERS"`                          // 0 = STREAM_MAX_CLIENTS
+	WebRTCLanes         int    `env:"WEBRTC_LANES" envDefault:"4"`               // audio tracks per peer = talkgroups heard at once
+
 	// TR auto-discovery (reads trunk-recorder's config.json + docker-compose.yaml)
 	TRDir        string `env:"TR_DIR"`
 	CSVWriteback bool   `env:"CSV_WRITEBACK" envDefault:"false"` // write edits back to TR's CSV files on disk
 
+	// Unit tag CSV sync: re-import changed unit CSV rows on an interval (0 = startup
+	// only) and, when UnitCSVWriteback is on, write manual and learned tags back.
+	UnitCSVSyncInterval time.Duration `env:"UNIT_CSV_SYNC_INTERVAL" envDefault:"5m"`
+	UnitCSVWriteback    bool          `env:"UNIT_CSV_WRITEBACK" envDefault:"false"`
+
 	// P25 system merging: when true (default), systems with the same sysid/wacn
 	// are auto-merged into one system with multiple sites. Set to false to keep
 	// each TR instance's systems separate even if they share sysid/wacn.
@@ -56,14 +214,39 @@ type Config struct {
 	AuthToken          string `env:"AUTH_TOKEN"`
 	AuthTokenGenerated bool   // true when auto-generated (not from env/config)
 	WriteToken         string `env:"WRITE_TOKEN"` // separate token for write operations; if not set, writes use AuthToken
+	ShareSigningKey    string `env:"SHARE_SIGNING_KEY"` // HMAC key for share links; derived from WriteToken when empty
+	// Web UI accounts (/api/v1/auth/login): sessions end after LoginSessionTTL
+	// without use. AuthInitEnabled=false stops handing AUTH_TOKEN to web
+	// pages, so people must sign in.
+	LoginSessionTTL time.Duration `env:"LOGIN_SESSION_TTL" envDefault:"720h"`
+	AuthInitEnabled bool          `env:"AUTH_INIT_ENABLED" envDefault:"true"`
 	RateLimitRPS   float64 `env:"RATE_LIMIT_RPS" envDefault:"20"`
 	RateLimitBurst int     `env:"RATE_LIMIT_BURST" envDefault:"40"`
+	// Authenticated API limits on top of the per-IP one (0 = off): all
+	// clients combined, per bearer token, and per IP on expensive endpoints.
+	RateLimitGlobalRPS       float64 `env:"RATE_LIMIT_GLOBAL_RPS" envDefault:"0"`
+	RateLimitGlobalBurst     int     `env:"RATE_LIMIT_GLOBAL_BURST" envDefault:"200"`
+	RateLimitTokenRPS        float64 `env:"RATE_LIMIT_TOKEN_RPS" envDefault:"0"`
+	RateLimitTokenBurst      int     `env:"RATE_LIMIT_TOKEN_BURST" envDefault:"40"`
+	RateLimitExpensivePerMin float64 `env:"RATE_LIMIT_EXPENSIVE_PER_MIN" envDefault:"30"`
+	RateLimitExpensiveBurst  int     `env:"RATE_LIMIT_EXPENSIVE_BURST" envDefault:"10"`
+	RateLimitExpensivePaths  string  `env:"RATE_LIMIT_EXPENSIVE_PATHS" envDefault:"/api/v1/transcriptions/search,/api/v1/search/semantic,/api/v1/query,/api/v1/stats/capacity,/api/v1/stats/call-heatmap,/api/v1/calls/timeline,/api/v1/admin/warehouse/run"`
 	CORSOrigins string `env:"CORS_ORIGINS"` // comma-separated allowed origins; empty = allow all (*)
 	LogLevel    string `env:"LOG_LEVEL" envDefault:"info"`
 
 	RawStore         bool   `env:"RAW_STORE" envDefault:"true"`
 	RawIncludeTopics string `env:"RAW_INCLUDE_TOPICS"`
 	RawExcludeTopics string `env:"RAW_EXCLUDE_TOPICS"`
+	RawRedact        string `env:"RAW_REDACT"` // handler:path,...,max=N;... (see ingest.ParseRawRedact)
+
+	// Ingest payload validation: "quarantine" rejects malformed MQTT payloads into
+	// ingest_quarantine, "log" only warns, "off" disables validation.
+	IngestValidation string `env:"INGEST_VALIDATION" envDefault:"quarantine"`
+
+	// Whether instances may auto-create systems/sites for new sys_names:
+	// "allow", or "deny" to stage them for approval unless the instance has a
+	// policy allowing it (see /api/v1/admin/instances).
+	IngestAutoCreate string `env:"INGEST_AUTO_CREATE" envDefault:"allow"`
 
 	// Transcription (optional — disabled when no STT provider is configured)
 	STTProvider        string `env:"STT_PROVIDER" envDefault:"whisper"`
@@ -77,6 +260,17 @@ type Config struct {
 	WhisperHotwords    string        `env:"WHISPER_HOTWORDS"`
 	WhisperBeamSize    int           `env:"WHISPER_BEAM_SIZE" envDefault:"0"`
 
+	// Per-call prompts: when WhisperPromptAuto is set, each call's prompt is
+	// built from WhisperPromptTemplate (Go text/template; empty = built-in)
+	// with the talkgroup, the units on the call and up to
+	// WhisperPromptContext recent transcripts on the talkgroup from within
+	// WhisperPromptContextWindow. Per-talkgroup overrides are set via the
+	// admin API and apply regardless.
+	WhisperPromptAuto          bool          `env:"WHISPER_PROMPT_AUTO" envDefault:"false"`
+	WhisperPromptTemplate      string        `env:"WHISPER_PROMPT_TEMPLATE"`
+	WhisperPromptContext       int           `env:"WHISPER_PROMPT_CONTEXT" envDefault:"3"`
+	WhisperPromptContextWindow time.Duration `env:"WHISPER_PROMPT_CONTEXT_WINDOW" envDefault:"30m"`
+
 	// Anti-hallucination parameters (require custom whisper-server or compatible endpoint)
 	WhisperRepetitionPenalty          float64 `env:"WHISPER_REPETITION_PENALTY" envDefault:"0"`
 	WhisperNoRepeatNgram              int     `env:"WHISPER_NO_REPEAT_NGRAM" envDefault:"0"`
@@ -95,18 +289,107 @@ type Config struct {
 	DeepInfraAPIKey string `env:"DEEPINFRA_STT_API_KEY"`
 	DeepInfraModel  string `env:"DEEPINFRA_STT_MODEL" envDefault:"openai/whisper-large-v3-turbo"`
 
-	// LLM post-processing (optional — disabled when LLM_URL is empty; not yet implemented)
+	// Custom STT endpoint (bring-your-own provider; used when STT_PROVIDER=custom)
+	CustomSTTURL     string `env:"CUSTOM_STT_URL"`
+	CustomSTTModel   string `env:"CUSTOM_STT_MODEL"`
+	CustomSTTHeaders string `env:"CUSTOM_STT_HEADERS"` // semicolon-separated "Name: value" pairs
+
+	// Urgency classification after transcription: "off", "keyword" (built-in
+	// vocabulary), or "model" (keyword plus URGENCY_MODEL_URL, see docs/urgency-classifier.md)
+	UrgencyClassifier   string        `env:"URGENCY_CLASSIFIER" envDefault:"off"`
+	UrgencyModelURL     string        `env:"URGENCY_MODEL_URL"`
+	UrgencyModelHeaders string        `env:"URGENCY_MODEL_HEADERS"` // semicolon-separated "Name: value" pairs
+	UrgencyModelTimeout time.Duration `env:"URGENCY_MODEL_TIMEOUT" envDefault:"10s"`
+
+	// Unit alias inference: scan attributed transcripts for self-identifications
+	// by untagged units and queue alias suggestions for review (0 = disabled).
+	// The first scan after startup covers UnitAliasLookback.
+	UnitAliasInterval time.Duration `env:"UNIT_ALIAS_INTERVAL" envDefault:"1h"`
+	UnitAliasLookback time.Duration `env:"UNIT_ALIAS_LOOKBACK" envDefault:"168h"`
+
+	// Unit session compaction: fold unit_events into unit_sessions every
+	// UnitSessionInterval (0 = disabled). A session ends after UnitSessionIdle
+	// without events; the first run covers UnitSessionBackfill.
+	UnitSessionInterval time.Duration `env:"UNIT_SESSION_INTERVAL" envDefault:"15m"`
+	UnitSessionIdle     time.Duration `env:"UNIT_SESSION_IDLE" envDefault:"1h"`
+	UnitSessionBackfill time.Duration `env:"UNIT_SESSION_BACKFILL" envDefault:"168h"`
+
+	// CAD pages by email (optional — disabled when CAD_PAGE_FORMATS is empty):
+	// dispatch pages are parsed with the formats in CAD_PAGE_FORMATS (JSON,
+	// see docs/cad-pages.md) and each incident is linked to calls on its
+	// dispatch talkgroups. Pages arrive on a receive-only SMTP listener at
+	// CAD_SMTP_LISTEN and/or POST /api/v1/cad-incidents/ingest.
+	// CAD_ALLOWED_SENDERS restricts the SMTP envelope sender (addresses or
+	// "@domain", comma-separated).
+	CADSMTPListen     string `env:"CAD_SMTP_LISTEN"`
+	CADPageFormats    string `env:"CAD_PAGE_FORMATS"`
+	CADAllowedSenders string `env:"CAD_ALLOWED_SENDERS"`
+	CADMaxMessageSize int64  `env:"CAD_MAX_MESSAGE_SIZE" envDefault:"1048576"`
+
+	// Event bridge to a streaming platform (optional — disabled when
+	// BRIDGE_DRIVER is empty): "nats" (JetStream) or "kafka-rest" (Kafka via a
+	// REST Proxy). BRIDGE_EVENTS may include "alert" (emergency events and
+	// urgent transcripts, see internal/bridge).
+	BridgeDriver       string `env:"BRIDGE_DRIVER"`
+	BridgeURLs         string `env:"BRIDGE_URLS"` // comma-separated; tried in order
+	BridgeEvents       string `env:"BRIDGE_EVENTS" envDefault:"call_end,transcription,unit_event,alert"`
+	BridgeTopicPrefix  string `env:"BRIDGE_TOPIC_PREFIX" envDefault:"tr-engine."`
+	BridgePartitionKey string `env:"BRIDGE_PARTITION_KEY" envDefault:"system_tgid"` // "system" or "system_tgid"
+	BridgeBuffer       int    `env:"BRIDGE_BUFFER" envDefault:"10000"`
+	BridgeUsername     string `env:"BRIDGE_USERNAME"`
+	BridgePassword     string `env:"BRIDGE_PASSWORD"`
+	BridgeToken        string `env:"BRIDGE_TOKEN"` // NATS auth token
+	BridgeFilter       string `env:"BRIDGE_FILTER"` // expression events must match (internal/expr); empty = all
+
+	// OpenAI-compatible chat completions endpoint (optional — disabled when
+	// LLM_URL is empty). Used by the archive Q&A endpoint (POST /api/v1/ask).
 	LLMUrl     string        `env:"LLM_URL"`
 	LLMModel   string        `env:"LLM_MODEL"`
-	LLMTimeout time.Duration `env:"LLM_TIMEOUT" envDefault:"30s"`
+	LLMHeaders string        `env:"LLM_HEADERS"` // semicolon-separated "Name: value" pairs
+	LLMTimeout time.Duration `env:"LLM_TIMEOUT" envDefault:"25s"`
+
+	// Transcript embeddings for semantic search (optional — disabled when
+	// EMBED_URL is empty; requires the pgvector extension). EMBED_URL is an
+	// OpenAI-compatible embeddings endpoint; new transcripts from the last
+	// EMBED_LOOKBACK are embedded every EMBED_INTERVAL, older ones via
+	// POST /api/v1/admin/embeddings/backfill.
+	EmbedURL        string        `env:"EMBED_URL"`
+	EmbedModel      string        `env:"EMBED_MODEL"`
+	EmbedDimensions int           `env:"EMBED_DIMENSIONS"`
+	EmbedHeaders    string        `env:"EMBED_HEADERS"` // semicolon-separated "Name: value" pairs
+	EmbedTimeout    time.Duration `env:"EMBED_TIMEOUT" envDefault:"30s"`
+	EmbedBatchSize  int           `env:"EMBED_BATCH_SIZE" envDefault:"32"`
+	EmbedInterval   time.Duration `env:"EMBED_INTERVAL" envDefault:"1m"`
+	EmbedLookback   time.Duration `env:"EMBED_LOOKBACK" envDefault:"24h"`
+
+	// Archive Q&A: questions per minute per client IP (0 = global limit only)
+	// and transcripts given to the model per question.
+	AskRateLimit  float64 `env:"ASK_RATE_LIMIT" envDefault:"6"`
+	AskMaxContext int     `env:"ASK_MAX_CONTEXT" envDefault:"20"`
+
+	// In-memory cache for hot read endpoints (systems, talkgroups, stats)
+	APICache           bool `env:"API_CACHE" envDefault:"true"`
+	APICacheMaxEntries int  `env:"API_CACHE_MAX_ENTRIES" envDefault:"1000"`
+
+	// Public talkgroup feeds (/api/v1/feeds/{slug}.json|rss|atom)
+	FeedBaseURL     string        `env:"FEED_BASE_URL"` // absolute URL prefix for feed links; derived from request headers when empty
+	FeedCacheMaxAge time.Duration `env:"FEED_CACHE_MAX_AGE" envDefault:"60s"`
 
 	// Prometheus metrics endpoint at /metrics (enabled by default)
 	MetricsEnabled bool `env:"METRICS_ENABLED" envDefault:"true"`
+	// Most talkgroups exported as per-talkgroup series (/admin/metrics/talkgroups)
+	MetricsTalkgroupLimit int `env:"METRICS_TALKGROUP_LIMIT" envDefault:"50"`
 
 	// Update checker (enabled by default — set UPDATE_CHECK=false to disable)
 	UpdateCheck    bool   `env:"UPDATE_CHECK" envDefault:"true"`
 	UpdateCheckURL string `env:"UPDATE_CHECK_URL" envDefault:"https://updates.luxprimatech.com/check"`
 
+	// Self-update of binary installs (disabled unless SELF_UPDATE_PUBLIC_KEY is
+	// set). Release archives must be signed with the matching Ed25519 key.
+	SelfUpdatePublicKey   string        `env:"SELF_UPDATE_PUBLIC_KEY"`
+	SelfUpdateReleasesURL string        `env:"SELF_UPDATE_RELEASES_URL" envDefault:"https://api.github.com/repos/LumenPrima/tr-engine/releases/latest"`
+	SelfUpdateHealthGrace time.Duration `env:"SELF_UPDATE_HEALTH_GRACE" envDefault:"2m"`
+
 	// Audio preprocessing (requires sox in PATH)
 	PreprocessAudio bool `env:"PREPROCESS_AUDIO" envDefault:"false"`
 
@@ -116,19 +399,79 @@ type Config struct {
 	RetentionPluginStatus time.Duration `env:"RETENTION_PLUGIN_STATUS" envDefault:"720h"`  // 30d
 	RetentionCheckpoints  time.Duration `env:"RETENTION_CHECKPOINTS" envDefault:"168h"`    // 7d
 	RetentionStaleCalls   time.Duration `env:"RETENTION_STALE_CALLS" envDefault:"1h"`
+	RetentionInactiveUnits time.Duration `env:"RETENTION_INACTIVE_UNITS" envDefault:"0"` // 0 = never archive units
+	RetentionQuarantine    time.Duration `env:"RETENTION_QUARANTINE" envDefault:"720h"`  // 30d
+	RetentionExternalEvents time.Duration `env:"RETENTION_EXTERNAL_EVENTS" envDefault:"720h"` // 30d; 0 = keep forever
+	RetentionWebhookDeliveries time.Duration `env:"RETENTION_WEBHOOK_DELIVERIES" envDefault:"720h"` // 30d; 0 = keep forever
+	RetentionTimeseries    time.Duration `env:"TIMESERIES_RETENTION" envDefault:"720h"`  // 30d; 0 = don't collect
+	RetentionUnitEvents    time.Duration `env:"RETENTION_UNIT_EVENTS" envDefault:"0"`    // 0 = keep forever; only compacted events are purged
+	RetentionUnitSessions  time.Duration `env:"RETENTION_UNIT_SESSIONS" envDefault:"0"`  // 0 = keep forever
+	RetentionCalls         time.Duration `env:"RETENTION_CALLS" envDefault:"0"`          // 0 = keep forever; calls under a legal hold are kept
+	RetentionCallAudio     time.Duration `env:"RETENTION_CALL_AUDIO" envDefault:"0"`     // 0 = keep until the call is purged
+	// Delete the oldest call audio when the disk holding AUDIO_DIR is fuller
+	// than this percentage (local-only store; 0 = off). Pinned calls keep
+	// their audio (and the call purge skips them) unless
+	// AUDIO_RETENTION_KEEP_PINNED=false.
+	AudioDiskMaxPercent      int  `env:"AUDIO_DISK_MAX_PERCENT" envDefault:"0"`
+	AudioRetentionKeepPinned bool `env:"AUDIO_RETENTION_KEEP_PINNED" envDefault:"true"`
+
+	// Maintenance audit: every destructive maintenance step is previewed and
+	// written to maintenance_audit before it runs. With MAINTENANCE_DRY_RUN,
+	// or for the first MAINTENANCE_OBSERVE_CYCLES scheduled runs, steps are
+	// only recorded. MAINTENANCE_DISABLED_TASKS is a comma-separated list of
+	// tasks to skip (see database.MaintenanceTasks); admin overrides win.
+	MaintenanceDryRun        bool   `env:"MAINTENANCE_DRY_RUN" envDefault:"false"`
+	MaintenanceObserveCycles int    `env:"MAINTENANCE_OBSERVE_CYCLES" envDefault:"0"`
+	MaintenanceDisabledTasks string `env:"MAINTENANCE_DISABLED_TASKS"`
+
+	// Data warehouse export: write each completed UTC day of calls, unit events
+	// and transcriptions to Parquet under WAREHOUSE_DIR ("local") or
+	// WAREHOUSE_S3_BUCKET/WAREHOUSE_S3_PREFIX ("s3", with the S3_* endpoint and
+	// credentials) once WAREHOUSE_EXPORT_DELAY has passed since the day ended.
+	WarehouseExport       string        `env:"WAREHOUSE_EXPORT" envDefault:"off"`
+	WarehouseDir          string        `env:"WAREHOUSE_DIR" envDefault:"./warehouse"`
+	WarehouseS3Bucket     string        `env:"WAREHOUSE_S3_BUCKET"` // defaults to S3_BUCKET
+	WarehouseS3Prefix     string        `env:"WAREHOUSE_S3_PREFIX" envDefault:"warehouse"`
+	WarehouseExportDelay  time.Duration `env:"WAREHOUSE_EXPORT_DELAY" envDefault:"2h"`
+	WarehouseBackfillDays int           `env:"WAREHOUSE_BACKFILL_DAYS" envDefault:"0"` // 0 = every day in the database
 
 	// Transcription worker pool
-	TranscribeWorkers     int     `env:"TRANSCRIBE_WORKERS" envDefault:"2"`
-	TranscribeQueueSize   int     `env:"TRANSCRIBE_QUEUE_SIZE" envDefault:"500"`
-	TranscribeMinDuration float64 `env:"TRANSCRIBE_MIN_DURATION" envDefault:"1.0"`
-	TranscribeMaxDuration float64 `env:"TRANSCRIBE_MAX_DURATION" envDefault:"300"`
+	TranscribeWorkers     int           `env:"TRANSCRIBE_WORKERS" envDefault:"2"`
+	TranscribeQueueSize   int           `env:"TRANSCRIBE_QUEUE_SIZE" envDefault:"500"`
+	TranscribeMinDuration float64       `env:"TRANSCRIBE_MIN_DURATION" envDefault:"1.0"`
+	TranscribeMaxDuration float64       `env:"TRANSCRIBE_MAX_DURATION" envDefault:"300"`
+	TranscribeJobDeadline time.Duration `env:"TRANSCRIBE_JOB_DEADLINE"` // 0 = WHISPER_TIMEOUT + 40s
+	TranscribeMaxRetries  int           `env:"TRANSCRIBE_MAX_RETRIES" envDefault:"2"`
+
+	// Calls longer than TRANSCRIBE_MAX_DURATION are skipped, or with
+	// TRANSCRIBE_LONG_CALLS=segment transcribed in overlapping chunks (seconds)
+	// up to TRANSCRIBE_SEGMENT_MAX_DURATION and stitched into one transcript.
+	TranscribeLongCalls          string  `env:"TRANSCRIBE_LONG_CALLS" envDefault:"skip"`
+	TranscribeSegmentLength      float64 `env:"TRANSCRIBE_SEGMENT_LENGTH" envDefault:"120"`
+	TranscribeSegmentOverlap     float64 `env:"TRANSCRIBE_SEGMENT_OVERLAP" envDefault:"5"`
+	TranscribeSegmentMaxDuration float64 `env:"TRANSCRIBE_SEGMENT_MAX_DURATION" envDefault:"7200"`
+
+	// Bulk re-transcription (/admin/retranscribe-jobs): the most calls a job
+	// may run at once, and prices per audio minute for its cost estimate as
+	// comma-separated "provider=price" or "provider:model=price" entries.
+	RetranscribeMaxConcurrency int    `env:"RETRANSCRIBE_MAX_CONCURRENCY" envDefault:"4"`
+	STTCostPerMinute           string `env:"STT_COST_PER_MINUTE"`
 
 	// Transcription talkgroup filtering
 	TranscribeIncludeTGIDs string `env:"TRANSCRIBE_INCLUDE_TGIDS"` // allowlist: only transcribe these TGIDs
 	TranscribeExcludeTGIDs string `env:"TRANSCRIBE_EXCLUDE_TGIDS"` // denylist: skip these TGIDs
 
+	// Audio storage backend: "local", "s3", "azure" or "gcs". Empty selects
+	// s3 when S3_BUCKET is set, else local. The S3_LOCAL_CACHE, S3_CACHE_*,
+	// S3_UPLOAD_* and S3_PRESIGN_EXPIRY settings apply to every object store.
+	StorageBackend string `env:"STORAGE_BACKEND"`
+
 	// S3 audio storage (optional — local disk used when S3_BUCKET is empty)
 	S3 S3Config
+
+	// Azure Blob Storage and Google Cloud Storage audio backends
+	Azure AzureConfig
+	GCS   GCSConfig
 }
 
 // S3Config holds S3-compatible object storage settings for audio files.
@@ -145,19 +488,357 @@ type S3Config struct {
 	CacheRetention time.Duration `env:"S3_CACHE_RETENTION" envDefault:"720h"` // 30d
 	CacheMaxGB     int           `env:"S3_CACHE_MAX_GB" envDefault:"0"`
 	UploadMode     string        `env:"S3_UPLOAD_MODE" envDefault:"async"`
+
+	// Async mode: concurrent uploads, and failed attempts before an upload
+	// gives up (listed and retried via /admin/storage/upload-failures).
+	UploadWorkers     int `env:"S3_UPLOAD_WORKERS" envDefault:"2"`
+	UploadMaxAttempts int `env:"S3_UPLOAD_MAX_ATTEMPTS" envDefault:"10"`
 }
 
 // Enabled reports whether S3 audio storage is configured.
 func (c S3Config) Enabled() bool { return c.Bucket != "" }
 
+// AzureConfig holds Azure Blob Storage settings for STORAGE_BACKEND=azure.
+// Requests are signed with the account's shared key.
+type AzureConfig struct {
+	Account   string `env:"AZURE_STORAGE_ACCOUNT"`
+	Key       string `env:"AZURE_STORAGE_KEY"` // base64 account key
+	Container string `env:"AZURE_STORAGE_CONTAINER"`
+	Endpoint  string `env:"AZURE_STORAGE_ENDPOINT"` // default https://{account}.blob.core.windows.net; set for Azurite
+	Prefix    string `env:"AZURE_STORAGE_PREFIX"`
+}
+
+// GCSConfig holds Google Cloud Storage settings for STORAGE_BACKEND=gcs.
+// Without a credentials file, tokens come from the GCE metadata server
+// (workload identity), and audio URLs are not signed.
+type GCSConfig struct {
+	Bucket          string `env:"GCS_BUCKET"`
+	CredentialsFile string `env:"GCS_CREDENTIALS_FILE"` // service account JSON key
+	Endpoint        string `env:"GCS_ENDPOINT"`         // default https://storage.googleapis.com; set for an emulator (no auth)
+	Prefix          string `env:"GCS_PREFIX"`
+}
+
+// StorageBackendName returns the selected audio storage backend, resolving
+// an empty STORAGE_BACKEND.
+func (c *Config) StorageBackendName() string {
+	if c.StorageBackend != "" {
+		return c.StorageBackend
+	}
+	if c.S3.Enabled() {
+		return "s3"
+	}
+	return "local"
+}
+
+// PortRange parses a "min-max" UDP port range.
+func PortRange(s string) (lo, hi uint16, err error) {
+	a, b, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("want min-max, got %q", s)
+	}
+	x, err1 := strconv.ParseUint(strings.TrimSpace(a), 10, 16)
+	y, err2 := strconv.ParseUint(strings.TrimSpace(b), 10, 16)
+	if err1 != nil || err2 != nil || x == 0 || y < x {
+		return 0, 0, fmt.Errorf("want min-max with 0 < min <= max <= 65535, got %q", s)
+	}
+	return uint16(x), uint16(y), nil
+}
+
 // Validate checks that at least one ingest source (MQTT, watch directory, or TR auto-discovery) is configured.
 func (c *Config) Validate() error {
 	if c.MQTTBrokerURL == "" && c.WatchDir == "" && c.TRDir == "" {
 		return fmt.Errorf("at least one of MQTT_BROKER_URL, WATCH_DIR, or TR_DIR must be set")
 	}
-	if c.S3.Enabled() && c.S3.UploadMode != "async" && c.S3.UploadMode != "sync" {
+	if c.MQTTSubscribeMapped && strings.TrimSpace(c.MQTTInstanceMap) == "" {
+		return fmt.Errorf("MQTT_SUBSCRIBE_MAPPED requires MQTT_INSTANCE_MAP")
+	}
+	switch c.MQTTAckMode {
+	case "receive", "processed":
+	default:
+		return fmt.Errorf("MQTT_ACK_MODE must be receive or processed, got %q", c.MQTTAckMode)
+	}
+	if c.MQTTMaxInflight < 1 {
+		return fmt.Errorf("MQTT_MAX_INFLIGHT must be at least 1, got %d", c.MQTTMaxInflight)
+	}
+	if c.MQTTAckRetryInterval <= 0 {
+		return fmt.Errorf("MQTT_ACK_RETRY_INTERVAL must be positive, got %v", c.MQTTAckRetryInterval)
+	}
+	if c.LoginSessionTTL <= 0 {
+		return fmt.Errorf("LOGIN_SESSION_TTL must be positive, got %v", c.LoginSessionTTL)
+	}
+	if c.UnitSessionInterval > 0 && c.UnitSessionIdle <= 0 {
+		return fmt.Errorf("UNIT_SESSION_IDLE must be > 0, got %s", c.UnitSessionIdle)
+	}
+	if c.RetentionUnitEvents > 0 && c.UnitSessionInterval <= 0 {
+		return fmt.Errorf("RETENTION_UNIT_EVENTS requires unit session compaction (UNIT_SESSION_INTERVAL > 0)")
+	}
+	for name, d := range map[string]time.Duration{"RETENTION_CALLS": c.RetentionCalls, "RETENTION_CALL_AUDIO": c.RetentionCallAudio} {
+		if d < 0 || (d > 0 && d < time.Hour) {
+			return fmt.Errorf("%s must be 0 (keep forever) or at least 1h, got %s", name, d)
+		}
+	}
+	switch c.StorageBackendName() {
+	case "local":
+	case "s3":
+		if !c.S3.Enabled() {
+			return fmt.Errorf("STORAGE_BACKEND=s3 requires S3_BUCKET")
+		}
+	case "azure":
+		if c.Azure.Account == "" || c.Azure.Key == "" || c.Azure.Container == "" {
+			return fmt.Errorf("STORAGE_BACKEND=azure requires AZURE_STORAGE_ACCOUNT, AZURE_STORAGE_KEY and AZURE_STORAGE_CONTAINER")
+		}
+	case "gcs":
+		if c.GCS.Bucket == "" {
+			return fmt.Errorf("STORAGE_BACKEND=gcs requires GCS_BUCKET")
+		}
+	default:
+		return fmt.Errorf("STORAGE_BACKEND must be \"local\", \"s3\", \"azure\", or \"gcs\", got %q", c.StorageBackend)
+	}
+	if c.StorageBackendName() != "local" && c.S3.UploadMode != "async" && c.S3.UploadMode != "sync" {
 		return fmt.Errorf("S3_UPLOAD_MODE must be \"async\" or \"sync\", got %q", c.S3.UploadMode)
 	}
+	if c.S3.UploadWorkers < 1 {
+		return fmt.Errorf("S3_UPLOAD_WORKERS must be >= 1, got %d", c.S3.UploadWorkers)
+	}
+	if c.S3.UploadMaxAttempts < 1 {
+		return fmt.Errorf("S3_UPLOAD_MAX_ATTEMPTS must be >= 1, got %d", c.S3.UploadMaxAttempts)
+	}
+	switch c.IngestValidation {
+	case "quarantine", "log", "off":
+	default:
+		return fmt.Errorf("INGEST_VALIDATION must be \"quarantine\", \"log\", or \"off\", got %q", c.IngestValidation)
+	}
+	if c.IngestAutoCreate != "allow" && c.IngestAutoCreate != "deny" {
+		return fmt.Errorf("INGEST_AUTO_CREATE must be \"allow\" or \"deny\", got %q", c.IngestAutoCreate)
+	}
+	switch c.TRAudioArchive {
+	case "off":
+	case "copy", "verify":
+		if c.TRAudioArchiveInterval <= 0 {
+			return fmt.Errorf("TR_AUDIO_ARCHIVE_INTERVAL must be positive, got %v", c.TRAudioArchiveInterval)
+		}
+		if c.TRAudioPurgeWindow <= c.TRAudioArchiveDelay {
+			return fmt.Errorf("TR_AUDIO_PURGE_WINDOW (%v) must be longer than TR_AUDIO_ARCHIVE_DELAY (%v)",
+				c.TRAudioPurgeWindow, c.TRAudioArchiveDelay)
+		}
+	default:
+		return fmt.Errorf("TR_AUDIO_ARCHIVE must be \"off\", \"copy\", or \"verify\", got %q", c.TRAudioArchive)
+	}
+	if c.AudioReconcile {
+		if c.AudioReconcileInterval <= 0 {
+			return fmt.Errorf("AUDIO_RECONCILE_INTERVAL must be positive, got %v", c.AudioReconcileInterval)
+		}
+		if c.AudioReconcileWindow <= c.AudioReconcileDelay {
+			return fmt.Errorf("AUDIO_RECONCILE_WINDOW (%v) must be longer than AUDIO_RECONCILE_DELAY (%v)",
+				c.AudioReconcileWindow, c.AudioReconcileDelay)
+		}
+		if c.AudioReconcileTolerance < 0 || c.AudioReconcileTolerance > time.Minute {
+			return fmt.Errorf("AUDIO_RECONCILE_TOLERANCE must be between 0 and 1m, got %v", c.AudioReconcileTolerance)
+		}
+		if c.AudioReconcileAttempts < 1 {
+			return fmt.Errorf("AUDIO_RECONCILE_ATTEMPTS must be at least 1, got %d", c.AudioReconcileAttempts)
+		}
+	}
+	if c.ReplicateURL != "" {
+		if !strings.HasPrefix(c.ReplicateURL, "http://") && !strings.HasPrefix(c.ReplicateURL, "https://") {
+			return fmt.Errorf("REPLICATE_URL must be an http:// or https:// URL, got %q", c.ReplicateURL)
+		}
+		if c.ReplicateMaxKbps < 0 {
+			return fmt.Errorf("REPLICATE_MAX_KBPS must be >= 0, got %d", c.ReplicateMaxKbps)
+		}
+		if c.ReplicateInterval <= 0 {
+			return fmt.Errorf("REPLICATE_INTERVAL must be positive, got %v", c.ReplicateInterval)
+		}
+		if c.ReplicateBackfill <= c.ReplicateDelay || c.ReplicateDelay < 0 {
+			return fmt.Errorf("REPLICATE_BACKFILL (%v) must be longer than REPLICATE_DELAY (%v)",
+				c.ReplicateBackfill, c.ReplicateDelay)
+		}
+	}
+	if c.ForwardURL != "" {
+		if !strings.HasPrefix(c.ForwardURL, "http://") && !strings.HasPrefix(c.ForwardURL, "https://") {
+			return fmt.Errorf("FORWARD_URL must be an http:// or https:// URL, got %q", c.ForwardURL)
+		}
+		if c.ForwardAPIKey == "" {
+			return fmt.Errorf("FORWARD_API_KEY is required with FORWARD_URL")
+		}
+		if c.ForwardInterval <= 0 {
+			return fmt.Errorf("FORWARD_INTERVAL must be positive, got %v", c.ForwardInterval)
+		}
+		if c.ForwardBackfill <= c.ForwardDelay || c.ForwardDelay < 0 {
+			return fmt.Errorf("FORWARD_BACKFILL (%v) must be longer than FORWARD_DELAY (%v)",
+				c.ForwardBackfill, c.ForwardDelay)
+		}
+	}
+	if c.DuplicateAudit {
+		if c.DuplicateAuditHour < 0 || c.DuplicateAuditHour > 23 {
+			return fmt.Errorf("DUPLICATE_AUDIT_HOUR must be between 0 and 23, got %d", c.DuplicateAuditHour)
+		}
+		if c.DuplicateAuditLookback <= 0 {
+			return fmt.Errorf("DUPLICATE_AUDIT_LOOKBACK must be positive, got %v", c.DuplicateAuditLookback)
+		}
+		if c.DuplicateAuditMaxStartGap <= 0 {
+			return fmt.Errorf("DUPLICATE_AUDIT_MAX_START_GAP must be positive, got %v", c.DuplicateAuditMaxStartGap)
+		}
+		if c.DuplicateAuditMinConfidence < 0 || c.DuplicateAuditMinConfidence > 1 {
+			return fmt.Errorf("DUPLICATE_AUDIT_MIN_CONFIDENCE must be between 0 and 1, got %v", c.DuplicateAuditMinConfidence)
+		}
+		if c.DuplicateAuditAutoGroup != 0 &&
+			(c.DuplicateAuditAutoGroup < c.DuplicateAuditMinConfidence || c.DuplicateAuditAutoGroup > 1) {
+			return fmt.Errorf("DUPLICATE_AUDIT_AUTO_GROUP must be 0 or between DUPLICATE_AUDIT_MIN_CONFIDENCE and 1, got %v",
+				c.DuplicateAuditAutoGroup)
+		}
+	}
+	if c.CallRestampAfterImport < 0 {
+		return fmt.Errorf("CALL_RESTAMP_AFTER_IMPORT must not be negative, got %v", c.CallRestampAfterImport)
+	}
+	if c.WebhookTimeout <= 0 {
+		return fmt.Errorf("WEBHOOK_TIMEOUT must be positive, got %v", c.WebhookTimeout)
+	}
+	if c.WebhookMaxAttempts < 1 {
+		return fmt.Errorf("WEBHOOK_MAX_ATTEMPTS must be at least 1, got %d", c.WebhookMaxAttempts)
+	}
+	if c.WebRTCEnabled {
+		if c.StreamListen == "" {
+			return fmt.Errorf("WEBRTC_ENABLED requires STREAM_LISTEN")
+		}
+		if _, _, err := PortRange(c.WebRTCUDPPorts); err != nil {
+			return fmt.Errorf("WEBRTC_UDP_PORTS: %w", err)
+		}
+		if c.WebRTCPublicIP != "" && net.ParseIP(c.WebRTCPublicIP) == nil {
+			return fmt.Errorf("WEBRTC_PUBLIC_IP must be an IP address, got %q", c.WebRTCPublicIP)
+		}
+		if c.WebRTCMaxPeers < 0 {
+			return fmt.Errorf("WEBRTC_MAX_PEERS must not be negative, got %d", c.WebRTCMaxPeers)
+		}
+		if c.WebRTCLanes < 1 || c.WebRTCLanes > 16 {
+			return fmt.Errorf("WEBRTC_LANES must be between 1 and 16, got %d", c.WebRTCLanes)
+		}
+	}
+	if c.IcecastURL != "" {
+		if !strings.HasPrefix(c.IcecastURL, "http://") && !strings.HasPrefix(c.IcecastURL, "https://") {
+			return fmt.Errorf("ICECAST_URL must be an http:// or https:// URL, got %q", c.IcecastURL)
+		}
+		if c.IcecastMounts == "" || c.IcecastPassword == "" {
+			return fmt.Errorf("ICECAST_MOUNTS and ICECAST_PASSWORD are required with ICECAST_URL")
+		}
+		// 16 kHz MP3 (MPEG-2 layer III) goes up to 160 kbit/s; multiples of
+		// 8 kbit/s keep every frame the same size.
+		if c.IcecastBitrate < 8000 || c.IcecastBitrate > 160000 || c.IcecastBitrate%8000 != 0 {
+			return fmt.Errorf("ICECAST_BITRATE must be a multiple of 8000 between 8000 and 160000, got %d", c.IcecastBitrate)
+		}
+		if c.IcecastSilence < 0 || c.IcecastSilence > time.Minute {
+			return fmt.Errorf("ICECAST_SILENCE must be between 0 and 1m, got %v", c.IcecastSilence)
+		}
+	}
+	switch c.WarehouseExport {
+	case "off", "local":
+	case "s3":
+		if c.WarehouseS3Bucket == "" && c.S3.Bucket == "" {
+			return fmt.Errorf("WAREHOUSE_EXPORT=s3 requires WAREHOUSE_S3_BUCKET or S3_BUCKET")
+		}
+	default:
+		return fmt.Errorf("WAREHOUSE_EXPORT must be \"off\", \"local\", or \"s3\", got %q", c.WarehouseExport)
+	}
+	if c.WarehouseExportDelay < 0 {
+		return fmt.Errorf("WAREHOUSE_EXPORT_DELAY must not be negative, got %v", c.WarehouseExportDelay)
+	}
+	if c.DBExplainSample < 0 || c.DBExplainSample > 1 {
+		return fmt.Errorf("DB_EXPLAIN_SAMPLE must be between 0 and 1, got %v", c.DBExplainSample)
+	}
+	if c.WhisperPromptContext < 0 {
+		return fmt.Errorf("WHISPER_PROMPT_CONTEXT must be >= 0, got %d", c.WhisperPromptContext)
+	}
+	if c.WhisperPromptContextWindow < 0 {
+		return fmt.Errorf("WHISPER_PROMPT_CONTEXT_WINDOW must not be negative, got %v", c.WhisperPromptContextWindow)
+	}
+	if c.RetranscribeMaxConcurrency < 1 {
+		return fmt.Errorf("RETRANSCRIBE_MAX_CONCURRENCY must be >= 1, got %d", c.RetranscribeMaxConcurrency)
+	}
+	if c.MaintenanceObserveCycles < 0 {
+		return fmt.Errorf("MAINTENANCE_OBSERVE_CYCLES must be >= 0, got %d", c.MaintenanceObserveCycles)
+	}
+	if c.AudioDiskMaxPercent < 0 || c.AudioDiskMaxPercent > 99 {
+		return fmt.Errorf("AUDIO_DISK_MAX_PERCENT must be between 0 and 99, got %d", c.AudioDiskMaxPercent)
+	}
+	if c.MetricsTalkgroupLimit < 0 {
+		return fmt.Errorf("METRICS_TALKGROUP_LIMIT must be >= 0, got %d", c.MetricsTalkgroupLimit)
+	}
+	if c.SplitCallMaxGap < 0 || c.SplitCallMaxGap > time.Minute {
+		return fmt.Errorf("SPLIT_CALL_MAX_GAP must be between 0 and 1m, got %v", c.SplitCallMaxGap)
+	}
+	if c.AudioDurationCorrect < 0 {
+		return fmt.Errorf("AUDIO_DURATION_CORRECT must not be negative, got %s", c.AudioDurationCorrect)
+	}
+	if c.AudioDurationCorrect > 0 && !c.AudioDurationCheck {
+		return fmt.Errorf("AUDIO_DURATION_CORRECT requires AUDIO_DURATION_CHECK")
+	}
+	if c.AudioSilenceLevel < -120 || c.AudioSilenceLevel > 0 {
+		return fmt.Errorf("AUDIO_SILENCE_LEVEL must be between -120 and 0 dBFS, got %v", c.AudioSilenceLevel)
+	}
+	if c.AudioUnusableDelete && !c.AudioUnusableCheck {
+		return fmt.Errorf("AUDIO_UNUSABLE_DELETE requires AUDIO_UNUSABLE_CHECK")
+	}
+	if c.StuckMicMaxSpeechRatio < 0 || c.StuckMicMaxSpeechRatio > 1 {
+		return fmt.Errorf("STUCK_MIC_MAX_SPEECH_RATIO must be between 0 and 1, got %v", c.StuckMicMaxSpeechRatio)
+	}
+	switch c.TranscribeLongCalls {
+	case "skip":
+	case "segment":
+		if c.TranscribeSegmentLength < 10 {
+			return fmt.Errorf("TRANSCRIBE_SEGMENT_LENGTH must be at least 10 seconds, got %v", c.TranscribeSegmentLength)
+		}
+		if c.TranscribeSegmentOverlap < 0 || c.TranscribeSegmentOverlap*2 >= c.TranscribeSegmentLength {
+			return fmt.Errorf("TRANSCRIBE_SEGMENT_OVERLAP must be between 0 and half of TRANSCRIBE_SEGMENT_LENGTH, got %v", c.TranscribeSegmentOverlap)
+		}
+		if c.TranscribeSegmentMaxDuration <= c.TranscribeMaxDuration {
+			return fmt.Errorf("TRANSCRIBE_SEGMENT_MAX_DURATION (%v) must be longer than TRANSCRIBE_MAX_DURATION (%v)",
+				c.TranscribeSegmentMaxDuration, c.TranscribeMaxDuration)
+		}
+	default:
+		return fmt.Errorf("TRANSCRIBE_LONG_CALLS must be \"skip\" or \"segment\", got %q", c.TranscribeLongCalls)
+	}
+	switch c.UrgencyClassifier {
+	case "off", "keyword":
+	case "model":
+		if c.UrgencyModelURL == "" {
+			return fmt.Errorf("URGENCY_CLASSIFIER=model requires URGENCY_MODEL_URL")
+		}
+	default:
+		return fmt.Errorf("URGENCY_CLASSIFIER must be \"off\", \"keyword\", or \"model\", got %q", c.UrgencyClassifier)
+	}
+	switch c.BridgeDriver {
+	case "":
+	case "nats", "kafka-rest":
+		if c.BridgeURLs == "" {
+			return fmt.Errorf("BRIDGE_DRIVER=%s requires BRIDGE_URLS", c.BridgeDriver)
+		}
+		if c.BridgePartitionKey != "system" && c.BridgePartitionKey != "system_tgid" {
+			return fmt.Errorf("BRIDGE_PARTITION_KEY must be \"system\" or \"system_tgid\", got %q", c.BridgePartitionKey)
+		}
+	default:
+		return fmt.Errorf("BRIDGE_DRIVER must be \"nats\" or \"kafka-rest\", got %q", c.BridgeDriver)
+	}
+	if c.LLMUrl != "" && c.LLMModel == "" {
+		return fmt.Errorf("LLM_URL requires LLM_MODEL")
+	}
+	if c.EmbedURL != "" && (c.EmbedModel == "" || c.EmbedDimensions <= 0) {
+		return fmt.Errorf("EMBED_URL requires EMBED_MODEL and EMBED_DIMENSIONS")
+	}
+	if c.EmbedDimensions > 2000 {
+		return fmt.Errorf("EMBED_DIMENSIONS must be at most 2000 (pgvector HNSW limit), got %d", c.EmbedDimensions)
+	}
+	if c.RateLimitGlobalRPS < 0 || c.RateLimitTokenRPS < 0 || c.RateLimitExpensivePerMin < 0 {
+		return fmt.Errorf("RATE_LIMIT_GLOBAL_RPS, RATE_LIMIT_TOKEN_RPS and RATE_LIMIT_EXPENSIVE_PER_MIN must be >= 0")
+	}
+	if c.AskRateLimit < 0 {
+		return fmt.Errorf("ASK_RATE_LIMIT must be >= 0, got %v", c.AskRateLimit)
+	}
+	if c.CADSMTPListen != "" && c.CADPageFormats == "" {
+		return fmt.Errorf("CAD_SMTP_LISTEN requires CAD_PAGE_FORMATS")
+	}
+	if c.CADMaxMessageSize <= 0 {
+		return fmt.Errorf("CAD_MAX_MESSAGE_SIZE must be positive, got %d", c.CADMaxMessageSize)
+	}
 	return nil
 }
 
@@ -109,6 +109,52 @@ func TestLoadMissingRequired(t *testing.T) {
 	}
 }
 
+func TestStorageBackend(t *testing.T) {
+	cleanup := setEnvs(t, map[string]string{
+		"DATABASE_URL":    "postgres://localhost/test",
+		"MQTT_BROKER_URL": "tcp://localhost:1883",
+	})
+	defer cleanup()
+
+	cfg, err := Load(Overrides{EnvFile: "nonexistent.env"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := cfg.StorageBackendName(); got != "local" {
+		t.Errorf("no bucket: backend = %q, want local", got)
+	}
+	cfg.S3.Bucket = "audio"
+	if got := cfg.StorageBackendName(); got != "s3" {
+		t.Errorf("S3_BUCKET set: backend = %q, want s3", got)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("s3: %v", err)
+	}
+
+	cfg.StorageBackend = "azure"
+	if err := cfg.Validate(); err == nil {
+		t.Error("azure without an account: expected an error")
+	}
+	cfg.Azure = AzureConfig{Account: "acct", Key: "a2V5", Container: "audio"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("azure: %v", err)
+	}
+
+	cfg.StorageBackend = "gcs"
+	if err := cfg.Validate(); err == nil {
+		t.Error("gcs without GCS_BUCKET: expected an error")
+	}
+	cfg.GCS.Bucket = "audio"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("gcs: %v", err)
+	}
+
+	cfg.StorageBackend = "minio"
+	if err := cfg.Validate(); err == nil {
+		t.Error("unknown backend: expected an error")
+	}
+}
+
 func TestStreamConfig(t *testing.T) {
 	cleanup := setEnvs(t, map[string]string{
 		"DATABASE_URL":       "postgres://localhost/test",
@@ -166,6 +212,53 @@ func TestStreamConfigDefaults(t *testing.T) {
 	}
 }
 
+func TestWebRTCConfig(t *testing.T) {
+	cleanup := setEnvs(t, map[string]string{
+		"DATABASE_URL":     "postgres://localhost/test",
+		"MQTT_BROKER_URL":  "tcp://localhost:1883",
+		"STREAM_LISTEN":    ":9123",
+		"WEBRTC_ENABLED":   "true",
+		"WEBRTC_UDP_PORTS": "40000-40010",
+	})
+	defer cleanup()
+
+	cfg, err := Load(Overrides{EnvFile: "nonexistent.env"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.WebRTCLanes != 4 {
+		t.Errorf("WebRTCLanes = %d, want 4", cfg.WebRTCLanes)
+	}
+	if lo, hi, err := PortRange(cfg.WebRTCUDPPorts); err != nil || lo != 40000 || hi != 40010 {
+		t.Errorf("PortRange = %d, %d, %v; want 40000, 40010", lo, hi, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("valid: %v", err)
+	}
+
+	for _, ports := range []string{"50000", "0-10", "20-10", "1-70000"} {
+		cfg.WebRTCUDPPorts = ports
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("WEBRTC_UDP_PORTS=%q: expected an error", ports)
+		}
+	}
+	cfg.WebRTCUDPPorts = "50000-50100"
+	cfg.WebRTCPublicIP = "example.com"
+	if err := cfg.Validate(); err == nil {
+		t.Error("hostname WEBRTC_PUBLIC_IP: expected an error")
+	}
+	cfg.WebRTCPublicIP = ""
+	cfg.WebRTCLanes = 0
+	if err := cfg.Validate(); err == nil {
+		t.Error("WEBRTC_LANES=0: expected an error")
+	}
+	cfg.WebRTCLanes = 4
+	cfg.StreamListen = ""
+	if err := cfg.Validate(); err == nil {
+		t.Error("no STREAM_LISTEN: expected an error")
+	}
+}
+
 // setEnvs sets environment variables and returns a cleanup function.
 func setEnvs(t *testing.T, envs map[string]string) func() {
 	t.Helper()
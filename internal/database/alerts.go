@@ -0,0 +1,272 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// AlertRule is one alert_rules row: transcripts on SystemID (every system
+// when nil) and Tgids (every talkgroup when empty) that contain any of
+// Keywords or match Pattern raise an alert. UnitIDs (any of them on the
+// call) and EmergencyOnly narrow it further.
+type AlertRule struct {
+	ID            int       `json:"id"`
+	Name          string    `json:"name"`
+	Keywords      []string  `json:"keywords"`
+	Pattern       string    `json:"pattern"` // RE2 regular expression; "" = none
+	SystemID      *int      `json:"system_id"`
+	Tgids         []int     `json:"tgids"`
+	UnitIDs       []int     `json:"unit_ids"`
+	EmergencyOnly bool      `json:"emergency_only"`
+	Enabled       bool      `json:"enabled"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Matches reports whether the rule applies to a call's system and talkgroup.
+func (a *AlertRule) Matches(systemID, tgid int) bool {
+	if a.SystemID != nil && *a.SystemID != systemID {
+		return false
+	}
+	if len(a.Tgids) == 0 {
+		return true
+	}
+	for _, t := range a.Tgids {
+		if t == tgid {
+			return true
+		}
+	}
+	return false
+}
+
+const alertRuleColumns = `id, name, keywords, pattern, system_id, tgids, unit_ids, emergency_only, enabled,
+	created_at, updated_at`
+
+func scanAlertRule(row pgx.Row, a *AlertRule) error {
+	return row.Scan(&a.ID, &a.Name, &a.Keywords, &a.Pattern, &a.SystemID, &a.Tgids, &a.UnitIDs,
+		&a.EmergencyOnly, &a.Enabled, &a.CreatedAt, &a.UpdatedAt)
+}
+
+// ListAlertRules returns all alert rules in ID order.
+func (db *DB) ListAlertRules(ctx context.Context) ([]AlertRule, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT `+alertRuleColumns+` FROM alert_rules ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	rules := []AlertRule{}
+	for rows.Next() {
+		var a AlertRule
+		if err := scanAlertRule(rows, &a); err != nil {
+			return nil, err
+		}
+		rules = append(rules, a)
+	}
+	return rules, rows.Err()
+}
+
+// GetAlertRule returns one rule, or "alert rule not found".
+func (db *DB) GetAlertRule(ctx context.Context, id int) (*AlertRule, error) {
+	var a AlertRule
+	err := scanAlertRule(db.Pool.QueryRow(ctx, `SELECT `+alertRuleColumns+` FROM alert_rules WHERE id = $1`, id), &a)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("alert rule not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// CreateAlertRule stores a new rule and returns its ID. Returns "alert rule
+// name already exists" on a duplicate name.
+func (db *DB) CreateAlertRule(ctx context.Context, a *AlertRule) (int, error) {
+	var id int
+	err := db.Pool.QueryRow(ctx, `
+		INSERT INTO alert_rules (name, keywords, pattern, system_id, tgids, unit_ids, emergency_only, enabled)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`, a.Name, a.Keywords, a.Pattern, a.SystemID, a.Tgids, a.UnitIDs, a.EmergencyOnly, a.Enabled).Scan(&id)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return 0, fmt.Errorf("alert rule name already exists")
+	}
+	return id, err
+}
+
+// UpdateAlertRule replaces a rule's settings. Returns "alert rule not found"
+// or "alert rule name already exists".
+func (db *DB) UpdateAlertRule(ctx context.Context, a *AlertRule) error {
+	tag, err := db.Pool.Exec(ctx, `
+		UPDATE alert_rules
+		SET name = $2, keywords = $3, pattern = $4, system_id = $5, tgids = $6, unit_ids = $7,
+			emergency_only = $8, enabled = $9, updated_at = now()
+		WHERE id = $1
+	`, a.ID, a.Name, a.Keywords, a.Pattern, a.SystemID, a.Tgids, a.UnitIDs, a.EmergencyOnly, a.Enabled)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return fmt.Errorf("alert rule name already exists")
+	}
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("alert rule not found")
+	}
+	return nil
+}
+
+// DeleteAlertRule removes a rule. Its alerts stay in the history under the
+// rule's name.
+func (db *DB) DeleteAlertRule(ctx context.Context, id int) error {
+	tag, err := db.Pool.Exec(ctx, `DELETE FROM alert_rules WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("alert rule not found")
+	}
+	return nil
+}
+
+// AlertCall is what alert rules need to know about a call beyond its
+// transcript.
+type AlertCall struct {
+	TgAlphaTag string
+	Unit       int   // initiating unit; 0 if unknown
+	UnitIDs    []int // every unit heard on the call
+	Emergency  bool
+}
+
+// GetAlertCall returns a call's talkgroup tag, units and emergency flag, or
+// "call not found".
+func (db *DB) GetAlertCall(ctx context.Context, callID int64, startTime time.Time) (*AlertCall, error) {
+	var c AlertCall
+	err := db.Pool.QueryRow(ctx, `
+		SELECT COALESCE(tg_alpha_tag, ''), COALESCE(`+initiatingUnitSQL+`, 0), COALESCE(unit_ids, '{}'),
+			COALESCE(emergency, false)
+		FROM calls
+		WHERE call_id = $1 AND start_time = $2
+	`, callID, startTime).Scan(&c.TgAlphaTag, &c.Unit, &c.UnitIDs, &c.Emergency)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("call not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Alert is one alerts row: a transcript that matched a rule.
+type Alert struct {
+	ID            int64     `json:"id"`
+	RuleID        *int      `json:"rule_id"` // nil once the rule is deleted
+	RuleName      string    `json:"rule_name"`
+	CallID        int64     `json:"call_id"`
+	CallStartTime time.Time `json:"call_start_time"`
+	SystemID      int       `json:"system_id"`
+	Tgid          int       `json:"tgid"`
+	TgAlphaTag    string    `json:"tg_alpha_tag"`
+	UnitID        int       `json:"unit_id"`
+	Emergency     bool      `json:"emergency"`
+	Matches       []string  `json:"matches"`
+	Text          string    `json:"text"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// InsertAlert records an alert, setting its ID and CreatedAt.
+func (db *DB) InsertAlert(ctx context.Context, a *Alert) error {
+	return db.Pool.QueryRow(ctx, `
+		INSERT INTO alerts (rule_id, rule_name, call_id, call_start_time, system_id, tgid, unit_id,
+			emergency, matches, text)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at
+	`, a.RuleID, a.RuleName, a.CallID, a.CallStartTime, a.SystemID, a.Tgid, a.UnitID,
+		a.Emergency, a.Matches, a.Text).Scan(&a.ID, &a.CreatedAt)
+}
+
+// AlertFilter selects alerts by when they were raised. Alerts on calls
+// hidden by a restricted encryption policy or an embargo are left out
+// unless IncludeRestricted.
+type AlertFilter struct {
+	RuleIDs           []int
+	SystemIDs         []int
+	Tgids             []int
+	UnitIDs           []int
+	Emergency         *bool
+	Start             *time.Time
+	End               *time.Time
+	IncludeRestricted bool
+	Limit             int
+	Offset            int
+}
+
+const alertColumns = `a.id, a.rule_id, a.rule_name, a.call_id, a.call_start_time, a.system_id, a.tgid,
+	COALESCE(c.tg_alpha_tag, ''), a.unit_id, a.emergency, a.matches, a.text, a.created_at`
+
+const alertFrom = `
+		FROM alerts a
+		JOIN calls c ON c.call_id = a.call_id AND c.start_time = a.call_start_time`
+
+func scanAlert(row pgx.Row, a *Alert) error {
+	return row.Scan(&a.ID, &a.RuleID, &a.RuleName, &a.CallID, &a.CallStartTime, &a.SystemID, &a.Tgid,
+		&a.TgAlphaTag, &a.UnitID, &a.Emergency, &a.Matches, &a.Text, &a.CreatedAt)
+}
+
+// ListAlerts returns alerts matching the filter, newest first, with the
+// total count.
+func (db *DB) ListAlerts(ctx context.Context, f AlertFilter) ([]Alert, int, error) {
+	where := `
+		WHERE ($1::int[] IS NULL OR a.rule_id = ANY($1))
+		  AND ($2::int[] IS NULL OR a.system_id = ANY($2))
+		  AND ($3::int[] IS NULL OR a.tgid = ANY($3))
+		  AND ($4::int[] IS NULL OR a.unit_id = ANY($4))
+		  AND ($5::boolean IS NULL OR a.emergency = $5)
+		  AND ($6::timestamptz IS NULL OR a.created_at >= $6)
+		  AND ($7::timestamptz IS NULL OR a.created_at < $7)
+		  AND ($8 OR NOT ` + restrictedCallSQL + `)`
+	args := []any{pqIntArray(f.RuleIDs), pqIntArray(f.SystemIDs), pqIntArray(f.Tgids), pqIntArray(f.UnitIDs),
+		f.Emergency, f.Start, f.End, f.IncludeRestricted}
+
+	var total int
+	if err := db.Pool.QueryRow(ctx, `SELECT count(*)`+alertFrom+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := db.Pool.Query(ctx, `SELECT `+alertColumns+alertFrom+where+`
+		ORDER BY a.created_at DESC, a.id DESC
+		LIMIT $9 OFFSET $10`, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	alerts := []Alert{}
+	for rows.Next() {
+		var a Alert
+		if err := scanAlert(rows, &a); err != nil {
+			return nil, 0, err
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, total, rows.Err()
+}
+
+// GetAlert returns one alert, or "alert not found" (also when its call is
+// restricted and includeRestricted is false).
+func (db *DB) GetAlert(ctx context.Context, id int64, includeRestricted bool) (*Alert, error) {
+	var a Alert
+	err := scanAlert(db.Pool.QueryRow(ctx, `SELECT `+alertColumns+alertFrom+`
+		WHERE a.id = $1 AND ($2 OR NOT `+restrictedCallSQL+`)`, id, includeRestricted), &a)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("alert not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
@@ -0,0 +1,182 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// API key scopes (api_keys.scope), from least to most access.
+const (
+	APIKeyScopeStream = "stream" // live event and audio streams only
+	APIKeyScopeRead   = "read"   // everything AUTH_TOKEN can do
+	APIKeyScopeWrite  = "write"  // reads and writes outside /admin
+	APIKeyScopeAdmin  = "admin"  // everything WRITE_TOKEN can do
+)
+
+// ValidAPIKeyScope reports whether s is a known API key scope.
+func ValidAPIKeyScope(s string) bool {
+	switch s {
+	case APIKeyScopeStream, APIKeyScopeRead, APIKeyScopeWrite, APIKeyScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// APIKey is one api_keys row: a named bearer token with a scope and an
+// optional rate limit of its own. Only a hash of the key is stored; Prefix
+// is its first characters, to tell keys apart in listings.
+type APIKey struct {
+	ID             int        `json:"id"`
+	Name           string     `json:"name"`
+	Prefix         string     `json:"prefix"`
+	Scope          string     `json:"scope"`
+	RateLimitRPS   *float64   `json:"rate_limit_rps"`   // nil = RATE_LIMIT_TOKEN_RPS
+	RateLimitBurst *int       `json:"rate_limit_burst"` // nil = RATE_LIMIT_TOKEN_BURST, or the rps rounded up
+	Active         bool       `json:"active"`           // not revoked
+	CreatedBy      string     `json:"created_by"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at"`
+	LastUsedIP     *string    `json:"last_used_ip"`
+	RevokedBy      *string    `json:"revoked_by"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+
+	Hash string `json:"-"` // hex SHA-256 of the key
+}
+
+const apiKeyColumns = `id, name, prefix, scope, rate_limit_rps, rate_limit_burst, revoked_at IS NULL,
+	created_by, created_at, last_used_at, last_used_ip, revoked_by, revoked_at, key_hash`
+
+func scanAPIKey(row pgx.Row) (*APIKey, error) {
+	var k APIKey
+	if err := row.Scan(&k.ID, &k.Name, &k.Prefix, &k.Scope, &k.RateLimitRPS, &k.RateLimitBurst, &k.Active,
+		&k.CreatedBy, &k.CreatedAt, &k.LastUsedAt, &k.LastUsedIP, &k.RevokedBy, &k.RevokedAt, &k.Hash); err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// apiKeyNameTaken reports whether err is a clash on the unique name of
+// active keys.
+func apiKeyNameTaken(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "uq_api_keys_active_name"
+}
+
+// ListAPIKeys returns API keys, newest first; only unrevoked ones when
+// activeOnly is set.
+func (db *DB) ListAPIKeys(ctx context.Context, activeOnly bool) ([]APIKey, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys
+		WHERE NOT $1 OR revoked_at IS NULL
+		ORDER BY id DESC
+	`, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+// GetAPIKey returns an API key. Returns "api key not found" if id is unknown.
+func (db *DB) GetAPIKey(ctx context.Context, id int) (*APIKey, error) {
+	k, err := scanAPIKey(db.Pool.QueryRow(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1`, id))
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("api key not found")
+	}
+	return k, err
+}
+
+// CreateAPIKey inserts an API key from its name, prefix, hash, scope, rate
+// limit and creator, and fills in the rest. Returns "api key name already
+// exists" when an active key has the name.
+func (db *DB) CreateAPIKey(ctx context.Context, k *APIKey) error {
+	created, err := scanAPIKey(db.Pool.QueryRow(ctx, `
+		INSERT INTO api_keys (name, prefix, key_hash, scope, rate_limit_rps, rate_limit_burst, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+apiKeyColumns,
+		k.Name, k.Prefix, k.Hash, k.Scope, k.RateLimitRPS, k.RateLimitBurst, k.CreatedBy))
+	if apiKeyNameTaken(err) {
+		return fmt.Errorf("api key name already exists")
+	}
+	if err != nil {
+		return err
+	}
+	*k = *created
+	return nil
+}
+
+// APIKeyUpdate holds the fields of an active key that can be changed; nil
+// fields are left alone. ClearRateLimit drops the key's own limit.
+type APIKeyUpdate struct {
+	Name           *string
+	Scope          *string
+	RateLimitRPS   *float64
+	RateLimitBurst *int
+	ClearRateLimit bool
+}
+
+// UpdateAPIKey changes an active key. Returns "api key not found", "api key
+// is revoked" or "api key name already exists".
+func (db *DB) UpdateAPIKey(ctx context.Context, id int, u APIKeyUpdate) (*APIKey, error) {
+	k, err := scanAPIKey(db.Pool.QueryRow(ctx, `
+		UPDATE api_keys SET
+			name             = COALESCE($2, name),
+			scope            = COALESCE($3, scope),
+			rate_limit_rps   = CASE WHEN $6 THEN NULL ELSE COALESCE($4, rate_limit_rps) END,
+			rate_limit_burst = CASE WHEN $6 THEN NULL ELSE COALESCE($5, rate_limit_burst) END
+		WHERE id = $1 AND revoked_at IS NULL
+		RETURNING `+apiKeyColumns,
+		id, u.Name, u.Scope, u.RateLimitRPS, u.RateLimitBurst, u.ClearRateLimit))
+	if apiKeyNameTaken(err) {
+		return nil, fmt.Errorf("api key name already exists")
+	}
+	if err == pgx.ErrNoRows {
+		if _, err := db.GetAPIKey(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("api key is revoked")
+	}
+	return k, err
+}
+
+// RevokeAPIKey disables a key; the row is kept as a record of who revoked
+// it. Returns "api key not found" or "api key is revoked".
+func (db *DB) RevokeAPIKey(ctx context.Context, id int, actor string) (*APIKey, error) {
+	k, err := scanAPIKey(db.Pool.QueryRow(ctx, `
+		UPDATE api_keys SET revoked_by = $2, revoked_at = now()
+		WHERE id = $1 AND revoked_at IS NULL
+		RETURNING `+apiKeyColumns,
+		id, actor))
+	if err == pgx.ErrNoRows {
+		if _, err := db.GetAPIKey(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("api key is revoked")
+	}
+	return k, err
+}
+
+// TouchAPIKey records that a key was used at the given time from ip.
+func (db *DB) TouchAPIKey(ctx context.Context, id int, at time.Time, ip string) error {
+	_, err := db.Pool.Exec(ctx, `
+		UPDATE api_keys SET last_used_at = $2, last_used_ip = NULLIF($3, '')
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $2)
+	`, id, at, ip)
+	return err
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// AskAuditEntry records one question put to the archive Q&A endpoint.
+type AskAuditEntry struct {
+	ID         int64           `json:"id"`
+	Time       time.Time       `json:"time"`
+	ClientIP   string          `json:"client_ip"`
+	Question   string          `json:"question"`
+	Filters    json.RawMessage `json:"filters,omitempty"`
+	Model      string          `json:"model"`
+	Retrieved  int             `json:"retrieved"`   // transcripts given to the model as context
+	CitedCalls []int64         `json:"cited_calls"` // call IDs the answer cites
+	Answer     *string         `json:"answer,omitempty"`
+	Error      *string         `json:"error,omitempty"`
+	LatencyMs  int             `json:"latency_ms"`
+}
+
+// AskAuditFilter specifies filters for listing audit entries.
+type AskAuditFilter struct {
+	StartTime *time.Time
+	EndTime   *time.Time
+	ClientIP  string
+	Limit     int
+	Offset    int
+}
+
+// InsertAskAudit stores an audit entry and returns its ID.
+func (db *DB) InsertAskAudit(ctx context.Context, e AskAuditEntry) (int64, error) {
+	if e.CitedCalls == nil {
+		e.CitedCalls = []int64{}
+	}
+	var filters any
+	if len(e.Filters) > 0 {
+		filters = e.Filters
+	}
+	var id int64
+	err := db.Pool.QueryRow(ctx, `
+		INSERT INTO ask_audit_log (client_ip, question, filters, model, retrieved, cited_calls, answer, error, latency_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`, e.ClientIP, e.Question, filters, e.Model, e.Retrieved, e.CitedCalls, e.Answer, e.Error, e.LatencyMs).Scan(&id)
+	return id, err
+}
+
+// ListAskAudit returns audit entries, newest first.
+func (db *DB) ListAskAudit(ctx context.Context, filter AskAuditFilter) ([]AskAuditEntry, int, error) {
+	const where = `
+		WHERE ($1::timestamptz IS NULL OR time >= $1)
+		  AND ($2::timestamptz IS NULL OR time < $2)
+		  AND ($3::text IS NULL OR client_ip = $3)`
+	args := []any{filter.StartTime, filter.EndTime, pqString(filter.ClientIP)}
+
+	var total int
+	if err := db.Pool.QueryRow(ctx, `SELECT count(*) FROM ask_audit_log`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT id, time, client_ip, question, filters, model, retrieved, cited_calls, answer, error, latency_ms
+		FROM ask_audit_log`+where+`
+		ORDER BY time DESC, id DESC
+		LIMIT $4 OFFSET $5`, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []AskAuditEntry{}
+	for rows.Next() {
+		var e AskAuditEntry
+		if err := rows.Scan(&e.ID, &e.Time, &e.ClientIP, &e.Question, &e.Filters, &e.Model,
+			&e.Retrieved, &e.CitedCalls, &e.Answer, &e.Error, &e.LatencyMs); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// AudioArchiveCandidate is a call whose audio is still only in
+// trunk-recorder's capture directory (TR_AUDIO_DIR).
+type AudioArchiveCandidate struct {
+	CallID        int64
+	StartTime     time.Time
+	SiteShortName string
+	CallFilename  string
+	Attempts      int
+}
+
+// ListAudioArchiveCandidates returns calls started in [since, before) that
+// have a call_filename but no tr-engine audio_file_path, oldest first (they
+// are closest to TR's purge). Calls that failed maxAttempts times are skipped,
+// as are those that failed within the last retryAfter.
+func (db *DB) ListAudioArchiveCandidates(ctx context.Context, since, before time.Time, maxAttempts int, retryAfter time.Duration, limit int) ([]AudioArchiveCandidate, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT c.call_id, c.start_time, COALESCE(NULLIF(c.site_short_name, ''), c.system_name, ''),
+			c.call_filename, COALESCE(f.attempts, 0)
+		FROM calls c
+		LEFT JOIN audio_archive_failures f
+			ON f.call_id = c.call_id AND f.call_start_time = c.start_time
+		WHERE c.start_time >= $1 AND c.start_time < $2
+		  AND COALESCE(c.audio_file_path, '') = ''
+		  AND COALESCE(c.call_filename, '') <> ''
+		  AND NOT COALESCE(c.encrypted, false)
+		  AND (f.call_id IS NULL OR (f.attempts < $3 AND f.last_attempt < now() - $4::interval))
+		ORDER BY c.start_time
+		LIMIT $5
+	`, since, before, maxAttempts, retryAfter, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []AudioArchiveCandidate
+	for rows.Next() {
+		var c AudioArchiveCandidate
+		if err := rows.Scan(&c.CallID, &c.StartTime, &c.SiteShortName, &c.CallFilename, &c.Attempts); err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+	return result, rows.Err()
+}
+
+// RecordAudioArchiveFailure counts a failed copy of a call's audio.
+func (db *DB) RecordAudioArchiveFailure(ctx context.Context, callID int64, startTime time.Time, msg string) error {
+	_, err := db.Pool.Exec(ctx, `
+		INSERT INTO audio_archive_failures (call_id, call_start_time, attempts, last_error, last_attempt)
+		VALUES ($1, $2, 1, $3, now())
+		ON CONFLICT (call_id, call_start_time) DO UPDATE SET
+			attempts     = audio_archive_failures.attempts + 1,
+			last_error   = EXCLUDED.last_error,
+			last_attempt = now()
+	`, callID, startTime, msg)
+	return err
+}
+
+// ClearAudioArchiveFailure forgets a call's failures after a successful copy.
+func (db *DB) ClearAudioArchiveFailure(ctx context.Context, callID int64, startTime time.Time) error {
+	_, err := db.Pool.Exec(ctx, `
+		DELETE FROM audio_archive_failures WHERE call_id = $1 AND call_start_time = $2
+	`, callID, startTime)
+	return err
+}
+
+// ResetAudioArchiveFailures makes every failed call eligible for another
+// attempt. Returns the number of calls reset.
+func (db *DB) ResetAudioArchiveFailures(ctx context.Context) (int64, error) {
+	tag, err := db.Pool.Exec(ctx, `DELETE FROM audio_archive_failures`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// AudioArchiveFailure is a call whose audio could not be copied.
+type AudioArchiveFailure struct {
+	CallID       int64     `json:"call_id"`
+	StartTime    time.Time `json:"start_time"`
+	CallFilename string    `json:"call_filename"`
+	Attempts     int       `json:"attempts"`
+	LastError    string    `json:"last_error"`
+	LastAttempt  time.Time `json:"last_attempt"`
+}
+
+// ListAudioArchiveFailures returns failed copies, most recent attempt first.
+func (db *DB) ListAudioArchiveFailures(ctx context.Context, limit int) ([]AudioArchiveFailure, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT f.call_id, f.call_start_time, COALESCE(c.call_filename, ''),
+			f.attempts, COALESCE(f.last_error, ''), f.last_attempt
+		FROM audio_archive_failures f
+		LEFT JOIN calls c ON c.call_id = f.call_id AND c.start_time = f.call_start_time
+		ORDER BY f.last_attempt DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []AudioArchiveFailure{}
+	for rows.Next() {
+		var f AudioArchiveFailure
+		if err := rows.Scan(&f.CallID, &f.StartTime, &f.CallFilename,
+			&f.Attempts, &f.LastError, &f.LastAttempt); err != nil {
+			return nil, err
+		}
+		result = append(result, f)
+	}
+	return result, rows.Err()
+}
+
+// AudioArchiveStats counts calls in the archive window by state.
+type AudioArchiveStats struct {
+	Archived      int64      `json:"archived"`       // copied into tr-engine's store
+	Pending       int64      `json:"pending"`        // not yet copied (including failing)
+	Failing       int64      `json:"failing"`        // at least one failed attempt, still retried
+	GaveUp        int64      `json:"gave_up"`        // failed maxAttempts times
+	Missed24h     int64      `json:"missed_24h"`     // aged out of the window uncopied in the last day
+	OldestPending *time.Time `json:"oldest_pending"` // start_time of the oldest uncopied call
+}
+
+// GetAudioArchiveStats counts calls started in [since, now) by archive state.
+func (db *DB) GetAudioArchiveStats(ctx context.Context, since time.Time, maxAttempts int) (AudioArchiveStats, error) {
+	var s AudioArchiveStats
+	err := db.Pool.QueryRow(ctx, `
+		WITH w AS (
+			SELECT c.start_time,
+				COALESCE(c.audio_file_path, '') <> '' AS archived,
+				COALESCE(f.attempts, 0) AS attempts
+			FROM calls c
+			LEFT JOIN audio_archive_failures f
+				ON f.call_id = c.call_id AND f.call_start_time = c.start_time
+			WHERE c.start_time >= $1 - interval '24 hours'
+			  AND COALESCE(c.call_filename, '') <> ''
+			  AND NOT COALESCE(c.encrypted, false)
+		)
+		SELECT
+			count(*) FILTER (WHERE start_time >= $1 AND archived),
+			count(*) FILTER (WHERE start_time >= $1 AND NOT archived),
+			count(*) FILTER (WHERE start_time >= $1 AND NOT archived AND attempts > 0 AND attempts < $2),
+			count(*) FILTER (WHERE start_time >= $1 AND NOT archived AND attempts >= $2),
+			count(*) FILTER (WHERE start_time < $1 AND NOT archived),
+			min(start_time) FILTER (WHERE start_time >= $1 AND NOT archived)
+		FROM w
+	`, since, maxAttempts).Scan(&s.Archived, &s.Pending, &s.Failing, &s.GaveUp, &s.Missed24h, &s.OldestPending)
+	return s, err
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// SetCallAudioDuration stores the duration measured from a call's saved audio
+// and flags the call when it differs from TR's reported duration by more than
+// tolerance seconds (the duration before any correction, see
+// CorrectCallDurations). The flag stays NULL while the reported duration is unknown.
+// Returns whether the call was flagged.
+func (db *DB) SetCallAudioDuration(ctx context.Context, callID int64, startTime time.Time, measured, tolerance float64) (bool, error) {
+	var mismatch bool
+	err := db.Pool.QueryRow(ctx, `
+		UPDATE calls SET
+			audio_duration    = $3,
+			duration_mismatch = CASE WHEN COALESCE(original_duration, duration) > 0
+				THEN abs(COALESCE(original_duration, duration) - $3) > $4 END
+		WHERE call_id = $1 AND start_time = $2
+		RETURNING COALESCE(duration_mismatch, false)
+	`, callID, startTime, float32(measured), tolerance).Scan(&mismatch)
+	return mismatch, err
+}
+
+// DurationDiscrepancyFilter specifies filters for the duration discrepancy report.
+type DurationDiscrepancyFilter struct {
+	SystemIDs []int
+	StartTime time.Time
+	EndTime   *time.Time
+	Tolerance *float64 // nil = use the flag stored at ingest
+	MinCalls  int      // omit recorders with fewer measured calls
+}
+
+// RecorderDurationDiscrepancy summarizes reported-vs-measured audio durations
+// for one recorder (instance, site, and recorder number).
+type RecorderDurationDiscrepancy struct {
+	InstanceID      string     `json:"instance_id"`
+	SystemID        int        `json:"system_id"`
+	SystemName      string     `json:"system_name,omitempty"`
+	SiteID          *int       `json:"site_id,omitempty"`
+	SiteShortName   string     `json:"site_short_name,omitempty"`
+	RecNum          *int16     `json:"rec_num,omitempty"`
+	MeasuredCalls   int        `json:"measured_calls"`
+	MismatchedCalls int        `json:"mismatched_calls"`
+	MismatchRate    float64    `json:"mismatch_rate"`
+	AvgDelta        *float64   `json:"avg_delta,omitempty"` // mean reported − measured over mismatched calls
+	MaxDelta        *float64   `json:"max_delta,omitempty"` // largest |reported − measured|
+	LastMismatch    *time.Time `json:"last_mismatch,omitempty"`
+}
+
+// GetDurationDiscrepancies groups calls with a measured audio duration by
+// recorder and counts those whose reported duration didn't match, worst first.
+func (db *DB) GetDurationDiscrepancies(ctx context.Context, filter DurationDiscrepancyFilter) ([]RecorderDurationDiscrepancy, error) {
+	rows, err := db.Pool.Query(ctx, `
+		WITH measured AS (
+			SELECT c.instance_id, c.system_id, c.site_id, c.rec_num, c.start_time,
+				COALESCE(c.original_duration, c.duration) - c.audio_duration AS delta,
+				CASE WHEN $4::float8 IS NULL THEN COALESCE(c.duration_mismatch, false)
+				     ELSE COALESCE(c.original_duration, c.duration) > 0
+				          AND abs(COALESCE(c.original_duration, c.duration) - c.audio_duration) > $4 END AS mismatch
+			FROM calls c
+			WHERE c.start_time >= $1
+			  AND ($2::timestamptz IS NULL OR c.start_time < $2)
+			  AND ($3::int[] IS NULL OR c.system_id = ANY($3))
+			  AND c.audio_duration IS NOT NULL
+		)
+		SELECT COALESCE(m.instance_id, ''), m.system_id, COALESCE(s.name, ''),
+			m.site_id, COALESCE(st.short_name, ''), m.rec_num,
+			count(*)::int,
+			count(*) FILTER (WHERE m.mismatch)::int,
+			avg(m.delta) FILTER (WHERE m.mismatch),
+			max(abs(m.delta))::float8,
+			max(m.start_time) FILTER (WHERE m.mismatch)
+		FROM measured m
+		JOIN systems s ON s.system_id = m.system_id
+		LEFT JOIN sites st ON st.site_id = m.site_id
+		GROUP BY m.instance_id, m.system_id, s.name, m.site_id, st.short_name, m.rec_num
+		HAVING count(*) >= $5
+		ORDER BY count(*) FILTER (WHERE m.mismatch)::float8 / count(*) DESC,
+			count(*) FILTER (WHERE m.mismatch) DESC
+	`, filter.StartTime, filter.EndTime, pqIntArray(filter.SystemIDs), filter.Tolerance, filter.MinCalls)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []RecorderDurationDiscrepancy{}
+	for rows.Next() {
+		var d RecorderDurationDiscrepancy
+		if err := rows.Scan(&d.InstanceID, &d.SystemID, &d.SystemName,
+			&d.SiteID, &d.SiteShortName, &d.RecNum,
+			&d.MeasuredCalls, &d.MismatchedCalls,
+			&d.AvgDelta, &d.MaxDelta, &d.LastMismatch); err != nil {
+			return nil, err
+		}
+		if d.MeasuredCalls > 0 {
+			d.MismatchRate = float64(d.MismatchedCalls) / float64(d.MeasuredCalls)
+		}
+		result = append(result, d)
+	}
+	return result, rows.Err()
+}
+
+// DurationCorrectionFilter selects calls for CorrectCallDurations.
+type DurationCorrectionFilter struct {
+	Call      *CallRef // a single call; the other fields are ignored
+	SystemIDs []int
+	StartTime time.Time
+	EndTime   *time.Time
+	Threshold float64 // seconds |duration − audio_duration| must exceed
+	DryRun    bool    // count the calls without changing them
+}
+
+// DurationCorrectionResult reports the calls a correction pass changed (or
+// would change) and the airtime it added, in seconds; negative if calls were
+// shortened overall.
+type DurationCorrectionResult struct {
+	Calls        int64   `json:"calls"`
+	AirtimeDelta float64 `json:"airtime_delta"`
+}
+
+// CorrectCallDurations replaces the duration and stop_time of calls whose
+// measured audio_duration differs from the recorded duration by more than the
+// threshold, or that have no recorded duration. The values from before the
+// first correction are kept in original_duration and original_stop_time.
+func (db *DB) CorrectCallDurations(ctx context.Context, filter DurationCorrectionFilter) (DurationCorrectionResult, error) {
+	var callID *int64
+	if filter.Call != nil {
+		callID = &filter.Call.CallID
+		filter.StartTime = filter.Call.StartTime
+	}
+	const target = `
+		WITH target AS (
+			SELECT c.call_id, c.start_time, c.duration AS old_duration, c.audio_duration::float8 AS audio_duration
+			FROM calls c
+			WHERE ($4::bigint IS NULL OR (c.call_id = $4 AND c.start_time = $1))
+			  AND ($4::bigint IS NOT NULL OR c.start_time >= $1)
+			  AND ($2::timestamptz IS NULL OR c.start_time < $2)
+			  AND ($3::int[] IS NULL OR c.system_id = ANY($3))
+			  AND c.audio_duration > 0
+			  AND (c.duration IS NULL OR c.duration <= 0 OR abs(c.duration - c.audio_duration) > $5)
+		)`
+	query := target + `
+		SELECT count(*), COALESCE(sum(audio_duration - COALESCE(old_duration, 0)), 0)::float8 FROM target`
+	if !filter.DryRun {
+		query = target + `,
+		upd AS (
+			UPDATE calls c SET
+				original_duration     = CASE WHEN c.duration_corrected_at IS NULL THEN c.duration ELSE c.original_duration END,
+				original_stop_time    = CASE WHEN c.duration_corrected_at IS NULL THEN c.stop_time ELSE c.original_stop_time END,
+				duration              = t.audio_duration,
+				stop_time             = c.start_time + make_interval(secs => t.audio_duration),
+				duration_corrected_at = now()
+			FROM target t
+			WHERE c.call_id = t.call_id AND c.start_time = t.start_time
+			RETURNING t.audio_duration - COALESCE(t.old_duration, 0) AS delta
+		)
+		SELECT count(*), COALESCE(sum(delta), 0)::float8 FROM upd`
+	}
+
+	var res DurationCorrectionResult
+	err := db.Pool.QueryRow(ctx, query, filter.StartTime, filter.EndTime, pqIntArray(filter.SystemIDs),
+		callID, filter.Threshold).Scan(&res.Calls, &res.AirtimeDelta)
+	return res, err
+}
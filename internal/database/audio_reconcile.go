@@ -0,0 +1,186 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// Audio reconciliation statuses (audio_reconciliation.status).
+const (
+	ReconcileSearching = "searching" // not found yet, retried
+	ReconcileRecovered = "recovered" // file found and attached
+	ReconcileMissing   = "missing"   // not found after every attempt
+	ReconcileSkipped   = "skipped"   // the call's policy keeps audio off it
+)
+
+// ReconcileCandidate is a finished call with no audio.
+type ReconcileCandidate struct {
+	CallID        int64
+	StartTime     time.Time
+	SystemID      int
+	Tgid          int
+	SiteShortName string // TR's short_name, the top directory of its capture layout
+	Attempts      int
+}
+
+// ListReconcileCandidates returns unencrypted calls started in [since, before)
+// and finished, with neither an audio_file_path nor a call_filename, oldest
+// first. Calls already recovered, missing or skipped are left out, as are
+// those searched within the last retryAfter.
+func (db *DB) ListReconcileCandidates(ctx context.Context, since, before time.Time, retryAfter time.Duration, limit int) ([]ReconcileCandidate, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT c.call_id, c.start_time, c.system_id, c.tgid,
+			COALESCE(NULLIF(c.site_short_name, ''), c.system_name, ''), COALESCE(r.attempts, 0)
+		FROM calls c
+		LEFT JOIN audio_reconciliation r
+			ON r.call_id = c.call_id AND r.call_start_time = c.start_time
+		WHERE c.start_time >= $1 AND c.start_time < $2
+		  AND c.stop_time IS NOT NULL
+		  AND c.merged_into IS NULL
+		  AND COALESCE(c.audio_file_path, '') = ''
+		  AND COALESCE(c.call_filename, '') = ''
+		  AND NOT COALESCE(c.encrypted, false)
+		  AND (r.call_id IS NULL OR (r.status = 'searching' AND r.checked_at < now() - $3::interval))
+		ORDER BY c.start_time
+		LIMIT $4
+	`, since, before, retryAfter, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []ReconcileCandidate
+	for rows.Next() {
+		var c ReconcileCandidate
+		if err := rows.Scan(&c.CallID, &c.StartTime, &c.SystemID, &c.Tgid, &c.SiteShortName, &c.Attempts); err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+	return result, rows.Err()
+}
+
+// RecordReconcileResult records the outcome of checking a call, counting an
+// attempt.
+func (db *DB) RecordReconcileResult(ctx context.Context, c ReconcileCandidate, status, audioPath string) error {
+	_, err := db.Pool.Exec(ctx, `
+		INSERT INTO audio_reconciliation (call_id, call_start_time, system_id, tgid, status, attempts, audio_path, checked_at)
+		VALUES ($1, $2, $3, $4, $5, 1, NULLIF($6, ''), now())
+		ON CONFLICT (call_id, call_start_time) DO UPDATE SET
+			status     = EXCLUDED.status,
+			attempts   = audio_reconciliation.attempts + 1,
+			audio_path = EXCLUDED.audio_path,
+			checked_at = now()
+	`, c.CallID, c.StartTime, c.SystemID, c.Tgid, status, audioPath)
+	return err
+}
+
+// CallFilenameTaken reports whether a call on the system started within an
+// hour of around already has a call_filename ending in the file name base, so
+// one file is never attached to two calls.
+func (db *DB) CallFilenameTaken(ctx context.Context, systemID int, base string, around time.Time) (bool, error) {
+	var taken bool
+	err := db.Pool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM calls
+			WHERE system_id = $1
+			  AND start_time BETWEEN $3::timestamptz - interval '1 hour' AND $3::timestamptz + interval '1 hour'
+			  AND (call_filename = $2 OR right(call_filename, length($2) + 1) = '/' || $2)
+		)
+	`, systemID, base, around).Scan(&taken)
+	return taken, err
+}
+
+// ResetMissingAudio makes calls given up on as missing eligible for another
+// search, e.g. after files were restored. Returns the number of calls reset.
+func (db *DB) ResetMissingAudio(ctx context.Context) (int64, error) {
+	tag, err := db.Pool.Exec(ctx, `DELETE FROM audio_reconciliation WHERE status = 'missing'`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ReconcileStats counts reconciled calls started in a window by outcome.
+type ReconcileStats struct {
+	Recovered int64 `json:"recovered"`
+	Missing   int64 `json:"missing"`   // permanently: every attempt missed
+	Searching int64 `json:"searching"` // not found yet, still retried
+	Skipped   int64 `json:"skipped"`
+	Unchecked int64 `json:"unchecked"` // finished calls without audio not looked at yet
+}
+
+// GetReconcileStats counts calls started in [since, before) by reconciliation
+// outcome; Unchecked counts candidates never searched.
+func (db *DB) GetReconcileStats(ctx context.Context, since, before time.Time) (ReconcileStats, error) {
+	var s ReconcileStats
+	err := db.Pool.QueryRow(ctx, `
+		SELECT
+			count(*) FILTER (WHERE status = 'recovered'),
+			count(*) FILTER (WHERE status = 'missing'),
+			count(*) FILTER (WHERE status = 'searching'),
+			count(*) FILTER (WHERE status = 'skipped')
+		FROM audio_reconciliation
+		WHERE call_start_time >= $1 AND call_start_time < $2
+	`, since, before).Scan(&s.Recovered, &s.Missing, &s.Searching, &s.Skipped)
+	if err != nil {
+		return s, err
+	}
+	err = db.Pool.QueryRow(ctx, `
+		SELECT count(*)
+		FROM calls c
+		WHERE c.start_time >= $1 AND c.start_time < $2
+		  AND c.stop_time IS NOT NULL
+		  AND c.merged_into IS NULL
+		  AND COALESCE(c.audio_file_path, '') = ''
+		  AND COALESCE(c.call_filename, '') = ''
+		  AND NOT COALESCE(c.encrypted, false)
+		  AND NOT EXISTS (
+			SELECT 1 FROM audio_reconciliation r
+			WHERE r.call_id = c.call_id AND r.call_start_time = c.start_time
+		  )
+	`, since, before).Scan(&s.Unchecked)
+	return s, err
+}
+
+// ReconciledCall is a call the reconciler has checked.
+type ReconciledCall struct {
+	CallID     int64     `json:"call_id"`
+	StartTime  time.Time `json:"start_time"`
+	SystemID   int       `json:"system_id"`
+	Tgid       int       `json:"tgid"`
+	TgAlphaTag string    `json:"tg_alpha_tag,omitempty"`
+	Status     string    `json:"status"`
+	Attempts   int       `json:"attempts"`
+	AudioPath  string    `json:"audio_path,omitempty"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// ListReconciledCalls returns checked calls started at or after since with the
+// given status (any when empty), most recent call first.
+func (db *DB) ListReconciledCalls(ctx context.Context, status string, since time.Time, limit int) ([]ReconciledCall, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT r.call_id, r.call_start_time, r.system_id, r.tgid, COALESCE(c.tg_alpha_tag, ''),
+			r.status, r.attempts, COALESCE(r.audio_path, ''), r.checked_at
+		FROM audio_reconciliation r
+		LEFT JOIN calls c ON c.call_id = r.call_id AND c.start_time = r.call_start_time
+		WHERE ($1 = '' OR r.status = $1) AND r.call_start_time >= $2
+		ORDER BY r.call_start_time DESC
+		LIMIT $3
+	`, status, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []ReconciledCall{}
+	for rows.Next() {
+		var c ReconciledCall
+		if err := rows.Scan(&c.CallID, &c.StartTime, &c.SystemID, &c.Tgid, &c.TgAlphaTag,
+			&c.Status, &c.Attempts, &c.AudioPath, &c.CheckedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+	return result, rows.Err()
+}
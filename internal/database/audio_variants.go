@@ -0,0 +1,214 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Audio variant names used by tr-engine itself. Variants uploaded through the
+// API may use other names.
+const (
+	AudioVariantOriginal   = "original"   // audio as recorded by TR
+	AudioVariantTranscoded = "transcoded" // re-encoded by a transcode storage policy
+	AudioVariantRedacted   = "redacted"
+)
+
+// AudioVariant is one stored version of a call's audio. The default variant
+// is the one served by /calls/{id}/audio and mirrored in
+// calls.audio_file_path, so readers of that column see it unchanged.
+type AudioVariant struct {
+	ID            int64     `json:"id"`
+	CallID        int64     `json:"call_id"`
+	CallStartTime time.Time `json:"call_start_time"`
+	Variant       string    `json:"variant"`
+	AudioPath     string    `json:"-"` // storage key
+	AudioType     string    `json:"audio_type,omitempty"`
+	AudioSize     int       `json:"audio_size,omitempty"`
+	Source        string    `json:"source"` // "mqtt", "upload", "archive", "import", "api", "legacy"
+	IsDefault     bool      `json:"is_default"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+const audioVariantColumns = `id, call_id, call_start_time, variant, audio_path,
+	COALESCE(audio_type, ''), COALESCE(audio_size, 0), source, is_default, created_at`
+
+func scanAudioVariants(rows pgx.Rows) ([]AudioVariant, error) {
+	defer rows.Close()
+	variants := []AudioVariant{}
+	for rows.Next() {
+		var v AudioVariant
+		if err := rows.Scan(&v.ID, &v.CallID, &v.CallStartTime, &v.Variant, &v.AudioPath,
+			&v.AudioType, &v.AudioSize, &v.Source, &v.IsDefault, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		variants = append(variants, v)
+	}
+	return variants, rows.Err()
+}
+
+// AddCallAudioVariant records (or replaces) a variant of a call's audio. It
+// becomes the default when makeDefault is set or the call has no default
+// yet. A call whose audio predates variants has its existing
+// audio_file_path recorded as the "original" variant first, so it isn't lost
+// when another variant becomes the default.
+func (db *DB) AddCallAudioVariant(ctx context.Context, v AudioVariant, makeDefault bool) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO call_audio_variants (call_id, call_start_time, variant, audio_path, audio_size, source, is_default)
+		SELECT c.call_id, c.start_time, 'original', c.audio_file_path, c.audio_file_size, 'legacy', true
+		FROM calls c
+		WHERE c.call_id = $1 AND c.start_time = $2
+		  AND COALESCE(c.audio_file_path, '') <> '' AND c.audio_file_path <> $3
+		  AND NOT EXISTS (SELECT 1 FROM call_audio_variants v
+		                  WHERE v.call_id = c.call_id AND v.call_start_time = c.start_time)
+	`, v.CallID, v.CallStartTime, v.AudioPath); err != nil {
+		return fmt.Errorf("record legacy audio: %w", err)
+	}
+
+	var hasDefault bool
+	if err := tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM call_audio_variants
+		               WHERE call_id = $1 AND call_start_time = $2 AND is_default AND variant <> $3)
+	`, v.CallID, v.CallStartTime, v.Variant).Scan(&hasDefault); err != nil {
+		return err
+	}
+	isDefault := makeDefault || !hasDefault
+	if isDefault {
+		if _, err := tx.Exec(ctx, `
+			UPDATE call_audio_variants SET is_default = false
+			WHERE call_id = $1 AND call_start_time = $2 AND is_default AND variant <> $3
+		`, v.CallID, v.CallStartTime, v.Variant); err != nil {
+			return fmt.Errorf("clear default: %w", err)
+		}
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO call_audio_variants (call_id, call_start_time, variant, audio_path, audio_type, audio_size, source, is_default)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8)
+		ON CONFLICT (call_id, call_start_time, variant) DO UPDATE SET
+			audio_path = EXCLUDED.audio_path,
+			audio_type = EXCLUDED.audio_type,
+			audio_size = EXCLUDED.audio_size,
+			source     = EXCLUDED.source,
+			is_default = call_audio_variants.is_default OR EXCLUDED.is_default,
+			created_at = now()
+	`, v.CallID, v.CallStartTime, v.Variant, v.AudioPath, v.AudioType, v.AudioSize, v.Source, isDefault); err != nil {
+		return fmt.Errorf("insert audio variant: %w", err)
+	}
+	if err := syncDefaultAudio(ctx, tx, v.CallID, v.CallStartTime); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// syncDefaultAudio copies the default variant's path and size to the call.
+func syncDefaultAudio(ctx context.Context, tx pgx.Tx, callID int64, startTime time.Time) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE calls c SET audio_file_path = v.audio_path, audio_file_size = v.audio_size
+		FROM call_audio_variants v
+		WHERE v.call_id = c.call_id AND v.call_start_time = c.start_time AND v.is_default
+		  AND c.call_id = $1 AND c.start_time = $2
+	`, callID, startTime)
+	if err != nil {
+		return fmt.Errorf("update call audio: %w", err)
+	}
+	return nil
+}
+
+// ListCallAudioVariants returns a call's audio variants, default first. A
+// call with audio from before variants existed lists its audio_file_path as
+// the default "original".
+func (db *DB) ListCallAudioVariants(ctx context.Context, ref CallRef) ([]AudioVariant, error) {
+	ref, err := db.ResolveCallRef(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Pool.Query(ctx, `SELECT `+audioVariantColumns+`
+		FROM call_audio_variants
+		WHERE call_id = $1 AND call_start_time = $2
+		ORDER BY is_default DESC, created_at, variant
+	`, ref.CallID, ref.StartTime)
+	if err != nil {
+		return nil, err
+	}
+	variants, err := scanAudioVariants(rows)
+	if err != nil || len(variants) > 0 {
+		return variants, err
+	}
+
+	path, _, err := db.GetCallAudioPath(ctx, ref)
+	if err != nil || path == "" {
+		return variants, err
+	}
+	var size int
+	_ = db.Pool.QueryRow(ctx, `SELECT COALESCE(audio_file_size, 0) FROM calls WHERE call_id = $1 AND start_time = $2`,
+		ref.CallID, ref.StartTime).Scan(&size)
+	return []AudioVariant{{
+		CallID: ref.CallID, CallStartTime: ref.StartTime, Variant: AudioVariantOriginal,
+		AudioPath: path, AudioSize: size, Source: "legacy", IsDefault: true,
+	}}, nil
+}
+
+// GetCallAudioVariant returns one variant of a call's audio. Returns
+// "audio variant not found" if the call has no such variant.
+func (db *DB) GetCallAudioVariant(ctx context.Context, ref CallRef, variant string) (*AudioVariant, error) {
+	variants, err := db.ListCallAudioVariants(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	for i := range variants {
+		if variants[i].Variant == variant {
+			return &variants[i], nil
+		}
+	}
+	return nil, fmt.Errorf("audio variant not found")
+}
+
+// SetDefaultCallAudioVariant makes variant the one served for the call.
+// Returns "audio variant not found" if the call has no such variant.
+func (db *DB) SetDefaultCallAudioVariant(ctx context.Context, ref CallRef, variant string) (*AudioVariant, error) {
+	v, err := db.GetCallAudioVariant(ctx, ref, variant)
+	if err != nil {
+		return nil, err
+	}
+	if v.ID == 0 {
+		// Legacy audio: already the default
+		return v, nil
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE call_audio_variants SET is_default = (variant = $3)
+		WHERE call_id = $1 AND call_start_time = $2 AND (is_default OR variant = $3)
+	`, v.CallID, v.CallStartTime, variant); err != nil {
+		return nil, fmt.Errorf("set default: %w", err)
+	}
+	if err := syncDefaultAudio(ctx, tx, v.CallID, v.CallStartTime); err != nil {
+		return nil, err
+	}
+	row, err := tx.Query(ctx, `SELECT `+audioVariantColumns+` FROM call_audio_variants WHERE id = $1`, v.ID)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := scanAudioVariants(row)
+	if err != nil {
+		return nil, err
+	}
+	if len(updated) == 0 {
+		return nil, errors.New("audio variant not found")
+	}
+	return &updated[0], tx.Commit(ctx)
+}
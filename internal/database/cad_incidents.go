@@ -0,0 +1,241 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CADIncident is a dispatch page received by email (see internal/cadmail),
+// linked to the calls on its dispatch talkgroups around DispatchedAt.
+type CADIncident struct {
+	ID             int64             `json:"id"`
+	ReceivedAt     time.Time         `json:"received_at"`
+	Format         string            `json:"format"`
+	IncidentNumber string            `json:"incident_number,omitempty"`
+	IncidentType   string            `json:"incident_type,omitempty"`
+	Address        string            `json:"address,omitempty"`
+	Units          string            `json:"units,omitempty"`
+	DispatchedAt   time.Time         `json:"dispatched_at"`
+	SystemID       int               `json:"system_id"`
+	Tgids          []int             `json:"tgids"`
+	WindowBefore   int               `json:"window_before_s"`
+	WindowAfter    int               `json:"window_after_s"`
+	Fields         map[string]string `json:"fields,omitempty"`
+	Sender         string            `json:"sender,omitempty"`
+	Subject        string            `json:"subject,omitempty"`
+	Body           string            `json:"body,omitempty"`
+	MessageID      string            `json:"-"`
+	CallCount      int               `json:"call_count"`
+	Calls          []CADIncidentCall `json:"calls,omitempty"`
+
+	// ExternalEvents linked to the incident's calls (incident detail only).
+	ExternalEvents []ExternalEventRef `json:"external_events,omitempty"`
+}
+
+// CADIncidentCall is a call linked to a CAD incident.
+type CADIncidentCall struct {
+	CallID     int64     `json:"call_id"`
+	StartTime  time.Time `json:"start_time"`
+	SystemID   int       `json:"system_id"`
+	Tgid       int       `json:"tgid"`
+	TgAlphaTag string    `json:"tg_alpha_tag,omitempty"`
+	Duration   *float32  `json:"duration,omitempty"`
+}
+
+// CADIncidentRef is the summary of an incident shown on call detail.
+type CADIncidentRef struct {
+	ID             int64     `json:"id"`
+	IncidentNumber string    `json:"incident_number,omitempty"`
+	IncidentType   string    `json:"incident_type,omitempty"`
+	Address        string    `json:"address,omitempty"`
+	DispatchedAt   time.Time `json:"dispatched_at"`
+}
+
+// CADIncidentFilter selects incidents for ListCADIncidents.
+type CADIncidentFilter struct {
+	Query     string // case-insensitive substring of number, type, address, units or body
+	SystemID  *int
+	StartTime *time.Time // dispatched_at >= StartTime
+	EndTime   *time.Time // dispatched_at < EndTime
+	Limit     int
+	Offset    int
+}
+
+// InsertCADIncident stores a parsed page and returns its ID. A page whose
+// email Message-ID was already stored is skipped: inserted is false and the
+// existing incident's ID is returned.
+func (db *DB) InsertCADIncident(ctx context.Context, inc *CADIncident) (id int64, inserted bool, err error) {
+	err = db.Pool.QueryRow(ctx, `
+		INSERT INTO cad_incidents (format, incident_number, incident_type, address, units, dispatched_at,
+			system_id, tgids, window_before_s, window_after_s, fields, sender, subject, body, message_id)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), $6,
+			$7, $8, $9, $10, $11, NULLIF($12, ''), NULLIF($13, ''), $14, NULLIF($15, ''))
+		ON CONFLICT (message_id) WHERE message_id IS NOT NULL DO NOTHING
+		RETURNING id
+	`, inc.Format, inc.IncidentNumber, inc.IncidentType, inc.Address, inc.Units, inc.DispatchedAt,
+		inc.SystemID, inc.Tgids, inc.WindowBefore, inc.WindowAfter, inc.Fields, inc.Sender, inc.Subject,
+		inc.Body, inc.MessageID).Scan(&id)
+	if err == nil {
+		return id, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, err
+	}
+	err = db.Pool.QueryRow(ctx, `SELECT id FROM cad_incidents WHERE message_id = $1`, inc.MessageID).Scan(&id)
+	return id, false, err
+}
+
+// CorrelateCADIncidents links incidents dispatched since `since` to the calls
+// on their system and dispatch talkgroups that started within the incident's
+// window. Already linked calls are kept, so it can run repeatedly while calls
+// in the window are still arriving. Returns the number of new links.
+func (db *DB) CorrelateCADIncidents(ctx context.Context, since time.Time) (int64, error) {
+	tag, err := db.Pool.Exec(ctx, `
+		INSERT INTO cad_incident_calls (incident_id, call_id, call_start_time)
+		SELECT i.id, c.call_id, c.start_time
+		FROM cad_incidents i
+		JOIN calls c ON c.system_id = i.system_id
+		            AND c.tgid = ANY(i.tgids)
+		            AND c.start_time >= i.dispatched_at - make_interval(secs => i.window_before_s)
+		            AND c.start_time <= i.dispatched_at + make_interval(secs => i.window_after_s)
+		WHERE i.dispatched_at >= $1
+		ON CONFLICT DO NOTHING
+	`, since)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+const cadIncidentColumns = `i.id, i.received_at, i.format, COALESCE(i.incident_number, ''),
+	COALESCE(i.incident_type, ''), COALESCE(i.address, ''), COALESCE(i.units, ''), i.dispatched_at,
+	i.system_id, i.tgids, i.window_before_s, i.window_after_s, COALESCE(i.fields, '{}'::jsonb),
+	COALESCE(i.sender, ''), COALESCE(i.subject, ''), i.body,
+	(SELECT count(*) FROM cad_incident_calls ic WHERE ic.incident_id = i.id)`
+
+func scanCADIncident(row pgx.Row, inc *CADIncident) error {
+	return row.Scan(&inc.ID, &inc.ReceivedAt, &inc.Format, &inc.IncidentNumber,
+		&inc.IncidentType, &inc.Address, &inc.Units, &inc.DispatchedAt,
+		&inc.SystemID, &inc.Tgids, &inc.WindowBefore, &inc.WindowAfter, &inc.Fields,
+		&inc.Sender, &inc.Subject, &inc.Body, &inc.CallCount)
+}
+
+// ListCADIncidents returns incidents matching the filter, newest first, with
+// the total count. Bodies are omitted; GetCADIncident returns them.
+func (db *DB) ListCADIncidents(ctx context.Context, f CADIncidentFilter) ([]CADIncident, int, error) {
+	const where = `
+		WHERE ($1::text IS NULL OR concat_ws(' ', i.incident_number, i.incident_type, i.address, i.units, i.body)
+		                           ILIKE '%' || $1 || '%')
+		  AND ($2::int IS NULL OR i.system_id = $2)
+		  AND ($3::timestamptz IS NULL OR i.dispatched_at >= $3)
+		  AND ($4::timestamptz IS NULL OR i.dispatched_at < $4)`
+	var query *string
+	if f.Query != "" {
+		query = &f.Query
+	}
+	args := []any{query, f.SystemID, f.StartTime, f.EndTime}
+
+	var total int
+	if err := db.Pool.QueryRow(ctx, `SELECT count(*) FROM cad_incidents i`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := db.Pool.Query(ctx, `SELECT `+cadIncidentColumns+` FROM cad_incidents i`+where+`
+		ORDER BY i.dispatched_at DESC, i.id DESC
+		LIMIT $5 OFFSET $6`, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	incidents := []CADIncident{}
+	for rows.Next() {
+		var inc CADIncident
+		if err := scanCADIncident(rows, &inc); err != nil {
+			return nil, 0, err
+		}
+		inc.Body = ""
+		incidents = append(incidents, inc)
+	}
+	return incidents, total, rows.Err()
+}
+
+// GetCADIncident returns one incident with its linked calls. Calls hidden by
+// a restricted encryption policy are left out unless includeRestricted.
+func (db *DB) GetCADIncident(ctx context.Context, id int64, includeRestricted bool) (*CADIncident, error) {
+	var inc CADIncident
+	err := scanCADIncident(db.Pool.QueryRow(ctx, `SELECT `+cadIncidentColumns+` FROM cad_incidents i WHERE i.id = $1`, id), &inc)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("cad incident not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT c.call_id, c.start_time, c.system_id, c.tgid, COALESCE(c.tg_alpha_tag, ''), c.duration
+		FROM cad_incident_calls ic
+		JOIN calls c ON c.call_id = ic.call_id AND c.start_time = ic.call_start_time
+		WHERE ic.incident_id = $1
+		  AND ($2 OR NOT `+restrictedCallSQL+`)
+		ORDER BY c.start_time
+	`, id, includeRestricted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	inc.Calls = []CADIncidentCall{}
+	for rows.Next() {
+		var c CADIncidentCall
+		if err := rows.Scan(&c.CallID, &c.StartTime, &c.SystemID, &c.Tgid, &c.TgAlphaTag, &c.Duration); err != nil {
+			return nil, err
+		}
+		inc.Calls = append(inc.Calls, c)
+	}
+	inc.CallCount = len(inc.Calls)
+	return &inc, rows.Err()
+}
+
+// ListCallCADIncidents returns the incidents a call is linked to.
+func (db *DB) ListCallCADIncidents(ctx context.Context, ref CallRef) ([]CADIncidentRef, error) {
+	ref, err := db.ResolveCallRef(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Pool.Query(ctx, `
+		SELECT i.id, COALESCE(i.incident_number, ''), COALESCE(i.incident_type, ''),
+			COALESCE(i.address, ''), i.dispatched_at
+		FROM cad_incident_calls ic
+		JOIN cad_incidents i ON i.id = ic.incident_id
+		WHERE ic.call_id = $1 AND ic.call_start_time = $2
+		ORDER BY i.dispatched_at
+	`, ref.CallID, ref.StartTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	refs := []CADIncidentRef{}
+	for rows.Next() {
+		var r CADIncidentRef
+		if err := rows.Scan(&r.ID, &r.IncidentNumber, &r.IncidentType, &r.Address, &r.DispatchedAt); err != nil {
+			return nil, err
+		}
+		refs = append(refs, r)
+	}
+	return refs, rows.Err()
+}
+
+// DeleteCADIncident removes an incident and its call links.
+func (db *DB) DeleteCADIncident(ctx context.Context, id int64) error {
+	tag, err := db.Pool.Exec(ctx, `DELETE FROM cad_incidents WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("cad incident not found")
+	}
+	return nil
+}
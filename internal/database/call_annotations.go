@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CallAnnotation is a typed note on a call posted by a bot or external
+// decoder (POST /calls/{id}/annotations). Annotations are machine-written
+// and kept apart from transcripts and human edits.
+type CallAnnotation struct {
+	ID         int64           `json:"id"`
+	CallID     int64           `json:"call_id"`
+	StartTime  time.Time       `json:"call_start_time"`
+	Type       string          `json:"type"`
+	Source     string          `json:"source"`
+	Label      string          `json:"label,omitempty"`
+	Confidence *float32        `json:"confidence,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+const callAnnotationColumns = `id, call_id, call_start_time, type, source, label, confidence, data, created_at`
+
+func scanCallAnnotation(row pgx.Row, a *CallAnnotation) error {
+	var data []byte
+	if err := row.Scan(&a.ID, &a.CallID, &a.StartTime, &a.Type, &a.Source, &a.Label,
+		&a.Confidence, &data, &a.CreatedAt); err != nil {
+		return err
+	}
+	if data != nil {
+		a.Data = data
+	}
+	return nil
+}
+
+// CreateCallAnnotation stores an annotation on the call ref (resolved, so
+// StartTime is the call's) and returns it as stored.
+func (db *DB) CreateCallAnnotation(ctx context.Context, ref CallRef, a *CallAnnotation) (*CallAnnotation, error) {
+	var data []byte
+	if len(a.Data) > 0 {
+		data = a.Data
+	}
+	var out CallAnnotation
+	err := scanCallAnnotation(db.Pool.QueryRow(ctx, `
+		INSERT INTO call_annotations (call_id, call_start_time, type, source, label, confidence, data)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+callAnnotationColumns,
+		ref.CallID, ref.StartTime, a.Type, a.Source, a.Label, a.Confidence, data), &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListCallsAnnotations returns the annotations on any of the given calls,
+// oldest first.
+func (db *DB) ListCallsAnnotations(ctx context.Context, refs []CallRef) ([]CallAnnotation, error) {
+	annotations := []CallAnnotation{}
+	if len(refs) == 0 {
+		return annotations, nil
+	}
+	callIDs := make([]int64, len(refs))
+	startTimes := make([]time.Time, len(refs))
+	for i, ref := range refs {
+		callIDs[i] = ref.CallID
+		startTimes[i] = ref.StartTime
+	}
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+callAnnotationColumns+`
+		FROM call_annotations
+		WHERE (call_id, call_start_time) IN (
+			SELECT * FROM unnest($1::bigint[], $2::timestamptz[]))
+		ORDER BY created_at, id
+	`, callIDs, startTimes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a CallAnnotation
+		if err := scanCallAnnotation(rows, &a); err != nil {
+			return nil, err
+		}
+		annotations = append(annotations, a)
+	}
+	return annotations, rows.Err()
+}
+
+// DeleteCallAnnotation removes one of a call's annotations.
+func (db *DB) DeleteCallAnnotation(ctx context.Context, ref CallRef, id int64) error {
+	tag, err := db.Pool.Exec(ctx, `
+		DELETE FROM call_annotations
+		WHERE id = $1 AND call_id = $2 AND call_start_time = $3
+	`, id, ref.CallID, ref.StartTime)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("annotation not found")
+	}
+	return nil
+}
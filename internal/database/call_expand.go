@@ -0,0 +1,245 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database/sqlcdb"
+)
+
+// CallExpansion selects the related records ExpandCalls embeds in calls
+// (?expand= on the calls API).
+type CallExpansion struct {
+	Transcription     bool
+	Transmissions     bool
+	Frequencies       bool
+	Group             bool
+	UnitTags          bool
+	IncludeRestricted bool // list restricted calls among the group's recordings
+}
+
+// Empty reports whether nothing is expanded.
+func (x CallExpansion) Empty() bool {
+	return !x.Transcription && !x.Transmissions && !x.Frequencies && !x.Group && !x.UnitTags
+}
+
+// CallGroupExpansion is a call's group with its recordings, embedded by
+// ?expand=group.
+type CallGroupExpansion struct {
+	CallGroupAPI
+	Calls []CallGroupMember `json:"calls"`
+}
+
+// CallGroupMember is one recording of a call group.
+type CallGroupMember struct {
+	CallID        int64     `json:"call_id"`
+	SiteID        *int      `json:"site_id,omitempty"`
+	SiteShortName string    `json:"site_short_name,omitempty"`
+	StartTime     time.Time `json:"start_time"`
+	Duration      *float32  `json:"duration,omitempty"`
+	SignalDB      *float32  `json:"signal_db,omitempty"`
+	ErrorCount    *int      `json:"error_count,omitempty"`
+}
+
+// CallUnitTag is the alpha tag of a unit heard on a call, embedded by
+// ?expand=unit_tags.
+type CallUnitTag struct {
+	UnitID         int32  `json:"unit_id"`
+	AlphaTag       string `json:"alpha_tag"`
+	AlphaTagSource string `json:"alpha_tag_source,omitempty"`
+}
+
+// ExpandCalls embeds the related records selected by x in calls. Each kind
+// costs at most one query for the whole slice, except groups, which are
+// loaded once per distinct group. Transmissions and frequencies are decoded
+// from the calls' src_list and freq_list, so those must have been selected.
+// Like the other optional call fields, expansions that come up empty are
+// left out of the JSON.
+func (db *DB) ExpandCalls(ctx context.Context, calls []CallAPI, x CallExpansion) error {
+	if len(calls) == 0 || x.Empty() {
+		return nil
+	}
+	for i := range calls {
+		c := &calls[i]
+		if x.Transmissions {
+			if len(c.SrcList) > 0 && string(c.SrcList) != "null" {
+				if err := json.Unmarshal(c.SrcList, &c.Transmissions); err != nil {
+					return err
+				}
+			}
+		}
+		if x.Frequencies {
+			if len(c.FreqList) > 0 && string(c.FreqList) != "null" {
+				if err := json.Unmarshal(c.FreqList, &c.Frequencies); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if x.Transcription {
+		if err := db.expandTranscriptions(ctx, calls); err != nil {
+			return err
+		}
+	}
+	if x.UnitTags {
+		if err := db.expandUnitTags(ctx, calls); err != nil {
+			return err
+		}
+	}
+	if x.Group {
+		if err := db.expandGroups(ctx, calls, x.IncludeRestricted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandTranscriptions loads the primary transcription of each call that
+// has one.
+func (db *DB) expandTranscriptions(ctx context.Context, calls []CallAPI) error {
+	byCall := make(map[int64][]int)
+	var ids []int64
+	for i, c := range calls {
+		if !c.HasTranscription {
+			continue
+		}
+		if _, ok := byCall[c.CallID]; !ok {
+			ids = append(ids, c.CallID)
+		}
+		byCall[c.CallID] = append(byCall[c.CallID], i)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT DISTINCT ON (call_id) id, call_id, text, source, is_primary,
+			confidence, language, model, provider,
+			word_count, duration_ms, provider_ms, words, created_at
+		FROM transcriptions
+		WHERE call_id = ANY($1) AND is_primary = true
+		ORDER BY call_id, created_at DESC
+	`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var ts []*TranscriptionAPI
+	for rows.Next() {
+		var r sqlcdb.GetPrimaryTranscriptionRow
+		if err := rows.Scan(
+			&r.ID, &r.CallID, &r.Text, &r.Source, &r.IsPrimary,
+			&r.Confidence, &r.Language, &r.Model, &r.Provider,
+			&r.WordCount, &r.DurationMs, &r.ProviderMs, &r.Words, &r.CreatedAt,
+		); err != nil {
+			return err
+		}
+		t := primaryTranscriptionToAPI(r)
+		ts = append(ts, &t)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := db.loadTranscriptionUrgency(ctx, ts); err != nil {
+		return err
+	}
+	if err := db.loadTranscriptionEdits(ctx, ts); err != nil {
+		return err
+	}
+	for _, t := range ts {
+		for _, i := range byCall[t.CallID] {
+			calls[i].Transcription = t
+		}
+	}
+	return nil
+}
+
+// expandUnitTags looks up the alpha tags of the calls' units in one query.
+// Units without a tag are listed with an empty one, so unit_tags lines up
+// with unit_ids.
+func (db *DB) expandUnitTags(ctx context.Context, calls []CallAPI) error {
+	type key struct{ system, unit int }
+	tags := make(map[key]CallUnitTag)
+	var systems, units []int32
+	for _, c := range calls {
+		for _, u := range c.UnitIDs {
+			k := key{c.SystemID, int(u)}
+			if _, ok := tags[k]; ok {
+				continue
+			}
+			tags[k] = CallUnitTag{UnitID: u}
+			systems = append(systems, int32(c.SystemID))
+			units = append(units, u)
+		}
+	}
+
+	if len(units) > 0 {
+		rows, err := db.Pool.Query(ctx, `
+			SELECT u.system_id, u.unit_id, COALESCE(u.alpha_tag, ''), COALESCE(u.alpha_tag_source, '')
+			FROM unnest($1::int[], $2::int[]) AS k(system_id, unit_id)
+			JOIN units u ON u.system_id = k.system_id AND u.unit_id = k.unit_id
+		`, systems, units)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var k key
+			var t CallUnitTag
+			if err := rows.Scan(&k.system, &k.unit, &t.AlphaTag, &t.AlphaTagSource); err != nil {
+				return err
+			}
+			t.UnitID = int32(k.unit)
+			tags[k] = t
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	for i := range calls {
+		c := &calls[i]
+		for _, u := range c.UnitIDs {
+			c.UnitTags = append(c.UnitTags, tags[key{c.SystemID, int(u)}])
+		}
+	}
+	return nil
+}
+
+// expandGroups loads the group of each grouped call, once per group.
+func (db *DB) expandGroups(ctx context.Context, calls []CallAPI, includeRestricted bool) error {
+	groups := make(map[int]*CallGroupExpansion)
+	for i := range calls {
+		id := calls[i].CallGroupID
+		if id == nil {
+			continue
+		}
+		g, ok := groups[*id]
+		if !ok {
+			group, members, err := db.GetCallGroupByID(ctx, *id)
+			if err != nil {
+				return err
+			}
+			g = &CallGroupExpansion{CallGroupAPI: *group, Calls: []CallGroupMember{}}
+			for _, m := range members {
+				if m.Restricted && !includeRestricted {
+					continue
+				}
+				g.Calls = append(g.Calls, CallGroupMember{
+					CallID:        m.CallID,
+					SiteID:        m.SiteID,
+					SiteShortName: m.SiteShortName,
+					StartTime:     m.StartTime,
+					Duration:      m.Duration,
+					SignalDB:      m.SignalDB,
+					ErrorCount:    m.ErrorCount,
+				})
+			}
+			groups[*id] = g
+		}
+		calls[i].Group = g
+	}
+	return nil
+}
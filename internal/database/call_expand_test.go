@@ -0,0 +1,27 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestExpandCallsLists(t *testing.T) {
+	calls := []CallAPI{
+		{CallID: 1, SrcList: json.RawMessage(`[{"src":101,"tag":"E1","time":"2026-01-02T03:04:05Z","emergency":0}]`), FreqList: json.RawMessage(`[{"freq":851000000}]`)},
+		{CallID: 2, SrcList: json.RawMessage(`null`)},
+	}
+	var db DB // transmissions and frequencies don't query
+	if err := db.ExpandCalls(context.Background(), calls, CallExpansion{Transmissions: true, Frequencies: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls[0].Transmissions) != 1 || calls[0].Transmissions[0].Src != 101 || calls[0].Transmissions[0].Tag != "E1" {
+		t.Errorf("transmissions = %+v", calls[0].Transmissions)
+	}
+	if len(calls[0].Frequencies) != 1 || calls[0].Frequencies[0].Freq != 851000000 {
+		t.Errorf("frequencies = %+v", calls[0].Frequencies)
+	}
+	if calls[1].Transmissions != nil || calls[1].Frequencies != nil {
+		t.Errorf("empty lists expanded: %+v", calls[1])
+	}
+}
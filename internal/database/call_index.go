@@ -0,0 +1,32 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CallRef identifies a single call. calls is partitioned by start_time, so
+// call_id alone can't be pruned to a partition; with StartTime set, lookups
+// hit exactly one. A zero StartTime is filled in from call_index.
+type CallRef struct {
+	CallID    int64
+	StartTime time.Time
+}
+
+// ResolveCallRef fills in ref.StartTime from call_index when it isn't set.
+// Returns "call not found" if the call_id is unknown.
+func (db *DB) ResolveCallRef(ctx context.Context, ref CallRef) (CallRef, error) {
+	if !ref.StartTime.IsZero() {
+		return ref, nil
+	}
+	err := db.Pool.QueryRow(ctx,
+		`SELECT start_time FROM call_index WHERE call_id = $1`, ref.CallID,
+	).Scan(&ref.StartTime)
+	if err == pgx.ErrNoRows {
+		return ref, fmt.Errorf("call not found")
+	}
+	return ref, err
+}
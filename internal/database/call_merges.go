@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CallMerge records a split call rejoined to the call it continues. The
+// merged (continuation) call has calls.merged_into = CallID while the merge
+// is in effect.
+type CallMerge struct {
+	ID              int64      `json:"id"`
+	SystemID        int        `json:"system_id"`
+	Tgid            int        `json:"tgid"`
+	UnitID          int        `json:"unit_id"`
+	CallID          int64      `json:"call_id"`
+	CallStartTime   time.Time  `json:"call_start_time"`
+	MergedCallID    int64      `json:"merged_call_id"`
+	MergedStartTime time.Time  `json:"merged_start_time"`
+	Gap             float32    `json:"gap"` // seconds between the preceding call's stop and the merged call's start
+	MergedAt        time.Time  `json:"merged_at"`
+	UndoneAt        *time.Time `json:"undone_at,omitempty"`
+	UndoneBy        string     `json:"undone_by,omitempty"`
+}
+
+// SplitPredecessor is the call a new call may continue.
+type SplitPredecessor struct {
+	CallID    int64     // the call to merge into: the predecessor, or what it was itself merged into
+	StartTime time.Time // start_time of CallID
+	StopTime  time.Time // stop_time of the immediate predecessor, for the gap
+}
+
+// FindSplitPredecessor returns the call that a call (callID, start) on
+// tgid initiated by unitID continues, if one on the same system and
+// talkgroup, initiated by the same unit, stopped no more than maxGap before
+// start. Returns nil if there is none.
+func (db *DB) FindSplitPredecessor(ctx context.Context, systemID, tgid, unitID int, callID int64, start time.Time, maxGap time.Duration) (*SplitPredecessor, error) {
+	var p SplitPredecessor
+	err := db.Pool.QueryRow(ctx, `
+		SELECT COALESCE(m.call_id, c.call_id), COALESCE(m.call_start_time, c.start_time), c.stop_time
+		FROM calls c
+		LEFT JOIN call_merges m ON m.merged_call_id = c.call_id
+			AND m.merged_start_time = c.start_time AND m.undone_at IS NULL
+		WHERE c.system_id = $1 AND c.tgid = $2
+		  AND c.call_id <> $4
+		  AND c.start_time < $5 AND c.start_time >= $5 - interval '1 hour'
+		  AND c.stop_time BETWEEN $5 - $6::interval AND $5 + interval '1 second'
+		  AND `+initiatingUnitSQL+` = $3
+		ORDER BY c.stop_time DESC
+		LIMIT 1
+	`, systemID, tgid, unitID, callID, start, maxGap).Scan(&p.CallID, &p.StartTime, &p.StopTime)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// InsertCallMerge marks m.MergedCallID as a continuation of m.CallID and
+// records the merge. Returns the merge ID, or 0 if the call was already
+// merged.
+func (db *DB) InsertCallMerge(ctx context.Context, m CallMerge) (int64, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE calls SET merged_into = $3
+		WHERE call_id = $1 AND start_time = $2 AND merged_into IS NULL
+	`, m.MergedCallID, m.MergedStartTime, m.CallID)
+	if err != nil {
+		return 0, fmt.Errorf("mark merged call: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return 0, nil
+	}
+	var id int64
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO call_merges (system_id, tgid, unit_id, call_id, call_start_time,
+			merged_call_id, merged_start_time, gap)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`, m.SystemID, m.Tgid, m.UnitID, m.CallID, m.CallStartTime,
+		m.MergedCallID, m.MergedStartTime, m.Gap).Scan(&id); err != nil {
+		return 0, fmt.Errorf("insert call merge: %w", err)
+	}
+	return id, tx.Commit(ctx)
+}
+
+// UndoCallMerge reverses a merge: the merged call reappears in call lists.
+func (db *DB) UndoCallMerge(ctx context.Context, id int64, by string) (*CallMerge, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		UPDATE call_merges SET undone_at = now(), undone_by = NULLIF($2, '')
+		WHERE id = $1 AND undone_at IS NULL
+		RETURNING `+callMergeColumns, id, by)
+	if err != nil {
+		return nil, err
+	}
+	merges, err := scanCallMerges(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(merges) == 0 {
+		return nil, fmt.Errorf("call merge not found or already undone")
+	}
+	m := merges[0]
+	if _, err := tx.Exec(ctx, `
+		UPDATE calls SET merged_into = NULL
+		WHERE call_id = $1 AND start_time = $2
+	`, m.MergedCallID, m.MergedStartTime); err != nil {
+		return nil, fmt.Errorf("unmark merged call: %w", err)
+	}
+	return &m, tx.Commit(ctx)
+}
+
+// CallMergeFilter selects merges by the merged call's start time.
+type CallMergeFilter struct {
+	SystemIDs     []int
+	Tgids         []int
+	Start         *time.Time
+	End           *time.Time
+	IncludeUndone bool
+	Limit         int
+	Offset        int
+}
+
+const callMergeColumns = `id, system_id, tgid, unit_id, call_id, call_start_time,
+	merged_call_id, merged_start_time, gap, merged_at, undone_at, COALESCE(undone_by, '')`
+
+func scanCallMerges(rows pgx.Rows) ([]CallMerge, error) {
+	defer rows.Close()
+	merges := []CallMerge{}
+	for rows.Next() {
+		var m CallMerge
+		if err := rows.Scan(&m.ID, &m.SystemID, &m.Tgid, &m.UnitID, &m.CallID, &m.CallStartTime,
+			&m.MergedCallID, &m.MergedStartTime, &m.Gap, &m.MergedAt, &m.UndoneAt, &m.UndoneBy); err != nil {
+			return nil, err
+		}
+		merges = append(merges, m)
+	}
+	return merges, rows.Err()
+}
+
+// ListCallMerges returns merges matching the filter, newest first, with the
+// total count.
+func (db *DB) ListCallMerges(ctx context.Context, f CallMergeFilter) ([]CallMerge, int, error) {
+	const where = `
+		WHERE ($1::int[] IS NULL OR system_id = ANY($1))
+		  AND ($2::int[] IS NULL OR tgid = ANY($2))
+		  AND ($3::timestamptz IS NULL OR merged_start_time >= $3)
+		  AND ($4::timestamptz IS NULL OR merged_start_time < $4)
+		  AND ($5::boolean OR undone_at IS NULL)`
+	args := []any{pqIntArray(f.SystemIDs), pqIntArray(f.Tgids), f.Start, f.End, f.IncludeUndone}
+
+	var total int
+	if err := db.Pool.QueryRow(ctx, `SELECT count(*) FROM call_merges`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := db.Pool.Query(ctx, `SELECT `+callMergeColumns+` FROM call_merges`+where+`
+		ORDER BY merged_start_time DESC, id DESC
+		LIMIT $6 OFFSET $7`, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	merges, err := scanCallMerges(rows)
+	return merges, total, err
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CallPin marks a call an operator wants kept (PUT /calls/{id}/pin). With
+// AUDIO_RETENTION_KEEP_PINNED, retention skips pinned calls and their audio.
+type CallPin struct {
+	CallID    int64     `json:"call_id"`
+	StartTime time.Time `json:"call_start_time"`
+	SystemID  int       `json:"system_id"`
+	Tgid      int       `json:"tgid"`
+	Duration  *float32  `json:"duration,omitempty"`
+	HasAudio  bool      `json:"has_audio"`
+	Note      string    `json:"note,omitempty"`
+	PinnedBy  string    `json:"pinned_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// callPinnedSQL is true when the call row alias is pinned.
+func callPinnedSQL(alias string) string {
+	return `EXISTS (SELECT 1 FROM call_pins cp WHERE cp.call_id = ` + alias + `.call_id AND cp.call_start_time = ` + alias + `.start_time)`
+}
+
+// retainedCallSQL is true when retention must keep the call row alias: it
+// is under a legal hold or, with keepPinned, pinned.
+func retainedCallSQL(alias string, keepPinned bool) string {
+	if !keepPinned {
+		return legalHoldSQL(alias)
+	}
+	return `(` + legalHoldSQL(alias) + ` OR ` + callPinnedSQL(alias) + `)`
+}
+
+const callPinSelect = `
+	SELECT p.call_id, p.call_start_time, c.system_id, c.tgid, c.duration,
+		c.audio_file_path IS NOT NULL, p.note, p.pinned_by, p.created_at
+	FROM call_pins p
+	JOIN calls c ON c.call_id = p.call_id AND c.start_time = p.call_start_time`
+
+// PinCall pins the call ref (resolved, so StartTime is the call's), or
+// updates the note of an existing pin, and returns the pin.
+func (db *DB) PinCall(ctx context.Context, ref CallRef, note, pinnedBy string) (*CallPin, error) {
+	if _, err := db.Pool.Exec(ctx, `
+		INSERT INTO call_pins (call_id, call_start_time, note, pinned_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (call_id, call_start_time) DO UPDATE SET note = $3
+	`, ref.CallID, ref.StartTime, note, pinnedBy); err != nil {
+		return nil, err
+	}
+	var p CallPin
+	err := db.Pool.QueryRow(ctx, callPinSelect+`
+		WHERE p.call_id = $1 AND p.call_start_time = $2
+	`, ref.CallID, ref.StartTime).Scan(&p.CallID, &p.StartTime, &p.SystemID, &p.Tgid, &p.Duration,
+		&p.HasAudio, &p.Note, &p.PinnedBy, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// UnpinCall removes a call's pin. Returns "call is not pinned" if it has none.
+func (db *DB) UnpinCall(ctx context.Context, ref CallRef) error {
+	tag, err := db.Pool.Exec(ctx,
+		`DELETE FROM call_pins WHERE call_id = $1 AND call_start_time = $2`, ref.CallID, ref.StartTime)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("call is not pinned")
+	}
+	return nil
+}
+
+// ListCallPins returns pinned calls, most recently pinned first, and the
+// total number of pins.
+func (db *DB) ListCallPins(ctx context.Context, limit, offset int) ([]CallPin, int, error) {
+	var total int
+	if err := db.Pool.QueryRow(ctx, `SELECT count(*) FROM call_pins`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := db.Pool.Query(ctx, callPinSelect+`
+		ORDER BY p.created_at DESC, p.call_id DESC
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	pins := []CallPin{}
+	for rows.Next() {
+		var p CallPin
+		if err := rows.Scan(&p.CallID, &p.StartTime, &p.SystemID, &p.Tgid, &p.Duration,
+			&p.HasAudio, &p.Note, &p.PinnedBy, &p.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		pins = append(pins, p)
+	}
+	return pins, total, rows.Err()
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RestampScope selects the calls RestampCalls re-stamps. SystemID 0 is every
+// system; Tgids (which need a SystemID) narrow it to those talkgroups.
+type RestampScope struct {
+	SystemID int
+	Tgids    []int
+}
+
+func (s RestampScope) systemID() any {
+	if s.SystemID == 0 {
+		return nil
+	}
+	return s.SystemID
+}
+
+// RestampCounts counts the rows a re-stamp changed.
+type RestampCounts struct {
+	Calls         int64 `json:"calls"`         // tg_* columns
+	CallGroups    int64 `json:"call_groups"`   // tg_* columns
+	SrcLists      int64 `json:"src_lists"`     // unit tags in calls.src_list
+	Transmissions int64 `json:"transmissions"` // call_transmissions.tag
+}
+
+// Add accumulates o into c.
+func (c *RestampCounts) Add(o RestampCounts) {
+	c.Calls += o.Calls
+	c.CallGroups += o.CallGroups
+	c.SrcLists += o.SrcLists
+	c.Transmissions += o.Transmissions
+}
+
+// CallStartBounds returns the earliest and latest start_time of calls in
+// scope; both nil when there are none.
+func (db *DB) CallStartBounds(ctx context.Context, scope RestampScope) (first, last *time.Time, err error) {
+	err = db.Pool.QueryRow(ctx, `
+		SELECT min(start_time), max(start_time) FROM calls
+		WHERE ($1::int IS NULL OR system_id = $1)
+		  AND ($2::int[] IS NULL OR tgid = ANY($2))
+	`, scope.systemID(), pqIntArray(scope.Tgids)).Scan(&first, &last)
+	return first, last, err
+}
+
+// RestampCalls copies the current talkgroup names (alpha_tag, description,
+// tag, group) onto calls and call groups that started in [from, to), and the
+// current unit alpha tags onto their src_list entries and transmissions. Names
+// ingest stamped are only replaced, never cleared: a talkgroup or unit with no
+// name now leaves the stamped one in place. Rows that already match are not
+// written, so re-running a range is cheap.
+func (db *DB) RestampCalls(ctx context.Context, scope RestampScope, from, to time.Time) (RestampCounts, error) {
+	var n RestampCounts
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return n, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	args := []any{from, to, scope.systemID(), pqIntArray(scope.Tgids)}
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE calls c SET
+			tg_alpha_tag   = COALESCE(NULLIF(t.alpha_tag, ''), c.tg_alpha_tag),
+			tg_description = COALESCE(NULLIF(t.description, ''), c.tg_description),
+			tg_tag         = COALESCE(NULLIF(t.tag, ''), c.tg_tag),
+			tg_group       = COALESCE(NULLIF(t."group", ''), c.tg_group)
+		FROM talkgroups t
+		WHERE t.system_id = c.system_id AND t.tgid = c.tgid
+		  AND c.start_time >= $1 AND c.start_time < $2
+		  AND ($3::int IS NULL OR c.system_id = $3)
+		  AND ($4::int[] IS NULL OR c.tgid = ANY($4))
+		  AND (c.tg_alpha_tag, c.tg_description, c.tg_tag, c.tg_group) IS DISTINCT FROM (
+			COALESCE(NULLIF(t.alpha_tag, ''), c.tg_alpha_tag),
+			COALESCE(NULLIF(t.description, ''), c.tg_description),
+			COALESCE(NULLIF(t.tag, ''), c.tg_tag),
+			COALESCE(NULLIF(t."group", ''), c.tg_group))
+	`, args...)
+	if err != nil {
+		return n, fmt.Errorf("restamp calls: %w", err)
+	}
+	n.Calls = tag.RowsAffected()
+
+	tag, err = tx.Exec(ctx, `
+		UPDATE call_groups g SET
+			tg_alpha_tag   = COALESCE(NULLIF(t.alpha_tag, ''), g.tg_alpha_tag),
+			tg_description = COALESCE(NULLIF(t.description, ''), g.tg_description),
+			tg_tag         = COALESCE(NULLIF(t.tag, ''), g.tg_tag),
+			tg_group       = COALESCE(NULLIF(t."group", ''), g.tg_group),
+			updated_at     = now()
+		FROM talkgroups t
+		WHERE t.system_id = g.system_id AND t.tgid = g.tgid
+		  AND g.start_time >= $1 AND g.start_time < $2
+		  AND ($3::int IS NULL OR g.system_id = $3)
+		  AND ($4::int[] IS NULL OR g.tgid = ANY($4))
+		  AND (g.tg_alpha_tag, g.tg_description, g.tg_tag, g.tg_group) IS DISTINCT FROM (
+			COALESCE(NULLIF(t.alpha_tag, ''), g.tg_alpha_tag),
+			COALESCE(NULLIF(t.description, ''), g.tg_description),
+			COALESCE(NULLIF(t.tag, ''), g.tg_tag),
+			COALESCE(NULLIF(t."group", ''), g.tg_group))
+	`, args...)
+	if err != nil {
+		return n, fmt.Errorf("restamp call groups: %w", err)
+	}
+	n.CallGroups = tag.RowsAffected()
+
+	tag, err = tx.Exec(ctx, `
+		UPDATE calls c SET src_list = (
+			SELECT jsonb_agg(CASE WHEN COALESCE(u.alpha_tag, '') <> ''
+			                      THEN e.elem || jsonb_build_object('tag', u.alpha_tag)
+			                      ELSE e.elem END ORDER BY e.ord)
+			FROM jsonb_array_elements(c.src_list) WITH ORDINALITY AS e(elem, ord)
+			LEFT JOIN units u ON u.system_id = c.system_id AND u.unit_id = (e.elem->>'src')::int
+		)
+		WHERE c.start_time >= $1 AND c.start_time < $2
+		  AND ($3::int IS NULL OR c.system_id = $3)
+		  AND ($4::int[] IS NULL OR c.tgid = ANY($4))
+		  AND jsonb_typeof(c.src_list) = 'array'
+		  AND EXISTS (
+			SELECT 1 FROM jsonb_array_elements(c.src_list) e
+			JOIN units u ON u.system_id = c.system_id AND u.unit_id = (e->>'src')::int
+			WHERE COALESCE(u.alpha_tag, '') <> '' AND e->>'tag' IS DISTINCT FROM u.alpha_tag)
+	`, args...)
+	if err != nil {
+		return n, fmt.Errorf("restamp src_list: %w", err)
+	}
+	n.SrcLists = tag.RowsAffected()
+
+	tag, err = tx.Exec(ctx, `
+		UPDATE call_transmissions ct SET tag = u.alpha_tag
+		FROM calls c, units u
+		WHERE ct.call_start_time >= $1 AND ct.call_start_time < $2
+		  AND c.call_id = ct.call_id AND c.start_time = ct.call_start_time
+		  AND ($3::int IS NULL OR c.system_id = $3)
+		  AND ($4::int[] IS NULL OR c.tgid = ANY($4))
+		  AND u.system_id = c.system_id AND u.unit_id = ct.src
+		  AND COALESCE(u.alpha_tag, '') <> '' AND ct.tag IS DISTINCT FROM u.alpha_tag
+	`, args...)
+	if err != nil {
+		return n, fmt.Errorf("restamp transmissions: %w", err)
+	}
+	n.Transmissions = tag.RowsAffected()
+
+	if err := tx.Commit(ctx); err != nil {
+		return n, fmt.Errorf("commit: %w", err)
+	}
+	return n, nil
+}
@@ -32,6 +32,7 @@ type CallExport struct {
 	Conventional  bool
 	Encrypted     bool
 	Emergency     bool
+	Interconnect  bool
 	PatchedTgids  []int32
 	SrcList       json.RawMessage
 	FreqList      json.RawMessage
@@ -55,7 +56,7 @@ func (db *DB) ExportCalls(ctx context.Context, systemIDs []int, start, end *time
 			audio_file_size, COALESCE(phase2_tdma, false), tdma_slot,
 			COALESCE(analog, false), COALESCE(conventional, false),
 			COALESCE(encrypted, false), COALESCE(emergency, false),
-			patched_tgids, src_list, freq_list, unit_ids,
+			COALESCE(interconnect, false), patched_tgids, src_list, freq_list, unit_ids,
 			metadata_json, ` + incidentCol + `, COALESCE(instance_id, '')
 		FROM calls
 		WHERE ($1::int[] IS NULL OR system_id = ANY($1))
@@ -86,7 +87,7 @@ func (db *DB) ExportCalls(ctx context.Context, systemIDs []int, start, end *time
 			&c.AudioType, &c.AudioFilePath,
 			&c.AudioFileSize, &c.Phase2TDMA, &c.TDMASlot,
 			&c.Analog, &c.Conventional, &c.Encrypted, &c.Emergency,
-			&c.PatchedTgids, &c.SrcList, &c.FreqList, &c.UnitIDs,
+			&c.Interconnect, &c.PatchedTgids, &c.SrcList, &c.FreqList, &c.UnitIDs,
 			&c.MetadataJSON, &c.IncidentData, &c.InstanceID,
 		); err != nil {
 			return nil, err
@@ -282,17 +283,6 @@ func (db *DB) UpdateCallStartFields(ctx context.Context, callID int64, startTime
 	})
 }
 
-// UpdateCallAudio updates a call with audio file path and size.
-func (db *DB) UpdateCallAudio(ctx context.Context, callID int64, startTime time.Time, audioPath string, audioSize int) error {
-	as := int32(audioSize)
-	return db.Q.UpdateCallAudio(ctx, sqlcdb.UpdateCallAudioParams{
-		CallID:        callID,
-		StartTime:     pgtz(startTime),
-		AudioFilePath: &audioPath,
-		AudioFileSize: &as,
-	})
-}
-
 // UpdateCallFilename sets the call_filename field (TR's original audio file path).
 func (db *DB) UpdateCallFilename(ctx context.Context, callID int64, startTime time.Time, callFilename string) error {
 	return db.Q.UpdateCallFilename(ctx, sqlcdb.UpdateCallFilenameParams{
@@ -418,10 +408,21 @@ func (db *DB) InsertActiveCallCheckpoint(ctx context.Context, instanceID string,
 }
 
 // PurgeStaleCalls deletes RECORDING calls older than maxAge that never received
-// audio or a call_end. These are orphaned call_start records. Returns the number deleted.
+// audio or a call_end. These are orphaned call_start records. Calls under a
+// legal hold are kept. Returns the number deleted.
 func (db *DB) PurgeStaleCalls(ctx context.Context, maxAge time.Duration) (int64, error) {
-	cutoff := time.Now().Add(-maxAge)
-	return db.Q.PurgeStaleCalls(ctx, pgtz(cutoff))
+	tag, err := db.Pool.Exec(ctx, `
+		DELETE FROM calls c
+		WHERE c.rec_state_type = 'RECORDING'
+		  AND c.audio_file_path IS NULL
+		  AND (c.stop_time IS NULL OR c.duration IS NULL OR c.duration = 0)
+		  AND c.start_time < $1
+		  AND NOT `+legalHoldSQL("c"),
+		time.Now().Add(-maxAge))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
 }
 
 // PurgeOrphanCallGroups deletes call_groups with no remaining calls. Returns count deleted.
@@ -465,8 +466,12 @@ func (db *DB) FindCallFuzzy(ctx context.Context, systemID, tgid int, startTime t
 
 // GetCallAudioPath returns the audio file path and call_filename for a call.
 // audio_file_path is the tr-engine managed path; call_filename is TR's original absolute path.
-func (db *DB) GetCallAudioPath(ctx context.Context, callID int64) (audioPath string, callFilename string, err error) {
-	row, err := db.Q.GetCallAudioPath(ctx, callID)
+func (db *DB) GetCallAudioPath(ctx context.Context, ref CallRef) (audioPath string, callFilename string, err error) {
+	ref, err = db.ResolveCallRef(ctx, ref)
+	if err != nil {
+		return "", "", err
+	}
+	row, err := db.Q.GetCallAudioPath(ctx, sqlcdb.GetCallAudioPathParams{CallID: ref.CallID, StartTime: pgtz(ref.StartTime)})
 	if err != nil {
 		return "", "", err
 	}
@@ -474,8 +479,12 @@ func (db *DB) GetCallAudioPath(ctx context.Context, callID int64) (audioPath str
 }
 
 // GetCallFrequencies returns frequency entries for a call by reading the freq_list JSONB column.
-func (db *DB) GetCallFrequencies(ctx context.Context, callID int64) ([]CallFrequencyAPI, error) {
-	raw, err := db.Q.GetCallFreqList(ctx, callID)
+func (db *DB) GetCallFrequencies(ctx context.Context, ref CallRef) ([]CallFrequencyAPI, error) {
+	ref, err := db.ResolveCallRef(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := db.Q.GetCallFreqList(ctx, sqlcdb.GetCallFreqListParams{CallID: ref.CallID, StartTime: pgtz(ref.StartTime)})
 	if err != nil {
 		return nil, err
 	}
@@ -494,8 +503,12 @@ func (db *DB) GetCallFrequencies(ctx context.Context, callID int64) ([]CallFrequ
 }
 
 // GetCallTransmissions returns transmission entries for a call by reading the src_list JSONB column.
-func (db *DB) GetCallTransmissions(ctx context.Context, callID int64) ([]CallTransmissionAPI, error) {
-	raw, err := db.Q.GetCallSrcList(ctx, callID)
+func (db *DB) GetCallTransmissions(ctx context.Context, ref CallRef) ([]CallTransmissionAPI, error) {
+	ref, err := db.ResolveCallRef(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := db.Q.GetCallSrcList(ctx, sqlcdb.GetCallSrcListParams{CallID: ref.CallID, StartTime: pgtz(ref.StartTime)})
 	if err != nil {
 		return nil, err
 	}
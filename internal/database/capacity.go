@@ -0,0 +1,376 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Control channel messages counted by the capacity report. Grants are initial
+// voice channel grants (not updates); denies and queued responses are the
+// site refusing or holding a request, queued usually meaning all channels
+// were busy.
+const (
+	capacityGrantCond  = `tm.trunk_msg_type IN ('GRANT', 'UU_V_GRANT')`
+	capacityDenyCond   = `(tm.opcode_type ILIKE '%DENY%' OR tm.opcode_desc ILIKE '%deny%')`
+	capacityQueuedCond = `(tm.opcode_type ILIKE '%QUE%RSP%' OR tm.opcode_desc ILIKE '%queue%')`
+)
+
+// capacityBusyStates are recorder states that mean the recorder is assigned
+// to a call.
+const capacityBusyStates = `('RECORDING', 'IDLE', 'ACTIVE')`
+
+// CapacityFilter specifies the window and scope of the capacity report.
+type CapacityFilter struct {
+	SystemIDs []int
+	SiteIDs   []int
+	StartTime time.Time
+	EndTime   time.Time
+	Timezone  string // for hour_of_day; default UTC
+}
+
+// CapacityHour is one site's traffic in one clock hour. Erlangs is call
+// airtime in the hour divided by 3600 (calls are counted in the hour they
+// started); utilization is erlangs per observed voice channel.
+type CapacityHour struct {
+	Hour        time.Time `json:"hour"`
+	HourOfDay   int       `json:"hour_of_day"`
+	Calls       int       `json:"calls"`
+	Erlangs     float64   `json:"erlangs"`
+	Utilization *float64  `json:"utilization,omitempty"`
+	Grants      int       `json:"grants"`
+	Denies      int       `json:"denies"`
+	Queued      int       `json:"queued"`
+	DenyPct     *float64  `json:"deny_pct,omitempty"`
+}
+
+// BusyHour is an hour of the day averaged across the window. The busiest is
+// the site's time-consistent busy hour.
+type BusyHour struct {
+	HourOfDay   int      `json:"hour_of_day"`
+	Erlangs     float64  `json:"erlangs"`
+	Utilization *float64 `json:"utilization,omitempty"`
+	DenyPct     *float64 `json:"deny_pct,omitempty"`
+}
+
+// SiteCapacity is the capacity report for one site. Channels is the number of
+// distinct voice frequencies seen carrying calls in the window.
+type SiteCapacity struct {
+	SystemID      int            `json:"system_id"`
+	SystemName    string         `json:"system_name,omitempty"`
+	SiteID        int            `json:"site_id"`
+	SiteShortName string         `json:"site_short_name,omitempty"`
+	Channels      int            `json:"channels"`
+	Calls         int            `json:"calls"`
+	Erlangs       float64        `json:"erlangs"` // average over the window
+	Utilization   *float64       `json:"utilization,omitempty"`
+	Grants        int            `json:"grants"`
+	Denies        int            `json:"denies"`
+	Queued        int            `json:"queued"`
+	DenyPct       *float64       `json:"deny_pct,omitempty"`
+	PeakHour      *CapacityHour  `json:"peak_hour,omitempty"`
+	BusyHours     []BusyHour     `json:"busy_hours"` // top 3 hours of the day
+	Hours         []CapacityHour `json:"hours"`
+}
+
+// RecorderHour is one trunk-recorder instance's recorder use in one hour:
+// the share of recorder reports showing a recorder assigned to a call.
+type RecorderHour struct {
+	Hour        time.Time `json:"hour"`
+	Recorders   int       `json:"recorders"`
+	Utilization float64   `json:"utilization"`
+}
+
+// RecorderCapacity summarizes one instance's recorder utilization.
+type RecorderCapacity struct {
+	InstanceID      string         `json:"instance_id"`
+	Recorders       int            `json:"recorders"`
+	Utilization     float64        `json:"utilization"` // average of the hours
+	PeakUtilization float64        `json:"peak_utilization"`
+	PeakHour        *time.Time     `json:"peak_hour,omitempty"`
+	Hours           []RecorderHour `json:"hours"`
+}
+
+// GetSiteCapacity returns per-site hourly traffic and control channel grant
+// and deny counts for the window, busiest sites first.
+func (db *DB) GetSiteCapacity(ctx context.Context, f CapacityFilter) ([]SiteCapacity, error) {
+	tz := f.Timezone
+	if tz == "" {
+		tz = "UTC"
+	}
+	args := []any{f.StartTime, f.EndTime, pqIntArray(f.SystemIDs), pqIntArray(f.SiteIDs), tz}
+
+	sites := map[int]*SiteCapacity{}
+	hours := map[int]map[time.Time]*CapacityHour{}
+	hourOf := func(siteID int, h time.Time, hourOfDay int) *CapacityHour {
+		if hours[siteID] == nil {
+			hours[siteID] = map[time.Time]*CapacityHour{}
+		}
+		ch := hours[siteID][h]
+		if ch == nil {
+			ch = &CapacityHour{Hour: h, HourOfDay: hourOfDay}
+			hours[siteID][h] = ch
+		}
+		return ch
+	}
+
+	// Sites in scope and their channels; those without activity are dropped below
+	rows, err := db.Pool.Query(ctx, `
+		SELECT st.site_id, st.system_id, COALESCE(sy.name, ''), st.short_name,
+			(SELECT count(DISTINCT c.freq)::int FROM calls c
+			 WHERE c.site_id = st.site_id AND c.freq > 0
+			   AND c.start_time >= $1 AND c.start_time < $2)
+		FROM sites st
+		JOIN systems sy ON sy.system_id = st.system_id
+		WHERE ($3::int[] IS NULL OR st.system_id = ANY($3))
+		  AND ($4::int[] IS NULL OR st.site_id = ANY($4))
+	`, args[:4]...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		s := &SiteCapacity{}
+		if err := rows.Scan(&s.SiteID, &s.SystemID, &s.SystemName, &s.SiteShortName, &s.Channels); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		sites[s.SiteID] = s
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Call traffic per site per hour
+	rows, err = db.Pool.Query(ctx, `
+		WITH t AS (
+			SELECT c.site_id, date_trunc('hour', c.start_time) AS hour,
+				count(*)::int AS calls, COALESCE(sum(c.duration), 0)::float8 AS airtime
+			FROM calls c
+			WHERE c.site_id IS NOT NULL
+			  AND c.start_time >= $1 AND c.start_time < $2
+			  AND ($3::int[] IS NULL OR c.system_id = ANY($3))
+			  AND ($4::int[] IS NULL OR c.site_id = ANY($4))
+			GROUP BY 1, 2
+		)
+		SELECT site_id, hour, extract(hour FROM hour AT TIME ZONE $5)::int, calls, airtime FROM t
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var siteID, hourOfDay, calls int
+		var h time.Time
+		var airtime float64
+		if err := rows.Scan(&siteID, &h, &hourOfDay, &calls, &airtime); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ch := hourOf(siteID, h, hourOfDay)
+		ch.Calls = calls
+		ch.Erlangs = airtime / 3600
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Grants, denies and queued responses per site per hour
+	rows, err = db.Pool.Query(ctx, `
+		WITH t AS (
+			SELECT st.site_id, date_trunc('hour', tm."time") AS hour,
+				count(*) FILTER (WHERE `+capacityGrantCond+`)::int AS grants,
+				count(*) FILTER (WHERE `+capacityDenyCond+`)::int AS denies,
+				count(*) FILTER (WHERE `+capacityQueuedCond+`)::int AS queued
+			FROM trunking_messages tm
+			JOIN sites st ON st.instance_id = tm.instance_id AND st.short_name = tm.sys_name
+			WHERE tm."time" >= $1 AND tm."time" < $2
+			  AND ($3::int[] IS NULL OR st.system_id = ANY($3))
+			  AND ($4::int[] IS NULL OR st.site_id = ANY($4))
+			  AND (`+capacityGrantCond+` OR `+capacityDenyCond+` OR `+capacityQueuedCond+`)
+			GROUP BY 1, 2
+		)
+		SELECT site_id, hour, extract(hour FROM hour AT TIME ZONE $5)::int, grants, denies, queued FROM t
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var siteID, hourOfDay, grants, denies, queued int
+		var h time.Time
+		if err := rows.Scan(&siteID, &h, &hourOfDay, &grants, &denies, &queued); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ch := hourOf(siteID, h, hourOfDay)
+		ch.Grants, ch.Denies, ch.Queued = grants, denies, queued
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	windowHours := f.EndTime.Sub(f.StartTime).Hours()
+	result := []SiteCapacity{}
+	for siteID, s := range sites {
+		if len(hours[siteID]) == 0 {
+			continue
+		}
+		for _, ch := range hours[siteID] {
+			s.Hours = append(s.Hours, *ch)
+		}
+		summarizeSiteCapacity(s, windowHours)
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Erlangs != result[j].Erlangs {
+			return result[i].Erlangs > result[j].Erlangs
+		}
+		return result[i].SiteID < result[j].SiteID
+	})
+	return result, nil
+}
+
+// summarizeSiteCapacity fills a site's totals, peak hour and busy hours from
+// its Hours. windowHours is the length of the report window.
+func summarizeSiteCapacity(s *SiteCapacity, windowHours float64) {
+	sort.Slice(s.Hours, func(i, j int) bool { return s.Hours[i].Hour.Before(s.Hours[j].Hour) })
+
+	perChannel := func(erlangs float64) *float64 {
+		if s.Channels == 0 {
+			return nil
+		}
+		u := erlangs / float64(s.Channels)
+		return &u
+	}
+
+	type hodTotal struct {
+		erlangs                float64
+		grants, denies, queued int
+	}
+	var byHour [24]hodTotal
+	var airtime float64
+	for i := range s.Hours {
+		h := &s.Hours[i]
+		h.Utilization = perChannel(h.Erlangs)
+		h.DenyPct = denyPct(h.Grants, h.Denies)
+
+		s.Calls += h.Calls
+		s.Grants += h.Grants
+		s.Denies += h.Denies
+		s.Queued += h.Queued
+		airtime += h.Erlangs
+
+		t := &byHour[h.HourOfDay%24]
+		t.erlangs += h.Erlangs
+		t.grants += h.Grants
+		t.denies += h.Denies
+		t.queued += h.Queued
+
+		if s.PeakHour == nil || h.Erlangs > s.PeakHour.Erlangs {
+			s.PeakHour = h
+		}
+	}
+	if s.PeakHour != nil {
+		peak := *s.PeakHour
+		s.PeakHour = &peak
+	}
+
+	if windowHours < 1 {
+		windowHours = 1
+	}
+	s.Erlangs = airtime / windowHours
+	s.Utilization = perChannel(s.Erlangs)
+	s.DenyPct = denyPct(s.Grants, s.Denies)
+
+	// Busy hours: each hour of the day averaged over the days in the window
+	days := windowHours / 24
+	if days < 1 {
+		days = 1
+	}
+	busy := make([]BusyHour, 0, 24)
+	for hod, t := range byHour {
+		if t.erlangs == 0 && t.grants+t.denies == 0 {
+			continue
+		}
+		e := t.erlangs / days
+		busy = append(busy, BusyHour{HourOfDay: hod, Erlangs: e, Utilization: perChannel(e), DenyPct: denyPct(t.grants, t.denies)})
+	}
+	sort.SliceStable(busy, func(i, j int) bool { return busy[i].Erlangs > busy[j].Erlangs })
+	if len(busy) > 3 {
+		busy = busy[:3]
+	}
+	s.BusyHours = busy
+}
+
+// denyPct is denies as a percentage of channel requests (grants + denies),
+// or nil when there were none.
+func denyPct(grants, denies int) *float64 {
+	if grants+denies == 0 {
+		return nil
+	}
+	p := 100 * float64(denies) / float64(grants+denies)
+	return &p
+}
+
+// GetRecorderCapacity returns hourly recorder utilization per trunk-recorder
+// instance for the window, busiest first. Instances are limited to those
+// hosting sites in the filter's systems and sites.
+func (db *DB) GetRecorderCapacity(ctx context.Context, f CapacityFilter) ([]RecorderCapacity, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT rs.instance_id, date_trunc('hour', rs."time") AS hour,
+			count(DISTINCT rs.recorder_id)::int,
+			(count(*) FILTER (WHERE rs.rec_state_type IN `+capacityBusyStates+`))::float8 / count(*)
+		FROM recorder_snapshots rs
+		WHERE rs."time" >= $1 AND rs."time" < $2
+		  AND rs.instance_id IS NOT NULL
+		  AND (($3::int[] IS NULL AND $4::int[] IS NULL) OR rs.instance_id IN (
+			SELECT st.instance_id FROM sites st
+			WHERE ($3::int[] IS NULL OR st.system_id = ANY($3))
+			  AND ($4::int[] IS NULL OR st.site_id = ANY($4))))
+		GROUP BY 1, 2
+		ORDER BY 1, 2
+	`, f.StartTime, f.EndTime, pqIntArray(f.SystemIDs), pqIntArray(f.SiteIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byInstance := map[string]*RecorderCapacity{}
+	var order []string
+	for rows.Next() {
+		var id string
+		var h RecorderHour
+		if err := rows.Scan(&id, &h.Hour, &h.Recorders, &h.Utilization); err != nil {
+			return nil, err
+		}
+		rc := byInstance[id]
+		if rc == nil {
+			rc = &RecorderCapacity{InstanceID: id}
+			byInstance[id] = rc
+			order = append(order, id)
+		}
+		rc.Hours = append(rc.Hours, h)
+		rc.Utilization += h.Utilization
+		if h.Recorders > rc.Recorders {
+			rc.Recorders = h.Recorders
+		}
+		if rc.PeakHour == nil || h.Utilization > rc.PeakUtilization {
+			hour := h.Hour
+			rc.PeakHour = &hour
+			rc.PeakUtilization = h.Utilization
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]RecorderCapacity, 0, len(order))
+	for _, id := range order {
+		rc := byInstance[id]
+		rc.Utilization /= float64(len(rc.Hours))
+		result = append(result, *rc)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Utilization > result[j].Utilization })
+	return result, nil
+}
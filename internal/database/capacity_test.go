@@ -0,0 +1,57 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummarizeSiteCapacity(t *testing.T) {
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	at := func(d, h int) time.Time { return day.Add(time.Duration(d*24+h) * time.Hour) }
+
+	s := &SiteCapacity{
+		Channels: 4,
+		Hours: []CapacityHour{
+			{Hour: at(1, 17), HourOfDay: 17, Calls: 300, Erlangs: 3.5, Grants: 290, Denies: 10},
+			{Hour: at(0, 8), HourOfDay: 8, Calls: 100, Erlangs: 1, Grants: 100},
+			{Hour: at(0, 17), HourOfDay: 17, Calls: 250, Erlangs: 2.5, Grants: 240, Denies: 10, Queued: 5},
+			{Hour: at(1, 3), HourOfDay: 3, Calls: 5, Erlangs: 0.1},
+			{Hour: at(1, 12), HourOfDay: 12, Calls: 50, Erlangs: 0.4},
+		},
+	}
+	summarizeSiteCapacity(s, 48)
+
+	if !s.Hours[0].Hour.Equal(at(0, 8)) {
+		t.Errorf("hours not sorted: first = %v", s.Hours[0].Hour)
+	}
+	if s.Calls != 705 || s.Grants != 630 || s.Denies != 20 || s.Queued != 5 {
+		t.Errorf("totals = %d calls, %d grants, %d denies, %d queued", s.Calls, s.Grants, s.Denies, s.Queued)
+	}
+	if s.PeakHour == nil || !s.PeakHour.Hour.Equal(at(1, 17)) || *s.PeakHour.Utilization != 3.5/4 {
+		t.Errorf("peak hour = %+v", s.PeakHour)
+	}
+	if want := 7.5 / 48; s.Erlangs != want {
+		t.Errorf("erlangs = %v, want %v", s.Erlangs, want)
+	}
+	if s.DenyPct == nil || *s.DenyPct != 100*20.0/650 {
+		t.Errorf("deny pct = %v", s.DenyPct)
+	}
+
+	// 17:00 averages (3.5 + 2.5) / 2 days
+	if len(s.BusyHours) != 3 || s.BusyHours[0].HourOfDay != 17 || s.BusyHours[0].Erlangs != 3 {
+		t.Fatalf("busy hours = %+v", s.BusyHours)
+	}
+	if s.BusyHours[1].HourOfDay != 8 || s.BusyHours[2].HourOfDay != 12 {
+		t.Errorf("busy hour order = %+v", s.BusyHours)
+	}
+	if s.Hours[2].DenyPct != nil {
+		t.Error("hour without denies or grants should have no deny pct")
+	}
+
+	// Without known channels there is no utilization
+	s = &SiteCapacity{Hours: []CapacityHour{{Hour: day, Erlangs: 1}}}
+	summarizeSiteCapacity(s, 24)
+	if s.Utilization != nil || s.Hours[0].Utilization != nil {
+		t.Error("utilization reported without channels")
+	}
+}
@@ -0,0 +1,297 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ConfigChange is one setting that differs between two config versions.
+// Old is absent for an added setting and New for a removed one.
+type ConfigChange struct {
+	Path string `json:"path"` // e.g. "sources[0].gain", "systems[butco].channelFile"
+	Old  any    `json:"old,omitempty"`
+	New  any    `json:"new,omitempty"`
+}
+
+// InstanceConfigVersion is one distinct configuration reported by a TR
+// instance. A config message identical to the latest version only bumps its
+// last_seen and times_seen.
+type InstanceConfigVersion struct {
+	InstanceID string          `json:"instance_id"`
+	Version    int             `json:"version"`
+	Hash       string          `json:"hash"`
+	Changes    []ConfigChange  `json:"changes"` // versus the previous version; empty for version 1
+	Config     json.RawMessage `json:"config,omitempty"`
+	FirstSeen  time.Time       `json:"first_seen"`
+	LastSeen   time.Time       `json:"last_seen"`
+	TimesSeen  int             `json:"times_seen"`
+}
+
+// RecordInstanceConfigVersion stores config (the "config" object of a TR
+// config message) as a new version of the instance's configuration when it
+// differs from the latest one, with the changes between them. Returns the
+// new version, or nil when the config is unchanged.
+func (db *DB) RecordInstanceConfigVersion(ctx context.Context, instanceID string, config json.RawMessage, seenAt time.Time) (*InstanceConfigVersion, error) {
+	var parsed any
+	if err := json.Unmarshal(config, &parsed); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	canonical, err := json.Marshal(parsed) // map keys sorted, whitespace dropped
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(canonical)
+	hash := hex.EncodeToString(sum[:])
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialize versions per instance
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('instance_config_versions:' || $1))`, instanceID); err != nil {
+		return nil, err
+	}
+	var prevVersion int
+	var prevHash string
+	var prevConfig []byte
+	err = tx.QueryRow(ctx, `
+		SELECT version, config_hash, config FROM instance_config_versions
+		WHERE instance_id = $1 ORDER BY version DESC LIMIT 1
+	`, instanceID).Scan(&prevVersion, &prevHash, &prevConfig)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	if prevHash == hash {
+		_, err := tx.Exec(ctx, `
+			UPDATE instance_config_versions
+			SET last_seen = GREATEST(last_seen, $3), times_seen = times_seen + 1
+			WHERE instance_id = $1 AND version = $2
+		`, instanceID, prevVersion, seenAt)
+		if err != nil {
+			return nil, err
+		}
+		return nil, tx.Commit(ctx)
+	}
+
+	v := &InstanceConfigVersion{
+		InstanceID: instanceID,
+		Version:    prevVersion + 1,
+		Hash:       hash,
+		Changes:    []ConfigChange{},
+		Config:     canonical,
+		FirstSeen:  seenAt,
+		LastSeen:   seenAt,
+		TimesSeen:  1,
+	}
+	if prevConfig != nil {
+		var prev any
+		if err := json.Unmarshal(prevConfig, &prev); err != nil {
+			return nil, fmt.Errorf("parse previous config: %w", err)
+		}
+		v.Changes = DiffConfig(prev, parsed)
+	}
+	changes, err := json.Marshal(v.Changes)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO instance_config_versions (instance_id, version, config_hash, config, changes, first_seen, last_seen)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+	`, instanceID, v.Version, hash, canonical, changes, seenAt); err != nil {
+		return nil, fmt.Errorf("insert config version: %w", err)
+	}
+	return v, tx.Commit(ctx)
+}
+
+// ListInstanceConfigVersions returns an instance's config versions, newest
+// first, with the total count. The full config is only included when
+// withConfig is set.
+func (db *DB) ListInstanceConfigVersions(ctx context.Context, instanceID string, withConfig bool, limit, offset int) ([]InstanceConfigVersion, int, error) {
+	var total int
+	if err := db.Pool.QueryRow(ctx, `
+		SELECT count(*) FROM instance_config_versions WHERE instance_id = $1
+	`, instanceID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := db.Pool.Query(ctx, `
+		SELECT instance_id, version, config_hash, changes,
+			CASE WHEN $2 THEN config END, first_seen, last_seen, times_seen
+		FROM instance_config_versions
+		WHERE instance_id = $1
+		ORDER BY version DESC
+		LIMIT $3 OFFSET $4
+	`, instanceID, withConfig, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	versions, err := scanConfigVersions(rows)
+	return versions, total, err
+}
+
+// GetInstanceConfigVersion returns one config version with its full config.
+// Returns "config version not found" if it doesn't exist.
+func (db *DB) GetInstanceConfigVersion(ctx context.Context, instanceID string, version int) (*InstanceConfigVersion, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT instance_id, version, config_hash, changes, config, first_seen, last_seen, times_seen
+		FROM instance_config_versions
+		WHERE instance_id = $1 AND version = $2
+	`, instanceID, version)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := scanConfigVersions(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("config version not found")
+	}
+	return &versions[0], nil
+}
+
+func scanConfigVersions(rows pgx.Rows) ([]InstanceConfigVersion, error) {
+	defer rows.Close()
+	versions := []InstanceConfigVersion{}
+	for rows.Next() {
+		var v InstanceConfigVersion
+		var changes, config []byte
+		if err := rows.Scan(&v.InstanceID, &v.Version, &v.Hash, &changes, &config,
+			&v.FirstSeen, &v.LastSeen, &v.TimesSeen); err != nil {
+			return nil, err
+		}
+		v.Changes = []ConfigChange{}
+		if len(changes) > 0 {
+			if err := json.Unmarshal(changes, &v.Changes); err != nil {
+				return nil, fmt.Errorf("parse config changes: %w", err)
+			}
+		}
+		if config != nil {
+			v.Config = config
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// configKeyFields name the fields that identify the elements of an array of
+// objects (systems by short name), so a reordered or inserted system is
+// reported by name rather than as changes to every later index.
+var configKeyFields = []string{"shortName", "short_name", "sys_name"}
+
+// DiffConfig lists the settings that differ between two decoded JSON
+// configs, sorted by path.
+func DiffConfig(old, new any) []ConfigChange {
+	changes := []ConfigChange{}
+	diffValue("", old, new, &changes)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+func diffValue(path string, old, new any, out *[]ConfigChange) {
+	switch o := old.(type) {
+	case map[string]any:
+		if n, ok := new.(map[string]any); ok {
+			for k, ov := range o {
+				if nv, ok := n[k]; ok {
+					diffValue(joinPath(path, k), ov, nv, out)
+				} else {
+					*out = append(*out, ConfigChange{Path: joinPath(path, k), Old: ov})
+				}
+			}
+			for k, nv := range n {
+				if _, ok := o[k]; !ok {
+					*out = append(*out, ConfigChange{Path: joinPath(path, k), New: nv})
+				}
+			}
+			return
+		}
+	case []any:
+		if n, ok := new.([]any); ok {
+			diffArray(path, o, n, out)
+			return
+		}
+	}
+	if !reflect.DeepEqual(old, new) {
+		*out = append(*out, ConfigChange{Path: path, Old: old, New: new})
+	}
+}
+
+// diffArray compares arrays element by element, or by key when every element
+// of both is an object with a distinct configKeyFields value.
+func diffArray(path string, old, new []any, out *[]ConfigChange) {
+	if ok, ov := keyedElements(old); ok {
+		if ok, nv := keyedElements(new); ok {
+			for k, o := range ov {
+				if n, ok := nv[k]; ok {
+					diffValue(path+"["+k+"]", o, n, out)
+				} else {
+					*out = append(*out, ConfigChange{Path: path + "[" + k + "]", Old: o})
+				}
+			}
+			for k, n := range nv {
+				if _, ok := ov[k]; !ok {
+					*out = append(*out, ConfigChange{Path: path + "[" + k + "]", New: n})
+				}
+			}
+			return
+		}
+	}
+	for i := 0; i < len(old) || i < len(new); i++ {
+		p := path + "[" + strconv.Itoa(i) + "]"
+		switch {
+		case i >= len(new):
+			*out = append(*out, ConfigChange{Path: p, Old: old[i]})
+		case i >= len(old):
+			*out = append(*out, ConfigChange{Path: p, New: new[i]})
+		default:
+			diffValue(p, old[i], new[i], out)
+		}
+	}
+}
+
+// keyedElements indexes an array of objects by their configKeyFields value.
+func keyedElements(arr []any) (bool, map[string]any) {
+	if len(arr) == 0 {
+		return false, nil
+	}
+	for _, field := range configKeyFields {
+		byKey := make(map[string]any, len(arr))
+		for _, e := range arr {
+			obj, ok := e.(map[string]any)
+			if !ok {
+				return false, nil
+			}
+			k, ok := obj[field].(string)
+			if !ok || k == "" {
+				break
+			}
+			if _, dup := byKey[k]; dup {
+				break
+			}
+			byKey[k] = e
+		}
+		if len(byKey) == len(arr) {
+			return true, byKey
+		}
+	}
+	return false, nil
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiffConfig(t *testing.T) {
+	decode := func(s string) any {
+		var v any
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	old := decode(`{
+		"capture_dir": "/audio",
+		"sources": [{"driver": "osmosdr", "gain": 32}, {"driver": "osmosdr", "gain": 30}],
+		"systems": [
+			{"shortName": "butco", "channelFile": "butco.csv"},
+			{"shortName": "warco", "channelFile": "warco.csv"}
+		],
+		"debug": true
+	}`)
+	new := decode(`{
+		"capture_dir": "/audio",
+		"sources": [{"driver": "osmosdr", "gain": 40}],
+		"systems": [
+			{"shortName": "hamco", "channelFile": "hamco.csv"},
+			{"shortName": "butco", "channelFile": "butco-v2.csv"},
+			{"shortName": "warco", "channelFile": "warco.csv"}
+		],
+		"call_timeout": 3
+	}`)
+
+	got := DiffConfig(old, new)
+	want := []ConfigChange{
+		{Path: "call_timeout", New: float64(3)},
+		{Path: "debug", Old: true},
+		{Path: "sources[0].gain", Old: float64(32), New: float64(40)},
+		{Path: "sources[1]", Old: map[string]any{"driver": "osmosdr", "gain": float64(30)}},
+		{Path: "systems[butco].channelFile", Old: "butco.csv", New: "butco-v2.csv"},
+		{Path: "systems[hamco]", New: map[string]any{"shortName": "hamco", "channelFile": "hamco.csv"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffConfig =\n%#v\nwant\n%#v", got, want)
+	}
+
+	if got := DiffConfig(old, old); len(got) != 0 {
+		t.Errorf("identical configs: %v", got)
+	}
+}
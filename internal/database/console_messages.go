@@ -11,13 +11,25 @@ import (
 // ConsoleMessageFilter specifies filters for listing console messages.
 type ConsoleMessageFilter struct {
 	InstanceID *string
-	Severity   *string
+	Severities []string // any of these; nil = all
+	Search     *string  // case-insensitive substring of log_msg
 	StartTime  *time.Time
 	EndTime    *time.Time
 	Limit      int
 	Offset     int
 }
 
+const consoleWhereClause = `
+		WHERE ($1::text IS NULL OR cm.instance_id = $1)
+		  AND ($2::text[] IS NULL OR cm.severity = ANY($2))
+		  AND ($3::timestamptz IS NULL OR cm.log_time >= $3)
+		  AND ($4::timestamptz IS NULL OR cm.log_time < $4)
+		  AND ($5::text IS NULL OR cm.log_msg ILIKE '%' || $5 || '%')`
+
+func (f ConsoleMessageFilter) args() []any {
+	return []any{f.InstanceID, f.Severities, f.StartTime, f.EndTime, f.Search}
+}
+
 // ConsoleMessageAPI represents a console message for API responses.
 type ConsoleMessageAPI struct {
 	ID         int64     `json:"id"`
@@ -29,24 +41,19 @@ type ConsoleMessageAPI struct {
 
 // ListConsoleMessages returns console messages matching the filter.
 func (db *DB) ListConsoleMessages(ctx context.Context, filter ConsoleMessageFilter) ([]ConsoleMessageAPI, int, error) {
-	const whereClause = `
-		WHERE ($1::text IS NULL OR cm.instance_id = $1)
-		  AND ($2::text IS NULL OR cm.severity = $2)
-		  AND ($3::timestamptz IS NULL OR cm.log_time >= $3)
-		  AND ($4::timestamptz IS NULL OR cm.log_time < $4)`
-	args := []any{filter.InstanceID, filter.Severity, filter.StartTime, filter.EndTime}
+	args := filter.args()
 
 	var total int
-	if err := db.Pool.QueryRow(ctx, "SELECT count(*) FROM console_messages cm"+whereClause, args...).Scan(&total); err != nil {
+	if err := db.Pool.QueryRow(ctx, "SELECT count(*) FROM console_messages cm"+consoleWhereClause, args...).Scan(&total); err != nil {
 		return nil, 0, err
 	}
 
 	dataQuery := `
 		SELECT cm.id, COALESCE(cm.instance_id, ''), COALESCE(cm.severity, ''),
 			COALESCE(cm.log_msg, ''), cm.log_time
-		FROM console_messages cm` + whereClause + `
+		FROM console_messages cm` + consoleWhereClause + `
 		ORDER BY cm.log_time DESC
-		LIMIT $5 OFFSET $6`
+		LIMIT $6 OFFSET $7`
 
 	rows, err := db.Pool.Query(ctx, dataQuery, append(args, filter.Limit, filter.Offset)...)
 	if err != nil {
@@ -77,3 +84,90 @@ func (db *DB) InsertConsoleMessage(ctx context.Context, instanceID string, logTi
 		MqttTimestamp: pgtype.Timestamptz{Time: mqttTimestamp, Valid: true},
 	})
 }
+
+// consoleTemplateSQL reduces a console message to its template: hex values
+// and numbers (including decimals, times and IP addresses) become "#" and
+// whitespace runs collapse, so "Retune failed 851.0125 MHz" and
+// "Retune failed 852.2 MHz" cluster together.
+const consoleTemplateSQL = `btrim(regexp_replace(regexp_replace(regexp_replace(COALESCE(cm.log_msg, ''),
+	'0x[0-9a-fA-F]+', '#', 'g'),
+	'[0-9]+([.:][0-9]+)*', '#', 'g'),
+	'\s+', ' ', 'g'))`
+
+// ConsoleCluster is a group of console messages sharing one template.
+type ConsoleCluster struct {
+	Template   string          `json:"template"`
+	Count      int             `json:"count"`
+	FirstSeen  time.Time       `json:"first_seen"`
+	LastSeen   time.Time       `json:"last_seen"`
+	Severities []string        `json:"severities"`
+	Instances  []string        `json:"instances"`
+	Sample     string          `json:"sample"` // most recent message
+	Buckets    []ConsoleBucket `json:"buckets"`
+}
+
+// ConsoleBucket is a cluster's message count in one time bucket.
+type ConsoleBucket struct {
+	Time  time.Time `json:"time"`
+	Count int       `json:"count"`
+}
+
+// ConsoleClusters groups the console messages matching filter by template,
+// most frequent first, with counts per bucket (aligned to StartTime, which
+// must be set). Returns at most filter.Limit clusters and the total number
+// of clusters.
+func (db *DB) ConsoleClusters(ctx context.Context, filter ConsoleMessageFilter, bucket time.Duration) ([]ConsoleCluster, int, error) {
+	query := `
+		WITH m AS (
+			SELECT ` + consoleTemplateSQL + ` AS template,
+				COALESCE(cm.severity, '') AS severity, COALESCE(cm.instance_id, '') AS instance_id,
+				COALESCE(cm.log_msg, '') AS log_msg, cm.log_time
+			FROM console_messages cm` + consoleWhereClause + `
+		),
+		top AS (
+			SELECT template, count(*) AS n, min(log_time) AS first_seen, max(log_time) AS last_seen,
+				array_agg(DISTINCT severity) AS severities,
+				array_agg(DISTINCT instance_id) AS instances,
+				(array_agg(log_msg ORDER BY log_time DESC))[1] AS sample,
+				count(*) OVER () AS total
+			FROM m
+			GROUP BY template
+			ORDER BY n DESC, template
+			LIMIT $6
+		),
+		b AS (
+			SELECT m.template, date_bin($7::interval, m.log_time, $3::timestamptz) AS bucket, count(*) AS n
+			FROM m JOIN top USING (template)
+			GROUP BY 1, 2
+		)
+		SELECT top.template, top.n, top.first_seen, top.last_seen, top.severities, top.instances,
+			top.sample, top.total,
+			(SELECT array_agg(b.bucket ORDER BY b.bucket) FROM b WHERE b.template = top.template),
+			(SELECT array_agg(b.n ORDER BY b.bucket) FROM b WHERE b.template = top.template)
+		FROM top
+		ORDER BY top.n DESC, top.template`
+
+	rows, err := db.Pool.Query(ctx, query, append(filter.args(), filter.Limit, bucket)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	clusters := []ConsoleCluster{}
+	total := 0
+	for rows.Next() {
+		var c ConsoleCluster
+		var times []time.Time
+		var counts []int
+		if err := rows.Scan(&c.Template, &c.Count, &c.FirstSeen, &c.LastSeen, &c.Severities, &c.Instances,
+			&c.Sample, &total, &times, &counts); err != nil {
+			return nil, 0, err
+		}
+		c.Buckets = make([]ConsoleBucket, len(times))
+		for i := range times {
+			c.Buckets[i] = ConsoleBucket{Time: times[i], Count: counts[i]}
+		}
+		clusters = append(clusters, c)
+	}
+	return clusters, total, rows.Err()
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ControlChannelChange is one control channel frequency recorded for a
+// site. Until is when the site moved to the next channel (nil = current).
+type ControlChannelChange struct {
+	ID         int64      `json:"id"`
+	SiteID     int        `json:"site_id"`
+	SystemID   int        `json:"system_id"`
+	ShortName  string     `json:"short_name"`
+	InstanceID string     `json:"instance_id"`
+	Freq       int64      `json:"freq"`
+	PrevFreq   *int64     `json:"prev_freq"`
+	Source     string     `json:"source"`
+	Time       time.Time  `json:"time"`
+	Until      *time.Time `json:"until"`
+	Duration   *float64   `json:"duration,omitempty"` // seconds on this channel; nil while current
+}
+
+// ControlChannelFilter selects control channel history.
+type ControlChannelFilter struct {
+	SystemIDs []int
+	SiteIDs   []int
+	Start     *time.Time
+	End       *time.Time
+	Limit     int
+}
+
+// InsertControlChannelChange records that a site's control channel is now
+// freq. prevFreq is 0 for the first channel recorded for the site.
+func (db *DB) InsertControlChannelChange(ctx context.Context, siteID int, freq, prevFreq int64, source string, t time.Time) error {
+	var prev *int64
+	if prevFreq > 0 {
+		prev = &prevFreq
+	}
+	_, err := db.Pool.Exec(ctx, `
+		INSERT INTO control_channel_history (site_id, freq, prev_freq, source, "time")
+		VALUES ($1, $2, $3, $4, $5)
+	`, siteID, freq, prev, source, t)
+	return err
+}
+
+// LastControlChannel returns the most recently recorded control channel for
+// a site, or 0 if none has been recorded.
+func (db *DB) LastControlChannel(ctx context.Context, siteID int) (int64, error) {
+	var freq int64
+	err := db.Pool.QueryRow(ctx, `
+		SELECT freq FROM control_channel_history
+		WHERE site_id = $1
+		ORDER BY "time" DESC, id DESC
+		LIMIT 1
+	`, siteID).Scan(&freq)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return freq, err
+}
+
+const controlChannelSelect = `
+	SELECT h.id, h.site_id, st.system_id, st.short_name, st.instance_id,
+		h.freq, h.prev_freq, h.source, h."time", h.until
+	FROM (
+		SELECT cch.*,
+			lead(cch."time") OVER (PARTITION BY cch.site_id ORDER BY cch."time", cch.id) AS until
+		FROM control_channel_history cch
+	) h
+	JOIN sites st ON st.site_id = h.site_id`
+
+func scanControlChannelChanges(rows pgx.Rows) ([]ControlChannelChange, error) {
+	defer rows.Close()
+	changes := []ControlChannelChange{}
+	for rows.Next() {
+		var c ControlChannelChange
+		if err := rows.Scan(&c.ID, &c.SiteID, &c.SystemID, &c.ShortName, &c.InstanceID,
+			&c.Freq, &c.PrevFreq, &c.Source, &c.Time, &c.Until); err != nil {
+			return nil, err
+		}
+		if c.Until != nil {
+			d := c.Until.Sub(c.Time).Seconds()
+			c.Duration = &d
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// ListControlChannelHistory returns control channel changes, newest first.
+// A change is included when it happened in [Start, End).
+func (db *DB) ListControlChannelHistory(ctx context.Context, f ControlChannelFilter) ([]ControlChannelChange, error) {
+	rows, err := db.Pool.Query(ctx, controlChannelSelect+`
+		WHERE ($1::int[] IS NULL OR st.system_id = ANY($1))
+		  AND ($2::int[] IS NULL OR h.site_id = ANY($2))
+		  AND ($3::timestamptz IS NULL OR h."time" >= $3)
+		  AND ($4::timestamptz IS NULL OR h."time" < $4)
+		ORDER BY h."time" DESC, h.id DESC
+		LIMIT $5
+	`, f.SystemIDs, f.SiteIDs, f.Start, f.End, f.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanControlChannelChanges(rows)
+}
+
+// ListCurrentControlChannels returns each site's latest control channel.
+func (db *DB) ListCurrentControlChannels(ctx context.Context, systemIDs []int) ([]ControlChannelChange, error) {
+	rows, err := db.Pool.Query(ctx, controlChannelSelect+`
+		WHERE h.until IS NULL
+		  AND ($1::int[] IS NULL OR st.system_id = ANY($1))
+		ORDER BY st.system_id, h.site_id
+	`, systemIDs)
+	if err != nil {
+		return nil, err
+	}
+	return scanControlChannelChanges(rows)
+}
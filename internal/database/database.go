@@ -16,6 +16,8 @@ type DB struct {
 	Pool *pgxpool.Pool
 	Q    *sqlcdb.Queries
 	log  zerolog.Logger
+
+	explain explainSampler // see query_plans.go
 }
 
 func Connect(ctx context.Context, databaseURL string, log zerolog.Logger) (*DB, error) {
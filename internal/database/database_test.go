@@ -43,3 +43,51 @@ func TestMaskDSN(t *testing.T) {
 	}
 }
 
+
+// ── anyWordTSQuery ───────────────────────────────────────────────────
+
+func TestAnyWordTSQuery(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"engine 5 main st", "engine | 5 | main | st"},
+		{"O'Brien & 12th: (ave)!", "o | brien | 12th | ave"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := anyWordTSQuery(tt.text); got != tt.want {
+			t.Errorf("anyWordTSQuery(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// ── vectorLiteral ────────────────────────────────────────────────────
+
+func TestVectorLiteral(t *testing.T) {
+	if got := vectorLiteral([]float32{0.5, -1, 0.1}); got != "[0.5,-1,0.1]" {
+		t.Errorf("vectorLiteral = %q", got)
+	}
+	if got := vectorLiteral(nil); got != "[]" {
+		t.Errorf("vectorLiteral(nil) = %q", got)
+	}
+}
+
+// ── encryptedPct ─────────────────────────────────────────────────────
+
+func TestEncryptedPct(t *testing.T) {
+	tests := []struct {
+		enc, clear float64
+		want       float64
+	}{
+		{30, 70, 30},
+		{1, 2, 33.3},
+		{12.5, 0, 100},
+		{0, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := encryptedPct(tt.enc, tt.clear); got != tt.want {
+			t.Errorf("encryptedPct(%v, %v) = %v, want %v", tt.enc, tt.clear, got, tt.want)
+		}
+	}
+}
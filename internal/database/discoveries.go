@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Discovery kinds.
+const (
+	DiscoveryTalkgroup = "talkgroup"
+	DiscoveryUnit      = "unit"
+)
+
+// TalkgroupExists reports whether a talkgroup row exists.
+func (db *DB) TalkgroupExists(ctx context.Context, systemID, tgid int) (bool, error) {
+	var exists bool
+	err := db.Pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM talkgroups WHERE system_id = $1 AND tgid = $2)`,
+		systemID, tgid).Scan(&exists)
+	return exists, err
+}
+
+// UnitExists reports whether a unit row exists.
+func (db *DB) UnitExists(ctx context.Context, systemID, unitID int) (bool, error) {
+	var exists bool
+	err := db.Pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM units WHERE system_id = $1 AND unit_id = $2)`,
+		systemID, unitID).Scan(&exists)
+	return exists, err
+}
+
+// DiscoveryCall is the first call a newly discovered entity was heard on.
+type DiscoveryCall struct {
+	CallID    int64     `json:"call_id"`
+	Tgid      int       `json:"tgid"`
+	StartTime time.Time `json:"start_time"`
+	Duration  *float32  `json:"duration,omitempty"`
+	AudioURL  *string   `json:"audio_url,omitempty"`
+}
+
+// Discovery is a talkgroup or unit first seen within a time range.
+type Discovery struct {
+	Kind       string         `json:"kind"`
+	SystemID   int            `json:"system_id"`
+	SystemName string         `json:"system_name"`
+	Tgid       int            `json:"tgid,omitempty"`
+	UnitID     int            `json:"unit_id,omitempty"`
+	AlphaTag   string         `json:"alpha_tag"`
+	FirstSeen  time.Time      `json:"first_seen"`
+	LastSeen   *time.Time     `json:"last_seen,omitempty"`
+	FirstCall  *DiscoveryCall `json:"first_call"`
+}
+
+// DiscoveryFilter selects entities by first_seen.
+type DiscoveryFilter struct {
+	Start     time.Time
+	End       time.Time
+	SystemIDs []int
+	Kind      string // "" = both
+	Limit     int
+}
+
+// discoveryFirstCallWindow bounds the first-call lookup after first_seen so
+// it stays within a few call partitions.
+const discoveryFirstCallWindow = "7 days"
+
+// ListDiscoveries returns talkgroups and units whose first_seen falls in
+// [Start, End), newest first, each with the earliest call heard on it from
+// first_seen on (null if none, e.g. a unit only seen in unit events).
+func (db *DB) ListDiscoveries(ctx context.Context, f DiscoveryFilter) ([]Discovery, error) {
+	rows, err := db.Pool.Query(ctx, `
+		WITH found AS (
+			SELECT 'talkgroup' AS kind, t.system_id, t.tgid, 0 AS unit_id,
+				COALESCE(t.alpha_tag, '') AS alpha_tag, t.first_seen, t.last_seen
+			FROM talkgroups t
+			WHERE $4 IN ('', 'talkgroup')
+			  AND t.first_seen >= $1 AND t.first_seen < $2
+			  AND ($3::int[] IS NULL OR t.system_id = ANY($3))
+			UNION ALL
+			SELECT 'unit', u.system_id, 0, u.unit_id,
+				COALESCE(u.alpha_tag, ''), u.first_seen, u.last_seen
+			FROM units u
+			WHERE $4 IN ('', 'unit')
+			  AND u.first_seen >= $1 AND u.first_seen < $2
+			  AND ($3::int[] IS NULL OR u.system_id = ANY($3))
+			ORDER BY first_seen DESC
+			LIMIT $5
+		)
+		SELECT f.kind, f.system_id, COALESCE(s.name, ''), f.tgid, f.unit_id,
+			f.alpha_tag, f.first_seen, f.last_seen,
+			fc.call_id, fc.tgid, fc.start_time, fc.duration, fc.audio_file_path
+		FROM found f
+		JOIN systems s ON s.system_id = f.system_id
+		LEFT JOIN LATERAL (
+			SELECT c.call_id, c.tgid, c.start_time, c.duration, c.audio_file_path
+			FROM calls c
+			WHERE c.system_id = f.system_id
+			  AND c.start_time >= f.first_seen - interval '1 minute'
+			  AND c.start_time < f.first_seen + interval '`+discoveryFirstCallWindow+`'
+			  AND CASE WHEN f.kind = 'talkgroup' THEN c.tgid = f.tgid
+			           ELSE f.unit_id = ANY(c.unit_ids) END
+			ORDER BY c.start_time
+			LIMIT 1
+		) fc ON true
+		ORDER BY f.first_seen DESC, f.kind, f.system_id, f.tgid, f.unit_id
+	`, f.Start, f.End, pqIntArray(f.SystemIDs), f.Kind, f.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []Discovery{}
+	for rows.Next() {
+		var d Discovery
+		var (
+			callID    *int64
+			callTgid  *int
+			callStart *time.Time
+			duration  *float32
+			audioPath *string
+		)
+		if err := rows.Scan(&d.Kind, &d.SystemID, &d.SystemName, &d.Tgid, &d.UnitID,
+			&d.AlphaTag, &d.FirstSeen, &d.LastSeen,
+			&callID, &callTgid, &callStart, &duration, &audioPath,
+		); err != nil {
+			return nil, err
+		}
+		if callID != nil {
+			d.FirstCall = &DiscoveryCall{CallID: *callID, Tgid: *callTgid, StartTime: *callStart, Duration: duration}
+			if audioPath != nil && *audioPath != "" {
+				url := fmt.Sprintf("/api/v1/calls/%d/audio", *callID)
+				d.FirstCall.AudioURL = &url
+			}
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,194 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Display is the visual identity of a system, site or talkgroup group, so
+// every client colors, labels and orders it the same way.
+type Display struct {
+	Color        string `json:"color,omitempty"` // "#rrggbb"
+	Icon         string `json:"icon,omitempty"`  // icon name; the set is up to clients
+	ShortLabel   string `json:"short_label,omitempty"`
+	DisplayOrder int    `json:"display_order"` // ascending; ties keep the default order
+}
+
+// DisplayPatch changes the non-nil fields of a Display; "" clears a text
+// field.
+type DisplayPatch struct {
+	Color        *string
+	Icon         *string
+	ShortLabel   *string
+	DisplayOrder *int
+}
+
+// IsZero reports whether the patch changes nothing.
+func (p DisplayPatch) IsZero() bool {
+	return p.Color == nil && p.Icon == nil && p.ShortLabel == nil && p.DisplayOrder == nil
+}
+
+// displaySet is the SET list applying a DisplayPatch passed as $2..$5.
+const displaySet = `
+	color = CASE WHEN $2::text IS NULL THEN color ELSE NULLIF($2, '') END,
+	icon = CASE WHEN $3::text IS NULL THEN icon ELSE NULLIF($3, '') END,
+	short_label = CASE WHEN $4::text IS NULL THEN short_label ELSE NULLIF($4, '') END,
+	display_order = COALESCE($5, display_order)`
+
+// UpdateSystemDisplay applies a display patch to a system.
+func (db *DB) UpdateSystemDisplay(ctx context.Context, systemID int, p DisplayPatch) error {
+	tag, err := db.Pool.Exec(ctx, `UPDATE systems SET`+displaySet+`
+		WHERE system_id = $1 AND deleted_at IS NULL`,
+		systemID, p.Color, p.Icon, p.ShortLabel, p.DisplayOrder)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("system not found")
+	}
+	return nil
+}
+
+// UpdateSiteDisplay applies a display patch to a site.
+func (db *DB) UpdateSiteDisplay(ctx context.Context, siteID int, p DisplayPatch) error {
+	tag, err := db.Pool.Exec(ctx, `UPDATE sites SET`+displaySet+`
+		WHERE site_id = $1`,
+		siteID, p.Color, p.Icon, p.ShortLabel, p.DisplayOrder)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("site not found")
+	}
+	return nil
+}
+
+// loadDisplays reads id → Display from a table with the display columns.
+// The generated system and site queries predate the columns.
+func (db *DB) loadDisplays(ctx context.Context, table, idColumn string) (map[int]Display, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+idColumn+`, COALESCE(color, ''), COALESCE(icon, ''), COALESCE(short_label, ''), display_order
+		FROM `+table+`
+		WHERE color IS NOT NULL OR icon IS NOT NULL OR short_label IS NOT NULL OR display_order <> 0`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	displays := make(map[int]Display)
+	for rows.Next() {
+		var id int
+		var d Display
+		if err := rows.Scan(&id, &d.Color, &d.Icon, &d.ShortLabel, &d.DisplayOrder); err != nil {
+			return nil, err
+		}
+		displays[id] = d
+	}
+	return displays, rows.Err()
+}
+
+// fillSystemDisplays sets the display metadata of n systems, where at(i)
+// returns the i-th system's ID and display field.
+func (db *DB) fillSystemDisplays(ctx context.Context, n int, at func(i int) (int, *Display)) error {
+	displays, err := db.loadDisplays(ctx, "systems", "system_id")
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		id, d := at(i)
+		*d = displays[id]
+	}
+	return nil
+}
+
+// fillSiteDisplays sets the sites' display metadata and sorts them by
+// display order.
+func (db *DB) fillSiteDisplays(ctx context.Context, sites []SiteAPI) error {
+	if len(sites) == 0 {
+		return nil
+	}
+	displays, err := db.loadDisplays(ctx, "sites", "site_id")
+	if err != nil {
+		return err
+	}
+	for i := range sites {
+		sites[i].Display = displays[sites[i].SiteID]
+	}
+	slices.SortStableFunc(sites, func(a, b SiteAPI) int { return a.Display.DisplayOrder - b.Display.DisplayOrder })
+	return nil
+}
+
+// TalkgroupGroup is a talkgroup group with its display metadata.
+type TalkgroupGroup struct {
+	Name       string `json:"name"`
+	Talkgroups int    `json:"talkgroups"` // talkgroups in the group on the listed systems
+	Display
+}
+
+// ListTalkgroupGroups returns the talkgroup groups on the given systems (all
+// when empty) by display order, then name. Without a system filter, groups
+// given display metadata before any talkgroup uses them are listed too.
+func (db *DB) ListTalkgroupGroups(ctx context.Context, systemIDs []int) ([]TalkgroupGroup, error) {
+	rows, err := db.Pool.Query(ctx, `
+		WITH g AS (
+			SELECT min("group") AS name, count(*) AS talkgroups
+			FROM talkgroups
+			WHERE "group" IS NOT NULL AND "group" <> ''
+			  AND ($1::int[] IS NULL OR system_id = ANY($1))
+			GROUP BY lower("group")
+		)
+		SELECT COALESCE(d.name, g.name), COALESCE(g.talkgroups, 0),
+			COALESCE(d.color, ''), COALESCE(d.icon, ''), COALESCE(d.short_label, ''), COALESCE(d.display_order, 0)
+		FROM g
+		FULL JOIN talkgroup_groups d ON lower(d.name) = lower(g.name)
+		WHERE g.name IS NOT NULL OR $1::int[] IS NULL
+		ORDER BY 6, lower(COALESCE(d.name, g.name))`, pqIntArray(systemIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	groups := []TalkgroupGroup{}
+	for rows.Next() {
+		var g TalkgroupGroup
+		if err := rows.Scan(&g.Name, &g.Talkgroups, &g.Color, &g.Icon, &g.ShortLabel, &g.DisplayOrder); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// UpdateTalkgroupGroupDisplay applies a display patch to a talkgroup group,
+// matched by name without regard to case, and returns it. A group without
+// talkgroups yet may be given display metadata ahead of an import.
+func (db *DB) UpdateTalkgroupGroupDisplay(ctx context.Context, name string, p DisplayPatch) (*TalkgroupGroup, error) {
+	if _, err := db.Pool.Exec(ctx, `
+		INSERT INTO talkgroup_groups (name) VALUES ($1)
+		ON CONFLICT ((lower(name))) DO NOTHING`, name); err != nil {
+		return nil, err
+	}
+	g := TalkgroupGroup{}
+	err := db.Pool.QueryRow(ctx, `
+		UPDATE talkgroup_groups SET`+displaySet+`, updated_at = now()
+		WHERE lower(name) = lower($1)
+		RETURNING name, COALESCE(color, ''), COALESCE(icon, ''), COALESCE(short_label, ''), display_order,
+			(SELECT count(*) FROM talkgroups t WHERE lower(t."group") = lower($1))`,
+		name, p.Color, p.Icon, p.ShortLabel, p.DisplayOrder,
+	).Scan(&g.Name, &g.Color, &g.Icon, &g.ShortLabel, &g.DisplayOrder, &g.Talkgroups)
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// DeleteTalkgroupGroupDisplay removes a talkgroup group's display metadata.
+func (db *DB) DeleteTalkgroupGroupDisplay(ctx context.Context, name string) error {
+	tag, err := db.Pool.Exec(ctx, `DELETE FROM talkgroup_groups WHERE lower(name) = lower($1)`, name)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("talkgroup group not found")
+	}
+	return nil
+}
@@ -0,0 +1,407 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Duplicate call finding statuses.
+const (
+	DuplicateOpen      = "open"      // reported, awaiting review
+	DuplicateGrouped   = "grouped"   // duplicate moved into the call's group
+	DuplicateDismissed = "dismissed" // not a duplicate (or ungrouped)
+)
+
+// DuplicatePair is a candidate duplicate: two calls on the same system and
+// talkgroup that overlap in time but aren't in the same call group. Call is
+// the earlier one; Dup starts at most the audit's maximum start gap later.
+type DuplicatePair struct {
+	SystemID     int
+	Tgid         int
+	Call         CallRef
+	Dup          CallRef
+	CallDuration float64 // seconds
+	DupDuration  float64
+	CallUnit     int // initiating unit; 0 = unknown
+	DupUnit      int
+}
+
+// initiatingUnitOf is initiatingUnitSQL for the calls row aliased alias.
+func initiatingUnitOf(alias string) string {
+	return strings.NewReplacer("src_list", alias+".src_list", "unit_ids", alias+".unit_ids").Replace(initiatingUnitSQL)
+}
+
+// ListDuplicatePairs returns candidate duplicates among finished calls that
+// started in [start, end), skipping pairs already recorded as findings.
+func (db *DB) ListDuplicatePairs(ctx context.Context, start, end time.Time, maxStartGap time.Duration) ([]DuplicatePair, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT a.system_id, a.tgid, a.call_id, a.start_time, b.call_id, b.start_time,
+			COALESCE(a.duration, extract(epoch FROM a.stop_time - a.start_time)),
+			COALESCE(b.duration, extract(epoch FROM b.stop_time - b.start_time)),
+			COALESCE(`+initiatingUnitOf("a")+`, 0), COALESCE(`+initiatingUnitOf("b")+`, 0)
+		FROM calls a
+		JOIN calls b ON b.system_id = a.system_id AND b.tgid = a.tgid
+			AND b.start_time >= a.start_time AND b.start_time <= a.start_time + $3::interval
+			AND b.start_time <= a.stop_time
+			AND (b.start_time > a.start_time OR b.call_id > a.call_id)
+		WHERE a.start_time >= $1 AND a.start_time < $2
+		  AND a.tgid <> 0
+		  AND a.stop_time IS NOT NULL AND b.stop_time IS NOT NULL
+		  AND a.merged_into IS NULL AND b.merged_into IS NULL
+		  AND (a.call_group_id IS NULL OR a.call_group_id IS DISTINCT FROM b.call_group_id)
+		  AND NOT EXISTS (SELECT 1 FROM duplicate_call_findings f
+			WHERE f.call_id = a.call_id AND f.call_start_time = a.start_time
+			  AND f.dup_call_id = b.call_id AND f.dup_start_time = b.start_time)
+		ORDER BY a.start_time, b.start_time
+	`, start, end, maxStartGap)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pairs []DuplicatePair
+	for rows.Next() {
+		var p DuplicatePair
+		if err := rows.Scan(&p.SystemID, &p.Tgid, &p.Call.CallID, &p.Call.StartTime,
+			&p.Dup.CallID, &p.Dup.StartTime, &p.CallDuration, &p.DupDuration,
+			&p.CallUnit, &p.DupUnit); err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, p)
+	}
+	return pairs, rows.Err()
+}
+
+// DuplicateFinding is a scored duplicate pair and what was done about it.
+type DuplicateFinding struct {
+	ID              int64      `json:"id"`
+	SystemID        int        `json:"system_id"`
+	Tgid            int        `json:"tgid"`
+	CallID          int64      `json:"call_id"`
+	CallStartTime   time.Time  `json:"call_start_time"`
+	DupCallID       int64      `json:"dup_call_id"`
+	DupStartTime    time.Time  `json:"dup_start_time"`
+	Confidence      float64    `json:"confidence"`
+	StartGap        float64    `json:"start_gap"`
+	OverlapRatio    float64    `json:"overlap_ratio"`
+	DurationRatio   float64    `json:"duration_ratio"`
+	SameUnit        *bool      `json:"same_unit"`
+	Status          string     `json:"status"`
+	GroupID         *int       `json:"group_id,omitempty"`
+	PreviousGroupID *int       `json:"previous_group_id,omitempty"`
+	FoundAt         time.Time  `json:"found_at"`
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty"`
+	ResolvedBy      string     `json:"resolved_by,omitempty"`
+}
+
+const duplicateFindingColumns = `id, system_id, tgid, call_id, call_start_time, dup_call_id, dup_start_time,
+	confidence, start_gap, overlap_ratio, duration_ratio, same_unit, status, group_id,
+	previous_group_id, found_at, resolved_at, COALESCE(resolved_by, '')`
+
+func scanDuplicateFindings(rows pgx.Rows) ([]DuplicateFinding, error) {
+	defer rows.Close()
+	findings := []DuplicateFinding{}
+	for rows.Next() {
+		var f DuplicateFinding
+		var conf, gap, overlap, dur float32
+		if err := rows.Scan(&f.ID, &f.SystemID, &f.Tgid, &f.CallID, &f.CallStartTime,
+			&f.DupCallID, &f.DupStartTime, &conf, &gap, &overlap, &dur, &f.SameUnit,
+			&f.Status, &f.GroupID, &f.PreviousGroupID, &f.FoundAt, &f.ResolvedAt,
+			&f.ResolvedBy); err != nil {
+			return nil, err
+		}
+		f.Confidence, f.StartGap, f.OverlapRatio, f.DurationRatio =
+			float64(conf), float64(gap), float64(overlap), float64(dur)
+		findings = append(findings, f)
+	}
+	return findings, rows.Err()
+}
+
+// InsertDuplicateFinding records a scored pair as an open finding. Returns
+// its ID, or 0 if the pair was already recorded.
+func (db *DB) InsertDuplicateFinding(ctx context.Context, f DuplicateFinding) (int64, error) {
+	var id int64
+	err := db.Pool.QueryRow(ctx, `
+		INSERT INTO duplicate_call_findings (system_id, tgid, call_id, call_start_time,
+			dup_call_id, dup_start_time, confidence, start_gap, overlap_ratio,
+			duration_ratio, same_unit)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (call_id, call_start_time, dup_call_id, dup_start_time) DO NOTHING
+		RETURNING id
+	`, f.SystemID, f.Tgid, f.CallID, f.CallStartTime, f.DupCallID, f.DupStartTime,
+		f.Confidence, f.StartGap, f.OverlapRatio, f.DurationRatio, f.SameUnit).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return id, err
+}
+
+// errFindingNotFound is returned when a finding doesn't exist or isn't in
+// the state an action needs.
+func errFindingNotFound(status string) error {
+	return fmt.Errorf("duplicate finding not found or not %s", status)
+}
+
+// lockFinding loads finding id for update if it has status.
+func lockFinding(ctx context.Context, tx pgx.Tx, id int64, status string) (*DuplicateFinding, error) {
+	rows, err := tx.Query(ctx, `SELECT `+duplicateFindingColumns+`
+		FROM duplicate_call_findings WHERE id = $1 AND status = $2 FOR UPDATE`, id, status)
+	if err != nil {
+		return nil, err
+	}
+	findings, err := scanDuplicateFindings(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(findings) == 0 {
+		return nil, errFindingNotFound(status)
+	}
+	return &findings[0], nil
+}
+
+// resolveFinding sets a finding's status and logs the action in the same
+// transaction, returning the updated finding.
+func resolveFinding(ctx context.Context, tx pgx.Tx, id int64, status, action, actor string, from, to, groupID, prevGroupID *int) (*DuplicateFinding, error) {
+	rows, err := tx.Query(ctx, `
+		UPDATE duplicate_call_findings SET status = $2, group_id = $3, previous_group_id = $4,
+			resolved_at = now(), resolved_by = $5
+		WHERE id = $1
+		RETURNING `+duplicateFindingColumns, id, status, groupID, prevGroupID, actor)
+	if err != nil {
+		return nil, err
+	}
+	findings, err := scanDuplicateFindings(rows)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO duplicate_call_actions (finding_id, action, actor, from_group_id, to_group_id)
+		VALUES ($1, $2, $3, $4, $5)
+	`, id, action, actor, from, to); err != nil {
+		return nil, fmt.Errorf("log duplicate action: %w", err)
+	}
+	return &findings[0], nil
+}
+
+// GroupDuplicate moves an open finding's duplicate call into the call's
+// group (creating one for the call if it has none) and marks it grouped.
+// actor is "auto" or "api".
+func (db *DB) GroupDuplicate(ctx context.Context, id int64, actor string) (*DuplicateFinding, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	f, err := lockFinding(ctx, tx, id, DuplicateOpen)
+	if err != nil {
+		return nil, err
+	}
+
+	var groupID *int
+	err = tx.QueryRow(ctx, `SELECT call_group_id FROM calls WHERE call_id = $1 AND start_time = $2`,
+		f.CallID, f.CallStartTime).Scan(&groupID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("call not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if groupID == nil {
+		var g int
+		if err := tx.QueryRow(ctx, `
+			INSERT INTO call_groups (system_id, tgid, start_time, primary_call_id,
+				tg_alpha_tag, tg_description, tg_tag, tg_group)
+			SELECT system_id, tgid, start_time, call_id, tg_alpha_tag, tg_description, tg_tag, tg_group
+			FROM calls WHERE call_id = $1 AND start_time = $2
+			ON CONFLICT (system_id, tgid, start_time) DO UPDATE SET
+				primary_call_id = COALESCE(call_groups.primary_call_id, EXCLUDED.primary_call_id)
+			RETURNING id
+		`, f.CallID, f.CallStartTime).Scan(&g); err != nil {
+			return nil, fmt.Errorf("create call group: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `UPDATE calls SET call_group_id = $3 WHERE call_id = $1 AND start_time = $2`,
+			f.CallID, f.CallStartTime, g); err != nil {
+			return nil, fmt.Errorf("group call: %w", err)
+		}
+		groupID = &g
+	}
+
+	var prev *int
+	err = tx.QueryRow(ctx, `SELECT call_group_id FROM calls WHERE call_id = $1 AND start_time = $2`,
+		f.DupCallID, f.DupStartTime).Scan(&prev)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("call not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if prev == nil || *prev != *groupID {
+		if _, err := tx.Exec(ctx, `UPDATE calls SET call_group_id = $3 WHERE call_id = $1 AND start_time = $2`,
+			f.DupCallID, f.DupStartTime, *groupID); err != nil {
+			return nil, fmt.Errorf("group duplicate: %w", err)
+		}
+	}
+
+	out, err := resolveFinding(ctx, tx, id, DuplicateGrouped, "grouped", actor, prev, groupID, groupID, prev)
+	if err != nil {
+		return nil, err
+	}
+	return out, tx.Commit(ctx)
+}
+
+// UngroupDuplicate undoes a grouping: the duplicate goes back to the group
+// it had (if that still exists) and the finding is dismissed.
+func (db *DB) UngroupDuplicate(ctx context.Context, id int64, actor string) (*DuplicateFinding, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	f, err := lockFinding(ctx, tx, id, DuplicateGrouped)
+	if err != nil {
+		return nil, err
+	}
+	var restored *int
+	if err := tx.QueryRow(ctx, `
+		UPDATE calls SET call_group_id = (SELECT id FROM call_groups WHERE id = $4)
+		WHERE call_id = $1 AND start_time = $2 AND call_group_id IS NOT DISTINCT FROM $3
+		RETURNING call_group_id
+	`, f.DupCallID, f.DupStartTime, f.GroupID, f.PreviousGroupID).Scan(&restored); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("ungroup duplicate: %w", err)
+	}
+	// A duplicate regrouped since is left where it is.
+	out, err := resolveFinding(ctx, tx, id, DuplicateDismissed, "ungrouped", actor, f.GroupID, restored, nil, f.PreviousGroupID)
+	if err != nil {
+		return nil, err
+	}
+	return out, tx.Commit(ctx)
+}
+
+// DismissDuplicate marks an open finding as not a duplicate.
+func (db *DB) DismissDuplicate(ctx context.Context, id int64, actor string) (*DuplicateFinding, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := lockFinding(ctx, tx, id, DuplicateOpen); err != nil {
+		return nil, err
+	}
+	out, err := resolveFinding(ctx, tx, id, DuplicateDismissed, "dismissed", actor, nil, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return out, tx.Commit(ctx)
+}
+
+// DuplicateFindingFilter selects findings by the call's start time.
+type DuplicateFindingFilter struct {
+	Statuses      []string
+	SystemIDs     []int
+	Tgids         []int
+	MinConfidence float64
+	Start         *time.Time
+	End           *time.Time
+	Limit         int
+	Offset        int
+}
+
+// ListDuplicateFindings returns findings matching the filter, newest call
+// first, with the total count.
+func (db *DB) ListDuplicateFindings(ctx context.Context, f DuplicateFindingFilter) ([]DuplicateFinding, int, error) {
+	const where = `
+		WHERE ($1::text[] IS NULL OR status = ANY($1))
+		  AND ($2::int[] IS NULL OR system_id = ANY($2))
+		  AND ($3::int[] IS NULL OR tgid = ANY($3))
+		  AND confidence >= $4
+		  AND ($5::timestamptz IS NULL OR call_start_time >= $5)
+		  AND ($6::timestamptz IS NULL OR call_start_time < $6)`
+	var statuses any
+	if len(f.Statuses) > 0 {
+		statuses = f.Statuses
+	}
+	args := []any{statuses, pqIntArray(f.SystemIDs), pqIntArray(f.Tgids), f.MinConfidence, f.Start, f.End}
+
+	var total int
+	if err := db.Pool.QueryRow(ctx, `SELECT count(*) FROM duplicate_call_findings`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := db.Pool.Query(ctx, `SELECT `+duplicateFindingColumns+` FROM duplicate_call_findings`+where+`
+		ORDER BY call_start_time DESC, id DESC
+		LIMIT $7 OFFSET $8`, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	findings, err := scanDuplicateFindings(rows)
+	return findings, total, err
+}
+
+// DuplicateAction is one audit trail entry: a finding grouped, dismissed or
+// ungrouped, with the duplicate call's group before and after.
+type DuplicateAction struct {
+	ID          int64     `json:"id"`
+	FindingID   int64     `json:"finding_id"`
+	Action      string    `json:"action"`
+	Actor       string    `json:"actor"`
+	FromGroupID *int      `json:"from_group_id"`
+	ToGroupID   *int      `json:"to_group_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ListDuplicateActions returns the audit trail, newest first, optionally for
+// one finding, with the total count.
+func (db *DB) ListDuplicateActions(ctx context.Context, findingID int64, limit, offset int) ([]DuplicateAction, int, error) {
+	const where = ` WHERE ($1::bigint = 0 OR finding_id = $1)`
+	var total int
+	if err := db.Pool.QueryRow(ctx, `SELECT count(*) FROM duplicate_call_actions`+where, findingID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := db.Pool.Query(ctx, `
+		SELECT id, finding_id, action, actor, from_group_id, to_group_id, created_at
+		FROM duplicate_call_actions`+where+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3`, findingID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	actions := []DuplicateAction{}
+	for rows.Next() {
+		var a DuplicateAction
+		if err := rows.Scan(&a.ID, &a.FindingID, &a.Action, &a.Actor, &a.FromGroupID,
+			&a.ToGroupID, &a.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		actions = append(actions, a)
+	}
+	return actions, total, rows.Err()
+}
+
+// DuplicateFindingCounts counts findings by status.
+type DuplicateFindingCounts struct {
+	Open      int64 `json:"open"`
+	Grouped   int64 `json:"grouped"`
+	Dismissed int64 `json:"dismissed"`
+}
+
+// CountDuplicateFindings counts findings by status.
+func (db *DB) CountDuplicateFindings(ctx context.Context) (DuplicateFindingCounts, error) {
+	var c DuplicateFindingCounts
+	err := db.Pool.QueryRow(ctx, `
+		SELECT
+			count(*) FILTER (WHERE status = 'open'),
+			count(*) FILTER (WHERE status = 'grouped'),
+			count(*) FILTER (WHERE status = 'dismissed')
+		FROM duplicate_call_findings
+	`).Scan(&c.Open, &c.Grouped, &c.Dismissed)
+	return c, err
+}
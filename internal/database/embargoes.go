@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// MaxEmbargoMinutes is the longest listener delay a talkgroup embargo may set
+// (one week).
+const MaxEmbargoMinutes = 10080
+
+// Embargo is one talkgroup_embargoes row: calls on the talkgroup reach
+// non-admins DelayMinutes after they end.
+type Embargo struct {
+	SystemID     int       `json:"system_id"`
+	Tgid         int       `json:"tgid"`
+	DelayMinutes int       `json:"delay_minutes"`
+	Note         string    `json:"note,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Delay returns the embargo's listener delay.
+func (e Embargo) Delay() time.Duration {
+	return time.Duration(e.DelayMinutes) * time.Minute
+}
+
+// embargoedCallSQL is true for a call (aliased c) still inside its
+// talkgroup's embargo. Calls without a stop time count from their start.
+const embargoedCallSQL = `EXISTS (SELECT 1 FROM talkgroup_embargoes te
+		WHERE te.system_id = c.system_id AND te.tgid = c.tgid
+		  AND COALESCE(c.stop_time, c.start_time) > now() - make_interval(mins => te.delay_minutes))`
+
+// ListEmbargoes returns all talkgroup embargoes.
+func (db *DB) ListEmbargoes(ctx context.Context) ([]Embargo, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT system_id, tgid, delay_minutes, COALESCE(note, ''), updated_at
+		FROM talkgroup_embargoes
+		ORDER BY system_id, tgid
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	embargoes := []Embargo{}
+	for rows.Next() {
+		var e Embargo
+		if err := rows.Scan(&e.SystemID, &e.Tgid, &e.DelayMinutes, &e.Note, &e.UpdatedAt); err != nil {
+			return nil, err
+		}
+		embargoes = append(embargoes, e)
+	}
+	return embargoes, rows.Err()
+}
+
+// GetEmbargo returns a talkgroup's embargo, or nil if it has none.
+func (db *DB) GetEmbargo(ctx context.Context, systemID, tgid int) (*Embargo, error) {
+	e := Embargo{SystemID: systemID, Tgid: tgid}
+	err := db.Pool.QueryRow(ctx, `
+		SELECT delay_minutes, COALESCE(note, ''), updated_at
+		FROM talkgroup_embargoes
+		WHERE system_id = $1 AND tgid = $2
+	`, systemID, tgid).Scan(&e.DelayMinutes, &e.Note, &e.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// UpsertEmbargo creates or replaces a talkgroup's embargo.
+func (db *DB) UpsertEmbargo(ctx context.Context, systemID, tgid, delayMinutes int, note string) (*Embargo, error) {
+	e := Embargo{SystemID: systemID, Tgid: tgid}
+	err := db.Pool.QueryRow(ctx, `
+		INSERT INTO talkgroup_embargoes (system_id, tgid, delay_minutes, note)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		ON CONFLICT (system_id, tgid) DO UPDATE SET
+			delay_minutes = EXCLUDED.delay_minutes,
+			note = EXCLUDED.note,
+			updated_at = now()
+		RETURNING delay_minutes, COALESCE(note, ''), updated_at
+	`, systemID, tgid, delayMinutes, note).Scan(&e.DelayMinutes, &e.Note, &e.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// DeleteEmbargo lifts a talkgroup's embargo. Returns whether one existed.
+func (db *DB) DeleteEmbargo(ctx context.Context, systemID, tgid int) (bool, error) {
+	tag, err := db.Pool.Exec(ctx,
+		`DELETE FROM talkgroup_embargoes WHERE system_id = $1 AND tgid = $2`, systemID, tgid)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,329 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// transcript_embeddings is optional: it needs the pgvector extension and its
+// vector dimension comes from EMBED_DIMENSIONS, so it is created at startup by
+// EnsureEmbeddingSchema rather than by schema.sql/migrations. Like calls, it is
+// partitioned monthly by call_start_time; each partition carries its own HNSW
+// index, created with the partition by EnsureEmbeddingPartition.
+
+// EnsureEmbeddingSchema creates the pgvector extension and the partitioned
+// transcript_embeddings table for vectors of dims dimensions. It fails if the
+// table exists with a different dimension (drop it to re-embed with a new model).
+func (db *DB) EnsureEmbeddingSchema(ctx context.Context, dims int) error {
+	if _, err := db.Pool.Exec(ctx, `CREATE EXTENSION IF NOT EXISTS vector`); err != nil {
+		return fmt.Errorf("create extension vector (is pgvector installed?): %w", err)
+	}
+	_, err := db.Pool.Exec(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS transcript_embeddings (
+			transcription_id  int          NOT NULL REFERENCES transcriptions (id) ON DELETE CASCADE,
+			call_id           bigint       NOT NULL,
+			call_start_time   timestamptz  NOT NULL,
+			system_id         int          NOT NULL,
+			tgid              int          NOT NULL,
+			model             text         NOT NULL,
+			embedding         vector(%d)   NOT NULL,
+			created_at        timestamptz  NOT NULL DEFAULT now(),
+
+			PRIMARY KEY (transcription_id, call_start_time)
+		) PARTITION BY RANGE (call_start_time)`, dims))
+	if err != nil {
+		return fmt.Errorf("create transcript_embeddings: %w", err)
+	}
+
+	var existing int
+	err = db.Pool.QueryRow(ctx, `
+		SELECT atttypmod FROM pg_attribute
+		WHERE attrelid = 'transcript_embeddings'::regclass AND attname = 'embedding'
+	`).Scan(&existing)
+	if err != nil {
+		return err
+	}
+	if existing != dims {
+		return fmt.Errorf("transcript_embeddings holds %d-dimension vectors but EMBED_DIMENSIONS=%d; "+
+			"DROP TABLE transcript_embeddings to re-embed with the new model", existing, dims)
+	}
+	return nil
+}
+
+// EnsureEmbeddingPartition creates the transcript_embeddings partition for the
+// month containing month, and its HNSW index. Idempotent; returns the
+// partition name, suffixed "(already exists)" like CreateMonthlyPartition.
+func (db *DB) EnsureEmbeddingPartition(ctx context.Context, month time.Time) (string, error) {
+	month = time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	result, err := db.CreateMonthlyPartition(ctx, "transcript_embeddings", month)
+	if err != nil {
+		return "", err
+	}
+	name := strings.TrimSuffix(result, " (already exists)")
+	// Built per partition so a new month's index starts empty and cheap, and
+	// a partition created before this index existed gets one on the next pass.
+	_, err = db.Pool.Exec(ctx, fmt.Sprintf(
+		`CREATE INDEX IF NOT EXISTS %s ON %s USING hnsw (embedding vector_cosine_ops)`,
+		pgx.Identifier{name + "_hnsw"}.Sanitize(), pgx.Identifier{name}.Sanitize()))
+	if err != nil {
+		return "", fmt.Errorf("create hnsw index on %s: %w", name, err)
+	}
+	return result, nil
+}
+
+// TranscriptionToEmbed is a primary transcription that has no embedding for
+// the current model.
+type TranscriptionToEmbed struct {
+	ID            int
+	CallID        int64
+	CallStartTime time.Time
+	SystemID      int
+	Tgid          int
+	Text          string
+}
+
+// EmbedCursor is a keyset position in ListTranscriptionsToEmbed order.
+type EmbedCursor struct {
+	CallStartTime time.Time
+	ID            int
+}
+
+// ListTranscriptionsToEmbed returns primary transcriptions with text whose
+// call started in [start, end) (nil = unbounded) and that have no embedding
+// from model, newest first. If after is set, only rows past that position are
+// returned, so a caller can walk the window once even when some rows fail.
+func (db *DB) ListTranscriptionsToEmbed(ctx context.Context, model string, start, end *time.Time, after *EmbedCursor, limit int) ([]TranscriptionToEmbed, error) {
+	var afterTime *time.Time
+	var afterID int
+	if after != nil {
+		afterTime, afterID = &after.CallStartTime, after.ID
+	}
+	rows, err := db.Pool.Query(ctx, `
+		SELECT t.id, t.call_id, t.call_start_time, c.system_id, c.tgid, t.text
+		FROM transcriptions t
+		JOIN calls c ON c.call_id = t.call_id AND c.start_time = t.call_start_time
+		WHERE t.is_primary
+		  AND COALESCE(t.text, '') <> ''
+		  AND ($2::timestamptz IS NULL OR t.call_start_time >= $2)
+		  AND ($3::timestamptz IS NULL OR t.call_start_time < $3)
+		  AND NOT EXISTS (
+			SELECT 1 FROM transcript_embeddings e
+			WHERE e.transcription_id = t.id AND e.call_start_time = t.call_start_time AND e.model = $1
+		  )
+		  AND ($4::timestamptz IS NULL OR (t.call_start_time, t.id) < ($4, $5))
+		ORDER BY t.call_start_time DESC, t.id DESC
+		LIMIT $6
+	`, model, start, end, afterTime, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []TranscriptionToEmbed
+	for rows.Next() {
+		var t TranscriptionToEmbed
+		if err := rows.Scan(&t.ID, &t.CallID, &t.CallStartTime, &t.SystemID, &t.Tgid, &t.Text); err != nil {
+			return nil, err
+		}
+		result = append(result, t)
+	}
+	return result, rows.Err()
+}
+
+// TranscriptEmbedding is a vector for one transcription.
+type TranscriptEmbedding struct {
+	TranscriptionToEmbed
+	Embedding []float32
+}
+
+// StoreEmbeddings upserts embeddings from model. The partitions for their
+// months must exist (EnsureEmbeddingPartition).
+func (db *DB) StoreEmbeddings(ctx context.Context, model string, es []TranscriptEmbedding) error {
+	batch := &pgx.Batch{}
+	for _, e := range es {
+		batch.Queue(`
+			INSERT INTO transcript_embeddings (transcription_id, call_id, call_start_time, system_id, tgid, model, embedding)
+			VALUES ($1, $2, $3, $4, $5, $6, $7::vector)
+			ON CONFLICT (transcription_id, call_start_time) DO UPDATE SET
+				model = EXCLUDED.model, embedding = EXCLUDED.embedding, created_at = now()
+		`, e.ID, e.CallID, e.CallStartTime, e.SystemID, e.Tgid, model, vectorLiteral(e.Embedding))
+	}
+	return db.Pool.SendBatch(ctx, batch).Close()
+}
+
+// EmbeddingStats counts primary transcriptions with and without an
+// embedding from model.
+type EmbeddingStats struct {
+	Embedded int `json:"embedded"`
+	Pending  int `json:"pending"`
+}
+
+// GetEmbeddingStats returns embedding coverage for model.
+func (db *DB) GetEmbeddingStats(ctx context.Context, model string) (EmbeddingStats, error) {
+	var s EmbeddingStats
+	err := db.Pool.QueryRow(ctx, `
+		SELECT count(e.transcription_id), count(*) - count(e.transcription_id)
+		FROM transcriptions t
+		LEFT JOIN transcript_embeddings e
+			ON e.transcription_id = t.id AND e.call_start_time = t.call_start_time AND e.model = $1
+		WHERE t.is_primary AND COALESCE(t.text, '') <> ''
+	`, model).Scan(&s.Embedded, &s.Pending)
+	return s, err
+}
+
+// SemanticSearchFilter specifies filters for hybrid transcript search.
+type SemanticSearchFilter struct {
+	SystemIDs    []int
+	Tgids        []int
+	StartTime    *time.Time
+	EndTime      *time.Time
+	Limit        int
+	VectorWeight float64 // share of the score from vector similarity, 0..1
+
+	IncludeRestricted bool // include calls hidden by a restricted encryption policy or embargo (admins)
+}
+
+// SemanticSearchHit is a hybrid search result.
+type SemanticSearchHit struct {
+	TranscriptionSearchHit
+	Score       float64 `json:"score"`
+	VectorScore float64 `json:"vector_score"` // cosine similarity, 0 if not embedded
+	TextScore   float64 `json:"text_score"`   // ts_rank normalized to 0..1
+}
+
+// SemanticSearchTranscriptions ranks primary transcriptions by a blend of
+// vector similarity to vec and full-text rank for query (any word). Candidates
+// are the nearest embeddings from model plus the best full-text matches, so a
+// transcript is found if either signal finds it; each is then scored
+// VectorWeight*similarity + (1-VectorWeight)*text rank. Filters are applied
+// while walking the HNSW index, which is approximate: very narrow filters
+// can return fewer than Limit hits.
+// Calls hidden by a restricted encryption policy or embargo are left out
+// unless filter.IncludeRestricted.
+func (db *DB) SemanticSearchTranscriptions(ctx context.Context, model string, vec []float32, query string, filter SemanticSearchFilter) ([]SemanticSearchHit, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	candidates := limit * 4
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	// Examine more of the graph so filtered searches still fill candidates.
+	if _, err := tx.Exec(ctx, `SELECT set_config('hnsw.ef_search', $1, true)`,
+		strconv.Itoa(max(100, candidates))); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, `
+		WITH q AS (
+			SELECT $1::vector AS vec, to_tsquery('english', $3) AS tsq
+		), vec AS (
+			SELECT e.transcription_id, e.call_start_time
+			FROM transcript_embeddings e, q
+			WHERE e.model = $2
+			  AND ($4::int[] IS NULL OR e.system_id = ANY($4))
+			  AND ($5::int[] IS NULL OR e.tgid = ANY($5))
+			  AND ($6::timestamptz IS NULL OR e.call_start_time >= $6)
+			  AND ($7::timestamptz IS NULL OR e.call_start_time < $7)
+			  AND ($11::boolean OR NOT EXISTS (SELECT 1 FROM calls c
+				WHERE c.call_id = e.call_id AND c.start_time = e.call_start_time AND `+restrictedCallSQL+`))
+			ORDER BY e.embedding <=> q.vec
+			LIMIT $8
+		), txt AS (
+			SELECT t.id AS transcription_id, t.call_start_time
+			FROM transcriptions t
+			JOIN calls c ON c.call_id = t.call_id AND c.start_time = t.call_start_time, q
+			WHERE $3 <> '' AND t.is_primary AND t.search_vector @@ q.tsq
+			  AND ($4::int[] IS NULL OR c.system_id = ANY($4))
+			  AND ($5::int[] IS NULL OR c.tgid = ANY($5))
+			  AND ($6::timestamptz IS NULL OR t.call_start_time >= $6)
+			  AND ($7::timestamptz IS NULL OR t.call_start_time < $7)
+			  AND ($11::boolean OR NOT `+restrictedCallSQL+`)
+			ORDER BY ts_rank(t.search_vector, q.tsq, 32) DESC
+			LIMIT $8
+		), cand AS (
+			SELECT * FROM vec UNION SELECT * FROM txt
+		), scored AS (
+			SELECT cand.transcription_id, cand.call_start_time,
+				COALESCE(1 - (e.embedding <=> q.vec), 0) AS vector_score,
+				CASE WHEN $3 = '' THEN 0 ELSE ts_rank(t.search_vector, q.tsq, 32) END AS text_score
+			FROM cand
+			CROSS JOIN q
+			JOIN transcriptions t ON t.id = cand.transcription_id
+			LEFT JOIN transcript_embeddings e
+				ON e.transcription_id = cand.transcription_id AND e.call_start_time = cand.call_start_time AND e.model = $2
+		)
+		SELECT t.id, t.call_id, t.text, t.source, t.is_primary,
+			t.confidence, t.language, t.model, t.provider,
+			t.word_count, t.duration_ms, t.provider_ms, t.words, t.created_at,
+			c.system_id, COALESCE(c.system_name, ''), c.tgid,
+			COALESCE(c.tg_alpha_tag, ''), c.start_time, c.duration,
+			s.vector_score, s.text_score,
+			$9 * s.vector_score + (1 - $9) * s.text_score AS score
+		FROM scored s
+		JOIN transcriptions t ON t.id = s.transcription_id
+		JOIN calls c ON c.call_id = t.call_id AND c.start_time = t.call_start_time
+		WHERE $11::boolean OR NOT `+restrictedCallSQL+`
+		ORDER BY score DESC, t.call_start_time DESC
+		LIMIT $10
+	`, vectorLiteral(vec), model, anyWordTSQuery(query),
+		pqIntArray(filter.SystemIDs), pqIntArray(filter.Tgids), filter.StartTime, filter.EndTime,
+		candidates, filter.VectorWeight, limit, filter.IncludeRestricted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []SemanticSearchHit{}
+	for rows.Next() {
+		var h SemanticSearchHit
+		var textScore float32
+		if err := rows.Scan(
+			&h.ID, &h.CallID, &h.Text, &h.Source, &h.IsPrimary,
+			&h.Confidence, &h.Language, &h.Model, &h.Provider,
+			&h.WordCount, &h.DurationMs, &h.ProviderMs, &h.Words, &h.CreatedAt,
+			&h.CallSystemID, &h.CallSystemName, &h.CallTgid,
+			&h.CallTgAlphaTag, &h.CallStartTime, &h.CallDuration,
+			&h.VectorScore, &textScore, &h.Score,
+		); err != nil {
+			return nil, err
+		}
+		h.TextScore = float64(textScore)
+		h.Rank = textScore
+		hits = append(hits, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	ptrs := make([]*TranscriptionAPI, len(hits))
+	for i := range hits {
+		ptrs[i] = &hits[i].TranscriptionAPI
+	}
+	if err := db.loadTranscriptionUrgency(ctx, ptrs); err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+// vectorLiteral formats v in pgvector's text form, "[0.1,0.2,...]".
+func vectorLiteral(v []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, f := range v {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(f), 'g', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
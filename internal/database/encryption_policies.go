@@ -0,0 +1,190 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Encryption policies decide what is kept for encrypted calls on a system
+// (Tgid 0) or a single talkgroup, which overrides its system's policy.
+const (
+	EncryptionPolicyMetadata   = "metadata"   // record call metadata (the default)
+	EncryptionPolicySuppress   = "suppress"   // drop at ingest, keep no record
+	EncryptionPolicyRestricted = "restricted" // record, visible to admins only
+)
+
+// EncryptionPolicy is one encryption_policies row.
+type EncryptionPolicy struct {
+	SystemID  int       `json:"system_id"`
+	Tgid      int       `json:"tgid"` // 0 = whole system
+	Policy    string    `json:"policy"`
+	Note      string    `json:"note,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ValidEncryptionPolicy reports whether policy is a known policy name.
+func ValidEncryptionPolicy(policy string) bool {
+	switch policy {
+	case EncryptionPolicyMetadata, EncryptionPolicySuppress, EncryptionPolicyRestricted:
+		return true
+	}
+	return false
+}
+
+// encryptionPolicySQL is the effective policy for the row aliased as alias
+// (calls or unit_events): its talkgroup's policy, else its system's, else
+// NULL.
+func encryptionPolicySQL(alias string) string {
+	return fmt.Sprintf(`(SELECT ep.policy FROM encryption_policies ep
+		WHERE ep.system_id = %[1]s.system_id AND ep.tgid IN (COALESCE(%[1]s.tgid, 0), 0)
+		ORDER BY ep.tgid DESC LIMIT 1)`, alias)
+}
+
+// restrictedCallSQL is true for a call (aliased c) hidden from non-admins:
+// encrypted under a restricted policy, or inside its talkgroup's embargo.
+// Evaluated at query time, so a policy change applies to calls already
+// recorded.
+var restrictedCallSQL = `((COALESCE(c.encrypted, false) AND COALESCE(` +
+	encryptionPolicySQL("c") + ` = 'restricted', false)) OR ` + embargoedCallSQL + `)`
+
+// ListEncryptionPolicies returns all encryption policies.
+func (db *DB) ListEncryptionPolicies(ctx context.Context) ([]EncryptionPolicy, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT system_id, tgid, policy, COALESCE(note, ''), updated_at
+		FROM encryption_policies
+		ORDER BY system_id, tgid
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []EncryptionPolicy{}
+	for rows.Next() {
+		var p EncryptionPolicy
+		if err := rows.Scan(&p.SystemID, &p.Tgid, &p.Policy, &p.Note, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+// UpsertEncryptionPolicy creates or replaces the policy for a system
+// (tgid 0) or talkgroup.
+func (db *DB) UpsertEncryptionPolicy(ctx context.Context, systemID, tgid int, policy, note string) (*EncryptionPolicy, error) {
+	var exists bool
+	if err := db.Pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM systems WHERE system_id = $1 AND deleted_at IS NULL)`, systemID,
+	).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("system not found")
+	}
+
+	p := EncryptionPolicy{SystemID: systemID, Tgid: tgid}
+	err := db.Pool.QueryRow(ctx, `
+		INSERT INTO encryption_policies (system_id, tgid, policy, note)
+		VALUES ($1, $2, $3, NULLIF($4, ''))
+		ON CONFLICT (system_id, tgid) DO UPDATE SET
+			policy = EXCLUDED.policy,
+			note = EXCLUDED.note,
+			updated_at = now()
+		RETURNING policy, COALESCE(note, ''), updated_at
+	`, systemID, tgid, policy, note).Scan(&p.Policy, &p.Note, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DeleteEncryptionPolicy removes a policy, reverting the system or talkgroup
+// to the next broader policy. Returns whether a policy existed.
+func (db *DB) DeleteEncryptionPolicy(ctx context.Context, systemID, tgid int) (bool, error) {
+	tag, err := db.Pool.Exec(ctx,
+		`DELETE FROM encryption_policies WHERE system_id = $1 AND tgid = $2`, systemID, tgid)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// EncryptedPurgeResult counts the records PurgeSuppressedEncrypted removed.
+type EncryptedPurgeResult struct {
+	Calls      int64 `json:"calls"`
+	UnitEvents int64 `json:"unit_events"`
+}
+
+// PurgeSuppressedEncrypted deletes the already-recorded encrypted calls (with
+// their frequencies, transmissions, transcriptions and other call rows) and
+// encrypted unit events of systemID whose effective policy is suppress, and
+// clears their encrypted counts from unit_encryption_daily. Calls under a
+// legal hold are kept. Scans all call partitions for the system.
+func (db *DB) PurgeSuppressedEncrypted(ctx context.Context, systemID int) (*EncryptedPurgeResult, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Collect the doomed calls once; every child table is keyed on them.
+	if _, err := tx.Exec(ctx, `
+		CREATE TEMP TABLE purge_calls ON COMMIT DROP AS
+		SELECT c.call_id, c.start_time FROM calls c
+		WHERE c.system_id = $1 AND c.encrypted
+		  AND `+encryptionPolicySQL("c")+` = 'suppress'
+		  AND NOT `+legalHoldSQL("c")+`
+	`, systemID); err != nil {
+		return nil, fmt.Errorf("collect calls: %w", err)
+	}
+
+	var res EncryptedPurgeResult
+	res.Calls, err = purgeCollectedCalls(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	tag, err := tx.Exec(ctx, `
+		DELETE FROM unit_events ue
+		WHERE ue.system_id = $1 AND ue.encrypted
+		  AND `+encryptionPolicySQL("ue")+` = 'suppress'
+	`, systemID)
+	if err != nil {
+		return nil, fmt.Errorf("purge unit events: %w", err)
+	}
+	res.UnitEvents = tag.RowsAffected()
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE unit_encryption_daily d SET encrypted_calls = 0, encrypted_seconds = 0
+		WHERE d.system_id = $1 AND d.encrypted_calls > 0
+		  AND `+encryptionPolicySQL("d")+` = 'suppress'
+	`, systemID); err != nil {
+		return nil, fmt.Errorf("purge unit encryption rollup: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM unit_encryption_daily WHERE system_id = $1 AND encrypted_calls = 0 AND clear_calls = 0
+	`, systemID); err != nil {
+		return nil, fmt.Errorf("purge unit encryption rollup: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return &res, nil
+}
+
+// CallRestricted reports whether a call is hidden from non-admins by a
+// restricted encryption policy or a talkgroup embargo.
+func (db *DB) CallRestricted(ctx context.Context, ref CallRef) (bool, error) {
+	ref, err := db.ResolveCallRef(ctx, ref)
+	if err != nil {
+		return false, err
+	}
+	var restricted bool
+	err = db.Pool.QueryRow(ctx, `
+		SELECT `+restrictedCallSQL+` FROM calls c WHERE c.call_id = $1 AND c.start_time = $2
+	`, ref.CallID, ref.StartTime).Scan(&restricted)
+	return restricted, err
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// EnrichmentHook is one enrichment_hooks row: a URL POSTed each call_end on
+// SystemID (every system when nil) and Tgids (every talkgroup when empty)
+// whose payload satisfies Condition, if set.
+// The JSON object it returns is merged into the call's metadata_json. After
+// FailureThreshold consecutive failures the hook is skipped for Cooldown
+// seconds.
+type EnrichmentHook struct {
+	ID               int               `json:"id"`
+	Name             string            `json:"name"`
+	URL              string            `json:"url"`
+	Headers          map[string]string `json:"headers"`
+	SystemID         *int              `json:"system_id"`
+	Tgids            []int             `json:"tgids"`
+	Condition        string            `json:"condition"` // expression on the call_end payload; "" = always
+	TimeoutMs        int               `json:"timeout_ms"`
+	FailureThreshold int               `json:"failure_threshold"`
+	Cooldown         int               `json:"cooldown_s"`
+	Enabled          bool              `json:"enabled"`
+	CreatedAt        time.Time         `json:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at"`
+}
+
+// Matches reports whether the hook applies to a call.
+func (h *EnrichmentHook) Matches(systemID, tgid int) bool {
+	if h.SystemID != nil && *h.SystemID != systemID {
+		return false
+	}
+	if len(h.Tgids) == 0 {
+		return true
+	}
+	for _, t := range h.Tgids {
+		if t == tgid {
+			return true
+		}
+	}
+	return false
+}
+
+const enrichmentHookColumns = `id, name, url, headers, system_id, tgids, condition, timeout_ms, failure_threshold,
+	cooldown_s, enabled, created_at, updated_at`
+
+func scanEnrichmentHook(row pgx.Row, h *EnrichmentHook) error {
+	var headers []byte
+	if err := row.Scan(&h.ID, &h.Name, &h.URL, &headers, &h.SystemID, &h.Tgids, &h.Condition, &h.TimeoutMs,
+		&h.FailureThreshold, &h.Cooldown, &h.Enabled, &h.CreatedAt, &h.UpdatedAt); err != nil {
+		return err
+	}
+	return json.Unmarshal(headers, &h.Headers)
+}
+
+// ListEnrichmentHooks returns all enrichment hooks in ID order, the order
+// their results are merged in.
+func (db *DB) ListEnrichmentHooks(ctx context.Context) ([]EnrichmentHook, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT `+enrichmentHookColumns+` FROM enrichment_hooks ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	hooks := []EnrichmentHook{}
+	for rows.Next() {
+		var h EnrichmentHook
+		if err := scanEnrichmentHook(rows, &h); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, h)
+	}
+	return hooks, rows.Err()
+}
+
+// GetEnrichmentHook returns one hook, or "enrichment hook not found".
+func (db *DB) GetEnrichmentHook(ctx context.Context, id int) (*EnrichmentHook, error) {
+	var h EnrichmentHook
+	err := scanEnrichmentHook(db.Pool.QueryRow(ctx, `SELECT `+enrichmentHookColumns+` FROM enrichment_hooks WHERE id = $1`, id), &h)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("enrichment hook not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// CreateEnrichmentHook stores a new hook and returns its ID. Returns
+// "enrichment hook name already exists" on a duplicate name.
+func (db *DB) CreateEnrichmentHook(ctx context.Context, h *EnrichmentHook) (int, error) {
+	headers, err := json.Marshal(h.Headers)
+	if err != nil {
+		return 0, err
+	}
+	var id int
+	err = db.Pool.QueryRow(ctx, `
+		INSERT INTO enrichment_hooks (name, url, headers, system_id, tgids, condition, timeout_ms,
+			failure_threshold, cooldown_s, enabled)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id
+	`, h.Name, h.URL, headers, h.SystemID, h.Tgids, h.Condition, h.TimeoutMs, h.FailureThreshold, h.Cooldown, h.Enabled).Scan(&id)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return 0, fmt.Errorf("enrichment hook name already exists")
+	}
+	return id, err
+}
+
+// UpdateEnrichmentHook replaces a hook's settings. Returns "enrichment hook
+// not found" or "enrichment hook name already exists".
+func (db *DB) UpdateEnrichmentHook(ctx context.Context, h *EnrichmentHook) error {
+	headers, err := json.Marshal(h.Headers)
+	if err != nil {
+		return err
+	}
+	tag, err := db.Pool.Exec(ctx, `
+		UPDATE enrichment_hooks
+		SET name = $2, url = $3, headers = $4, system_id = $5, tgids = $6, condition = $7, timeout_ms = $8,
+			failure_threshold = $9, cooldown_s = $10, enabled = $11, updated_at = now()
+		WHERE id = $1
+	`, h.ID, h.Name, h.URL, headers, h.SystemID, h.Tgids, h.Condition, h.TimeoutMs, h.FailureThreshold, h.Cooldown, h.Enabled)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return fmt.Errorf("enrichment hook name already exists")
+	}
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("enrichment hook not found")
+	}
+	return nil
+}
+
+// DeleteEnrichmentHook removes a hook. Fields it already merged into calls
+// are kept.
+func (db *DB) DeleteEnrichmentHook(ctx context.Context, id int) error {
+	tag, err := db.Pool.Exec(ctx, `DELETE FROM enrichment_hooks WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("enrichment hook not found")
+	}
+	return nil
+}
+
+// MergeCallMetadata merges fields (a JSON object) into a call's
+// metadata_json, replacing keys it already has. startTime may be off by a
+// few seconds (TR adjusts it between call_start and call_end); it only
+// narrows the partitions searched.
+func (db *DB) MergeCallMetadata(ctx context.Context, callID int64, startTime time.Time, fields json.RawMessage) error {
+	_, err := db.Pool.Exec(ctx, `
+		UPDATE calls
+		SET metadata_json = CASE WHEN jsonb_typeof(metadata_json) = 'object' THEN metadata_json ELSE '{}'::jsonb END || $3::jsonb
+		WHERE call_id = $1 AND start_time BETWEEN $2::timestamptz - interval '1 minute' AND $2::timestamptz + interval '1 minute'
+	`, callID, startTime, fields)
+	return err
+}
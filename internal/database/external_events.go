@@ -0,0 +1,421 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ExternalEvent is an event from another receiver (ADS-B, AIS, ACARS, ...),
+// linked by correlation rules to the calls made around it.
+type ExternalEvent struct {
+	ID         int64               `json:"id"`
+	Source     string              `json:"source"`
+	Kind       string              `json:"kind,omitempty"`
+	EventKey   string              `json:"event_key,omitempty"`
+	Subject    string              `json:"subject,omitempty"`
+	Label      string              `json:"label,omitempty"`
+	EventTime  time.Time           `json:"event_time"`
+	Latitude   *float64            `json:"latitude,omitempty"`
+	Longitude  *float64            `json:"longitude,omitempty"`
+	Data       json.RawMessage     `json:"data,omitempty"`
+	ReceivedAt time.Time           `json:"received_at"`
+	CallCount  int                 `json:"call_count"`
+	Calls      []ExternalEventCall `json:"calls,omitempty"`
+}
+
+// ExternalEventCall is a call linked to an external event, with the rule
+// that linked it.
+type ExternalEventCall struct {
+	CallID     int64     `json:"call_id"`
+	StartTime  time.Time `json:"start_time"`
+	SystemID   int       `json:"system_id"`
+	Tgid       int       `json:"tgid"`
+	TgAlphaTag string    `json:"tg_alpha_tag,omitempty"`
+	Duration   *float32  `json:"duration,omitempty"`
+	RuleID     int       `json:"rule_id"`
+}
+
+// ExternalEventRef is the summary of an external event shown alongside calls
+// (call detail, CAD incidents, timeline exports). CallIDs are the calls of
+// the requested set it is linked to.
+type ExternalEventRef struct {
+	ID        int64           `json:"id"`
+	Source    string          `json:"source"`
+	Kind      string          `json:"kind,omitempty"`
+	Subject   string          `json:"subject,omitempty"`
+	Label     string          `json:"label,omitempty"`
+	EventTime time.Time       `json:"event_time"`
+	Latitude  *float64        `json:"latitude,omitempty"`
+	Longitude *float64        `json:"longitude,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	CallIDs   []int64         `json:"call_ids"`
+}
+
+// ExternalEventFilter selects events for ListExternalEvents.
+type ExternalEventFilter struct {
+	Source    string
+	Kind      string
+	Subject   string
+	StartTime *time.Time // event_time >= StartTime
+	EndTime   *time.Time // event_time < EndTime
+	Limit     int
+	Offset    int
+}
+
+// ExternalCorrelationRule links external events from Source (and Kind, when
+// set) to the calls on SystemID and Tgids (every talkgroup when empty) that
+// started from WindowBefore seconds before the event to WindowAfter seconds
+// after it. With MaxDistanceKm, only events positioned within that distance
+// of the call's site are linked.
+type ExternalCorrelationRule struct {
+	ID            int       `json:"id"`
+	Name          string    `json:"name"`
+	Source        string    `json:"source"`
+	Kind          string    `json:"kind"`
+	SystemID      int       `json:"system_id"`
+	Tgids         []int     `json:"tgids"`
+	WindowBefore  int       `json:"window_before_s"`
+	WindowAfter   int       `json:"window_after_s"`
+	MaxDistanceKm *float64  `json:"max_distance_km"`
+	Enabled       bool      `json:"enabled"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	CallLinks     int       `json:"call_links"`
+}
+
+// InsertExternalEvents stores a batch of events. An event whose event_key
+// was already stored for its source is skipped and the existing event's ID
+// returned in its place. Returns the IDs in input order and the number of
+// new events.
+func (db *DB) InsertExternalEvents(ctx context.Context, events []ExternalEvent) (ids []int64, inserted int, err error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	ids = make([]int64, 0, len(events))
+	for _, e := range events {
+		var data []byte
+		if len(e.Data) > 0 {
+			data = e.Data
+		}
+		var id int64
+		err := tx.QueryRow(ctx, `
+			INSERT INTO external_events (source, kind, event_key, subject, label, event_time, latitude, longitude, data)
+			VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (source, event_key) WHERE event_key IS NOT NULL DO NOTHING
+			RETURNING id
+		`, e.Source, e.Kind, e.EventKey, e.Subject, e.Label, e.EventTime, e.Latitude, e.Longitude, data).Scan(&id)
+		switch {
+		case err == nil:
+			inserted++
+		case errors.Is(err, pgx.ErrNoRows):
+			if err := tx.QueryRow(ctx, `
+				SELECT id FROM external_events WHERE source = $1 AND event_key = $2
+			`, e.Source, e.EventKey).Scan(&id); err != nil {
+				return nil, 0, err
+			}
+		default:
+			return nil, 0, fmt.Errorf("insert external event: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, inserted, tx.Commit(ctx)
+}
+
+// externalDistanceKmSQL is the great-circle distance between event e and
+// site s. NULL when either has no position, so a rule with max_distance_km
+// never links an unpositioned event.
+const externalDistanceKmSQL = `2 * 6371 * asin(LEAST(1, sqrt(
+	power(sin(radians(e.latitude - s.latitude) / 2), 2) +
+	cos(radians(s.latitude)) * cos(radians(e.latitude)) *
+	power(sin(radians(e.longitude - s.longitude) / 2), 2))))`
+
+// CorrelateExternalEvents applies the enabled correlation rules (only ruleID
+// when non-zero) to the events received since `since` and to those whose
+// window may still be receiving calls. Already linked calls are kept, so it
+// can run repeatedly. Returns the number of new links.
+func (db *DB) CorrelateExternalEvents(ctx context.Context, since time.Time, ruleID int) (int64, error) {
+	tag, err := db.Pool.Exec(ctx, `
+		INSERT INTO external_event_calls (event_id, call_id, call_start_time, rule_id)
+		SELECT e.id, c.call_id, c.start_time, r.id
+		FROM external_correlation_rules r
+		JOIN external_events e ON e.source = r.source
+		                      AND (r.kind = '' OR e.kind = r.kind)
+		JOIN calls c ON c.system_id = r.system_id
+		            AND (cardinality(r.tgids) = 0 OR c.tgid = ANY(r.tgids))
+		            AND c.start_time >= e.event_time - make_interval(secs => r.window_before_s)
+		            AND c.start_time <= e.event_time + make_interval(secs => r.window_after_s)
+		LEFT JOIN sites s ON s.site_id = c.site_id
+		WHERE r.enabled
+		  AND ($2 = 0 OR r.id = $2)
+		  AND (e.received_at >= $1 OR e.event_time >= $1 - make_interval(secs => r.window_after_s))
+		  AND (r.max_distance_km IS NULL OR `+externalDistanceKmSQL+` <= r.max_distance_km)
+		ON CONFLICT DO NOTHING
+	`, since, ruleID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+const externalEventColumns = `e.id, e.source, e.kind, COALESCE(e.event_key, ''), e.subject, e.label,
+	e.event_time, e.latitude, e.longitude, e.data, e.received_at,
+	(SELECT count(*) FROM external_event_calls ec WHERE ec.event_id = e.id)`
+
+func scanExternalEvent(row pgx.Row, e *ExternalEvent) error {
+	var data []byte
+	if err := row.Scan(&e.ID, &e.Source, &e.Kind, &e.EventKey, &e.Subject, &e.Label,
+		&e.EventTime, &e.Latitude, &e.Longitude, &data, &e.ReceivedAt, &e.CallCount); err != nil {
+		return err
+	}
+	if data != nil {
+		e.Data = data
+	}
+	return nil
+}
+
+// ListExternalEvents returns events matching the filter, newest first, with
+// the total count.
+func (db *DB) ListExternalEvents(ctx context.Context, f ExternalEventFilter) ([]ExternalEvent, int, error) {
+	const where = `
+		WHERE ($1 = '' OR e.source = $1)
+		  AND ($2 = '' OR e.kind = $2)
+		  AND ($3 = '' OR e.subject = $3)
+		  AND ($4::timestamptz IS NULL OR e.event_time >= $4)
+		  AND ($5::timestamptz IS NULL OR e.event_time < $5)`
+	args := []any{f.Source, f.Kind, f.Subject, f.StartTime, f.EndTime}
+
+	var total int
+	if err := db.Pool.QueryRow(ctx, `SELECT count(*) FROM external_events e`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := db.Pool.Query(ctx, `SELECT `+externalEventColumns+` FROM external_events e`+where+`
+		ORDER BY e.event_time DESC, e.id DESC
+		LIMIT $6 OFFSET $7`, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	events := []ExternalEvent{}
+	for rows.Next() {
+		var e ExternalEvent
+		if err := scanExternalEvent(rows, &e); err != nil {
+			return nil, 0, err
+		}
+		events = append(events, e)
+	}
+	return events, total, rows.Err()
+}
+
+// GetExternalEvent returns one event with its linked calls. Calls hidden by
+// a restricted encryption policy are left out unless includeRestricted.
+func (db *DB) GetExternalEvent(ctx context.Context, id int64, includeRestricted bool) (*ExternalEvent, error) {
+	var e ExternalEvent
+	err := scanExternalEvent(db.Pool.QueryRow(ctx, `SELECT `+externalEventColumns+` FROM external_events e WHERE e.id = $1`, id), &e)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("external event not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT c.call_id, c.start_time, c.system_id, c.tgid, COALESCE(c.tg_alpha_tag, ''), c.duration, ec.rule_id
+		FROM external_event_calls ec
+		JOIN calls c ON c.call_id = ec.call_id AND c.start_time = ec.call_start_time
+		WHERE ec.event_id = $1
+		  AND ($2 OR NOT `+restrictedCallSQL+`)
+		ORDER BY c.start_time
+	`, id, includeRestricted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	e.Calls = []ExternalEventCall{}
+	for rows.Next() {
+		var c ExternalEventCall
+		if err := rows.Scan(&c.CallID, &c.StartTime, &c.SystemID, &c.Tgid, &c.TgAlphaTag, &c.Duration, &c.RuleID); err != nil {
+			return nil, err
+		}
+		e.Calls = append(e.Calls, c)
+	}
+	e.CallCount = len(e.Calls)
+	return &e, rows.Err()
+}
+
+// DeleteExternalEvent removes an event and its call links.
+func (db *DB) DeleteExternalEvent(ctx context.Context, id int64) error {
+	tag, err := db.Pool.Exec(ctx, `DELETE FROM external_events WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("external event not found")
+	}
+	return nil
+}
+
+// ListCallsExternalEvents returns the external events linked to any of the
+// given calls, in event time order.
+func (db *DB) ListCallsExternalEvents(ctx context.Context, refs []CallRef) ([]ExternalEventRef, error) {
+	events := []ExternalEventRef{}
+	if len(refs) == 0 {
+		return events, nil
+	}
+	callIDs := make([]int64, len(refs))
+	startTimes := make([]time.Time, len(refs))
+	for i, ref := range refs {
+		callIDs[i] = ref.CallID
+		startTimes[i] = ref.StartTime
+	}
+	rows, err := db.Pool.Query(ctx, `
+		SELECT e.id, e.source, e.kind, e.subject, e.label, e.event_time, e.latitude, e.longitude, e.data,
+			array_agg(DISTINCT ec.call_id ORDER BY ec.call_id)
+		FROM unnest($1::bigint[], $2::timestamptz[]) AS r(call_id, start_time)
+		JOIN external_event_calls ec ON ec.call_id = r.call_id AND ec.call_start_time = r.start_time
+		JOIN external_events e ON e.id = ec.event_id
+		GROUP BY e.id
+		ORDER BY e.event_time, e.id
+	`, callIDs, startTimes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e ExternalEventRef
+		var data []byte
+		if err := rows.Scan(&e.ID, &e.Source, &e.Kind, &e.Subject, &e.Label, &e.EventTime,
+			&e.Latitude, &e.Longitude, &data, &e.CallIDs); err != nil {
+			return nil, err
+		}
+		if data != nil {
+			e.Data = data
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// ListCADIncidentExternalEvents returns the external events linked to an
+// incident's calls. Calls hidden by a restricted encryption policy are
+// skipped unless includeRestricted.
+func (db *DB) ListCADIncidentExternalEvents(ctx context.Context, incidentID int64, includeRestricted bool) ([]ExternalEventRef, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT c.call_id, c.start_time
+		FROM cad_incident_calls ic
+		JOIN calls c ON c.call_id = ic.call_id AND c.start_time = ic.call_start_time
+		WHERE ic.incident_id = $1
+		  AND ($2 OR NOT `+restrictedCallSQL+`)
+	`, incidentID, includeRestricted)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (CallRef, error) {
+		var ref CallRef
+		err := row.Scan(&ref.CallID, &ref.StartTime)
+		return ref, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db.ListCallsExternalEvents(ctx, refs)
+}
+
+const externalRuleColumns = `r.id, r.name, r.source, r.kind, r.system_id, r.tgids, r.window_before_s,
+	r.window_after_s, r.max_distance_km, r.enabled, r.created_at, r.updated_at,
+	(SELECT count(*) FROM external_event_calls ec WHERE ec.rule_id = r.id)`
+
+func scanExternalRule(row pgx.Row, r *ExternalCorrelationRule) error {
+	return row.Scan(&r.ID, &r.Name, &r.Source, &r.Kind, &r.SystemID, &r.Tgids, &r.WindowBefore,
+		&r.WindowAfter, &r.MaxDistanceKm, &r.Enabled, &r.CreatedAt, &r.UpdatedAt, &r.CallLinks)
+}
+
+// ListExternalCorrelationRules returns all correlation rules.
+func (db *DB) ListExternalCorrelationRules(ctx context.Context) ([]ExternalCorrelationRule, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT `+externalRuleColumns+` FROM external_correlation_rules r ORDER BY r.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	rules := []ExternalCorrelationRule{}
+	for rows.Next() {
+		var r ExternalCorrelationRule
+		if err := scanExternalRule(rows, &r); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// GetExternalCorrelationRule returns one rule, or "correlation rule not found".
+func (db *DB) GetExternalCorrelationRule(ctx context.Context, id int) (*ExternalCorrelationRule, error) {
+	var r ExternalCorrelationRule
+	err := scanExternalRule(db.Pool.QueryRow(ctx, `SELECT `+externalRuleColumns+` FROM external_correlation_rules r WHERE r.id = $1`, id), &r)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("correlation rule not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// CreateExternalCorrelationRule stores a new rule and returns its ID.
+func (db *DB) CreateExternalCorrelationRule(ctx context.Context, r *ExternalCorrelationRule) (int, error) {
+	var id int
+	err := db.Pool.QueryRow(ctx, `
+		INSERT INTO external_correlation_rules (name, source, kind, system_id, tgids, window_before_s,
+			window_after_s, max_distance_km, enabled)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`, r.Name, r.Source, r.Kind, r.SystemID, r.Tgids, r.WindowBefore, r.WindowAfter, r.MaxDistanceKm, r.Enabled).Scan(&id)
+	return id, err
+}
+
+// UpdateExternalCorrelationRule replaces a rule's settings and drops the
+// links it made, so they can be rebuilt under the new settings. Returns
+// "correlation rule not found" if it doesn't exist.
+func (db *DB) UpdateExternalCorrelationRule(ctx context.Context, r *ExternalCorrelationRule) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE external_correlation_rules
+		SET name = $2, source = $3, kind = $4, system_id = $5, tgids = $6, window_before_s = $7,
+			window_after_s = $8, max_distance_km = $9, enabled = $10, updated_at = now()
+		WHERE id = $1
+	`, r.ID, r.Name, r.Source, r.Kind, r.SystemID, r.Tgids, r.WindowBefore, r.WindowAfter, r.MaxDistanceKm, r.Enabled)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("correlation rule not found")
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM external_event_calls WHERE rule_id = $1`, r.ID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// DeleteExternalCorrelationRule removes a rule and the links it made.
+func (db *DB) DeleteExternalCorrelationRule(ctx context.Context, id int) error {
+	tag, err := db.Pool.Exec(ctx, `DELETE FROM external_correlation_rules WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("correlation rule not found")
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// feedLookback bounds feed item queries so partition pruning keeps them cheap.
+// Talkgroups quieter than this simply show a shorter feed.
+const feedLookback = 30 * 24 * time.Hour
+
+// Feed is a named, publicly renderable view over a set of talkgroups.
+type Feed struct {
+	ID           int       `json:"id"`
+	Slug         string    `json:"slug"`
+	Title        string    `json:"title"`
+	Description  string    `json:"description,omitempty"`
+	SystemID     *int      `json:"system_id,omitempty"`
+	Tgids        []int     `json:"tgids"`
+	DelaySeconds int       `json:"delay_seconds"`
+	MaxItems     int       `json:"max_items"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// FeedPatch holds optional fields for UpdateFeed. Nil fields are left unchanged.
+type FeedPatch struct {
+	Title        *string
+	Description  *string
+	SystemID     *int // 0 clears the system restriction
+	Tgids        []int
+	DelaySeconds *int
+	MaxItems     *int
+}
+
+// FeedItem is a completed call published in a feed.
+type FeedItem struct {
+	CallID        int64
+	SystemID      int
+	SystemName    string
+	Tgid          int
+	TgAlphaTag    string
+	TgDescription string
+	StartTime     time.Time
+	Duration      *float32
+	Emergency     bool
+	AudioPath     string // audio_file_path or call_filename; used for the enclosure MIME type
+	AudioFileSize *int
+	Transcript    string
+}
+
+const feedColumns = `id, slug, title, COALESCE(description, ''), system_id, tgids,
+	delay_seconds, max_items, created_at, updated_at`
+
+func scanFeed(row pgx.Row) (*Feed, error) {
+	var f Feed
+	err := row.Scan(&f.ID, &f.Slug, &f.Title, &f.Description, &f.SystemID, &f.Tgids,
+		&f.DelaySeconds, &f.MaxItems, &f.CreatedAt, &f.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// ListFeeds returns all configured feeds ordered by slug.
+func (db *DB) ListFeeds(ctx context.Context) ([]Feed, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT `+feedColumns+` FROM feeds ORDER BY slug`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feeds := []Feed{}
+	for rows.Next() {
+		f, err := scanFeed(rows)
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, *f)
+	}
+	return feeds, rows.Err()
+}
+
+// GetFeed returns a feed by slug.
+func (db *DB) GetFeed(ctx context.Context, slug string) (*Feed, error) {
+	f, err := scanFeed(db.Pool.QueryRow(ctx, `SELECT `+feedColumns+` FROM feeds WHERE slug = $1`, slug))
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("feed not found")
+	}
+	return f, err
+}
+
+// CreateFeed inserts a feed and fills in its generated fields.
+func (db *DB) CreateFeed(ctx context.Context, f *Feed) error {
+	err := db.Pool.QueryRow(ctx, `
+		INSERT INTO feeds (slug, title, description, system_id, tgids, delay_seconds, max_items)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at
+	`, f.Slug, f.Title, f.Description, f.SystemID, f.Tgids, f.DelaySeconds, f.MaxItems).
+		Scan(&f.ID, &f.CreatedAt, &f.UpdatedAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return fmt.Errorf("feed already exists")
+	}
+	return err
+}
+
+// UpdateFeed applies patch to the feed identified by slug and returns the result.
+func (db *DB) UpdateFeed(ctx context.Context, slug string, patch FeedPatch) (*Feed, error) {
+	f, err := scanFeed(db.Pool.QueryRow(ctx, `
+		UPDATE feeds SET
+			title         = COALESCE($2, title),
+			description   = CASE WHEN $3::text IS NULL THEN description ELSE NULLIF($3, '') END,
+			system_id     = CASE WHEN $4::int IS NULL THEN system_id ELSE NULLIF($4, 0) END,
+			tgids         = COALESCE($5, tgids),
+			delay_seconds = COALESCE($6, delay_seconds),
+			max_items     = COALESCE($7, max_items),
+			updated_at    = now()
+		WHERE slug = $1
+		RETURNING `+feedColumns,
+		slug, patch.Title, patch.Description, patch.SystemID, pqIntArray(patch.Tgids), patch.DelaySeconds, patch.MaxItems))
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("feed not found")
+	}
+	return f, err
+}
+
+// DeleteFeed removes a feed by slug.
+func (db *DB) DeleteFeed(ctx context.Context, slug string) error {
+	tag, err := db.Pool.Exec(ctx, `DELETE FROM feeds WHERE slug = $1`, slug)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("feed not found")
+	}
+	return nil
+}
+
+// ListFeedItems returns the newest completed, unencrypted calls with audio on
+// the feed's talkgroups that started at or before cutoff (now minus the feed delay)
+// and are past any talkgroup embargo.
+func (db *DB) ListFeedItems(ctx context.Context, f *Feed, cutoff time.Time) ([]FeedItem, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT c.call_id, c.system_id, COALESCE(c.system_name, ''), c.tgid,
+			COALESCE(c.tg_alpha_tag, ''), COALESCE(c.tg_description, ''),
+			c.start_time, c.duration, COALESCE(c.emergency, false),
+			COALESCE(c.audio_file_path, c.call_filename, ''), c.audio_file_size, COALESCE(c.transcription_text, '')
+		FROM calls c
+		WHERE c.tgid = ANY($1)
+		  AND ($2::int IS NULL OR c.system_id = $2)
+		  AND c.start_time <= $3 AND c.start_time > $4
+		  AND (c.audio_file_path IS NOT NULL OR c.call_filename IS NOT NULL)
+		  AND COALESCE(c.encrypted, false) = false
+		  AND NOT `+embargoedCallSQL+`
+		ORDER BY c.start_time DESC
+		LIMIT $5
+	`, f.Tgids, f.SystemID, cutoff, cutoff.Add(-feedLookback), f.MaxItems)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []FeedItem{}
+	for rows.Next() {
+		var it FeedItem
+		if err := rows.Scan(&it.CallID, &it.SystemID, &it.SystemName, &it.Tgid,
+			&it.TgAlphaTag, &it.TgDescription,
+			&it.StartTime, &it.Duration, &it.Emergency,
+			&it.AudioPath, &it.AudioFileSize, &it.Transcript); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+// FeedIncludesCall reports whether callID is published in feed f as of cutoff.
+// Used to gate public audio access to calls the feed actually exposes.
+func (db *DB) FeedIncludesCall(ctx context.Context, f *Feed, callID int64, cutoff time.Time) (bool, error) {
+	var ok bool
+	err := db.Pool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM calls c
+			WHERE c.call_id = $1
+			  AND c.tgid = ANY($2)
+			  AND ($3::int IS NULL OR c.system_id = $3)
+			  AND c.start_time <= $4 AND c.start_time > $5
+			  AND COALESCE(c.encrypted, false) = false
+			  AND NOT `+embargoedCallSQL+`
+		)
+	`, callID, f.Tgids, f.SystemID, cutoff, cutoff.Add(-feedLookback)).Scan(&ok)
+	return ok, err
+}
@@ -0,0 +1,299 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Forwarding states of a call sent to a downstream rdio-scanner (call_forwards).
+const (
+	ForwardPending  = "pending"  // failing, retried
+	ForwardDone     = "done"     // accepted downstream
+	ForwardRejected = "rejected" // refused downstream
+)
+
+// ForwardSystem is one forward_systems row: whether a system's calls are
+// forwarded and the system ID they're sent as.
+type ForwardSystem struct {
+	SystemID     int       `json:"system_id"`
+	SystemName   string    `json:"system_name"`
+	Enabled      bool      `json:"enabled"`
+	RemoteSystem *int      `json:"remote_system"` // nil = same as system_id
+	EnabledAt    time.Time `json:"enabled_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+const forwardSystemColumns = `fs.system_id, COALESCE(s.name, ''), fs.enabled, fs.remote_system, fs.enabled_at, fs.updated_at`
+
+func scanForwardSystem(row pgx.Row) (*ForwardSystem, error) {
+	var fs ForwardSystem
+	if err := row.Scan(&fs.SystemID, &fs.SystemName, &fs.Enabled, &fs.RemoteSystem,
+		&fs.EnabledAt, &fs.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &fs, nil
+}
+
+// ListForwardSystems returns the forwarding settings of every configured
+// system.
+func (db *DB) ListForwardSystems(ctx context.Context) ([]ForwardSystem, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+forwardSystemColumns+`
+		FROM forward_systems fs
+		JOIN systems s ON s.system_id = fs.system_id
+		ORDER BY fs.system_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	systems := []ForwardSystem{}
+	for rows.Next() {
+		fs, err := scanForwardSystem(rows)
+		if err != nil {
+			return nil, err
+		}
+		systems = append(systems, *fs)
+	}
+	return systems, rows.Err()
+}
+
+// UpsertForwardSystem enables or disables forwarding for a system. Turning it
+// on (or configuring it for the first time) resets enabled_at, so calls
+// recorded while forwarding was off are not sent.
+func (db *DB) UpsertForwardSystem(ctx context.Context, systemID int, enabled bool, remoteSystem *int) (*ForwardSystem, error) {
+	fs, err := scanForwardSystem(db.Pool.QueryRow(ctx, `
+		WITH up AS (
+			INSERT INTO forward_systems (system_id, enabled, remote_system)
+			SELECT system_id, $2, $3 FROM systems WHERE system_id = $1
+			ON CONFLICT (system_id) DO UPDATE SET
+				enabled       = EXCLUDED.enabled,
+				remote_system = EXCLUDED.remote_system,
+				enabled_at    = CASE WHEN forward_systems.enabled THEN forward_systems.enabled_at ELSE now() END,
+				updated_at    = now()
+			RETURNING *
+		)
+		SELECT `+forwardSystemColumns+`
+		FROM up fs
+		JOIN systems s ON s.system_id = fs.system_id
+	`, systemID, enabled, remoteSystem))
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("system not found")
+	}
+	return fs, err
+}
+
+// DeleteForwardSystem stops forwarding a system and forgets its settings.
+// Returns whether it was configured.
+func (db *DB) DeleteForwardSystem(ctx context.Context, systemID int) (bool, error) {
+	tag, err := db.Pool.Exec(ctx, `DELETE FROM forward_systems WHERE system_id = $1`, systemID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ForwardCandidate is a finished call on a forwarded system not yet accepted
+// downstream, with what its rdio-scanner upload needs.
+type ForwardCandidate struct {
+	Ref          CallRef
+	RemoteSystem int
+	SysName      string
+	Tgid         int
+	Freq         *int64
+	Source       *int // first unit heard
+	AlphaTag     string
+	Description  string
+	Tag          string
+	Group        string
+	AudioPath    string
+	Sources      json.RawMessage // rdio-scanner sources: [{pos, src}]
+	Frequencies  json.RawMessage // rdio-scanner frequencies: [{freq, pos, len, errorCount, spikeCount}]
+	Attempts     int
+}
+
+// ListForwardCandidates returns calls with audio on enabled systems, started
+// since `since` (and since forwarding was enabled), that ended before
+// `before` and aren't done or rejected, oldest first. Restricted calls are
+// never sent and embargoed ones wait for their embargo to pass. A call that
+// failed is retried retryAfter × attempts (at most 10×) after its last
+// attempt.
+func (db *DB) ListForwardCandidates(ctx context.Context, since, before time.Time, retryAfter time.Duration, limit int) ([]ForwardCandidate, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT c.call_id, c.start_time, COALESCE(fs.remote_system, c.system_id),
+			COALESCE(c.system_name, ''), c.tgid, c.freq,
+			(SELECT t.src FROM call_transmissions t
+				WHERE t.call_id = c.call_id AND t.call_start_time = c.start_time
+				ORDER BY t.pos LIMIT 1),
+			COALESCE(c.tg_alpha_tag, ''), COALESCE(c.tg_description, ''),
+			COALESCE(c.tg_tag, ''), COALESCE(c.tg_group, ''),
+			c.audio_file_path,
+			(SELECT json_agg(json_build_object('pos', COALESCE(t.pos, 0), 'src', t.src) ORDER BY t.pos)
+				FROM call_transmissions t
+				WHERE t.call_id = c.call_id AND t.call_start_time = c.start_time),
+			(SELECT json_agg(json_build_object(
+					'freq', f.freq, 'pos', COALESCE(f.pos, 0), 'len', COALESCE(f.len, 0),
+					'errorCount', COALESCE(f.error_count, 0), 'spikeCount', COALESCE(f.spike_count, 0))
+					ORDER BY f.pos)
+				FROM call_frequencies f
+				WHERE f.call_id = c.call_id AND f.call_start_time = c.start_time),
+			COALESCE(cf.attempts, 0)
+		FROM calls c
+		JOIN forward_systems fs ON fs.system_id = c.system_id AND fs.enabled
+		LEFT JOIN call_forwards cf
+			ON cf.call_id = c.call_id AND cf.call_start_time = c.start_time
+		WHERE c.start_time >= $1 AND c.start_time >= fs.enabled_at
+		  AND c.stop_time IS NOT NULL AND c.stop_time < $2
+		  AND c.merged_into IS NULL
+		  AND COALESCE(c.audio_file_path, '') <> ''
+		  AND NOT `+restrictedCallSQL+`
+		  AND (cf.call_id IS NULL OR (cf.state = 'pending'
+			AND cf.updated_at < now() - LEAST(cf.attempts, 10) * $3::interval))
+		ORDER BY c.start_time
+		LIMIT $4
+	`, since, before, retryAfter, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []ForwardCandidate
+	for rows.Next() {
+		var c ForwardCandidate
+		var sources, freqs []byte
+		if err := rows.Scan(&c.Ref.CallID, &c.Ref.StartTime, &c.RemoteSystem, &c.SysName, &c.Tgid,
+			&c.Freq, &c.Source, &c.AlphaTag, &c.Description, &c.Tag, &c.Group,
+			&c.AudioPath, &sources, &freqs, &c.Attempts); err != nil {
+			return nil, err
+		}
+		c.Sources, c.Frequencies = sources, freqs
+		result = append(result, c)
+	}
+	return result, rows.Err()
+}
+
+// MarkCallForwarded records that the downstream accepted the call.
+func (db *DB) MarkCallForwarded(ctx context.Context, ref CallRef) error {
+	_, err := db.Pool.Exec(ctx, `
+		INSERT INTO call_forwards (call_id, call_start_time, state, updated_at)
+		VALUES ($1, $2, 'done', now())
+		ON CONFLICT (call_id, call_start_time) DO UPDATE SET
+			state      = 'done',
+			last_error = NULL,
+			updated_at = now()
+	`, ref.CallID, ref.StartTime)
+	return err
+}
+
+// RecordForwardFailure counts a failed attempt. A rejected call is not
+// retried until reset; otherwise it stays pending.
+func (db *DB) RecordForwardFailure(ctx context.Context, ref CallRef, msg string, rejected bool) error {
+	state := ForwardPending
+	if rejected {
+		state = ForwardRejected
+	}
+	_, err := db.Pool.Exec(ctx, `
+		INSERT INTO call_forwards (call_id, call_start_time, state, attempts, last_error, updated_at)
+		VALUES ($1, $2, $3, 1, $4, now())
+		ON CONFLICT (call_id, call_start_time) DO UPDATE SET
+			state      = EXCLUDED.state,
+			attempts   = call_forwards.attempts + 1,
+			last_error = EXCLUDED.last_error,
+			updated_at = now()
+	`, ref.CallID, ref.StartTime, state, msg)
+	return err
+}
+
+// ResetForwardFailures makes failing and rejected calls eligible for an
+// immediate attempt. Returns the number of calls reset.
+func (db *DB) ResetForwardFailures(ctx context.Context) (int64, error) {
+	tag, err := db.Pool.Exec(ctx, `
+		UPDATE call_forwards SET state = 'pending', attempts = 0, updated_at = now()
+		WHERE state = 'rejected' OR (state = 'pending' AND attempts > 0)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ForwardFailure is a call whose forwarding failed or was rejected.
+type ForwardFailure struct {
+	CallID      int64     `json:"call_id"`
+	StartTime   time.Time `json:"start_time"`
+	State       string    `json:"state"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error"`
+	LastAttempt time.Time `json:"last_attempt"`
+}
+
+// ListForwardFailures returns failing and rejected calls, most recent
+// attempt first.
+func (db *DB) ListForwardFailures(ctx context.Context, limit int) ([]ForwardFailure, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT call_id, call_start_time, state, attempts, COALESCE(last_error, ''), updated_at
+		FROM call_forwards
+		WHERE state = 'rejected' OR (state = 'pending' AND attempts > 0)
+		ORDER BY updated_at DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []ForwardFailure{}
+	for rows.Next() {
+		var f ForwardFailure
+		if err := rows.Scan(&f.CallID, &f.StartTime, &f.State, &f.Attempts,
+			&f.LastError, &f.LastAttempt); err != nil {
+			return nil, err
+		}
+		result = append(result, f)
+	}
+	return result, rows.Err()
+}
+
+// ForwardStats counts finished calls with audio on enabled systems in the
+// forwarding window by state.
+type ForwardStats struct {
+	Forwarded     int64      `json:"forwarded"`      // accepted downstream
+	Pending       int64      `json:"pending"`        // not yet forwarded (including failing and embargoed)
+	Failing       int64      `json:"failing"`        // at least one failed attempt, still retried
+	Rejected      int64      `json:"rejected"`       // refused downstream
+	OldestPending *time.Time `json:"oldest_pending"` // start_time of the oldest unforwarded call
+}
+
+// GetForwardStats counts finished calls started since `since` by forwarding
+// state. Restricted calls, which are never forwarded, are not counted.
+func (db *DB) GetForwardStats(ctx context.Context, since time.Time) (ForwardStats, error) {
+	var s ForwardStats
+	err := db.Pool.QueryRow(ctx, `
+		WITH w AS (
+			SELECT c.start_time, COALESCE(cf.state, 'pending') AS state, COALESCE(cf.attempts, 0) AS attempts
+			FROM calls c
+			JOIN forward_systems fs ON fs.system_id = c.system_id AND fs.enabled
+			LEFT JOIN call_forwards cf
+				ON cf.call_id = c.call_id AND cf.call_start_time = c.start_time
+			WHERE c.start_time >= $1 AND c.start_time >= fs.enabled_at
+			  AND c.stop_time IS NOT NULL
+			  AND c.merged_into IS NULL
+			  AND COALESCE(c.audio_file_path, '') <> ''
+			  AND (cf.call_id IS NOT NULL OR NOT (COALESCE(c.encrypted, false) AND COALESCE(`+
+		encryptionPolicySQL("c")+` = 'restricted', false)))
+		)
+		SELECT
+			count(*) FILTER (WHERE state = 'done'),
+			count(*) FILTER (WHERE state = 'pending'),
+			count(*) FILTER (WHERE state = 'pending' AND attempts > 0),
+			count(*) FILTER (WHERE state = 'rejected'),
+			min(start_time) FILTER (WHERE state = 'pending')
+		FROM w
+	`, since).Scan(&s.Forwarded, &s.Pending, &s.Failing, &s.Rejected, &s.OldestPending)
+	return s, err
+}
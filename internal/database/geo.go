@@ -0,0 +1,168 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// SiteLocation is a site's map placement plus the identity fields needed to label it.
+type SiteLocation struct {
+	SiteID       int       `json:"site_id"`
+	SystemID     int       `json:"system_id"`
+	SystemName   string    `json:"system_name,omitempty"`
+	ShortName    string    `json:"short_name"`
+	InstanceID   string    `json:"instance_id"`
+	Nac          string    `json:"nac,omitempty"`
+	Latitude     *float64  `json:"latitude"`
+	Longitude    *float64  `json:"longitude"`
+	RangeRingsKm []float32 `json:"range_rings_km,omitempty"`
+}
+
+// SystemBoundary is a system's uploaded GeoJSON coverage geometry.
+type SystemBoundary struct {
+	SystemID int             `json:"system_id"`
+	Name     string          `json:"name,omitempty"`
+	GeoJSON  json.RawMessage `json:"geojson"`
+}
+
+// UnitLocation places a unit at the site that most recently heard it.
+// TR does not report GPS, so the site location is the best available fix.
+type UnitLocation struct {
+	SystemID      int       `json:"system_id"`
+	UnitID        int       `json:"unit_id"`
+	AlphaTag      string    `json:"alpha_tag,omitempty"`
+	SiteID        int       `json:"site_id"`
+	SiteShortName string    `json:"site_short_name"`
+	Latitude      float64   `json:"latitude"`
+	Longitude     float64   `json:"longitude"`
+	EventType     string    `json:"event_type"`
+	Tgid          *int      `json:"tgid,omitempty"`
+	TgAlphaTag    string    `json:"tg_alpha_tag,omitempty"`
+	Time          time.Time `json:"time"`
+}
+
+// UpdateSiteLocation sets (or clears, when lat/lon are nil) a site's map placement.
+func (db *DB) UpdateSiteLocation(ctx context.Context, siteID int, lat, lon *float64, rangeRingsKm []float32) error {
+	tag, err := db.Pool.Exec(ctx, `
+		UPDATE sites SET latitude = $2, longitude = $3, range_rings_km = $4
+		WHERE site_id = $1
+	`, siteID, lat, lon, rangeRingsKm)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("site not found")
+	}
+	return nil
+}
+
+// ListSiteLocations returns all sites on active systems with their map placement.
+// Sites without coordinates are included with nil lat/lon so callers can show
+// which sites still need placing.
+func (db *DB) ListSiteLocations(ctx context.Context, systemIDs []int) ([]SiteLocation, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT st.site_id, st.system_id, COALESCE(s.name, ''), st.short_name, st.instance_id,
+			COALESCE(st.nac, ''), st.latitude, st.longitude, st.range_rings_km
+		FROM sites st
+		JOIN systems s ON s.system_id = st.system_id AND s.deleted_at IS NULL
+		WHERE ($1::int[] IS NULL OR st.system_id = ANY($1))
+		ORDER BY st.site_id
+	`, pqIntArray(systemIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sites []SiteLocation
+	for rows.Next() {
+		var s SiteLocation
+		if err := rows.Scan(&s.SiteID, &s.SystemID, &s.SystemName, &s.ShortName, &s.InstanceID,
+			&s.Nac, &s.Latitude, &s.Longitude, &s.RangeRingsKm); err != nil {
+			return nil, err
+		}
+		sites = append(sites, s)
+	}
+	return sites, rows.Err()
+}
+
+// SetSystemBoundary stores (or clears, when geojson is nil) a system's coverage geometry.
+func (db *DB) SetSystemBoundary(ctx context.Context, systemID int, geojson json.RawMessage) error {
+	var arg any
+	if geojson != nil {
+		arg = geojson
+	}
+	tag, err := db.Pool.Exec(ctx, `
+		UPDATE systems SET boundary_geojson = $2
+		WHERE system_id = $1 AND deleted_at IS NULL
+	`, systemID, arg)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("system not found")
+	}
+	return nil
+}
+
+// ListSystemBoundaries returns the uploaded coverage geometry for active systems that have one.
+func (db *DB) ListSystemBoundaries(ctx context.Context, systemIDs []int) ([]SystemBoundary, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT system_id, COALESCE(name, ''), boundary_geojson
+		FROM systems
+		WHERE deleted_at IS NULL AND boundary_geojson IS NOT NULL
+		  AND ($1::int[] IS NULL OR system_id = ANY($1))
+		ORDER BY system_id
+	`, pqIntArray(systemIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []SystemBoundary
+	for rows.Next() {
+		var b SystemBoundary
+		if err := rows.Scan(&b.SystemID, &b.Name, &b.GeoJSON); err != nil {
+			return nil, err
+		}
+		result = append(result, b)
+	}
+	return result, rows.Err()
+}
+
+// ListUnitLocations returns the latest located event per unit within the window,
+// positioned at the coordinates of the site (matched by instance_id + sys_name)
+// that heard it. Units heard only at unplaced sites are omitted.
+func (db *DB) ListUnitLocations(ctx context.Context, systemIDs []int, activeWithinMinutes int) ([]UnitLocation, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT DISTINCT ON (ue.system_id, ue.unit_rid)
+			ue.system_id, ue.unit_rid, COALESCE(u.alpha_tag, ''),
+			st.site_id, st.short_name, st.latitude, st.longitude,
+			ue.event_type, ue.tgid, COALESCE(ue.tg_alpha_tag, ''), ue."time"
+		FROM unit_events ue
+		JOIN sites st ON st.instance_id = ue.instance_id AND st.short_name = ue.sys_name
+		LEFT JOIN units u ON u.system_id = ue.system_id AND u.unit_id = ue.unit_rid
+		WHERE ue."time" > now() - $2::interval
+		  AND st.latitude IS NOT NULL AND st.longitude IS NOT NULL
+		  AND ($1::int[] IS NULL OR ue.system_id = ANY($1))
+		ORDER BY ue.system_id, ue.unit_rid, ue."time" DESC
+	`, pqIntArray(systemIDs), strconv.Itoa(activeWithinMinutes)+" minutes")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []UnitLocation
+	for rows.Next() {
+		var u UnitLocation
+		if err := rows.Scan(&u.SystemID, &u.UnitID, &u.AlphaTag,
+			&u.SiteID, &u.SiteShortName, &u.Latitude, &u.Longitude,
+			&u.EventType, &u.Tgid, &u.TgAlphaTag, &u.Time); err != nil {
+			return nil, err
+		}
+		result = append(result, u)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SiteRemapResult describes a RemapSite change.
+type SiteRemapResult struct {
+	InstanceID    string `json:"instance_id"`
+	SysName       string `json:"sys_name"`
+	SiteID        int    `json:"site_id"`
+	OldSystemID   int    `json:"old_system_id"`
+	NewSystemID   int    `json:"new_system_id"`
+	CallsMoved    int64  `json:"calls_moved"`
+	TalkgroupsNew int64  `json:"talkgroups_created"`
+}
+
+// RemapSite repoints the site an instance reports sysName from to
+// systemID. With rewriteCalls, the site's calls (since since, if set) that
+// sit in another system are moved too, creating any talkgroups the target
+// lacks from the source's metadata; moved calls leave their call groups.
+// Unit events, affiliations and rollups are not rewritten.
+//
+// Returns errors "site not found" and "system not found" for unknown
+// targets.
+func (db *DB) RemapSite(ctx context.Context, instanceID, sysName string, systemID int, rewriteCalls bool, since *time.Time) (*SiteRemapResult, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	res := SiteRemapResult{InstanceID: instanceID, SysName: sysName, NewSystemID: systemID}
+	err = tx.QueryRow(ctx, `
+		SELECT site_id, system_id FROM sites
+		WHERE instance_id = $1 AND short_name = $2
+		FOR UPDATE
+	`, instanceID, sysName).Scan(&res.SiteID, &res.OldSystemID)
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("site not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find site: %w", err)
+	}
+
+	var exists bool
+	if err := tx.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM systems WHERE system_id = $1 AND deleted_at IS NULL)`, systemID,
+	).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("system not found")
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE sites SET system_id = $1 WHERE site_id = $2`, systemID, res.SiteID); err != nil {
+		return nil, fmt.Errorf("update site: %w", err)
+	}
+
+	if rewriteCalls {
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO talkgroups (system_id, tgid, alpha_tag, tag, "group", description, first_seen, last_seen)
+			SELECT DISTINCT ON (c.tgid) $1, c.tgid, t.alpha_tag, t.tag, t."group", t.description, now(), now()
+			FROM calls c
+			LEFT JOIN talkgroups t ON t.system_id = c.system_id AND t.tgid = c.tgid
+			WHERE c.site_id = $2 AND c.system_id <> $1
+			  AND ($3::timestamptz IS NULL OR c.start_time >= $3)
+			ORDER BY c.tgid
+			ON CONFLICT (system_id, tgid) DO NOTHING
+		`, systemID, res.SiteID, since)
+		if err != nil {
+			return nil, fmt.Errorf("create talkgroups: %w", err)
+		}
+		res.TalkgroupsNew = tag.RowsAffected()
+
+		tag, err = tx.Exec(ctx, `
+			UPDATE calls SET system_id = $1, call_group_id = NULL
+			WHERE site_id = $2 AND system_id <> $1
+			  AND ($3::timestamptz IS NULL OR start_time >= $3)
+		`, systemID, res.SiteID, since)
+		if err != nil {
+			return nil, fmt.Errorf("move calls: %w", err)
+		}
+		res.CallsMoved = tag.RowsAffected()
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return &res, nil
+}
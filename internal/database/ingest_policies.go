@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// Ingest policies decide how much of a system's calls is kept at ingest.
+// They apply before talkgroup storage policies: a system that keeps no audio
+// keeps none for any of its talkgroups.
+const (
+	IngestPolicyFull       = "full"       // audio, metadata and transcripts (the default)
+	IngestPolicyTranscript = "transcript" // metadata and transcripts; audio is transcribed, never stored
+	IngestPolicyMetadata   = "metadata"   // metadata only; audio is not even decoded
+)
+
+// ValidIngestPolicy reports whether policy is a known ingest policy name.
+func ValidIngestPolicy(policy string) bool {
+	switch policy {
+	case IngestPolicyFull, IngestPolicyTranscript, IngestPolicyMetadata:
+		return true
+	}
+	return false
+}
+
+// ListSystemIngestPolicies returns the ingest policy of every system that
+// isn't full.
+func (db *DB) ListSystemIngestPolicies(ctx context.Context) (map[int]string, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT system_id, ingest_policy FROM systems
+		WHERE ingest_policy <> 'full' AND deleted_at IS NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var policy string
+		if err := rows.Scan(&id, &policy); err != nil {
+			return nil, err
+		}
+		policies[id] = policy
+	}
+	return policies, rows.Err()
+}
+
+// SetSystemIngestPolicy sets a system's ingest policy.
+func (db *DB) SetSystemIngestPolicy(ctx context.Context, systemID int, policy string) error {
+	tag, err := db.Pool.Exec(ctx,
+		`UPDATE systems SET ingest_policy = $2 WHERE system_id = $1 AND deleted_at IS NULL`, systemID, policy)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("system not found")
+	}
+	return nil
+}
+
+// fillIngestPolicies sets the ingest policy of n systems, where at(i)
+// returns the i-th system's ID and policy field. The generated system
+// queries predate the column.
+func (db *DB) fillIngestPolicies(ctx context.Context, n int, at func(i int) (int, *string)) error {
+	policies, err := db.ListSystemIngestPolicies(ctx)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		id, policy := at(i)
+		*policy = IngestPolicyFull
+		if p, ok := policies[id]; ok {
+			*policy = p
+		}
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// InstancePolicy controls whether an instance may auto-create systems and
+// sites for sys_names it hasn't sent before.
+type InstancePolicy struct {
+	InstanceID string    `json:"instance_id"`
+	AutoCreate bool      `json:"auto_create"`
+	Note       string    `json:"note,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ListInstancePolicies returns all instance policies.
+func (db *DB) ListInstancePolicies(ctx context.Context) ([]InstancePolicy, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT instance_id, auto_create, COALESCE(note, ''), updated_at
+		FROM instance_policies
+		ORDER BY instance_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []InstancePolicy{}
+	for rows.Next() {
+		var p InstancePolicy
+		if err := rows.Scan(&p.InstanceID, &p.AutoCreate, &p.Note, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+// UpsertInstancePolicy creates or replaces an instance's policy.
+func (db *DB) UpsertInstancePolicy(ctx context.Context, instanceID string, autoCreate bool, note string) (*InstancePolicy, error) {
+	p := InstancePolicy{InstanceID: instanceID}
+	err := db.Pool.QueryRow(ctx, `
+		INSERT INTO instance_policies (instance_id, auto_create, note)
+		VALUES ($1, $2, NULLIF($3, ''))
+		ON CONFLICT (instance_id) DO UPDATE SET
+			auto_create = EXCLUDED.auto_create,
+			note = EXCLUDED.note,
+			updated_at = now()
+		RETURNING auto_create, COALESCE(note, ''), updated_at
+	`, instanceID, autoCreate, note).Scan(&p.AutoCreate, &p.Note, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DeleteInstancePolicy removes an instance's policy, reverting it to the
+// default. Returns whether a policy existed.
+func (db *DB) DeleteInstancePolicy(ctx context.Context, instanceID string) (bool, error) {
+	tag, err := db.Pool.Exec(ctx, `DELETE FROM instance_policies WHERE instance_id = $1`, instanceID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// FindSiteIdentity looks up the site an instance reports sysName from,
+// without creating anything. Returns pgx.ErrNoRows if there is none.
+func (db *DB) FindSiteIdentity(ctx context.Context, instanceID, sysName string) (systemID, siteID int, sysid string, err error) {
+	err = db.Pool.QueryRow(ctx, `
+		SELECT st.system_id, st.site_id, COALESCE(s.sysid, '')
+		FROM sites st
+		JOIN systems s ON s.system_id = st.system_id
+		WHERE st.instance_id = $1 AND st.short_name = $2
+		ORDER BY st.site_id
+		LIMIT 1
+	`, instanceID, sysName).Scan(&systemID, &siteID, &sysid)
+	return systemID, siteID, sysid, err
+}
+
+// PendingIdentity is a sys_name an instance reported but was not allowed to
+// auto-create, staged for review.
+type PendingIdentity struct {
+	ID         int        `json:"id"`
+	InstanceID string     `json:"instance_id"`
+	SysName    string     `json:"sys_name"`
+	Status     string     `json:"status"` // pending, approved, mapped, rejected
+	Messages   int64      `json:"messages"`
+	FirstSeen  time.Time  `json:"first_seen"`
+	LastSeen   time.Time  `json:"last_seen"`
+	SystemID   *int       `json:"system_id,omitempty"`
+	SiteID     *int       `json:"site_id,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// StagePendingIdentity records that instanceID sent messages messages for
+// sysName. A rejected entry stays rejected.
+func (db *DB) StagePendingIdentity(ctx context.Context, instanceID, sysName string, messages int, seen time.Time) error {
+	_, err := db.Pool.Exec(ctx, `
+		INSERT INTO pending_identities (instance_id, sys_name, messages, first_seen, last_seen)
+		VALUES ($1, $2, $3, $4, $4)
+		ON CONFLICT (instance_id, sys_name) DO UPDATE SET
+			messages  = pending_identities.messages + EXCLUDED.messages,
+			last_seen = GREATEST(pending_identities.last_seen, EXCLUDED.last_seen)
+	`, instanceID, sysName, messages, seen)
+	return err
+}
+
+const pendingIdentityColumns = `id, instance_id, sys_name, status, messages, first_seen, last_seen, system_id, site_id, resolved_at`
+
+func scanPendingIdentity(row pgx.Row) (*PendingIdentity, error) {
+	var p PendingIdentity
+	err := row.Scan(&p.ID, &p.InstanceID, &p.SysName, &p.Status, &p.Messages,
+		&p.FirstSeen, &p.LastSeen, &p.SystemID, &p.SiteID, &p.ResolvedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListPendingIdentities returns staged identities, most recently seen first.
+// An empty status returns all of them.
+func (db *DB) ListPendingIdentities(ctx context.Context, status string) ([]PendingIdentity, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+pendingIdentityColumns+`
+		FROM pending_identities
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY last_seen DESC
+	`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []PendingIdentity{}
+	for rows.Next() {
+		p, err := scanPendingIdentity(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *p)
+	}
+	return result, rows.Err()
+}
+
+// ResolvePendingIdentity approves a staged identity. With systemID nil, the
+// system and site are created as auto-create would have (status "approved");
+// otherwise a site is added to that existing system (status "mapped").
+func (db *DB) ResolvePendingIdentity(ctx context.Context, id int, systemID *int) (*PendingIdentity, error) {
+	p, err := scanPendingIdentity(db.Pool.QueryRow(ctx,
+		`SELECT `+pendingIdentityColumns+` FROM pending_identities WHERE id = $1`, id))
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("pending identity not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.Status == "approved" || p.Status == "mapped" {
+		return nil, fmt.Errorf("pending identity already %s", p.Status)
+	}
+
+	if _, err := db.UpsertInstance(ctx, p.InstanceID); err != nil {
+		return nil, fmt.Errorf("upsert instance: %w", err)
+	}
+	status := "approved"
+	var target int
+	if systemID != nil {
+		var exists bool
+		if err := db.Pool.QueryRow(ctx,
+			`SELECT EXISTS (SELECT 1 FROM systems WHERE system_id = $1 AND deleted_at IS NULL)`, *systemID,
+		).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("system not found")
+		}
+		target = *systemID
+		status = "mapped"
+	} else {
+		target, _, err = db.FindOrCreateSystem(ctx, p.InstanceID, p.SysName, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+	siteID, err := db.FindOrCreateSite(ctx, target, p.InstanceID, p.SysName)
+	if err != nil {
+		return nil, fmt.Errorf("create site: %w", err)
+	}
+
+	return scanPendingIdentity(db.Pool.QueryRow(ctx, `
+		UPDATE pending_identities SET
+			status = $2, system_id = $3, site_id = $4, resolved_at = now()
+		WHERE id = $1
+		RETURNING `+pendingIdentityColumns,
+		id, status, target, siteID))
+}
+
+// RejectPendingIdentity marks a staged identity rejected; its messages keep
+// being dropped.
+func (db *DB) RejectPendingIdentity(ctx context.Context, id int) (*PendingIdentity, error) {
+	p, err := scanPendingIdentity(db.Pool.QueryRow(ctx, `
+		UPDATE pending_identities SET status = 'rejected', resolved_at = now()
+		WHERE id = $1 AND status IN ('pending', 'rejected')
+		RETURNING `+pendingIdentityColumns, id))
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("pending identity not found or already approved")
+	}
+	return p, err
+}
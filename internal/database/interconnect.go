@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// InterconnectCallRow is a telephone interconnect call seen only on the
+// control channel. Interconnect calls have no talkgroup; they are stored with
+// tgid 0 and the requesting unit in unit_ids.
+type InterconnectCallRow struct {
+	SystemID      int
+	SiteID        *int
+	UnitID        int
+	StartTime     time.Time
+	Freq          *int64
+	SystemName    string
+	SiteShortName string
+	InstanceID    string
+}
+
+// InsertInterconnectCall inserts an interconnect call and returns its call_id.
+// The call starts with a zero duration; ExtendInterconnectCall advances it as
+// grant updates arrive.
+func (db *DB) InsertInterconnectCall(ctx context.Context, c *InterconnectCallRow) (int64, error) {
+	var callID int64
+	err := db.Pool.QueryRow(ctx, `
+		INSERT INTO calls (
+			system_id, site_id, tgid, start_time, stop_time, duration, freq,
+			unit_ids, interconnect, system_name, site_short_name, tg_alpha_tag, instance_id
+		) VALUES ($1, $2, 0, $3, $3, 0, $4, ARRAY[$5::int], true, $6, $7, 'Telephone Interconnect', $8)
+		RETURNING call_id
+	`, c.SystemID, c.SiteID, c.StartTime, c.Freq, c.UnitID,
+		c.SystemName, c.SiteShortName, c.InstanceID,
+	).Scan(&callID)
+	return callID, err
+}
+
+// ExtendInterconnectCall moves an interconnect call's stop time to lastSeen.
+func (db *DB) ExtendInterconnectCall(ctx context.Context, callID int64, startTime, lastSeen time.Time) error {
+	_, err := db.Pool.Exec(ctx, `
+		UPDATE calls SET
+			stop_time = $3,
+			duration  = EXTRACT(EPOCH FROM ($3 - start_time))::real,
+			updated_at = now()
+		WHERE call_id = $1 AND start_time = $2 AND $3 > stop_time
+	`, callID, startTime, lastSeen)
+	return err
+}
+
+// MarkCallInterconnect flags a recorded call as a telephone interconnect call
+// and adds unitID (if non-zero) to its unit_ids.
+func (db *DB) MarkCallInterconnect(ctx context.Context, callID int64, startTime time.Time, unitID int) error {
+	_, err := db.Pool.Exec(ctx, `
+		UPDATE calls SET
+			interconnect = true,
+			unit_ids = CASE
+				WHEN $3 = 0 OR $3 = ANY(COALESCE(unit_ids, '{}')) THEN unit_ids
+				ELSE array_append(COALESCE(unit_ids, '{}'), $3)
+			END,
+			updated_at = now()
+		WHERE call_id = $1 AND start_time = $2
+	`, callID, startTime, unitID)
+	return err
+}
+
+// InterconnectUsageFilter specifies filters for the interconnect usage report.
+type InterconnectUsageFilter struct {
+	SystemIDs []int
+	UnitIDs   []int
+	StartTime time.Time
+	EndTime   *time.Time
+}
+
+// UnitInterconnectUsage summarizes one unit's telephone interconnect calls.
+type UnitInterconnectUsage struct {
+	SystemID      int       `json:"system_id"`
+	SystemName    string    `json:"system_name,omitempty"`
+	UnitID        int       `json:"unit_id"`
+	UnitAlphaTag  string    `json:"unit_alpha_tag,omitempty"`
+	Calls         int       `json:"calls"`
+	TotalDuration float64   `json:"total_duration"` // seconds
+	FirstCall     time.Time `json:"first_call"`
+	LastCall      time.Time `json:"last_call"`
+}
+
+// GetInterconnectUsage groups interconnect calls by unit, heaviest users first.
+func (db *DB) GetInterconnectUsage(ctx context.Context, filter InterconnectUsageFilter) ([]UnitInterconnectUsage, error) {
+	rows, err := db.Pool.Query(ctx, `
+		WITH ic AS (
+			SELECT c.system_id, u.unit_id, c.start_time, COALESCE(c.duration, 0) AS duration
+			FROM calls c
+			CROSS JOIN LATERAL unnest(c.unit_ids) AS u(unit_id)
+			WHERE c.interconnect
+			  AND c.start_time >= $1
+			  AND ($2::timestamptz IS NULL OR c.start_time < $2)
+			  AND ($3::int[] IS NULL OR c.system_id = ANY($3))
+			  AND ($4::int[] IS NULL OR u.unit_id = ANY($4))
+		)
+		SELECT ic.system_id, COALESCE(s.name, ''), ic.unit_id, COALESCE(un.alpha_tag, ''),
+			count(*)::int, sum(ic.duration)::float8, min(ic.start_time), max(ic.start_time)
+		FROM ic
+		JOIN systems s ON s.system_id = ic.system_id
+		LEFT JOIN units un ON un.system_id = ic.system_id AND un.unit_id = ic.unit_id
+		GROUP BY ic.system_id, s.name, ic.unit_id, un.alpha_tag
+		ORDER BY sum(ic.duration) DESC, count(*) DESC
+	`, filter.StartTime, filter.EndTime, pqIntArray(filter.SystemIDs), pqIntArray(filter.UnitIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []UnitInterconnectUsage{}
+	for rows.Next() {
+		var u UnitInterconnectUsage
+		if err := rows.Scan(&u.SystemID, &u.SystemName, &u.UnitID, &u.UnitAlphaTag,
+			&u.Calls, &u.TotalDuration, &u.FirstCall, &u.LastCall); err != nil {
+			return nil, err
+		}
+		result = append(result, u)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,257 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// LegalHold is one legal_holds row. A hold covers a single call (CallID), or
+// every call matching its system, talkgroup and [StartTime, EndTime) range,
+// where an unset field matches anything. Calls covered by an active hold are
+// skipped by retention purges, unusable-audio deletion and storage policies.
+type LegalHold struct {
+	ID            int        `json:"id"`
+	Name          string     `json:"name"`
+	Reason        string     `json:"reason,omitempty"`
+	SystemID      *int       `json:"system_id"`
+	Tgid          *int       `json:"tgid"`
+	CallID        *int64     `json:"call_id"`
+	CallStartTime *time.Time `json:"call_start_time,omitempty"`
+	StartTime     *time.Time `json:"start_time"`
+	EndTime       *time.Time `json:"end_time"`
+	Active        bool       `json:"active"` // not yet released
+	CreatedBy     string     `json:"created_by"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedBy     *string    `json:"updated_by"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	ReleasedBy    *string    `json:"released_by"`
+	ReleasedAt    *time.Time `json:"released_at"`
+}
+
+// Covers reports whether an active hold covers a call.
+func (h *LegalHold) Covers(systemID, tgid int, callID int64, startTime time.Time) bool {
+	if !h.Active {
+		return false
+	}
+	if h.CallID != nil {
+		return callID == *h.CallID
+	}
+	return (h.SystemID == nil || *h.SystemID == systemID) &&
+		(h.Tgid == nil || *h.Tgid == tgid) &&
+		(h.StartTime == nil || !startTime.Before(*h.StartTime)) &&
+		(h.EndTime == nil || startTime.Before(*h.EndTime))
+}
+
+// legalHoldMatchSQL is the condition for hold lh covering call alias.
+func legalHoldMatchSQL(alias string) string {
+	return `(
+		(lh.call_id IS NOT NULL AND lh.call_id = ` + alias + `.call_id AND lh.call_start_time = ` + alias + `.start_time)
+		OR (lh.call_id IS NULL
+			AND (lh.system_id IS NULL OR lh.system_id = ` + alias + `.system_id)
+			AND (lh.tgid IS NULL OR lh.tgid = ` + alias + `.tgid)
+			AND (lh.start_time IS NULL OR ` + alias + `.start_time >= lh.start_time)
+			AND (lh.end_time IS NULL OR ` + alias + `.start_time < lh.end_time)))`
+}
+
+// legalHoldSQL is true when an active hold covers the call row alias.
+// Anything that deletes calls or their audio excludes these.
+func legalHoldSQL(alias string) string {
+	return `EXISTS (SELECT 1 FROM legal_holds lh WHERE lh.released_at IS NULL AND ` + legalHoldMatchSQL(alias) + `)`
+}
+
+const legalHoldColumns = `id, name, COALESCE(reason, ''), system_id, tgid, call_id, call_start_time,
+	start_time, end_time, released_at IS NULL, created_by, created_at, updated_by, updated_at,
+	released_by, released_at`
+
+func scanLegalHold(row pgx.Row) (*LegalHold, error) {
+	var h LegalHold
+	if err := row.Scan(&h.ID, &h.Name, &h.Reason, &h.SystemID, &h.Tgid, &h.CallID, &h.CallStartTime,
+		&h.StartTime, &h.EndTime, &h.Active, &h.CreatedBy, &h.CreatedAt, &h.UpdatedBy, &h.UpdatedAt,
+		&h.ReleasedBy, &h.ReleasedAt); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// ListLegalHolds returns holds, newest first; only active ones when
+// activeOnly is set.
+func (db *DB) ListLegalHolds(ctx context.Context, activeOnly bool) ([]LegalHold, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+legalHoldColumns+`
+		FROM legal_holds
+		WHERE NOT $1 OR released_at IS NULL
+		ORDER BY id DESC
+	`, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holds := []LegalHold{}
+	for rows.Next() {
+		h, err := scanLegalHold(rows)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, *h)
+	}
+	return holds, rows.Err()
+}
+
+// GetLegalHold returns a hold. Returns "legal hold not found" if id is unknown.
+func (db *DB) GetLegalHold(ctx context.Context, id int) (*LegalHold, error) {
+	h, err := scanLegalHold(db.Pool.QueryRow(ctx,
+		`SELECT `+legalHoldColumns+` FROM legal_holds WHERE id = $1`, id))
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("legal hold not found")
+	}
+	return h, err
+}
+
+// CreateLegalHold inserts a hold and fills in its ID and timestamps.
+func (db *DB) CreateLegalHold(ctx context.Context, h *LegalHold) error {
+	created, err := scanLegalHold(db.Pool.QueryRow(ctx, `
+		INSERT INTO legal_holds (name, reason, system_id, tgid, call_id, call_start_time, start_time, end_time, created_by)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8, $9)
+		RETURNING `+legalHoldColumns,
+		h.Name, h.Reason, h.SystemID, h.Tgid, h.CallID, h.CallStartTime, h.StartTime, h.EndTime, h.CreatedBy))
+	if err != nil {
+		return err
+	}
+	*h = *created
+	return nil
+}
+
+// LegalHoldPatch holds the fields UpdateLegalHold changes; nil leaves a
+// field as is. An empty Reason clears it.
+type LegalHoldPatch struct {
+	Name    *string
+	Reason  *string
+	EndTime *time.Time
+}
+
+// UpdateLegalHold changes an active hold's name, reason or end time and
+// records actor as its last editor. Returns "legal hold not found" or
+// "legal hold is released".
+func (db *DB) UpdateLegalHold(ctx context.Context, id int, patch LegalHoldPatch, actor string) (*LegalHold, error) {
+	h, err := scanLegalHold(db.Pool.QueryRow(ctx, `
+		UPDATE legal_holds SET
+			name       = COALESCE($2, name),
+			reason     = CASE WHEN $3::text IS NULL THEN reason ELSE NULLIF($3, '') END,
+			end_time   = COALESCE($4, end_time),
+			updated_by = $5,
+			updated_at = now()
+		WHERE id = $1 AND released_at IS NULL
+		RETURNING `+legalHoldColumns,
+		id, patch.Name, patch.Reason, patch.EndTime, actor))
+	if err == pgx.ErrNoRows {
+		return nil, db.legalHoldConflict(ctx, id)
+	}
+	return h, err
+}
+
+// ReleaseLegalHold ends a hold; the row is kept as a record of who released
+// it. Returns "legal hold not found" or "legal hold is released".
+func (db *DB) ReleaseLegalHold(ctx context.Context, id int, actor string) (*LegalHold, error) {
+	h, err := scanLegalHold(db.Pool.QueryRow(ctx, `
+		UPDATE legal_holds SET released_by = $2, released_at = now(), updated_at = now()
+		WHERE id = $1 AND released_at IS NULL
+		RETURNING `+legalHoldColumns,
+		id, actor))
+	if err == pgx.ErrNoRows {
+		return nil, db.legalHoldConflict(ctx, id)
+	}
+	return h, err
+}
+
+// legalHoldConflict explains why an update matched no active hold.
+func (db *DB) legalHoldConflict(ctx context.Context, id int) error {
+	if _, err := db.GetLegalHold(ctx, id); err != nil {
+		return err
+	}
+	return fmt.Errorf("legal hold is released")
+}
+
+// CallOnHold reports whether an active hold covers a call.
+func (db *DB) CallOnHold(ctx context.Context, callID int64, startTime time.Time) (bool, error) {
+	var held bool
+	err := db.Pool.QueryRow(ctx, `
+		SELECT `+legalHoldSQL("c")+` FROM calls c
+		WHERE c.call_id = $1 AND c.start_time = $2
+	`, callID, startTime).Scan(&held)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	return held, err
+}
+
+// LegalHoldUsage is the storage an active hold keeps.
+type LegalHoldUsage struct {
+	HoldID       int     `json:"hold_id"`
+	Name         string  `json:"name"`
+	Calls        int64   `json:"calls"`
+	AudioBytes   int64   `json:"audio_bytes"` // all audio variants
+	AudioSeconds float64 `json:"audio_seconds"`
+}
+
+// LegalHoldReport is the storage kept by active holds. Totals count each
+// call once, however many holds cover it.
+type LegalHoldReport struct {
+	Holds        []LegalHoldUsage `json:"holds"`
+	Calls        int64            `json:"calls"`
+	AudioBytes   int64            `json:"audio_bytes"`
+	AudioSeconds float64          `json:"audio_seconds"`
+}
+
+// callAudioBytesSQL is a call's stored audio size: every variant, or the
+// audio file for calls recorded before variants were tracked.
+const callAudioBytesSQL = `COALESCE(
+	(SELECT sum(v.audio_size) FROM call_audio_variants v WHERE v.call_id = c.call_id AND v.call_start_time = c.start_time),
+	c.audio_file_size, 0)`
+
+// GetLegalHoldReport sums the calls, audio bytes and audio duration covered
+// by each active hold. Scans every call partition a hold can match.
+func (db *DB) GetLegalHoldReport(ctx context.Context) (*LegalHoldReport, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT lh.id, lh.name, count(c.call_id),
+			COALESCE(sum(`+callAudioBytesSQL+`), 0)::bigint,
+			COALESCE(sum(c.duration), 0)::float8
+		FROM legal_holds lh
+		LEFT JOIN calls c ON `+legalHoldMatchSQL("c")+`
+		WHERE lh.released_at IS NULL
+		GROUP BY lh.id, lh.name
+		ORDER BY lh.id DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := &LegalHoldReport{Holds: []LegalHoldUsage{}}
+	for rows.Next() {
+		var u LegalHoldUsage
+		if err := rows.Scan(&u.HoldID, &u.Name, &u.Calls, &u.AudioBytes, &u.AudioSeconds); err != nil {
+			return nil, err
+		}
+		report.Holds = append(report.Holds, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(report.Holds) == 0 {
+		return report, nil
+	}
+
+	err = db.Pool.QueryRow(ctx, `
+		SELECT count(*), COALESCE(sum(`+callAudioBytesSQL+`), 0)::bigint, COALESCE(sum(c.duration), 0)::float8
+		FROM calls c
+		WHERE `+legalHoldSQL("c"),
+	).Scan(&report.Calls, &report.AudioBytes, &report.AudioSeconds)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLegalHoldCovers(t *testing.T) {
+	sys, tg, callID := 1, 9044, int64(42)
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	in := start.Add(time.Hour)
+
+	for _, tc := range []struct {
+		name string
+		hold LegalHold
+		sys  int
+		tgid int
+		call int64
+		at   time.Time
+		want bool
+	}{
+		{"call", LegalHold{Active: true, CallID: &callID}, 2, 1, 42, in, true},
+		{"other call", LegalHold{Active: true, CallID: &callID}, sys, tg, 43, in, false},
+		{"talkgroup", LegalHold{Active: true, SystemID: &sys, Tgid: &tg}, sys, tg, 7, in, true},
+		{"other talkgroup", LegalHold{Active: true, SystemID: &sys, Tgid: &tg}, sys, 100, 7, in, false},
+		{"other system", LegalHold{Active: true, SystemID: &sys}, 2, tg, 7, in, false},
+		{"range", LegalHold{Active: true, StartTime: &start, EndTime: &end}, 2, 100, 7, start, true},
+		{"range end is exclusive", LegalHold{Active: true, StartTime: &start, EndTime: &end}, 2, 100, 7, end, false},
+		{"open-ended", LegalHold{Active: true, SystemID: &sys, StartTime: &start}, sys, 100, 7, end.AddDate(1, 0, 0), true},
+		{"before range", LegalHold{Active: true, SystemID: &sys, StartTime: &start}, sys, 100, 7, start.Add(-time.Second), false},
+		{"released", LegalHold{SystemID: &sys, Tgid: &tg}, sys, tg, 7, in, false},
+	} {
+		if got := tc.hold.Covers(tc.sys, tc.tgid, tc.call, tc.at); got != tc.want {
+			t.Errorf("%s: Covers = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -62,13 +62,21 @@ func (db *DB) PurgeOlderThan(ctx context.Context, table, timeColumn string, rete
 	return tag.RowsAffected(), nil
 }
 
-// DropOldWeeklyPartitions finds and drops weekly partitions whose upper bound
-// is older than the given duration. Returns the names of dropped partitions.
-func (db *DB) DropOldWeeklyPartitions(ctx context.Context, parentTable string, olderThan time.Duration) ([]string, error) {
+// WeeklyPartition is a weekly child partition eligible for dropping.
+type WeeklyPartition struct {
+	Name          string    `json:"name"`
+	UpperBound    time.Time `json:"upper_bound"`
+	EstimatedRows int64     `json:"estimated_rows"` // planner estimate (pg_class.reltuples)
+}
+
+// OldWeeklyPartitions lists the weekly partitions of parentTable whose upper
+// bound is older than the given duration.
+func (db *DB) OldWeeklyPartitions(ctx context.Context, parentTable string, olderThan time.Duration) ([]WeeklyPartition, error) {
 	// Find child partitions with their upper bound timestamps
 	rows, err := db.Pool.Query(ctx, `
 		SELECT c.relname,
-		       (regexp_match(pg_get_expr(c.relpartbound, c.oid), 'TO \(''([^'']+)''\)'))[1] AS upper_bound
+		       (regexp_match(pg_get_expr(c.relpartbound, c.oid), 'TO \(''([^'']+)''\)'))[1] AS upper_bound,
+		       GREATEST(c.reltuples, 0)::bigint
 		FROM pg_inherits i
 		JOIN pg_class p ON i.inhparent = p.oid
 		JOIN pg_class c ON i.inhrelid = c.oid
@@ -82,38 +90,38 @@ func (db *DB) DropOldWeeklyPartitions(ctx context.Context, parentTable string, o
 	defer rows.Close()
 
 	cutoff := time.Now().Add(-olderThan)
-	var dropped []string
-
-	type partition struct {
-		name       string
-		upperBound string
-	}
-	var candidates []partition
-
+	var old []WeeklyPartition
 	for rows.Next() {
-		var p partition
-		if err := rows.Scan(&p.name, &p.upperBound); err != nil {
-			return dropped, err
+		var name string
+		var upperBound *string
+		var estimate int64
+		if err := rows.Scan(&name, &upperBound, &estimate); err != nil {
+			return nil, err
 		}
-		candidates = append(candidates, p)
-	}
-	if err := rows.Err(); err != nil {
-		return dropped, err
-	}
-
-	for _, p := range candidates {
-		upper, err := time.Parse("2006-01-02", p.upperBound)
+		if upperBound == nil {
+			continue
+		}
+		upper, err := time.Parse("2006-01-02", *upperBound)
 		if err != nil {
 			continue // skip partitions with unparseable bounds
 		}
 		if upper.Before(cutoff) {
-			_, err := db.Pool.Exec(ctx, fmt.Sprintf(`DROP TABLE IF EXISTS %s`, pgx.Identifier{p.name}.Sanitize()))
-			if err != nil {
-				return dropped, fmt.Errorf("drop %s: %w", p.name, err)
-			}
-			dropped = append(dropped, p.name)
+			old = append(old, WeeklyPartition{Name: name, UpperBound: upper, EstimatedRows: estimate})
 		}
 	}
+	return old, rows.Err()
+}
 
+// DropPartitions drops the named partitions. Returns the names dropped
+// before any failure.
+func (db *DB) DropPartitions(ctx context.Context, names []string) ([]string, error) {
+	var dropped []string
+	for _, name := range names {
+		_, err := db.Pool.Exec(ctx, fmt.Sprintf(`DROP TABLE IF EXISTS %s`, pgx.Identifier{name}.Sanitize()))
+		if err != nil {
+			return dropped, fmt.Errorf("drop %s: %w", name, err)
+		}
+		dropped = append(dropped, name)
+	}
 	return dropped, nil
 }
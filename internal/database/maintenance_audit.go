@@ -0,0 +1,309 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Destructive maintenance tasks, named in MAINTENANCE_DISABLED_TASKS, the
+// audit log and /admin/maintenance/tasks.
+const (
+	MaintenanceTaskDecimation         = "decimation"          // thin recorder_snapshots, decode_rates
+	MaintenanceTaskPurge              = "purge"               // RETENTION_* row purges, including calls
+	MaintenanceTaskPartitionDrop      = "partition_drop"      // old mqtt_raw_messages partitions
+	MaintenanceTaskAudioPurge         = "audio_purge"         // RETENTION_CALL_AUDIO file deletion
+	MaintenanceTaskStaleCalls         = "stale_calls"         // RECORDING calls that never finished
+	MaintenanceTaskOrphanCallGroups   = "orphan_call_groups"  // call_groups with no calls
+	MaintenanceTaskInactiveUnits      = "inactive_units"      // RETENTION_INACTIVE_UNITS archival
+	MaintenanceTaskDurationCorrection = "duration_correction" // AUDIO_DURATION_CORRECT
+)
+
+// MaintenanceTasks lists the destructive maintenance tasks in run order.
+var MaintenanceTasks = []string{
+	MaintenanceTaskDecimation,
+	MaintenanceTaskPurge,
+	MaintenanceTaskPartitionDrop,
+	MaintenanceTaskAudioPurge,
+	MaintenanceTaskStaleCalls,
+	MaintenanceTaskOrphanCallGroups,
+	MaintenanceTaskInactiveUnits,
+	MaintenanceTaskDurationCorrection,
+}
+
+// ValidMaintenanceTask reports whether task names a destructive maintenance task.
+func ValidMaintenanceTask(task string) bool {
+	for _, t := range MaintenanceTasks {
+		if t == task {
+			return true
+		}
+	}
+	return false
+}
+
+// Maintenance audit statuses. A dry-run action is written as observed; an
+// enforced one as planned before it runs, then applied or failed.
+const (
+	MaintenanceAuditPlanned  = "planned"
+	MaintenanceAuditObserved = "observed"
+	MaintenanceAuditApplied  = "applied"
+	MaintenanceAuditFailed   = "failed"
+)
+
+// MaintenancePreview is what a destructive action would touch.
+type MaintenancePreview struct {
+	Rows       int64
+	RangeStart *time.Time // time span of the rows, when the target has one
+	RangeEnd   *time.Time
+	Detail     any // extra context, e.g. partition names
+}
+
+// MaintenanceAuditEntry is one destructive maintenance action.
+type MaintenanceAuditEntry struct {
+	ID         int64           `json:"id"`
+	RunID      int64           `json:"run_id"`
+	Task       string          `json:"task"`
+	Target     string          `json:"target"`
+	Status     string          `json:"status"`
+	Planned    int64           `json:"planned"`
+	Affected   *int64          `json:"affected"`
+	RangeStart *time.Time      `json:"range_start,omitempty"`
+	RangeEnd   *time.Time      `json:"range_end,omitempty"`
+	Detail     json.RawMessage `json:"detail,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+// MaintenanceAuditFilter selects entries for ListMaintenanceAudit.
+type MaintenanceAuditFilter struct {
+	RunID     *int64
+	Task      string
+	Status    string
+	StartTime *time.Time // created_at >= StartTime
+	EndTime   *time.Time // created_at < EndTime
+	Limit     int
+	Offset    int
+}
+
+// MaintenanceTaskSetting is an admin override of a task's configured state.
+type MaintenanceTaskSetting struct {
+	Task      string    `json:"task"`
+	Enabled   bool      `json:"enabled"`
+	DryRun    bool      `json:"dry_run"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StartMaintenanceRun records the start of a maintenance run and returns its ID.
+func (db *DB) StartMaintenanceRun(ctx context.Context, trigger string, dryRun bool) (int64, error) {
+	var id int64
+	err := db.Pool.QueryRow(ctx, `
+		INSERT INTO maintenance_runs (trigger, dry_run) VALUES ($1, $2) RETURNING id
+	`, trigger, dryRun).Scan(&id)
+	return id, err
+}
+
+// FinishMaintenanceRun marks a maintenance run complete.
+func (db *DB) FinishMaintenanceRun(ctx context.Context, id int64) error {
+	_, err := db.Pool.Exec(ctx, `UPDATE maintenance_runs SET finished_at = now() WHERE id = $1`, id)
+	return err
+}
+
+// CountObservedMaintenanceRuns returns how many scheduled dry runs have
+// completed, for MAINTENANCE_OBSERVE_CYCLES.
+func (db *DB) CountObservedMaintenanceRuns(ctx context.Context) (int, error) {
+	var n int
+	err := db.Pool.QueryRow(ctx, `
+		SELECT count(*) FROM maintenance_runs
+		WHERE trigger = 'schedule' AND dry_run AND finished_at IS NOT NULL
+	`).Scan(&n)
+	return n, err
+}
+
+// InsertMaintenanceAudit writes an action's preview before it runs and
+// returns the entry's ID.
+func (db *DB) InsertMaintenanceAudit(ctx context.Context, runID int64, task, target, status string, p MaintenancePreview) (int64, error) {
+	var detail []byte
+	if p.Detail != nil {
+		var err error
+		if detail, err = json.Marshal(p.Detail); err != nil {
+			return 0, err
+		}
+	}
+	var finished *time.Time
+	if status != MaintenanceAuditPlanned {
+		now := time.Now()
+		finished = &now
+	}
+	var id int64
+	err := db.Pool.QueryRow(ctx, `
+		INSERT INTO maintenance_audit (run_id, task, target, status, planned, range_start, range_end, detail, finished_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`, runID, task, target, status, p.Rows, p.RangeStart, p.RangeEnd, detail, finished).Scan(&id)
+	return id, err
+}
+
+// FinishMaintenanceAudit records the outcome of a planned action.
+func (db *DB) FinishMaintenanceAudit(ctx context.Context, id int64, status string, affected int64, errMsg string) error {
+	_, err := db.Pool.Exec(ctx, `
+		UPDATE maintenance_audit
+		SET status = $2, affected = $3, error = NULLIF($4, ''), finished_at = now()
+		WHERE id = $1
+	`, id, status, affected, errMsg)
+	return err
+}
+
+// ListMaintenanceAudit returns audit entries matching the filter, newest
+// first, with the total count.
+func (db *DB) ListMaintenanceAudit(ctx context.Context, f MaintenanceAuditFilter) ([]MaintenanceAuditEntry, int, error) {
+	const where = `
+		WHERE ($1::bigint IS NULL OR a.run_id = $1)
+		  AND ($2 = '' OR a.task = $2)
+		  AND ($3 = '' OR a.status = $3)
+		  AND ($4::timestamptz IS NULL OR a.created_at >= $4)
+		  AND ($5::timestamptz IS NULL OR a.created_at < $5)`
+	args := []any{f.RunID, f.Task, f.Status, f.StartTime, f.EndTime}
+
+	var total int
+	if err := db.Pool.QueryRow(ctx, `SELECT count(*) FROM maintenance_audit a`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := db.Pool.Query(ctx, `
+		SELECT a.id, a.run_id, a.task, a.target, a.status, a.planned,
+			a.affected, a.range_start, a.range_end, a.detail, COALESCE(a.error, ''), a.created_at, a.finished_at
+		FROM maintenance_audit a`+where+`
+		ORDER BY a.id DESC
+		LIMIT $6 OFFSET $7`, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	entries := []MaintenanceAuditEntry{}
+	for rows.Next() {
+		var e MaintenanceAuditEntry
+		var detail []byte
+		if err := rows.Scan(&e.ID, &e.RunID, &e.Task, &e.Target, &e.Status, &e.Planned,
+			&e.Affected, &e.RangeStart, &e.RangeEnd, &detail, &e.Error, &e.CreatedAt, &e.FinishedAt); err != nil {
+			return nil, 0, err
+		}
+		if detail != nil {
+			e.Detail = detail
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
+
+// ListMaintenanceTaskSettings returns the admin task overrides by task.
+func (db *DB) ListMaintenanceTaskSettings(ctx context.Context) (map[string]MaintenanceTaskSetting, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT task, enabled, dry_run, updated_at FROM maintenance_task_settings`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	settings := make(map[string]MaintenanceTaskSetting)
+	for rows.Next() {
+		var s MaintenanceTaskSetting
+		if err := rows.Scan(&s.Task, &s.Enabled, &s.DryRun, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		settings[s.Task] = s
+	}
+	return settings, rows.Err()
+}
+
+// UpsertMaintenanceTaskSetting sets the admin override for a task.
+func (db *DB) UpsertMaintenanceTaskSetting(ctx context.Context, task string, enabled, dryRun bool) (*MaintenanceTaskSetting, error) {
+	s := MaintenanceTaskSetting{Task: task}
+	err := db.Pool.QueryRow(ctx, `
+		INSERT INTO maintenance_task_settings (task, enabled, dry_run)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (task) DO UPDATE SET enabled = $2, dry_run = $3, updated_at = now()
+		RETURNING enabled, dry_run, updated_at
+	`, task, enabled, dryRun).Scan(&s.Enabled, &s.DryRun, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// DeleteMaintenanceTaskSetting removes a task's override, returning it to its
+// configured state. Returns false if there was none.
+func (db *DB) DeleteMaintenanceTaskSetting(ctx context.Context, task string) (bool, error) {
+	tag, err := db.Pool.Exec(ctx, `DELETE FROM maintenance_task_settings WHERE task = $1`, task)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// previewRows scans count, min and max of a preview query.
+func (db *DB) previewRows(ctx context.Context, query string, args ...any) (MaintenancePreview, error) {
+	var p MaintenancePreview
+	err := db.Pool.QueryRow(ctx, query, args...).Scan(&p.Rows, &p.RangeStart, &p.RangeEnd)
+	return p, err
+}
+
+// PreviewOlderThan counts the rows PurgeOlderThan would delete.
+func (db *DB) PreviewOlderThan(ctx context.Context, table, timeColumn string, retention time.Duration) (MaintenancePreview, error) {
+	col := pgx.Identifier{timeColumn}.Sanitize()
+	return db.previewRows(ctx, fmt.Sprintf(
+		`SELECT count(*), min(%[1]s), max(%[1]s) FROM %[2]s WHERE %[1]s < now() - $1::interval`,
+		col, pgx.Identifier{table}.Sanitize(),
+	), retention)
+}
+
+// PreviewDecimateStateTable counts the rows DecimateStateTable would delete
+// in both phases.
+func (db *DB) PreviewDecimateStateTable(ctx context.Context, table, timeColumn string) (MaintenancePreview, error) {
+	return db.previewRows(ctx, fmt.Sprintf(`
+		WITH phase1 AS (
+			SELECT %[1]s AS t, row_number() OVER (PARTITION BY date_trunc('minute', %[1]s) ORDER BY %[1]s) AS rn
+			FROM %[2]s
+			WHERE %[1]s < now() - interval '7 days' AND %[1]s >= now() - interval '1 month'
+		), phase2 AS (
+			SELECT %[1]s AS t, row_number() OVER (PARTITION BY date_trunc('hour', %[1]s) ORDER BY %[1]s) AS rn
+			FROM %[2]s
+			WHERE %[1]s < now() - interval '1 month'
+		), doomed AS (
+			SELECT t FROM phase1 WHERE rn > 1
+			UNION ALL
+			SELECT t FROM phase2 WHERE rn > 1
+		)
+		SELECT count(*), min(t), max(t) FROM doomed`,
+		pgx.Identifier{timeColumn}.Sanitize(), pgx.Identifier{table}.Sanitize(),
+	))
+}
+
+// PreviewStaleCalls counts the calls PurgeStaleCalls would delete.
+func (db *DB) PreviewStaleCalls(ctx context.Context, maxAge time.Duration) (MaintenancePreview, error) {
+	return db.previewRows(ctx, `
+		SELECT count(*), min(c.start_time), max(c.start_time) FROM calls c
+		WHERE c.rec_state_type = 'RECORDING'
+		  AND c.audio_file_path IS NULL
+		  AND (c.stop_time IS NULL OR c.duration IS NULL OR c.duration = 0)
+		  AND c.start_time < $1
+		  AND NOT `+legalHoldSQL("c")+`
+	`, time.Now().Add(-maxAge))
+}
+
+// PreviewOrphanCallGroups counts the call groups PurgeOrphanCallGroups would delete.
+func (db *DB) PreviewOrphanCallGroups(ctx context.Context) (MaintenancePreview, error) {
+	return db.previewRows(ctx, `
+		SELECT count(*), min(cg.start_time), max(cg.start_time) FROM call_groups cg
+		WHERE NOT EXISTS (SELECT 1 FROM calls c WHERE c.call_group_id = cg.id)
+	`)
+}
+
+// PreviewInactiveUnits counts the units ArchiveInactiveUnits would move.
+func (db *DB) PreviewInactiveUnits(ctx context.Context, olderThan time.Duration) (MaintenancePreview, error) {
+	return db.previewRows(ctx, `
+		SELECT count(*), min(last_seen), max(last_seen) FROM units
+		WHERE last_seen < now() - $1::interval
+		  AND COALESCE(alpha_tag_source, '') <> 'manual'
+	`, olderThan)
+}
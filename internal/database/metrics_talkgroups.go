@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// MetricsTalkgroup is a talkgroup exported as per-talkgroup Prometheus
+// series, with its alpha tag and last call for seeding the exporter.
+type MetricsTalkgroup struct {
+	SystemID  int        `json:"system_id"`
+	Tgid      int        `json:"tgid"`
+	AlphaTag  string     `json:"alpha_tag"`
+	LastCall  *time.Time `json:"last_call,omitempty"` // stop (or start) time of the talkgroup's latest call
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ListMetricsTalkgroups returns the talkgroups in the metrics allowlist.
+func (db *DB) ListMetricsTalkgroups(ctx context.Context) ([]MetricsTalkgroup, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT m.system_id, m.tgid, COALESCE(t.alpha_tag, ''), last.t, m.created_at
+		FROM metrics_talkgroups m
+		LEFT JOIN talkgroups t ON t.system_id = m.system_id AND t.tgid = m.tgid
+		LEFT JOIN LATERAL (
+			SELECT COALESCE(c.stop_time, c.start_time) AS t FROM calls c
+			WHERE c.system_id = m.system_id AND c.tgid = m.tgid
+			ORDER BY c.start_time DESC
+			LIMIT 1
+		) last ON true
+		ORDER BY m.system_id, m.tgid
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []MetricsTalkgroup{}
+	for rows.Next() {
+		var m MetricsTalkgroup
+		if err := rows.Scan(&m.SystemID, &m.Tgid, &m.AlphaTag, &m.LastCall, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+// AddMetricsTalkgroup adds a talkgroup to the metrics allowlist. Returns
+// false if it was already listed.
+func (db *DB) AddMetricsTalkgroup(ctx context.Context, systemID, tgid int) (bool, error) {
+	tag, err := db.Pool.Exec(ctx, `
+		INSERT INTO metrics_talkgroups (system_id, tgid) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, systemID, tgid)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// DeleteMetricsTalkgroup removes a talkgroup from the metrics allowlist.
+// Returns whether it was listed.
+func (db *DB) DeleteMetricsTalkgroup(ctx context.Context, systemID, tgid int) (bool, error) {
+	tag, err := db.Pool.Exec(ctx,
+		`DELETE FROM metrics_talkgroups WHERE system_id = $1 AND tgid = $2`, systemID, tgid)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -74,6 +74,1223 @@ ALTER TABLE systems ADD CONSTRAINT systems_system_type_check
 		sql:   `ALTER TABLE transcriptions ADD COLUMN IF NOT EXISTS provider_ms int`,
 		check: `SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'transcriptions' AND column_name = 'provider_ms')`,
 	},
+	{
+		name: "add site geodata columns",
+		sql: `ALTER TABLE sites
+			ADD COLUMN IF NOT EXISTS latitude double precision,
+			ADD COLUMN IF NOT EXISTS longitude double precision,
+			ADD COLUMN IF NOT EXISTS range_rings_km real[]`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'sites' AND column_name = 'range_rings_km')`,
+	},
+	{
+		name:  "add systems.boundary_geojson",
+		sql:   `ALTER TABLE systems ADD COLUMN IF NOT EXISTS boundary_geojson jsonb`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'systems' AND column_name = 'boundary_geojson')`,
+	},
+	{
+		name: "create units_archive",
+		sql: `CREATE TABLE IF NOT EXISTS units_archive (
+    system_id         int          NOT NULL,
+    unit_id           int          NOT NULL,
+    alpha_tag         text,
+    alpha_tag_source  text,
+    first_seen        timestamptz,
+    last_seen         timestamptz,
+    archived_at       timestamptz  NOT NULL DEFAULT now(),
+    PRIMARY KEY (system_id, unit_id)
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'units_archive')`,
+	},
+	{
+		name: "create unit_merge_log",
+		sql: `CREATE TABLE IF NOT EXISTS unit_merge_log (
+    id                  serial       PRIMARY KEY,
+    system_id           int          NOT NULL,
+    source_unit_id      int          NOT NULL,
+    target_unit_id      int          NOT NULL,
+    events_moved        int,
+    transmissions_moved int,
+    calls_updated       int,
+    alpha_tag_kept      text,
+    performed_at        timestamptz  NOT NULL DEFAULT now(),
+    performed_by        text
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'unit_merge_log')`,
+	},
+	{
+		name: "create ingest_quarantine",
+		sql: `CREATE TABLE IF NOT EXISTS ingest_quarantine (
+    id               bigserial    PRIMARY KEY,
+    received_at      timestamptz  NOT NULL DEFAULT now(),
+    handler          text         NOT NULL,
+    topic            text         NOT NULL,
+    instance_id      text,
+    payload          bytea        NOT NULL,
+    reasons          text[]       NOT NULL,
+    status           text         NOT NULL DEFAULT 'pending'
+                                  CHECK (status IN ('pending', 'reprocessed', 'failed')),
+    reprocessed_at   timestamptz,
+    reprocess_error  text
+);
+CREATE INDEX IF NOT EXISTS idx_ingest_quarantine_received ON ingest_quarantine (received_at DESC);
+CREATE INDEX IF NOT EXISTS idx_ingest_quarantine_handler  ON ingest_quarantine (handler, received_at DESC)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'ingest_quarantine')`,
+	},
+	{
+		name: "create feeds",
+		sql: `CREATE TABLE feeds (
+    id             serial       PRIMARY KEY,
+    slug           text         NOT NULL UNIQUE,
+    title          text         NOT NULL,
+    description    text,
+    system_id      int          REFERENCES systems (system_id),
+    tgids          int[]        NOT NULL,
+    delay_seconds  int          NOT NULL DEFAULT 0 CHECK (delay_seconds >= 0),
+    max_items      int          NOT NULL DEFAULT 50 CHECK (max_items BETWEEN 1 AND 500),
+    created_at     timestamptz  NOT NULL DEFAULT now(),
+    updated_at     timestamptz  NOT NULL DEFAULT now()
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'feeds')`,
+	},
+	{
+		name: "create unit_csv_state",
+		sql: `CREATE TABLE unit_csv_state (
+    system_id      int          NOT NULL,
+    unit_id        int          NOT NULL,
+    csv_alpha_tag  text         NOT NULL,
+    synced_at      timestamptz  NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (system_id, unit_id)
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'unit_csv_state')`,
+	},
+	{
+		name: "create unit_csv_conflicts",
+		sql: `CREATE TABLE unit_csv_conflicts (
+    id             serial       PRIMARY KEY,
+    system_id      int          NOT NULL,
+    unit_id        int          NOT NULL,
+    db_alpha_tag   text,
+    csv_alpha_tag  text,
+    detected_at    timestamptz  NOT NULL DEFAULT now(),
+    resolved_at    timestamptz,
+    resolution     text         CHECK (resolution IN ('kept_db', 'kept_csv', 'converged'))
+);
+CREATE UNIQUE INDEX uq_unit_csv_conflicts_open ON unit_csv_conflicts (system_id, unit_id) WHERE resolved_at IS NULL`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'unit_csv_conflicts')`,
+	},
+	{
+		name: "add transcriptions urgency columns",
+		sql: `ALTER TABLE transcriptions
+			ADD COLUMN IF NOT EXISTS urgency_score real,
+			ADD COLUMN IF NOT EXISTS urgency_label text CHECK (urgency_label IN ('routine', 'urgent', 'emergency_language')),
+			ADD COLUMN IF NOT EXISTS urgency_signals text[],
+			ADD COLUMN IF NOT EXISTS urgency_classifier text;
+CREATE INDEX IF NOT EXISTS idx_transcriptions_urgency ON transcriptions (urgency_label, call_start_time DESC)
+    WHERE urgency_label IN ('urgent', 'emergency_language')`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'transcriptions' AND column_name = 'urgency_label')`,
+	},
+	{
+		name: "create call_index",
+		sql: `CREATE TABLE IF NOT EXISTS call_index (
+    call_id     bigint       PRIMARY KEY,
+    start_time  timestamptz  NOT NULL
+);
+CREATE OR REPLACE FUNCTION call_index_sync()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        DELETE FROM call_index WHERE call_id = OLD.call_id AND start_time = OLD.start_time;
+    ELSE
+        INSERT INTO call_index (call_id, start_time) VALUES (NEW.call_id, NEW.start_time)
+        ON CONFLICT (call_id) DO UPDATE SET start_time = EXCLUDED.start_time;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS trg_calls_index ON calls;
+CREATE TRIGGER trg_calls_index
+    AFTER INSERT OR DELETE OR UPDATE OF start_time ON calls
+    FOR EACH ROW EXECUTE FUNCTION call_index_sync();
+INSERT INTO call_index (call_id, start_time)
+SELECT call_id, start_time FROM calls
+ON CONFLICT (call_id) DO NOTHING`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'call_index')`,
+	},
+	{
+		name: "add calls audio duration columns",
+		sql: `ALTER TABLE calls
+			ADD COLUMN IF NOT EXISTS audio_duration real,
+			ADD COLUMN IF NOT EXISTS duration_mismatch boolean;
+CREATE INDEX IF NOT EXISTS idx_calls_duration_mismatch ON calls (start_time DESC) WHERE duration_mismatch`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'calls' AND column_name = 'duration_mismatch')`,
+	},
+	{
+		name: "create unit_alias_suggestions",
+		sql: `CREATE TABLE IF NOT EXISTS unit_alias_suggestions (
+    id           serial       PRIMARY KEY,
+    system_id    int          NOT NULL,
+    unit_id      int          NOT NULL,
+    alias        text         NOT NULL,
+    status       text         NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'rejected')),
+    first_seen   timestamptz  NOT NULL,
+    last_seen    timestamptz  NOT NULL,
+    reviewed_at  timestamptz,
+
+    UNIQUE (system_id, unit_id, alias)
+);
+CREATE INDEX IF NOT EXISTS idx_unit_alias_suggestions_status ON unit_alias_suggestions (status, system_id);
+CREATE TABLE IF NOT EXISTS unit_alias_evidence (
+    suggestion_id     int          NOT NULL REFERENCES unit_alias_suggestions (id) ON DELETE CASCADE,
+    transcription_id  int          NOT NULL REFERENCES transcriptions (id) ON DELETE CASCADE,
+    call_id           bigint       NOT NULL,
+    call_start_time   timestamptz  NOT NULL,
+    snippet           text         NOT NULL,
+    weight            real         NOT NULL,
+
+    PRIMARY KEY (suggestion_id, transcription_id)
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'unit_alias_evidence')`,
+	},
+	{
+		name: "create ask_audit_log",
+		sql: `CREATE TABLE IF NOT EXISTS ask_audit_log (
+    id           bigserial    PRIMARY KEY,
+    time         timestamptz  NOT NULL DEFAULT now(),
+    client_ip    text         NOT NULL,
+    question     text         NOT NULL,
+    filters      jsonb,
+    model        text         NOT NULL DEFAULT '',
+    retrieved    int          NOT NULL DEFAULT 0,
+    cited_calls  bigint[]     NOT NULL DEFAULT '{}',
+    answer       text,
+    error        text,
+    latency_ms   int          NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_ask_audit_log_time ON ask_audit_log (time DESC)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'ask_audit_log')`,
+	},
+	{
+		name: "add calls interconnect column",
+		sql: `ALTER TABLE calls ADD COLUMN IF NOT EXISTS interconnect boolean;
+CREATE INDEX IF NOT EXISTS idx_calls_interconnect ON calls (start_time DESC) WHERE interconnect`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'calls' AND column_name = 'interconnect')`,
+	},
+	{
+		name: "create instance_policies and pending_identities",
+		sql: `CREATE TABLE IF NOT EXISTS instance_policies (
+    instance_id  text         PRIMARY KEY,
+    auto_create  boolean      NOT NULL,
+    note         text,
+    updated_at   timestamptz  NOT NULL DEFAULT now()
+);
+CREATE TABLE IF NOT EXISTS pending_identities (
+    id           serial       PRIMARY KEY,
+    instance_id  text         NOT NULL,
+    sys_name     text         NOT NULL,
+    status       text         NOT NULL DEFAULT 'pending'
+                              CHECK (status IN ('pending', 'approved', 'mapped', 'rejected')),
+    messages     bigint       NOT NULL DEFAULT 0,
+    first_seen   timestamptz  NOT NULL,
+    last_seen    timestamptz  NOT NULL,
+    system_id    int,
+    site_id      int,
+    resolved_at  timestamptz,
+    UNIQUE (instance_id, sys_name)
+);
+CREATE INDEX IF NOT EXISTS idx_pending_identities_status ON pending_identities (status, last_seen DESC)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'pending_identities')`,
+	},
+	{
+		name: "create unit_encryption_daily",
+		sql: `CREATE TABLE IF NOT EXISTS unit_encryption_daily (
+    system_id          int      NOT NULL,
+    unit_id            int      NOT NULL,
+    day                date     NOT NULL,
+    tgid               int      NOT NULL,
+    encrypted_calls    int      NOT NULL DEFAULT 0,
+    encrypted_seconds  real     NOT NULL DEFAULT 0,
+    clear_calls        int      NOT NULL DEFAULT 0,
+    clear_seconds      real     NOT NULL DEFAULT 0,
+
+    PRIMARY KEY (system_id, unit_id, day, tgid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_unit_encryption_daily_day ON unit_encryption_daily (day, system_id)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'unit_encryption_daily')`,
+	},
+	{
+		name: "create audio_archive_failures",
+		sql: `CREATE TABLE IF NOT EXISTS audio_archive_failures (
+    call_id          bigint       NOT NULL,
+    call_start_time  timestamptz  NOT NULL,
+    attempts         int          NOT NULL DEFAULT 0,
+    last_error       text,
+    last_attempt     timestamptz  NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (call_id, call_start_time)
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'audio_archive_failures')`,
+	},
+	{
+		name: "create warehouse_exports",
+		sql: `CREATE TABLE IF NOT EXISTS warehouse_exports (
+    dataset      text         NOT NULL,
+    day          date         NOT NULL,
+    row_count    bigint       NOT NULL DEFAULT 0,
+    size_bytes   bigint       NOT NULL DEFAULT 0,
+    path         text         NOT NULL DEFAULT '',
+    exported_at  timestamptz  NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (dataset, day)
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'warehouse_exports')`,
+	},
+	{
+		name: "create subscription_profiles",
+		sql: `CREATE TABLE IF NOT EXISTS subscription_profiles (
+    id           serial       PRIMARY KEY,
+    owner        text         NOT NULL,
+    name         text         NOT NULL,
+    description  text,
+    filter       jsonb        NOT NULL DEFAULT '{}',
+    created_at   timestamptz  NOT NULL DEFAULT now(),
+    updated_at   timestamptz  NOT NULL DEFAULT now(),
+
+    UNIQUE (owner, name)
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'subscription_profiles')`,
+	},
+	{
+		name: "add calls stuck_mic columns",
+		sql: `ALTER TABLE calls ADD COLUMN IF NOT EXISTS stuck_mic boolean;
+ALTER TABLE calls ADD COLUMN IF NOT EXISTS speech_ratio real;
+CREATE INDEX IF NOT EXISTS idx_calls_stuck_mic ON calls (start_time DESC) WHERE stuck_mic`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'calls' AND column_name = 'stuck_mic')`,
+	},
+	{
+		name: "create encryption_policies",
+		sql: `CREATE TABLE IF NOT EXISTS encryption_policies (
+    system_id   int          NOT NULL REFERENCES systems (system_id),
+    tgid        int          NOT NULL DEFAULT 0,
+    policy      text         NOT NULL CHECK (policy IN ('metadata', 'suppress', 'restricted')),
+    note        text,
+    updated_at  timestamptz  NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (system_id, tgid)
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'encryption_policies')`,
+	},
+	{
+		name: "create system_timeseries",
+		sql: `CREATE TABLE IF NOT EXISTS system_timeseries (
+    ts      timestamptz       NOT NULL,
+    metric  text              NOT NULL,
+    label   text              NOT NULL DEFAULT '',
+    value   double precision  NOT NULL,
+
+    PRIMARY KEY (metric, label, ts)
+);
+CREATE INDEX IF NOT EXISTS idx_system_timeseries_ts ON system_timeseries (ts)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'system_timeseries')`,
+	},
+	{
+		name: "create talkgroup_storage_policies",
+		sql: `CREATE TABLE IF NOT EXISTS talkgroup_storage_policies (
+    system_id   int          NOT NULL REFERENCES systems (system_id),
+    tgid        int          NOT NULL,
+    policy      text         NOT NULL CHECK (policy IN ('full', 'transcode', 'metadata')),
+    bitrate     int,
+    note        text,
+    updated_at  timestamptz  NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (system_id, tgid)
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'talkgroup_storage_policies')`,
+	},
+	{
+		name:  "add talkgroups first_seen index",
+		sql:   `CREATE INDEX IF NOT EXISTS idx_talkgroups_first_seen ON talkgroups (first_seen DESC)`,
+		check: `SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_talkgroups_first_seen')`,
+	},
+	{
+		name:  "add units first_seen index",
+		sql:   `CREATE INDEX IF NOT EXISTS idx_units_first_seen ON units (first_seen DESC)`,
+		check: `SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_units_first_seen')`,
+	},
+	{
+		name: "create control_channel_history",
+		sql: `CREATE TABLE IF NOT EXISTS control_channel_history (
+    id          bigserial    PRIMARY KEY,
+    site_id     int          NOT NULL REFERENCES sites (site_id) ON DELETE CASCADE,
+    freq        bigint       NOT NULL,
+    prev_freq   bigint,
+    source      text         NOT NULL,
+    "time"      timestamptz  NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_control_channel_history_site_time ON control_channel_history (site_id, "time" DESC);
+CREATE INDEX IF NOT EXISTS idx_control_channel_history_time ON control_channel_history ("time" DESC)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'control_channel_history')`,
+	},
+	{
+		name: "create call_merges",
+		sql: `ALTER TABLE calls ADD COLUMN IF NOT EXISTS merged_into bigint;
+CREATE TABLE IF NOT EXISTS call_merges (
+    id                 bigserial    PRIMARY KEY,
+    system_id          int          NOT NULL REFERENCES systems (system_id),
+    tgid               int          NOT NULL,
+    unit_id            int          NOT NULL,
+    call_id            bigint       NOT NULL,
+    call_start_time    timestamptz  NOT NULL,
+    merged_call_id     bigint       NOT NULL,
+    merged_start_time  timestamptz  NOT NULL,
+    gap                real         NOT NULL,
+    merged_at          timestamptz  NOT NULL DEFAULT now(),
+    undone_at          timestamptz,
+    undone_by          text
+);
+CREATE INDEX IF NOT EXISTS idx_call_merges_merged_at ON call_merges (merged_at DESC);
+CREATE INDEX IF NOT EXISTS idx_call_merges_call ON call_merges (call_id);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_call_merges_active ON call_merges (merged_call_id, merged_start_time)
+    WHERE undone_at IS NULL`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'call_merges')`,
+	},
+	{
+		name: "create status_daily",
+		sql: `CREATE TABLE IF NOT EXISTS status_daily (
+    day                  date  NOT NULL,
+    component            text  NOT NULL,
+    operational_minutes  int   NOT NULL DEFAULT 0,
+    degraded_minutes     int   NOT NULL DEFAULT 0,
+    down_minutes         int   NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, component)
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'status_daily')`,
+	},
+	{
+		name: "create call_audio_variants",
+		sql: `CREATE TABLE IF NOT EXISTS call_audio_variants (
+    id               bigserial    PRIMARY KEY,
+    call_id          bigint       NOT NULL,
+    call_start_time  timestamptz  NOT NULL,
+    variant          text         NOT NULL,
+    audio_path       text         NOT NULL,
+    audio_type       text,
+    audio_size       int,
+    source           text         NOT NULL,
+    is_default       boolean      NOT NULL DEFAULT false,
+    created_at       timestamptz  NOT NULL DEFAULT now(),
+    UNIQUE (call_id, call_start_time, variant)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_call_audio_variants_default ON call_audio_variants (call_id, call_start_time) WHERE is_default`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'call_audio_variants')`,
+	},
+	{
+		name: "create cad_incidents",
+		sql: `CREATE TABLE IF NOT EXISTS cad_incidents (
+    id               bigserial    PRIMARY KEY,
+    received_at      timestamptz  NOT NULL DEFAULT now(),
+    format           text         NOT NULL,
+    incident_number  text,
+    incident_type    text,
+    address          text,
+    units            text,
+    dispatched_at    timestamptz  NOT NULL,
+    system_id        int          NOT NULL,
+    tgids            int[]        NOT NULL,
+    window_before_s  int          NOT NULL,
+    window_after_s   int          NOT NULL,
+    fields           jsonb,
+    sender           text,
+    subject          text,
+    body             text         NOT NULL,
+    message_id       text
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cad_incidents_message_id ON cad_incidents (message_id) WHERE message_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_cad_incidents_dispatched ON cad_incidents (dispatched_at DESC);
+CREATE TABLE IF NOT EXISTS cad_incident_calls (
+    incident_id      bigint       NOT NULL REFERENCES cad_incidents (id) ON DELETE CASCADE,
+    call_id          bigint       NOT NULL,
+    call_start_time  timestamptz  NOT NULL,
+    PRIMARY KEY (incident_id, call_id)
+);
+CREATE INDEX IF NOT EXISTS idx_cad_incident_calls_call ON cad_incident_calls (call_id, call_start_time)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'cad_incident_calls')`,
+	},
+	{
+		name: "create watch_backfill_files",
+		sql: `CREATE TABLE IF NOT EXISTS watch_backfill_files (
+    path         text         PRIMARY KEY,
+    day          date         NOT NULL,
+    instance_id  text         NOT NULL,
+    status       text         NOT NULL,
+    reason       text         NOT NULL DEFAULT '',
+    detail       text,
+    attempts     int          NOT NULL DEFAULT 1,
+    first_seen   timestamptz  NOT NULL DEFAULT now(),
+    updated_at   timestamptz  NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_watch_backfill_files_day ON watch_backfill_files (day, status)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'watch_backfill_files')`,
+	},
+	{
+		name: "add calls duration correction columns",
+		sql: `ALTER TABLE calls
+			ADD COLUMN IF NOT EXISTS original_duration real,
+			ADD COLUMN IF NOT EXISTS original_stop_time timestamptz,
+			ADD COLUMN IF NOT EXISTS duration_corrected_at timestamptz`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'calls' AND column_name = 'duration_corrected_at')`,
+	},
+	{
+		name: "add calls audio_unusable column",
+		sql: `ALTER TABLE calls ADD COLUMN IF NOT EXISTS audio_unusable text;
+CREATE INDEX IF NOT EXISTS idx_calls_audio_unusable ON calls (start_time DESC) WHERE audio_unusable IS NOT NULL`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'calls' AND column_name = 'audio_unusable')`,
+	},
+	{
+		name: "create instance_config_versions",
+		sql: `CREATE TABLE IF NOT EXISTS instance_config_versions (
+    instance_id  text         NOT NULL,
+    version      int          NOT NULL,
+    config_hash  text         NOT NULL,
+    config       jsonb        NOT NULL,
+    changes      jsonb        NOT NULL,
+    first_seen   timestamptz  NOT NULL,
+    last_seen    timestamptz  NOT NULL,
+    times_seen   int          NOT NULL DEFAULT 1,
+    PRIMARY KEY (instance_id, version)
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'instance_config_versions')`,
+	},
+	{
+		name: "create external_events",
+		sql: `CREATE TABLE IF NOT EXISTS external_events (
+    id           bigserial    PRIMARY KEY,
+    source       text         NOT NULL,
+    kind         text         NOT NULL DEFAULT '',
+    event_key    text,
+    subject      text         NOT NULL DEFAULT '',
+    label        text         NOT NULL DEFAULT '',
+    event_time   timestamptz  NOT NULL,
+    latitude     double precision,
+    longitude    double precision,
+    data         jsonb,
+    received_at  timestamptz  NOT NULL DEFAULT now()
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_external_events_key ON external_events (source, event_key) WHERE event_key IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_external_events_time ON external_events (event_time DESC);
+CREATE INDEX IF NOT EXISTS idx_external_events_received ON external_events (received_at);
+CREATE TABLE IF NOT EXISTS external_correlation_rules (
+    id               serial       PRIMARY KEY,
+    name             text         NOT NULL,
+    source           text         NOT NULL,
+    kind             text         NOT NULL DEFAULT '',
+    system_id        int          NOT NULL,
+    tgids            int[]        NOT NULL DEFAULT '{}',
+    window_before_s  int          NOT NULL,
+    window_after_s   int          NOT NULL,
+    max_distance_km  double precision,
+    enabled          boolean      NOT NULL DEFAULT true,
+    created_at       timestamptz  NOT NULL DEFAULT now(),
+    updated_at       timestamptz  NOT NULL DEFAULT now()
+);
+CREATE TABLE IF NOT EXISTS external_event_calls (
+    event_id         bigint       NOT NULL REFERENCES external_events (id) ON DELETE CASCADE,
+    call_id          bigint       NOT NULL,
+    call_start_time  timestamptz  NOT NULL,
+    rule_id          int          NOT NULL REFERENCES external_correlation_rules (id) ON DELETE CASCADE,
+    PRIMARY KEY (event_id, call_id)
+);
+CREATE INDEX IF NOT EXISTS idx_external_event_calls_call ON external_event_calls (call_id, call_start_time);
+CREATE INDEX IF NOT EXISTS idx_external_event_calls_rule ON external_event_calls (rule_id)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'external_event_calls')`,
+	},
+	{
+		name: "create maintenance_audit",
+		sql: `CREATE TABLE IF NOT EXISTS maintenance_runs (
+    id           bigserial    PRIMARY KEY,
+    trigger      text         NOT NULL,
+    dry_run      boolean      NOT NULL,
+    started_at   timestamptz  NOT NULL DEFAULT now(),
+    finished_at  timestamptz
+);
+CREATE TABLE IF NOT EXISTS maintenance_audit (
+    id           bigserial    PRIMARY KEY,
+    run_id       bigint       NOT NULL REFERENCES maintenance_runs (id),
+    task         text         NOT NULL,
+    target       text         NOT NULL,
+    status       text         NOT NULL,
+    planned      bigint       NOT NULL,
+    affected     bigint,
+    range_start  timestamptz,
+    range_end    timestamptz,
+    detail       jsonb,
+    error        text,
+    created_at   timestamptz  NOT NULL DEFAULT now(),
+    finished_at  timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_maintenance_audit_run ON maintenance_audit (run_id);
+CREATE INDEX IF NOT EXISTS idx_maintenance_audit_created ON maintenance_audit (created_at DESC);
+CREATE TABLE IF NOT EXISTS maintenance_task_settings (
+    task        text         PRIMARY KEY,
+    enabled     boolean      NOT NULL,
+    dry_run     boolean      NOT NULL DEFAULT false,
+    updated_at  timestamptz  NOT NULL DEFAULT now()
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'maintenance_task_settings')`,
+	},
+	{
+		name: "add transcriptions parent_id and diff",
+		sql: `ALTER TABLE transcriptions
+			ADD COLUMN IF NOT EXISTS parent_id int REFERENCES transcriptions (id) ON DELETE SET NULL,
+			ADD COLUMN IF NOT EXISTS diff jsonb`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'transcriptions' AND column_name = 'diff')`,
+	},
+	{
+		name: "create stt_eval_pairs",
+		sql: `CREATE TABLE IF NOT EXISTS stt_eval_pairs (
+    id                bigserial    PRIMARY KEY,
+    transcription_id  int          NOT NULL UNIQUE REFERENCES transcriptions (id) ON DELETE CASCADE,
+    hypothesis_id     int          NOT NULL REFERENCES transcriptions (id) ON DELETE CASCADE,
+    call_id           bigint       NOT NULL,
+    call_start_time   timestamptz  NOT NULL,
+    provider          text,
+    model             text,
+    reference         text         NOT NULL,
+    hypothesis        text         NOT NULL,
+    reference_words   int          NOT NULL,
+    word_errors       int          NOT NULL,
+    created_at        timestamptz  NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_stt_eval_pairs_created ON stt_eval_pairs (created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_stt_eval_pairs_model ON stt_eval_pairs (provider, model)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'stt_eval_pairs')`,
+	},
+	{
+		name: "create stt_prompt_overrides",
+		sql: `CREATE TABLE IF NOT EXISTS stt_prompt_overrides (
+    system_id   int          NOT NULL REFERENCES systems (system_id),
+    tgid        int          NOT NULL DEFAULT 0,
+    prompt      text         NOT NULL,
+    updated_at  timestamptz  NOT NULL DEFAULT now(),
+    PRIMARY KEY (system_id, tgid)
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'stt_prompt_overrides')`,
+	},
+	{
+		name: "create s3_upload_queue",
+		sql: `CREATE TABLE IF NOT EXISTS s3_upload_queue (
+    key            text         PRIMARY KEY,
+    content_type   text         NOT NULL DEFAULT '',
+    priority       smallint     NOT NULL DEFAULT 0,
+    call_time      timestamptz  NOT NULL,
+    attempts       int          NOT NULL DEFAULT 0,
+    last_error     text,
+    next_attempt   timestamptz  NOT NULL DEFAULT now(),
+    claimed_until  timestamptz,
+    failed         boolean      NOT NULL DEFAULT false,
+    created_at     timestamptz  NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_s3_upload_queue_next ON s3_upload_queue (priority DESC, call_time DESC) WHERE NOT failed`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 's3_upload_queue')`,
+	},
+	{
+		name: "create retranscribe_jobs",
+		sql: `CREATE TABLE IF NOT EXISTS retranscribe_jobs (
+    id              serial       PRIMARY KEY,
+    status          text         NOT NULL DEFAULT 'pending'
+                                 CHECK (status IN ('pending', 'running', 'completed', 'cancelled', 'failed')),
+    start_time      timestamptz  NOT NULL,
+    end_time        timestamptz  NOT NULL,
+    system_ids      int[],
+    tgids           int[],
+    provider        text         NOT NULL,
+    model           text         NOT NULL,
+    make_primary    boolean      NOT NULL DEFAULT true,
+    concurrency     int          NOT NULL DEFAULT 1,
+    total_calls     int          NOT NULL DEFAULT 0,
+    audio_seconds   double precision NOT NULL DEFAULT 0,
+    estimated_cost  double precision,
+    processed       int          NOT NULL DEFAULT 0,
+    failed          int          NOT NULL DEFAULT 0,
+    cursor_time     timestamptz,
+    cursor_call_id  bigint,
+    last_error      text,
+    created_at      timestamptz  NOT NULL DEFAULT now(),
+    started_at      timestamptz,
+    finished_at     timestamptz
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'retranscribe_jobs')`,
+	},
+	{
+		name: "create legal_holds",
+		sql: `CREATE TABLE IF NOT EXISTS legal_holds (
+    id               serial       PRIMARY KEY,
+    name             text         NOT NULL,
+    reason           text,
+    system_id        int,
+    tgid             int,
+    call_id          bigint,
+    call_start_time  timestamptz,
+    start_time       timestamptz,
+    end_time         timestamptz,
+    created_by       text         NOT NULL,
+    created_at       timestamptz  NOT NULL DEFAULT now(),
+    updated_by       text,
+    updated_at       timestamptz  NOT NULL DEFAULT now(),
+    released_by      text,
+    released_at      timestamptz
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'legal_holds')`,
+	},
+	{
+		name: "create enrichment_hooks",
+		sql: `CREATE TABLE IF NOT EXISTS enrichment_hooks (
+    id                 serial       PRIMARY KEY,
+    name               text         NOT NULL UNIQUE,
+    url                text         NOT NULL,
+    headers            jsonb        NOT NULL DEFAULT '{}',  -- header name -> value
+    system_id          int,                                 -- NULL = every system
+    tgids              int[]        NOT NULL DEFAULT '{}',  -- empty = every talkgroup
+    timeout_ms         int          NOT NULL DEFAULT 2000,
+    failure_threshold  int          NOT NULL DEFAULT 5,     -- consecutive failures that open the circuit
+    cooldown_s         int          NOT NULL DEFAULT 60,    -- how long an open circuit skips the hook
+    enabled            boolean      NOT NULL DEFAULT true,
+    created_at         timestamptz  NOT NULL DEFAULT now(),
+    updated_at         timestamptz  NOT NULL DEFAULT now()
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'enrichment_hooks')`,
+	},
+	{
+		name: "create unit_sessions",
+		sql: `CREATE TABLE IF NOT EXISTS unit_sessions (
+    id          bigserial    PRIMARY KEY,
+    system_id   int          NOT NULL,
+    unit_rid    int          NOT NULL,
+    tgid        int          NOT NULL,
+    start_time  timestamptz  NOT NULL,
+    end_time    timestamptz  NOT NULL,   -- last event so far while open
+    events      int          NOT NULL,   -- unit events folded in
+    calls       int          NOT NULL,   -- of which 'call' events
+    ended_by    text         CHECK (ended_by IN ('off', 'on', 'tgid_change', 'idle')),  -- NULL = open
+
+    UNIQUE (system_id, unit_rid, tgid, start_time)
+);
+CREATE INDEX IF NOT EXISTS idx_unit_events_time_brin ON unit_events USING brin ("time");
+CREATE INDEX IF NOT EXISTS idx_unit_sessions_unit_time ON unit_sessions (system_id, unit_rid, start_time DESC);
+CREATE INDEX IF NOT EXISTS idx_unit_sessions_tgid_time ON unit_sessions (system_id, tgid, start_time DESC);
+CREATE INDEX IF NOT EXISTS idx_unit_sessions_end_time  ON unit_sessions (end_time);
+CREATE INDEX IF NOT EXISTS idx_unit_sessions_open      ON unit_sessions (system_id, unit_rid) WHERE ended_by IS NULL;
+CREATE TABLE IF NOT EXISTS unit_session_state (
+    id                boolean      PRIMARY KEY DEFAULT true CHECK (id),  -- single row
+    compacted_through timestamptz  NOT NULL,  -- unit_events before this are folded in
+    updated_at        timestamptz  NOT NULL DEFAULT now()
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'unit_session_state')`,
+	},
+	{
+		name:  "add enrichment_hooks.condition",
+		sql:   `ALTER TABLE enrichment_hooks ADD COLUMN IF NOT EXISTS condition text NOT NULL DEFAULT ''`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'enrichment_hooks' AND column_name = 'condition')`,
+	},
+	{
+		name: "add systems.ingest_policy",
+		sql: `ALTER TABLE systems ADD COLUMN IF NOT EXISTS ingest_policy text NOT NULL DEFAULT 'full'
+			CHECK (ingest_policy IN ('full', 'transcript', 'metadata'))`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'systems' AND column_name = 'ingest_policy')`,
+	},
+	{
+		name: "create share_links",
+		sql: `CREATE TABLE IF NOT EXISTS share_links (
+    id               serial       PRIMARY KEY,
+    label            text         NOT NULL,
+    system_id        int,                       -- NULL = the talkgroups on any system
+    tgids            int[]        NOT NULL,
+    history_seconds  int          NOT NULL,     -- calls this far before creation are visible
+    expires_at       timestamptz  NOT NULL,
+    created_by       text         NOT NULL,
+    created_at       timestamptz  NOT NULL DEFAULT now(),
+    revoked_by       text,
+    revoked_at       timestamptz
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'share_links')`,
+	},
+	{
+		name: "create sources",
+		sql: `CREATE TABLE IF NOT EXISTS sources (
+    instance_id        text         NOT NULL,
+    source_num         int          NOT NULL,               -- TR's source index (calls.src_num, recorder src_num)
+    device             text         NOT NULL DEFAULT '',    -- e.g. rtl=0, serial=...
+    driver             text         NOT NULL DEFAULT '',
+    antenna            text         NOT NULL DEFAULT '',
+    center_hz          bigint       NOT NULL,
+    rate_hz            bigint       NOT NULL,
+    min_hz             bigint       NOT NULL,               -- coverage: center -/+ half the sample rate
+    max_hz             bigint       NOT NULL,
+    error_hz           int          NOT NULL DEFAULT 0,     -- configured tuning correction
+    gain               text         NOT NULL DEFAULT '',
+    analog_recorders   int          NOT NULL DEFAULT 0,
+    digital_recorders  int          NOT NULL DEFAULT 0,
+    origin             text         NOT NULL CHECK (origin IN ('mqtt', 'tr_dir')),
+    active             boolean      NOT NULL DEFAULT true,  -- in the instance's latest config
+    first_seen         timestamptz  NOT NULL DEFAULT now(),
+    last_seen          timestamptz  NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (instance_id, source_num)
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'sources')`,
+	}, {
+		name: "create call_annotations",
+		sql: `CREATE TABLE IF NOT EXISTS call_annotations (
+    id               bigserial    PRIMARY KEY,
+    call_id          bigint       NOT NULL,
+    call_start_time  timestamptz  NOT NULL,
+    type             text         NOT NULL,               -- e.g. tone_detected, plate_match
+    source           text         NOT NULL,               -- poster, e.g. a bot name
+    label            text         NOT NULL DEFAULT '',    -- human-readable summary
+    confidence       real,                                -- 0..1 when the poster has one
+    data             jsonb,
+    created_at       timestamptz  NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_call_annotations_call ON call_annotations (call_id, call_start_time);
+CREATE INDEX IF NOT EXISTS idx_call_annotations_type ON call_annotations (type, call_start_time DESC)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'call_annotations')`,
+	},
+	{
+		name: "create retention_settings",
+		sql: `CREATE TABLE IF NOT EXISTS retention_settings (
+    target             text         PRIMARY KEY,
+    retention_seconds  bigint       NOT NULL CHECK (retention_seconds >= 0),
+    updated_at         timestamptz  NOT NULL DEFAULT now()
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'retention_settings')`,
+	},
+	{
+		name: "create metrics_talkgroups",
+		sql: `CREATE TABLE IF NOT EXISTS metrics_talkgroups (
+    system_id   int          NOT NULL,
+    tgid        int          NOT NULL,
+    created_at  timestamptz  NOT NULL DEFAULT now(),
+    PRIMARY KEY (system_id, tgid)
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'metrics_talkgroups')`,
+	},
+	{
+		name: "create call_pins",
+		sql: `CREATE TABLE IF NOT EXISTS call_pins (
+    call_id          bigint       NOT NULL,
+    call_start_time  timestamptz  NOT NULL,
+    note             text         NOT NULL DEFAULT '',
+    pinned_by        text         NOT NULL DEFAULT '',
+    created_at       timestamptz  NOT NULL DEFAULT now(),
+    PRIMARY KEY (call_id, call_start_time)
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'call_pins')`,
+	},
+	{
+		name: "create call_replications",
+		sql: `CREATE TABLE IF NOT EXISTS call_replications (
+    call_id          bigint       NOT NULL,
+    call_start_time  timestamptz  NOT NULL,
+    state            text         NOT NULL DEFAULT 'pending',
+    session_path     text,
+    remote_call_id   bigint,
+    bytes_sent       bigint       NOT NULL DEFAULT 0,
+    attempts         int          NOT NULL DEFAULT 0,
+    last_error       text,
+    updated_at       timestamptz  NOT NULL DEFAULT now(),
+    PRIMARY KEY (call_id, call_start_time)
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'call_replications')`,
+	},
+	{
+		name:  "add call_replications state index",
+		sql:   `CREATE INDEX IF NOT EXISTS idx_call_replications_state ON call_replications (state, call_start_time)`,
+		check: `SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_call_replications_state')`,
+	},
+	{
+		name: "create saved_queries",
+		sql: `CREATE TABLE IF NOT EXISTS saved_queries (
+    id           serial       PRIMARY KEY,
+    owner        text         NOT NULL,
+    name         text         NOT NULL,
+    description  text,
+    filter       jsonb        NOT NULL DEFAULT '{}',
+    shared       boolean      NOT NULL DEFAULT false,
+    created_at   timestamptz  NOT NULL DEFAULT now(),
+    updated_at   timestamptz  NOT NULL DEFAULT now(),
+    UNIQUE (owner, name)
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'saved_queries')`,
+	},
+	{
+		name: "create talkgroup_embargoes",
+		sql: `CREATE TABLE IF NOT EXISTS talkgroup_embargoes (
+    system_id      int          NOT NULL REFERENCES systems (system_id),
+    tgid           int          NOT NULL,
+    delay_minutes  int          NOT NULL CHECK (delay_minutes BETWEEN 1 AND 10080),
+    note           text,
+    updated_at     timestamptz  NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (system_id, tgid)
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'talkgroup_embargoes')`,
+	},
+	{
+		name: "create forward_systems",
+		sql: `CREATE TABLE IF NOT EXISTS forward_systems (
+    system_id      int          PRIMARY KEY REFERENCES systems (system_id),
+    enabled        boolean      NOT NULL DEFAULT true,
+    remote_system  int,
+    enabled_at     timestamptz  NOT NULL DEFAULT now(),
+    updated_at     timestamptz  NOT NULL DEFAULT now()
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'forward_systems')`,
+	},
+	{
+		name: "create call_forwards",
+		sql: `CREATE TABLE IF NOT EXISTS call_forwards (
+    call_id          bigint       NOT NULL,
+    call_start_time  timestamptz  NOT NULL,
+    state            text         NOT NULL DEFAULT 'pending',
+    attempts         int          NOT NULL DEFAULT 0,
+    last_error       text,
+    updated_at       timestamptz  NOT NULL DEFAULT now(),
+    PRIMARY KEY (call_id, call_start_time)
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'call_forwards')`,
+	},
+	{
+		name:  "add call_forwards state index",
+		sql:   `CREATE INDEX IF NOT EXISTS idx_call_forwards_state ON call_forwards (state, call_start_time)`,
+		check: `SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_call_forwards_state')`,
+	},
+	{
+		name: "create duplicate_call_findings",
+		sql: `CREATE TABLE IF NOT EXISTS duplicate_call_findings (
+    id                 bigserial    PRIMARY KEY,
+    system_id          int          NOT NULL REFERENCES systems (system_id),
+    tgid               int          NOT NULL,
+    call_id            bigint       NOT NULL,
+    call_start_time    timestamptz  NOT NULL,
+    dup_call_id        bigint       NOT NULL,
+    dup_start_time     timestamptz  NOT NULL,
+    confidence         real         NOT NULL,
+    start_gap          real         NOT NULL,
+    overlap_ratio      real         NOT NULL,
+    duration_ratio     real         NOT NULL,
+    same_unit          boolean,
+    status             text         NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'grouped', 'dismissed')),
+    group_id           int,
+    previous_group_id  int,
+    found_at           timestamptz  NOT NULL DEFAULT now(),
+    resolved_at        timestamptz,
+    resolved_by        text,
+    UNIQUE (call_id, call_start_time, dup_call_id, dup_start_time)
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'duplicate_call_findings')`,
+	},
+	{
+		name:  "add duplicate_call_findings status index",
+		sql:   `CREATE INDEX IF NOT EXISTS idx_duplicate_call_findings_status ON duplicate_call_findings (status, call_start_time DESC)`,
+		check: `SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_duplicate_call_findings_status')`,
+	},
+	{
+		name: "create duplicate_call_actions",
+		sql: `CREATE TABLE IF NOT EXISTS duplicate_call_actions (
+    id                 bigserial    PRIMARY KEY,
+    finding_id         bigint       NOT NULL REFERENCES duplicate_call_findings (id) ON DELETE CASCADE,
+    action             text         NOT NULL,
+    actor              text         NOT NULL,
+    from_group_id      int,
+    to_group_id        int,
+    created_at         timestamptz  NOT NULL DEFAULT now()
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'duplicate_call_actions')`,
+	},
+	{
+		name:  "add duplicate_call_actions finding index",
+		sql:   `CREATE INDEX IF NOT EXISTS idx_duplicate_call_actions_finding ON duplicate_call_actions (finding_id)`,
+		check: `SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_duplicate_call_actions_finding')`,
+	},
+	{
+		name: "create alert_rules",
+		sql: `CREATE TABLE IF NOT EXISTS alert_rules (
+    id              serial       PRIMARY KEY,
+    name            text         NOT NULL UNIQUE,
+    keywords        text[]       NOT NULL DEFAULT '{}',
+    pattern         text         NOT NULL DEFAULT '',
+    system_id       int,
+    tgids           int[]        NOT NULL DEFAULT '{}',
+    unit_ids        int[]        NOT NULL DEFAULT '{}',
+    emergency_only  boolean      NOT NULL DEFAULT false,
+    enabled         boolean      NOT NULL DEFAULT true,
+    created_at      timestamptz  NOT NULL DEFAULT now(),
+    updated_at      timestamptz  NOT NULL DEFAULT now()
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'alert_rules')`,
+	},
+	{
+		name: "create alerts",
+		sql: `CREATE TABLE IF NOT EXISTS alerts (
+    id               bigserial    PRIMARY KEY,
+    rule_id          int          REFERENCES alert_rules (id) ON DELETE SET NULL,
+    rule_name        text         NOT NULL,
+    call_id          bigint       NOT NULL,
+    call_start_time  timestamptz  NOT NULL,
+    system_id        int          NOT NULL,
+    tgid             int          NOT NULL,
+    unit_id          int          NOT NULL DEFAULT 0,
+    emergency        boolean      NOT NULL DEFAULT false,
+    matches          text[]       NOT NULL DEFAULT '{}',
+    text             text         NOT NULL,
+    created_at       timestamptz  NOT NULL DEFAULT now()
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'alerts')`,
+	},
+	{
+		name:  "add alerts created index",
+		sql:   `CREATE INDEX IF NOT EXISTS idx_alerts_created ON alerts (created_at DESC)`,
+		check: `SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_alerts_created')`,
+	},
+	{
+		name:  "add alerts rule index",
+		sql:   `CREATE INDEX IF NOT EXISTS idx_alerts_rule ON alerts (rule_id, created_at DESC)`,
+		check: `SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_alerts_rule')`,
+	},
+	{
+		name:  "add alerts call index",
+		sql:   `CREATE INDEX IF NOT EXISTS idx_alerts_call ON alerts (call_id, call_start_time)`,
+		check: `SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_alerts_call')`,
+	},
+	{
+		name: "create webhooks",
+		sql: `CREATE TABLE IF NOT EXISTS webhooks (
+    id           serial       PRIMARY KEY,
+    name         text         NOT NULL UNIQUE,
+    url          text         NOT NULL,
+    format       text         NOT NULL DEFAULT 'generic' CHECK (format IN ('generic', 'discord', 'slack')),
+    event_types  text[]       NOT NULL,
+    system_id    int,
+    tgids        int[]        NOT NULL DEFAULT '{}',
+    secret       text         NOT NULL DEFAULT '',
+    enabled      boolean      NOT NULL DEFAULT true,
+    created_at   timestamptz  NOT NULL DEFAULT now(),
+    updated_at   timestamptz  NOT NULL DEFAULT now()
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'webhooks')`,
+	},
+	{
+		name: "create webhook_deliveries",
+		sql: `CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id               bigserial    PRIMARY KEY,
+    webhook_id       int          NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event_id         text         NOT NULL,
+    event_type       text         NOT NULL,
+    payload          jsonb        NOT NULL,
+    status           text         NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts         int          NOT NULL DEFAULT 0,
+    next_attempt_at  timestamptz  NOT NULL DEFAULT now(),
+    response_status  int,
+    last_error       text,
+    created_at       timestamptz  NOT NULL DEFAULT now(),
+    delivered_at     timestamptz
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'webhook_deliveries')`,
+	},
+	{
+		name:  "add webhook_deliveries due index",
+		sql:   `CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending'`,
+		check: `SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_webhook_deliveries_due')`,
+	},
+	{
+		name:  "add webhook_deliveries hook index",
+		sql:   `CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_hook ON webhook_deliveries (webhook_id, created_at DESC)`,
+		check: `SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_webhook_deliveries_hook')`,
+	},
+	{
+		name:  "add webhook_deliveries created index",
+		sql:   `CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_created ON webhook_deliveries (created_at)`,
+		check: `SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_webhook_deliveries_created')`,
+	},
+	{
+		name: "add systems display metadata",
+		sql: `ALTER TABLE systems
+			ADD COLUMN IF NOT EXISTS color text,
+			ADD COLUMN IF NOT EXISTS icon text,
+			ADD COLUMN IF NOT EXISTS short_label text,
+			ADD COLUMN IF NOT EXISTS display_order int NOT NULL DEFAULT 0`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'systems' AND column_name = 'display_order')`,
+	},
+	{
+		name: "add sites display metadata",
+		sql: `ALTER TABLE sites
+			ADD COLUMN IF NOT EXISTS color text,
+			ADD COLUMN IF NOT EXISTS icon text,
+			ADD COLUMN IF NOT EXISTS short_label text,
+			ADD COLUMN IF NOT EXISTS display_order int NOT NULL DEFAULT 0`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'sites' AND column_name = 'display_order')`,
+	},
+	{
+		name: "create talkgroup_groups",
+		sql: `CREATE TABLE IF NOT EXISTS talkgroup_groups (
+    name          text         NOT NULL,
+    color         text,
+    icon          text,
+    short_label   text,
+    display_order int          NOT NULL DEFAULT 0,
+    updated_at    timestamptz  NOT NULL DEFAULT now()
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'talkgroup_groups')`,
+	},
+	{
+		name:  "add talkgroup_groups name index",
+		sql:   `CREATE UNIQUE INDEX IF NOT EXISTS uq_talkgroup_groups_name ON talkgroup_groups (lower(name))`,
+		check: `SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'uq_talkgroup_groups_name')`,
+	},
+	{
+		name: "create audio_reconciliation",
+		sql: `CREATE TABLE IF NOT EXISTS audio_reconciliation (
+    call_id          bigint       NOT NULL,
+    call_start_time  timestamptz  NOT NULL,
+    system_id        int          NOT NULL,
+    tgid             int          NOT NULL,
+    status           text         NOT NULL,
+    attempts         int          NOT NULL DEFAULT 0,
+    audio_path       text,
+    checked_at       timestamptz  NOT NULL DEFAULT now(),
+    PRIMARY KEY (call_id, call_start_time)
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'audio_reconciliation')`,
+	},
+	{
+		name:  "add audio_reconciliation status index",
+		sql:   `CREATE INDEX IF NOT EXISTS idx_audio_reconciliation_status ON audio_reconciliation (status, call_start_time DESC)`,
+		check: `SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_audio_reconciliation_status')`,
+	},
+	{
+		name: "create api_keys",
+		sql: `CREATE TABLE IF NOT EXISTS api_keys (
+    id                serial       PRIMARY KEY,
+    name              text         NOT NULL,
+    prefix            text         NOT NULL,
+    key_hash          text         NOT NULL UNIQUE,
+    scope             text         NOT NULL CHECK (scope IN ('stream', 'read', 'write', 'admin')),
+    rate_limit_rps    double precision,
+    rate_limit_burst  int,
+    created_by        text         NOT NULL,
+    created_at        timestamptz  NOT NULL DEFAULT now(),
+    last_used_at      timestamptz,
+    last_used_ip      text,
+    revoked_by        text,
+    revoked_at        timestamptz
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'api_keys')`,
+	},
+	{
+		name:  "add api_keys active name index",
+		sql:   `CREATE UNIQUE INDEX IF NOT EXISTS uq_api_keys_active_name ON api_keys (lower(name)) WHERE revoked_at IS NULL`,
+		check: `SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'uq_api_keys_active_name')`,
+	},
+	{
+		name: "create unit_census_daily",
+		sql: `CREATE TABLE IF NOT EXISTS unit_census_daily (
+    system_id        int   NOT NULL,
+    unit_id          int   NOT NULL,
+    day              date  NOT NULL,
+    events           int   NOT NULL DEFAULT 0,
+    registrations    int   NOT NULL DEFAULT 0,
+    deregistrations  int   NOT NULL DEFAULT 0,
+    PRIMARY KEY (system_id, unit_id, day)
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'unit_census_daily')`,
+	},
+	{
+		name:  "add unit_census_daily day index",
+		sql:   `CREATE INDEX IF NOT EXISTS idx_unit_census_daily_day ON unit_census_daily (system_id, day)`,
+		check: `SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_unit_census_daily_day')`,
+	},
+	{
+		name: "create users",
+		sql: `CREATE TABLE IF NOT EXISTS users (
+    id                   serial       PRIMARY KEY,
+    username             text         NOT NULL,
+    display_name         text         NOT NULL DEFAULT '',
+    password_hash        text         NOT NULL,
+    role                 text         NOT NULL CHECK (role IN ('read', 'write', 'admin')),
+    preferences          jsonb        NOT NULL DEFAULT '{}',
+    disabled             boolean      NOT NULL DEFAULT false,
+    created_by           text         NOT NULL,
+    created_at           timestamptz  NOT NULL DEFAULT now(),
+    updated_at           timestamptz  NOT NULL DEFAULT now(),
+    password_changed_at  timestamptz  NOT NULL DEFAULT now(),
+    last_login_at        timestamptz,
+    last_login_ip        text
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'users')`,
+	},
+	{
+		name:  "add users username index",
+		sql:   `CREATE UNIQUE INDEX IF NOT EXISTS uq_users_username ON users (lower(username))`,
+		check: `SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'uq_users_username')`,
+	},
+	{
+		name: "create user_sessions",
+		sql: `CREATE TABLE IF NOT EXISTS user_sessions (
+    id            text         PRIMARY KEY,
+    user_id       int          NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    csrf_token    text         NOT NULL,
+    created_at    timestamptz  NOT NULL DEFAULT now(),
+    expires_at    timestamptz  NOT NULL,
+    last_seen_at  timestamptz  NOT NULL DEFAULT now(),
+    ip            text,
+    user_agent    text
+)`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'user_sessions')`,
+	},
+	{
+		name:  "add user_sessions user index",
+		sql:   `CREATE INDEX IF NOT EXISTS idx_user_sessions_user ON user_sessions (user_id)`,
+		check: `SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_user_sessions_user')`,
+	},
+	{
+		name:  "add user_sessions expiry index",
+		sql:   `CREATE INDEX IF NOT EXISTS idx_user_sessions_expires ON user_sessions (expires_at)`,
+		check: `SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_user_sessions_expires')`,
+	},
+	{
+		name: "add units archive restore trigger",
+		sql: `CREATE OR REPLACE FUNCTION units_restore_archived()
+RETURNS TRIGGER AS $$
+DECLARE
+    a units_archive%ROWTYPE;
+BEGIN
+    DELETE FROM units_archive WHERE system_id = NEW.system_id AND unit_id = NEW.unit_id
+    RETURNING * INTO a;
+    IF FOUND THEN
+        IF NULLIF(a.alpha_tag, '') IS NOT NULL
+           AND (COALESCE(a.alpha_tag_source, '') IN ('manual', 'csv') OR NULLIF(NEW.alpha_tag, '') IS NULL) THEN
+            NEW.alpha_tag := a.alpha_tag;
+            NEW.alpha_tag_source := a.alpha_tag_source;
+        END IF;
+        NEW.first_seen := LEAST(NEW.first_seen, a.first_seen);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS trg_units_restore_archived ON units;
+CREATE TRIGGER trg_units_restore_archived
+    BEFORE INSERT ON units
+    FOR EACH ROW EXECUTE FUNCTION units_restore_archived()`,
+		check: `SELECT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'trg_units_restore_archived')`,
+	},
+	{
+		name: "add webhooks template",
+		sql: `ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS template text NOT NULL DEFAULT '';
+ALTER TABLE webhooks DROP CONSTRAINT IF EXISTS webhooks_format_check;
+ALTER TABLE webhooks ADD CONSTRAINT webhooks_format_check CHECK (format IN ('generic', 'discord', 'slack', 'template'))`,
+		check: `SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'webhooks' AND column_name = 'template')`,
+	},
 }
 
 // Migrate runs all pending schema migrations.
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// TalkgroupOccupancyRow is a talkgroup's recent activity from its cached stats.
+type TalkgroupOccupancyRow struct {
+	SystemID    int
+	SystemName  string
+	Tgid        int
+	AlphaTag    string
+	Tag         string
+	Group       string
+	Description string
+	LastSeen    *time.Time // start of the most recent call
+	Calls1h     int
+}
+
+// ListTalkgroupOccupancy returns talkgroups heard since since, optionally
+// limited to systemIDs and tgids, most recently heard first.
+func (db *DB) ListTalkgroupOccupancy(ctx context.Context, since time.Time, systemIDs, tgids []int) ([]TalkgroupOccupancyRow, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT t.system_id, COALESCE(s.name, ''), t.tgid,
+			COALESCE(t.alpha_tag, ''), COALESCE(t.tag, ''), COALESCE(t."group", ''),
+			COALESCE(t.description, ''), t.last_seen, t.calls_1h
+		FROM talkgroups t
+		JOIN systems s ON s.system_id = t.system_id AND s.deleted_at IS NULL
+		WHERE t.last_seen >= $1
+		  AND ($2::int[] IS NULL OR t.system_id = ANY($2))
+		  AND ($3::int[] IS NULL OR t.tgid = ANY($3))
+		ORDER BY t.last_seen DESC
+	`, since, systemIDs, tgids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []TalkgroupOccupancyRow
+	for rows.Next() {
+		var a TalkgroupOccupancyRow
+		if err := rows.Scan(&a.SystemID, &a.SystemName, &a.Tgid,
+			&a.AlphaTag, &a.Tag, &a.Group, &a.Description, &a.LastSeen, &a.Calls1h); err != nil {
+			return nil, err
+		}
+		result = append(result, a)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// QuarantinedMessage is an MQTT payload rejected by ingest validation.
+// Payload is only populated by GetQuarantinedMessage; list queries report
+// PayloadSize instead since audio payloads can be several megabytes.
+type QuarantinedMessage struct {
+	ID             int64      `json:"id"`
+	ReceivedAt     time.Time  `json:"received_at"`
+	Handler        string     `json:"handler"`
+	Topic          string     `json:"topic"`
+	InstanceID     string     `json:"instance_id,omitempty"`
+	Reasons        []string   `json:"reasons"`
+	Status         string     `json:"status"`
+	ReprocessedAt  *time.Time `json:"reprocessed_at,omitempty"`
+	ReprocessError string     `json:"reprocess_error,omitempty"`
+	PayloadSize    int        `json:"payload_size"`
+	Payload        []byte     `json:"-"`
+}
+
+// QuarantineFilter specifies filters for listing quarantined messages.
+type QuarantineFilter struct {
+	Handler *string
+	Status  *string
+	Limit   int
+	Offset  int
+}
+
+// InsertQuarantinedMessage stores a rejected payload with its validation failures.
+func (db *DB) InsertQuarantinedMessage(ctx context.Context, handler, topic, instanceID string, payload []byte, reasons []string) error {
+	_, err := db.Pool.Exec(ctx, `
+		INSERT INTO ingest_quarantine (handler, topic, instance_id, payload, reasons)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+	`, handler, topic, instanceID, payload, reasons)
+	return err
+}
+
+// ListQuarantinedMessages returns quarantined messages (without payloads), newest first.
+func (db *DB) ListQuarantinedMessages(ctx context.Context, filter QuarantineFilter) ([]QuarantinedMessage, int, error) {
+	var total int
+	if err := db.Pool.QueryRow(ctx, `
+		SELECT count(*) FROM ingest_quarantine
+		WHERE ($1::text IS NULL OR handler = $1)
+		  AND ($2::text IS NULL OR status = $2)
+	`, filter.Handler, filter.Status).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT id, received_at, handler, topic, COALESCE(instance_id, ''), reasons, status,
+			reprocessed_at, COALESCE(reprocess_error, ''), octet_length(payload)
+		FROM ingest_quarantine
+		WHERE ($1::text IS NULL OR handler = $1)
+		  AND ($2::text IS NULL OR status = $2)
+		ORDER BY received_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`, filter.Handler, filter.Status, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var msgs []QuarantinedMessage
+	for rows.Next() {
+		var m QuarantinedMessage
+		if err := rows.Scan(&m.ID, &m.ReceivedAt, &m.Handler, &m.Topic, &m.InstanceID, &m.Reasons, &m.Status,
+			&m.ReprocessedAt, &m.ReprocessError, &m.PayloadSize); err != nil {
+			return nil, 0, err
+		}
+		msgs = append(msgs, m)
+	}
+	if msgs == nil {
+		msgs = []QuarantinedMessage{}
+	}
+	return msgs, total, rows.Err()
+}
+
+// GetQuarantinedMessage returns a single quarantined message including its payload.
+func (db *DB) GetQuarantinedMessage(ctx context.Context, id int64) (*QuarantinedMessage, error) {
+	var m QuarantinedMessage
+	err := db.Pool.QueryRow(ctx, `
+		SELECT id, received_at, handler, topic, COALESCE(instance_id, ''), reasons, status,
+			reprocessed_at, COALESCE(reprocess_error, ''), payload
+		FROM ingest_quarantine WHERE id = $1
+	`, id).Scan(&m.ID, &m.ReceivedAt, &m.Handler, &m.Topic, &m.InstanceID, &m.Reasons, &m.Status,
+		&m.ReprocessedAt, &m.ReprocessError, &m.Payload)
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("quarantined message not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.PayloadSize = len(m.Payload)
+	return &m, nil
+}
+
+// UpdateQuarantineResult records the outcome of a reprocess attempt.
+// reasons replaces the stored validation failures when non-nil.
+func (db *DB) UpdateQuarantineResult(ctx context.Context, id int64, status, reprocessErr string, reasons []string) error {
+	_, err := db.Pool.Exec(ctx, `
+		UPDATE ingest_quarantine SET
+			status          = $2,
+			reprocessed_at  = now(),
+			reprocess_error = NULLIF($3, ''),
+			reasons         = COALESCE($4, reasons)
+		WHERE id = $1
+	`, id, status, reprocessErr, reasons)
+	return err
+}
+
+// DeleteQuarantinedMessage discards a quarantined message.
+func (db *DB) DeleteQuarantinedMessage(ctx context.Context, id int64) error {
+	tag, err := db.Pool.Exec(ctx, `DELETE FROM ingest_quarantine WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("quarantined message not found")
+	}
+	return nil
+}
@@ -9,19 +9,68 @@ import (
 
 // CallFilter specifies filters for listing calls.
 type CallFilter struct {
-	SystemIDs   []int
-	SiteIDs     []int
-	Sysids      []string
-	Tgids       []int
-	UnitIDs     []int
-	Emergency   *bool
-	Encrypted   *bool
-	Deduplicate bool
-	StartTime   *time.Time
-	EndTime     *time.Time
-	Limit       int
-	Offset      int
-	Sort        string
+	SystemIDs        []int
+	SiteIDs          []int
+	Sysids           []string
+	Tgids            []int
+	UnitIDs          []int
+	Emergency        *bool
+	Encrypted        *bool
+	DurationMismatch *bool
+	Interconnect     *bool
+	StuckMic         *bool
+	AudioUnusable    *bool // calls whose recording was flagged empty or silent
+	AnnotationType   string // calls with an annotation of this type (call_annotations)
+	Deduplicate      bool
+	IncludeRestricted bool // include calls hidden by a restricted encryption policy or embargo (admins)
+	IncludeMerged    bool // include continuations of split calls (calls.merged_into set)
+	StartTime        *time.Time
+	EndTime          *time.Time
+	Limit            int
+	Offset           int
+	Sort             string
+	Omit             map[string]bool // OmittableCallFields not to select (left empty in results)
+}
+
+// OmittableCallFields are the CallAPI JSON fields backed by large columns.
+// ListCalls selects NULL for those named in CallFilter.Omit, so sparse
+// fieldset requests (?fields=, ?exclude=) don't read them at all. The omitted
+// set is a query parameter rather than part of the SQL text, so every
+// fieldset shares one prepared statement per sort order.
+var OmittableCallFields = []string{
+	"src_list", "freq_list", "unit_ids", "patched_tgids",
+	"transcription_text", "metadata_json", "incident_data",
+}
+
+// callColumns maps each OmittableCallFields entry to its column and the
+// typed NULL selected in its place.
+var callColumns = map[string][2]string{
+	"src_list":           {"c.src_list", "NULL::jsonb"},
+	"freq_list":          {"c.freq_list", "NULL::jsonb"},
+	"unit_ids":           {"c.unit_ids", "NULL::int[]"},
+	"patched_tgids":      {"c.patched_tgids", "NULL::int[]"},
+	"transcription_text": {"c.transcription_text", "NULL::text"},
+	"metadata_json":      {"c.metadata_json", "NULL::jsonb"},
+	"incident_data":      {"c.incidentdata", "NULL::jsonb"},
+}
+
+// omittableColumn returns the select expression for an OmittableCallFields
+// entry: NULL when the field is in the text[] parameter $n. Postgres only
+// reads the column when the ELSE branch is taken.
+func omittableColumn(field string, n int) string {
+	c := callColumns[field]
+	return fmt.Sprintf("CASE WHEN '%s' = ANY($%d::text[]) THEN %s ELSE %s END", field, n, c[1], c[0])
+}
+
+// omitted returns the omitted fields as a sorted, non-nil list.
+func (f CallFilter) omitted() []string {
+	out := []string{}
+	for _, field := range OmittableCallFields {
+		if f.Omit[field] {
+			out = append(out, field)
+		}
+	}
+	return out
 }
 
 // CallAPI represents a call for API responses.
@@ -44,6 +93,15 @@ type CallAPI struct {
 	AudioURL      *string   `json:"audio_url,omitempty"`
 	AudioType     string    `json:"audio_type,omitempty"`
 	AudioSize     *int      `json:"audio_size,omitempty"`
+	AudioDuration    *float32 `json:"audio_duration,omitempty"`
+	DurationMismatch bool     `json:"duration_mismatch,omitempty"`
+	OriginalDuration    *float32   `json:"original_duration,omitempty"`     // reported duration, when corrected from the audio
+	DurationCorrectedAt *time.Time `json:"duration_corrected_at,omitempty"` // detail only
+	Interconnect     bool     `json:"interconnect"`
+	StuckMic         bool     `json:"stuck_mic,omitempty"`
+	SpeechRatio      *float32 `json:"speech_ratio,omitempty"`
+	AudioUnusable    string   `json:"audio_unusable,omitempty"` // "empty" or "silent"
+	MergedInto       *int64   `json:"merged_into,omitempty"` // call_id this split call continues, see call_merges
 	Freq          *int64    `json:"freq,omitempty"`
 	FreqError     *int      `json:"freq_error,omitempty"`
 	SignalDB      *float32  `json:"signal_db,omitempty"`
@@ -68,16 +126,26 @@ type CallAPI struct {
 	TranscriptionWordCt  *int            `json:"transcription_word_count,omitempty"`
 	MetadataJSON         json.RawMessage `json:"metadata_json,omitempty"`
 	IncidentData         json.RawMessage `json:"incident_data,omitempty"`
+	CADIncidents         []CADIncidentRef `json:"cad_incidents,omitempty"` // call detail only
+	ExternalEvents       []ExternalEventRef `json:"external_events,omitempty"` // call detail only
+	Annotations          []CallAnnotation `json:"annotations,omitempty"` // call detail only
+	Transcription        *TranscriptionAPI     `json:"transcription,omitempty"` // ?expand= only, see ExpandCalls
+	Transmissions        []CallTransmissionAPI `json:"transmissions,omitempty"`
+	Frequencies          []CallFrequencyAPI    `json:"frequencies,omitempty"`
+	Group                *CallGroupExpansion   `json:"group,omitempty"`
+	UnitTags             []CallUnitTag         `json:"unit_tags,omitempty"`
 	CallFilename         string          `json:"-"` // TR's original path, not exposed in JSON; used for audio resolution
+	Restricted           bool            `json:"-"` // hidden from non-admins by a restricted encryption policy or embargo
 }
 
 // ListCalls returns calls matching the filter with a total count.
 func (db *DB) ListCalls(ctx context.Context, filter CallFilter) ([]CallAPI, int, error) {
+	start := time.Now()
 	// Always include the LEFT JOIN; the dedup condition skips it when not active.
 	const fromClause = `FROM calls c
 		JOIN systems s ON s.system_id = c.system_id
 		LEFT JOIN call_groups cg ON cg.id = c.call_group_id`
-	const whereClause = `
+	whereClause := `
 		WHERE ($1::timestamptz IS NULL OR c.start_time >= $1)
 		  AND ($2::timestamptz IS NULL OR c.start_time < $2)
 		  AND ($3::int[] IS NULL OR c.system_id = ANY($3))
@@ -87,13 +155,24 @@ func (db *DB) ListCalls(ctx context.Context, filter CallFilter) ([]CallAPI, int,
 		  AND ($7::int[] IS NULL OR c.unit_ids && $7)
 		  AND ($8::boolean IS NULL OR c.emergency = $8)
 		  AND ($9::boolean IS NULL OR c.encrypted = $9)
-		  AND ($10::boolean IS NOT TRUE OR c.call_group_id IS NULL OR c.call_id = cg.primary_call_id OR cg.primary_call_id IS NULL)`
+		  AND ($10::boolean IS NOT TRUE OR c.call_group_id IS NULL OR c.call_id = cg.primary_call_id OR cg.primary_call_id IS NULL)
+		  AND ($11::boolean IS NULL OR COALESCE(c.duration_mismatch, false) = $11)
+		  AND ($12::boolean IS NULL OR COALESCE(c.interconnect, false) = $12)
+		  AND ($13::boolean IS NULL OR COALESCE(c.stuck_mic, false) = $13)
+		  AND ($14::boolean OR NOT ` + restrictedCallSQL + `)
+		  AND ($15::boolean OR c.merged_into IS NULL)
+		  AND ($16::boolean IS NULL OR (c.audio_unusable IS NOT NULL) = $16)
+		  AND ($17::text IS NULL OR EXISTS (
+			SELECT 1 FROM call_annotations a
+			WHERE a.call_id = c.call_id AND a.call_start_time = c.start_time AND a.type = $17))`
 	args := []any{
 		filter.StartTime, filter.EndTime,
 		pqIntArray(filter.SystemIDs), pqIntArray(filter.SiteIDs),
 		pqStringArray(filter.Sysids), pqIntArray(filter.Tgids),
 		pqIntArray(filter.UnitIDs), filter.Emergency, filter.Encrypted,
-		filter.Deduplicate,
+		filter.Deduplicate, filter.DurationMismatch, filter.Interconnect,
+		filter.StuckMic, filter.IncludeRestricted, filter.IncludeMerged,
+		filter.AudioUnusable, pqString(filter.AnnotationType),
 	}
 
 	// Count query
@@ -108,7 +187,7 @@ func (db *DB) ListCalls(ctx context.Context, filter CallFilter) ([]CallAPI, int,
 		orderBy = filter.Sort
 	}
 
-	// Data query
+	// Data query (heavy columns may be omitted, see OmittableCallFields)
 	dataQuery := fmt.Sprintf(`
 		SELECT c.call_id, c.call_group_id, c.system_id, COALESCE(c.system_name, ''), COALESCE(s.sysid, ''),
 			c.site_id, COALESCE(c.site_short_name, ''),
@@ -122,17 +201,26 @@ func (db *DB) ListCalls(ctx context.Context, filter CallFilter) ([]CallAPI, int,
 			COALESCE(c.emergency, false), COALESCE(c.encrypted, false),
 			COALESCE(c.analog, false), COALESCE(c.conventional, false),
 			COALESCE(c.phase2_tdma, false), c.tdma_slot,
-			c.patched_tgids,
-			c.src_list, c.freq_list, c.unit_ids,
+			%s,
+			%s, %s, %s,
 			COALESCE(c.has_transcription, false), COALESCE(c.transcription_status, 'none'),
-			c.transcription_text, c.transcription_word_count,
-			c.metadata_json, c.incidentdata
+			%s, c.transcription_word_count,
+			%s, %s,
+			c.audio_duration, COALESCE(c.duration_mismatch, false),
+			COALESCE(c.interconnect, false),
+			COALESCE(c.stuck_mic, false), c.speech_ratio, COALESCE(c.audio_unusable, ''), c.merged_into,
+			%s
 		%s %s
 		ORDER BY %s
-		LIMIT $11 OFFSET $12
-	`, fromClause, whereClause, orderBy)
+		LIMIT $18 OFFSET $19
+	`, omittableColumn("patched_tgids", 20),
+		omittableColumn("src_list", 20), omittableColumn("freq_list", 20), omittableColumn("unit_ids", 20),
+		omittableColumn("transcription_text", 20),
+		omittableColumn("metadata_json", 20), omittableColumn("incident_data", 20),
+		restrictedCallSQL, fromClause, whereClause, orderBy)
+	dataArgs := append(args, filter.Limit, filter.Offset, filter.omitted())
 
-	rows, err := db.Pool.Query(ctx, dataQuery, append(args, filter.Limit, filter.Offset)...)
+	rows, err := db.Pool.Query(ctx, dataQuery, dataArgs...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -158,6 +246,9 @@ func (db *DB) ListCalls(ctx context.Context, filter CallFilter) ([]CallAPI, int,
 			&c.HasTranscription, &c.TranscriptionStatus,
 			&c.TranscriptionText, &c.TranscriptionWordCt,
 			&c.MetadataJSON, &c.IncidentData,
+			&c.AudioDuration, &c.DurationMismatch,
+			&c.Interconnect, &c.StuckMic, &c.SpeechRatio, &c.AudioUnusable, &c.MergedInto,
+			&c.Restricted,
 		); err != nil {
 			return nil, 0, err
 		}
@@ -172,14 +263,22 @@ func (db *DB) ListCalls(ctx context.Context, filter CallFilter) ([]CallAPI, int,
 	if calls == nil {
 		calls = []CallAPI{}
 	}
-	return calls, total, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	db.observeQuery("list_calls", start, dataQuery, dataArgs)
+	return calls, total, nil
 }
 
 // GetCallByID returns a single call.
-func (db *DB) GetCallByID(ctx context.Context, callID int64) (*CallAPI, error) {
+func (db *DB) GetCallByID(ctx context.Context, ref CallRef) (*CallAPI, error) {
+	ref, err := db.ResolveCallRef(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
 	var c CallAPI
 	var audioPath *string
-	err := db.Pool.QueryRow(ctx, `
+	err = db.Pool.QueryRow(ctx, `
 		SELECT c.call_id, c.call_group_id, c.system_id, COALESCE(c.system_name, ''), COALESCE(s.sysid, ''),
 			c.site_id, COALESCE(c.site_short_name, ''),
 			c.tgid, COALESCE(c.tg_alpha_tag, ''), COALESCE(c.tg_description, ''),
@@ -196,11 +295,16 @@ func (db *DB) GetCallByID(ctx context.Context, callID int64) (*CallAPI, error) {
 			c.src_list, c.freq_list, c.unit_ids,
 			COALESCE(c.has_transcription, false), COALESCE(c.transcription_status, 'none'),
 			c.transcription_text, c.transcription_word_count,
-			c.metadata_json, c.incidentdata
+			c.metadata_json, c.incidentdata,
+			c.audio_duration, COALESCE(c.duration_mismatch, false),
+			c.original_duration, c.duration_corrected_at,
+			COALESCE(c.interconnect, false),
+			COALESCE(c.stuck_mic, false), c.speech_ratio, COALESCE(c.audio_unusable, ''), c.merged_into,
+			`+restrictedCallSQL+`
 		FROM calls c
 		JOIN systems s ON s.system_id = c.system_id
-		WHERE c.call_id = $1
-	`, callID).Scan(
+		WHERE c.call_id = $1 AND c.start_time = $2
+	`, ref.CallID, ref.StartTime).Scan(
 		&c.CallID, &c.CallGroupID, &c.SystemID, &c.SystemName, &c.Sysid,
 		&c.SiteID, &c.SiteShortName,
 		&c.Tgid, &c.TgAlphaTag, &c.TgDescription, &c.TgTag, &c.TgGroup,
@@ -216,6 +320,10 @@ func (db *DB) GetCallByID(ctx context.Context, callID int64) (*CallAPI, error) {
 		&c.HasTranscription, &c.TranscriptionStatus,
 		&c.TranscriptionText, &c.TranscriptionWordCt,
 		&c.MetadataJSON, &c.IncidentData,
+		&c.AudioDuration, &c.DurationMismatch,
+		&c.OriginalDuration, &c.DurationCorrectedAt,
+			&c.Interconnect, &c.StuckMic, &c.SpeechRatio, &c.AudioUnusable, &c.MergedInto,
+		&c.Restricted,
 	)
 	if err != nil {
 		return nil, err
@@ -386,7 +494,11 @@ func (db *DB) GetCallGroupByID(ctx context.Context, id int) (*CallGroupAPI, []Ca
 			c.src_list, c.freq_list, c.unit_ids,
 			COALESCE(c.has_transcription, false), COALESCE(c.transcription_status, 'none'),
 			c.transcription_text, c.transcription_word_count,
-			c.metadata_json, c.incidentdata
+			c.metadata_json, c.incidentdata,
+			c.audio_duration, COALESCE(c.duration_mismatch, false),
+			COALESCE(c.interconnect, false),
+			COALESCE(c.stuck_mic, false), c.speech_ratio, COALESCE(c.audio_unusable, ''), c.merged_into,
+			`+restrictedCallSQL+`
 		FROM calls c
 		JOIN systems s ON s.system_id = c.system_id
 		WHERE c.call_group_id = $1
@@ -417,6 +529,9 @@ func (db *DB) GetCallGroupByID(ctx context.Context, id int) (*CallGroupAPI, []Ca
 			&c.HasTranscription, &c.TranscriptionStatus,
 			&c.TranscriptionText, &c.TranscriptionWordCt,
 			&c.MetadataJSON, &c.IncidentData,
+			&c.AudioDuration, &c.DurationMismatch,
+			&c.Interconnect, &c.StuckMic, &c.SpeechRatio, &c.AudioUnusable, &c.MergedInto,
+			&c.Restricted,
 		); err != nil {
 			return nil, nil, err
 		}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand/v2"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/metrics"
+)
+
+// Hot list queries are written as a fixed SQL text per sort order: optional
+// filters are "($n IS NULL OR ...)" predicates and omitted columns are a
+// parameter, never spliced into the string. pgx's default exec mode
+// (QueryExecModeCacheStatement) then prepares each form once per connection
+// and reuses it, so a request pays neither parse nor round-trip for the
+// describe. Whether Postgres replans each execution (custom plan) or reuses a
+// generic plan is left to the server's plan_cache_mode, which can be set
+// per connection with ?plan_cache_mode= on DATABASE_URL.
+//
+// Latency of each hot query is exported as tr_engine_db_query_duration_seconds.
+// With DB_EXPLAIN_SAMPLE set, a fraction of executions is also EXPLAINed in
+// the background with the same arguments and the plan summary logged, so a
+// plan regression (a sequential scan on calls, a cost jump) shows up in logs
+// before users notice.
+
+// explainSampler holds the DB_EXPLAIN_SAMPLE state.
+type explainSampler struct {
+	rate     atomic.Uint64 // sample rate in millionths; 0 disables sampling
+	inFlight atomic.Bool   // at most one EXPLAIN at a time
+}
+
+// SetExplainSampling EXPLAINs about rate (0–1) of hot query executions.
+func (db *DB) SetExplainSampling(rate float64) {
+	if rate < 0 {
+		rate = 0
+	}
+	db.explain.rate.Store(uint64(rate * 1e6))
+}
+
+// observeQuery records a hot query's latency and, when sampled, logs its plan.
+func (db *DB) observeQuery(name string, start time.Time, sql string, args []any) {
+	elapsed := time.Since(start)
+	metrics.DBQueryDuration.WithLabelValues(name).Observe(elapsed.Seconds())
+
+	rate := float64(db.explain.rate.Load()) / 1e6
+	if rate <= 0 || rand.Float64() >= rate {
+		return
+	}
+	if !db.explain.inFlight.CompareAndSwap(false, true) {
+		return
+	}
+	args = append([]any(nil), args...)
+	go func() {
+		defer db.explain.inFlight.Store(false)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var plan []byte
+		if err := db.Pool.QueryRow(ctx, "EXPLAIN (FORMAT JSON) "+sql, args...).Scan(&plan); err != nil {
+			db.log.Warn().Err(err).Str("query", name).Msg("explain sample failed")
+			return
+		}
+		s, err := summarizePlan(plan)
+		if err != nil {
+			db.log.Warn().Err(err).Str("query", name).Msg("explain sample: unreadable plan")
+			return
+		}
+		ev := db.log.Info()
+		if s.hasSeqScanOn("calls") {
+			ev = db.log.Warn()
+		}
+		ev.Str("query", name).
+			Dur("elapsed", elapsed).
+			Float64("total_cost", s.TotalCost).
+			Float64("plan_rows", s.PlanRows).
+			Strs("seq_scans", s.SeqScans).
+			Strs("index_scans", s.IndexScans).
+			Str("plan", string(plan)).
+			Msg("query plan sample")
+	}()
+}
+
+// planSummary is the part of an EXPLAIN (FORMAT JSON) plan worth logging.
+type planSummary struct {
+	TotalCost  float64
+	PlanRows   float64
+	SeqScans   []string // relations read by Seq Scan
+	IndexScans []string // indexes used
+}
+
+func (s planSummary) hasSeqScanOn(table string) bool {
+	for _, rel := range s.SeqScans {
+		// Partitions are named calls_YYYY_MM
+		if rel == table || strings.HasPrefix(rel, table+"_") {
+			return true
+		}
+	}
+	return false
+}
+
+type planNode struct {
+	NodeType     string     `json:"Node Type"`
+	RelationName string     `json:"Relation Name"`
+	IndexName    string     `json:"Index Name"`
+	TotalCost    float64    `json:"Total Cost"`
+	PlanRows     float64    `json:"Plan Rows"`
+	Plans        []planNode `json:"Plans"`
+}
+
+// summarizePlan walks an EXPLAIN (FORMAT JSON) result.
+func summarizePlan(data []byte) (planSummary, error) {
+	var out []struct {
+		Plan planNode `json:"Plan"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return planSummary{}, err
+	}
+	var s planSummary
+	if len(out) == 0 {
+		return s, nil
+	}
+	s.TotalCost = out[0].Plan.TotalCost
+	s.PlanRows = out[0].Plan.PlanRows
+	var walk func(n planNode)
+	walk = func(n planNode) {
+		switch {
+		case n.NodeType == "Seq Scan" || n.NodeType == "Parallel Seq Scan":
+			s.SeqScans = append(s.SeqScans, n.RelationName)
+		case n.IndexName != "":
+			s.IndexScans = append(s.IndexScans, n.IndexName)
+		}
+		for _, c := range n.Plans {
+			walk(c)
+		}
+	}
+	walk(out[0].Plan)
+	return s, nil
+}
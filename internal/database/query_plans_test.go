@@ -0,0 +1,51 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSummarizePlan(t *testing.T) {
+	plan := []byte(`[{"Plan": {
+		"Node Type": "Limit", "Total Cost": 812.5, "Plan Rows": 50,
+		"Plans": [{"Node Type": "Nested Loop", "Plans": [
+			{"Node Type": "Index Scan", "Relation Name": "calls_2026_10", "Index Name": "calls_2026_10_start_time_idx"},
+			{"Node Type": "Seq Scan", "Relation Name": "systems"},
+			{"Node Type": "Parallel Seq Scan", "Relation Name": "calls_2026_09"}
+		]}]
+	}}]`)
+	s, err := summarizePlan(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.TotalCost != 812.5 || s.PlanRows != 50 {
+		t.Errorf("cost/rows = %v/%v", s.TotalCost, s.PlanRows)
+	}
+	if want := []string{"systems", "calls_2026_09"}; !reflect.DeepEqual(s.SeqScans, want) {
+		t.Errorf("seq scans = %v, want %v", s.SeqScans, want)
+	}
+	if want := []string{"calls_2026_10_start_time_idx"}; !reflect.DeepEqual(s.IndexScans, want) {
+		t.Errorf("index scans = %v, want %v", s.IndexScans, want)
+	}
+	if !s.hasSeqScanOn("calls") || s.hasSeqScanOn("units") {
+		t.Error("hasSeqScanOn wrong")
+	}
+
+	if _, err := summarizePlan([]byte("not json")); err == nil {
+		t.Error("expected error for bad plan")
+	}
+}
+
+func TestCallFilterOmitted(t *testing.T) {
+	f := CallFilter{Omit: map[string]bool{"incident_data": true, "src_list": true, "bogus": true}}
+	if got, want := f.omitted(), []string{"src_list", "incident_data"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("omitted = %v, want %v", got, want)
+	}
+	if got := (CallFilter{}).omitted(); got == nil || len(got) != 0 {
+		t.Errorf("empty omitted = %#v, want non-nil empty", got)
+	}
+	want := "CASE WHEN 'src_list' = ANY($18::text[]) THEN NULL::jsonb ELSE c.src_list END"
+	if got := omittableColumn("src_list", 18); got != want {
+		t.Errorf("omittableColumn = %q", got)
+	}
+}
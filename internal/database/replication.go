@@ -0,0 +1,231 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Replication states of a call on an edge instance (call_replications).
+const (
+	ReplicationPending  = "pending"  // in progress or failing, retried
+	ReplicationDone     = "done"     // stored on the central instance
+	ReplicationRejected = "rejected" // refused by the central instance
+)
+
+// ReplicationCandidate is a finished call not yet replicated to the central
+// instance, with what its upload needs: the metadata of a call-upload and
+// the audio store key.
+type ReplicationCandidate struct {
+	Ref         CallRef
+	SysName     string // TR short_name, the central's systemLabel
+	Tgid        int
+	StopTime    *time.Time
+	Duration    *float32
+	Freq        *int64
+	Emergency   bool
+	Encrypted   bool
+	AlphaTag    string
+	Description string
+	Tag         string
+	Group       string
+	AudioType   string
+	AudioPath   string // audio store key; "" for a call without audio
+	Sources     json.RawMessage
+	Frequencies json.RawMessage
+	SessionPath string // central upload session of an earlier attempt
+	Attempts    int
+}
+
+// ListReplicationCandidates returns calls started since `since` that ended
+// before `before` and aren't done or rejected, oldest first. A call that
+// failed is retried retryAfter × attempts (at most 10×) after its last
+// attempt.
+func (db *DB) ListReplicationCandidates(ctx context.Context, since, before time.Time, retryAfter time.Duration, limit int) ([]ReplicationCandidate, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT c.call_id, c.start_time, COALESCE(NULLIF(c.site_short_name, ''), c.system_name, ''),
+			c.tgid, c.stop_time, c.duration, c.freq,
+			COALESCE(c.emergency, false), COALESCE(c.encrypted, false),
+			COALESCE(c.tg_alpha_tag, ''), COALESCE(c.tg_description, ''),
+			COALESCE(c.tg_tag, ''), COALESCE(c.tg_group, ''),
+			COALESCE(c.audio_type, ''), COALESCE(c.audio_file_path, ''),
+			(SELECT json_agg(json_build_object(
+					'src', t.src, 'time', COALESCE(extract(epoch FROM t."time")::bigint, 0),
+					'pos', COALESCE(t.pos, 0), 'emergency', COALESCE(t.emergency, 0),
+					'signal_system', COALESCE(t.signal_system, ''), 'tag', COALESCE(t.tag, ''))
+					ORDER BY t.pos)
+				FROM call_transmissions t
+				WHERE t.call_id = c.call_id AND t.call_start_time = c.start_time),
+			(SELECT json_agg(json_build_object(
+					'freq', f.freq, 'time', COALESCE(extract(epoch FROM f."time")::bigint, 0),
+					'pos', COALESCE(f.pos, 0), 'len', COALESCE(f.len, 0),
+					'error_count', COALESCE(f.error_count, 0), 'spike_count', COALESCE(f.spike_count, 0))
+					ORDER BY f.pos)
+				FROM call_frequencies f
+				WHERE f.call_id = c.call_id AND f.call_start_time = c.start_time),
+			COALESCE(r.session_path, ''), COALESCE(r.attempts, 0)
+		FROM calls c
+		LEFT JOIN call_replications r
+			ON r.call_id = c.call_id AND r.call_start_time = c.start_time
+		WHERE c.start_time >= $1
+		  AND c.stop_time IS NOT NULL AND c.stop_time < $2
+		  AND c.merged_into IS NULL
+		  AND (r.call_id IS NULL OR (r.state = 'pending'
+			AND r.updated_at < now() - LEAST(r.attempts, 10) * $3::interval))
+		ORDER BY c.start_time
+		LIMIT $4
+	`, since, before, retryAfter, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []ReplicationCandidate
+	for rows.Next() {
+		var c ReplicationCandidate
+		var sources, freqs []byte
+		if err := rows.Scan(&c.Ref.CallID, &c.Ref.StartTime, &c.SysName, &c.Tgid, &c.StopTime,
+			&c.Duration, &c.Freq, &c.Emergency, &c.Encrypted, &c.AlphaTag, &c.Description,
+			&c.Tag, &c.Group, &c.AudioType, &c.AudioPath, &sources, &freqs,
+			&c.SessionPath, &c.Attempts); err != nil {
+			return nil, err
+		}
+		c.Sources, c.Frequencies = sources, freqs
+		result = append(result, c)
+	}
+	return result, rows.Err()
+}
+
+// SaveReplicationProgress records the central upload session of a call in
+// progress and how many audio bytes it holds, so a restart resumes it.
+func (db *DB) SaveReplicationProgress(ctx context.Context, ref CallRef, sessionPath string, bytesSent int64) error {
+	_, err := db.Pool.Exec(ctx, `
+		INSERT INTO call_replications (call_id, call_start_time, session_path, bytes_sent)
+		VALUES ($1, $2, NULLIF($3, ''), $4)
+		ON CONFLICT (call_id, call_start_time) DO UPDATE SET
+			session_path = EXCLUDED.session_path,
+			bytes_sent   = EXCLUDED.bytes_sent
+	`, ref.CallID, ref.StartTime, sessionPath, bytesSent)
+	return err
+}
+
+// MarkCallReplicated records that the central instance has the call, as
+// remoteCallID (0 if unknown).
+func (db *DB) MarkCallReplicated(ctx context.Context, ref CallRef, remoteCallID int64) error {
+	_, err := db.Pool.Exec(ctx, `
+		INSERT INTO call_replications (call_id, call_start_time, state, remote_call_id, updated_at)
+		VALUES ($1, $2, 'done', NULLIF($3, 0), now())
+		ON CONFLICT (call_id, call_start_time) DO UPDATE SET
+			state          = 'done',
+			session_path   = NULL,
+			remote_call_id = EXCLUDED.remote_call_id,
+			last_error     = NULL,
+			updated_at     = now()
+	`, ref.CallID, ref.StartTime, remoteCallID)
+	return err
+}
+
+// RecordReplicationFailure counts a failed attempt. A rejected call is not
+// retried until reset; otherwise it stays pending.
+func (db *DB) RecordReplicationFailure(ctx context.Context, ref CallRef, msg string, rejected bool) error {
+	state := ReplicationPending
+	if rejected {
+		state = ReplicationRejected
+	}
+	_, err := db.Pool.Exec(ctx, `
+		INSERT INTO call_replications (call_id, call_start_time, state, attempts, last_error, updated_at)
+		VALUES ($1, $2, $3, 1, $4, now())
+		ON CONFLICT (call_id, call_start_time) DO UPDATE SET
+			state      = EXCLUDED.state,
+			attempts   = call_replications.attempts + 1,
+			last_error = EXCLUDED.last_error,
+			updated_at = now()
+	`, ref.CallID, ref.StartTime, state, msg)
+	return err
+}
+
+// ResetReplicationFailures makes failing and rejected calls eligible for an
+// immediate attempt. Returns the number of calls reset.
+func (db *DB) ResetReplicationFailures(ctx context.Context) (int64, error) {
+	tag, err := db.Pool.Exec(ctx, `
+		UPDATE call_replications SET state = 'pending', attempts = 0, updated_at = now()
+		WHERE state = 'rejected' OR (state = 'pending' AND attempts > 0)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ReplicationFailure is a call whose replication failed or was rejected.
+type ReplicationFailure struct {
+	CallID      int64     `json:"call_id"`
+	StartTime   time.Time `json:"start_time"`
+	State       string    `json:"state"`
+	Attempts    int       `json:"attempts"`
+	BytesSent   int64     `json:"bytes_sent"`
+	LastError   string    `json:"last_error"`
+	LastAttempt time.Time `json:"last_attempt"`
+}
+
+// ListReplicationFailures returns failing and rejected calls, most recent
+// attempt first.
+func (db *DB) ListReplicationFailures(ctx context.Context, limit int) ([]ReplicationFailure, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT call_id, call_start_time, state, attempts, bytes_sent,
+			COALESCE(last_error, ''), updated_at
+		FROM call_replications
+		WHERE state = 'rejected' OR (state = 'pending' AND attempts > 0)
+		ORDER BY updated_at DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []ReplicationFailure{}
+	for rows.Next() {
+		var f ReplicationFailure
+		if err := rows.Scan(&f.CallID, &f.StartTime, &f.State, &f.Attempts, &f.BytesSent,
+			&f.LastError, &f.LastAttempt); err != nil {
+			return nil, err
+		}
+		result = append(result, f)
+	}
+	return result, rows.Err()
+}
+
+// ReplicationStats counts finished calls in the replication window by state.
+type ReplicationStats struct {
+	Replicated    int64      `json:"replicated"`     // stored on the central instance
+	Pending       int64      `json:"pending"`        // not yet replicated (including failing)
+	Failing       int64      `json:"failing"`        // at least one failed attempt, still retried
+	Rejected      int64      `json:"rejected"`       // refused by the central instance
+	OldestPending *time.Time `json:"oldest_pending"` // start_time of the oldest unreplicated call
+}
+
+// GetReplicationStats counts finished calls started since `since` by
+// replication state.
+func (db *DB) GetReplicationStats(ctx context.Context, since time.Time) (ReplicationStats, error) {
+	var s ReplicationStats
+	err := db.Pool.QueryRow(ctx, `
+		WITH w AS (
+			SELECT c.start_time, COALESCE(r.state, 'pending') AS state, COALESCE(r.attempts, 0) AS attempts
+			FROM calls c
+			LEFT JOIN call_replications r
+				ON r.call_id = c.call_id AND r.call_start_time = c.start_time
+			WHERE c.start_time >= $1
+			  AND c.stop_time IS NOT NULL
+			  AND c.merged_into IS NULL
+		)
+		SELECT
+			count(*) FILTER (WHERE state = 'done'),
+			count(*) FILTER (WHERE state = 'pending'),
+			count(*) FILTER (WHERE state = 'pending' AND attempts > 0),
+			count(*) FILTER (WHERE state = 'rejected'),
+			min(start_time) FILTER (WHERE state = 'pending')
+		FROM w
+	`, since).Scan(&s.Replicated, &s.Pending, &s.Failing, &s.Rejected, &s.OldestPending)
+	return s, err
+}
@@ -0,0 +1,289 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Retention targets: what daily maintenance expires, named in
+// /admin/retention. Each defaults to its RETENTION_* setting.
+const (
+	RetentionRawMessages       = "mqtt_raw_messages"       // RETENTION_RAW_MESSAGES, whole weekly partitions
+	RetentionConsoleLogs       = "console_messages"        // RETENTION_CONSOLE_LOGS
+	RetentionPluginStatus      = "plugin_statuses"         // RETENTION_PLUGIN_STATUS
+	RetentionCheckpoints       = "call_active_checkpoints" // RETENTION_CHECKPOINTS
+	RetentionQuarantine        = "ingest_quarantine"       // RETENTION_QUARANTINE
+	RetentionExternalEvents    = "external_events"         // RETENTION_EXTERNAL_EVENTS
+	RetentionWebhookDeliveries = "webhook_deliveries"      // RETENTION_WEBHOOK_DELIVERIES
+	RetentionTimeseries        = "system_timeseries"       // TIMESERIES_RETENTION
+	RetentionUnitEvents        = "unit_events"             // RETENTION_UNIT_EVENTS
+	RetentionUnitSessions      = "unit_sessions"           // RETENTION_UNIT_SESSIONS
+	RetentionCalls             = "calls"                   // RETENTION_CALLS, with their child rows
+	RetentionCallAudio         = "call_audio"              // RETENTION_CALL_AUDIO, audio files only
+)
+
+// RetentionTarget is a retention target. Optional targets keep their data
+// forever at a retention of 0; the others always need a positive one.
+type RetentionTarget struct {
+	Name     string
+	Optional bool
+}
+
+// RetentionTargets lists the retention targets in maintenance order.
+var RetentionTargets = []RetentionTarget{
+	{RetentionConsoleLogs, false},
+	{RetentionPluginStatus, false},
+	{RetentionCheckpoints, false},
+	{RetentionQuarantine, false},
+	{RetentionExternalEvents, true},
+	{RetentionWebhookDeliveries, true},
+	{RetentionTimeseries, false},
+	{RetentionUnitEvents, true},
+	{RetentionUnitSessions, true},
+	{RetentionRawMessages, false},
+	{RetentionCallAudio, true},
+	{RetentionCalls, true},
+}
+
+// LookupRetentionTarget returns the retention target called name.
+func LookupRetentionTarget(name string) (RetentionTarget, bool) {
+	for _, t := range RetentionTargets {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return RetentionTarget{}, false
+}
+
+// RetentionSetting is an admin override of a target's configured retention.
+type RetentionSetting struct {
+	Target    string
+	Retention time.Duration
+	UpdatedAt time.Time
+}
+
+// ListRetentionSettings returns the admin retention overrides by target.
+func (db *DB) ListRetentionSettings(ctx context.Context) (map[string]RetentionSetting, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT target, retention_seconds, updated_at FROM retention_settings`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	settings := make(map[string]RetentionSetting)
+	for rows.Next() {
+		var s RetentionSetting
+		var seconds int64
+		if err := rows.Scan(&s.Target, &seconds, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		s.Retention = time.Duration(seconds) * time.Second
+		settings[s.Target] = s
+	}
+	return settings, rows.Err()
+}
+
+// UpsertRetentionSetting sets the admin override for a target. The
+// retention is stored in whole seconds.
+func (db *DB) UpsertRetentionSetting(ctx context.Context, target string, retention time.Duration) (*RetentionSetting, error) {
+	s := RetentionSetting{Target: target, Retention: retention.Truncate(time.Second)}
+	err := db.Pool.QueryRow(ctx, `
+		INSERT INTO retention_settings (target, retention_seconds)
+		VALUES ($1, $2)
+		ON CONFLICT (target) DO UPDATE SET retention_seconds = $2, updated_at = now()
+		RETURNING updated_at
+	`, target, int64(s.Retention/time.Second)).Scan(&s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// DeleteRetentionSetting removes a target's override, returning it to its
+// configured retention. Returns false if there was none.
+func (db *DB) DeleteRetentionSetting(ctx context.Context, target string) (bool, error) {
+	tag, err := db.Pool.Exec(ctx, `DELETE FROM retention_settings WHERE target = $1`, target)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// purgeCollectedCalls deletes the calls listed in the purge_calls temp table
+// (call_id, start_time) along with the rows keyed on them. Returns the number
+// of calls deleted.
+func purgeCollectedCalls(ctx context.Context, tx pgx.Tx) (int64, error) {
+	for _, stmt := range []struct{ name, sql string }{
+		{"transcriptions", `DELETE FROM transcriptions WHERE (call_id, call_start_time) IN (SELECT call_id, start_time FROM purge_calls)`},
+		{"call_frequencies", `DELETE FROM call_frequencies WHERE (call_id, call_start_time) IN (SELECT call_id, start_time FROM purge_calls)`},
+		{"call_transmissions", `DELETE FROM call_transmissions WHERE (call_id, call_start_time) IN (SELECT call_id, start_time FROM purge_calls)`},
+		{"call_audio_variants", `DELETE FROM call_audio_variants WHERE (call_id, call_start_time) IN (SELECT call_id, start_time FROM purge_calls)`},
+		{"call_annotations", `DELETE FROM call_annotations WHERE (call_id, call_start_time) IN (SELECT call_id, start_time FROM purge_calls)`},
+		{"cad_incident_calls", `DELETE FROM cad_incident_calls WHERE (call_id, call_start_time) IN (SELECT call_id, start_time FROM purge_calls)`},
+		{"external_event_calls", `DELETE FROM external_event_calls WHERE (call_id, call_start_time) IN (SELECT call_id, start_time FROM purge_calls)`},
+		{"call_pins", `DELETE FROM call_pins WHERE (call_id, call_start_time) IN (SELECT call_id, start_time FROM purge_calls)`},
+		{"call_replications", `DELETE FROM call_replications WHERE (call_id, call_start_time) IN (SELECT call_id, start_time FROM purge_calls)`},
+		{"call_forwards", `DELETE FROM call_forwards WHERE (call_id, call_start_time) IN (SELECT call_id, start_time FROM purge_calls)`},
+		{"alerts", `DELETE FROM alerts WHERE (call_id, call_start_time) IN (SELECT call_id, start_time FROM purge_calls)`},
+		{"duplicate_call_findings", `DELETE FROM duplicate_call_findings WHERE (call_id, call_start_time) IN (SELECT call_id, start_time FROM purge_calls) OR (dup_call_id, dup_start_time) IN (SELECT call_id, start_time FROM purge_calls)`},
+		{"call_groups", `UPDATE call_groups SET primary_call_id = NULL WHERE primary_call_id IN (SELECT call_id FROM purge_calls)`},
+	} {
+		if _, err := tx.Exec(ctx, stmt.sql); err != nil {
+			return 0, fmt.Errorf("purge %s: %w", stmt.name, err)
+		}
+	}
+	tag, err := tx.Exec(ctx, `DELETE FROM calls WHERE (call_id, start_time) IN (SELECT call_id, start_time FROM purge_calls)`)
+	if err != nil {
+		return 0, fmt.Errorf("purge calls: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// PreviewOldCalls counts the calls PurgeOldCalls would delete.
+func (db *DB) PreviewOldCalls(ctx context.Context, olderThan time.Duration, keepPinned bool) (MaintenancePreview, error) {
+	return db.previewRows(ctx, `
+		SELECT count(*), min(c.start_time), max(c.start_time) FROM calls c
+		WHERE c.start_time < $1
+		  AND NOT `+retainedCallSQL("c", keepPinned)+`
+	`, time.Now().Add(-olderThan))
+}
+
+// PurgeOldCalls deletes up to limit of the oldest calls that started more
+// than olderThan ago, with their frequencies, transmissions, transcriptions,
+// audio variants, annotations, pins and incident/event links. Calls under a
+// legal hold, and with keepPinned pinned calls, are kept. Audio files are not
+// touched. Returns the number deleted.
+func (db *DB) PurgeOldCalls(ctx context.Context, olderThan time.Duration, limit int, keepPinned bool) (int64, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		CREATE TEMP TABLE purge_calls ON COMMIT DROP AS
+		SELECT c.call_id, c.start_time FROM calls c
+		WHERE c.start_time < $1
+		  AND NOT `+retainedCallSQL("c", keepPinned)+`
+		ORDER BY c.start_time
+		LIMIT $2
+	`, time.Now().Add(-olderThan), limit); err != nil {
+		return 0, fmt.Errorf("collect calls: %w", err)
+	}
+	n, err := purgeCollectedCalls(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return n, nil
+}
+
+// callHasAudioSQL is true when call c references stored audio.
+const callHasAudioSQL = `(c.audio_file_path IS NOT NULL
+	OR EXISTS (SELECT 1 FROM call_audio_variants v WHERE v.call_id = c.call_id AND v.call_start_time = c.start_time))`
+
+// CallAudioFiles is the stored audio of one call: its audio file and the
+// paths of its audio variants, as audio store keys.
+type CallAudioFiles struct {
+	Ref   CallRef
+	Paths []string
+}
+
+// PreviewCallAudio counts the calls whose audio OldCallAudio would return.
+func (db *DB) PreviewCallAudio(ctx context.Context, olderThan time.Duration, keepPinned bool) (MaintenancePreview, error) {
+	return db.previewRows(ctx, `
+		SELECT count(*), min(c.start_time), max(c.start_time) FROM calls c
+		WHERE c.start_time < $1
+		  AND `+callHasAudioSQL+`
+		  AND NOT `+retainedCallSQL("c", keepPinned)+`
+	`, time.Now().Add(-olderThan))
+}
+
+// PreviewCallAudioBytes counts the oldest calls whose audio would have to
+// go to free bytes, going by their recorded audio_file_size.
+func (db *DB) PreviewCallAudioBytes(ctx context.Context, bytes int64, keepPinned bool) (MaintenancePreview, error) {
+	return db.previewRows(ctx, `
+		SELECT count(*), min(start_time), max(start_time) FROM (
+			SELECT c.start_time,
+				sum(COALESCE(c.audio_file_size, 0)) OVER (ORDER BY c.start_time, c.call_id)
+					- COALESCE(c.audio_file_size, 0) AS freed_before
+			FROM calls c
+			WHERE `+callHasAudioSQL+`
+			  AND NOT `+retainedCallSQL("c", keepPinned)+`
+		) a
+		WHERE freed_before < $1
+	`, bytes)
+}
+
+// OldCallAudio returns the audio of up to limit of the oldest calls that
+// started more than olderThan ago and still have audio. Calls under a legal
+// hold, and with keepPinned pinned calls, are skipped.
+func (db *DB) OldCallAudio(ctx context.Context, olderThan time.Duration, limit int, keepPinned bool) ([]CallAudioFiles, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT c.call_id, c.start_time,
+			array_remove(ARRAY[c.audio_file_path] || ARRAY(
+				SELECT v.audio_path FROM call_audio_variants v
+				WHERE v.call_id = c.call_id AND v.call_start_time = c.start_time), NULL)
+		FROM calls c
+		WHERE c.start_time < $1
+		  AND `+callHasAudioSQL+`
+		  AND NOT `+retainedCallSQL("c", keepPinned)+`
+		ORDER BY c.start_time
+		LIMIT $2
+	`, time.Now().Add(-olderThan), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []CallAudioFiles
+	for rows.Next() {
+		var f CallAudioFiles
+		if err := rows.Scan(&f.Ref.CallID, &f.Ref.StartTime, &f.Paths); err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
+
+// ClearCallAudio removes the audio references and audio variants of calls
+// whose audio files were deleted. Returns the number of calls updated.
+func (db *DB) ClearCallAudio(ctx context.Context, refs []CallRef) (int64, error) {
+	if len(refs) == 0 {
+		return 0, nil
+	}
+	callIDs := make([]int64, len(refs))
+	startTimes := make([]time.Time, len(refs))
+	for i, ref := range refs {
+		callIDs[i] = ref.CallID
+		startTimes[i] = ref.StartTime
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	tag, err := tx.Exec(ctx, `
+		UPDATE calls SET audio_file_path = NULL, audio_file_size = NULL,
+			call_filename = NULL, updated_at = now()
+		WHERE (call_id, start_time) IN (SELECT * FROM unnest($1::bigint[], $2::timestamptz[]))
+	`, callIDs, startTimes)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM call_audio_variants
+		WHERE (call_id, call_start_time) IN (SELECT * FROM unnest($1::bigint[], $2::timestamptz[]))
+	`, callIDs, startTimes); err != nil {
+		return 0, fmt.Errorf("delete audio variants: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
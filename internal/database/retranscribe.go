@@ -0,0 +1,275 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Re-transcription job statuses. A job is created pending with its cost
+// estimate and runs only once started.
+const (
+	RetranscribePending   = "pending"
+	RetranscribeRunning   = "running"
+	RetranscribeCompleted = "completed"
+	RetranscribeCancelled = "cancelled"
+	RetranscribeFailed    = "failed"
+)
+
+// RetranscribeFilter selects the calls a re-transcription job covers.
+// Calls that already have a transcript from Provider and Model are left out,
+// as are encrypted or unusable calls, calls without audio and calls outside
+// [MinDuration, MaxDuration] seconds (0 = no bound).
+type RetranscribeFilter struct {
+	StartTime   time.Time
+	EndTime     time.Time
+	SystemIDs   []int
+	Tgids       []int
+	Provider    string
+	Model       string
+	MinDuration float64
+	MaxDuration float64
+}
+
+// RetranscribeJob is a bulk re-transcription job.
+type RetranscribeJob struct {
+	ID            int        `json:"id"`
+	Status        string     `json:"status"`
+	StartTime     time.Time  `json:"start_time"`
+	EndTime       time.Time  `json:"end_time"`
+	SystemIDs     []int      `json:"system_ids"`
+	Tgids         []int      `json:"tgids"`
+	Provider      string     `json:"provider"`
+	Model         string     `json:"model"`
+	MakePrimary   bool       `json:"make_primary"` // false: stored as a variant, the current primary is kept
+	Concurrency   int        `json:"concurrency"`
+	TotalCalls    int        `json:"total_calls"`
+	AudioSeconds  float64    `json:"audio_seconds"`
+	EstimatedCost *float64   `json:"estimated_cost"` // null without a rate for the provider
+	Processed     int        `json:"processed"`      // calls finished, including failures
+	Failed        int        `json:"failed"`
+	CursorTime    *time.Time `json:"-"`
+	CursorCallID  *int64     `json:"-"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+}
+
+// Filter returns the call selection of the job.
+func (j *RetranscribeJob) Filter() RetranscribeFilter {
+	return RetranscribeFilter{
+		StartTime: j.StartTime,
+		EndTime:   j.EndTime,
+		SystemIDs: j.SystemIDs,
+		Tgids:     j.Tgids,
+		Provider:  j.Provider,
+		Model:     j.Model,
+	}
+}
+
+// RetranscribeCall is a call due for re-transcription.
+type RetranscribeCall struct {
+	CallTranscriptionInfo
+	HumanPrimary bool // the primary transcript is a human correction
+}
+
+const retranscribeJobColumns = `id, status, start_time, end_time, system_ids, tgids, provider, model,
+	make_primary, concurrency, total_calls, audio_seconds, estimated_cost, processed, failed,
+	cursor_time, cursor_call_id, COALESCE(last_error, ''), created_at, started_at, finished_at`
+
+func scanRetranscribeJob(row pgx.Row) (*RetranscribeJob, error) {
+	var j RetranscribeJob
+	if err := row.Scan(&j.ID, &j.Status, &j.StartTime, &j.EndTime, &j.SystemIDs, &j.Tgids,
+		&j.Provider, &j.Model, &j.MakePrimary, &j.Concurrency, &j.TotalCalls, &j.AudioSeconds,
+		&j.EstimatedCost, &j.Processed, &j.Failed, &j.CursorTime, &j.CursorCallID, &j.LastError,
+		&j.CreatedAt, &j.StartedAt, &j.FinishedAt); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// retranscribeCallsWhere is the call selection shared by the estimate and
+// the job's pages. $1-$8 are the RetranscribeFilter fields in order.
+const retranscribeCallsWhere = `
+	WHERE c.start_time >= $1 AND c.start_time < $2
+	  AND ($3::int[] IS NULL OR c.system_id = ANY($3))
+	  AND ($4::int[] IS NULL OR c.tgid = ANY($4))
+	  AND (COALESCE(c.audio_file_path, '') <> '' OR COALESCE(c.call_filename, '') <> '')
+	  AND NOT COALESCE(c.encrypted, false)
+	  AND c.audio_unusable IS NULL
+	  AND ($7::float8 = 0 OR COALESCE(c.duration, 0) >= $7)
+	  AND ($8::float8 = 0 OR COALESCE(c.duration, 0) <= $8)
+	  AND NOT EXISTS (SELECT 1 FROM transcriptions t
+	                  WHERE t.call_id = c.call_id AND t.call_start_time = c.start_time
+	                    AND t.provider = $5 AND t.model = $6)`
+
+func (f RetranscribeFilter) args() []any {
+	return []any{f.StartTime, f.EndTime, f.SystemIDs, f.Tgids, f.Provider, f.Model, f.MinDuration, f.MaxDuration}
+}
+
+// CountRetranscribeCalls returns the number of calls a filter selects and
+// their total duration in seconds.
+func (db *DB) CountRetranscribeCalls(ctx context.Context, f RetranscribeFilter) (int, float64, error) {
+	var calls int
+	var seconds float64
+	err := db.Pool.QueryRow(ctx, `
+		SELECT count(*), COALESCE(sum(c.duration), 0)::float8
+		FROM calls c`+retranscribeCallsWhere, f.args()...).Scan(&calls, &seconds)
+	return calls, seconds, err
+}
+
+// ListRetranscribeCalls returns up to limit calls a filter selects after
+// the (afterTime, afterCallID) cursor, oldest first.
+func (db *DB) ListRetranscribeCalls(ctx context.Context, f RetranscribeFilter, afterTime *time.Time, afterCallID *int64, limit int) ([]RetranscribeCall, error) {
+	args := append(f.args(), afterTime, afterCallID, limit)
+	rows, err := db.Pool.Query(ctx, `
+		SELECT c.call_id, c.start_time, c.system_id, c.tgid, c.duration,
+			COALESCE(c.audio_file_path, ''), COALESCE(c.call_filename, ''), c.src_list,
+			COALESCE(c.tg_alpha_tag, ''), COALESCE(c.tg_description, ''),
+			COALESCE(c.tg_tag, ''), COALESCE(c.tg_group, ''), c.has_transcription,
+			EXISTS (SELECT 1 FROM transcriptions t
+			        WHERE t.call_id = c.call_id AND t.call_start_time = c.start_time
+			          AND t.is_primary AND t.source = 'human')
+		FROM calls c`+retranscribeCallsWhere+`
+		  AND ($9::timestamptz IS NULL OR (c.start_time, c.call_id) > ($9, $10::bigint))
+		ORDER BY c.start_time, c.call_id
+		LIMIT $11
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []RetranscribeCall
+	for rows.Next() {
+		var c RetranscribeCall
+		if err := rows.Scan(&c.CallID, &c.StartTime, &c.SystemID, &c.Tgid, &c.Duration,
+			&c.AudioFilePath, &c.CallFilename, &c.SrcList,
+			&c.TgAlphaTag, &c.TgDescription, &c.TgTag, &c.TgGroup, &c.HasTranscription,
+			&c.HumanPrimary); err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+	return result, rows.Err()
+}
+
+// CreateRetranscribeJob inserts a pending job, filling in ID, Status and
+// CreatedAt.
+func (db *DB) CreateRetranscribeJob(ctx context.Context, j *RetranscribeJob) error {
+	return db.Pool.QueryRow(ctx, `
+		INSERT INTO retranscribe_jobs (start_time, end_time, system_ids, tgids, provider, model,
+			make_primary, concurrency, total_calls, audio_seconds, estimated_cost)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, status, created_at
+	`, j.StartTime, j.EndTime, j.SystemIDs, j.Tgids, j.Provider, j.Model,
+		j.MakePrimary, j.Concurrency, j.TotalCalls, j.AudioSeconds, j.EstimatedCost,
+	).Scan(&j.ID, &j.Status, &j.CreatedAt)
+}
+
+// GetRetranscribeJob returns a job by ID.
+func (db *DB) GetRetranscribeJob(ctx context.Context, id int) (*RetranscribeJob, error) {
+	j, err := scanRetranscribeJob(db.Pool.QueryRow(ctx,
+		`SELECT `+retranscribeJobColumns+` FROM retranscribe_jobs WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("retranscribe job not found")
+	}
+	return j, err
+}
+
+// ListRetranscribeJobs returns the most recent jobs, newest first.
+func (db *DB) ListRetranscribeJobs(ctx context.Context, limit int) ([]RetranscribeJob, error) {
+	rows, err := db.Pool.Query(ctx,
+		`SELECT `+retranscribeJobColumns+` FROM retranscribe_jobs ORDER BY id DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []RetranscribeJob{}
+	for rows.Next() {
+		j, err := scanRetranscribeJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *j)
+	}
+	return jobs, rows.Err()
+}
+
+// setRetranscribeStatus moves a job from one of the from statuses to status.
+// Returns "retranscribe job not found", or "retranscribe job is <status>"
+// when the job is in another state.
+func (db *DB) setRetranscribeStatus(ctx context.Context, id int, status string, from ...string) (*RetranscribeJob, error) {
+	j, err := scanRetranscribeJob(db.Pool.QueryRow(ctx, `
+		UPDATE retranscribe_jobs SET status = $2,
+			started_at  = CASE WHEN $2 = 'running' THEN now() ELSE started_at END,
+			finished_at = CASE WHEN $2 = 'running' THEN NULL ELSE now() END
+		WHERE id = $1 AND status = ANY($3)
+		RETURNING `+retranscribeJobColumns, id, status, from))
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return j, err
+	}
+	cur, err := db.GetRetranscribeJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("retranscribe job is %s", cur.Status)
+}
+
+// StartRetranscribeJob moves a pending job to running.
+func (db *DB) StartRetranscribeJob(ctx context.Context, id int) (*RetranscribeJob, error) {
+	return db.setRetranscribeStatus(ctx, id, RetranscribeRunning, RetranscribePending)
+}
+
+// CancelRetranscribeJob cancels a pending or running job. Transcripts
+// already stored are kept.
+func (db *DB) CancelRetranscribeJob(ctx context.Context, id int) (*RetranscribeJob, error) {
+	return db.setRetranscribeStatus(ctx, id, RetranscribeCancelled, RetranscribePending, RetranscribeRunning)
+}
+
+// NextRetranscribeJob returns the running job started first, or nil.
+func (db *DB) NextRetranscribeJob(ctx context.Context) (*RetranscribeJob, error) {
+	j, err := scanRetranscribeJob(db.Pool.QueryRow(ctx, `
+		SELECT `+retranscribeJobColumns+` FROM retranscribe_jobs
+		WHERE status = 'running'
+		ORDER BY started_at, id
+		LIMIT 1`))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return j, err
+}
+
+// AdvanceRetranscribeJob adds a finished page's counts to a running job
+// and moves its cursor to the page's last call. lastErr, if set, replaces
+// the job's last error. Returns the job's status, so a runner notices
+// cancellation.
+func (db *DB) AdvanceRetranscribeJob(ctx context.Context, id, processed, failed int, cursorTime time.Time, cursorCallID int64, lastErr string) (string, error) {
+	var status string
+	err := db.Pool.QueryRow(ctx, `
+		UPDATE retranscribe_jobs SET
+			processed      = processed + $2,
+			failed         = failed + $3,
+			cursor_time    = $4,
+			cursor_call_id = $5,
+			last_error     = COALESCE(NULLIF($6, ''), last_error)
+		WHERE id = $1
+		RETURNING status
+	`, id, processed, failed, cursorTime, cursorCallID, lastErr).Scan(&status)
+	return status, err
+}
+
+// FinishRetranscribeJob marks a running job completed or failed.
+func (db *DB) FinishRetranscribeJob(ctx context.Context, id int, status, lastErr string) error {
+	_, err := db.Pool.Exec(ctx, `
+		UPDATE retranscribe_jobs SET status = $2, finished_at = now(),
+			last_error = COALESCE(NULLIF($3, ''), last_error)
+		WHERE id = $1 AND status = 'running'
+	`, id, status, lastErr)
+	return err
+}
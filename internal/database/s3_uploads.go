@@ -0,0 +1,173 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// S3Upload is an audio file waiting in s3_upload_queue for its S3 copy.
+type S3Upload struct {
+	Key         string    `json:"key"`
+	ContentType string    `json:"content_type"`
+	Priority    int       `json:"priority"`
+	CallTime    time.Time `json:"call_time"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error,omitempty"`
+	NextAttempt time.Time `json:"next_attempt"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// S3UploadStats counts the upload queue by state.
+type S3UploadStats struct {
+	Pending       int64      `json:"pending"`        // waiting or being uploaded, including retrying
+	Retrying      int64      `json:"retrying"`       // pending with at least one failed attempt
+	Priority      int64      `json:"priority"`       // pending with priority > 0 (emergency calls)
+	Failed        int64      `json:"failed"`         // gave up; retried only on request
+	OldestPending *time.Time `json:"oldest_pending"` // created_at of the oldest pending entry
+}
+
+// EnqueueS3Upload adds a file to the upload queue. Re-enqueueing a key
+// resets it to a fresh pending entry, keeping the higher priority.
+func (db *DB) EnqueueS3Upload(ctx context.Context, key, contentType string, priority int, callTime time.Time) error {
+	_, err := db.Pool.Exec(ctx, `
+		INSERT INTO s3_upload_queue (key, content_type, priority, call_time)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key) DO UPDATE SET
+			content_type  = EXCLUDED.content_type,
+			priority      = GREATEST(s3_upload_queue.priority, EXCLUDED.priority),
+			call_time     = EXCLUDED.call_time,
+			attempts      = 0,
+			last_error    = NULL,
+			next_attempt  = now(),
+			claimed_until = NULL,
+			failed        = false
+	`, key, contentType, priority, callTime)
+	return err
+}
+
+// claimS3UploadSQL leases the next due upload: highest priority first,
+// then newest call. SKIP LOCKED lets workers claim different rows at once.
+const claimS3UploadSQL = `
+	UPDATE s3_upload_queue SET claimed_until = now() + $1::interval
+	WHERE key = (
+		SELECT key FROM s3_upload_queue
+		WHERE NOT failed AND next_attempt <= now()
+		  AND (claimed_until IS NULL OR claimed_until < now())
+		ORDER BY priority DESC, call_time DESC
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING key, content_type, priority, call_time, attempts,
+		COALESCE(last_error, ''), next_attempt, created_at
+`
+
+// ClaimS3Upload takes the next due upload for lease: highest priority
+// first, then newest call. Returns nil when nothing is due.
+func (db *DB) ClaimS3Upload(ctx context.Context, lease time.Duration) (*S3Upload, error) {
+	var u S3Upload
+	err := db.Pool.QueryRow(ctx, claimS3UploadSQL, lease).Scan(&u.Key, &u.ContentType, &u.Priority, &u.CallTime, &u.Attempts,
+		&u.LastError, &u.NextAttempt, &u.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// CompleteS3Upload removes an uploaded file from the queue.
+func (db *DB) CompleteS3Upload(ctx context.Context, key string) error {
+	_, err := db.Pool.Exec(ctx, `DELETE FROM s3_upload_queue WHERE key = $1`, key)
+	return err
+}
+
+// FailS3Upload records a failed attempt. The upload is retried after
+// retryAfter, or marked failed when giveUp is set.
+func (db *DB) FailS3Upload(ctx context.Context, key, msg string, retryAfter time.Duration, giveUp bool) error {
+	_, err := db.Pool.Exec(ctx, `
+		UPDATE s3_upload_queue SET
+			attempts      = attempts + 1,
+			last_error    = $2,
+			next_attempt  = now() + $3::interval,
+			claimed_until = NULL,
+			failed        = $4
+		WHERE key = $1
+	`, key, msg, retryAfter, giveUp)
+	return err
+}
+
+// ReleaseS3UploadClaims frees uploads claimed by a previous run so they are
+// picked up again at once after a restart.
+func (db *DB) ReleaseS3UploadClaims(ctx context.Context) (int64, error) {
+	tag, err := db.Pool.Exec(ctx, `
+		UPDATE s3_upload_queue SET claimed_until = NULL WHERE claimed_until IS NOT NULL
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetS3UploadStats counts the upload queue by state.
+func (db *DB) GetS3UploadStats(ctx context.Context) (S3UploadStats, error) {
+	var s S3UploadStats
+	err := db.Pool.QueryRow(ctx, `
+		SELECT
+			count(*) FILTER (WHERE NOT failed),
+			count(*) FILTER (WHERE NOT failed AND attempts > 0),
+			count(*) FILTER (WHERE NOT failed AND priority > 0),
+			count(*) FILTER (WHERE failed),
+			min(created_at) FILTER (WHERE NOT failed)
+		FROM s3_upload_queue
+	`).Scan(&s.Pending, &s.Retrying, &s.Priority, &s.Failed, &s.OldestPending)
+	return s, err
+}
+
+// ListFailedS3Uploads returns uploads that gave up, most recent attempt
+// first, with the total count.
+func (db *DB) ListFailedS3Uploads(ctx context.Context, limit int) ([]S3Upload, int, error) {
+	var total int
+	if err := db.Pool.QueryRow(ctx, `SELECT count(*) FROM s3_upload_queue WHERE failed`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := db.Pool.Query(ctx, `
+		SELECT key, content_type, priority, call_time, attempts,
+			COALESCE(last_error, ''), next_attempt, created_at
+		FROM s3_upload_queue
+		WHERE failed
+		ORDER BY next_attempt DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	result := []S3Upload{}
+	for rows.Next() {
+		var u S3Upload
+		if err := rows.Scan(&u.Key, &u.ContentType, &u.Priority, &u.CallTime, &u.Attempts,
+			&u.LastError, &u.NextAttempt, &u.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		result = append(result, u)
+	}
+	return result, total, rows.Err()
+}
+
+// RetryFailedS3Uploads returns every failed upload to the queue with its
+// attempts reset. Returns the number of uploads reset.
+func (db *DB) RetryFailedS3Uploads(ctx context.Context) (int64, error) {
+	tag, err := db.Pool.Exec(ctx, `
+		UPDATE s3_upload_queue SET failed = false, attempts = 0, next_attempt = now()
+		WHERE failed
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
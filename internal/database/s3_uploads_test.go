@@ -0,0 +1,21 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestClaimS3UploadSQL pins the claim order — emergency calls first, then
+// newest call — and the row locking that keeps workers from claiming the
+// same upload.
+func TestClaimS3UploadSQL(t *testing.T) {
+	q := strings.Join(strings.Fields(claimS3UploadSQL), " ")
+	for _, want := range []string{
+		"WHERE NOT failed AND next_attempt <= now() AND (claimed_until IS NULL OR claimed_until < now())",
+		"ORDER BY priority DESC, call_time DESC LIMIT 1 FOR UPDATE SKIP LOCKED",
+	} {
+		if !strings.Contains(q, want) {
+			t.Errorf("claim query lacks %q:\n%s", want, q)
+		}
+	}
+}
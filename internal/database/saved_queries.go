@@ -0,0 +1,150 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SavedQuery is a named call/search filter run by ID with ?saved_query=.
+// Filter maps query parameter names to their values. Owned by one API token;
+// Shared makes it visible to every token.
+type SavedQuery struct {
+	ID          int               `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Filter      map[string]string `json:"filter"`
+	Shared      bool              `json:"shared"`
+	Mine        bool              `json:"mine"` // owned by the requesting token
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// SavedQueryUpdate changes a saved query; nil fields are left unchanged.
+type SavedQueryUpdate struct {
+	Name        *string
+	Description *string
+	Filter      map[string]string
+	Shared      *bool
+}
+
+// savedQueryColumns selects a saved query as seen by owner $1.
+const savedQueryColumns = `id, name, COALESCE(description, ''), filter, shared, owner = $1, created_at, updated_at`
+
+func scanSavedQuery(row pgx.Row) (*SavedQuery, error) {
+	var q SavedQuery
+	if err := row.Scan(&q.ID, &q.Name, &q.Description, &q.Filter, &q.Shared, &q.Mine,
+		&q.CreatedAt, &q.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if q.Filter == nil {
+		q.Filter = map[string]string{}
+	}
+	return &q, nil
+}
+
+func savedQueryErr(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return fmt.Errorf("saved query already exists")
+	}
+	if err == pgx.ErrNoRows {
+		return fmt.Errorf("saved query not found")
+	}
+	return err
+}
+
+// ListSavedQueries returns the saved queries owner can see (its own and
+// shared ones) by name, optionally only those whose name or description
+// contains search, and the total matching.
+func (db *DB) ListSavedQueries(ctx context.Context, owner, search string, limit, offset int) ([]SavedQuery, int, error) {
+	const where = `
+		WHERE (owner = $1 OR shared)
+		  AND ($2 = '' OR name ILIKE '%' || $2 || '%' OR description ILIKE '%' || $2 || '%')`
+	var total int
+	if err := db.Pool.QueryRow(ctx, `SELECT count(*) FROM saved_queries`+where, owner, search).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+savedQueryColumns+` FROM saved_queries`+where+`
+		ORDER BY name, id
+		LIMIT $3 OFFSET $4
+	`, owner, search, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	queries := []SavedQuery{}
+	for rows.Next() {
+		q, err := scanSavedQuery(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		queries = append(queries, *q)
+	}
+	return queries, total, rows.Err()
+}
+
+// GetSavedQuery returns a saved query owner can see. Returns "saved query
+// not found" if it doesn't exist or is another token's private query.
+func (db *DB) GetSavedQuery(ctx context.Context, owner string, id int) (*SavedQuery, error) {
+	q, err := scanSavedQuery(db.Pool.QueryRow(ctx, `
+		SELECT `+savedQueryColumns+` FROM saved_queries
+		WHERE id = $2 AND (owner = $1 OR shared)
+	`, owner, id))
+	return q, savedQueryErr(err)
+}
+
+// CreateSavedQuery saves q for owner and fills in its ID and timestamps.
+// Returns "saved query already exists" if owner has one with the same name.
+func (db *DB) CreateSavedQuery(ctx context.Context, owner string, q *SavedQuery) error {
+	err := db.Pool.QueryRow(ctx, `
+		INSERT INTO saved_queries (owner, name, description, filter, shared)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+		RETURNING id, created_at, updated_at
+	`, owner, q.Name, q.Description, q.Filter, q.Shared).Scan(&q.ID, &q.CreatedAt, &q.UpdatedAt)
+	if err != nil {
+		return savedQueryErr(err)
+	}
+	q.Mine = true
+	return nil
+}
+
+// UpdateSavedQuery changes one of owner's saved queries. Returns "saved
+// query not found" if owner doesn't own it.
+func (db *DB) UpdateSavedQuery(ctx context.Context, owner string, id int, u SavedQueryUpdate) (*SavedQuery, error) {
+	var filter any
+	if u.Filter != nil {
+		filter = u.Filter
+	}
+	q, err := scanSavedQuery(db.Pool.QueryRow(ctx, `
+		UPDATE saved_queries SET
+			name        = COALESCE($3, name),
+			description = CASE WHEN $4::text IS NULL THEN description ELSE NULLIF($4, '') END,
+			filter      = COALESCE($5::jsonb, filter),
+			shared      = COALESCE($6, shared),
+			updated_at  = now()
+		WHERE id = $2 AND owner = $1
+		RETURNING `+savedQueryColumns,
+		owner, id, u.Name, u.Description, filter, u.Shared))
+	return q, savedQueryErr(err)
+}
+
+// DeleteSavedQuery removes one of owner's saved queries, or with anyOwner
+// any saved query.
+func (db *DB) DeleteSavedQuery(ctx context.Context, owner string, id int, anyOwner bool) error {
+	tag, err := db.Pool.Exec(ctx,
+		`DELETE FROM saved_queries WHERE id = $2 AND (owner = $1 OR $3)`, owner, id, anyOwner)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("saved query not found")
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ShareLink is one share_links row: a temporary grant to a system's
+// talkgroups (any system when SystemID is nil), covering calls from
+// HistorySeconds before CreatedAt until ExpiresAt. The signed token itself
+// isn't stored; the row records the issuer and allows early revocation.
+type ShareLink struct {
+	ID             int        `json:"id"`
+	Label          string     `json:"label"`
+	SystemID       *int       `json:"system_id"`
+	Tgids          []int      `json:"tgids"`
+	HistorySeconds int        `json:"history_seconds"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	Active         bool       `json:"active"` // not revoked and not expired
+	CreatedBy      string     `json:"created_by"`
+	CreatedAt      time.Time  `json:"created_at"`
+	RevokedBy      *string    `json:"revoked_by"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+}
+
+// Since is the start of the link's call history.
+func (l *ShareLink) Since() time.Time {
+	return l.CreatedAt.Add(-time.Duration(l.HistorySeconds) * time.Second)
+}
+
+const shareLinkColumns = `id, label, system_id, tgids, history_seconds, expires_at,
+	revoked_at IS NULL AND expires_at > now(), created_by, created_at, revoked_by, revoked_at`
+
+func scanShareLink(row pgx.Row) (*ShareLink, error) {
+	var l ShareLink
+	if err := row.Scan(&l.ID, &l.Label, &l.SystemID, &l.Tgids, &l.HistorySeconds, &l.ExpiresAt,
+		&l.Active, &l.CreatedBy, &l.CreatedAt, &l.RevokedBy, &l.RevokedAt); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// ListShareLinks returns share links, newest first; only unrevoked,
+// unexpired ones when activeOnly is set.
+func (db *DB) ListShareLinks(ctx context.Context, activeOnly bool) ([]ShareLink, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+shareLinkColumns+`
+		FROM share_links
+		WHERE NOT $1 OR (revoked_at IS NULL AND expires_at > now())
+		ORDER BY id DESC
+	`, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []ShareLink{}
+	for rows.Next() {
+		l, err := scanShareLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, *l)
+	}
+	return links, rows.Err()
+}
+
+// GetShareLink returns a share link. Returns "share link not found" if id is
+// unknown.
+func (db *DB) GetShareLink(ctx context.Context, id int) (*ShareLink, error) {
+	l, err := scanShareLink(db.Pool.QueryRow(ctx,
+		`SELECT `+shareLinkColumns+` FROM share_links WHERE id = $1`, id))
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("share link not found")
+	}
+	return l, err
+}
+
+// CreateShareLink inserts a share link and fills in its ID and creation time.
+func (db *DB) CreateShareLink(ctx context.Context, l *ShareLink) error {
+	created, err := scanShareLink(db.Pool.QueryRow(ctx, `
+		INSERT INTO share_links (label, system_id, tgids, history_seconds, expires_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+shareLinkColumns,
+		l.Label, l.SystemID, l.Tgids, l.HistorySeconds, l.ExpiresAt, l.CreatedBy))
+	if err != nil {
+		return err
+	}
+	*l = *created
+	return nil
+}
+
+// RevokeShareLink ends a share link before it expires; the row is kept as a
+// record of who revoked it. Returns "share link not found" or "share link
+// is revoked".
+func (db *DB) RevokeShareLink(ctx context.Context, id int, actor string) (*ShareLink, error) {
+	l, err := scanShareLink(db.Pool.QueryRow(ctx, `
+		UPDATE share_links SET revoked_by = $2, revoked_at = now()
+		WHERE id = $1 AND revoked_at IS NULL
+		RETURNING `+shareLinkColumns,
+		id, actor))
+	if err == pgx.ErrNoRows {
+		if _, err := db.GetShareLink(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("share link is revoked")
+	}
+	return l, err
+}
+
+// ShareLinkActive reports whether a share link exists and is neither revoked
+// nor expired. Checked on every request made with its token.
+func (db *DB) ShareLinkActive(ctx context.Context, id int) (bool, error) {
+	var active bool
+	err := db.Pool.QueryRow(ctx,
+		`SELECT revoked_at IS NULL AND expires_at > now() FROM share_links WHERE id = $1`, id).Scan(&active)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	return active, err
+}
@@ -38,14 +38,15 @@ func (db *DB) UpdateSite(ctx context.Context, siteID int, sysNum int, nac string
 
 // SiteAPI represents a site for API responses.
 type SiteAPI struct {
-	SiteID     int    `json:"site_id"`
-	SystemID   int    `json:"system_id"`
-	ShortName  string `json:"short_name"`
-	InstanceID string `json:"instance_id"`
-	Nac        string `json:"nac,omitempty"`
-	Rfss       *int   `json:"rfss,omitempty"`
-	P25SiteID  *int   `json:"p25_site_id,omitempty"`
-	SysNum     *int   `json:"sys_num,omitempty"`
+	SiteID     int     `json:"site_id"`
+	SystemID   int     `json:"system_id"`
+	ShortName  string  `json:"short_name"`
+	InstanceID string  `json:"instance_id"`
+	Nac        string  `json:"nac,omitempty"`
+	Rfss       *int    `json:"rfss,omitempty"`
+	P25SiteID  *int    `json:"p25_site_id,omitempty"`
+	SysNum     *int    `json:"sys_num,omitempty"`
+	Display    Display `json:"display"`
 }
 
 func siteRowToAPI(r sqlcdb.GetSiteByIDRow) SiteAPI {
@@ -124,7 +125,11 @@ func (db *DB) GetSiteByID(ctx context.Context, siteID int) (*SiteAPI, error) {
 		return nil, err
 	}
 	s := siteRowToAPI(row)
-	return &s, nil
+	sites := []SiteAPI{s}
+	if err := db.fillSiteDisplays(ctx, sites); err != nil {
+		return nil, err
+	}
+	return &sites[0], nil
 }
 
 // ListSitesForSystem returns all sites for a given system.
@@ -137,6 +142,9 @@ func (db *DB) ListSitesForSystem(ctx context.Context, systemID int) ([]SiteAPI,
 	for i, r := range rows {
 		sites[i] = listSiteRowToAPI(r)
 	}
+	if err := db.fillSiteDisplays(ctx, sites); err != nil {
+		return nil, err
+	}
 	return sites, nil
 }
 
@@ -150,6 +158,9 @@ func (db *DB) LoadAllSitesAPI(ctx context.Context) ([]SiteAPI, error) {
 	for i, r := range rows {
 		sites[i] = allSiteRowToAPI(r)
 	}
+	if err := db.fillSiteDisplays(ctx, sites); err != nil {
+		return nil, err
+	}
 	return sites, nil
 }
 
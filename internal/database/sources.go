@@ -0,0 +1,194 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Source is an SDR source from a TR instance's config (sources table).
+// Calls and recorders refer to it by src_num.
+type Source struct {
+	InstanceID       string    `json:"instance_id"`
+	SourceNum        int       `json:"source_num"`
+	Device           string    `json:"device"`
+	Driver           string    `json:"driver"`
+	Antenna          string    `json:"antenna,omitempty"`
+	CenterHz         int64     `json:"center_hz"`
+	RateHz           int64     `json:"rate_hz"`
+	MinHz            int64     `json:"min_hz"`
+	MaxHz            int64     `json:"max_hz"`
+	ErrorHz          int       `json:"error_hz"`
+	Gain             string    `json:"gain,omitempty"`
+	AnalogRecorders  int       `json:"analog_recorders"`
+	DigitalRecorders int       `json:"digital_recorders"`
+	Origin           string    `json:"origin"` // mqtt or tr_dir
+	Active           bool      `json:"active"` // in the instance's latest config
+	FirstSeen        time.Time `json:"first_seen"`
+	LastSeen         time.Time `json:"last_seen"`
+}
+
+// Covers reports whether freq (Hz) is inside the source's sampled band.
+func (s *Source) Covers(freq int64) bool {
+	return freq >= s.MinHz && freq <= s.MaxHz
+}
+
+// SyncSources records an instance's configured sources: each is inserted or
+// updated, and the instance's sources missing from the list are marked
+// inactive. Sources keep their first_seen across updates.
+func (db *DB) SyncSources(ctx context.Context, instanceID, origin string, sources []Source, seen time.Time) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	nums := make([]int32, 0, len(sources))
+	for _, s := range sources {
+		nums = append(nums, int32(s.SourceNum))
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO sources (instance_id, source_num, device, driver, antenna, center_hz, rate_hz,
+				min_hz, max_hz, error_hz, gain, analog_recorders, digital_recorders, origin,
+				active, first_seen, last_seen)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, true, $15, $15)
+			ON CONFLICT (instance_id, source_num) DO UPDATE SET
+				device = EXCLUDED.device, driver = EXCLUDED.driver, antenna = EXCLUDED.antenna,
+				center_hz = EXCLUDED.center_hz, rate_hz = EXCLUDED.rate_hz,
+				min_hz = EXCLUDED.min_hz, max_hz = EXCLUDED.max_hz, error_hz = EXCLUDED.error_hz,
+				gain = EXCLUDED.gain, analog_recorders = EXCLUDED.analog_recorders,
+				digital_recorders = EXCLUDED.digital_recorders, origin = EXCLUDED.origin,
+				active = true, last_seen = GREATEST(sources.last_seen, EXCLUDED.last_seen)
+		`, instanceID, s.SourceNum, s.Device, s.Driver, s.Antenna, s.CenterHz, s.RateHz,
+			s.MinHz, s.MaxHz, s.ErrorHz, s.Gain, s.AnalogRecorders, s.DigitalRecorders, origin, seen); err != nil {
+			return fmt.Errorf("upsert source %d: %w", s.SourceNum, err)
+		}
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE sources SET active = false
+		WHERE instance_id = $1 AND active AND NOT (source_num = ANY($2))
+	`, instanceID, nums); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// SourceFrequency is one frequency a source recorded calls on.
+type SourceFrequency struct {
+	Freq       int64   `json:"freq"`
+	Calls      int64   `json:"calls"`
+	ErrorCount int64   `json:"error_count"`
+	ErrorRate  float64 `json:"error_rate"` // share of calls with decode errors
+	InRange    bool    `json:"in_range"`   // inside the source's configured band
+}
+
+// SourceReport is a source with the calls recorded on it in a time range.
+type SourceReport struct {
+	Source
+	Calls           int64             `json:"calls"`
+	CallsWithErrors int64             `json:"calls_with_errors"`
+	ErrorRate       float64           `json:"error_rate"` // share of calls with decode errors
+	ErrorCount      int64             `json:"error_count"`
+	SpikeCount      int64             `json:"spike_count"`
+	AudioSeconds    float64           `json:"audio_seconds"`
+	ErrorsPerMinute float64           `json:"errors_per_minute"` // decode errors per minute of audio
+	Frequencies     []SourceFrequency `json:"frequencies"`       // busiest first
+}
+
+// SourcesReport is an instance's sources with their call statistics.
+type SourcesReport struct {
+	InstanceID string         `json:"instance_id"`
+	StartTime  time.Time      `json:"start_time"`
+	EndTime    time.Time      `json:"end_time"`
+	Sources    []SourceReport `json:"sources"`
+	// Calls whose src_num matches no configured source (or that have none).
+	UnassignedCalls int64 `json:"unassigned_calls"`
+}
+
+// GetSourcesReport returns an instance's sources, ordered by source number,
+// with per-source and per-frequency call counts and error rates for calls
+// starting in [start, end).
+func (db *DB) GetSourcesReport(ctx context.Context, instanceID string, start, end time.Time) (*SourcesReport, error) {
+	rep := &SourcesReport{InstanceID: instanceID, StartTime: start, EndTime: end, Sources: []SourceReport{}}
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT instance_id, source_num, device, driver, antenna, center_hz, rate_hz, min_hz, max_hz,
+			error_hz, gain, analog_recorders, digital_recorders, origin, active, first_seen, last_seen
+		FROM sources WHERE instance_id = $1
+		ORDER BY source_num
+	`, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	bySource := make(map[int]*SourceReport)
+	for rows.Next() {
+		var s SourceReport
+		if err := rows.Scan(&s.InstanceID, &s.SourceNum, &s.Device, &s.Driver, &s.Antenna,
+			&s.CenterHz, &s.RateHz, &s.MinHz, &s.MaxHz, &s.ErrorHz, &s.Gain,
+			&s.AnalogRecorders, &s.DigitalRecorders, &s.Origin, &s.Active, &s.FirstSeen, &s.LastSeen); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		s.Frequencies = []SourceFrequency{}
+		rep.Sources = append(rep.Sources, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range rep.Sources {
+		bySource[rep.Sources[i].SourceNum] = &rep.Sources[i]
+	}
+
+	rows, err = db.Pool.Query(ctx, `
+		SELECT src_num, COALESCE(freq, 0), count(*),
+			count(*) FILTER (WHERE error_count > 0),
+			COALESCE(sum(error_count), 0), COALESCE(sum(spike_count), 0),
+			COALESCE(sum(duration), 0)
+		FROM calls
+		WHERE instance_id = $1 AND start_time >= $2 AND start_time < $3
+		GROUP BY src_num, freq
+		ORDER BY count(*) DESC
+	`, instanceID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var srcNum *int
+		var f SourceFrequency
+		var withErrors, spikes int64
+		var seconds float64
+		if err := rows.Scan(&srcNum, &f.Freq, &f.Calls, &withErrors, &f.ErrorCount, &spikes, &seconds); err != nil {
+			return nil, err
+		}
+		var s *SourceReport
+		if srcNum != nil {
+			s = bySource[*srcNum]
+		}
+		if s == nil {
+			rep.UnassignedCalls += f.Calls
+			continue
+		}
+		f.ErrorRate = float64(withErrors) / float64(f.Calls)
+		f.InRange = s.Covers(f.Freq)
+		s.Frequencies = append(s.Frequencies, f)
+		s.Calls += f.Calls
+		s.CallsWithErrors += withErrors
+		s.ErrorCount += f.ErrorCount
+		s.SpikeCount += spikes
+		s.AudioSeconds += seconds
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range rep.Sources {
+		s := &rep.Sources[i]
+		if s.Calls > 0 {
+			s.ErrorRate = float64(s.CallsWithErrors) / float64(s.Calls)
+		}
+		if s.AudioSeconds > 0 {
+			s.ErrorsPerMinute = float64(s.ErrorCount) / (s.AudioSeconds / 60)
+		}
+	}
+	return rep, nil
+}
@@ -66,39 +66,53 @@ func (q *Queries) FindCallForAudio(ctx context.Context, arg FindCallForAudioPara
 
 const getCallAudioPath = `-- name: GetCallAudioPath :one
 SELECT COALESCE(audio_file_path, '') AS audio_file_path, COALESCE(call_filename, '') AS call_filename
-FROM calls WHERE call_id = $1
-ORDER BY start_time DESC LIMIT 1
+FROM calls WHERE call_id = $1 AND start_time = $2
 `
 
+type GetCallAudioPathParams struct {
+	CallID    int64
+	StartTime pgtype.Timestamptz
+}
+
 type GetCallAudioPathRow struct {
 	AudioFilePath string
 	CallFilename  string
 }
 
-func (q *Queries) GetCallAudioPath(ctx context.Context, callID int64) (GetCallAudioPathRow, error) {
-	row := q.db.QueryRow(ctx, getCallAudioPath, callID)
+func (q *Queries) GetCallAudioPath(ctx context.Context, arg GetCallAudioPathParams) (GetCallAudioPathRow, error) {
+	row := q.db.QueryRow(ctx, getCallAudioPath, arg.CallID, arg.StartTime)
 	var i GetCallAudioPathRow
 	err := row.Scan(&i.AudioFilePath, &i.CallFilename)
 	return i, err
 }
 
 const getCallFreqList = `-- name: GetCallFreqList :one
-SELECT freq_list FROM calls WHERE call_id = $1 ORDER BY start_time DESC LIMIT 1
+SELECT freq_list FROM calls WHERE call_id = $1 AND start_time = $2
 `
 
-func (q *Queries) GetCallFreqList(ctx context.Context, callID int64) ([]byte, error) {
-	row := q.db.QueryRow(ctx, getCallFreqList, callID)
+type GetCallFreqListParams struct {
+	CallID    int64
+	StartTime pgtype.Timestamptz
+}
+
+func (q *Queries) GetCallFreqList(ctx context.Context, arg GetCallFreqListParams) ([]byte, error) {
+	row := q.db.QueryRow(ctx, getCallFreqList, arg.CallID, arg.StartTime)
 	var freq_list []byte
 	err := row.Scan(&freq_list)
 	return freq_list, err
 }
 
 const getCallSrcList = `-- name: GetCallSrcList :one
-SELECT src_list FROM calls WHERE call_id = $1 ORDER BY start_time DESC LIMIT 1
+SELECT src_list FROM calls WHERE call_id = $1 AND start_time = $2
 `
 
-func (q *Queries) GetCallSrcList(ctx context.Context, callID int64) ([]byte, error) {
-	row := q.db.QueryRow(ctx, getCallSrcList, callID)
+type GetCallSrcListParams struct {
+	CallID    int64
+	StartTime pgtype.Timestamptz
+}
+
+func (q *Queries) GetCallSrcList(ctx context.Context, arg GetCallSrcListParams) ([]byte, error) {
+	row := q.db.QueryRow(ctx, getCallSrcList, arg.CallID, arg.StartTime)
 	var src_list []byte
 	err := row.Scan(&src_list)
 	return src_list, err
@@ -36,11 +36,14 @@ SELECT call_id, start_time, system_id, tgid, duration,
     COALESCE(tg_tag, '') AS tg_tag,
     COALESCE(tg_group, '') AS tg_group
 FROM calls
-WHERE call_id = $1
-ORDER BY start_time DESC
-LIMIT 1
+WHERE call_id = $1 AND start_time = $2
 `
 
+type GetCallForTranscriptionParams struct {
+	CallID    int64
+	StartTime pgtype.Timestamptz
+}
+
 type GetCallForTranscriptionRow struct {
 	CallID           int64
 	StartTime        pgtype.Timestamptz
@@ -58,8 +61,8 @@ type GetCallForTranscriptionRow struct {
 	TgGroup          string
 }
 
-func (q *Queries) GetCallForTranscription(ctx context.Context, callID int64) (GetCallForTranscriptionRow, error) {
-	row := q.db.QueryRow(ctx, getCallForTranscription, callID)
+func (q *Queries) GetCallForTranscription(ctx context.Context, arg GetCallForTranscriptionParams) (GetCallForTranscriptionRow, error) {
+	row := q.db.QueryRow(ctx, getCallForTranscription, arg.CallID, arg.StartTime)
 	var i GetCallForTranscriptionRow
 	err := row.Scan(
 		&i.CallID,
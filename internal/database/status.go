@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// StatusDay is one component's minute counts for a UTC day.
+type StatusDay struct {
+	Day         time.Time `json:"-"`
+	Component   string    `json:"-"`
+	Operational int       `json:"operational_minutes"`
+	Degraded    int       `json:"degraded_minutes"`
+	Down        int       `json:"down_minutes"`
+}
+
+// AddStatusMinute adds one minute in the given state ("operational",
+// "degraded" or "down") to each component's bucket for ts's UTC day.
+func (db *DB) AddStatusMinute(ctx context.Context, ts time.Time, states map[string]string) error {
+	if len(states) == 0 {
+		return nil
+	}
+	day := ts.UTC().Format("2006-01-02")
+	batch := &pgx.Batch{}
+	for component, state := range states {
+		var op, deg, down int
+		switch state {
+		case "operational":
+			op = 1
+		case "degraded":
+			deg = 1
+		default:
+			down = 1
+		}
+		batch.Queue(`
+			INSERT INTO status_daily (day, component, operational_minutes, degraded_minutes, down_minutes)
+			VALUES ($1::date, $2, $3, $4, $5)
+			ON CONFLICT (day, component) DO UPDATE SET
+				operational_minutes = status_daily.operational_minutes + EXCLUDED.operational_minutes,
+				degraded_minutes = status_daily.degraded_minutes + EXCLUDED.degraded_minutes,
+				down_minutes = status_daily.down_minutes + EXCLUDED.down_minutes
+		`, day, component, op, deg, down)
+	}
+	return db.Pool.SendBatch(ctx, batch).Close()
+}
+
+// ListStatusDays returns per-day minute counts for UTC days on or after
+// since, ordered by component and day.
+func (db *DB) ListStatusDays(ctx context.Context, since time.Time) ([]StatusDay, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT day, component, operational_minutes, degraded_minutes, down_minutes
+		FROM status_daily
+		WHERE day >= $1::date
+		ORDER BY component, day
+	`, since.UTC().Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var days []StatusDay
+	for rows.Next() {
+		var d StatusDay
+		if err := rows.Scan(&d.Day, &d.Component, &d.Operational, &d.Degraded, &d.Down); err != nil {
+			return nil, err
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
+
+// PruneStatusDays deletes uptime history for UTC days before the given one.
+func (db *DB) PruneStatusDays(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := db.Pool.Exec(ctx, `DELETE FROM status_daily WHERE day < $1::date`,
+		before.UTC().Format("2006-01-02"))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Storage policies decide how a talkgroup's call audio is kept at ingest.
+const (
+	StoragePolicyFull      = "full"      // keep audio as received (the default)
+	StoragePolicyTranscode = "transcode" // re-encode to low-bitrate Opus
+	StoragePolicyMetadata  = "metadata"  // keep no audio
+)
+
+// DefaultTranscodeBitrate is the Opus bitrate (bps) for transcode policies
+// that don't set one.
+const DefaultTranscodeBitrate = 16000
+
+// StoragePolicy is one talkgroup_storage_policies row.
+type StoragePolicy struct {
+	SystemID  int       `json:"system_id"`
+	Tgid      int       `json:"tgid"`
+	Policy    string    `json:"policy"`
+	Bitrate   int       `json:"bitrate,omitempty"` // bps, transcode only
+	Note      string    `json:"note,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ValidStoragePolicy reports whether policy is a known policy name.
+func ValidStoragePolicy(policy string) bool {
+	switch policy {
+	case StoragePolicyFull, StoragePolicyTranscode, StoragePolicyMetadata:
+		return true
+	}
+	return false
+}
+
+// ListStoragePolicies returns all talkgroup storage policies.
+func (db *DB) ListStoragePolicies(ctx context.Context) ([]StoragePolicy, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT system_id, tgid, policy, COALESCE(bitrate, 0), COALESCE(note, ''), updated_at
+		FROM talkgroup_storage_policies
+		ORDER BY system_id, tgid
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []StoragePolicy{}
+	for rows.Next() {
+		var p StoragePolicy
+		if err := rows.Scan(&p.SystemID, &p.Tgid, &p.Policy, &p.Bitrate, &p.Note, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+// GetStoragePolicy returns a talkgroup's storage policy, or nil if it has
+// none (full).
+func (db *DB) GetStoragePolicy(ctx context.Context, systemID, tgid int) (*StoragePolicy, error) {
+	p := StoragePolicy{SystemID: systemID, Tgid: tgid}
+	err := db.Pool.QueryRow(ctx, `
+		SELECT policy, COALESCE(bitrate, 0), COALESCE(note, ''), updated_at
+		FROM talkgroup_storage_policies
+		WHERE system_id = $1 AND tgid = $2
+	`, systemID, tgid).Scan(&p.Policy, &p.Bitrate, &p.Note, &p.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// UpsertStoragePolicy creates or replaces a talkgroup's storage policy.
+func (db *DB) UpsertStoragePolicy(ctx context.Context, systemID, tgid int, policy string, bitrate int, note string) (*StoragePolicy, error) {
+	p := StoragePolicy{SystemID: systemID, Tgid: tgid}
+	err := db.Pool.QueryRow(ctx, `
+		INSERT INTO talkgroup_storage_policies (system_id, tgid, policy, bitrate, note)
+		VALUES ($1, $2, $3, NULLIF($4, 0), NULLIF($5, ''))
+		ON CONFLICT (system_id, tgid) DO UPDATE SET
+			policy = EXCLUDED.policy,
+			bitrate = EXCLUDED.bitrate,
+			note = EXCLUDED.note,
+			updated_at = now()
+		RETURNING policy, COALESCE(bitrate, 0), COALESCE(note, ''), updated_at
+	`, systemID, tgid, policy, bitrate, note).Scan(&p.Policy, &p.Bitrate, &p.Note, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DeleteStoragePolicy removes a talkgroup's storage policy, reverting it to
+// full. Returns whether a policy existed.
+func (db *DB) DeleteStoragePolicy(ctx context.Context, systemID, tgid int) (bool, error) {
+	tag, err := db.Pool.Exec(ctx,
+		`DELETE FROM talkgroup_storage_policies WHERE system_id = $1 AND tgid = $2`, systemID, tgid)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// STTPromptOverride replaces the built STT prompt for a system (Tgid 0) or
+// talkgroup.
+type STTPromptOverride struct {
+	SystemID  int       `json:"system_id"`
+	Tgid      int       `json:"tgid"` // 0 = whole system
+	Prompt    string    `json:"prompt"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// STTPromptContext is what a call's STT prompt is built from, beyond the
+// call itself.
+type STTPromptContext struct {
+	Override string   // talkgroup or system override; "" = none
+	Recent   []string // recent transcripts on the talkgroup, oldest first
+}
+
+// ListSTTPromptOverrides returns all prompt overrides.
+func (db *DB) ListSTTPromptOverrides(ctx context.Context) ([]STTPromptOverride, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT system_id, tgid, prompt, updated_at FROM stt_prompt_overrides
+		ORDER BY system_id, tgid
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	overrides := []STTPromptOverride{}
+	for rows.Next() {
+		var o STTPromptOverride
+		if err := rows.Scan(&o.SystemID, &o.Tgid, &o.Prompt, &o.UpdatedAt); err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, rows.Err()
+}
+
+// UpsertSTTPromptOverride creates or replaces the prompt override for a
+// system (tgid 0) or talkgroup.
+func (db *DB) UpsertSTTPromptOverride(ctx context.Context, systemID, tgid int, prompt string) (*STTPromptOverride, error) {
+	var exists bool
+	if err := db.Pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM systems WHERE system_id = $1 AND deleted_at IS NULL)`, systemID,
+	).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("system not found")
+	}
+
+	o := STTPromptOverride{SystemID: systemID, Tgid: tgid, Prompt: prompt}
+	err := db.Pool.QueryRow(ctx, `
+		INSERT INTO stt_prompt_overrides (system_id, tgid, prompt)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (system_id, tgid) DO UPDATE SET prompt = EXCLUDED.prompt, updated_at = now()
+		RETURNING updated_at
+	`, systemID, tgid, prompt).Scan(&o.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// DeleteSTTPromptOverride removes an override. Returns whether one existed.
+func (db *DB) DeleteSTTPromptOverride(ctx context.Context, systemID, tgid int) (bool, error) {
+	tag, err := db.Pool.Exec(ctx,
+		`DELETE FROM stt_prompt_overrides WHERE system_id = $1 AND tgid = $2`, systemID, tgid)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetSTTPromptContext returns the prompt override for a talkgroup (falling
+// back to its system's) and, when limit > 0, up to limit primary transcripts
+// of calls on the talkgroup that started within window before before.
+func (db *DB) GetSTTPromptContext(ctx context.Context, systemID, tgid int, before time.Time, window time.Duration, limit int) (*STTPromptContext, error) {
+	var pc STTPromptContext
+	err := db.Pool.QueryRow(ctx, `
+		SELECT prompt FROM stt_prompt_overrides
+		WHERE system_id = $1 AND tgid IN (0, $2)
+		ORDER BY tgid DESC
+		LIMIT 1
+	`, systemID, tgid).Scan(&pc.Override)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	if limit <= 0 {
+		return &pc, nil
+	}
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT transcription_text FROM calls
+		WHERE system_id = $1 AND tgid = $2
+		  AND start_time < $3 AND start_time >= $4
+		  AND transcription_text IS NOT NULL AND transcription_text <> ''
+		ORDER BY start_time DESC
+		LIMIT $5
+	`, systemID, tgid, before, before.Add(-window), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var text string
+		if err := rows.Scan(&text); err != nil {
+			return nil, err
+		}
+		pc.Recent = append(pc.Recent, text)
+	}
+	// Newest first from the query; prompts read oldest first.
+	for i, j := 0, len(pc.Recent)-1; i < j; i, j = i+1, j-1 {
+		pc.Recent[i], pc.Recent[j] = pc.Recent[j], pc.Recent[i]
+	}
+	return &pc, rows.Err()
+}
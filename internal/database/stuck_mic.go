@@ -0,0 +1,25 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// MarkCallStuckMic flags a call as a suspected stuck microphone.
+func (db *DB) MarkCallStuckMic(ctx context.Context, callID int64, startTime time.Time) error {
+	_, err := db.Pool.Exec(ctx, `
+		UPDATE calls SET stuck_mic = true, updated_at = now()
+		WHERE call_id = $1 AND start_time = $2
+	`, callID, startTime)
+	return err
+}
+
+// SetCallSpeechRatio records the measured speech ratio of a stuck mic suspect
+// and whether it is still considered stuck.
+func (db *DB) SetCallSpeechRatio(ctx context.Context, callID int64, startTime time.Time, ratio float32, stuck bool) error {
+	_, err := db.Pool.Exec(ctx, `
+		UPDATE calls SET speech_ratio = $3, stuck_mic = $4, updated_at = now()
+		WHERE call_id = $1 AND start_time = $2
+	`, callID, startTime, ratio, stuck)
+	return err
+}
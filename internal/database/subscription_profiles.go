@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SubscriptionProfile is a saved, named event filter owned by one API token.
+// Filter holds an api.EventFilter as JSON.
+type SubscriptionProfile struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Filter      json.RawMessage `json:"filter"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+const subscriptionProfileColumns = `name, COALESCE(description, ''), filter, created_at, updated_at`
+
+func scanSubscriptionProfile(row pgx.Row) (*SubscriptionProfile, error) {
+	var p SubscriptionProfile
+	if err := row.Scan(&p.Name, &p.Description, &p.Filter, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListSubscriptionProfiles returns owner's profiles ordered by name.
+func (db *DB) ListSubscriptionProfiles(ctx context.Context, owner string) ([]SubscriptionProfile, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+subscriptionProfileColumns+` FROM subscription_profiles
+		WHERE owner = $1 ORDER BY name
+	`, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := []SubscriptionProfile{}
+	for rows.Next() {
+		p, err := scanSubscriptionProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, *p)
+	}
+	return profiles, rows.Err()
+}
+
+// GetSubscriptionProfiles returns owner's profiles with the given names, in
+// the order requested. Returns an error naming the first that doesn't exist.
+func (db *DB) GetSubscriptionProfiles(ctx context.Context, owner string, names []string) ([]SubscriptionProfile, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT `+subscriptionProfileColumns+` FROM subscription_profiles
+		WHERE owner = $1 AND name = ANY($2)
+	`, owner, names)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byName := make(map[string]SubscriptionProfile, len(names))
+	for rows.Next() {
+		p, err := scanSubscriptionProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		byName[p.Name] = *p
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	profiles := make([]SubscriptionProfile, 0, len(names))
+	for _, n := range names {
+		p, ok := byName[n]
+		if !ok {
+			return nil, fmt.Errorf("subscription profile not found: %s", n)
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
+// CreateSubscriptionProfile saves a new profile for owner and fills in its
+// timestamps.
+func (db *DB) CreateSubscriptionProfile(ctx context.Context, owner string, p *SubscriptionProfile) error {
+	err := db.Pool.QueryRow(ctx, `
+		INSERT INTO subscription_profiles (owner, name, description, filter)
+		VALUES ($1, $2, NULLIF($3, ''), $4)
+		RETURNING created_at, updated_at
+	`, owner, p.Name, p.Description, p.Filter).Scan(&p.CreatedAt, &p.UpdatedAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return fmt.Errorf("subscription profile already exists")
+	}
+	return err
+}
+
+// UpdateSubscriptionProfile changes a profile's description and/or filter;
+// nil leaves a field unchanged.
+func (db *DB) UpdateSubscriptionProfile(ctx context.Context, owner, name string, description *string, filter json.RawMessage) (*SubscriptionProfile, error) {
+	var f any
+	if filter != nil {
+		f = filter
+	}
+	p, err := scanSubscriptionProfile(db.Pool.QueryRow(ctx, `
+		UPDATE subscription_profiles SET
+			description = CASE WHEN $3::text IS NULL THEN description ELSE NULLIF($3, '') END,
+			filter      = COALESCE($4::jsonb, filter),
+			updated_at  = now()
+		WHERE owner = $1 AND name = $2
+		RETURNING `+subscriptionProfileColumns,
+		owner, name, description, f))
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("subscription profile not found")
+	}
+	return p, err
+}
+
+// DeleteSubscriptionProfile removes one of owner's profiles.
+func (db *DB) DeleteSubscriptionProfile(ctx context.Context, owner, name string) error {
+	tag, err := db.Pool.Exec(ctx, `DELETE FROM subscription_profiles WHERE owner = $1 AND name = $2`, owner, name)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("subscription profile not found")
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/jackc/pgx/v5"
@@ -230,6 +231,19 @@ func (db *DB) MergeSystems(ctx context.Context, sourceID, targetID int, performe
 	}
 	eventsMoved = int(tag.RowsAffected())
 
+	// Move unit_sessions; both systems may have folded the same events
+	// (same network, two sites), so drop source sessions the target has.
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM unit_sessions s USING unit_sessions t
+		WHERE s.system_id = $2 AND t.system_id = $1
+		  AND t.unit_rid = s.unit_rid AND t.tgid = s.tgid AND t.start_time = s.start_time
+	`, targetID, sourceID); err != nil {
+		return 0, 0, 0, 0, 0, 0, fmt.Errorf("dedupe unit_sessions: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE unit_sessions SET system_id = $1 WHERE system_id = $2`, targetID, sourceID); err != nil {
+		return 0, 0, 0, 0, 0, 0, fmt.Errorf("move unit_sessions: %w", err)
+	}
+
 	// Move trunking_messages
 	if _, err := tx.Exec(ctx, `UPDATE trunking_messages SET system_id = $1 WHERE system_id = $2`, targetID, sourceID); err != nil {
 		return 0, 0, 0, 0, 0, 0, fmt.Errorf("move trunking_messages: %w", err)
@@ -240,6 +254,45 @@ func (db *DB) MergeSystems(ctx context.Context, sourceID, targetID int, performe
 		return 0, 0, 0, 0, 0, 0, fmt.Errorf("move decode_rates: %w", err)
 	}
 
+	// Move call_merges (their calls moved above)
+	if _, err := tx.Exec(ctx, `UPDATE call_merges SET system_id = $1 WHERE system_id = $2`, targetID, sourceID); err != nil {
+		return 0, 0, 0, 0, 0, 0, fmt.Errorf("move call_merges: %w", err)
+	}
+
+	// Fold unit_encryption_daily rows into the target (same unit/day/tgid sums)
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO unit_encryption_daily (system_id, unit_id, day, tgid,
+			encrypted_calls, encrypted_seconds, clear_calls, clear_seconds)
+		SELECT $1, unit_id, day, tgid, encrypted_calls, encrypted_seconds, clear_calls, clear_seconds
+		FROM unit_encryption_daily WHERE system_id = $2
+		ON CONFLICT (system_id, unit_id, day, tgid) DO UPDATE SET
+			encrypted_calls   = unit_encryption_daily.encrypted_calls + EXCLUDED.encrypted_calls,
+			encrypted_seconds = unit_encryption_daily.encrypted_seconds + EXCLUDED.encrypted_seconds,
+			clear_calls       = unit_encryption_daily.clear_calls + EXCLUDED.clear_calls,
+			clear_seconds     = unit_encryption_daily.clear_seconds + EXCLUDED.clear_seconds
+	`, targetID, sourceID); err != nil {
+		return 0, 0, 0, 0, 0, 0, fmt.Errorf("merge unit_encryption_daily: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM unit_encryption_daily WHERE system_id = $1`, sourceID); err != nil {
+		return 0, 0, 0, 0, 0, 0, fmt.Errorf("delete source unit_encryption_daily: %w", err)
+	}
+
+	// Fold unit_census_daily rows into the target (same unit/day sums)
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO unit_census_daily (system_id, unit_id, day, events, registrations, deregistrations)
+		SELECT $1, unit_id, day, events, registrations, deregistrations
+		FROM unit_census_daily WHERE system_id = $2
+		ON CONFLICT (system_id, unit_id, day) DO UPDATE SET
+			events          = unit_census_daily.events + EXCLUDED.events,
+			registrations   = unit_census_daily.registrations + EXCLUDED.registrations,
+			deregistrations = unit_census_daily.deregistrations + EXCLUDED.deregistrations
+	`, targetID, sourceID); err != nil {
+		return 0, 0, 0, 0, 0, 0, fmt.Errorf("merge unit_census_daily: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM unit_census_daily WHERE system_id = $1`, sourceID); err != nil {
+		return 0, 0, 0, 0, 0, 0, fmt.Errorf("delete source unit_census_daily: %w", err)
+	}
+
 	// Move sites to target system
 	if _, err := tx.Exec(ctx, `UPDATE sites SET system_id = $1 WHERE system_id = $2`, targetID, sourceID); err != nil {
 		return 0, 0, 0, 0, 0, 0, fmt.Errorf("move sites: %w", err)
@@ -283,6 +336,8 @@ type SystemAPI struct {
 	Name       string    `json:"name,omitempty"`
 	Sysid      string    `json:"sysid"`
 	Wacn       string    `json:"wacn"`
+	IngestPolicy string  `json:"ingest_policy"` // full, transcript or metadata
+	Display    Display   `json:"display"`
 	Sites      []SiteAPI `json:"sites"`
 }
 
@@ -299,6 +354,12 @@ func (db *DB) GetSystemByID(ctx context.Context, systemID int) (*SystemAPI, erro
 		Sysid:      row.Sysid,
 		Wacn:       row.Wacn,
 	}
+	if err := db.Pool.QueryRow(ctx, `SELECT ingest_policy FROM systems WHERE system_id = $1`, systemID).Scan(&s.IngestPolicy); err != nil {
+		return nil, err
+	}
+	if err := db.fillSystemDisplays(ctx, 1, func(int) (int, *Display) { return systemID, &s.Display }); err != nil {
+		return nil, err
+	}
 	sites, err := db.ListSitesForSystem(ctx, systemID)
 	if err != nil {
 		return nil, err
@@ -324,6 +385,16 @@ func (db *DB) ListSystemsWithSites(ctx context.Context) ([]SystemAPI, error) {
 			Wacn:       r.Wacn,
 		}
 	}
+	if err := db.fillIngestPolicies(ctx, len(systems), func(i int) (int, *string) {
+		return systems[i].SystemID, &systems[i].IngestPolicy
+	}); err != nil {
+		return nil, err
+	}
+	if err := db.fillSystemDisplays(ctx, len(systems), func(i int) (int, *Display) {
+		return systems[i].SystemID, &systems[i].Display
+	}); err != nil {
+		return nil, err
+	}
 
 	// Load sites for each system
 	allSites, err := db.LoadAllSitesAPI(ctx)
@@ -340,6 +411,7 @@ func (db *DB) ListSystemsWithSites(ctx context.Context) ([]SystemAPI, error) {
 			systems[i].Sites = []SiteAPI{}
 		}
 	}
+	slices.SortStableFunc(systems, func(a, b SystemAPI) int { return a.Display.DisplayOrder - b.Display.DisplayOrder })
 
 	return systems, nil
 }
@@ -350,6 +422,8 @@ type P25SystemAPI struct {
 	Name           string    `json:"name,omitempty"`
 	Sysid          string    `json:"sysid"`
 	Wacn           string    `json:"wacn"`
+	IngestPolicy   string    `json:"ingest_policy"`
+	Display        Display   `json:"display"`
 	Sites          []SiteAPI `json:"sites"`
 	TalkgroupCount int       `json:"talkgroup_count"`
 	UnitCount      int       `json:"unit_count"`
@@ -375,6 +449,16 @@ func (db *DB) ListP25Systems(ctx context.Context) ([]P25SystemAPI, error) {
 			Calls24h:       int(r.Calls24h),
 		}
 	}
+	if err := db.fillIngestPolicies(ctx, len(systems), func(i int) (int, *string) {
+		return systems[i].SystemID, &systems[i].IngestPolicy
+	}); err != nil {
+		return nil, err
+	}
+	if err := db.fillSystemDisplays(ctx, len(systems), func(i int) (int, *Display) {
+		return systems[i].SystemID, &systems[i].Display
+	}); err != nil {
+		return nil, err
+	}
 
 	// Load sites
 	allSites, err := db.LoadAllSitesAPI(ctx)
@@ -391,6 +475,7 @@ func (db *DB) ListP25Systems(ctx context.Context) ([]P25SystemAPI, error) {
 			systems[i].Sites = []SiteAPI{}
 		}
 	}
+	slices.SortStableFunc(systems, func(a, b P25SystemAPI) int { return a.Display.DisplayOrder - b.Display.DisplayOrder })
 
 	return systems, nil
 }
@@ -0,0 +1,182 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// RelatedTalkgroup is a talkgroup related to another by patches, shared
+// units or co-activity. Score is between 0 and 1; Shared is what it is
+// computed from (patched calls, units or active windows in common).
+type RelatedTalkgroup struct {
+	Tgid        int     `json:"tgid"`
+	AlphaTag    string  `json:"alpha_tag,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Tag         string  `json:"tag,omitempty"`
+	Group       string  `json:"group,omitempty"`
+	Shared      int     `json:"shared"`
+	Score       float64 `json:"score"`
+}
+
+// TalkgroupRelations cross-references a talkgroup with the others on its
+// system over a time range.
+type TalkgroupRelations struct {
+	SystemID      int       `json:"system_id"`
+	Tgid          int       `json:"tgid"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+	WindowMinutes int       `json:"window_minutes"`
+
+	Calls         int `json:"calls"`          // calls on the talkgroup or patched to it
+	Units         int `json:"units"`          // distinct units heard on the talkgroup
+	ActiveWindows int `json:"active_windows"` // windows with a call on the talkgroup
+
+	// Patches: share of Calls also patched with the other talkgroup.
+	Patches []RelatedTalkgroup `json:"patches"`
+	// SharedUnits: Jaccard similarity of the two talkgroups' unit sets.
+	SharedUnits []RelatedTalkgroup `json:"shared_units"`
+	// CoActive: Jaccard similarity of the two talkgroups' active windows.
+	CoActive []RelatedTalkgroup `json:"co_active"`
+}
+
+// TalkgroupRelationsQuery selects the talkgroup, time range, co-activity
+// window size and how many related talkgroups to return per list.
+type TalkgroupRelationsQuery struct {
+	SystemID      int
+	Tgid          int
+	Start         time.Time
+	End           time.Time
+	WindowMinutes int
+	Limit         int
+}
+
+// relatedRanked wraps a query defining the CTEs that end in
+// ranked(tgid, shared, score) with the talkgroup names and ordering. $1 is
+// the system ID and the limit is the last parameter.
+func relatedRanked(ctes string, limitParam int) string {
+	return ctes + `
+		SELECT r.tgid, r.shared, r.score,
+			COALESCE(t.alpha_tag, ''), COALESCE(t.description, ''), COALESCE(t.tag, ''), COALESCE(t."group", '')
+		FROM ranked r
+		LEFT JOIN talkgroups t ON t.system_id = $1 AND t.tgid = r.tgid
+		ORDER BY r.score DESC, r.shared DESC, r.tgid
+		LIMIT $` + strconv.Itoa(limitParam)
+}
+
+const relatedPatchesSQL = `
+	WITH c AS (
+		SELECT call_id, tgid, patched_tgids
+		FROM calls
+		WHERE system_id = $1 AND start_time >= $3 AND start_time < $4
+		  AND (tgid = $2 OR $2 = ANY(patched_tgids))
+	), pairs AS (
+		SELECT DISTINCT c.call_id, o AS tgid
+		FROM c, unnest(array_append(c.patched_tgids, c.tgid)) AS o
+		WHERE c.patched_tgids IS NOT NULL AND o <> $2
+	), ranked AS (
+		SELECT tgid, count(*)::int AS shared,
+			count(*)::float8 / GREATEST((SELECT count(*) FROM c), 1) AS score
+		FROM pairs
+		GROUP BY tgid
+	)`
+
+const relatedUnitsSQL = `
+	WITH u AS (
+		SELECT DISTINCT c.tgid, uid
+		FROM calls c, unnest(c.unit_ids) AS uid
+		WHERE c.system_id = $1 AND c.start_time >= $3 AND c.start_time < $4
+	), mine AS (
+		SELECT uid FROM u WHERE tgid = $2
+	), totals AS (
+		SELECT tgid, count(*) AS n FROM u GROUP BY tgid
+	), ranked AS (
+		SELECT u.tgid, count(*)::int AS shared,
+			count(*)::float8 / ((SELECT count(*) FROM mine) + tot.n - count(*)) AS score
+		FROM u
+		JOIN mine USING (uid)
+		JOIN totals tot USING (tgid)
+		WHERE u.tgid <> $2
+		GROUP BY u.tgid, tot.n
+	)`
+
+const relatedCoActiveSQL = `
+	WITH b AS (
+		SELECT DISTINCT tgid, date_bin($5::interval, start_time, $3::timestamptz) AS bucket
+		FROM calls
+		WHERE system_id = $1 AND start_time >= $3 AND start_time < $4
+	), mine AS (
+		SELECT bucket FROM b WHERE tgid = $2
+	), totals AS (
+		SELECT tgid, count(*) AS n FROM b GROUP BY tgid
+	), ranked AS (
+		SELECT b.tgid, count(*)::int AS shared,
+			count(*)::float8 / ((SELECT count(*) FROM mine) + tot.n - count(*)) AS score
+		FROM b
+		JOIN mine USING (bucket)
+		JOIN totals tot USING (tgid)
+		WHERE b.tgid <> $2
+		GROUP BY b.tgid, tot.n
+	)`
+
+// GetTalkgroupRelations finds the talkgroups most often patched with a
+// talkgroup, sharing the most of its units, and most often active in the
+// same time windows, each scored and ranked.
+func (db *DB) GetTalkgroupRelations(ctx context.Context, q TalkgroupRelationsQuery) (*TalkgroupRelations, error) {
+	window := strconv.Itoa(q.WindowMinutes) + " minutes"
+	rel := &TalkgroupRelations{
+		SystemID:      q.SystemID,
+		Tgid:          q.Tgid,
+		StartTime:     q.Start,
+		EndTime:       q.End,
+		WindowMinutes: q.WindowMinutes,
+	}
+
+	if err := db.Pool.QueryRow(ctx, `
+		SELECT
+			(SELECT count(*) FROM calls
+			 WHERE system_id = $1 AND start_time >= $3 AND start_time < $4
+			   AND (tgid = $2 OR $2 = ANY(patched_tgids))),
+			(SELECT count(DISTINCT uid) FROM calls c, unnest(c.unit_ids) AS uid
+			 WHERE c.system_id = $1 AND c.tgid = $2 AND c.start_time >= $3 AND c.start_time < $4),
+			(SELECT count(DISTINCT date_bin($5::interval, start_time, $3::timestamptz)) FROM calls
+			 WHERE system_id = $1 AND tgid = $2 AND start_time >= $3 AND start_time < $4)
+	`, q.SystemID, q.Tgid, q.Start, q.End, window).Scan(&rel.Calls, &rel.Units, &rel.ActiveWindows); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if rel.Patches, err = db.queryRelated(ctx, relatedRanked(relatedPatchesSQL, 5),
+		q.SystemID, q.Tgid, q.Start, q.End, q.Limit); err != nil {
+		return nil, fmt.Errorf("patches: %w", err)
+	}
+	if rel.SharedUnits, err = db.queryRelated(ctx, relatedRanked(relatedUnitsSQL, 5),
+		q.SystemID, q.Tgid, q.Start, q.End, q.Limit); err != nil {
+		return nil, fmt.Errorf("shared units: %w", err)
+	}
+	if rel.CoActive, err = db.queryRelated(ctx, relatedRanked(relatedCoActiveSQL, 6),
+		q.SystemID, q.Tgid, q.Start, q.End, window, q.Limit); err != nil {
+		return nil, fmt.Errorf("co-activity: %w", err)
+	}
+	return rel, nil
+}
+
+func (db *DB) queryRelated(ctx context.Context, query string, args ...any) ([]RelatedTalkgroup, error) {
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	related := []RelatedTalkgroup{}
+	for rows.Next() {
+		var r RelatedTalkgroup
+		if err := rows.Scan(&r.Tgid, &r.Shared, &r.Score,
+			&r.AlphaTag, &r.Description, &r.Tag, &r.Group); err != nil {
+			return nil, err
+		}
+		related = append(related, r)
+	}
+	return related, rows.Err()
+}
@@ -243,6 +243,7 @@ func (db *DB) EnrichTalkgroupsFromDirectory(ctx context.Context, systemID, tgid
 
 // ListTalkgroups returns talkgroups with cached stats.
 func (db *DB) ListTalkgroups(ctx context.Context, filter TalkgroupFilter) ([]TalkgroupAPI, int, error) {
+	start := time.Now()
 	const whereClause = `
 		WHERE ($1::int[] IS NULL OR t.system_id = ANY($1))
 		  AND ($2::text[] IS NULL OR s.sysid = ANY($2))
@@ -275,7 +276,8 @@ func (db *DB) ListTalkgroups(ctx context.Context, filter TalkgroupFilter) ([]Tal
 		LIMIT $5 OFFSET $6
 	`, whereClause, orderBy)
 
-	rows, err := db.Pool.Query(ctx, dataQuery, append(args, filter.Limit, filter.Offset)...)
+	dataArgs := append(args, filter.Limit, filter.Offset)
+	rows, err := db.Pool.Query(ctx, dataQuery, dataArgs...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -310,7 +312,11 @@ func (db *DB) ListTalkgroups(ctx context.Context, filter TalkgroupFilter) ([]Tal
 	if talkgroups == nil {
 		talkgroups = []TalkgroupAPI{}
 	}
-	return talkgroups, total, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	db.observeQuery("list_talkgroups", start, dataQuery, dataArgs)
+	return talkgroups, total, nil
 }
 
 // ListTalkgroupUnits returns units affiliated with a talkgroup within a time window.
@@ -533,8 +539,16 @@ func (db *DB) RefreshTalkgroupStatsCold(ctx context.Context) (int64, error) {
 		) cs
 		FULL JOIN (
 			SELECT system_id, tgid, count(DISTINCT unit_rid)::int AS unit_count
-			FROM unit_events
-			WHERE tgid IS NOT NULL AND time > now() - interval '30 days'
+			FROM (
+				SELECT system_id, tgid, unit_rid
+				FROM unit_events
+				WHERE tgid IS NOT NULL AND time > now() - interval '30 days'
+				UNION ALL
+				-- sessions cover units whose raw events were already purged
+				SELECT system_id, tgid, unit_rid
+				FROM unit_sessions
+				WHERE end_time > now() - interval '30 days'
+			) ue
 			GROUP BY system_id, tgid
 		) us USING (system_id, tgid)
 		WHERE t.system_id = COALESCE(cs.system_id, us.system_id)
@@ -718,3 +732,68 @@ func (db *DB) ImportUpsertTalkgroup(ctx context.Context, systemID, tgid int,
 	`, systemID, tgid, alphaTag, tag, group, description, modeVal, prioVal, firstSeen, lastSeen)
 	return err
 }
+
+// SyncUpsertTalkgroup upserts a talkgroup's curated metadata from another
+// instance's directory snapshot. Unlike ImportUpsertTalkgroup, non-empty
+// tag/group/description/mode/priority values replace local ones. alpha_tag
+// still respects source priority: manual > csv > mqtt > directory.
+func (db *DB) SyncUpsertTalkgroup(ctx context.Context, systemID, tgid int,
+	alphaTag, alphaTagSource, tag, group, description, mode string, priority *int) error {
+
+	var prioVal *int32
+	if priority != nil {
+		v := int32(*priority)
+		prioVal = &v
+	}
+
+	_, err := db.Pool.Exec(ctx, `
+		INSERT INTO talkgroups (system_id, tgid, alpha_tag, alpha_tag_source, tag, "group", description, mode, priority)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9)
+		ON CONFLICT (system_id, tgid) DO UPDATE SET
+			alpha_tag = CASE
+				WHEN $4 = 'manual' THEN $3
+				WHEN $4 = 'csv' AND COALESCE(talkgroups.alpha_tag_source, '') NOT IN ('manual') THEN $3
+				WHEN $4 = 'mqtt' AND COALESCE(talkgroups.alpha_tag_source, '') NOT IN ('manual', 'csv') THEN $3
+				WHEN $4 = 'directory' AND COALESCE(talkgroups.alpha_tag_source, '') NOT IN ('manual', 'csv', 'mqtt') THEN $3
+				ELSE talkgroups.alpha_tag
+			END,
+			alpha_tag_source = CASE
+				WHEN $4 = 'manual' THEN $4
+				WHEN $4 = 'csv' AND COALESCE(talkgroups.alpha_tag_source, '') NOT IN ('manual') THEN $4
+				WHEN $4 = 'mqtt' AND COALESCE(talkgroups.alpha_tag_source, '') NOT IN ('manual', 'csv') THEN $4
+				WHEN $4 = 'directory' AND COALESCE(talkgroups.alpha_tag_source, '') NOT IN ('manual', 'csv', 'mqtt') THEN $4
+				ELSE talkgroups.alpha_tag_source
+			END,
+			tag         = COALESCE(NULLIF($5, ''), talkgroups.tag),
+			"group"     = COALESCE(NULLIF($6, ''), talkgroups."group"),
+			description = COALESCE(NULLIF($7, ''), talkgroups.description),
+			mode        = COALESCE($8, talkgroups.mode),
+			priority    = COALESCE($9, talkgroups.priority)
+	`, systemID, tgid, alphaTag, alphaTagSource, tag, group, description, pqString(mode), prioVal)
+	return err
+}
+
+// TalkgroupsInGroups returns the talkgroups whose group is one of groups
+// (case-insensitive), on systemID or on every system when it is 0, as
+// system_id → tgids.
+func (db *DB) TalkgroupsInGroups(ctx context.Context, systemID int, groups []string) (map[int][]int, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT system_id, tgid
+		FROM talkgroups
+		WHERE lower("group") = ANY(SELECT lower(g) FROM unnest($1::text[]) g)
+			AND ($2 = 0 OR system_id = $2)
+	`, groups, systemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make(map[int][]int)
+	for rows.Next() {
+		var sys, tgid int
+		if err := rows.Scan(&sys, &tgid); err != nil {
+			return nil, err
+		}
+		out[sys] = append(out[sys], tgid)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TimeseriesSample is one metric value for a minute.
+type TimeseriesSample struct {
+	Metric string
+	Label  string
+	Value  float64
+}
+
+// TimeseriesPoint is one bucket of a queried series.
+type TimeseriesPoint struct {
+	Time time.Time `json:"t"`
+	Avg  float64   `json:"avg"`
+	Max  float64   `json:"max"`
+}
+
+// TimeseriesSeries is a queried metric/label series.
+type TimeseriesSeries struct {
+	Metric string            `json:"metric"`
+	Label  string            `json:"label,omitempty"`
+	Points []TimeseriesPoint `json:"points"`
+}
+
+// TimeseriesMetric describes a stored series.
+type TimeseriesMetric struct {
+	Metric string    `json:"metric"`
+	Label  string    `json:"label,omitempty"`
+	First  time.Time `json:"first"`
+	Last   time.Time `json:"last"`
+}
+
+// TimeseriesQuery selects series and buckets them by Step.
+type TimeseriesQuery struct {
+	Metrics []string // required
+	Labels  []string // empty = all labels
+	Start   time.Time
+	End     time.Time
+	Step    time.Duration // bucket width, at least one minute
+}
+
+// InsertTimeseries stores samples for the minute ts. Re-inserting a minute
+// replaces its values.
+func (db *DB) InsertTimeseries(ctx context.Context, ts time.Time, samples []TimeseriesSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	batch := &pgx.Batch{}
+	for _, s := range samples {
+		batch.Queue(`
+			INSERT INTO system_timeseries (ts, metric, label, value) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (metric, label, ts) DO UPDATE SET value = EXCLUDED.value
+		`, ts, s.Metric, s.Label, s.Value)
+	}
+	return db.Pool.SendBatch(ctx, batch).Close()
+}
+
+// QueryTimeseries returns the selected series averaged (and maxed) into
+// Step-wide buckets, ordered by metric, label and time. Buckets with no
+// samples are omitted.
+func (db *DB) QueryTimeseries(ctx context.Context, q TimeseriesQuery) ([]TimeseriesSeries, error) {
+	step := q.Step
+	if step < time.Minute {
+		step = time.Minute
+	}
+	rows, err := db.Pool.Query(ctx, `
+		SELECT metric, label,
+			date_bin($5::interval, ts, '2000-01-01 00:00:00+00') AS bucket,
+			avg(value), max(value)
+		FROM system_timeseries
+		WHERE metric = ANY($1)
+		  AND (cardinality($2::text[]) = 0 OR label = ANY($2))
+		  AND ts >= $3 AND ts < $4
+		GROUP BY metric, label, bucket
+		ORDER BY metric, label, bucket
+	`, q.Metrics, q.Labels, q.Start, q.End, step)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	series := []TimeseriesSeries{}
+	for rows.Next() {
+		var metric, label string
+		var p TimeseriesPoint
+		if err := rows.Scan(&metric, &label, &p.Time, &p.Avg, &p.Max); err != nil {
+			return nil, err
+		}
+		if n := len(series); n == 0 || series[n-1].Metric != metric || series[n-1].Label != label {
+			series = append(series, TimeseriesSeries{Metric: metric, Label: label})
+		}
+		cur := &series[len(series)-1]
+		cur.Points = append(cur.Points, p)
+	}
+	return series, rows.Err()
+}
+
+// ListTimeseriesMetrics returns every stored series with its time span.
+func (db *DB) ListTimeseriesMetrics(ctx context.Context) ([]TimeseriesMetric, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT metric, label, min(ts), max(ts)
+		FROM system_timeseries
+		GROUP BY metric, label
+		ORDER BY metric, label
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	metrics := []TimeseriesMetric{}
+	for rows.Next() {
+		var m TimeseriesMetric
+		if err := rows.Scan(&m.Metric, &m.Label, &m.First, &m.Last); err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, rows.Err()
+}
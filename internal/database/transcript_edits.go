@@ -0,0 +1,227 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrTranscriptChanged means the transcription an edit was made against is no
+// longer the call's primary.
+var ErrTranscriptChanged = errors.New("transcript changed")
+
+// TranscriptEdit is a human edit of a call's primary transcription.
+type TranscriptEdit struct {
+	Row      TranscriptionRow // the new version (Source "human", IsPrimary)
+	ParentID int              // primary transcription the edit was made against
+	Diff     json.RawMessage  // word-level diff against ParentID
+	Eval     *STTEvalPair     // non-nil to add the edit to the STT evaluation set
+}
+
+// STTEvalPair is a human transcript paired with the STT output it corrects.
+type STTEvalPair struct {
+	ID              int64     `json:"id"`
+	TranscriptionID int       `json:"transcription_id"` // human reference
+	HypothesisID    int       `json:"hypothesis_id"`    // STT output
+	CallID          int64     `json:"call_id"`
+	CallStartTime   time.Time `json:"call_start_time"`
+	Provider        string    `json:"provider,omitempty"`
+	Model           string    `json:"model,omitempty"`
+	Reference       string    `json:"reference"`
+	Hypothesis      string    `json:"hypothesis"`
+	ReferenceWords  int       `json:"reference_words"`
+	WordErrors      int       `json:"word_errors"`
+	WER             float64   `json:"wer"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// STTEvalFilter selects pairs for ListSTTEvalPairs and STTEvalSummaries.
+type STTEvalFilter struct {
+	Provider  string
+	Model     string
+	StartTime *time.Time // created_at >= StartTime
+	EndTime   *time.Time // created_at < EndTime
+	Limit     int
+	Offset    int
+
+	IncludeRestricted bool // include pairs of calls hidden by a restricted encryption policy or embargo (admins)
+}
+
+// STTEvalSummary is the word error rate of one provider/model over its pairs.
+type STTEvalSummary struct {
+	Provider       string  `json:"provider"`
+	Model          string  `json:"model"`
+	Pairs          int     `json:"pairs"`
+	ReferenceWords int64   `json:"reference_words"`
+	WordErrors     int64   `json:"word_errors"`
+	WER            float64 `json:"wer"` // WordErrors / ReferenceWords
+}
+
+// InsertTranscriptEdit stores a human edit as the call's new primary
+// transcription, with its parent and diff, and optionally its evaluation
+// pair. Fails with ErrTranscriptChanged if ParentID is no longer the primary.
+func (db *DB) InsertTranscriptEdit(ctx context.Context, e *TranscriptEdit) (int, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var primaryID int
+	err = tx.QueryRow(ctx, `
+		SELECT id FROM transcriptions
+		WHERE call_id = $1 AND call_start_time = $2 AND is_primary
+		FOR UPDATE
+	`, e.Row.CallID, e.Row.CallStartTime).Scan(&primaryID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("lock primary: %w", err)
+	}
+	if primaryID != e.ParentID {
+		return 0, ErrTranscriptChanged
+	}
+
+	id, err := db.insertTranscriptionTx(ctx, tx, &e.Row)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE transcriptions SET parent_id = $2, diff = $3 WHERE id = $1`,
+		id, e.ParentID, e.Diff); err != nil {
+		return 0, fmt.Errorf("set diff: %w", err)
+	}
+
+	if p := e.Eval; p != nil {
+		p.TranscriptionID = id
+		if err := tx.QueryRow(ctx, `
+			INSERT INTO stt_eval_pairs (transcription_id, hypothesis_id, call_id, call_start_time,
+				provider, model, reference, hypothesis, reference_words, word_errors)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8, $9, $10)
+			RETURNING id, created_at
+		`, id, p.HypothesisID, p.CallID, p.CallStartTime, p.Provider, p.Model,
+			p.Reference, p.Hypothesis, p.ReferenceWords, p.WordErrors).Scan(&p.ID, &p.CreatedAt); err != nil {
+			return 0, fmt.Errorf("insert eval pair: %w", err)
+		}
+		p.WER = wordErrorRate(int64(p.WordErrors), int64(p.ReferenceWords))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit tx: %w", err)
+	}
+	return id, nil
+}
+
+// loadTranscriptionEdits fills in ParentID and Diff for transcriptions read
+// through the generated queries.
+func (db *DB) loadTranscriptionEdits(ctx context.Context, ts []*TranscriptionAPI) error {
+	if len(ts) == 0 {
+		return nil
+	}
+	byID := make(map[int]*TranscriptionAPI, len(ts))
+	ids := make([]int, len(ts))
+	for i, t := range ts {
+		byID[t.ID] = t
+		ids[i] = t.ID
+	}
+	rows, err := db.Pool.Query(ctx, `
+		SELECT id, parent_id, diff FROM transcriptions
+		WHERE id = ANY($1) AND (parent_id IS NOT NULL OR diff IS NOT NULL)
+	`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var parentID *int
+		var diff []byte
+		if err := rows.Scan(&id, &parentID, &diff); err != nil {
+			return err
+		}
+		byID[id].ParentID = parentID
+		if diff != nil {
+			byID[id].Diff = diff
+		}
+	}
+	return rows.Err()
+}
+
+// sttEvalFrom selects pairs with their calls, so restricted calls' pairs
+// can be left out.
+const sttEvalFrom = `
+	FROM stt_eval_pairs p
+	JOIN calls c ON c.call_id = p.call_id AND c.start_time = p.call_start_time`
+
+var sttEvalWhere = `
+	WHERE ($1 = '' OR p.provider = $1)
+	  AND ($2 = '' OR p.model = $2)
+	  AND ($3::timestamptz IS NULL OR p.created_at >= $3)
+	  AND ($4::timestamptz IS NULL OR p.created_at < $4)
+	  AND ($5::boolean OR NOT ` + restrictedCallSQL + `)`
+
+// ListSTTEvalPairs returns evaluation pairs matching the filter, newest
+// first, with the total count. Pairs of restricted calls are left out unless
+// f.IncludeRestricted.
+func (db *DB) ListSTTEvalPairs(ctx context.Context, f STTEvalFilter) ([]STTEvalPair, int, error) {
+	args := []any{f.Provider, f.Model, f.StartTime, f.EndTime, f.IncludeRestricted}
+
+	var total int
+	if err := db.Pool.QueryRow(ctx, `SELECT count(*)`+sttEvalFrom+sttEvalWhere, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := db.Pool.Query(ctx, `
+		SELECT p.id, p.transcription_id, p.hypothesis_id, p.call_id, p.call_start_time,
+			COALESCE(p.provider, ''), COALESCE(p.model, ''), p.reference, p.hypothesis,
+			p.reference_words, p.word_errors, p.created_at`+sttEvalFrom+sttEvalWhere+`
+		ORDER BY p.id DESC
+		LIMIT $6 OFFSET $7`, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	pairs := []STTEvalPair{}
+	for rows.Next() {
+		var p STTEvalPair
+		if err := rows.Scan(&p.ID, &p.TranscriptionID, &p.HypothesisID, &p.CallID, &p.CallStartTime,
+			&p.Provider, &p.Model, &p.Reference, &p.Hypothesis,
+			&p.ReferenceWords, &p.WordErrors, &p.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		p.WER = wordErrorRate(int64(p.WordErrors), int64(p.ReferenceWords))
+		pairs = append(pairs, p)
+	}
+	return pairs, total, rows.Err()
+}
+
+// STTEvalSummaries returns the word error rate per provider and model over
+// the pairs matching the filter (Limit and Offset are ignored).
+func (db *DB) STTEvalSummaries(ctx context.Context, f STTEvalFilter) ([]STTEvalSummary, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT COALESCE(p.provider, ''), COALESCE(p.model, ''), count(*),
+			sum(p.reference_words)::bigint, sum(p.word_errors)::bigint`+sttEvalFrom+sttEvalWhere+`
+		GROUP BY 1, 2
+		ORDER BY 3 DESC, 1, 2`, f.Provider, f.Model, f.StartTime, f.EndTime, f.IncludeRestricted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	summaries := []STTEvalSummary{}
+	for rows.Next() {
+		var s STTEvalSummary
+		if err := rows.Scan(&s.Provider, &s.Model, &s.Pairs, &s.ReferenceWords, &s.WordErrors); err != nil {
+			return nil, err
+		}
+		s.WER = wordErrorRate(s.WordErrors, s.ReferenceWords)
+		summaries = append(summaries, s)
+	}
+	return summaries, rows.Err()
+}
+
+func wordErrorRate(wordErrors, referenceWords int64) float64 {
+	if referenceWords == 0 {
+		return 0
+	}
+	return float64(wordErrors) / float64(referenceWords)
+}
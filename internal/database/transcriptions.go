@@ -4,8 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
+	"unicode"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/snarg/tr-engine/internal/database/sqlcdb"
 )
@@ -25,24 +28,36 @@ type TranscriptionRow struct {
 	DurationMs    int
 	ProviderMs    *int
 	Words         json.RawMessage // word-level timestamps with unit attribution
+	Urgency       *TranscriptUrgency
+}
+
+// TranscriptUrgency is the post-transcription urgency classification of a call.
+type TranscriptUrgency struct {
+	Score      float32  `json:"score"`                // 0..1
+	Label      string   `json:"label"`                // "routine", "urgent", "emergency_language"
+	Signals    []string `json:"signals,omitempty"`    // matched phrases / cues that drove the score
+	Classifier string   `json:"classifier,omitempty"` // "keyword" or "model"
 }
 
 // TranscriptionAPI is the transcription representation for API responses.
 type TranscriptionAPI struct {
-	ID         int             `json:"id"`
-	CallID     int64           `json:"call_id"`
-	Text       string          `json:"text"`
-	Source     string          `json:"source"`
-	IsPrimary  bool            `json:"is_primary"`
-	Confidence *float32        `json:"confidence,omitempty"`
-	Language   string          `json:"language,omitempty"`
-	Model      string          `json:"model,omitempty"`
-	Provider   string          `json:"provider,omitempty"`
-	WordCount  int             `json:"word_count"`
-	DurationMs int             `json:"duration_ms"`
-	ProviderMs *int            `json:"provider_ms,omitempty"`
-	Words      json.RawMessage `json:"words,omitempty"`
-	CreatedAt  time.Time       `json:"created_at"`
+	ID         int                `json:"id"`
+	CallID     int64              `json:"call_id"`
+	Text       string             `json:"text"`
+	Source     string             `json:"source"`
+	IsPrimary  bool               `json:"is_primary"`
+	Confidence *float32           `json:"confidence,omitempty"`
+	Language   string             `json:"language,omitempty"`
+	Model      string             `json:"model,omitempty"`
+	Provider   string             `json:"provider,omitempty"`
+	WordCount  int                `json:"word_count"`
+	DurationMs int                `json:"duration_ms"`
+	ProviderMs *int               `json:"provider_ms,omitempty"`
+	Words      json.RawMessage    `json:"words,omitempty"`
+	Urgency    *TranscriptUrgency `json:"urgency,omitempty"`
+	ParentID   *int               `json:"parent_id,omitempty"` // version a human edit was made against
+	Diff       json.RawMessage    `json:"diff,omitempty"`      // word-level diff against ParentID
+	CreatedAt  time.Time          `json:"created_at"`
 }
 
 // CallTranscriptionInfo is a lightweight view of a call for the transcription worker.
@@ -73,6 +88,15 @@ type TranscriptionSearchFilter struct {
 	PrimaryOnly *bool // default true; set to false to include all variants
 	Limit     int
 	Offset    int
+
+	UrgencyLabels   []string // nil = any label, including unclassified
+	MinUrgencyScore *float32
+
+	// MatchAny matches transcriptions containing any word of the query rather
+	// than all of them (used to retrieve context for natural-language questions).
+	MatchAny bool
+
+	IncludeRestricted bool // include calls hidden by a restricted encryption policy or embargo (admins)
 }
 
 // TranscriptionSearchHit is a search result with relevance score and call context.
@@ -173,6 +197,18 @@ func (db *DB) InsertTranscription(ctx context.Context, row *TranscriptionRow) (i
 	}
 	defer tx.Rollback(ctx)
 
+	id, err := db.insertTranscriptionTx(ctx, tx, row)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit tx: %w", err)
+	}
+	return id, nil
+}
+
+// insertTranscriptionTx does the work of InsertTranscription inside tx.
+func (db *DB) insertTranscriptionTx(ctx context.Context, tx pgx.Tx, row *TranscriptionRow) (int, error) {
 	qtx := db.Q.WithTx(tx)
 
 	if row.IsPrimary {
@@ -210,6 +246,16 @@ func (db *DB) InsertTranscription(ctx context.Context, row *TranscriptionRow) (i
 		return 0, fmt.Errorf("insert transcription: %w", err)
 	}
 
+	if u := row.Urgency; u != nil {
+		if _, err := tx.Exec(ctx, `
+			UPDATE transcriptions SET
+				urgency_score = $2, urgency_label = $3, urgency_signals = $4, urgency_classifier = NULLIF($5, '')
+			WHERE id = $1
+		`, id, u.Score, u.Label, u.Signals, u.Classifier); err != nil {
+			return 0, fmt.Errorf("set urgency: %w", err)
+		}
+	}
+
 	if row.IsPrimary {
 		status := row.Source
 		if status == "human" {
@@ -234,10 +280,6 @@ func (db *DB) InsertTranscription(ctx context.Context, row *TranscriptionRow) (i
 			return 0, fmt.Errorf("update call_groups denorm: %w", err)
 		}
 	}
-
-	if err := tx.Commit(ctx); err != nil {
-		return 0, fmt.Errorf("commit tx: %w", err)
-	}
 	return id, nil
 }
 
@@ -248,6 +290,12 @@ func (db *DB) GetPrimaryTranscription(ctx context.Context, callID int64) (*Trans
 		return nil, err
 	}
 	t := primaryTranscriptionToAPI(row)
+	if err := db.loadTranscriptionUrgency(ctx, []*TranscriptionAPI{&t}); err != nil {
+		return nil, err
+	}
+	if err := db.loadTranscriptionEdits(ctx, []*TranscriptionAPI{&t}); err != nil {
+		return nil, err
+	}
 	return &t, nil
 }
 
@@ -258,28 +306,79 @@ func (db *DB) ListTranscriptionsByCall(ctx context.Context, callID int64) ([]Tra
 		return nil, err
 	}
 	result := make([]TranscriptionAPI, len(rows))
+	ptrs := make([]*TranscriptionAPI, len(rows))
 	for i, r := range rows {
 		result[i] = listTranscriptionToAPI(r)
+		ptrs[i] = &result[i]
+	}
+	if err := db.loadTranscriptionUrgency(ctx, ptrs); err != nil {
+		return nil, err
+	}
+	if err := db.loadTranscriptionEdits(ctx, ptrs); err != nil {
+		return nil, err
 	}
 	return result, nil
 }
 
+// loadTranscriptionUrgency fills in Urgency for transcriptions read through
+// the generated queries, which predate the urgency columns.
+func (db *DB) loadTranscriptionUrgency(ctx context.Context, ts []*TranscriptionAPI) error {
+	if len(ts) == 0 {
+		return nil
+	}
+	byID := make(map[int]*TranscriptionAPI, len(ts))
+	ids := make([]int, len(ts))
+	for i, t := range ts {
+		byID[t.ID] = t
+		ids[i] = t.ID
+	}
+	rows, err := db.Pool.Query(ctx, `
+		SELECT id, urgency_score, urgency_label, COALESCE(urgency_signals, '{}'), COALESCE(urgency_classifier, '')
+		FROM transcriptions
+		WHERE id = ANY($1) AND urgency_label IS NOT NULL
+	`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var u TranscriptUrgency
+		if err := rows.Scan(&id, &u.Score, &u.Label, &u.Signals, &u.Classifier); err != nil {
+			return err
+		}
+		byID[id].Urgency = &u
+	}
+	return rows.Err()
+}
+
 // SearchTranscriptions performs full-text search across transcriptions with call context.
 // Defaults to primary transcriptions only; pass primary_only=false to include all variants.
+// An empty query matches everything (used with urgency filters for triage); results
+// are then ordered newest first.
 func (db *DB) SearchTranscriptions(ctx context.Context, query string, filter TranscriptionSearchFilter) ([]TranscriptionSearchHit, int, error) {
 	primaryOnly := filter.PrimaryOnly == nil || *filter.PrimaryOnly
+	tsquery := "plainto_tsquery('english', $1)"
+	if filter.MatchAny {
+		query = anyWordTSQuery(query)
+		tsquery = "to_tsquery('english', $1)"
+	}
 
 	const fromClause = `FROM transcriptions t JOIN calls c ON c.call_id = t.call_id AND c.start_time = t.call_start_time`
-	const whereClause = `
-		WHERE t.search_vector @@ plainto_tsquery('english', $1)
+	whereClause := `
+		WHERE ($1 = '' OR t.search_vector @@ ` + tsquery + `)
 		  AND ($2::boolean IS NOT TRUE OR t.is_primary = true)
 		  AND ($3::timestamptz IS NULL OR t.call_start_time >= $3)
 		  AND ($4::timestamptz IS NULL OR t.call_start_time < $4)
 		  AND ($5::int[] IS NULL OR c.system_id = ANY($5))
 		  AND ($6::int[] IS NULL OR c.site_id = ANY($6))
-		  AND ($7::int[] IS NULL OR c.tgid = ANY($7))`
+		  AND ($7::int[] IS NULL OR c.tgid = ANY($7))
+		  AND ($8::text[] IS NULL OR t.urgency_label = ANY($8))
+		  AND ($9::real IS NULL OR t.urgency_score >= $9)
+		  AND ($10::boolean OR NOT ` + restrictedCallSQL + `)`
 	args := []any{query, primaryOnly, filter.StartTime, filter.EndTime,
-		pqIntArray(filter.SystemIDs), pqIntArray(filter.SiteIDs), pqIntArray(filter.Tgids)}
+		pqIntArray(filter.SystemIDs), pqIntArray(filter.SiteIDs), pqIntArray(filter.Tgids),
+		pqStringArray(filter.UrgencyLabels), filter.MinUrgencyScore, filter.IncludeRestricted}
 
 	// Count
 	var total int
@@ -297,12 +396,13 @@ func (db *DB) SearchTranscriptions(ctx context.Context, query string, filter Tra
 		SELECT t.id, t.call_id, t.text, t.source, t.is_primary,
 			t.confidence, t.language, t.model, t.provider,
 			t.word_count, t.duration_ms, t.provider_ms, t.words, t.created_at,
-			ts_rank(t.search_vector, plainto_tsquery('english', $1)) AS rank,
+			t.urgency_score, t.urgency_label, COALESCE(t.urgency_signals, '{}'), COALESCE(t.urgency_classifier, ''),
+			ts_rank(t.search_vector, `+tsquery+`) AS rank,
 			c.system_id, COALESCE(c.system_name, ''), c.tgid,
 			COALESCE(c.tg_alpha_tag, ''), c.start_time, c.duration
 		` + fromClause + whereClause + `
-		ORDER BY rank DESC
-		LIMIT $8 OFFSET $9`
+		ORDER BY rank DESC, t.call_start_time DESC
+		LIMIT $11 OFFSET $12`
 
 	rows, err := db.Pool.Query(ctx, dataQuery, append(args, limit, filter.Offset)...)
 	if err != nil {
@@ -313,16 +413,27 @@ func (db *DB) SearchTranscriptions(ctx context.Context, query string, filter Tra
 	var hits []TranscriptionSearchHit
 	for rows.Next() {
 		var h TranscriptionSearchHit
+		var u TranscriptUrgency
+		var urgencyScore *float32
+		var urgencyLabel *string
 		if err := rows.Scan(
 			&h.ID, &h.CallID, &h.Text, &h.Source, &h.IsPrimary,
 			&h.Confidence, &h.Language, &h.Model, &h.Provider,
 			&h.WordCount, &h.DurationMs, &h.ProviderMs, &h.Words, &h.CreatedAt,
+			&urgencyScore, &urgencyLabel, &u.Signals, &u.Classifier,
 			&h.Rank,
 			&h.CallSystemID, &h.CallSystemName, &h.CallTgid,
 			&h.CallTgAlphaTag, &h.CallStartTime, &h.CallDuration,
 		); err != nil {
 			return nil, 0, err
 		}
+		if urgencyLabel != nil {
+			u.Label = *urgencyLabel
+			if urgencyScore != nil {
+				u.Score = *urgencyScore
+			}
+			h.Urgency = &u
+		}
 		hits = append(hits, h)
 	}
 	if hits == nil {
@@ -331,6 +442,16 @@ func (db *DB) SearchTranscriptions(ctx context.Context, query string, filter Tra
 	return hits, total, rows.Err()
 }
 
+// anyWordTSQuery turns free text into a to_tsquery expression that matches
+// any of its words ("engine 5 main st" → "engine | 5 | main | st"). Words are
+// reduced to letters and digits so the result is always valid syntax.
+func anyWordTSQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " | ")
+}
+
 // BatchTranscriptionRow is a lightweight transcription for batch fetches.
 type BatchTranscriptionRow struct {
 	CallID   int64           `json:"call_id"`
@@ -340,17 +461,20 @@ type BatchTranscriptionRow struct {
 
 // GetBatchTranscriptions returns primary transcriptions for multiple call IDs.
 // Only returns call_id, text, and words->'segments' — the minimal shape needed by frontends.
-func (db *DB) GetBatchTranscriptions(ctx context.Context, callIDs []int64) ([]BatchTranscriptionRow, error) {
+// Calls hidden from non-admins are skipped unless includeRestricted.
+func (db *DB) GetBatchTranscriptions(ctx context.Context, callIDs []int64, includeRestricted bool) ([]BatchTranscriptionRow, error) {
 	if len(callIDs) == 0 {
 		return []BatchTranscriptionRow{}, nil
 	}
 
 	query := `
-		SELECT call_id, COALESCE(text, '') AS text, words->'segments' AS segments
-		FROM transcriptions
-		WHERE call_id = ANY($1) AND is_primary = true`
+		SELECT t.call_id, COALESCE(t.text, '') AS text, t.words->'segments' AS segments
+		FROM transcriptions t
+		WHERE t.call_id = ANY($1) AND t.is_primary = true
+		  AND ($2 OR NOT EXISTS (SELECT 1 FROM calls c
+			WHERE c.call_id = t.call_id AND c.start_time = t.call_start_time AND ` + restrictedCallSQL + `))`
 
-	rows, err := db.Pool.Query(ctx, query, callIDs)
+	rows, err := db.Pool.Query(ctx, query, callIDs, includeRestricted)
 	if err != nil {
 		return nil, err
 	}
@@ -371,8 +495,15 @@ func (db *DB) GetBatchTranscriptions(ctx context.Context, callIDs []int64) ([]Ba
 }
 
 // GetCallForTranscription returns a lightweight call view for the transcription worker.
-func (db *DB) GetCallForTranscription(ctx context.Context, callID int64) (*CallTranscriptionInfo, error) {
-	row, err := db.Q.GetCallForTranscription(ctx, callID)
+func (db *DB) GetCallForTranscription(ctx context.Context, ref CallRef) (*CallTranscriptionInfo, error) {
+	ref, err := db.ResolveCallRef(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	row, err := db.Q.GetCallForTranscription(ctx, sqlcdb.GetCallForTranscriptionParams{
+		CallID:    ref.CallID,
+		StartTime: pgtz(ref.StartTime),
+	})
 	if err != nil {
 		return nil, err
 	}
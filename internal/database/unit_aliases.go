@@ -0,0 +1,277 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// AttributedTranscription is a primary transcription with unit-attributed
+// words, as scanned by the unit alias inference job.
+type AttributedTranscription struct {
+	ID            int
+	CallID        int64
+	CallStartTime time.Time
+	SystemID      int
+	Words         json.RawMessage
+}
+
+// ListAttributedTranscriptions returns primary transcriptions with word
+// attribution whose id is greater than afterID and whose call started at or
+// after since, in id order.
+func (db *DB) ListAttributedTranscriptions(ctx context.Context, afterID int, since time.Time, limit int) ([]AttributedTranscription, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT t.id, t.call_id, t.call_start_time, c.system_id, t.words
+		FROM transcriptions t
+		JOIN calls c ON c.call_id = t.call_id AND c.start_time = t.call_start_time
+		WHERE t.id > $1
+		  AND t.call_start_time >= $2
+		  AND t.is_primary
+		  AND t.words IS NOT NULL
+		ORDER BY t.id
+		LIMIT $3
+	`, afterID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []AttributedTranscription
+	for rows.Next() {
+		var t AttributedTranscription
+		if err := rows.Scan(&t.ID, &t.CallID, &t.CallStartTime, &t.SystemID, &t.Words); err != nil {
+			return nil, err
+		}
+		result = append(result, t)
+	}
+	return result, rows.Err()
+}
+
+// UnitAliasEvidence is one transcript segment in which a unit appears to
+// identify itself by alias.
+type UnitAliasEvidence struct {
+	SystemID        int
+	UnitID          int
+	Alias           string
+	TranscriptionID int
+	CallID          int64
+	CallStartTime   time.Time
+	Snippet         string
+	Weight          float64
+}
+
+// RecordUnitAliasEvidence proposes e.Alias for the unit (once per unit/alias)
+// and attaches the supporting snippet. Units that already have an alpha_tag
+// are skipped, and evidence from a transcription already recorded for the
+// suggestion is ignored. Returns whether new evidence was stored.
+func (db *DB) RecordUnitAliasEvidence(ctx context.Context, e UnitAliasEvidence) (bool, error) {
+	tag, err := db.Pool.Exec(ctx, `
+		WITH s AS (
+			INSERT INTO unit_alias_suggestions (system_id, unit_id, alias, first_seen, last_seen)
+			SELECT $1, $2, $3, $6, $6
+			WHERE NOT EXISTS (
+				SELECT 1 FROM units
+				WHERE system_id = $1 AND unit_id = $2 AND COALESCE(alpha_tag, '') <> ''
+			)
+			ON CONFLICT (system_id, unit_id, alias) DO UPDATE SET
+				first_seen = LEAST(unit_alias_suggestions.first_seen, EXCLUDED.first_seen),
+				last_seen  = GREATEST(unit_alias_suggestions.last_seen, EXCLUDED.last_seen)
+			RETURNING id
+		)
+		INSERT INTO unit_alias_evidence (suggestion_id, transcription_id, call_id, call_start_time, snippet, weight)
+		SELECT s.id, $4, $5, $6, $7, $8 FROM s
+		ON CONFLICT (suggestion_id, transcription_id) DO NOTHING
+	`, e.SystemID, e.UnitID, e.Alias, e.TranscriptionID, e.CallID, e.CallStartTime, e.Snippet, float32(e.Weight))
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// UnitAliasExample is a transcript snippet supporting a suggestion.
+type UnitAliasExample struct {
+	CallID        int64     `json:"call_id"`
+	CallStartTime time.Time `json:"call_start_time"`
+	Snippet       string    `json:"snippet"`
+}
+
+// UnitAliasSuggestion is a proposed alpha_tag for a unit, inferred from
+// transcripts in which the unit identified itself.
+type UnitAliasSuggestion struct {
+	ID           int                `json:"id"`
+	SystemID     int                `json:"system_id"`
+	UnitID       int                `json:"unit_id"`
+	Alias        string             `json:"alias"`
+	Status       string             `json:"status"`
+	Confidence   float64            `json:"confidence"`
+	Hits         int                `json:"hits"`  // supporting transcripts
+	Calls        int                `json:"calls"` // distinct calls among them
+	UnitAlphaTag string             `json:"unit_alpha_tag,omitempty"`
+	FirstSeen    time.Time          `json:"first_seen"`
+	LastSeen     time.Time          `json:"last_seen"`
+	ReviewedAt   *time.Time         `json:"reviewed_at,omitempty"`
+	Examples     []UnitAliasExample `json:"examples"`
+}
+
+// UnitAliasSuggestionFilter specifies filters for listing alias suggestions.
+type UnitAliasSuggestionFilter struct {
+	SystemID      *int
+	UnitID        *int
+	Status        *string // nil = all
+	MinConfidence float64
+	Limit         int
+	Offset        int
+}
+
+// unitAliasSuggestionQuery scores suggestions from their evidence. confidence
+// is the share of the unit's (non-rejected) self-identification weight that
+// names this alias, damped while evidence is thin: one strong hit scores at most
+// ~0.28, three ~0.63, six ~0.86. A radio shared between crews, or a dispatcher
+// echoing unit names, spreads weight across aliases and scores low.
+const unitAliasSuggestionQuery = `
+	WITH agg AS (
+		SELECT suggestion_id, count(*)::int AS hits, count(DISTINCT call_id)::int AS calls,
+			sum(weight)::float8 AS score
+		FROM unit_alias_evidence
+		GROUP BY suggestion_id
+	), totals AS (
+		SELECT s.system_id, s.unit_id, sum(a.score) AS total
+		FROM unit_alias_suggestions s
+		JOIN agg a ON a.suggestion_id = s.id
+		WHERE s.status <> 'rejected'
+		GROUP BY s.system_id, s.unit_id
+	), scored AS (
+		SELECT s.*, a.hits, a.calls,
+			(a.score / GREATEST(t.total, a.score)) * (1 - exp(-a.score / 3)) AS confidence
+		FROM unit_alias_suggestions s
+		JOIN agg a ON a.suggestion_id = s.id
+		LEFT JOIN totals t ON t.system_id = s.system_id AND t.unit_id = s.unit_id
+	)
+	SELECT sc.id, sc.system_id, sc.unit_id, sc.alias, sc.status, sc.confidence,
+		sc.hits, sc.calls, COALESCE(u.alpha_tag, ''), sc.first_seen, sc.last_seen, sc.reviewed_at,
+		COALESCE((
+			SELECT json_agg(json_build_object('call_id', e.call_id, 'call_start_time', e.call_start_time, 'snippet', e.snippet)
+				ORDER BY e.call_start_time DESC)
+			FROM (
+				SELECT call_id, call_start_time, snippet FROM unit_alias_evidence
+				WHERE suggestion_id = sc.id
+				ORDER BY weight DESC, call_start_time DESC
+				LIMIT 3
+			) e
+		), '[]')
+	FROM scored sc
+	LEFT JOIN units u ON u.system_id = sc.system_id AND u.unit_id = sc.unit_id`
+
+func scanUnitAliasSuggestion(row pgx.Row) (*UnitAliasSuggestion, error) {
+	var s UnitAliasSuggestion
+	var examples []byte
+	if err := row.Scan(&s.ID, &s.SystemID, &s.UnitID, &s.Alias, &s.Status, &s.Confidence,
+		&s.Hits, &s.Calls, &s.UnitAlphaTag, &s.FirstSeen, &s.LastSeen, &s.ReviewedAt, &examples); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(examples, &s.Examples); err != nil {
+		return nil, fmt.Errorf("decode examples: %w", err)
+	}
+	return &s, nil
+}
+
+// ListUnitAliasSuggestions returns alias suggestions, most confident first.
+// Pending suggestions for units that have since been tagged are left out.
+func (db *DB) ListUnitAliasSuggestions(ctx context.Context, filter UnitAliasSuggestionFilter) ([]UnitAliasSuggestion, int, error) {
+	const where = `
+		WHERE ($1::int IS NULL OR sc.system_id = $1)
+		  AND ($2::int IS NULL OR sc.unit_id = $2)
+		  AND ($3::text IS NULL OR sc.status = $3)
+		  AND sc.confidence >= $4
+		  AND NOT (sc.status = 'pending' AND COALESCE(u.alpha_tag, '') <> '')`
+	args := []any{filter.SystemID, filter.UnitID, filter.Status, filter.MinConfidence}
+
+	var total int
+	if err := db.Pool.QueryRow(ctx, `SELECT count(*) FROM (`+unitAliasSuggestionQuery+where+`) q`,
+		args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Pool.Query(ctx, unitAliasSuggestionQuery+where+`
+		ORDER BY sc.confidence DESC, sc.hits DESC, sc.id
+		LIMIT $5 OFFSET $6
+	`, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	suggestions := []UnitAliasSuggestion{}
+	for rows.Next() {
+		s, err := scanUnitAliasSuggestion(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		suggestions = append(suggestions, *s)
+	}
+	return suggestions, total, rows.Err()
+}
+
+// ReviewUnitAliasSuggestion accepts or rejects a pending suggestion. Accepting
+// sets the unit's alpha_tag (to alphaTag if non-empty, otherwise the suggested
+// alias) as a manual tag and rejects the unit's other pending suggestions.
+// Rejected aliases are never proposed again for that unit.
+func (db *DB) ReviewUnitAliasSuggestion(ctx context.Context, id int, accept bool, alphaTag string) (*UnitAliasSuggestion, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var systemID, unitID int
+	var alias, status string
+	err = tx.QueryRow(ctx, `
+		SELECT system_id, unit_id, alias, status FROM unit_alias_suggestions WHERE id = $1 FOR UPDATE
+	`, id).Scan(&systemID, &unitID, &alias, &status)
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("unit alias suggestion not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status != "pending" {
+		return nil, fmt.Errorf("unit alias suggestion already reviewed")
+	}
+
+	newStatus := "rejected"
+	if accept {
+		newStatus = "accepted"
+		if alphaTag == "" {
+			alphaTag = alias
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO units (system_id, unit_id, alpha_tag, alpha_tag_source)
+			VALUES ($1, $2, $3, 'manual')
+			ON CONFLICT (system_id, unit_id) DO UPDATE SET
+				alpha_tag        = EXCLUDED.alpha_tag,
+				alpha_tag_source = 'manual'
+		`, systemID, unitID, alphaTag); err != nil {
+			return nil, fmt.Errorf("apply alias: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE unit_alias_suggestions SET status = 'rejected', reviewed_at = now()
+			WHERE system_id = $1 AND unit_id = $2 AND status = 'pending' AND id <> $3
+		`, systemID, unitID, id); err != nil {
+			return nil, fmt.Errorf("close other suggestions: %w", err)
+		}
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE unit_alias_suggestions SET status = $2, reviewed_at = now() WHERE id = $1
+	`, id, newStatus); err != nil {
+		return nil, err
+	}
+
+	s, err := scanUnitAliasSuggestion(tx.QueryRow(ctx, unitAliasSuggestionQuery+` WHERE sc.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	return s, tx.Commit(ctx)
+}
@@ -0,0 +1,233 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RefreshUnitCensusRollup rebuilds unit_census_daily for the UTC days from
+// start through end (inclusive) from unit_events. Rows are upserted rather
+// than replaced, so days whose events were purged keep their counts.
+// Returns the number of rows written.
+func (db *DB) RefreshUnitCensusRollup(ctx context.Context, start, end time.Time) (int64, error) {
+	from := start.UTC().Truncate(24 * time.Hour)
+	to := end.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	if !to.After(from) {
+		return 0, fmt.Errorf("end before start")
+	}
+
+	tag, err := db.Pool.Exec(ctx, `
+		INSERT INTO unit_census_daily (system_id, unit_id, day, events, registrations, deregistrations)
+		SELECT system_id, unit_rid, ("time" AT TIME ZONE 'UTC')::date,
+			count(*)::int,
+			count(*) FILTER (WHERE event_type = 'on')::int,
+			count(*) FILTER (WHERE event_type = 'off')::int
+		FROM unit_events
+		WHERE "time" >= $1 AND "time" < $2
+		  AND unit_rid > 0
+		GROUP BY 1, 2, 3
+		ON CONFLICT (system_id, unit_id, day) DO UPDATE SET
+			events          = EXCLUDED.events,
+			registrations   = EXCLUDED.registrations,
+			deregistrations = EXCLUDED.deregistrations
+	`, from, to)
+	if err != nil {
+		return 0, fmt.Errorf("build rollup: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// UnitCensusRollupLatestDay returns the most recent day in
+// unit_census_daily, or nil if the rollup is empty.
+func (db *DB) UnitCensusRollupLatestDay(ctx context.Context) (*time.Time, error) {
+	var day *time.Time
+	err := db.Pool.QueryRow(ctx, `SELECT max(day)::timestamptz FROM unit_census_daily`).Scan(&day)
+	return day, err
+}
+
+// UnitCensusDay is one UTC day of a system's radio census.
+type UnitCensusDay struct {
+	Day             string `json:"day"`
+	Seen            int    `json:"seen"`            // unique radios with any unit event
+	Registered      int    `json:"registered"`      // of those, radios that registered
+	New             int    `json:"new"`             // radios first seen on the system that day
+	WentSilent      int    `json:"went_silent"`     // radios reaching silent_days without activity that day, still silent
+	Registrations   int    `json:"registrations"`   // "on" events
+	Deregistrations int    `json:"deregistrations"` // "off" events
+}
+
+// GetUnitCensus returns a system's census for each UTC day from start
+// through end, days without activity included. A radio counts as gone
+// silent on the day its last activity becomes silentDays old, if it is
+// still silent now.
+func (db *DB) GetUnitCensus(ctx context.Context, systemID int, start, end time.Time, silentDays int) ([]UnitCensusDay, error) {
+	rows, err := db.Pool.Query(ctx, `
+		WITH days AS (
+			SELECT d::date AS day FROM generate_series($2::date, $3::date, interval '1 day') d
+		), census AS (
+			SELECT day, count(*) AS seen,
+				count(*) FILTER (WHERE registrations > 0) AS registered,
+				sum(registrations) AS registrations,
+				sum(deregistrations) AS deregistrations
+			FROM unit_census_daily
+			WHERE system_id = $1 AND day BETWEEN $2::date AND $3::date
+			GROUP BY day
+		), first AS (
+			SELECT (first_seen AT TIME ZONE 'UTC')::date AS day, count(*) AS n
+			FROM units
+			WHERE system_id = $1 AND first_seen IS NOT NULL
+			GROUP BY 1
+		), silent AS (
+			SELECT (last_seen AT TIME ZONE 'UTC')::date + $4::int AS day, count(*) AS n
+			FROM units
+			WHERE system_id = $1 AND last_seen < now() - make_interval(days => $4::int)
+			GROUP BY 1
+		)
+		SELECT to_char(days.day, 'YYYY-MM-DD'),
+			COALESCE(census.seen, 0)::int, COALESCE(census.registered, 0)::int,
+			COALESCE(first.n, 0)::int, COALESCE(silent.n, 0)::int,
+			COALESCE(census.registrations, 0)::int, COALESCE(census.deregistrations, 0)::int
+		FROM days
+		LEFT JOIN census USING (day)
+		LEFT JOIN first USING (day)
+		LEFT JOIN silent USING (day)
+		ORDER BY days.day
+	`, systemID, start.UTC().Format("2006-01-02"), end.UTC().Format("2006-01-02"), silentDays)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []UnitCensusDay{}
+	for rows.Next() {
+		var d UnitCensusDay
+		if err := rows.Scan(&d.Day, &d.Seen, &d.Registered, &d.New, &d.WentSilent,
+			&d.Registrations, &d.Deregistrations); err != nil {
+			return nil, err
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
+
+// UnitCensusSummary totals a system's census over a window.
+type UnitCensusSummary struct {
+	Known  int `json:"known"`  // radios in the units table
+	Seen   int `json:"seen"`   // unique radios active in the window
+	New    int `json:"new"`    // radios first seen in the window
+	Silent int `json:"silent"` // radios without activity for silent_days, as of now
+	Never  int `json:"never"`  // radios in the directory never seen on the air
+}
+
+// GetUnitCensusSummary totals a system's radios for the UTC days from start
+// through end.
+func (db *DB) GetUnitCensusSummary(ctx context.Context, systemID int, start, end time.Time, silentDays int) (UnitCensusSummary, error) {
+	var s UnitCensusSummary
+	err := db.Pool.QueryRow(ctx, `
+		SELECT
+			count(*)::int,
+			(SELECT count(DISTINCT unit_id) FROM unit_census_daily
+			 WHERE system_id = $1 AND day BETWEEN $2::date AND $3::date)::int,
+			count(*) FILTER (WHERE (first_seen AT TIME ZONE 'UTC')::date BETWEEN $2::date AND $3::date)::int,
+			count(*) FILTER (WHERE last_seen < now() - make_interval(days => $4::int))::int,
+			count(*) FILTER (WHERE last_seen IS NULL)::int
+		FROM units
+		WHERE system_id = $1
+	`, systemID, start.UTC().Format("2006-01-02"), end.UTC().Format("2006-01-02"), silentDays).
+		Scan(&s.Known, &s.Seen, &s.New, &s.Silent, &s.Never)
+	return s, err
+}
+
+// Unit census statuses, for the radio inventory.
+const (
+	UnitCensusActive = "active" // seen in the window
+	UnitCensusNew    = "new"    // first seen in the window
+	UnitCensusIdle   = "idle"   // seen before the window, not yet silent
+	UnitCensusSilent = "silent" // no activity for silent_days
+	UnitCensusNever  = "never"  // in the directory, never seen on the air
+)
+
+// UnitCensusFilter selects radios for the inventory. Days are UTC. Status
+// active includes new radios seen in the window, and silent includes every
+// radio without activity for SilentDays whatever its other status.
+type UnitCensusFilter struct {
+	SystemID   int
+	StartTime  time.Time
+	EndTime    time.Time
+	SilentDays int
+	Status     string // one of the UnitCensus statuses; "" = every radio
+	Limit      int
+	Offset     int
+}
+
+// UnitCensusUnit is one radio in a system's inventory.
+type UnitCensusUnit struct {
+	UnitID          int        `json:"unit_id"`
+	AlphaTag        string     `json:"alpha_tag,omitempty"`
+	FirstSeen       *time.Time `json:"first_seen"`
+	LastSeen        *time.Time `json:"last_seen"`
+	DaysSeen        int        `json:"days_seen"` // days active in the window
+	Registrations   int        `json:"registrations"`
+	Deregistrations int        `json:"deregistrations"`
+	Status          string     `json:"status"`
+}
+
+// ListUnitCensusUnits returns a system's radios with their activity in the
+// window, by unit ID, and the total matching.
+func (db *DB) ListUnitCensusUnits(ctx context.Context, f UnitCensusFilter) ([]UnitCensusUnit, int, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 1000
+	}
+	rows, err := db.Pool.Query(ctx, `
+		WITH activity AS (
+			SELECT unit_id, count(*) AS days_seen,
+				sum(registrations) AS registrations, sum(deregistrations) AS deregistrations
+			FROM unit_census_daily
+			WHERE system_id = $1 AND day BETWEEN $2::date AND $3::date
+			GROUP BY unit_id
+		), inventory AS (
+			SELECT u.unit_id, COALESCE(u.alpha_tag, '') AS alpha_tag, u.first_seen, u.last_seen,
+				COALESCE(a.days_seen, 0)::int AS days_seen,
+				COALESCE(a.registrations, 0)::int AS registrations,
+				COALESCE(a.deregistrations, 0)::int AS deregistrations,
+				CASE
+					WHEN u.last_seen IS NULL THEN 'never'
+					WHEN (u.first_seen AT TIME ZONE 'UTC')::date BETWEEN $2::date AND $3::date THEN 'new'
+					WHEN a.unit_id IS NOT NULL THEN 'active'
+					WHEN u.last_seen < now() - make_interval(days => $4::int) THEN 'silent'
+					ELSE 'idle'
+				END AS status
+			FROM units u
+			LEFT JOIN activity a ON a.unit_id = u.unit_id
+			WHERE u.system_id = $1
+		)
+		SELECT unit_id, alpha_tag, first_seen, last_seen, days_seen, registrations, deregistrations, status,
+			count(*) OVER ()
+		FROM inventory
+		WHERE $5 = ''
+		   OR status = $5
+		   OR ($5 = 'active' AND status = 'new' AND days_seen > 0)
+		   OR ($5 = 'silent' AND last_seen < now() - make_interval(days => $4::int))
+		ORDER BY unit_id
+		LIMIT $6 OFFSET $7
+	`, f.SystemID, f.StartTime.UTC().Format("2006-01-02"), f.EndTime.UTC().Format("2006-01-02"),
+		f.SilentDays, f.Status, limit, f.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	units := []UnitCensusUnit{}
+	total := 0
+	for rows.Next() {
+		var u UnitCensusUnit
+		if err := rows.Scan(&u.UnitID, &u.AlphaTag, &u.FirstSeen, &u.LastSeen, &u.DaysSeen,
+			&u.Registrations, &u.Deregistrations, &u.Status, &total); err != nil {
+			return nil, 0, err
+		}
+		units = append(units, u)
+	}
+	return units, total, rows.Err()
+}
@@ -0,0 +1,266 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// UnitTagState is a unit's current alpha_tag and where it came from
+// ("manual", "csv", "mqtt", or empty).
+type UnitTagState struct {
+	AlphaTag string
+	Source   string
+}
+
+// UnitCSVConflict records a unit whose CSV row changed while the database
+// held a different manual edit. Neither side is overwritten until resolved.
+type UnitCSVConflict struct {
+	ID          int        `json:"id"`
+	SystemID    int        `json:"system_id"`
+	UnitID      int        `json:"unit_id"`
+	DBAlphaTag  string     `json:"db_alpha_tag"`
+	CSVAlphaTag string     `json:"csv_alpha_tag"`
+	DetectedAt  time.Time  `json:"detected_at"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+	Resolution  string     `json:"resolution,omitempty"`
+}
+
+// UnitCSVConflictFilter specifies filters for listing unit CSV conflicts.
+type UnitCSVConflictFilter struct {
+	SystemID *int
+	Open     *bool // nil = all, true = unresolved only, false = resolved only
+	Limit    int
+	Offset   int
+}
+
+// GetUnitCSVState returns the unit CSV contents recorded at the last sync of systemID.
+func (db *DB) GetUnitCSVState(ctx context.Context, systemID int) (map[int]string, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT unit_id, csv_alpha_tag FROM unit_csv_state WHERE system_id = $1
+	`, systemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	state := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			return nil, err
+		}
+		state[id] = tag
+	}
+	return state, rows.Err()
+}
+
+// ReplaceUnitCSVState stores tags as the new sync baseline for systemID.
+// Units absent from tags are dropped, so a row re-added to the CSV later is treated as new.
+func (db *DB) ReplaceUnitCSVState(ctx context.Context, systemID int, tags map[int]string) error {
+	ids := make([]int, 0, len(tags))
+	alphas := make([]string, 0, len(tags))
+	for id, tag := range tags {
+		ids = append(ids, id)
+		alphas = append(alphas, tag)
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM unit_csv_state WHERE system_id = $1`, systemID); err != nil {
+		return fmt.Errorf("clear state: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO unit_csv_state (system_id, unit_id, csv_alpha_tag)
+		SELECT $1, u.unit_id, u.alpha_tag FROM unnest($2::int[], $3::text[]) AS u(unit_id, alpha_tag)
+	`, systemID, ids, alphas); err != nil {
+		return fmt.Errorf("insert state: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// GetUnitTagStates returns the alpha_tag and source of every tagged unit in systemID.
+func (db *DB) GetUnitTagStates(ctx context.Context, systemID int) (map[int]UnitTagState, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT unit_id, alpha_tag, COALESCE(alpha_tag_source, '')
+		FROM units
+		WHERE system_id = $1 AND alpha_tag IS NOT NULL AND alpha_tag <> ''
+	`, systemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := make(map[int]UnitTagState)
+	for rows.Next() {
+		var id int
+		var s UnitTagState
+		if err := rows.Scan(&id, &s.AlphaTag, &s.Source); err != nil {
+			return nil, err
+		}
+		states[id] = s
+	}
+	return states, rows.Err()
+}
+
+// ApplyCSVUnitTag sets a unit's alpha_tag from a changed CSV row, creating the
+// unit if needed. Manual tags are never overwritten here; the sync reports
+// those as conflicts instead.
+func (db *DB) ApplyCSVUnitTag(ctx context.Context, systemID, unitID int, alphaTag string) error {
+	_, err := db.Pool.Exec(ctx, `
+		INSERT INTO units (system_id, unit_id, alpha_tag, alpha_tag_source)
+		VALUES ($1, $2, $3, 'csv')
+		ON CONFLICT (system_id, unit_id) DO UPDATE SET
+			alpha_tag        = EXCLUDED.alpha_tag,
+			alpha_tag_source = 'csv'
+		WHERE COALESCE(units.alpha_tag_source, '') <> 'manual'
+	`, systemID, unitID, alphaTag)
+	return err
+}
+
+// RecordUnitCSVConflict opens a conflict for a unit, or refreshes the tags on
+// its existing open conflict.
+func (db *DB) RecordUnitCSVConflict(ctx context.Context, systemID, unitID int, dbTag, csvTag string) error {
+	_, err := db.Pool.Exec(ctx, `
+		INSERT INTO unit_csv_conflicts (system_id, unit_id, db_alpha_tag, csv_alpha_tag)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (system_id, unit_id) WHERE resolved_at IS NULL DO UPDATE SET
+			db_alpha_tag  = EXCLUDED.db_alpha_tag,
+			csv_alpha_tag = EXCLUDED.csv_alpha_tag
+	`, systemID, unitID, dbTag, csvTag)
+	return err
+}
+
+// ConvergeUnitCSVConflict closes a unit's open conflict once the CSV and
+// database agree again without an explicit resolution.
+func (db *DB) ConvergeUnitCSVConflict(ctx context.Context, systemID, unitID int) error {
+	_, err := db.Pool.Exec(ctx, `
+		UPDATE unit_csv_conflicts SET resolved_at = now(), resolution = 'converged'
+		WHERE system_id = $1 AND unit_id = $2 AND resolved_at IS NULL
+	`, systemID, unitID)
+	return err
+}
+
+// OpenUnitCSVConflictUnits returns the unit IDs in systemID with an unresolved conflict.
+func (db *DB) OpenUnitCSVConflictUnits(ctx context.Context, systemID int) (map[int]bool, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT unit_id FROM unit_csv_conflicts WHERE system_id = $1 AND resolved_at IS NULL
+	`, systemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	open := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		open[id] = true
+	}
+	return open, rows.Err()
+}
+
+const unitCSVConflictColumns = `id, system_id, unit_id, COALESCE(db_alpha_tag, ''), COALESCE(csv_alpha_tag, ''),
+	detected_at, resolved_at, COALESCE(resolution, '')`
+
+func scanUnitCSVConflict(row pgx.Row) (*UnitCSVConflict, error) {
+	var c UnitCSVConflict
+	if err := row.Scan(&c.ID, &c.SystemID, &c.UnitID, &c.DBAlphaTag, &c.CSVAlphaTag,
+		&c.DetectedAt, &c.ResolvedAt, &c.Resolution); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ListUnitCSVConflicts returns unit CSV conflicts, newest first.
+func (db *DB) ListUnitCSVConflicts(ctx context.Context, filter UnitCSVConflictFilter) ([]UnitCSVConflict, int, error) {
+	const where = `
+		WHERE ($1::int IS NULL OR system_id = $1)
+		  AND ($2::bool IS NULL OR (resolved_at IS NULL) = $2)`
+
+	var total int
+	if err := db.Pool.QueryRow(ctx, `SELECT count(*) FROM unit_csv_conflicts`+where,
+		filter.SystemID, filter.Open).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Pool.Query(ctx, `SELECT `+unitCSVConflictColumns+` FROM unit_csv_conflicts`+where+`
+		ORDER BY detected_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`, filter.SystemID, filter.Open, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	conflicts := []UnitCSVConflict{}
+	for rows.Next() {
+		c, err := scanUnitCSVConflict(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		conflicts = append(conflicts, *c)
+	}
+	return conflicts, total, rows.Err()
+}
+
+// ResolveUnitCSVConflict closes an open conflict. keep is "csv" (apply the CSV
+// tag to the unit) or "db" (keep the manual tag; the next sync with writeback
+// enabled pushes it to the CSV).
+func (db *DB) ResolveUnitCSVConflict(ctx context.Context, id int, keep string) (*UnitCSVConflict, error) {
+	var resolution string
+	switch keep {
+	case "csv":
+		resolution = "kept_csv"
+	case "db":
+		resolution = "kept_db"
+	default:
+		return nil, fmt.Errorf("keep must be csv or db")
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	c, err := scanUnitCSVConflict(tx.QueryRow(ctx, `
+		SELECT `+unitCSVConflictColumns+` FROM unit_csv_conflicts WHERE id = $1 FOR UPDATE
+	`, id))
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("unit CSV conflict not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.ResolvedAt != nil {
+		return nil, fmt.Errorf("unit CSV conflict already resolved")
+	}
+
+	if keep == "csv" {
+		if _, err := tx.Exec(ctx, `
+			UPDATE units SET alpha_tag = NULLIF($3, ''), alpha_tag_source = 'csv'
+			WHERE system_id = $1 AND unit_id = $2
+		`, c.SystemID, c.UnitID, c.CSVAlphaTag); err != nil {
+			return nil, fmt.Errorf("apply CSV tag: %w", err)
+		}
+	}
+
+	c, err = scanUnitCSVConflict(tx.QueryRow(ctx, `
+		UPDATE unit_csv_conflicts SET resolved_at = now(), resolution = $2
+		WHERE id = $1
+		RETURNING `+unitCSVConflictColumns, id, resolution))
+	if err != nil {
+		return nil, err
+	}
+	return c, tx.Commit(ctx)
+}
@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// initiatingUnitSQL picks the unit that keyed up a call: the first src_list
+// entry, or — for encrypted calls, which never get a src_list — the single
+// unit stored in unit_ids at call_start.
+const initiatingUnitSQL = `COALESCE(
+	NULLIF(GREATEST((src_list->0->>'src')::int, 0), 0),
+	CASE WHEN cardinality(unit_ids) = 1 THEN unit_ids[1] END)`
+
+// RefreshUnitEncryptionRollup rebuilds unit_encryption_daily for the UTC days
+// from start through end (inclusive). Returns the number of rows written.
+func (db *DB) RefreshUnitEncryptionRollup(ctx context.Context, start, end time.Time) (int64, error) {
+	from := start.UTC().Truncate(24 * time.Hour)
+	to := end.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	if !to.After(from) {
+		return 0, fmt.Errorf("end before start")
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM unit_encryption_daily WHERE day >= $1::date AND day < $2::date
+	`, from, to); err != nil {
+		return 0, fmt.Errorf("clear rollup: %w", err)
+	}
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO unit_encryption_daily (system_id, unit_id, day, tgid,
+			encrypted_calls, encrypted_seconds, clear_calls, clear_seconds)
+		SELECT system_id, unit_id, day, tgid,
+			count(*) FILTER (WHERE encrypted)::int,
+			COALESCE(sum(duration) FILTER (WHERE encrypted), 0)::real,
+			count(*) FILTER (WHERE NOT encrypted)::int,
+			COALESCE(sum(duration) FILTER (WHERE NOT encrypted), 0)::real
+		FROM (
+			SELECT system_id, tgid,
+				(start_time AT TIME ZONE 'UTC')::date AS day,
+				COALESCE(encrypted, false) AS encrypted,
+				COALESCE(duration, 0) AS duration,
+				`+initiatingUnitSQL+` AS unit_id
+			FROM calls
+			WHERE start_time >= $1 AND start_time < $2
+			  AND tgid <> 0
+			  AND NOT COALESCE(interconnect, false)
+		) c
+		WHERE unit_id IS NOT NULL
+		GROUP BY system_id, unit_id, day, tgid
+	`, from, to)
+	if err != nil {
+		return 0, fmt.Errorf("build rollup: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// UnitEncryptionRollupLatestDay returns the most recent day in
+// unit_encryption_daily, or nil if the rollup is empty.
+func (db *DB) UnitEncryptionRollupLatestDay(ctx context.Context) (*time.Time, error) {
+	var day *time.Time
+	err := db.Pool.QueryRow(ctx, `SELECT max(day)::timestamptz FROM unit_encryption_daily`).Scan(&day)
+	return day, err
+}
+
+// UnitEncryptionFilter specifies filters for the unit encryption reports.
+// Times are rounded to UTC days.
+type UnitEncryptionFilter struct {
+	SystemIDs []int
+	UnitIDs   []int
+	StartTime time.Time
+	EndTime   *time.Time
+	MinCalls  int // switchers: minimum calls on each side
+	Limit     int
+}
+
+// UnitEncryptionUsage summarizes one unit's encrypted and clear calls.
+type UnitEncryptionUsage struct {
+	SystemID         int     `json:"system_id"`
+	SystemName       string  `json:"system_name,omitempty"`
+	UnitID           int     `json:"unit_id"`
+	UnitAlphaTag     string  `json:"unit_alpha_tag,omitempty"`
+	EncryptedCalls   int     `json:"encrypted_calls"`
+	EncryptedSeconds float64 `json:"encrypted_seconds"`
+	ClearCalls       int     `json:"clear_calls"`
+	ClearSeconds     float64 `json:"clear_seconds"`
+	EncryptedPct     float64 `json:"encrypted_pct"` // share of airtime
+	EncryptedTgids   []int   `json:"encrypted_tgids"`
+	ClearTgids       []int   `json:"clear_tgids"`
+	MixedTgids       []int   `json:"mixed_tgids"` // used both encrypted and clear
+	FirstDay         string  `json:"first_day"`
+	LastDay          string  `json:"last_day"`
+}
+
+// GetUnitEncryptionUsage returns units with encrypted calls in the window,
+// most encrypted airtime first.
+func (db *DB) GetUnitEncryptionUsage(ctx context.Context, filter UnitEncryptionFilter) ([]UnitEncryptionUsage, error) {
+	return db.queryUnitEncryption(ctx, filter, false)
+}
+
+// GetEncryptionSwitchers returns units that made both encrypted and clear
+// calls (at least MinCalls of each) in the window, most balanced first.
+func (db *DB) GetEncryptionSwitchers(ctx context.Context, filter UnitEncryptionFilter) ([]UnitEncryptionUsage, error) {
+	return db.queryUnitEncryption(ctx, filter, true)
+}
+
+func (db *DB) queryUnitEncryption(ctx context.Context, filter UnitEncryptionFilter, switchers bool) ([]UnitEncryptionUsage, error) {
+	minCalls := filter.MinCalls
+	if minCalls < 1 {
+		minCalls = 1
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	var endDay any
+	if filter.EndTime != nil {
+		endDay = filter.EndTime.UTC()
+	}
+
+	rows, err := db.Pool.Query(ctx, `
+		WITH per_tg AS (
+			SELECT system_id, unit_id, tgid,
+				sum(encrypted_calls) AS ec, sum(encrypted_seconds) AS es,
+				sum(clear_calls) AS cc, sum(clear_seconds) AS cs,
+				min(day) AS first_day, max(day) AS last_day
+			FROM unit_encryption_daily
+			WHERE day >= ($1::timestamptz AT TIME ZONE 'UTC')::date
+			  AND ($2::timestamptz IS NULL OR day <= ($2::timestamptz AT TIME ZONE 'UTC')::date)
+			  AND ($3::int[] IS NULL OR system_id = ANY($3))
+			  AND ($4::int[] IS NULL OR unit_id = ANY($4))
+			GROUP BY system_id, unit_id, tgid
+		), per_unit AS (
+			SELECT system_id, unit_id,
+				sum(ec)::int AS ec, sum(es)::float8 AS es,
+				sum(cc)::int AS cc, sum(cs)::float8 AS cs,
+				COALESCE(array_agg(tgid ORDER BY tgid) FILTER (WHERE ec > 0), '{}') AS enc_tgids,
+				COALESCE(array_agg(tgid ORDER BY tgid) FILTER (WHERE cc > 0), '{}') AS clear_tgids,
+				COALESCE(array_agg(tgid ORDER BY tgid) FILTER (WHERE ec > 0 AND cc > 0), '{}') AS mixed_tgids,
+				min(first_day) AS first_day, max(last_day) AS last_day
+			FROM per_tg
+			GROUP BY system_id, unit_id
+		)
+		SELECT pu.system_id, COALESCE(s.name, ''), pu.unit_id, COALESCE(un.alpha_tag, ''),
+			pu.ec, pu.es, pu.cc, pu.cs, pu.enc_tgids, pu.clear_tgids, pu.mixed_tgids,
+			to_char(pu.first_day, 'YYYY-MM-DD'), to_char(pu.last_day, 'YYYY-MM-DD')
+		FROM per_unit pu
+		JOIN systems s ON s.system_id = pu.system_id
+		LEFT JOIN units un ON un.system_id = pu.system_id AND un.unit_id = pu.unit_id
+		WHERE pu.ec >= $5 AND (NOT $6 OR pu.cc >= $5)
+		ORDER BY CASE WHEN $6 THEN least(pu.ec, pu.cc) ELSE 0 END DESC, pu.es DESC, pu.unit_id
+		LIMIT $7
+	`, filter.StartTime.UTC(), endDay, pqIntArray(filter.SystemIDs), pqIntArray(filter.UnitIDs),
+		minCalls, switchers, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []UnitEncryptionUsage{}
+	for rows.Next() {
+		var u UnitEncryptionUsage
+		if err := rows.Scan(&u.SystemID, &u.SystemName, &u.UnitID, &u.UnitAlphaTag,
+			&u.EncryptedCalls, &u.EncryptedSeconds, &u.ClearCalls, &u.ClearSeconds,
+			&u.EncryptedTgids, &u.ClearTgids, &u.MixedTgids, &u.FirstDay, &u.LastDay); err != nil {
+			return nil, err
+		}
+		u.EncryptedPct = encryptedPct(u.EncryptedSeconds, u.ClearSeconds)
+		result = append(result, u)
+	}
+	return result, rows.Err()
+}
+
+// encryptedPct returns the encrypted share of airtime as a percentage with
+// one decimal place.
+func encryptedPct(encrypted, clear float64) float64 {
+	total := encrypted + clear
+	if total <= 0 {
+		return 0
+	}
+	return math.Round(encrypted/total*1000) / 10
+}
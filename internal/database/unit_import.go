@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+)
+
+// UnitTag is a tagged unit as exported to a unit roster.
+type UnitTag struct {
+	UnitID         int    `json:"unit_id"`
+	AlphaTag       string `json:"alpha_tag"`
+	AlphaTagSource string `json:"alpha_tag_source,omitempty"`
+}
+
+// ListUnitTags returns every unit in systemID with an alpha_tag, ordered by
+// unit ID.
+func (db *DB) ListUnitTags(ctx context.Context, systemID int) ([]UnitTag, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT unit_id, alpha_tag, COALESCE(alpha_tag_source, '')
+		FROM units
+		WHERE system_id = $1 AND alpha_tag IS NOT NULL AND alpha_tag <> ''
+		ORDER BY unit_id
+	`, systemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []UnitTag{}
+	for rows.Next() {
+		var t UnitTag
+		if err := rows.Scan(&t.UnitID, &t.AlphaTag, &t.AlphaTagSource); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// ImportUnitTags sets the alpha_tag of each unit in tags as a manual edit,
+// creating units that haven't been heard yet. Returns the number of units
+// added or changed.
+func (db *DB) ImportUnitTags(ctx context.Context, systemID int, tags map[int]string) (int64, error) {
+	ids := make([]int, 0, len(tags))
+	alphas := make([]string, 0, len(tags))
+	for id, tag := range tags {
+		ids = append(ids, id)
+		alphas = append(alphas, tag)
+	}
+	tag, err := db.Pool.Exec(ctx, `
+		INSERT INTO units (system_id, unit_id, alpha_tag, alpha_tag_source)
+		SELECT $1, u.unit_id, u.alpha_tag, 'manual' FROM unnest($2::int[], $3::text[]) AS u(unit_id, alpha_tag)
+		ON CONFLICT (system_id, unit_id) DO UPDATE SET
+			alpha_tag        = EXCLUDED.alpha_tag,
+			alpha_tag_source = 'manual'
+		WHERE units.alpha_tag IS DISTINCT FROM EXCLUDED.alpha_tag
+		   OR units.alpha_tag_source IS DISTINCT FROM 'manual'
+	`, systemID, ids, alphas)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
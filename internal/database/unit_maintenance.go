@@ -0,0 +1,201 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ArchiveInactiveUnits moves units not seen within olderThan from units into
+// units_archive, preserving their tags and first/last seen times. Units with a
+// manually assigned alpha_tag are kept regardless of activity. Historical
+// unit_events and call_transmissions are untouched. A unit seen again is moved
+// back by the trg_units_restore_archived trigger, which restores its CSV or
+// manual tag (or any tag, when the new row has none). Returns the number archived.
+func (db *DB) ArchiveInactiveUnits(ctx context.Context, olderThan time.Duration) (int64, error) {
+	var n int64
+	err := db.Pool.QueryRow(ctx, `
+		WITH moved AS (
+			DELETE FROM units
+			WHERE last_seen < now() - $1::interval
+			  AND COALESCE(alpha_tag_source, '') <> 'manual'
+			RETURNING system_id, unit_id, alpha_tag, alpha_tag_source, first_seen, last_seen
+		), archived AS (
+			INSERT INTO units_archive (system_id, unit_id, alpha_tag, alpha_tag_source, first_seen, last_seen)
+			SELECT system_id, unit_id, alpha_tag, alpha_tag_source, first_seen, last_seen FROM moved
+			ON CONFLICT (system_id, unit_id) DO UPDATE SET
+				alpha_tag        = COALESCE(EXCLUDED.alpha_tag, units_archive.alpha_tag),
+				alpha_tag_source = COALESCE(EXCLUDED.alpha_tag_source, units_archive.alpha_tag_source),
+				first_seen       = LEAST(units_archive.first_seen, EXCLUDED.first_seen),
+				last_seen        = GREATEST(units_archive.last_seen, EXCLUDED.last_seen),
+				archived_at      = now()
+			RETURNING 1
+		)
+		SELECT count(*) FROM archived
+	`, olderThan).Scan(&n)
+	return n, err
+}
+
+// CountArchivedUnits returns the number of archived units per system.
+func (db *DB) CountArchivedUnits(ctx context.Context) (map[int]int64, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT system_id, count(*) FROM units_archive GROUP BY system_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int64)
+	for rows.Next() {
+		var systemID int
+		var n int64
+		if err := rows.Scan(&systemID, &n); err != nil {
+			return nil, err
+		}
+		counts[systemID] = n
+	}
+	return counts, rows.Err()
+}
+
+// UnitMergeResult reports what MergeUnits changed.
+type UnitMergeResult struct {
+	SystemID           int    `json:"system_id"`
+	SourceUnitID       int    `json:"source_unit_id"`
+	TargetUnitID       int    `json:"target_unit_id"`
+	EventsMoved        int    `json:"events_moved"`
+	TransmissionsMoved int    `json:"transmissions_moved"`
+	CallsUpdated       int    `json:"calls_updated"`
+	AlphaTag           string `json:"alpha_tag"`
+}
+
+// MergeUnits folds sourceUnitID into targetUnitID within a system, e.g. when an
+// agency re-issues a radio ID. unit_events, call_transmissions, and calls.unit_ids
+// are rewritten to the target; the target keeps its alpha_tag unless it has none,
+// in which case the source's tag is carried over. The source unit row is removed
+// and an entry is written to unit_merge_log.
+//
+// Like MergeSystems, the partitioned-table updates are not time-bounded and scan
+// every partition. Acceptable for a rare admin operation.
+func (db *DB) MergeUnits(ctx context.Context, systemID, sourceUnitID, targetUnitID int, performedBy string) (*UnitMergeResult, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	type unitRow struct {
+		alpha, source       string
+		firstSeen, lastSeen *time.Time
+	}
+	var src unitRow
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(alpha_tag, ''), COALESCE(alpha_tag_source, ''), first_seen, last_seen
+		FROM units WHERE system_id = $1 AND unit_id = $2
+		FOR UPDATE
+	`, systemID, sourceUnitID).Scan(&src.alpha, &src.source, &src.firstSeen, &src.lastSeen)
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("source unit not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read source unit: %w", err)
+	}
+
+	result := &UnitMergeResult{SystemID: systemID, SourceUnitID: sourceUnitID, TargetUnitID: targetUnitID}
+
+	var dst unitRow
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(alpha_tag, ''), COALESCE(alpha_tag_source, ''), first_seen, last_seen
+		FROM units WHERE system_id = $1 AND unit_id = $2
+		FOR UPDATE
+	`, systemID, targetUnitID).Scan(&dst.alpha, &dst.source, &dst.firstSeen, &dst.lastSeen)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("read target unit: %w", err)
+	}
+	if err == pgx.ErrNoRows {
+		// No target row yet — renumber the source in place.
+		if _, err := tx.Exec(ctx, `UPDATE units SET unit_id = $3 WHERE system_id = $1 AND unit_id = $2`,
+			systemID, sourceUnitID, targetUnitID); err != nil {
+			return nil, fmt.Errorf("renumber unit: %w", err)
+		}
+		result.AlphaTag = src.alpha
+	} else {
+		alpha, source := dst.alpha, dst.source
+		if alpha == "" {
+			alpha, source = src.alpha, src.source
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE units SET
+				alpha_tag        = NULLIF($3, ''),
+				alpha_tag_source = NULLIF($4, ''),
+				first_seen       = LEAST(first_seen, $5),
+				last_seen        = GREATEST(last_seen, $6)
+			WHERE system_id = $1 AND unit_id = $2
+		`, systemID, targetUnitID, alpha, source, src.firstSeen, src.lastSeen); err != nil {
+			return nil, fmt.Errorf("merge unit tags: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM units WHERE system_id = $1 AND unit_id = $2`, systemID, sourceUnitID); err != nil {
+			return nil, fmt.Errorf("delete source unit: %w", err)
+		}
+		result.AlphaTag = alpha
+	}
+
+	// Move unit_events
+	tag, err := tx.Exec(ctx, `UPDATE unit_events SET unit_rid = $3 WHERE system_id = $1 AND unit_rid = $2`,
+		systemID, sourceUnitID, targetUnitID)
+	if err != nil {
+		return nil, fmt.Errorf("move unit_events: %w", err)
+	}
+	result.EventsMoved = int(tag.RowsAffected())
+
+	// Move unit_sessions, dropping any the target already has
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM unit_sessions s USING unit_sessions t
+		WHERE s.system_id = $1 AND s.unit_rid = $2 AND t.system_id = $1 AND t.unit_rid = $3
+		  AND t.tgid = s.tgid AND t.start_time = s.start_time
+	`, systemID, sourceUnitID, targetUnitID); err != nil {
+		return nil, fmt.Errorf("dedupe unit_sessions: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE unit_sessions SET unit_rid = $3 WHERE system_id = $1 AND unit_rid = $2`,
+		systemID, sourceUnitID, targetUnitID); err != nil {
+		return nil, fmt.Errorf("move unit_sessions: %w", err)
+	}
+
+	// Move call_transmissions (no system_id column — scope via parent call)
+	tag, err = tx.Exec(ctx, `
+		UPDATE call_transmissions ct SET src = $3
+		FROM calls c
+		WHERE c.call_id = ct.call_id AND c.start_time = ct.call_start_time
+		  AND c.system_id = $1 AND ct.src = $2
+	`, systemID, sourceUnitID, targetUnitID)
+	if err != nil {
+		return nil, fmt.Errorf("move call_transmissions: %w", err)
+	}
+	result.TransmissionsMoved = int(tag.RowsAffected())
+
+	// Rewrite calls.unit_ids, avoiding a duplicate when the target is already present.
+	// src_list is left as recorded (raw TR payload).
+	tag, err = tx.Exec(ctx, `
+		UPDATE calls SET unit_ids = CASE
+			WHEN $3 = ANY(unit_ids) THEN array_remove(unit_ids, $2)
+			ELSE array_replace(unit_ids, $2, $3)
+		END
+		WHERE system_id = $1 AND $2 = ANY(unit_ids)
+	`, systemID, sourceUnitID, targetUnitID)
+	if err != nil {
+		return nil, fmt.Errorf("rewrite calls.unit_ids: %w", err)
+	}
+	result.CallsUpdated = int(tag.RowsAffected())
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO unit_merge_log (system_id, source_unit_id, target_unit_id, events_moved, transmissions_moved, calls_updated, alpha_tag_kept, performed_by)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8)
+	`, systemID, sourceUnitID, targetUnitID, result.EventsMoved, result.TransmissionsMoved, result.CallsUpdated, result.AlphaTag, performedBy); err != nil {
+		return nil, fmt.Errorf("log merge: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit merge: %w", err)
+	}
+	return result, nil
+}
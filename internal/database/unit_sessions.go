@@ -0,0 +1,294 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Unit session end reasons (unit_sessions.ended_by).
+const (
+	UnitSessionEndOff        = "off"         // unit deregistered
+	UnitSessionEndOn         = "on"          // unit registered again
+	UnitSessionEndTgidChange = "tgid_change" // unit moved to another talkgroup
+	UnitSessionEndIdle       = "idle"        // no events for UNIT_SESSION_IDLE
+)
+
+// UnitSession is a unit on a talkgroup from StartTime to EndTime, folded
+// from unit_events. EndedBy is empty while the session is open; EndTime is
+// then the last event so far.
+type UnitSession struct {
+	ID           int64     `json:"id"`
+	SystemID     int       `json:"system_id"`
+	SystemName   string    `json:"system_name,omitempty"`
+	UnitID       int       `json:"unit_id"`
+	UnitAlphaTag string    `json:"unit_alpha_tag,omitempty"`
+	Tgid         int       `json:"tgid"`
+	TgAlphaTag   string    `json:"tg_alpha_tag,omitempty"`
+	StartTime    time.Time `json:"start_time"`
+	EndTime      time.Time `json:"end_time"`
+	Duration     float64   `json:"duration"` // seconds
+	Events       int       `json:"events"`
+	Calls        int       `json:"calls"`
+	EndedBy      string    `json:"ended_by,omitempty"`
+	Open         bool      `json:"open"`
+}
+
+// UnitSessionEvent is the part of a unit event the compactor folds.
+type UnitSessionEvent struct {
+	SystemID  int
+	UnitRID   int
+	EventType string
+	Tgid      int // 0 = none
+	Time      time.Time
+}
+
+// UnitSessionWatermark returns the time unit_events have been folded into
+// sessions up to, or nil before the first compaction.
+func (db *DB) UnitSessionWatermark(ctx context.Context) (*time.Time, error) {
+	var t time.Time
+	err := db.Pool.QueryRow(ctx, `SELECT compacted_through FROM unit_session_state`).Scan(&t)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListUnitEventsForSessions returns unit events in [from, to), grouped by
+// unit and in time order.
+func (db *DB) ListUnitEventsForSessions(ctx context.Context, from, to time.Time) ([]UnitSessionEvent, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT system_id, unit_rid, event_type, COALESCE(tgid, 0), "time"
+		FROM unit_events
+		WHERE "time" >= $1 AND "time" < $2
+		ORDER BY system_id, unit_rid, "time", id
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []UnitSessionEvent
+	for rows.Next() {
+		var e UnitSessionEvent
+		if err := rows.Scan(&e.SystemID, &e.UnitRID, &e.EventType, &e.Tgid, &e.Time); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// ListOpenUnitSessions returns the sessions still open, oldest last event
+// first.
+func (db *DB) ListOpenUnitSessions(ctx context.Context) ([]UnitSession, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT id, system_id, unit_rid, tgid, start_time, end_time, events, calls
+		FROM unit_sessions
+		WHERE ended_by IS NULL
+		ORDER BY end_time
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var sessions []UnitSession
+	for rows.Next() {
+		s := UnitSession{Open: true}
+		if err := rows.Scan(&s.ID, &s.SystemID, &s.UnitID, &s.Tgid, &s.StartTime, &s.EndTime, &s.Events, &s.Calls); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// SaveUnitSessions stores new and changed sessions and advances the
+// compaction watermark to through, in one transaction. Sessions are keyed by
+// (system, unit, talkgroup, start time), so saving one again updates it.
+func (db *DB) SaveUnitSessions(ctx context.Context, sessions []UnitSession, through time.Time) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	for _, s := range sessions {
+		var endedBy *string
+		if s.EndedBy != "" {
+			endedBy = &s.EndedBy
+		}
+		batch.Queue(`
+			INSERT INTO unit_sessions (system_id, unit_rid, tgid, start_time, end_time, events, calls, ended_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (system_id, unit_rid, tgid, start_time) DO UPDATE SET
+				end_time = EXCLUDED.end_time,
+				events   = EXCLUDED.events,
+				calls    = EXCLUDED.calls,
+				ended_by = EXCLUDED.ended_by
+		`, s.SystemID, s.UnitID, s.Tgid, s.StartTime, s.EndTime, s.Events, s.Calls, endedBy)
+	}
+	batch.Queue(`
+		INSERT INTO unit_session_state (id, compacted_through, updated_at) VALUES (true, $1, now())
+		ON CONFLICT (id) DO UPDATE SET compacted_through = EXCLUDED.compacted_through, updated_at = now()
+	`, through)
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("save unit sessions: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// UnitSessionFilter selects sessions for ListUnitSessions. StartTime and
+// EndTime select sessions overlapping the range.
+type UnitSessionFilter struct {
+	SystemIDs []int
+	UnitIDs   []int
+	Tgids     []int
+	Open      *bool
+	StartTime *time.Time
+	EndTime   *time.Time
+	Sort      string
+	Limit     int
+	Offset    int
+}
+
+// ListUnitSessions returns sessions matching the filter with display names.
+func (db *DB) ListUnitSessions(ctx context.Context, filter UnitSessionFilter) ([]UnitSession, int, error) {
+	const fromClause = `FROM unit_sessions us
+		JOIN systems s ON s.system_id = us.system_id
+		LEFT JOIN units u ON u.system_id = us.system_id AND u.unit_id = us.unit_rid
+		LEFT JOIN talkgroups tg ON tg.system_id = us.system_id AND tg.tgid = us.tgid`
+	const whereClause = `
+		WHERE ($1::int[] IS NULL OR us.system_id = ANY($1))
+		  AND ($2::int[] IS NULL OR us.unit_rid = ANY($2))
+		  AND ($3::int[] IS NULL OR us.tgid = ANY($3))
+		  AND ($4::boolean IS NULL OR (us.ended_by IS NULL) = $4)
+		  AND ($5::timestamptz IS NULL OR us.end_time >= $5)
+		  AND ($6::timestamptz IS NULL OR us.start_time < $6)`
+	args := []any{
+		pqIntArray(filter.SystemIDs), pqIntArray(filter.UnitIDs), pqIntArray(filter.Tgids),
+		filter.Open, filter.StartTime, filter.EndTime,
+	}
+
+	var total int
+	if err := db.Pool.QueryRow(ctx, "SELECT count(*) "+fromClause+whereClause, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	orderBy := "us.start_time DESC"
+	if filter.Sort != "" {
+		orderBy = filter.Sort
+	}
+	rows, err := db.Pool.Query(ctx, fmt.Sprintf(`
+		SELECT us.id, us.system_id, COALESCE(s.name, ''),
+			us.unit_rid, COALESCE(u.alpha_tag, ''),
+			us.tgid, COALESCE(tg.alpha_tag, ''),
+			us.start_time, us.end_time, us.events, us.calls, COALESCE(us.ended_by, '')
+		%s %s
+		ORDER BY %s
+		LIMIT $7 OFFSET $8
+	`, fromClause, whereClause, orderBy), append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	sessions := []UnitSession{}
+	for rows.Next() {
+		var s UnitSession
+		if err := rows.Scan(&s.ID, &s.SystemID, &s.SystemName, &s.UnitID, &s.UnitAlphaTag,
+			&s.Tgid, &s.TgAlphaTag, &s.StartTime, &s.EndTime, &s.Events, &s.Calls, &s.EndedBy); err != nil {
+			return nil, 0, err
+		}
+		s.Duration = s.EndTime.Sub(s.StartTime).Seconds()
+		s.Open = s.EndedBy == ""
+		sessions = append(sessions, s)
+	}
+	return sessions, total, rows.Err()
+}
+
+// UnitSessionSummary is session time within a range for one talkgroup or
+// one unit.
+type UnitSessionSummary struct {
+	SystemID    int     `json:"system_id"`
+	Tgid        *int    `json:"tgid,omitempty"`
+	UnitID      *int    `json:"unit_id,omitempty"`
+	AlphaTag    string  `json:"alpha_tag,omitempty"`
+	Sessions    int     `json:"sessions"`
+	Units       int     `json:"units,omitempty"`      // distinct units, by talkgroup
+	Talkgroups  int     `json:"talkgroups,omitempty"` // distinct talkgroups, by unit
+	Calls       int     `json:"calls"`
+	UnitSeconds float64 `json:"unit_seconds"` // session time inside the range, summed
+}
+
+// UnitSessionSummaryFilter selects what SummarizeUnitSessions aggregates.
+// GroupBy is "tgid" or "unit"; the range is required.
+type UnitSessionSummaryFilter struct {
+	SystemIDs []int
+	UnitIDs   []int
+	Tgids     []int
+	StartTime time.Time
+	EndTime   time.Time
+	GroupBy   string
+	Limit     int
+}
+
+// SummarizeUnitSessions totals session time per talkgroup or unit, clipped
+// to the range, most time first.
+func (db *DB) SummarizeUnitSessions(ctx context.Context, filter UnitSessionSummaryFilter) ([]UnitSessionSummary, error) {
+	var key, distinct, name, join string
+	switch filter.GroupBy {
+	case "tgid":
+		key, distinct = "us.tgid", "us.unit_rid"
+		name = "COALESCE(max(tg.alpha_tag), '')"
+		join = "LEFT JOIN talkgroups tg ON tg.system_id = us.system_id AND tg.tgid = us.tgid"
+	case "unit":
+		key, distinct = "us.unit_rid", "us.tgid"
+		name = "COALESCE(max(u.alpha_tag), '')"
+		join = "LEFT JOIN units u ON u.system_id = us.system_id AND u.unit_id = us.unit_rid"
+	default:
+		return nil, fmt.Errorf("invalid group_by %q", filter.GroupBy)
+	}
+	rows, err := db.Pool.Query(ctx, fmt.Sprintf(`
+		SELECT us.system_id, %[1]s, %[3]s,
+			count(*)::int, count(DISTINCT %[2]s)::int, sum(us.calls)::int,
+			COALESCE(sum(EXTRACT(EPOCH FROM LEAST(us.end_time, $5) - GREATEST(us.start_time, $4))), 0)::float8 AS unit_seconds
+		FROM unit_sessions us
+		%[4]s
+		WHERE ($1::int[] IS NULL OR us.system_id = ANY($1))
+		  AND ($2::int[] IS NULL OR us.unit_rid = ANY($2))
+		  AND ($3::int[] IS NULL OR us.tgid = ANY($3))
+		  AND us.end_time >= $4 AND us.start_time < $5
+		GROUP BY us.system_id, %[1]s
+		ORDER BY unit_seconds DESC, us.system_id, %[1]s
+		LIMIT $6
+	`, key, distinct, name, join),
+		pqIntArray(filter.SystemIDs), pqIntArray(filter.UnitIDs), pqIntArray(filter.Tgids),
+		filter.StartTime, filter.EndTime, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []UnitSessionSummary{}
+	for rows.Next() {
+		var s UnitSessionSummary
+		var id, count int
+		if err := rows.Scan(&s.SystemID, &id, &s.AlphaTag, &s.Sessions, &count, &s.Calls, &s.UnitSeconds); err != nil {
+			return nil, err
+		}
+		if filter.GroupBy == "tgid" {
+			s.Tgid, s.Units = &id, count
+		} else {
+			s.UnitID, s.Talkgroups = &id, count
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
@@ -68,6 +68,7 @@ func unitRowToAPI(r sqlcdb.GetUnitByCompositeRow) UnitAPI {
 
 // ListUnits returns units matching the filter.
 func (db *DB) ListUnits(ctx context.Context, filter UnitFilter) ([]UnitAPI, int, error) {
+	start := time.Now()
 	var activeWithin any
 	if filter.ActiveWithin != nil {
 		activeWithin = strconv.Itoa(*filter.ActiveWithin) + " minutes"
@@ -104,7 +105,8 @@ func (db *DB) ListUnits(ctx context.Context, filter UnitFilter) ([]UnitAPI, int,
 		LIMIT $5 OFFSET $6
 	`, fromClause, whereClause, orderBy)
 
-	rows, err := db.Pool.Query(ctx, dataQuery, append(args, filter.Limit, filter.Offset)...)
+	dataArgs := append(args, filter.Limit, filter.Offset)
+	rows, err := db.Pool.Query(ctx, dataQuery, dataArgs...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -139,7 +141,11 @@ func (db *DB) ListUnits(ctx context.Context, filter UnitFilter) ([]UnitAPI, int,
 	if units == nil {
 		units = []UnitAPI{}
 	}
-	return units, total, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	db.observeQuery("list_units", start, dataQuery, dataArgs)
+	return units, total, nil
 }
 
 // GetUnitByComposite returns a single unit by system_id and unit_id.
@@ -190,21 +196,6 @@ func (db *DB) UpdateUnitFields(ctx context.Context, systemID, unitID int, alphaT
 	})
 }
 
-// ImportUnitTag imports a unit alpha_tag from a CSV file. Preserves manual tags (user edits)
-// and existing CSV tags (idempotent re-import). Overwrites auto-discovered tags from MQTT.
-func (db *DB) ImportUnitTag(ctx context.Context, systemID, unitID int, alphaTag string) error {
-	_, err := db.Pool.Exec(ctx, `
-		INSERT INTO units (system_id, unit_id, alpha_tag, alpha_tag_source)
-		VALUES ($1, $2, $3, 'csv')
-		ON CONFLICT (system_id, unit_id) DO UPDATE SET
-			alpha_tag = CASE WHEN COALESCE(units.alpha_tag_source, '') IN ('manual', 'csv') THEN units.alpha_tag
-			                 ELSE $3 END,
-			alpha_tag_source = CASE WHEN COALESCE(units.alpha_tag_source, '') = 'manual' THEN units.alpha_tag_source
-			                        ELSE 'csv' END
-	`, systemID, unitID, alphaTag)
-	return err
-}
-
 // UpsertUnit inserts or updates a unit, never overwriting good data with empty strings.
 // Returns the effective alpha_tag from the database (respects manual > csv > mqtt priority).
 func (db *DB) UpsertUnit(ctx context.Context, systemID, unitID int, alphaTag, eventType string, eventTime time.Time, tgid int) (string, error) {
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// MarkCallAudioUnusable records why a call's recording is unusable ("empty"
+// or "silent"). When the file was deleted, the call's audio references and
+// audio variants are cleared as well.
+func (db *DB) MarkCallAudioUnusable(ctx context.Context, callID int64, startTime time.Time, reason string, deleted bool) error {
+	if !deleted {
+		_, err := db.Pool.Exec(ctx, `
+			UPDATE calls SET audio_unusable = $3, updated_at = now()
+			WHERE call_id = $1 AND start_time = $2
+		`, callID, startTime, reason)
+		return err
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `
+		UPDATE calls SET audio_unusable = $3, audio_file_path = NULL, audio_file_size = NULL,
+			call_filename = NULL, updated_at = now()
+		WHERE call_id = $1 AND start_time = $2
+	`, callID, startTime, reason); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM call_audio_variants WHERE call_id = $1 AND call_start_time = $2
+	`, callID, startTime); err != nil {
+		return fmt.Errorf("delete audio variants: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// UnusableAudioFilter specifies filters for the unusable audio report.
+type UnusableAudioFilter struct {
+	SystemIDs []int
+	StartTime time.Time
+	EndTime   *time.Time
+}
+
+// RecorderUnusableAudio counts the unusable recordings of one recorder
+// (instance, site, and recorder number).
+type RecorderUnusableAudio struct {
+	InstanceID    string     `json:"instance_id"`
+	SystemID      int        `json:"system_id"`
+	SystemName    string     `json:"system_name,omitempty"`
+	SiteID        *int       `json:"site_id,omitempty"`
+	SiteShortName string     `json:"site_short_name,omitempty"`
+	RecNum        *int16     `json:"rec_num,omitempty"`
+	Calls         int        `json:"calls"`
+	UnusableCalls int        `json:"unusable_calls"`
+	Empty         int        `json:"empty"`
+	Silent        int        `json:"silent"`
+	UnusableRate  float64    `json:"unusable_rate"`
+	LastUnusable  *time.Time `json:"last_unusable,omitempty"`
+}
+
+// GetUnusableAudio groups calls by recorder and counts those flagged with
+// unusable (empty or silent) audio, most affected first. Recorders without
+// unusable calls are omitted.
+func (db *DB) GetUnusableAudio(ctx context.Context, filter UnusableAudioFilter) ([]RecorderUnusableAudio, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT COALESCE(c.instance_id, ''), c.system_id, COALESCE(s.name, ''),
+			c.site_id, COALESCE(st.short_name, ''), c.rec_num,
+			count(*)::int,
+			count(*) FILTER (WHERE c.audio_unusable IS NOT NULL)::int,
+			count(*) FILTER (WHERE c.audio_unusable = 'empty')::int,
+			count(*) FILTER (WHERE c.audio_unusable = 'silent')::int,
+			max(c.start_time) FILTER (WHERE c.audio_unusable IS NOT NULL)
+		FROM calls c
+		JOIN systems s ON s.system_id = c.system_id
+		LEFT JOIN sites st ON st.site_id = c.site_id
+		WHERE c.start_time >= $1
+		  AND ($2::timestamptz IS NULL OR c.start_time < $2)
+		  AND ($3::int[] IS NULL OR c.system_id = ANY($3))
+		GROUP BY c.instance_id, c.system_id, s.name, c.site_id, st.short_name, c.rec_num
+		HAVING count(*) FILTER (WHERE c.audio_unusable IS NOT NULL) > 0
+		ORDER BY count(*) FILTER (WHERE c.audio_unusable IS NOT NULL) DESC,
+			count(*) FILTER (WHERE c.audio_unusable IS NOT NULL)::float8 / count(*) DESC
+	`, filter.StartTime, filter.EndTime, pqIntArray(filter.SystemIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []RecorderUnusableAudio{}
+	for rows.Next() {
+		var r RecorderUnusableAudio
+		if err := rows.Scan(&r.InstanceID, &r.SystemID, &r.SystemName,
+			&r.SiteID, &r.SiteShortName, &r.RecNum,
+			&r.Calls, &r.UnusableCalls, &r.Empty, &r.Silent, &r.LastUnusable); err != nil {
+			return nil, err
+		}
+		if r.Calls > 0 {
+			r.UnusableRate = float64(r.UnusableCalls) / float64(r.Calls)
+		}
+		result = append(result, r)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,291 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// User roles (users.role), named like the API key scopes they match.
+const (
+	UserRoleRead  = "read"  // everything AUTH_TOKEN can do
+	UserRoleWrite = "write" // reads and writes outside /admin
+	UserRoleAdmin = "admin" // everything WRITE_TOKEN can do
+)
+
+// ValidUserRole reports whether s is a known user role.
+func ValidUserRole(s string) bool {
+	switch s {
+	case UserRoleRead, UserRoleWrite, UserRoleAdmin:
+		return true
+	}
+	return false
+}
+
+// UserPreferences are a user's web UI settings, stored as users.preferences.
+type UserPreferences struct {
+	PinnedTalkgroups []string                   `json:"pinned_talkgroups"` // "system_id:tgid"
+	DefaultFilters   map[string]json.RawMessage `json:"default_filters"`   // per page, as the page saves them
+}
+
+// User is one users row: a web UI account that signs in with a password and
+// gets the access of its role. Only a bcrypt hash of the password is stored.
+type User struct {
+	ID                int             `json:"id"`
+	Username          string          `json:"username"`
+	DisplayName       string          `json:"display_name"`
+	Role              string          `json:"role"`
+	Preferences       UserPreferences `json:"preferences"`
+	Disabled          bool            `json:"disabled"`
+	CreatedBy         string          `json:"created_by"`
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+	PasswordChangedAt time.Time       `json:"password_changed_at"`
+	LastLoginAt       *time.Time      `json:"last_login_at"`
+	LastLoginIP       *string         `json:"last_login_ip"`
+
+	PasswordHash string `json:"-"`
+}
+
+const userColumns = `u.id, u.username, u.display_name, u.role, u.preferences, u.disabled, u.created_by,
+	u.created_at, u.updated_at, u.password_changed_at, u.last_login_at, u.last_login_ip, u.password_hash`
+
+func scanUser(row pgx.Row, extra ...any) (*User, error) {
+	var u User
+	var prefs []byte
+	dest := append([]any{&u.ID, &u.Username, &u.DisplayName, &u.Role, &prefs, &u.Disabled, &u.CreatedBy,
+		&u.CreatedAt, &u.UpdatedAt, &u.PasswordChangedAt, &u.LastLoginAt, &u.LastLoginIP, &u.PasswordHash}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(prefs, &u.Preferences); err != nil {
+		return nil, fmt.Errorf("decode preferences: %w", err)
+	}
+	if u.Preferences.PinnedTalkgroups == nil {
+		u.Preferences.PinnedTalkgroups = []string{}
+	}
+	if u.Preferences.DefaultFilters == nil {
+		u.Preferences.DefaultFilters = map[string]json.RawMessage{}
+	}
+	return &u, nil
+}
+
+// usernameTaken reports whether err is a clash on the unique username.
+func usernameTaken(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "uq_users_username"
+}
+
+// ListUsers returns every user by username.
+func (db *DB) ListUsers(ctx context.Context) ([]User, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT `+userColumns+` FROM users u ORDER BY lower(u.username)`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, rows.Err()
+}
+
+// GetUser returns a user. Returns "user not found" if id is unknown.
+func (db *DB) GetUser(ctx context.Context, id int) (*User, error) {
+	u, err := scanUser(db.Pool.QueryRow(ctx, `SELECT `+userColumns+` FROM users u WHERE u.id = $1`, id))
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	}
+	return u, err
+}
+
+// GetUserByUsername returns a user by case-insensitive username. Returns
+// "user not found" if there is none.
+func (db *DB) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	u, err := scanUser(db.Pool.QueryRow(ctx,
+		`SELECT `+userColumns+` FROM users u WHERE lower(u.username) = lower($1)`, username))
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	}
+	return u, err
+}
+
+// CreateUser inserts a user from its username, display name, password hash,
+// role and creator, and fills in the rest. Returns "username already exists".
+func (db *DB) CreateUser(ctx context.Context, u *User) error {
+	created, err := scanUser(db.Pool.QueryRow(ctx, `
+		INSERT INTO users AS u (username, display_name, password_hash, role, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+userColumns,
+		u.Username, u.DisplayName, u.PasswordHash, u.Role, u.CreatedBy))
+	if usernameTaken(err) {
+		return fmt.Errorf("username already exists")
+	}
+	if err != nil {
+		return err
+	}
+	*u = *created
+	return nil
+}
+
+// UserUpdate holds the fields of a user that can be changed; nil fields are
+// left alone.
+type UserUpdate struct {
+	DisplayName  *string
+	Role         *string
+	PasswordHash *string
+	Disabled     *bool
+}
+
+// UpdateUser changes a user. A new password or disabling the user signs it
+// out everywhere. Returns "user not found".
+func (db *DB) UpdateUser(ctx context.Context, id int, upd UserUpdate) (*User, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	u, err := scanUser(tx.QueryRow(ctx, `
+		UPDATE users AS u SET
+			display_name        = COALESCE($2, display_name),
+			role                = COALESCE($3, role),
+			password_hash       = COALESCE($4, password_hash),
+			password_changed_at = CASE WHEN $4::text IS NULL THEN password_changed_at ELSE now() END,
+			disabled            = COALESCE($5, disabled),
+			updated_at          = now()
+		WHERE id = $1
+		RETURNING `+userColumns,
+		id, upd.DisplayName, upd.Role, upd.PasswordHash, upd.Disabled))
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if upd.PasswordHash != nil || u.Disabled {
+		if _, err := tx.Exec(ctx, `DELETE FROM user_sessions WHERE user_id = $1`, id); err != nil {
+			return nil, err
+		}
+	}
+	return u, tx.Commit(ctx)
+}
+
+// SetUserPreferences replaces a user's preferences. Returns "user not found".
+func (db *DB) SetUserPreferences(ctx context.Context, id int, prefs UserPreferences) (*User, error) {
+	b, err := json.Marshal(prefs)
+	if err != nil {
+		return nil, err
+	}
+	u, err := scanUser(db.Pool.QueryRow(ctx, `
+		UPDATE users AS u SET preferences = $2, updated_at = now()
+		WHERE id = $1
+		RETURNING `+userColumns,
+		id, b))
+	if err == pgx.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	}
+	return u, err
+}
+
+// DeleteUser removes a user and its sessions. Returns "user not found".
+func (db *DB) DeleteUser(ctx context.Context, id int) error {
+	tag, err := db.Pool.Exec(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+// RecordUserLogin notes a successful sign-in.
+func (db *DB) RecordUserLogin(ctx context.Context, id int, at time.Time, ip string) error {
+	_, err := db.Pool.Exec(ctx, `
+		UPDATE users SET last_login_at = $2, last_login_ip = NULLIF($3, '')
+		WHERE id = $1
+	`, id, at, ip)
+	return err
+}
+
+// UserSession is one user_sessions row: a signed-in browser. Only a hash of
+// the session cookie is stored; CSRFToken must accompany changes made with it.
+type UserSession struct {
+	ID         string    `json:"-"` // hex SHA-256 of the session cookie
+	UserID     int       `json:"user_id"`
+	CSRFToken  string    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+}
+
+// CreateUserSession inserts a session, and drops sessions that have expired.
+func (db *DB) CreateUserSession(ctx context.Context, s *UserSession) error {
+	if _, err := db.Pool.Exec(ctx, `DELETE FROM user_sessions WHERE expires_at < now()`); err != nil {
+		return err
+	}
+	return db.Pool.QueryRow(ctx, `
+		INSERT INTO user_sessions (id, user_id, csrf_token, expires_at, ip, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at, last_seen_at
+	`, s.ID, s.UserID, s.CSRFToken, s.ExpiresAt, s.IP, s.UserAgent).Scan(&s.CreatedAt, &s.LastSeenAt)
+}
+
+// GetUserSession returns an unexpired session and its user, if the user is
+// enabled. Returns "session not found" otherwise.
+func (db *DB) GetUserSession(ctx context.Context, id string) (*UserSession, *User, error) {
+	var s UserSession
+	u, err := scanUser(db.Pool.QueryRow(ctx, `
+		SELECT `+userColumns+`,
+			s.id, s.user_id, s.csrf_token, s.created_at, s.expires_at, s.last_seen_at,
+			COALESCE(s.ip, ''), COALESCE(s.user_agent, '')
+		FROM user_sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.id = $1 AND s.expires_at > now() AND NOT u.disabled
+	`, id), &s.ID, &s.UserID, &s.CSRFToken, &s.CreatedAt, &s.ExpiresAt, &s.LastSeenAt, &s.IP, &s.UserAgent)
+	if err == pgx.ErrNoRows {
+		return nil, nil, fmt.Errorf("session not found")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &s, u, nil
+}
+
+// TouchUserSession records a session's use and moves its expiry.
+func (db *DB) TouchUserSession(ctx context.Context, id string, at, expires time.Time) error {
+	_, err := db.Pool.Exec(ctx, `
+		UPDATE user_sessions SET last_seen_at = $2, expires_at = GREATEST(expires_at, $3)
+		WHERE id = $1
+	`, id, at, expires)
+	return err
+}
+
+// DeleteUserSession signs a session out.
+func (db *DB) DeleteUserSession(ctx context.Context, id string) error {
+	_, err := db.Pool.Exec(ctx, `DELETE FROM user_sessions WHERE id = $1`, id)
+	return err
+}
+
+// DeleteUserSessions signs a user out everywhere and returns the number of
+// sessions ended.
+func (db *DB) DeleteUserSessions(ctx context.Context, userID int) (int64, error) {
+	tag, err := db.Pool.Exec(ctx, `DELETE FROM user_sessions WHERE user_id = $1`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
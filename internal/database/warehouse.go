@@ -0,0 +1,265 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WarehouseColumn is one column of a warehouse dataset. Type is the value
+// type rows carry for it: int32, int64, float64, bool, string, json
+// (JSON text) or timestamp.
+type WarehouseColumn struct {
+	Name string
+	Type string
+	expr string
+}
+
+// WarehouseDataset is a table exported to the data warehouse one UTC day at
+// a time, partitioned on timeColumn.
+type WarehouseDataset struct {
+	Name       string
+	Columns    []WarehouseColumn
+	from       string
+	timeColumn string
+}
+
+func wcol(name, typ, expr string) WarehouseColumn {
+	return WarehouseColumn{Name: name, Type: typ, expr: expr}
+}
+
+// WarehouseDatasets are the exported datasets. Column names and types are
+// the warehouse's documented schema (docs/warehouse.md): add columns at the
+// end and never change an existing column's type.
+var WarehouseDatasets = []WarehouseDataset{
+	{
+		Name:       "calls",
+		from:       "calls c LEFT JOIN systems s ON s.system_id = c.system_id",
+		timeColumn: "c.start_time",
+		Columns: []WarehouseColumn{
+			wcol("call_id", "int64", "c.call_id"),
+			wcol("start_time", "timestamp", "c.start_time"),
+			wcol("stop_time", "timestamp", "c.stop_time"),
+			wcol("duration", "float64", "c.duration::float8"),
+			wcol("system_id", "int32", "c.system_id"),
+			wcol("system_name", "string", "c.system_name"),
+			wcol("sysid", "string", "s.sysid"),
+			wcol("site_id", "int32", "c.site_id"),
+			wcol("site_short_name", "string", "c.site_short_name"),
+			wcol("tgid", "int32", "c.tgid"),
+			wcol("tg_alpha_tag", "string", "c.tg_alpha_tag"),
+			wcol("tg_description", "string", "c.tg_description"),
+			wcol("tg_tag", "string", "c.tg_tag"),
+			wcol("tg_group", "string", "c.tg_group"),
+			wcol("call_num", "int32", "c.call_num"),
+			wcol("freq", "int64", "c.freq"),
+			wcol("freq_error", "int32", "c.freq_error"),
+			wcol("signal_db", "float64", "c.signal_db::float8"),
+			wcol("noise_db", "float64", "c.noise_db::float8"),
+			wcol("error_count", "int32", "c.error_count"),
+			wcol("spike_count", "int32", "c.spike_count"),
+			wcol("audio_type", "string", "c.audio_type"),
+			wcol("audio_file_path", "string", "c.audio_file_path"),
+			wcol("audio_file_size", "int32", "c.audio_file_size"),
+			wcol("audio_duration", "float64", "c.audio_duration::float8"),
+			wcol("emergency", "bool", "c.emergency"),
+			wcol("encrypted", "bool", "c.encrypted"),
+			wcol("analog", "bool", "c.analog"),
+			wcol("conventional", "bool", "c.conventional"),
+			wcol("phase2_tdma", "bool", "c.phase2_tdma"),
+			wcol("tdma_slot", "int32", "c.tdma_slot::int"),
+			wcol("interconnect", "bool", "c.interconnect"),
+			wcol("patched_tgids", "json", "array_to_json(c.patched_tgids)::text"),
+			wcol("unit_ids", "json", "array_to_json(c.unit_ids)::text"),
+			wcol("src_list", "json", "c.src_list::text"),
+			wcol("freq_list", "json", "c.freq_list::text"),
+			wcol("has_transcription", "bool", "c.has_transcription"),
+			wcol("transcription_status", "string", "c.transcription_status"),
+			wcol("transcription_word_count", "int32", "c.transcription_word_count"),
+			wcol("instance_id", "string", "c.instance_id"),
+			wcol("metadata_json", "json", "c.metadata_json::text"),
+		},
+	},
+	{
+		Name:       "unit_events",
+		from:       "unit_events e",
+		timeColumn: `e."time"`,
+		Columns: []WarehouseColumn{
+			wcol("id", "int64", "e.id"),
+			wcol("time", "timestamp", `e."time"`),
+			wcol("event_type", "string", "e.event_type"),
+			wcol("system_id", "int32", "e.system_id"),
+			wcol("sys_name", "string", "e.sys_name"),
+			wcol("unit_id", "int32", "e.unit_rid"),
+			wcol("unit_alpha_tag", "string", "e.unit_alpha_tag"),
+			wcol("tgid", "int32", "e.tgid"),
+			wcol("tg_alpha_tag", "string", "e.tg_alpha_tag"),
+			wcol("call_num", "int32", "e.call_num"),
+			wcol("freq", "int64", "e.freq"),
+			wcol("start_time", "timestamp", "e.start_time"),
+			wcol("stop_time", "timestamp", "e.stop_time"),
+			wcol("encrypted", "bool", "e.encrypted"),
+			wcol("emergency", "bool", "e.emergency"),
+			wcol("position", "float64", `e."position"::float8`),
+			wcol("length", "float64", "e.length::float8"),
+			wcol("error_count", "int32", "e.error_count"),
+			wcol("spike_count", "int32", "e.spike_count"),
+			wcol("sample_count", "int32", "e.sample_count"),
+			wcol("talkgroup_patches", "json", "array_to_json(e.talkgroup_patches)::text"),
+			wcol("instance_id", "string", "e.instance_id"),
+		},
+	},
+	{
+		Name:       "transcriptions",
+		from:       "transcriptions t JOIN calls c ON c.call_id = t.call_id AND c.start_time = t.call_start_time",
+		timeColumn: "t.call_start_time",
+		Columns: []WarehouseColumn{
+			wcol("id", "int64", "t.id::int8"),
+			wcol("call_id", "int64", "t.call_id"),
+			wcol("call_start_time", "timestamp", "t.call_start_time"),
+			wcol("system_id", "int32", "c.system_id"),
+			wcol("tgid", "int32", "c.tgid"),
+			wcol("tg_alpha_tag", "string", "c.tg_alpha_tag"),
+			wcol("source", "string", "t.source"),
+			wcol("is_primary", "bool", "t.is_primary"),
+			wcol("text", "string", "t.text"),
+			wcol("word_count", "int32", "t.word_count"),
+			wcol("confidence", "float64", "t.confidence::float8"),
+			wcol("language", "string", "t.language"),
+			wcol("model", "string", "t.model"),
+			wcol("provider", "string", "t.provider"),
+			wcol("duration_ms", "int32", "t.duration_ms"),
+			wcol("urgency_label", "string", "t.urgency_label"),
+			wcol("urgency_score", "float64", "t.urgency_score::float8"),
+			wcol("created_at", "timestamp", "t.created_at"),
+		},
+	},
+	{
+		Name:       "call_annotations",
+		from:       "call_annotations a JOIN calls c ON c.call_id = a.call_id AND c.start_time = a.call_start_time",
+		timeColumn: "a.call_start_time",
+		Columns: []WarehouseColumn{
+			wcol("id", "int64", "a.id"),
+			wcol("call_id", "int64", "a.call_id"),
+			wcol("call_start_time", "timestamp", "a.call_start_time"),
+			wcol("system_id", "int32", "c.system_id"),
+			wcol("tgid", "int32", "c.tgid"),
+			wcol("tg_alpha_tag", "string", "c.tg_alpha_tag"),
+			wcol("type", "string", "a.type"),
+			wcol("source", "string", "a.source"),
+			wcol("label", "string", "a.label"),
+			wcol("confidence", "float64", "a.confidence::float8"),
+			wcol("data", "json", "a.data::text"),
+			wcol("created_at", "timestamp", "a.created_at"),
+		},
+	},
+}
+
+// ScanWarehouseDay calls fn with each row of ds in [start, end), in time
+// order. Values line up with ds.Columns; NULLs are nil.
+func (db *DB) ScanWarehouseDay(ctx context.Context, ds WarehouseDataset, start, end time.Time, fn func(values []any) error) error {
+	exprs := make([]string, len(ds.Columns))
+	for i, c := range ds.Columns {
+		exprs[i] = c.expr
+	}
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE %s >= $1 AND %s < $2 ORDER BY %s`,
+		strings.Join(exprs, ", "), ds.from, ds.timeColumn, ds.timeColumn, ds.timeColumn)
+
+	rows, err := db.Pool.Query(ctx, query, start, end)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return err
+		}
+		if err := fn(values); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// WarehouseDataStart returns the start of the oldest call, or nil if there
+// are none. Exports begin at its UTC day.
+func (db *DB) WarehouseDataStart(ctx context.Context) (*time.Time, error) {
+	var t *time.Time
+	err := db.Pool.QueryRow(ctx, `SELECT min(start_time) FROM calls`).Scan(&t)
+	return t, err
+}
+
+// WarehouseExport records one dataset-day written to the warehouse.
+type WarehouseExport struct {
+	Dataset    string    `json:"dataset"`
+	Day        string    `json:"day"` // YYYY-MM-DD, UTC
+	Rows       int64     `json:"rows"`
+	Bytes      int64     `json:"bytes"`
+	Path       string    `json:"path,omitempty"` // empty for days with no rows
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+// RecordWarehouseExport saves (or replaces) the record of an exported day.
+func (db *DB) RecordWarehouseExport(ctx context.Context, e WarehouseExport) error {
+	_, err := db.Pool.Exec(ctx, `
+		INSERT INTO warehouse_exports (dataset, day, row_count, size_bytes, path, exported_at)
+		VALUES ($1, $2::date, $3, $4, $5, now())
+		ON CONFLICT (dataset, day) DO UPDATE SET
+			row_count   = EXCLUDED.row_count,
+			size_bytes  = EXCLUDED.size_bytes,
+			path        = EXCLUDED.path,
+			exported_at = now()
+	`, e.Dataset, e.Day, e.Rows, e.Bytes, e.Path)
+	return err
+}
+
+// WarehouseExportedDays returns the days (YYYY-MM-DD) on or after since
+// already exported for dataset.
+func (db *DB) WarehouseExportedDays(ctx context.Context, dataset string, since time.Time) (map[string]bool, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT to_char(day, 'YYYY-MM-DD') FROM warehouse_exports
+		WHERE dataset = $1 AND day >= ($2::timestamptz AT TIME ZONE 'UTC')::date
+	`, dataset, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := make(map[string]bool)
+	for rows.Next() {
+		var d string
+		if err := rows.Scan(&d); err != nil {
+			return nil, err
+		}
+		days[d] = true
+	}
+	return days, rows.Err()
+}
+
+// ListWarehouseExports returns exported days, most recent first, optionally
+// for one dataset.
+func (db *DB) ListWarehouseExports(ctx context.Context, dataset string, limit int) ([]WarehouseExport, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT dataset, to_char(day, 'YYYY-MM-DD'), row_count, size_bytes, path, exported_at
+		FROM warehouse_exports
+		WHERE ($1 = '' OR dataset = $1)
+		ORDER BY day DESC, dataset
+		LIMIT $2
+	`, dataset, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []WarehouseExport{}
+	for rows.Next() {
+		var e WarehouseExport
+		if err := rows.Scan(&e.Dataset, &e.Day, &e.Rows, &e.Bytes, &e.Path, &e.ExportedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// Outcomes of a file-watch backfill attempt (watch_backfill_files.status).
+const (
+	BackfillImported = "imported"
+	BackfillSkipped  = "skipped"
+	BackfillError    = "error"
+)
+
+// BackfillAlreadyIngested is the skip reason for a file whose call is already
+// in the database. It never downgrades a file recorded as imported, so
+// re-running backfill after a restart keeps the original outcome.
+const BackfillAlreadyIngested = "already_ingested"
+
+// WatchBackfillFile is the outcome of the last backfill attempt on one file.
+type WatchBackfillFile struct {
+	Path       string    `json:"path"`
+	Day        string    `json:"day"` // UTC date of the call's start time
+	InstanceID string    `json:"instance_id"`
+	Status     string    `json:"status"`
+	Reason     string    `json:"reason,omitempty"`
+	Detail     string    `json:"detail,omitempty"`
+	Attempts   int       `json:"attempts"`
+	FirstSeen  time.Time `json:"first_seen"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// WatchBackfillDay is the backfill report for one day of files.
+type WatchBackfillDay struct {
+	Day      string         `json:"day"`
+	Found    int            `json:"found"`
+	Imported int            `json:"imported"`
+	Skipped  int            `json:"skipped"`
+	Errors   int            `json:"errors"`
+	Reasons  map[string]int `json:"reasons,omitempty"` // skip and error reasons
+}
+
+// WatchBackfillFilter selects files for ListWatchBackfillFiles.
+type WatchBackfillFilter struct {
+	StartDay *time.Time // day >= StartDay
+	EndDay   *time.Time // day <= EndDay
+	Status   string
+	Limit    int
+	Offset   int
+}
+
+// RecordWatchBackfillFile stores the outcome of a backfill attempt on a file,
+// replacing the previous one.
+func (db *DB) RecordWatchBackfillFile(ctx context.Context, instanceID, path string, startTime time.Time, status, reason, detail string) error {
+	_, err := db.Pool.Exec(ctx, `
+		INSERT INTO watch_backfill_files (path, day, instance_id, status, reason, detail)
+		VALUES ($1, ($2::timestamptz AT TIME ZONE 'UTC')::date, $3, $4, $5, NULLIF($6, ''))
+		ON CONFLICT (path) DO UPDATE SET
+			day = EXCLUDED.day,
+			instance_id = EXCLUDED.instance_id,
+			status = CASE WHEN watch_backfill_files.status = 'imported' AND EXCLUDED.reason = $7
+				THEN watch_backfill_files.status ELSE EXCLUDED.status END,
+			reason = CASE WHEN watch_backfill_files.status = 'imported' AND EXCLUDED.reason = $7
+				THEN watch_backfill_files.reason ELSE EXCLUDED.reason END,
+			detail = EXCLUDED.detail,
+			attempts = watch_backfill_files.attempts + 1,
+			updated_at = now()
+	`, path, startTime, instanceID, status, reason, detail, BackfillAlreadyIngested)
+	return err
+}
+
+// WatchBackfillReport returns per-day counts of backfilled files, oldest day
+// first, within the optional day range.
+func (db *DB) WatchBackfillReport(ctx context.Context, startDay, endDay *time.Time) ([]WatchBackfillDay, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT day, status, reason, count(*)
+		FROM watch_backfill_files
+		WHERE ($1::date IS NULL OR day >= $1::date)
+		  AND ($2::date IS NULL OR day <= $2::date)
+		GROUP BY day, status, reason
+		ORDER BY day
+	`, startDay, endDay)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []backfillCount
+	for rows.Next() {
+		var c backfillCount
+		var day time.Time
+		if err := rows.Scan(&day, &c.status, &c.reason, &c.n); err != nil {
+			return nil, err
+		}
+		c.day = day.Format("2006-01-02")
+		counts = append(counts, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return summarizeBackfill(counts), nil
+}
+
+type backfillCount struct {
+	day, status, reason string
+	n                   int
+}
+
+// summarizeBackfill folds (day, status, reason) counts, ordered by day, into
+// one report row per day.
+func summarizeBackfill(counts []backfillCount) []WatchBackfillDay {
+	days := []WatchBackfillDay{}
+	for _, c := range counts {
+		if len(days) == 0 || days[len(days)-1].Day != c.day {
+			days = append(days, WatchBackfillDay{Day: c.day})
+		}
+		d := &days[len(days)-1]
+		d.Found += c.n
+		switch c.status {
+		case BackfillImported:
+			d.Imported += c.n
+			continue
+		case BackfillSkipped:
+			d.Skipped += c.n
+		case BackfillError:
+			d.Errors += c.n
+		}
+		if c.reason != "" {
+			if d.Reasons == nil {
+				d.Reasons = make(map[string]int)
+			}
+			d.Reasons[c.reason] += c.n
+		}
+	}
+	return days
+}
+
+// ListWatchBackfillFiles returns backfilled files matching the filter, oldest
+// day first, and the total count.
+func (db *DB) ListWatchBackfillFiles(ctx context.Context, filter WatchBackfillFilter) ([]WatchBackfillFile, int, error) {
+	const where = `
+		WHERE ($1::date IS NULL OR day >= $1::date)
+		  AND ($2::date IS NULL OR day <= $2::date)
+		  AND ($3 = '' OR status = $3)`
+
+	var total int
+	if err := db.Pool.QueryRow(ctx, `SELECT count(*) FROM watch_backfill_files`+where,
+		filter.StartDay, filter.EndDay, filter.Status).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Pool.Query(ctx, `
+		SELECT path, day, instance_id, status, reason, COALESCE(detail, ''), attempts, first_seen, updated_at
+		FROM watch_backfill_files`+where+`
+		ORDER BY day, path
+		LIMIT $4 OFFSET $5
+	`, filter.StartDay, filter.EndDay, filter.Status, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	files := []WatchBackfillFile{}
+	for rows.Next() {
+		var f WatchBackfillFile
+		var day time.Time
+		if err := rows.Scan(&f.Path, &day, &f.InstanceID, &f.Status, &f.Reason, &f.Detail,
+			&f.Attempts, &f.FirstSeen, &f.UpdatedAt); err != nil {
+			return nil, 0, err
+		}
+		f.Day = day.Format("2006-01-02")
+		files = append(files, f)
+	}
+	return files, total, rows.Err()
+}
@@ -0,0 +1,30 @@
+package database
+
+import "testing"
+
+func TestSummarizeBackfill(t *testing.T) {
+	days := summarizeBackfill([]backfillCount{
+		{"2026-10-01", BackfillImported, "", 40},
+		{"2026-10-01", BackfillSkipped, BackfillAlreadyIngested, 7},
+		{"2026-10-01", BackfillSkipped, "no_talkgroup", 2},
+		{"2026-10-01", BackfillError, "invalid_json", 1},
+		{"2026-10-02", BackfillImported, "", 12},
+	})
+	if len(days) != 2 {
+		t.Fatalf("got %d days, want 2", len(days))
+	}
+	d := days[0]
+	if d.Day != "2026-10-01" || d.Found != 50 || d.Imported != 40 || d.Skipped != 9 || d.Errors != 1 {
+		t.Errorf("day 1 = %+v", d)
+	}
+	if len(d.Reasons) != 3 || d.Reasons[BackfillAlreadyIngested] != 7 || d.Reasons["invalid_json"] != 1 {
+		t.Errorf("reasons = %v", d.Reasons)
+	}
+	if days[1].Found != 12 || days[1].Reasons != nil {
+		t.Errorf("day 2 = %+v", days[1])
+	}
+
+	if got := summarizeBackfill(nil); got == nil || len(got) != 0 {
+		t.Errorf("empty report = %#v, want empty slice", got)
+	}
+}
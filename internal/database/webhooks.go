@@ -0,0 +1,344 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Webhook delivery statuses.
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookFailed    = "failed"
+)
+
+// WebhookEventTypes are the event types a webhook can subscribe to.
+var WebhookEventTypes = []string{"call_end", "transcription", "alert"}
+
+// WebhookFormats are the request body formats: the event envelope as JSON,
+// a one-line chat message for Discord or Slack, or the webhook's Template.
+var WebhookFormats = []string{"generic", "discord", "slack", "template"}
+
+// Webhook is one webhooks row: EventTypes on SystemID (every system when
+// nil) and Tgids (every talkgroup when empty) are POSTed to URL as Format
+// ("generic", "discord", "slack", or "template" to render Template), signed
+// with Secret when it is set.
+type Webhook struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	URL        string    `json:"url"`
+	Format     string    `json:"format"`
+	Template   string    `json:"template,omitempty"`
+	EventTypes []string  `json:"event_types"`
+	SystemID   *int      `json:"system_id"`
+	Tgids      []int     `json:"tgids"`
+	Secret     string    `json:"-"`
+	HasSecret  bool      `json:"has_secret"`
+	Enabled    bool      `json:"enabled"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Matches reports whether the hook wants an event of this type on a
+// system and talkgroup.
+func (h *Webhook) Matches(eventType string, systemID, tgid int) bool {
+	if h.SystemID != nil && *h.SystemID != systemID {
+		return false
+	}
+	wanted := false
+	for _, t := range h.EventTypes {
+		if t == eventType {
+			wanted = true
+			break
+		}
+	}
+	if !wanted {
+		return false
+	}
+	if len(h.Tgids) == 0 {
+		return true
+	}
+	for _, t := range h.Tgids {
+		if t == tgid {
+			return true
+		}
+	}
+	return false
+}
+
+const webhookColumns = `id, name, url, format, template, event_types, system_id, tgids, secret, enabled, created_at, updated_at`
+
+func scanWebhook(row pgx.Row, h *Webhook) error {
+	err := row.Scan(&h.ID, &h.Name, &h.URL, &h.Format, &h.Template, &h.EventTypes, &h.SystemID, &h.Tgids, &h.Secret,
+		&h.Enabled, &h.CreatedAt, &h.UpdatedAt)
+	h.HasSecret = h.Secret != ""
+	return err
+}
+
+// ListWebhooks returns all webhooks in ID order.
+func (db *DB) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT `+webhookColumns+` FROM webhooks ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	hooks := []Webhook{}
+	for rows.Next() {
+		var h Webhook
+		if err := scanWebhook(rows, &h); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, h)
+	}
+	return hooks, rows.Err()
+}
+
+// GetWebhook returns one webhook, or "webhook not found".
+func (db *DB) GetWebhook(ctx context.Context, id int) (*Webhook, error) {
+	var h Webhook
+	err := scanWebhook(db.Pool.QueryRow(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id = $1`, id), &h)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("webhook not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// CreateWebhook stores a new webhook and returns its ID. Returns "webhook
+// name already exists" on a duplicate name.
+func (db *DB) CreateWebhook(ctx context.Context, h *Webhook) (int, error) {
+	var id int
+	err := db.Pool.QueryRow(ctx, `
+		INSERT INTO webhooks (name, url, format, event_types, system_id, tgids, secret, enabled, template)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`, h.Name, h.URL, h.Format, h.EventTypes, h.SystemID, h.Tgids, h.Secret, h.Enabled, h.Template).Scan(&id)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return 0, fmt.Errorf("webhook name already exists")
+	}
+	return id, err
+}
+
+// UpdateWebhook replaces a webhook's settings, and its secret when
+// setSecret. Returns "webhook not found" or "webhook name already exists".
+func (db *DB) UpdateWebhook(ctx context.Context, h *Webhook, setSecret bool) error {
+	tag, err := db.Pool.Exec(ctx, `
+		UPDATE webhooks
+		SET name = $2, url = $3, format = $4, event_types = $5, system_id = $6, tgids = $7,
+			secret = CASE WHEN $8 THEN $9 ELSE secret END, enabled = $10, template = $11, updated_at = now()
+		WHERE id = $1
+	`, h.ID, h.Name, h.URL, h.Format, h.EventTypes, h.SystemID, h.Tgids, setSecret, h.Secret, h.Enabled, h.Template)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return fmt.Errorf("webhook name already exists")
+	}
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("webhook not found")
+	}
+	return nil
+}
+
+// DeleteWebhook removes a webhook and its delivery log.
+func (db *DB) DeleteWebhook(ctx context.Context, id int) error {
+	tag, err := db.Pool.Exec(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("webhook not found")
+	}
+	return nil
+}
+
+// WebhookDelivery is one webhook_deliveries row: an event POSTed, or still
+// to be POSTed, to a webhook.
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int             `json:"webhook_id"`
+	WebhookName    string          `json:"webhook_name"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload,omitempty"` // only in GetWebhookDelivery
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	ResponseStatus *int            `json:"response_status"`
+	LastError      *string         `json:"last_error"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+// InsertWebhookDelivery queues a delivery, first attempted at NextAttemptAt
+// (now when zero).
+func (db *DB) InsertWebhookDelivery(ctx context.Context, d *WebhookDelivery) error {
+	var next *time.Time
+	if !d.NextAttemptAt.IsZero() {
+		next = &d.NextAttemptAt
+	}
+	return db.Pool.QueryRow(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, next_attempt_at)
+		VALUES ($1, $2, $3, $4, COALESCE($5, now()))
+		RETURNING id, status, next_attempt_at, created_at
+	`, d.WebhookID, d.EventID, d.EventType, d.Payload, next).Scan(&d.ID, &d.Status, &d.NextAttemptAt, &d.CreatedAt)
+}
+
+// DueWebhookDelivery is a pending delivery whose next attempt is due, with
+// where to send it.
+type DueWebhookDelivery struct {
+	ID        int64
+	WebhookID int
+	EventID   string
+	EventType string
+	Payload   []byte
+	Attempts  int
+	URL       string
+	Secret    string
+}
+
+// ListDueWebhookDeliveries returns up to limit pending deliveries to
+// enabled webhooks whose next attempt is due, oldest first.
+func (db *DB) ListDueWebhookDeliveries(ctx context.Context, limit int) ([]DueWebhookDelivery, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.attempts, w.url, w.secret
+		FROM webhook_deliveries d
+		JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.status = 'pending' AND d.next_attempt_at <= now() AND w.enabled
+		ORDER BY d.next_attempt_at, d.id
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var due []DueWebhookDelivery
+	for rows.Next() {
+		var d DueWebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Payload, &d.Attempts,
+			&d.URL, &d.Secret); err != nil {
+			return nil, err
+		}
+		due = append(due, d)
+	}
+	return due, rows.Err()
+}
+
+// RecordWebhookAttempt records one delivery attempt: its HTTP status (nil
+// when there was no response), error ("" on success) and the delivery's new
+// status. A pending delivery is tried again at next.
+func (db *DB) RecordWebhookAttempt(ctx context.Context, id int64, status string, responseStatus *int, errMsg string, next time.Time) error {
+	_, err := db.Pool.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET attempts = attempts + 1, status = $2, response_status = $3, last_error = NULLIF($4, ''),
+			next_attempt_at = $5,
+			delivered_at = CASE WHEN $2 = 'delivered' THEN now() END
+		WHERE id = $1
+	`, id, status, responseStatus, errMsg, next)
+	return err
+}
+
+// WebhookDeliveryFilter selects deliveries by when they were queued.
+type WebhookDeliveryFilter struct {
+	WebhookIDs []int
+	Statuses   []string
+	EventTypes []string
+	Start      *time.Time
+	End        *time.Time
+	Limit      int
+	Offset     int
+}
+
+const webhookDeliveryColumns = `d.id, d.webhook_id, w.name, d.event_id, d.event_type, d.status, d.attempts,
+	d.next_attempt_at, d.response_status, d.last_error, d.created_at, d.delivered_at`
+
+func scanWebhookDelivery(row pgx.Row, d *WebhookDelivery, extra ...any) error {
+	return row.Scan(append([]any{&d.ID, &d.WebhookID, &d.WebhookName, &d.EventID, &d.EventType, &d.Status,
+		&d.Attempts, &d.NextAttemptAt, &d.ResponseStatus, &d.LastError, &d.CreatedAt, &d.DeliveredAt}, extra...)...)
+}
+
+// ListWebhookDeliveries returns deliveries matching the filter, newest
+// first, with the total count. Payloads are left out.
+func (db *DB) ListWebhookDeliveries(ctx context.Context, f WebhookDeliveryFilter) ([]WebhookDelivery, int, error) {
+	const from = `
+		FROM webhook_deliveries d
+		JOIN webhooks w ON w.id = d.webhook_id`
+	where := `
+		WHERE ($1::int[] IS NULL OR d.webhook_id = ANY($1))
+		  AND ($2::text[] IS NULL OR d.status = ANY($2))
+		  AND ($3::text[] IS NULL OR d.event_type = ANY($3))
+		  AND ($4::timestamptz IS NULL OR d.created_at >= $4)
+		  AND ($5::timestamptz IS NULL OR d.created_at < $5)`
+	args := []any{pqIntArray(f.WebhookIDs), pqStringArray(f.Statuses), pqStringArray(f.EventTypes), f.Start, f.End}
+
+	var total int
+	if err := db.Pool.QueryRow(ctx, `SELECT count(*)`+from+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := db.Pool.Query(ctx, `SELECT `+webhookDeliveryColumns+from+where+`
+		ORDER BY d.created_at DESC, d.id DESC
+		LIMIT $6 OFFSET $7`, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := scanWebhookDelivery(rows, &d); err != nil {
+			return nil, 0, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, total, rows.Err()
+}
+
+// GetWebhookDelivery returns one delivery with its payload, or "webhook
+// delivery not found".
+func (db *DB) GetWebhookDelivery(ctx context.Context, id int64) (*WebhookDelivery, error) {
+	var d WebhookDelivery
+	err := scanWebhookDelivery(db.Pool.QueryRow(ctx, `SELECT `+webhookDeliveryColumns+`, d.payload
+		FROM webhook_deliveries d
+		JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.id = $1`, id), &d, &d.Payload)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("webhook delivery not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// RetryWebhookDelivery puts a failed delivery back in the queue with a fresh
+// set of attempts. Returns "webhook delivery not found" or "webhook delivery
+// has not failed".
+func (db *DB) RetryWebhookDelivery(ctx context.Context, id int64) (*WebhookDelivery, error) {
+	tag, err := db.Pool.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = now()
+		WHERE id = $1 AND status = 'failed'
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	d, err := db.GetWebhookDelivery(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, fmt.Errorf("webhook delivery has not failed")
+	}
+	return d, nil
+}
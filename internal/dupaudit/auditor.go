@@ -0,0 +1,292 @@
+// Package dupaudit finds likely duplicate calls that ingest didn't group.
+//
+// Ingest groups calls recorded at several sites by (system, talkgroup,
+// start time), so copies whose start times differ by a second or two, or
+// that TR recorded twice, end up as separate calls and are counted twice in
+// statistics. Once a night the auditor pairs up calls on the same system
+// and talkgroup that overlap in time but sit in different groups, scores
+// each pair (Score) and records those above a minimum confidence in
+// duplicate_call_findings for review. Pairs at or above the auto-group
+// threshold are grouped right away: the later call is moved into the
+// earlier call's group. Every grouping, dismissal and ungrouping is logged
+// in duplicate_call_actions.
+package dupaudit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// Options configures an Auditor.
+type Options struct {
+	Hour          int           // local hour of the nightly run (0-23)
+	Lookback      time.Duration // a nightly run covers calls that started this long ago until now
+	MaxStartGap   time.Duration // calls starting further apart are never paired
+	MinConfidence float64       // pairs scoring lower are not recorded
+	AutoGroup     float64       // pairs scoring at least this are grouped automatically; 0 = off
+}
+
+// Signals are the measurements a pair is scored on.
+type Signals struct {
+	StartGap      float64 // seconds between the starts
+	OverlapRatio  float64 // overlap / the longer call's duration
+	DurationRatio float64 // the shorter call's duration / the longer's
+	SameUnit      *bool   // initiating units match; nil when either is unknown
+}
+
+// Measure computes a pair's signals.
+func Measure(p database.DuplicatePair) Signals {
+	var s Signals
+	s.StartGap = p.Dup.StartTime.Sub(p.Call.StartTime).Seconds()
+	a, b := math.Max(p.CallDuration, 0), math.Max(p.DupDuration, 0)
+	longer, shorter := math.Max(a, b), math.Min(a, b)
+	if longer > 0 {
+		end := math.Min(a, s.StartGap+b) // both measured from the call's start
+		s.OverlapRatio = math.Max(end-s.StartGap, 0) / longer
+		s.DurationRatio = shorter / longer
+	} else {
+		// Zero-length calls (no audio) that start together
+		s.OverlapRatio, s.DurationRatio = 1, 1
+	}
+	if p.CallUnit != 0 && p.DupUnit != 0 {
+		same := p.CallUnit == p.DupUnit
+		s.SameUnit = &same
+	}
+	return s
+}
+
+// Score rates how likely a pair is the same transmission, from 0 to 1.
+// Overlap counts most, then how close the starts are and how alike the
+// durations; a shared initiating unit raises the score and different units
+// cut it sharply.
+func Score(s Signals, maxStartGap time.Duration) float64 {
+	startScore := 1.0
+	if g := maxStartGap.Seconds(); g > 0 {
+		startScore = math.Max(1-s.StartGap/g, 0)
+	}
+	score := 0.5*s.OverlapRatio + 0.3*startScore + 0.2*s.DurationRatio
+	if s.SameUnit != nil {
+		if *s.SameUnit {
+			score += (1 - score) / 2
+		} else {
+			score *= 0.4
+		}
+	}
+	return math.Round(math.Min(math.Max(score, 0), 1)*1000) / 1000
+}
+
+// RunResult summarizes one audit run.
+type RunResult struct {
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	From       time.Time  `json:"from"`
+	To         time.Time  `json:"to"`
+	Pairs      int        `json:"pairs"`    // candidate pairs scored
+	Findings   int        `json:"findings"` // recorded (at or above min_confidence)
+	Grouped    int        `json:"grouped"`  // auto-grouped
+	Error      string     `json:"error,omitempty"`
+}
+
+// Status reports the auditor's configuration and progress.
+type Status struct {
+	Hour          int                             `json:"hour"`
+	Lookback      string                          `json:"lookback"`
+	MaxStartGap   string                          `json:"max_start_gap"`
+	MinConfidence float64                         `json:"min_confidence"`
+	AutoGroup     float64                         `json:"auto_group"`
+	NextRun       time.Time                       `json:"next_run"`
+	Running       bool                            `json:"running"`
+	Findings      database.DuplicateFindingCounts `json:"findings"`
+	LastRun       *RunResult                      `json:"last_run"`
+}
+
+// Auditor runs the duplicate call audit nightly and on demand.
+type Auditor struct {
+	db   *database.DB
+	opts Options
+	log  zerolog.Logger
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+
+	running atomic.Bool
+
+	mu      sync.Mutex
+	lastRun *RunResult
+}
+
+// New creates an auditor.
+func New(db *database.DB, opts Options, log zerolog.Logger) *Auditor {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Auditor{
+		db:     db,
+		opts:   opts,
+		log:    log.With().Str("component", "dupaudit").Logger(),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (a *Auditor) Start() { go a.loop() }
+
+// Stop ends background work, including a run in progress.
+func (a *Auditor) Stop() { a.stopOnce.Do(a.cancel) }
+
+// nextRun returns the next time the nightly run is due after now.
+func (a *Auditor) nextRun(now time.Time) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), a.opts.Hour, 0, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+func (a *Auditor) loop() {
+	for {
+		timer := time.NewTimer(time.Until(a.nextRun(time.Now())))
+		select {
+		case <-timer.C:
+			if a.running.CompareAndSwap(false, true) {
+				now := time.Now()
+				a.run(now.Add(-a.opts.Lookback), now)
+				a.running.Store(false)
+			}
+		case <-a.ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// Run audits calls that started in [from, to) now, in the background. A
+// zero to is now and a zero from is Lookback before to. Returns an error if
+// a run is already in progress.
+func (a *Auditor) Run(from, to time.Time) error {
+	if !a.running.CompareAndSwap(false, true) {
+		return fmt.Errorf("duplicate audit already in progress")
+	}
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.Add(-a.opts.Lookback)
+	}
+	go func() {
+		defer a.running.Store(false)
+		a.run(from, to)
+	}()
+	return nil
+}
+
+// run audits one range. The caller holds running.
+func (a *Auditor) run(from, to time.Time) {
+	res := &RunResult{StartedAt: time.Now(), From: from, To: to}
+	a.mu.Lock()
+	a.lastRun = res
+	a.mu.Unlock()
+
+	err := a.audit(res)
+
+	now := time.Now()
+	a.mu.Lock()
+	res.FinishedAt = &now
+	if err != nil {
+		res.Error = err.Error()
+	}
+	a.mu.Unlock()
+
+	if err != nil {
+		a.log.Warn().Err(err).Int("findings", res.Findings).Msg("duplicate audit failed")
+		return
+	}
+	a.log.Info().Int("pairs", res.Pairs).Int("findings", res.Findings).Int("grouped", res.Grouped).
+		Msg("duplicate audit complete")
+}
+
+func (a *Auditor) audit(res *RunResult) error {
+	ctx, cancel := context.WithTimeout(a.ctx, 30*time.Minute)
+	defer cancel()
+
+	pairs, err := a.db.ListDuplicatePairs(ctx, res.From, res.To, a.opts.MaxStartGap)
+	if err != nil {
+		return fmt.Errorf("list pairs: %w", err)
+	}
+	a.mu.Lock()
+	res.Pairs = len(pairs)
+	a.mu.Unlock()
+
+	for _, p := range pairs {
+		s := Measure(p)
+		conf := Score(s, a.opts.MaxStartGap)
+		if conf < a.opts.MinConfidence {
+			continue
+		}
+		id, err := a.db.InsertDuplicateFinding(ctx, database.DuplicateFinding{
+			SystemID:      p.SystemID,
+			Tgid:          p.Tgid,
+			CallID:        p.Call.CallID,
+			CallStartTime: p.Call.StartTime,
+			DupCallID:     p.Dup.CallID,
+			DupStartTime:  p.Dup.StartTime,
+			Confidence:    conf,
+			StartGap:      s.StartGap,
+			OverlapRatio:  s.OverlapRatio,
+			DurationRatio: s.DurationRatio,
+			SameUnit:      s.SameUnit,
+		})
+		if err != nil {
+			return fmt.Errorf("record finding: %w", err)
+		}
+		if id == 0 {
+			continue
+		}
+		a.mu.Lock()
+		res.Findings++
+		a.mu.Unlock()
+
+		if a.opts.AutoGroup > 0 && conf >= a.opts.AutoGroup {
+			if _, err := a.db.GroupDuplicate(ctx, id, "auto"); err != nil {
+				a.log.Warn().Err(err).Int64("finding_id", id).Msg("failed to auto-group duplicate")
+				continue
+			}
+			a.mu.Lock()
+			res.Grouped++
+			a.mu.Unlock()
+		}
+	}
+	return nil
+}
+
+// Status returns the configuration, finding counts and run progress.
+func (a *Auditor) Status(ctx context.Context) (Status, error) {
+	counts, err := a.db.CountDuplicateFindings(ctx)
+	if err != nil {
+		return Status{}, err
+	}
+	s := Status{
+		Hour:          a.opts.Hour,
+		Lookback:      a.opts.Lookback.String(),
+		MaxStartGap:   a.opts.MaxStartGap.String(),
+		MinConfidence: a.opts.MinConfidence,
+		AutoGroup:     a.opts.AutoGroup,
+		NextRun:       a.nextRun(time.Now()),
+		Running:       a.running.Load(),
+		Findings:      counts,
+	}
+	a.mu.Lock()
+	if a.lastRun != nil {
+		lr := *a.lastRun
+		s.LastRun = &lr
+	}
+	a.mu.Unlock()
+	return s, nil
+}
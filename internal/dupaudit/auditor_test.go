@@ -0,0 +1,71 @@
+package dupaudit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+func TestMeasureAndScore(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	pair := func(gap time.Duration, durA, durB float64, unitA, unitB int) database.DuplicatePair {
+		return database.DuplicatePair{
+			Call:         database.CallRef{CallID: 1, StartTime: start},
+			Dup:          database.CallRef{CallID: 2, StartTime: start.Add(gap)},
+			CallDuration: durA,
+			DupDuration:  durB,
+			CallUnit:     unitA,
+			DupUnit:      unitB,
+		}
+	}
+	maxGap := 10 * time.Second
+
+	// Simulcast copy a second late, same talker: near certain
+	s := Measure(pair(time.Second, 12, 11, 1234, 1234))
+	if s.StartGap != 1 || s.OverlapRatio != 11.0/12 || s.DurationRatio != 11.0/12 || s.SameUnit == nil || !*s.SameUnit {
+		t.Errorf("simulcast signals = %+v", s)
+	}
+	high := Score(s, maxGap)
+	if high < 0.9 {
+		t.Errorf("simulcast copy scored %v, want >= 0.9", high)
+	}
+
+	// Same start and length but unknown units
+	if got := Score(Measure(pair(0, 8, 8, 0, 0)), maxGap); got != 1 {
+		t.Errorf("identical timing scored %v, want 1", got)
+	}
+
+	// Different talkers overlapping briefly: unlikely
+	s = Measure(pair(8*time.Second, 9, 20, 1234, 5678))
+	if s.OverlapRatio != 1.0/20 {
+		t.Errorf("overlap ratio = %v, want %v", s.OverlapRatio, 1.0/20)
+	}
+	if low := Score(s, maxGap); low > 0.2 {
+		t.Errorf("different talkers scored %v, want <= 0.2", low)
+	}
+
+	// Zero-length calls that start together still match
+	if s := Measure(pair(0, 0, 0, 0, 0)); s.OverlapRatio != 1 || s.DurationRatio != 1 {
+		t.Errorf("zero-length signals = %+v", s)
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	a := New(nil, Options{Hour: 3}, zerolog.Nop())
+	loc := time.FixedZone("test", -5*3600)
+	tests := []struct {
+		now, want time.Time
+	}{
+		{time.Date(2026, 3, 1, 1, 30, 0, 0, loc), time.Date(2026, 3, 1, 3, 0, 0, 0, loc)},
+		{time.Date(2026, 3, 1, 3, 0, 0, 0, loc), time.Date(2026, 3, 2, 3, 0, 0, 0, loc)},
+		{time.Date(2026, 3, 1, 22, 0, 0, 0, loc), time.Date(2026, 3, 2, 3, 0, 0, 0, loc)},
+	}
+	for _, tt := range tests {
+		if got := a.nextRun(tt.now); !got.Equal(tt.want) {
+			t.Errorf("nextRun(%s) = %s, want %s", tt.now, got, tt.want)
+		}
+	}
+}
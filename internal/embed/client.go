@@ -0,0 +1,97 @@
+package embed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Client calls an OpenAI-compatible embeddings endpoint (OpenAI, Ollama,
+// vLLM, text-embeddings-inference, LiteLLM, ...). url is the full endpoint,
+// e.g. http://localhost:11434/v1/embeddings.
+type Client struct {
+	url     string
+	model   string
+	dims    int
+	headers http.Header
+	client  *http.Client
+}
+
+// NewClient creates a client for a model producing dims-dimension vectors.
+// headers are added to every request (e.g. Authorization).
+func NewClient(url, model string, dims int, headers http.Header, timeout time.Duration) *Client {
+	if headers == nil {
+		headers = http.Header{}
+	}
+	return &Client{
+		url:     url,
+		model:   model,
+		dims:    dims,
+		headers: headers,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Model returns the configured model name.
+func (c *Client) Model() string { return c.model }
+
+type embeddingsResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Embed returns one vector per input, in input order.
+func (c *Client) Embed(ctx context.Context, inputs []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]any{
+		"model": c.model,
+		"input": inputs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range c.headers {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embeddings request: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embeddings error (status %d): %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var er embeddingsResponse
+	if err := json.Unmarshal(respBody, &er); err != nil {
+		return nil, fmt.Errorf("decode embeddings response: %w", err)
+	}
+	if len(er.Data) != len(inputs) {
+		return nil, fmt.Errorf("embeddings endpoint returned %d vectors for %d inputs", len(er.Data), len(inputs))
+	}
+	sort.SliceStable(er.Data, func(i, j int) bool { return er.Data[i].Index < er.Data[j].Index })
+	out := make([][]float32, len(er.Data))
+	for i, d := range er.Data {
+		if len(d.Embedding) != c.dims {
+			return nil, fmt.Errorf("embeddings endpoint returned %d dimensions, EMBED_DIMENSIONS is %d", len(d.Embedding), c.dims)
+		}
+		out[i] = d.Embedding
+	}
+	return out, nil
+}
@@ -0,0 +1,337 @@
+// Package embed computes vector embeddings for transcripts and serves hybrid
+// (vector + full-text) search over them.
+//
+// Vectors come from an OpenAI-compatible embeddings endpoint and are stored in
+// transcript_embeddings (pgvector), partitioned monthly like calls. The
+// embedder embeds new primary transcripts in the background, newest first,
+// and can backfill an arbitrary time range on demand. It also keeps the
+// partitions and their HNSW indexes created ahead of time, so the month
+// rollover never has to build an index under write load.
+package embed
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// maxInputRunes bounds each transcript sent to the embeddings endpoint; radio
+// transmissions are short, and most embedding models truncate past ~512 tokens.
+const maxInputRunes = 2000
+
+// Options configures an Embedder.
+type Options struct {
+	Interval  time.Duration // how often new transcripts are embedded; 0 = never (backfill only)
+	Lookback  time.Duration // background runs cover calls from the last Lookback; 0 = all
+	BatchSize int           // transcripts per embeddings request (default 32)
+}
+
+// Result summarizes one embedding run.
+type Result struct {
+	Embedded int `json:"embedded"`
+	Failed   int `json:"failed"` // transcripts the endpoint rejected; retried next run
+}
+
+// BackfillState describes the most recent backfill.
+type BackfillState struct {
+	Running    bool       `json:"running"`
+	StartTime  *time.Time `json:"start_time,omitempty"`
+	EndTime    *time.Time `json:"end_time,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Result     Result     `json:"result"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Status reports embedding coverage and backfill progress.
+type Status struct {
+	Model      string `json:"model"`
+	Dimensions int    `json:"dimensions"`
+	database.EmbeddingStats
+	Backfill *BackfillState `json:"backfill,omitempty"`
+}
+
+// Embedder embeds transcripts in the background and answers searches.
+type Embedder struct {
+	db     *database.DB
+	client *Client
+	opts   Options
+	dims   int
+	log    zerolog.Logger
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+
+	runMu sync.Mutex // one run at a time
+
+	partMu         sync.Mutex
+	partitions     map[string]bool // months with a partition and index
+	lastMaintained time.Time
+
+	backfilling atomic.Bool
+	bfMu        sync.Mutex
+	backfill    *BackfillState
+}
+
+// NewEmbedder creates an embedder. The transcript_embeddings table must exist
+// (database.EnsureEmbeddingSchema).
+func NewEmbedder(db *database.DB, client *Client, dims int, opts Options, log zerolog.Logger) *Embedder {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 32
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Embedder{
+		db:         db,
+		client:     client,
+		opts:       opts,
+		dims:       dims,
+		log:        log.With().Str("component", "embed").Logger(),
+		ctx:        ctx,
+		cancel:     cancel,
+		partitions: make(map[string]bool),
+	}
+}
+
+// Model returns the embedding model name.
+func (e *Embedder) Model() string { return e.client.Model() }
+
+// Start maintains partitions and, if Interval > 0, embeds new transcripts
+// periodically.
+func (e *Embedder) Start() {
+	go e.loop()
+}
+
+// Stop ends background work, including a running backfill.
+func (e *Embedder) Stop() { e.stopOnce.Do(e.cancel) }
+
+func (e *Embedder) loop() {
+	interval := e.opts.Interval
+	if interval <= 0 {
+		interval = time.Hour // partition maintenance only
+	}
+	e.tick()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.tick()
+		case <-e.ctx.Done():
+			return
+		}
+	}
+}
+
+func (e *Embedder) tick() {
+	if time.Since(e.lastMaintained) >= 24*time.Hour {
+		if err := e.MaintainPartitions(e.ctx); err != nil {
+			e.log.Warn().Err(err).Msg("embedding partition maintenance failed")
+		} else {
+			e.lastMaintained = time.Now()
+		}
+	}
+	if e.opts.Interval <= 0 {
+		return
+	}
+	if !e.runMu.TryLock() {
+		e.log.Debug().Msg("embedding run skipped: backfill in progress")
+		return
+	}
+	defer e.runMu.Unlock()
+
+	var start *time.Time
+	if e.opts.Lookback > 0 {
+		t := time.Now().Add(-e.opts.Lookback)
+		start = &t
+	}
+	res, err := e.run(e.ctx, start, nil)
+	if err != nil {
+		e.log.Warn().Err(err).Int("embedded", res.Embedded).Msg("embedding run failed")
+		return
+	}
+	if res.Embedded > 0 || res.Failed > 0 {
+		e.log.Info().Int("embedded", res.Embedded).Int("failed", res.Failed).Msg("transcripts embedded")
+	}
+}
+
+// MaintainPartitions makes sure the partitions (and HNSW indexes) for the
+// current month and the next three exist, mirroring the calls partitions.
+func (e *Embedder) MaintainPartitions(ctx context.Context) error {
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i <= 3; i++ {
+		if err := e.ensurePartition(ctx, month.AddDate(0, i, 0)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Embedder) ensurePartition(ctx context.Context, t time.Time) error {
+	t = t.UTC()
+	key := t.Format("2006-01")
+	e.partMu.Lock()
+	defer e.partMu.Unlock()
+	if e.partitions[key] {
+		return nil
+	}
+	res, err := e.db.EnsureEmbeddingPartition(ctx, t)
+	if err != nil {
+		return err
+	}
+	e.log.Debug().Str("result", res).Msg("embedding partition")
+	e.partitions[key] = true
+	return nil
+}
+
+// run embeds pending transcripts for calls in [start, end), newest first,
+// walking the window once. The caller holds runMu.
+func (e *Embedder) run(ctx context.Context, start, end *time.Time) (Result, error) {
+	var res Result
+	var cursor *database.EmbedCursor
+	for {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		batch, err := e.db.ListTranscriptionsToEmbed(ctx, e.Model(), start, end, cursor, e.opts.BatchSize)
+		if err != nil {
+			return res, err
+		}
+		if len(batch) == 0 {
+			return res, nil
+		}
+		last := batch[len(batch)-1]
+		cursor = &database.EmbedCursor{CallStartTime: last.CallStartTime, ID: last.ID}
+
+		stored, failed, err := e.embedBatch(ctx, batch)
+		res.Embedded += stored
+		res.Failed += failed
+		if err != nil {
+			return res, err
+		}
+		if len(batch) < e.opts.BatchSize {
+			return res, nil
+		}
+	}
+}
+
+// embedBatch embeds and stores batch. If the endpoint rejects the batch, each
+// transcript is retried alone so one bad input doesn't hold back the rest; if
+// none succeeds, the endpoint is assumed down and the error is returned.
+func (e *Embedder) embedBatch(ctx context.Context, batch []database.TranscriptionToEmbed) (stored, failed int, err error) {
+	vecs, embedErr := e.embedTexts(ctx, batch)
+	if embedErr != nil {
+		if len(batch) == 1 || ctx.Err() != nil {
+			e.log.Debug().Err(embedErr).Int("transcription_id", batch[0].ID).Msg("embedding failed")
+			return 0, len(batch), nil
+		}
+		for _, t := range batch {
+			s, f, err := e.embedBatch(ctx, []database.TranscriptionToEmbed{t})
+			stored, failed = stored+s, failed+f
+			if err != nil {
+				return stored, failed, err
+			}
+		}
+		if stored == 0 {
+			return 0, failed, embedErr
+		}
+		return stored, failed, nil
+	}
+
+	rows := make([]database.TranscriptEmbedding, len(batch))
+	for i, t := range batch {
+		if err := e.ensurePartition(ctx, t.CallStartTime); err != nil {
+			return 0, 0, err
+		}
+		rows[i] = database.TranscriptEmbedding{TranscriptionToEmbed: t, Embedding: vecs[i]}
+	}
+	if err := e.db.StoreEmbeddings(ctx, e.Model(), rows); err != nil {
+		return 0, 0, fmt.Errorf("store embeddings: %w", err)
+	}
+	return len(rows), 0, nil
+}
+
+func (e *Embedder) embedTexts(ctx context.Context, batch []database.TranscriptionToEmbed) ([][]float32, error) {
+	texts := make([]string, len(batch))
+	for i, t := range batch {
+		texts[i] = truncate(t.Text, maxInputRunes)
+	}
+	return e.client.Embed(ctx, texts)
+}
+
+// Backfill embeds pending transcripts for calls in [start, end) (nil =
+// unbounded) in the background. Only one backfill runs at a time.
+func (e *Embedder) Backfill(start, end *time.Time) error {
+	if !e.backfilling.CompareAndSwap(false, true) {
+		return fmt.Errorf("backfill already running")
+	}
+	state := &BackfillState{Running: true, StartTime: start, EndTime: end, StartedAt: time.Now()}
+	e.bfMu.Lock()
+	e.backfill = state
+	e.bfMu.Unlock()
+
+	go func() {
+		defer e.backfilling.Store(false)
+		e.runMu.Lock()
+		res, err := e.run(e.ctx, start, end)
+		e.runMu.Unlock()
+
+		now := time.Now()
+		e.bfMu.Lock()
+		state.Running = false
+		state.FinishedAt = &now
+		state.Result = res
+		if err != nil {
+			state.Error = err.Error()
+		}
+		e.bfMu.Unlock()
+
+		ev := e.log.Info()
+		if err != nil {
+			ev = e.log.Warn().Err(err)
+		}
+		ev.Int("embedded", res.Embedded).Int("failed", res.Failed).Dur("elapsed", now.Sub(state.StartedAt)).
+			Msg("embedding backfill finished")
+	}()
+	return nil
+}
+
+// Status returns coverage for the current model and the last backfill.
+func (e *Embedder) Status(ctx context.Context) (Status, error) {
+	stats, err := e.db.GetEmbeddingStats(ctx, e.Model())
+	if err != nil {
+		return Status{}, err
+	}
+	s := Status{Model: e.Model(), Dimensions: e.dims, EmbeddingStats: stats}
+	e.bfMu.Lock()
+	if e.backfill != nil {
+		bf := *e.backfill
+		s.Backfill = &bf
+	}
+	e.bfMu.Unlock()
+	return s, nil
+}
+
+// Search embeds query and runs a hybrid search with it.
+func (e *Embedder) Search(ctx context.Context, query string, filter database.SemanticSearchFilter) ([]database.SemanticSearchHit, error) {
+	vecs, err := e.client.Embed(ctx, []string{truncate(query, maxInputRunes)})
+	if err != nil {
+		return nil, err
+	}
+	return e.db.SemanticSearchTranscriptions(ctx, e.Model(), vecs[0], query, filter)
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
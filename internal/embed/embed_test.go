@@ -0,0 +1,64 @@
+package embed
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientEmbed(t *testing.T) {
+	var got struct {
+		Model string   `json:"model"`
+		Input []string `json:"input"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		// Out of order: the client must sort by index.
+		io.WriteString(w, `{"data":[{"index":1,"embedding":[0,1,0]},{"index":0,"embedding":[1,0,0]}]}`)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, "nomic-embed-text", 3, nil, 5*time.Second)
+	vecs, err := c.Embed(context.Background(), []string{"structure fire", "house fire"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Model != "nomic-embed-text" || len(got.Input) != 2 {
+		t.Errorf("request = %+v", got)
+	}
+	if len(vecs) != 2 || vecs[0][0] != 1 || vecs[1][1] != 1 {
+		t.Errorf("vecs = %v", vecs)
+	}
+}
+
+func TestClientEmbed_Errors(t *testing.T) {
+	for name, body := range map[string]string{
+		"wrong dimensions": `{"data":[{"index":0,"embedding":[1,0]}]}`,
+		"missing vectors":  `{"data":[]}`,
+		"bad json":         `not json`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, body)
+			}))
+			defer srv.Close()
+			c := NewClient(srv.URL, "m", 3, nil, 5*time.Second)
+			if _, err := c.Embed(context.Background(), []string{"x"}); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("héllo", 2); got != "hé" {
+		t.Errorf("truncate = %q", got)
+	}
+	if got := truncate("hi", 5); got != "hi" {
+		t.Errorf("truncate = %q", got)
+	}
+}
@@ -0,0 +1,490 @@
+package export
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// DirectorySnapshot is a canonical, hashable view of an instance's curated
+// directory metadata — named talkgroups, the talkgroup directory (with
+// categories), and named units — keyed by natural system refs so snapshots
+// from different instances can be compared and applied.
+//
+// Volatile fields (first/last seen, counts) are left out so the hash only
+// changes when curated metadata does.
+type DirectorySnapshot struct {
+	Version   int               `json:"version"`
+	Format    string            `json:"format"`
+	CreatedAt time.Time         `json:"created_at"`
+	Hash      string            `json:"hash"`
+	Systems   []DirectorySystem `json:"systems"`
+}
+
+// DirectorySystem holds one system's directory entries.
+type DirectorySystem struct {
+	SystemID   int                  `json:"system_id"` // local to the exporting instance; not hashed
+	System     SystemRecord         `json:"system"`
+	Hash       string               `json:"hash"`
+	Talkgroups []DirectoryTalkgroup `json:"talkgroups"`
+	Directory  []DirectoryEntry     `json:"directory"`
+	Units      []DirectoryUnit      `json:"units"`
+}
+
+// DirectoryTalkgroup is the curated metadata of a heard talkgroup.
+type DirectoryTalkgroup struct {
+	Tgid           int    `json:"tgid"`
+	AlphaTag       string `json:"alpha_tag,omitempty"`
+	AlphaTagSource string `json:"alpha_tag_source,omitempty"`
+	Tag            string `json:"tag,omitempty"`
+	Group          string `json:"group,omitempty"`
+	Description    string `json:"description,omitempty"`
+	Mode           string `json:"mode,omitempty"`
+	Priority       *int   `json:"priority,omitempty"`
+	Hash           string `json:"hash,omitempty"`
+}
+
+// DirectoryEntry is a talkgroup_directory row.
+type DirectoryEntry struct {
+	Tgid        int    `json:"tgid"`
+	AlphaTag    string `json:"alpha_tag,omitempty"`
+	Mode        string `json:"mode,omitempty"`
+	Description string `json:"description,omitempty"`
+	Tag         string `json:"tag,omitempty"`
+	Category    string `json:"category,omitempty"`
+	Priority    *int   `json:"priority,omitempty"`
+	Hash        string `json:"hash,omitempty"`
+}
+
+// DirectoryUnit is a named unit.
+type DirectoryUnit struct {
+	UnitID         int    `json:"unit_id"`
+	AlphaTag       string `json:"alpha_tag"`
+	AlphaTagSource string `json:"alpha_tag_source,omitempty"`
+	Hash           string `json:"hash,omitempty"`
+}
+
+const directoryFormat = "tr-engine-directory"
+
+// BuildDirectorySnapshot reads the local directory for the given systems
+// (empty = all) and returns a hashed snapshot.
+func BuildDirectorySnapshot(ctx context.Context, db *database.DB, systemIDs []int) (*DirectorySnapshot, error) {
+	systems, err := db.ListSystemsWithSites(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load systems: %w", err)
+	}
+	if len(systemIDs) > 0 {
+		idSet := make(map[int]bool, len(systemIDs))
+		for _, id := range systemIDs {
+			idSet[id] = true
+		}
+		filtered := systems[:0]
+		for _, s := range systems {
+			if idSet[s.SystemID] {
+				filtered = append(filtered, s)
+			}
+		}
+		systems = filtered
+	}
+
+	bySystem := make(map[int]*DirectorySystem, len(systems))
+	ids := make([]int, len(systems))
+	for i, s := range systems {
+		ids[i] = s.SystemID
+		bySystem[s.SystemID] = &DirectorySystem{SystemID: s.SystemID, System: buildSystemRecord(s)}
+	}
+
+	talkgroups, err := db.ExportTalkgroups(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("load talkgroups: %w", err)
+	}
+	for _, tg := range talkgroups {
+		ds := bySystem[tg.SystemID]
+		if ds == nil || (tg.AlphaTag == "" && tg.Tag == "" && tg.Group == "" && tg.Description == "") {
+			continue
+		}
+		ds.Talkgroups = append(ds.Talkgroups, DirectoryTalkgroup{
+			Tgid:           tg.Tgid,
+			AlphaTag:       tg.AlphaTag,
+			AlphaTagSource: tg.AlphaTagSource,
+			Tag:            tg.Tag,
+			Group:          tg.Group,
+			Description:    tg.Description,
+			Mode:           tg.Mode,
+			Priority:       tg.Priority,
+		})
+	}
+
+	tgDir, err := db.ExportTalkgroupDirectory(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("load talkgroup directory: %w", err)
+	}
+	for _, td := range tgDir {
+		if ds := bySystem[td.SystemID]; ds != nil {
+			ds.Directory = append(ds.Directory, DirectoryEntry{
+				Tgid:        td.Tgid,
+				AlphaTag:    td.AlphaTag,
+				Mode:        td.Mode,
+				Description: td.Description,
+				Tag:         td.Tag,
+				Category:    td.Category,
+				Priority:    td.Priority,
+			})
+		}
+	}
+
+	units, err := db.ExportUnits(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("load units: %w", err)
+	}
+	for _, u := range units {
+		if ds := bySystem[u.SystemID]; ds != nil && u.AlphaTag != "" {
+			ds.Units = append(ds.Units, DirectoryUnit{
+				UnitID:         u.UnitID,
+				AlphaTag:       u.AlphaTag,
+				AlphaTagSource: u.AlphaTagSource,
+			})
+		}
+	}
+
+	snap := &DirectorySnapshot{
+		Version:   1,
+		Format:    directoryFormat,
+		CreatedAt: time.Now().UTC(),
+	}
+	for _, id := range ids {
+		snap.Systems = append(snap.Systems, *bySystem[id])
+	}
+	snap.Seal()
+	return snap, nil
+}
+
+// Seal sorts every section and recomputes entry, system, and snapshot hashes.
+// Hashes on a snapshot received from elsewhere are never trusted; call Seal
+// before comparing it.
+func (s *DirectorySnapshot) Seal() {
+	sort.SliceStable(s.Systems, func(i, j int) bool {
+		return systemKey(s.Systems[i].System) < systemKey(s.Systems[j].System)
+	})
+
+	top := sha256.New()
+	for i := range s.Systems {
+		ds := &s.Systems[i]
+		sort.Slice(ds.Talkgroups, func(a, b int) bool { return ds.Talkgroups[a].Tgid < ds.Talkgroups[b].Tgid })
+		sort.Slice(ds.Directory, func(a, b int) bool { return ds.Directory[a].Tgid < ds.Directory[b].Tgid })
+		sort.Slice(ds.Units, func(a, b int) bool { return ds.Units[a].UnitID < ds.Units[b].UnitID })
+
+		h := sha256.New()
+		for j := range ds.Talkgroups {
+			ds.Talkgroups[j].Hash = ""
+			ds.Talkgroups[j].Hash = hashJSON(ds.Talkgroups[j])
+			fmt.Fprintf(h, "tg %s\n", ds.Talkgroups[j].Hash)
+		}
+		for j := range ds.Directory {
+			ds.Directory[j].Hash = ""
+			ds.Directory[j].Hash = hashJSON(ds.Directory[j])
+			fmt.Fprintf(h, "dir %s\n", ds.Directory[j].Hash)
+		}
+		for j := range ds.Units {
+			ds.Units[j].Hash = ""
+			ds.Units[j].Hash = hashJSON(ds.Units[j])
+			fmt.Fprintf(h, "unit %s\n", ds.Units[j].Hash)
+		}
+		ds.Hash = hex.EncodeToString(h.Sum(nil)[:16])
+		fmt.Fprintf(top, "%s %s\n", systemKey(ds.System), ds.Hash)
+	}
+	s.Hash = hex.EncodeToString(top.Sum(nil)[:16])
+}
+
+// Validate checks the snapshot header.
+func (s *DirectorySnapshot) Validate() error {
+	if s.Format != directoryFormat {
+		return fmt.Errorf("unsupported snapshot format %q", s.Format)
+	}
+	if s.Version != 1 {
+		return fmt.Errorf("unsupported snapshot version %d (expected 1)", s.Version)
+	}
+	return nil
+}
+
+func hashJSON(v any) string {
+	b, _ := json.Marshal(v)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:16])
+}
+
+// systemKeys returns the natural keys a system can be matched by: sysid+wacn
+// for P25, otherwise one key per site (as in archive import).
+func systemKeys(rec SystemRecord) []string {
+	if rec.Sysid != "" && rec.Sysid != "0" {
+		return []string{"p25:" + rec.Sysid + ":" + rec.Wacn}
+	}
+	keys := make([]string, 0, len(rec.Sites))
+	for _, site := range rec.Sites {
+		keys = append(keys, "conv:"+site.InstanceID+":"+site.ShortName)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func systemKey(rec SystemRecord) string {
+	if keys := systemKeys(rec); len(keys) > 0 {
+		return keys[0]
+	}
+	return "name:" + rec.Name
+}
+
+// DirectoryDiff describes what applying a remote snapshot would change locally.
+type DirectoryDiff struct {
+	LocalHash        string         `json:"local_hash"`
+	RemoteHash       string         `json:"remote_hash"`
+	Identical        bool           `json:"identical"`
+	Systems          []SystemDiff   `json:"systems"`
+	UnchangedSystems int            `json:"unchanged_systems"`
+	UnmatchedSystems []SystemRecord `json:"unmatched_systems"` // remote systems with no local counterpart
+}
+
+// SystemDiff is the per-system difference between a local and remote snapshot.
+type SystemDiff struct {
+	SystemID   int                             `json:"system_id"` // local
+	Name       string                          `json:"name"`
+	LocalHash  string                          `json:"local_hash"`
+	RemoteHash string                          `json:"remote_hash"`
+	Talkgroups SectionDiff[DirectoryTalkgroup] `json:"talkgroups"`
+	Directory  SectionDiff[DirectoryEntry]     `json:"directory"`
+	Units      SectionDiff[DirectoryUnit]      `json:"units"`
+}
+
+// SectionDiff lists entries only in the remote snapshot (Added), in both but
+// different (Changed), and only local (LocalOnly, never removed by apply).
+type SectionDiff[T any] struct {
+	Added     []T           `json:"added"`
+	Changed   []EntryChange `json:"changed"`
+	LocalOnly []int         `json:"local_only"`
+
+	pending []T // Added plus the remote side of Changed
+}
+
+// EntryChange lists the differing fields of one talkgroup or unit.
+type EntryChange struct {
+	ID      int           `json:"id"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// FieldChange is one field's local and remote value.
+type FieldChange struct {
+	Field  string `json:"field"`
+	Local  any    `json:"local"`
+	Remote any    `json:"remote"`
+}
+
+// DiffDirectory compares a remote snapshot against the local one. Both are
+// re-sealed first.
+func DiffDirectory(local, remote *DirectorySnapshot) *DirectoryDiff {
+	local.Seal()
+	remote.Seal()
+
+	d := &DirectoryDiff{
+		LocalHash:        local.Hash,
+		RemoteHash:       remote.Hash,
+		Identical:        local.Hash == remote.Hash,
+		Systems:          []SystemDiff{},
+		UnmatchedSystems: []SystemRecord{},
+	}
+
+	byKey := make(map[string]*DirectorySystem)
+	for i := range local.Systems {
+		for _, k := range systemKeys(local.Systems[i].System) {
+			byKey[k] = &local.Systems[i]
+		}
+	}
+
+	for i := range remote.Systems {
+		rs := &remote.Systems[i]
+		var ls *DirectorySystem
+		for _, k := range systemKeys(rs.System) {
+			if ls = byKey[k]; ls != nil {
+				break
+			}
+		}
+		if ls == nil {
+			d.UnmatchedSystems = append(d.UnmatchedSystems, rs.System)
+			continue
+		}
+		if ls.Hash == rs.Hash {
+			d.UnchangedSystems++
+			continue
+		}
+		d.Systems = append(d.Systems, SystemDiff{
+			SystemID:   ls.SystemID,
+			Name:       ls.System.Name,
+			LocalHash:  ls.Hash,
+			RemoteHash: rs.Hash,
+			Talkgroups: diffSection(ls.Talkgroups, rs.Talkgroups,
+				func(e DirectoryTalkgroup) (int, string) { return e.Tgid, e.Hash }),
+			Directory: diffSection(ls.Directory, rs.Directory,
+				func(e DirectoryEntry) (int, string) { return e.Tgid, e.Hash }),
+			Units: diffSection(ls.Units, rs.Units,
+				func(e DirectoryUnit) (int, string) { return e.UnitID, e.Hash }),
+		})
+	}
+	return d
+}
+
+// diffSection compares two entry lists sorted by ID.
+func diffSection[T any](local, remote []T, key func(T) (int, string)) SectionDiff[T] {
+	sd := SectionDiff[T]{Added: []T{}, Changed: []EntryChange{}, LocalOnly: []int{}}
+	localByID := make(map[int]T, len(local))
+	for _, e := range local {
+		id, _ := key(e)
+		localByID[id] = e
+	}
+	seen := make(map[int]bool, len(remote))
+	for _, re := range remote {
+		id, rh := key(re)
+		seen[id] = true
+		le, ok := localByID[id]
+		if !ok {
+			sd.Added = append(sd.Added, re)
+			sd.pending = append(sd.pending, re)
+			continue
+		}
+		if _, lh := key(le); lh != rh {
+			sd.Changed = append(sd.Changed, EntryChange{ID: id, Changes: fieldChanges(le, re)})
+			sd.pending = append(sd.pending, re)
+		}
+	}
+	for _, e := range local {
+		if id, _ := key(e); !seen[id] {
+			sd.LocalOnly = append(sd.LocalOnly, id)
+		}
+	}
+	return sd
+}
+
+// fieldChanges compares two entries field by field via their JSON form.
+func fieldChanges(local, remote any) []FieldChange {
+	lm, rm := jsonFields(local), jsonFields(remote)
+	fields := make([]string, 0, len(lm)+len(rm))
+	for f := range lm {
+		fields = append(fields, f)
+	}
+	for f := range rm {
+		if _, ok := lm[f]; !ok {
+			fields = append(fields, f)
+		}
+	}
+	sort.Strings(fields)
+
+	var changes []FieldChange
+	for _, f := range fields {
+		if f == "hash" || reflect.DeepEqual(lm[f], rm[f]) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: f, Local: lm[f], Remote: rm[f]})
+	}
+	return changes
+}
+
+func jsonFields(v any) map[string]any {
+	b, _ := json.Marshal(v)
+	m := map[string]any{}
+	json.Unmarshal(b, &m)
+	return m
+}
+
+// DirectoryApplyResult reports what ApplyDirectory wrote.
+type DirectoryApplyResult struct {
+	DryRun             bool           `json:"dry_run"`
+	Talkgroups         ImportCounts   `json:"talkgroups"`
+	TalkgroupDirectory ImportCounts   `json:"talkgroup_directory"`
+	Units              ImportCounts   `json:"units"`
+	Diff               *DirectoryDiff `json:"diff"`
+}
+
+// ApplyDirectory upserts every added or changed entry from remote into the
+// matching local systems. Nothing is deleted and systems are never created;
+// remote systems without a local match are reported and skipped. alpha_tag
+// changes respect source priority, so a local manual name is not replaced by
+// a lower-priority remote one.
+func ApplyDirectory(ctx context.Context, db *database.DB, local, remote *DirectorySnapshot, dryRun bool, log zerolog.Logger) (*DirectoryApplyResult, error) {
+	diff := DiffDirectory(local, remote)
+	result := &DirectoryApplyResult{DryRun: dryRun, Diff: diff}
+
+	for _, sd := range diff.Systems {
+		result.Talkgroups.Create += len(sd.Talkgroups.Added)
+		result.Talkgroups.Update += len(sd.Talkgroups.Changed)
+		result.TalkgroupDirectory.Create += len(sd.Directory.Added)
+		result.TalkgroupDirectory.Update += len(sd.Directory.Changed)
+		result.Units.Create += len(sd.Units.Added)
+		result.Units.Update += len(sd.Units.Changed)
+		if dryRun {
+			continue
+		}
+
+		for _, tg := range sd.Talkgroups.pending {
+			if err := db.SyncUpsertTalkgroup(ctx, sd.SystemID, tg.Tgid,
+				tg.AlphaTag, tg.AlphaTagSource, tg.Tag, tg.Group,
+				tg.Description, tg.Mode, tg.Priority,
+			); err != nil {
+				if ctx.Err() != nil {
+					return result, ctx.Err()
+				}
+				log.Warn().Err(err).Int("system_id", sd.SystemID).Int("tgid", tg.Tgid).Msg("failed to apply talkgroup")
+				result.Talkgroups.Skip++
+			}
+		}
+
+		for _, td := range sd.Directory.pending {
+			prio := 0
+			if td.Priority != nil {
+				prio = *td.Priority
+			}
+			if err := db.UpsertTalkgroupDirectory(ctx, sd.SystemID, td.Tgid,
+				td.AlphaTag, td.Mode, td.Description, td.Tag, td.Category, prio,
+			); err != nil {
+				if ctx.Err() != nil {
+					return result, ctx.Err()
+				}
+				log.Warn().Err(err).Int("system_id", sd.SystemID).Int("tgid", td.Tgid).Msg("failed to apply talkgroup directory entry")
+				result.TalkgroupDirectory.Skip++
+			}
+		}
+		if len(sd.Directory.pending) > 0 {
+			if _, err := db.EnrichTalkgroupsFromDirectory(ctx, sd.SystemID, 0); err != nil {
+				log.Warn().Err(err).Int("system_id", sd.SystemID).Msg("directory apply: enrichment failed")
+			}
+		}
+
+		for _, u := range sd.Units.pending {
+			if err := db.ImportUpsertUnit(ctx, sd.SystemID, u.UnitID,
+				u.AlphaTag, u.AlphaTagSource, nil, nil,
+			); err != nil {
+				if ctx.Err() != nil {
+					return result, ctx.Err()
+				}
+				log.Warn().Err(err).Int("system_id", sd.SystemID).Int("unit_id", u.UnitID).Msg("failed to apply unit")
+				result.Units.Skip++
+			}
+		}
+	}
+
+	if !dryRun && len(diff.Systems) > 0 {
+		log.Info().
+			Str("remote_hash", diff.RemoteHash).
+			Int("systems", len(diff.Systems)).
+			Int("talkgroups", result.Talkgroups.Create+result.Talkgroups.Update).
+			Int("directory", result.TalkgroupDirectory.Create+result.TalkgroupDirectory.Update).
+			Int("units", result.Units.Create+result.Units.Update).
+			Msg("applied directory snapshot")
+	}
+	return result, nil
+}
@@ -0,0 +1,160 @@
+package export
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func testSnapshot() *DirectorySnapshot {
+	prio := 2
+	return &DirectorySnapshot{
+		Version: 1,
+		Format:  directoryFormat,
+		Systems: []DirectorySystem{
+			{
+				SystemID: 1,
+				System:   SystemRecord{V: 1, Type: "p25", Name: "Butler/Warren", Sysid: "348", Wacn: "BEE00"},
+				Talkgroups: []DirectoryTalkgroup{
+					{Tgid: 9178, AlphaTag: "09 WC HOSP", AlphaTagSource: "csv", Group: "Hospitals"},
+					{Tgid: 1001, AlphaTag: "BC FIRE DISP", AlphaTagSource: "manual", Tag: "Fire Dispatch"},
+				},
+				Directory: []DirectoryEntry{
+					{Tgid: 1001, AlphaTag: "BC FIRE DISP", Category: "Fire", Priority: &prio},
+				},
+				Units: []DirectoryUnit{
+					{UnitID: 4501, AlphaTag: "Engine 45", AlphaTagSource: "manual"},
+				},
+			},
+			{
+				SystemID: 2,
+				System: SystemRecord{V: 1, Type: "conventional", Name: "Local Fire",
+					Sites: []SiteRef{{InstanceID: "tr-1", ShortName: "local_fire"}}},
+				Talkgroups: []DirectoryTalkgroup{
+					{Tgid: 1, AlphaTag: "Fire Ops", AlphaTagSource: "csv"},
+				},
+			},
+		},
+	}
+}
+
+func findSystem(s *DirectorySnapshot, name string) *DirectorySystem {
+	for i := range s.Systems {
+		if s.Systems[i].System.Name == name {
+			return &s.Systems[i]
+		}
+	}
+	return nil
+}
+
+// clone round-trips through JSON, as a snapshot received from another instance would.
+func clone(t *testing.T, s *DirectorySnapshot) *DirectorySnapshot {
+	t.Helper()
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out DirectorySnapshot
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	return &out
+}
+
+func TestDirectorySnapshotSeal_Deterministic(t *testing.T) {
+	a := testSnapshot()
+	a.Seal()
+
+	// Same content in a different order, with bogus hashes and a different
+	// local system_id, must seal to the same hash.
+	b := testSnapshot()
+	b.Systems[0], b.Systems[1] = b.Systems[1], b.Systems[0]
+	tgs := b.Systems[1].Talkgroups
+	tgs[0], tgs[1] = tgs[1], tgs[0]
+	b.Systems[1].SystemID = 99
+	b.Systems[1].Hash = "tampered"
+	b.Systems[1].Talkgroups[0].Hash = "tampered"
+	b.Seal()
+
+	if a.Hash == "" || a.Hash != b.Hash {
+		t.Errorf("hash mismatch: %q vs %q", a.Hash, b.Hash)
+	}
+	if tg := findSystem(a, "Butler/Warren").Talkgroups[0]; tg.Tgid != 1001 {
+		t.Errorf("talkgroups not sorted by tgid: first = %d", tg.Tgid)
+	}
+
+	c := testSnapshot()
+	c.Systems[0].Units[0].AlphaTag = "Engine 46"
+	c.Seal()
+	if c.Hash == a.Hash {
+		t.Error("hash unchanged after editing a unit alpha_tag")
+	}
+}
+
+func TestDiffDirectory(t *testing.T) {
+	local := testSnapshot()
+	remote := clone(t, local)
+
+	d := DiffDirectory(local, remote)
+	if !d.Identical || len(d.Systems) != 0 || d.UnchangedSystems != 2 {
+		t.Fatalf("identical snapshots: identical=%v systems=%d unchanged=%d",
+			d.Identical, len(d.Systems), d.UnchangedSystems)
+	}
+
+	// Remote renames a talkgroup, adds a unit, drops the directory entry, and
+	// knows a system this instance doesn't.
+	rs := findSystem(remote, "Butler/Warren")
+	for i := range rs.Talkgroups {
+		if rs.Talkgroups[i].Tgid == 9178 {
+			rs.Talkgroups[i].AlphaTag = "09 WC HOSP EAST"
+		}
+	}
+	rs.Units = append(rs.Units, DirectoryUnit{UnitID: 4502, AlphaTag: "Engine 46", AlphaTagSource: "csv"})
+	rs.Directory = nil
+	remote.Systems = append(remote.Systems, DirectorySystem{
+		System: SystemRecord{V: 1, Type: "p25", Name: "Elsewhere", Sysid: "1AB", Wacn: "BEE00"},
+	})
+
+	d = DiffDirectory(local, remote)
+	if d.Identical {
+		t.Fatal("expected snapshots to differ")
+	}
+	if len(d.UnmatchedSystems) != 1 || d.UnmatchedSystems[0].Sysid != "1AB" {
+		t.Errorf("unmatched systems = %+v", d.UnmatchedSystems)
+	}
+	if d.UnchangedSystems != 1 || len(d.Systems) != 1 {
+		t.Fatalf("systems: changed=%d unchanged=%d", len(d.Systems), d.UnchangedSystems)
+	}
+
+	sd := d.Systems[0]
+	if sd.SystemID != 1 {
+		t.Errorf("system_id = %d, want local id 1", sd.SystemID)
+	}
+	if len(sd.Talkgroups.Changed) != 1 || sd.Talkgroups.Changed[0].ID != 9178 {
+		t.Fatalf("talkgroup changes = %+v", sd.Talkgroups.Changed)
+	}
+	ch := sd.Talkgroups.Changed[0].Changes
+	if len(ch) != 1 || ch[0].Field != "alpha_tag" || ch[0].Local != "09 WC HOSP" || ch[0].Remote != "09 WC HOSP EAST" {
+		t.Errorf("field changes = %+v", ch)
+	}
+	if len(sd.Units.Added) != 1 || sd.Units.Added[0].UnitID != 4502 {
+		t.Errorf("units added = %+v", sd.Units.Added)
+	}
+	if len(sd.Directory.LocalOnly) != 1 || sd.Directory.LocalOnly[0] != 1001 {
+		t.Errorf("directory local_only = %v", sd.Directory.LocalOnly)
+	}
+	if len(sd.Talkgroups.pending) != 1 || len(sd.Units.pending) != 1 || len(sd.Directory.pending) != 0 {
+		t.Errorf("pending: tg=%d units=%d dir=%d",
+			len(sd.Talkgroups.pending), len(sd.Units.pending), len(sd.Directory.pending))
+	}
+}
+
+func TestDirectorySnapshotValidate(t *testing.T) {
+	s := testSnapshot()
+	if err := s.Validate(); err != nil {
+		t.Errorf("valid snapshot: %v", err)
+	}
+	s.Format = "tr-engine-export"
+	if err := s.Validate(); err == nil {
+		t.Error("expected error for wrong format")
+	}
+}
@@ -397,6 +397,7 @@ func buildCallRecord(c database.CallExport, sysRef SystemRef, siteIDMap map[int]
 		Conventional:  c.Conventional,
 		Encrypted:     c.Encrypted,
 		Emergency:     c.Emergency,
+		Interconnect:  c.Interconnect,
 		SrcList:       c.SrcList,
 		FreqList:      c.FreqList,
 		MetadataJSON:  c.MetadataJSON,
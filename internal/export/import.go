@@ -557,11 +557,25 @@ func importCalls(ctx context.Context, db *database.DB, data []byte, sysMap map[s
 				if rec.AudioFileSize != nil {
 					audioSize = *rec.AudioFileSize
 				}
-				if err := db.UpdateCallAudio(ctx, callID, rec.StartTime, rec.AudioFilePath, audioSize); err != nil {
+				if err := db.AddCallAudioVariant(ctx, database.AudioVariant{
+					CallID:        callID,
+					CallStartTime: rec.StartTime,
+					Variant:       database.AudioVariantOriginal,
+					AudioPath:     rec.AudioFilePath,
+					AudioType:     rec.AudioType,
+					AudioSize:     audioSize,
+					Source:        "import",
+				}, true); err != nil {
 					log.Warn().Err(err).Msg("failed to set audio path")
 				}
 			}
 
+			if rec.Interconnect {
+				if err := db.MarkCallInterconnect(ctx, callID, rec.StartTime, 0); err != nil {
+					log.Warn().Err(err).Msg("failed to mark interconnect call")
+				}
+			}
+
 			// Rebuild call_frequencies from freq_list JSONB
 			if len(rec.FreqList) > 0 {
 				rebuildCallFrequencies(ctx, db, callID, rec.StartTime, rec.FreqList, log)
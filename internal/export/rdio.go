@@ -0,0 +1,494 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/snarg/tr-engine/internal/audio"
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/storage"
+)
+
+// RdioInstanceID is the instance_id of the sites created for imported
+// rdio-scanner systems, so re-running an import finds them again.
+const RdioInstanceID = "rdio-import"
+
+// RdioImportOptions configures ImportRdio.
+type RdioImportOptions struct {
+	DryRun    bool
+	BatchSize int         // calls read per round trip to the rdio-scanner database
+	SystemMap map[int]int // rdio-scanner system id → existing tr-engine system_id
+	AfterID   int64       // resume after this rdio-scanner call id
+	// Checkpoint is called with the last rdio-scanner call id handled after
+	// each batch (not on dry runs), for resuming an interrupted import.
+	Checkpoint func(lastID int64) error
+}
+
+// RdioImportReport summarizes an rdio-scanner import. On a dry run it is
+// what the import would do.
+type RdioImportReport struct {
+	Source       string              `json:"source"`
+	DryRun       bool                `json:"dry_run"`
+	ResumedAfter int64               `json:"resumed_after,omitempty"`
+	LastCallID   int64               `json:"last_call_id"`
+	Systems      []*RdioSystemReport `json:"systems"`
+	Talkgroups   ImportCounts        `json:"talkgroups"`
+	Units        ImportCounts        `json:"units"`
+	Calls        ImportCounts        `json:"calls"`
+	CallsFailed  int                 `json:"calls_failed"`
+	AudioFiles   int                 `json:"audio_files"`
+	AudioBytes   int64               `json:"audio_bytes"`
+	AudioSkipped int                 `json:"audio_skipped"` // systems whose ingest policy keeps no audio
+	FirstCall    *time.Time          `json:"first_call,omitempty"`
+	LastCall     *time.Time          `json:"last_call,omitempty"`
+}
+
+// RdioSystemReport is the per-system part of RdioImportReport. SystemID is
+// 0 for a system a dry run would create.
+type RdioSystemReport struct {
+	RdioSystemID int        `json:"rdio_system_id"`
+	Label        string     `json:"label"`
+	SystemID     int        `json:"system_id,omitempty"`
+	Action       string     `json:"action"` // "mapped", "existing", "created", "create"
+	Talkgroups   int        `json:"talkgroups"`
+	Units        int        `json:"units"`
+	Calls        int        `json:"calls"`
+	AudioBytes   int64      `json:"audio_bytes"`
+	FirstCall    *time.Time `json:"first_call,omitempty"`
+	LastCall     *time.Time `json:"last_call,omitempty"`
+}
+
+// rdioTarget is where an rdio-scanner system's calls go.
+type rdioTarget struct {
+	report     *RdioSystemReport
+	systemID   int
+	siteID     *int
+	shortName  string
+	keepAudio  bool
+	talkgroups map[int]RdioTalkgroup
+}
+
+// ImportRdio copies systems, talkgroups, units and calls from an
+// rdio-scanner database, with the calls' audio saved to store. Calls are
+// read in id order and checkpointed after each batch; calls that already
+// exist (same system and talkgroup within a few seconds) are skipped, so
+// re-running over calls already imported is harmless.
+func ImportRdio(ctx context.Context, db *database.DB, src *RdioDB, store storage.AudioStore, opts RdioImportOptions, log zerolog.Logger) (*RdioImportReport, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 200
+	}
+	report := &RdioImportReport{Source: src.String(), DryRun: opts.DryRun, ResumedAfter: opts.AfterID, LastCallID: opts.AfterID}
+
+	systems, err := src.Systems(ctx)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := db.ListSystemIngestPolicies(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load ingest policies: %w", err)
+	}
+
+	targets := make(map[int]*rdioTarget)
+	for _, sys := range systems {
+		t, err := resolveRdioSystem(ctx, db, sys, opts, policies, report, log)
+		if err != nil {
+			return report, err
+		}
+		targets[sys.ID] = t
+	}
+
+	months := make(map[time.Time]bool)
+	after := opts.AfterID
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		calls, err := src.Calls(ctx, after, opts.BatchSize, !opts.DryRun)
+		if err != nil {
+			return report, err
+		}
+		if len(calls) == 0 {
+			break
+		}
+		for i := range calls {
+			c := &calls[i]
+			t, ok := targets[c.System]
+			if !ok {
+				// Calls can outlive their system's entry in rdio-scanner
+				sys := RdioSystem{ID: c.System}
+				if t, err = resolveRdioSystem(ctx, db, sys, opts, policies, report, log); err != nil {
+					return report, err
+				}
+				targets[c.System] = t
+			}
+			if !opts.DryRun {
+				month := time.Date(c.DateTime.Year(), c.DateTime.Month(), 1, 0, 0, 0, 0, time.UTC)
+				if !months[month] {
+					ensureCallPartitions(ctx, db, month, log)
+					months[month] = true
+				}
+			}
+			importRdioCall(ctx, db, store, c, t, report, opts.DryRun, log)
+		}
+		after = calls[len(calls)-1].ID
+		report.LastCallID = after
+		if !opts.DryRun && opts.Checkpoint != nil {
+			if err := opts.Checkpoint(after); err != nil {
+				return report, fmt.Errorf("save checkpoint: %w", err)
+			}
+		}
+		log.Info().Int64("last_call_id", after).Int("imported", report.Calls.Create).
+			Int("skipped", report.Calls.Skip).Int("failed", report.CallsFailed).Msg("rdio-scanner import progress")
+	}
+
+	if !opts.DryRun {
+		if _, err := db.RefreshTalkgroupStatsHot(ctx); err != nil {
+			log.Warn().Err(err).Msg("post-import: hot stats refresh failed")
+		}
+		if _, err := db.RefreshTalkgroupStatsCold(ctx); err != nil {
+			log.Warn().Err(err).Msg("post-import: cold stats refresh failed")
+		}
+	}
+	return report, nil
+}
+
+// resolveRdioSystem finds or creates the tr-engine system for an
+// rdio-scanner system and imports its talkgroups and units.
+func resolveRdioSystem(ctx context.Context, db *database.DB, sys RdioSystem, opts RdioImportOptions,
+	policies map[int]string, report *RdioImportReport, log zerolog.Logger) (*rdioTarget, error) {
+
+	if sys.Label == "" {
+		sys.Label = fmt.Sprintf("rdio-%d", sys.ID)
+	}
+	t := &rdioTarget{
+		report: &RdioSystemReport{
+			RdioSystemID: sys.ID,
+			Label:        sys.Label,
+			Talkgroups:   len(sys.Talkgroups),
+			Units:        len(sys.Units),
+		},
+		shortName:  rdioShortName(sys.Label),
+		talkgroups: make(map[int]RdioTalkgroup, len(sys.Talkgroups)),
+	}
+	for _, tg := range sys.Talkgroups {
+		t.talkgroups[tg.ID] = tg
+	}
+	report.Systems = append(report.Systems, t.report)
+
+	if id, ok := opts.SystemMap[sys.ID]; ok {
+		if _, err := db.GetSystemByID(ctx, id); err != nil {
+			return nil, fmt.Errorf("rdio-scanner system %d is mapped to system %d, which was not found", sys.ID, id)
+		}
+		t.systemID = id
+		t.report.Action = "mapped"
+	} else {
+		id, err := db.FindSystemViaSiteIdentity(ctx, RdioInstanceID, t.shortName)
+		if err != nil {
+			return nil, fmt.Errorf("look up system %q: %w", t.shortName, err)
+		}
+		switch {
+		case id > 0:
+			t.systemID = id
+			t.report.Action = "existing"
+		case opts.DryRun:
+			t.report.Action = "create"
+		default:
+			if t.systemID, _, err = db.FindOrCreateSystem(ctx, RdioInstanceID, t.shortName, ""); err != nil {
+				return nil, err
+			}
+			t.report.Action = "created"
+		}
+		if t.systemID > 0 && !opts.DryRun {
+			siteID, err := db.FindOrCreateSite(ctx, t.systemID, RdioInstanceID, t.shortName)
+			if err != nil {
+				return nil, fmt.Errorf("create site %q: %w", t.shortName, err)
+			}
+			t.siteID = &siteID
+		}
+	}
+	t.report.SystemID = t.systemID
+	policy := policies[t.systemID]
+	t.keepAudio = policy == "" || policy == database.IngestPolicyFull
+
+	// Labels curated in rdio-scanner rank like a CSV import: above what
+	// trunk-recorder reports, below manual edits.
+	for _, tg := range sys.Talkgroups {
+		if !opts.DryRun {
+			if err := db.ImportUpsertTalkgroup(ctx, t.systemID, tg.ID, tg.Label, "csv", tg.Tag, tg.Group, tg.Name, "", nil, nil, nil); err != nil {
+				log.Warn().Err(err).Int("tgid", tg.ID).Msg("failed to import talkgroup")
+				report.Talkgroups.Skip++
+				continue
+			}
+		}
+		report.Talkgroups.Update++
+	}
+	for _, u := range sys.Units {
+		if !opts.DryRun {
+			if err := db.ImportUpsertUnit(ctx, t.systemID, u.ID, u.Label, "csv", nil, nil); err != nil {
+				log.Warn().Err(err).Int("unit_id", u.ID).Msg("failed to import unit")
+				report.Units.Skip++
+				continue
+			}
+		}
+		report.Units.Update++
+	}
+	return t, nil
+}
+
+// importRdioCall creates one call, with its audio, transmissions and
+// frequencies. Failures are logged and counted, not returned, so one bad
+// call doesn't stop the import.
+func importRdioCall(ctx context.Context, db *database.DB, store storage.AudioStore, c *RdioCall, t *rdioTarget,
+	report *RdioImportReport, dryRun bool, log zerolog.Logger) {
+
+	if t.systemID > 0 {
+		if existingID, _, err := db.FindCallFuzzy(ctx, t.systemID, c.Talkgroup, c.DateTime); err == nil && existingID > 0 {
+			report.Calls.Skip++
+			return
+		}
+	}
+	if dryRun {
+		countRdioCall(c, t, report)
+		return
+	}
+
+	tg := t.talkgroups[c.Talkgroup]
+	row := buildRdioCallRow(c, t, tg)
+	callID, err := db.InsertCall(ctx, row)
+	if err != nil {
+		log.Warn().Err(err).Int64("rdio_call_id", c.ID).Int("tgid", c.Talkgroup).Msg("failed to insert call")
+		report.CallsFailed++
+		return
+	}
+	if cgID, err := db.UpsertCallGroup(ctx, t.systemID, c.Talkgroup, c.DateTime, tg.Label, tg.Name, tg.Tag, tg.Group); err == nil {
+		_ = db.SetCallGroupID(ctx, callID, c.DateTime, cgID)
+		_ = db.SetCallGroupPrimary(ctx, cgID, callID)
+	} else {
+		log.Warn().Err(err).Int("tgid", c.Talkgroup).Msg("failed to upsert call group")
+	}
+
+	if len(c.Audio) > 0 && !t.keepAudio {
+		report.AudioSkipped++
+	} else if len(c.Audio) > 0 {
+		ext := rdioAudioExt(c.AudioName, c.AudioType)
+		key := rdioAudioKey(t.shortName, c, ext)
+		contentType := c.AudioType
+		if contentType == "" {
+			contentType = rdioContentType(ext)
+		}
+		if err := store.Save(ctx, key, c.Audio, contentType); err != nil {
+			log.Warn().Err(err).Int64("rdio_call_id", c.ID).Msg("failed to save audio")
+		} else if err := db.AddCallAudioVariant(ctx, database.AudioVariant{
+			CallID:        callID,
+			CallStartTime: c.DateTime,
+			Variant:       database.AudioVariantOriginal,
+			AudioPath:     key,
+			AudioType:     ext,
+			AudioSize:     len(c.Audio),
+			Source:        "import",
+		}, true); err != nil {
+			log.Warn().Err(err).Msg("failed to set audio path")
+		} else {
+			report.AudioFiles++
+		}
+	}
+
+	if len(row.FreqList) > 0 {
+		rebuildCallFrequencies(ctx, db, callID, c.DateTime, row.FreqList, log)
+	}
+	if len(row.SrcList) > 0 {
+		rebuildCallTransmissions(ctx, db, callID, c.DateTime, row.SrcList, log)
+	}
+	countRdioCall(c, t, report)
+}
+
+// countRdioCall adds an imported (or, on a dry run, importable) call to
+// the report.
+func countRdioCall(c *RdioCall, t *rdioTarget, report *RdioImportReport) {
+	report.Calls.Create++
+	t.report.Calls++
+	if t.keepAudio {
+		report.AudioBytes += int64(c.AudioSize)
+		t.report.AudioBytes += int64(c.AudioSize)
+	}
+	at := c.DateTime
+	widen(&report.FirstCall, &report.LastCall, at)
+	widen(&t.report.FirstCall, &t.report.LastCall, at)
+}
+
+func widen(first, last **time.Time, t time.Time) {
+	if *first == nil || t.Before(**first) {
+		*first = &t
+	}
+	if *last == nil || t.After(**last) {
+		*last = &t
+	}
+}
+
+// buildRdioCallRow maps an rdio-scanner call to a call row. rdio-scanner
+// keeps no duration, so it comes from the audio header, else from where
+// the last frequency entry ends.
+func buildRdioCallRow(c *RdioCall, t *rdioTarget, tg RdioTalkgroup) *database.CallRow {
+	row := &database.CallRow{
+		SystemID:      t.systemID,
+		SiteID:        t.siteID,
+		Tgid:          c.Talkgroup,
+		TrCallID:      fmt.Sprintf("rdio-%d", c.ID),
+		StartTime:     c.DateTime,
+		AudioType:     rdioAudioExt(c.AudioName, c.AudioType),
+		SystemName:    t.shortName,
+		SiteShortName: t.shortName,
+		TgAlphaTag:    tg.Label,
+		TgDescription: tg.Name,
+		TgTag:         tg.Tag,
+		TgGroup:       tg.Group,
+		InstanceID:    RdioInstanceID,
+	}
+	if c.Frequency > 0 {
+		freq := c.Frequency
+		row.Freq = &freq
+	}
+
+	var length float64
+	if len(c.Audio) > 0 {
+		length, _ = audio.Duration(c.Audio, row.AudioType)
+	}
+	if length == 0 {
+		for _, f := range c.Frequencies {
+			length = max(length, f.Pos+f.Len)
+		}
+	}
+	if length > 0 {
+		d := float32(length)
+		stop := c.DateTime.Add(time.Duration(length * float64(time.Second)))
+		row.Duration = &d
+		row.StopTime = &stop
+	}
+
+	for _, p := range c.Patches {
+		if p != c.Talkgroup {
+			row.PatchedTgids = append(row.PatchedTgids, int32(p))
+		}
+	}
+
+	// Same shapes as calls recorded live (see buildSrcFreqJSON in ingest)
+	if len(c.Sources) > 0 {
+		type srcEntry struct {
+			Src       int     `json:"src"`
+			Time      int64   `json:"time"`
+			Pos       float64 `json:"pos"`
+			Duration  float64 `json:"duration,omitempty"`
+			Emergency int     `json:"emergency"`
+		}
+		entries := make([]srcEntry, len(c.Sources))
+		seen := make(map[int32]bool)
+		for i, s := range c.Sources {
+			var dur float64
+			if i+1 < len(c.Sources) {
+				dur = c.Sources[i+1].Pos - s.Pos
+			} else if length > 0 {
+				dur = length - s.Pos
+			}
+			entries[i] = srcEntry{Src: s.Src, Time: c.DateTime.Unix() + int64(s.Pos), Pos: s.Pos, Duration: dur}
+			if s.Src > 0 && !seen[int32(s.Src)] {
+				seen[int32(s.Src)] = true
+				row.UnitIDs = append(row.UnitIDs, int32(s.Src))
+			}
+		}
+		row.SrcList, _ = json.Marshal(entries)
+	}
+	if len(c.Frequencies) > 0 {
+		type freqEntry struct {
+			Freq       int64   `json:"freq"`
+			Time       int64   `json:"time"`
+			Pos        float64 `json:"pos"`
+			Len        float64 `json:"len"`
+			ErrorCount int     `json:"error_count"`
+			SpikeCount int     `json:"spike_count"`
+		}
+		entries := make([]freqEntry, len(c.Frequencies))
+		for i, f := range c.Frequencies {
+			entries[i] = freqEntry{
+				Freq:       f.Freq,
+				Time:       c.DateTime.Unix() + int64(f.Pos),
+				Pos:        f.Pos,
+				Len:        f.Len,
+				ErrorCount: f.ErrorCount,
+				SpikeCount: f.SpikeCount,
+			}
+		}
+		row.FreqList, _ = json.Marshal(entries)
+	}
+	return row
+}
+
+// ensureCallPartitions creates the month's partitions of the call tables,
+// which for old calls usually don't exist yet.
+func ensureCallPartitions(ctx context.Context, db *database.DB, month time.Time, log zerolog.Logger) {
+	for _, table := range []string{"calls", "call_frequencies", "call_transmissions"} {
+		result, err := db.CreateMonthlyPartition(ctx, table, month)
+		if err != nil {
+			log.Warn().Err(err).Str("table", table).Time("month", month).Msg("failed to create partition")
+		} else if !strings.Contains(result, "already exists") {
+			log.Info().Str("result", result).Str("table", table).Msg("created partition")
+		}
+	}
+}
+
+var nonShortName = regexp.MustCompile(`[^a-z0-9]+`)
+
+// rdioShortName turns a system label into a site short name, which is
+// also the first element of the audio storage keys.
+func rdioShortName(label string) string {
+	s := strings.Trim(nonShortName.ReplaceAllString(strings.ToLower(label), "_"), "_")
+	if s == "" {
+		return "rdio"
+	}
+	return s
+}
+
+// rdioAudioExt returns the audio type ("m4a", "mp3", ...) from the file
+// name, else from the MIME type.
+func rdioAudioExt(name, mimeType string) string {
+	if ext := strings.TrimPrefix(strings.ToLower(path.Ext(name)), "."); ext != "" {
+		return ext
+	}
+	switch strings.ToLower(mimeType) {
+	case "audio/mpeg", "audio/mp3":
+		return "mp3"
+	case "audio/wav", "audio/x-wav", "audio/wave":
+		return "wav"
+	case "audio/ogg", "audio/opus":
+		return "ogg"
+	}
+	return "m4a"
+}
+
+func rdioContentType(ext string) string {
+	switch ext {
+	case "m4a":
+		return "audio/mp4"
+	case "mp3":
+		return "audio/mpeg"
+	case "wav":
+		return "audio/wav"
+	case "ogg", "opus":
+		return "audio/ogg"
+	}
+	return "application/octet-stream"
+}
+
+// rdioAudioKey is the storage key for a call's audio, laid out like live
+// recordings: {short_name}/{YYYY-MM-DD}/{filename}. The rdio-scanner call
+// id keeps file names unique.
+func rdioAudioKey(shortName string, c *RdioCall, ext string) string {
+	return filepath.Join(shortName, c.DateTime.UTC().Format("2006-01-02"),
+		fmt.Sprintf("rdio-%d-%d.%s", c.ID, c.DateTime.Unix(), ext))
+}
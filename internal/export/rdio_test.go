@@ -0,0 +1,165 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseRdioTime(t *testing.T) {
+	want := time.Date(2024, 3, 5, 14, 7, 9, 0, time.UTC)
+	for _, s := range []string{
+		"2024-03-05 14:07:09+00:00",
+		"2024-03-05 09:07:09-05:00",
+		"2024-03-05 14:07:09",
+		"2024-03-05T14:07:09Z",
+		"1709647629",
+		"1709647629000",
+	} {
+		got, err := parseRdioTime(s)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("parseRdioTime(%q) = %v, %v", s, got, err)
+		}
+	}
+	if _, err := parseRdioTime("yesterday"); err == nil {
+		t.Error("expected error for unrecognized time")
+	}
+}
+
+func TestParseRdioTalkgroups(t *testing.T) {
+	// v5: labels inline
+	tgs, err := parseRdioTalkgroups(`[{"id":100,"label":"FD","name":"Fire Dispatch","tag":"Fire Dispatch","group":"Fire"}]`, nil, nil)
+	if err != nil || len(tgs) != 1 || tgs[0].Tag != "Fire Dispatch" || tgs[0].Group != "Fire" {
+		t.Errorf("v5: %+v, %v", tgs, err)
+	}
+	// v6: group and tag by id
+	tgs, err = parseRdioTalkgroups(`[{"id":200,"label":"PD","name":"Police","groupId":1,"tagId":2}]`,
+		map[int]string{1: "Law"}, map[int]string{2: "Law Dispatch"})
+	if err != nil || len(tgs) != 1 || tgs[0].ID != 200 || tgs[0].Group != "Law" || tgs[0].Tag != "Law Dispatch" {
+		t.Errorf("v6: %+v, %v", tgs, err)
+	}
+}
+
+func TestParseMySQLBatch(t *testing.T) {
+	rows := parseMySQLBatch([]byte("id\tlabel\ttalkgroups\n1\tCounty\tNULL\n2\tCity\t[]\n"))
+	if len(rows) != 2 || rows[0]["label"] != "County" || rows[1]["talkgroups"] != "[]" {
+		t.Fatalf("rows = %v", rows)
+	}
+	if _, ok := rows[0]["talkgroups"]; ok {
+		t.Error("NULL column should be left out")
+	}
+}
+
+func TestBuildRdioCallRow(t *testing.T) {
+	start := time.Unix(1709647629, 0).UTC()
+	c := &RdioCall{
+		ID: 7, System: 1, Talkgroup: 100, DateTime: start, Frequency: 851012500,
+		AudioName: "100-1709647629.m4a", AudioType: "audio/mp4",
+		Sources:     []RdioSource{{Pos: 0, Src: 5001}, {Pos: 2.5, Src: 5002}, {Pos: 4, Src: 5001}},
+		Frequencies: []RdioFrequency{{Freq: 851012500, Pos: 0, Len: 6, ErrorCount: 2}},
+		Patches:     []int{100, 101},
+	}
+	tr := &rdioTarget{systemID: 3, shortName: "county"}
+	row := buildRdioCallRow(c, tr, RdioTalkgroup{ID: 100, Label: "FD", Tag: "Fire Dispatch"})
+
+	if row.SystemID != 3 || row.Tgid != 100 || !row.StartTime.Equal(start) || row.TgAlphaTag != "FD" || row.AudioType != "m4a" {
+		t.Errorf("row = %+v", row)
+	}
+	if row.Duration == nil || *row.Duration != 6 || row.StopTime == nil || !row.StopTime.Equal(start.Add(6*time.Second)) {
+		t.Errorf("duration = %v, stop = %v", row.Duration, row.StopTime)
+	}
+	if len(row.UnitIDs) != 2 || len(row.PatchedTgids) != 1 || row.PatchedTgids[0] != 101 {
+		t.Errorf("units = %v, patched = %v", row.UnitIDs, row.PatchedTgids)
+	}
+	var srcs []struct {
+		Src      int     `json:"src"`
+		Time     int64   `json:"time"`
+		Duration float64 `json:"duration"`
+	}
+	if err := json.Unmarshal(row.SrcList, &srcs); err != nil || len(srcs) != 3 {
+		t.Fatalf("src_list = %s, %v", row.SrcList, err)
+	}
+	if srcs[1].Time != start.Unix()+2 || srcs[1].Duration != 1.5 || srcs[2].Duration != 2 {
+		t.Errorf("src_list = %s", row.SrcList)
+	}
+	var freqs []map[string]any
+	if err := json.Unmarshal(row.FreqList, &freqs); err != nil || len(freqs) != 1 || freqs[0]["error_count"] != 2.0 {
+		t.Errorf("freq_list = %s, %v", row.FreqList, err)
+	}
+}
+
+func TestRdioAudioNames(t *testing.T) {
+	if got := rdioShortName("Butler County / Fire"); got != "butler_county_fire" {
+		t.Errorf("short name = %q", got)
+	}
+	if got := rdioAudioExt("", "audio/mpeg"); got != "mp3" {
+		t.Errorf("ext = %q", got)
+	}
+	c := &RdioCall{ID: 42, DateTime: time.Unix(1709647629, 0)}
+	if got := rdioAudioKey("county", c, "m4a"); got != filepath.Join("county", "2024-03-05", "rdio-42-1709647629.m4a") {
+		t.Errorf("key = %q", got)
+	}
+}
+
+// TestRdioDBSQLite reads a minimal rdio-scanner v6 database through the
+// sqlite3 client.
+func TestRdioDBSQLite(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 not installed")
+	}
+	path := filepath.Join(t.TempDir(), "rdio-scanner.db")
+	setup := `
+CREATE TABLE rdioScannerSystems (_id INTEGER PRIMARY KEY, id INTEGER, label TEXT, talkgroups TEXT, units TEXT);
+CREATE TABLE rdioScannerGroups (_id INTEGER PRIMARY KEY, label TEXT);
+CREATE TABLE rdioScannerTags (_id INTEGER PRIMARY KEY, label TEXT);
+CREATE TABLE rdioScannerCalls (id INTEGER PRIMARY KEY, audio BLOB, audioName TEXT, audioType TEXT, dateTime DATETIME,
+  frequencies TEXT, frequency INTEGER, patches TEXT, source INTEGER, sources TEXT, system INTEGER, talkgroup INTEGER);
+INSERT INTO rdioScannerGroups VALUES (1, 'Fire');
+INSERT INTO rdioScannerTags VALUES (1, 'Fire Dispatch');
+INSERT INTO rdioScannerSystems VALUES (1, 11, 'County', '[{"id":100,"label":"FD","name":"Fire Dispatch","groupId":1,"tagId":1}]', '[{"id":5001,"label":"Engine 1"}]');
+INSERT INTO rdioScannerCalls VALUES (1, X'00010203', 'a.m4a', 'audio/mp4', '2024-03-05 14:07:09+00:00',
+  '[{"errorCount":1,"freq":851012500,"len":2,"pos":0,"spikeCount":0}]', 851012500, '[]', 5001, '[{"pos":0,"src":5001}]', 11, 100);
+INSERT INTO rdioScannerCalls VALUES (2, X'0405', 'b.mp3', 'audio/mpeg', '2024-03-05 14:08:00+00:00', NULL, 0, NULL, 0, NULL, 11, 100);
+`
+	if out, err := exec.Command("sqlite3", path, setup).CombinedOutput(); err != nil {
+		t.Fatalf("create fixture: %v: %s", err, out)
+	}
+
+	src, err := OpenRdioDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	systems, err := src.Systems(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(systems) != 1 || systems[0].ID != 11 || len(systems[0].Talkgroups) != 1 || systems[0].Talkgroups[0].Group != "Fire" ||
+		len(systems[0].Units) != 1 || systems[0].Units[0].Label != "Engine 1" {
+		t.Fatalf("systems = %+v", systems)
+	}
+
+	calls, err := src.Calls(ctx, 0, 1, true)
+	if err != nil || len(calls) != 1 {
+		t.Fatalf("calls = %+v, %v", calls, err)
+	}
+	c := calls[0]
+	if c.ID != 1 || c.System != 11 || c.Talkgroup != 100 || len(c.Audio) != 4 || c.Audio[3] != 3 ||
+		len(c.Sources) != 1 || len(c.Frequencies) != 1 || c.Frequencies[0].ErrorCount != 1 {
+		t.Errorf("call = %+v", c)
+	}
+	if !c.DateTime.Equal(time.Date(2024, 3, 5, 14, 7, 9, 0, time.UTC)) {
+		t.Errorf("dateTime = %v", c.DateTime)
+	}
+
+	calls, err = src.Calls(ctx, 1, 10, false)
+	if err != nil || len(calls) != 1 || calls[0].ID != 2 || calls[0].Audio != nil || calls[0].AudioSize != 2 {
+		t.Fatalf("calls after 1 = %+v, %v", calls, err)
+	}
+	if calls, err = src.Calls(ctx, 2, 10, false); err != nil || len(calls) != 0 {
+		t.Errorf("calls after 2 = %+v, %v", calls, err)
+	}
+}
@@ -137,6 +137,7 @@ type CallRecord struct {
 	Conventional  bool            `json:"conventional,omitempty"`
 	Encrypted     bool            `json:"encrypted,omitempty"`
 	Emergency     bool            `json:"emergency,omitempty"`
+	Interconnect  bool            `json:"interconnect,omitempty"`
 	PatchedTgids  []int           `json:"patched_tgids,omitempty"`
 	SrcList       json.RawMessage `json:"src_list,omitempty"`
 	FreqList      json.RawMessage `json:"freq_list,omitempty"`
@@ -0,0 +1,461 @@
+// Package expr is a small sandboxed expression language for filtering event
+// payloads, e.g.
+//
+//	tgid in [4001, 4002] && duration > 15 && text.contains('shots')
+//
+// Expressions are evaluated against JSON-shaped data (objects, arrays,
+// numbers, strings, booleans, null). There are no loops, assignments or
+// user-defined functions, and regular expressions are RE2 and compiled once
+// at Compile, so evaluation time is bounded by the expression's size, which
+// Compile caps.
+//
+// Operators, lowest precedence first: || ; && ; == != < <= > >= in ;
+// + - ; * / % ; unary ! and -. Field access is a.b or a["b"], list
+// elements a[0]. Functions: size(x), has(field), lower(s), upper(s).
+// String methods: s.contains(t), s.startsWith(t), s.endsWith(t),
+// s.matches('re'), s.size(), s.lower(), s.upper(). "x in list" tests
+// membership; "k in object" tests for a key.
+//
+// Missing fields are null. Comparing values of different types with == is
+// false; ordering them, arithmetic on non-numbers and && / || / ! on
+// non-booleans are evaluation errors, and a filter whose expression errors
+// does not match.
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Error is a compile or evaluation error at a byte offset in the source.
+type Error struct {
+	Pos int    `json:"position"`
+	Msg string `json:"message"`
+}
+
+func (e *Error) Error() string { return fmt.Sprintf("at position %d: %s", e.Pos, e.Msg) }
+
+// Program is a compiled expression. It is safe for concurrent use.
+type Program struct {
+	src  string
+	root *node
+}
+
+// Compile parses and checks an expression.
+func Compile(src string) (*Program, error) {
+	if len(src) > MaxLength {
+		return nil, errorf(MaxLength, "expression longer than %d bytes", MaxLength)
+	}
+	if strings.TrimSpace(src) == "" {
+		return nil, errorf(0, "empty expression")
+	}
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, errorf(t.pos, "unexpected %q", t.text)
+	}
+	return &Program{src: src, root: root}, nil
+}
+
+// String returns the source expression.
+func (p *Program) String() string { return p.src }
+
+// Fields returns the variables the expression reads as dotted paths
+// ("tgid", "call.units"), sorted. A path through an index stops there:
+// units[0].id is reported as "units".
+func (p *Program) Fields() []string {
+	seen := map[string]bool{}
+	var walk func(n *node)
+	walk = func(n *node) {
+		if path, ok := fieldPath(n); ok {
+			seen[path] = true
+			return
+		}
+		for _, a := range n.args {
+			walk(a)
+		}
+	}
+	walk(p.root)
+	out := make([]string, 0, len(seen))
+	for f := range seen {
+		out = append(out, f)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func fieldPath(n *node) (string, bool) {
+	switch n.kind {
+	case nIdent:
+		return n.op, true
+	case nField:
+		if base, ok := fieldPath(n.args[0]); ok {
+			return base + "." + n.op, true
+		}
+	}
+	return "", false
+}
+
+// Vars converts a JSON-encodable value (typically an event payload struct)
+// to the variables an expression is evaluated against.
+func Vars(v any) (map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var vars map[string]any
+	if err := json.Unmarshal(data, &vars); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// Eval evaluates the expression against vars.
+func (p *Program) Eval(vars map[string]any) (any, error) {
+	return eval(p.root, vars)
+}
+
+// Match reports whether the expression evaluates to true. Errors and
+// non-boolean results don't match.
+func (p *Program) Match(vars map[string]any) bool {
+	v, err := p.Eval(vars)
+	b, ok := v.(bool)
+	return err == nil && ok && b
+}
+
+func eval(n *node, vars map[string]any) (any, error) {
+	switch n.kind {
+	case nLiteral:
+		return n.val, nil
+	case nIdent:
+		return normalize(vars[n.op]), nil
+	case nField:
+		x, err := eval(n.args[0], vars)
+		if err != nil {
+			return nil, err
+		}
+		if m, ok := x.(map[string]any); ok {
+			return normalize(m[n.op]), nil
+		}
+		return nil, nil
+	case nIndex:
+		return evalIndex(n, vars)
+	case nList:
+		out := make([]any, len(n.args))
+		for i, a := range n.args {
+			v, err := eval(a, vars)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	case nUnary:
+		x, err := eval(n.args[0], vars)
+		if err != nil {
+			return nil, err
+		}
+		if n.op == "!" {
+			b, ok := x.(bool)
+			if !ok {
+				return nil, errorf(n.pos, "! needs a boolean, got %s", typeName(x))
+			}
+			return !b, nil
+		}
+		f, ok := x.(float64)
+		if !ok {
+			return nil, errorf(n.pos, "- needs a number, got %s", typeName(x))
+		}
+		return -f, nil
+	case nBinary:
+		return evalBinary(n, vars)
+	case nCall:
+		return evalCall(n, vars)
+	}
+	return nil, errorf(n.pos, "invalid expression")
+}
+
+func evalIndex(n *node, vars map[string]any) (any, error) {
+	x, err := eval(n.args[0], vars)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := eval(n.args[1], vars)
+	if err != nil {
+		return nil, err
+	}
+	switch c := x.(type) {
+	case map[string]any:
+		k, ok := idx.(string)
+		if !ok {
+			return nil, errorf(n.pos, "object key must be a string, got %s", typeName(idx))
+		}
+		return normalize(c[k]), nil
+	case []any:
+		f, ok := idx.(float64)
+		if !ok || f != math.Trunc(f) {
+			return nil, errorf(n.pos, "list index must be an integer, got %s", typeName(idx))
+		}
+		if f < 0 || int(f) >= len(c) {
+			return nil, nil
+		}
+		return normalize(c[int(f)]), nil
+	case nil:
+		return nil, nil
+	}
+	return nil, errorf(n.pos, "cannot index %s", typeName(x))
+}
+
+func evalBinary(n *node, vars map[string]any) (any, error) {
+	l, err := eval(n.args[0], vars)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "&&" || n.op == "||" {
+		lb, ok := l.(bool)
+		if !ok {
+			return nil, errorf(n.pos, "%s needs booleans, got %s", n.op, typeName(l))
+		}
+		if (n.op == "&&" && !lb) || (n.op == "||" && lb) {
+			return lb, nil
+		}
+		r, err := eval(n.args[1], vars)
+		if err != nil {
+			return nil, err
+		}
+		rb, ok := r.(bool)
+		if !ok {
+			return nil, errorf(n.pos, "%s needs booleans, got %s", n.op, typeName(r))
+		}
+		return rb, nil
+	}
+	r, err := eval(n.args[1], vars)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+	case "in":
+		switch c := r.(type) {
+		case []any:
+			for _, v := range c {
+				if equal(l, normalize(v)) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case map[string]any:
+			k, ok := l.(string)
+			if !ok {
+				return false, nil
+			}
+			_, found := c[k]
+			return found, nil
+		case nil:
+			return false, nil
+		}
+		return nil, errorf(n.pos, "in needs a list or object on the right, got %s", typeName(r))
+	case "<", "<=", ">", ">=":
+		var c int
+		switch lv := l.(type) {
+		case float64:
+			rv, ok := r.(float64)
+			if !ok {
+				return nil, errorf(n.pos, "cannot compare number with %s", typeName(r))
+			}
+			c = compareFloat(lv, rv)
+		case string:
+			rv, ok := r.(string)
+			if !ok {
+				return nil, errorf(n.pos, "cannot compare string with %s", typeName(r))
+			}
+			c = strings.Compare(lv, rv)
+		default:
+			return nil, errorf(n.pos, "cannot order %s", typeName(l))
+		}
+		switch n.op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	case "+":
+		if ls, ok := l.(string); ok {
+			if rs, ok := r.(string); ok {
+				if len(ls)+len(rs) > MaxLength*16 {
+					return nil, errorf(n.pos, "string too long")
+				}
+				return ls + rs, nil
+			}
+		}
+	}
+	lf, lok := l.(float64)
+	rf, rok := r.(float64)
+	if !lok || !rok {
+		return nil, errorf(n.pos, "%s needs numbers, got %s and %s", n.op, typeName(l), typeName(r))
+	}
+	switch n.op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/":
+		if rf == 0 {
+			return nil, errorf(n.pos, "division by zero")
+		}
+		return lf / rf, nil
+	case "%":
+		if rf == 0 {
+			return nil, errorf(n.pos, "division by zero")
+		}
+		return math.Mod(lf, rf), nil
+	}
+	return nil, errorf(n.pos, "unknown operator %q", n.op)
+}
+
+func evalCall(n *node, vars map[string]any) (any, error) {
+	if n.op == "has" {
+		v, err := eval(n.args[0], vars)
+		if err != nil {
+			return nil, err
+		}
+		return v != nil, nil
+	}
+	args := make([]any, len(n.args))
+	for i, a := range n.args {
+		v, err := eval(a, vars)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	if n.op == "size" {
+		switch x := args[0].(type) {
+		case string:
+			return float64(len([]rune(x))), nil
+		case []any:
+			return float64(len(x)), nil
+		case map[string]any:
+			return float64(len(x)), nil
+		}
+		return nil, errorf(n.pos, "size() needs a string, list or object, got %s", typeName(args[0]))
+	}
+	s, ok := args[0].(string)
+	if !ok {
+		return nil, errorf(n.pos, "%s() needs a string, got %s", n.op, typeName(args[0]))
+	}
+	switch n.op {
+	case "lower":
+		return strings.ToLower(s), nil
+	case "upper":
+		return strings.ToUpper(s), nil
+	case "matches":
+		return n.re.MatchString(s), nil
+	}
+	t, ok := args[1].(string)
+	if !ok {
+		return nil, errorf(n.pos, "%s() needs a string argument, got %s", n.op, typeName(args[1]))
+	}
+	switch n.op {
+	case "contains":
+		return strings.Contains(s, t), nil
+	case "startsWith":
+		return strings.HasPrefix(s, t), nil
+	case "endsWith":
+		return strings.HasSuffix(s, t), nil
+	}
+	return nil, errorf(n.pos, "unknown function %q", n.op)
+}
+
+// normalize converts Go numeric types to float64 so values from decoded
+// JSON and from Go maps compare alike.
+func normalize(v any) any {
+	switch x := v.(type) {
+	case int:
+		return float64(x)
+	case int32:
+		return float64(x)
+	case int64:
+		return float64(x)
+	case float32:
+		return float64(x)
+	case json.Number:
+		f, _ := x.Float64()
+		return f
+	}
+	return v
+}
+
+func equal(a, b any) bool {
+	switch x := a.(type) {
+	case nil:
+		return b == nil
+	case float64:
+		y, ok := b.(float64)
+		return ok && x == y
+	case string:
+		y, ok := b.(string)
+		return ok && x == y
+	case bool:
+		y, ok := b.(bool)
+		return ok && x == y
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(normalize(x[i]), normalize(y[i])) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case []any:
+		return "list"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
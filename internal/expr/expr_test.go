@@ -0,0 +1,153 @@
+package expr
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func vars(t *testing.T, s string) map[string]any {
+	t.Helper()
+	var m map[string]any
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestEval(t *testing.T) {
+	env := vars(t, `{
+		"tgid": 4001, "duration": 22.5, "text": "Shots fired on Main",
+		"emergency": false, "units": [{"unit_id": 7}], "call": {"sys_name": "metro", "freq": 851000000},
+		"tags": ["fire", "ems"], "empty": ""
+	}`)
+	env["count"] = 3 // Go ints compare like JSON numbers
+
+	tests := []struct {
+		src  string
+		want any
+	}{
+		{`tgid in [4001, 4002] && duration > 15 && text.contains('Shots')`, true},
+		{`tgid in [4002]`, false},
+		{`!emergency || tgid == 1`, true},
+		{`text.lower().contains("shots") && text.startsWith('Shots') && text.endsWith("Main")`, true},
+		{`text.matches('(?i)^shots\\s+fired')`, true},
+		{`call.sys_name == 'metro' && call["freq"] / 1000000 == 851`, true},
+		{`units[0].unit_id == 7 && units[5] == null`, true},
+		{`size(tags) == 2 && tags.size == null`, true},
+		{`'fire' in tags && 'sys_name' in call && !('x' in call)`, true},
+		{`has(call.sys_name) && !has(call.missing) && !has(nothing.deeper)`, true},
+		{`missing == null && missing != 0`, true},
+		{`count * 2 + 1`, 7.0},
+		{`-duration % 10`, -2.5},
+		{`"a" + 'b' == "ab"`, true},
+		{`size(empty) == 0 && upper(call.sys_name) == "METRO"`, true},
+		{`tgid == '4001'`, false},
+		{`[1, 'a'] == [1, 'a']`, true},
+		{`false && missing > 1`, false}, // short-circuit skips the error
+	}
+	for _, tt := range tests {
+		p, err := Compile(tt.src)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tt.src, err)
+			continue
+		}
+		got, err := p.Eval(env)
+		if err != nil {
+			t.Errorf("Eval(%q): %v", tt.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Eval(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	env := map[string]any{"tgid": 1.0, "text": "x"}
+	for _, src := range []string{
+		`missing > 1`,
+		`tgid > 'a'`,
+		`tgid && true`,
+		`!tgid`,
+		`text - 1`,
+		`tgid / 0`,
+		`tgid in 5`,
+		`text.contains(1)`,
+		`tgid.contains('a')`,
+		`tgid[0]`,
+	} {
+		p, err := Compile(src)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", src, err)
+			continue
+		}
+		if _, err := p.Eval(env); err == nil {
+			t.Errorf("Eval(%q): expected error", src)
+		}
+		if p.Match(env) {
+			t.Errorf("Match(%q) = true on error", src)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		pos int
+	}{
+		{``, 0},
+		{`tgid ==`, 7},
+		{`tgid = 1`, 5},
+		{`a < b < c`, 6},
+		{`foo(1)`, 0},
+		{`text.shout()`, 5},
+		{`text.contains()`, 5},
+		{`text.matches(pattern)`, 13},
+		{`text.matches('(')`, 13},
+		{`'open`, 0},
+		{`tgid in [1, 2`, 13},
+		{`tgid # 1`, 5},
+		{`has(1)`, 4},
+		{`(1) 2`, 4},
+	}
+	for _, tt := range tests {
+		_, err := Compile(tt.src)
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Errorf("Compile(%q) = %v, want *Error", tt.src, err)
+			continue
+		}
+		if e.Pos != tt.pos {
+			t.Errorf("Compile(%q) error at %d (%s), want %d", tt.src, e.Pos, e.Msg, tt.pos)
+		}
+	}
+}
+
+func TestCompileLimits(t *testing.T) {
+	if _, err := Compile(strings.Repeat(" ", MaxLength) + "1"); err == nil {
+		t.Error("expected length error")
+	}
+	if _, err := Compile(strings.Repeat("(", 40) + "1" + strings.Repeat(")", 40)); err == nil {
+		t.Error("expected depth error")
+	}
+	if _, err := Compile(strings.Repeat("!", 40) + "true"); err == nil {
+		t.Error("expected depth error for unary chain")
+	}
+	if _, err := Compile("1" + strings.Repeat(" + 1", 600)); err == nil {
+		t.Error("expected node count error")
+	}
+}
+
+func TestFields(t *testing.T) {
+	p, err := Compile(`tgid in [1] && call.units[0].id == 2 && text.contains(call.sys_name) && has(a.b.c)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.b.c", "call.sys_name", "call.units", "text", "tgid"}
+	if got := p.Fields(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,427 @@
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Limits keep compile and evaluation cost bounded for untrusted input.
+const (
+	MaxLength = 4096 // source bytes
+	maxNodes  = 512
+	maxDepth  = 32
+)
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp // operators and punctuation
+)
+
+type token struct {
+	kind tokKind
+	text string // operator, identifier, or decoded string literal
+	num  float64
+	pos  int
+}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isDigit(c):
+			start := i
+			for i < len(src) && isDigit(src[i]) {
+				i++
+			}
+			if i+1 < len(src) && src[i] == '.' && isDigit(src[i+1]) {
+				i++
+				for i < len(src) && isDigit(src[i]) {
+					i++
+				}
+			}
+			n, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, errorf(start, "invalid number %q", src[start:i])
+			}
+			toks = append(toks, token{kind: tokNumber, num: n, text: src[start:i], pos: start})
+		case isIdentStart(c):
+			start := i
+			for i < len(src) && (isIdentStart(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			toks = append(toks, token{kind: tokIdent, text: src[start:i], pos: start})
+		case c == '\'' || c == '"':
+			start := i
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(src) {
+					return nil, errorf(start, "unterminated string")
+				}
+				if src[i] == c {
+					i++
+					break
+				}
+				if src[i] == '\\' && i+1 < len(src) {
+					switch src[i+1] {
+					case 'n':
+						b.WriteByte('\n')
+					case 't':
+						b.WriteByte('\t')
+					case '\\', '\'', '"':
+						b.WriteByte(src[i+1])
+					default:
+						return nil, errorf(i, "invalid escape \\%c", src[i+1])
+					}
+					i += 2
+					continue
+				}
+				b.WriteByte(src[i])
+				i++
+			}
+			toks = append(toks, token{kind: tokString, text: b.String(), pos: start})
+		default:
+			op := ""
+			for _, o := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "%", "(", ")", "[", "]", ",", "."} {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, errorf(i, "unexpected character %q", c)
+			}
+			toks = append(toks, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+func isDigit(c byte) bool      { return c >= '0' && c <= '9' }
+func isIdentStart(c byte) bool { return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+
+type nodeKind int
+
+const (
+	nLiteral nodeKind = iota
+	nIdent            // top-level variable
+	nField            // x.name
+	nIndex            // x[i]
+	nList             // [a, b]
+	nUnary            // !x, -x
+	nBinary           // x op y
+	nCall             // f(args) or x.f(args)
+)
+
+type node struct {
+	kind nodeKind
+	pos  int
+	op   string // operator, identifier, field or function name
+	val  any    // literal value
+	args []*node
+	re   *regexp.Regexp // compiled matches() pattern
+}
+
+// functions and methods: name → allowed argument counts, receiver
+// included for methods.
+var (
+	functions = map[string]int{"size": 1, "has": 1, "lower": 1, "upper": 1}
+	methods   = map[string]int{
+		"contains": 1, "startsWith": 1, "endsWith": 1, "matches": 1,
+		"size": 0, "lower": 0, "upper": 0,
+	}
+)
+
+type parser struct {
+	toks  []token
+	i     int
+	nodes int
+	depth int
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) isOp(ops ...string) bool {
+	t := p.peek()
+	if t.kind == tokOp {
+		for _, o := range ops {
+			if t.text == o {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.isOp(op) {
+		return errorf(p.peek().pos, "expected %q", op)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) newNode(n *node) (*node, error) {
+	p.nodes++
+	if p.nodes > maxNodes {
+		return nil, errorf(n.pos, "expression too complex (more than %d nodes)", maxNodes)
+	}
+	return n, nil
+}
+
+func (p *parser) parseExpr() (*node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		return nil, errorf(p.peek().pos, "expression nested too deeply (more than %d levels)", maxDepth)
+	}
+	return p.parseBinary(0)
+}
+
+// precedence levels, lowest first.
+var levels = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">=", "in"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) atLevelOp(level int) (string, bool) {
+	t := p.peek()
+	for _, o := range levels[level] {
+		if (t.kind == tokOp && t.text == o) || (o == "in" && t.kind == tokIdent && t.text == "in") {
+			return o, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) parseBinary(level int) (*node, error) {
+	if level == len(levels) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.atLevelOp(level)
+		if !ok {
+			return left, nil
+		}
+		pos := p.next().pos
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		if left, err = p.newNode(&node{kind: nBinary, pos: pos, op: op, args: []*node{left, right}}); err != nil {
+			return nil, err
+		}
+		if level == 2 {
+			// Comparisons don't chain: a < b < c is an error.
+			if _, ok := p.atLevelOp(level); ok {
+				return nil, errorf(p.peek().pos, "comparisons cannot be chained")
+			}
+			return left, nil
+		}
+	}
+}
+
+func (p *parser) parseUnary() (*node, error) {
+	if p.isOp("!", "-") {
+		t := p.next()
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.depth > maxDepth {
+			return nil, errorf(t.pos, "expression nested too deeply (more than %d levels)", maxDepth)
+		}
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return p.newNode(&node{kind: nUnary, pos: t.pos, op: t.text, args: []*node{x}})
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (*node, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.isOp("."):
+			p.next()
+			name := p.next()
+			if name.kind != tokIdent {
+				return nil, errorf(name.pos, "expected field or method name after '.'")
+			}
+			if p.isOp("(") {
+				args, err := p.parseArgs()
+				if err != nil {
+					return nil, err
+				}
+				if x, err = p.method(name, x, args); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if x, err = p.newNode(&node{kind: nField, pos: name.pos, op: name.text, args: []*node{x}}); err != nil {
+				return nil, err
+			}
+		case p.isOp("["):
+			pos := p.next().pos
+			idx, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			if x, err = p.newNode(&node{kind: nIndex, pos: pos, args: []*node{x, idx}}); err != nil {
+				return nil, err
+			}
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *parser) method(name token, recv *node, args []*node) (*node, error) {
+	n, ok := methods[name.text]
+	if !ok {
+		return nil, errorf(name.pos, "unknown method %q", name.text)
+	}
+	if len(args) != n {
+		return nil, errorf(name.pos, "%s() takes %d argument(s), got %d", name.text, n, len(args))
+	}
+	call := &node{kind: nCall, pos: name.pos, op: name.text, args: append([]*node{recv}, args...)}
+	if name.text == "matches" {
+		lit := args[0]
+		s, ok := lit.val.(string)
+		if lit.kind != nLiteral || !ok {
+			return nil, errorf(lit.pos, "matches() pattern must be a string literal")
+		}
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, errorf(lit.pos, "invalid pattern: %v", err)
+		}
+		call.re = re
+	}
+	return p.newNode(call)
+}
+
+func (p *parser) parseArgs() ([]*node, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var args []*node
+	for !p.isOp(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		a, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, a)
+	}
+	p.next()
+	return args, nil
+}
+
+func (p *parser) parsePrimary() (*node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return p.newNode(&node{kind: nLiteral, pos: t.pos, val: t.num})
+	case tokString:
+		return p.newNode(&node{kind: nLiteral, pos: t.pos, val: t.text})
+	case tokIdent:
+		switch t.text {
+		case "true", "false":
+			return p.newNode(&node{kind: nLiteral, pos: t.pos, val: t.text == "true"})
+		case "null":
+			return p.newNode(&node{kind: nLiteral, pos: t.pos})
+		case "in":
+			return nil, errorf(t.pos, "unexpected 'in'")
+		}
+		if p.isOp("(") {
+			args, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			n, ok := functions[t.text]
+			if !ok {
+				return nil, errorf(t.pos, "unknown function %q", t.text)
+			}
+			if len(args) != n {
+				return nil, errorf(t.pos, "%s() takes %d argument(s), got %d", t.text, n, len(args))
+			}
+			if t.text == "has" && args[0].kind != nIdent && args[0].kind != nField && args[0].kind != nIndex {
+				return nil, errorf(args[0].pos, "has() takes a field, e.g. has(call.units)")
+			}
+			return p.newNode(&node{kind: nCall, pos: t.pos, op: t.text, args: args})
+		}
+		return p.newNode(&node{kind: nIdent, pos: t.pos, op: t.text})
+	case tokOp:
+		switch t.text {
+		case "(":
+			x, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		case "[":
+			list := &node{kind: nList, pos: t.pos}
+			for !p.isOp("]") {
+				if len(list.args) > 0 {
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+				x, err := p.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				list.args = append(list.args, x)
+			}
+			p.next()
+			return p.newNode(list)
+		}
+	case tokEOF:
+		return nil, errorf(t.pos, "unexpected end of expression")
+	}
+	return nil, errorf(t.pos, "unexpected %q", t.text)
+}
+
+func errorf(pos int, format string, args ...any) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
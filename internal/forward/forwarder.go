@@ -0,0 +1,375 @@
+// Package forward relays calls to a downstream rdio-scanner.
+//
+// With forwarding on, tr-engine acts as a relay: every finished call with
+// audio on an enabled system, however it was ingested (MQTT, file watch or
+// upload), is posted with its metadata to the downstream's
+// /api/call-upload, the same API trunk-recorder's rdioscanner_uploader
+// plugin uses. Systems are enabled one at a time in forward_systems, each
+// mapped to a system ID on the downstream. call_forwards is the retry
+// queue: failures are retried with backoff, calls the downstream refuses
+// are parked as rejected until reset.
+package forward
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/storage"
+)
+
+// Options configures a Forwarder.
+type Options struct {
+	URL       string        // downstream rdio-scanner base URL
+	APIKey    string        // rdio-scanner API key
+	Delay     time.Duration // minimum time since a call ended before sending it
+	Backfill  time.Duration // calls that started longer ago are not sent
+	Interval  time.Duration // how often to look for calls to send
+	BatchSize int           // calls per query (default 100)
+}
+
+// RunResult summarizes one forwarding run.
+type RunResult struct {
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Forwarded  int        `json:"forwarded"`
+	Failed     int        `json:"failed"`
+	Rejected   int        `json:"rejected"`
+	Bytes      int64      `json:"bytes"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Totals counts forwarding work since startup.
+type Totals struct {
+	Forwarded int64 `json:"forwarded"`
+	Failed    int64 `json:"failed"`
+	Rejected  int64 `json:"rejected"`
+	Bytes     int64 `json:"bytes"`
+}
+
+// Status reports the forwarder's configuration and progress.
+type Status struct {
+	URL      string `json:"url"`
+	Backfill string `json:"backfill"`
+	Delay    string `json:"delay"`
+	Running  bool   `json:"running"`
+	database.ForwardStats
+	Systems []database.ForwardSystem `json:"systems"`
+	Totals  Totals                   `json:"totals"`
+	LastRun *RunResult               `json:"last_run"`
+}
+
+// Forwarder sends finished calls to the downstream in the background.
+type Forwarder struct {
+	db     *database.DB
+	store  storage.AudioStore
+	opts   Options
+	client *http.Client
+	log    zerolog.Logger
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+
+	running   atomic.Bool
+	forwarded atomic.Int64
+	failed    atomic.Int64
+	rejected  atomic.Int64
+	bytes     atomic.Int64
+
+	mu      sync.Mutex
+	lastRun *RunResult
+}
+
+// New creates a forwarder.
+func New(db *database.DB, store storage.AudioStore, opts Options, log zerolog.Logger) *Forwarder {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	opts.URL = strings.TrimRight(opts.URL, "/")
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Forwarder{
+		db:     db,
+		store:  store,
+		opts:   opts,
+		client: &http.Client{Timeout: 2 * time.Minute},
+		log:    log.With().Str("component", "forward").Logger(),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (f *Forwarder) Start() { go f.loop() }
+
+// Stop ends background work, including a run in progress.
+func (f *Forwarder) Stop() { f.stopOnce.Do(f.cancel) }
+
+func (f *Forwarder) loop() {
+	ticker := time.NewTicker(f.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if f.running.CompareAndSwap(false, true) {
+				f.run()
+				f.running.Store(false)
+			}
+		case <-f.ctx.Done():
+			return
+		}
+	}
+}
+
+// run sends every eligible call, a batch at a time. The caller holds running.
+func (f *Forwarder) run() {
+	res := &RunResult{StartedAt: time.Now()}
+	err := f.sendPending(res)
+
+	now := time.Now()
+	f.mu.Lock()
+	res.FinishedAt = &now
+	if err != nil {
+		res.Error = err.Error()
+	}
+	f.lastRun = res
+	f.mu.Unlock()
+
+	switch {
+	case err != nil:
+		f.log.Warn().Err(err).Int("forwarded", res.Forwarded).Int("failed", res.Failed).Msg("forwarding run failed")
+	case res.Failed > 0 || res.Rejected > 0:
+		f.log.Warn().Int("forwarded", res.Forwarded).Int("failed", res.Failed).Int("rejected", res.Rejected).
+			Msg("forwarding run finished with failures")
+	case res.Forwarded > 0:
+		f.log.Debug().Int("forwarded", res.Forwarded).Int64("bytes", res.Bytes).Msg("calls forwarded")
+	}
+}
+
+func (f *Forwarder) sendPending(res *RunResult) error {
+	// Failed calls wait 5 intervals per attempt so far before the next one.
+	retryAfter := 5 * f.opts.Interval
+	for {
+		if err := f.ctx.Err(); err != nil {
+			return err
+		}
+		now := time.Now()
+		batch, err := f.db.ListForwardCandidates(f.ctx,
+			now.Add(-f.opts.Backfill), now.Add(-f.opts.Delay), retryAfter, f.opts.BatchSize)
+		if err != nil {
+			return fmt.Errorf("list candidates: %w", err)
+		}
+		failed := 0
+		for _, c := range batch {
+			if err := f.ctx.Err(); err != nil {
+				return err
+			}
+			if !f.forward(c, res) {
+				failed++
+			}
+		}
+		// A batch of nothing but failures means the downstream is down;
+		// leave the rest for the next run.
+		if len(batch) < f.opts.BatchSize || failed == len(batch) {
+			return nil
+		}
+	}
+}
+
+// forward sends one call and records the outcome. Returns false if it failed.
+func (f *Forwarder) forward(c database.ForwardCandidate, res *RunResult) bool {
+	ctx, cancel := context.WithTimeout(f.ctx, 5*time.Minute)
+	defer cancel()
+
+	data, err := f.readAudio(ctx, c.AudioPath)
+	if err == nil {
+		err = f.push(ctx, c, data)
+	}
+	if err != nil {
+		rejected := isRejected(err)
+		f.log.Debug().Err(err).Int64("call_id", c.Ref.CallID).Bool("rejected", rejected).Msg("forwarding failed")
+		if recErr := f.db.RecordForwardFailure(ctx, c.Ref, err.Error(), rejected); recErr != nil {
+			f.log.Warn().Err(recErr).Int64("call_id", c.Ref.CallID).Msg("failed to record forwarding failure")
+		}
+		if rejected {
+			f.log.Warn().Err(err).Int64("call_id", c.Ref.CallID).Msg("downstream rejected call")
+			res.Rejected++
+			f.rejected.Add(1)
+		} else {
+			res.Failed++
+			f.failed.Add(1)
+		}
+		return false
+	}
+	if err := f.db.MarkCallForwarded(ctx, c.Ref); err != nil {
+		f.log.Warn().Err(err).Int64("call_id", c.Ref.CallID).Msg("failed to mark call forwarded")
+	}
+	res.Forwarded++
+	res.Bytes += int64(len(data))
+	f.forwarded.Add(1)
+	f.bytes.Add(int64(len(data)))
+	return true
+}
+
+func (f *Forwarder) readAudio(ctx context.Context, key string) ([]byte, error) {
+	rc, err := f.store.Open(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("open audio: %w", err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("read audio: %w", err)
+	}
+	return data, nil
+}
+
+// downstreamError is an error response from the downstream rdio-scanner.
+type downstreamError struct {
+	status int
+	msg    string
+}
+
+func (e *downstreamError) Error() string {
+	if e.msg == "" {
+		return fmt.Sprintf("downstream returned %d", e.status)
+	}
+	return fmt.Sprintf("downstream returned %d: %s", e.status, e.msg)
+}
+
+// isRejected reports whether the downstream refused the call itself
+// (rdio-scanner answers 417 for incomplete call data), so retrying won't
+// help. A bad API key, wrong URL, timeouts, rate limits and server errors
+// are retried.
+func isRejected(err error) bool {
+	var de *downstreamError
+	if !errors.As(err, &de) || de.status < 400 || de.status >= 500 {
+		return false
+	}
+	switch de.status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+		http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return true
+}
+
+// push posts c with its audio to the downstream's call-upload API.
+func (f *Forwarder) push(ctx context.Context, c database.ForwardCandidate, audio []byte) error {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fields := uploadFields(c)
+	fields["key"] = f.opts.APIKey
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			return err
+		}
+	}
+	fw, err := mw.CreateFormFile("audio", fields["audioName"])
+	if err != nil {
+		return err
+	}
+	if _, err := fw.Write(audio); err != nil {
+		return err
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.opts.URL+"/api/call-upload", &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	if resp.StatusCode/100 != 2 {
+		return &downstreamError{status: resp.StatusCode, msg: strings.TrimSpace(string(body))}
+	}
+	return nil
+}
+
+// uploadFields builds the rdio-scanner call-upload fields for c, all but the
+// API key and the audio itself.
+func uploadFields(c database.ForwardCandidate) map[string]string {
+	f := map[string]string{
+		"system":      strconv.Itoa(c.RemoteSystem),
+		"talkgroup":   strconv.Itoa(c.Tgid),
+		"dateTime":    strconv.FormatInt(c.Ref.StartTime.Unix(), 10),
+		"systemLabel": c.SysName,
+		"audioName":   path.Base(c.AudioPath),
+		"audioType":   storage.ContentTypeFromExt(path.Ext(c.AudioPath)),
+	}
+	set := func(k, v string) {
+		if v != "" {
+			f[k] = v
+		}
+	}
+	if c.Freq != nil {
+		f["frequency"] = strconv.FormatInt(*c.Freq, 10)
+	}
+	if c.Source != nil {
+		f["source"] = strconv.Itoa(*c.Source)
+	}
+	set("talkgroupLabel", c.AlphaTag)
+	set("talkgroupName", c.Description)
+	set("talkgroupTag", c.Tag)
+	set("talkgroupGroup", c.Group)
+	if len(c.Sources) > 0 {
+		f["sources"] = string(c.Sources)
+	}
+	if len(c.Frequencies) > 0 {
+		f["frequencies"] = string(c.Frequencies)
+	}
+	return f
+}
+
+// Status returns the backfill window's counts, the configured systems and
+// run progress.
+func (f *Forwarder) Status(ctx context.Context) (Status, error) {
+	stats, err := f.db.GetForwardStats(ctx, time.Now().Add(-f.opts.Backfill))
+	if err != nil {
+		return Status{}, err
+	}
+	systems, err := f.db.ListForwardSystems(ctx)
+	if err != nil {
+		return Status{}, err
+	}
+	s := Status{
+		URL:          f.opts.URL,
+		Backfill:     f.opts.Backfill.String(),
+		Delay:        f.opts.Delay.String(),
+		Running:      f.running.Load(),
+		ForwardStats: stats,
+		Systems:      systems,
+		Totals: Totals{
+			Forwarded: f.forwarded.Load(),
+			Failed:    f.failed.Load(),
+			Rejected:  f.rejected.Load(),
+			Bytes:     f.bytes.Load(),
+		},
+	}
+	f.mu.Lock()
+	if f.lastRun != nil {
+		lr := *f.lastRun
+		s.LastRun = &lr
+	}
+	f.mu.Unlock()
+	return s, nil
+}
@@ -0,0 +1,122 @@
+package forward
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// fakeRdio mimics rdio-scanner's /api/call-upload: it checks the API key and
+// answers 417 for a call without a talkgroup.
+type fakeRdio struct {
+	fields map[string]string
+	audio  []byte
+	name   string
+}
+
+func (f *fakeRdio) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/api/call-upload" {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.FormValue("key") != "secret" {
+		http.Error(w, "Invalid API key", http.StatusUnauthorized)
+		return
+	}
+	if r.FormValue("talkgroup") == "0" {
+		http.Error(w, "Incomplete call data: no talkgroup", http.StatusExpectationFailed)
+		return
+	}
+	f.fields = map[string]string{}
+	for k, v := range r.MultipartForm.Value {
+		f.fields[k] = v[0]
+	}
+	file, hdr, err := r.FormFile("audio")
+	if err != nil {
+		http.Error(w, "Incomplete call data: no audio", http.StatusExpectationFailed)
+		return
+	}
+	defer file.Close()
+	f.audio, _ = io.ReadAll(file)
+	f.name = hdr.Filename
+	fmt.Fprint(w, "Call imported successfully.")
+}
+
+func TestPush(t *testing.T) {
+	rdio := &fakeRdio{}
+	srv := httptest.NewServer(rdio)
+	defer srv.Close()
+
+	f := New(nil, nil, Options{URL: srv.URL + "/", APIKey: "secret"}, zerolog.Nop())
+	freq := int64(851012500)
+	src := 1234
+	c := database.ForwardCandidate{
+		Ref:          database.CallRef{CallID: 7, StartTime: time.Unix(1772373600, 0)},
+		RemoteSystem: 12,
+		SysName:      "warco",
+		Tgid:         9131,
+		Freq:         &freq,
+		Source:       &src,
+		AlphaTag:     "Fire Dispatch",
+		AudioPath:    "warco/2026-03-01/9131-1772373600.m4a",
+		Sources:      []byte(`[{"pos":0,"src":1234}]`),
+	}
+	audio := []byte("0123456789")
+
+	if err := f.push(context.Background(), c, audio); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	got := rdio.fields
+	if got["key"] != "secret" || got["system"] != "12" || got["talkgroup"] != "9131" ||
+		got["dateTime"] != "1772373600" || got["frequency"] != "851012500" || got["source"] != "1234" ||
+		got["systemLabel"] != "warco" || got["talkgroupLabel"] != "Fire Dispatch" ||
+		got["audioType"] != "audio/mp4" || got["sources"] != `[{"pos":0,"src":1234}]` {
+		t.Errorf("downstream got fields %v", got)
+	}
+	if string(rdio.audio) != string(audio) || rdio.name != "9131-1772373600.m4a" {
+		t.Errorf("downstream got audio %q named %q", rdio.audio, rdio.name)
+	}
+
+	// A refused call is rejected; a bad key is retried.
+	c.Tgid = 0
+	if err := f.push(context.Background(), c, audio); err == nil || !isRejected(err) {
+		t.Errorf("incomplete call: err = %v, want a rejection", err)
+	}
+	c.Tgid = 9131
+	f.opts.APIKey = "wrong"
+	if err := f.push(context.Background(), c, audio); err == nil || isRejected(err) {
+		t.Errorf("bad API key: err = %v, want a retryable error", err)
+	}
+}
+
+func TestIsRejected(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&downstreamError{status: http.StatusExpectationFailed}, true},
+		{&downstreamError{status: http.StatusBadRequest}, true},
+		{&downstreamError{status: http.StatusUnauthorized}, false},
+		{&downstreamError{status: http.StatusNotFound}, false},
+		{&downstreamError{status: http.StatusTooManyRequests}, false},
+		{&downstreamError{status: http.StatusBadGateway}, false},
+		{fmt.Errorf("dial tcp: connection refused"), false},
+	}
+	for _, tt := range tests {
+		if got := isRejected(tt.err); got != tt.want {
+			t.Errorf("isRejected(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+// Package icecast streams finished call audio to an Icecast server: one
+// continuous MP3 stream per mount, each carrying a set of talkgroups (for
+// example every Fire-Tac talkgroup) for scanner apps and stream listeners.
+//
+// Each mount is a long-lived HTTP PUT source connection (Icecast 2.4+; put a
+// SHOUTcast server behind an Icecast-compatible source input). Calls are
+// queued in the order they end, transcoded to 16 kHz mono CBR MP3 and written
+// at real-time speed, with the mount's silence padding after each one; while
+// its talkgroups are idle a mount streams silence so the server keeps the
+// source. Emergency calls jump the queue and, unless the mount turns
+// emergency_priority off, cut into the call being played. Encrypted calls and
+// events restricted by an encryption policy are never streamed; calls held
+// back by a talkgroup embargo are queued when the embargo ends. A dropped
+// connection is retried with backoff.
+package icecast
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/api"
+	"github.com/snarg/tr-engine/internal/audio"
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/storage"
+	"github.com/snarg/tr-engine/pkg/events"
+)
+
+// Stream settings. At 16 kHz an MPEG-2 layer III frame holds 576 samples
+// (36 ms) and, for bitrates that are a multiple of 8 kbit/s, is exactly
+// 72*bitrate/16000 bytes, so audio is paced frame by frame.
+const (
+	SampleRate     = 16000
+	frameDuration  = 36 * time.Millisecond
+	lead           = 2 * time.Second // audio written ahead of real time
+	writeInterval  = 250 * time.Millisecond
+	audioWait      = 30 * time.Second // how long after call_end its audio may arrive
+	audioPoll      = time.Second
+	maxCallAge     = 10 * time.Minute // calls still queued after this are dropped
+	groupRefresh   = 5 * time.Minute
+	minBackoff     = 5 * time.Second
+	maxBackoff     = time.Minute
+	defaultBitrate = 32000
+)
+
+var errNoAudio = errors.New("call has no audio")
+
+// Options configures a Streamer.
+type Options struct {
+	URL        string        // server base URL, e.g. http://icecast:8000
+	Username   string        // source user (default "source")
+	Password   string        // source password
+	Bitrate    int           // MP3 bitrate in bit/s, a multiple of 8000 (default 32000)
+	Silence    time.Duration // silence after each call unless a mount sets silence_ms (default 1s)
+	MaxQueue   int           // calls waiting per mount; beyond it the oldest non-emergency call is dropped (default 50)
+	AudioDir   string        // local audio directory, for calls not in the audio store
+	TRAudioDir string        // trunk-recorder's audio directory
+}
+
+// Streamer feeds call audio to the configured mounts.
+type Streamer struct {
+	db      *database.DB
+	store   storage.AudioStore
+	opts    Options
+	log     zerolog.Logger
+	client  *http.Client
+	sources []*source
+
+	frameBytes int
+	silence    []byte // one silent frame
+
+	// loadAudio returns a call's audio as MP3 in the stream format, or
+	// errNoAudio while it has not been stored yet.
+	loadAudio func(ctx context.Context, c events.CallEnd) ([]byte, error)
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// New creates a streamer for mounts loaded with LoadMounts.
+func New(db *database.DB, store storage.AudioStore, mounts []*Mount, opts Options, log zerolog.Logger) *Streamer {
+	if opts.Username == "" {
+		opts.Username = "source"
+	}
+	if opts.Bitrate <= 0 {
+		opts.Bitrate = defaultBitrate
+	}
+	if opts.Silence < 0 {
+		opts.Silence = 0
+	}
+	if opts.MaxQueue <= 0 {
+		opts.MaxQueue = 50
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Streamer{
+		db:         db,
+		store:      store,
+		opts:       opts,
+		log:        log.With().Str("component", "icecast").Logger(),
+		client:     &http.Client{}, // source connections stay open indefinitely
+		frameBytes: 72 * opts.Bitrate / SampleRate,
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	s.loadAudio = s.readCallAudio
+	for _, m := range mounts {
+		s.sources = append(s.sources, newSource(s, m))
+	}
+	return s
+}
+
+// Start renders the silence frame (which needs ffmpeg), resolves talkgroup
+// groups and connects every mount.
+func (s *Streamer) Start() error {
+	silence, err := audio.SilenceMP3(s.ctx, time.Second, s.opts.Bitrate, SampleRate)
+	if err != nil {
+		return fmt.Errorf("render silence: %w", err)
+	}
+	// Skip the first frames, which may carry encoder start-up data.
+	if len(silence) < 12*s.frameBytes {
+		return fmt.Errorf("render silence: got %d bytes", len(silence))
+	}
+	s.silence = silence[10*s.frameBytes : 11*s.frameBytes]
+
+	s.refreshGroups(s.ctx)
+	s.wg.Add(1)
+	go s.groupLoop()
+	for _, src := range s.sources {
+		s.wg.Add(1)
+		go src.run()
+	}
+	return nil
+}
+
+// Stop closes the source connections; queued calls are discarded.
+func (s *Streamer) Stop() {
+	s.stopOnce.Do(s.cancel)
+	s.wg.Wait()
+}
+
+// Enqueue hands an event bus event to the streamer. It never blocks; only
+// unencrypted call_end events on a mount's talkgroups are queued.
+func (s *Streamer) Enqueue(e api.SSEEvent) {
+	if e.Type != events.TypeCallEnd || e.Restricted {
+		return
+	}
+	var c events.CallEnd
+	if json.Unmarshal(e.Data, &c) != nil || c.Encrypted || c.CallID == 0 {
+		return
+	}
+	for _, src := range s.sources {
+		if src.matches(c.SystemID, c.Tgid) {
+			src.push(c, c.Emergency || e.Emergency, e.VisibleAt)
+		}
+	}
+}
+
+// groupLoop re-resolves talkgroup groups, picking up talkgroups added to or
+// moved between groups.
+func (s *Streamer) groupLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(groupRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.refreshGroups(s.ctx)
+		}
+	}
+}
+
+func (s *Streamer) refreshGroups(ctx context.Context) {
+	for _, src := range s.sources {
+		if len(src.mount.Groups) == 0 {
+			continue
+		}
+		tgids, err := s.db.TalkgroupsInGroups(ctx, src.mount.SystemID, src.mount.Groups)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.log.Warn().Err(err).Str("mount", src.mount.Mount).Msg("failed to resolve talkgroup groups")
+			}
+			continue
+		}
+		src.setGroupTgids(tgids)
+	}
+}
+
+// readCallAudio reads a call's audio from the audio store, or from the audio
+// directories, and transcodes it to the stream format.
+func (s *Streamer) readCallAudio(ctx context.Context, c events.CallEnd) ([]byte, error) {
+	ref := database.CallRef{CallID: c.CallID, StartTime: c.StartTime}
+	audioPath, callFilename, err := s.db.GetCallAudioPath(ctx, ref)
+	if err != nil {
+		return nil, errNoAudio
+	}
+	var data []byte
+	ext := filepath.Ext(audioPath)
+	if audioPath != "" && s.store != nil {
+		if rc, err := s.store.Open(ctx, audioPath); err == nil {
+			data, err = io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if data == nil {
+		path := audio.ResolveFile(s.opts.AudioDir, s.opts.TRAudioDir, audioPath, callFilename)
+		if path == "" {
+			return nil, errNoAudio
+		}
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+		ext = filepath.Ext(path)
+	}
+	return audio.TranscodeMP3(ctx, data, ext, s.opts.Bitrate, SampleRate)
+}
+
+// Status describes the streamer and each mount.
+type Status struct {
+	URL     string        `json:"url"`
+	Bitrate int           `json:"bitrate"`
+	Mounts  []MountStatus `json:"mounts"`
+}
+
+// MountStatus describes one mount's connection and playback.
+type MountStatus struct {
+	Mount          string      `json:"mount"`
+	Name           string      `json:"name"`
+	Talkgroups     int         `json:"talkgroups"` // tgids plus resolved group members
+	Connected      bool        `json:"connected"`
+	ConnectedSince *time.Time  `json:"connected_since,omitempty"`
+	Queued         int         `json:"queued"`
+	NowPlaying     *NowPlaying `json:"now_playing,omitempty"`
+	Played         int64       `json:"played"`
+	Interrupted    int64       `json:"interrupted"` // calls cut short by an emergency call
+	Dropped        int64       `json:"dropped"`     // queue overflow, stale calls and calls without audio
+	LastError      string      `json:"last_error,omitempty"`
+	LastErrorAt    *time.Time  `json:"last_error_at,omitempty"`
+}
+
+// NowPlaying is the call a mount is streaming.
+type NowPlaying struct {
+	CallID     int64     `json:"call_id"`
+	SystemID   int       `json:"system_id"`
+	Tgid       int       `json:"tgid"`
+	TgAlphaTag string    `json:"tg_alpha_tag,omitempty"`
+	Emergency  bool      `json:"emergency"`
+	StartedAt  time.Time `json:"started_at"`
+}
+
+// Status returns the mounts' connection and playback state. Credentials in
+// the server URL are not included.
+func (s *Streamer) Status() Status {
+	st := Status{URL: s.opts.URL, Bitrate: s.opts.Bitrate, Mounts: make([]MountStatus, 0, len(s.sources))}
+	if u, err := url.Parse(s.opts.URL); err == nil {
+		u.User = nil
+		st.URL = u.String()
+	}
+	for _, src := range s.sources {
+		st.Mounts = append(st.Mounts, src.status())
+	}
+	return st
+}
@@ -0,0 +1,193 @@
+package icecast
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/api"
+	"github.com/snarg/tr-engine/pkg/events"
+)
+
+func writeMounts(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mounts.json")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadMounts(t *testing.T) {
+	mounts, err := LoadMounts(writeMounts(t, `[
+		{"mount": "/fire-tac", "groups": ["Fire-Tac", " "], "silence_ms": 500},
+		{"mount": "/dispatch", "name": "Dispatch", "system_id": 1, "tgids": [9178], "emergency_priority": false}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := mounts[0]; m.Name != "fire-tac" || len(m.Groups) != 1 || !m.priority() {
+		t.Errorf("mount 0 = %+v", m)
+	}
+	if m := mounts[1]; !m.tgids[9178] || m.priority() {
+		t.Errorf("mount 1 = %+v", m)
+	}
+
+	for body, want := range map[string]string{
+		`[]`:                                                "no mounts",
+		`[{"mount": "fire", "tgids": [1]}]`:                 "mount must be a path",
+		`[{"mount": "/fire"}]`:                              "tgids or groups is required",
+		`[{"mount": "/fire", "groups": [""]}]`:              "tgids or groups is required",
+		`[{"mount": "/fire", "tgids": [0]}]`:                "tgids must be positive",
+		`[{"mount": "/a", "tgids": [1], "silence_ms": -1}]`: "silence_ms",
+		`[{"mount": "/a", "tgids": [1]}, {"mount": "/a", "tgids": [2]}]`: "duplicate mount",
+	} {
+		if _, err := LoadMounts(writeMounts(t, body)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadMounts(%s) = %v, want %q", body, err, want)
+		}
+	}
+}
+
+func callEvent(callID int64, tgid int, emergency bool) api.SSEEvent {
+	data, _ := json.Marshal(events.CallEnd{CallID: callID, SystemID: 1, Tgid: tgid, Emergency: emergency})
+	return api.SSEEvent{Type: events.TypeCallEnd, SystemID: 1, Tgid: tgid, Emergency: emergency, Data: data}
+}
+
+// testStreamer returns a streamer whose call audio is the call ID repeated
+// over one frame.
+func testStreamer(mounts []*Mount, opts Options) *Streamer {
+	s := New(nil, nil, mounts, opts, zerolog.Nop())
+	s.silence = bytes.Repeat([]byte{0}, s.frameBytes)
+	s.loadAudio = func(_ context.Context, c events.CallEnd) ([]byte, error) {
+		return bytes.Repeat([]byte{byte(c.CallID)}, s.frameBytes), nil
+	}
+	return s
+}
+
+func mount(t *testing.T, m *Mount) *Mount {
+	t.Helper()
+	if err := m.compile(); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestQueueOrder(t *testing.T) {
+	off := false
+	s := testStreamer([]*Mount{
+		mount(t, &Mount{Mount: "/a", Tgids: []int{100}, Groups: []string{"Fire-Tac"}}),
+		mount(t, &Mount{Mount: "/b", SystemID: 2, Tgids: []int{100}, EmergencyPriority: &off}),
+	}, Options{MaxQueue: 3})
+	a, b := s.sources[0], s.sources[1]
+	a.setGroupTgids(map[int][]int{1: {200}})
+
+	s.Enqueue(callEvent(1, 100, false))
+	s.Enqueue(callEvent(2, 200, false))
+	s.Enqueue(callEvent(3, 300, false)) // no mount
+	s.Enqueue(callEvent(4, 100, true))
+	s.Enqueue(callEvent(5, 200, true))  // full: drops call 1
+	s.Enqueue(callEvent(6, 100, false)) // full: drops call 2
+
+	enc := callEvent(7, 100, true)
+	enc.Data, _ = json.Marshal(events.CallEnd{CallID: 7, SystemID: 1, Tgid: 100, Encrypted: true})
+	s.Enqueue(enc)
+	restricted := callEvent(8, 100, false)
+	restricted.Restricted = true
+	s.Enqueue(restricted)
+
+	if len(b.queue) != 0 {
+		t.Errorf("system 2 mount queued %d calls", len(b.queue))
+	}
+	var got []int64
+	for p := a.next(context.Background()); p != nil; p = a.next(context.Background()) {
+		got = append(got, int64(p.data[0]))
+	}
+	if want := []int64{4, 5, 6}; !slices.Equal(got, want) {
+		t.Errorf("played %v, want %v", got, want)
+	}
+	if st := a.status(); st.Played != 3 || st.Dropped != 2 || st.Talkgroups != 2 || st.Queued != 0 {
+		t.Errorf("status = %+v", st)
+	}
+
+	// Without emergency priority calls keep their order.
+	b.push(events.CallEnd{CallID: 1}, false, time.Time{})
+	b.push(events.CallEnd{CallID: 2}, true, time.Time{})
+	if p := b.next(context.Background()); p == nil || p.data[0] != 1 || p.urgent {
+		t.Errorf("priority off: first call = %+v", p)
+	}
+
+	// Embargoed calls wait.
+	b.push(events.CallEnd{CallID: 3}, false, time.Now().Add(time.Hour))
+	if p := b.next(context.Background()); p == nil || p.data[0] != 2 {
+		t.Errorf("next = %+v, want call 2", p)
+	}
+	if p := b.next(context.Background()); p != nil {
+		t.Errorf("embargoed call played: %+v", p)
+	}
+}
+
+func TestPadding(t *testing.T) {
+	ms := 100
+	s := testStreamer([]*Mount{mount(t, &Mount{Mount: "/a", Tgids: []int{1}, SilenceMs: &ms})}, Options{})
+	src := s.sources[0]
+	src.push(events.CallEnd{CallID: 9}, false, time.Time{})
+	p := src.next(context.Background())
+	// 100 ms of padding is three 36 ms frames.
+	if p == nil || p.callBytes != s.frameBytes || len(p.data) != 4*s.frameBytes {
+		t.Fatalf("playback = %d bytes, %d of call", len(p.data), p.callBytes)
+	}
+}
+
+func TestSourceStreams(t *testing.T) {
+	type request struct {
+		method, path, auth, name, contentType string
+	}
+	got := make(chan request, 1)
+	body := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		got <- request{r.Method, r.URL.Path, user + ":" + pass, r.Header.Get("Ice-Name"), r.Header.Get("Content-Type")}
+		http.NewResponseController(w).EnableFullDuplex()
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		buf := make([]byte, 20*144)
+		n, _ := io.ReadFull(r.Body, buf)
+		body <- buf[:n]
+	}))
+	defer srv.Close()
+
+	s := testStreamer([]*Mount{mount(t, &Mount{Mount: "/fire-tac", Name: "Fire Tac", Tgids: []int{100}})},
+		Options{URL: srv.URL + "/", Password: "hackme", Silence: 0})
+	s.Enqueue(callEvent(7, 100, false))
+	s.wg.Add(1)
+	go s.sources[0].run()
+	defer s.Stop()
+
+	select {
+	case r := <-got:
+		if r != (request{"PUT", "/fire-tac", "source:hackme", "Fire Tac", "audio/mpeg"}) {
+			t.Errorf("request = %+v", r)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no source connection")
+	}
+	data := <-body
+	if len(data) != 20*144 || !bytes.Equal(data[:144], bytes.Repeat([]byte{7}, 144)) ||
+		!bytes.Equal(data[144:288], make([]byte, 144)) {
+		t.Errorf("stream starts % x", data[:16])
+	}
+	if st := s.Status(); st.Mounts[0].Played != 1 {
+		t.Errorf("status = %+v", st)
+	}
+}
@@ -0,0 +1,89 @@
+package icecast
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Mount is one Icecast mount point and the talkgroups whose calls it carries.
+type Mount struct {
+	Mount       string   `json:"mount"` // e.g. "/fire-tac"
+	Name        string   `json:"name"`  // stream name shown by players (Ice-Name)
+	Description string   `json:"description"`
+	Genre       string   `json:"genre"`
+	Public      bool     `json:"public"`    // list the stream in Icecast's directory
+	SystemID    int      `json:"system_id"` // 0 matches any system
+	Tgids       []int    `json:"tgids"`
+	Groups      []string `json:"groups"` // talkgroup groups (talkgroup CSV "Category"), case-insensitive
+
+	SilenceMs         *int  `json:"silence_ms"`         // silence after each call; default ICECAST_SILENCE
+	EmergencyPriority *bool `json:"emergency_priority"` // emergency calls jump the queue and cut in; default true
+
+	tgids map[int]bool
+}
+
+// LoadMounts reads an ICECAST_MOUNTS JSON file: an array of Mount.
+func LoadMounts(path string) ([]*Mount, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var mounts []*Mount
+	if err := json.Unmarshal(data, &mounts); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(mounts) == 0 {
+		return nil, fmt.Errorf("%s defines no mounts", path)
+	}
+	seen := make(map[string]bool, len(mounts))
+	for i, m := range mounts {
+		if err := m.compile(); err != nil {
+			return nil, fmt.Errorf("mount %d (%q): %w", i, m.Mount, err)
+		}
+		if seen[m.Mount] {
+			return nil, fmt.Errorf("mount %d (%q): duplicate mount", i, m.Mount)
+		}
+		seen[m.Mount] = true
+	}
+	return mounts, nil
+}
+
+func (m *Mount) compile() error {
+	if !strings.HasPrefix(m.Mount, "/") || len(m.Mount) < 2 || strings.ContainsAny(m.Mount, " ?#") {
+		return fmt.Errorf(`mount must be a path like "/fire-tac"`)
+	}
+	if m.SystemID < 0 {
+		return fmt.Errorf("system_id must not be negative")
+	}
+	if m.SilenceMs != nil && (*m.SilenceMs < 0 || *m.SilenceMs > 60000) {
+		return fmt.Errorf("silence_ms must be between 0 and 60000")
+	}
+	if m.Name == "" {
+		m.Name = strings.TrimPrefix(m.Mount, "/")
+	}
+	m.tgids = make(map[int]bool, len(m.Tgids))
+	for _, t := range m.Tgids {
+		if t <= 0 {
+			return fmt.Errorf("tgids must be positive")
+		}
+		m.tgids[t] = true
+	}
+	groups := m.Groups[:0]
+	for _, g := range m.Groups {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+	m.Groups = groups
+	if len(m.Tgids) == 0 && len(m.Groups) == 0 {
+		return fmt.Errorf("tgids or groups is required")
+	}
+	return nil
+}
+
+// priority reports whether emergency calls take priority on this mount.
+func (m *Mount) priority() bool {
+	return m.EmergencyPriority == nil || *m.EmergencyPriority
+}
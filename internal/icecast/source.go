@@ -0,0 +1,415 @@
+package icecast
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/pkg/events"
+)
+
+// responseTimeout bounds how long the server may take to accept a source.
+const responseTimeout = 15 * time.Second
+
+// queuedCall is a finished call waiting to be streamed.
+type queuedCall struct {
+	call      events.CallEnd
+	emergency bool
+	urgent    bool      // emergency on a mount with emergency_priority
+	visibleAt time.Time // embargo end; zero when not embargoed
+	queuedAt  time.Time
+	retryAt   time.Time // next audio lookup while the audio is not stored yet
+}
+
+// playback is a call's audio followed by its silence padding.
+type playback struct {
+	data      []byte
+	callBytes int // len(data) without the padding
+	urgent    bool
+}
+
+// source is one mount's queue and source connection.
+type source struct {
+	s         *Streamer
+	mount     *Mount
+	log       zerolog.Logger
+	padFrames int
+
+	mu          sync.Mutex
+	queue       []*queuedCall
+	groupTgids  map[int]map[int]bool // system_id → tgids resolved from the mount's groups
+	connected   bool
+	connectedAt time.Time
+	playing     *NowPlaying
+	played      int64
+	interrupted int64
+	dropped     int64
+	lastErr     string
+	lastErrAt   time.Time
+}
+
+func newSource(s *Streamer, m *Mount) *source {
+	silence := s.opts.Silence
+	if m.SilenceMs != nil {
+		silence = time.Duration(*m.SilenceMs) * time.Millisecond
+	}
+	return &source{
+		s:         s,
+		mount:     m,
+		log:       s.log.With().Str("mount", m.Mount).Logger(),
+		padFrames: int((silence + frameDuration - 1) / frameDuration),
+	}
+}
+
+func (src *source) setGroupTgids(bySystem map[int][]int) {
+	groups := make(map[int]map[int]bool, len(bySystem))
+	for sys, tgids := range bySystem {
+		groups[sys] = make(map[int]bool, len(tgids))
+		for _, t := range tgids {
+			groups[sys][t] = true
+		}
+	}
+	src.mu.Lock()
+	src.groupTgids = groups
+	src.mu.Unlock()
+}
+
+// matches reports whether a talkgroup's calls go to this mount.
+func (src *source) matches(systemID, tgid int) bool {
+	m := src.mount
+	if m.SystemID != 0 && m.SystemID != systemID {
+		return false
+	}
+	if m.tgids[tgid] {
+		return true
+	}
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	return src.groupTgids[systemID][tgid]
+}
+
+// push queues a call. Urgent calls go ahead of every other call but behind
+// urgent calls already waiting; a full queue drops its oldest non-urgent call.
+func (src *source) push(c events.CallEnd, emergency bool, visibleAt time.Time) {
+	q := &queuedCall{
+		call:      c,
+		emergency: emergency,
+		urgent:    emergency && src.mount.priority(),
+		visibleAt: visibleAt,
+		queuedAt:  time.Now(),
+	}
+	notUrgent := func(q *queuedCall) bool { return !q.urgent }
+
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	if len(src.queue) >= src.s.opts.MaxQueue {
+		i := slices.IndexFunc(src.queue, notUrgent)
+		if i < 0 {
+			i = 0
+		}
+		src.log.Debug().Int64("call_id", src.queue[i].call.CallID).Msg("icecast queue full, call dropped")
+		src.queue = slices.Delete(src.queue, i, i+1)
+		src.dropped++
+	}
+	if !q.urgent {
+		src.queue = append(src.queue, q)
+		return
+	}
+	i := slices.IndexFunc(src.queue, notUrgent)
+	if i < 0 {
+		i = len(src.queue)
+	}
+	src.queue = slices.Insert(src.queue, i, q)
+}
+
+// urgentWaiting reports whether an urgent call past its embargo is queued.
+func (src *source) urgentWaiting() bool {
+	now := time.Now()
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	for _, q := range src.queue {
+		if !q.urgent {
+			return false
+		}
+		if !now.Before(q.visibleAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// next takes the first queued call whose audio is ready and returns it with
+// its padding, or nil when no call is ready. Calls without audio are retried
+// for audioWait; an urgent call waiting for its audio holds back the calls
+// behind it.
+func (src *source) next(ctx context.Context) *playback {
+	now := time.Now()
+	src.mu.Lock()
+	kept := src.queue[:0]
+	for _, q := range src.queue {
+		if now.Sub(q.queuedAt) > maxCallAge {
+			src.dropped++
+			continue
+		}
+		kept = append(kept, q)
+	}
+	clear(src.queue[len(kept):])
+	src.queue = kept
+	var pick *queuedCall
+	for _, q := range src.queue {
+		if now.Before(q.visibleAt) {
+			continue
+		}
+		if now.Before(q.retryAt) {
+			if q.urgent {
+				break
+			}
+			continue
+		}
+		pick = q
+		break
+	}
+	src.mu.Unlock()
+	if pick == nil {
+		return nil
+	}
+
+	data, err := src.s.loadAudio(ctx, pick.call)
+
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	if errors.Is(err, errNoAudio) && now.Sub(pick.queuedAt) < audioWait {
+		pick.retryAt = now.Add(audioPoll)
+		return nil
+	}
+	if i := slices.Index(src.queue, pick); i >= 0 {
+		src.queue = slices.Delete(src.queue, i, i+1)
+	}
+	if err != nil || len(data) == 0 {
+		if ctx.Err() == nil {
+			src.log.Debug().Err(err).Int64("call_id", pick.call.CallID).Msg("icecast call skipped")
+			src.dropped++
+		}
+		return nil
+	}
+	src.played++
+	src.playing = &NowPlaying{
+		CallID:     pick.call.CallID,
+		SystemID:   pick.call.SystemID,
+		Tgid:       pick.call.Tgid,
+		TgAlphaTag: pick.call.TgAlphaTag,
+		Emergency:  pick.emergency,
+		StartedAt:  time.Now(),
+	}
+	n := len(data)
+	data = append(data, bytes.Repeat(src.s.silence, src.padFrames)...)
+	return &playback{data: data, callBytes: n, urgent: pick.urgent}
+}
+
+// run keeps the source connected until the streamer stops, retrying with
+// backoff.
+func (src *source) run() {
+	defer src.s.wg.Done()
+	ctx := src.s.ctx
+	backoff := minBackoff
+	for {
+		started := time.Now()
+		err := src.connect(ctx)
+		src.stopped()
+		if ctx.Err() != nil {
+			return
+		}
+		src.failed(err)
+		if time.Since(started) > maxBackoff {
+			backoff = minBackoff
+		}
+		src.log.Warn().Err(err).Dur("retry_in", backoff).Msg("icecast source disconnected")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// connect opens the source connection with an HTTP PUT and streams until
+// the connection or the streamer ends.
+func (src *source) connect(parent context.Context) error {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	m, opts := src.mount, src.s.opts
+	pr, pw := io.Pipe()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, strings.TrimRight(opts.URL, "/")+m.Mount, pr)
+	if err != nil {
+		return err
+	}
+	kbps := strconv.Itoa(opts.Bitrate / 1000)
+	req.SetBasicAuth(opts.Username, opts.Password)
+	req.Header.Set("Content-Type", "audio/mpeg")
+	req.Header.Set("Ice-Name", m.Name)
+	if m.Description != "" {
+		req.Header.Set("Ice-Description", m.Description)
+	}
+	if m.Genre != "" {
+		req.Header.Set("Ice-Genre", m.Genre)
+	}
+	public := "0"
+	if m.Public {
+		public = "1"
+	}
+	req.Header.Set("Ice-Public", public)
+	req.Header.Set("Ice-Bitrate", kbps)
+	req.Header.Set("Ice-Audio-Info", "channels=1;samplerate="+strconv.Itoa(SampleRate)+";bitrate="+kbps)
+
+	done := make(chan error, 1)
+	go func() {
+		err := src.stream(ctx, pw)
+		pw.CloseWithError(err)
+		done <- err
+	}()
+	timer := time.AfterFunc(responseTimeout, cancel)
+	resp, err := src.s.client.Do(req)
+	timer.Stop()
+	if err == nil && resp.StatusCode/100 != 2 {
+		resp.Body.Close()
+		err = fmt.Errorf("server returned %s", resp.Status)
+	}
+	if err != nil {
+		cancel()
+		<-done
+		return err
+	}
+	// Closing the response body closes the connection, so it stays open
+	// for as long as the source streams.
+	defer resp.Body.Close()
+
+	src.mu.Lock()
+	src.connected, src.connectedAt = true, time.Now()
+	src.mu.Unlock()
+	src.log.Info().Msg("icecast source connected")
+	return <-done
+}
+
+// stream writes frames at real-time speed, lead ahead, until ctx ends or a
+// write fails: queued calls with their padding, silence in between. An
+// urgent call cuts into a non-urgent one, which is not resumed.
+func (src *source) stream(ctx context.Context, w io.Writer) error {
+	fb := src.s.frameBytes
+	start := time.Now()
+	var sent int64
+	var cur *playback
+	var buf []byte
+	ticker := time.NewTicker(writeInterval)
+	defer ticker.Stop()
+	for {
+		due := int64((time.Since(start) + lead) / frameDuration)
+		buf = buf[:0]
+		for ; sent < due; sent++ {
+			if cur != nil && !cur.urgent && src.urgentWaiting() {
+				if p := src.next(ctx); p != nil {
+					if cur.callBytes > 0 {
+						src.mu.Lock()
+						src.interrupted++
+						src.mu.Unlock()
+					}
+					cur = p
+				}
+			}
+			if cur == nil {
+				cur = src.next(ctx)
+			}
+			if cur == nil {
+				buf = append(buf, src.s.silence...)
+				continue
+			}
+			n := min(fb, len(cur.data))
+			buf = append(buf, cur.data[:n]...)
+			cur.data = cur.data[n:]
+			if cur.callBytes > 0 {
+				if cur.callBytes -= n; cur.callBytes <= 0 {
+					src.setPlaying(nil)
+				}
+			}
+			if len(cur.data) == 0 {
+				cur = nil
+			}
+		}
+		if len(buf) > 0 {
+			if _, err := w.Write(buf); err != nil {
+				return fmt.Errorf("write: %w", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (src *source) setPlaying(p *NowPlaying) {
+	src.mu.Lock()
+	src.playing = p
+	src.mu.Unlock()
+}
+
+// stopped clears the connection state after a connection ends.
+func (src *source) stopped() {
+	src.mu.Lock()
+	src.connected, src.playing = false, nil
+	src.mu.Unlock()
+}
+
+func (src *source) failed(err error) {
+	src.mu.Lock()
+	src.lastErr, src.lastErrAt = err.Error(), time.Now()
+	src.mu.Unlock()
+}
+
+func (src *source) status() MountStatus {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	st := MountStatus{
+		Mount:       src.mount.Mount,
+		Name:        src.mount.Name,
+		Talkgroups:  len(src.mount.tgids),
+		Connected:   src.connected,
+		Queued:      len(src.queue),
+		Played:      src.played,
+		Interrupted: src.interrupted,
+		Dropped:     src.dropped,
+		LastError:   src.lastErr,
+	}
+	for _, tgids := range src.groupTgids {
+		for t := range tgids {
+			if !src.mount.tgids[t] {
+				st.Talkgroups++
+			}
+		}
+	}
+	if src.connected {
+		t := src.connectedAt
+		st.ConnectedSince = &t
+	}
+	if src.playing != nil {
+		p := *src.playing
+		st.NowPlaying = &p
+	}
+	if !src.lastErrAt.IsZero() {
+		t := src.lastErrAt
+		st.LastErrorAt = &t
+	}
+	return st
+}
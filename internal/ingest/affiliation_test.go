@@ -190,3 +190,29 @@ func TestAffiliationMap_UpdateOverwrites(t *testing.T) {
 		t.Errorf("Tgid = %d, want 300 after overwrite", entry.Tgid)
 	}
 }
+
+func TestAffiliationMap_RewriteUnit(t *testing.T) {
+	m := newAffiliationMap()
+	now := time.Now()
+	m.Update(affiliationKey{SystemID: 1, UnitID: 100}, &affiliationEntry{SystemID: 1, UnitID: 100, Tgid: 200, UnitAlphaTag: "Old", LastEventTime: now})
+
+	// No target entry: the source entry moves over
+	m.RewriteUnit(1, 100, 101, "Engine 1")
+	if _, ok := m.Get(affiliationKey{SystemID: 1, UnitID: 100}); ok {
+		t.Error("source entry still present")
+	}
+	e, ok := m.Get(affiliationKey{SystemID: 1, UnitID: 101})
+	if !ok || e.UnitID != 101 || e.Tgid != 200 || e.UnitAlphaTag != "Engine 1" {
+		t.Errorf("target = %+v, %v", e, ok)
+	}
+
+	// Target entry present: it is kept and the source dropped
+	m.Update(affiliationKey{SystemID: 1, UnitID: 102}, &affiliationEntry{SystemID: 1, UnitID: 102, Tgid: 300, LastEventTime: now})
+	m.RewriteUnit(1, 102, 101, "Engine 1")
+	if _, ok := m.Get(affiliationKey{SystemID: 1, UnitID: 102}); ok {
+		t.Error("source entry still present")
+	}
+	if e, _ := m.Get(affiliationKey{SystemID: 1, UnitID: 101}); e.Tgid != 200 {
+		t.Errorf("target Tgid = %d, want 200", e.Tgid)
+	}
+}
@@ -0,0 +1,234 @@
+package ingest
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/pkg/events"
+)
+
+// maxAlertMatches caps the matched text stored per alert.
+const maxAlertMatches = 10
+
+// alertRule is a cached, compiled alert rule.
+type alertRule struct {
+	database.AlertRule
+	keywords *regexp.Regexp // nil = no keywords
+	pattern  *regexp.Regexp // nil = no pattern
+}
+
+// compileAlertKeywords builds one case-insensitive expression matching any
+// keyword as a whole word (or phrase, with any whitespace between its
+// words). Returns nil for no keywords.
+func compileAlertKeywords(keywords []string) *regexp.Regexp {
+	var alts []string
+	for _, kw := range keywords {
+		kw = strings.TrimSpace(kw)
+		words := strings.Fields(kw)
+		if len(words) == 0 {
+			continue
+		}
+		for i, w := range words {
+			words[i] = regexp.QuoteMeta(w)
+		}
+		alt := strings.Join(words, `\s+`)
+		// \b is ASCII-only: only anchor ends that are ASCII word characters
+		if isASCIIWordByte(kw[0]) {
+			alt = `\b` + alt
+		}
+		if isASCIIWordByte(kw[len(kw)-1]) {
+			alt += `\b`
+		}
+		alts = append(alts, alt)
+	}
+	if len(alts) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(`(?i)(?:` + strings.Join(alts, "|") + `)`)
+}
+
+func isASCIIWordByte(b byte) bool {
+	return b == '_' || ('0' <= b && b <= '9') || ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z')
+}
+
+// textMatches returns the transcript text that matched the rule's keywords
+// or pattern, deduplicated case-insensitively; nil if neither matched.
+func (r *alertRule) textMatches(text string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, re := range []*regexp.Regexp{r.keywords, r.pattern} {
+		if re == nil {
+			continue
+		}
+		for _, m := range re.FindAllString(text, maxAlertMatches) {
+			key := strings.ToLower(strings.Join(strings.Fields(m), " "))
+			if key == "" || seen[key] || len(out) >= maxAlertMatches {
+				continue
+			}
+			seen[key] = true
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// callMatches applies the rule's unit and emergency filters.
+func (r *alertRule) callMatches(c *database.AlertCall) bool {
+	if r.EmergencyOnly && !c.Emergency {
+		return false
+	}
+	if len(r.UnitIDs) == 0 {
+		return true
+	}
+	for _, u := range r.UnitIDs {
+		if u == c.Unit {
+			return true
+		}
+		for _, id := range c.UnitIDs {
+			if u == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// alertMatch is a rule whose text conditions a transcript satisfied.
+type alertMatch struct {
+	rule    *alertRule
+	matches []string
+}
+
+// alertRules caches enabled alert rules for new transcripts.
+type alertRules struct {
+	mu    sync.RWMutex
+	rules []*alertRule
+}
+
+func newAlertRules() *alertRules { return &alertRules{} }
+
+// set replaces the cached rules.
+func (ar *alertRules) set(list []database.AlertRule) {
+	rules := make([]*alertRule, 0, len(list))
+	for _, a := range list {
+		if !a.Enabled {
+			continue
+		}
+		rule := &alertRule{AlertRule: a, keywords: compileAlertKeywords(a.Keywords)}
+		if a.Pattern != "" {
+			re, err := regexp.Compile(a.Pattern)
+			if err != nil {
+				// Patterns are validated on save; one that doesn't compile
+				// (e.g. edited in the database) disables its rule.
+				continue
+			}
+			rule.pattern = re
+		}
+		if rule.keywords == nil && rule.pattern == nil {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	ar.mu.Lock()
+	ar.rules = rules
+	ar.mu.Unlock()
+}
+
+// matching returns the rules whose system, talkgroup and text conditions a
+// transcript satisfies, in ID order.
+func (ar *alertRules) matching(systemID, tgid int, text string) []alertMatch {
+	ar.mu.RLock()
+	defer ar.mu.RUnlock()
+	var out []alertMatch
+	for _, r := range ar.rules {
+		if !r.Matches(systemID, tgid) {
+			continue
+		}
+		if m := r.textMatches(text); m != nil {
+			out = append(out, alertMatch{rule: r, matches: m})
+		}
+	}
+	return out
+}
+
+// ReloadAlertRules reloads alert rules from the database. Called at startup
+// and after rule changes.
+func (p *Pipeline) ReloadAlertRules(ctx context.Context) error {
+	list, err := p.db.ListAlertRules(ctx)
+	if err != nil {
+		return err
+	}
+	p.alertRules.set(list)
+	return nil
+}
+
+// evaluateAlerts checks a new transcript against the alert rules. Each
+// matching rule's alert is stored and published as an alert event. The call
+// is only looked up (for its units and emergency flag) when some rule's
+// text matched.
+func (p *Pipeline) evaluateAlerts(t *events.Transcription) {
+	if p.alertRules == nil || t.StartTime.IsZero() {
+		return
+	}
+	matched := p.alertRules.matching(t.SystemID, t.Tgid, t.Text)
+	if len(matched) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(p.ctx, 5*time.Second)
+	defer cancel()
+	call, err := p.db.GetAlertCall(ctx, t.CallID, t.StartTime)
+	if err != nil {
+		p.log.Warn().Err(err).Int64("call_id", t.CallID).Msg("failed to look up call for alert rules")
+		return
+	}
+	for _, m := range matched {
+		if !m.rule.callMatches(call) {
+			continue
+		}
+		ruleID := m.rule.ID
+		a := &database.Alert{
+			RuleID:        &ruleID,
+			RuleName:      m.rule.Name,
+			CallID:        t.CallID,
+			CallStartTime: t.StartTime,
+			SystemID:      t.SystemID,
+			Tgid:          t.Tgid,
+			UnitID:        call.Unit,
+			Emergency:     call.Emergency,
+			Matches:       m.matches,
+			Text:          t.Text,
+		}
+		if err := p.db.InsertAlert(ctx, a); err != nil {
+			p.log.Warn().Err(err).Str("rule", m.rule.Name).Int64("call_id", t.CallID).Msg("failed to store alert")
+			continue
+		}
+		p.log.Info().Str("rule", m.rule.Name).Int64("call_id", t.CallID).Int("tgid", t.Tgid).
+			Strs("matches", m.matches).Msg("alert raised")
+		p.PublishEvent(EventData{
+			Type:      events.TypeAlert,
+			SystemID:  t.SystemID,
+			Tgid:      t.Tgid,
+			UnitID:    call.Unit,
+			Emergency: call.Emergency,
+			Payload: &events.Alert{
+				AlertID:    a.ID,
+				RuleID:     ruleID,
+				RuleName:   m.rule.Name,
+				CallID:     t.CallID,
+				SystemID:   t.SystemID,
+				Tgid:       t.Tgid,
+				TgAlphaTag: call.TgAlphaTag,
+				Unit:       call.Unit,
+				Emergency:  call.Emergency,
+				Matches:    m.matches,
+				Text:       t.Text,
+				StartTime:  t.StartTime,
+			},
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package ingest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+func TestAlertRulesMatching(t *testing.T) {
+	sys := 1
+	ar := newAlertRules()
+	ar.set([]database.AlertRule{
+		{ID: 1, Name: "shots", Keywords: []string{"shots fired", " 10-33 "}, Enabled: true},
+		{ID: 2, Name: "plates", Pattern: `(?i)plate [A-Z0-9]{5,7}\b`, SystemID: &sys, Tgids: []int{9178}, Enabled: true},
+		{ID: 3, Name: "disabled", Keywords: []string{"units"}, Enabled: false},
+		{ID: 4, Name: "bad pattern", Pattern: `(`, Enabled: true},
+		{ID: 5, Name: "empty", Keywords: []string{"  "}, Enabled: true},
+	})
+	if len(ar.rules) != 2 {
+		t.Fatalf("cached %d rules, want 2 (disabled, invalid and empty rules skipped)", len(ar.rules))
+	}
+
+	names := func(ms []alertMatch) []string {
+		var out []string
+		for _, m := range ms {
+			out = append(out, m.rule.Name)
+		}
+		return out
+	}
+
+	// Phrases match across whitespace, case-insensitively, once each
+	got := ar.matching(1, 9178, "Shots  fired, SHOTS FIRED at Main and 10-33, plate abc1234")
+	if !reflect.DeepEqual(names(got), []string{"shots", "plates"}) {
+		t.Fatalf("matched %v", names(got))
+	}
+	if want := []string{"Shots  fired", "10-33"}; !reflect.DeepEqual(got[0].matches, want) {
+		t.Errorf("shots matches = %q, want %q", got[0].matches, want)
+	}
+	if want := []string{"plate abc1234"}; !reflect.DeepEqual(got[1].matches, want) {
+		t.Errorf("plates matches = %q, want %q", got[1].matches, want)
+	}
+
+	// Whole words only; the pattern rule is limited to its talkgroup
+	if got := ar.matching(1, 1234, "mugshots fired up, 10-330, plate abc1234"); len(got) != 0 {
+		t.Errorf("matched %v", names(got))
+	}
+}
+
+func TestAlertRuleCallMatches(t *testing.T) {
+	r := &alertRule{AlertRule: database.AlertRule{UnitIDs: []int{104, 924003}}}
+	if !r.callMatches(&database.AlertCall{Unit: 924003}) {
+		t.Error("initiating unit did not match")
+	}
+	if !r.callMatches(&database.AlertCall{Unit: 5, UnitIDs: []int{5, 104}}) {
+		t.Error("unit heard on the call did not match")
+	}
+	if r.callMatches(&database.AlertCall{Unit: 5, UnitIDs: []int{5, 6}}) {
+		t.Error("other units matched")
+	}
+
+	r = &alertRule{AlertRule: database.AlertRule{EmergencyOnly: true}}
+	if r.callMatches(&database.AlertCall{}) || !r.callMatches(&database.AlertCall{Emergency: true}) {
+		t.Error("emergency_only not applied")
+	}
+}
@@ -0,0 +1,87 @@
+package ingest
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/metrics"
+)
+
+// audioDedup remembers recently handled MQTT audio messages so the copies
+// trunk-recorder republishes after a broker reconnect (retained or resent
+// messages) are skipped before their audio is decoded. A message is claimed
+// when it arrives and forgotten again if handling it fails, so a later copy
+// can still be processed.
+type audioDedup struct {
+	window time.Duration
+
+	mu   sync.Mutex
+	seen map[audioDedupKey]time.Time // first seen
+}
+
+// audioDedupKey identifies an audio message: the instance and trunk-recorder's
+// call filename, or the system short name, talkgroup and start time when the
+// message has no filename.
+type audioDedupKey struct {
+	InstanceID string
+	Call       string
+}
+
+func newAudioDedup(window time.Duration) *audioDedup {
+	if window <= 0 {
+		return nil
+	}
+	return &audioDedup{window: window, seen: make(map[audioDedupKey]time.Time)}
+}
+
+func audioDedupKeyFor(instanceID string, meta *AudioMetadata) audioDedupKey {
+	call := meta.Filename
+	if call == "" {
+		call = meta.ShortName + "/" + strconv.Itoa(meta.Talkgroup) + "/" + strconv.FormatInt(meta.StartTime, 10)
+	}
+	return audioDedupKey{InstanceID: instanceID, Call: call}
+}
+
+// claim records a message and reports whether it is new: false when the same
+// message was claimed less than the window ago. A nil audioDedup claims
+// everything.
+func (d *audioDedup) claim(k audioDedupKey, now time.Time) bool {
+	if d == nil {
+		return true
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if first, ok := d.seen[k]; ok && now.Sub(first) < d.window {
+		return false
+	}
+	d.seen[k] = now
+	metrics.AudioDedupEntries.Set(float64(len(d.seen)))
+	return true
+}
+
+// forget drops a claim, so the next copy of the message is handled.
+func (d *audioDedup) forget(k audioDedupKey) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	delete(d.seen, k)
+	metrics.AudioDedupEntries.Set(float64(len(d.seen)))
+	d.mu.Unlock()
+}
+
+// sweep drops claims older than the window.
+func (d *audioDedup) sweep(now time.Time) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	for k, first := range d.seen {
+		if now.Sub(first) >= d.window {
+			delete(d.seen, k)
+		}
+	}
+	metrics.AudioDedupEntries.Set(float64(len(d.seen)))
+	d.mu.Unlock()
+}
@@ -0,0 +1,56 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAudioDedup(t *testing.T) {
+	d := newAudioDedup(time.Minute)
+	now := time.Now()
+	k := audioDedupKeyFor("tr-1", &AudioMetadata{Filename: "/tr/butco/9178-1709647629_851012500.m4a"})
+
+	if !d.claim(k, now) {
+		t.Fatal("first message should be new")
+	}
+	if d.claim(k, now.Add(30*time.Second)) {
+		t.Error("repeat within the window should be suppressed")
+	}
+	if !d.claim(audioDedupKeyFor("tr-2", &AudioMetadata{Filename: "/tr/butco/9178-1709647629_851012500.m4a"}), now) {
+		t.Error("same filename from another instance should be new")
+	}
+	if !d.claim(k, now.Add(time.Minute)) {
+		t.Error("repeat after the window should be new")
+	}
+
+	d.forget(k)
+	if !d.claim(k, now.Add(61*time.Second)) {
+		t.Error("forgotten message should be new")
+	}
+
+	d.sweep(now.Add(3 * time.Minute))
+	if len(d.seen) != 0 {
+		t.Errorf("sweep left %d entries", len(d.seen))
+	}
+}
+
+func TestAudioDedupKeyWithoutFilename(t *testing.T) {
+	a := audioDedupKeyFor("tr-1", &AudioMetadata{ShortName: "butco", Talkgroup: 9178, StartTime: 1709647629})
+	b := audioDedupKeyFor("tr-1", &AudioMetadata{ShortName: "butco", Talkgroup: 9178, StartTime: 1709647630})
+	if a == b || a.Call != "butco/9178/1709647629" {
+		t.Errorf("keys = %+v, %+v", a, b)
+	}
+}
+
+func TestAudioDedupDisabled(t *testing.T) {
+	d := newAudioDedup(0)
+	if d != nil {
+		t.Fatal("expected nil dedup for a zero window")
+	}
+	k := audioDedupKey{InstanceID: "tr-1", Call: "x"}
+	if !d.claim(k, time.Now()) || !d.claim(k, time.Now()) {
+		t.Error("disabled dedup should pass every message")
+	}
+	d.forget(k)
+	d.sweep(time.Now())
+}
@@ -2,6 +2,7 @@ package ingest
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,6 +16,7 @@ type Batcher[T any] struct {
 	timer    *time.Timer
 	stopped  bool
 	wg       sync.WaitGroup
+	flushed  atomic.Int64 // items handed to flushFn
 }
 
 // NewBatcher creates a batcher that calls flushFn when maxSize items accumulate
@@ -78,6 +80,18 @@ func (b *Batcher[T]) Stop() {
 	b.wg.Wait()
 }
 
+// Pending returns the number of items waiting for the next flush.
+func (b *Batcher[T]) Pending() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.items)
+}
+
+// Flushed returns the total number of items flushed so far.
+func (b *Batcher[T]) Flushed() int64 {
+	return b.flushed.Load()
+}
+
 func (b *Batcher[T]) flushLocked() {
 	if b.timer != nil {
 		b.timer.Stop()
@@ -85,6 +99,7 @@ func (b *Batcher[T]) flushLocked() {
 	}
 	items := b.items
 	b.items = nil
+	b.flushed.Add(int64(len(items)))
 	// Run flush outside lock to avoid deadlock
 	b.wg.Add(1)
 	go func() {
@@ -0,0 +1,111 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ParseResult is what ingest makes of one MQTT message before any database
+// work: the handler it routes to, the validation problems that would
+// quarantine it, and the message as the handler decodes it. The trcorpus
+// conformance runner compares it with golden files.
+type ParseResult struct {
+	Handler  string   `json:"handler"`
+	SysName  string   `json:"sys_name,omitempty"`
+	Problems []string `json:"problems,omitempty"`
+	Error    string   `json:"error,omitempty"` // decode error the handler would return
+	Message  any      `json:"message,omitempty"`
+}
+
+// unitEventResult is a decoded unit event. The payload keys its data by the
+// event type, which comes from the topic.
+type unitEventResult struct {
+	Envelope
+	Event string        `json:"event"`
+	Data  UnitEventData `json:"data"`
+}
+
+// issiCallResult is a decoded gateway call record, mapped to the metadata
+// and system it is stored as.
+type issiCallResult struct {
+	Call   *AudioMetadata  `json:"call"`
+	System *SystemInfoData `json:"system"`
+}
+
+// ParseMessage routes, validates and decodes a message the way HandleMessage
+// does, without a pipeline. now bounds the timestamp sanity checks. Instance
+// map attribution is not applied.
+func ParseMessage(topic string, payload []byte, now time.Time) *ParseResult {
+	route := ParseTopic(topic)
+	if route == nil {
+		return &ParseResult{Error: "unknown topic"}
+	}
+	res := &ParseResult{
+		Handler:  route.Handler,
+		SysName:  route.SysName,
+		Problems: validatePayload(route, topic, payload, now),
+	}
+	msg, err := decodeMessage(route, topic, payload)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Message = msg
+	return res
+}
+
+// decodeMessage unmarshals payload into the type route's handler uses.
+func decodeMessage(route *Route, topic string, payload []byte) (any, error) {
+	var msg any
+	switch route.Handler {
+	case "status":
+		msg = &StatusMsg{}
+	case "systems":
+		msg = &SystemsMsg{}
+	case "system":
+		msg = &SystemMsg{}
+	case "call_start":
+		msg = &CallStartMsg{}
+	case "call_end":
+		msg = &CallEndMsg{}
+	case "calls_active":
+		msg = &CallsActiveMsg{}
+	case "audio":
+		msg = &AudioMsg{}
+	case "recorders":
+		msg = &RecordersMsg{}
+	case "recorder":
+		msg = &RecorderMsg{}
+	case "rates":
+		msg = &RatesMsg{}
+	case "config":
+		msg = &ConfigMsg{}
+	case "trunking_message":
+		msg = &TrunkingMessageMsg{}
+	case "console":
+		msg = &ConsoleLogMsg{}
+	case "unit_event":
+		eventType, err := parseUnitEventTopic(topic)
+		if err != nil {
+			return nil, err
+		}
+		env, data, err := parseUnitEventData(payload, eventType)
+		if err != nil {
+			return nil, err
+		}
+		return &unitEventResult{Envelope: env, Event: eventType, Data: data}, nil
+	case "issi_call":
+		_, meta, sys, err := ParseISSICall(payload)
+		if err != nil {
+			return nil, err
+		}
+		return &issiCallResult{Call: meta, System: sys}, nil
+	default:
+		return nil, fmt.Errorf("no handler for route %q", route.Handler)
+	}
+	if err := json.Unmarshal(payload, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
@@ -0,0 +1,73 @@
+package ingest
+
+import (
+	"encoding/json"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/snarg/tr-engine/pkg/trcorpus"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite pkg/trcorpus golden files from the current parse results")
+
+// Every corpus message must parse as its golden file says, so a handler
+// change that drops or misreads a field from some trunk-recorder version
+// shows up here. After an intended change, regenerate with
+//
+//	go test ./internal/ingest -run TestCorpusConformance -update
+func TestCorpusConformance(t *testing.T) {
+	cases, err := trcorpus.Embedded()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) == 0 {
+		t.Fatal("empty corpus")
+	}
+	now := time.Now()
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			res := ParseMessage(c.Topic, c.Payload, now)
+			got, err := json.Marshal(res)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *updateGolden {
+				if err := trcorpus.WriteGolden("../../pkg/trcorpus/corpus", c, got); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			// Corpus messages are real trunk-recorder output: none may be quarantined
+			if len(res.Problems) > 0 || res.Error != "" {
+				t.Errorf("problems %v, error %q", res.Problems, res.Error)
+			}
+			if c.Golden == nil {
+				t.Fatalf("no golden file; run with -update")
+			}
+			if diff := trcorpus.Compare(c.Golden, got); diff != "" {
+				t.Errorf("parse result differs from golden file, %s", diff)
+			}
+		})
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	res := ParseMessage("trengine/units/butco/join",
+		[]byte(`{"timestamp":1700000000,"join":{"sys_name":"butco","unit":42,"talkgroup":9131}}`), now)
+	ue, ok := res.Message.(*unitEventResult)
+	if res.Handler != "unit_event" || res.SysName != "butco" || !ok || ue.Event != "join" || ue.Data.Unit != 42 {
+		t.Errorf("unit event = %+v", res)
+	}
+
+	res = ParseMessage("trengine/feeds/call_start", []byte(`{"timestamp":1700000000,"call":{"talkgroup":"9131"}}`), now)
+	if len(res.Problems) == 0 || res.Error == "" || res.Message != nil {
+		t.Errorf("invalid call_start = %+v", res)
+	}
+
+	if res := ParseMessage("trengine/feeds/nope", []byte(`{}`), now); res.Error != "unknown topic" {
+		t.Errorf("unknown topic = %+v", res)
+	}
+}
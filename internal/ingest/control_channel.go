@@ -0,0 +1,144 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snarg/tr-engine/pkg/events"
+)
+
+// Control channel tracking.
+//
+// Each site's current control channel is taken from rates messages
+// (control_channel), system/systems messages when they carry one, and P25
+// RFSS status broadcasts in trunking messages. When it differs from the last
+// recorded channel (e.g. the system failed over to an alternate control
+// channel), the change is stored in control_channel_history and a
+// control_channel event is published.
+
+// Sources of control channel observations.
+const (
+	ccSourceRates    = "rates"
+	ccSourceSystem   = "system"
+	ccSourceTrunking = "trunking_message"
+)
+
+type ccState struct {
+	Freq  int64
+	Since time.Time
+}
+
+// controlChannels holds each site's current control channel, by site ID.
+type controlChannels struct {
+	mu    sync.Mutex
+	sites map[int]ccState
+}
+
+func newControlChannels() *controlChannels {
+	return &controlChannels{sites: make(map[int]ccState)}
+}
+
+// parseControlChannelMsg recognizes a P25 RFSS status broadcast (the site's
+// own status, which names its current control channel) and extracts the
+// channel frequency from the message's meta. Adjacent-site broadcasts are
+// ignored: they describe other sites' channels.
+func parseControlChannelMsg(data TrunkingMessageData) (int64, bool) {
+	opType := strings.ToUpper(data.OpcodeType)
+	desc := strings.ToLower(data.OpcodeDesc)
+	if strings.Contains(opType, "ADJ") || strings.Contains(desc, "adjacent") {
+		return 0, false
+	}
+	if !strings.Contains(opType, "RFSS_STS") && !strings.Contains(desc, "rfss status") {
+		return 0, false
+	}
+
+	var obj map[string]any
+	if json.Unmarshal([]byte(data.Meta), &obj) == nil {
+		for _, k := range []string{"control_channel", "freq", "frequency"} {
+			if v, ok := metaNumber(obj[k]); ok && v > 0 {
+				return normalizeFreq(v), true
+			}
+		}
+		return 0, false
+	}
+	if m := interconnectFreqRe.FindStringSubmatch(data.Meta); m != nil {
+		if v, ok := metaNumber(m[1]); ok && v > 0 {
+			return normalizeFreq(v), true
+		}
+	}
+	return 0, false
+}
+
+// observeControlChannel records that sysName's site on instanceID reported
+// control channel freq (Hz) at ts. Repeats of the current channel are
+// ignored; a different channel is stored and announced. Sites not yet known
+// to the identity cache are skipped.
+func (p *Pipeline) observeControlChannel(instanceID, sysName string, freq int64, ts time.Time, source string) {
+	if freq <= 0 {
+		return
+	}
+	identity, ok := p.identity.Lookup(instanceID, sysName)
+	if !ok {
+		return
+	}
+	siteID := identity.SiteID
+
+	ctx, cancel := context.WithTimeout(p.ctx, 5*time.Second)
+	defer cancel()
+
+	c := p.controlChannels
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cur, ok := c.sites[siteID]
+	if ok && (cur.Freq == freq || ts.Before(cur.Since)) {
+		return
+	}
+	if !ok {
+		// First observation since startup: compare against the stored history
+		last, err := p.db.LastControlChannel(ctx, siteID)
+		if err != nil {
+			p.log.Warn().Err(err).Int("site_id", siteID).Msg("failed to load last control channel")
+			return
+		}
+		if last == freq {
+			c.sites[siteID] = ccState{Freq: freq, Since: ts}
+			return
+		}
+		cur.Freq = last
+	}
+
+	if err := p.db.InsertControlChannelChange(ctx, siteID, freq, cur.Freq, source, ts); err != nil {
+		p.log.Warn().Err(err).Int("site_id", siteID).Msg("failed to record control channel change")
+		return
+	}
+	c.sites[siteID] = ccState{Freq: freq, Since: ts}
+	if cur.Freq == 0 {
+		return // first channel recorded for the site; nothing changed
+	}
+
+	p.log.Info().
+		Str("sys_name", sysName).
+		Int("site_id", siteID).
+		Int64("freq", freq).
+		Int64("prev_freq", cur.Freq).
+		Str("source", source).
+		Msg("control channel changed")
+	p.PublishEvent(EventData{
+		Type:     events.TypeControlChannel,
+		SystemID: identity.SystemID,
+		SiteID:   siteID,
+		Payload: &events.ControlChannel{
+			SystemID: identity.SystemID,
+			SiteID:   siteID,
+			SysName:  sysName,
+			Freq:     freq,
+			PrevFreq: cur.Freq,
+			Source:   source,
+			Time:     ts,
+		},
+	})
+}
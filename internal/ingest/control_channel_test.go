@@ -0,0 +1,27 @@
+package ingest
+
+import "testing"
+
+func TestParseControlChannelMsg(t *testing.T) {
+	tests := []struct {
+		name string
+		data TrunkingMessageData
+		want int64
+		ok   bool
+	}{
+		{"rfss_json_hz", TrunkingMessageData{OpcodeType: "RFSS_STS_BCST", Meta: `{"freq": 851012500}`}, 851012500, true},
+		{"rfss_json_mhz_string", TrunkingMessageData{OpcodeType: "RFSS_STS_BCST", Meta: `{"control_channel": "851.0125"}`}, 851012500, true},
+		{"rfss_text", TrunkingMessageData{OpcodeDesc: "RFSS Status Broadcast", Meta: "rfss 1 site 3 freq: 852.2125"}, 852212500, true},
+		{"rfss_no_freq", TrunkingMessageData{OpcodeType: "RFSS_STS_BCST", Meta: `{"site": 3}`}, 0, false},
+		{"adjacent_site", TrunkingMessageData{OpcodeType: "ADJ_STS_BCST", OpcodeDesc: "Adjacent RFSS Status", Meta: `{"freq": 853000000}`}, 0, false},
+		{"grant", TrunkingMessageData{OpcodeType: "GRP_V_CH_GRANT", Meta: `{"freq": 851012500}`}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseControlChannelMsg(tt.data)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("got (%d, %v), want (%d, %v)", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package ingest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/pkg/events"
+)
+
+type discoveryKey struct {
+	kind     string
+	systemID int
+	id       int
+}
+
+// discoveries remembers which talkgroups and units this process has already
+// checked, so the "does it exist yet" lookup runs once per entity rather
+// than on every message.
+type discoveries struct {
+	mu   sync.Mutex
+	seen map[discoveryKey]struct{}
+}
+
+// claim returns true the first time k is seen.
+func (d *discoveries) claim(k discoveryKey) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.seen[k]; ok {
+		return false
+	}
+	if d.seen == nil {
+		d.seen = make(map[discoveryKey]struct{})
+	}
+	d.seen[k] = struct{}{}
+	return true
+}
+
+// forget lets k be claimed again (after a failed lookup).
+func (d *discoveries) forget(k discoveryKey) {
+	d.mu.Lock()
+	delete(d.seen, k)
+	d.mu.Unlock()
+}
+
+// isNew reports whether a talkgroup or unit has no row yet. Call it before
+// the upsert that creates the row.
+func (p *Pipeline) isNew(ctx context.Context, kind string, systemID, id int) bool {
+	k := discoveryKey{kind: kind, systemID: systemID, id: id}
+	if !p.discoveries.claim(k) {
+		return false
+	}
+	var exists bool
+	var err error
+	if kind == database.DiscoveryTalkgroup {
+		exists, err = p.db.TalkgroupExists(ctx, systemID, id)
+	} else {
+		exists, err = p.db.UnitExists(ctx, systemID, id)
+	}
+	if err != nil {
+		p.discoveries.forget(k)
+		return false
+	}
+	return !exists
+}
+
+// upsertTalkgroup upserts a talkgroup and announces it if it is new.
+func (p *Pipeline) upsertTalkgroup(ctx context.Context, systemID, tgid int, alphaTag, tag, group, description string, eventTime time.Time, source string) (string, error) {
+	isNew := p.isNew(ctx, database.DiscoveryTalkgroup, systemID, tgid)
+	dbTag, err := p.db.UpsertTalkgroup(ctx, systemID, tgid, alphaTag, tag, group, description, eventTime)
+	if err == nil && isNew {
+		if dbTag != "" {
+			alphaTag = dbTag
+		}
+		p.publishDiscovery(database.DiscoveryTalkgroup, systemID, tgid, 0, alphaTag, eventTime, source)
+	}
+	return dbTag, err
+}
+
+// upsertUnit upserts a unit and announces it if it is new. eventType is
+// stored as the unit's last event and reported as the discovery source.
+func (p *Pipeline) upsertUnit(ctx context.Context, systemID, unitID int, alphaTag, eventType string, eventTime time.Time, tgid int) (string, error) {
+	isNew := p.isNew(ctx, database.DiscoveryUnit, systemID, unitID)
+	dbTag, err := p.db.UpsertUnit(ctx, systemID, unitID, alphaTag, eventType, eventTime, tgid)
+	if err == nil && isNew {
+		if dbTag != "" {
+			alphaTag = dbTag
+		}
+		p.publishDiscovery(database.DiscoveryUnit, systemID, tgid, unitID, alphaTag, eventTime, eventType)
+	}
+	return dbTag, err
+}
+
+func (p *Pipeline) publishDiscovery(kind string, systemID, tgid, unitID int, alphaTag string, firstSeen time.Time, source string) {
+	p.log.Info().
+		Str("kind", kind).
+		Int("system_id", systemID).
+		Int("tgid", tgid).
+		Int("unit_id", unitID).
+		Str("alpha_tag", alphaTag).
+		Str("source", source).
+		Msg("new " + kind + " discovered")
+	p.PublishEvent(EventData{
+		Type:     events.TypeDiscovery,
+		SubType:  kind,
+		SystemID: systemID,
+		Tgid:     tgid,
+		UnitID:   unitID,
+		Payload: &events.Discovery{
+			Kind:      kind,
+			SystemID:  systemID,
+			Tgid:      tgid,
+			UnitID:    unitID,
+			AlphaTag:  alphaTag,
+			FirstSeen: firstSeen,
+			Source:    source,
+		},
+	})
+}
@@ -0,0 +1,63 @@
+package ingest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/api"
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/pkg/events"
+)
+
+func TestDiscoveriesClaim(t *testing.T) {
+	var d discoveries
+	k := discoveryKey{kind: database.DiscoveryTalkgroup, systemID: 1, id: 9044}
+	if !d.claim(k) {
+		t.Fatal("first claim should succeed")
+	}
+	if d.claim(k) {
+		t.Error("second claim should fail")
+	}
+	if !d.claim(discoveryKey{kind: database.DiscoveryUnit, systemID: 1, id: 9044}) {
+		t.Error("a unit with the same id is a different entity")
+	}
+	d.forget(k)
+	if !d.claim(k) {
+		t.Error("claim after forget should succeed")
+	}
+}
+
+func TestPublishDiscovery(t *testing.T) {
+	bus := NewEventBus(16)
+	p := &Pipeline{log: zerolog.Nop(), eventBus: bus}
+	ch, cancel := bus.Subscribe(api.EventFilter{Types: []string{"discovery:unit"}})
+	defer cancel()
+
+	first := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	p.publishDiscovery(database.DiscoveryTalkgroup, 1, 9044, 0, "Fire Dispatch", first, "call_start")
+	p.publishDiscovery(database.DiscoveryUnit, 1, 9044, 924003, "", first, "join")
+
+	select {
+	case e := <-ch:
+		if e.Type != events.TypeDiscovery || e.SubType != "unit" || e.UnitID != 924003 {
+			t.Fatalf("got %s:%s unit %d", e.Type, e.SubType, e.UnitID)
+		}
+		var d events.Discovery
+		if err := json.Unmarshal(e.Data, &d); err != nil {
+			t.Fatal(err)
+		}
+		if d.Kind != "unit" || d.Tgid != 9044 || d.Source != "join" || !d.FirstSeen.Equal(first) {
+			t.Errorf("payload = %+v", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no discovery:unit event")
+	}
+	select {
+	case e := <-ch:
+		t.Errorf("unexpected event %s:%s", e.Type, e.SubType)
+	default:
+	}
+}
@@ -0,0 +1,44 @@
+package ingest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// embargoes caches talkgroup_embargoes for event publishing: each
+// talkgroup's listener delay for non-admin subscribers.
+type embargoes struct {
+	mu     sync.RWMutex
+	delays map[[2]int]time.Duration
+}
+
+func (em *embargoes) set(list []database.Embargo) {
+	m := make(map[[2]int]time.Duration, len(list))
+	for _, e := range list {
+		m[[2]int{e.SystemID, e.Tgid}] = e.Delay()
+	}
+	em.mu.Lock()
+	em.delays = m
+	em.mu.Unlock()
+}
+
+// get returns a talkgroup's listener delay, or 0 if it isn't embargoed.
+func (em *embargoes) get(systemID, tgid int) time.Duration {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+	return em.delays[[2]int{systemID, tgid}]
+}
+
+// ReloadEmbargoes reloads talkgroup embargoes from the database. Called at
+// startup and after admin changes.
+func (p *Pipeline) ReloadEmbargoes(ctx context.Context) error {
+	list, err := p.db.ListEmbargoes(ctx)
+	if err != nil {
+		return err
+	}
+	p.embargoes.set(list)
+	return nil
+}
@@ -0,0 +1,93 @@
+package ingest
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/metrics"
+	"github.com/snarg/tr-engine/pkg/events"
+)
+
+// emergencyRepeatWindow suppresses repeat emergency events for a unit and
+// talkgroup: trunk-recorder reports one emergency in call_start and again in
+// the unit's call event, often from several sites.
+const emergencyRepeatWindow = 30 * time.Second
+
+type emergencyKey struct {
+	systemID int
+	tgid     int
+	unit     int
+}
+
+// emergencyTracker remembers recently published emergencies.
+type emergencyTracker struct {
+	mu   sync.Mutex
+	seen map[emergencyKey]time.Time
+}
+
+func newEmergencyTracker() *emergencyTracker {
+	return &emergencyTracker{seen: make(map[emergencyKey]time.Time)}
+}
+
+// first reports whether an emergency is new, i.e. none was published for key
+// within emergencyRepeatWindow of now, and records it if so.
+func (t *emergencyTracker) first(key emergencyKey, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if last, ok := t.seen[key]; ok && now.Sub(last) < emergencyRepeatWindow {
+		return false
+	}
+	for k, at := range t.seen {
+		if now.Sub(at) >= emergencyRepeatWindow {
+			delete(t.seen, k)
+		}
+	}
+	t.seen[key] = now
+	return true
+}
+
+// publishEmergency is the emergency fast path. Handlers call it right after
+// resolving identity, so the emergency event goes out ahead of the talkgroup,
+// unit and call writes for the message. Latency from trunk-recorder's
+// timestamp is recorded as the "event" stage of EmergencyLatency.
+func (p *Pipeline) publishEmergency(siteID int, encrypted bool, e events.Emergency) {
+	if !p.emergencies.first(emergencyKey{systemID: e.SystemID, tgid: e.Tgid, unit: e.Unit}, time.Now()) {
+		return
+	}
+	p.PublishEvent(EventData{
+		Type:       events.TypeEmergency,
+		SystemID:   e.SystemID,
+		SiteID:     siteID,
+		Tgid:       e.Tgid,
+		UnitID:     e.Unit,
+		Emergency:  true,
+		Restricted: p.restrictedEncrypted(e.SystemID, e.Tgid, encrypted),
+		Payload:    &e,
+	})
+	metrics.EmergencyLatency.WithLabelValues("event").Observe(time.Since(e.Time).Seconds())
+	p.log.Info().
+		Int("system_id", e.SystemID).
+		Int("tgid", e.Tgid).
+		Int("unit", e.Unit).
+		Str("source", e.Source).
+		Msg("emergency")
+}
+
+// isEmergencyMessage reports whether a buffered message is an emergency
+// call_start or unit event, so warmup replays it first.
+func isEmergencyMessage(route *Route, topic string, payload []byte) bool {
+	switch route.Handler {
+	case "call_start":
+		var msg CallStartMsg
+		return json.Unmarshal(payload, &msg) == nil && msg.Call.Emergency
+	case "unit_event":
+		eventType, err := parseUnitEventTopic(topic)
+		if err != nil {
+			return false
+		}
+		_, data, err := parseUnitEventData(payload, eventType)
+		return err == nil && data.Emergency
+	}
+	return false
+}
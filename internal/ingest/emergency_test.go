@@ -0,0 +1,79 @@
+package ingest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/api"
+	"github.com/snarg/tr-engine/pkg/events"
+)
+
+func TestPublishEmergency(t *testing.T) {
+	bus := NewEventBus(16)
+	p := &Pipeline{log: zerolog.Nop(), eventBus: bus, activeCalls: newActiveCallMap(),
+		occupancy: newOccupancyTracker(), emergencies: newEmergencyTracker()}
+	ch, cancel := bus.Subscribe(api.EventFilter{EmergencyOnly: true})
+	defer cancel()
+
+	now := time.Now()
+	p.publishEmergency(3, false, events.Emergency{SystemID: 1, Tgid: 9044, Unit: 1234, Source: "call_start", TrCallID: "1_9044_1", Time: now})
+	select {
+	case e := <-ch:
+		var em events.Emergency
+		if err := json.Unmarshal(e.Data, &em); err != nil {
+			t.Fatal(err)
+		}
+		if e.Type != events.TypeEmergency || e.SiteID != 3 || em.Unit != 1234 || em.TrCallID != "1_9044_1" {
+			t.Fatalf("event = %+v, payload %+v", e, em)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("no emergency event")
+	}
+
+	// The same emergency reported again by the unit's call event is not repeated.
+	p.publishEmergency(3, false, events.Emergency{SystemID: 1, Tgid: 9044, Unit: 1234, Source: "call", Time: now})
+	select {
+	case e := <-ch:
+		t.Fatalf("repeat published: %+v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestEmergencyTracker(t *testing.T) {
+	tr := newEmergencyTracker()
+	key := emergencyKey{systemID: 1, tgid: 9044, unit: 1234}
+	now := time.Now()
+	if !tr.first(key, now) {
+		t.Fatal("first emergency not new")
+	}
+	if tr.first(key, now.Add(10*time.Second)) {
+		t.Error("repeat within window reported new")
+	}
+	if !tr.first(emergencyKey{systemID: 1, tgid: 9044, unit: 5678}, now) {
+		t.Error("other unit not new")
+	}
+	if !tr.first(key, now.Add(emergencyRepeatWindow)) {
+		t.Error("emergency after window not new")
+	}
+}
+
+func TestIsEmergencyMessage(t *testing.T) {
+	tests := []struct {
+		handler, topic, payload string
+		want                    bool
+	}{
+		{"call_start", "tr/call_start", `{"call":{"id":"1_9044_1","emergency":true}}`, true},
+		{"call_start", "tr/call_start", `{"call":{"id":"1_9044_1"}}`, false},
+		{"unit_event", "tr/units/sys/call", `{"call":{"unit":1234,"emergency":true}}`, true},
+		{"unit_event", "tr/units/sys/on", `{"on":{"unit":1234}}`, false},
+		{"audio", "tr/audio", `{"call":{"emergency":true}}`, false},
+	}
+	for _, tt := range tests {
+		if got := isEmergencyMessage(&Route{Handler: tt.handler}, tt.topic, []byte(tt.payload)); got != tt.want {
+			t.Errorf("%s %s = %v, want %v", tt.handler, tt.payload, got, tt.want)
+		}
+	}
+}
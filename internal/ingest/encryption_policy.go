@@ -0,0 +1,96 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/metrics"
+	"github.com/snarg/tr-engine/pkg/events"
+)
+
+// ErrEncryptedSuppressed is returned by handlers that dropped an encrypted
+// call, audio file or unit event because its talkgroup's encryption policy
+// is suppress.
+var ErrEncryptedSuppressed = errors.New("encrypted traffic suppressed by encryption policy")
+
+// encryptionPolicies caches encryption_policies for the ingest hot path.
+// Keys are (system_id, tgid); tgid 0 is the system-wide policy.
+type encryptionPolicies struct {
+	mu       sync.RWMutex
+	policies map[[2]int]string
+}
+
+func (ep *encryptionPolicies) set(list []database.EncryptionPolicy) {
+	m := make(map[[2]int]string, len(list))
+	for _, p := range list {
+		m[[2]int{p.SystemID, p.Tgid}] = p.Policy
+	}
+	ep.mu.Lock()
+	ep.policies = m
+	ep.mu.Unlock()
+}
+
+// get returns the effective policy for a talkgroup: its own, else its
+// system's, else metadata.
+func (ep *encryptionPolicies) get(systemID, tgid int) string {
+	ep.mu.RLock()
+	defer ep.mu.RUnlock()
+	if p, ok := ep.policies[[2]int{systemID, tgid}]; ok {
+		return p
+	}
+	if p, ok := ep.policies[[2]int{systemID, 0}]; ok {
+		return p
+	}
+	return database.EncryptionPolicyMetadata
+}
+
+// ReloadEncryptionPolicies reloads encryption policies from the database.
+// Called at startup and after admin changes.
+func (p *Pipeline) ReloadEncryptionPolicies(ctx context.Context) error {
+	list, err := p.db.ListEncryptionPolicies(ctx)
+	if err != nil {
+		return err
+	}
+	p.encryptionPolicies.set(list)
+	return nil
+}
+
+// suppressEncrypted reports whether an encrypted message on a talkgroup must
+// be dropped, counting it under kind if so.
+func (p *Pipeline) suppressEncrypted(systemID, tgid int, encrypted bool, kind string) bool {
+	if !encrypted || p.encryptionPolicies.get(systemID, tgid) != database.EncryptionPolicySuppress {
+		return false
+	}
+	metrics.EncryptedSuppressedTotal.WithLabelValues(kind).Inc()
+	return true
+}
+
+// restrictedEncrypted reports whether an encrypted call on a talkgroup is
+// visible to admins only.
+func (p *Pipeline) restrictedEncrypted(systemID, tgid int, encrypted bool) bool {
+	return encrypted && p.encryptionPolicies.get(systemID, tgid) == database.EncryptionPolicyRestricted
+}
+
+// restrictedTalkgroup reports whether live audio on a talkgroup is hidden
+// from non-admin listeners: the talkgroup is embargoed, or its policy is
+// restricted and it has an encrypted call in progress.
+func (p *Pipeline) restrictedTalkgroup(systemID, tgid int) bool {
+	if p.embargoes.get(systemID, tgid) > 0 {
+		return true
+	}
+	return p.encryptionPolicies.get(systemID, tgid) == database.EncryptionPolicyRestricted &&
+		p.activeCalls.encryptedTalkgroup(systemID, tgid)
+}
+
+// restrictedEvent reports whether an event describes a restricted call.
+func (p *Pipeline) restrictedEvent(e EventData) bool {
+	switch ev := e.Payload.(type) {
+	case *events.CallStart:
+		return p.restrictedEncrypted(e.SystemID, e.Tgid, ev.Encrypted)
+	case *events.CallEnd:
+		return p.restrictedEncrypted(e.SystemID, e.Tgid, ev.Encrypted)
+	}
+	return false
+}
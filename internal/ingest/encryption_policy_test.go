@@ -0,0 +1,81 @@
+package ingest
+
+import (
+	"testing"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/pkg/events"
+)
+
+func TestEncryptionPolicies(t *testing.T) {
+	p := &Pipeline{}
+	if got := p.encryptionPolicies.get(1, 100); got != database.EncryptionPolicyMetadata {
+		t.Errorf("no policies: got %q, want metadata", got)
+	}
+
+	p.encryptionPolicies.set([]database.EncryptionPolicy{
+		{SystemID: 1, Tgid: 0, Policy: database.EncryptionPolicySuppress},
+		{SystemID: 1, Tgid: 200, Policy: database.EncryptionPolicyMetadata},
+		{SystemID: 2, Tgid: 300, Policy: database.EncryptionPolicyRestricted},
+	})
+
+	tests := []struct {
+		systemID, tgid int
+		encrypted      bool
+		suppress       bool
+		restricted     bool
+	}{
+		{1, 100, true, true, false},   // system-wide suppress
+		{1, 100, false, false, false}, // clear traffic is never affected
+		{1, 200, true, false, false},  // talkgroup overrides system
+		{2, 300, true, false, true},
+		{2, 301, true, false, false},
+		{3, 100, true, false, false},
+	}
+	for _, tt := range tests {
+		if got := p.suppressEncrypted(tt.systemID, tt.tgid, tt.encrypted, "call"); got != tt.suppress {
+			t.Errorf("suppressEncrypted(%d, %d, %v) = %v, want %v", tt.systemID, tt.tgid, tt.encrypted, got, tt.suppress)
+		}
+		if got := p.restrictedEncrypted(tt.systemID, tt.tgid, tt.encrypted); got != tt.restricted {
+			t.Errorf("restrictedEncrypted(%d, %d, %v) = %v, want %v", tt.systemID, tt.tgid, tt.encrypted, got, tt.restricted)
+		}
+	}
+
+	if !p.restrictedEvent(EventData{SystemID: 2, Tgid: 300, Payload: &events.CallEnd{Encrypted: true}}) {
+		t.Error("encrypted call_end on restricted talkgroup should be restricted")
+	}
+	if p.restrictedEvent(EventData{SystemID: 2, Tgid: 300, Payload: &events.CallEnd{}}) {
+		t.Error("clear call_end should not be restricted")
+	}
+}
+
+func TestRestrictedTalkgroup(t *testing.T) {
+	p := &Pipeline{activeCalls: newActiveCallMap()}
+	p.embargoes.set([]database.Embargo{{SystemID: 1, Tgid: 100, DelayMinutes: 30}})
+	p.encryptionPolicies.set([]database.EncryptionPolicy{
+		{SystemID: 2, Tgid: 300, Policy: database.EncryptionPolicyRestricted},
+	})
+
+	if !p.restrictedTalkgroup(1, 100) {
+		t.Error("embargoed talkgroup should be restricted")
+	}
+	if p.restrictedTalkgroup(1, 101) {
+		t.Error("open talkgroup should not be restricted")
+	}
+	if p.restrictedTalkgroup(2, 300) {
+		t.Error("restricted policy with no encrypted call should not be restricted")
+	}
+
+	p.activeCalls.Set("a", activeCallEntry{SystemID: 2, Tgid: 300})
+	if p.restrictedTalkgroup(2, 300) {
+		t.Error("restricted policy with a clear call should not be restricted")
+	}
+	p.activeCalls.Set("b", activeCallEntry{SystemID: 2, Tgid: 300, Encrypted: true})
+	if !p.restrictedTalkgroup(2, 300) {
+		t.Error("restricted policy with an encrypted call should be restricted")
+	}
+	p.activeCalls.Delete("b")
+	if p.restrictedTalkgroup(2, 300) {
+		t.Error("restricted policy after the encrypted call ends should not be restricted")
+	}
+}
@@ -0,0 +1,272 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/expr"
+	"github.com/snarg/tr-engine/internal/metrics"
+	"github.com/snarg/tr-engine/pkg/events"
+)
+
+// maxEnrichmentResponse caps the body read from an enrichment hook.
+const maxEnrichmentResponse = 1 << 20
+
+// enrichmentRequest is the body POSTed to enrichment hooks.
+type enrichmentRequest struct {
+	Hook  string          `json:"hook"`
+	Event string          `json:"event"`
+	Call  *events.CallEnd `json:"call"`
+}
+
+// enrichmentHook is a cached hook with its circuit breaker state.
+type enrichmentHook struct {
+	database.EnrichmentHook
+	condition *expr.Program // nil = always
+
+	mu        sync.Mutex
+	failures  int // consecutive
+	openUntil time.Time
+}
+
+// allow reports whether the hook may be called: its circuit is closed, or
+// its cooldown has passed and it gets another try.
+func (h *enrichmentHook) allow(now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.openUntil.IsZero() {
+		return true
+	}
+	if now.Before(h.openUntil) {
+		return false
+	}
+	h.openUntil = time.Time{}
+	metrics.EnrichmentHookCircuitOpen.WithLabelValues(h.Name).Set(0)
+	return true
+}
+
+// record updates the breaker with a call's outcome. The circuit opens after
+// FailureThreshold consecutive failures; failures stay counted through the
+// cooldown, so the first failure after it reopens the circuit.
+func (h *enrichmentHook) record(ok bool, now time.Time) (opened bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ok {
+		h.failures = 0
+		return false
+	}
+	h.failures++
+	if h.failures < h.FailureThreshold || !h.openUntil.IsZero() {
+		return false
+	}
+	h.openUntil = now.Add(time.Duration(h.Cooldown) * time.Second)
+	metrics.EnrichmentHookCircuitOpen.WithLabelValues(h.Name).Set(1)
+	return true
+}
+
+// enrichmentHooks caches enabled enrichment hooks for call_end.
+type enrichmentHooks struct {
+	mu     sync.RWMutex
+	hooks  []*enrichmentHook
+	client *http.Client
+}
+
+func newEnrichmentHooks() *enrichmentHooks {
+	return &enrichmentHooks{client: &http.Client{}}
+}
+
+// set replaces the cached hooks, keeping breaker state for hooks whose
+// settings are unchanged.
+func (eh *enrichmentHooks) set(list []database.EnrichmentHook) {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+	old := make(map[int]*enrichmentHook, len(eh.hooks))
+	for _, h := range eh.hooks {
+		old[h.ID] = h
+	}
+	hooks := make([]*enrichmentHook, 0, len(list))
+	for _, h := range list {
+		if !h.Enabled {
+			continue
+		}
+		if prev, ok := old[h.ID]; ok && prev.UpdatedAt.Equal(h.UpdatedAt) {
+			hooks = append(hooks, prev)
+			continue
+		}
+		hook := &enrichmentHook{EnrichmentHook: h}
+		if h.Condition != "" {
+			cond, err := expr.Compile(h.Condition)
+			if err != nil {
+				// Conditions are validated on save; one that doesn't compile
+				// (e.g. edited in the database) disables its hook.
+				continue
+			}
+			hook.condition = cond
+		}
+		metrics.EnrichmentHookCircuitOpen.WithLabelValues(h.Name).Set(0)
+		hooks = append(hooks, hook)
+	}
+	eh.hooks = hooks
+}
+
+// matching returns the hooks that apply to a call, in ID order.
+func (eh *enrichmentHooks) matching(systemID, tgid int) []*enrichmentHook {
+	eh.mu.RLock()
+	defer eh.mu.RUnlock()
+	var out []*enrichmentHook
+	for _, h := range eh.hooks {
+		if h.Matches(systemID, tgid) {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// withCondition drops hooks whose condition the call doesn't satisfy. The
+// call is converted to expression variables only if some hook has one.
+func withCondition(hooks []*enrichmentHook, ce *events.CallEnd) []*enrichmentHook {
+	var vars map[string]any
+	out := hooks[:0:0]
+	for _, h := range hooks {
+		if h.condition != nil {
+			if vars == nil {
+				var err error
+				if vars, err = expr.Vars(ce); err != nil {
+					continue
+				}
+			}
+			if !h.condition.Match(vars) {
+				continue
+			}
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
+// call POSTs a call to one hook and returns the fields it sent back (nil
+// for an empty response).
+func (eh *enrichmentHooks) call(ctx context.Context, h *enrichmentHook, ce *events.CallEnd) (map[string]json.RawMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(h.TimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	body, err := json.Marshal(enrichmentRequest{Hook: h.Name, Event: events.TypeCallEnd, Call: ce})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range h.Headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := eh.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxEnrichmentResponse))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("response is not a JSON object: %w", err)
+	}
+	return fields, nil
+}
+
+// ReloadEnrichmentHooks reloads enrichment hooks from the database. Called
+// at startup and after admin changes.
+func (p *Pipeline) ReloadEnrichmentHooks(ctx context.Context) error {
+	list, err := p.db.ListEnrichmentHooks(ctx)
+	if err != nil {
+		return err
+	}
+	p.enrichment.set(list)
+	return nil
+}
+
+// enrichCallEnd runs the enrichment hooks matching a call_end before it is
+// published. Hooks run in parallel, each bounded by its own timeout; their
+// fields are merged in hook ID order (later hooks win on a conflict), stored
+// in the call's metadata_json and set on the event. A failed hook only
+// loses its fields.
+func (p *Pipeline) enrichCallEnd(ce *events.CallEnd) {
+	if p.enrichment == nil {
+		return
+	}
+	hooks := withCondition(p.enrichment.matching(ce.SystemID, ce.Tgid), ce)
+	if len(hooks) == 0 {
+		return
+	}
+
+	results := make([]map[string]json.RawMessage, len(hooks))
+	var wg sync.WaitGroup
+	for i, h := range hooks {
+		if !h.allow(time.Now()) {
+			metrics.EnrichmentHookRequestsTotal.WithLabelValues(h.Name, "circuit_open").Inc()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			fields, err := p.enrichment.call(p.ctx, h, ce)
+			metrics.EnrichmentHookDuration.WithLabelValues(h.Name).Observe(time.Since(start).Seconds())
+			result := "ok"
+			switch {
+			case errors.Is(err, context.DeadlineExceeded):
+				result = "timeout"
+			case err != nil:
+				result = "error"
+			}
+			metrics.EnrichmentHookRequestsTotal.WithLabelValues(h.Name, result).Inc()
+			if h.record(err == nil, time.Now()) {
+				p.log.Warn().Str("hook", h.Name).Int("cooldown_s", h.Cooldown).Msg("enrichment hook circuit opened")
+			}
+			if err != nil {
+				p.log.Warn().Err(err).Str("hook", h.Name).Int64("call_id", ce.CallID).Msg("enrichment hook failed")
+				return
+			}
+			results[i] = fields
+		}()
+	}
+	wg.Wait()
+
+	merged := map[string]json.RawMessage{}
+	for _, fields := range results {
+		for k, v := range fields {
+			merged[k] = v
+		}
+	}
+	if len(merged) == 0 {
+		return
+	}
+	raw, err := json.Marshal(merged)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(p.ctx, 5*time.Second)
+	defer cancel()
+	if err := p.db.MergeCallMetadata(ctx, ce.CallID, ce.StartTime, raw); err != nil {
+		p.log.Warn().Err(err).Int64("call_id", ce.CallID).Msg("failed to store enrichment fields")
+	}
+	ce.Enrichment = raw
+}
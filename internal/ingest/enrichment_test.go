@@ -0,0 +1,141 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/pkg/events"
+)
+
+func TestEnrichmentHookBreaker(t *testing.T) {
+	h := &enrichmentHook{EnrichmentHook: database.EnrichmentHook{Name: "cad", FailureThreshold: 2, Cooldown: 60}}
+	now := time.Now()
+
+	if h.record(false, now) {
+		t.Fatal("opened after 1 failure")
+	}
+	if !h.record(false, now) {
+		t.Fatal("not opened after 2 failures")
+	}
+	if h.allow(now.Add(59 * time.Second)) {
+		t.Fatal("allowed during cooldown")
+	}
+	// After the cooldown one try is allowed; failing it reopens at once.
+	if !h.allow(now.Add(61 * time.Second)) {
+		t.Fatal("not allowed after cooldown")
+	}
+	if !h.record(false, now.Add(61*time.Second)) {
+		t.Fatal("not reopened by failure after cooldown")
+	}
+	if !h.allow(now.Add(122*time.Second)) || h.record(true, now.Add(122*time.Second)) {
+		t.Fatal("success after cooldown should close the circuit")
+	}
+	if h.record(false, now.Add(123*time.Second)) {
+		t.Fatal("success did not reset the failure count")
+	}
+}
+
+func TestEnrichmentHooksCall(t *testing.T) {
+	var got enrichmentRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		switch r.URL.Path {
+		case "/ok":
+			w.Write([]byte(`{"incident":"24-001","address":"1 Main St"}`))
+		case "/empty":
+		case "/array":
+			w.Write([]byte(`[1,2]`))
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	eh := newEnrichmentHooks()
+	hook := func(path string, timeoutMs int) *enrichmentHook {
+		return &enrichmentHook{EnrichmentHook: database.EnrichmentHook{
+			Name: "cad", URL: srv.URL + path, TimeoutMs: timeoutMs,
+			Headers: map[string]string{"Authorization": "Bearer secret"},
+		}}
+	}
+	ce := &events.CallEnd{CallID: 42, SystemID: 1, Tgid: 9044}
+	ctx := context.Background()
+
+	fields, err := eh.call(ctx, hook("/ok", 1000), ce)
+	if err != nil || string(fields["incident"]) != `"24-001"` || len(fields) != 2 {
+		t.Fatalf("ok: fields %v, err %v", fields, err)
+	}
+	if got.Hook != "cad" || got.Event != events.TypeCallEnd || got.Call == nil || got.Call.CallID != 42 {
+		t.Fatalf("request = %+v", got)
+	}
+	if fields, err := eh.call(ctx, hook("/empty", 1000), ce); err != nil || fields != nil {
+		t.Fatalf("empty: fields %v, err %v", fields, err)
+	}
+	if _, err := eh.call(ctx, hook("/array", 1000), ce); err == nil {
+		t.Fatal("array response accepted")
+	}
+	if _, err := eh.call(ctx, hook("/fail", 1000), ce); err == nil {
+		t.Fatal("502 accepted")
+	}
+	if _, err := eh.call(ctx, hook("/slow", 50), ce); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("slow: err %v, want deadline exceeded", err)
+	}
+}
+
+func TestEnrichmentHooksSet(t *testing.T) {
+	sys := 1
+	updated := time.Now()
+	eh := newEnrichmentHooks()
+	eh.set([]database.EnrichmentHook{
+		{ID: 1, Name: "all", Enabled: true, FailureThreshold: 1, Cooldown: 60, UpdatedAt: updated},
+		{ID: 2, Name: "tg", Enabled: true, SystemID: &sys, Tgids: []int{9044}, UpdatedAt: updated},
+		{ID: 3, Name: "off", Enabled: false},
+	})
+	if m := eh.matching(1, 9044); len(m) != 2 || m[0].ID != 1 || m[1].ID != 2 {
+		t.Fatalf("matching(1, 9044) = %d hooks", len(m))
+	}
+	if m := eh.matching(2, 9044); len(m) != 1 || m[0].ID != 1 {
+		t.Fatalf("matching(2, 9044) = %d hooks", len(m))
+	}
+
+	// Breaker state survives a reload unless the hook was edited.
+	eh.matching(1, 1)[0].record(false, updated)
+	eh.set([]database.EnrichmentHook{{ID: 1, Name: "all", Enabled: true, FailureThreshold: 1, Cooldown: 60, UpdatedAt: updated}})
+	if eh.matching(1, 1)[0].allow(updated) {
+		t.Fatal("open circuit reset by unchanged reload")
+	}
+	eh.set([]database.EnrichmentHook{{ID: 1, Name: "all", Enabled: true, FailureThreshold: 1, Cooldown: 60, UpdatedAt: updated.Add(time.Second)}})
+	if !eh.matching(1, 1)[0].allow(updated) {
+		t.Fatal("edited hook kept its open circuit")
+	}
+}
+
+func TestEnrichmentHookCondition(t *testing.T) {
+	eh := newEnrichmentHooks()
+	eh.set([]database.EnrichmentHook{
+		{ID: 1, Name: "long", Enabled: true, Condition: "duration > 15"},
+		{ID: 2, Name: "all", Enabled: true},
+		{ID: 3, Name: "broken", Enabled: true, Condition: "duration >"},
+	})
+	if n := len(eh.matching(1, 1)); n != 2 {
+		t.Fatalf("broken condition not skipped: %d hooks", n)
+	}
+	if m := withCondition(eh.matching(1, 1), &events.CallEnd{Duration: 20}); len(m) != 2 {
+		t.Errorf("long call: %d hooks, want 2", len(m))
+	}
+	if m := withCondition(eh.matching(1, 1), &events.CallEnd{Duration: 5}); len(m) != 1 || m[0].ID != 2 {
+		t.Errorf("short call: %d hooks, want only unconditional", len(m))
+	}
+}
@@ -1,6 +1,7 @@
 package ingest
 
 import (
+	"container/heap"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -25,6 +26,33 @@ type EventBus struct {
 	ringSize int
 	ringHead int
 	ringMu   sync.RWMutex
+
+	// sink receives every event (unfiltered, never dropped here); set once
+	// before publishing starts. Must not block.
+	sink func(api.SSEEvent)
+
+	// Embargoed events waiting to reach non-admin subscribers
+	delayMu    sync.Mutex
+	delayed    delayedEvents
+	delayTimer *time.Timer
+}
+
+// maxDelayedEvents caps the embargoed events held for non-admin
+// subscribers; beyond it they are dropped like events for a slow subscriber.
+const maxDelayedEvents = 50000
+
+// delayedEvents is a min-heap of events by VisibleAt.
+type delayedEvents []api.SSEEvent
+
+func (d delayedEvents) Len() int           { return len(d) }
+func (d delayedEvents) Less(i, j int) bool { return d[i].VisibleAt.Before(d[j].VisibleAt) }
+func (d delayedEvents) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d *delayedEvents) Push(x any)        { *d = append(*d, x.(api.SSEEvent)) }
+func (d *delayedEvents) Pop() any {
+	old := *d
+	e := old[len(old)-1]
+	*d = old[:len(old)-1]
+	return e
 }
 
 type subscriber struct {
@@ -110,7 +138,13 @@ type EventData struct {
 	Tgid      int
 	UnitID    int
 	Emergency bool
-	Payload   any
+	// Restricted limits the event to admin subscribers (restricted
+	// encryption policy). The bridge sink still receives it.
+	Restricted bool
+	// Embargo holds the event back from non-admin subscribers for this
+	// long (talkgroup embargo). The bridge sink still receives it at once.
+	Embargo time.Duration
+	Payload any
 }
 
 // Publish sends an event to all matching subscribers and adds it to the ring buffer.
@@ -124,16 +158,20 @@ func (eb *EventBus) Publish(e EventData) {
 
 	seq := eb.seq.Add(1)
 	event := api.SSEEvent{
-		ID:        fmt.Sprintf("%d-%d", time.Now().UnixMilli(), seq),
-		Type:      e.Type,
-		SubType:   e.SubType,
-		Timestamp: time.Now().UTC().Format(time.RFC3339),
-		SystemID:  e.SystemID,
-		SiteID:    e.SiteID,
-		Tgid:      e.Tgid,
-		UnitID:    e.UnitID,
-		Emergency: e.Emergency,
-		Data:      data,
+		ID:         fmt.Sprintf("%d-%d", time.Now().UnixMilli(), seq),
+		Type:       e.Type,
+		SubType:    e.SubType,
+		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		SystemID:   e.SystemID,
+		SiteID:     e.SiteID,
+		Tgid:       e.Tgid,
+		UnitID:     e.UnitID,
+		Emergency:  e.Emergency,
+		Restricted: e.Restricted,
+		Data:       data,
+	}
+	if e.Embargo > 0 {
+		event.VisibleAt = time.Now().Add(e.Embargo)
 	}
 
 	// Add to ring buffer
@@ -142,10 +180,15 @@ func (eb *EventBus) Publish(e EventData) {
 	eb.ringHead = (eb.ringHead + 1) % eb.ringSize
 	eb.ringMu.Unlock()
 
+	if eb.sink != nil {
+		eb.sink(event)
+	}
+
 	// Distribute to subscribers
+	vars := api.NewEventVars(&event)
 	eb.mu.RLock()
 	for _, sub := range eb.subscribers {
-		if matchesFilter(event, sub.filter) {
+		if matchFilter(event, sub.filter, vars) {
 			select {
 			case sub.ch <- event:
 			default:
@@ -154,6 +197,65 @@ func (eb *EventBus) Publish(e EventData) {
 		}
 	}
 	eb.mu.RUnlock()
+
+	if !event.VisibleAt.IsZero() && !event.Restricted {
+		eb.hold(event)
+	}
+}
+
+// hold queues an embargoed event for non-admin subscribers until its
+// VisibleAt. Admin subscribers already received it from Publish.
+func (eb *EventBus) hold(e api.SSEEvent) {
+	eb.delayMu.Lock()
+	defer eb.delayMu.Unlock()
+	if eb.delayed.Len() >= maxDelayedEvents {
+		return
+	}
+	heap.Push(&eb.delayed, e)
+	if eb.delayed[0].ID == e.ID {
+		eb.scheduleLocked()
+	}
+}
+
+// scheduleLocked arms the timer for the earliest held event.
+func (eb *EventBus) scheduleLocked() {
+	if eb.delayed.Len() == 0 {
+		return
+	}
+	d := time.Until(eb.delayed[0].VisibleAt)
+	if eb.delayTimer == nil {
+		eb.delayTimer = time.AfterFunc(d, eb.release)
+	} else {
+		eb.delayTimer.Reset(d)
+	}
+}
+
+// release delivers held events that have become visible to the non-admin
+// subscribers they match, including any that subscribed while they were held.
+func (eb *EventBus) release() {
+	now := time.Now()
+	var due []api.SSEEvent
+	eb.delayMu.Lock()
+	for eb.delayed.Len() > 0 && !eb.delayed[0].VisibleAt.After(now) {
+		due = append(due, heap.Pop(&eb.delayed).(api.SSEEvent))
+	}
+	eb.scheduleLocked()
+	eb.delayMu.Unlock()
+
+	for i := range due {
+		e := due[i]
+		vars := api.NewEventVars(&e)
+		eb.mu.RLock()
+		for _, sub := range eb.subscribers {
+			if !sub.filter.IncludeRestricted && matchFilter(e, sub.filter, vars) {
+				select {
+				case sub.ch <- e:
+				default:
+				}
+			}
+		}
+		eb.mu.RUnlock()
+	}
 }
 
 // SubscriberCount returns the current number of SSE subscribers.
@@ -165,9 +267,24 @@ func (eb *EventBus) SubscriberCount() int {
 }
 
 func matchesFilter(e api.SSEEvent, f api.EventFilter) bool {
+	return matchFilter(e, f, api.NewEventVars(&e))
+}
+
+// matchFilter is matchesFilter with the event's expression variables, shared
+// across the filters one event is matched against.
+func matchFilter(e api.SSEEvent, f api.EventFilter, vars *api.EventVars) bool {
+	if e.Restricted && !f.IncludeRestricted {
+		return false
+	}
+	if !e.VisibleAt.IsZero() && !f.IncludeRestricted && time.Now().Before(e.VisibleAt) {
+		return false
+	}
 	if f.EmergencyOnly && !e.Emergency {
 		return false
 	}
+	if f.Scoped && (e.Tgid == 0 || (len(f.Systems) > 0 && e.SystemID == 0)) {
+		return false
+	}
 	if len(f.Types) > 0 {
 		match := false
 		for _, t := range f.Types {
@@ -237,5 +354,17 @@ func matchesFilter(e api.SSEEvent, f api.EventFilter) bool {
 			return false
 		}
 	}
+	if f.Program != nil && !f.Program.Match(vars.Get()) {
+		return false
+	}
+	if len(f.Any) > 0 {
+		for _, alt := range f.Any {
+			alt.IncludeRestricted = f.IncludeRestricted
+			if matchFilter(e, alt, vars) {
+				return true
+			}
+		}
+		return false
+	}
 	return true
 }
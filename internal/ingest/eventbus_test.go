@@ -104,6 +104,53 @@ func TestEventBusPublishSubscribe(t *testing.T) {
 	})
 }
 
+// ── EventBus embargo ─────────────────────────────────────────────────
+
+func TestEventBusEmbargo(t *testing.T) {
+	eb := NewEventBus(64)
+	admin, cancelAdmin := eb.Subscribe(api.EventFilter{IncludeRestricted: true})
+	defer cancelAdmin()
+	public, cancelPublic := eb.Subscribe(api.EventFilter{})
+	defer cancelPublic()
+
+	start := time.Now()
+	eb.Publish(EventData{Type: "call_start", SystemID: 1, Tgid: 100, Embargo: 100 * time.Millisecond, Payload: "x"})
+
+	select {
+	case <-admin:
+	case <-time.After(time.Second):
+		t.Fatal("admin subscriber should get the event at once")
+	}
+	if got := eb.ReplaySince("", api.EventFilter{}); len(got) != 0 {
+		t.Errorf("replay for non-admin during embargo = %d events, want 0", len(got))
+	}
+	// Subscribed while the event is held back: still gets it once visible.
+	late, cancelLate := eb.Subscribe(api.EventFilter{Tgids: []int{100}})
+	defer cancelLate()
+
+	for _, ch := range []<-chan api.SSEEvent{public, late} {
+		select {
+		case e := <-ch:
+			if waited := time.Since(start); waited < 100*time.Millisecond {
+				t.Errorf("non-admin got the event after %v, before the embargo ended", waited)
+			}
+			if e.Tgid != 100 {
+				t.Errorf("Tgid = %d, want 100", e.Tgid)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("non-admin subscriber never got the embargoed event")
+		}
+	}
+	select {
+	case e := <-admin:
+		t.Errorf("admin got the embargoed event twice: %+v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if got := eb.ReplaySince("", api.EventFilter{}); len(got) != 1 {
+		t.Errorf("replay for non-admin after embargo = %d events, want 1", len(got))
+	}
+}
+
 // ── EventBus ReplaySince ─────────────────────────────────────────────
 
 func TestEventBusReplaySince(t *testing.T) {
@@ -182,6 +229,67 @@ func TestMatchesFilter(t *testing.T) {
 			want:   true,
 		},
 
+		// Restricted encryption policy
+		{
+			name:   "restricted_hidden_from_non_admin",
+			event:  api.SSEEvent{Type: "call_start", Restricted: true},
+			filter: api.EventFilter{},
+			want:   false,
+		},
+		{
+			name:   "restricted_shown_to_admin",
+			event:  api.SSEEvent{Type: "call_start", Restricted: true},
+			filter: api.EventFilter{IncludeRestricted: true},
+			want:   true,
+		},
+		{
+			name:  "restricted_shown_to_admin_through_profiles",
+			event: api.SSEEvent{Type: "call_start", Restricted: true},
+			filter: api.EventFilter{IncludeRestricted: true,
+				Any: []api.EventFilter{{Types: []string{"call_start"}}}},
+			want: true,
+		},
+
+		// Talkgroup embargo
+		{
+			name:   "embargoed_hidden_from_non_admin",
+			event:  api.SSEEvent{Type: "call_start", VisibleAt: time.Now().Add(time.Hour)},
+			filter: api.EventFilter{},
+			want:   false,
+		},
+		{
+			name:   "embargoed_shown_to_admin",
+			event:  api.SSEEvent{Type: "call_start", VisibleAt: time.Now().Add(time.Hour)},
+			filter: api.EventFilter{IncludeRestricted: true},
+			want:   true,
+		},
+		{
+			name:   "embargo_over",
+			event:  api.SSEEvent{Type: "call_start", VisibleAt: time.Now().Add(-time.Second)},
+			filter: api.EventFilter{},
+			want:   true,
+		},
+
+		// Share link scope: events must name the talkgroup (and system)
+		{
+			name:   "scoped_drops_event_without_tgid",
+			event:  api.SSEEvent{Type: "recorder_update", SystemID: 1},
+			filter: api.EventFilter{Systems: []int{1}, Tgids: []int{100}, Scoped: true},
+			want:   false,
+		},
+		{
+			name:   "scoped_drops_event_without_system",
+			event:  api.SSEEvent{Type: "call_start", Tgid: 100},
+			filter: api.EventFilter{Systems: []int{1}, Tgids: []int{100}, Scoped: true},
+			want:   false,
+		},
+		{
+			name:   "scoped_passes_event_in_scope",
+			event:  api.SSEEvent{Type: "call_start", SystemID: 1, Tgid: 100},
+			filter: api.EventFilter{Systems: []int{1}, Tgids: []int{100}, Scoped: true},
+			want:   true,
+		},
+
 		// Type matching
 		{
 			name:   "type_match",
@@ -303,10 +411,68 @@ func TestMatchesFilter(t *testing.T) {
 			filter: api.EventFilter{Types: []string{"call_start"}, Systems: []int{1}, Tgids: []int{100}},
 			want:   false,
 		},
+
+		// Any: OR of profile filters, AND-ed with the top level
+		{
+			name:  "any_second_matches",
+			event: api.SSEEvent{Type: "call_end", SystemID: 1, Tgid: 200},
+			filter: api.EventFilter{Any: []api.EventFilter{
+				{Tgids: []int{100}},
+				{Tgids: []int{200}, Types: []string{"call_end"}},
+			}},
+			want: true,
+		},
+		{
+			name:  "any_none_match",
+			event: api.SSEEvent{Type: "call_start", SystemID: 1, Tgid: 200},
+			filter: api.EventFilter{Any: []api.EventFilter{
+				{Tgids: []int{100}},
+				{Tgids: []int{200}, Types: []string{"call_end"}},
+			}},
+			want: false,
+		},
+		{
+			name:   "expr_payload_and_envelope",
+			event:  api.SSEEvent{Type: "transcription", SystemID: 1, Tgid: 4001, Data: []byte(`{"text":"shots fired","duration":22}`)},
+			filter: api.EventFilter{Expr: `event_type == 'transcription' && tgid in [4001, 4002] && duration > 15 && text.contains('shots')`},
+			want:   true,
+		},
+		{
+			name:   "expr_no_match",
+			event:  api.SSEEvent{Type: "transcription", SystemID: 1, Tgid: 4003, Data: []byte(`{"text":"shots fired"}`)},
+			filter: api.EventFilter{Expr: `tgid in [4001, 4002]`},
+			want:   false,
+		},
+		{
+			name:   "expr_error_does_not_match",
+			event:  api.SSEEvent{Type: "call_start", Tgid: 4001, Data: []byte(`{}`)},
+			filter: api.EventFilter{Expr: `duration > 15`},
+			want:   false,
+		},
+		{
+			name:  "expr_in_any",
+			event: api.SSEEvent{Type: "call_end", Tgid: 4001, Data: []byte(`{"duration":30}`)},
+			filter: api.EventFilter{Any: []api.EventFilter{
+				{Expr: `duration < 10`},
+				{Expr: `duration >= 10`},
+			}},
+			want: true,
+		},
+		{
+			name:  "any_top_level_still_applies",
+			event: api.SSEEvent{Type: "call_start", SystemID: 2, Tgid: 100},
+			filter: api.EventFilter{Systems: []int{1}, Any: []api.EventFilter{
+				{Tgids: []int{100}},
+			}},
+			want: false,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.CompileExpr(); err != nil {
+				t.Fatal(err)
+			}
 			got := matchesFilter(tt.event, tt.filter)
 			if got != tt.want {
 				t.Errorf("matchesFilter(%+v, %+v) = %v, want %v", tt.event, tt.filter, got, tt.want)
@@ -0,0 +1,38 @@
+package ingest
+
+import (
+	"context"
+	"time"
+)
+
+// externalCorrelateInterval is how often recent external events are
+// re-linked to calls.
+const externalCorrelateInterval = time.Minute
+
+// externalEventCorrelationLoop links external events to calls that were
+// ingested after the event was posted. POST /external-events links the calls
+// already stored; each pass here covers events whose rule window may still be
+// receiving calls.
+func (p *Pipeline) externalEventCorrelationLoop() {
+	log := p.log.With().Str("task", "external-event-correlation").Logger()
+
+	ticker := time.NewTicker(externalCorrelateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(p.ctx, 30*time.Second)
+			// Calls land a little after they end, so look back past the window.
+			n, err := p.db.CorrelateExternalEvents(ctx, time.Now().Add(-10*time.Minute), 0)
+			cancel()
+			if err != nil {
+				log.Warn().Err(err).Msg("external event correlation failed")
+			} else if n > 0 {
+				log.Debug().Int64("linked", n).Msg("external events linked to calls")
+			}
+		}
+	}
+}
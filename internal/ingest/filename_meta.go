@@ -0,0 +1,186 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/audio"
+)
+
+// Filename metadata: when audio arrives with no metadata JSON (a bare file
+// upload, or WATCH_AUDIO_ONLY imports), the talkgroup, start time and
+// optionally system, frequency and unit are read from the path using
+// FILENAME_PATTERNS. Each pattern is matched against the end of the path
+// without its extension, so patterns may include parent directories.
+//
+// Placeholders:
+//
+//	{tgid}      talkgroup (required)
+//	{start}     unix start time in seconds
+//	{datetime}  YYYYMMDD_HHMMSS or YYYYMMDDHHMMSS, server local time
+//	{freq}      frequency in Hz, e.g. 859262500.0
+//	{system}    system short name (one path segment)
+//	{unit}      source unit ID
+//	{#}         any digits, ignored
+//	{*}         any text within a path segment, ignored
+//
+// Either {start} or {datetime} is required.
+
+// DefaultFilenamePatterns matches trunk-recorder's output: files named
+// {tgid}-{start}_{freq}-call_{n} under {short_name}/YYYY/M/D directories. It
+// is the FILENAME_PATTERNS default (internal/config).
+const DefaultFilenamePatterns = "{system}/{#}/{#}/{#}/{tgid}-{start}_{freq}-call_{#}," +
+	"{tgid}-{start}_{freq}-call_{#},{tgid}-{start}_{freq},{tgid}-{start}"
+
+// FilenamePattern is a compiled FILENAME_PATTERNS entry.
+type FilenamePattern struct {
+	spec string
+	re   *regexp.Regexp
+}
+
+func (fp *FilenamePattern) String() string { return fp.spec }
+
+var filenamePlaceholders = map[string]string{
+	"tgid":     `(?P<tgid>\d+)`,
+	"start":    `(?P<start>\d{9,10})`,
+	"datetime": `(?P<datetime>\d{8}[_-]?\d{6})`,
+	"freq":     `(?P<freq>\d+(?:\.\d+)?)`,
+	"system":   `(?P<system>[^/]+?)`,
+	"unit":     `(?P<unit>\d+)`,
+	"#":        `\d+`,
+	"*":        `[^/]*?`,
+}
+
+var placeholderRe = regexp.MustCompile(`\{([a-z#*]+)\}`)
+
+// CompileFilenamePattern compiles one pattern.
+func CompileFilenamePattern(spec string) (*FilenamePattern, error) {
+	var expr strings.Builder
+	seen := map[string]bool{}
+	last := 0
+	for _, m := range placeholderRe.FindAllStringSubmatchIndex(spec, -1) {
+		name := spec[m[2]:m[3]]
+		group, ok := filenamePlaceholders[name]
+		if !ok {
+			return nil, fmt.Errorf("filename pattern %q: unknown placeholder {%s}", spec, name)
+		}
+		if seen[name] && name != "#" && name != "*" {
+			return nil, fmt.Errorf("filename pattern %q: {%s} appears twice", spec, name)
+		}
+		seen[name] = true
+		expr.WriteString(regexp.QuoteMeta(spec[last:m[0]]))
+		expr.WriteString(group)
+		last = m[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(spec[last:]))
+
+	if !seen["tgid"] {
+		return nil, fmt.Errorf("filename pattern %q: {tgid} is required", spec)
+	}
+	if seen["start"] == seen["datetime"] {
+		return nil, fmt.Errorf("filename pattern %q: exactly one of {start} or {datetime} is required", spec)
+	}
+	re, err := regexp.Compile(`(?:^|/)` + expr.String() + `$`)
+	if err != nil {
+		return nil, fmt.Errorf("filename pattern %q: %w", spec, err)
+	}
+	return &FilenamePattern{spec: spec, re: re}, nil
+}
+
+// ParseFilenamePatterns compiles a comma-separated FILENAME_PATTERNS value.
+// Patterns are tried in order.
+func ParseFilenamePatterns(s string) ([]*FilenamePattern, error) {
+	var patterns []*FilenamePattern
+	for _, spec := range strings.Split(s, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		fp, err := CompileFilenamePattern(spec)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, fp)
+	}
+	return patterns, nil
+}
+
+// MetadataFromFilename builds minimal call metadata from an audio file path
+// using the first pattern that matches. path may be a bare filename or a
+// slash- or OS-separated path. Returns false if no pattern matches.
+func MetadataFromFilename(path string, patterns []*FilenamePattern) (*AudioMetadata, bool) {
+	path = filepath.ToSlash(path)
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+
+	for _, fp := range patterns {
+		m := fp.re.FindStringSubmatch(base)
+		if m == nil {
+			continue
+		}
+		meta := &AudioMetadata{AudioType: strings.ToLower(strings.TrimPrefix(ext, "."))}
+		ok := true
+		for i, name := range fp.re.SubexpNames() {
+			v := m[i]
+			switch name {
+			case "tgid":
+				meta.Talkgroup, _ = strconv.Atoi(v)
+				ok = ok && meta.Talkgroup > 0
+			case "start":
+				meta.StartTime, _ = strconv.ParseInt(v, 10, 64)
+				ok = ok && meta.StartTime > 0
+			case "datetime":
+				digits := strings.NewReplacer("_", "", "-", "").Replace(v)
+				t, err := time.ParseInLocation("20060102150405", digits, time.Local)
+				meta.StartTime = t.Unix()
+				ok = ok && err == nil
+			case "freq":
+				meta.Freq, _ = strconv.ParseFloat(v, 64)
+			case "system":
+				meta.ShortName = v
+			case "unit":
+				if unit, err := strconv.Atoi(v); err == nil && unit > 0 {
+					meta.SrcList = []SrcItem{{Src: unit}}
+				}
+			}
+		}
+		if !ok {
+			continue
+		}
+		// {unit} may precede the start time in the pattern.
+		for i := range meta.SrcList {
+			meta.SrcList[i].Time = meta.StartTime
+		}
+		return meta, true
+	}
+	return nil, false
+}
+
+// metadataFromAudio derives metadata for an audio file with no metadata JSON.
+// The call length is measured from data, or by probing path when data is nil
+// or its format can't be parsed, so the call record has a duration.
+func (p *Pipeline) metadataFromAudio(ctx context.Context, name string, data []byte, path string) (*AudioMetadata, error) {
+	meta, ok := MetadataFromFilename(name, p.filenamePatterns)
+	if !ok {
+		return nil, fmt.Errorf("filename %q does not match FILENAME_PATTERNS", filepath.Base(name))
+	}
+
+	var length float64
+	err := audio.ErrUnsupportedFormat
+	if data != nil {
+		length, err = audio.Duration(data, meta.AudioType)
+	}
+	if err != nil && path != "" {
+		length, err = audio.ProbeFile(ctx, path)
+	}
+	if err == nil && length > 0 {
+		meta.CallLength = int(length + 0.5)
+		meta.StopTime = meta.StartTime + int64(meta.CallLength)
+	}
+	return meta, nil
+}
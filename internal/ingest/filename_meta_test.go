@@ -0,0 +1,75 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetadataFromFilename(t *testing.T) {
+	defaults, err := ParseFilenamePatterns(DefaultFilenamePatterns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom, err := ParseFilenamePatterns("{datetime}_TG{tgid}_U{unit}, {system}/{*}_{tgid}_{start}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		patterns []*FilenamePattern
+		path     string
+		ok       bool
+		want     AudioMetadata
+		unit     int
+	}{
+		{"trunk-recorder tree", defaults, "/data/butco/2026/3/1/9044-1771332008_859262500.0-call_28344.m4a", true,
+			AudioMetadata{Talkgroup: 9044, StartTime: 1771332008, Freq: 859262500, ShortName: "butco", AudioType: "m4a"}, 0},
+		{"bare trunk-recorder name", defaults, "9044-1771332008_859262500.0-call_28344.wav", true,
+			AudioMetadata{Talkgroup: 9044, StartTime: 1771332008, Freq: 859262500, AudioType: "wav"}, 0},
+		{"flat directory", defaults, "/home/me/scanner/calls/9044-1771332008_859262500.0-call_1.wav", true,
+			AudioMetadata{Talkgroup: 9044, StartTime: 1771332008, Freq: 859262500, AudioType: "wav"}, 0},
+		{"no freq", defaults, "9044-1771332008.mp3", true,
+			AudioMetadata{Talkgroup: 9044, StartTime: 1771332008, AudioType: "mp3"}, 0},
+		{"no match", defaults, "recording.wav", false, AudioMetadata{}, 0},
+		{"datetime and unit", custom, "20260301_120000_TG9044_U1234.wav", true,
+			AudioMetadata{Talkgroup: 9044, StartTime: time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local).Unix(), AudioType: "wav"}, 1234},
+		{"system from directory", custom, "metro/call_9044_1771332008.wav", true,
+			AudioMetadata{Talkgroup: 9044, StartTime: 1771332008, ShortName: "metro", AudioType: "wav"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, ok := MetadataFromFilename(tt.path, tt.patterns)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if meta.Talkgroup != tt.want.Talkgroup || meta.StartTime != tt.want.StartTime || meta.Freq != tt.want.Freq ||
+				meta.ShortName != tt.want.ShortName || meta.AudioType != tt.want.AudioType {
+				t.Errorf("got tgid=%d start=%d freq=%v system=%q type=%q", meta.Talkgroup, meta.StartTime, meta.Freq, meta.ShortName, meta.AudioType)
+			}
+			switch {
+			case tt.unit == 0 && len(meta.SrcList) != 0:
+				t.Errorf("SrcList = %+v, want none", meta.SrcList)
+			case tt.unit != 0 && (len(meta.SrcList) != 1 || meta.SrcList[0].Src != tt.unit || meta.SrcList[0].Time != meta.StartTime):
+				t.Errorf("SrcList = %+v, want unit %d", meta.SrcList, tt.unit)
+			}
+		})
+	}
+}
+
+func TestParseFilenamePatterns_Invalid(t *testing.T) {
+	for _, spec := range []string{
+		"{start}",                   // no tgid
+		"{tgid}",                    // no start time
+		"{tgid}-{start}-{datetime}", // both start forms
+		"{tgid}-{start}-{tgid}",     // repeated
+		"{tgid}-{start}-{bogus}",
+	} {
+		if _, err := ParseFilenamePatterns(spec); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
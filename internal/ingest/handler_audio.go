@@ -4,14 +4,19 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/snarg/tr-engine/internal/api"
+	"github.com/snarg/tr-engine/internal/audio"
 	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/metrics"
 	"github.com/snarg/tr-engine/internal/storage"
+	"github.com/snarg/tr-engine/pkg/events"
 )
 
 func (p *Pipeline) handleAudio(payload []byte) error {
@@ -23,13 +28,27 @@ func (p *Pipeline) handleAudio(payload []byte) error {
 	meta := &msg.Call.Metadata
 	startTime := time.Unix(meta.StartTime, 0)
 
+	// Repeats of a message handled within AUDIO_DEDUP_WINDOW (TR republishes
+	// after a broker reconnect) are acknowledged without decoding the audio.
+	dedupKey := audioDedupKeyFor(msg.InstanceID, meta)
+	if !p.audioDedup.claim(dedupKey, time.Now()) {
+		metrics.AudioDuplicatesSuppressedTotal.WithLabelValues(msg.InstanceID).Inc()
+		p.log.Debug().Str("instance_id", msg.InstanceID).Str("filename", meta.Filename).
+			Int("tgid", meta.Talkgroup).Msg("duplicate audio message skipped")
+		return nil
+	}
+
 	ctx, cancel := context.WithTimeout(p.ctx, 10*time.Second)
 	defer cancel()
 
 	identity, err := p.identity.Resolve(ctx, msg.InstanceID, meta.ShortName)
 	if err != nil {
+		p.audioDedup.forget(dedupKey)
 		return fmt.Errorf("resolve identity: %w", err)
 	}
+	if p.suppressEncrypted(identity.SystemID, meta.Talkgroup, meta.Encrypted != 0, "audio") {
+		return ErrEncryptedSuppressed
+	}
 
 	// Find the matching call, or create one from audio metadata
 	callID, callStartTime, err := p.db.FindCallForAudio(ctx, identity.SystemID, meta.Talkgroup, startTime)
@@ -38,6 +57,7 @@ func (p *Pipeline) handleAudio(payload []byte) error {
 		// call_end will find this record later via FindCallForAudio and update it.
 		callID, callStartTime, _, err = p.createCallFromAudio(ctx, identity, meta, startTime)
 		if err != nil {
+			p.audioDedup.forget(dedupKey)
 			p.log.Error().Err(err).
 				Int("tgid", meta.Talkgroup).
 				Str("sys_name", meta.ShortName).
@@ -49,6 +69,9 @@ func (p *Pipeline) handleAudio(payload []byte) error {
 	// Decode and save audio file (skip when TR_AUDIO_DIR is set — files served from TR's filesystem)
 	var audioPath string
 	var audioSize int
+	var audioType, receivedType string
+	var decoded, held []byte
+	var unusable bool
 
 	if p.trAudioDir == "" {
 		audioData := msg.Call.AudioM4ABase64
@@ -58,22 +81,35 @@ func (p *Pipeline) handleAudio(payload []byte) error {
 			inferredType = "wav"
 		}
 
-		audioType := meta.AudioType
+		audioType = meta.AudioType
 		if audioType == "" {
 			audioType = inferredType
 		}
 
+		if audioData != "" && p.audioDropped(identity.SystemID, meta.Talkgroup, startTime) {
+			metrics.AudioStoragePolicyTotal.WithLabelValues("dropped").Inc()
+			audioData = ""
+		}
+
 		if audioData != "" {
-			decoded, decErr := base64.StdEncoding.DecodeString(audioData)
+			var decErr error
+			decoded, decErr = base64.StdEncoding.DecodeString(audioData)
 			if decErr != nil {
 				p.log.Warn().Err(decErr).Msg("failed to decode audio base64")
+			} else if p.transcriptOnly(identity.SystemID, meta.Talkgroup, startTime) {
+				// Held in memory for transcription only; nothing is written
+				metrics.AudioStoragePolicyTotal.WithLabelValues("transcript_only").Inc()
+				held = decoded
 			} else {
+				var storedName string
+				receivedType = audioType
+				decoded, audioType, storedName = p.applyStoragePolicy(ctx, identity.SystemID, meta.Talkgroup, startTime, decoded, audioType, meta.Filename)
 				audioSize = len(decoded)
-				filename := buildAudioFilename(meta.Filename, audioType, startTime)
+				filename := buildAudioFilename(storedName, audioType, startTime)
 				audioKey := buildAudioRelPath(meta.ShortName, startTime, filename)
 				contentType := audioContentType(audioType)
 
-				if err := p.saveAudio(ctx, audioKey, decoded, contentType); err != nil {
+				if err := p.saveAudio(ctx, audioKey, decoded, contentType, startTime, meta.Emergency != 0); err != nil {
 					p.log.Error().Err(err).Msg("failed to save audio file")
 				} else {
 					audioPath = audioKey
@@ -82,21 +118,35 @@ func (p *Pipeline) handleAudio(payload []byte) error {
 		}
 
 		if callID > 0 && audioPath != "" {
-			if err := p.db.UpdateCallAudio(ctx, callID, callStartTime, audioPath, audioSize); err != nil {
+			if err := p.registerAudio(ctx, callID, callStartTime, audioPath, receivedType, audioType, audioSize, "mqtt"); err != nil {
 				p.log.Warn().Err(err).Int64("call_id", callID).Msg("failed to update call audio")
+			} else {
+				p.verifyAudioDuration(ctx, callID, callStartTime, decoded, audioType, p.store.LocalPath(audioPath))
+				// Only a local-only store can drop its sole copy
+				unusable = p.checkAudioUsable(ctx, callID, callStartTime, decoded, audioType,
+					p.store.LocalPath(audioPath), p.store.Type() == "local")
 			}
 		}
+	} else if callID > 0 && meta.Filename != "" {
+		// TR_AUDIO_DIR mode: measure the file where trunk-recorder left it
+		if path := audio.ResolveFile(p.audioDir, p.trAudioDir, "", meta.Filename); path != "" {
+			p.verifyAudioDuration(ctx, callID, callStartTime, nil, "", path)
+			unusable = p.checkAudioUsable(ctx, callID, callStartTime, nil, "", path, true)
+		}
 	}
 
 	// Build srcList/freqList JSON and update call
 	if callID > 0 {
 		p.processSrcFreqData(ctx, callID, callStartTime, meta)
+		p.checkSplitCall(ctx, identity.SystemID, meta.Talkgroup, initiatingUnit(meta.SrcList), callID, callStartTime)
 	}
 
 	// Enqueue for transcription if audio was saved and call is not encrypted
-	if callID > 0 && meta.Encrypted == 0 {
+	if callID > 0 && meta.Encrypted == 0 && !unusable {
 		if meta.Transcript != "" {
 			p.insertSourceTranscription(callID, callStartTime, identity.SystemID, meta.Talkgroup, meta)
+		} else if held != nil {
+			p.enqueueHeldTranscription(callID, callStartTime, identity.SystemID, held, audioType, meta)
 		} else {
 			p.enqueueTranscription(callID, callStartTime, identity.SystemID, audioPath, meta)
 		}
@@ -172,12 +222,13 @@ func (p *Pipeline) createCallFromAudio(ctx context.Context, identity *ResolvedId
 	if err != nil {
 		return 0, time.Time{}, "", fmt.Errorf("insert call from audio: %w", err)
 	}
+	p.invalidate(api.CacheTagTalkgroup(meta.Talkgroup))
 
 	// Upsert talkgroup + enrich from directory — capture effective tag
 	effectiveTgTag := meta.TalkgroupTag
 	if meta.Talkgroup > 0 {
 		effectiveTgTag = p.upsertAndEnrichTalkgroup(ctx, identity.SystemID, meta.Talkgroup,
-			meta.TalkgroupTag, meta.TalkgroupGroupTag, meta.TalkgroupGroup, meta.TalkgroupDesc, startTime)
+			meta.TalkgroupTag, meta.TalkgroupGroupTag, meta.TalkgroupGroup, meta.TalkgroupDesc, startTime, "audio")
 	}
 
 	// Create call group
@@ -342,10 +393,12 @@ func (p *Pipeline) processSrcFreqData(ctx context.Context, callID int64, callSta
 	}
 }
 
-// processWatchedFile handles a JSON metadata file from the file watcher.
+// processWatchedFile handles a JSON metadata file from the file watcher, or
+// with WATCH_AUDIO_ONLY an audio file whose metadata came from its name.
 // It creates a call record, processes srcList/freqList, sets the audio path,
-// and publishes a call_end SSE event.
-func (p *Pipeline) processWatchedFile(instanceID string, meta *AudioMetadata, jsonPath string) error {
+// and publishes a call_end SSE event. A file that is passed over without an
+// error returns the reason it was skipped.
+func (p *Pipeline) processWatchedFile(instanceID string, meta *AudioMetadata, jsonPath string) (skipped string, err error) {
 	startTime := time.Unix(meta.StartTime, 0)
 
 	ctx, cancel := context.WithTimeout(p.ctx, 60*time.Second)
@@ -354,7 +407,11 @@ func (p *Pipeline) processWatchedFile(instanceID string, meta *AudioMetadata, js
 	// Resolve identity (auto-creates system/site if needed)
 	identity, err := p.identity.Resolve(ctx, instanceID, meta.ShortName)
 	if err != nil {
-		return fmt.Errorf("resolve identity: %w", err)
+		return "", fmt.Errorf("resolve identity: %w", err)
+	}
+	if p.suppressEncrypted(identity.SystemID, meta.Talkgroup, meta.Encrypted != 0, "audio") {
+		p.log.Debug().Str("path", jsonPath).Msg("watched file is suppressed encrypted traffic, skipping")
+		return "encrypted_suppressed", nil
 	}
 
 	// Check for existing call (dedup against MQTT ingest or prior backfill)
@@ -363,7 +420,7 @@ func (p *Pipeline) processWatchedFile(instanceID string, meta *AudioMetadata, js
 			Int64("call_id", existingID).
 			Str("path", jsonPath).
 			Msg("watched file already in DB, skipping")
-		return nil
+		return database.BackfillAlreadyIngested, nil
 	}
 
 	// Create call from audio metadata
@@ -374,33 +431,53 @@ func (p *Pipeline) processWatchedFile(instanceID string, meta *AudioMetadata, js
 		callID, callStartTime, effectiveTgTag, err = p.createCallFromAudio(ctx, identity, meta, startTime)
 	}
 	if err != nil {
-		return fmt.Errorf("create call from watched file: %w", err)
+		return "", fmt.Errorf("create call from watched file: %w", err)
 	}
 
 	// Set call_filename to the companion audio file next to the .json.
-	// Try common extensions in preference order.
-	base := strings.TrimSuffix(jsonPath, ".json")
+	// Try common extensions in preference order. Audio-only imports pass
+	// the audio file itself.
 	var audioPath string
-	for _, ext := range []string{".m4a", ".wav", ".mp3"} {
-		if _, statErr := os.Stat(base + ext); statErr == nil {
-			audioPath = base + ext
-			break
+	var unusable bool
+	if !strings.HasSuffix(strings.ToLower(jsonPath), ".json") {
+		audioPath = jsonPath
+	} else {
+		base := strings.TrimSuffix(jsonPath, ".json")
+		for _, ext := range watchAudioExts {
+			if _, statErr := os.Stat(base + ext); statErr == nil {
+				audioPath = base + ext
+				break
+			}
 		}
 	}
+	if audioPath != "" && p.audioDropped(identity.SystemID, meta.Talkgroup, startTime) {
+		// Watched files stay where trunk-recorder wrote them; a metadata-only
+		// system or talkgroup just isn't linked to its file.
+		metrics.AudioStoragePolicyTotal.WithLabelValues("dropped").Inc()
+		audioPath = ""
+	} else if audioPath != "" && p.transcriptOnly(identity.SystemID, meta.Talkgroup, startTime) {
+		// Transcribed where it lies, but not linked to the call
+		metrics.AudioStoragePolicyTotal.WithLabelValues("transcript_only").Inc()
+		meta.Filename = audioPath
+		audioPath = ""
+	}
 	if audioPath != "" {
 		if err := p.db.UpdateCallFilename(ctx, callID, callStartTime, audioPath); err != nil {
 			p.log.Warn().Err(err).Int64("call_id", callID).Msg("failed to set call_filename from watched file")
 		}
 		meta.Filename = audioPath // pass to transcription job
+		p.verifyAudioDuration(ctx, callID, callStartTime, nil, "", audioPath)
+		unusable = p.checkAudioUsable(ctx, callID, callStartTime, nil, "", audioPath, true)
 	}
 
 	// Process srcList/freqList
 	p.processSrcFreqData(ctx, callID, callStartTime, meta)
+	p.checkSplitCall(ctx, identity.SystemID, meta.Talkgroup, initiatingUnit(meta.SrcList), callID, callStartTime)
 
 	// Upsert units from srcList
 	for _, s := range meta.SrcList {
 		if s.Src > 0 {
-			_, _ = p.db.UpsertUnit(ctx, identity.SystemID, s.Src,
+			_, _ = p.upsertUnit(ctx, identity.SystemID, s.Src,
 				s.Tag, "file_watch", startTime, meta.Talkgroup,
 			)
 		}
@@ -417,24 +494,24 @@ func (p *Pipeline) processWatchedFile(instanceID string, meta *AudioMetadata, js
 		SiteID:    identity.SiteID,
 		Tgid:      meta.Talkgroup,
 		Emergency: meta.Emergency != 0,
-		Payload: map[string]any{
-			"call_id":       callID,
-			"system_id":     identity.SystemID,
-			"tgid":          meta.Talkgroup,
-			"tg_alpha_tag":  effectiveTgTag,
-			"freq":          int64(meta.Freq),
-			"start_time":    startTime,
-			"stop_time":     stopTime,
-			"duration":      float64(meta.CallLength),
-			"emergency":     meta.Emergency != 0,
-			"encrypted":     meta.Encrypted != 0,
-			"call_filename": audioPath,
-			"source":        "file_watch",
+		Payload: &events.CallEnd{
+			CallID:       callID,
+			SystemID:     identity.SystemID,
+			Tgid:         meta.Talkgroup,
+			TgAlphaTag:   effectiveTgTag,
+			Freq:         int64(meta.Freq),
+			StartTime:    startTime,
+			StopTime:     stopTime,
+			Duration:     float64(meta.CallLength),
+			Emergency:    meta.Emergency != 0,
+			Encrypted:    meta.Encrypted != 0,
+			CallFilename: audioPath,
+			Source:       "file_watch",
 		},
 	})
 
 	// Enqueue for transcription if not encrypted
-	if meta.Encrypted == 0 {
+	if meta.Encrypted == 0 && !unusable {
 		if meta.Transcript != "" {
 			p.insertSourceTranscription(callID, callStartTime, identity.SystemID, meta.Talkgroup, meta)
 		} else {
@@ -449,7 +526,65 @@ func (p *Pipeline) processWatchedFile(instanceID string, meta *AudioMetadata, js
 		Str("path", jsonPath).
 		Msg("call created from watched file")
 
-	return nil
+	return "", nil
+}
+
+// verifyAudioDuration measures a call's saved audio and records it alongside
+// TR's reported call_length, flagging recordings that were cut short (or run
+// long) beyond the configured tolerance. data is the audio in memory when
+// available; path is probed on disk for formats that can't be parsed from
+// memory, or when there is no in-memory copy. With AUDIO_DURATION_CORRECT the
+// call's duration and stop_time are then replaced by the measured length if
+// they are off by more than that. Best-effort: failures are logged.
+func (p *Pipeline) verifyAudioDuration(ctx context.Context, callID int64, startTime time.Time, data []byte, format, path string) {
+	if p.durationTolerance <= 0 {
+		return
+	}
+
+	var measured float64
+	err := audio.ErrUnsupportedFormat
+	if data != nil {
+		measured, err = audio.Duration(data, format)
+	}
+	if errors.Is(err, audio.ErrUnsupportedFormat) && path != "" {
+		measured, err = audio.ProbeFile(ctx, path)
+	}
+	if err != nil {
+		metrics.AudioDurationChecksTotal.WithLabelValues("error").Inc()
+		p.log.Debug().Err(err).Int64("call_id", callID).Msg("failed to measure audio duration")
+		return
+	}
+
+	mismatch, err := p.db.SetCallAudioDuration(ctx, callID, startTime, measured, p.durationTolerance.Seconds())
+	if err != nil {
+		p.log.Warn().Err(err).Int64("call_id", callID).Msg("failed to store audio duration")
+		return
+	}
+	if mismatch {
+		metrics.AudioDurationChecksTotal.WithLabelValues("mismatch").Inc()
+		p.log.Debug().
+			Int64("call_id", callID).
+			Float64("audio_duration", measured).
+			Msg("audio duration does not match reported call length")
+	} else {
+		metrics.AudioDurationChecksTotal.WithLabelValues("ok").Inc()
+	}
+
+	if p.durationCorrect > 0 {
+		res, err := p.db.CorrectCallDurations(ctx, database.DurationCorrectionFilter{
+			Call:      &database.CallRef{CallID: callID, StartTime: startTime},
+			Threshold: p.durationCorrect.Seconds(),
+		})
+		if err != nil {
+			p.log.Warn().Err(err).Int64("call_id", callID).Msg("failed to correct call duration")
+		} else if res.Calls > 0 {
+			metrics.AudioDurationChecksTotal.WithLabelValues("corrected").Inc()
+			p.log.Debug().
+				Int64("call_id", callID).
+				Float64("audio_duration", measured).
+				Msg("call duration corrected from audio")
+		}
+	}
 }
 
 // buildAudioFilename returns the filename to use for saving audio.
@@ -478,15 +613,15 @@ func buildAudioRelPath(sysName string, startTime time.Time, filename string) str
 
 // saveAudio writes audio data through the storage abstraction.
 // For tiered stores in async mode: writes to local cache synchronously,
-// then enqueues S3 upload in the background.
-func (p *Pipeline) saveAudio(ctx context.Context, key string, data []byte, contentType string) error {
+// then enqueues S3 upload in the background, emergency calls first.
+func (p *Pipeline) saveAudio(ctx context.Context, key string, data []byte, contentType string, callTime time.Time, emergency bool) error {
 	if p.uploader != nil {
 		// Async mode: save locally first, then background S3 upload
 		if tiered, ok := p.store.(*storage.TieredStore); ok {
 			if err := tiered.SaveLocal(ctx, key, data, contentType); err != nil {
 				return err
 			}
-			p.uploader.Enqueue(key, data, contentType)
+			p.uploader.Enqueue(ctx, key, contentType, callTime, emergency)
 			return nil
 		}
 	}
@@ -503,7 +638,7 @@ func audioContentType(audioType string) string {
 		return "audio/mpeg"
 	case "wav":
 		return "audio/wav"
-	case "ogg":
+	case "ogg", "opus":
 		return "audio/ogg"
 	default:
 		return "application/octet-stream"
@@ -7,14 +7,17 @@ import (
 	"strings"
 	"time"
 
+	"github.com/snarg/tr-engine/internal/api"
 	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/pkg/events"
 )
 
 // upsertAndEnrichTalkgroup upserts a talkgroup, enriches it from the directory,
 // and returns the effective alpha tag (respects manual > csv > mqtt priority).
-func (p *Pipeline) upsertAndEnrichTalkgroup(ctx context.Context, systemID, tgid int, alphaTag, tag, group, description string, eventTime time.Time) string {
+// source names the message for a first-heard discovery event.
+func (p *Pipeline) upsertAndEnrichTalkgroup(ctx context.Context, systemID, tgid int, alphaTag, tag, group, description string, eventTime time.Time, source string) string {
 	effectiveTag := alphaTag
-	if dbTag, err := p.db.UpsertTalkgroup(ctx, systemID, tgid, alphaTag, tag, group, description, eventTime); err != nil {
+	if dbTag, err := p.upsertTalkgroup(ctx, systemID, tgid, alphaTag, tag, group, description, eventTime, source); err != nil {
 		p.log.Warn().Err(err).Int("tgid", tgid).Msg("failed to upsert talkgroup")
 	} else if dbTag != "" {
 		effectiveTag = dbTag
@@ -44,18 +47,34 @@ func (p *Pipeline) handleCallStart(payload []byte) error {
 	if err != nil {
 		return fmt.Errorf("resolve identity: %w", err)
 	}
+	if p.suppressEncrypted(identity.SystemID, call.Talkgroup, call.Encrypted, "call") {
+		return ErrEncryptedSuppressed
+	}
+	if call.Emergency {
+		p.publishEmergency(identity.SiteID, call.Encrypted, events.Emergency{
+			SystemID:     identity.SystemID,
+			Tgid:         call.Talkgroup,
+			TgAlphaTag:   call.TalkgroupAlphaTag,
+			Unit:         call.Unit,
+			UnitAlphaTag: call.UnitAlphaTag,
+			Freq:         int64(call.Freq),
+			Source:       "call_start",
+			TrCallID:     call.ID,
+			Time:         startTime,
+		})
+	}
 
 	// Upsert talkgroup + enrich from directory — capture effective tag
 	effectiveTgTag := call.TalkgroupAlphaTag
 	if call.Talkgroup > 0 {
 		effectiveTgTag = p.upsertAndEnrichTalkgroup(ctx, identity.SystemID, call.Talkgroup,
-			call.TalkgroupAlphaTag, call.TalkgroupTag, call.TalkgroupGroup, call.TalkgroupDescription, startTime)
+			call.TalkgroupAlphaTag, call.TalkgroupTag, call.TalkgroupGroup, call.TalkgroupDescription, startTime, "call_start")
 	}
 
 	// Upsert unit — capture effective tag from DB
 	effectiveUnitTag := call.UnitAlphaTag
 	if call.Unit > 0 {
-		if dbTag, err := p.db.UpsertUnit(ctx, identity.SystemID, call.Unit,
+		if dbTag, err := p.upsertUnit(ctx, identity.SystemID, call.Unit,
 			call.UnitAlphaTag, "call_start", startTime, call.Talkgroup,
 		); err != nil {
 			p.log.Warn().Err(err).Int("unit", call.Unit).Msg("failed to upsert unit")
@@ -155,6 +174,7 @@ func (p *Pipeline) handleCallStart(payload []byte) error {
 		if insertErr != nil {
 			return fmt.Errorf("insert call: %w", insertErr)
 		}
+		p.invalidate(api.CacheTagTalkgroup(call.Talkgroup))
 	}
 
 	p.activeCalls.Set(call.ID, activeCallEntry{
@@ -215,25 +235,25 @@ func (p *Pipeline) handleCallStart(payload []byte) error {
 		Tgid:      call.Talkgroup,
 		UnitID:    call.Unit,
 		Emergency: call.Emergency,
-		Payload: map[string]any{
-			"call_id":         callID,
-			"system_id":       identity.SystemID,
-			"tgid":            call.Talkgroup,
-			"tg_alpha_tag":    effectiveTgTag,
-			"tg_tag":          call.TalkgroupTag,
-			"tg_group":        call.TalkgroupGroup,
-			"tg_description":  call.TalkgroupDescription,
-			"unit":            call.Unit,
-			"unit_alpha_tag":  effectiveUnitTag,
-			"freq":            freq,
-			"start_time":      startTime,
-			"emergency":       call.Emergency,
-			"encrypted":       call.Encrypted,
-			"analog":          call.Analog,
-			"conventional":    call.Conventional,
-			"phase2_tdma":     call.Phase2TDMA,
-			"audio_type":      call.AudioType,
-			"incident_data":   call.IncidentData,
+		Payload: &events.CallStart{
+			CallID:        callID,
+			SystemID:      identity.SystemID,
+			Tgid:          call.Talkgroup,
+			TgAlphaTag:    effectiveTgTag,
+			TgTag:         call.TalkgroupTag,
+			TgGroup:       call.TalkgroupGroup,
+			TgDescription: call.TalkgroupDescription,
+			Unit:          call.Unit,
+			UnitAlphaTag:  effectiveUnitTag,
+			Freq:          freq,
+			StartTime:     startTime,
+			Emergency:     call.Emergency,
+			Encrypted:     call.Encrypted,
+			Analog:        call.Analog,
+			Conventional:  call.Conventional,
+			Phase2TDMA:    call.Phase2TDMA,
+			AudioType:     call.AudioType,
+			IncidentData:  call.IncidentData,
 		},
 	})
 
@@ -318,10 +338,10 @@ func (p *Pipeline) handleCallEnd(payload []byte) error {
 	effectiveUnitTag := call.UnitAlphaTag
 	if idErr == nil && call.Talkgroup > 0 {
 		effectiveTgTag = p.upsertAndEnrichTalkgroup(ctx, identity.SystemID, call.Talkgroup,
-			call.TalkgroupAlphaTag, call.TalkgroupTag, call.TalkgroupGroup, call.TalkgroupDescription, startTime)
+			call.TalkgroupAlphaTag, call.TalkgroupTag, call.TalkgroupGroup, call.TalkgroupDescription, startTime, "call_end")
 	}
 	if idErr == nil && call.Unit > 0 {
-		if dbTag, upsertErr := p.db.UpsertUnit(ctx, identity.SystemID, call.Unit,
+		if dbTag, upsertErr := p.upsertUnit(ctx, identity.SystemID, call.Unit,
 			call.UnitAlphaTag, "call_end", startTime, call.Talkgroup,
 		); upsertErr == nil && dbTag != "" {
 			effectiveUnitTag = dbTag
@@ -334,6 +354,11 @@ func (p *Pipeline) handleCallEnd(payload []byte) error {
 		Float64("duration", call.Length).
 		Msg("call ended")
 
+	if idErr == nil && call.Encrypted {
+		// No audio will bring a srcList; call_end has the initiating unit
+		p.checkSplitCall(ctx, identity.SystemID, call.Talkgroup, call.Unit, entry.CallID, entry.StartTime)
+	}
+
 	if idErr == nil {
 		p.PublishEvent(EventData{
 			Type:      "call_end",
@@ -341,21 +366,21 @@ func (p *Pipeline) handleCallEnd(payload []byte) error {
 			SiteID:    identity.SiteID,
 			Tgid:      call.Talkgroup,
 			Emergency: call.Emergency,
-			Payload: map[string]any{
-				"call_id":        entry.CallID,
-				"system_id":      identity.SystemID,
-				"tgid":           call.Talkgroup,
-				"tg_alpha_tag":   effectiveTgTag,
-				"unit":           call.Unit,
-				"unit_alpha_tag": effectiveUnitTag,
-				"freq":           int64(call.Freq),
-				"start_time":     startTime,
-				"stop_time":      stopTime,
-				"duration":       call.Length,
-				"emergency":      call.Emergency,
-				"encrypted":      call.Encrypted,
-				"call_filename":  call.CallFilename,
-				"incident_data":  call.IncidentData,
+			Payload: &events.CallEnd{
+				CallID:       entry.CallID,
+				SystemID:     identity.SystemID,
+				Tgid:         call.Talkgroup,
+				TgAlphaTag:   effectiveTgTag,
+				Unit:         call.Unit,
+				UnitAlphaTag: effectiveUnitTag,
+				Freq:         int64(call.Freq),
+				StartTime:    startTime,
+				StopTime:     stopTime,
+				Duration:     call.Length,
+				Emergency:    call.Emergency,
+				Encrypted:    call.Encrypted,
+				CallFilename: call.CallFilename,
+				IncidentData: call.IncidentData,
 			},
 		})
 
@@ -387,6 +412,9 @@ func (p *Pipeline) handleCallStartFromEnd(ctx context.Context, msg *CallEndMsg)
 	if err != nil {
 		return fmt.Errorf("resolve identity: %w", err)
 	}
+	if p.suppressEncrypted(identity.SystemID, call.Talkgroup, call.Encrypted, "call") {
+		return ErrEncryptedSuppressed
+	}
 
 	freq := int64(call.Freq)
 	duration := float32(call.Length)
@@ -450,13 +478,13 @@ func (p *Pipeline) handleCallStartFromEnd(ctx context.Context, msg *CallEndMsg)
 	effectiveTgTag := call.TalkgroupAlphaTag
 	if call.Talkgroup > 0 {
 		effectiveTgTag = p.upsertAndEnrichTalkgroup(ctx, identity.SystemID, call.Talkgroup,
-			call.TalkgroupAlphaTag, call.TalkgroupTag, call.TalkgroupGroup, call.TalkgroupDescription, startTime)
+			call.TalkgroupAlphaTag, call.TalkgroupTag, call.TalkgroupGroup, call.TalkgroupDescription, startTime, "call_end")
 	}
 
 	// Upsert unit — capture effective tag from DB
 	effectiveUnitTag := call.UnitAlphaTag
 	if call.Unit > 0 {
-		if dbTag, upsertErr := p.db.UpsertUnit(ctx, identity.SystemID, call.Unit,
+		if dbTag, upsertErr := p.upsertUnit(ctx, identity.SystemID, call.Unit,
 			call.UnitAlphaTag, "call_end", startTime, call.Talkgroup,
 		); upsertErr == nil && dbTag != "" {
 			effectiveUnitTag = dbTag
@@ -491,6 +519,9 @@ func (p *Pipeline) handleCallStartFromEnd(ctx context.Context, msg *CallEndMsg)
 			Str("tr_call_id", call.ID).
 			Int64("call_id", existingID).
 			Msg("call_end matched audio-created call")
+		if call.Encrypted {
+			p.checkSplitCall(ctx, identity.SystemID, call.Talkgroup, call.Unit, existingID, existingST)
+		}
 
 		p.PublishEvent(EventData{
 			Type:      "call_end",
@@ -498,21 +529,21 @@ func (p *Pipeline) handleCallStartFromEnd(ctx context.Context, msg *CallEndMsg)
 			SiteID:    identity.SiteID,
 			Tgid:      call.Talkgroup,
 			Emergency: call.Emergency,
-			Payload: map[string]any{
-				"call_id":        existingID,
-				"system_id":      identity.SystemID,
-				"tgid":           call.Talkgroup,
-				"tg_alpha_tag":   effectiveTgTag,
-				"unit":           call.Unit,
-				"unit_alpha_tag": effectiveUnitTag,
-				"freq":           freq,
-				"start_time":     startTime,
-				"stop_time":      stopTime,
-				"duration":       call.Length,
-				"emergency":      call.Emergency,
-				"encrypted":      call.Encrypted,
-				"call_filename":  call.CallFilename,
-				"incident_data":  call.IncidentData,
+			Payload: &events.CallEnd{
+				CallID:       existingID,
+				SystemID:     identity.SystemID,
+				Tgid:         call.Talkgroup,
+				TgAlphaTag:   effectiveTgTag,
+				Unit:         call.Unit,
+				UnitAlphaTag: effectiveUnitTag,
+				Freq:         freq,
+				StartTime:    startTime,
+				StopTime:     stopTime,
+				Duration:     call.Length,
+				Emergency:    call.Emergency,
+				Encrypted:    call.Encrypted,
+				CallFilename: call.CallFilename,
+				IncidentData: call.IncidentData,
 			},
 		})
 
@@ -541,6 +572,7 @@ func (p *Pipeline) handleCallStartFromEnd(ctx context.Context, msg *CallEndMsg)
 	if err != nil {
 		return fmt.Errorf("insert call from end: %w", err)
 	}
+	p.invalidate(api.CacheTagTalkgroup(call.Talkgroup))
 
 	// Create call group (same as handleCallStart)
 	cgID, cgErr := p.db.UpsertCallGroup(ctx, identity.SystemID, call.Talkgroup, startTime,
@@ -566,6 +598,9 @@ func (p *Pipeline) handleCallStartFromEnd(ctx context.Context, msg *CallEndMsg)
 		Str("tr_call_id", call.ID).
 		Int64("call_id", callID).
 		Msg("call inserted from call_end (missed call_start)")
+	if call.Encrypted {
+		p.checkSplitCall(ctx, identity.SystemID, call.Talkgroup, call.Unit, callID, startTime)
+	}
 
 	p.PublishEvent(EventData{
 		Type:      "call_end",
@@ -573,21 +608,21 @@ func (p *Pipeline) handleCallStartFromEnd(ctx context.Context, msg *CallEndMsg)
 		SiteID:    identity.SiteID,
 		Tgid:      call.Talkgroup,
 		Emergency: call.Emergency,
-		Payload: map[string]any{
-			"call_id":        callID,
-			"system_id":      identity.SystemID,
-			"tgid":           call.Talkgroup,
-			"tg_alpha_tag":   effectiveTgTag,
-			"unit":           call.Unit,
-			"unit_alpha_tag": effectiveUnitTag,
-			"freq":           freq,
-			"start_time":     startTime,
-			"stop_time":      stopTime,
-			"duration":       call.Length,
-			"emergency":      call.Emergency,
-			"encrypted":      call.Encrypted,
-			"call_filename":  call.CallFilename,
-			"incident_data":  call.IncidentData,
+		Payload: &events.CallEnd{
+			CallID:       callID,
+			SystemID:     identity.SystemID,
+			Tgid:         call.Talkgroup,
+			TgAlphaTag:   effectiveTgTag,
+			Unit:         call.Unit,
+			UnitAlphaTag: effectiveUnitTag,
+			Freq:         freq,
+			StartTime:    startTime,
+			StopTime:     stopTime,
+			Duration:     call.Length,
+			Emergency:    call.Emergency,
+			Encrypted:    call.Encrypted,
+			CallFilename: call.CallFilename,
+			IncidentData: call.IncidentData,
 		},
 	})
 
@@ -641,6 +676,7 @@ func (p *Pipeline) handleCallsActive(payload []byte) error {
 				stopTime := entry.StartTime.Add(time.Duration(activeCall.Elapsed) * time.Second)
 				_ = p.db.UpdateCallElapsed(ctx, entry.CallID, entry.StartTime, &stopTime, &elapsed)
 			}
+			p.checkStuckMic(ctx, trCallID, entry, activeCall)
 			continue
 		}
 
@@ -690,23 +726,25 @@ func (p *Pipeline) handleCallsActive(payload []byte) error {
 			Tgid:      entry.Tgid,
 			UnitID:    entry.Unit,
 			Emergency: entry.Emergency,
-			Payload: map[string]any{
-				"call_id":        entry.CallID,
-				"system_id":      entry.SystemID,
-				"tgid":           entry.Tgid,
-				"tg_alpha_tag":   entry.TgAlphaTag,
-				"unit":           entry.Unit,
-				"unit_alpha_tag": entry.UnitAlphaTag,
-				"freq":           entry.Freq,
-				"start_time":     entry.StartTime,
-				"stop_time":      stopTime,
-				"duration":       duration,
-				"emergency":      entry.Emergency,
-				"encrypted":      entry.Encrypted,
+			Payload: &events.CallEnd{
+				CallID:       entry.CallID,
+				SystemID:     entry.SystemID,
+				Tgid:         entry.Tgid,
+				TgAlphaTag:   entry.TgAlphaTag,
+				Unit:         entry.Unit,
+				UnitAlphaTag: entry.UnitAlphaTag,
+				Freq:         entry.Freq,
+				StartTime:    entry.StartTime,
+				StopTime:     stopTime,
+				Duration:     float64(duration),
+				Emergency:    entry.Emergency,
+				Encrypted:    entry.Encrypted,
 			},
 		})
 	}
 
+	p.stuckMic.Prune(activeIDs, time.Now())
+
 	p.log.Debug().
 		Int("active_calls", len(msg.Calls)).
 		Str("instance_id", msg.InstanceID).
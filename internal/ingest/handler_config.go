@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/trconfig"
 )
 
 func (p *Pipeline) handleConfig(payload []byte) error {
@@ -38,5 +41,80 @@ func (p *Pipeline) handleConfig(payload []byte) error {
 		Str("capture_dir", cfg.CaptureDir).
 		Msg("stored instance config")
 
+	p.recordConfigVersion(ctx, msg.InstanceID, msg.Timestamp, payload)
+	p.syncSources(ctx, msg.InstanceID, msg.Timestamp, cfg.Sources)
 	return nil
 }
+
+// syncSources updates the instance's source registry from the config's
+// sources. Older trunk-recorder versions send none, in which case the registry
+// is left alone. Best-effort, like the config history.
+func (p *Pipeline) syncSources(ctx context.Context, instanceID string, timestamp int64, cfgSources []ConfigSource) {
+	if len(cfgSources) == 0 {
+		return
+	}
+	seenAt := time.Now()
+	if timestamp > 0 {
+		seenAt = time.Unix(timestamp, 0)
+	}
+	sources := make([]database.Source, len(cfgSources))
+	for i, cs := range cfgSources {
+		sources[i] = cs.toSource()
+	}
+	if err := p.db.SyncSources(ctx, instanceID, "mqtt", sources, seenAt); err != nil {
+		p.log.Warn().Err(err).Str("instance_id", instanceID).Msg("failed to sync sources")
+	}
+}
+
+func (cs ConfigSource) toSource() database.Source {
+	s := database.Source{
+		SourceNum:        cs.SourceNum,
+		Device:           cs.Device,
+		Driver:           cs.Driver,
+		Antenna:          cs.Antenna,
+		CenterHz:         int64(cs.Center),
+		RateHz:           int64(cs.Rate),
+		MinHz:            int64(cs.MinHz),
+		MaxHz:            int64(cs.MaxHz),
+		ErrorHz:          int(cs.Error),
+		Gain:             trconfig.FormatGain(cs.Gain),
+		AnalogRecorders:  cs.AnalogRecorders,
+		DigitalRecorders: cs.DigitalRecorders,
+	}
+	if s.MinHz == 0 && s.MaxHz == 0 {
+		s.MinHz, s.MaxHz = trconfig.TRSource{Center: cs.Center, Rate: cs.Rate}.Band()
+	}
+	return s
+}
+
+// recordConfigVersion keeps the config as a new version in the instance's
+// config history when it changed since the last message. Best-effort: the
+// snapshot is already stored in instance_configs.
+func (p *Pipeline) recordConfigVersion(ctx context.Context, instanceID string, timestamp int64, payload []byte) {
+	var raw struct {
+		Config json.RawMessage `json:"config"`
+	}
+	if err := json.Unmarshal(payload, &raw); err != nil || len(raw.Config) == 0 {
+		return
+	}
+	seenAt := time.Now()
+	if timestamp > 0 {
+		seenAt = time.Unix(timestamp, 0)
+	}
+	v, err := p.db.RecordInstanceConfigVersion(ctx, instanceID, raw.Config, seenAt)
+	if err != nil {
+		p.log.Warn().Err(err).Str("instance_id", instanceID).Msg("failed to record config version")
+		return
+	}
+	if v != nil && v.Version > 1 {
+		paths := make([]string, len(v.Changes))
+		for i, c := range v.Changes {
+			paths[i] = c.Path
+		}
+		p.log.Info().
+			Str("instance_id", instanceID).
+			Int("version", v.Version).
+			Strs("changed", paths).
+			Msg("instance config changed")
+	}
+}
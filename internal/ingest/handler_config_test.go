@@ -0,0 +1,37 @@
+package ingest
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestConfigSources(t *testing.T) {
+	payload := `{"type":"config","instance_id":"tr-1","config":{"capture_dir":"/audio","sources":[
+		{"source_num":0,"rate":2400000,"center":851500000,"min_hz":850300000,"max_hz":852700000,"error":-150,
+		 "driver":"osmosdr","device":"rtl=00000101","antenna":"","gain":38.6,"analog_recorders":0,"digital_recorders":4},
+		{"source_num":1,"rate":8000000,"center":857000000,"error":0,
+		 "driver":"osmosdr","device":"airspy","gain":"LNA:12,MIX:10","digital_recorders":6}
+	]}}`
+	var msg ConfigMsg
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if len(msg.Config.Sources) != 2 {
+		t.Fatalf("sources = %d, want 2", len(msg.Config.Sources))
+	}
+
+	s := msg.Config.Sources[0].toSource()
+	if s.SourceNum != 0 || s.CenterHz != 851500000 || s.MinHz != 850300000 || s.MaxHz != 852700000 ||
+		s.ErrorHz != -150 || s.Gain != "38.6" || s.Device != "rtl=00000101" || s.DigitalRecorders != 4 {
+		t.Errorf("source 0 = %+v", s)
+	}
+
+	// No min/max in the message: derived from center and rate.
+	s = msg.Config.Sources[1].toSource()
+	if s.SourceNum != 1 || s.MinHz != 853000000 || s.MaxHz != 861000000 || s.Gain != "LNA:12,MIX:10" {
+		t.Errorf("source 1 = %+v", s)
+	}
+	if !s.Covers(860987500) || s.Covers(851012500) {
+		t.Error("Covers gave wrong answer")
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"time"
+
+	"github.com/snarg/tr-engine/pkg/events"
 )
 
 func (p *Pipeline) handleConsoleLog(payload []byte) error {
@@ -30,11 +32,11 @@ func (p *Pipeline) handleConsoleLog(payload []byte) error {
 
 	p.PublishEvent(EventData{
 		Type: "console",
-		Payload: map[string]any{
-			"instance_id": msg.InstanceID,
-			"severity":    data.Severity,
-			"log_msg":     data.LogMsg,
-			"time":        logTime,
+		Payload: &events.Console{
+			InstanceID: msg.InstanceID,
+			Severity:   data.Severity,
+			LogMsg:     data.LogMsg,
+			Time:       logTime,
 		},
 	})
 
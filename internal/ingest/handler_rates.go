@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/pkg/events"
 )
 
 func (p *Pipeline) handleRates(payload []byte) error {
@@ -44,6 +45,8 @@ func (p *Pipeline) handleRates(payload []byte) error {
 	}
 
 	for _, row := range rows {
+		p.observeControlChannel(msg.InstanceID, row.SysName, row.ControlChannel, ts, ccSourceRates)
+
 		systemID := 0
 		if row.SystemID != nil {
 			systemID = *row.SystemID
@@ -51,13 +54,13 @@ func (p *Pipeline) handleRates(payload []byte) error {
 		p.PublishEvent(EventData{
 			Type:     "rate_update",
 			SystemID: systemID,
-			Payload: map[string]any{
-				"system_id":             systemID,
-				"sys_name":             row.SysName,
-				"decode_rate":          row.DecodeRate,
-				"decode_rate_interval": row.DecodeRateInterval,
-				"control_channel":      row.ControlChannel,
-				"time":                 row.Time,
+			Payload: &events.RateUpdate{
+				SystemID:           systemID,
+				SysName:            row.SysName,
+				DecodeRate:         row.DecodeRate,
+				DecodeRateInterval: row.DecodeRateInterval,
+				ControlChannel:     row.ControlChannel,
+				Time:               row.Time,
 			},
 		})
 	}
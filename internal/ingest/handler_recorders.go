@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/pkg/events"
 )
 
 func (p *Pipeline) handleRecorders(payload []byte) error {
@@ -52,35 +53,35 @@ func (p *Pipeline) processRecorder(instanceID string, rec RecorderData, ts time.
 	p.recorderBatcher.Add(row)
 	p.UpdateRecorderCache(instanceID, row)
 
-	payload := map[string]any{
-		"id":          rec.ID,
-		"instance_id": instanceID,
-		"src_num":     rec.SrcNum,
-		"rec_num":     rec.RecNum,
-		"type":        rec.Type,
-		"rec_state":   rec.RecStateType,
-		"freq":        int64(rec.Freq),
-		"duration":    rec.Duration,
-		"count":       rec.Count,
-		"squelched":   rec.Squelched,
+	payload := &events.RecorderUpdate{
+		ID:         rec.ID,
+		InstanceID: instanceID,
+		SrcNum:     rec.SrcNum,
+		RecNum:     rec.RecNum,
+		Type:       rec.Type,
+		RecState:   rec.RecStateType,
+		Freq:       int64(rec.Freq),
+		Duration:   rec.Duration,
+		Count:      rec.Count,
+		Squelched:  rec.Squelched,
 	}
 
 	// Enrich with active call data by matching frequency
 	freq := int64(rec.Freq)
 	if freq > 0 {
 		if call, ok := p.activeCalls.FindByFreq(freq); ok {
-			payload["system_id"] = call.SystemID
-			payload["tgid"] = call.Tgid
-			payload["tg_alpha_tag"] = call.TgAlphaTag
-			payload["unit_id"] = call.Unit
-			payload["unit_alpha_tag"] = call.UnitAlphaTag
+			payload.SystemID = call.SystemID
+			payload.Tgid = call.Tgid
+			payload.TgAlphaTag = call.TgAlphaTag
+			payload.UnitID = call.Unit
+			payload.UnitAlphaTag = call.UnitAlphaTag
 		} else if strings.Contains(rec.Type, "Analog") {
 			// AnalogC recorders: fall back to conventional freq→talkgroup map
 			if v, ok := p.conventionalFreqMap.Load(freq); ok {
 				e := v.(conventionalFreqEntry)
-				payload["system_id"] = e.SystemID
-				payload["tgid"] = e.Tgid
-				payload["tg_alpha_tag"] = e.TgAlphaTag
+				payload.SystemID = e.SystemID
+				payload.Tgid = e.Tgid
+				payload.TgAlphaTag = e.TgAlphaTag
 			}
 		}
 	}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/snarg/tr-engine/internal/api"
 )
 
 func (p *Pipeline) handleSystems(payload []byte) error {
@@ -58,6 +60,7 @@ func (p *Pipeline) processSystemInfo(instanceID string, sys *SystemInfoData) err
 			// our system is soft-deleted — no need to update its identity.
 			p.mergeSystem(ctx, identity.SystemID, targetID, sys.SysName)
 			p.completeWarmup()
+			p.invalidate(api.CacheTagSystems, api.CacheTagTalkgroups)
 
 			// Update site fields on the (now-moved) site
 			if err := p.db.UpdateSite(ctx, identity.SiteID, sys.SysNum, sys.Nac, sys.RFSS, sys.SiteID, sys.Type); err != nil {
@@ -98,7 +101,11 @@ func (p *Pipeline) processSystemInfo(instanceID string, sys *SystemInfoData) err
 		Str("wacn", sys.Wacn).
 		Str("nac", sys.Nac).
 		Msg("system info processed")
+	p.invalidate(api.CacheTagSystems)
 
+	if sys.ControlChannel > 0 {
+		p.observeControlChannel(instanceID, sys.SysName, normalizeFreq(sys.ControlChannel), time.Now(), ccSourceSystem)
+	}
 	return nil
 }
 
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/pkg/events"
 )
 
 func (p *Pipeline) handleTrunkingMessage(topic string, payload []byte) error {
@@ -42,6 +43,14 @@ func (p *Pipeline) handleTrunkingMessage(topic string, payload []byte) error {
 	}
 	p.trunkingBatcher.Add(row)
 
+	if g, ok := parseInterconnectGrant(data); ok && systemID != nil {
+		p.trackInterconnect(msg.InstanceID, data.SysName, *systemID, g, ts)
+	}
+
+	if freq, ok := parseControlChannelMsg(data); ok {
+		p.observeControlChannel(msg.InstanceID, data.SysName, freq, ts, ccSourceTrunking)
+	}
+
 	sysID := 0
 	if systemID != nil {
 		sysID = *systemID
@@ -49,15 +58,15 @@ func (p *Pipeline) handleTrunkingMessage(topic string, payload []byte) error {
 	p.PublishEvent(EventData{
 		Type:     "trunking_message",
 		SystemID: sysID,
-		Payload: map[string]any{
-			"system_id":      sysID,
-			"sys_name":       data.SysName,
-			"trunk_msg":      data.TrunkMsg,
-			"trunk_msg_type": data.TrunkMsgType,
-			"opcode":         data.Opcode,
-			"opcode_type":    data.OpcodeType,
-			"opcode_desc":    data.OpcodeDesc,
-			"time":           ts,
+		Payload: &events.TrunkingMessage{
+			SystemID:     sysID,
+			SysName:      data.SysName,
+			TrunkMsg:     data.TrunkMsg,
+			TrunkMsgType: data.TrunkMsgType,
+			Opcode:       data.Opcode,
+			OpcodeType:   data.OpcodeType,
+			OpcodeDesc:   data.OpcodeDesc,
+			Time:         ts,
 		},
 	})
 
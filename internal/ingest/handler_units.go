@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/pkg/events"
 )
 
 // parseUnitEventTopic extracts the event type from the trailing topic segment.
@@ -70,12 +71,27 @@ func (p *Pipeline) handleUnitEvent(topic string, payload []byte) error {
 	if data.Unit <= 0 {
 		return nil
 	}
+	if p.suppressEncrypted(identity.SystemID, data.Talkgroup, data.Encrypted, "unit_event") {
+		return ErrEncryptedSuppressed
+	}
+	if data.Emergency {
+		p.publishEmergency(identity.SiteID, data.Encrypted, events.Emergency{
+			SystemID:     identity.SystemID,
+			Tgid:         data.Talkgroup,
+			TgAlphaTag:   data.TalkgroupAlphaTag,
+			Unit:         data.Unit,
+			UnitAlphaTag: data.UnitAlphaTag,
+			Freq:         int64(data.Freq),
+			Source:       eventType,
+			Time:         ts,
+		})
+	}
 
 	// Upsert talkgroup if present — capture effective tag from DB
 	effectiveTgTag := data.TalkgroupAlphaTag
 	if data.Talkgroup > 0 {
-		if dbTag, err := p.db.UpsertTalkgroup(ctx, identity.SystemID, data.Talkgroup,
-			data.TalkgroupAlphaTag, data.TalkgroupTag, data.TalkgroupGroup, data.TalkgroupDescription, ts,
+		if dbTag, err := p.upsertTalkgroup(ctx, identity.SystemID, data.Talkgroup,
+			data.TalkgroupAlphaTag, data.TalkgroupTag, data.TalkgroupGroup, data.TalkgroupDescription, ts, eventType,
 		); err != nil {
 			p.log.Warn().Err(err).Int("tgid", data.Talkgroup).Msg("failed to upsert talkgroup")
 		} else if dbTag != "" {
@@ -85,7 +101,7 @@ func (p *Pipeline) handleUnitEvent(topic string, payload []byte) error {
 
 	// Upsert unit — returns the DB's effective alpha_tag (respects manual > csv > mqtt priority)
 	effectiveUnitTag := data.UnitAlphaTag
-	if dbTag, err := p.db.UpsertUnit(ctx, identity.SystemID, data.Unit,
+	if dbTag, err := p.upsertUnit(ctx, identity.SystemID, data.Unit,
 		data.UnitAlphaTag, eventType, ts, data.Talkgroup,
 	); err != nil {
 		p.log.Warn().Err(err).Int("unit", data.Unit).Msg("failed to upsert unit")
@@ -185,30 +201,31 @@ func (p *Pipeline) handleUnitEvent(topic string, payload []byte) error {
 			return fmt.Errorf("insert unit event: %w", err)
 		}
 
-		ssePayload := map[string]any{
-			"event_type":     eventType,
-			"system_id":      identity.SystemID,
-			"unit_id":        data.Unit,
-			"unit_alpha_tag": effectiveUnitTag,
-			"tgid":           data.Talkgroup,
-			"tg_alpha_tag":   effectiveTgTag,
-			"time":           ts,
-			"incident_data":  data.IncidentData,
+		ssePayload := &events.UnitEvent{
+			EventType:    eventType,
+			SystemID:     identity.SystemID,
+			UnitID:       data.Unit,
+			UnitAlphaTag: effectiveUnitTag,
+			Tgid:         data.Talkgroup,
+			TgAlphaTag:   effectiveTgTag,
+			Time:         ts,
+			IncidentData: data.IncidentData,
 		}
 		if eventType == "signal" {
-			ssePayload["signaling_type"] = data.SignalingType
-			ssePayload["signal_type"] = data.SignalType
+			ssePayload.SignalingType = data.SignalingType
+			ssePayload.SignalType = data.SignalType
 		}
 
 		p.PublishEvent(EventData{
-			Type:      "unit_event",
-			SubType:   eventType,
-			SystemID:  identity.SystemID,
-			SiteID:    identity.SiteID,
-			Tgid:      data.Talkgroup,
-			UnitID:    data.Unit,
-			Emergency: data.Emergency,
-			Payload:   ssePayload,
+			Type:       "unit_event",
+			SubType:    eventType,
+			SystemID:   identity.SystemID,
+			SiteID:     identity.SiteID,
+			Tgid:       data.Talkgroup,
+			UnitID:     data.Unit,
+			Emergency:  data.Emergency,
+			Restricted: p.restrictedEncrypted(identity.SystemID, data.Talkgroup, data.Encrypted),
+			Payload:    ssePayload,
 		})
 	}
 
@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/snarg/tr-engine/internal/api"
+	"github.com/snarg/tr-engine/internal/metrics"
+	"github.com/snarg/tr-engine/pkg/events"
 )
 
 // UploadResult holds the outcome of a successfully processed uploaded call.
@@ -26,12 +28,23 @@ type UploadResult struct {
 func (p *Pipeline) ProcessUpload(ctx context.Context, instanceID string, format string, fields map[string]string, audioData []byte, audioFilename string) (*api.UploadCallResult, error) {
 	var meta *AudioMetadata
 	var err error
+	source := "upload"
 
 	switch format {
 	case "rdio-scanner":
 		meta, err = ParseRdioScannerFields(fields)
 	case "openmhz":
 		meta, err = ParseOpenMHzFields(fields)
+	case "filename":
+		// Bare audio file: talkgroup and start time come from its name.
+		meta, err = p.metadataFromAudio(ctx, audioFilename, audioData, "")
+		if err == nil && fields["system"] != "" {
+			meta.ShortName = fields["system"]
+		}
+	case "issi":
+		// ISSI/DFSI gateway call record (JSON) in the "issi" field
+		instanceID, meta, err = p.prepareISSICall(instanceID, []byte(fields["issi"]))
+		source = "issi"
 	default:
 		return nil, fmt.Errorf("unsupported upload format: %s", format)
 	}
@@ -39,12 +52,12 @@ func (p *Pipeline) ProcessUpload(ctx context.Context, instanceID string, format
 		return nil, fmt.Errorf("parse %s fields: %w", format, err)
 	}
 
-	// OpenMHz doesn't always include short_name — use instanceID as fallback
+	// OpenMHz and bare files don't always include short_name — use instanceID as fallback
 	if meta.ShortName == "" {
 		meta.ShortName = instanceID
 	}
 
-	result, err := p.ProcessUploadedCall(ctx, instanceID, meta, audioData, audioFilename)
+	result, err := p.processCompleteCall(ctx, instanceID, meta, audioData, audioFilename, source)
 	if err != nil {
 		return nil, err
 	}
@@ -58,11 +71,18 @@ func (p *Pipeline) ProcessUpload(ctx context.Context, instanceID string, format
 	}, nil
 }
 
-// ProcessUploadedCall ingests a call submitted via HTTP upload (rdio-scanner or
-// OpenMHz format). It mirrors processWatchedFile: identity resolution, dedup,
+// ProcessUploadedCall ingests a call submitted via HTTP upload (rdio-scanner,
+// OpenMHz, or a bare audio file). It mirrors processWatchedFile: identity resolution, dedup,
 // call creation, audio save, src/freq processing, unit upserts, SSE publish,
 // and transcription enqueue.
 func (p *Pipeline) ProcessUploadedCall(ctx context.Context, instanceID string, meta *AudioMetadata, audioData []byte, audioFilename string) (*UploadResult, error) {
+	return p.processCompleteCall(ctx, instanceID, meta, audioData, audioFilename, "upload")
+}
+
+// processCompleteCall creates a finished call from metadata and optional
+// audio. source ("upload", "issi") is recorded as the unit event type and the
+// call_end event source.
+func (p *Pipeline) processCompleteCall(ctx context.Context, instanceID string, meta *AudioMetadata, audioData []byte, audioFilename, source string) (*UploadResult, error) {
 	startTime := time.Unix(meta.StartTime, 0)
 
 	// Resolve identity (auto-creates system/site if needed)
@@ -70,6 +90,9 @@ func (p *Pipeline) ProcessUploadedCall(ctx context.Context, instanceID string, m
 	if err != nil {
 		return nil, fmt.Errorf("resolve identity: %w", err)
 	}
+	if p.suppressEncrypted(identity.SystemID, meta.Talkgroup, meta.Encrypted != 0, "audio") {
+		return nil, ErrEncryptedSuppressed
+	}
 
 	// Dedup check — reject if this call already exists
 	if existingID, _, findErr := p.db.FindCallForAudio(ctx, identity.SystemID, meta.Talkgroup, startTime); findErr == nil {
@@ -90,39 +113,53 @@ func (p *Pipeline) ProcessUploadedCall(ctx context.Context, instanceID string, m
 
 	// Save audio file (best-effort — still return success for the call record)
 	var audioPath string
-	if len(audioData) > 0 {
-		audioType := meta.AudioType
-		if audioType == "" {
-			if idx := strings.LastIndex(audioFilename, "."); idx >= 0 {
-				audioType = audioFilename[idx+1:]
-			}
-		}
-		if audioType == "" {
-			audioType = "m4a"
+	var unusable bool
+	var held []byte
+	audioType := meta.AudioType
+	if audioType == "" {
+		if idx := strings.LastIndex(audioFilename, "."); idx >= 0 {
+			audioType = audioFilename[idx+1:]
 		}
-
+	}
+	if audioType == "" {
+		audioType = "m4a"
+	}
+	if len(audioData) > 0 && p.audioDropped(identity.SystemID, meta.Talkgroup, startTime) {
+		metrics.AudioStoragePolicyTotal.WithLabelValues("dropped").Inc()
+	} else if len(audioData) > 0 && p.transcriptOnly(identity.SystemID, meta.Talkgroup, startTime) {
+		// Held in memory for transcription only; nothing is written
+		metrics.AudioStoragePolicyTotal.WithLabelValues("transcript_only").Inc()
+		held = audioData
+	} else if len(audioData) > 0 {
+		receivedType := audioType
+		audioData, audioType, audioFilename = p.applyStoragePolicy(ctx, identity.SystemID, meta.Talkgroup, startTime, audioData, audioType, audioFilename)
 		filename := buildAudioFilename(audioFilename, audioType, startTime)
 		audioKey := buildAudioRelPath(meta.ShortName, startTime, filename)
 		contentType := audioContentType(audioType)
 
-		if err := p.saveAudio(ctx, audioKey, audioData, contentType); err != nil {
+		if err := p.saveAudio(ctx, audioKey, audioData, contentType, startTime, meta.Emergency != 0); err != nil {
 			p.log.Error().Err(err).Int64("call_id", callID).Msg("failed to save uploaded audio file")
 		} else {
 			audioPath = audioKey
-			if updateErr := p.db.UpdateCallAudio(ctx, callID, callStartTime, audioPath, len(audioData)); updateErr != nil {
+			if updateErr := p.registerAudio(ctx, callID, callStartTime, audioPath, receivedType, audioType, len(audioData), "upload"); updateErr != nil {
 				p.log.Warn().Err(updateErr).Int64("call_id", callID).Msg("failed to update call audio path")
+			} else {
+				p.verifyAudioDuration(ctx, callID, callStartTime, audioData, audioType, p.store.LocalPath(audioPath))
+				unusable = p.checkAudioUsable(ctx, callID, callStartTime, audioData, audioType,
+					p.store.LocalPath(audioPath), p.store.Type() == "local")
 			}
 		}
 	}
 
 	// Process srcList/freqList
 	p.processSrcFreqData(ctx, callID, callStartTime, meta)
+	p.checkSplitCall(ctx, identity.SystemID, meta.Talkgroup, initiatingUnit(meta.SrcList), callID, callStartTime)
 
 	// Upsert units from srcList
 	for _, s := range meta.SrcList {
 		if s.Src > 0 {
-			_, _ = p.db.UpsertUnit(ctx, identity.SystemID, s.Src,
-				s.Tag, "upload", startTime, meta.Talkgroup,
+			_, _ = p.upsertUnit(ctx, identity.SystemID, s.Src,
+				s.Tag, source, startTime, meta.Talkgroup,
 			)
 		}
 	}
@@ -138,26 +175,28 @@ func (p *Pipeline) ProcessUploadedCall(ctx context.Context, instanceID string, m
 		SiteID:    identity.SiteID,
 		Tgid:      meta.Talkgroup,
 		Emergency: meta.Emergency != 0,
-		Payload: map[string]any{
-			"call_id":        callID,
-			"system_id":      identity.SystemID,
-			"tgid":           meta.Talkgroup,
-			"tg_alpha_tag":   effectiveTgTag,
-			"freq":           int64(meta.Freq),
-			"start_time":     startTime,
-			"stop_time":      stopTime,
-			"duration":       float64(meta.CallLength),
-			"emergency":      meta.Emergency != 0,
-			"encrypted":      meta.Encrypted != 0,
-			"audio_file_path": audioPath,
-			"source":         "upload",
+		Payload: &events.CallEnd{
+			CallID:        callID,
+			SystemID:      identity.SystemID,
+			Tgid:          meta.Talkgroup,
+			TgAlphaTag:    effectiveTgTag,
+			Freq:          int64(meta.Freq),
+			StartTime:     startTime,
+			StopTime:      stopTime,
+			Duration:      float64(meta.CallLength),
+			Emergency:     meta.Emergency != 0,
+			Encrypted:     meta.Encrypted != 0,
+			AudioFilePath: audioPath,
+			Source:        source,
 		},
 	})
 
 	// Enqueue for transcription if not encrypted
-	if meta.Encrypted == 0 {
+	if meta.Encrypted == 0 && !unusable {
 		if meta.Transcript != "" {
 			p.insertSourceTranscription(callID, callStartTime, identity.SystemID, meta.Talkgroup, meta)
+		} else if held != nil {
+			p.enqueueHeldTranscription(callID, callStartTime, identity.SystemID, held, audioType, meta)
 		} else {
 			p.enqueueTranscription(callID, callStartTime, identity.SystemID, audioPath, meta)
 		}
@@ -169,7 +208,8 @@ func (p *Pipeline) ProcessUploadedCall(ctx context.Context, instanceID string, m
 		Str("sys_name", meta.ShortName).
 		Str("instance_id", instanceID).
 		Str("audio_path", audioPath).
-		Msg("call created from HTTP upload")
+		Str("source", source).
+		Msg("call created from complete call record")
 
 	return &UploadResult{
 		CallID:        callID,
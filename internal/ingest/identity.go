@@ -2,13 +2,33 @@ package ingest
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/rs/zerolog"
 	"github.com/snarg/tr-engine/internal/database"
 )
 
+// ErrIdentityPending is returned by Resolve when an instance may not
+// auto-create the system/site for a sys_name. The sys_name is staged in
+// pending_identities until an admin approves or maps it.
+var ErrIdentityPending = errors.New("identity pending approval")
+
+// pendingRecheck is how often a denied instance/sys_name pair is looked up
+// again (to pick up an approval) and its message count flushed to the DB.
+const pendingRecheck = 30 * time.Second
+
+type pendingCheck struct {
+	checked time.Time
+	hits    int // messages since the last flush
+}
+
 // ResolvedIdentity contains the resolved system/site IDs for an MQTT message.
 type ResolvedIdentity struct {
 	InstanceDBID int
@@ -18,6 +38,30 @@ type ResolvedIdentity struct {
 	Sysid        string
 }
 
+// identityStats tracks how a cache entry was resolved and how often it is
+// used, for GET /admin/identity-map.
+type identityStats struct {
+	instanceID string
+	sysName    string
+	source     string // loaded, resolved, approved
+	cachedAt   time.Time
+	hits       atomic.Int64
+	lastHit    atomic.Int64 // unix nanos, 0 = never
+}
+
+// IdentityEntry is a snapshot of one identity cache entry.
+type IdentityEntry struct {
+	InstanceID string
+	SysName    string
+	SystemID   int
+	SiteID     int
+	Sysid      string
+	Source     string
+	CachedAt   time.Time
+	Hits       int64
+	LastHit    time.Time
+}
+
 // IdentityResolver caches instance/system/site mappings in memory.
 // It auto-creates entries on first encounter.
 type IdentityResolver struct {
@@ -27,8 +71,16 @@ type IdentityResolver struct {
 
 	// cache keyed by "instanceID:sysName"
 	cache map[string]*ResolvedIdentity
+	// usage of cache entries, same keys
+	stats map[string]*identityStats
 	// instance cache keyed by instanceID
 	instances map[string]int
+
+	// Auto-create policy: per-instance overrides of the default
+	denyAutoCreate bool
+	policies       map[string]bool // instanceID → may auto-create
+	// denied "instanceID:sysName" pairs, rechecked every pendingRecheck
+	pending map[string]*pendingCheck
 }
 
 func NewIdentityResolver(db *database.DB, log zerolog.Logger) *IdentityResolver {
@@ -36,12 +88,76 @@ func NewIdentityResolver(db *database.DB, log zerolog.Logger) *IdentityResolver
 		db:        db,
 		log:       log,
 		cache:     make(map[string]*ResolvedIdentity),
+		stats:     make(map[string]*identityStats),
 		instances: make(map[string]int),
+		policies:  make(map[string]bool),
+		pending:   make(map[string]*pendingCheck),
+	}
+}
+
+// put caches id under instanceID/sysName, noting how it was resolved. The
+// caller holds r.mu for writing.
+func (r *IdentityResolver) put(instanceID, sysName string, id *ResolvedIdentity, source string) {
+	key := instanceID + ":" + sysName
+	r.cache[key] = id
+	if r.stats == nil {
+		r.stats = make(map[string]*identityStats)
+	}
+	r.stats[key] = &identityStats{
+		instanceID: instanceID,
+		sysName:    sysName,
+		source:     source,
+		cachedAt:   time.Now(),
+	}
+}
+
+// hit counts a cache hit on key. The caller holds r.mu.
+func (r *IdentityResolver) hit(key string) {
+	if st := r.stats[key]; st != nil {
+		st.hits.Add(1)
+		st.lastHit.Store(time.Now().UnixNano())
+	}
+}
+
+// SetDenyAutoCreate sets the default for instances without a policy: when
+// deny is true, they may only send sys_names that already have a site.
+func (r *IdentityResolver) SetDenyAutoCreate(deny bool) {
+	r.mu.Lock()
+	r.denyAutoCreate = deny
+	r.mu.Unlock()
+}
+
+// ReloadPolicies reloads instance policies from the DB and forgets denied
+// pairs, so approvals and policy changes apply to the next message.
+func (r *IdentityResolver) ReloadPolicies(ctx context.Context) error {
+	policies, err := r.db.ListInstancePolicies(ctx)
+	if err != nil {
+		return err
 	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.policies = make(map[string]bool, len(policies))
+	for _, p := range policies {
+		r.policies[p.InstanceID] = p.AutoCreate
+	}
+	r.pending = make(map[string]*pendingCheck)
+	return nil
+}
+
+// mayAutoCreate reports whether instanceID may create systems/sites. The
+// caller holds r.mu.
+func (r *IdentityResolver) mayAutoCreate(instanceID string) bool {
+	if allow, ok := r.policies[instanceID]; ok {
+		return allow
+	}
+	return !r.denyAutoCreate
 }
 
 // LoadCache pre-populates the cache from existing DB records.
 func (r *IdentityResolver) LoadCache(ctx context.Context) error {
+	if err := r.ReloadPolicies(ctx); err != nil {
+		return fmt.Errorf("load instance policies: %w", err)
+	}
 	sites, err := r.db.LoadAllSites(ctx)
 	if err != nil {
 		return fmt.Errorf("load sites: %w", err)
@@ -51,13 +167,12 @@ func (r *IdentityResolver) LoadCache(ctx context.Context) error {
 	defer r.mu.Unlock()
 
 	for _, s := range sites {
-		key := s.InstanceID + ":" + s.ShortName
-		r.cache[key] = &ResolvedIdentity{
+		r.put(s.InstanceID, s.ShortName, &ResolvedIdentity{
 			SystemID:   s.SystemID,
 			SiteID:     s.SiteID,
 			SystemName: s.ShortName,
 			Sysid:      s.Sysid,
-		}
+		}, "loaded")
 	}
 
 	r.log.Info().Int("cached_sites", len(sites)).Msg("identity cache loaded")
@@ -79,6 +194,7 @@ func (r *IdentityResolver) Resolve(ctx context.Context, instanceID, sysName stri
 	// Fast path: read lock
 	r.mu.RLock()
 	if id, ok := r.cache[key]; ok {
+		r.hit(key)
 		r.mu.RUnlock()
 		return id, nil
 	}
@@ -90,9 +206,14 @@ func (r *IdentityResolver) Resolve(ctx context.Context, instanceID, sysName stri
 
 	// Double-check
 	if id, ok := r.cache[key]; ok {
+		r.hit(key)
 		return id, nil
 	}
 
+	if !r.mayAutoCreate(instanceID) {
+		return r.resolveExisting(ctx, key, instanceID, sysName)
+	}
+
 	// Ensure instance exists
 	if _, ok := r.instances[instanceID]; !ok {
 		dbID, err := r.db.UpsertInstance(ctx, instanceID)
@@ -121,7 +242,7 @@ func (r *IdentityResolver) Resolve(ctx context.Context, instanceID, sysName stri
 		SystemName:   sysName,
 		Sysid:        sysid,
 	}
-	r.cache[key] = id
+	r.put(instanceID, sysName, id, "resolved")
 
 	r.log.Info().
 		Str("instance_id", instanceID).
@@ -133,6 +254,70 @@ func (r *IdentityResolver) Resolve(ctx context.Context, instanceID, sysName stri
 	return id, nil
 }
 
+// resolveExisting resolves a sys_name for an instance that may not
+// auto-create: an existing site (e.g. one an admin approved) is used;
+// otherwise the pair is staged for approval and ErrIdentityPending returned.
+// Lookups and staging are throttled to one per pendingRecheck per pair. The
+// caller holds r.mu for writing.
+func (r *IdentityResolver) resolveExisting(ctx context.Context, key, instanceID, sysName string) (*ResolvedIdentity, error) {
+	pc, ok := r.pending[key]
+	if ok && time.Since(pc.checked) < pendingRecheck {
+		pc.hits++
+		return nil, ErrIdentityPending
+	}
+	if !ok {
+		pc = &pendingCheck{}
+		r.pending[key] = pc
+	}
+	hits := pc.hits + 1
+	pc.checked, pc.hits = time.Now(), 0
+
+	systemID, siteID, sysid, err := r.db.FindSiteIdentity(ctx, instanceID, sysName)
+	if err == nil {
+		delete(r.pending, key)
+		id := &ResolvedIdentity{
+			SystemID:   systemID,
+			SiteID:     siteID,
+			SystemName: sysName,
+			Sysid:      sysid,
+		}
+		r.put(instanceID, sysName, id, "approved")
+		r.log.Info().
+			Str("instance_id", instanceID).
+			Str("sys_name", sysName).
+			Int("system_id", systemID).
+			Msg("approved identity resolved and cached")
+		return id, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("find site %q/%q: %w", instanceID, sysName, err)
+	}
+
+	if err := r.db.StagePendingIdentity(ctx, instanceID, sysName, hits, time.Now()); err != nil {
+		return nil, fmt.Errorf("stage pending identity %q/%q: %w", instanceID, sysName, err)
+	}
+	if !ok {
+		r.log.Warn().
+			Str("instance_id", instanceID).
+			Str("sys_name", sysName).
+			Msg("instance may not auto-create systems; sys_name staged for approval")
+	}
+	return nil, ErrIdentityPending
+}
+
+// Lookup returns the cached identity for instanceID/sysName without creating
+// anything.
+func (r *IdentityResolver) Lookup(instanceID, sysName string) (*ResolvedIdentity, bool) {
+	key := instanceID + ":" + sysName
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	id, ok := r.cache[key]
+	if ok {
+		r.hit(key)
+	}
+	return id, ok
+}
+
 // GetSystemIDForSysName returns the system_id for a given sys_name from any instance.
 // Returns 0 if not found.
 func (r *IdentityResolver) GetSystemIDForSysName(sysName string) int {
@@ -183,3 +368,72 @@ func (r *IdentityResolver) RewriteSystemID(oldSystemID, newSystemID int) {
 		}
 	}
 }
+
+// Entries returns a snapshot of the identity cache, ordered by instance and
+// sys_name.
+func (r *IdentityResolver) Entries() []IdentityEntry {
+	r.mu.RLock()
+	out := make([]IdentityEntry, 0, len(r.cache))
+	for key, id := range r.cache {
+		e := IdentityEntry{
+			SystemID: id.SystemID,
+			SiteID:   id.SiteID,
+			Sysid:    id.Sysid,
+		}
+		if st := r.stats[key]; st != nil {
+			e.InstanceID, e.SysName = st.instanceID, st.sysName
+			e.Source, e.CachedAt = st.source, st.cachedAt
+			e.Hits = st.hits.Load()
+			if n := st.lastHit.Load(); n > 0 {
+				e.LastHit = time.Unix(0, n)
+			}
+		} else {
+			e.InstanceID, e.SysName, _ = strings.Cut(key, ":")
+		}
+		out = append(out, e)
+	}
+	r.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].InstanceID != out[j].InstanceID {
+			return out[i].InstanceID < out[j].InstanceID
+		}
+		return out[i].SysName < out[j].SysName
+	})
+	return out
+}
+
+// Invalidate drops cache entries so the next message re-resolves them from
+// the database: every entry when instanceID is empty, an instance's entries
+// when sysName is empty, else the one pair. Returns how many were dropped.
+func (r *IdentityResolver) Invalidate(instanceID, sysName string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for key := range r.cache {
+		inst, name := "", ""
+		if st := r.stats[key]; st != nil {
+			inst, name = st.instanceID, st.sysName
+		} else {
+			inst, name, _ = strings.Cut(key, ":")
+		}
+		if instanceID != "" && (inst != instanceID || (sysName != "" && name != sysName)) {
+			continue
+		}
+		delete(r.cache, key)
+		delete(r.stats, key)
+		delete(r.pending, key)
+		n++
+	}
+	if instanceID == "" {
+		r.pending = make(map[string]*pendingCheck)
+	}
+	if n > 0 {
+		r.log.Info().
+			Str("instance_id", instanceID).
+			Str("sys_name", sysName).
+			Int("entries", n).
+			Msg("identity cache invalidated")
+	}
+	return n
+}
@@ -1,7 +1,10 @@
 package ingest
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/rs/zerolog"
 )
@@ -12,6 +15,8 @@ func newTestResolver(entries map[string]*ResolvedIdentity) *IdentityResolver {
 		log:       zerolog.Nop(),
 		cache:     make(map[string]*ResolvedIdentity),
 		instances: make(map[string]int),
+		policies:  make(map[string]bool),
+		pending:   make(map[string]*pendingCheck),
 	}
 	for k, v := range entries {
 		r.cache[k] = v
@@ -114,3 +119,82 @@ func TestRewriteSystemID(t *testing.T) {
 		}
 	})
 }
+
+func TestMayAutoCreate(t *testing.T) {
+	r := newTestResolver(nil)
+	r.policies["trusted"] = true
+	r.policies["uploader"] = false
+
+	if !r.mayAutoCreate("other") {
+		t.Error("default allow: other should be allowed")
+	}
+	if r.mayAutoCreate("uploader") {
+		t.Error("uploader policy denies, but was allowed")
+	}
+
+	r.SetDenyAutoCreate(true)
+	if r.mayAutoCreate("other") {
+		t.Error("default deny: other should be denied")
+	}
+	if !r.mayAutoCreate("trusted") {
+		t.Error("trusted policy allows, but was denied")
+	}
+}
+
+func TestResolve_PendingThrottled(t *testing.T) {
+	r := newTestResolver(map[string]*ResolvedIdentity{
+		"tr-1:butco": {SystemID: 10, SiteID: 1, SystemName: "butco"},
+	})
+	r.SetDenyAutoCreate(true)
+	// Checked moments ago: answered from memory, no DB lookup.
+	r.pending["tr-1:junk"] = &pendingCheck{checked: time.Now()}
+
+	if _, err := r.Resolve(context.Background(), "tr-1", "junk"); !errors.Is(err, ErrIdentityPending) {
+		t.Fatalf("err = %v, want ErrIdentityPending", err)
+	}
+	if got := r.pending["tr-1:junk"].hits; got != 1 {
+		t.Errorf("hits = %d, want 1", got)
+	}
+	// Known identities still resolve from the cache.
+	if id, err := r.Resolve(context.Background(), "tr-1", "butco"); err != nil || id.SystemID != 10 {
+		t.Errorf("Resolve(butco) = %+v, %v", id, err)
+	}
+}
+
+func TestIdentityEntriesAndInvalidate(t *testing.T) {
+	r := newTestResolver(map[string]*ResolvedIdentity{
+		"tr-2:warco": {SystemID: 2, SiteID: 3, SystemName: "warco"}, // no stats
+	})
+	r.put("tr-1", "butco", &ResolvedIdentity{SystemID: 1, SiteID: 1, SystemName: "butco"}, "loaded")
+	r.put("tr-1", "hamco", &ResolvedIdentity{SystemID: 4, SiteID: 2, SystemName: "hamco"}, "resolved")
+
+	for i := 0; i < 3; i++ {
+		if _, err := r.Resolve(context.Background(), "tr-1", "butco"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries := r.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("len(entries) = %d, want 3", len(entries))
+	}
+	if e := entries[0]; e.InstanceID != "tr-1" || e.SysName != "butco" || e.Hits != 3 || e.Source != "loaded" || e.LastHit.IsZero() {
+		t.Errorf("entries[0] = %+v", e)
+	}
+	if e := entries[1]; e.SysName != "hamco" || e.Hits != 0 || !e.LastHit.IsZero() {
+		t.Errorf("entries[1] = %+v", e)
+	}
+	if e := entries[2]; e.InstanceID != "tr-2" || e.SysName != "warco" {
+		t.Errorf("entries[2] = %+v", e)
+	}
+
+	if n := r.Invalidate("tr-1", "butco"); n != 1 {
+		t.Errorf("Invalidate(tr-1, butco) = %d, want 1", n)
+	}
+	if n := r.Invalidate("tr-1", ""); n != 1 {
+		t.Errorf("Invalidate(tr-1) = %d, want 1", n)
+	}
+	if n := r.Invalidate("", ""); n != 1 || r.CacheLen() != 0 {
+		t.Errorf("Invalidate(all) = %d, cache len %d", n, r.CacheLen())
+	}
+}
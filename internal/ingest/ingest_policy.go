@@ -0,0 +1,57 @@
+package ingest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// ingestPolicies caches systems.ingest_policy for the ingest hot path.
+type ingestPolicies struct {
+	mu       sync.RWMutex
+	policies map[int]string // systems that aren't full
+}
+
+func (ip *ingestPolicies) set(m map[int]string) {
+	ip.mu.Lock()
+	ip.policies = m
+	ip.mu.Unlock()
+}
+
+// get returns a system's ingest policy; full when it has none.
+func (ip *ingestPolicies) get(systemID int) string {
+	ip.mu.RLock()
+	defer ip.mu.RUnlock()
+	if p, ok := ip.policies[systemID]; ok {
+		return p
+	}
+	return database.IngestPolicyFull
+}
+
+// ReloadIngestPolicies reloads system ingest policies from the database.
+// Called at startup and after admin changes.
+func (p *Pipeline) ReloadIngestPolicies(ctx context.Context) error {
+	m, err := p.db.ListSystemIngestPolicies(ctx)
+	if err != nil {
+		return err
+	}
+	p.ingestPolicies.set(m)
+	return nil
+}
+
+// ingestPolicy returns the ingest policy for a call starting at startTime:
+// the system's, or full when a legal hold covers the call.
+func (p *Pipeline) ingestPolicy(systemID, tgid int, startTime time.Time) string {
+	if p.legalHolds.covers(systemID, tgid, 0, startTime) {
+		return database.IngestPolicyFull
+	}
+	return p.ingestPolicies.get(systemID)
+}
+
+// transcriptOnly reports whether a call's audio is kept only long enough to
+// transcribe it: it is decoded, but never stored or linked to the call.
+func (p *Pipeline) transcriptOnly(systemID, tgid int, startTime time.Time) bool {
+	return p.ingestPolicy(systemID, tgid, startTime) == database.IngestPolicyTranscript
+}
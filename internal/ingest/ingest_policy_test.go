@@ -0,0 +1,45 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+func TestIngestPolicies(t *testing.T) {
+	p := &Pipeline{log: zerolog.Nop()}
+	if got := p.ingestPolicies.get(1); got != database.IngestPolicyFull {
+		t.Errorf("no policies: got %q, want full", got)
+	}
+
+	p.ingestPolicies.set(map[int]string{1: database.IngestPolicyMetadata, 2: database.IngestPolicyTranscript})
+	if !p.audioDropped(1, 100, time.Time{}) || p.transcriptOnly(1, 100, time.Time{}) {
+		t.Error("metadata system should drop audio undecoded")
+	}
+	if p.audioDropped(2, 100, time.Time{}) || !p.transcriptOnly(2, 100, time.Time{}) {
+		t.Error("transcript system should hold audio for transcription only")
+	}
+	if p.audioDropped(3, 100, time.Time{}) || p.transcriptOnly(3, 100, time.Time{}) {
+		t.Error("other systems keep full audio")
+	}
+
+	// A talkgroup storage policy can still drop audio on a transcript system
+	p.storagePolicies.set([]database.StoragePolicy{{SystemID: 2, Tgid: 200, Policy: database.StoragePolicyMetadata}})
+	if !p.audioDropped(2, 200, time.Time{}) {
+		t.Error("metadata talkgroup should drop audio on a transcript system")
+	}
+
+	// A legal hold keeps full audio whatever the policy
+	sys := 1
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	p.legalHolds.set([]database.LegalHold{{Active: true, SystemID: &sys, StartTime: &from}})
+	if p.audioDropped(1, 100, from) {
+		t.Error("held call should keep its audio")
+	}
+	if !p.audioDropped(1, 100, from.Add(-time.Hour)) {
+		t.Error("call before the hold should drop audio")
+	}
+}
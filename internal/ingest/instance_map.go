@@ -0,0 +1,96 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/api"
+	"github.com/snarg/tr-engine/internal/mqttclient"
+)
+
+// instanceMap attributes MQTT messages to TR instances by topic prefix
+// (MQTT_INSTANCE_MAP) and counts what each mapping matched. A nil map has no
+// mappings.
+type instanceMap struct {
+	mappings []mqttclient.InstanceMapping
+	messages []atomic.Int64 // per mapping
+	lastAt   []atomic.Int64 // per mapping, unix nanoseconds
+	unmapped atomic.Int64
+}
+
+func newInstanceMap(mappings []mqttclient.InstanceMapping) *instanceMap {
+	return &instanceMap{
+		mappings: mappings,
+		messages: make([]atomic.Int64, len(mappings)),
+		lastAt:   make([]atomic.Int64, len(mappings)),
+	}
+}
+
+// attribute returns payload with its instance_id set to the instance mapped
+// for topic, and whether a mapping matched.
+func (m *instanceMap) attribute(topic string, payload []byte, now time.Time) ([]byte, bool) {
+	if m == nil || len(m.mappings) == 0 {
+		return payload, false
+	}
+	i := mqttclient.MatchInstance(m.mappings, topic)
+	if i < 0 {
+		m.unmapped.Add(1)
+		return payload, false
+	}
+	m.messages[i].Add(1)
+	m.lastAt[i].Store(now.UnixNano())
+	return setInstanceID(payload, m.mappings[i].InstanceID), true
+}
+
+// snapshot returns the mappings with their counters.
+func (m *instanceMap) snapshot() []api.TopicMappingData {
+	if m == nil {
+		return []api.TopicMappingData{}
+	}
+	out := make([]api.TopicMappingData, len(m.mappings))
+	for i, mapping := range m.mappings {
+		out[i] = api.TopicMappingData{
+			Prefix:     mapping.Prefix,
+			InstanceID: mapping.InstanceID,
+			Messages:   m.messages[i].Load(),
+		}
+		if ns := m.lastAt[i].Load(); ns != 0 {
+			t := time.Unix(0, ns)
+			out[i].LastMessageAt = &t
+		}
+	}
+	return out
+}
+
+// setInstanceID rewrites every instance_id in a JSON object payload to id,
+// adding one at the top level if it has none (e.g. a TR build that omits it).
+func setInstanceID(payload []byte, id string) []byte {
+	if bytes.Contains(payload, []byte(`"instance_id"`)) {
+		return rewriteInstanceID(payload, id)
+	}
+	trimmed := bytes.TrimLeft(payload, " \t\r\n")
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return payload
+	}
+	body := bytes.TrimLeft(trimmed[1:], " \t\r\n")
+	quoted, _ := json.Marshal(id)
+	out := make([]byte, 0, len(payload)+len(quoted)+16)
+	out = append(out, `{"instance_id":`...)
+	out = append(out, quoted...)
+	if len(body) > 0 && body[0] != '}' {
+		out = append(out, ',')
+	}
+	return append(out, body...)
+}
+
+// TopicMap returns the active MQTT topic prefix → instance mapping with
+// per-mapping message counts.
+func (p *Pipeline) TopicMap() *api.TopicMapData {
+	data := &api.TopicMapData{Mappings: p.instanceMap.snapshot()}
+	if p.instanceMap != nil {
+		data.UnmappedMessages = p.instanceMap.unmapped.Load()
+	}
+	return data
+}
@@ -0,0 +1,49 @@
+package ingest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/mqttclient"
+)
+
+func TestInstanceMapAttribute(t *testing.T) {
+	m := newInstanceMap(mqttclient.ParseInstanceMap("site-a:tr-a,county/site-b:tr-b"))
+	now := time.Now()
+
+	payload, ok := m.attribute("site-a/trunk_recorder/status", []byte(`{"type":"status","instance_id":"trunk-recorder"}`), now)
+	if !ok || string(payload) != `{"type":"status","instance_id":"tr-a"}` {
+		t.Errorf("rewrite: %s, %v", payload, ok)
+	}
+	payload, ok = m.attribute("county/site-b/feeds/call_start", []byte(` {"type":"call_start"}`), now)
+	var env Envelope
+	if !ok || json.Unmarshal(payload, &env) != nil || env.InstanceID != "tr-b" || env.Type != "call_start" {
+		t.Errorf("inject: %s, %v", payload, ok)
+	}
+	if payload, ok = m.attribute("site-c/feeds/call_start", []byte(`{"instance_id":"x"}`), now); ok || string(payload) != `{"instance_id":"x"}` {
+		t.Errorf("unmapped: %s, %v", payload, ok)
+	}
+
+	snap := m.snapshot()
+	if len(snap) != 2 || snap[0].Prefix != "county/site-b" || snap[0].Messages != 1 || snap[1].Messages != 1 ||
+		snap[1].LastMessageAt == nil || !snap[1].LastMessageAt.Equal(time.Unix(0, now.UnixNano())) {
+		t.Errorf("snapshot = %+v", snap)
+	}
+	if n := m.unmapped.Load(); n != 1 {
+		t.Errorf("unmapped = %d, want 1", n)
+	}
+}
+
+func TestSetInstanceID(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{`{}`, `{"instance_id":"a\"b"}`},
+		{`{ "x": 1}`, `{"instance_id":"a\"b","x": 1}`},
+		{`[1]`, `[1]`},
+	}
+	for _, tt := range tests {
+		if got := string(setInstanceID([]byte(tt.in), `a"b`)); got != tt.want {
+			t.Errorf("setInstanceID(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// interconnectGap is how long an interconnect call may go without a grant or
+// grant update before the next grant for the same unit starts a new call.
+// Sites repeat grant updates every few seconds while the channel is up.
+const interconnectGap = 15 * time.Second
+
+// interconnectGrant is a telephone interconnect channel grant (or grant
+// update) decoded from a trunking message.
+type interconnectGrant struct {
+	Unit int   // requesting/target radio; 0 if the message didn't carry it
+	Freq int64 // channel frequency in Hz; 0 if unknown
+}
+
+var (
+	interconnectUnitRe = regexp.MustCompile(`(?i)\b(?:source|src|unit|radio)(?:[ _]?id)?\b\W{0,3}(\d+)`)
+	interconnectFreqRe = regexp.MustCompile(`(?i)\bfreq(?:uency)?\b\W{0,3}(\d+(?:\.\d+)?)`)
+)
+
+// parseInterconnectGrant recognizes telephone interconnect voice channel
+// grants and grant updates (P25 TELE_INT_CH_GRANT / TELE_INT_CH_GRANT_UPDT,
+// or any message described as an interconnect grant) and extracts the unit
+// and frequency from the message's meta. Meta may be a JSON object
+// ({"source": 1234, "freq": 851012500}) or free text ("source: 1234
+// freq: 851.0125"); either field may be missing.
+func parseInterconnectGrant(data TrunkingMessageData) (interconnectGrant, bool) {
+	opType := strings.ToUpper(data.OpcodeType)
+	desc := strings.ToLower(data.OpcodeDesc)
+	isGrant := strings.Contains(opType, "TELE_INT") && strings.Contains(opType, "GRANT") ||
+		strings.Contains(desc, "interconnect") && (strings.Contains(desc, "grant") || strings.Contains(desc, "update"))
+	if !isGrant {
+		return interconnectGrant{}, false
+	}
+
+	var g interconnectGrant
+	var obj map[string]any
+	if json.Unmarshal([]byte(data.Meta), &obj) == nil {
+		for _, k := range []string{"source", "src", "unit", "unit_id", "source_id", "radio_id"} {
+			if v, ok := metaNumber(obj[k]); ok && v > 0 {
+				g.Unit = int(v)
+				break
+			}
+		}
+		for _, k := range []string{"freq", "frequency"} {
+			if v, ok := metaNumber(obj[k]); ok && v > 0 {
+				g.Freq = normalizeFreq(v)
+				break
+			}
+		}
+		return g, true
+	}
+
+	text := data.Meta + " " + data.OpcodeDesc
+	if m := interconnectUnitRe.FindStringSubmatch(text); m != nil {
+		g.Unit, _ = strconv.Atoi(m[1])
+	}
+	if m := interconnectFreqRe.FindStringSubmatch(text); m != nil {
+		if v, err := strconv.ParseFloat(m[1], 64); err == nil {
+			g.Freq = normalizeFreq(v)
+		}
+	}
+	return g, true
+}
+
+// metaNumber reads a JSON number or numeric string.
+func metaNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// normalizeFreq converts a frequency given in MHz to Hz; values already in Hz
+// are returned unchanged.
+func normalizeFreq(v float64) int64 {
+	if v < 10000 {
+		v *= 1e6
+	}
+	return int64(v + 0.5)
+}
+
+// interconnectKey identifies an interconnect call in progress: by unit when
+// the grant carries one, otherwise by channel.
+type interconnectKey struct {
+	SystemID int
+	UnitID   int
+	Freq     int64
+}
+
+type interconnectEntry struct {
+	CallID    int64
+	StartTime time.Time
+	LastSeen  time.Time
+	Recorded  bool // TR recorded the call; only the flag was set
+}
+
+// interconnectMap tracks interconnect calls in progress so repeated grant
+// updates extend one call instead of creating many.
+type interconnectMap struct {
+	mu    sync.Mutex
+	items map[interconnectKey]*interconnectEntry
+}
+
+func newInterconnectMap() *interconnectMap {
+	return &interconnectMap{items: make(map[interconnectKey]*interconnectEntry)}
+}
+
+// EvictStale removes calls not seen for longer than interconnectGap.
+func (m *interconnectMap) EvictStale(now time.Time) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	evicted := 0
+	for k, e := range m.items {
+		if now.Sub(e.LastSeen) > interconnectGap {
+			delete(m.items, k)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// trackInterconnect records an interconnect grant seen at ts. If TR is
+// recording a call on the granted channel, that call is flagged; otherwise a
+// call is created from the grant (TR doesn't record interconnect calls by
+// default, so without this they never appear). Later grant updates extend the
+// call's stop time.
+func (p *Pipeline) trackInterconnect(instanceID, sysName string, systemID int, g interconnectGrant, ts time.Time) {
+	if g.Unit == 0 && g.Freq == 0 {
+		return
+	}
+	key := interconnectKey{SystemID: systemID, UnitID: g.Unit}
+	if g.Unit == 0 {
+		key.Freq = g.Freq
+	}
+
+	ctx, cancel := context.WithTimeout(p.ctx, 5*time.Second)
+	defer cancel()
+
+	m := p.interconnects
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.items[key]; ok && ts.Sub(e.LastSeen) <= interconnectGap {
+		if !ts.After(e.LastSeen) {
+			return
+		}
+		e.LastSeen = ts
+		if !e.Recorded {
+			if err := p.db.ExtendInterconnectCall(ctx, e.CallID, e.StartTime, ts); err != nil {
+				p.log.Warn().Err(err).Int64("call_id", e.CallID).Msg("failed to extend interconnect call")
+			}
+		}
+		return
+	}
+
+	if g.Freq > 0 {
+		if ac, ok := p.activeCalls.FindByFreq(g.Freq); ok && ac.SystemID == systemID {
+			if err := p.db.MarkCallInterconnect(ctx, ac.CallID, ac.StartTime, g.Unit); err != nil {
+				p.log.Warn().Err(err).Int64("call_id", ac.CallID).Msg("failed to mark call as interconnect")
+				return
+			}
+			m.items[key] = &interconnectEntry{CallID: ac.CallID, StartTime: ac.StartTime, LastSeen: ts, Recorded: true}
+			p.log.Debug().Int64("call_id", ac.CallID).Int("unit", g.Unit).Msg("recorded call marked as interconnect")
+			return
+		}
+	}
+	if g.Unit == 0 {
+		return // nothing to attribute the call to
+	}
+
+	identity, err := p.identity.Resolve(ctx, instanceID, sysName)
+	if err != nil {
+		p.log.Warn().Err(err).Str("sys_name", sysName).Msg("failed to resolve identity for interconnect call")
+		return
+	}
+	siteID := identity.SiteID
+	row := &database.InterconnectCallRow{
+		SystemID:      systemID,
+		SiteID:        &siteID,
+		UnitID:        g.Unit,
+		StartTime:     ts,
+		SystemName:    sysName,
+		SiteShortName: sysName,
+		InstanceID:    instanceID,
+	}
+	if g.Freq > 0 {
+		row.Freq = &g.Freq
+	}
+	callID, err := p.db.InsertInterconnectCall(ctx, row)
+	if err != nil && strings.Contains(err.Error(), "no partition") {
+		p.ensurePartitionsFor(ts)
+		callID, err = p.db.InsertInterconnectCall(ctx, row)
+	}
+	if err != nil {
+		p.log.Warn().Err(err).Int("unit", g.Unit).Msg("failed to insert interconnect call")
+		return
+	}
+	m.items[key] = &interconnectEntry{CallID: callID, StartTime: ts, LastSeen: ts}
+	p.log.Debug().Int64("call_id", callID).Int("unit", g.Unit).Str("sys_name", sysName).Msg("interconnect call started")
+}
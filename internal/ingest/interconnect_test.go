@@ -0,0 +1,71 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInterconnectGrant(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   TrunkingMessageData
+		wantOK bool
+		want   interconnectGrant
+	}{
+		{
+			name:   "p25 grant with json meta",
+			data:   TrunkingMessageData{OpcodeType: "TELE_INT_CH_GRANT", Meta: `{"source": 1234, "freq": 851012500}`},
+			wantOK: true,
+			want:   interconnectGrant{Unit: 1234, Freq: 851012500},
+		},
+		{
+			name:   "grant update with string values in MHz",
+			data:   TrunkingMessageData{OpcodeType: "TELE_INT_CH_GRANT_UPDT", Meta: `{"unit": "42", "frequency": "851.0125"}`},
+			wantOK: true,
+			want:   interconnectGrant{Unit: 42, Freq: 851012500},
+		},
+		{
+			name:   "described grant with text meta",
+			data:   TrunkingMessageData{OpcodeDesc: "Telephone Interconnect Voice Channel Grant", Meta: "Source ID: 7001 Freq: 852.3375"},
+			wantOK: true,
+			want:   interconnectGrant{Unit: 7001, Freq: 852337500},
+		},
+		{
+			name:   "grant without meta",
+			data:   TrunkingMessageData{OpcodeType: "TELE_INT_CH_GRANT"},
+			wantOK: true,
+		},
+		{
+			name: "answer request is not a grant",
+			data: TrunkingMessageData{OpcodeType: "TELE_INT_ANS_REQ", OpcodeDesc: "Telephone Interconnect Answer Request"},
+		},
+		{
+			name: "group voice grant",
+			data: TrunkingMessageData{OpcodeType: "GRP_V_CH_GRANT", OpcodeDesc: "Group Voice Channel Grant", Meta: `{"source": 1234}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseInterconnectGrant(tt.data)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if got != tt.want {
+				t.Errorf("grant = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterconnectMapEvictStale(t *testing.T) {
+	now := time.Now()
+	m := newInterconnectMap()
+	m.items[interconnectKey{SystemID: 1, UnitID: 1}] = &interconnectEntry{LastSeen: now.Add(-time.Minute)}
+	m.items[interconnectKey{SystemID: 1, UnitID: 2}] = &interconnectEntry{LastSeen: now.Add(-time.Second)}
+	if n := m.EvictStale(now); n != 1 {
+		t.Errorf("evicted %d, want 1", n)
+	}
+	if _, ok := m.items[interconnectKey{SystemID: 1, UnitID: 2}]; !ok {
+		t.Error("recent entry was evicted")
+	}
+}
@@ -0,0 +1,315 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ISSI/DFSI gateway ingest.
+//
+// A P25 ISSI (inter-RF-subsystem) or DFSI (fixed station) gateway reports
+// each finished call as one JSON record, over HTTP (POST
+// /api/v1/call-upload/issi) or MQTT (topic ending in /issi_call). Records are
+// mapped onto the same call path as HTTP uploads, so gateway traffic and
+// trunk-recorder traffic land in one archive: with MERGE_P25_SYSTEMS the
+// gateway's system merges with trunk-recorder's by (sysid, wacn), and a call
+// both already recorded is rejected as a duplicate.
+//
+// Talkgroups and units may be given as plain IDs (group_id, unit_id) or as
+// fully qualified P25 identities in hex: an SGID (WACN 20 bits + System ID
+// 12 bits + group 16 bits) or an SUID (WACN + System ID + unit 24 bits).
+// The SGID's WACN/System ID name the talkgroup's home system, which the call
+// is filed under unless wacn/sysid are given. Units roaming in from another
+// system are stored under that system by their 24-bit unit ID.
+
+// ISSICallRecord is one call reported by an ISSI/DFSI gateway.
+type ISSICallRecord struct {
+	GatewayID  string `json:"gateway_id"` // instance_id; defaults to the upload instance
+	Protocol   string `json:"protocol"`   // "issi" (default) or "dfsi"
+	CallID     string `json:"call_id"`    // gateway's own call ID, for logs
+	SystemName string `json:"system_name"`
+	WACN       string `json:"wacn"`  // hex
+	Sysid      string `json:"sysid"` // hex P25 System ID
+	RFSSID     int    `json:"rfss_id"`
+	SiteID     int    `json:"site_id"`
+	NAC        string `json:"nac"` // hex, DFSI
+
+	SGID      string `json:"sgid"`
+	GroupID   int    `json:"group_id"`
+	GroupName string `json:"group_name"`
+	SUID      string `json:"suid"`
+	UnitID    int    `json:"unit_id"`
+	UnitAlias string `json:"unit_alias"`
+
+	StartTime   issiTime `json:"start_time"`
+	EndTime     issiTime `json:"end_time"`
+	Duration    float64  `json:"duration"` // seconds
+	Frequency   float64  `json:"frequency"`
+	Emergency   bool     `json:"emergency"`
+	Encrypted   bool     `json:"encrypted"`
+	AlgorithmID int      `json:"algorithm_id"` // 0x80 = clear
+
+	Transmissions []ISSITransmission `json:"transmissions"`
+}
+
+// ISSITransmission is one unit's transmission within a call.
+type ISSITransmission struct {
+	SUID      string   `json:"suid"`
+	UnitID    int      `json:"unit_id"`
+	UnitAlias string   `json:"unit_alias"`
+	StartTime issiTime `json:"start_time"`
+	Emergency bool     `json:"emergency"`
+}
+
+// issiTime accepts an RFC 3339 string or unix time in seconds (or
+// milliseconds, when larger than 1e12).
+type issiTime struct{ time.Time }
+
+func (t *issiTime) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("null")) {
+		return nil
+	}
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		if s == "" {
+			return nil
+		}
+		v, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return err
+		}
+		t.Time = v
+		return nil
+	}
+	n, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return fmt.Errorf("time must be RFC 3339 or unix seconds: %s", b)
+	}
+	if n > 1e12 {
+		t.Time = time.UnixMilli(int64(n))
+	} else if n > 0 {
+		t.Time = time.Unix(int64(n), 0)
+	}
+	return nil
+}
+
+// p25ID is a parsed fully qualified P25 identity.
+type p25ID struct {
+	Wacn, Sysid string
+	ID          int
+}
+
+// parseP25ID splits a hex SGID (idBits 16) or SUID (idBits 24).
+func parseP25ID(s string, idBits uint) (p25ID, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), "0x"), "0X")
+	v, err := strconv.ParseUint(s, 16, 64)
+	if err != nil || v>>(idBits+32) != 0 {
+		return p25ID{}, fmt.Errorf("invalid P25 identity %q", s)
+	}
+	return p25ID{
+		Wacn:  fmt.Sprintf("%X", v>>(idBits+12)),
+		Sysid: fmt.Sprintf("%X", (v>>idBits)&0xFFF),
+		ID:    int(v & (1<<idBits - 1)),
+	}, nil
+}
+
+// normalizeHex formats a hex identifier the way trunk-recorder does
+// (uppercase, no prefix or leading zeros). Empty stays empty.
+func normalizeHex(s string) (string, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), "0x"), "0X")
+	if s == "" {
+		return "", nil
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return "", fmt.Errorf("invalid hex value %q", s)
+	}
+	return fmt.Sprintf("%X", v), nil
+}
+
+// unitOf returns the 24-bit unit ID from a plain ID or an SUID.
+func unitOf(unitID int, suid string) (int, error) {
+	if suid == "" {
+		return unitID, nil
+	}
+	id, err := parseP25ID(suid, 24)
+	if err != nil {
+		return 0, err
+	}
+	return id.ID, nil
+}
+
+// ParseISSICall decodes and maps a gateway call record. It returns the call
+// metadata and the system the call belongs to.
+func ParseISSICall(data []byte) (*ISSICallRecord, *AudioMetadata, *SystemInfoData, error) {
+	var rec ISSICallRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, nil, nil, err
+	}
+
+	sys := &SystemInfoData{SysName: rec.SystemName, Type: "p25", RFSS: rec.RFSSID, SiteID: rec.SiteID}
+	switch rec.Protocol {
+	case "", "issi":
+	case "dfsi":
+		sys.Type = "conventionalP25"
+	default:
+		return nil, nil, nil, fmt.Errorf("protocol must be issi or dfsi")
+	}
+	var err error
+	if sys.Wacn, err = normalizeHex(rec.WACN); err != nil {
+		return nil, nil, nil, fmt.Errorf("wacn: %w", err)
+	}
+	if sys.Sysid, err = normalizeHex(rec.Sysid); err != nil {
+		return nil, nil, nil, fmt.Errorf("sysid: %w", err)
+	}
+	if sys.Nac, err = normalizeHex(rec.NAC); err != nil {
+		return nil, nil, nil, fmt.Errorf("nac: %w", err)
+	}
+
+	tgid := rec.GroupID
+	if rec.SGID != "" {
+		g, err := parseP25ID(rec.SGID, 16)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("sgid: %w", err)
+		}
+		tgid = g.ID
+		if sys.Wacn == "" && sys.Sysid == "" {
+			sys.Wacn, sys.Sysid = g.Wacn, g.Sysid
+		}
+	}
+	if tgid <= 0 {
+		return nil, nil, nil, errors.New("group_id or sgid is required")
+	}
+	if sys.SysName == "" {
+		if sys.Wacn == "" || sys.Sysid == "" {
+			return nil, nil, nil, errors.New("system_name is required without wacn/sysid or sgid")
+		}
+		sys.SysName = "p25-" + strings.ToLower(sys.Wacn+"-"+sys.Sysid)
+	}
+	if rec.StartTime.IsZero() {
+		return nil, nil, nil, errors.New("start_time is required")
+	}
+
+	start := rec.StartTime.Time
+	dur := rec.Duration
+	if dur <= 0 && rec.EndTime.After(start) {
+		dur = rec.EndTime.Sub(start).Seconds()
+	}
+	meta := &AudioMetadata{
+		ShortName:    sys.SysName,
+		Talkgroup:    tgid,
+		TalkgroupTag: rec.GroupName,
+		StartTime:    start.Unix(),
+		CallLength:   int(dur + 0.5),
+		Freq:         rec.Frequency,
+	}
+	if dur > 0 {
+		meta.StopTime = start.Add(time.Duration(dur * float64(time.Second))).Unix()
+	}
+	if rec.Emergency {
+		meta.Emergency = 1
+	}
+	if rec.Encrypted || (rec.AlgorithmID != 0 && rec.AlgorithmID != 0x80) {
+		meta.Encrypted = 1
+	}
+
+	for _, tx := range rec.Transmissions {
+		unit, err := unitOf(tx.UnitID, tx.SUID)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("transmission suid: %w", err)
+		}
+		if unit <= 0 {
+			continue
+		}
+		at := tx.StartTime.Time
+		if at.IsZero() {
+			at = start
+		}
+		item := SrcItem{Src: unit, Time: at.Unix(), Pos: at.Sub(start).Seconds(), Tag: tx.UnitAlias}
+		if tx.Emergency {
+			item.Emergency = 1
+		}
+		meta.SrcList = append(meta.SrcList, item)
+	}
+	if len(meta.SrcList) == 0 {
+		unit, err := unitOf(rec.UnitID, rec.SUID)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("suid: %w", err)
+		}
+		if unit > 0 {
+			meta.SrcList = []SrcItem{{Src: unit, Time: start.Unix(), Tag: rec.UnitAlias, Emergency: meta.Emergency}}
+		}
+	}
+	return &rec, meta, sys, nil
+}
+
+// defaultISSIInstance is the instance_id for records that name no gateway.
+const defaultISSIInstance = "issi-gateway"
+
+// prepareISSICall parses a gateway record and registers its system. It
+// returns the instance to file the call under (gateway_id, else instanceID)
+// and the call metadata.
+func (p *Pipeline) prepareISSICall(instanceID string, data []byte) (string, *AudioMetadata, error) {
+	rec, meta, sys, err := ParseISSICall(data)
+	if err != nil {
+		return "", nil, err
+	}
+	if rec.GatewayID != "" {
+		instanceID = rec.GatewayID
+	}
+	if instanceID == "" {
+		instanceID = defaultISSIInstance
+	}
+	p.registerISSISystem(instanceID, sys)
+	return instanceID, meta, nil
+}
+
+// ProcessISSICall ingests one gateway call record (no audio). instanceID is
+// used when the record has no gateway_id.
+func (p *Pipeline) ProcessISSICall(ctx context.Context, instanceID string, data []byte) (*UploadResult, error) {
+	instanceID, meta, err := p.prepareISSICall(instanceID, data)
+	if err != nil {
+		return nil, fmt.Errorf("parse issi call: %w", err)
+	}
+	return p.processCompleteCall(ctx, instanceID, meta, nil, "", "issi")
+}
+
+// registerISSISystem records the gateway system's P25 identity (and merges
+// it into an existing system with the same sysid/wacn) the first time each
+// identity is seen.
+func (p *Pipeline) registerISSISystem(instanceID string, sys *SystemInfoData) {
+	key := fmt.Sprintf("%s|%s|%s|%s|%s|%d|%d", instanceID, sys.SysName, sys.Wacn, sys.Sysid, sys.Nac, sys.RFSS, sys.SiteID)
+	if _, seen := p.issiSystems.LoadOrStore(key, struct{}{}); seen {
+		return
+	}
+	if err := p.processSystemInfo(instanceID, sys); err != nil {
+		p.issiSystems.Delete(key)
+		p.log.Warn().Err(err).Str("sys_name", sys.SysName).Msg("failed to register issi system")
+	}
+}
+
+// handleISSICall handles an ISSI/DFSI call record received over MQTT.
+func (p *Pipeline) handleISSICall(payload []byte) error {
+	var env Envelope
+	_ = json.Unmarshal(payload, &env)
+
+	ctx, cancel := context.WithTimeout(p.ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := p.ProcessISSICall(ctx, env.InstanceID, payload)
+	if err != nil && strings.Contains(err.Error(), "duplicate call") {
+		// Already recorded by trunk-recorder (or redelivered)
+		p.log.Debug().Err(err).Msg("issi call already archived")
+		return nil
+	}
+	return err
+}
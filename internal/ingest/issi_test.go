@@ -0,0 +1,101 @@
+package ingest
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseISSICall(t *testing.T) {
+	// SGID BEE00-348-1234: WACN BEE00, System ID 348, group 0x1234
+	// SUID BEE00-348-0E1A26: unit 0x0E1A26 = 924198
+	rec, meta, sys, err := ParseISSICall([]byte(`{
+		"gateway_id": "issi-gw1",
+		"sgid": "0xbee003481234",
+		"group_name": "Fire Dispatch",
+		"rfss_id": 2, "site_id": 7,
+		"start_time": "2026-03-01T12:00:00Z",
+		"end_time": "2026-03-01T12:00:09.6Z",
+		"frequency": 851012500,
+		"algorithm_id": 128,
+		"transmissions": [
+			{"suid": "BEE003480E1A26", "unit_alias": "E12", "start_time": "2026-03-01T12:00:00Z"},
+			{"unit_id": 104, "start_time": 1772366405, "emergency": true}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.GatewayID != "issi-gw1" {
+		t.Errorf("gateway_id = %q", rec.GatewayID)
+	}
+	if sys.Wacn != "BEE00" || sys.Sysid != "348" || sys.Type != "p25" || sys.RFSS != 2 || sys.SiteID != 7 {
+		t.Errorf("system = %+v", sys)
+	}
+	if sys.SysName != "p25-bee00-348" || meta.ShortName != sys.SysName {
+		t.Errorf("sys_name = %q / %q", sys.SysName, meta.ShortName)
+	}
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if meta.Talkgroup != 0x1234 || meta.TalkgroupTag != "Fire Dispatch" || meta.StartTime != start.Unix() {
+		t.Errorf("talkgroup %d tag %q start %d", meta.Talkgroup, meta.TalkgroupTag, meta.StartTime)
+	}
+	if meta.CallLength != 10 || meta.StopTime != start.Unix()+9 || meta.Freq != 851012500 {
+		t.Errorf("length %d stop %d freq %v", meta.CallLength, meta.StopTime, meta.Freq)
+	}
+	if meta.Encrypted != 0 {
+		t.Error("algorithm 0x80 is clear")
+	}
+	if len(meta.SrcList) != 2 {
+		t.Fatalf("srcList = %+v", meta.SrcList)
+	}
+	if s := meta.SrcList[0]; s.Src != 0x0E1A26 || s.Tag != "E12" || s.Pos != 0 {
+		t.Errorf("src[0] = %+v", s)
+	}
+	if s := meta.SrcList[1]; s.Src != 104 || s.Pos != 5 || s.Emergency != 1 {
+		t.Errorf("src[1] = %+v", s)
+	}
+}
+
+func TestParseISSICall_DFSI(t *testing.T) {
+	_, meta, sys, err := ParseISSICall([]byte(`{
+		"protocol": "dfsi", "system_name": "county-fs", "nac": "0x293",
+		"group_id": 1, "unit_id": 5001, "unit_alias": "Base",
+		"start_time": 1772366400, "duration": 4.2, "algorithm_id": 170
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sys.Type != "conventionalP25" || sys.Nac != "293" || sys.SysName != "county-fs" {
+		t.Errorf("system = %+v", sys)
+	}
+	if meta.Encrypted != 1 {
+		t.Error("algorithm 0xAA should mark the call encrypted")
+	}
+	if len(meta.SrcList) != 1 || meta.SrcList[0].Src != 5001 || meta.SrcList[0].Tag != "Base" {
+		t.Errorf("srcList = %+v", meta.SrcList)
+	}
+	if meta.CallLength != 4 {
+		t.Errorf("call_length = %d", meta.CallLength)
+	}
+}
+
+func TestParseISSICall_Errors(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"start_time":1772366400,"system_name":"x"}`, "group_id or sgid is required"},
+		{`{"group_id":1,"start_time":1772366400}`, "system_name is required"},
+		{`{"group_id":1,"system_name":"x"}`, "start_time is required"},
+		{`{"sgid":"nothex","start_time":1772366400}`, "sgid"},
+		{`{"sgid":"1BEE003481234","start_time":1772366400}`, "invalid P25 identity"},
+		{`{"protocol":"p25","group_id":1,"system_name":"x","start_time":1772366400}`, "protocol"},
+		{`{"group_id":1,"system_name":"x","start_time":"yesterday"}`, "cannot parse"},
+	}
+	for _, tt := range tests {
+		_, _, _, err := ParseISSICall([]byte(tt.body))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.body, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package ingest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// legalHolds caches active legal holds for the ingest hot path.
+type legalHolds struct {
+	mu    sync.RWMutex
+	holds []database.LegalHold
+}
+
+func (lh *legalHolds) set(list []database.LegalHold) {
+	lh.mu.Lock()
+	lh.holds = list
+	lh.mu.Unlock()
+}
+
+// covers reports whether an active hold covers a call.
+func (lh *legalHolds) covers(systemID, tgid int, callID int64, startTime time.Time) bool {
+	lh.mu.RLock()
+	defer lh.mu.RUnlock()
+	for i := range lh.holds {
+		if lh.holds[i].Covers(systemID, tgid, callID, startTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReloadLegalHolds reloads active legal holds from the database. Called at
+// startup and after admin changes.
+func (p *Pipeline) ReloadLegalHolds(ctx context.Context) error {
+	list, err := p.db.ListLegalHolds(ctx, true)
+	if err != nil {
+		return err
+	}
+	p.legalHolds.set(list)
+	return nil
+}
@@ -0,0 +1,65 @@
+package ingest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/loadgen"
+)
+
+// Synthetic load-test traffic must route and validate like real TR traffic,
+// or a load test would only measure quarantine inserts.
+func TestLoadgenTrafficValidates(t *testing.T) {
+	g := loadgen.New(loadgen.Config{
+		Systems:       2,
+		Talkgroups:    20,
+		Units:         50,
+		CallsPerDay:   86400 * 2,
+		TrunkingRate:  10,
+		UnitEventRate: 5,
+		Audio:         true,
+		Seed:          1,
+	})
+	now := time.Now()
+	msgs := g.Start(now)
+	for i := 0; i < 600; i++ {
+		now = now.Add(100 * time.Millisecond)
+		msgs = append(msgs, g.Tick(now)...)
+	}
+
+	kinds := map[string]int{}
+	for _, m := range msgs {
+		route := ParseTopic(m.Topic)
+		if route == nil || route.Handler != m.Kind {
+			t.Fatalf("%s routes to %+v, want %s", m.Topic, route, m.Kind)
+		}
+		if problems := validatePayload(route, m.Topic, m.Payload, now); len(problems) > 0 {
+			t.Fatalf("%s: %v\n%s", m.Topic, problems, m.Payload)
+		}
+		kinds[m.Kind]++
+	}
+	for _, k := range []string{"status", "systems", "rates", "call_start", "call_end", "audio", "unit_event", "trunking_message"} {
+		if kinds[k] == 0 {
+			t.Errorf("no %s messages generated", k)
+		}
+	}
+	if kinds["call_end"] != kinds["audio"] {
+		t.Errorf("%d call_end but %d audio", kinds["call_end"], kinds["audio"])
+	}
+
+	// Audio carries a decodable WAV and the call's metadata
+	for _, m := range msgs {
+		if m.Kind != "audio" {
+			continue
+		}
+		var a AudioMsg
+		if err := json.Unmarshal(m.Payload, &a); err != nil {
+			t.Fatal(err)
+		}
+		if a.Call.Metadata.ShortName == "" || len(a.Call.Metadata.SrcList) == 0 || len(a.Call.AudioWavBase64) < 100 {
+			t.Errorf("audio message incomplete: %+v", a.Call.Metadata)
+		}
+		break
+	}
+}
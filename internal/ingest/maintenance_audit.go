@@ -0,0 +1,223 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/api"
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+const (
+	maintenanceTriggerSchedule = "schedule"
+	maintenanceTriggerManual   = "manual"
+	maintenanceTriggerDisk     = "disk_usage" // AUDIO_DISK_MAX_PERCENT exceeded
+
+	// maintenanceAuditRetention is how long audit entries are kept.
+	maintenanceAuditRetention = 90 * 24 * time.Hour
+)
+
+// Maintenance modes, reported on runs and GET /admin/maintenance.
+const (
+	maintenanceModeEnforce = "enforce"
+	maintenanceModeDryRun  = "dry_run" // MAINTENANCE_DRY_RUN or a requested dry run
+	maintenanceModeObserve = "observe" // within MAINTENANCE_OBSERVE_CYCLES
+)
+
+// maintenanceConfig is the configured maintenance mode and task toggles.
+// Admin overrides in maintenance_task_settings take precedence per task.
+type maintenanceConfig struct {
+	dryRun        bool            // MAINTENANCE_DRY_RUN
+	observeCycles int             // MAINTENANCE_OBSERVE_CYCLES
+	disabled      map[string]bool // MAINTENANCE_DISABLED_TASKS
+}
+
+func newMaintenanceConfig(dryRun bool, observeCycles int, disabledTasks []string) maintenanceConfig {
+	cfg := maintenanceConfig{dryRun: dryRun, observeCycles: observeCycles, disabled: make(map[string]bool)}
+	for _, t := range disabledTasks {
+		cfg.disabled[t] = true
+	}
+	return cfg
+}
+
+// maintenanceMode returns the mode of the next scheduled run and the number
+// of scheduled dry runs completed so far.
+func (p *Pipeline) maintenanceMode(ctx context.Context) (string, int, error) {
+	if p.maintenanceCfg.dryRun {
+		return maintenanceModeDryRun, 0, nil
+	}
+	if p.maintenanceCfg.observeCycles <= 0 {
+		return maintenanceModeEnforce, 0, nil
+	}
+	observed, err := p.db.CountObservedMaintenanceRuns(ctx)
+	if err != nil {
+		return maintenanceModeObserve, 0, err
+	}
+	if observed < p.maintenanceCfg.observeCycles {
+		return maintenanceModeObserve, observed, nil
+	}
+	return maintenanceModeEnforce, observed, nil
+}
+
+// maintenanceTasks returns the effective state of each destructive task:
+// the admin override if there is one, else MAINTENANCE_DISABLED_TASKS.
+func (p *Pipeline) maintenanceTasks(ctx context.Context) ([]api.MaintenanceTaskStatus, error) {
+	settings, err := p.db.ListMaintenanceTaskSettings(ctx)
+	tasks := make([]api.MaintenanceTaskStatus, 0, len(database.MaintenanceTasks))
+	for _, task := range database.MaintenanceTasks {
+		st := api.MaintenanceTaskStatus{Task: task, Enabled: !p.maintenanceCfg.disabled[task], Source: "config"}
+		if s, ok := settings[task]; ok {
+			st.Enabled, st.DryRun, st.Source = s.Enabled, s.DryRun, "admin"
+		}
+		tasks = append(tasks, st)
+	}
+	return tasks, err
+}
+
+// maintenanceModeStatus fills the mode and task state of a status report.
+func (p *Pipeline) maintenanceModeStatus(ctx context.Context, status *api.MaintenanceStatusData) {
+	var err error
+	status.Mode, status.ObservedCycles, err = p.maintenanceMode(ctx)
+	if err != nil {
+		p.log.Warn().Err(err).Msg("failed to count observed maintenance runs")
+	}
+	status.ObserveCycles = p.maintenanceCfg.observeCycles
+	if status.Tasks, err = p.maintenanceTasks(ctx); err != nil {
+		p.log.Warn().Err(err).Msg("failed to load maintenance task settings")
+	}
+}
+
+// maintenanceRun is the audit state of one maintenance run.
+type maintenanceRun struct {
+	p      *Pipeline
+	ctx    context.Context
+	id     int64 // maintenance_runs.id; 0 if the run couldn't be recorded
+	dryRun bool
+	tasks  map[string]api.MaintenanceTaskStatus
+	result *api.MaintenanceRunData
+	log    zerolog.Logger
+}
+
+// startMaintenanceRun records a run and decides its mode. If the run can't
+// be recorded, its destructive steps are skipped: nothing is deleted without
+// an audit entry.
+func (p *Pipeline) startMaintenanceRun(ctx context.Context, trigger string, forceDryRun bool, result *api.MaintenanceRunData, log zerolog.Logger) *maintenanceRun {
+	mode, _, err := p.maintenanceMode(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to count observed maintenance runs")
+	}
+	if forceDryRun {
+		mode = maintenanceModeDryRun
+	}
+	run := &maintenanceRun{
+		p:      p,
+		ctx:    ctx,
+		dryRun: mode != maintenanceModeEnforce,
+		tasks:  make(map[string]api.MaintenanceTaskStatus),
+		result: result,
+		log:    log,
+	}
+	tasks, err := p.maintenanceTasks(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to load maintenance task settings, using configured tasks")
+	}
+	for _, t := range tasks {
+		run.tasks[t.Task] = t
+		if !t.Enabled {
+			result.SkippedTasks = append(result.SkippedTasks, t.Task)
+		}
+	}
+	result.Mode = mode
+	result.DryRun = run.dryRun
+	result.Observed = make(map[string]int64)
+
+	if run.id, err = p.db.StartMaintenanceRun(ctx, trigger, run.dryRun); err != nil {
+		log.Error().Err(err).Msg("failed to record maintenance run, destructive steps skipped")
+	}
+	result.RunID = run.id
+	if run.dryRun {
+		log.Info().Str("mode", mode).Int64("run_id", run.id).Msg("maintenance dry run: destructive steps are only recorded")
+	}
+	return run
+}
+
+// audited runs one destructive action under the write-ahead audit. preview
+// counts what apply would delete and is recorded first; apply runs only when
+// neither the run nor the task is a dry run. Returns the rows apply changed
+// and applied true, or the previewed rows and applied false in a dry run.
+// A disabled task does nothing.
+func (r *maintenanceRun) audited(task, target string, preview func() (database.MaintenancePreview, error), apply func() (int64, error)) (n int64, applied bool, err error) {
+	st := r.tasks[task]
+	if !st.Enabled {
+		return 0, false, nil
+	}
+	if r.id == 0 {
+		return 0, false, fmt.Errorf("%s skipped: maintenance run not recorded", task)
+	}
+	prev, err := preview()
+	if err != nil {
+		return 0, false, fmt.Errorf("preview: %w", err)
+	}
+
+	dryRun := r.dryRun || st.DryRun
+	status := database.MaintenanceAuditPlanned
+	if dryRun {
+		status = database.MaintenanceAuditObserved
+	}
+	auditID, err := r.p.db.InsertMaintenanceAudit(r.ctx, r.id, task, target, status, prev)
+	if err != nil {
+		return 0, false, fmt.Errorf("write audit: %w", err)
+	}
+	if dryRun {
+		r.result.Observed[task+":"+target] = prev.Rows
+		evt := r.log.Info().Str("maintenance_task", task).Str("target", target).Int64("rows", prev.Rows)
+		if prev.RangeStart != nil {
+			evt = evt.Time("range_start", *prev.RangeStart)
+		}
+		if prev.RangeEnd != nil {
+			evt = evt.Time("range_end", *prev.RangeEnd)
+		}
+		evt.Msg("maintenance dry run: would delete")
+		return prev.Rows, false, nil
+	}
+
+	n, err = apply()
+	status, errMsg := database.MaintenanceAuditApplied, ""
+	if err != nil {
+		status, errMsg = database.MaintenanceAuditFailed, err.Error()
+	}
+	if ferr := r.p.db.FinishMaintenanceAudit(r.ctx, auditID, status, n, errMsg); ferr != nil {
+		r.log.Warn().Err(ferr).Int64("audit_id", auditID).Msg("failed to record maintenance outcome")
+	}
+	return n, true, err
+}
+
+// finish marks the run complete.
+func (r *maintenanceRun) finish() {
+	if r.id == 0 {
+		return
+	}
+	if err := r.p.db.FinishMaintenanceRun(r.ctx, r.id); err != nil {
+		r.log.Warn().Err(err).Int64("run_id", r.id).Msg("failed to record maintenance run end")
+	}
+}
+
+// partitionPreview summarizes the partitions a drop would remove: estimated
+// rows, the newest upper bound, and the partitions themselves.
+func partitionPreview(parts []database.WeeklyPartition) database.MaintenancePreview {
+	var prev database.MaintenancePreview
+	if len(parts) == 0 {
+		return prev
+	}
+	for i := range parts {
+		prev.Rows += parts[i].EstimatedRows
+		if prev.RangeEnd == nil || parts[i].UpperBound.After(*prev.RangeEnd) {
+			prev.RangeEnd = &parts[i].UpperBound
+		}
+	}
+	prev.Detail = parts
+	return prev
+}
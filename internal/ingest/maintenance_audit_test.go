@@ -0,0 +1,72 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/api"
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+func TestPartitionPreview(t *testing.T) {
+	if prev := partitionPreview(nil); prev.Rows != 0 || prev.RangeEnd != nil || prev.Detail != nil {
+		t.Errorf("empty preview = %+v", prev)
+	}
+
+	older := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+	newer := older.AddDate(0, 0, 7)
+	prev := partitionPreview([]database.WeeklyPartition{
+		{Name: "mqtt_raw_messages_w02", UpperBound: newer, EstimatedRows: 1200},
+		{Name: "mqtt_raw_messages_w01", UpperBound: older, EstimatedRows: 800},
+	})
+	if prev.Rows != 2000 {
+		t.Errorf("Rows = %d, want 2000", prev.Rows)
+	}
+	if prev.RangeEnd == nil || !prev.RangeEnd.Equal(newer) {
+		t.Errorf("RangeEnd = %v, want %v", prev.RangeEnd, newer)
+	}
+}
+
+func TestMaintenanceRunAuditedSkips(t *testing.T) {
+	called := false
+	preview := func() (database.MaintenancePreview, error) {
+		called = true
+		return database.MaintenancePreview{Rows: 5}, nil
+	}
+	apply := func() (int64, error) {
+		called = true
+		return 5, nil
+	}
+	run := &maintenanceRun{
+		tasks: map[string]api.MaintenanceTaskStatus{
+			database.MaintenanceTaskPurge:      {Task: database.MaintenanceTaskPurge, Enabled: false},
+			database.MaintenanceTaskStaleCalls: {Task: database.MaintenanceTaskStaleCalls, Enabled: true},
+		},
+		result: &api.MaintenanceRunData{Observed: map[string]int64{}},
+		log:    zerolog.Nop(),
+	}
+
+	// Disabled task: nothing runs, no error.
+	n, applied, err := run.audited(database.MaintenanceTaskPurge, "console_messages", preview, apply)
+	if n != 0 || applied || err != nil || called {
+		t.Errorf("disabled task: n=%d applied=%v err=%v called=%v", n, applied, err, called)
+	}
+
+	// Enabled task without a recorded run: skipped with an error.
+	n, applied, err = run.audited(database.MaintenanceTaskStaleCalls, "calls", preview, apply)
+	if n != 0 || applied || err == nil || called {
+		t.Errorf("unrecorded run: n=%d applied=%v err=%v called=%v", n, applied, err, called)
+	}
+}
+
+func TestNewMaintenanceConfig(t *testing.T) {
+	cfg := newMaintenanceConfig(false, 3, []string{database.MaintenanceTaskPartitionDrop})
+	if !cfg.disabled[database.MaintenanceTaskPartitionDrop] || cfg.disabled[database.MaintenanceTaskPurge] {
+		t.Errorf("disabled = %v", cfg.disabled)
+	}
+	if cfg.observeCycles != 3 {
+		t.Errorf("observeCycles = %d, want 3", cfg.observeCycles)
+	}
+}
@@ -239,6 +239,23 @@ type ConfigData struct {
 	LogFile      json.RawMessage `json:"log_file"` // bool or string in different TR versions
 	InstanceID   string          `json:"instance_id"`
 	InstanceKey  string          `json:"instance_key"`
+	Sources      []ConfigSource  `json:"sources"`
+}
+
+// ConfigSource is an SDR source in the config message. Frequencies are in Hz.
+type ConfigSource struct {
+	SourceNum        int             `json:"source_num"`
+	Rate             float64         `json:"rate"`
+	Center           float64         `json:"center"`
+	MinHz            float64         `json:"min_hz"`
+	MaxHz            float64         `json:"max_hz"`
+	Error            float64         `json:"error"`
+	Driver           string          `json:"driver"`
+	Device           string          `json:"device"`
+	Antenna          string          `json:"antenna"`
+	Gain             json.RawMessage `json:"gain"` // number or string depending on driver
+	AnalogRecorders  int             `json:"analog_recorders"`
+	DigitalRecorders int             `json:"digital_recorders"`
 }
 
 // SystemInfoData represents a system entry from the systems/system topics.
@@ -251,6 +268,8 @@ type SystemInfoData struct {
 	Nac     string `json:"nac"`
 	RFSS    int    `json:"rfss"`
 	SiteID  int    `json:"site_id"`
+
+	ControlChannel float64 `json:"control_channel,omitempty"` // Hz or MHz; not sent by every plugin version
 }
 
 // SystemsMsg wraps a systems (batch) message.
@@ -0,0 +1,120 @@
+package ingest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/snarg/tr-engine/pkg/events"
+)
+
+// Occupancy states.
+const (
+	occupancyActive = "active"
+	occupancyIdle   = "idle"
+)
+
+// occupancyTracker remembers which talkgroups were last published active, so
+// occupancy events are only sent on transitions.
+type occupancyTracker struct {
+	mu     sync.Mutex
+	active map[tgKey]bool
+}
+
+type tgKey struct {
+	systemID int
+	tgid     int
+}
+
+func newOccupancyTracker() *occupancyTracker {
+	return &occupancyTracker{active: make(map[tgKey]bool)}
+}
+
+// transition records a talkgroup's state and reports whether an occupancy
+// event should be sent: on becoming active, and on every idle (an idle
+// talkgroup's last call time still moved).
+func (t *occupancyTracker) transition(key tgKey, active bool) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if active {
+		if t.active[key] {
+			return false
+		}
+		t.active[key] = true
+		return true
+	}
+	delete(t.active, key)
+	return true
+}
+
+// countTalkgroup returns the number of in-progress calls on a talkgroup.
+func (m *activeCallMap) countTalkgroup(systemID, tgid int) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, e := range m.calls {
+		if e.SystemID == systemID && e.Tgid == tgid {
+			n++
+		}
+	}
+	return n
+}
+
+// encryptedTalkgroup reports whether a talkgroup has an encrypted call in
+// progress.
+func (m *activeCallMap) encryptedTalkgroup(systemID, tgid int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.calls {
+		if e.SystemID == systemID && e.Tgid == tgid && e.Encrypted {
+			return true
+		}
+	}
+	return false
+}
+
+// publishOccupancy follows a call_start or call_end event with an occupancy
+// event when its talkgroup goes active or idle. The active call map has
+// already been updated for the call.
+func (p *Pipeline) publishOccupancy(e EventData) {
+	if p.occupancy == nil || e.SystemID == 0 || e.Tgid == 0 {
+		return
+	}
+	occ := events.Occupancy{
+		SystemID:    e.SystemID,
+		Tgid:        e.Tgid,
+		ActiveCalls: p.activeCalls.countTalkgroup(e.SystemID, e.Tgid),
+		Emergency:   e.Emergency,
+	}
+	switch c := e.Payload.(type) {
+	case *events.CallStart:
+		occ.TgAlphaTag, occ.CallID, occ.Since = c.TgAlphaTag, c.CallID, c.StartTime
+	case *events.CallEnd:
+		occ.TgAlphaTag, occ.CallID, occ.Since = c.TgAlphaTag, c.CallID, c.StopTime
+		if occ.Since.IsZero() {
+			occ.Since = time.Now()
+		}
+	default:
+		return
+	}
+	occ.State = occupancyIdle
+	if occ.ActiveCalls > 0 {
+		occ.State = occupancyActive
+	}
+	// A call_end on a talkgroup other sites still carry changes nothing.
+	if e.Type == events.TypeCallEnd && occ.State == occupancyActive {
+		return
+	}
+	if !p.occupancy.transition(tgKey{e.SystemID, e.Tgid}, occ.State == occupancyActive) {
+		return
+	}
+	p.eventBus.Publish(EventData{
+		Type:       events.TypeOccupancy,
+		SystemID:   e.SystemID,
+		SiteID:     e.SiteID,
+		Tgid:       e.Tgid,
+		Emergency:  e.Emergency,
+		Restricted: e.Restricted,
+		Embargo:    e.Embargo,
+		Payload:    &occ,
+	})
+}
@@ -0,0 +1,59 @@
+package ingest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/api"
+	"github.com/snarg/tr-engine/pkg/events"
+)
+
+func TestPublishOccupancy(t *testing.T) {
+	bus := NewEventBus(16)
+	p := &Pipeline{log: zerolog.Nop(), eventBus: bus, activeCalls: newActiveCallMap(), occupancy: newOccupancyTracker()}
+	ch, cancel := bus.Subscribe(api.EventFilter{Types: []string{events.TypeOccupancy}})
+	defer cancel()
+
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	callStart := func(trID string, callID int64) {
+		p.activeCalls.Set(trID, activeCallEntry{CallID: callID, SystemID: 1, Tgid: 9044, StartTime: start})
+		p.PublishEvent(EventData{Type: "call_start", SystemID: 1, Tgid: 9044,
+			Payload: &events.CallStart{CallID: callID, SystemID: 1, Tgid: 9044, TgAlphaTag: "FD Dispatch", StartTime: start}})
+	}
+	callEnd := func(trID string, callID int64) {
+		p.activeCalls.Delete(trID)
+		p.PublishEvent(EventData{Type: "call_end", SystemID: 1, Tgid: 9044,
+			Payload: &events.CallEnd{CallID: callID, SystemID: 1, Tgid: 9044, StopTime: start.Add(time.Minute)}})
+	}
+	next := func() *events.Occupancy {
+		t.Helper()
+		select {
+		case e := <-ch:
+			var o events.Occupancy
+			if err := json.Unmarshal(e.Data, &o); err != nil {
+				t.Fatal(err)
+			}
+			return &o
+		case <-time.After(100 * time.Millisecond):
+			return nil
+		}
+	}
+
+	// Two sites carry the talkgroup: active once, idle once both end.
+	callStart("a", 1)
+	if o := next(); o == nil || o.State != "active" || o.ActiveCalls != 1 || o.CallID != 1 || !o.Since.Equal(start) {
+		t.Fatalf("first call_start: %+v", o)
+	}
+	callStart("b", 2)
+	callEnd("a", 1)
+	if o := next(); o != nil {
+		t.Fatalf("unexpected occupancy %+v", o)
+	}
+	callEnd("b", 2)
+	if o := next(); o == nil || o.State != "idle" || o.ActiveCalls != 0 || o.CallID != 2 || !o.Since.Equal(start.Add(time.Minute)) {
+		t.Fatalf("last call_end: %+v", o)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -15,8 +17,10 @@ import (
 	"github.com/snarg/tr-engine/internal/audio"
 	"github.com/snarg/tr-engine/internal/database"
 	"github.com/snarg/tr-engine/internal/metrics"
+	"github.com/snarg/tr-engine/internal/mqttclient"
 	"github.com/snarg/tr-engine/internal/storage"
 	"github.com/snarg/tr-engine/internal/transcribe"
+	"github.com/snarg/tr-engine/pkg/events"
 )
 
 // Pipeline processes incoming MQTT messages from trunk-recorder.
@@ -39,6 +43,12 @@ type Pipeline struct {
 	// Unit affiliation tracking: (system_id, unit_id) → current talkgroup
 	affiliations *affiliationMap
 
+	// Telephone interconnect calls in progress, from control channel grants
+	interconnects *interconnectMap
+
+	// Current control channel per site (control_channel.go)
+	controlChannels *controlChannels
+
 	// Event bus for SSE subscribers
 	eventBus *EventBus
 
@@ -50,13 +60,72 @@ type Pipeline struct {
 	rawStore   bool            // false = disable all raw archival
 	rawInclude map[string]bool // if non-empty, allowlist mode (only these handlers)
 	rawExclude map[string]bool // if non-empty, denylist mode (skip these handlers)
+	rawRedact  RawRedactRules  // per-handler path stripping and size caps
 
-	// MQTT instance_id rewrite: topic prefix → override instance_id
-	instancePrefixMap map[string]string
+	// MQTT instance attribution by topic prefix (MQTT_INSTANCE_MAP)
+	instanceMap *instanceMap
 
 	// P25 system merging
 	mergeP25Systems bool // when false, systems with same sysid/wacn stay separate
 
+	// Payload validation: "quarantine" (reject + store), "log" (warn only), "off"
+	validationMode string
+
+	// API response cache invalidation hook (nil if caching disabled)
+	invalidateCache func(tags ...string)
+
+	// Saved-audio duration check tolerance (0 = disabled)
+	durationTolerance time.Duration
+	// Correct duration/stop_time from the measured audio beyond this (0 = off)
+	durationCorrect time.Duration
+
+	// Empty/silent recording detection (AUDIO_UNUSABLE_CHECK)
+	unusableCheck  bool
+	silenceLevel   float64
+	unusableDelete bool
+
+	// Stuck microphone detection (disabled when STUCK_MIC_MIN_DURATION is 0)
+	stuckMic *stuckMicTracker
+
+	// Talkgroups last published active (occupancy events)
+	occupancy *occupancyTracker
+
+	// Recently published emergencies (emergency fast path)
+	emergencies *emergencyTracker
+
+	// Split call merging (disabled when SPLIT_CALL_MAX_GAP is 0)
+	splitCallMaxGap time.Duration
+
+	// FILENAME_PATTERNS for audio uploaded or watched without metadata JSON
+	filenamePatterns []*FilenamePattern
+
+	// Per-system/talkgroup encrypted traffic policies (suppress, restricted)
+	encryptionPolicies encryptionPolicies
+
+	// Per-talkgroup audio storage policies (transcode, metadata-only)
+	storagePolicies storagePolicies
+
+	// Per-talkgroup listener delays for non-admin event subscribers
+	embargoes embargoes
+
+	// Per-system ingest policies (transcript-only, metadata-only)
+	ingestPolicies ingestPolicies
+
+	// Active legal holds (override storage and ingest policies, block audio deletion)
+	legalHolds legalHolds
+
+	// Enrichment hooks run on call_end (nil in tests)
+	enrichment *enrichmentHooks
+
+	// Alert rules checked against new transcripts (nil in tests)
+	alertRules *alertRules
+
+	// Talkgroups/units already checked for first-heard discovery events
+	discoveries discoveries
+
+	// ISSI/DFSI gateway system identities already registered
+	issiSystems sync.Map
+
 	// Transcription worker pool (optional, nil if WHISPER_URL not set)
 	transcriber          *transcribe.WorkerPool
 	transcribeIncludeTGs map[string]bool // allowlist: "tgid" or "systemID:tgid"
@@ -79,6 +148,12 @@ type Pipeline struct {
 	// Unit event dedup buffer: unitDedupKey → time.Time (first seen)
 	unitEventDedup sync.Map
 
+	// Recently handled MQTT audio messages; nil when AUDIO_DEDUP_WINDOW is 0
+	audioDedup *audioDedup
+
+	// ProcessMessage: wait between handler retries while the database is down
+	ackRetryInterval time.Duration
+
 	// Warmup gate: buffer non-identity messages until system registration
 	// establishes real sysid/wacn, preventing duplicate system creation
 	// when calls arrive before system info on fresh start.
@@ -92,11 +167,13 @@ type Pipeline struct {
 
 	msgCount     atomic.Int64
 	handlerCount sync.Map // handler name → *atomic.Int64
+	callCount    atomic.Int64 // call_end events published
 
 	// Maintenance state
 	maintenanceRunning atomic.Bool
 	lastMaintenance    atomic.Pointer[api.MaintenanceRunData]
 	retentionCfg       retentionConfig
+	maintenanceCfg     maintenanceConfig
 }
 
 // retentionConfig holds configurable retention durations for maintenance tasks.
@@ -106,6 +183,17 @@ type retentionConfig struct {
 	PluginStatus time.Duration
 	Checkpoints  time.Duration
 	StaleCalls   time.Duration
+	InactiveUnits time.Duration // 0 = disabled
+	Quarantine   time.Duration
+	ExternalEvents time.Duration // 0 = kept forever
+	WebhookDeliveries time.Duration // 0 = kept forever
+	Timeseries   time.Duration // 0 = time series collection disabled
+	UnitEvents   time.Duration // 0 = kept forever; clamped to the session compaction watermark
+	UnitSessions time.Duration // 0 = kept forever
+	Calls        time.Duration // 0 = kept forever
+	CallAudio    time.Duration // 0 = kept until the call is purged
+	AudioDiskMaxPercent int // purge the oldest audio above this disk usage (0 = off)
+	KeepPinned   bool // audio and call purges skip pinned calls
 }
 
 // bufferedMsg holds a message deferred during warmup.
@@ -124,22 +212,56 @@ type PipelineOptions struct {
 	RawStore         bool
 	RawIncludeTopics string
 	RawExcludeTopics string
+	RawRedact        RawRedactRules // per-handler raw archive redaction; audio base64 is always stripped
 	MergeP25Systems    bool   // auto-merge systems with same sysid/wacn (default true)
+	DenyAutoCreate     bool   // instances without a policy may not auto-create systems/sites
 	MQTTInstanceMap    string // "prefix:instance_id,prefix:instance_id"
+	IngestValidation   string // "quarantine" (default), "log", or "off"
+	InvalidateCache    func(tags ...string) // drops cached API responses by tag; nil = no API cache
 	TranscribeOpts     *transcribe.WorkerPoolOptions // nil = transcription disabled
 	TranscribeInclude  string // comma-separated TGID allowlist for transcription
 	TranscribeExclude  string // comma-separated TGID denylist for transcription
+	AudioDurationTolerance time.Duration // flag calls whose audio differs from call_length by more; 0 = don't measure
+	AudioDurationCorrect   time.Duration // replace duration with the measured audio length beyond this; 0 = off
+	AudioUnusableCheck     bool          // flag empty or silent recordings and don't transcribe them
+	AudioSilenceLevel      float64       // dBFS a recording must reach somewhere not to be silent
+	AudioUnusableDelete    bool          // remove unusable recordings from local disk
+	StuckMicMinDuration       time.Duration // flag calls keyed by one unit for this long; 0 = disabled
+	StuckMicMaxSpeechRatio    float64       // flagged calls with more speech than this are unflagged
+	StuckMicSkipTranscription bool          // don't transcribe confirmed stuck mic calls
+	SplitCallMaxGap           time.Duration // merge a call into the same unit's call on the tgid that stopped this soon before; 0 = disabled
+	AudioDedupWindow          time.Duration // skip repeated MQTT audio messages seen this recently; 0 = disabled
+	MQTTAckRetryInterval      time.Duration // ProcessMessage retry wait while the database is unreachable (default 5s)
+	FilenamePatterns          []*FilenamePattern // derive metadata for audio with no JSON; nil = disabled
 	// Configurable retention durations for maintenance tasks
 	RetentionRawMessages  time.Duration
 	RetentionConsoleLogs  time.Duration
 	RetentionPluginStatus time.Duration
 	RetentionCheckpoints  time.Duration
 	RetentionStaleCalls   time.Duration
+	RetentionInactiveUnits time.Duration // archive units not seen in this long (0 = disabled)
+	RetentionQuarantine    time.Duration
+	RetentionExternalEvents time.Duration // external events posted to /external-events (0 = keep forever)
+	RetentionWebhookDeliveries time.Duration // webhook delivery log, by queue time (0 = keep forever)
+	RetentionTimeseries    time.Duration // 1-minute internal counter snapshots (0 = don't collect)
+	RetentionUnitEvents    time.Duration // raw unit events already folded into sessions (0 = keep forever)
+	RetentionUnitSessions  time.Duration // unit sessions, by end time (0 = keep forever)
+	RetentionCalls         time.Duration // calls and their child rows (0 = keep forever)
+	RetentionCallAudio     time.Duration // call audio files on local disk (0 = keep until the call is purged)
+	AudioDiskMaxPercent    int           // delete the oldest call audio above this AUDIO_DIR disk usage (0 = off)
+	AudioRetentionKeepPinned bool        // audio and call purges skip pinned calls
+	// Maintenance dry-run/observe mode and per-task toggles
+	MaintenanceDryRun        bool
+	MaintenanceObserveCycles int
+	MaintenanceDisabledTasks []string
 	// Live audio streaming
 	StreamListen      string
 	StreamIdleTimeout time.Duration
 	StreamOpusBitrate int // 0 = PCM passthrough, >0 = Opus bitrate in bps
-	Log               zerolog.Logger
+	// EventSink receives every published event, e.g. for the Kafka/NATS
+	// bridge. Must not block. nil = none.
+	EventSink func(api.SSEEvent)
+	Log       zerolog.Logger
 }
 
 func NewPipeline(opts PipelineOptions) *Pipeline {
@@ -166,17 +288,28 @@ func NewPipeline(opts PipelineOptions) *Pipeline {
 		}
 		log.Info().Strs("handlers", names).Msg("raw message archival excluded for handlers")
 	}
+	if rawStore && len(opts.RawRedact) > 0 {
+		names := make([]string, 0, len(opts.RawRedact))
+		for h := range opts.RawRedact {
+			names = append(names, h)
+		}
+		log.Info().Strs("handlers", names).Msg("raw message redaction rules active")
+	}
+
+	if opts.MQTTAckRetryInterval <= 0 {
+		opts.MQTTAckRetryInterval = 5 * time.Second
+	}
 
 	if !opts.MergeP25Systems {
 		log.Info().Msg("P25 system auto-merge disabled (MERGE_P25_SYSTEMS=false)")
 	}
 
 	// Parse MQTT_INSTANCE_MAP: "prefix:instance_id,prefix:instance_id"
-	instancePrefixMap := parseInstanceMap(opts.MQTTInstanceMap)
-	if len(instancePrefixMap) > 0 {
-		pairs := make([]string, 0, len(instancePrefixMap))
-		for prefix, id := range instancePrefixMap {
-			pairs = append(pairs, prefix+"→"+id)
+	instanceMappings := mqttclient.ParseInstanceMap(opts.MQTTInstanceMap)
+	if len(instanceMappings) > 0 {
+		pairs := make([]string, 0, len(instanceMappings))
+		for _, m := range instanceMappings {
+			pairs = append(pairs, m.Prefix+"→"+m.InstanceID)
 		}
 		log.Info().Strs("mappings", pairs).Msg("MQTT instance_id rewrite active")
 	}
@@ -198,7 +331,19 @@ func NewPipeline(opts PipelineOptions) *Pipeline {
 		log.Info().Strs("tgids", ids).Msg("transcription talkgroup denylist active")
 	}
 
+	validationMode := opts.IngestValidation
+	if validationMode == "" {
+		validationMode = "quarantine"
+	}
+	if validationMode != "quarantine" {
+		log.Info().Str("mode", validationMode).Msg("ingest payload quarantine disabled")
+	}
+
 	identity := NewIdentityResolver(opts.DB, log)
+	if opts.DenyAutoCreate {
+		identity.SetDenyAutoCreate(true)
+		log.Info().Msg("system auto-create denied by default (INGEST_AUTO_CREATE=deny)")
+	}
 
 	// Only create audio streaming infrastructure if STREAM_LISTEN is configured
 	var audioBus *audio.AudioBus
@@ -220,8 +365,25 @@ func NewPipeline(opts PipelineOptions) *Pipeline {
 		rawStore:          rawStore,
 		rawInclude:        rawInclude,
 		rawExclude:        rawExclude,
-		instancePrefixMap: instancePrefixMap,
+		rawRedact:         opts.RawRedact,
+		instanceMap:       newInstanceMap(instanceMappings),
 		mergeP25Systems:   opts.MergeP25Systems,
+		validationMode:    validationMode,
+		invalidateCache:   opts.InvalidateCache,
+		durationTolerance: opts.AudioDurationTolerance,
+		durationCorrect:   opts.AudioDurationCorrect,
+		unusableCheck:     opts.AudioUnusableCheck,
+		silenceLevel:      opts.AudioSilenceLevel,
+		unusableDelete:    opts.AudioUnusableDelete,
+		stuckMic:          newStuckMicTracker(opts.StuckMicMinDuration, opts.StuckMicMaxSpeechRatio, opts.StuckMicSkipTranscription),
+		occupancy:         newOccupancyTracker(),
+		emergencies:       newEmergencyTracker(),
+		enrichment:        newEnrichmentHooks(),
+		alertRules:        newAlertRules(),
+		splitCallMaxGap:   opts.SplitCallMaxGap,
+		audioDedup:        newAudioDedup(opts.AudioDedupWindow),
+		ackRetryInterval:  opts.MQTTAckRetryInterval,
+		filenamePatterns:  opts.FilenamePatterns,
 		transcribeIncludeTGs: transcribeInclude,
 		transcribeExcludeTGs: transcribeExclude,
 		retentionCfg: retentionConfig{
@@ -230,20 +392,37 @@ func NewPipeline(opts PipelineOptions) *Pipeline {
 			PluginStatus: opts.RetentionPluginStatus,
 			Checkpoints:  opts.RetentionCheckpoints,
 			StaleCalls:   opts.RetentionStaleCalls,
+			InactiveUnits: opts.RetentionInactiveUnits,
+			Quarantine:   opts.RetentionQuarantine,
+			ExternalEvents: opts.RetentionExternalEvents,
+			WebhookDeliveries: opts.RetentionWebhookDeliveries,
+			Timeseries:   opts.RetentionTimeseries,
+			UnitEvents:   opts.RetentionUnitEvents,
+			UnitSessions: opts.RetentionUnitSessions,
+			Calls:        opts.RetentionCalls,
+			CallAudio:    opts.RetentionCallAudio,
+			AudioDiskMaxPercent: opts.AudioDiskMaxPercent,
+			KeepPinned:   opts.AudioRetentionKeepPinned,
 		},
+		maintenanceCfg: newMaintenanceConfig(opts.MaintenanceDryRun, opts.MaintenanceObserveCycles, opts.MaintenanceDisabledTasks),
 		activeCalls:  newActiveCallMap(),
 		affiliations: newAffiliationMap(),
+		interconnects: newInterconnectMap(),
+		controlChannels: newControlChannels(),
 		eventBus:    NewEventBus(4096), // ~60s of events at high rate
 		audioBus:    audioBus,
 		audioRouter: audioRouter,
 		ctx:         ctx,
 		cancel:      cancel,
 	}
+	if audioBus != nil {
+		audioBus.SetRestricted(p.restrictedTalkgroup)
+	}
 
 	// Transcription worker pool (optional)
 	if opts.TranscribeOpts != nil {
 		tOpts := opts.TranscribeOpts
-		tOpts.PublishEvent = func(eventType string, systemID, tgid int, payload map[string]any) {
+		tOpts.PublishEvent = func(eventType string, systemID, tgid int, payload any) {
 			p.PublishEvent(EventData{
 				Type:     eventType,
 				SystemID: systemID,
@@ -257,6 +436,7 @@ func NewPipeline(opts PipelineOptions) *Pipeline {
 	p.rawBatcher = NewBatcher[database.RawMessageRow](100, 2*time.Second, p.flushRawMessages)
 	p.recorderBatcher = NewBatcher[database.RecorderSnapshotRow](100, 2*time.Second, p.flushRecorderSnapshots)
 	p.trunkingBatcher = NewBatcher[database.TrunkingMessageRow](100, 2*time.Second, p.flushTrunkingMessages)
+	p.eventBus.sink = opts.EventSink
 
 	return p
 }
@@ -266,6 +446,30 @@ func (p *Pipeline) Start(ctx context.Context) error {
 	if err := p.identity.LoadCache(ctx); err != nil {
 		return err
 	}
+	if err := p.ReloadEncryptionPolicies(ctx); err != nil {
+		return fmt.Errorf("load encryption policies: %w", err)
+	}
+	if err := p.ReloadStoragePolicies(ctx); err != nil {
+		return fmt.Errorf("load storage policies: %w", err)
+	}
+	if err := p.ReloadEmbargoes(ctx); err != nil {
+		return fmt.Errorf("load talkgroup embargoes: %w", err)
+	}
+	if err := p.ReloadIngestPolicies(ctx); err != nil {
+		return fmt.Errorf("load ingest policies: %w", err)
+	}
+	if err := p.ReloadLegalHolds(ctx); err != nil {
+		return fmt.Errorf("load legal holds: %w", err)
+	}
+	if err := p.ReloadEnrichmentHooks(ctx); err != nil {
+		return fmt.Errorf("load enrichment hooks: %w", err)
+	}
+	if err := p.ReloadAlertRules(ctx); err != nil {
+		return fmt.Errorf("load alert rules: %w", err)
+	}
+	if err := p.ReloadMetricsTalkgroups(ctx); err != nil {
+		return fmt.Errorf("load metrics talkgroups: %w", err)
+	}
 
 	// Skip warmup if identity cache already has entries (not a fresh DB).
 	if p.identity.CacheLen() > 0 {
@@ -288,8 +492,17 @@ func (p *Pipeline) Start(ctx context.Context) error {
 	go p.statsLoop()
 	go p.maintenanceLoop()
 	go p.talkgroupStatsLoop()
+	go p.unitEncryptionRollupLoop()
+	go p.unitCensusRollupLoop()
 	go p.dedupCleanupLoop()
 	go p.affiliationEvictionLoop()
+	go p.externalEventCorrelationLoop()
+	if p.retentionCfg.AudioDiskMaxPercent > 0 {
+		go p.audioDiskLoop()
+	}
+	if p.retentionCfg.Timeseries > 0 {
+		go p.timeseriesLoop()
+	}
 	if p.transcriber != nil {
 		p.transcriber.Start()
 	}
@@ -301,8 +514,8 @@ func (p *Pipeline) Start(ctx context.Context) error {
 }
 
 // StartWatcher creates and starts a file watcher on the given directory.
-func (p *Pipeline) StartWatcher(watchDir, instanceID string, backfillDays int) error {
-	fw := newFileWatcher(p, watchDir, instanceID, backfillDays)
+func (p *Pipeline) StartWatcher(watchDir, instanceID string, backfillDays int, audioOnly bool) error {
+	fw := newFileWatcher(p, watchDir, instanceID, backfillDays, audioOnly)
 	if err := fw.Start(); err != nil {
 		return err
 	}
@@ -310,6 +523,12 @@ func (p *Pipeline) StartWatcher(watchDir, instanceID string, backfillDays int) e
 	return nil
 }
 
+// ReloadIdentityPolicies reloads instance auto-create policies and retries
+// staged identities on their next message. Called after admin changes.
+func (p *Pipeline) ReloadIdentityPolicies(ctx context.Context) error {
+	return p.identity.ReloadPolicies(ctx)
+}
+
 // ResolveIdentity resolves (or auto-creates) the system/site for a given
 // instance ID and system name. Used by TR auto-discovery to resolve system IDs
 // for talkgroup directory import.
@@ -325,6 +544,20 @@ func (p *Pipeline) WatcherStatus() *api.WatcherStatusData {
 	return p.watcher.Status()
 }
 
+// RetryBackfillFailures re-ingests files the backfill report lists as errors.
+func (p *Pipeline) RetryBackfillFailures(ctx context.Context, startDay, endDay *time.Time) (int, error) {
+	if p.watcher == nil {
+		return 0, fmt.Errorf("file watcher not active")
+	}
+	return p.watcher.RetryFailed(ctx, startDay, endDay)
+}
+
+// Transcriber returns the transcription worker pool, or nil if transcription
+// is disabled.
+func (p *Pipeline) Transcriber() *transcribe.WorkerPool {
+	return p.transcriber
+}
+
 // TranscriptionStatus returns the transcription service status.
 func (p *Pipeline) TranscriptionStatus() *api.TranscriptionStatusData {
 	if p.transcriber == nil {
@@ -338,16 +571,16 @@ func (p *Pipeline) TranscriptionStatus() *api.TranscriptionStatusData {
 }
 
 // EnqueueTranscription enqueues a call for transcription by looking it up in the DB.
-func (p *Pipeline) EnqueueTranscription(callID int64) bool {
+func (p *Pipeline) EnqueueTranscription(ref database.CallRef) bool {
 	if p.transcriber == nil {
 		return false
 	}
 	ctx, cancel := context.WithTimeout(p.ctx, 5*time.Second)
 	defer cancel()
 
-	c, err := p.db.GetCallForTranscription(ctx, callID)
+	c, err := p.db.GetCallForTranscription(ctx, ref)
 	if err != nil {
-		p.log.Warn().Err(err).Int64("call_id", callID).Msg("failed to load call for transcription")
+		p.log.Warn().Err(err).Int64("call_id", ref.CallID).Msg("failed to load call for transcription")
 		return false
 	}
 	return p.transcriber.Enqueue(transcribe.Job{
@@ -366,6 +599,21 @@ func (p *Pipeline) EnqueueTranscription(callID int64) bool {
 	})
 }
 
+// ClassifyTranscript runs the urgency classifier on text for call. Returns nil
+// if transcription or classification is disabled.
+func (p *Pipeline) ClassifyTranscript(ctx context.Context, call *database.CallTranscriptionInfo, text string) *database.TranscriptUrgency {
+	if p.transcriber == nil {
+		return nil
+	}
+	return p.transcriber.Classify(ctx, transcribe.ClassifyInput{
+		Text:       text,
+		SystemID:   call.SystemID,
+		Tgid:       call.Tgid,
+		TgAlphaTag: call.TgAlphaTag,
+		TgTag:      call.TgTag,
+	})
+}
+
 // TranscriptionQueueStats returns transcription queue statistics.
 func (p *Pipeline) TranscriptionQueueStats() *api.TranscriptionQueueStatsData {
 	if p.transcriber == nil {
@@ -373,9 +621,17 @@ func (p *Pipeline) TranscriptionQueueStats() *api.TranscriptionQueueStatsData {
 	}
 	stats := p.transcriber.Stats()
 	result := &api.TranscriptionQueueStatsData{
-		Pending:   stats.Pending,
-		Completed: stats.Completed,
-		Failed:    stats.Failed,
+		Pending:          stats.Pending,
+		PendingEmergency: stats.PendingEmergency,
+		InFlight:         stats.InFlight,
+		Completed:        stats.Completed,
+		Failed:           stats.Failed,
+		Requeued:         stats.Requeued,
+		DeadLettered:     stats.DeadLettered,
+		InFlightJobs:     []api.TranscriptionInFlightData{},
+	}
+	for _, j := range p.transcriber.InFlight() {
+		result.InFlightJobs = append(result.InFlightJobs, api.TranscriptionInFlightData(j))
 	}
 
 	if perf := p.transcriber.Performance(); perf != nil {
@@ -400,6 +656,19 @@ func (p *Pipeline) TranscriptionQueueStats() *api.TranscriptionQueueStatsData {
 	return result
 }
 
+// TranscriptionDeadLetters returns dead-lettered transcription jobs.
+func (p *Pipeline) TranscriptionDeadLetters() []api.TranscriptionDeadLetterData {
+	if p.transcriber == nil {
+		return nil
+	}
+	dead := p.transcriber.DeadLetters()
+	out := make([]api.TranscriptionDeadLetterData, len(dead))
+	for i, d := range dead {
+		out[i] = api.TranscriptionDeadLetterData(d)
+	}
+	return out
+}
+
 // SubscribeAudio subscribes to live audio frames matching the filter.
 func (p *Pipeline) SubscribeAudio(filter audio.AudioFilter) (<-chan audio.AudioFrame, func()) {
 	if p.audioBus == nil {
@@ -444,12 +713,30 @@ func (p *Pipeline) AudioRouterInput() chan<- audio.AudioChunk {
 
 // enqueueTranscription is called by ingest handlers when a call has audio ready.
 func (p *Pipeline) enqueueTranscription(callID int64, startTime time.Time, systemID int, audioFilePath string, meta *AudioMetadata) {
+	p.queueTranscription(callID, startTime, systemID, audioFilePath, nil, "", meta)
+}
+
+// enqueueHeldTranscription is enqueueTranscription for audio that is held in
+// memory instead of stored (transcript-only systems).
+func (p *Pipeline) enqueueHeldTranscription(callID int64, startTime time.Time, systemID int, data []byte, audioType string, meta *AudioMetadata) {
+	p.queueTranscription(callID, startTime, systemID, "", data, audioType, meta)
+}
+
+func (p *Pipeline) queueTranscription(callID int64, startTime time.Time, systemID int, audioFilePath string, held []byte, heldType string, meta *AudioMetadata) {
+	if p.confirmStuckMic(callID, startTime, audioFilePath, meta.Filename) {
+		return
+	}
 	if p.transcriber == nil {
 		return
 	}
-	// Skip if neither an audio file path nor a call filename is available —
-	// the transcription worker would fail to resolve the audio.
-	if audioFilePath == "" && meta.Filename == "" {
+	// Metadata-only systems and talkgroups keep no audio to transcribe
+	if p.audioDropped(systemID, meta.Talkgroup, startTime) {
+		return
+	}
+	// Skip if no audio is held and neither an audio file path nor a call
+	// filename is available — the transcription worker would fail to
+	// resolve the audio.
+	if len(held) == 0 && audioFilePath == "" && meta.Filename == "" {
 		return
 	}
 	dur := float32(meta.CallLength)
@@ -468,10 +755,13 @@ func (p *Pipeline) enqueueTranscription(callID int64, startTime time.Time, syste
 		Duration:      dur,
 		AudioFilePath: audioFilePath,
 		CallFilename:  meta.Filename,
+		Audio:         held,
+		AudioType:     heldType,
 		TgAlphaTag:    meta.TalkgroupTag,
 		TgDescription: meta.TalkgroupDesc,
 		TgTag:         meta.TalkgroupGroupTag,
 		TgGroup:       meta.TalkgroupGroup,
+		Emergency:     meta.Emergency != 0,
 	}
 	// Try to get src_list from metadata
 	if len(meta.SrcList) > 0 {
@@ -505,7 +795,7 @@ func (p *Pipeline) insertSourceTranscription(callID int64, startTime time.Time,
 	defer cancel()
 
 	text := strings.TrimSpace(meta.Transcript)
-	if text == "" {
+	if text == "" || p.ingestPolicy(systemID, tgid, startTime) == database.IngestPolicyMetadata {
 		return
 	}
 
@@ -535,13 +825,14 @@ func (p *Pipeline) insertSourceTranscription(callID int64, startTime time.Time,
 		Type:     "transcription",
 		SystemID: systemID,
 		Tgid:     tgid,
-		Payload: map[string]any{
-			"call_id":    callID,
-			"system_id":  systemID,
-			"tgid":       tgid,
-			"text":       text,
-			"word_count": wordCount,
-			"source":     "source",
+		Payload: &events.Transcription{
+			CallID:    callID,
+			SystemID:  systemID,
+			Tgid:      tgid,
+			StartTime: startTime,
+			Text:      text,
+			WordCount: wordCount,
+			Source:    "source",
 		},
 	})
 }
@@ -624,19 +915,20 @@ func (p *Pipeline) maintenanceLoop() {
 }
 
 func (p *Pipeline) runMaintenance() {
-	result, err := p.runMaintenanceWithResult()
+	result, err := p.runMaintenanceWithResult(maintenanceTriggerSchedule, false)
 	if err != nil {
 		p.log.Warn().Err(err).Msg("maintenance run failed")
 		return
 	}
 	p.log.Info().
 		Int64("duration_ms", result.DurationMs).
+		Bool("dry_run", result.DryRun).
 		Int("partitions_created", result.PartitionsCreated).
 		Int("partitions_dropped", len(result.PartitionsDropped)).
 		Msg("partition maintenance complete")
 }
 
-func (p *Pipeline) runMaintenanceWithResult() (*api.MaintenanceRunData, error) {
+func (p *Pipeline) runMaintenanceWithResult(trigger string, forceDryRun bool) (*api.MaintenanceRunData, error) {
 	if !p.maintenanceRunning.CompareAndSwap(false, true) {
 		return nil, fmt.Errorf("maintenance already running")
 	}
@@ -655,6 +947,16 @@ func (p *Pipeline) runMaintenanceWithResult() (*api.MaintenanceRunData, error) {
 	ctx, cancel := context.WithTimeout(p.ctx, 5*time.Minute)
 	defer cancel()
 
+	// Destructive steps below go through run.audited, which records what
+	// each would delete before doing it, and only previews in a dry run.
+	run := p.startMaintenanceRun(ctx, trigger, forceDryRun, &result, log)
+
+	// Retention: RETENTION_* with the admin overrides from /admin/retention
+	ret, _, err := p.effectiveRetention(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to load retention settings, using configured retention")
+	}
+
 	// 1. Create monthly partitions 3 months ahead
 	monthlyTables := []string{"calls", "call_frequencies", "call_transmissions", "unit_events", "trunking_messages"}
 	for _, table := range monthlyTables {
@@ -689,10 +991,19 @@ func (p *Pipeline) runMaintenanceWithResult() (*api.MaintenanceRunData, error) {
 		{"recorder_snapshots", "time"},
 		{"decode_rates", "time"},
 	} {
-		decRes, err := p.db.DecimateStateTable(ctx, spec.table, spec.col)
+		var decRes database.DecimateResult
+		_, applied, err := run.audited(database.MaintenanceTaskDecimation, spec.table,
+			func() (database.MaintenancePreview, error) {
+				return p.db.PreviewDecimateStateTable(ctx, spec.table, spec.col)
+			},
+			func() (int64, error) {
+				var err error
+				decRes, err = p.db.DecimateStateTable(ctx, spec.table, spec.col)
+				return decRes.Deleted1w + decRes.Deleted1m, err
+			})
 		if err != nil {
 			log.Warn().Err(err).Str("table", spec.table).Msg("decimation failed")
-		} else {
+		} else if applied {
 			if decRes.Deleted1w > 0 || decRes.Deleted1m > 0 {
 				log.Info().
 					Str("table", spec.table).
@@ -708,19 +1019,72 @@ func (p *Pipeline) runMaintenanceWithResult() (*api.MaintenanceRunData, error) {
 	}
 
 	// 4. Purge expired data
-	for _, spec := range []struct {
+	purges := []struct {
 		table     string
 		col       string
 		retention time.Duration
 	}{
-		{"console_messages", "log_time", p.retentionCfg.ConsoleLogs},
-		{"plugin_statuses", "time", p.retentionCfg.PluginStatus},
-		{"call_active_checkpoints", "snapshot_time", p.retentionCfg.Checkpoints},
-	} {
-		n, err := p.db.PurgeOlderThan(ctx, spec.table, spec.col, spec.retention)
+		{"console_messages", "log_time", ret.ConsoleLogs},
+		{"plugin_statuses", "time", ret.PluginStatus},
+		{"call_active_checkpoints", "snapshot_time", ret.Checkpoints},
+		{"ingest_quarantine", "received_at", ret.Quarantine},
+	}
+	if ret.ExternalEvents > 0 {
+		purges = append(purges, struct {
+			table     string
+			col       string
+			retention time.Duration
+		}{"external_events", "event_time", ret.ExternalEvents})
+	}
+	if ret.WebhookDeliveries > 0 {
+		purges = append(purges, struct {
+			table     string
+			col       string
+			retention time.Duration
+		}{"webhook_deliveries", "created_at", ret.WebhookDeliveries})
+	}
+	if ret.Timeseries > 0 {
+		purges = append(purges, struct {
+			table     string
+			col       string
+			retention time.Duration
+		}{"system_timeseries", "ts", ret.Timeseries})
+	}
+	if ret.UnitEvents > 0 {
+		// Only purge raw unit events already folded into unit_sessions.
+		wm, err := p.db.UnitSessionWatermark(ctx)
+		switch {
+		case err != nil:
+			log.Warn().Err(err).Msg("failed to read unit session watermark")
+		case wm == nil:
+			log.Info().Msg("unit events not compacted yet, skipping unit_events purge")
+		default:
+			retention := max(ret.UnitEvents, time.Since(*wm))
+			purges = append(purges, struct {
+				table     string
+				col       string
+				retention time.Duration
+			}{"unit_events", "time", retention})
+		}
+	}
+	if ret.UnitSessions > 0 {
+		purges = append(purges, struct {
+			table     string
+			col       string
+			retention time.Duration
+		}{"unit_sessions", "end_time", ret.UnitSessions})
+	}
+	for _, spec := range purges {
+		n, applied, err := run.audited(database.MaintenanceTaskPurge, spec.table,
+			func() (database.MaintenancePreview, error) {
+				return p.db.PreviewOlderThan(ctx, spec.table, spec.col, spec.retention)
+			},
+			func() (int64, error) {
+				return p.db.PurgeOlderThan(ctx, spec.table, spec.col, spec.retention)
+			})
 		if err != nil {
 			log.Warn().Err(err).Str("table", spec.table).Msg("purge failed")
-		} else {
+		} else if applied {
 			if n > 0 {
 				log.Info().Str("table", spec.table).Int64("deleted", n).Msg("purged old rows")
 			}
@@ -729,38 +1093,136 @@ func (p *Pipeline) runMaintenanceWithResult() (*api.MaintenanceRunData, error) {
 	}
 
 	// 5. Drop old weekly partitions (raw MQTT)
-	dropped, err := p.db.DropOldWeeklyPartitions(ctx, "mqtt_raw_messages", p.retentionCfg.RawMessages)
+	var oldPartitions []database.WeeklyPartition
+	_, _, err = run.audited(database.MaintenanceTaskPartitionDrop, "mqtt_raw_messages",
+		func() (database.MaintenancePreview, error) {
+			var err error
+			oldPartitions, err = p.db.OldWeeklyPartitions(ctx, "mqtt_raw_messages", ret.RawMessages)
+			return partitionPreview(oldPartitions), err
+		},
+		func() (int64, error) {
+			names := make([]string, len(oldPartitions))
+			var rows int64
+			for i, part := range oldPartitions {
+				names[i] = part.Name
+				rows += part.EstimatedRows
+			}
+			dropped, err := p.db.DropPartitions(ctx, names)
+			for _, name := range dropped {
+				log.Info().Str("partition", name).Msg("dropped old weekly partition")
+			}
+			result.PartitionsDropped = dropped
+			return rows, err
+		})
 	if err != nil {
 		log.Warn().Err(err).Msg("failed to drop old weekly partitions")
 	}
-	for _, name := range dropped {
-		log.Info().Str("partition", name).Msg("dropped old weekly partition")
+
+	// 6. Delete expired call audio (local-only store), then expired calls.
+	// Audio goes by RETENTION_CALLS too, so purged calls leave no files.
+	if age := ret.callAudioAge(); age > 0 && p.callAudioDeletable() {
+		n, applied, err := run.audited(database.MaintenanceTaskAudioPurge, "call_audio",
+			func() (database.MaintenancePreview, error) { return p.db.PreviewCallAudio(ctx, age, ret.KeepPinned) },
+			func() (int64, error) { return p.purgeCallAudio(ctx, age, ret.KeepPinned, 0, log) })
+		if err != nil {
+			log.Warn().Err(err).Msg("failed to delete expired call audio")
+		} else if applied {
+			if n > 0 {
+				log.Info().Int64("calls", n).Msg("deleted expired call audio")
+			}
+			result.Purged["call_audio"] = n
+		}
+	}
+	if ret.Calls > 0 {
+		n, applied, err := run.audited(database.MaintenanceTaskPurge, "calls",
+			func() (database.MaintenancePreview, error) { return p.db.PreviewOldCalls(ctx, ret.Calls, ret.KeepPinned) },
+			func() (int64, error) { return p.purgeOldCalls(ctx, ret.Calls, ret.KeepPinned) })
+		if err != nil {
+			log.Warn().Err(err).Msg("failed to purge expired calls")
+		} else if applied {
+			if n > 0 {
+				log.Info().Int64("deleted", n).Msg("purged expired calls")
+			}
+			result.Purged["calls"] = n
+		}
 	}
-	result.PartitionsDropped = dropped
 
-	// 6. Purge stale RECORDING calls (call_start with no call_end or audio)
-	stalePurged, err := p.db.PurgeStaleCalls(ctx, p.retentionCfg.StaleCalls)
+	// 7. Purge stale RECORDING calls (call_start with no call_end or audio)
+	stalePurged, applied, err := run.audited(database.MaintenanceTaskStaleCalls, "calls",
+		func() (database.MaintenancePreview, error) {
+			return p.db.PreviewStaleCalls(ctx, ret.StaleCalls)
+		},
+		func() (int64, error) { return p.db.PurgeStaleCalls(ctx, ret.StaleCalls) })
 	if err != nil {
 		log.Warn().Err(err).Msg("failed to purge stale calls")
-	} else {
+	} else if applied {
 		if stalePurged > 0 {
 			log.Info().Int64("deleted", stalePurged).Msg("purged stale RECORDING calls")
 		}
 		result.Purged["stale_calls"] = stalePurged
 	}
 
-	// 7. Clean up orphaned call_groups (no calls reference them)
-	orphansPurged, err := p.db.PurgeOrphanCallGroups(ctx)
+	// 8. Clean up orphaned call_groups (no calls reference them)
+	orphansPurged, applied, err := run.audited(database.MaintenanceTaskOrphanCallGroups, "call_groups",
+		func() (database.MaintenancePreview, error) { return p.db.PreviewOrphanCallGroups(ctx) },
+		func() (int64, error) { return p.db.PurgeOrphanCallGroups(ctx) })
 	if err != nil {
 		log.Warn().Err(err).Msg("failed to purge orphan call_groups")
-	} else {
+	} else if applied {
 		if orphansPurged > 0 {
 			log.Info().Int64("deleted", orphansPurged).Msg("purged orphan call_groups")
 		}
 		result.Purged["orphan_call_groups"] = orphansPurged
 	}
 
-	// 8. Expire stale entries from in-memory active calls map (calls older than 1 hour)
+	// 9. Archive units not seen within the retention window (opt-in)
+	if ret.InactiveUnits > 0 {
+		archived, applied, err := run.audited(database.MaintenanceTaskInactiveUnits, "units",
+			func() (database.MaintenancePreview, error) {
+				return p.db.PreviewInactiveUnits(ctx, ret.InactiveUnits)
+			},
+			func() (int64, error) { return p.db.ArchiveInactiveUnits(ctx, ret.InactiveUnits) })
+		if err != nil {
+			log.Warn().Err(err).Msg("failed to archive inactive units")
+		} else if applied {
+			if archived > 0 {
+				log.Info().Int64("archived", archived).Msg("archived inactive units")
+			}
+			result.Purged["inactive_units"] = archived
+		}
+	}
+
+	// 10. Re-correct recent call durations from their measured audio. A
+	// call_end that arrives after the audio overwrites the corrected duration.
+	if p.durationCorrect > 0 {
+		filter := database.DurationCorrectionFilter{
+			StartTime: time.Now().Add(-48 * time.Hour),
+			Threshold: p.durationCorrect.Seconds(),
+		}
+		var res database.DurationCorrectionResult
+		_, applied, err := run.audited(database.MaintenanceTaskDurationCorrection, "calls",
+			func() (database.MaintenancePreview, error) {
+				preview := filter
+				preview.DryRun = true
+				res, err := p.db.CorrectCallDurations(ctx, preview)
+				return database.MaintenancePreview{Rows: res.Calls, RangeStart: &filter.StartTime}, err
+			},
+			func() (int64, error) {
+				var err error
+				res, err = p.db.CorrectCallDurations(ctx, filter)
+				return res.Calls, err
+			})
+		if err != nil {
+			log.Warn().Err(err).Msg("failed to correct call durations")
+		} else if applied {
+			if res.Calls > 0 {
+				log.Info().Int64("calls", res.Calls).Float64("airtime_delta", res.AirtimeDelta).Msg("corrected call durations from audio")
+			}
+			result.DurationsCorrected = res.Calls
+		}
+	}
+
+	// 11. Expire stale entries from in-memory active calls map (calls older than 1 hour)
 	staleMapEntries := 0
 	for trCallID, entry := range p.activeCalls.All() {
 		if time.Since(entry.StartTime) > 1*time.Hour {
@@ -772,30 +1234,55 @@ func (p *Pipeline) runMaintenanceWithResult() (*api.MaintenanceRunData, error) {
 		log.Info().Int("expired", staleMapEntries).Msg("expired stale active calls from memory")
 	}
 
+	// 12. Old audit entries (the runs themselves are kept for MAINTENANCE_OBSERVE_CYCLES)
+	if _, err := p.db.PurgeOlderThan(ctx, "maintenance_audit", "created_at", maintenanceAuditRetention); err != nil {
+		log.Warn().Err(err).Msg("failed to purge maintenance audit")
+	}
+
+	run.finish()
 	result.DurationMs = time.Since(start).Milliseconds()
 	p.lastMaintenance.Store(&result)
 	return &result, nil
 }
 
-// MaintenanceStatus returns the current maintenance configuration and last run results.
-func (p *Pipeline) MaintenanceStatus() *api.MaintenanceStatusData {
-	return &api.MaintenanceStatusData{
+// MaintenanceStatus returns the current maintenance configuration, with
+// retention overrides applied, and last run results.
+func (p *Pipeline) MaintenanceStatus(ctx context.Context) *api.MaintenanceStatusData {
+	ret, _, err := p.effectiveRetention(ctx)
+	if err != nil {
+		p.log.Warn().Err(err).Msg("failed to load retention settings")
+	}
+	status := &api.MaintenanceStatusData{
 		Config: api.MaintenanceConfigData{
-			RetentionRawMessages:  p.retentionCfg.RawMessages.String(),
-			RetentionConsoleLogs:  p.retentionCfg.ConsoleLogs.String(),
-			RetentionPluginStatus: p.retentionCfg.PluginStatus.String(),
-			RetentionCheckpoints:  p.retentionCfg.Checkpoints.String(),
-			RetentionStaleCalls:   p.retentionCfg.StaleCalls.String(),
+			RetentionRawMessages:  ret.RawMessages.String(),
+			RetentionConsoleLogs:  ret.ConsoleLogs.String(),
+			RetentionPluginStatus: ret.PluginStatus.String(),
+			RetentionCheckpoints:  ret.Checkpoints.String(),
+			RetentionStaleCalls:   ret.StaleCalls.String(),
+			RetentionInactiveUnits: ret.InactiveUnits.String(),
+			RetentionQuarantine:    ret.Quarantine.String(),
+			RetentionExternalEvents: ret.ExternalEvents.String(),
+			RetentionWebhookDeliveries: ret.WebhookDeliveries.String(),
+			RetentionTimeseries:    ret.Timeseries.String(),
+			RetentionUnitEvents:    ret.UnitEvents.String(),
+			RetentionUnitSessions:  ret.UnitSessions.String(),
+			RetentionCalls:         ret.Calls.String(),
+			RetentionCallAudio:     ret.callAudioAge().String(),
+			AudioDiskMaxPercent:    ret.AudioDiskMaxPercent,
+			KeepPinned:             ret.KeepPinned,
 			Schedule:              "every 24h",
 		},
 		LastRun: p.lastMaintenance.Load(),
 	}
+	p.maintenanceModeStatus(ctx, status)
+	return status
 }
 
-// RunMaintenance triggers an immediate maintenance run.
+// RunMaintenance triggers an immediate maintenance run. With dryRun, every
+// destructive step only records what it would delete.
 // Returns the results, or an error if maintenance is already running.
-func (p *Pipeline) RunMaintenance(ctx context.Context) (*api.MaintenanceRunData, error) {
-	return p.runMaintenanceWithResult()
+func (p *Pipeline) RunMaintenance(ctx context.Context, dryRun bool) (*api.MaintenanceRunData, error) {
+	return p.runMaintenanceWithResult(maintenanceTriggerManual, dryRun)
 }
 
 // talkgroupStatsLoop refreshes cached talkgroup stats on two cadences:
@@ -836,6 +1323,7 @@ func (p *Pipeline) refreshTalkgroupStatsHot(log zerolog.Logger) {
 	}
 	if updated > 0 {
 		log.Info().Int64("updated", updated).Msg("talkgroup stats hot refreshed")
+		p.invalidate(api.CacheTagTalkgroups)
 	}
 }
 
@@ -850,9 +1338,105 @@ func (p *Pipeline) refreshTalkgroupStatsCold(log zerolog.Logger) {
 	}
 	if updated > 0 {
 		log.Info().Int64("updated", updated).Msg("talkgroup stats cold refreshed")
+		p.invalidate(api.CacheTagTalkgroups)
 	}
 }
 
+// unitEncryptionBackfill is how far back the unit encryption rollup is built
+// when it is empty.
+const unitEncryptionBackfill = 90 * 24 * time.Hour
+
+// unitEncryptionRollupLoop keeps unit_encryption_daily current: hourly it
+// rebuilds from the day before the latest rolled-up day through today, so
+// calls that end or arrive late are picked up.
+func (p *Pipeline) unitEncryptionRollupLoop() {
+	log := p.log.With().Str("task", "unit-encryption-rollup").Logger()
+
+	p.refreshUnitEncryptionRollup(log)
+
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			p.refreshUnitEncryptionRollup(log)
+		}
+	}
+}
+
+func (p *Pipeline) refreshUnitEncryptionRollup(log zerolog.Logger) {
+	ctx, cancel := context.WithTimeout(p.ctx, 10*time.Minute)
+	defer cancel()
+
+	now := time.Now()
+	since := now.Add(-unitEncryptionBackfill)
+	latest, err := p.db.UnitEncryptionRollupLatestDay(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("unit encryption rollup: failed to read latest day")
+		return
+	}
+	if latest != nil {
+		since = latest.AddDate(0, 0, -1)
+	}
+
+	rows, err := p.db.RefreshUnitEncryptionRollup(ctx, since, now)
+	if err != nil {
+		log.Warn().Err(err).Msg("unit encryption rollup refresh failed")
+		return
+	}
+	log.Debug().Time("since", since).Int64("rows", rows).Msg("unit encryption rollup refreshed")
+}
+
+// unitCensusBackfill is how far back the unit census rollup is built when it
+// is empty.
+const unitCensusBackfill = 90 * 24 * time.Hour
+
+// unitCensusRollupLoop keeps unit_census_daily current: hourly it rebuilds
+// from the day before the latest rolled-up day through today.
+func (p *Pipeline) unitCensusRollupLoop() {
+	log := p.log.With().Str("task", "unit-census-rollup").Logger()
+
+	p.refreshUnitCensusRollup(log)
+
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			p.refreshUnitCensusRollup(log)
+		}
+	}
+}
+
+func (p *Pipeline) refreshUnitCensusRollup(log zerolog.Logger) {
+	ctx, cancel := context.WithTimeout(p.ctx, 10*time.Minute)
+	defer cancel()
+
+	now := time.Now()
+	since := now.Add(-unitCensusBackfill)
+	latest, err := p.db.UnitCensusRollupLatestDay(ctx)
+	if err != nil {
+		log.Warn().Err(err).Msg("unit census rollup: failed to read latest day")
+		return
+	}
+	if latest != nil {
+		since = latest.AddDate(0, 0, -1)
+	}
+
+	rows, err := p.db.RefreshUnitCensusRollup(ctx, since, now)
+	if err != nil {
+		log.Warn().Err(err).Msg("unit census rollup refresh failed")
+		return
+	}
+	log.Debug().Time("since", since).Int64("rows", rows).Msg("unit census rollup refreshed")
+}
+
 // unitDedupKey identifies a unique unit event for deduplication across sites.
 // No time bucket — the dedup window is controlled by the 10-second cleanup loop.
 // This avoids boundary artifacts where events 1-2s apart straddle a fixed bucket edge.
@@ -863,7 +1447,8 @@ type unitDedupKey struct {
 	Tgid      int
 }
 
-// dedupCleanupLoop sweeps expired entries from the unit event dedup buffer every 10 seconds.
+// dedupCleanupLoop sweeps expired entries from the unit event and audio
+// dedup buffers and ended interconnect calls every 10 seconds.
 func (p *Pipeline) dedupCleanupLoop() {
 	ticker := time.NewTicker(10 * time.Second)
 	defer ticker.Stop()
@@ -879,6 +1464,8 @@ func (p *Pipeline) dedupCleanupLoop() {
 				}
 				return true
 			})
+			p.audioDedup.sweep(time.Now())
+			p.interconnects.EvictStale(time.Now())
 		}
 	}
 }
@@ -925,19 +1512,51 @@ func (p *Pipeline) ensurePartitionsFor(t time.Time) {
 
 // HandleMessage is the entry point called by the MQTT client for each message.
 func (p *Pipeline) HandleMessage(topic string, payload []byte) {
+	if route, payload, env := p.accept(topic, payload); route != nil {
+		p.dispatch(route, topic, payload, env)
+	}
+}
+
+// ProcessMessage is the entry point for MQTT_ACK_MODE=processed: it handles
+// a message and reports whether it may be acknowledged. When a handler fails
+// while the database is unreachable, the handler is retried every
+// ackRetryInterval until the database answers, holding the message (and, once
+// every inflight slot is held, the broker) back. Other handler errors are
+// logged and the message is acknowledged, as a redelivery would fail the
+// same way. Returns false on shutdown, leaving the message to be redelivered.
+func (p *Pipeline) ProcessMessage(topic string, payload []byte) bool {
+	route, payload, env := p.accept(topic, payload)
+	if route == nil {
+		return true
+	}
+	err := p.dispatch(route, topic, payload, env)
+	for err != nil {
+		if p.db.HealthCheck(p.ctx) == nil {
+			return true
+		}
+		metrics.MQTTHandlerRetriesTotal.WithLabelValues(route.Handler).Inc()
+		p.log.Warn().Err(err).Str("handler", route.Handler).Str("topic", topic).
+			Dur("retry_in", p.ackRetryInterval).Msg("database unreachable, holding message")
+		select {
+		case <-p.ctx.Done():
+			return false
+		case <-time.After(p.ackRetryInterval):
+		}
+		err = handlerError(p.runHandler(route, topic, payload))
+	}
+	return true
+}
+
+// accept counts, attributes, archives and validates a message, returning
+// the route to dispatch it to or nil when it stops here.
+func (p *Pipeline) accept(topic string, payload []byte) (*Route, []byte, *Envelope) {
 	p.msgCount.Add(1)
 	metrics.MQTTMessagesTotal.Inc()
 
 	route := ParseTopic(topic)
 
-	// Rewrite instance_id based on topic prefix (before any unmarshalling)
-	if len(p.instancePrefixMap) > 0 {
-		if prefix, _, ok := strings.Cut(topic, "/"); ok {
-			if newID, mapped := p.instancePrefixMap[prefix]; mapped {
-				payload = rewriteInstanceID(payload, newID)
-			}
-		}
-	}
+	// Set instance_id from the topic prefix mapping (before any unmarshalling)
+	payload, _ = p.instanceMap.attribute(topic, payload, time.Now())
 
 	// Best-effort extract instance_id for archival
 	var env Envelope
@@ -947,7 +1566,7 @@ func (p *Pipeline) HandleMessage(topic string, payload []byte) {
 	if route == nil {
 		p.archiveRaw("_unknown", topic, payload, env.InstanceID)
 		p.log.Warn().Str("topic", topic).Msg("unknown topic, skipping")
-		return
+		return nil, nil, nil
 	}
 
 	p.archiveRaw(route.Handler, topic, payload, env.InstanceID)
@@ -957,8 +1576,18 @@ func (p *Pipeline) HandleMessage(topic string, payload []byte) {
 		p.UpdateTRInstanceStatus(env.InstanceID, "connected", time.Now())
 	}
 
-	// Dispatch to handler
-	p.dispatch(route, topic, payload, &env)
+	// Reject malformed payloads before they reach a handler
+	if p.quarantineInvalid(route, topic, payload, env.InstanceID) {
+		return nil, nil, nil
+	}
+	return route, payload, &env
+}
+
+// invalidate drops cached API responses carrying any of tags.
+func (p *Pipeline) invalidate(tags ...string) {
+	if p.invalidateCache != nil {
+		p.invalidateCache(tags...)
+	}
 }
 
 func (p *Pipeline) incHandler(name string) {
@@ -967,7 +1596,9 @@ func (p *Pipeline) incHandler(name string) {
 	metrics.MQTTHandlerMessagesTotal.WithLabelValues(name).Inc()
 }
 
-func (p *Pipeline) dispatch(route *Route, topic string, payload []byte, env *Envelope) {
+// dispatch runs a message's handler and returns its error, which it has
+// already logged. Messages buffered during warmup return nil.
+func (p *Pipeline) dispatch(route *Route, topic string, payload []byte, env *Envelope) error {
 	// Warmup gate: buffer non-identity messages until system registration arrives
 	if !p.warmupDone.Load() {
 		switch route.Handler {
@@ -982,15 +1613,42 @@ func (p *Pipeline) dispatch(route *Route, topic string, payload []byte, env *Env
 					payload: append([]byte(nil), payload...), // copy — may be reused
 				})
 				p.warmupMu.Unlock()
-				return
+				return nil
 			}
 			p.warmupMu.Unlock()
 		}
 	}
 
 	p.incHandler(route.Handler)
-	var err error
+	err := p.runHandler(route, topic, payload)
+	if errors.Is(err, ErrIdentityPending) || errors.Is(err, ErrEncryptedSuppressed) {
+		// Pending identities are already staged and logged once by the resolver
+		p.log.Debug().Err(err).Str("handler", route.Handler).Str("topic", topic).Msg("message dropped")
+	} else if err != nil {
+		p.log.Error().Err(err).
+			Str("handler", route.Handler).
+			Str("topic", topic).
+			Msg("handler error")
+	}
+	return handlerError(err)
+}
+
+// handlerError drops the errors of messages that were deliberately
+// discarded rather than failed.
+func handlerError(err error) error {
+	if errors.Is(err, ErrIdentityPending) || errors.Is(err, ErrEncryptedSuppressed) {
+		return nil
+	}
+	return err
+}
 
+// runHandler invokes the handler for route and returns its error.
+func (p *Pipeline) runHandler(route *Route, topic string, payload []byte) error {
+	start := time.Now()
+	defer func() {
+		metrics.MQTTHandlerDuration.WithLabelValues(route.Handler).Observe(time.Since(start).Seconds())
+	}()
+	var err error
 	switch route.Handler {
 	case "status":
 		err = p.handleStatus(payload)
@@ -1020,17 +1678,12 @@ func (p *Pipeline) dispatch(route *Route, topic string, payload []byte, env *Env
 		err = p.handleConsoleLog(payload)
 	case "unit_event":
 		err = p.handleUnitEvent(topic, payload)
+	case "issi_call":
+		err = p.handleISSICall(payload)
 	default:
-		p.log.Warn().Str("handler", route.Handler).Msg("no handler for route")
-		return
-	}
-
-	if err != nil {
-		p.log.Error().Err(err).
-			Str("handler", route.Handler).
-			Str("topic", topic).
-			Msg("handler error")
+		return fmt.Errorf("no handler for route %q", route.Handler)
 	}
+	return err
 }
 
 // completeWarmup ends the warmup gate and replays buffered messages.
@@ -1049,6 +1702,12 @@ func (p *Pipeline) completeWarmup() {
 	p.warmupBuf = nil
 	p.warmupMu.Unlock()
 
+	// Emergencies go first; the rest keep their arrival order
+	sort.SliceStable(buf, func(i, j int) bool {
+		return isEmergencyMessage(buf[i].route, buf[i].topic, buf[i].payload) &&
+			!isEmergencyMessage(buf[j].route, buf[j].topic, buf[j].payload)
+	})
+
 	p.log.Info().Int("buffered_messages", len(buf)).Msg("warmup complete, replaying buffered messages")
 	for _, msg := range buf {
 		var env Envelope
@@ -1094,8 +1753,9 @@ func (p *Pipeline) flushRecorderSnapshots(rows []database.RecorderSnapshotRow) {
 }
 
 // archiveRaw conditionally stores a message in mqtt_raw_messages based on the
-// raw archival config: RAW_STORE, RAW_INCLUDE_TOPICS, RAW_EXCLUDE_TOPICS.
-// Use handler="_unknown" for unrecognized topics.
+// raw archival config: RAW_STORE, RAW_INCLUDE_TOPICS, RAW_EXCLUDE_TOPICS,
+// after applying the RAW_REDACT rules. Use handler="_unknown" for
+// unrecognized topics.
 func (p *Pipeline) archiveRaw(handler, topic string, payload []byte, instanceID string) {
 	if !p.rawStore {
 		return
@@ -1108,37 +1768,14 @@ func (p *Pipeline) archiveRaw(handler, topic string, payload []byte, instanceID
 		return
 	}
 
-	rawPayload := payload
-	if handler == "audio" {
-		rawPayload = stripAudioBase64(payload)
-	}
 	p.rawBatcher.Add(database.RawMessageRow{
 		Topic:      topic,
-		Payload:    rawPayload,
+		Payload:    p.rawRedact.redact(handler, payload),
 		ReceivedAt: time.Now(),
 		InstanceID: instanceID,
 	})
 }
 
-// parseInstanceMap parses "prefix:instance_id,prefix:instance_id" into a map.
-func parseInstanceMap(s string) map[string]string {
-	m := make(map[string]string)
-	if s == "" {
-		return m
-	}
-	for _, pair := range strings.Split(s, ",") {
-		pair = strings.TrimSpace(pair)
-		if prefix, id, ok := strings.Cut(pair, ":"); ok {
-			prefix = strings.TrimSpace(prefix)
-			id = strings.TrimSpace(id)
-			if prefix != "" && id != "" {
-				m[prefix] = id
-			}
-		}
-	}
-	return m
-}
-
 // rewriteInstanceID replaces ALL instance_id values in a JSON payload.
 // Some TR messages have nested instance_id fields (e.g. signal events have one
 // inside the signal object and one at the envelope level). Both must be rewritten.
@@ -1210,36 +1847,6 @@ func parseHandlerSet(s string) map[string]bool {
 	return m
 }
 
-// stripAudioBase64 removes the base64 audio data from audio message payloads
-// before storing in mqtt_raw_messages. The audio is already saved to disk by
-// the audio handler, so keeping it in the DB is pure waste (~60KB per message).
-func stripAudioBase64(payload []byte) []byte {
-	var obj map[string]json.RawMessage
-	if err := json.Unmarshal(payload, &obj); err != nil {
-		return payload
-	}
-	callRaw, ok := obj["call"]
-	if !ok {
-		return payload
-	}
-	var call map[string]json.RawMessage
-	if err := json.Unmarshal(callRaw, &call); err != nil {
-		return payload
-	}
-	delete(call, "audio_m4a_base64")
-	delete(call, "audio_wav_base64")
-	callBytes, err := json.Marshal(call)
-	if err != nil {
-		return payload
-	}
-	obj["call"] = callBytes
-	out, err := json.Marshal(obj)
-	if err != nil {
-		return payload
-	}
-	return out
-}
-
 // activeCallMap tracks in-flight calls: tr_call_id → call metadata for API display.
 // conventionalFreqEntry maps a conventional frequency to its talkgroup identity.
 type conventionalFreqEntry struct {
@@ -1465,6 +2072,26 @@ func (m *affiliationMap) All() []affiliationEntry {
 	return result
 }
 
+// RewriteUnit moves the entry for unit from to unit to within a system after a
+// unit merge, keeping the target's own entry when it has one, and sets the
+// target's alpha tag.
+func (m *affiliationMap) RewriteUnit(systemID, from, to int, alphaTag string) {
+	m.mu.Lock()
+	src := affiliationKey{SystemID: systemID, UnitID: from}
+	dst := affiliationKey{SystemID: systemID, UnitID: to}
+	if e, ok := m.items[src]; ok {
+		delete(m.items, src)
+		if _, ok := m.items[dst]; !ok {
+			e.UnitID = to
+			m.items[dst] = e
+		}
+	}
+	if e, ok := m.items[dst]; ok {
+		e.UnitAlphaTag = alphaTag
+	}
+	m.mu.Unlock()
+}
+
 // EvictStale removes entries whose LastEventTime is older than maxAge.
 // Returns the number of entries evicted.
 func (m *affiliationMap) EvictStale(maxAge time.Duration) int {
@@ -1509,6 +2136,7 @@ func (p *Pipeline) ActiveCalls() []api.ActiveCallData {
 			Conventional:  e.Conventional,
 			Phase2TDMA:    e.Phase2TDMA,
 			AudioType:     e.AudioType,
+			Restricted:    p.restrictedEncrypted(e.SystemID, e.Tgid, e.Encrypted) || p.embargoes.get(e.SystemID, e.Tgid) > 0,
 		})
 	}
 	return calls
@@ -1542,6 +2170,45 @@ func (p *Pipeline) RewriteSystemID(oldSystemID, newSystemID int) {
 	p.identity.RewriteSystemID(oldSystemID, newSystemID)
 }
 
+// RewriteUnitID updates the affiliation and discovery caches after a unit
+// merge, so the source unit no longer shows up live and either ID is looked
+// up again the next time it is heard.
+func (p *Pipeline) RewriteUnitID(result *database.UnitMergeResult) {
+	p.affiliations.RewriteUnit(result.SystemID, result.SourceUnitID, result.TargetUnitID, result.AlphaTag)
+	for _, id := range []int{result.SourceUnitID, result.TargetUnitID} {
+		p.discoveries.forget(discoveryKey{kind: database.DiscoveryUnit, systemID: result.SystemID, id: id})
+	}
+}
+
+// IdentityMap returns the identity cache entries.
+func (p *Pipeline) IdentityMap() []api.IdentityMapEntryData {
+	entries := p.identity.Entries()
+	out := make([]api.IdentityMapEntryData, len(entries))
+	for i, e := range entries {
+		out[i] = api.IdentityMapEntryData{
+			InstanceID: e.InstanceID,
+			SysName:    e.SysName,
+			SystemID:   e.SystemID,
+			SiteID:     e.SiteID,
+			Sysid:      e.Sysid,
+			Source:     e.Source,
+			CachedAt:   e.CachedAt,
+			Hits:       e.Hits,
+		}
+		if !e.LastHit.IsZero() {
+			t := e.LastHit
+			out[i].LastHit = &t
+		}
+	}
+	return out
+}
+
+// InvalidateIdentity drops identity cache entries so they re-resolve from
+// the database.
+func (p *Pipeline) InvalidateIdentity(instanceID, sysName string) int {
+	return p.identity.Invalidate(instanceID, sysName)
+}
+
 // MsgCount returns the total number of MQTT messages processed.
 func (p *Pipeline) MsgCount() int64 {
 	return p.msgCount.Load()
@@ -1578,9 +2245,30 @@ func (p *Pipeline) IngestMetrics() *api.IngestMetricsData {
 }
 
 // PublishEvent is a convenience method to publish an event through the event bus.
+// A call_end first goes through the enrichment hooks, which may add fields,
+// and is counted in the per-talkgroup metrics. A transcription is checked
+// against the alert rules once published. Events on an embargoed talkgroup
+// are held back from non-admin subscribers.
 func (p *Pipeline) PublishEvent(e EventData) {
+	if ce, ok := e.Payload.(*events.CallEnd); ok {
+		p.enrichCallEnd(ce)
+		observeTalkgroupCall(ce)
+	}
 	if p.eventBus != nil {
+		e.Restricted = e.Restricted || p.restrictedEvent(e)
+		if e.Tgid != 0 && e.Embargo == 0 {
+			e.Embargo = p.embargoes.get(e.SystemID, e.Tgid)
+		}
+		if e.Type == "call_end" {
+			p.callCount.Add(1)
+		}
 		p.eventBus.Publish(e)
+		if e.Type == "call_start" || e.Type == "call_end" {
+			p.publishOccupancy(e)
+		}
+	}
+	if t, ok := e.Payload.(*events.Transcription); ok {
+		p.evaluateAlerts(t)
 	}
 }
 
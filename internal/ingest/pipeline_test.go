@@ -3,6 +3,8 @@ package ingest
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 )
@@ -398,3 +400,17 @@ func TestUnitDedupKeyEquality(t *testing.T) {
 		t.Error("different SystemID should not be equal")
 	}
 }
+
+// ── handlerError ─────────────────────────────────────────────────────
+
+func TestHandlerErrorDropsDiscardedMessages(t *testing.T) {
+	for _, err := range []error{nil, ErrIdentityPending, fmt.Errorf("resolve: %w", ErrEncryptedSuppressed)} {
+		if got := handlerError(err); got != nil {
+			t.Errorf("handlerError(%v) = %v, want nil", err, got)
+		}
+	}
+	failed := errors.New("insert call: connection refused")
+	if got := handlerError(failed); got != failed {
+		t.Errorf("handlerError(%v) = %v", failed, got)
+	}
+}
@@ -0,0 +1,83 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/api"
+	"github.com/snarg/tr-engine/internal/metrics"
+)
+
+// quarantineInvalid validates payload against its handler schema. Depending on
+// INGEST_VALIDATION it either logs the failure ("log") or stores the message in
+// ingest_quarantine ("quarantine"). Returns true if the message was quarantined
+// and must not be dispatched.
+func (p *Pipeline) quarantineInvalid(route *Route, topic string, payload []byte, instanceID string) bool {
+	if p.validationMode == "off" {
+		return false
+	}
+	problems := validatePayload(route, topic, payload, time.Now())
+	if len(problems) == 0 {
+		return false
+	}
+
+	metrics.IngestValidationFailuresTotal.WithLabelValues(route.Handler).Inc()
+	p.log.Warn().
+		Str("handler", route.Handler).
+		Str("topic", topic).
+		Strs("reasons", problems).
+		Str("mode", p.validationMode).
+		Msg("payload failed validation")
+
+	if p.validationMode == "log" {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(p.ctx, 5*time.Second)
+	defer cancel()
+	if err := p.db.InsertQuarantinedMessage(ctx, route.Handler, topic, instanceID, payload, problems); err != nil {
+		p.log.Error().Err(err).Str("topic", topic).Msg("failed to quarantine message")
+	}
+	return true
+}
+
+// ReprocessQuarantined re-runs a quarantined message. Unless force is set the
+// payload is validated again first; if it still fails, the stored reasons are
+// refreshed and the message stays pending. Reprocessed messages bypass the
+// warmup gate since the system identity is established by then.
+func (p *Pipeline) ReprocessQuarantined(ctx context.Context, id int64, force bool) (*api.QuarantineReprocessData, error) {
+	msg, err := p.db.GetQuarantinedMessage(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	route := ParseTopic(msg.Topic)
+	if route == nil || route.Handler != msg.Handler {
+		return nil, fmt.Errorf("topic %q no longer routes to handler %q", msg.Topic, msg.Handler)
+	}
+
+	result := &api.QuarantineReprocessData{ID: id}
+	if !force {
+		if problems := validatePayload(route, msg.Topic, msg.Payload, time.Now()); len(problems) > 0 {
+			if err := p.db.UpdateQuarantineResult(ctx, id, "pending", "", problems); err != nil {
+				return nil, err
+			}
+			result.Status = "pending"
+			result.Reasons = problems
+			return result, nil
+		}
+	}
+
+	p.incHandler(route.Handler)
+	if herr := p.runHandler(route, msg.Topic, msg.Payload); herr != nil {
+		result.Status = "failed"
+		result.Error = herr.Error()
+	} else {
+		result.Status = "reprocessed"
+	}
+	if err := p.db.UpdateQuarantineResult(ctx, id, result.Status, result.Error, nil); err != nil {
+		return nil, err
+	}
+	p.log.Info().Int64("id", id).Str("handler", route.Handler).Str("status", result.Status).Msg("quarantined message reprocessed")
+	return result, nil
+}
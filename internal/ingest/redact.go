@@ -0,0 +1,213 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Raw archive redaction.
+//
+// Before a message is stored in mqtt_raw_messages, the rules for its handler
+// (plus the "*" rules, which apply to every handler) strip JSON paths and cap
+// the payload size. RAW_REDACT holds the rules:
+//
+//	handler:item,item;handler:item,...
+//
+// An item is a dot-separated JSON path to remove, or max=<bytes>. Path
+// segments follow validate.go: "x[]" applies the rest of the path to every
+// element of array x; "*" matches every key of an object (e.g.
+// "*.incidentdata" for unit events keyed by event type). A payload still
+// larger than max after stripping is replaced with a truncation marker:
+//
+//	{"_truncated": true, "size": <bytes before truncation>, "head": "<first max bytes>"}
+//
+// The base64 audio in audio messages is always stripped (it is already saved
+// to the audio store).
+
+// RawRedactRule is the redaction applied to one handler's raw messages.
+type RawRedactRule struct {
+	Paths   [][]string
+	MaxSize int // 0 = unlimited
+}
+
+// RawRedactRules maps handler name ("*" = all handlers) to its rule.
+type RawRedactRules map[string]*RawRedactRule
+
+// audioRedactPaths are always stripped from audio messages.
+var audioRedactPaths = [][]string{
+	{"call", "audio_m4a_base64"},
+	{"call", "audio_wav_base64"},
+}
+
+// ParseRawRedact parses a RAW_REDACT value.
+func ParseRawRedact(s string) (RawRedactRules, error) {
+	rules := RawRedactRules{}
+	for _, spec := range strings.Split(s, ";") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		handler, items, ok := strings.Cut(spec, ":")
+		handler = strings.TrimSpace(handler)
+		if !ok || handler == "" {
+			return nil, fmt.Errorf("rule %q: expected handler:item,...", spec)
+		}
+		rule := rules[handler]
+		if rule == nil {
+			rule = &RawRedactRule{}
+			rules[handler] = rule
+		}
+		for _, item := range strings.Split(items, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			if v, ok := strings.CutPrefix(item, "max="); ok {
+				n, err := strconv.Atoi(v)
+				if err != nil || n < 64 {
+					return nil, fmt.Errorf("rule %q: max must be an integer of at least 64", spec)
+				}
+				rule.MaxSize = n
+				continue
+			}
+			segs := strings.Split(item, ".")
+			for _, seg := range segs {
+				if seg == "" || seg == "[]" {
+					return nil, fmt.Errorf("rule %q: invalid path %q", spec, item)
+				}
+			}
+			rule.Paths = append(rule.Paths, segs)
+		}
+	}
+	return rules, nil
+}
+
+// redact applies the rules for handler to a raw payload. Payloads that are
+// not JSON objects are only size-capped.
+func (rr RawRedactRules) redact(handler string, payload []byte) []byte {
+	var paths [][]string
+	if handler == "audio" {
+		paths = append(paths, audioRedactPaths...)
+	}
+	maxSize := 0
+	if r := rr["*"]; r != nil {
+		paths = append(paths, r.Paths...)
+		maxSize = r.MaxSize
+	}
+	if r := rr[handler]; r != nil {
+		paths = append(paths, r.Paths...)
+		if r.MaxSize > 0 {
+			maxSize = r.MaxSize
+		}
+	}
+
+	out := stripJSONPaths(payload, paths)
+	if maxSize > 0 && len(out) > maxSize {
+		out = truncationMarker(out, maxSize)
+	}
+	return out
+}
+
+// stripJSONPaths removes paths from a JSON payload. It returns payload
+// unchanged when nothing was removed or it can't be parsed.
+func stripJSONPaths(payload []byte, paths [][]string) []byte {
+	if len(paths) == 0 {
+		return payload
+	}
+	var doc any
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return payload
+	}
+	removed := false
+	for _, p := range paths {
+		if deletePath(doc, p) {
+			removed = true
+		}
+	}
+	if !removed {
+		return payload
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return payload
+	}
+	return out
+}
+
+// deletePath removes the value at segs below v and reports whether anything
+// was removed.
+func deletePath(v any, segs []string) bool {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return false
+	}
+	seg := segs[0]
+	if seg == "*" {
+		removed := false
+		for k := range obj {
+			if len(segs) == 1 {
+				delete(obj, k)
+				removed = true
+			} else if deletePath(obj[k], segs[1:]) {
+				removed = true
+			}
+		}
+		return removed
+	}
+
+	key, isArray := strings.CutSuffix(seg, "[]")
+	child, present := obj[key]
+	if !present {
+		return false
+	}
+	if isArray {
+		arr, ok := child.([]any)
+		if !ok || len(segs) == 1 {
+			return false
+		}
+		removed := false
+		for _, el := range arr {
+			if deletePath(el, segs[1:]) {
+				removed = true
+			}
+		}
+		return removed
+	}
+	if len(segs) == 1 {
+		delete(obj, key)
+		return true
+	}
+	return deletePath(child, segs[1:])
+}
+
+// truncationMarker replaces an oversized payload with a JSON object holding
+// its size and first maxSize bytes (cut on a UTF-8 boundary).
+func truncationMarker(payload []byte, maxSize int) []byte {
+	head := payload[:maxSize]
+	// Don't end on a partial multi-byte character
+	for i := 0; i < utf8.UTFMax && len(head) > 0; i++ {
+		if r, _ := utf8.DecodeLastRune(head); r != utf8.RuneError {
+			break
+		}
+		head = head[:len(head)-1]
+	}
+	out, _ := json.Marshal(map[string]any{
+		"_truncated": true,
+		"size":       len(payload),
+		"head":       string(head),
+	})
+	return out
+}
+
+// stripAudioBase64 removes the base64 audio data from audio message payloads
+// before storing in mqtt_raw_messages. The audio is already saved to disk by
+// the audio handler, so keeping it in the DB is pure waste (~60KB per message).
+func stripAudioBase64(payload []byte) []byte {
+	return stripJSONPaths(payload, audioRedactPaths)
+}
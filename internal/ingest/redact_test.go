@@ -0,0 +1,126 @@
+package ingest
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseRawRedact(t *testing.T) {
+	rules, err := ParseRawRedact(" *:max=4096 ; unit_event:*.incidentdata, call.extra ;config:max=128;unit_event:max=256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := rules["*"]; r == nil || r.MaxSize != 4096 || len(r.Paths) != 0 {
+		t.Errorf("* rule = %+v", r)
+	}
+	ue := rules["unit_event"]
+	if ue == nil || ue.MaxSize != 256 || len(ue.Paths) != 2 {
+		t.Fatalf("unit_event rule = %+v", ue)
+	}
+	if strings.Join(ue.Paths[0], "|") != "*|incidentdata" || strings.Join(ue.Paths[1], "|") != "call|extra" {
+		t.Errorf("unit_event paths = %v", ue.Paths)
+	}
+
+	if rules, err := ParseRawRedact(""); err != nil || len(rules) != 0 {
+		t.Errorf("empty = %v, %v", rules, err)
+	}
+	for _, bad := range []string{"call_start", ":max=100", "audio:max=10", "audio:max=x", "audio:call..x", "audio:[]"} {
+		if _, err := ParseRawRedact(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestRawRedact(t *testing.T) {
+	rules, err := ParseRawRedact("*:debug;unit_event:*.incidentdata;systems:systems[].secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	decode := func(b []byte) map[string]any {
+		t.Helper()
+		var m map[string]any
+		if err := json.Unmarshal(b, &m); err != nil {
+			t.Fatalf("%s: %v", b, err)
+		}
+		return m
+	}
+
+	t.Run("wildcard_key", func(t *testing.T) {
+		out := decode(rules.redact("unit_event", []byte(`{"type":"call","call":{"unit":5,"incidentdata":{"x":1}},"debug":"y"}`)))
+		call := out["call"].(map[string]any)
+		if _, ok := call["incidentdata"]; ok {
+			t.Error("incidentdata not stripped")
+		}
+		if call["unit"] != float64(5) {
+			t.Errorf("unit = %v", call["unit"])
+		}
+		if _, ok := out["debug"]; ok {
+			t.Error("* rule not applied")
+		}
+	})
+
+	t.Run("array_elements", func(t *testing.T) {
+		out := decode(rules.redact("systems", []byte(`{"systems":[{"sys_name":"a","secret":1},{"sys_name":"b"}]}`)))
+		systems := out["systems"].([]any)
+		if len(systems) != 2 {
+			t.Fatalf("systems = %v", systems)
+		}
+		for _, s := range systems {
+			if _, ok := s.(map[string]any)["secret"]; ok {
+				t.Errorf("secret not stripped: %v", s)
+			}
+		}
+	})
+
+	t.Run("unchanged_bytes", func(t *testing.T) {
+		in := []byte(`{ "sys_name" : "a",  "n": 12345678901234567890 }`)
+		if out := rules.redact("systems", in); string(out) != string(in) {
+			t.Errorf("got %s", out)
+		}
+		notJSON := []byte("not json")
+		if out := rules.redact("systems", notJSON); string(out) != string(notJSON) {
+			t.Errorf("got %s", out)
+		}
+	})
+
+	t.Run("audio_default", func(t *testing.T) {
+		var none RawRedactRules
+		out := decode(none.redact("audio", []byte(`{"call":{"audio_wav_base64":"AAAA","freq":1}}`)))
+		call := out["call"].(map[string]any)
+		if _, ok := call["audio_wav_base64"]; ok {
+			t.Error("audio not stripped with no rules")
+		}
+	})
+}
+
+func TestRawRedactMaxSize(t *testing.T) {
+	rules, err := ParseRawRedact("*:max=1000;trunking_message:max=64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	big := []byte(`{"message":"` + strings.Repeat("é", 100) + `"}`)
+
+	if out := rules.redact("call_start", big); string(out) != string(big) {
+		t.Errorf("payload under the * max was changed: %s", out)
+	}
+
+	out := rules.redact("trunking_message", big)
+	var marker struct {
+		Truncated bool   `json:"_truncated"`
+		Size      int    `json:"size"`
+		Head      string `json:"head"`
+	}
+	if err := json.Unmarshal(out, &marker); err != nil {
+		t.Fatalf("%s: %v", out, err)
+	}
+	if !marker.Truncated || marker.Size != len(big) {
+		t.Errorf("marker = %+v", marker)
+	}
+	if len(marker.Head) > 64 || !strings.HasPrefix(string(big), marker.Head) {
+		t.Errorf("head = %q", marker.Head)
+	}
+	if strings.ContainsRune(marker.Head, '�') {
+		t.Error("head split a multi-byte character")
+	}
+}
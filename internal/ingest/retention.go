@@ -0,0 +1,237 @@
+package ingest
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/api"
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/storage"
+)
+
+// retentionBatch is how many calls a call or audio purge handles per query.
+const retentionBatch = 5000
+
+// field returns the retention of a database.RetentionTargets entry, or nil.
+func (c *retentionConfig) field(target string) *time.Duration {
+	switch target {
+	case database.RetentionRawMessages:
+		return &c.RawMessages
+	case database.RetentionConsoleLogs:
+		return &c.ConsoleLogs
+	case database.RetentionPluginStatus:
+		return &c.PluginStatus
+	case database.RetentionCheckpoints:
+		return &c.Checkpoints
+	case database.RetentionQuarantine:
+		return &c.Quarantine
+	case database.RetentionExternalEvents:
+		return &c.ExternalEvents
+	case database.RetentionWebhookDeliveries:
+		return &c.WebhookDeliveries
+	case database.RetentionTimeseries:
+		return &c.Timeseries
+	case database.RetentionUnitEvents:
+		return &c.UnitEvents
+	case database.RetentionUnitSessions:
+		return &c.UnitSessions
+	case database.RetentionCalls:
+		return &c.Calls
+	case database.RetentionCallAudio:
+		return &c.CallAudio
+	}
+	return nil
+}
+
+// callAudioAge is how old call audio may get before it is deleted:
+// RETENTION_CALL_AUDIO, capped by RETENTION_CALLS so a call's audio goes
+// before the call does. 0 = kept.
+func (c *retentionConfig) callAudioAge() time.Duration {
+	switch {
+	case c.CallAudio == 0:
+		return c.Calls
+	case c.Calls == 0:
+		return c.CallAudio
+	}
+	return min(c.CallAudio, c.Calls)
+}
+
+// effectiveRetention returns the configured retention with the admin
+// overrides in retention_settings applied, and the overrides themselves.
+// If the overrides can't be loaded, the configured retention is returned
+// with the error.
+func (p *Pipeline) effectiveRetention(ctx context.Context) (retentionConfig, map[string]database.RetentionSetting, error) {
+	cfg := p.retentionCfg
+	settings, err := p.db.ListRetentionSettings(ctx)
+	for target, s := range settings {
+		if f := cfg.field(target); f != nil {
+			*f = s.Retention
+		}
+	}
+	return cfg, settings, err
+}
+
+// callAudioDeletable reports whether call audio retention can delete audio:
+// only a local-only store holds the sole copy of a call's audio.
+func (p *Pipeline) callAudioDeletable() bool {
+	return p.store != nil && p.store.Type() == "local"
+}
+
+// purgeCallAudio deletes the audio files of calls older than olderThan from
+// the local audio store, oldest first, and clears the calls' audio
+// references, a batch at a time. With freeBytes > 0 it stops once that many
+// bytes are freed. A call whose file can't be removed keeps its references
+// and is retried next run. Returns the number of calls whose audio was
+// deleted.
+func (p *Pipeline) purgeCallAudio(ctx context.Context, olderThan time.Duration, keepPinned bool, freeBytes int64, log zerolog.Logger) (int64, error) {
+	var total, freed int64
+	for {
+		batch, err := p.db.OldCallAudio(ctx, olderThan, retentionBatch, keepPinned)
+		if err != nil {
+			return total, err
+		}
+		var cleared []database.CallRef
+		for _, f := range batch {
+			if freeBytes > 0 && freed >= freeBytes {
+				break
+			}
+			removed := true
+			for _, key := range f.Paths {
+				path := p.store.LocalPath(key)
+				if path == "" {
+					continue // already gone
+				}
+				info, statErr := os.Stat(path)
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					log.Warn().Err(err).Str("path", path).Msg("failed to delete expired call audio")
+					removed = false
+				} else if err == nil && statErr == nil {
+					freed += info.Size()
+				}
+			}
+			if removed {
+				cleared = append(cleared, f.Ref)
+			}
+		}
+		n, err := p.db.ClearCallAudio(ctx, cleared)
+		total += n
+		if err != nil || len(batch) < retentionBatch || len(cleared) == 0 || (freeBytes > 0 && freed >= freeBytes) {
+			return total, err
+		}
+	}
+}
+
+// audioDiskInterval is how often the disk holding AUDIO_DIR is checked
+// against AUDIO_DISK_MAX_PERCENT.
+const audioDiskInterval = 10 * time.Minute
+
+// audioDiskLoop keeps the disk holding AUDIO_DIR under AUDIO_DISK_MAX_PERCENT
+// by deleting the oldest call audio.
+func (p *Pipeline) audioDiskLoop() {
+	log := p.log.With().Str("task", "audio-disk").Logger()
+	if !p.callAudioDeletable() {
+		log.Warn().Msg("AUDIO_DISK_MAX_PERCENT ignored: audio store is not local-only")
+		return
+	}
+
+	p.checkAudioDisk(log)
+	ticker := time.NewTicker(audioDiskInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			p.checkAudioDisk(log)
+		}
+	}
+}
+
+// checkAudioDisk deletes the oldest call audio until the disk holding
+// AUDIO_DIR is back under AUDIO_DISK_MAX_PERCENT. It runs as its own
+// maintenance run (trigger disk_usage) under the audio_purge task, so dry
+// runs and task toggles apply and every deletion is audited. Skipped while
+// scheduled maintenance runs.
+func (p *Pipeline) checkAudioDisk(log zerolog.Logger) {
+	used, size, err := storage.DiskUsage(p.audioDir)
+	if err != nil {
+		log.Warn().Err(err).Str("dir", p.audioDir).Msg("failed to read audio disk usage")
+		return
+	}
+	limit := size / 100 * uint64(p.retentionCfg.AudioDiskMaxPercent)
+	if used <= limit {
+		return
+	}
+	if !p.maintenanceRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer p.maintenanceRunning.Store(false)
+
+	ctx, cancel := context.WithTimeout(p.ctx, 5*time.Minute)
+	defer cancel()
+	need := int64(used - limit)
+	keepPinned := p.retentionCfg.KeepPinned
+	log.Warn().Int("max_percent", p.retentionCfg.AudioDiskMaxPercent).
+		Float64("used_percent", float64(used)*100/float64(size)).
+		Int64("free_bytes", need).Msg("audio disk over threshold, deleting oldest call audio")
+
+	result := api.MaintenanceRunData{StartedAt: time.Now(), Purged: make(map[string]int64)}
+	run := p.startMaintenanceRun(ctx, maintenanceTriggerDisk, false, &result, log)
+	defer run.finish()
+	n, applied, err := run.audited(database.MaintenanceTaskAudioPurge, "call_audio",
+		func() (database.MaintenancePreview, error) { return p.db.PreviewCallAudioBytes(ctx, need, keepPinned) },
+		func() (int64, error) { return p.purgeCallAudio(ctx, 0, keepPinned, need, log) })
+	switch {
+	case err != nil:
+		log.Warn().Err(err).Msg("failed to delete call audio for disk usage")
+	case applied:
+		log.Info().Int64("calls", n).Msg("deleted oldest call audio for disk usage")
+	}
+}
+
+// purgeOldCalls deletes calls older than olderThan, a batch at a time.
+func (p *Pipeline) purgeOldCalls(ctx context.Context, olderThan time.Duration, keepPinned bool) (int64, error) {
+	var total int64
+	for {
+		n, err := p.db.PurgeOldCalls(ctx, olderThan, retentionBatch, keepPinned)
+		total += n
+		if err != nil || n < retentionBatch {
+			return total, err
+		}
+	}
+}
+
+// RetentionStatus returns each retention target's configured retention,
+// admin override and the retention maintenance applies.
+func (p *Pipeline) RetentionStatus(ctx context.Context) ([]api.RetentionStatus, error) {
+	effective, settings, err := p.effectiveRetention(ctx)
+	if err != nil {
+		return nil, err
+	}
+	configured := p.retentionCfg
+	out := make([]api.RetentionStatus, 0, len(database.RetentionTargets))
+	for _, t := range database.RetentionTargets {
+		st := api.RetentionStatus{
+			Target:    t.Name,
+			Optional:  t.Optional,
+			Config:    configured.field(t.Name).String(),
+			Retention: effective.field(t.Name).String(),
+			Source:    "config",
+		}
+		if s, ok := settings[t.Name]; ok {
+			st.Source = "admin"
+			st.UpdatedAt = &s.UpdatedAt
+		}
+		if t.Name == database.RetentionCallAudio {
+			st.Retention = effective.callAudioAge().String()
+			if !p.callAudioDeletable() && effective.callAudioAge() > 0 {
+				st.Note = "audio store is not local-only: audio is not deleted, use the object store's lifecycle rules"
+			}
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
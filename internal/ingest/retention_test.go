@@ -0,0 +1,46 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+func TestRetentionConfigField(t *testing.T) {
+	var cfg retentionConfig
+	seen := make(map[*time.Duration]string)
+	for _, target := range database.RetentionTargets {
+		f := cfg.field(target.Name)
+		if f == nil {
+			t.Errorf("no retention field for target %q", target.Name)
+			continue
+		}
+		if other, ok := seen[f]; ok {
+			t.Errorf("targets %q and %q share a field", target.Name, other)
+		}
+		seen[f] = target.Name
+	}
+	if cfg.field("stale_calls") != nil {
+		t.Error("stale_calls is not a retention target")
+	}
+}
+
+func TestCallAudioAge(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		audio, calls, want time.Duration
+	}{
+		{0, 0, 0},
+		{30 * day, 0, 30 * day},
+		{0, 90 * day, 90 * day},
+		{30 * day, 90 * day, 30 * day},
+		{120 * day, 90 * day, 90 * day},
+	}
+	for _, tt := range tests {
+		cfg := retentionConfig{CallAudio: tt.audio, Calls: tt.calls}
+		if got := cfg.callAudioAge(); got != tt.want {
+			t.Errorf("callAudioAge(audio=%s, calls=%s) = %s, want %s", tt.audio, tt.calls, got, tt.want)
+		}
+	}
+}
@@ -4,7 +4,7 @@ import "strings"
 
 // Route describes a parsed MQTT topic.
 type Route struct {
-	Handler string // handler name: "status", "console", "systems", "system", "calls_active", "call_start", "call_end", "audio", "recorders", "recorder", "rates", "trunking_message", "unit_event" (includes signal)
+	Handler string // handler name: "status", "console", "systems", "system", "calls_active", "call_start", "call_end", "audio", "recorders", "recorder", "rates", "trunking_message", "unit_event" (includes signal), "issi_call"
 	SysName string // set for unit event and trunking message topics
 }
 
@@ -28,6 +28,7 @@ type Route struct {
 //	.../recorder               → recorder
 //	.../rates                  → rates
 //	.../config                 → config
+//	.../issi_call              → issi_call (ISSI/DFSI gateway call record)
 //
 // Trunking message topics ({message_topic}/...):
 //
@@ -58,7 +59,7 @@ func ParseTopic(topic string) *Route {
 	// Single-segment feed handlers
 	switch last {
 	case "systems", "system", "calls_active", "call_start", "call_end",
-		"audio", "recorders", "recorder", "rates", "config":
+		"audio", "recorders", "recorder", "rates", "config", "issi_call":
 		return &Route{Handler: last}
 	}
 
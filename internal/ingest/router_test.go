@@ -22,6 +22,7 @@ func TestParseTopic(t *testing.T) {
 		{name: "recorder", topic: "trengine/feeds/recorder", want: &Route{Handler: "recorder"}},
 		{name: "rates", topic: "trengine/feeds/rates", want: &Route{Handler: "rates"}},
 		{name: "config", topic: "trengine/feeds/config", want: &Route{Handler: "config"}},
+		{name: "issi_call", topic: "gateway/issi_call", want: &Route{Handler: "issi_call"}},
 
 		// Trunking messages with SysName extraction
 		{name: "trunking_butco", topic: "trengine/messages/butco/message", want: &Route{Handler: "trunking_message", SysName: "butco"}},
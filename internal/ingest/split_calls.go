@@ -0,0 +1,71 @@
+package ingest
+
+import (
+	"context"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/metrics"
+)
+
+// checkSplitCall merges a completed call into the call it continues when TR
+// split one transmission in two: a retune can end a call and start a new
+// one seconds later on the same talkgroup, keyed by the same unit. The
+// earlier call must have stopped no more than SPLIT_CALL_MAX_GAP before
+// this one started. The merge only hides this call from call lists (see
+// call_merges), so it can be undone.
+//
+// It runs once the call's initiating unit is known: at call_end for
+// encrypted calls, otherwise once the audio's srcList has been stored.
+func (p *Pipeline) checkSplitCall(ctx context.Context, systemID, tgid, unit int, callID int64, start time.Time) {
+	if p.splitCallMaxGap <= 0 || unit <= 0 || tgid <= 0 || callID <= 0 {
+		return
+	}
+	prev, err := p.db.FindSplitPredecessor(ctx, systemID, tgid, unit, callID, start, p.splitCallMaxGap)
+	if err != nil {
+		p.log.Warn().Err(err).Int64("call_id", callID).Msg("split call check failed")
+		return
+	}
+	if prev == nil {
+		return
+	}
+	gap := start.Sub(prev.StopTime).Seconds()
+	if gap < 0 {
+		gap = 0 // start/stop times are whole seconds
+	}
+	id, err := p.db.InsertCallMerge(ctx, database.CallMerge{
+		SystemID:        systemID,
+		Tgid:            tgid,
+		UnitID:          unit,
+		CallID:          prev.CallID,
+		CallStartTime:   prev.StartTime,
+		MergedCallID:    callID,
+		MergedStartTime: start,
+		Gap:             float32(gap),
+	})
+	if err != nil {
+		p.log.Warn().Err(err).Int64("call_id", callID).Msg("failed to merge split call")
+		return
+	}
+	if id == 0 {
+		return // already merged
+	}
+	metrics.SplitCallsMergedTotal.Inc()
+	p.log.Info().
+		Int64("merge_id", id).
+		Int64("call_id", prev.CallID).
+		Int64("merged_call_id", callID).
+		Int("tgid", tgid).
+		Int("unit", unit).
+		Float64("gap", gap).
+		Msg("merged split call")
+}
+
+// initiatingUnit returns the unit that keyed up a call from its srcList,
+// matching how the database picks it (first entry).
+func initiatingUnit(srcList []SrcItem) int {
+	if len(srcList) == 0 || srcList[0].Src < 0 {
+		return 0
+	}
+	return srcList[0].Src
+}
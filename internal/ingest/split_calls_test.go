@@ -0,0 +1,34 @@
+package ingest
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestInitiatingUnit(t *testing.T) {
+	tests := []struct {
+		src  []SrcItem
+		want int
+	}{
+		{nil, 0},
+		{[]SrcItem{{Src: 1234}, {Src: 5678}}, 1234},
+		{[]SrcItem{{Src: -1}, {Src: 5678}}, 0}, // unknown first unit: don't guess
+	}
+	for _, tt := range tests {
+		if got := initiatingUnit(tt.src); got != tt.want {
+			t.Errorf("initiatingUnit(%+v) = %d, want %d", tt.src, got, tt.want)
+		}
+	}
+}
+
+// With SPLIT_CALL_MAX_GAP unset, or without a unit to match on, the check
+// returns before touching the database.
+func TestCheckSplitCallSkips(t *testing.T) {
+	p := &Pipeline{}
+	p.checkSplitCall(context.Background(), 1, 100, 1234, 42, time.Now())
+
+	p.splitCallMaxGap = 3 * time.Second
+	p.checkSplitCall(context.Background(), 1, 100, 0, 42, time.Now())
+	p.checkSplitCall(context.Background(), 1, 0, 1234, 42, time.Now())
+}
@@ -0,0 +1,120 @@
+package ingest
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/audio"
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/metrics"
+)
+
+// storagePolicies caches talkgroup_storage_policies for the ingest hot path.
+type storagePolicies struct {
+	mu       sync.RWMutex
+	policies map[[2]int]database.StoragePolicy
+}
+
+func (sp *storagePolicies) set(list []database.StoragePolicy) {
+	m := make(map[[2]int]database.StoragePolicy, len(list))
+	for _, p := range list {
+		m[[2]int{p.SystemID, p.Tgid}] = p
+	}
+	sp.mu.Lock()
+	sp.policies = m
+	sp.mu.Unlock()
+}
+
+// get returns a talkgroup's storage policy; full when it has none.
+func (sp *storagePolicies) get(systemID, tgid int) database.StoragePolicy {
+	sp.mu.RLock()
+	defer sp.mu.RUnlock()
+	if p, ok := sp.policies[[2]int{systemID, tgid}]; ok {
+		return p
+	}
+	return database.StoragePolicy{SystemID: systemID, Tgid: tgid, Policy: database.StoragePolicyFull}
+}
+
+// ReloadStoragePolicies reloads talkgroup storage policies from the
+// database. Called at startup and after admin changes.
+func (p *Pipeline) ReloadStoragePolicies(ctx context.Context) error {
+	list, err := p.db.ListStoragePolicies(ctx)
+	if err != nil {
+		return err
+	}
+	p.storagePolicies.set(list)
+	return nil
+}
+
+// storagePolicy returns the storage policy for a call starting at startTime:
+// the talkgroup's, or full when a legal hold covers the call.
+func (p *Pipeline) storagePolicy(systemID, tgid int, startTime time.Time) database.StoragePolicy {
+	if p.legalHolds.covers(systemID, tgid, 0, startTime) {
+		return database.StoragePolicy{SystemID: systemID, Tgid: tgid, Policy: database.StoragePolicyFull}
+	}
+	return p.storagePolicies.get(systemID, tgid)
+}
+
+// audioDropped reports whether a call's audio is dropped without being
+// decoded: its system is metadata-only or its talkgroup's storage policy
+// keeps no audio.
+func (p *Pipeline) audioDropped(systemID, tgid int, startTime time.Time) bool {
+	if p.ingestPolicy(systemID, tgid, startTime) == database.IngestPolicyMetadata {
+		return true
+	}
+	return p.storagePolicy(systemID, tgid, startTime).Policy == database.StoragePolicyMetadata
+}
+
+// KeepsCallAudio reports whether a call's audio file is linked to the call
+// under its ingest and storage policies, for audio attached after the fact.
+func (p *Pipeline) KeepsCallAudio(systemID, tgid int, startTime time.Time) bool {
+	return !p.audioDropped(systemID, tgid, startTime) && !p.transcriptOnly(systemID, tgid, startTime)
+}
+
+// applyStoragePolicy returns the audio to store for a call, with its type and
+// filename: unchanged for full, re-encoded to Opus for transcode. A failed
+// transcode keeps the original so no audio is lost. Callers check
+// audioDropped first.
+func (p *Pipeline) applyStoragePolicy(ctx context.Context, systemID, tgid int, startTime time.Time, data []byte, audioType, filename string) ([]byte, string, string) {
+	pol := p.storagePolicy(systemID, tgid, startTime)
+	if pol.Policy != database.StoragePolicyTranscode || audioType == "opus" {
+		return data, audioType, filename
+	}
+	bitrate := pol.Bitrate
+	if bitrate <= 0 {
+		bitrate = database.DefaultTranscodeBitrate
+	}
+	out, err := audio.TranscodeOpus(ctx, data, audioType, bitrate)
+	if err != nil {
+		metrics.AudioStoragePolicyTotal.WithLabelValues("transcode_failed").Inc()
+		p.log.Warn().Err(err).Int("system_id", systemID).Int("tgid", tgid).
+			Msg("audio transcode failed, storing original")
+		return data, audioType, filename
+	}
+	metrics.AudioStoragePolicyTotal.WithLabelValues("transcoded").Inc()
+	if filename != "" {
+		filename = strings.TrimSuffix(filename, filepath.Ext(filename)) + ".opus"
+	}
+	return out, "opus", filename
+}
+
+// registerAudio records stored call audio as the call's "original" variant,
+// or as "transcoded" when the storage policy re-encoded it.
+func (p *Pipeline) registerAudio(ctx context.Context, callID int64, startTime time.Time, key, receivedType, storedType string, size int, source string) error {
+	variant := database.AudioVariantOriginal
+	if storedType != receivedType {
+		variant = database.AudioVariantTranscoded
+	}
+	return p.db.AddCallAudioVariant(ctx, database.AudioVariant{
+		CallID:        callID,
+		CallStartTime: startTime,
+		Variant:       variant,
+		AudioPath:     key,
+		AudioType:     storedType,
+		AudioSize:     size,
+		Source:        source,
+	}, true)
+}
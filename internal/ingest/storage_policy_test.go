@@ -0,0 +1,65 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/audio"
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+func TestStoragePolicies(t *testing.T) {
+	p := &Pipeline{log: zerolog.Nop()}
+	if got := p.storagePolicies.get(1, 100).Policy; got != database.StoragePolicyFull {
+		t.Errorf("no policies: got %q, want full", got)
+	}
+
+	p.storagePolicies.set([]database.StoragePolicy{
+		{SystemID: 1, Tgid: 100, Policy: database.StoragePolicyMetadata},
+		{SystemID: 1, Tgid: 200, Policy: database.StoragePolicyTranscode, Bitrate: 12000},
+	})
+	if !p.audioDropped(1, 100, time.Time{}) {
+		t.Error("metadata talkgroup should drop audio")
+	}
+	if p.audioDropped(2, 100, time.Time{}) || p.audioDropped(1, 200, time.Time{}) {
+		t.Error("only the metadata talkgroup drops audio")
+	}
+
+	// A legal hold keeps full audio whatever the policy
+	sys, tg := 1, 100
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	p.legalHolds.set([]database.LegalHold{{Active: true, SystemID: &sys, Tgid: &tg, StartTime: &from}})
+	if p.audioDropped(1, 100, from) {
+		t.Error("held call should keep its audio")
+	}
+	if !p.audioDropped(1, 100, from.Add(-time.Hour)) {
+		t.Error("call before the hold should drop audio")
+	}
+	p.legalHolds.set(nil)
+
+	// Full keeps the audio untouched.
+	data, typ, name := p.applyStoragePolicy(context.Background(), 1, 300, time.Time{}, []byte("abc"), "m4a", "a.m4a")
+	if string(data) != "abc" || typ != "m4a" || name != "a.m4a" {
+		t.Errorf("full: got %q %q %q", data, typ, name)
+	}
+
+	var wav bytes.Buffer
+	if err := audio.WriteWAV(&wav, make([]int16, 8000), 8000); err != nil {
+		t.Fatal(err)
+	}
+	data, typ, name = p.applyStoragePolicy(context.Background(), 1, 200, time.Time{}, wav.Bytes(), "wav", "9044-1708881234.wav")
+	if !audio.CheckFFmpeg() {
+		// Without ffmpeg the original is kept.
+		if !bytes.Equal(data, wav.Bytes()) || typ != "wav" || name != "9044-1708881234.wav" {
+			t.Errorf("transcode fallback: got type %q name %q", typ, name)
+		}
+		return
+	}
+	if typ != "opus" || name != "9044-1708881234.opus" || !bytes.HasPrefix(data, []byte("OggS")) {
+		t.Errorf("transcode: got type %q name %q header %q", typ, name, data[:min(4, len(data))])
+	}
+}
@@ -0,0 +1,199 @@
+package ingest
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/audio"
+	"github.com/snarg/tr-engine/internal/metrics"
+	"github.com/snarg/tr-engine/pkg/events"
+)
+
+// stuckMicAudioWait is how long a flagged call waits for its audio before it
+// is forgotten (the flag stays set in the database).
+const stuckMicAudioWait = time.Hour
+
+// stuckMicCall is what calls_active has shown of one active call.
+type stuckMicCall struct {
+	units   map[int]bool // distinct non-zero units heard
+	flagged bool
+}
+
+// stuckMicTracker detects continuously-keyed (stuck microphone) calls. A
+// call is suspected once it has run for minDuration with a single unit
+// keyed; when its audio arrives the speech ratio decides whether it really
+// was an open mic (little speech) or just a long transmission.
+type stuckMicTracker struct {
+	minDuration       time.Duration // 0 = disabled
+	maxSpeechRatio    float64
+	skipTranscription bool
+
+	mu      sync.Mutex
+	active  map[string]*stuckMicCall // TR call ID → state
+	pending map[int64]time.Time      // flagged call_id → when flagged, until its audio is checked
+}
+
+func newStuckMicTracker(minDuration time.Duration, maxSpeechRatio float64, skipTranscription bool) *stuckMicTracker {
+	return &stuckMicTracker{
+		minDuration:       minDuration,
+		maxSpeechRatio:    maxSpeechRatio,
+		skipTranscription: skipTranscription,
+		active:            make(map[string]*stuckMicCall),
+		pending:           make(map[int64]time.Time),
+	}
+}
+
+// Observe records a calls_active sighting of a call and reports whether it
+// has just become a stuck mic suspect: keyed for at least minDuration with no
+// more than one unit heard. Each call is reported once.
+func (t *stuckMicTracker) Observe(trCallID string, callID int64, elapsed time.Duration, units ...int) bool {
+	if t == nil || t.minDuration <= 0 {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c, ok := t.active[trCallID]
+	if !ok {
+		c = &stuckMicCall{units: make(map[int]bool)}
+		t.active[trCallID] = c
+	}
+	for _, u := range units {
+		if u > 0 {
+			c.units[u] = true
+		}
+	}
+	if c.flagged || elapsed < t.minDuration || len(c.units) > 1 {
+		return false
+	}
+	c.flagged = true
+	t.pending[callID] = time.Now()
+	return true
+}
+
+// Prune forgets calls no longer active and flagged calls whose audio never
+// arrived.
+func (t *stuckMicTracker) Prune(active map[string]CallData, now time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id := range t.active {
+		if _, ok := active[id]; !ok {
+			delete(t.active, id)
+		}
+	}
+	for id, at := range t.pending {
+		if now.Sub(at) > stuckMicAudioWait {
+			delete(t.pending, id)
+		}
+	}
+}
+
+// Take reports whether callID is a flagged suspect awaiting its audio check,
+// and removes it.
+func (t *stuckMicTracker) Take(callID int64) bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.pending[callID]
+	delete(t.pending, callID)
+	return ok
+}
+
+// checkStuckMic flags a still-active call that has been keyed by one unit for
+// longer than STUCK_MIC_MIN_DURATION and alerts subscribers.
+func (p *Pipeline) checkStuckMic(ctx context.Context, trCallID string, entry activeCallEntry, c CallData) {
+	elapsed := time.Duration(c.Elapsed) * time.Second
+	if !p.stuckMic.Observe(trCallID, entry.CallID, elapsed, entry.Unit, c.Unit) {
+		return
+	}
+
+	if err := p.db.MarkCallStuckMic(ctx, entry.CallID, entry.StartTime); err != nil {
+		p.log.Warn().Err(err).Int64("call_id", entry.CallID).Msg("failed to flag stuck mic call")
+	}
+	metrics.StuckMicCallsTotal.WithLabelValues("flagged").Inc()
+
+	unit, unitTag := entry.Unit, entry.UnitAlphaTag
+	if unit == 0 {
+		unit, unitTag = c.Unit, c.UnitAlphaTag
+	}
+	p.log.Warn().
+		Int64("call_id", entry.CallID).
+		Int("system_id", entry.SystemID).
+		Int("tgid", entry.Tgid).
+		Int("unit", unit).
+		Dur("elapsed", elapsed).
+		Msg("possible stuck mic: call keyed continuously by one unit")
+
+	siteID := 0
+	if entry.SiteID != nil {
+		siteID = *entry.SiteID
+	}
+	p.PublishEvent(EventData{
+		Type:      events.TypeStuckMic,
+		SystemID:  entry.SystemID,
+		SiteID:    siteID,
+		Tgid:      entry.Tgid,
+		UnitID:    unit,
+		Emergency: entry.Emergency,
+		Payload: &events.StuckMic{
+			CallID:       entry.CallID,
+			SystemID:     entry.SystemID,
+			Tgid:         entry.Tgid,
+			TgAlphaTag:   entry.TgAlphaTag,
+			Unit:         unit,
+			UnitAlphaTag: unitTag,
+			Freq:         entry.Freq,
+			StartTime:    entry.StartTime,
+			Elapsed:      elapsed.Seconds(),
+		},
+	})
+}
+
+// confirmStuckMic checks the speech ratio of a flagged call's audio. A call
+// that turns out to be mostly speech is unflagged; otherwise it stays
+// flagged (also when the audio can't be analyzed) and the result reports
+// whether to skip transcribing it.
+func (p *Pipeline) confirmStuckMic(callID int64, startTime time.Time, audioFilePath, callFilename string) (skip bool) {
+	if !p.stuckMic.Take(callID) {
+		return false
+	}
+
+	// Only WAV is analyzed, so don't read other formats at all
+	var ratio float64
+	err := audio.ErrUnsupportedFormat
+	path := audio.ResolveFile(p.audioDir, p.trAudioDir, audioFilePath, callFilename)
+	if strings.HasSuffix(strings.ToLower(path), ".wav") {
+		var data []byte
+		if data, err = os.ReadFile(path); err == nil {
+			ratio, err = audio.SpeechRatio(data, "wav")
+		}
+	}
+	if err != nil {
+		p.log.Debug().Err(err).Int64("call_id", callID).Msg("stuck mic audio not analyzed, keeping flag")
+		metrics.StuckMicCallsTotal.WithLabelValues("confirmed").Inc()
+		return p.stuckMic.skipTranscription
+	}
+
+	stuck := ratio <= p.stuckMic.maxSpeechRatio
+	ctx, cancel := context.WithTimeout(p.ctx, 5*time.Second)
+	defer cancel()
+	if err := p.db.SetCallSpeechRatio(ctx, callID, startTime, float32(ratio), stuck); err != nil {
+		p.log.Warn().Err(err).Int64("call_id", callID).Msg("failed to store speech ratio")
+	}
+	if !stuck {
+		metrics.StuckMicCallsTotal.WithLabelValues("cleared").Inc()
+		p.log.Info().Int64("call_id", callID).Float64("speech_ratio", ratio).Msg("long call has speech, not a stuck mic")
+		return false
+	}
+	metrics.StuckMicCallsTotal.WithLabelValues("confirmed").Inc()
+	p.log.Info().Int64("call_id", callID).Float64("speech_ratio", ratio).Msg("stuck mic confirmed")
+	return p.stuckMic.skipTranscription
+}
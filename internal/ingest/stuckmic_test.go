@@ -0,0 +1,56 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStuckMicTracker(t *testing.T) {
+	tr := newStuckMicTracker(5*time.Minute, 0.2, true)
+
+	// Single unit, under the threshold, then over it: flagged once
+	if tr.Observe("a", 1, 4*time.Minute, 100, 100) {
+		t.Error("flagged before min duration")
+	}
+	if !tr.Observe("a", 1, 5*time.Minute, 100, 100) {
+		t.Error("not flagged at min duration")
+	}
+	if tr.Observe("a", 1, 6*time.Minute, 100, 100) {
+		t.Error("flagged twice")
+	}
+
+	// A second unit during the call means a conversation, not a stuck mic
+	tr.Observe("b", 2, time.Minute, 200, 200)
+	tr.Observe("b", 2, 2*time.Minute, 200, 201)
+	if tr.Observe("b", 2, 10*time.Minute, 200, 200) {
+		t.Error("multi-source call flagged")
+	}
+
+	// Unknown units (0) don't count as sources
+	if !tr.Observe("c", 3, 10*time.Minute, 0, 0) {
+		t.Error("call with unknown unit not flagged")
+	}
+
+	// Audio check takes each flagged call once
+	if !tr.Take(1) || tr.Take(1) {
+		t.Error("Take(1) should succeed exactly once")
+	}
+	if tr.Take(2) {
+		t.Error("unflagged call taken")
+	}
+
+	// Ended calls are forgotten; pending checks expire
+	tr.Prune(map[string]CallData{"c": {}}, time.Now())
+	if _, ok := tr.active["a"]; ok {
+		t.Error("ended call not pruned")
+	}
+	tr.Prune(nil, time.Now().Add(2*stuckMicAudioWait))
+	if tr.Take(3) {
+		t.Error("pending check did not expire")
+	}
+
+	disabled := newStuckMicTracker(0, 0.2, true)
+	if disabled.Observe("a", 1, time.Hour, 100) {
+		t.Error("disabled tracker flagged a call")
+	}
+}
@@ -0,0 +1,37 @@
+package ingest
+
+import (
+	"context"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/metrics"
+	"github.com/snarg/tr-engine/pkg/events"
+)
+
+// ReloadMetricsTalkgroups reloads the per-talkgroup metrics allowlist from
+// the database, seeding each talkgroup's last call. Called at startup and
+// after admin changes.
+func (p *Pipeline) ReloadMetricsTalkgroups(ctx context.Context) error {
+	list, err := p.db.ListMetricsTalkgroups(ctx)
+	if err != nil {
+		return err
+	}
+	tgs := make([]metrics.TalkgroupInfo, len(list))
+	for i, m := range list {
+		tgs[i] = metrics.TalkgroupInfo{SystemID: m.SystemID, Tgid: m.Tgid, AlphaTag: m.AlphaTag}
+		if m.LastCall != nil {
+			tgs[i].LastCall = *m.LastCall
+		}
+	}
+	metrics.Talkgroups.SetTalkgroups(tgs)
+	return nil
+}
+
+// observeTalkgroupCall feeds a call_end to the per-talkgroup metrics.
+func observeTalkgroupCall(ce *events.CallEnd) {
+	end := ce.StopTime
+	if end.IsZero() {
+		end = ce.StartTime.Add(time.Duration(ce.Duration * float64(time.Second)))
+	}
+	metrics.Talkgroups.ObserveCall(ce.SystemID, ce.Tgid, ce.Duration, end)
+}
@@ -0,0 +1,122 @@
+package ingest
+
+import (
+	"context"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// Internal time series: once a minute the pipeline snapshots its counters
+// into system_timeseries so operators without Prometheus can look back at
+// load and throughput (GET /api/v1/admin/timeseries).
+//
+// Counters are stored as per-minute rates, gauges as their value at the
+// sample time.
+
+// counterRates turns monotonically increasing counters into per-minute rates.
+type counterRates struct {
+	last     map[string]int64
+	lastTime time.Time
+}
+
+func newCounterRates() *counterRates {
+	return &counterRates{last: make(map[string]int64)}
+}
+
+// begin starts a sample at now and returns the minutes since the previous
+// one (0 on the first sample).
+func (c *counterRates) begin(now time.Time) float64 {
+	var minutes float64
+	if !c.lastTime.IsZero() {
+		minutes = now.Sub(c.lastTime).Minutes()
+	}
+	c.lastTime = now
+	return minutes
+}
+
+// rate records counter v and returns its increase per minute over minutes.
+// Reports false for a counter's first value or after a reset.
+func (c *counterRates) rate(key string, v int64, minutes float64) (float64, bool) {
+	prev, seen := c.last[key]
+	c.last[key] = v
+	if !seen || v < prev || minutes <= 0 {
+		return 0, false
+	}
+	return float64(v-prev) / minutes, true
+}
+
+// timeseriesSamples snapshots the pipeline's counters and gauges.
+func (p *Pipeline) timeseriesSamples(c *counterRates, now time.Time) []database.TimeseriesSample {
+	minutes := c.begin(now)
+	var out []database.TimeseriesSample
+	gauge := func(metric, label string, v float64) {
+		out = append(out, database.TimeseriesSample{Metric: metric, Label: label, Value: v})
+	}
+	counter := func(metric, label string, v int64) {
+		if r, ok := c.rate(metric+"\x00"+label, v, minutes); ok {
+			gauge(metric, label, r)
+		}
+	}
+
+	counter("calls", "", p.callCount.Load())
+	counter("messages", "", p.msgCount.Load())
+	for handler, n := range p.HandlerCounts() {
+		counter("messages", handler, n)
+	}
+
+	gauge("active_calls", "", float64(p.activeCalls.Len()))
+	if p.eventBus != nil {
+		gauge("sse_subscribers", "", float64(p.eventBus.SubscriberCount()))
+	}
+
+	for _, b := range []struct {
+		name    string
+		pending int
+		flushed int64
+	}{
+		{"raw_messages", p.rawBatcher.Pending(), p.rawBatcher.Flushed()},
+		{"recorder_snapshots", p.recorderBatcher.Pending(), p.recorderBatcher.Flushed()},
+		{"trunking_messages", p.trunkingBatcher.Pending(), p.trunkingBatcher.Flushed()},
+	} {
+		counter("batcher_rows", b.name, b.flushed)
+		gauge("batcher_pending", b.name, float64(b.pending))
+	}
+
+	if p.transcriber != nil {
+		s := p.transcriber.Stats()
+		gauge("transcribe_pending", "", float64(s.Pending))
+		gauge("transcribe_in_flight", "", float64(s.InFlight))
+		counter("transcribe_completed", "", s.Completed)
+		counter("transcribe_failed", "", s.Failed)
+	}
+
+	if p.db != nil && p.db.Pool != nil {
+		st := p.db.Pool.Stat()
+		gauge("db_conns_acquired", "", float64(st.AcquiredConns()))
+		gauge("db_conns_total", "", float64(st.TotalConns()))
+		counter("db_acquires", "", st.AcquireCount())
+	}
+	return out
+}
+
+// timeseriesLoop writes a sample at the start of every minute.
+func (p *Pipeline) timeseriesLoop() {
+	rates := newCounterRates()
+	for {
+		now := time.Now()
+		next := now.Truncate(time.Minute).Add(time.Minute)
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-time.After(next.Sub(now)):
+		}
+
+		samples := p.timeseriesSamples(rates, next)
+		ctx, cancel := context.WithTimeout(p.ctx, 30*time.Second)
+		if err := p.db.InsertTimeseries(ctx, next, samples); err != nil {
+			p.log.Warn().Err(err).Msg("failed to store time series sample")
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,76 @@
+package ingest
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+func TestCounterRates(t *testing.T) {
+	c := newCounterRates()
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	m := c.begin(t0)
+	if _, ok := c.rate("x", 100, m); ok {
+		t.Error("first value should not produce a rate")
+	}
+	m = c.begin(t0.Add(2 * time.Minute))
+	if r, ok := c.rate("x", 160, m); !ok || r != 30 {
+		t.Errorf("rate = %v, %v; want 30/min", r, ok)
+	}
+	m = c.begin(t0.Add(3 * time.Minute))
+	if _, ok := c.rate("x", 5, m); ok {
+		t.Error("counter reset should not produce a rate")
+	}
+	m = c.begin(t0.Add(4 * time.Minute))
+	if r, ok := c.rate("x", 25, m); !ok || r != 20 {
+		t.Errorf("rate after reset = %v, %v; want 20", r, ok)
+	}
+}
+
+func TestTimeseriesSamples(t *testing.T) {
+	p := &Pipeline{
+		activeCalls:     newActiveCallMap(),
+		rawBatcher:      NewBatcher[database.RawMessageRow](100, time.Hour, func([]database.RawMessageRow) {}),
+		recorderBatcher: NewBatcher[database.RecorderSnapshotRow](100, time.Hour, func([]database.RecorderSnapshotRow) {}),
+		trunkingBatcher: NewBatcher[database.TrunkingMessageRow](100, time.Hour, func([]database.TrunkingMessageRow) {}),
+	}
+	calls := &atomic.Int64{}
+	p.handlerCount.Store("call_end", calls)
+	rates := newCounterRates()
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	first := p.timeseriesSamples(rates, t0)
+	for _, s := range first {
+		if s.Metric == "calls" || s.Metric == "messages" {
+			t.Errorf("first sample has rate %+v", s)
+		}
+	}
+
+	p.callCount.Add(12)
+	p.msgCount.Add(300)
+	calls.Add(12)
+	p.rawBatcher.Add(database.RawMessageRow{})
+
+	got := map[string]float64{}
+	for _, s := range p.timeseriesSamples(rates, t0.Add(time.Minute)) {
+		got[s.Metric+"/"+s.Label] = s.Value
+	}
+	for key, want := range map[string]float64{
+		"calls/":                       12,
+		"messages/":                    300,
+		"messages/call_end":            12,
+		"active_calls/":                0,
+		"batcher_pending/raw_messages": 1,
+		"batcher_rows/raw_messages":    0,
+	} {
+		if v, ok := got[key]; !ok || v != want {
+			t.Errorf("%s = %v (present %v), want %v", key, v, ok, want)
+		}
+	}
+	if _, ok := got["transcribe_pending/"]; ok {
+		t.Error("transcription gauges reported with transcription disabled")
+	}
+}
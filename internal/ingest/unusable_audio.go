@@ -0,0 +1,61 @@
+package ingest
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/audio"
+	"github.com/snarg/tr-engine/internal/metrics"
+)
+
+// checkAudioUsable flags a call whose recording is empty or silent and
+// reports whether it is unusable, so the caller skips transcribing it. data is
+// the audio in memory when available; path is the file on disk, analyzed when
+// the in-memory copy can't be. With AUDIO_UNUSABLE_DELETE the file at path is
+// removed if deletable (false when another copy, e.g. in S3, would be left
+// behind) and the call isn't under a legal hold. Best-effort: failures are
+// logged.
+func (p *Pipeline) checkAudioUsable(ctx context.Context, callID int64, startTime time.Time, data []byte, format, path string, deletable bool) bool {
+	if !p.unusableCheck {
+		return false
+	}
+
+	var reason string
+	checked := false
+	if data != nil {
+		reason, checked = audio.Unusable(data, format, p.silenceLevel)
+	}
+	if !checked && path != "" {
+		var err error
+		if reason, _, err = audio.UnusableFile(ctx, path, p.silenceLevel); err != nil {
+			p.log.Debug().Err(err).Int64("call_id", callID).Msg("failed to check audio for silence")
+		}
+	}
+	if reason == "" {
+		return false
+	}
+
+	deleted := false
+	if p.unusableDelete && deletable && path != "" {
+		// Keep the file when the hold can't be checked
+		if held, err := p.db.CallOnHold(ctx, callID, startTime); err != nil || held {
+			p.log.Debug().Err(err).Int64("call_id", callID).Msg("keeping unusable audio under legal hold")
+		} else if err := os.Remove(path); err != nil {
+			p.log.Warn().Err(err).Str("path", path).Msg("failed to delete unusable audio")
+		} else {
+			deleted = true
+		}
+	}
+	if err := p.db.MarkCallAudioUnusable(ctx, callID, startTime, reason, deleted); err != nil {
+		p.log.Warn().Err(err).Int64("call_id", callID).Msg("failed to flag unusable audio")
+	}
+	metrics.AudioUnusableTotal.WithLabelValues(reason).Inc()
+	p.log.Info().
+		Int64("call_id", callID).
+		Str("reason", reason).
+		Str("path", path).
+		Bool("deleted", deleted).
+		Msg("unusable audio, skipping transcription")
+	return true
+}
@@ -0,0 +1,326 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Ingest payload validation.
+//
+// Each MQTT handler has a declarative schema listing the fields it depends on.
+// Payloads that fail validation are quarantined (see quarantineInvalid) instead
+// of reaching the handler, so malformed plugin output shows up as an explicit
+// list of reasons rather than a cryptic handler error.
+//
+// Paths are dot-separated. A segment ending in "[]" applies the remainder of
+// the path to every element of that array. The "$event" segment is replaced
+// with the unit event type taken from the topic (unit event payloads are keyed
+// by event type, e.g. {"on": {...}}).
+
+// fieldKind is the JSON type a field must have.
+type fieldKind int
+
+const (
+	kindAny fieldKind = iota
+	kindNumber
+	kindString
+	kindBool
+	kindObject
+	kindArray
+)
+
+func (k fieldKind) String() string {
+	switch k {
+	case kindNumber:
+		return "number"
+	case kindString:
+		return "string"
+	case kindBool:
+		return "boolean"
+	case kindObject:
+		return "object"
+	case kindArray:
+		return "array"
+	}
+	return "any"
+}
+
+// fieldRule constrains a single field.
+type fieldRule struct {
+	path     string
+	kind     fieldKind
+	required bool
+	hasRange bool
+	min, max float64
+	epoch    bool // unix seconds; must be within [minEpoch, now+maxEpochSkew]
+}
+
+func req(path string, kind fieldKind) fieldRule {
+	return fieldRule{path: path, kind: kind, required: true}
+}
+func opt(path string, kind fieldKind) fieldRule { return fieldRule{path: path, kind: kind} }
+
+// between adds an inclusive numeric range check.
+func (r fieldRule) between(min, max float64) fieldRule {
+	r.hasRange, r.min, r.max = true, min, max
+	return r
+}
+
+// epochSeconds marks the field as a unix timestamp subject to sanity checks.
+// Optional epoch fields may be 0 (TR sends 0 for "not set", e.g. stop_time on call_start).
+func (r fieldRule) epochSeconds() fieldRule {
+	r.kind, r.epoch = kindNumber, true
+	return r
+}
+
+const (
+	minEpoch     = 946684800 // 2000-01-01 — anything earlier is a clock or encoding bug
+	maxEpochSkew = 24 * time.Hour
+	maxFreqHz    = 10e9
+	maxTgid      = 16777215 // 24-bit (DMR); P25 talkgroups fit well within
+)
+
+// envelopeRules apply to every handler.
+var envelopeRules = []fieldRule{
+	req("timestamp", kindNumber).epochSeconds(),
+	opt("instance_id", kindString),
+	opt("type", kindString),
+}
+
+// callRules are shared by call_start, call_end, and each calls_active entry.
+func callRules(prefix string) []fieldRule {
+	return []fieldRule{
+		req(prefix+"id", kindString),
+		req(prefix+"sys_name", kindString),
+		req(prefix+"talkgroup", kindNumber).between(0, maxTgid),
+		opt(prefix+"unit", kindNumber),
+		opt(prefix+"freq", kindNumber).between(0, maxFreqHz),
+		req(prefix+"start_time", kindNumber).epochSeconds(),
+		opt(prefix+"stop_time", kindNumber).epochSeconds(),
+		opt(prefix+"length", kindNumber).between(0, 86400),
+	}
+}
+
+// payloadSchemas maps handler name → rules (in addition to envelopeRules).
+var payloadSchemas = map[string][]fieldRule{
+	"status": {
+		req("status", kindString),
+	},
+	"console": {
+		req("console", kindObject),
+		opt("console.log_msg", kindString),
+		opt("console.severity", kindString),
+	},
+	"systems": {
+		req("systems", kindArray),
+		req("systems[].sys_name", kindString),
+		opt("systems[].sys_num", kindNumber).between(0, 1000),
+		opt("systems[].type", kindString),
+	},
+	"system": {
+		req("system", kindObject),
+		req("system.sys_name", kindString),
+		opt("system.sys_num", kindNumber).between(0, 1000),
+		opt("system.type", kindString),
+	},
+	"call_start":   append([]fieldRule{req("call", kindObject)}, callRules("call.")...),
+	"call_end":     append([]fieldRule{req("call", kindObject)}, callRules("call.")...),
+	"calls_active": append([]fieldRule{req("calls", kindArray)}, callRules("calls[].")...),
+	"audio": {
+		req("call", kindObject),
+		req("call.metadata", kindObject),
+		req("call.metadata.talkgroup", kindNumber).between(0, maxTgid),
+		req("call.metadata.start_time", kindNumber).epochSeconds(),
+		opt("call.metadata.stop_time", kindNumber).epochSeconds(),
+		opt("call.metadata.short_name", kindString),
+		opt("call.metadata.freq", kindNumber).between(0, maxFreqHz),
+		opt("call.metadata.call_length", kindNumber).between(0, 86400),
+		opt("call.metadata.srcList", kindArray),
+		opt("call.metadata.freqList", kindArray),
+		opt("call.audio_wav_base64", kindString),
+		opt("call.audio_m4a_base64", kindString),
+	},
+	"recorders": {
+		req("recorders", kindArray),
+		req("recorders[].id", kindString),
+	},
+	"recorder": {
+		req("recorder", kindObject),
+		req("recorder.id", kindString),
+	},
+	"rates": {
+		req("rates", kindArray),
+		req("rates[].sys_name", kindString),
+		opt("rates[].decoderate", kindNumber).between(0, 10000),
+	},
+	"config": {
+		req("config", kindObject),
+	},
+	"trunking_message": {
+		req("message", kindObject),
+		opt("message.sys_name", kindString),
+	},
+	"unit_event": {
+		req("$event", kindObject),
+		req("$event.sys_name", kindString),
+		req("$event.unit", kindNumber),
+		opt("$event.talkgroup", kindNumber).between(0, maxTgid),
+		opt("$event.freq", kindNumber).between(0, maxFreqHz),
+	},
+	// ISSI/DFSI gateway records (see issi.go); start_time may be RFC 3339 or unix
+	"issi_call": {
+		req("start_time", kindAny),
+		opt("gateway_id", kindString),
+		opt("protocol", kindString),
+		opt("system_name", kindString),
+		opt("sgid", kindString),
+		opt("group_id", kindNumber).between(0, maxTgid),
+		opt("suid", kindString),
+		opt("unit_id", kindNumber),
+		opt("frequency", kindNumber).between(0, maxFreqHz),
+		opt("duration", kindNumber).between(0, 86400),
+		opt("transmissions", kindArray),
+	},
+}
+
+// validatePayload checks payload against the schema for route and returns a
+// list of human-readable problems (empty when valid). Handlers without a
+// schema only get the envelope and JSON well-formedness checks.
+func validatePayload(route *Route, topic string, payload []byte, now time.Time) []string {
+	var doc map[string]any
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return []string{"invalid JSON: " + err.Error()}
+	}
+
+	eventType := ""
+	if route.Handler == "unit_event" {
+		if i := strings.LastIndex(topic, "/"); i >= 0 {
+			eventType = topic[i+1:]
+		}
+	}
+
+	var problems []string
+	check := func(rules []fieldRule) {
+		for _, r := range rules {
+			path := r.path
+			if eventType != "" {
+				path = strings.Replace(path, "$event", eventType, 1)
+			}
+			problems = append(problems, checkRule(doc, strings.Split(path, "."), path, r, now)...)
+		}
+	}
+	// Gateway records are not trunk-recorder messages and carry no envelope
+	if route.Handler != "issi_call" {
+		check(envelopeRules)
+	}
+	check(payloadSchemas[route.Handler])
+	return problems
+}
+
+// checkRule walks doc along segs and validates the value at the end.
+func checkRule(doc any, segs []string, fullPath string, r fieldRule, now time.Time) []string {
+	obj, ok := doc.(map[string]any)
+	if !ok {
+		// Parent type mismatch is reported by the parent's own rule.
+		return nil
+	}
+
+	seg := segs[0]
+	isArray := strings.HasSuffix(seg, "[]")
+	key := strings.TrimSuffix(seg, "[]")
+
+	v, present := obj[key]
+	if !present || v == nil {
+		if r.required && len(segs) == 1 {
+			return []string{fmt.Sprintf("%s: required field missing", fullPath)}
+		}
+		return nil
+	}
+
+	if isArray {
+		arr, ok := v.([]any)
+		if !ok {
+			return nil
+		}
+		var problems []string
+		for i, el := range arr {
+			elPath := strings.Replace(fullPath, key+"[]", fmt.Sprintf("%s[%d]", key, i), 1)
+			problems = append(problems, checkRule(el, segs[1:], elPath, r, now)...)
+		}
+		return problems
+	}
+
+	if len(segs) > 1 {
+		return checkRule(v, segs[1:], fullPath, r, now)
+	}
+	return checkValue(v, fullPath, r, now)
+}
+
+// checkValue applies kind, range, and epoch constraints to a leaf value.
+func checkValue(v any, path string, r fieldRule, now time.Time) []string {
+	if !kindMatches(v, r.kind) {
+		return []string{fmt.Sprintf("%s: expected %s, got %s", path, r.kind, jsonKind(v))}
+	}
+	if r.kind != kindNumber {
+		return nil
+	}
+
+	n, err := v.(json.Number).Float64()
+	if err != nil {
+		return []string{fmt.Sprintf("%s: invalid number %q", path, v)}
+	}
+	if r.hasRange && (n < r.min || n > r.max) {
+		return []string{fmt.Sprintf("%s: %v out of range [%v, %v]", path, v, r.min, r.max)}
+	}
+	if r.epoch {
+		if n == 0 && !r.required {
+			return nil
+		}
+		if n < minEpoch || n > float64(now.Add(maxEpochSkew).Unix()) {
+			return []string{fmt.Sprintf("%s: implausible unix timestamp %v", path, v)}
+		}
+	}
+	return nil
+}
+
+func kindMatches(v any, k fieldKind) bool {
+	switch k {
+	case kindNumber:
+		_, ok := v.(json.Number)
+		return ok
+	case kindString:
+		_, ok := v.(string)
+		return ok
+	case kindBool:
+		_, ok := v.(bool)
+		return ok
+	case kindObject:
+		_, ok := v.(map[string]any)
+		return ok
+	case kindArray:
+		_, ok := v.([]any)
+		return ok
+	}
+	return true
+}
+
+func jsonKind(v any) string {
+	switch v.(type) {
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	}
+	return "null"
+}
@@ -0,0 +1,111 @@
+package ingest
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidatePayload(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name  string
+		topic string
+		body  string
+		want  []string // substrings expected in problems; nil means valid
+	}{
+		{
+			name:  "valid_call_start",
+			topic: "trengine/feeds/call_start",
+			body:  `{"type":"call_start","timestamp":1700000000,"instance_id":"tr1","call":{"id":"1_9131_1700000000","sys_name":"butco","talkgroup":9131,"unit":123,"freq":851000000,"start_time":1700000000,"stop_time":0}}`,
+		},
+		{
+			name:  "missing_fields",
+			topic: "trengine/feeds/call_start",
+			body:  `{"timestamp":1700000000,"call":{"id":"x","talkgroup":9131}}`,
+			want:  []string{"call.sys_name: required field missing", "call.start_time: required field missing"},
+		},
+		{
+			name:  "wrong_type",
+			topic: "trengine/feeds/call_end",
+			body:  `{"timestamp":1700000000,"call":{"id":"x","sys_name":"butco","talkgroup":"9131","start_time":1700000000}}`,
+			want:  []string{"call.talkgroup: expected number, got string"},
+		},
+		{
+			name:  "implausible_epoch",
+			topic: "trengine/feeds/call_start",
+			body:  `{"timestamp":1700000000,"call":{"id":"x","sys_name":"butco","talkgroup":1,"start_time":1700000000000}}`,
+			want:  []string{"call.start_time: implausible unix timestamp"},
+		},
+		{
+			name:  "missing_envelope_timestamp",
+			topic: "trengine/feeds/trunk_recorder/status",
+			body:  `{"status":"connected"}`,
+			want:  []string{"timestamp: required field missing"},
+		},
+		{
+			name:  "unit_event_keyed_by_topic",
+			topic: "trengine/units/butco/on",
+			body:  `{"timestamp":1700000000,"on":{"sys_name":"butco","unit":42,"talkgroup":9131}}`,
+		},
+		{
+			name:  "unit_event_wrong_key",
+			topic: "trengine/units/butco/join",
+			body:  `{"timestamp":1700000000,"on":{"sys_name":"butco","unit":42}}`,
+			want:  []string{"join: required field missing"},
+		},
+		{
+			name:  "array_element_path",
+			topic: "trengine/feeds/rates",
+			body:  `{"timestamp":1700000000,"rates":[{"sys_name":"butco","decoderate":40},{"decoderate":40}]}`,
+			want:  []string{"rates[1].sys_name: required field missing"},
+		},
+		{
+			name:  "out_of_range",
+			topic: "trengine/feeds/call_start",
+			body:  `{"timestamp":1700000000,"call":{"id":"x","sys_name":"butco","talkgroup":99999999,"start_time":1700000000}}`,
+			want:  []string{"call.talkgroup: 99999999 out of range"},
+		},
+		{
+			name:  "issi_call_without_envelope",
+			topic: "gateway/issi_call",
+			body:  `{"sgid":"BEE003481234","start_time":"2026-03-01T12:00:00Z","transmissions":[]}`,
+		},
+		{
+			name:  "issi_call_missing_start",
+			topic: "gateway/issi_call",
+			body:  `{"group_id":"9131"}`,
+			want:  []string{"start_time: required field missing", "group_id: expected number, got string"},
+		},
+		{
+			name:  "invalid_json",
+			topic: "trengine/feeds/call_start",
+			body:  `{"timestamp":`,
+			want:  []string{"invalid JSON"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := ParseTopic(tt.topic)
+			if route == nil {
+				t.Fatalf("ParseTopic(%q) = nil", tt.topic)
+			}
+			got := validatePayload(route, tt.topic, []byte(tt.body), now)
+			if tt.want == nil {
+				if len(got) != 0 {
+					t.Fatalf("validatePayload() = %v, want no problems", got)
+				}
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("validatePayload() = %v, want %d problems", got, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(got[i], want) {
+					t.Errorf("problem[%d] = %q, want substring %q", i, got[i], want)
+				}
+			}
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package ingest
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -15,16 +17,20 @@ import (
 	"github.com/fsnotify/fsnotify"
 	"github.com/rs/zerolog"
 	"github.com/snarg/tr-engine/internal/api"
+	"github.com/snarg/tr-engine/internal/database"
 )
 
 // FileWatcher monitors a trunk-recorder audio output directory for new JSON
 // metadata files and ingests them via the Pipeline. This provides an alternative
 // to MQTT-based ingestion for users who don't have the MQTT plugin configured.
+// With audioOnly set, audio files with no companion .json are also imported,
+// their metadata derived from the path via FILENAME_PATTERNS.
 type FileWatcher struct {
 	pipeline   *Pipeline
 	watchDir   string
 	instanceID string
 	backfillDays int
+	audioOnly    bool
 	log        zerolog.Logger
 
 	watcher *fsnotify.Watcher
@@ -38,14 +44,53 @@ type FileWatcher struct {
 	filesProcessed atomic.Int64
 	filesSkipped   atomic.Int64
 	status         atomic.Value // string: "starting", "backfilling", "watching", "stopped"
+
+	// Set while a retry of failed backfill files runs.
+	retrying atomic.Bool
+}
+
+// fileOutcome is the result of ingesting one watched file, recorded in the
+// backfill report (watch_backfill_files).
+type fileOutcome struct {
+	status string // database.BackfillImported, BackfillSkipped or BackfillError
+	reason string // why the file was skipped or failed
+	detail string // error text, if any
+}
+
+func skippedFile(reason string, err error) fileOutcome {
+	o := fileOutcome{status: database.BackfillSkipped, reason: reason}
+	if err != nil {
+		o.detail = err.Error()
+	}
+	return o
+}
+
+func failedFile(reason string, err error) fileOutcome {
+	return fileOutcome{status: database.BackfillError, reason: reason, detail: err.Error()}
+}
+
+// backfillFile is a file queued for backfill, with the call start time parsed
+// from its name.
+type backfillFile struct {
+	path      string
+	startTime int64
 }
 
-func newFileWatcher(p *Pipeline, watchDir, instanceID string, backfillDays int) *FileWatcher {
+// watchAudioExts are the audio extensions looked for next to a .json, in
+// preference order.
+var watchAudioExts = []string{".m4a", ".wav", ".mp3"}
+
+// audioOnlySettle is how long an audio file must sit before it is imported
+// without metadata, giving trunk-recorder time to write its .json.
+const audioOnlySettle = 10 * time.Second
+
+func newFileWatcher(p *Pipeline, watchDir, instanceID string, backfillDays int, audioOnly bool) *FileWatcher {
 	fw := &FileWatcher{
 		pipeline:       p,
 		watchDir:       watchDir,
 		instanceID:     instanceID,
 		backfillDays:   backfillDays,
+		audioOnly:      audioOnly,
 		log:            p.log.With().Str("component", "watcher").Logger(),
 		debounceTimers: make(map[string]*time.Timer),
 	}
@@ -156,8 +201,8 @@ func (fw *FileWatcher) watchLoop() {
 				continue
 			}
 
-			// Only process .json files
-			if !strings.HasSuffix(strings.ToLower(event.Name), ".json") {
+			// Only process .json files (and bare audio in audio-only mode)
+			if !fw.wantsFile(event.Name) {
 				continue
 			}
 
@@ -193,75 +238,152 @@ func (fw *FileWatcher) addDirRecursive(root string) {
 			}
 			return nil
 		}
-		if processFiles && strings.HasSuffix(strings.ToLower(path), ".json") {
+		if processFiles && fw.wantsFile(path) {
 			fw.scheduleProcess(path)
 		}
 		return nil
 	})
 }
 
-// scheduleProcess debounces file processing by 500ms. This coalesces rapid
-// Create+Write events and ensures the file is fully written before reading.
+// wantsFile reports whether the watcher ingests path: .json metadata files,
+// plus audio files in audio-only mode.
+func (fw *FileWatcher) wantsFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".json" {
+		return true
+	}
+	return fw.audioOnly && isWatchAudioExt(ext)
+}
+
+func isWatchAudioExt(ext string) bool {
+	for _, e := range watchAudioExts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduleProcess debounces file processing by 500ms (audioOnlySettle for
+// bare audio). This coalesces rapid Create+Write events and ensures the file
+// is fully written before reading.
 func (fw *FileWatcher) scheduleProcess(path string) {
 	fw.debounceMu.Lock()
 	defer fw.debounceMu.Unlock()
 
+	delay := 500 * time.Millisecond
+	if !strings.HasSuffix(strings.ToLower(path), ".json") {
+		delay = audioOnlySettle
+	}
+
 	if t, ok := fw.debounceTimers[path]; ok {
-		t.Reset(500 * time.Millisecond)
+		t.Reset(delay)
 		return
 	}
 
-	fw.debounceTimers[path] = time.AfterFunc(500*time.Millisecond, func() {
+	fw.debounceTimers[path] = time.AfterFunc(delay, func() {
 		fw.debounceMu.Lock()
 		delete(fw.debounceTimers, path)
 		fw.debounceMu.Unlock()
 
-		fw.processJSONFile(path)
+		fw.processFile(path)
 	})
 }
 
 // processJSONFile reads a JSON metadata file, parses it, and passes it to the
 // pipeline for call creation.
-func (fw *FileWatcher) processJSONFile(path string) {
+func (fw *FileWatcher) processJSONFile(path string) fileOutcome {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		fw.log.Warn().Err(err).Str("path", path).Msg("failed to read JSON file")
-		return
+		return failedFile("read_failed", err)
 	}
 
 	var meta AudioMetadata
 	if err := json.Unmarshal(data, &meta); err != nil {
 		fw.log.Warn().Err(err).Str("path", path).Msg("failed to parse JSON metadata")
-		return
+		return failedFile("invalid_json", err)
 	}
 
 	// Skip files with no talkgroup (invalid metadata)
 	if meta.Talkgroup <= 0 {
 		fw.filesSkipped.Add(1)
-		return
+		return skippedFile("no_talkgroup", nil)
 	}
 
-	if err := fw.pipeline.processWatchedFile(fw.instanceID, &meta, path); err != nil {
+	return fw.ingest(&meta, path)
+}
+
+// ingest passes parsed metadata to the pipeline and counts the result.
+func (fw *FileWatcher) ingest(meta *AudioMetadata, path string) fileOutcome {
+	skipped, err := fw.pipeline.processWatchedFile(fw.instanceID, meta, path)
+	if err != nil {
 		fw.log.Warn().Err(err).Str("path", path).Msg("failed to process watched file")
-		return
+		return failedFile("ingest_failed", err)
+	}
+	if skipped != "" {
+		return skippedFile(skipped, nil)
 	}
 
 	fw.filesProcessed.Add(1)
+	return fileOutcome{status: database.BackfillImported}
+}
+
+// hasJSONCompanion reports whether an audio file has trunk-recorder metadata
+// next to it, in which case the .json is what gets ingested.
+func hasJSONCompanion(audioPath string) bool {
+	_, err := os.Stat(strings.TrimSuffix(audioPath, filepath.Ext(audioPath)) + ".json")
+	return err == nil
+}
+
+// processAudioFile imports an audio file with no metadata JSON, deriving the
+// talkgroup, start time and system from its path relative to the watch
+// directory. Files whose system can't be derived use the instance ID.
+func (fw *FileWatcher) processAudioFile(path string) fileOutcome {
+	if hasJSONCompanion(path) {
+		return skippedFile("has_json", nil)
+	}
+	rel, err := filepath.Rel(fw.watchDir, path)
+	if err != nil {
+		rel = filepath.Base(path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fw.log.Warn().Err(err).Str("path", path).Msg("failed to read audio file")
+		return failedFile("read_failed", err)
+	}
+	meta, err := fw.pipeline.metadataFromAudio(fw.pipeline.ctx, rel, data, path)
+	if err != nil {
+		fw.log.Debug().Err(err).Str("path", path).Msg("skipping audio file")
+		fw.filesSkipped.Add(1)
+		return skippedFile("no_metadata", err)
+	}
+	if meta.ShortName == "" {
+		meta.ShortName = fw.instanceID
+	}
+
+	return fw.ingest(meta, path)
+}
+
+// processFile ingests a .json or, in audio-only mode, a bare audio file.
+func (fw *FileWatcher) processFile(path string) fileOutcome {
+	if strings.HasSuffix(strings.ToLower(path), ".json") {
+		return fw.processJSONFile(path)
+	}
+	return fw.processAudioFile(path)
 }
 
 // backfill scans the watch directory for existing JSON files and processes any
 // that aren't already in the database. Files are processed oldest-first with
-// rate limiting to avoid overwhelming the database on first run.
+// rate limiting to avoid overwhelming the database on first run. Each file's
+// outcome is recorded for the backfill report.
 func (fw *FileWatcher) backfill() {
 	fw.status.Store("backfilling")
 	start := time.Now()
 
-	// Collect all .json files
-	type fileEntry struct {
-		path      string
-		startTime int64
-	}
-	var files []fileEntry
+	// Collect all .json files (and bare audio in audio-only mode)
+	var files []backfillFile
 
 	var cutoff int64
 	if fw.backfillDays > 0 {
@@ -272,13 +394,22 @@ func (fw *FileWatcher) backfill() {
 		if err != nil || d.IsDir() {
 			return nil
 		}
-		if !strings.HasSuffix(strings.ToLower(path), ".json") {
+		if !fw.wantsFile(path) {
 			return nil
 		}
+		isJSON := strings.HasSuffix(strings.ToLower(path), ".json")
+		if !isJSON && hasJSONCompanion(path) {
+			return nil // imported via its .json
+		}
 
-		// Parse start_time from filename: {tgid}-{start_time}_{freq}-call_{id}.json
-		ts := parseStartTimeFromFilename(filepath.Base(path))
+		ts := fw.fileStartTime(path)
 		if ts == 0 {
+			// Report files whose start time can't be read under the day
+			// they were written.
+			if info, infoErr := d.Info(); infoErr == nil && (cutoff == 0 || info.ModTime().Unix() >= cutoff) {
+				fw.filesSkipped.Add(1)
+				fw.record(path, info.ModTime(), skippedFile("no_timestamp", nil))
+			}
 			return nil
 		}
 
@@ -286,7 +417,7 @@ func (fw *FileWatcher) backfill() {
 			return nil // too old
 		}
 
-		files = append(files, fileEntry{path: path, startTime: ts})
+		files = append(files, backfillFile{path: path, startTime: ts})
 		return nil
 	})
 
@@ -308,11 +439,41 @@ func (fw *FileWatcher) backfill() {
 		Int("backfill_days", fw.backfillDays).
 		Msg("backfill starting")
 
-	// Process files concurrently with a worker pool.
+	processed, done := fw.processFiles(files, "backfill")
+	if !done {
+		fw.log.Info().Int64("processed", processed).Msg("backfill interrupted by shutdown")
+		return
+	}
+
+	fw.status.Store("watching")
+	fw.log.Info().
+		Int64("processed", processed).
+		Dur("elapsed", time.Since(start)).
+		Msg("backfill complete")
+}
+
+// fileStartTime returns the call start time encoded in a watched file's name,
+// or 0 if it has none.
+func (fw *FileWatcher) fileStartTime(path string) int64 {
+	// Parse start_time from filename: {tgid}-{start_time}_{freq}-call_{id}.json
+	if strings.HasSuffix(strings.ToLower(path), ".json") {
+		return parseStartTimeFromFilename(filepath.Base(path))
+	}
+	if rel, err := filepath.Rel(fw.watchDir, path); err == nil {
+		if meta, ok := MetadataFromFilename(rel, fw.pipeline.filenamePatterns); ok {
+			return meta.StartTime
+		}
+	}
+	return 0
+}
+
+// processFiles ingests files with a worker pool, recording each outcome.
+// Returns how many were processed and false if shutdown interrupted it.
+func (fw *FileWatcher) processFiles(files []backfillFile, label string) (int64, bool) {
 	// Keep workers under the DB pool size (20 max conns) to avoid
 	// connection starvation during partition creation DDL.
 	const numWorkers = 8
-	work := make(chan fileEntry, numWorkers*2)
+	work := make(chan backfillFile, numWorkers*2)
 	var wg sync.WaitGroup
 
 	var processed atomic.Int64
@@ -322,13 +483,13 @@ func (fw *FileWatcher) backfill() {
 		go func() {
 			defer wg.Done()
 			for f := range work {
-				fw.processJSONFile(f.path)
+				fw.record(f.path, time.Unix(f.startTime, 0), fw.processFile(f.path))
 				n := processed.Add(1)
 				if n%5000 == 0 {
 					fw.log.Info().
 						Int64("processed", n).
 						Int("total", len(files)).
-						Msg("backfill progress")
+						Msg(label + " progress")
 				}
 			}
 		}()
@@ -337,21 +498,73 @@ func (fw *FileWatcher) backfill() {
 	for _, f := range files {
 		select {
 		case <-fw.pipeline.ctx.Done():
-			fw.log.Info().Int64("processed", processed.Load()).Msg("backfill interrupted by shutdown")
 			close(work)
 			wg.Wait()
-			return
+			return processed.Load(), false
 		case work <- f:
 		}
 	}
 	close(work)
 	wg.Wait()
+	return processed.Load(), true
+}
 
-	fw.status.Store("watching")
-	fw.log.Info().
-		Int64("processed", processed.Load()).
-		Dur("elapsed", time.Since(start)).
-		Msg("backfill complete")
+// record stores a file's backfill outcome.
+func (fw *FileWatcher) record(path string, startTime time.Time, o fileOutcome) {
+	ctx, cancel := context.WithTimeout(fw.pipeline.ctx, 10*time.Second)
+	defer cancel()
+	if err := fw.pipeline.db.RecordWatchBackfillFile(ctx, fw.instanceID, path, startTime, o.status, o.reason, o.detail); err != nil {
+		fw.log.Warn().Err(err).Str("path", path).Msg("failed to record backfill outcome")
+	}
+}
+
+// RetryFailed re-ingests the files the backfill report lists as errors,
+// optionally only those from days in [startDay, endDay]. The files are
+// processed in the background; returns how many were queued.
+func (fw *FileWatcher) RetryFailed(ctx context.Context, startDay, endDay *time.Time) (int, error) {
+	if s, _ := fw.status.Load().(string); s == "backfilling" {
+		return 0, fmt.Errorf("backfill already running")
+	}
+	if !fw.retrying.CompareAndSwap(false, true) {
+		return 0, fmt.Errorf("backfill retry already running")
+	}
+
+	var files []backfillFile
+	filter := database.WatchBackfillFilter{
+		StartDay: startDay,
+		EndDay:   endDay,
+		Status:   database.BackfillError,
+		Limit:    1000,
+	}
+	for {
+		page, total, err := fw.pipeline.db.ListWatchBackfillFiles(ctx, filter)
+		if err != nil {
+			fw.retrying.Store(false)
+			return 0, err
+		}
+		for _, f := range page {
+			ts := fw.fileStartTime(f.Path)
+			if ts == 0 {
+				day, _ := time.Parse("2006-01-02", f.Day)
+				ts = day.Unix()
+			}
+			files = append(files, backfillFile{path: f.Path, startTime: ts})
+		}
+		filter.Offset += len(page)
+		if len(page) == 0 || filter.Offset >= total {
+			break
+		}
+	}
+
+	go func() {
+		defer fw.retrying.Store(false)
+		if len(files) > 0 {
+			fw.ensurePartitions(time.Unix(files[0].startTime, 0))
+		}
+		processed, _ := fw.processFiles(files, "backfill retry")
+		fw.log.Info().Int64("processed", processed).Msg("backfill retry complete")
+	}()
+	return len(files), nil
 }
 
 // ensurePartitions creates monthly partitions for all partitioned tables from
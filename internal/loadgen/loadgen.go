@@ -0,0 +1,445 @@
+// Package loadgen generates synthetic trunk-recorder MQTT traffic (systems,
+// calls with audio, unit events, trunking messages, decode rates) for load
+// testing an ingest pipeline. Payloads follow the TR MQTT plugin's format, so
+// tr-engine processes them exactly like real traffic. See cmd/tr-loadgen.
+package loadgen
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/audio"
+)
+
+// Config sets the shape and rate of the generated traffic.
+type Config struct {
+	TopicPrefix string // feed topic is {prefix}/feeds, units {prefix}/units, messages {prefix}/messages
+	InstanceID  string
+	Systems     int
+	Talkgroups  int // per system
+	Units       int // per system
+
+	CallsPerDay   float64
+	TrunkingRate  float64 // trunking messages per second, all systems
+	UnitEventRate float64 // affiliation/registration chatter per second, besides call events
+	RatesInterval time.Duration
+	Audio         bool // send an audio message (WAV) after each call_end
+
+	Seed int64
+}
+
+// Defaults for zero Config fields.
+const (
+	DefaultTopicPrefix   = "trengine"
+	DefaultInstanceID    = "loadgen"
+	DefaultRatesInterval = 3 * time.Second
+)
+
+// Message is one MQTT message to publish.
+type Message struct {
+	Kind    string // handler the message routes to (call_start, audio, unit_event, ...)
+	Topic   string
+	Payload []byte
+
+	// Set on call_end messages, to look the call up after ingest
+	CallID    string
+	StartTime time.Time
+}
+
+type system struct {
+	num            int
+	name           string
+	sysid          string
+	controlChannel float64
+	busy           map[int]bool // talkgroups with a call in progress
+}
+
+type call struct {
+	sys   *system
+	id    string
+	num   int
+	tgid  int
+	units []int
+	start time.Time
+	end   time.Time
+	freq  float64
+	emerg bool
+}
+
+// Generator produces traffic for Config. It is not safe for concurrent use.
+type Generator struct {
+	cfg     Config
+	rng     *rand.Rand
+	tgZipf  *rand.Zipf
+	systems []*system
+	active  callHeap
+	callNum int
+	noise   []int16 // 60s of 8 kHz noise, sliced per call
+
+	last                       time.Time
+	callAcc, trunkAcc, unitAcc float64
+	lastRates                  time.Time
+}
+
+// New returns a generator for cfg, filling in defaults.
+func New(cfg Config) *Generator {
+	if cfg.TopicPrefix == "" {
+		cfg.TopicPrefix = DefaultTopicPrefix
+	}
+	if cfg.InstanceID == "" {
+		cfg.InstanceID = DefaultInstanceID
+	}
+	if cfg.Systems < 1 {
+		cfg.Systems = 1
+	}
+	if cfg.Talkgroups < 1 {
+		cfg.Talkgroups = 1
+	}
+	if cfg.Units < 1 {
+		cfg.Units = 1
+	}
+	if cfg.RatesInterval <= 0 {
+		cfg.RatesInterval = DefaultRatesInterval
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+
+	g := &Generator{cfg: cfg, rng: rand.New(rand.NewSource(cfg.Seed))}
+	if cfg.Talkgroups > 1 {
+		// A few talkgroups carry most of the traffic, as on real systems
+		g.tgZipf = rand.NewZipf(g.rng, 1.2, 4, uint64(cfg.Talkgroups-1))
+	}
+	for i := 0; i < cfg.Systems; i++ {
+		g.systems = append(g.systems, &system{
+			num:            i,
+			name:           fmt.Sprintf("loadgen%d", i+1),
+			sysid:          fmt.Sprintf("%X", 0xF00+i),
+			controlChannel: 851000000 + float64(i)*250000,
+			busy:           make(map[int]bool),
+		})
+	}
+	if cfg.Audio {
+		g.noise = make([]int16, 60*8000)
+		for i := range g.noise {
+			g.noise[i] = int16(g.rng.NormFloat64() * 600)
+		}
+	}
+	return g
+}
+
+// Active returns the number of calls in progress.
+func (g *Generator) Active() int { return len(g.active) }
+
+// Start returns the messages a trunk-recorder instance sends on connect
+// (status, systems, rates) and starts the traffic clock at now.
+func (g *Generator) Start(now time.Time) []Message {
+	g.last, g.lastRates = now, now
+	sysList := make([]map[string]any, 0, len(g.systems))
+	for _, s := range g.systems {
+		sysList = append(sysList, map[string]any{
+			"sys_num": s.num, "sys_name": s.name, "type": "p25",
+			"sysid": s.sysid, "wacn": "BEE0F", "nac": "F7E", "rfss": 1, "site_id": s.num + 1,
+		})
+	}
+	return []Message{
+		g.feed("status", "trunk_recorder/status", now, map[string]any{"status": "connected", "client_id": g.cfg.InstanceID}),
+		g.feed("systems", "systems", now, map[string]any{"systems": sysList}),
+		g.rates(now),
+	}
+}
+
+// Tick returns every message due between the previous call (or Start) and
+// now: new calls, calls ending, trunking messages, unit chatter and rates.
+func (g *Generator) Tick(now time.Time) []Message {
+	dt := now.Sub(g.last).Seconds()
+	if dt < 0 {
+		dt = 0
+	}
+	g.last = now
+
+	var out []Message
+	for len(g.active) > 0 && !g.active[0].end.After(now) {
+		out = append(out, g.endCall(heap.Pop(&g.active).(*call))...)
+	}
+
+	g.callAcc += g.cfg.CallsPerDay / 86400 * dt
+	for ; g.callAcc >= 1; g.callAcc-- {
+		out = append(out, g.startCall(now)...)
+	}
+	g.trunkAcc += g.cfg.TrunkingRate * dt
+	for ; g.trunkAcc >= 1; g.trunkAcc-- {
+		out = append(out, g.trunking(now))
+	}
+	g.unitAcc += g.cfg.UnitEventRate * dt
+	for ; g.unitAcc >= 1; g.unitAcc-- {
+		out = append(out, g.unitChatter(now))
+	}
+	if now.Sub(g.lastRates) >= g.cfg.RatesInterval {
+		g.lastRates = now
+		out = append(out, g.rates(now))
+	}
+	return out
+}
+
+func (g *Generator) pickTalkgroup(s *system) (int, bool) {
+	for try := 0; try < 8; try++ {
+		n := 0
+		if g.tgZipf != nil {
+			n = int(g.tgZipf.Uint64())
+		}
+		if tgid := 1000 + n; !s.busy[tgid] {
+			return tgid, true
+		}
+	}
+	return 0, false
+}
+
+func (g *Generator) startCall(now time.Time) []Message {
+	s := g.systems[g.rng.Intn(len(g.systems))]
+	tgid, ok := g.pickTalkgroup(s)
+	if !ok {
+		return nil // busiest talkgroups all in use; drop like a busy system would queue
+	}
+	s.busy[tgid] = true
+	g.callNum++
+
+	// Dispatch-style calls: a few seconds, occasionally much longer
+	dur := time.Duration((2 + g.rng.ExpFloat64()*6) * float64(time.Second))
+	if dur > 60*time.Second {
+		dur = 60 * time.Second
+	}
+	c := &call{
+		sys:   s,
+		num:   g.callNum,
+		tgid:  tgid,
+		start: now.Truncate(time.Second),
+		end:   now.Add(dur),
+		freq:  851012500 + float64(g.rng.Intn(20))*12500,
+		emerg: g.rng.Intn(500) == 0,
+	}
+	c.id = fmt.Sprintf("%d_%d_%d", s.num, tgid, c.start.Unix())
+	for n := 1 + g.rng.Intn(3); n > 0; n-- {
+		c.units = append(c.units, 100000+g.rng.Intn(g.cfg.Units))
+	}
+	heap.Push(&g.active, c)
+
+	return []Message{
+		g.unitEvent(s, "call", now, map[string]any{"unit": c.units[0], "talkgroup": tgid, "freq": c.freq}),
+		g.feed("call_start", "call_start", now, map[string]any{"call": g.callData(c, now, false)}),
+	}
+}
+
+func (g *Generator) endCall(c *call) []Message {
+	delete(c.sys.busy, c.tgid)
+	now := c.end
+	var out []Message
+	for _, u := range c.units {
+		out = append(out, g.unitEvent(c.sys, "end", now, map[string]any{"unit": u, "talkgroup": c.tgid, "freq": c.freq}))
+	}
+	end := g.feed("call_end", "call_end", now, map[string]any{"call": g.callData(c, now, true)})
+	end.CallID, end.StartTime = c.id, c.start
+	out = append(out, end)
+	if g.cfg.Audio {
+		out = append(out, g.audio(c))
+	}
+	return out
+}
+
+func (g *Generator) callData(c *call, now time.Time, ended bool) map[string]any {
+	d := map[string]any{
+		"id":                  c.id,
+		"call_num":            c.num,
+		"sys_num":             c.sys.num,
+		"sys_name":            c.sys.name,
+		"freq":                c.freq,
+		"unit":                c.units[0],
+		"talkgroup":           c.tgid,
+		"talkgroup_alpha_tag": fmt.Sprintf("LG %d", c.tgid),
+		"talkgroup_group":     "Load Test",
+		"talkgroup_tag":       "Load Test",
+		"elapsed":             int(now.Sub(c.start).Seconds()),
+		"length":              now.Sub(c.start).Seconds(),
+		"call_state":          1,
+		"call_state_type":     "RECORDING",
+		"audio_type":          "digital",
+		"emergency":           c.emerg,
+		"encrypted":           false,
+		"start_time":          c.start.Unix(),
+		"stop_time":           0,
+	}
+	if ended {
+		d["call_state"], d["call_state_type"] = 2, "COMPLETED"
+		d["stop_time"] = now.Unix()
+		d["process_call_time"] = 0.05
+		d["signal"], d["noise"] = -52.0, -110.0
+		d["call_filename"] = fmt.Sprintf("/loadgen/%s/%s.wav", c.sys.name, c.id)
+	}
+	return d
+}
+
+func (g *Generator) audio(c *call) Message {
+	dur := c.end.Sub(c.start)
+	srcs := make([]map[string]any, len(c.units))
+	step := dur.Seconds() / float64(len(c.units))
+	for i, u := range c.units {
+		pos := step * float64(i)
+		srcs[i] = map[string]any{
+			"src": u, "time": c.start.Unix() + int64(pos), "pos": pos, "emergency": 0, "signal_system": "", "tag": "",
+		}
+	}
+	emerg := 0
+	if c.emerg {
+		emerg = 1
+	}
+	meta := map[string]any{
+		"freq":                  c.freq,
+		"start_time":            c.start.Unix(),
+		"stop_time":             c.end.Unix(),
+		"emergency":             emerg,
+		"encrypted":             0,
+		"call_length":           int(dur.Seconds() + 0.5),
+		"talkgroup":             c.tgid,
+		"talkgroup_tag":         fmt.Sprintf("LG %d", c.tgid),
+		"talkgroup_group":       "Load Test",
+		"audio_type":            "digital",
+		"short_name":            c.sys.name,
+		"freqList":              []map[string]any{{"freq": c.freq, "time": c.start.Unix(), "pos": 0, "len": dur.Seconds(), "error_count": 0, "spike_count": 0}},
+		"srcList":               srcs,
+		"filename":              c.id + ".wav",
+		"talkgroup_description": "",
+	}
+	return g.feed("audio", "audio", c.end, map[string]any{"call": map[string]any{
+		"audio_wav_base64": base64.StdEncoding.EncodeToString(g.wav(dur)),
+		"metadata":         meta,
+	}})
+}
+
+// wav returns a WAV file of d (at most 60s) of noise.
+func (g *Generator) wav(d time.Duration) []byte {
+	n := int(d.Seconds() * 8000)
+	if n > len(g.noise) {
+		n = len(g.noise)
+	}
+	var buf bytes.Buffer
+	_ = audio.WriteWAV(&buf, g.noise[:n], 8000) // bytes.Buffer writes don't fail
+	return buf.Bytes()
+}
+
+var trunkingOpcodes = []struct {
+	opcode, opType, desc string
+	weight               int
+}{
+	{"00", "GRP_V_CH_GRANT", "Group Voice Channel Grant", 30},
+	{"02", "GRP_V_CH_GRANT_UPDT", "Group Voice Channel Grant Update", 40},
+	{"28", "GRP_AFF_RSP", "Group Affiliation Response", 10},
+	{"2c", "U_REG_RSP", "Unit Registration Response", 5},
+	{"3a", "RFSS_STS_BCST", "RFSS Status Broadcast", 5},
+	{"3b", "NET_STS_BCST", "Network Status Broadcast", 5},
+	{"3d", "IDEN_UP", "Identifier Update", 5},
+}
+
+func (g *Generator) trunking(now time.Time) Message {
+	s := g.systems[g.rng.Intn(len(g.systems))]
+	total := 0
+	for _, o := range trunkingOpcodes {
+		total += o.weight
+	}
+	pick := g.rng.Intn(total)
+	op := trunkingOpcodes[0]
+	for _, o := range trunkingOpcodes {
+		if pick < o.weight {
+			op = o
+			break
+		}
+		pick -= o.weight
+	}
+	meta := ""
+	if op.opType == "RFSS_STS_BCST" {
+		b, _ := json.Marshal(map[string]any{"freq": s.controlChannel})
+		meta = string(b)
+	}
+	p := g.envelope("message", now)
+	p["message"] = map[string]any{
+		"sys_num": s.num, "sys_name": s.name, "trunk_msg": 0, "trunk_msg_type": "P25",
+		"opcode": op.opcode, "opcode_type": op.opType, "opcode_desc": op.desc, "meta": meta,
+	}
+	return g.message("trunking_message", g.cfg.TopicPrefix+"/messages/"+s.name+"/message", p)
+}
+
+func (g *Generator) unitChatter(now time.Time) Message {
+	s := g.systems[g.rng.Intn(len(g.systems))]
+	unit := 100000 + g.rng.Intn(g.cfg.Units)
+	switch g.rng.Intn(10) {
+	case 0:
+		return g.unitEvent(s, "on", now, map[string]any{"unit": unit})
+	case 1:
+		return g.unitEvent(s, "off", now, map[string]any{"unit": unit})
+	case 2, 3:
+		return g.unitEvent(s, "location", now, map[string]any{"unit": unit, "talkgroup": 1000 + g.rng.Intn(g.cfg.Talkgroups)})
+	default:
+		return g.unitEvent(s, "join", now, map[string]any{"unit": unit, "talkgroup": 1000 + g.rng.Intn(g.cfg.Talkgroups)})
+	}
+}
+
+func (g *Generator) unitEvent(s *system, event string, now time.Time, fields map[string]any) Message {
+	fields["sys_num"], fields["sys_name"] = s.num, s.name
+	fields["unit_alpha_tag"] = ""
+	if tg, ok := fields["talkgroup"].(int); ok {
+		fields["talkgroup_alpha_tag"] = fmt.Sprintf("LG %d", tg)
+	}
+	p := g.envelope(event, now)
+	p[event] = fields
+	return g.message("unit_event", g.cfg.TopicPrefix+"/units/"+s.name+"/"+event, p)
+}
+
+func (g *Generator) rates(now time.Time) Message {
+	list := make([]map[string]any, 0, len(g.systems))
+	for _, s := range g.systems {
+		list = append(list, map[string]any{
+			"sys_num": s.num, "sys_name": s.name,
+			"decoderate": 38 + g.rng.Float64()*2, "decoderate_interval": 3, "control_channel": s.controlChannel,
+		})
+	}
+	return g.feed("rates", "rates", now, map[string]any{"rates": list})
+}
+
+func (g *Generator) envelope(typ string, now time.Time) map[string]any {
+	return map[string]any{"type": typ, "timestamp": now.Unix(), "instance_id": g.cfg.InstanceID}
+}
+
+// feed builds a message on {prefix}/feeds/{suffix}.
+func (g *Generator) feed(kind, suffix string, now time.Time, body map[string]any) Message {
+	p := g.envelope(kind, now)
+	for k, v := range body {
+		p[k] = v
+	}
+	return g.message(kind, g.cfg.TopicPrefix+"/feeds/"+suffix, p)
+}
+
+func (g *Generator) message(kind, topic string, payload map[string]any) Message {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		panic(err) // only plain maps, slices and numbers are marshalled
+	}
+	return Message{Kind: kind, Topic: topic, Payload: b}
+}
+
+// callHeap orders calls in progress by end time.
+type callHeap []*call
+
+func (h callHeap) Len() int           { return len(h) }
+func (h callHeap) Less(i, j int) bool { return h[i].end.Before(h[j].end) }
+func (h callHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *callHeap) Push(x any)        { *h = append(*h, x.(*call)) }
+func (h *callHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
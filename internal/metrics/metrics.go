@@ -32,6 +32,12 @@ var (
 		Help:      "HTTP response size in bytes.",
 		Buckets:   prometheus.ExponentialBuckets(100, 10, 7), // 100B → 100MB
 	}, []string{"method", "path_pattern"})
+
+	RateLimitedRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_rate_limited_requests_total",
+		Help:      "Requests rejected with 429 by limit scope (ip, global, token, expensive).",
+	}, []string{"scope"})
 )
 
 // Ingest counters (incremented directly by pipeline).
@@ -48,6 +54,31 @@ var (
 		Help:      "MQTT messages processed per handler.",
 	}, []string{"handler"})
 
+	MQTTHandlerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "mqtt_handler_duration_seconds",
+		Help:      "MQTT message handler latency per handler.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2.5, 12), // 0.5ms → ~30s
+	}, []string{"handler"})
+
+	MQTTMessagesInflight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "mqtt_messages_inflight",
+		Help:      "MQTT messages being processed and not yet acknowledged (MQTT_ACK_MODE=processed).",
+	})
+
+	MQTTHandlerRetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mqtt_handler_retries_total",
+		Help:      "MQTT messages retried while the database was unreachable (MQTT_ACK_MODE=processed), per handler.",
+	}, []string{"handler"})
+
+	IngestValidationFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ingest_validation_failures_total",
+		Help:      "MQTT messages that failed payload validation, per handler.",
+	}, []string{"handler"})
+
 	SSEEventsPublishedTotal = prometheus.NewCounter(prometheus.CounterOpts{
 		Namespace: namespace,
 		Name:      "sse_events_published_total",
@@ -55,14 +86,183 @@ var (
 	})
 )
 
+// API response cache counters (incremented by api.ResponseCache).
+var (
+	APICacheRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_cache_requests_total",
+		Help:      "Cacheable API requests by endpoint and result (hit or miss).",
+	}, []string{"endpoint", "result"})
+
+	TranscriptionUrgencyTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transcription_urgency_total",
+		Help:      "Classified transcriptions by urgency label.",
+	}, []string{"label"})
+
+	AudioDurationChecksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audio_duration_checks_total",
+		Help:      "Saved audio duration checks by result (ok, mismatch, error), plus corrected when AUDIO_DURATION_CORRECT replaced the call duration.",
+	}, []string{"result"})
+
+	AudioUnusableTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audio_unusable_total",
+		Help:      "Calls flagged with unusable audio by reason (empty, silent), see AUDIO_UNUSABLE_CHECK.",
+	}, []string{"reason"})
+
+	StuckMicCallsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "stuck_mic_calls_total",
+		Help:      "Suspected stuck microphone calls by outcome (flagged, confirmed, cleared).",
+	}, []string{"result"})
+
+	SplitCallsMergedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "split_calls_merged_total",
+		Help:      "Calls merged into the call they continue (split by a TR retune), see SPLIT_CALL_MAX_GAP.",
+	})
+
+	EncryptedSuppressedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "encrypted_suppressed_total",
+		Help:      "Encrypted messages dropped by a suppress encryption policy, by kind (call, audio, unit_event).",
+	}, []string{"kind"})
+
+	AudioDuplicatesSuppressedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audio_duplicates_suppressed_total",
+		Help:      "MQTT audio messages skipped as repeats within AUDIO_DEDUP_WINDOW, by instance.",
+	}, []string{"instance"})
+
+	AudioDedupEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "audio_dedup_entries",
+		Help:      "Audio messages remembered for duplicate suppression.",
+	})
+
+	AudioStoragePolicyTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audio_storage_policy_total",
+		Help:      "Call audio handled by a talkgroup storage policy or system ingest policy, by outcome (transcoded, transcode_failed, dropped, transcript_only).",
+	}, []string{"outcome"})
+
+	TranscriptionJobsRecoveredTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transcription_jobs_recovered_total",
+		Help:      "Transcription jobs taken back from a stuck or panicked worker, by reason (stuck, panic) and outcome (requeued, dead_lettered).",
+	}, []string{"reason", "outcome"})
+)
+
+// Event bridge metrics (updated by internal/bridge).
+var (
+	BridgeEventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bridge_events_total",
+		Help:      "Events handled by the Kafka/NATS bridge by type and result (published, dropped or filtered).",
+	}, []string{"type", "result"})
+
+	BridgePublishErrorsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bridge_publish_errors_total",
+		Help:      "Failed bridge batch publish attempts (each is retried).",
+	})
+
+	BridgeQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "bridge_queue_depth",
+		Help:      "Events waiting to be published by the bridge.",
+	})
+
+	BridgeLagSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "bridge_lag_seconds",
+		Help:      "Age of the oldest event in the batch the bridge is delivering; 0 when caught up.",
+	})
+
+	BridgeDeliveryLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "bridge_delivery_latency_seconds",
+		Help:      "Time from event publication to broker acknowledgement.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 8), // 10ms → ~160s
+	})
+)
+
+// EmergencyLatency tracks the emergency fast path apart from the general
+// ingest and transcription metrics.
+var EmergencyLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "emergency_latency_seconds",
+	Help:      "End-to-end emergency latency by stage: event (trunk-recorder timestamp to emergency event published) and transcription (end of call audio to transcript stored).",
+	Buckets:   prometheus.ExponentialBuckets(0.05, 2.5, 10), // 50ms → ~190s
+}, []string{"stage"})
+
+// Enrichment hook metrics (updated by internal/ingest).
+var (
+	EnrichmentHookRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "enrichment_hook_requests_total",
+		Help:      "Enrichment hook calls by hook and result (ok, error, timeout, circuit_open).",
+	}, []string{"hook", "result"})
+
+	EnrichmentHookDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "enrichment_hook_duration_seconds",
+		Help:      "Enrichment hook request latency by hook.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2.5, 9), // 5ms → ~7.6s
+	}, []string{"hook"})
+
+	EnrichmentHookCircuitOpen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "enrichment_hook_circuit_open",
+		Help:      "1 while a hook's circuit breaker is open and the hook is skipped.",
+	}, []string{"hook"})
+)
+
+// Database query latency for hot list queries (observed by the database package).
+var DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "db_query_duration_seconds",
+	Help:      "Latency of hot list queries (count + page) by query.",
+	Buckets:   prometheus.ExponentialBuckets(0.001, 2.5, 10), // 1ms → ~3.8s
+}, []string{"query"})
+
 func init() {
 	prometheus.MustRegister(
 		HTTPRequestsTotal,
 		HTTPRequestDuration,
 		HTTPResponseSize,
+		RateLimitedRequestsTotal,
 		MQTTMessagesTotal,
 		MQTTHandlerMessagesTotal,
+		MQTTHandlerDuration,
+		MQTTMessagesInflight,
+		MQTTHandlerRetriesTotal,
+		IngestValidationFailuresTotal,
 		SSEEventsPublishedTotal,
+		APICacheRequestsTotal,
+		TranscriptionUrgencyTotal,
+		AudioDurationChecksTotal,
+		AudioUnusableTotal,
+		StuckMicCallsTotal,
+		SplitCallsMergedTotal,
+		EncryptedSuppressedTotal,
+		AudioDuplicatesSuppressedTotal,
+		AudioDedupEntries,
+		AudioStoragePolicyTotal,
+		TranscriptionJobsRecoveredTotal,
+		BridgeEventsTotal,
+		BridgePublishErrorsTotal,
+		BridgeQueueDepth,
+		BridgeLagSeconds,
+		BridgeDeliveryLatency,
+		EmergencyLatency,
+		EnrichmentHookRequestsTotal,
+		EnrichmentHookDuration,
+		EnrichmentHookCircuitOpen,
+		DBQueryDuration,
+		Talkgroups,
 	)
 }
 
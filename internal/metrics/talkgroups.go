@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TalkgroupInfo is a talkgroup in the metrics allowlist, with the end of its
+// latest stored call (zero if none) to seed the last-call gauge.
+type TalkgroupInfo struct {
+	SystemID int
+	Tgid     int
+	AlphaTag string
+	LastCall time.Time
+}
+
+type talkgroupKey struct{ systemID, tgid int }
+
+type talkgroupStats struct {
+	alphaTag string
+	calls    uint64
+	airtime  float64
+	lastCall time.Time
+}
+
+// TalkgroupActivity exports call counts, airtime and the last call time of
+// the talkgroups in the metrics allowlist (/admin/metrics/talkgroups). Calls
+// on other talkgroups are ignored, so the allowlist bounds the number of
+// series. Counters start at zero when the process starts.
+type TalkgroupActivity struct {
+	mu  sync.Mutex
+	tgs map[talkgroupKey]*talkgroupStats
+
+	calls    *prometheus.Desc
+	airtime  *prometheus.Desc
+	lastCall *prometheus.Desc
+}
+
+// Talkgroups is the per-talkgroup activity exporter fed by the pipeline.
+var Talkgroups = NewTalkgroupActivity()
+
+// NewTalkgroupActivity creates an exporter with an empty allowlist.
+func NewTalkgroupActivity() *TalkgroupActivity {
+	labels := []string{"system_id", "tgid", "alpha_tag"}
+	return &TalkgroupActivity{
+		tgs: make(map[talkgroupKey]*talkgroupStats),
+		calls: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "talkgroup", "calls_total"),
+			"Calls ended on an allowlisted talkgroup.",
+			labels, nil,
+		),
+		airtime: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "talkgroup", "airtime_seconds_total"),
+			"Call duration on an allowlisted talkgroup, in seconds.",
+			labels, nil,
+		),
+		lastCall: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "talkgroup", "last_call_timestamp_seconds"),
+			"Unix time the latest call on an allowlisted talkgroup ended. Absent until the talkgroup has a call.",
+			labels, nil,
+		),
+	}
+}
+
+// SetTalkgroups replaces the allowlist. Talkgroups that stay listed keep
+// their counters.
+func (a *TalkgroupActivity) SetTalkgroups(list []TalkgroupInfo) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	tgs := make(map[talkgroupKey]*talkgroupStats, len(list))
+	for _, tg := range list {
+		key := talkgroupKey{tg.SystemID, tg.Tgid}
+		st := a.tgs[key]
+		if st == nil {
+			st = &talkgroupStats{}
+		}
+		st.alphaTag = tg.AlphaTag
+		if tg.LastCall.After(st.lastCall) {
+			st.lastCall = tg.LastCall
+		}
+		tgs[key] = st
+	}
+	a.tgs = tgs
+}
+
+// ObserveCall counts a call that ended at end, if its talkgroup is listed.
+func (a *TalkgroupActivity) ObserveCall(systemID, tgid int, duration float64, end time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	st := a.tgs[talkgroupKey{systemID, tgid}]
+	if st == nil {
+		return
+	}
+	st.calls++
+	if duration > 0 {
+		st.airtime += duration
+	}
+	if end.After(st.lastCall) {
+		st.lastCall = end
+	}
+}
+
+func (a *TalkgroupActivity) Describe(ch chan<- *prometheus.Desc) {
+	ch <- a.calls
+	ch <- a.airtime
+	ch <- a.lastCall
+}
+
+func (a *TalkgroupActivity) Collect(ch chan<- prometheus.Metric) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for key, st := range a.tgs {
+		labels := []string{strconv.Itoa(key.systemID), strconv.Itoa(key.tgid), st.alphaTag}
+		ch <- prometheus.MustNewConstMetric(a.calls, prometheus.CounterValue, float64(st.calls), labels...)
+		ch <- prometheus.MustNewConstMetric(a.airtime, prometheus.CounterValue, st.airtime, labels...)
+		if !st.lastCall.IsZero() {
+			ch <- prometheus.MustNewConstMetric(a.lastCall, prometheus.GaugeValue,
+				float64(st.lastCall.UnixMilli())/1000, labels...)
+		}
+	}
+}
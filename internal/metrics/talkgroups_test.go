@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gatherTalkgroups returns metric name → tgid label → value.
+func gatherTalkgroups(t *testing.T, a *TalkgroupActivity) map[string]map[string]float64 {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(a)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	out := make(map[string]map[string]float64)
+	for _, f := range families {
+		byTG := make(map[string]float64)
+		for _, m := range f.GetMetric() {
+			var tgid string
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "tgid" {
+					tgid = l.GetValue()
+				}
+			}
+			switch {
+			case m.GetCounter() != nil:
+				byTG[tgid] = m.GetCounter().GetValue()
+			case m.GetGauge() != nil:
+				byTG[tgid] = m.GetGauge().GetValue()
+			}
+		}
+		out[f.GetName()] = byTG
+	}
+	return out
+}
+
+func TestTalkgroupActivity(t *testing.T) {
+	a := NewTalkgroupActivity()
+	seeded := time.Unix(1700000000, 0)
+	a.SetTalkgroups([]TalkgroupInfo{
+		{SystemID: 1, Tgid: 100, AlphaTag: "Fire Dispatch", LastCall: seeded},
+		{SystemID: 1, Tgid: 200, AlphaTag: "PD Main"},
+	})
+
+	end := seeded.Add(time.Hour)
+	a.ObserveCall(1, 100, 12.5, end)
+	a.ObserveCall(1, 100, 2.5, end.Add(-time.Minute)) // late call_end doesn't move the gauge back
+	a.ObserveCall(1, 300, 60, end)                    // not listed
+	a.ObserveCall(2, 100, 60, end)                    // same tgid, other system
+
+	got := gatherTalkgroups(t, a)
+	if v := got["tr_engine_talkgroup_calls_total"]; v["100"] != 2 || v["200"] != 0 || len(v) != 2 {
+		t.Errorf("calls_total = %v, want 100:2 200:0", v)
+	}
+	if v := got["tr_engine_talkgroup_airtime_seconds_total"]; v["100"] != 15 || len(v) != 2 {
+		t.Errorf("airtime_seconds_total = %v, want 100:15", v)
+	}
+	last := got["tr_engine_talkgroup_last_call_timestamp_seconds"]
+	if last["100"] != float64(end.Unix()) {
+		t.Errorf("last_call 100 = %v, want %v", last["100"], end.Unix())
+	}
+	if _, ok := last["200"]; ok {
+		t.Error("last_call 200 exported before the talkgroup had a call")
+	}
+
+	// Relisting keeps counters of talkgroups that stay; dropped ones vanish.
+	a.SetTalkgroups([]TalkgroupInfo{{SystemID: 1, Tgid: 100, AlphaTag: "Fire Dispatch"}})
+	got = gatherTalkgroups(t, a)
+	if v := got["tr_engine_talkgroup_calls_total"]; v["100"] != 2 || len(v) != 1 {
+		t.Errorf("after relist calls_total = %v, want 100:2 only", v)
+	}
+}
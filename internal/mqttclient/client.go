@@ -9,16 +9,36 @@ import (
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/metrics"
+)
+
+// Ack modes: when messages are acknowledged to the broker.
+const (
+	// AckOnReceive subscribes at QoS 0; a message is gone once received.
+	AckOnReceive = "receive"
+	// AckOnProcessed subscribes at QoS 1 with a persistent session and
+	// acknowledges a message only once its ProcessHandler accepts it, so
+	// the broker redelivers anything unacknowledged after a reconnect.
+	AckOnProcessed = "processed"
 )
 
 type MessageHandler func(topic string, payload []byte)
 
+// ProcessHandler handles a message in AckOnProcessed mode and reports
+// whether it may be acknowledged.
+type ProcessHandler func(topic string, payload []byte) bool
+
 type Client struct {
 	conn      mqtt.Client
 	topics    []string
+	qos       byte
 	connected atomic.Bool
 	log       zerolog.Logger
 	handler   MessageHandler
+	process   ProcessHandler
+	slots     chan struct{} // AckOnProcessed: messages being processed
+	ready     chan struct{} // AckOnProcessed: closed once process is set
 }
 
 type Options struct {
@@ -28,11 +48,24 @@ type Options struct {
 	Username  string
 	Password  string
 	Log       zerolog.Logger
+
+	// InstanceMap and SubscribeMapped: with SubscribeMapped set, the client
+	// subscribes to each mapped prefix instead of a "#" in Topics.
+	InstanceMap     []InstanceMapping
+	SubscribeMapped bool
+
+	// AckMode is AckOnReceive (default) or AckOnProcessed. With
+	// AckOnProcessed, ClientID is used as is so the broker session survives
+	// restarts, and at most MaxInflight messages (default 16) are processed
+	// at once; further messages aren't read from the broker until one
+	// finishes.
+	AckMode     string
+	MaxInflight int
 }
 
 func Connect(opts Options) (*Client, error) {
 	c := &Client{
-		topics: parseTopics(opts.Topics),
+		topics: subscriptionFilters(parseTopics(opts.Topics), opts.InstanceMap, opts.SubscribeMapped),
 		log:    opts.Log,
 	}
 
@@ -40,10 +73,13 @@ func Connect(opts Options) (*Client, error) {
 	if clientID == "" {
 		clientID = "tr-engine"
 	}
-	// Append random suffix to avoid client ID collisions with other instances
-	var suffix [4]byte
-	rand.Read(suffix[:])
-	clientID = clientID + "-" + hex.EncodeToString(suffix[:])
+	processed := opts.AckMode == AckOnProcessed
+	if !processed {
+		// Append random suffix to avoid client ID collisions with other instances
+		var suffix [4]byte
+		rand.Read(suffix[:])
+		clientID = clientID + "-" + hex.EncodeToString(suffix[:])
+	}
 
 	c.log.Info().Str("client_id", clientID).Msg("connecting with client ID")
 
@@ -57,6 +93,22 @@ func Connect(opts Options) (*Client, error) {
 		SetConnectionLostHandler(c.onConnectionLost).
 		SetDefaultPublishHandler(c.onMessage)
 
+	if processed {
+		// Messages are handled in the router goroutine so that a full set of
+		// slots stops reading from the broker; paho no longer acks for us.
+		maxInflight := opts.MaxInflight
+		if maxInflight <= 0 {
+			maxInflight = 16
+		}
+		c.qos = 1
+		c.slots = make(chan struct{}, maxInflight)
+		c.ready = make(chan struct{})
+		clientOpts.
+			SetCleanSession(false).
+			SetOrderMatters(true).
+			SetAutoAckDisabled(true)
+	}
+
 	if opts.Username != "" {
 		clientOpts.SetUsername(opts.Username)
 	}
@@ -78,13 +130,22 @@ func (c *Client) SetMessageHandler(h MessageHandler) {
 	c.handler = h
 }
 
+// SetProcessHandler sets the handler used in AckOnProcessed mode. Messages
+// the broker redelivers on connect wait for it. Call it once.
+func (c *Client) SetProcessHandler(h ProcessHandler) {
+	c.process = h
+	if c.ready != nil {
+		close(c.ready)
+	}
+}
+
 func (c *Client) onConnect(client mqtt.Client) {
 	c.connected.Store(true)
 	c.log.Info().Strs("topics", c.topics).Msg("mqtt connected, subscribing")
 
 	filters := make(map[string]byte, len(c.topics))
 	for _, t := range c.topics {
-		filters[t] = 0
+		filters[t] = c.qos
 	}
 	token := client.SubscribeMultiple(filters, nil)
 	token.Wait()
@@ -99,6 +160,21 @@ func (c *Client) onConnectionLost(_ mqtt.Client, err error) {
 }
 
 func (c *Client) onMessage(_ mqtt.Client, msg mqtt.Message) {
+	if c.slots != nil {
+		<-c.ready
+		c.slots <- struct{}{}
+		metrics.MQTTMessagesInflight.Inc()
+		go func() {
+			defer func() {
+				metrics.MQTTMessagesInflight.Dec()
+				<-c.slots
+			}()
+			if c.process(msg.Topic(), msg.Payload()) {
+				msg.Ack()
+			}
+		}()
+		return
+	}
 	if c.handler != nil {
 		c.handler(msg.Topic(), msg.Payload())
 		return
@@ -109,6 +185,11 @@ func (c *Client) onMessage(_ mqtt.Client, msg mqtt.Message) {
 		Msg("mqtt message received")
 }
 
+// Topics returns the topic filters the client subscribes to.
+func (c *Client) Topics() []string {
+	return c.topics
+}
+
 func (c *Client) IsConnected() bool {
 	return c.connected.Load()
 }
@@ -0,0 +1,83 @@
+package mqttclient
+
+import (
+	"sort"
+	"strings"
+)
+
+// InstanceMapping attributes every message under an MQTT topic prefix to a
+// trunk-recorder instance, for brokers shared by several TR instances with
+// different topic prefixes. A prefix may span several topic levels
+// ("site-a" or "county/site-a").
+type InstanceMapping struct {
+	Prefix     string `json:"prefix"`
+	InstanceID string `json:"instance_id"`
+}
+
+// ParseInstanceMap parses MQTT_INSTANCE_MAP ("prefix:instance_id,...").
+// Leading and trailing slashes on prefixes are ignored; a repeated prefix
+// keeps its last instance. Mappings are returned longest prefix first, the
+// order MatchInstance tries them in.
+func ParseInstanceMap(s string) []InstanceMapping {
+	byPrefix := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		prefix, id, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			continue
+		}
+		prefix = strings.Trim(strings.TrimSpace(prefix), "/")
+		id = strings.TrimSpace(id)
+		if prefix != "" && id != "" {
+			byPrefix[prefix] = id
+		}
+	}
+	mappings := make([]InstanceMapping, 0, len(byPrefix))
+	for prefix, id := range byPrefix {
+		mappings = append(mappings, InstanceMapping{Prefix: prefix, InstanceID: id})
+	}
+	sort.Slice(mappings, func(i, j int) bool {
+		a, b := mappings[i].Prefix, mappings[j].Prefix
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return a < b
+	})
+	return mappings
+}
+
+// MatchInstance returns the index of the mapping whose prefix covers topic,
+// whole topic levels only, or -1. mappings must be in ParseInstanceMap order
+// so the most specific prefix wins.
+func MatchInstance(mappings []InstanceMapping, topic string) int {
+	for i, m := range mappings {
+		if topic == m.Prefix || (strings.HasPrefix(topic, m.Prefix) && topic[len(m.Prefix)] == '/') {
+			return i
+		}
+	}
+	return -1
+}
+
+// subscriptionFilters returns the filters to subscribe to: topics, or with
+// mapped set, "{prefix}/#" for each mapping plus any topics other than "#".
+func subscriptionFilters(topics []string, mappings []InstanceMapping, mapped bool) []string {
+	if !mapped || len(mappings) == 0 {
+		return topics
+	}
+	seen := make(map[string]bool)
+	var filters []string
+	add := func(f string) {
+		if !seen[f] {
+			seen[f] = true
+			filters = append(filters, f)
+		}
+	}
+	for _, m := range mappings {
+		add(m.Prefix + "/#")
+	}
+	for _, t := range topics {
+		if t != "#" {
+			add(t)
+		}
+	}
+	return filters
+}
@@ -0,0 +1,56 @@
+package mqttclient
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseInstanceMap(t *testing.T) {
+	got := ParseInstanceMap(" site-a:a , county/site-b/:b,bad,:x,y:,site-a:a2,county:c")
+	want := []InstanceMapping{
+		{Prefix: "county/site-b", InstanceID: "b"},
+		{Prefix: "county", InstanceID: "c"}, // same length: alphabetical
+		{Prefix: "site-a", InstanceID: "a2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseInstanceMap = %+v, want %+v", got, want)
+	}
+	if got := ParseInstanceMap(""); len(got) != 0 {
+		t.Errorf("empty map = %+v", got)
+	}
+}
+
+func TestMatchInstance(t *testing.T) {
+	mappings := ParseInstanceMap("county:c,county/site-b:b,site-a:a")
+	tests := []struct {
+		topic string
+		want  string
+	}{
+		{"site-a/trunk_recorder/status", "a"},
+		{"site-a", "a"},
+		{"county/site-b/feeds/call_start", "b"},
+		{"county/site-c/feeds/call_start", "c"},
+		{"site-ab/trunk_recorder/status", ""},
+		{"trunk_recorder/status", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if i := MatchInstance(mappings, tt.topic); i >= 0 {
+			got = mappings[i].InstanceID
+		}
+		if got != tt.want {
+			t.Errorf("MatchInstance(%q) = %q, want %q", tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestSubscriptionFilters(t *testing.T) {
+	mappings := ParseInstanceMap("site-a:a,site-b:b")
+	if got := subscriptionFilters([]string{"#"}, mappings, false); !reflect.DeepEqual(got, []string{"#"}) {
+		t.Errorf("unmapped = %v", got)
+	}
+	got := subscriptionFilters([]string{"#", "legacy/#", "site-a/#"}, mappings, true)
+	if want := []string{"site-a/#", "site-b/#", "legacy/#"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("mapped = %v, want %v", got, want)
+	}
+}
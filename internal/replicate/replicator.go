@@ -0,0 +1,745 @@
+// Package replicate pushes calls from an edge tr-engine to a central one.
+//
+// An edge instance at a receive site with a poor uplink sends every finished
+// call to the central instance's call-upload API: metadata and audio as an
+// rdio-scanner upload, the audio in resumable chunks (the central's
+// /call-upload/sessions) so a dropped link resumes where it stopped instead of
+// starting over. Uploads can be capped to a bandwidth and limited to an
+// off-peak window. The central assigns its own call IDs and rejects a call it
+// already has (same system, talkgroup and start time) as a duplicate, so
+// replicated calls never collide with the central's own or another edge's;
+// the edge records the central's call_id in call_replications.
+package replicate
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/time/rate"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/storage"
+)
+
+// Options configures a Replicator.
+type Options struct {
+	URL       string        // central tr-engine base URL
+	Token     string        // central WRITE_TOKEN
+	MaxKbps   int           // upload bandwidth cap in kbit/s; 0 = unlimited
+	Window    Window        // off-peak window; the zero Window is all day
+	Delay     time.Duration // minimum time since a call ended before sending it
+	Backfill  time.Duration // calls that started longer ago are not sent
+	Interval  time.Duration // how often to look for calls to send
+	ChunkSize int           // audio bytes per PATCH (default 256 KiB)
+	BatchSize int           // calls per query (default 100)
+}
+
+// Window is a daily time-of-day range, local time. It may wrap midnight
+// ("22:00-06:00"); the zero Window, or equal ends, covers the whole day.
+type Window struct {
+	start, end int // minutes since midnight
+}
+
+// ParseWindow parses "HH:MM-HH:MM". An empty string is the whole day.
+func ParseWindow(s string) (Window, error) {
+	if s == "" {
+		return Window{}, nil
+	}
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return Window{}, fmt.Errorf("window %q must be HH:MM-HH:MM", s)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return Window{}, fmt.Errorf("window %q: %w", s, err)
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return Window{}, fmt.Errorf("window %q: %w", s, err)
+	}
+	return Window{start: start, end: end}, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Contains reports whether t falls in the window.
+func (w Window) Contains(t time.Time) bool {
+	if w.start == w.end {
+		return true
+	}
+	m := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return m >= w.start && m < w.end
+	}
+	return m >= w.start || m < w.end
+}
+
+func (w Window) String() string {
+	if w.start == w.end {
+		return ""
+	}
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.start/60, w.start%60, w.end/60, w.end%60)
+}
+
+// RunResult summarizes one replication run.
+type RunResult struct {
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Sent       int        `json:"sent"`
+	Duplicates int        `json:"duplicates"` // already on the central instance
+	Failed     int        `json:"failed"`
+	Rejected   int        `json:"rejected"`
+	Bytes      int64      `json:"bytes"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Totals counts replication work since startup.
+type Totals struct {
+	Sent       int64 `json:"sent"`
+	Duplicates int64 `json:"duplicates"`
+	Failed     int64 `json:"failed"`
+	Rejected   int64 `json:"rejected"`
+	Bytes      int64 `json:"bytes"`
+}
+
+// Status reports the replicator's configuration and progress.
+type Status struct {
+	URL      string `json:"url"`
+	MaxKbps  int    `json:"max_kbps"`
+	Window   string `json:"window,omitempty"`
+	InWindow bool   `json:"in_window"`
+	Backfill string `json:"backfill"`
+	Running  bool   `json:"running"`
+	database.ReplicationStats
+	Totals  Totals     `json:"totals"`
+	LastRun *RunResult `json:"last_run"`
+}
+
+// Replicator sends finished calls to the central instance in the background.
+type Replicator struct {
+	db     *database.DB
+	store  storage.AudioStore
+	opts   Options
+	client *http.Client
+	limit  *rate.Limiter // nil when uncapped
+	log    zerolog.Logger
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+
+	running    atomic.Bool
+	sent       atomic.Int64
+	duplicates atomic.Int64
+	failed     atomic.Int64
+	rejected   atomic.Int64
+	bytes      atomic.Int64
+
+	mu      sync.Mutex
+	lastRun *RunResult
+}
+
+// New creates a replicator.
+func New(db *database.DB, store storage.AudioStore, opts Options, log zerolog.Logger) *Replicator {
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = 256 << 10
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	opts.URL = strings.TrimRight(opts.URL, "/")
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Replicator{
+		db:     db,
+		store:  store,
+		opts:   opts,
+		client: &http.Client{Timeout: 5 * time.Minute},
+		log:    log.With().Str("component", "replicate").Logger(),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	if opts.MaxKbps > 0 {
+		bps := opts.MaxKbps * 1000 / 8
+		r.limit = rate.NewLimiter(rate.Limit(bps), max(bps, readPiece))
+	}
+	return r
+}
+
+func (r *Replicator) Start() { go r.loop() }
+
+// Stop ends background work, including a run in progress.
+func (r *Replicator) Stop() { r.stopOnce.Do(r.cancel) }
+
+func (r *Replicator) loop() {
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.tick()
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Replicator) tick() {
+	if !r.opts.Window.Contains(time.Now()) {
+		return
+	}
+	if !r.running.CompareAndSwap(false, true) {
+		return
+	}
+	defer r.running.Store(false)
+	r.run(true)
+}
+
+// Run starts a run now in the background, regardless of the off-peak
+// window. Returns an error if one is already in progress.
+func (r *Replicator) Run() error {
+	if !r.running.CompareAndSwap(false, true) {
+		return fmt.Errorf("replication run already in progress")
+	}
+	go func() {
+		defer r.running.Store(false)
+		r.run(false)
+	}()
+	return nil
+}
+
+// run sends every eligible call, a batch at a time. A scheduled run stops at
+// the end of the window. The caller holds running.
+func (r *Replicator) run(scheduled bool) {
+	res := &RunResult{StartedAt: time.Now()}
+	r.mu.Lock()
+	r.lastRun = res
+	r.mu.Unlock()
+
+	err := r.sendPending(res, scheduled)
+
+	now := time.Now()
+	r.mu.Lock()
+	res.FinishedAt = &now
+	if err != nil {
+		res.Error = err.Error()
+	}
+	r.mu.Unlock()
+
+	switch {
+	case err != nil:
+		r.log.Warn().Err(err).Int("sent", res.Sent).Int("failed", res.Failed).Msg("replication run failed")
+	case res.Failed > 0 || res.Rejected > 0:
+		r.log.Warn().Int("sent", res.Sent).Int("failed", res.Failed).Int("rejected", res.Rejected).
+			Msg("replication run finished with failures")
+	case res.Sent > 0:
+		r.log.Info().Int("sent", res.Sent).Int("duplicates", res.Duplicates).Int64("bytes", res.Bytes).
+			Msg("calls replicated")
+	}
+}
+
+func (r *Replicator) sendPending(res *RunResult, scheduled bool) error {
+	// Failed calls are skipped for the rest of the run (and the next few).
+	retryAfter := 5 * r.opts.Interval
+	for {
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
+		now := time.Now()
+		batch, err := r.db.ListReplicationCandidates(r.ctx,
+			now.Add(-r.opts.Backfill), now.Add(-r.opts.Delay), retryAfter, r.opts.BatchSize)
+		if err != nil {
+			return fmt.Errorf("list candidates: %w", err)
+		}
+		for _, c := range batch {
+			if err := r.ctx.Err(); err != nil {
+				return err
+			}
+			if scheduled && !r.opts.Window.Contains(time.Now()) {
+				return nil
+			}
+			r.replicate(c, res)
+		}
+		if len(batch) < r.opts.BatchSize {
+			return nil
+		}
+	}
+}
+
+// replicate sends one call and records the outcome.
+func (r *Replicator) replicate(c database.ReplicationCandidate, res *RunResult) {
+	ctx, cancel := context.WithTimeout(r.ctx, 30*time.Minute)
+	defer cancel()
+
+	var data []byte
+	var err error
+	if c.AudioPath != "" {
+		data, err = r.readAudio(ctx, c.AudioPath)
+	}
+	var out outcome
+	if err == nil {
+		out, err = r.push(ctx, c, data, func(session string, offset int64) {
+			if perr := r.db.SaveReplicationProgress(ctx, c.Ref, session, offset); perr != nil {
+				r.log.Warn().Err(perr).Int64("call_id", c.Ref.CallID).Msg("failed to save replication progress")
+			}
+		})
+	}
+	if err != nil {
+		rejected := isRejected(err)
+		r.log.Debug().Err(err).Int64("call_id", c.Ref.CallID).Bool("rejected", rejected).Msg("replication failed")
+		if recErr := r.db.RecordReplicationFailure(ctx, c.Ref, err.Error(), rejected); recErr != nil {
+			r.log.Warn().Err(recErr).Int64("call_id", c.Ref.CallID).Msg("failed to record replication failure")
+		}
+		r.mu.Lock()
+		if rejected {
+			res.Rejected++
+		} else {
+			res.Failed++
+		}
+		r.mu.Unlock()
+		if rejected {
+			r.log.Warn().Err(err).Int64("call_id", c.Ref.CallID).Msg("central instance rejected call")
+			r.rejected.Add(1)
+		} else {
+			r.failed.Add(1)
+		}
+		return
+	}
+	if err := r.db.MarkCallReplicated(ctx, c.Ref, out.remoteCallID); err != nil {
+		r.log.Warn().Err(err).Int64("call_id", c.Ref.CallID).Msg("failed to mark call replicated")
+	}
+	r.mu.Lock()
+	if out.duplicate {
+		res.Duplicates++
+	} else {
+		res.Sent++
+	}
+	res.Bytes += out.bytes
+	r.mu.Unlock()
+	if out.duplicate {
+		r.duplicates.Add(1)
+	} else {
+		r.sent.Add(1)
+	}
+	r.bytes.Add(out.bytes)
+}
+
+func (r *Replicator) readAudio(ctx context.Context, key string) ([]byte, error) {
+	rc, err := r.store.Open(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("open audio: %w", err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("read audio: %w", err)
+	}
+	return data, nil
+}
+
+// outcome is a call the central instance has.
+type outcome struct {
+	remoteCallID int64
+	duplicate    bool  // it had the call already
+	bytes        int64 // audio bytes sent this attempt
+}
+
+// centralError is an error response from the central instance.
+type centralError struct {
+	status int
+	code   string
+	msg    string
+	retry  bool // a transfer problem, not a verdict on the call
+}
+
+func (e *centralError) Error() string {
+	if e.msg == "" {
+		return fmt.Sprintf("central returned %d", e.status)
+	}
+	return fmt.Sprintf("central returned %d: %s", e.status, e.msg)
+}
+
+// isRejected reports whether the central refused the call itself (bad
+// fields, encryption policy, unknown system with INGEST_AUTO_CREATE=deny), so
+// retrying won't help. Auth, timeouts, rate limits and conflicts are retried.
+func isRejected(err error) bool {
+	var ce *centralError
+	if !errors.As(err, &ce) || ce.retry || ce.status < 400 || ce.status >= 500 {
+		return false
+	}
+	switch ce.status {
+	case http.StatusUnauthorized, http.StatusNotFound, http.StatusRequestTimeout,
+		http.StatusConflict, http.StatusTooManyRequests:
+		return false
+	}
+	return true
+}
+
+var duplicateIDRe = regexp.MustCompile(`call_id=(\d+)`)
+
+// push sends c to the central instance: metadata only as one multipart
+// upload, with audio as a resumable session continued from c.SessionPath.
+// progress is called with the session and the central's offset as the
+// upload advances.
+func (r *Replicator) push(ctx context.Context, c database.ReplicationCandidate, data []byte, progress func(session string, offset int64)) (outcome, error) {
+	fields := uploadFields(c)
+	if len(data) == 0 {
+		return r.uploadMetadata(ctx, fields)
+	}
+
+	session, offset := c.SessionPath, int64(0)
+	if session != "" {
+		var err error
+		offset, err = r.sessionOffset(ctx, session)
+		var ce *centralError
+		if errors.As(err, &ce) && ce.status == http.StatusNotFound {
+			session = "" // expired or finalized; start over
+		} else if err != nil {
+			return outcome{}, err
+		}
+	}
+	if session == "" {
+		var err error
+		if session, err = r.createSession(ctx, fields, data); err != nil {
+			return outcome{}, err
+		}
+		offset = 0
+		progress(session, 0)
+	}
+
+	var sent int64
+	size := int64(len(data))
+	for offset < size {
+		end := min(offset+int64(r.opts.ChunkSize), size)
+		next, err := r.patch(ctx, session, offset, data[offset:end])
+		if err != nil {
+			var ce *centralError
+			if errors.As(err, &ce) && ce.status == http.StatusNotFound {
+				progress("", 0)
+			}
+			return outcome{bytes: sent}, err
+		}
+		if next > offset {
+			sent += next - offset
+		}
+		offset = next
+		progress(session, offset)
+	}
+
+	out, err := r.finalize(ctx, session)
+	out.bytes = sent
+	var ce *centralError
+	if errors.As(err, &ce) && ce.status == http.StatusBadRequest && ce.code == "invalid_body" {
+		// The assembled file failed its checksum and the central deleted
+		// the session; upload it again.
+		ce.retry = true
+		progress("", 0)
+	}
+	return out, err
+}
+
+// uploadFields builds the rdio-scanner call-upload fields for c.
+func uploadFields(c database.ReplicationCandidate) map[string]string {
+	f := map[string]string{
+		"talkgroup":   strconv.Itoa(c.Tgid),
+		"dateTime":    strconv.FormatInt(c.Ref.StartTime.Unix(), 10),
+		"systemLabel": c.SysName,
+		"emergency":   boolField(c.Emergency),
+		"encrypted":   boolField(c.Encrypted),
+	}
+	set := func(k, v string) {
+		if v != "" {
+			f[k] = v
+		}
+	}
+	if c.StopTime != nil {
+		f["stopTime"] = strconv.FormatInt(c.StopTime.Unix(), 10)
+	}
+	if c.Duration != nil {
+		f["callLength"] = strconv.Itoa(int(*c.Duration + 0.5))
+	}
+	if c.Freq != nil {
+		f["frequency"] = strconv.FormatInt(*c.Freq, 10)
+	}
+	set("talkgroupLabel", c.AlphaTag)
+	set("talkgroupName", c.Description)
+	set("talkgroupTag", c.Tag)
+	set("talkgroupGroup", c.Group)
+	set("audioType", c.AudioType)
+	if len(c.Sources) > 0 {
+		f["sources"] = string(c.Sources)
+	}
+	if len(c.Frequencies) > 0 {
+		f["frequencies"] = string(c.Frequencies)
+	}
+	if c.AudioPath != "" {
+		f["audioName"] = path.Base(c.AudioPath)
+	}
+	return f
+}
+
+func boolField(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+func (r *Replicator) newRequest(ctx context.Context, method, p string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, r.opts.URL+p, body)
+	if err != nil {
+		return nil, err
+	}
+	if r.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.opts.Token)
+	}
+	return req, nil
+}
+
+// do sends req and returns the response if its status is one of ok;
+// otherwise the body is read into a centralError.
+func (r *Replicator) do(req *http.Request, ok ...int) (*http.Response, error) {
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ok {
+		if resp.StatusCode == s {
+			return resp, nil
+		}
+	}
+	defer resp.Body.Close()
+	ce := &centralError{status: resp.StatusCode}
+	var body struct {
+		Code  string `json:"code"`
+		Error string `json:"error"`
+	}
+	if json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body) == nil {
+		ce.code, ce.msg = body.Code, body.Error
+	}
+	return nil, ce
+}
+
+// created parses a call-upload result, treating a duplicate as done.
+func created(resp *http.Response, err error) (outcome, error) {
+	var ce *centralError
+	if errors.As(err, &ce) && ce.status == http.StatusConflict && ce.code == "duplicate" {
+		out := outcome{duplicate: true}
+		if m := duplicateIDRe.FindStringSubmatch(ce.msg); m != nil {
+			out.remoteCallID, _ = strconv.ParseInt(m[1], 10, 64)
+		}
+		return out, nil
+	}
+	if err != nil {
+		return outcome{}, err
+	}
+	defer resp.Body.Close()
+	var body struct {
+		CallID int64 `json:"call_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return outcome{}, fmt.Errorf("decode upload result: %w", err)
+	}
+	return outcome{remoteCallID: body.CallID}, nil
+}
+
+func (r *Replicator) uploadMetadata(ctx context.Context, fields map[string]string) (outcome, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			return outcome{}, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return outcome{}, err
+	}
+	req, err := r.newRequest(ctx, http.MethodPost, "/api/v1/call-upload", &buf)
+	if err != nil {
+		return outcome{}, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return created(r.do(req, http.StatusCreated))
+}
+
+func (r *Replicator) createSession(ctx context.Context, fields map[string]string, data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	body, err := json.Marshal(map[string]any{
+		"format":   "rdio-scanner",
+		"fields":   fields,
+		"filename": fields["audioName"],
+		"size":     len(data),
+		"sha256":   hex.EncodeToString(sum[:]),
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := r.newRequest(ctx, http.MethodPost, "/api/v1/call-upload/sessions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.do(req, http.StatusCreated)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	loc := resp.Header.Get("Location")
+	if !strings.HasPrefix(loc, "/api/v1/call-upload/sessions/") {
+		return "", fmt.Errorf("upload session created without a Location")
+	}
+	return loc, nil
+}
+
+// sessionOffset asks the central how much of session it has.
+func (r *Replicator) sessionOffset(ctx context.Context, session string) (int64, error) {
+	req, err := r.newRequest(ctx, http.MethodHead, session, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := r.do(req, http.StatusOK)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return headerOffset(resp)
+}
+
+func headerOffset(resp *http.Response) (int64, error) {
+	off, err := strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("central sent no Upload-Offset")
+	}
+	return off, nil
+}
+
+// patch sends chunk at offset and returns the central's new offset. On an
+// offset conflict (a chunk that landed though its response was lost) it
+// returns the central's offset to continue from.
+func (r *Replicator) patch(ctx context.Context, session string, offset int64, chunk []byte) (int64, error) {
+	sum := sha256.Sum256(chunk)
+	req, err := r.newRequest(ctx, http.MethodPatch, session, r.throttle(ctx, bytes.NewReader(chunk)))
+	if err != nil {
+		return 0, err
+	}
+	req.ContentLength = int64(len(chunk))
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	req.Header.Set("Upload-Checksum", "sha256 "+base64.StdEncoding.EncodeToString(sum[:]))
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		if next, err := headerOffset(resp); err == nil {
+			return next, nil
+		}
+		return offset + int64(len(chunk)), nil
+	case http.StatusConflict:
+		return headerOffset(resp)
+	}
+	ce := &centralError{status: resp.StatusCode}
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body) == nil {
+		ce.msg = body.Error
+	}
+	// 400 is an interrupted or corrupted chunk: resend it.
+	ce.retry = resp.StatusCode == http.StatusBadRequest
+	return 0, ce
+}
+
+func (r *Replicator) finalize(ctx context.Context, session string) (outcome, error) {
+	req, err := r.newRequest(ctx, http.MethodPost, session+"/finalize", nil)
+	if err != nil {
+		return outcome{}, err
+	}
+	return created(r.do(req, http.StatusCreated))
+}
+
+// readPiece is the most bytes a throttled read takes from the limiter at
+// once, so the cap is smooth rather than one chunk per burst.
+const readPiece = 16 << 10
+
+// throttle caps reads from rd to the configured bandwidth.
+func (r *Replicator) throttle(ctx context.Context, rd io.Reader) io.Reader {
+	if r.limit == nil {
+		return rd
+	}
+	return &limitedReader{ctx: ctx, r: rd, limit: r.limit}
+}
+
+type limitedReader struct {
+	ctx   context.Context
+	r     io.Reader
+	limit *rate.Limiter
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > readPiece {
+		p = p[:readPiece]
+	}
+	n, err := l.r.Read(p)
+	if n > 0 {
+		if werr := l.limit.WaitN(l.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// Status returns the backfill window's counts and run progress.
+func (r *Replicator) Status(ctx context.Context) (Status, error) {
+	stats, err := r.db.GetReplicationStats(ctx, time.Now().Add(-r.opts.Backfill))
+	if err != nil {
+		return Status{}, err
+	}
+	s := Status{
+		URL:              r.opts.URL,
+		MaxKbps:          r.opts.MaxKbps,
+		Window:           r.opts.Window.String(),
+		InWindow:         r.opts.Window.Contains(time.Now()),
+		Backfill:         r.opts.Backfill.String(),
+		Running:          r.running.Load(),
+		ReplicationStats: stats,
+		Totals: Totals{
+			Sent:       r.sent.Load(),
+			Duplicates: r.duplicates.Load(),
+			Failed:     r.failed.Load(),
+			Rejected:   r.rejected.Load(),
+			Bytes:      r.bytes.Load(),
+		},
+	}
+	r.mu.Lock()
+	if r.lastRun != nil {
+		lr := *r.lastRun
+		s.LastRun = &lr
+	}
+	r.mu.Unlock()
+	return s, nil
+}
@@ -0,0 +1,220 @@
+package replicate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+func TestWindow(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2026, 3, 1, h, m, 0, 0, time.Local) }
+	tests := []struct {
+		window string
+		in     []time.Time
+		out    []time.Time
+	}{
+		{"", []time.Time{at(0, 0), at(12, 0), at(23, 59)}, nil},
+		{"01:00-05:30", []time.Time{at(1, 0), at(5, 29)}, []time.Time{at(0, 59), at(5, 30), at(12, 0)}},
+		{"22:00-06:00", []time.Time{at(22, 0), at(23, 59), at(0, 0), at(5, 59)}, []time.Time{at(6, 0), at(21, 59)}},
+	}
+	for _, tt := range tests {
+		w, err := ParseWindow(tt.window)
+		if err != nil {
+			t.Fatalf("ParseWindow(%q): %v", tt.window, err)
+		}
+		if w.String() != tt.window {
+			t.Errorf("ParseWindow(%q).String() = %q", tt.window, w.String())
+		}
+		for _, ts := range tt.in {
+			if !w.Contains(ts) {
+				t.Errorf("%q should contain %s", tt.window, ts.Format("15:04"))
+			}
+		}
+		for _, ts := range tt.out {
+			if w.Contains(ts) {
+				t.Errorf("%q should not contain %s", tt.window, ts.Format("15:04"))
+			}
+		}
+	}
+	for _, bad := range []string{"22:00", "25:00-06:00", "22:00-6pm"} {
+		if _, err := ParseWindow(bad); err == nil {
+			t.Errorf("ParseWindow(%q) should fail", bad)
+		}
+	}
+}
+
+// fakeCentral mimics a central tr-engine's call-upload API: one resumable
+// session at a time, and the first call for a talkgroup and start time gets
+// call_id 42 while later ones are duplicates. The second PATCH it receives
+// fails as if the link dropped.
+type fakeCentral struct {
+	mu      sync.Mutex
+	patches int
+	size    int64
+	part    []byte
+	fields  map[string]string
+	calls   map[string]bool
+	audio   []byte
+}
+
+func (f *fakeCentral) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	const session = "/api/v1/call-upload/sessions/0123"
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/call-upload":
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fields := map[string]string{}
+		for k, v := range r.MultipartForm.Value {
+			fields[k] = v[0]
+		}
+		f.ingest(w, fields, nil)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/call-upload/sessions":
+		var req struct {
+			Fields map[string]string `json:"fields"`
+			Size   int64             `json:"size"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		f.fields, f.size, f.part = req.Fields, req.Size, nil
+		w.Header().Set("Location", session)
+		w.WriteHeader(http.StatusCreated)
+	case r.URL.Path != session && r.URL.Path != session+"/finalize":
+		http.NotFound(w, r)
+	case r.Method == http.MethodHead:
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(f.part)))
+	case r.Method == http.MethodPatch:
+		if f.patches++; f.patches == 2 {
+			http.Error(w, "link down", http.StatusBadGateway)
+			return
+		}
+		if r.Header.Get("Upload-Offset") != strconv.Itoa(len(f.part)) {
+			w.Header().Set("Upload-Offset", strconv.Itoa(len(f.part)))
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		chunk, _ := io.ReadAll(r.Body)
+		f.part = append(f.part, chunk...)
+		w.Header().Set("Upload-Offset", strconv.Itoa(len(f.part)))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPost:
+		if int64(len(f.part)) != f.size {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.ingest(w, f.fields, f.part)
+	}
+}
+
+func (f *fakeCentral) ingest(w http.ResponseWriter, fields map[string]string, audio []byte) {
+	key := fields["talkgroup"] + "/" + fields["dateTime"]
+	w.Header().Set("Content-Type", "application/json")
+	if f.calls[key] {
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprintf(w, `{"code":"duplicate","error":"duplicate call: call_id=42 already exists for system=1 tgid=%s"}`, fields["talkgroup"])
+		return
+	}
+	f.calls[key] = true
+	f.fields, f.audio = fields, audio
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprint(w, `{"call_id":42}`)
+}
+
+func TestPush(t *testing.T) {
+	central := &fakeCentral{calls: map[string]bool{}}
+	srv := httptest.NewServer(central)
+	defer srv.Close()
+
+	r := New(nil, nil, Options{URL: srv.URL + "/", ChunkSize: 4, MaxKbps: 1000}, zerolog.Nop())
+	start := time.Unix(1772373600, 0)
+	dur := float32(2.6)
+	c := database.ReplicationCandidate{
+		Ref:       database.CallRef{CallID: 7, StartTime: start},
+		SysName:   "warco",
+		Tgid:      9131,
+		Duration:  &dur,
+		AlphaTag:  "Fire Dispatch",
+		AudioType: "m4a",
+		AudioPath: "warco/2026-03-01/9131-1772373600.m4a",
+		Sources:   []byte(`[{"src":1234,"time":1772373600,"pos":0}]`),
+	}
+	audio := []byte("0123456789")
+
+	var session string
+	var offset int64
+	progress := func(s string, off int64) { session, offset = s, off }
+
+	if _, err := r.push(context.Background(), c, audio, progress); err == nil {
+		t.Fatal("push over a dropped link should fail")
+	} else if isRejected(err) {
+		t.Errorf("dropped link counted as a rejection: %v", err)
+	}
+	if session == "" || offset != 4 {
+		t.Fatalf("after the drop session, offset = %q, %d; want a session at 4", session, offset)
+	}
+
+	c.SessionPath = session
+	out, err := r.push(context.Background(), c, audio, progress)
+	if err != nil {
+		t.Fatalf("resumed push: %v", err)
+	}
+	if out.remoteCallID != 42 || out.duplicate || out.bytes != 6 {
+		t.Errorf("resumed push = %+v, want call 42 with the remaining 6 bytes", out)
+	}
+	if string(central.audio) != string(audio) {
+		t.Errorf("central got audio %q", central.audio)
+	}
+	f := central.fields
+	if f["talkgroup"] != "9131" || f["dateTime"] != "1772373600" || f["systemLabel"] != "warco" ||
+		f["talkgroupLabel"] != "Fire Dispatch" || f["callLength"] != "3" || f["sources"] == "" {
+		t.Errorf("central got fields %v", f)
+	}
+
+	// A call the central already has is done, with its call_id there.
+	c.SessionPath = ""
+	out, err = r.push(context.Background(), c, audio, progress)
+	if err != nil || !out.duplicate || out.remoteCallID != 42 {
+		t.Errorf("duplicate push = %+v, %v", out, err)
+	}
+
+	// Metadata-only calls go as a single upload.
+	c.Ref.StartTime = start.Add(time.Minute)
+	c.AudioPath = ""
+	out, err = r.push(context.Background(), c, nil, progress)
+	if err != nil || out.remoteCallID != 42 || central.fields["dateTime"] != "1772373660" {
+		t.Errorf("metadata-only push = %+v, %v", out, err)
+	}
+}
+
+func TestIsRejected(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&centralError{status: http.StatusBadRequest}, true},
+		{&centralError{status: http.StatusForbidden}, true},
+		{&centralError{status: http.StatusBadRequest, retry: true}, false},
+		{&centralError{status: http.StatusUnauthorized}, false},
+		{&centralError{status: http.StatusTooManyRequests}, false},
+		{&centralError{status: http.StatusInternalServerError}, false},
+		{fmt.Errorf("dial tcp: connection refused"), false},
+	}
+	for _, tt := range tests {
+		if got := isRejected(tt.err); got != tt.want {
+			t.Errorf("isRejected(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
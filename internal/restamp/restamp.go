@@ -0,0 +1,218 @@
+// Package restamp re-stamps the talkgroup and unit names stored on calls.
+//
+// Ingest copies a talkgroup's alpha tag, description, tag and group onto each
+// call and call group, and unit alpha tags into src_list and
+// call_transmissions, as they were when the call was recorded. After a
+// talkgroup is renamed or a directory or unit roster is imported, call history
+// keeps the old names. A Restamper rewrites them from the current talkgroups
+// and units tables for one system, some talkgroups or a time range, a day at a
+// time so progress can be reported and a large range never holds one long
+// transaction.
+package restamp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// ChunkSize is the span of call start times re-stamped per transaction.
+const ChunkSize = 24 * time.Hour
+
+// Scope selects the calls to re-stamp. A zero Start is the scope's first call
+// and a zero End is now.
+type Scope struct {
+	SystemID int       `json:"system_id,omitempty"` // 0 = every system
+	Tgids    []int     `json:"tgids,omitempty"`     // requires SystemID
+	Start    time.Time `json:"start_time"`
+	End      time.Time `json:"end_time"`
+}
+
+// Validate reports a scope the Restamper won't run.
+func (s Scope) Validate() error {
+	if len(s.Tgids) > 0 && s.SystemID == 0 {
+		return fmt.Errorf("tgids requires system_id")
+	}
+	if !s.Start.IsZero() && !s.End.IsZero() && !s.Start.Before(s.End) {
+		return fmt.Errorf("start_time must be before end_time")
+	}
+	return nil
+}
+
+// RunResult reports one run's scope and progress.
+type RunResult struct {
+	Scope
+	Trigger    string                 `json:"trigger"` // "api" or "import"
+	StartedAt  time.Time              `json:"started_at"`
+	FinishedAt *time.Time             `json:"finished_at,omitempty"`
+	Chunks     int                    `json:"chunks"`
+	ChunksDone int                    `json:"chunks_done"`
+	Updated    database.RestampCounts `json:"updated"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// Status reports whether a run is in progress and the latest run.
+type Status struct {
+	Running     bool       `json:"running"`
+	AfterImport string     `json:"after_import"` // lookback re-stamped after imports; "0s" = off
+	LastRun     *RunResult `json:"last_run"`
+}
+
+// Restamper runs one re-stamp at a time in the background.
+type Restamper struct {
+	db          *database.DB
+	afterImport time.Duration
+	log         zerolog.Logger
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+
+	running atomic.Bool
+
+	mu      sync.Mutex
+	lastRun *RunResult
+}
+
+// New creates a restamper. With afterImport > 0, AfterImport re-stamps that
+// much of a system's recent history.
+func New(db *database.DB, afterImport time.Duration, log zerolog.Logger) *Restamper {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Restamper{
+		db:          db,
+		afterImport: afterImport,
+		log:         log.With().Str("component", "restamp").Logger(),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+}
+
+// Stop ends a run in progress.
+func (r *Restamper) Stop() { r.stopOnce.Do(r.cancel) }
+
+// Run re-stamps the calls in scope in the background. Returns an error if the
+// scope is invalid or a run is already in progress.
+func (r *Restamper) Run(scope Scope, trigger string) error {
+	if err := scope.Validate(); err != nil {
+		return err
+	}
+	if !r.running.CompareAndSwap(false, true) {
+		return fmt.Errorf("call restamp already in progress")
+	}
+	if scope.End.IsZero() {
+		scope.End = time.Now()
+	}
+	// Recorded before returning so a status request right after sees this run
+	res := &RunResult{Scope: scope, Trigger: trigger, StartedAt: time.Now()}
+	r.mu.Lock()
+	r.lastRun = res
+	r.mu.Unlock()
+	go func() {
+		defer r.running.Store(false)
+		r.run(res)
+	}()
+	return nil
+}
+
+// AfterImport re-stamps the system's calls from the configured lookback after
+// a talkgroup directory or unit import. A no-op when the lookback is 0; a run
+// already in progress is left alone.
+func (r *Restamper) AfterImport(systemID int) {
+	if r.afterImport <= 0 {
+		return
+	}
+	now := time.Now()
+	err := r.Run(Scope{SystemID: systemID, Start: now.Add(-r.afterImport), End: now}, "import")
+	if err != nil {
+		r.log.Warn().Err(err).Int("system_id", systemID).Msg("skipped call restamp after import")
+	}
+}
+
+// run re-stamps one run's scope. The caller holds running.
+func (r *Restamper) run(res *RunResult) {
+	err := r.restamp(res)
+
+	now := time.Now()
+	r.mu.Lock()
+	res.FinishedAt = &now
+	if err != nil {
+		res.Error = err.Error()
+	}
+	updated := res.Updated
+	r.mu.Unlock()
+
+	l := r.log.With().Int("system_id", res.SystemID).Ints("tgids", res.Tgids).
+		Int64("calls", updated.Calls).Int64("call_groups", updated.CallGroups).
+		Int64("src_lists", updated.SrcLists).Int64("transmissions", updated.Transmissions).Logger()
+	if err != nil {
+		l.Warn().Err(err).Msg("call restamp failed")
+		return
+	}
+	l.Info().Str("trigger", res.Trigger).Msg("call restamp complete")
+}
+
+func (r *Restamper) restamp(res *RunResult) error {
+	dbScope := database.RestampScope{SystemID: res.SystemID, Tgids: res.Tgids}
+	start := res.Start
+	if start.IsZero() {
+		first, _, err := r.db.CallStartBounds(r.ctx, dbScope)
+		if err != nil {
+			return fmt.Errorf("find first call: %w", err)
+		}
+		if first == nil {
+			return nil
+		}
+		start = *first
+	}
+
+	bounds := Chunks(start, res.End, ChunkSize)
+	r.mu.Lock()
+	res.Start = start
+	res.Chunks = max(len(bounds)-1, 0)
+	r.mu.Unlock()
+
+	for i := 0; i+1 < len(bounds); i++ {
+		ctx, cancel := context.WithTimeout(r.ctx, 10*time.Minute)
+		n, err := r.db.RestampCalls(ctx, dbScope, bounds[i], bounds[i+1])
+		cancel()
+		if err != nil {
+			return fmt.Errorf("%s: %w", bounds[i].Format(time.RFC3339), err)
+		}
+		r.mu.Lock()
+		res.Updated.Add(n)
+		res.ChunksDone++
+		r.mu.Unlock()
+	}
+	return nil
+}
+
+// Chunks splits [start, end) into spans of at most size, returning their
+// boundaries (start, ..., end); nil when the range is empty.
+func Chunks(start, end time.Time, size time.Duration) []time.Time {
+	if !start.Before(end) {
+		return nil
+	}
+	bounds := []time.Time{start}
+	for t := start.Add(size); t.Before(end); t = t.Add(size) {
+		bounds = append(bounds, t)
+	}
+	return append(bounds, end)
+}
+
+// Status returns whether a run is in progress and the latest run's progress.
+func (r *Restamper) Status() Status {
+	s := Status{Running: r.running.Load(), AfterImport: r.afterImport.String()}
+	r.mu.Lock()
+	if r.lastRun != nil {
+		lr := *r.lastRun
+		s.LastRun = &lr
+	}
+	r.mu.Unlock()
+	return s
+}
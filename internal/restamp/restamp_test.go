@@ -0,0 +1,49 @@
+package restamp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestChunks(t *testing.T) {
+	start := time.Date(2026, 3, 1, 6, 0, 0, 0, time.UTC)
+	got := Chunks(start, start.Add(50*time.Hour), ChunkSize)
+	want := []time.Time{start, start.Add(24 * time.Hour), start.Add(48 * time.Hour), start.Add(50 * time.Hour)}
+	if len(got) != len(want) {
+		t.Fatalf("Chunks = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("bound %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+
+	if got := Chunks(start, start.Add(ChunkSize), ChunkSize); len(got) != 2 {
+		t.Errorf("exact chunk = %v, want [start end]", got)
+	}
+	if got := Chunks(start, start, ChunkSize); got != nil {
+		t.Errorf("empty range = %v, want nil", got)
+	}
+}
+
+func TestRunValidatesScope(t *testing.T) {
+	r := New(nil, 0, zerolog.Nop())
+	now := time.Now()
+	if err := r.Run(Scope{Tgids: []int{9131}}, "api"); err == nil {
+		t.Error("tgids without system_id accepted")
+	}
+	if err := r.Run(Scope{SystemID: 1, Start: now, End: now.Add(-time.Hour)}, "api"); err == nil {
+		t.Error("reversed range accepted")
+	}
+	if r.Status().Running {
+		t.Error("rejected scope left a run in progress")
+	}
+
+	// Off unless configured
+	r.AfterImport(1)
+	if r.Status().LastRun != nil || r.Status().Running {
+		t.Error("AfterImport ran with no lookback")
+	}
+}
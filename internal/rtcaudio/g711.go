@@ -0,0 +1,48 @@
+package rtcaudio
+
+import "encoding/binary"
+
+// pcmuRate is G.711's sample rate; browsers decode PCMU at 8 kHz only.
+const pcmuRate = 8000
+
+// pcmu converts 16-bit little-endian PCM at rate samples per second to
+// G.711 μ-law at 8 kHz. Rates that are a multiple of 8 kHz are averaged
+// down; others take the nearest sample.
+func pcmu(pcm []byte, rate int) []byte {
+	n := len(pcm) / 2
+	if rate <= 0 {
+		rate = pcmuRate
+	}
+	sample := func(i int) int16 { return int16(binary.LittleEndian.Uint16(pcm[2*i:])) }
+
+	out := make([]byte, n*pcmuRate/rate)
+	step := rate / pcmuRate
+	for i := range out {
+		if rate%pcmuRate == 0 {
+			sum := 0
+			for j := 0; j < step; j++ {
+				sum += int(sample(i*step + j))
+			}
+			out[i] = ulaw(int16(sum / step))
+		} else {
+			out[i] = ulaw(sample(i * rate / pcmuRate))
+		}
+	}
+	return out
+}
+
+// ulaw encodes one linear sample as G.711 μ-law.
+func ulaw(sample int16) byte {
+	const bias, clip = 0x84, 32635
+	s, sign := int(sample), 0
+	if s < 0 {
+		s, sign = -s, 0x80
+	}
+	s = min(s, clip) + bias
+	exp := 7
+	for mask := 0x4000; s&mask == 0 && exp > 0; mask >>= 1 {
+		exp--
+	}
+	mantissa := (s >> (exp + 3)) & 0x0F
+	return ^byte(sign | exp<<4 | mantissa)
+}
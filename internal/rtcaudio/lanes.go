@@ -0,0 +1,43 @@
+package rtcaudio
+
+import "time"
+
+// laneHold is how long a lane stays with a talkgroup after its last frame,
+// so the gaps between transmissions don't hand it to another talkgroup.
+const laneHold = time.Second
+
+// lane is the talkgroup an audio track is carrying.
+type lane struct {
+	systemID, tgid, unitID int
+	last                   time.Time
+}
+
+// lanes are a peer's audio tracks. A talkgroup with audio takes a free lane
+// and keeps it until laneHold passes without a frame.
+type lanes []lane
+
+// assign returns the lane for a frame, or -1 when all are busy with other
+// talkgroups. changed reports a new talkgroup or unit on the lane.
+func (ls lanes) assign(systemID, tgid, unitID int, now time.Time) (i int, changed bool) {
+	free := -1
+	for i := range ls {
+		l := &ls[i]
+		busy := l.tgid != 0 && now.Sub(l.last) <= laneHold
+		if busy && l.systemID == systemID && l.tgid == tgid {
+			changed = unitID != 0 && unitID != l.unitID
+			if unitID != 0 {
+				l.unitID = unitID
+			}
+			l.last = now
+			return i, changed
+		}
+		if !busy && free < 0 {
+			free = i
+		}
+	}
+	if free < 0 {
+		return -1, false
+	}
+	ls[free] = lane{systemID: systemID, tgid: tgid, unitID: unitID, last: now}
+	return free, true
+}
@@ -0,0 +1,372 @@
+// Package rtcaudio sends live audio to browsers over WebRTC, for listeners
+// who need lower latency than the WebSocket at /audio/live gives.
+//
+// Each peer is a pion PeerConnection with a fixed number of send-only G.711
+// μ-law tracks ("lanes") and a "meta" data channel. The peer subscribes to
+// the audio bus with the same talkgroup/system filter as the WebSocket; a
+// talkgroup with audio takes a free lane (see lanes.assign) and the data
+// channel announces which talkgroup and unit are on it. Frames are
+// forwarded, never mixed, so subscription changes need no renegotiation.
+//
+// Signaling is one round trip: Open returns the server's offer with ICE
+// gathering complete, and the client's answer goes to Answer. The live
+// encoder emits PCM; frames in any other format are not sent.
+package rtcaudio
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/audio"
+)
+
+// answerTimeout closes sessions whose offer was never answered.
+const answerTimeout = 30 * time.Second
+
+var (
+	ErrSessionNotFound = errors.New("webrtc session not found")
+	ErrTooManyPeers    = errors.New("maximum webrtc peers reached")
+	ErrInvalidAnswer   = errors.New("invalid answer")
+)
+
+// FrameSource is the live audio bus (ingest.Pipeline).
+type FrameSource interface {
+	SubscribeAudio(filter audio.AudioFilter) (<-chan audio.AudioFrame, func())
+	UpdateAudioFilter(ch <-chan audio.AudioFrame, filter audio.AudioFilter)
+}
+
+// Options configures a Publisher.
+type Options struct {
+	ICEServers    []string // stun:/turn: URLs for both ends
+	ICEUsername   string   // TURN credentials
+	ICECredential string
+	PortMin       uint16 // ICE UDP port range (0 = any)
+	PortMax       uint16
+	PublicIP      string // NAT 1:1 address for host candidates
+	MaxPeers      int    // default 50
+	Lanes         int    // tracks per peer (default 4)
+}
+
+// ICEServer is an ICE server as RTCPeerConnection takes it.
+type ICEServer struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+// Session is a new peer: the offer to answer and what the client needs to
+// set up its RTCPeerConnection.
+type Session struct {
+	ID         string      `json:"session_id"`
+	SDP        string      `json:"sdp"`   // offer
+	Lanes      []string    `json:"lanes"` // media section mid of each lane, lane 0 first
+	ICEServers []ICEServer `json:"ice_servers"`
+}
+
+// Status reports the publisher for GET /health.
+type Status struct {
+	Enabled    bool     `json:"enabled"`
+	Peers      int      `json:"peers"`
+	MaxPeers   int      `json:"max_peers"`
+	Lanes      int      `json:"lanes"`
+	ICEServers []string `json:"ice_servers,omitempty"`
+}
+
+// Publisher serves live audio to WebRTC peers.
+type Publisher struct {
+	src    FrameSource
+	opts   Options
+	api    *webrtc.API
+	config webrtc.Configuration
+	log    zerolog.Logger
+
+	mu    sync.Mutex
+	peers map[string]*peer
+}
+
+// peer is one session.
+type peer struct {
+	id     string
+	pc     *webrtc.PeerConnection
+	tracks []*webrtc.TrackLocalStaticSample
+	mids   []string
+	meta   *webrtc.DataChannel
+	frames <-chan audio.AudioFrame
+	cancel func()
+	admin  bool // filter.IncludeRestricted at Open; later filters keep it
+}
+
+// New creates a publisher fed by src.
+func New(src FrameSource, opts Options, log zerolog.Logger) (*Publisher, error) {
+	if opts.MaxPeers <= 0 {
+		opts.MaxPeers = 50
+	}
+	if opts.Lanes <= 0 {
+		opts.Lanes = 4
+	}
+	var se webrtc.SettingEngine
+	if opts.PortMin != 0 {
+		if err := se.SetEphemeralUDPPortRange(opts.PortMin, opts.PortMax); err != nil {
+			return nil, fmt.Errorf("udp port range: %w", err)
+		}
+	}
+	if opts.PublicIP != "" {
+		se.SetNAT1To1IPs([]string{opts.PublicIP}, webrtc.ICECandidateTypeHost)
+	}
+	var me webrtc.MediaEngine
+	if err := me.RegisterDefaultCodecs(); err != nil {
+		return nil, err
+	}
+	p := &Publisher{
+		src:   src,
+		opts:  opts,
+		api:   webrtc.NewAPI(webrtc.WithSettingEngine(se), webrtc.WithMediaEngine(&me)),
+		log:   log.With().Str("component", "webrtc").Logger(),
+		peers: make(map[string]*peer),
+	}
+	if len(opts.ICEServers) > 0 {
+		p.config.ICEServers = []webrtc.ICEServer{{
+			URLs:       opts.ICEServers,
+			Username:   opts.ICEUsername,
+			Credential: opts.ICECredential,
+		}}
+	}
+	return p, nil
+}
+
+// Open creates a session receiving frames that match filter and returns
+// its offer. filter.IncludeRestricted holds for the life of the session.
+// Returns ErrTooManyPeers at MaxPeers.
+func (p *Publisher) Open(filter audio.AudioFilter) (*Session, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	if len(p.peers) >= p.opts.MaxPeers {
+		p.mu.Unlock()
+		return nil, ErrTooManyPeers
+	}
+	p.peers[id] = nil // hold the slot while the offer is built
+	p.mu.Unlock()
+
+	pe, offer, err := p.newPeer(id)
+	if err != nil {
+		p.mu.Lock()
+		delete(p.peers, id)
+		p.mu.Unlock()
+		return nil, err
+	}
+	pe.admin = filter.IncludeRestricted
+	pe.frames, pe.cancel = p.src.SubscribeAudio(filter)
+	p.mu.Lock()
+	p.peers[id] = pe
+	p.mu.Unlock()
+	go p.forward(pe)
+
+	time.AfterFunc(answerTimeout, func() {
+		if pe.pc.RemoteDescription() == nil {
+			p.Close(id)
+		}
+	})
+
+	s := &Session{ID: id, SDP: offer, Lanes: pe.mids, ICEServers: []ICEServer{}}
+	for _, srv := range p.config.ICEServers {
+		cred, _ := srv.Credential.(string)
+		s.ICEServers = append(s.ICEServers, ICEServer{URLs: srv.URLs, Username: srv.Username, Credential: cred})
+	}
+	return s, nil
+}
+
+// newPeer builds a peer connection with its lanes and data channel, and
+// returns its offer once ICE gathering has finished.
+func (p *Publisher) newPeer(id string) (*peer, string, error) {
+	pc, err := p.api.NewPeerConnection(p.config)
+	if err != nil {
+		return nil, "", err
+	}
+	pe := &peer{id: id, pc: pc}
+	fail := func(err error) (*peer, string, error) {
+		pc.Close()
+		return nil, "", err
+	}
+
+	codec := webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypePCMU, ClockRate: pcmuRate, Channels: 1}
+	var transceivers []*webrtc.RTPTransceiver
+	for i := 0; i < p.opts.Lanes; i++ {
+		track, err := webrtc.NewTrackLocalStaticSample(codec, fmt.Sprintf("lane%d", i), "tr-engine")
+		if err != nil {
+			return fail(err)
+		}
+		tr, err := pc.AddTransceiverFromTrack(track, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionSendonly})
+		if err != nil {
+			return fail(err)
+		}
+		go drainRTCP(tr.Sender())
+		pe.tracks = append(pe.tracks, track)
+		transceivers = append(transceivers, tr)
+	}
+	if pe.meta, err = pc.CreateDataChannel("meta", nil); err != nil {
+		return fail(err)
+	}
+	pc.OnConnectionStateChange(func(s webrtc.PeerConnectionState) {
+		if s == webrtc.PeerConnectionStateFailed || s == webrtc.PeerConnectionStateClosed {
+			p.Close(id)
+		}
+	})
+
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		return fail(err)
+	}
+	gathered := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(offer); err != nil {
+		return fail(err)
+	}
+	select {
+	case <-gathered:
+	case <-time.After(10 * time.Second):
+		return fail(errors.New("ice gathering timed out"))
+	}
+	for _, tr := range transceivers {
+		pe.mids = append(pe.mids, tr.Mid())
+	}
+	return pe, pc.LocalDescription().SDP, nil
+}
+
+// drainRTCP reads RTCP for a sender, which pion needs for its interceptors.
+func drainRTCP(s *webrtc.RTPSender) {
+	buf := make([]byte, 1500)
+	for {
+		if _, _, err := s.Read(buf); err != nil {
+			return
+		}
+	}
+}
+
+// Answer applies the client's answer to a session's offer.
+func (p *Publisher) Answer(id, sdp string) error {
+	pe, err := p.peer(id)
+	if err != nil {
+		return err
+	}
+	if err := pe.pc.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeAnswer, SDP: sdp}); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAnswer, err)
+	}
+	return nil
+}
+
+// UpdateFilter changes which frames a session receives. The session keeps
+// the IncludeRestricted it was opened with.
+func (p *Publisher) UpdateFilter(id string, filter audio.AudioFilter) error {
+	pe, err := p.peer(id)
+	if err != nil {
+		return err
+	}
+	filter.IncludeRestricted = pe.admin
+	p.src.UpdateAudioFilter(pe.frames, filter)
+	return nil
+}
+
+// Close ends a session.
+func (p *Publisher) Close(id string) error {
+	p.mu.Lock()
+	pe, ok := p.peers[id]
+	if !ok || pe == nil {
+		p.mu.Unlock()
+		return ErrSessionNotFound
+	}
+	delete(p.peers, id)
+	p.mu.Unlock()
+	pe.cancel()
+	return pe.pc.Close()
+}
+
+// Stop ends every session.
+func (p *Publisher) Stop() {
+	p.mu.Lock()
+	ids := make([]string, 0, len(p.peers))
+	for id, pe := range p.peers {
+		if pe != nil {
+			ids = append(ids, id)
+		}
+	}
+	p.mu.Unlock()
+	for _, id := range ids {
+		p.Close(id)
+	}
+}
+
+// Status reports the publisher's peers and settings.
+func (p *Publisher) Status() *Status {
+	p.mu.Lock()
+	n := len(p.peers)
+	p.mu.Unlock()
+	return &Status{
+		Enabled:    true,
+		Peers:      n,
+		MaxPeers:   p.opts.MaxPeers,
+		Lanes:      p.opts.Lanes,
+		ICEServers: p.opts.ICEServers,
+	}
+}
+
+func (p *Publisher) peer(id string) (*peer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pe := p.peers[id]
+	if pe == nil {
+		return nil, ErrSessionNotFound
+	}
+	return pe, nil
+}
+
+// laneMessage announces the talkgroup and unit on a lane.
+type laneMessage struct {
+	Type     string `json:"type"` // "lane"
+	Lane     int    `json:"lane"`
+	Mid      string `json:"mid"`
+	SystemID int    `json:"system_id"`
+	Tgid     int    `json:"tgid"`
+	UnitID   int    `json:"unit_id,omitempty"`
+}
+
+// forward writes a peer's frames to its lanes until the session closes.
+func (p *Publisher) forward(pe *peer) {
+	ls := make(lanes, len(pe.tracks))
+	for f := range pe.frames {
+		if f.Format != audio.AudioFormatPCM {
+			continue
+		}
+		i, changed := ls.assign(f.SystemID, f.TGID, f.UnitID, time.Now())
+		if i < 0 {
+			continue // every lane busy with another talkgroup
+		}
+		if changed && pe.meta.ReadyState() == webrtc.DataChannelStateOpen {
+			msg, _ := json.Marshal(laneMessage{Type: "lane", Lane: i, Mid: pe.mids[i], SystemID: f.SystemID, Tgid: f.TGID, UnitID: f.UnitID})
+			pe.meta.SendText(string(msg))
+		}
+		data := pcmu(f.Data, f.SampleRate)
+		err := pe.tracks[i].WriteSample(media.Sample{Data: data, Duration: time.Duration(len(data)) * time.Second / pcmuRate})
+		if err != nil {
+			p.log.Debug().Err(err).Str("session", pe.id).Msg("write sample failed")
+		}
+	}
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,215 @@
+package rtcaudio
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/audio"
+)
+
+// busSource adapts an AudioBus to FrameSource.
+type busSource struct{ *audio.AudioBus }
+
+func (b busSource) SubscribeAudio(f audio.AudioFilter) (<-chan audio.AudioFrame, func()) {
+	return b.Subscribe(f)
+}
+
+func (b busSource) UpdateAudioFilter(ch <-chan audio.AudioFrame, f audio.AudioFilter) {
+	b.UpdateFilter(ch, f)
+}
+
+func TestULaw(t *testing.T) {
+	tests := []struct {
+		in   int16
+		want byte
+	}{
+		{0, 0xFF},
+		{-1, 0x7F},
+		{32767, 0x80},
+		{-32768, 0x00},
+		{1000, 0xCE},
+	}
+	for _, tt := range tests {
+		if got := ulaw(tt.in); got != tt.want {
+			t.Errorf("ulaw(%d) = %#x, want %#x", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPCMU(t *testing.T) {
+	pcm := func(samples ...int16) []byte {
+		b := make([]byte, 2*len(samples))
+		for i, s := range samples {
+			binary.LittleEndian.PutUint16(b[2*i:], uint16(s))
+		}
+		return b
+	}
+
+	if got := pcmu(pcm(0, 1000, 32767), 8000); len(got) != 3 || got[1] != 0xCE {
+		t.Errorf("8 kHz: got %x", got)
+	}
+	// 16 kHz averages each pair.
+	if got := pcmu(pcm(900, 1100, 0, 0), 16000); len(got) != 2 || got[0] != 0xCE || got[1] != 0xFF {
+		t.Errorf("16 kHz: got %x", got)
+	}
+	// 11025 Hz takes the nearest sample.
+	if got := pcmu(pcm(make([]int16, 11025)...), 11025); len(got) != 8000 {
+		t.Errorf("11025 Hz: got %d samples, want 8000", len(got))
+	}
+}
+
+func TestLanesAssign(t *testing.T) {
+	now := time.Now()
+	ls := make(lanes, 2)
+
+	if i, changed := ls.assign(1, 100, 5, now); i != 0 || !changed {
+		t.Errorf("first talkgroup: lane %d changed %v, want 0 true", i, changed)
+	}
+	if i, changed := ls.assign(1, 100, 5, now); i != 0 || changed {
+		t.Errorf("same unit: lane %d changed %v, want 0 false", i, changed)
+	}
+	if i, changed := ls.assign(1, 100, 6, now); i != 0 || !changed {
+		t.Errorf("new unit: lane %d changed %v, want 0 true", i, changed)
+	}
+	if i, _ := ls.assign(2, 100, 7, now); i != 1 {
+		t.Errorf("same tgid on another system: lane %d, want 1", i)
+	}
+	if i, _ := ls.assign(1, 200, 8, now); i != -1 {
+		t.Errorf("all lanes busy: lane %d, want -1", i)
+	}
+	later := now.Add(laneHold + time.Millisecond)
+	if i, changed := ls.assign(1, 200, 8, later); i != 0 || !changed {
+		t.Errorf("after hold: lane %d changed %v, want 0 true", i, changed)
+	}
+}
+
+func TestPublisherMaxPeers(t *testing.T) {
+	p, err := New(busSource{audio.NewAudioBus()}, Options{MaxPeers: 1, Lanes: 1}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	s, err := p.Open(audio.AudioFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Lanes) != 1 || s.SDP == "" {
+		t.Errorf("session = %+v", s)
+	}
+	if _, err := p.Open(audio.AudioFilter{}); !errors.Is(err, ErrTooManyPeers) {
+		t.Errorf("second Open err = %v, want ErrTooManyPeers", err)
+	}
+	if err := p.Answer("nope", ""); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Answer unknown err = %v, want ErrSessionNotFound", err)
+	}
+	if err := p.Answer(s.ID, "not sdp"); !errors.Is(err, ErrInvalidAnswer) {
+		t.Errorf("Answer bad sdp err = %v, want ErrInvalidAnswer", err)
+	}
+	if err := p.Close(s.ID); err != nil {
+		t.Fatal(err)
+	}
+	if st := p.Status(); st.Peers != 0 {
+		t.Errorf("peers after close = %d, want 0", st.Peers)
+	}
+}
+
+// TestPublisherLoopback connects a pion client and checks that a talkgroup's
+// audio arrives on a lane announced over the data channel.
+func TestPublisherLoopback(t *testing.T) {
+	bus := audio.NewAudioBus()
+	p, err := New(busSource{bus}, Options{Lanes: 2}, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	s, err := p.Open(audio.AudioFilter{TGIDs: []int{9001}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	announced := make(chan laneMessage, 4)
+	client.OnDataChannel(func(dc *webrtc.DataChannel) {
+		dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+			var m laneMessage
+			if json.Unmarshal(msg.Data, &m) == nil {
+				announced <- m
+			}
+		})
+	})
+	received := make(chan string, 4)
+	client.OnTrack(func(tr *webrtc.TrackRemote, rx *webrtc.RTPReceiver) {
+		if _, _, err := tr.ReadRTP(); err == nil {
+			received <- tr.Codec().MimeType
+		}
+	})
+	connected := make(chan struct{})
+	client.OnConnectionStateChange(func(st webrtc.PeerConnectionState) {
+		if st == webrtc.PeerConnectionStateConnected {
+			close(connected)
+		}
+	})
+
+	if err := client.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: s.SDP}); err != nil {
+		t.Fatal(err)
+	}
+	answer, err := client.CreateAnswer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gathered := webrtc.GatheringCompletePromise(client)
+	if err := client.SetLocalDescription(answer); err != nil {
+		t.Fatal(err)
+	}
+	<-gathered
+	if err := p.Answer(s.ID, client.LocalDescription().SDP); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-connected:
+	case <-time.After(10 * time.Second):
+		t.Fatal("peer did not connect")
+	}
+
+	// Publish until the lane is announced and media arrives; the data
+	// channel may open after the first frames.
+	frame := audio.AudioFrame{SystemID: 1, TGID: 9001, UnitID: 42, Format: audio.AudioFormatPCM, SampleRate: 8000, Data: make([]byte, 320)}
+	var lane *laneMessage
+	var mime string
+	deadline := time.After(10 * time.Second)
+	tick := time.NewTicker(20 * time.Millisecond)
+	defer tick.Stop()
+	for lane == nil || mime == "" {
+		select {
+		case m := <-announced:
+			lane = &m
+		case mime = <-received:
+		case <-tick.C:
+			bus.Publish(frame)
+			// Re-announce once the channel opens by moving to a new unit.
+			frame.UnitID++
+		case <-deadline:
+			t.Fatalf("lane %v, media %q", lane, mime)
+		}
+	}
+	if lane.Tgid != 9001 || lane.SystemID != 1 || lane.Lane != 0 || lane.Mid != s.Lanes[0] {
+		t.Errorf("lane message = %+v", *lane)
+	}
+	if mime != webrtc.MimeTypePCMU {
+		t.Errorf("codec = %s, want %s", mime, webrtc.MimeTypePCMU)
+	}
+}
@@ -0,0 +1,88 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// verifySignature checks an Ed25519 signature over the whole archive. The
+// signature file holds the 64-byte signature raw (openssl pkeyutl -sign) or
+// base64-encoded.
+func verifySignature(key ed25519.PublicKey, archive, sig []byte) error {
+	if len(sig) != ed25519.SignatureSize {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+		if err != nil || len(decoded) != ed25519.SignatureSize {
+			return errors.New("malformed signature")
+		}
+		sig = decoded
+	}
+	if !ed25519.Verify(key, archive, sig) {
+		return errors.New("signature verification failed")
+	}
+	return nil
+}
+
+// extractBinary returns the named binary from a release archive (.zip or
+// .tar.gz), found by base name in any directory.
+func extractBinary(archive []byte, asset, name string) ([]byte, error) {
+	if strings.HasSuffix(asset, ".zip") {
+		zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			return nil, fmt.Errorf("open zip: %w", err)
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() || path.Base(f.Name) != name {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			return readBinary(rc)
+		}
+		return nil, fmt.Errorf("archive has no %s", name)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("open gzip: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("archive has no %s", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read tar: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeReg && path.Base(hdr.Name) == name {
+			return readBinary(tr)
+		}
+	}
+}
+
+func readBinary(r io.Reader) ([]byte, error) {
+	bin, err := io.ReadAll(io.LimitReader(r, maxArchiveSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(bin) > maxArchiveSize {
+		return nil, fmt.Errorf("binary larger than %d bytes", maxArchiveSize)
+	}
+	if len(bin) == 0 {
+		return nil, errors.New("binary is empty")
+	}
+	return bin, nil
+}
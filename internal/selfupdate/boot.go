@@ -0,0 +1,132 @@
+package selfupdate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// verifyRetryDelay is the wait between failed health checks in Verify.
+var verifyRetryDelay = 10 * time.Second
+
+// BootAction is what Boot decided for an update in progress.
+type BootAction int
+
+const (
+	BootNormal     BootAction = iota // no update pending
+	BootVerify                       // running a pending update: call Verify
+	BootRolledBack                   // pending update failed: restart into the restored binary
+)
+
+// Boot is called at startup, before anything else touches the database. A
+// pending update gets one start: the first start of the new binary returns
+// BootVerify, and a second start before Verify confirmed it (the new binary
+// crashed, exited or was restarted) rolls back to the previous binary.
+func (u *Updater) Boot() (BootAction, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	st, err := u.State()
+	if err != nil || st == nil || st.Status != StatusPending {
+		return BootNormal, err
+	}
+	if CompareVersions(st.Version, u.version) != 0 {
+		// Not the updated binary (replaced by hand since): leave it be.
+		return BootNormal, nil
+	}
+	if st.Attempts > 0 {
+		if err := u.rollback(st, "update restarted before passing its health check"); err != nil {
+			return BootNormal, err
+		}
+		return BootRolledBack, nil
+	}
+	st.Attempts++
+	if err := u.saveState(st); err != nil {
+		return BootNormal, err
+	}
+	return BootVerify, nil
+}
+
+// Verify waits out the grace period and then checks the new binary's health,
+// retrying a few times. On success the update is confirmed and the previous
+// binary removed; on failure the previous binary is restored, a restart is
+// requested and the health error returned. If ctx ends first, Verify returns
+// without deciding, and the next start rolls back.
+func (u *Updater) Verify(ctx context.Context, grace time.Duration, check func(context.Context) error) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(grace):
+	}
+
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(verifyRetryDelay):
+			}
+		}
+		checkCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err = check(checkCtx)
+		cancel()
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	st, serr := u.State()
+	if serr != nil || st == nil || st.Status != StatusPending {
+		return serr
+	}
+	if err != nil {
+		if rerr := u.rollback(st, "health check failed: "+err.Error()); rerr != nil {
+			return fmt.Errorf("health check failed: %v; rollback failed: %w", err, rerr)
+		}
+		return fmt.Errorf("health check failed: %w", err)
+	}
+
+	now := time.Now().UTC()
+	st.Status = StatusConfirmed
+	st.ConfirmedAt = &now
+	if err := u.saveState(st); err != nil {
+		return err
+	}
+	os.Remove(u.exe + ".old")
+	u.log.Info().Str("version", st.Version).Msg("update confirmed")
+	return nil
+}
+
+// rollback restores <exe>.old, keeping the failed binary as <exe>.failed.
+// Called with u.mu held.
+func (u *Updater) rollback(st *State, reason string) error {
+	if _, err := os.Stat(u.exe + ".old"); err != nil {
+		return fmt.Errorf("previous binary missing: %w", err)
+	}
+	os.Remove(u.exe + ".failed")
+	if err := os.Rename(u.exe, u.exe+".failed"); err != nil {
+		return fmt.Errorf("move failed binary aside: %w", err)
+	}
+	if err := os.Rename(u.exe+".old", u.exe); err != nil {
+		return fmt.Errorf("restore previous binary: %w", err)
+	}
+	st.Status = StatusRolledBack
+	st.Error = reason
+	if err := u.saveState(st); err != nil {
+		return err
+	}
+	u.restart.Store(true)
+	u.log.Error().
+		Str("version", st.Version).
+		Str("restored", st.PreviousVersion).
+		Str("reason", reason).
+		Msg("update rolled back")
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package selfupdate
+
+import (
+	"os"
+	"syscall"
+)
+
+// execSelf replaces the process with the binary at exe, keeping its arguments
+// and environment.
+func execSelf(exe string) error {
+	return syscall.Exec(exe, os.Args, os.Environ())
+}
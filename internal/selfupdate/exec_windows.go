@@ -0,0 +1,11 @@
+//go:build windows
+
+package selfupdate
+
+import "errors"
+
+// execSelf can't replace a process on Windows; the caller exits and the
+// service manager (NSSM, a scheduled task) starts the new binary.
+func execSelf(exe string) error {
+	return errors.New("in-place restart is not supported on Windows")
+}
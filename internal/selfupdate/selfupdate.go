@@ -0,0 +1,408 @@
+// Package selfupdate updates a binary install of tr-engine in place: it
+// downloads the latest release archive for the running platform, verifies its
+// Ed25519 signature, stages the binary next to the running one, swaps it in
+// on request and, if the new binary doesn't come up healthy, swaps the old one
+// back.
+//
+// Files live beside the executable: <exe>.new (staged binary), <exe>.old
+// (previous binary, kept until the update is confirmed) and <exe>.update.json
+// (the update's State).
+package selfupdate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Update statuses recorded in the state file.
+const (
+	StatusStaged     = "staged"      // binary downloaded and verified, not applied
+	StatusPending    = "pending"     // binary swapped in, waiting for its health check
+	StatusConfirmed  = "confirmed"   // new binary passed its health check
+	StatusRolledBack = "rolled_back" // new binary failed, previous binary restored
+)
+
+const (
+	maxArchiveSize   = 256 << 20
+	maxSignatureSize = 4 << 10
+)
+
+var (
+	ErrUpToDate      = errors.New("already up to date")
+	ErrNothingStaged = errors.New("no update staged")
+)
+
+// Options configures an Updater.
+type Options struct {
+	ReleasesURL string // GitHub "latest release" API URL
+	PublicKey   string // base64 Ed25519 public key release archives are signed with
+	Version     string // version of the running binary
+	Executable  string // path of the running binary (default: os.Executable)
+	Client      *http.Client
+	Log         zerolog.Logger
+}
+
+// Updater stages, applies and rolls back binary updates.
+type Updater struct {
+	releasesURL string
+	publicKey   ed25519.PublicKey
+	version     string
+	exe         string
+	client      *http.Client
+	log         zerolog.Logger
+
+	mu      sync.Mutex // serializes changes to the binary and state file
+	restart atomic.Bool
+}
+
+// New creates an Updater. The public key is required: unsigned updates are
+// never installed.
+func New(opts Options) (*Updater, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(opts.PublicKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be a base64-encoded Ed25519 public key")
+	}
+	exe := opts.Executable
+	if exe == "" {
+		if exe, err = os.Executable(); err != nil {
+			return nil, fmt.Errorf("locate executable: %w", err)
+		}
+		if exe, err = filepath.EvalSymlinks(exe); err != nil {
+			return nil, fmt.Errorf("locate executable: %w", err)
+		}
+	}
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	return &Updater{
+		releasesURL: opts.ReleasesURL,
+		publicKey:   ed25519.PublicKey(key),
+		version:     versionPrefix(opts.Version),
+		exe:         exe,
+		client:      client,
+		log:         opts.Log,
+	}, nil
+}
+
+// Version returns the running version.
+func (u *Updater) Version() string { return u.version }
+
+// Platform returns the running OS/architecture, e.g. "linux/arm64".
+func (u *Updater) Platform() string { return runtime.GOOS + "/" + runtime.GOARCH }
+
+// Release is the latest release's asset for the running platform.
+type Release struct {
+	Version      string    `json:"version"`
+	ReleaseURL   string    `json:"release_url,omitempty"`
+	PublishedAt  time.Time `json:"published_at"`
+	Asset        string    `json:"asset"`
+	ArchiveURL   string    `json:"-"`
+	SignatureURL string    `json:"-"`
+}
+
+// Newer reports whether the release is newer than the running version.
+func (u *Updater) Newer(rel *Release) bool {
+	return CompareVersions(rel.Version, u.version) > 0
+}
+
+// AssetName returns the release archive name for a platform, as built by the
+// release workflow.
+func AssetName(goos, goarch string) string {
+	if goos == "windows" {
+		return "tr-engine-" + goos + "-" + goarch + ".zip"
+	}
+	return "tr-engine-" + goos + "-" + goarch + ".tar.gz"
+}
+
+func binaryName() string {
+	if runtime.GOOS == "windows" {
+		return "tr-engine.exe"
+	}
+	return "tr-engine"
+}
+
+// Latest fetches the latest release and picks the running platform's archive
+// and its signature (<archive>.sig).
+func (u *Updater) Latest(ctx context.Context) (*Release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.releasesURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "tr-engine/"+u.version)
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch latest release: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch latest release: HTTP %d", resp.StatusCode)
+	}
+
+	var gh struct {
+		TagName     string    `json:"tag_name"`
+		HTMLURL     string    `json:"html_url"`
+		PublishedAt time.Time `json:"published_at"`
+		Assets      []struct {
+			Name string `json:"name"`
+			URL  string `json:"browser_download_url"`
+		} `json:"assets"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&gh); err != nil {
+		return nil, fmt.Errorf("parse latest release: %w", err)
+	}
+	if gh.TagName == "" {
+		return nil, fmt.Errorf("latest release has no tag")
+	}
+
+	rel := &Release{
+		Version:     gh.TagName,
+		ReleaseURL:  gh.HTMLURL,
+		PublishedAt: gh.PublishedAt,
+		Asset:       AssetName(runtime.GOOS, runtime.GOARCH),
+	}
+	for _, a := range gh.Assets {
+		switch a.Name {
+		case rel.Asset:
+			rel.ArchiveURL = a.URL
+		case rel.Asset + ".sig":
+			rel.SignatureURL = a.URL
+		}
+	}
+	if rel.ArchiveURL == "" {
+		return nil, fmt.Errorf("release %s has no %s asset", rel.Version, rel.Asset)
+	}
+	if rel.SignatureURL == "" {
+		return nil, fmt.Errorf("release %s has no signature for %s", rel.Version, rel.Asset)
+	}
+	return rel, nil
+}
+
+// Stage downloads the latest release if it's newer than the running version,
+// verifies its signature and writes its binary to <exe>.new. It returns
+// ErrUpToDate when there is nothing newer.
+func (u *Updater) Stage(ctx context.Context) (*State, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	rel, err := u.Latest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !u.Newer(rel) {
+		return nil, ErrUpToDate
+	}
+
+	sig, err := u.download(ctx, rel.SignatureURL, maxSignatureSize)
+	if err != nil {
+		return nil, fmt.Errorf("download signature: %w", err)
+	}
+	archive, err := u.download(ctx, rel.ArchiveURL, maxArchiveSize)
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", rel.Asset, err)
+	}
+	if err := verifySignature(u.publicKey, archive, sig); err != nil {
+		return nil, fmt.Errorf("%s: %w", rel.Asset, err)
+	}
+	bin, err := extractBinary(archive, rel.Asset, binaryName())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", rel.Asset, err)
+	}
+
+	tmp := u.exe + ".new.tmp"
+	if err := os.WriteFile(tmp, bin, 0o755); err != nil {
+		return nil, fmt.Errorf("write staged binary: %w", err)
+	}
+	if err := os.Rename(tmp, u.exe+".new"); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("write staged binary: %w", err)
+	}
+
+	st := &State{
+		Status:          StatusStaged,
+		Version:         rel.Version,
+		PreviousVersion: u.version,
+		StagedAt:        time.Now().UTC(),
+	}
+	if err := u.saveState(st); err != nil {
+		return nil, err
+	}
+	u.log.Info().Str("version", rel.Version).Str("asset", rel.Asset).Msg("update staged")
+	return st, nil
+}
+
+func (u *Updater) download(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "tr-engine/"+u.version)
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("larger than %d bytes", limit)
+	}
+	return data, nil
+}
+
+// Apply swaps the staged binary in, keeping the running one as <exe>.old,
+// and marks the update pending its health check. The caller restarts the
+// process (see RestartRequested and Restart).
+func (u *Updater) Apply() (*State, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	st, err := u.State()
+	if err != nil {
+		return nil, err
+	}
+	if st == nil || st.Status != StatusStaged {
+		return nil, ErrNothingStaged
+	}
+	if _, err := os.Stat(u.exe + ".new"); err != nil {
+		return nil, ErrNothingStaged
+	}
+
+	os.Remove(u.exe + ".old")
+	if err := os.Rename(u.exe, u.exe+".old"); err != nil {
+		return nil, fmt.Errorf("move current binary aside: %w", err)
+	}
+	if err := os.Rename(u.exe+".new", u.exe); err != nil {
+		if rerr := os.Rename(u.exe+".old", u.exe); rerr != nil {
+			u.log.Error().Err(rerr).Str("path", u.exe).Msg("failed to restore binary after failed update")
+		}
+		return nil, fmt.Errorf("swap in staged binary: %w", err)
+	}
+
+	now := time.Now().UTC()
+	st.Status = StatusPending
+	st.AppliedAt = &now
+	st.Attempts = 0
+	if err := u.saveState(st); err != nil {
+		return nil, err
+	}
+	u.restart.Store(true)
+	u.log.Warn().Str("version", st.Version).Msg("update applied, restarting")
+	return st, nil
+}
+
+// RestartRequested reports whether the binary was swapped (applied or rolled
+// back) and the process should restart into it.
+func (u *Updater) RestartRequested() bool {
+	return u != nil && u.restart.Load()
+}
+
+// Restart replaces the process with the binary now at the executable's path.
+// Where that isn't possible (Windows) it returns an error and the caller
+// should exit non-zero so the service manager restarts it.
+func (u *Updater) Restart() error {
+	return execSelf(u.exe)
+}
+
+// State is an update's progress, persisted in <exe>.update.json.
+type State struct {
+	Status          string     `json:"status"`
+	Version         string     `json:"version"`
+	PreviousVersion string     `json:"previous_version"`
+	StagedAt        time.Time  `json:"staged_at"`
+	AppliedAt       *time.Time `json:"applied_at,omitempty"`
+	ConfirmedAt     *time.Time `json:"confirmed_at,omitempty"`
+	Attempts        int        `json:"attempts,omitempty"` // starts of the pending binary
+	Error           string     `json:"error,omitempty"`
+}
+
+// State returns the last update's state, or nil if there has been none.
+func (u *Updater) State() (*State, error) {
+	data, err := os.ReadFile(u.exe + ".update.json")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st State
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("parse update state: %w", err)
+	}
+	return &st, nil
+}
+
+func (u *Updater) saveState(st *State) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := u.exe + ".update.json.tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write update state: %w", err)
+	}
+	if err := os.Rename(tmp, u.exe+".update.json"); err != nil {
+		return fmt.Errorf("write update state: %w", err)
+	}
+	return nil
+}
+
+// versionPrefix strips build details, e.g. "v0.8.7.6 (commit=...)" → "v0.8.7.6".
+func versionPrefix(v string) string {
+	if idx := strings.Index(v, " "); idx > 0 {
+		return v[:idx]
+	}
+	return v
+}
+
+// CompareVersions compares two version strings like "v0.8.8.1" or "0.8.8".
+// Returns >0 if a > b, <0 if a < b, 0 if equal.
+// Handles variable-length segments (0.8.8 < 0.8.8.1).
+func CompareVersions(a, b string) int {
+	a = strings.TrimPrefix(a, "v")
+	b = strings.TrimPrefix(b, "v")
+
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+
+	maxLen := len(aParts)
+	if len(bParts) > maxLen {
+		maxLen = len(bParts)
+	}
+
+	for i := 0; i < maxLen; i++ {
+		var aNum, bNum int
+		if i < len(aParts) {
+			aNum, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			bNum, _ = strconv.Atoi(bParts[i])
+		}
+		if aNum != bNum {
+			return aNum - bNum
+		}
+	}
+	return 0
+}
@@ -0,0 +1,272 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testArchive(t *testing.T, asset string, bin []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	dir := strings.TrimSuffix(strings.TrimSuffix(asset, ".zip"), ".tar.gz")
+	if strings.HasSuffix(asset, ".zip") {
+		zw := zip.NewWriter(&buf)
+		f, _ := zw.Create(dir + "/" + binaryName())
+		f.Write(bin)
+		zw.Close()
+		return buf.Bytes()
+	}
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: dir + "/schema.sql", Mode: 0o644, Size: 3, Typeflag: tar.TypeReg})
+	tw.Write([]byte("sql"))
+	tw.WriteHeader(&tar.Header{Name: dir + "/" + binaryName(), Mode: 0o755, Size: int64(len(bin)), Typeflag: tar.TypeReg})
+	tw.Write(bin)
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// testRelease serves a GitHub-style latest release with a signed archive
+// holding bin, and returns an Updater for the running version using it.
+func testRelease(t *testing.T, tag, running string, bin []byte, signWith ed25519.PrivateKey) (*Updater, string) {
+	t.Helper()
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	if signWith == nil {
+		signWith = priv
+	}
+	asset := AssetName(runtime.GOOS, runtime.GOARCH)
+	archive := testArchive(t, asset, bin)
+	sig := ed25519.Sign(signWith, archive)
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	mux.HandleFunc("/latest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"tag_name":%q,"html_url":"https://example.invalid/r","assets":[
+			{"name":"tr-engine-plan9-mips.tar.gz","browser_download_url":"%[2]s/other"},
+			{"name":%q,"browser_download_url":"%[2]s/archive"},
+			{"name":"%[3]s.sig","browser_download_url":"%[2]s/sig"}]}`, tag, srv.URL, asset)
+	})
+	mux.HandleFunc("/archive", func(w http.ResponseWriter, r *http.Request) { w.Write(archive) })
+	mux.HandleFunc("/sig", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(base64.StdEncoding.EncodeToString(sig) + "\n"))
+	})
+
+	exe := filepath.Join(t.TempDir(), binaryName())
+	if err := os.WriteFile(exe, []byte("old binary"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	u, err := New(Options{
+		ReleasesURL: srv.URL + "/latest",
+		PublicKey:   base64.StdEncoding.EncodeToString(pub),
+		Version:     running,
+		Executable:  exe,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u, exe
+}
+
+// started returns an Updater for the binary at u's executable path running
+// the given version, as after a restart.
+func started(t *testing.T, u *Updater, version string) *Updater {
+	t.Helper()
+	next, err := New(Options{ReleasesURL: u.releasesURL, PublicKey: base64.StdEncoding.EncodeToString(u.publicKey),
+		Version: version, Executable: u.exe})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return next
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestStageApplyConfirm(t *testing.T) {
+	u, exe := testRelease(t, "v0.9.1", "0.9.0 (commit=abc)", []byte("new binary"), nil)
+
+	rel, err := u.Latest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel.Version != "v0.9.1" || !u.Newer(rel) {
+		t.Errorf("release = %+v", rel)
+	}
+
+	if _, err := u.Apply(); !errors.Is(err, ErrNothingStaged) {
+		t.Errorf("apply before stage: err = %v", err)
+	}
+	st, err := u.Stage(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Status != StatusStaged || st.Version != "v0.9.1" || st.PreviousVersion != "0.9.0" {
+		t.Errorf("state = %+v", st)
+	}
+	if got := readFile(t, exe+".new"); got != "new binary" {
+		t.Errorf("staged binary = %q", got)
+	}
+
+	if _, err := u.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	if readFile(t, exe) != "new binary" || readFile(t, exe+".old") != "old binary" || !u.RestartRequested() {
+		t.Error("apply didn't swap the binaries")
+	}
+
+	// The new binary starts: verify, then confirm.
+	next := started(t, u, "0.9.1")
+	if action, err := next.Boot(); err != nil || action != BootVerify {
+		t.Fatalf("boot = %v, %v", action, err)
+	}
+	if err := next.Verify(context.Background(), 0, func(context.Context) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	st, _ = next.State()
+	if st.Status != StatusConfirmed || next.RestartRequested() {
+		t.Errorf("state = %+v", st)
+	}
+	if _, err := os.Stat(exe + ".old"); !os.IsNotExist(err) {
+		t.Error("previous binary not removed after confirm")
+	}
+
+	if _, err := next.Stage(context.Background()); !errors.Is(err, ErrUpToDate) {
+		t.Errorf("stage when current: err = %v", err)
+	}
+}
+
+func TestRollbackOnSecondStart(t *testing.T) {
+	u, exe := testRelease(t, "v0.9.1", "0.9.0", []byte("new binary"), nil)
+	if _, err := u.Stage(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	next := started(t, u, "v0.9.1")
+	if action, _ := next.Boot(); action != BootVerify {
+		t.Fatalf("first start = %v, want BootVerify", action)
+	}
+	// Crashed before confirming: the next start rolls back.
+	if action, err := next.Boot(); err != nil || action != BootRolledBack {
+		t.Fatalf("second start = %v, %v", action, err)
+	}
+	if readFile(t, exe) != "old binary" || readFile(t, exe+".failed") != "new binary" || !next.RestartRequested() {
+		t.Error("rollback didn't restore the previous binary")
+	}
+	st, _ := next.State()
+	if st.Status != StatusRolledBack || st.Error == "" {
+		t.Errorf("state = %+v", st)
+	}
+
+	// The restored binary boots normally.
+	if action, _ := started(t, u, "0.9.0").Boot(); action != BootNormal {
+		t.Errorf("restored binary boot = %v", action)
+	}
+}
+
+func TestRollbackOnFailedHealthCheck(t *testing.T) {
+	u, exe := testRelease(t, "v0.9.1", "0.9.0", []byte("new binary"), nil)
+	u.Stage(context.Background())
+	u.Apply()
+	next := started(t, u, "0.9.1")
+	next.Boot()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := next.Verify(ctx, time.Hour, func(context.Context) error { return nil }); err == nil {
+		t.Error("expected Verify to stop with the context")
+	}
+
+	defer func(d time.Duration) { verifyRetryDelay = d }(verifyRetryDelay)
+	verifyRetryDelay = time.Millisecond
+	checks := 0
+	err := next.Verify(context.Background(), 0, func(ctx context.Context) error {
+		checks++
+		return errors.New("database down")
+	})
+	if checks != 3 {
+		t.Errorf("health checked %d times, want 3", checks)
+	}
+	if err == nil || !strings.Contains(err.Error(), "database down") {
+		t.Fatalf("err = %v", err)
+	}
+	if readFile(t, exe) != "old binary" || !next.RestartRequested() {
+		t.Error("failed health check didn't roll back")
+	}
+}
+
+func TestStageRejectsBadSignature(t *testing.T) {
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+	u, exe := testRelease(t, "v0.9.1", "0.9.0", []byte("evil binary"), other)
+	if _, err := u.Stage(context.Background()); err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Fatalf("err = %v", err)
+	}
+	if _, err := os.Stat(exe + ".new"); !os.IsNotExist(err) {
+		t.Error("unverified binary was staged")
+	}
+	if st, _ := u.State(); st != nil {
+		t.Errorf("state = %+v", st)
+	}
+}
+
+func TestExtractBinary(t *testing.T) {
+	for _, asset := range []string{"tr-engine-windows-amd64.zip", "tr-engine-linux-arm64.tar.gz"} {
+		archive := testArchive(t, asset, []byte("bin"))
+		got, err := extractBinary(archive, asset, binaryName())
+		if err != nil || string(got) != "bin" {
+			t.Errorf("%s: got %q, %v", asset, got, err)
+		}
+		if _, err := extractBinary(archive, asset, "missing"); err == nil {
+			t.Errorf("%s: expected missing binary error", asset)
+		}
+	}
+}
+
+func TestNewRequiresKey(t *testing.T) {
+	if _, err := New(Options{PublicKey: "not-a-key", Executable: "/x"}); err == nil {
+		t.Error("expected invalid key error")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v0.9.1", "0.9.0", 1},
+		{"0.8.8", "v0.8.8.1", -1},
+		{"v1.0", "1.0.0", 0},
+	}
+	for _, tt := range tests {
+		got := CompareVersions(tt.a, tt.b)
+		if (got > 0) != (tt.want > 0) || (got < 0) != (tt.want < 0) {
+			t.Errorf("CompareVersions(%q, %q) = %d", tt.a, tt.b, got)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/snarg/tr-engine/internal/config"
+)
+
+// azureAPIVersion is the Blob service REST version requests and SAS tokens use.
+const azureAPIVersion = "2021-08-06"
+
+// AzureStore stores audio files in an Azure Blob Storage container, using
+// the REST API with shared key authorization.
+type AzureStore struct {
+	client        *http.Client
+	account       string
+	key           []byte
+	endpoint      string // no trailing slash
+	container     string
+	prefix        string
+	presignExpiry time.Duration
+	log           zerolog.Logger
+}
+
+// NewAzureStore creates an Azure Blob Storage audio store from config.
+// URL returns SAS links valid for presignExpiry.
+func NewAzureStore(cfg config.AzureConfig, presignExpiry time.Duration, log zerolog.Logger) (*AzureStore, error) {
+	key, err := base64.StdEncoding.DecodeString(cfg.Key)
+	if err != nil {
+		return nil, fmt.Errorf("AZURE_STORAGE_KEY is not valid base64: %w", err)
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://" + cfg.Account + ".blob.core.windows.net"
+	}
+	return &AzureStore{
+		client:        &http.Client{Timeout: 60 * time.Second},
+		account:       cfg.Account,
+		key:           key,
+		endpoint:      strings.TrimSuffix(endpoint, "/"),
+		container:     cfg.Container,
+		prefix:        strings.Trim(cfg.Prefix, "/"),
+		presignExpiry: presignExpiry,
+		log:           log.With().Str("component", "azure-store").Logger(),
+	}, nil
+}
+
+// Ping checks that the container exists and the key is valid.
+func (s *AzureStore) Ping(ctx context.Context) error {
+	resp, err := s.do(ctx, http.MethodHead, s.containerURL(), url.Values{"restype": {"container"}}, nil, "")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *AzureStore) Save(ctx context.Context, key string, data []byte, contentType string) error {
+	resp, err := s.do(ctx, http.MethodPut, s.blobURL(s.objectKey(key)), nil, data, contentType)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *AzureStore) LocalPath(key string) string {
+	return ""
+}
+
+// URL returns a read-only service SAS link for the blob.
+func (s *AzureStore) URL(ctx context.Context, key string) (string, error) {
+	name := s.objectKey(key)
+	expiry := time.Now().UTC().Add(s.presignExpiry).Format(time.RFC3339)
+	// Service SAS string-to-sign (version 2020-12-06 and later)
+	toSign := strings.Join([]string{
+		"r",    // signedPermissions
+		"",     // signedStart
+		expiry, // signedExpiry
+		"/blob/" + s.account + "/" + s.container + "/" + name,
+		"",                 // signedIdentifier
+		"",                 // signedIP
+		"",                 // signedProtocol
+		azureAPIVersion,    // signedVersion
+		"b",                // signedResource
+		"",                 // signedSnapshotTime
+		"",                 // signedEncryptionScope
+		"", "", "", "", "", // rscc, rscd, rsce, rscl, rsct
+	}, "\n")
+	q := url.Values{
+		"sv":  {azureAPIVersion},
+		"sr":  {"b"},
+		"sp":  {"r"},
+		"se":  {expiry},
+		"sig": {s.sign(toSign)},
+	}
+	return s.blobURL(name) + "?" + q.Encode(), nil
+}
+
+func (s *AzureStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, s.blobURL(s.objectKey(key)), nil, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *AzureStore) Exists(ctx context.Context, key string) bool {
+	resp, err := s.do(ctx, http.MethodHead, s.blobURL(s.objectKey(key)), nil, nil, "")
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return true
+}
+
+func (s *AzureStore) Type() string { return "azure" }
+
+func (s *AzureStore) objectKey(key string) string {
+	if s.prefix != "" {
+		return s.prefix + "/audio/" + key
+	}
+	return "audio/" + key
+}
+
+func (s *AzureStore) containerURL() string {
+	return s.endpoint + "/" + url.PathEscape(s.container)
+}
+
+func (s *AzureStore) blobURL(name string) string {
+	segments := strings.Split(name, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return s.containerURL() + "/" + strings.Join(segments, "/")
+}
+
+// do sends a signed request; a PUT uploads body as a block blob. Non-2xx
+// responses are returned as errors.
+func (s *AzureStore) do(ctx context.Context, method, rawURL string, query url.Values, body []byte, contentType string) (*http.Response, error) {
+	if len(query) > 0 {
+		rawURL += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", azureAPIVersion)
+	if method == http.MethodPut {
+		req.Header.Set("x-ms-blob-type", "BlockBlob")
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+	}
+	req.Header.Set("Authorization", "SharedKey "+s.account+":"+s.sign(s.stringToSign(req, len(body))))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("azure %s %s: %s %s", method, req.URL.Path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// stringToSign builds the shared key string-to-sign for a request.
+func (s *AzureStore) stringToSign(req *http.Request, contentLength int) string {
+	length := ""
+	if contentLength > 0 {
+		length = strconv.Itoa(contentLength)
+	}
+	var b strings.Builder
+	for _, v := range []string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		length,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"", // Date (x-ms-date is used instead)
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+	} {
+		b.WriteString(v)
+		b.WriteByte('\n')
+	}
+
+	// Canonicalized headers: x-ms-* lowercased and sorted
+	var names []string
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-ms-") {
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+
+	// Canonicalized resource: /account/path, then sorted query parameters
+	b.WriteString("/" + s.account + req.URL.EscapedPath())
+	query := req.URL.Query()
+	params := make([]string, 0, len(query))
+	for name := range query {
+		params = append(params, name)
+	}
+	sort.Strings(params)
+	for _, name := range params {
+		values := query[name]
+		sort.Strings(values)
+		b.WriteString("\n" + strings.ToLower(name) + ":" + strings.Join(values, ","))
+	}
+	return b.String()
+}
+
+func (s *AzureStore) sign(toSign string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(toSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,16 @@
+package storage
+
+import "testing"
+
+func TestDiskUsage(t *testing.T) {
+	used, total, err := DiskUsage(t.TempDir())
+	if err != nil {
+		t.Fatalf("DiskUsage: %v", err)
+	}
+	if total == 0 || used > total {
+		t.Errorf("DiskUsage = %d used of %d, want 0 <= used <= total > 0", used, total)
+	}
+	if _, _, err := DiskUsage(t.TempDir() + "/missing"); err == nil {
+		t.Error("DiskUsage of a missing path: want error")
+	}
+}
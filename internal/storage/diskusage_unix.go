@@ -0,0 +1,16 @@
+//go:build !windows
+
+package storage
+
+import "syscall"
+
+// DiskUsage returns the size of the filesystem holding path and how much of
+// it is in use, counting space reserved for root as used (as df does).
+func DiskUsage(path string) (used, total uint64, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	total = uint64(st.Blocks) * uint64(st.Bsize)
+	return total - uint64(st.Bavail)*uint64(st.Bsize), total, nil
+}
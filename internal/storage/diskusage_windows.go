@@ -0,0 +1,19 @@
+//go:build windows
+
+package storage
+
+import "golang.org/x/sys/windows"
+
+// DiskUsage returns the size of the volume holding path and how much of it
+// is unavailable to this process.
+func DiskUsage(path string) (used, total uint64, err error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	var avail, totalFree uint64
+	if err := windows.GetDiskFreeSpaceEx(p, &avail, &total, &totalFree); err != nil {
+		return 0, 0, err
+	}
+	return total - avail, total, nil
+}
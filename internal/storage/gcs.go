@@ -0,0 +1,338 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/snarg/tr-engine/internal/config"
+)
+
+const (
+	gcsDefaultEndpoint = "https://storage.googleapis.com"
+	gcsScope           = "https://www.googleapis.com/auth/devstorage.read_write"
+	gcsMetadataToken   = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	gcsMaxSignedExpiry = 7 * 24 * time.Hour // V4 signed URL limit
+)
+
+// GCSStore stores audio files in a Google Cloud Storage bucket, using the
+// JSON API. It authenticates with a service account key, or with the GCE
+// metadata server when no key is configured; an emulator endpoint is used
+// without authentication.
+type GCSStore struct {
+	client        *http.Client
+	endpoint      string // no trailing slash
+	bucket        string
+	prefix        string
+	presignExpiry time.Duration
+	account       *gcsServiceAccount // nil: metadata server or no auth
+	noAuth        bool               // custom endpoint without credentials
+	log           zerolog.Logger
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// gcsServiceAccount is the part of a service account JSON key used here.
+type gcsServiceAccount struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+
+	key *rsa.PrivateKey
+}
+
+// NewGCSStore creates a Google Cloud Storage audio store from config. URL
+// returns V4 signed links valid for presignExpiry when a service account key
+// is configured.
+func NewGCSStore(cfg config.GCSConfig, presignExpiry time.Duration, log zerolog.Logger) (*GCSStore, error) {
+	s := &GCSStore{
+		client:        &http.Client{Timeout: 60 * time.Second},
+		endpoint:      strings.TrimSuffix(cfg.Endpoint, "/"),
+		bucket:        cfg.Bucket,
+		prefix:        strings.Trim(cfg.Prefix, "/"),
+		presignExpiry: min(presignExpiry, gcsMaxSignedExpiry),
+		log:           log.With().Str("component", "gcs-store").Logger(),
+	}
+	if s.endpoint == "" {
+		s.endpoint = gcsDefaultEndpoint
+	}
+	if cfg.CredentialsFile != "" {
+		account, err := loadGCSServiceAccount(cfg.CredentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		s.account = account
+	} else if cfg.Endpoint != "" {
+		s.noAuth = true
+	}
+	return s, nil
+}
+
+func loadGCSServiceAccount(path string) (*gcsServiceAccount, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read GCS_CREDENTIALS_FILE: %w", err)
+	}
+	var a gcsServiceAccount
+	if err := json.Unmarshal(raw, &a); err != nil {
+		return nil, fmt.Errorf("parse GCS_CREDENTIALS_FILE: %w", err)
+	}
+	if a.ClientEmail == "" || a.PrivateKey == "" {
+		return nil, fmt.Errorf("GCS_CREDENTIALS_FILE is not a service account key")
+	}
+	if a.TokenURI == "" {
+		a.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	block, _ := pem.Decode([]byte(a.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("GCS_CREDENTIALS_FILE: invalid private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("GCS_CREDENTIALS_FILE: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("GCS_CREDENTIALS_FILE: private key is not RSA")
+	}
+	a.key = key
+	return &a, nil
+}
+
+// Ping checks that the bucket's objects can be listed with the credentials.
+func (s *GCSStore) Ping(ctx context.Context) error {
+	resp, err := s.do(ctx, http.MethodGet, s.endpoint+"/storage/v1/b/"+url.PathEscape(s.bucket)+"/o?maxResults=1&fields=kind", nil, "")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *GCSStore) Save(ctx context.Context, key string, data []byte, contentType string) error {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	q := url.Values{"uploadType": {"media"}, "name": {s.objectKey(key)}}
+	resp, err := s.do(ctx, http.MethodPost, s.endpoint+"/upload/storage/v1/b/"+url.PathEscape(s.bucket)+"/o?"+q.Encode(), data, contentType)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *GCSStore) LocalPath(key string) string {
+	return ""
+}
+
+// URL returns a V4 signed link for the object, or "" without a service
+// account key to sign it (the API then serves the audio itself).
+func (s *GCSStore) URL(ctx context.Context, key string) (string, error) {
+	if s.account == nil {
+		return "", nil
+	}
+	u, err := url.Parse(s.endpoint)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	datetime := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/auto/storage/goog4_request"
+	path := "/" + s.bucket + "/" + gcsEscapePath(s.objectKey(key))
+
+	q := url.Values{
+		"X-Goog-Algorithm":     {"GOOG4-RSA-SHA256"},
+		"X-Goog-Credential":    {s.account.ClientEmail + "/" + scope},
+		"X-Goog-Date":          {datetime},
+		"X-Goog-Expires":       {strconv.Itoa(int(s.presignExpiry.Seconds()))},
+		"X-Goog-SignedHeaders": {"host"},
+	}
+	// url.Values.Encode sorts by key; GCS wants %20 rather than +
+	query := strings.ReplaceAll(q.Encode(), "+", "%20")
+	canonical := strings.Join([]string{
+		http.MethodGet,
+		path,
+		query,
+		"host:" + u.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "GOOG4-RSA-SHA256\n" + datetime + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	digest := sha256.Sum256([]byte(toSign))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.account.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return u.Scheme + "://" + u.Host + path + "?" + query + "&X-Goog-Signature=" + hex.EncodeToString(sig), nil
+}
+
+func (s *GCSStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, s.objectURL(key)+"?alt=media", nil, "")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *GCSStore) Exists(ctx context.Context, key string) bool {
+	resp, err := s.do(ctx, http.MethodGet, s.objectURL(key)+"?fields=name", nil, "")
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return true
+}
+
+func (s *GCSStore) Type() string { return "gcs" }
+
+func (s *GCSStore) objectKey(key string) string {
+	if s.prefix != "" {
+		return s.prefix + "/audio/" + key
+	}
+	return "audio/" + key
+}
+
+// objectURL is the JSON API URL of an object; the name is one path segment.
+func (s *GCSStore) objectURL(key string) string {
+	return s.endpoint + "/storage/v1/b/" + url.PathEscape(s.bucket) + "/o/" + url.PathEscape(s.objectKey(key))
+}
+
+// gcsEscapePath percent-encodes an object name for an XML API path, keeping
+// its slashes.
+func gcsEscapePath(name string) string {
+	segments := strings.Split(name, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+// do sends an authorized request. Non-2xx responses are returned as errors.
+func (s *GCSStore) do(ctx context.Context, method, rawURL string, body []byte, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if !s.noAuth {
+		token, err := s.accessToken(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("gcs auth: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("gcs %s %s: %s %s", method, req.URL.Path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// accessToken returns a cached OAuth token, refreshing it a minute before
+// it expires.
+func (s *GCSStore) accessToken(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Now().Before(s.tokenExpiry.Add(-time.Minute)) {
+		return s.token, nil
+	}
+
+	var req *http.Request
+	var err error
+	if s.account != nil {
+		assertion, signErr := s.account.jwt(time.Now())
+		if signErr != nil {
+			return "", signErr
+		}
+		form := url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {assertion},
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, s.account.TokenURI, strings.NewReader(form.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, gcsMetadataToken, nil)
+		if err == nil {
+			req.Header.Set("Metadata-Flavor", "Google")
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("token request: %s %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", fmt.Errorf("decode token: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return "", fmt.Errorf("token response has no access_token")
+	}
+	s.token = tok.AccessToken
+	s.tokenExpiry = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	return s.token, nil
+}
+
+// jwt builds the signed assertion exchanged for an access token.
+func (a *gcsServiceAccount) jwt(now time.Time) (string, error) {
+	enc := base64.RawURLEncoding
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": a.PrivateKeyID})
+	claims, _ := json.Marshal(map[string]any{
+		"iss":   a.ClientEmail,
+		"scope": gcsScope,
+		"aud":   a.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
@@ -104,3 +104,15 @@ func (s *LocalStore) Type() string { return "local" }
 
 // Dir returns the audio directory path.
 func (s *LocalStore) Dir() string { return s.audioDir }
+
+// check returns an error if the audio directory is missing or not a directory.
+func (s *LocalStore) check() error {
+	info, err := os.Stat(s.audioDir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", s.audioDir)
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,27 +15,28 @@ import (
 )
 
 // CachePruner evicts old files from the local NVMe cache.
-// S3 retains everything permanently — the pruner only touches local disk.
-// Before deleting, it verifies the file exists in S3 to prevent data loss.
+// The object store retains everything permanently — the pruner only touches
+// local disk. Before deleting, it verifies the file exists in the object store
+// to prevent data loss.
 type CachePruner struct {
 	cacheDir  string
 	retention time.Duration
 	maxBytes  int64
 	interval  time.Duration
-	s3        *S3Store
+	remote    ObjectStore
 	log       zerolog.Logger
 	stop      chan struct{}
 	stopOnce  sync.Once
 }
 
 // NewCachePruner creates a cache pruner that evicts files by age and/or size.
-func NewCachePruner(cacheDir string, retention time.Duration, maxGB int, s3 *S3Store, log zerolog.Logger) *CachePruner {
+func NewCachePruner(cacheDir string, retention time.Duration, maxGB int, remote ObjectStore, log zerolog.Logger) *CachePruner {
 	return &CachePruner{
 		cacheDir:  cacheDir,
 		retention: retention,
 		maxBytes:  int64(maxGB) * 1024 * 1024 * 1024,
 		interval:  1 * time.Hour,
-		s3:        s3,
+		remote:    remote,
 		log:       log.With().Str("component", "cache-pruner").Logger(),
 		stop:      make(chan struct{}),
 	}
@@ -73,13 +75,14 @@ func (p *CachePruner) prune() {
 	var totalSize int64
 	var prunedCount int
 	var prunedBytes int64
-	var skippedNotInS3 int
+	var skippedNotRemote int
 
 	type fileEntry struct {
 		path    string
 		key     string
 		modTime time.Time
 		size    int64
+		derived bool // rendered from audio (e.g. .spectrograms/); never uploaded
 	}
 	var files []fileEntry
 
@@ -100,6 +103,7 @@ func (p *CachePruner) prune() {
 			key:     filepath.ToSlash(rel),
 			modTime: info.ModTime(),
 			size:    info.Size(),
+			derived: strings.HasPrefix(rel, "."),
 		})
 		totalSize += info.Size()
 		return nil
@@ -120,13 +124,13 @@ func (p *CachePruner) prune() {
 		}
 
 		if shouldPrune {
-			if p.s3 != nil {
+			if p.remote != nil && !f.derived {
 				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-				inS3 := p.s3.Exists(ctx, f.key)
+				inRemote := p.remote.Exists(ctx, f.key)
 				cancel()
-				if !inS3 {
-					skippedNotInS3++
-					p.log.Warn().Str("key", f.key).Msg("skipping prune: file not in S3")
+				if !inRemote {
+					skippedNotRemote++
+					p.log.Warn().Str("key", f.key).Msg("skipping prune: file not in object store")
 					continue
 				}
 			}
@@ -140,12 +144,12 @@ func (p *CachePruner) prune() {
 
 	p.removeEmptyDirs()
 
-	if prunedCount > 0 || skippedNotInS3 > 0 {
+	if prunedCount > 0 || skippedNotRemote > 0 {
 		p.log.Info().
 			Int("pruned", prunedCount).
 			Str("freed", humanizeBytes(prunedBytes)).
 			Str("remaining", humanizeBytes(totalSize)).
-			Int("skipped_not_in_s3", skippedNotInS3).
+			Int("skipped_not_uploaded", skippedNotRemote).
 			Msg("cache prune complete")
 	}
 }
@@ -11,11 +11,12 @@ import (
 	"github.com/rs/zerolog"
 )
 
-// UploadReconciler scans the local cache for files missing from S3 and
+// UploadReconciler scans the local cache for files missing from the object
+// store and
 // re-uploads them. Handles failed/dropped async uploads and crash recovery.
 type UploadReconciler struct {
 	cacheDir string
-	s3       *S3Store
+	remote   ObjectStore
 	interval time.Duration
 	window   time.Duration
 	log      zerolog.Logger
@@ -23,11 +24,11 @@ type UploadReconciler struct {
 	stopOnce sync.Once
 }
 
-// NewUploadReconciler creates a reconciler that checks for missing S3 uploads.
-func NewUploadReconciler(cacheDir string, s3 *S3Store, log zerolog.Logger) *UploadReconciler {
+// NewUploadReconciler creates a reconciler that checks for missing object store uploads.
+func NewUploadReconciler(cacheDir string, remote ObjectStore, log zerolog.Logger) *UploadReconciler {
 	return &UploadReconciler{
 		cacheDir: cacheDir,
-		s3:       s3,
+		remote:   remote,
 		interval: 5 * time.Minute,
 		window:   24 * time.Hour,
 		log:      log.With().Str("component", "upload-reconciler").Logger(),
@@ -95,7 +96,7 @@ func (r *UploadReconciler) reconcile() {
 				)
 
 				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-				exists := r.s3.Exists(ctx, key)
+				exists := r.remote.Exists(ctx, key)
 				cancel()
 				if exists {
 					continue
@@ -106,9 +107,9 @@ func (r *UploadReconciler) reconcile() {
 					continue
 				}
 
-				ct := audioContentTypeFromExt(filepath.Ext(f.Name()))
+				ct := ContentTypeFromExt(filepath.Ext(f.Name()))
 				ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
-				if saveErr := r.s3.Save(ctx, key, data, ct); saveErr != nil {
+				if saveErr := r.remote.Save(ctx, key, data, ct); saveErr != nil {
 					r.log.Warn().Err(saveErr).Str("key", key).Msg("reconcile upload failed")
 					failed++
 				} else {
@@ -128,8 +129,8 @@ func (r *UploadReconciler) reconcile() {
 	}
 }
 
-// audioContentTypeFromExt returns the MIME type for an audio file extension.
-func audioContentTypeFromExt(ext string) string {
+// ContentTypeFromExt returns the MIME type for an audio file extension.
+func ContentTypeFromExt(ext string) string {
 	switch strings.ToLower(ext) {
 	case ".m4a":
 		return "audio/mp4"
@@ -137,7 +138,7 @@ func audioContentTypeFromExt(ext string) string {
 		return "audio/mpeg"
 	case ".wav":
 		return "audio/wav"
-	case ".ogg":
+	case ".ogg", ".opus":
 		return "audio/ogg"
 	default:
 		return "application/octet-stream"
@@ -68,8 +68,16 @@ func (s *S3Store) HeadBucket(ctx context.Context) error {
 	return err
 }
 
+// Ping checks bucket access (ObjectStore).
+func (s *S3Store) Ping(ctx context.Context) error { return s.HeadBucket(ctx) }
+
 func (s *S3Store) Save(ctx context.Context, key string, data []byte, contentType string) error {
-	objKey := s.objectKey(key)
+	return s.PutObject(ctx, s.objectKey(key), data, contentType)
+}
+
+// PutObject writes data at objKey, which is used as-is (no S3_PREFIX or
+// audio/ prefix). Used for non-audio objects such as warehouse exports.
+func (s *S3Store) PutObject(ctx context.Context, objKey string, data []byte, contentType string) error {
 	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
 		Bucket:      &s.bucket,
 		Key:         &objKey,
@@ -29,50 +29,74 @@ type AudioStore interface {
 	// Exists checks if an audio file exists in any backend.
 	Exists(ctx context.Context, key string) bool
 
-	// Type returns "local", "s3", or "tiered".
+	// Type returns "local", "s3", "azure", "gcs", or "tiered".
 	Type() string
 }
 
-// New creates an AudioStore based on config. Returns the store and optional
-// background services (pruner, reconciler) that the caller must Start/Stop.
-// Returns an error if S3 is configured but unreachable.
-func New(cfg config.S3Config, audioDir string, log zerolog.Logger) (AudioStore, []BackgroundService, error) {
-	if !cfg.Enabled() {
-		return NewLocalStore(audioDir), nil, nil
-	}
+// ObjectStore is a remote object storage backend: S3, Azure Blob Storage or
+// Google Cloud Storage. Tiered mode pairs one with the local disk cache.
+type ObjectStore interface {
+	AudioStore
+
+	// Ping checks that the bucket (or container) is reachable with the
+	// configured credentials.
+	Ping(ctx context.Context) error
+}
 
-	s3store, err := NewS3Store(cfg, log)
+// New creates an AudioStore based on config (STORAGE_BACKEND). Returns the
+// store and optional background services (pruner, reconciler) that the
+// caller must Start/Stop. Returns an error if an object store is configured
+// but unreachable.
+func New(cfg *config.Config, log zerolog.Logger) (AudioStore, []BackgroundService, error) {
+	var (
+		remote ObjectStore
+		err    error
+		target string
+	)
+	backend := cfg.StorageBackendName()
+	switch backend {
+	case "s3":
+		remote, err = NewS3Store(cfg.S3, log)
+		target = fmt.Sprintf("bucket=%q endpoint=%q", cfg.S3.Bucket, cfg.S3.Endpoint)
+	case "azure":
+		remote, err = NewAzureStore(cfg.Azure, cfg.S3.PresignExpiry, log)
+		target = fmt.Sprintf("account=%q container=%q", cfg.Azure.Account, cfg.Azure.Container)
+	case "gcs":
+		remote, err = NewGCSStore(cfg.GCS, cfg.S3.PresignExpiry, log)
+		target = fmt.Sprintf("bucket=%q", cfg.GCS.Bucket)
+	default:
+		return NewLocalStore(cfg.AudioDir), nil, nil
+	}
 	if err != nil {
-		return nil, nil, fmt.Errorf("S3 init failed: %w", err)
+		return nil, nil, fmt.Errorf("%s init failed: %w", backend, err)
 	}
 
 	// Startup validation: verify credentials and bucket access
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	if err := s3store.HeadBucket(ctx); err != nil {
-		return nil, nil, fmt.Errorf("S3 startup check failed (bucket=%q endpoint=%q): %w",
-			cfg.Bucket, cfg.Endpoint, err)
+	if err := remote.Ping(ctx); err != nil {
+		return nil, nil, fmt.Errorf("%s startup check failed (%s): %w", backend, target, err)
 	}
-	log.Info().Str("bucket", cfg.Bucket).Str("endpoint", cfg.Endpoint).Msg("S3 connection verified")
+	log.Info().Str("backend", backend).Msg("object store connection verified")
 
-	if !cfg.LocalCache {
-		return s3store, nil, nil
+	if !cfg.S3.LocalCache {
+		return remote, nil, nil
 	}
 
-	// Tiered mode: local primary + S3 backup
-	local := NewLocalStore(audioDir)
-	tiered := NewTieredStore(s3store, local, log)
+	// Tiered mode: local primary + object store backup
+	local := NewLocalStore(cfg.AudioDir)
+	tiered := NewTieredStore(remote, local, log)
 
 	var services []BackgroundService
 
 	// Cache pruner
-	if cfg.CacheRetention > 0 || cfg.CacheMaxGB > 0 {
-		pruner := NewCachePruner(audioDir, cfg.CacheRetention, cfg.CacheMaxGB, s3store, log)
+	if cfg.S3.CacheRetention > 0 || cfg.S3.CacheMaxGB > 0 {
+		pruner := NewCachePruner(cfg.AudioDir, cfg.S3.CacheRetention, cfg.S3.CacheMaxGB, remote, log)
 		services = append(services, pruner)
 	}
 
 	// Upload reconciler
-	reconciler := NewUploadReconciler(audioDir, s3store, log)
+	reconciler := NewUploadReconciler(cfg.AudioDir, remote, log)
 	services = append(services, reconciler)
 
 	return tiered, services, nil
@@ -83,3 +107,31 @@ type BackgroundService interface {
 	Start()
 	Stop()
 }
+
+// Backend returns the backend holding a store's durable copy: the object
+// store's type for a tiered store, otherwise the store's own type.
+func Backend(store AudioStore) string {
+	if t, ok := store.(*TieredStore); ok {
+		return t.remote.Type()
+	}
+	return store.Type()
+}
+
+// Check reports whether a store's backends are reachable: the local audio
+// directory exists, and the object store answers a Ping.
+func Check(ctx context.Context, store AudioStore) error {
+	switch s := store.(type) {
+	case *LocalStore:
+		return s.check()
+	case *TieredStore:
+		if err := s.local.check(); err != nil {
+			return err
+		}
+		if err := s.remote.Ping(ctx); err != nil {
+			return fmt.Errorf("%s backup: %w", s.remote.Type(), err)
+		}
+	case ObjectStore:
+		return s.Ping(ctx)
+	}
+	return nil
+}
@@ -2,72 +2,265 @@ package storage
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog"
+	"github.com/snarg/tr-engine/internal/database"
 )
 
-// AsyncUploader handles background S3 uploads without blocking the ingest pipeline.
-// Files are already cached locally before being enqueued here.
+// Upload priorities. Higher is uploaded first; within a priority, newer
+// calls go first.
+const (
+	UploadPriorityNormal    = 0
+	UploadPriorityEmergency = 1
+)
+
+const (
+	uploadTimeout    = 30 * time.Second
+	uploadLease      = 2 * time.Minute // longer than uploadTimeout plus the local read
+	uploadPoll       = 10 * time.Second
+	uploadMaxBackoff = time.Hour
+)
+
+// UploaderOptions configures an AsyncUploader.
+type UploaderOptions struct {
+	Workers     int // concurrent uploads (default 2)
+	MaxAttempts int // failed attempts before an upload gives up (default 10)
+}
+
+// uploadQueue is the s3_upload_queue table (*database.DB).
+type uploadQueue interface {
+	EnqueueS3Upload(ctx context.Context, key, contentType string, priority int, callTime time.Time) error
+	ClaimS3Upload(ctx context.Context, lease time.Duration) (*database.S3Upload, error)
+	CompleteS3Upload(ctx context.Context, key string) error
+	FailS3Upload(ctx context.Context, key, msg string, retryAfter time.Duration, giveUp bool) error
+	ReleaseS3UploadClaims(ctx context.Context) (int64, error)
+	GetS3UploadStats(ctx context.Context) (database.S3UploadStats, error)
+}
+
+// AsyncUploader handles background object store uploads without blocking the ingest pipeline.
+// Files are already cached locally before being enqueued here. The queue is
+// the s3_upload_queue table, so uploads pending at shutdown resume on the
+// next start; the file itself is read back from the local cache.
 type AsyncUploader struct {
-	s3       *S3Store
-	ch       chan uploadJob
-	log      zerolog.Logger
-	stopped  atomic.Bool
+	remote ObjectStore
+	local  *LocalStore
+	db     uploadQueue
+	opts   UploaderOptions
+	log    zerolog.Logger
+
+	wake     chan struct{}
+	stop     chan struct{}
 	stopOnce sync.Once
+	stopped  atomic.Bool
+	wg       sync.WaitGroup
+
+	inFlight atomic.Int64
+	uploaded atomic.Int64
+	retried  atomic.Int64
+	failed   atomic.Int64
 }
 
-type uploadJob struct {
-	key         string
-	data        []byte
-	contentType string
+// UploaderStatus reports the upload queue and progress since startup.
+type UploaderStatus struct {
+	Workers     int   `json:"workers"`
+	MaxAttempts int   `json:"max_attempts"`
+	InFlight    int64 `json:"in_flight"`
+	database.S3UploadStats
+	Uploaded int64 `json:"uploaded"` // since startup
+	Retried  int64 `json:"retried"`  // failed attempts that will be retried, since startup
+	GaveUp   int64 `json:"gave_up"`  // uploads that gave up, since startup
 }
 
-// NewAsyncUploader creates an async S3 uploader with the given buffer size.
-func NewAsyncUploader(s3 *S3Store, bufferSize int, log zerolog.Logger) *AsyncUploader {
+// NewAsyncUploader creates an async object store uploader backed by the upload queue table.
+func NewAsyncUploader(tiered *TieredStore, db *database.DB, opts UploaderOptions, log zerolog.Logger) *AsyncUploader {
+	if opts.Workers <= 0 {
+		opts.Workers = 2
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 10
+	}
 	return &AsyncUploader{
-		s3:  s3,
-		ch:  make(chan uploadJob, bufferSize),
-		log: log.With().Str("component", "async-uploader").Logger(),
+		remote: tiered.remote,
+		local:  tiered.local,
+		db:     db,
+		opts:   opts,
+		log:    log.With().Str("component", "async-uploader").Logger(),
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
 	}
 }
 
-// Enqueue adds an S3 upload job. Non-blocking — drops with warning if full or stopped.
-// Safe because the file is already in the local NVMe cache.
-func (u *AsyncUploader) Enqueue(key string, data []byte, contentType string) {
+// Enqueue queues an upload of a file already in the local cache.
+// Emergency calls are uploaded ahead of the rest. If the queue can't be
+// written the file stays safe in the cache for the upload reconciler.
+func (u *AsyncUploader) Enqueue(ctx context.Context, key, contentType string, callTime time.Time, emergency bool) {
 	if u.stopped.Load() {
 		return
 	}
-	job := uploadJob{key: key, data: data, contentType: contentType}
+	priority := UploadPriorityNormal
+	if emergency {
+		priority = UploadPriorityEmergency
+	}
+	if err := u.db.EnqueueS3Upload(ctx, key, contentType, priority, callTime); err != nil {
+		u.log.Warn().Err(err).Str("key", key).Msg("failed to queue async upload, skipping (file safe in cache)")
+		return
+	}
+	u.Wake()
+}
+
+// Wake tells an idle worker to check the queue now.
+func (u *AsyncUploader) Wake() {
 	select {
-	case u.ch <- job:
+	case u.wake <- struct{}{}:
 	default:
-		u.log.Warn().Str("key", key).Msg("async upload queue full, skipping (file safe in cache)")
 	}
 }
 
-// Start launches worker goroutines.
-func (u *AsyncUploader) Start(workers int) {
-	for i := 0; i < workers; i++ {
+// Start releases uploads claimed before a restart and launches the workers.
+func (u *AsyncUploader) Start() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if n, err := u.db.ReleaseS3UploadClaims(ctx); err != nil {
+		u.log.Warn().Err(err).Msg("failed to release upload claims")
+	} else if n > 0 {
+		u.log.Info().Int64("uploads", n).Msg("resuming interrupted uploads")
+	}
+	if stats, err := u.db.GetS3UploadStats(ctx); err == nil && (stats.Pending > 0 || stats.Failed > 0) {
+		u.log.Info().Int64("pending", stats.Pending).Int64("failed", stats.Failed).Msg("upload queue backlog")
+	}
+	cancel()
+
+	for i := 0; i < u.opts.Workers; i++ {
+		u.wg.Add(1)
 		go u.worker()
 	}
-	u.log.Info().Int("workers", workers).Int("buffer", cap(u.ch)).Msg("async uploader started")
+	u.log.Info().Int("workers", u.opts.Workers).Int("max_attempts", u.opts.MaxAttempts).Msg("async uploader started")
 }
 
-// Stop signals workers to drain. Call after closing the ingest pipeline.
+// Stop waits for in-flight uploads to finish. Anything still queued is
+// uploaded after the next start. Call after closing the ingest pipeline.
 func (u *AsyncUploader) Stop() {
 	u.stopped.Store(true)
-	u.stopOnce.Do(func() { close(u.ch) })
+	u.stopOnce.Do(func() { close(u.stop) })
+	u.wg.Wait()
+}
+
+// Status reports the queue and counts since startup.
+func (u *AsyncUploader) Status(ctx context.Context) (*UploaderStatus, error) {
+	stats, err := u.db.GetS3UploadStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &UploaderStatus{
+		Workers:       u.opts.Workers,
+		MaxAttempts:   u.opts.MaxAttempts,
+		InFlight:      u.inFlight.Load(),
+		S3UploadStats: stats,
+		Uploaded:      u.uploaded.Load(),
+		Retried:       u.retried.Load(),
+		GaveUp:        u.failed.Load(),
+	}, nil
 }
 
 func (u *AsyncUploader) worker() {
-	for job := range u.ch {
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		if err := u.s3.Save(ctx, job.key, job.data, job.contentType); err != nil {
-			u.log.Error().Err(err).Str("key", job.key).Msg("async S3 upload failed (file safe in cache)")
+	defer u.wg.Done()
+	for {
+		select {
+		case <-u.stop:
+			return
+		default:
 		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		job, err := u.db.ClaimS3Upload(ctx, uploadLease)
 		cancel()
+		if err != nil {
+			u.log.Warn().Err(err).Msg("failed to claim upload")
+		}
+		if job == nil {
+			select {
+			case <-u.stop:
+				return
+			case <-u.wake:
+			case <-time.After(uploadPoll):
+			}
+			continue
+		}
+
+		u.inFlight.Add(1)
+		u.upload(job)
+		u.inFlight.Add(-1)
+	}
+}
+
+func (u *AsyncUploader) upload(job *database.S3Upload) {
+	ctx, cancel := context.WithTimeout(context.Background(), uploadTimeout)
+	defer cancel()
+
+	data, err := u.readLocal(ctx, job.Key)
+	if err != nil {
+		// Nothing left to upload from. The cache pruner only evicts files
+		// already in the object store, so check before giving up.
+		if u.remote.Exists(ctx, job.Key) {
+			u.complete(ctx, job.Key)
+			return
+		}
+		u.fail(ctx, job, fmt.Errorf("local copy missing: %w", err), true)
+		return
+	}
+	if err := u.remote.Save(ctx, job.Key, data, job.ContentType); err != nil {
+		u.fail(ctx, job, err, job.Attempts+1 >= u.opts.MaxAttempts)
+		return
+	}
+	u.complete(ctx, job.Key)
+}
+
+func (u *AsyncUploader) readLocal(ctx context.Context, key string) ([]byte, error) {
+	r, err := u.local.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func (u *AsyncUploader) complete(ctx context.Context, key string) {
+	u.uploaded.Add(1)
+	// As in fail: a slow upload may have used up the upload context.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	if err := u.db.CompleteS3Upload(ctx, key); err != nil {
+		u.log.Warn().Err(err).Str("key", key).Msg("failed to remove uploaded file from queue")
+	}
+}
+
+func (u *AsyncUploader) fail(ctx context.Context, job *database.S3Upload, uploadErr error, giveUp bool) {
+	backoff := uploadBackoff(job.Attempts)
+	if giveUp {
+		u.failed.Add(1)
+		u.log.Error().Err(uploadErr).Str("key", job.Key).Int("attempts", job.Attempts+1).Msg("async upload gave up (file safe in cache)")
+	} else {
+		u.retried.Add(1)
+		u.log.Warn().Err(uploadErr).Str("key", job.Key).Dur("retry_in", backoff).Msg("async upload failed, will retry")
+	}
+	// The upload context may have expired; recording the failure must not.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	if err := u.db.FailS3Upload(ctx, job.Key, uploadErr.Error(), backoff, giveUp); err != nil {
+		u.log.Warn().Err(err).Str("key", job.Key).Msg("failed to record upload failure")
+	}
+}
+
+// uploadBackoff is the wait before retrying an upload that has failed
+// attempts times before: 30s doubling up to an hour.
+func uploadBackoff(attempts int) time.Duration {
+	d := 30 * time.Second
+	for i := 0; i < attempts && d < uploadMaxBackoff; i++ {
+		d *= 2
 	}
+	return min(d, uploadMaxBackoff)
 }
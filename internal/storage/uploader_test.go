@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// fakeUploadQueue records what the uploader reports back to the queue.
+type fakeUploadQueue struct {
+	uploadQueue // unused methods panic
+
+	completed []string
+	failed    []fakeUploadFailure
+}
+
+type fakeUploadFailure struct {
+	key        string
+	retryAfter time.Duration
+	giveUp     bool
+}
+
+func (q *fakeUploadQueue) CompleteS3Upload(ctx context.Context, key string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	q.completed = append(q.completed, key)
+	return nil
+}
+
+func (q *fakeUploadQueue) FailS3Upload(_ context.Context, key, _ string, retryAfter time.Duration, giveUp bool) error {
+	q.failed = append(q.failed, fakeUploadFailure{key, retryAfter, giveUp})
+	return nil
+}
+
+// fakeRemote is an object store whose saves fail with err.
+type fakeRemote struct {
+	ObjectStore // unused methods panic
+	err         error
+}
+
+func (r *fakeRemote) Save(context.Context, string, []byte, string) error { return r.err }
+func (r *fakeRemote) Exists(context.Context, string) bool                { return false }
+
+func newTestUploader(t *testing.T, saveErr error) (*AsyncUploader, *fakeUploadQueue) {
+	t.Helper()
+	local := NewLocalStore(t.TempDir())
+	if err := local.Save(context.Background(), "sys/2026-10-16/call.m4a", []byte("audio"), "audio/mp4"); err != nil {
+		t.Fatal(err)
+	}
+	q := &fakeUploadQueue{}
+	return &AsyncUploader{
+		remote: &fakeRemote{err: saveErr},
+		local:  local,
+		db:     q,
+		opts:   UploaderOptions{Workers: 1, MaxAttempts: 3},
+		log:    zerolog.Nop(),
+	}, q
+}
+
+func TestUploadBackoff(t *testing.T) {
+	for _, tt := range []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{6, 32 * time.Minute},
+		{7, time.Hour},
+		{100, time.Hour},
+	} {
+		if got := uploadBackoff(tt.attempts); got != tt.want {
+			t.Errorf("uploadBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestUploadGivesUpAfterMaxAttempts(t *testing.T) {
+	u, q := newTestUploader(t, errors.New("bucket unreachable"))
+	for attempts := 0; attempts < 3; attempts++ {
+		u.upload(&database.S3Upload{Key: "sys/2026-10-16/call.m4a", Attempts: attempts})
+	}
+	want := []fakeUploadFailure{
+		{"sys/2026-10-16/call.m4a", 30 * time.Second, false},
+		{"sys/2026-10-16/call.m4a", time.Minute, false},
+		{"sys/2026-10-16/call.m4a", 2 * time.Minute, true},
+	}
+	if len(q.failed) != len(want) {
+		t.Fatalf("failures = %+v, want %+v", q.failed, want)
+	}
+	for i := range want {
+		if q.failed[i] != want[i] {
+			t.Errorf("failure %d = %+v, want %+v", i, q.failed[i], want[i])
+		}
+	}
+	if u.retried.Load() != 2 || u.failed.Load() != 1 || len(q.completed) != 0 {
+		t.Errorf("retried %d, gave up %d, completed %v; want 2, 1, none", u.retried.Load(), u.failed.Load(), q.completed)
+	}
+}
+
+func TestUploadLocalCopyMissing(t *testing.T) {
+	u, q := newTestUploader(t, nil)
+	u.upload(&database.S3Upload{Key: "sys/2026-10-16/missing.m4a"})
+	if len(q.failed) != 1 || !q.failed[0].giveUp {
+		t.Errorf("failures = %+v, want one give-up on the first attempt", q.failed)
+	}
+}
+
+func TestUploadCompletes(t *testing.T) {
+	u, q := newTestUploader(t, nil)
+	u.upload(&database.S3Upload{Key: "sys/2026-10-16/call.m4a"})
+	if len(q.completed) != 1 || len(q.failed) != 0 || u.uploaded.Load() != 1 {
+		t.Errorf("completed %v, failed %+v, uploaded %d", q.completed, q.failed, u.uploaded.Load())
+	}
+
+	// Completion is recorded even if the upload used up its context.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	u.complete(ctx, "sys/2026-10-16/late.m4a")
+	if len(q.completed) != 2 || q.completed[1] != "sys/2026-10-16/late.m4a" {
+		t.Errorf("completion after the upload context ended was not recorded: %v", q.completed)
+	}
+}
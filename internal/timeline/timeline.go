@@ -0,0 +1,362 @@
+// Package timeline builds after-action review packages from a set of calls:
+// the recordings stitched in chronological order into one WAV, and a
+// transcript synchronized to it that gives the speaking unit and absolute
+// time of every line.
+//
+// Calls are placed back to back with a short silence between them rather
+// than at their real-time spacing, so a quiet hour doesn't become an hour of
+// silence. Each cue carries both its offset into the stitched audio and the
+// wall-clock time it was spoken.
+package timeline
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/audio"
+	"github.com/snarg/tr-engine/internal/transcribe"
+)
+
+// Defaults for Options.
+const (
+	DefaultSampleRate  = 8000
+	DefaultGap         = time.Second
+	DefaultMaxDuration = 2 * time.Hour
+)
+
+// Call is one recording to include.
+type Call struct {
+	CallID     int64
+	SystemName string
+	Tgid       int
+	TgAlphaTag string
+	StartTime  time.Time
+	Duration   float64 // seconds, from the call record; used when the audio can't be decoded
+	AudioPath  string  // local audio file, "" if unavailable
+	Text       string  // primary transcript, used when it has no segments
+	Segments   []transcribe.Segment
+}
+
+// Options controls how calls are stitched.
+type Options struct {
+	SampleRate  int           // output rate in Hz (default 8000)
+	Gap         time.Duration // silence between calls (default 1s; negative = none)
+	MaxDuration time.Duration // refuse to build longer audio (default 2h)
+}
+
+// Entry places a call in the stitched audio.
+type Entry struct {
+	CallID     int64     `json:"call_id"`
+	SystemName string    `json:"system_name,omitempty"`
+	Tgid       int       `json:"tgid"`
+	TgAlphaTag string    `json:"tg_alpha_tag,omitempty"`
+	StartTime  time.Time `json:"start_time"`
+	Offset     float64   `json:"offset"` // seconds into timeline.wav
+	Length     float64   `json:"length"`
+	Audio      string    `json:"audio"` // "ok", "missing", or "undecodable" (silence in its place)
+}
+
+// Cue is one transcript line.
+type Cue struct {
+	CallID  int64     `json:"call_id"`
+	Start   float64   `json:"start"` // seconds into timeline.wav
+	End     float64   `json:"end"`
+	Time    time.Time `json:"time"` // when it was said
+	Speaker string    `json:"speaker,omitempty"`
+	Text    string    `json:"text"`
+}
+
+// ExternalEvent is an event from another receiver (ADS-B, AIS, ...) linked
+// to calls in the package.
+type ExternalEvent struct {
+	Time      time.Time       `json:"time"`
+	Source    string          `json:"source"`
+	Kind      string          `json:"kind,omitempty"`
+	Subject   string          `json:"subject,omitempty"`
+	Label     string          `json:"label,omitempty"`
+	Latitude  *float64        `json:"latitude,omitempty"`
+	Longitude *float64        `json:"longitude,omitempty"`
+	CallIDs   []int64         `json:"call_ids"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// Annotation is a typed note a bot or external decoder posted on a call in
+// the package.
+type Annotation struct {
+	CallID     int64           `json:"call_id"`
+	Time       time.Time       `json:"time"` // when it was posted
+	Type       string          `json:"type"`
+	Source     string          `json:"source"`
+	Label      string          `json:"label,omitempty"`
+	Confidence *float32        `json:"confidence,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+}
+
+// Package is a built review package.
+type Package struct {
+	SampleRate int     `json:"sample_rate"`
+	Duration   float64 `json:"duration"`
+	Gap        float64 `json:"gap"`
+	Calls      []Entry `json:"calls"`
+	Cues       []Cue   `json:"cues"`
+
+	// ExternalEvents and Annotations are set by the caller after Build.
+	ExternalEvents []ExternalEvent `json:"external_events,omitempty"`
+	Annotations    []Annotation    `json:"annotations,omitempty"`
+
+	samples []int16
+}
+
+// Build decodes and stitches calls in chronological order. Calls whose
+// audio is missing or can't be decoded are kept as silence of their
+// recorded duration so the transcript stays in sync.
+func Build(ctx context.Context, calls []Call, opts Options) (*Package, error) {
+	if opts.SampleRate <= 0 {
+		opts.SampleRate = DefaultSampleRate
+	}
+	if opts.Gap == 0 {
+		opts.Gap = DefaultGap
+	} else if opts.Gap < 0 {
+		opts.Gap = 0
+	}
+	if opts.MaxDuration <= 0 {
+		opts.MaxDuration = DefaultMaxDuration
+	}
+	maxSamples := int(opts.MaxDuration.Seconds() * float64(opts.SampleRate))
+	gap := int(opts.Gap.Seconds() * float64(opts.SampleRate))
+
+	sorted := append([]Call(nil), calls...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].StartTime.Equal(sorted[j].StartTime) {
+			return sorted[i].StartTime.Before(sorted[j].StartTime)
+		}
+		return sorted[i].CallID < sorted[j].CallID
+	})
+
+	p := &Package{
+		SampleRate: opts.SampleRate,
+		Gap:        opts.Gap.Seconds(),
+		Calls:      make([]Entry, 0, len(sorted)),
+		Cues:       []Cue{},
+	}
+	rate := float64(opts.SampleRate)
+	for i, c := range sorted {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			p.samples = append(p.samples, make([]int16, gap)...)
+		}
+
+		status := "missing"
+		var clip []int16
+		if c.AudioPath != "" {
+			var err error
+			if clip, err = audio.DecodeFile(ctx, c.AudioPath, opts.SampleRate); err == nil {
+				status = "ok"
+			} else {
+				status = "undecodable"
+				clip = nil
+			}
+		}
+		if clip == nil {
+			clip = make([]int16, int(math.Max(c.Duration, 0)*rate))
+		}
+		if len(p.samples)+len(clip) > maxSamples {
+			return nil, fmt.Errorf("stitched audio would exceed %s", opts.MaxDuration)
+		}
+
+		offset := float64(len(p.samples)) / rate
+		length := float64(len(clip)) / rate
+		p.samples = append(p.samples, clip...)
+		p.Calls = append(p.Calls, Entry{
+			CallID:     c.CallID,
+			SystemName: c.SystemName,
+			Tgid:       c.Tgid,
+			TgAlphaTag: c.TgAlphaTag,
+			StartTime:  c.StartTime,
+			Offset:     offset,
+			Length:     length,
+			Audio:      status,
+		})
+		p.Cues = append(p.Cues, callCues(c, offset, length)...)
+	}
+	p.Duration = float64(len(p.samples)) / rate
+	return p, nil
+}
+
+// callCues turns a call's transcript into cues placed at offset.
+func callCues(c Call, offset, length float64) []Cue {
+	var cues []Cue
+	for _, seg := range c.Segments {
+		text := strings.TrimSpace(seg.Text)
+		if text == "" {
+			continue
+		}
+		start := math.Min(math.Max(seg.Start, 0), length)
+		end := math.Min(math.Max(seg.End, start), length)
+		cues = append(cues, Cue{
+			CallID:  c.CallID,
+			Start:   offset + start,
+			End:     offset + end,
+			Time:    c.StartTime.Add(time.Duration(seg.Start * float64(time.Second))),
+			Speaker: speaker(seg),
+			Text:    text,
+		})
+	}
+	if len(cues) == 0 && strings.TrimSpace(c.Text) != "" {
+		cues = append(cues, Cue{
+			CallID: c.CallID,
+			Start:  offset,
+			End:    offset + length,
+			Time:   c.StartTime,
+			Text:   strings.TrimSpace(c.Text),
+		})
+	}
+	return cues
+}
+
+// speaker names a segment's unit by its alpha tag, else its radio ID.
+func speaker(seg transcribe.Segment) string {
+	switch {
+	case seg.SrcTag != "":
+		return seg.SrcTag
+	case seg.Src > 0:
+		return fmt.Sprintf("Unit %d", seg.Src)
+	}
+	return ""
+}
+
+// WriteZip writes the package as a zip: timeline.wav, transcript.vtt
+// (WebVTT, speaker as voice span, absolute time in each cue),
+// transcript.txt and manifest.json.
+func (p *Package) WriteZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"timeline.wav", func(w io.Writer) error { return audio.WriteWAV(w, p.samples, p.SampleRate) }},
+		{"transcript.vtt", p.WriteVTT},
+		{"transcript.txt", p.WriteText},
+		{"manifest.json", func(w io.Writer) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(p)
+		}},
+	}
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if err := f.write(fw); err != nil {
+			return fmt.Errorf("write %s: %w", f.name, err)
+		}
+	}
+	return zw.Close()
+}
+
+// WriteVTT writes the transcript as WebVTT.
+func (p *Package) WriteVTT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	for i, c := range p.Cues {
+		fmt.Fprintf(&b, "\n%d\n%s --> %s\n", i+1, vttTime(c.Start), vttTime(c.End))
+		fmt.Fprintf(&b, "[%s] ", c.Time.UTC().Format(time.RFC3339))
+		if c.Speaker != "" {
+			fmt.Fprintf(&b, "<v %s>", vttEscape(c.Speaker))
+		}
+		b.WriteString(vttEscape(c.Text))
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteText writes a plain transcript for reports, one line per cue, with
+// a header line for each call and its annotations after its cues, followed
+// by any external events.
+func (p *Package) WriteText(w io.Writer) error {
+	var b strings.Builder
+	cues := p.Cues
+	for _, e := range p.Calls {
+		tg := e.TgAlphaTag
+		if tg == "" {
+			tg = fmt.Sprintf("TG %d", e.Tgid)
+		}
+		fmt.Fprintf(&b, "== %s  %s  call %d  (%s in timeline.wav)", e.StartTime.UTC().Format(time.RFC3339), tg, e.CallID, clock(e.Offset))
+		if e.Audio != "ok" {
+			fmt.Fprintf(&b, "  [audio %s]", e.Audio)
+		}
+		b.WriteString("\n")
+		for len(cues) > 0 && cues[0].CallID == e.CallID {
+			c := cues[0]
+			cues = cues[1:]
+			who := c.Speaker
+			if who == "" {
+				who = "Unknown"
+			}
+			fmt.Fprintf(&b, "%s  %s  %s: %s\n", c.Time.UTC().Format(time.RFC3339), clock(c.Start), who, c.Text)
+		}
+		for _, a := range p.Annotations {
+			if a.CallID != e.CallID {
+				continue
+			}
+			fmt.Fprintf(&b, "* %s", a.Type)
+			if a.Label != "" {
+				fmt.Fprintf(&b, "  %s", a.Label)
+			}
+			if a.Confidence != nil {
+				fmt.Fprintf(&b, "  (%s, %.2f)\n", a.Source, *a.Confidence)
+			} else {
+				fmt.Fprintf(&b, "  (%s)\n", a.Source)
+			}
+		}
+		b.WriteString("\n")
+	}
+	if len(p.ExternalEvents) > 0 {
+		b.WriteString("== External events\n")
+		for _, e := range p.ExternalEvents {
+			what := e.Source
+			if e.Kind != "" {
+				what += "/" + e.Kind
+			}
+			for _, s := range []string{e.Label, e.Subject} {
+				if s != "" {
+					what += "  " + s
+				}
+			}
+			ids := make([]string, len(e.CallIDs))
+			for i, id := range e.CallIDs {
+				ids[i] = fmt.Sprint(id)
+			}
+			fmt.Fprintf(&b, "%s  %s  (calls %s)\n", e.Time.UTC().Format(time.RFC3339), what, strings.Join(ids, ", "))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// vttTime formats seconds as HH:MM:SS.mmm.
+func vttTime(sec float64) string {
+	ms := int64(math.Round(sec * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// clock formats seconds as HH:MM:SS.
+func clock(sec float64) string {
+	s := int64(sec)
+	return fmt.Sprintf("%02d:%02d:%02d", s/3600, s/60%60, s%60)
+}
+
+var vttReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\n", " ", "-->", "--&gt;")
+
+func vttEscape(s string) string { return vttReplacer.Replace(s) }
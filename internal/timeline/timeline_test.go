@@ -0,0 +1,165 @@
+package timeline
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/audio"
+	"github.com/snarg/tr-engine/internal/transcribe"
+)
+
+func writeWAV(t *testing.T, seconds float64) string {
+	t.Helper()
+	var buf bytes.Buffer
+	samples := make([]int16, int(seconds*8000))
+	for i := range samples {
+		samples[i] = 1000
+	}
+	if err := audio.WriteWAV(&buf, samples, 8000); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "call.wav")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBuild(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 14, 0, 0, 0, time.UTC)
+	calls := []Call{
+		{
+			CallID: 2, Tgid: 100, StartTime: t0.Add(10 * time.Minute), Duration: 3,
+			AudioPath: filepath.Join(t.TempDir(), "gone.wav"),
+			Text:      "second call",
+		},
+		{
+			CallID: 1, Tgid: 100, TgAlphaTag: "Fire Dispatch", StartTime: t0, Duration: 2,
+			AudioPath: writeWAV(t, 2),
+			Segments: []transcribe.Segment{
+				{Src: 4021, SrcTag: "Engine 5", Start: 0.2, End: 1.1, Text: "Engine 5 on scene"},
+				{Src: 4022, Start: 1.3, End: 9, Text: "copy"},
+				{Src: 4023, Start: 1.5, End: 1.6, Text: " "},
+			},
+		},
+	}
+	p, err := Build(context.Background(), calls, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Chronological, gap between, missing audio kept as silence
+	if len(p.Calls) != 2 || p.Calls[0].CallID != 1 || p.Calls[1].CallID != 2 {
+		t.Fatalf("calls = %+v", p.Calls)
+	}
+	if p.Calls[0].Audio != "ok" || p.Calls[1].Audio != "undecodable" {
+		t.Errorf("audio = %q, %q", p.Calls[0].Audio, p.Calls[1].Audio)
+	}
+	if p.Calls[1].Offset != 3 || p.Calls[1].Length != 3 || p.Duration != 6 {
+		t.Errorf("offset %v length %v duration %v", p.Calls[1].Offset, p.Calls[1].Length, p.Duration)
+	}
+
+	if len(p.Cues) != 3 {
+		t.Fatalf("cues = %+v", p.Cues)
+	}
+	if c := p.Cues[0]; c.Speaker != "Engine 5" || c.Start != 0.2 || !c.Time.Equal(t0.Add(200*time.Millisecond)) {
+		t.Errorf("cue 0 = %+v", c)
+	}
+	if c := p.Cues[1]; c.Speaker != "Unit 4022" || c.End != 2 {
+		t.Errorf("cue 1 = %+v (end should clamp to call length)", c)
+	}
+	if c := p.Cues[2]; c.CallID != 2 || c.Start != 3 || c.End != 6 || c.Speaker != "" || c.Text != "second call" {
+		t.Errorf("cue 2 = %+v", c)
+	}
+
+	var vtt strings.Builder
+	p.WriteVTT(&vtt)
+	if !strings.HasPrefix(vtt.String(), "WEBVTT\n") ||
+		!strings.Contains(vtt.String(), "00:00:00.200 --> 00:00:01.100\n[2026-03-01T14:00:00Z] <v Engine 5>Engine 5 on scene\n") {
+		t.Errorf("vtt:\n%s", vtt.String())
+	}
+	p.ExternalEvents = []ExternalEvent{
+		{Time: t0.Add(5 * time.Minute), Source: "adsb", Kind: "position", Subject: "a1b2c3", Label: "N123AB", CallIDs: []int64{1, 2}},
+	}
+	conf := float32(0.9)
+	p.Annotations = []Annotation{
+		{CallID: 1, Time: t0.Add(time.Minute), Type: "tone_detected", Source: "tone-bot", Label: "Station 5", Confidence: &conf},
+	}
+	var txt strings.Builder
+	p.WriteText(&txt)
+	for _, want := range []string{
+		"== 2026-03-01T14:00:00Z  Fire Dispatch  call 1  (00:00:00 in timeline.wav)\n",
+		"2026-03-01T14:00:01Z  00:00:01  Unit 4022: copy\n",
+		"call 2  (00:00:03 in timeline.wav)  [audio undecodable]\n",
+		"Unit 4022: copy\n* tone_detected  Station 5  (tone-bot, 0.90)\n\n== ",
+		"Unknown: second call\n",
+		"== External events\n2026-03-01T14:05:00Z  adsb/position  N123AB  a1b2c3  (calls 1, 2)\n",
+	} {
+		if !strings.Contains(txt.String(), want) {
+			t.Errorf("text missing %q:\n%s", want, txt.String())
+		}
+	}
+}
+
+func TestBuildLimits(t *testing.T) {
+	calls := []Call{
+		{CallID: 1, StartTime: time.Unix(0, 0), Duration: 40},
+		{CallID: 2, StartTime: time.Unix(60, 0), Duration: 30},
+	}
+	if _, err := Build(context.Background(), calls, Options{MaxDuration: time.Minute}); err == nil {
+		t.Error("expected error over MaxDuration")
+	}
+	p, err := Build(context.Background(), calls, Options{Gap: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Calls[0].Audio != "missing" || p.Calls[1].Offset != 40 {
+		t.Errorf("calls = %+v", p.Calls)
+	}
+}
+
+func TestWriteZip(t *testing.T) {
+	p, err := Build(context.Background(), []Call{{CallID: 1, Duration: 1, AudioPath: writeWAV(t, 1), Text: "hi"}}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := p.WriteZip(&buf); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		if f.Name == "timeline.wav" {
+			rc, _ := f.Open()
+			data, _ := io.ReadAll(rc)
+			rc.Close()
+			if d, err := audio.Duration(data, "wav"); err != nil || d != 1 {
+				t.Errorf("timeline.wav duration = %v, %v", d, err)
+			}
+		}
+	}
+	if got := strings.Join(names, ","); got != "timeline.wav,transcript.vtt,transcript.txt,manifest.json" {
+		t.Errorf("files = %s", got)
+	}
+}
+
+func TestVTTTime(t *testing.T) {
+	if got := vttTime(3725.5); got != "01:02:05.500" {
+		t.Errorf("vttTime = %q", got)
+	}
+	if got := vttEscape("a <b> & c --> d"); got != "a &lt;b&gt; &amp; c --&gt; d" {
+		t.Errorf("vttEscape = %q", got)
+	}
+}
@@ -0,0 +1,162 @@
+package transcribe
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// Single-call job statuses.
+const (
+	CallJobQueued    = "queued"
+	CallJobRunning   = "running"
+	CallJobCompleted = "completed"
+	CallJobFailed    = "failed"
+)
+
+const (
+	callJobsMaxUnfinished = 100       // queued or running single-call jobs
+	callJobsKeep          = time.Hour // finished jobs are forgotten after this
+)
+
+// ErrCallJobsFull is returned by Retranscriber.TranscribeCall when too many
+// single-call jobs are unfinished.
+var ErrCallJobsFull = errors.New("too many call transcriptions in progress")
+
+// CallJob is one call re-run through a chosen provider, model and
+// temperature, outside the live queue. Its transcript is stored as a
+// non-primary variant. Jobs are held in memory only.
+type CallJob struct {
+	ID          int64      `json:"job_id"`
+	CallID      int64      `json:"call_id"`
+	Status      string     `json:"status"`
+	Provider    string     `json:"provider"`
+	Model       string     `json:"model"`
+	Temperature *float64   `json:"temperature"` // nil = WHISPER_TEMPERATURE
+	Error       string     `json:"error,omitempty"`
+	QueuedAt    time.Time  `json:"queued_at"`
+	StartedAt   *time.Time `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+}
+
+// callJobs tracks single-call jobs by ID.
+type callJobs struct {
+	mu     sync.Mutex
+	nextID int64
+	jobs   map[int64]*CallJob
+}
+
+func newCallJobs() *callJobs {
+	return &callJobs{jobs: map[int64]*CallJob{}}
+}
+
+// add registers a queued job, first forgetting jobs finished before
+// now-callJobsKeep. Returns ErrCallJobsFull at the limit.
+func (c *callJobs) add(job CallJob, now time.Time) (CallJob, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	unfinished := 0
+	for id, j := range c.jobs {
+		if j.FinishedAt == nil {
+			unfinished++
+		} else if now.Sub(*j.FinishedAt) > callJobsKeep {
+			delete(c.jobs, id)
+		}
+	}
+	if unfinished >= callJobsMaxUnfinished {
+		return CallJob{}, ErrCallJobsFull
+	}
+	c.nextID++
+	job.ID, job.Status, job.QueuedAt = c.nextID, CallJobQueued, now
+	c.jobs[job.ID] = &job
+	return job, nil
+}
+
+// get returns a copy of job id.
+func (c *callJobs) get(id int64) (CallJob, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	j, ok := c.jobs[id]
+	if !ok {
+		return CallJob{}, false
+	}
+	return *j, true
+}
+
+// start marks job id running.
+func (c *callJobs) start(id int64, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if j, ok := c.jobs[id]; ok {
+		j.Status, j.StartedAt = CallJobRunning, &now
+	}
+}
+
+// finish marks job id completed, or failed with err.
+func (c *callJobs) finish(id int64, err error, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	j, ok := c.jobs[id]
+	if !ok {
+		return
+	}
+	j.Status, j.FinishedAt = CallJobCompleted, &now
+	if err != nil {
+		j.Status, j.Error = CallJobFailed, err.Error()
+	}
+}
+
+// TranscribeCall queues call to be re-transcribed by provider and model
+// (empty = STT_PROVIDER and its configured model), with temperature when
+// set, and returns the job. Up to RETRANSCRIBE_MAX_CONCURRENCY single-call
+// jobs run at once, alongside any bulk job. Returns ErrProviderUnavailable
+// or ErrCallJobsFull.
+func (r *Retranscriber) TranscribeCall(call *database.CallTranscriptionInfo, provider, model string, temperature *float64) (CallJob, error) {
+	if provider == "" {
+		provider = r.opts.Pool.ProviderName()
+	}
+	p, err := r.opts.NewProvider(provider, model)
+	if err != nil {
+		return CallJob{}, fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	}
+	job, err := r.calls.add(CallJob{
+		CallID:      call.CallID,
+		Provider:    p.Name(),
+		Model:       p.Model(),
+		Temperature: temperature,
+	}, time.Now())
+	if err != nil {
+		return CallJob{}, err
+	}
+
+	tj := retranscribeJob(database.RetranscribeCall{CallTranscriptionInfo: *call})
+	tj.Temperature = temperature
+	r.callWait.Add(1)
+	go func() {
+		defer r.callWait.Done()
+		select {
+		case r.callSem <- struct{}{}:
+		case <-r.ctx.Done():
+			r.calls.finish(job.ID, r.ctx.Err(), time.Now())
+			return
+		}
+		defer func() { <-r.callSem }()
+
+		r.calls.start(job.ID, time.Now())
+		err := r.opts.Pool.Retranscribe(r.ctx, tj, p, false)
+		if err != nil {
+			r.log.Warn().Err(err).Int64("call_id", job.CallID).Str("provider", job.Provider).
+				Str("model", job.Model).Msg("call re-transcription failed")
+		}
+		r.calls.finish(job.ID, err, time.Now())
+	}()
+	return job, nil
+}
+
+// CallJob returns single-call job id, if it is still known.
+func (r *Retranscriber) CallJob(id int64) (CallJob, bool) {
+	return r.calls.get(id)
+}
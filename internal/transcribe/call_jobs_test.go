@@ -0,0 +1,45 @@
+package transcribe
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCallJobs(t *testing.T) {
+	c := newCallJobs()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	job, err := c.add(CallJob{CallID: 42, Provider: "deepinfra"}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.ID != 1 || job.Status != CallJobQueued || !job.QueuedAt.Equal(now) {
+		t.Errorf("job = %+v", job)
+	}
+
+	c.start(job.ID, now.Add(time.Second))
+	if j, _ := c.get(job.ID); j.Status != CallJobRunning || j.StartedAt == nil {
+		t.Errorf("started = %+v", j)
+	}
+	c.finish(job.ID, errors.New("deepinfra: 502"), now.Add(2*time.Second))
+	if j, _ := c.get(job.ID); j.Status != CallJobFailed || j.Error != "deepinfra: 502" || j.FinishedAt == nil {
+		t.Errorf("failed = %+v", j)
+	}
+
+	// Unfinished jobs are capped; finished ones don't count
+	for i := 0; i < callJobsMaxUnfinished; i++ {
+		if _, err := c.add(CallJob{CallID: int64(i)}, now); err != nil {
+			t.Fatalf("add %d: %v", i, err)
+		}
+	}
+	if _, err := c.add(CallJob{CallID: 1000}, now); !errors.Is(err, ErrCallJobsFull) {
+		t.Errorf("over the limit: %v", err)
+	}
+
+	// Finished jobs are forgotten after callJobsKeep
+	c.add(CallJob{CallID: 1001}, now.Add(callJobsKeep+3*time.Second))
+	if _, ok := c.get(job.ID); ok {
+		t.Error("finished job still kept")
+	}
+}
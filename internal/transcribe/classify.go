@@ -0,0 +1,313 @@
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+// Urgency labels, in increasing severity.
+const (
+	UrgencyRoutine   = "routine"
+	UrgencyUrgent    = "urgent"
+	UrgencyEmergency = "emergency_language"
+)
+
+// urgencyRank orders labels by severity; unknown labels rank as routine.
+var urgencyRank = map[string]int{UrgencyRoutine: 0, UrgencyUrgent: 1, UrgencyEmergency: 2}
+
+// ValidUrgencyLabel reports whether label is one of the urgency labels.
+func ValidUrgencyLabel(label string) bool {
+	_, ok := urgencyRank[label]
+	return ok
+}
+
+// ClassifyInput is a transcript plus the call context a classifier may use.
+type ClassifyInput struct {
+	Text       string
+	SystemID   int
+	Tgid       int
+	TgAlphaTag string
+	TgTag      string
+}
+
+// Classifier scores a transcript for urgency/stress after transcription.
+// A classifier may return a usable result alongside an error (e.g. a model
+// classifier that fell back to keywords); callers should store any non-nil result.
+type Classifier interface {
+	Classify(ctx context.Context, in ClassifyInput) (*database.TranscriptUrgency, error)
+	Name() string // "keyword", "model"
+}
+
+// keywordRule is a phrase and how much it contributes to the urgency score.
+// Emergency phrases force the emergency_language label regardless of score.
+type keywordRule struct {
+	phrase    string
+	weight    float64
+	emergency bool
+}
+
+// urgencyKeywords is radio-traffic vocabulary that signals distress or a
+// priority incident. Phrases are matched on word boundaries after
+// normalization, so "10-33" also matches "10 33".
+var urgencyKeywords = []keywordRule{
+	// Emergency language: a unit in distress or an immediate threat to life
+	{"mayday", 1, true},
+	{"officer down", 1, true},
+	{"firefighter down", 1, true},
+	{"man down", 1, true},
+	{"officer needs assistance", 1, true},
+	{"shots fired", 1, true},
+	{"shot fired", 1, true},
+	{"active shooter", 1, true},
+	{"under fire", 1, true},
+	{"emergency traffic", 1, true},
+	{"emergency button", 1, true},
+	{"10 33", 1, true},
+	{"help me", 1, true},
+
+	// Priority incidents
+	{"in pursuit", 0.5, false},
+	{"pursuit", 0.4, false},
+	{"shooting", 0.5, false},
+	{"stabbing", 0.5, false},
+	{"stabbed", 0.5, false},
+	{"not breathing", 0.5, false},
+	{"cardiac arrest", 0.5, false},
+	{"cpr", 0.4, false},
+	{"unconscious", 0.4, false},
+	{"unresponsive", 0.4, false},
+	{"overdose", 0.4, false},
+	{"working fire", 0.5, false},
+	{"structure fire", 0.4, false},
+	{"fully involved", 0.5, false},
+	{"entrapment", 0.5, false},
+	{"trapped", 0.4, false},
+	{"explosion", 0.5, false},
+	{"mass casualty", 0.5, false},
+	{"mci", 0.5, false},
+	{"hazmat", 0.4, false},
+	{"evacuate", 0.4, false},
+	{"weapon", 0.3, false},
+	{"gun", 0.3, false},
+	{"knife", 0.3, false},
+	{"fight", 0.2, false},
+	{"assault", 0.3, false},
+	{"code 3", 0.3, false},
+	{"backup", 0.2, false},
+	{"expedite", 0.2, false},
+	{"step it up", 0.2, false},
+	{"hurry", 0.2, false},
+	{"priority", 0.2, false},
+}
+
+// urgentThreshold is the keyword score at which a call is labeled urgent.
+const urgentThreshold = 0.4
+
+// KeywordClassifier scores transcripts from urgencyKeywords plus simple
+// stress cues (repeated words, exclamations). It needs no external service.
+type KeywordClassifier struct {
+	rules []keywordRule
+}
+
+// NewKeywordClassifier creates a classifier using the built-in vocabulary.
+func NewKeywordClassifier() *KeywordClassifier {
+	rules := make([]keywordRule, len(urgencyKeywords))
+	for i, r := range urgencyKeywords {
+		r.phrase = normalizeForMatch(r.phrase)
+		rules[i] = r
+	}
+	return &KeywordClassifier{rules: rules}
+}
+
+func (kc *KeywordClassifier) Name() string { return "keyword" }
+
+// Classify never returns an error.
+func (kc *KeywordClassifier) Classify(_ context.Context, in ClassifyInput) (*database.TranscriptUrgency, error) {
+	norm := normalizeForMatch(in.Text)
+	padded := " " + norm + " "
+
+	var score float64
+	emergency := false
+	signals := []string{}
+	for _, r := range kc.rules {
+		if !strings.Contains(padded, " "+r.phrase+" ") {
+			continue
+		}
+		score += r.weight
+		signals = append(signals, r.phrase)
+		if r.emergency {
+			emergency = true
+		}
+	}
+
+	// Stress cues: the same word said back-to-back ("help help", "go go go")
+	// and shouted punctuation.
+	fields := strings.Fields(norm)
+	for i := 1; i < len(fields); i++ {
+		if fields[i] == fields[i-1] && len(fields[i]) > 1 {
+			score += 0.2
+			signals = append(signals, "repetition")
+			break
+		}
+	}
+	if strings.Count(in.Text, "!") >= 2 {
+		score += 0.1
+		signals = append(signals, "exclamation")
+	}
+
+	score = math.Min(score, 1)
+	label := UrgencyRoutine
+	switch {
+	case emergency:
+		label = UrgencyEmergency
+	case score >= urgentThreshold:
+		label = UrgencyUrgent
+	}
+	return &database.TranscriptUrgency{
+		Score:      float32(math.Round(score*100) / 100),
+		Label:      label,
+		Signals:    signals,
+		Classifier: kc.Name(),
+	}, nil
+}
+
+// normalizeForMatch lowercases s and replaces everything but letters and
+// digits with single spaces.
+func normalizeForMatch(s string) string {
+	var b strings.Builder
+	space := true
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			space = false
+		} else if !space {
+			b.WriteByte(' ')
+			space = true
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// ModelClassifier calls a user-supplied classification endpoint and merges its
+// verdict with the keyword classifier's, keeping the more severe label and
+// higher score so a model can add recall without hiding keyword hits. If the
+// endpoint fails, the keyword result is returned along with the error.
+//
+// Request: POST application/json
+//
+//	{"text": "...", "system_id": 1, "tgid": 9131, "tg_alpha_tag": "Fire Dispatch",
+//	 "tg_tag": "Fire Dispatch",
+//	 "keyword": {"score": 0.4, "label": "urgent", "signals": ["structure fire"]}}
+//
+// Response: 200 with JSON
+//
+//	{"score": 0.85, "label": "urgent", "signals": ["raised voice"]}
+//
+// label must be routine, urgent, or emergency_language; score is clamped to 0..1.
+// signals is optional.
+type ModelClassifier struct {
+	url     string
+	headers http.Header
+	client  *http.Client
+	keyword *KeywordClassifier
+}
+
+// NewModelClassifier creates a model-backed classifier. headers are added to
+// every request (e.g. Authorization).
+func NewModelClassifier(url string, headers http.Header, timeout time.Duration) *ModelClassifier {
+	if headers == nil {
+		headers = http.Header{}
+	}
+	return &ModelClassifier{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{Timeout: timeout},
+		keyword: NewKeywordClassifier(),
+	}
+}
+
+func (mc *ModelClassifier) Name() string { return "model" }
+
+func (mc *ModelClassifier) Classify(ctx context.Context, in ClassifyInput) (*database.TranscriptUrgency, error) {
+	kw, _ := mc.keyword.Classify(ctx, in)
+
+	body, err := json.Marshal(map[string]any{
+		"text":         in.Text,
+		"system_id":    in.SystemID,
+		"tgid":         in.Tgid,
+		"tg_alpha_tag": in.TgAlphaTag,
+		"tg_tag":       in.TgTag,
+		"keyword":      kw,
+	})
+	if err != nil {
+		return kw, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, mc.url, bytes.NewReader(body))
+	if err != nil {
+		return kw, err
+	}
+	for name, values := range mc.headers {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := mc.client.Do(req)
+	if err != nil {
+		return kw, fmt.Errorf("urgency model request: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return kw, fmt.Errorf("urgency model error (status %d): %s", resp.StatusCode, string(respBody))
+	}
+
+	var m database.TranscriptUrgency
+	if err := json.Unmarshal(respBody, &m); err != nil {
+		return kw, fmt.Errorf("decode urgency model response: %w", err)
+	}
+	if !ValidUrgencyLabel(m.Label) {
+		return kw, fmt.Errorf("urgency model returned invalid label %q", m.Label)
+	}
+	m.Score = float32(math.Max(0, math.Min(1, float64(m.Score))))
+
+	return mergeUrgency(kw, &m, mc.Name()), nil
+}
+
+// mergeUrgency combines keyword and model verdicts: the more severe label,
+// the higher score, and the union of signals.
+func mergeUrgency(kw, model *database.TranscriptUrgency, classifier string) *database.TranscriptUrgency {
+	out := &database.TranscriptUrgency{
+		Score:      kw.Score,
+		Label:      kw.Label,
+		Signals:    append([]string{}, kw.Signals...),
+		Classifier: classifier,
+	}
+	if model.Score > out.Score {
+		out.Score = model.Score
+	}
+	if urgencyRank[model.Label] > urgencyRank[out.Label] {
+		out.Label = model.Label
+	}
+	seen := make(map[string]bool, len(out.Signals))
+	for _, s := range out.Signals {
+		seen[s] = true
+	}
+	for _, s := range model.Signals {
+		if !seen[s] {
+			seen[s] = true
+			out.Signals = append(out.Signals, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,105 @@
+package transcribe
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestKeywordClassifier(t *testing.T) {
+	kc := NewKeywordClassifier()
+	tests := []struct {
+		text      string
+		wantLabel string
+		wantSig   string // a signal expected in the result; "" = none required
+	}{
+		{"Engine 5 on scene, nothing showing, holding the assignment.", UrgencyRoutine, ""},
+		{"Mayday mayday, firefighter down on the second floor!", UrgencyEmergency, "mayday"},
+		{"Unit 12 is 10-33, need units now", UrgencyEmergency, "10 33"},
+		{"Medic 3 respond for a male not breathing, CPR in progress", UrgencyUrgent, "not breathing"},
+		{"We're in pursuit southbound on Main", UrgencyUrgent, "in pursuit"},
+		{"Backup requested at the gas station", UrgencyRoutine, "backup"},
+		{"Go go go, get out!! now!!", UrgencyRoutine, "repetition"},
+		{"The gunnery club called about parking", UrgencyRoutine, ""}, // "gun" must match whole words
+	}
+	for _, tt := range tests {
+		u, err := kc.Classify(context.Background(), ClassifyInput{Text: tt.text})
+		if err != nil {
+			t.Fatalf("Classify(%q): %v", tt.text, err)
+		}
+		if u.Label != tt.wantLabel {
+			t.Errorf("Classify(%q) label = %q (score %.2f, signals %v), want %q", tt.text, u.Label, u.Score, u.Signals, tt.wantLabel)
+		}
+		if u.Score < 0 || u.Score > 1 {
+			t.Errorf("Classify(%q) score = %v, out of range", tt.text, u.Score)
+		}
+		if tt.wantSig != "" && !containsString(u.Signals, tt.wantSig) {
+			t.Errorf("Classify(%q) signals = %v, want %q", tt.text, u.Signals, tt.wantSig)
+		}
+		if tt.wantSig == "" && tt.wantLabel == UrgencyRoutine && len(u.Signals) != 0 {
+			t.Errorf("Classify(%q) signals = %v, want none", tt.text, u.Signals)
+		}
+	}
+}
+
+func TestModelClassifier(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer k" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			Text    string `json:"text"`
+			Tgid    int    `json:"tgid"`
+			Keyword struct {
+				Label string `json:"label"`
+			} `json:"keyword"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Tgid != 9131 || req.Keyword.Label == "" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"score": 1.7, "label": "urgent", "signals": ["raised voice"]}`))
+	}))
+	defer srv.Close()
+
+	mc := NewModelClassifier(srv.URL, http.Header{"Authorization": {"Bearer k"}}, 5*time.Second)
+
+	// Model raises a routine keyword verdict; score is clamped.
+	u, err := mc.Classify(context.Background(), ClassifyInput{Text: "copy that", Tgid: 9131})
+	if err != nil {
+		t.Fatalf("Classify: %v", err)
+	}
+	if u.Label != UrgencyUrgent || u.Score != 1 || u.Classifier != "model" || !containsString(u.Signals, "raised voice") {
+		t.Errorf("merged = %+v", u)
+	}
+
+	// Model can't lower a keyword emergency.
+	u, _ = mc.Classify(context.Background(), ClassifyInput{Text: "shots fired", Tgid: 9131})
+	if u.Label != UrgencyEmergency {
+		t.Errorf("label = %q, want %q", u.Label, UrgencyEmergency)
+	}
+
+	// Endpoint failure falls back to the keyword verdict with an error.
+	bad := NewModelClassifier(srv.URL, nil, 5*time.Second)
+	u, err = bad.Classify(context.Background(), ClassifyInput{Text: "mayday", Tgid: 9131})
+	if err == nil {
+		t.Error("expected error from unauthorized endpoint")
+	}
+	if u == nil || u.Label != UrgencyEmergency || u.Classifier != "keyword" {
+		t.Errorf("fallback = %+v", u)
+	}
+}
+
+func containsString(s []string, v string) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,199 @@
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CustomClient calls a user-supplied STT endpoint that implements the
+// tr-engine custom provider contract (see docs/custom-stt.md). This lets
+// Google STT, Azure, or a local model server be wired in via a small adapter
+// without adding a vendor-specific provider.
+// Implements the Provider interface.
+//
+// Request: POST multipart/form-data with fields
+//
+//	file         audio file (required)
+//	language     ISO-639 language code (always sent, default "en")
+//	model        model identifier (only when CUSTOM_STT_MODEL is set)
+//	temperature  decoding temperature
+//	prompt       domain vocabulary prompt (only when set)
+//	hotwords     comma-separated boost terms (only when set)
+//
+// Response: 200 with JSON
+//
+//	{"text": "...", "language": "en", "duration": 4.2,
+//	 "words": [{"word": "Engine", "start": 0.12, "end": 0.48}, ...]}
+//
+// Only "text" is required. Words may use "text" instead of "word".
+type CustomClient struct {
+	url     string
+	model   string
+	headers http.Header
+	timeout time.Duration
+	client  *http.Client
+}
+
+// customResponse is the JSON response defined by the custom provider contract.
+type customResponse struct {
+	Text     string       `json:"text"`
+	Language string       `json:"language"`
+	Duration float64      `json:"duration"`
+	Words    []customWord `json:"words"`
+}
+
+// customWord accepts both "word" (OpenAI style) and "text" (DeepInfra style)
+// so existing adapters need not rename fields.
+type customWord struct {
+	Word  string  `json:"word"`
+	Text  string  `json:"text"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// NewCustomClient creates a client for a custom STT endpoint. headers are
+// added to every request (e.g. Authorization, Ocp-Apim-Subscription-Key).
+func NewCustomClient(url, model string, headers http.Header, timeout time.Duration) *CustomClient {
+	if headers == nil {
+		headers = http.Header{}
+	}
+	return &CustomClient{
+		url:     url,
+		model:   model,
+		headers: headers,
+		timeout: timeout,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// ParseHeaders parses a semicolon-separated list of "Name: value" pairs into
+// an http.Header. Semicolons are used because header values (e.g. bearer
+// tokens) may legitimately contain commas.
+func ParseHeaders(s string) (http.Header, error) {
+	h := http.Header{}
+	for _, pair := range strings.Split(s, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header %q (expected \"Name: value\")", pair)
+		}
+		h.Add(name, strings.TrimSpace(value))
+	}
+	return h, nil
+}
+
+// Name returns the provider name.
+func (cc *CustomClient) Name() string { return "custom" }
+
+// Model returns the configured model identifier, or "custom" when unset.
+// The URL is deliberately not used since it may embed credentials.
+func (cc *CustomClient) Model() string {
+	if cc.model != "" {
+		return cc.model
+	}
+	return "custom"
+}
+
+// Transcribe sends an audio file to the custom endpoint and returns the result.
+func (cc *CustomClient) Transcribe(ctx context.Context, audioPath string, opts TranscribeOpts) (*Response, error) {
+	f, err := os.Open(audioPath)
+	if err != nil {
+		return nil, fmt.Errorf("open audio file: %w", err)
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	// Audio file field
+	part, err := w.CreateFormFile("file", filepath.Base(audioPath))
+	if err != nil {
+		return nil, fmt.Errorf("create form file: %w", err)
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		return nil, fmt.Errorf("copy audio data: %w", err)
+	}
+
+	lang := opts.Language
+	if lang == "" {
+		lang = "en"
+	}
+	w.WriteField("language", lang)
+	w.WriteField("temperature", fmt.Sprintf("%.2f", opts.Temperature))
+
+	if cc.model != "" {
+		w.WriteField("model", cc.model)
+	}
+	if opts.Prompt != "" {
+		w.WriteField("prompt", opts.Prompt)
+	}
+	if opts.Hotwords != "" {
+		w.WriteField("hotwords", opts.Hotwords)
+	}
+
+	w.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cc.url, &buf)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	for name, values := range cc.headers {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := cc.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("custom STT request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("custom STT error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var result customResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	var words []Word
+	for _, cw := range result.Words {
+		text := cw.Word
+		if text == "" {
+			text = cw.Text
+		}
+		if text == "" {
+			continue
+		}
+		words = append(words, Word{Word: text, Start: cw.Start, End: cw.End})
+	}
+
+	return &Response{
+		Text:     result.Text,
+		Language: result.Language,
+		Duration: result.Duration,
+		Words:    words,
+	}, nil
+}
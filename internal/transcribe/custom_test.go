@@ -0,0 +1,82 @@
+package transcribe
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseHeaders(t *testing.T) {
+	h, err := ParseHeaders("Authorization: Bearer a,b ; X-Region:us-east;;")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := h.Get("Authorization"); got != "Bearer a,b" {
+		t.Errorf("Authorization = %q, want %q", got, "Bearer a,b")
+	}
+	if got := h.Get("X-Region"); got != "us-east" {
+		t.Errorf("X-Region = %q, want %q", got, "us-east")
+	}
+
+	for _, bad := range []string{"NoColon", ": value"} {
+		if _, err := ParseHeaders(bad); err == nil {
+			t.Errorf("ParseHeaders(%q) expected error", bad)
+		}
+	}
+}
+
+func TestCustomClient_Transcribe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, _, err := r.FormFile("file"); err != nil {
+			http.Error(w, "missing file", http.StatusBadRequest)
+			return
+		}
+		if r.FormValue("language") != "es" || r.FormValue("model") != "my-model" || r.FormValue("hotwords") != "Medic" {
+			http.Error(w, "unexpected form fields", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"text":"Medic 5 en route","language":"es","duration":2.5,
+			"words":[{"word":"Medic","start":0.1,"end":0.4},{"text":"5","start":0.5,"end":0.6},{"start":0.7,"end":0.8}]}`))
+	}))
+	defer srv.Close()
+
+	audio := filepath.Join(t.TempDir(), "call.wav")
+	if err := os.WriteFile(audio, []byte("RIFF"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	headers, _ := ParseHeaders("X-Api-Key: secret")
+	cc := NewCustomClient(srv.URL, "my-model", headers, 5*time.Second)
+	resp, err := cc.Transcribe(context.Background(), audio, TranscribeOpts{Language: "es", Hotwords: "Medic"})
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if resp.Text != "Medic 5 en route" || resp.Language != "es" || resp.Duration != 2.5 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if len(resp.Words) != 2 || resp.Words[1].Word != "5" {
+		t.Errorf("words = %+v, want 2 words with text fallback", resp.Words)
+	}
+
+	// Missing auth header surfaces the upstream status.
+	cc = NewCustomClient(srv.URL, "", nil, 5*time.Second)
+	if _, err := cc.Transcribe(context.Background(), audio, TranscribeOpts{}); err == nil {
+		t.Error("expected error for non-200 response")
+	}
+	if cc.Model() != "custom" {
+		t.Errorf("Model() = %q, want %q", cc.Model(), "custom")
+	}
+}
@@ -0,0 +1,233 @@
+package transcribe
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Diff hunk operations.
+const (
+	DiffReplace = "replace"
+	DiffInsert  = "insert"
+	DiffDelete  = "delete"
+)
+
+// maxDiffCells bounds the alignment table of DiffWords. Larger changes are
+// reported as a single replace hunk.
+const maxDiffCells = 1 << 22
+
+// WordEdit replaces Count words starting at Index with the words of Text.
+// Count 0 inserts; empty Text deletes.
+type WordEdit struct {
+	Index int    `json:"index"`
+	Count int    `json:"count"`
+	Text  string `json:"text"`
+}
+
+// DiffHunk is one changed run of words between two transcript versions.
+type DiffHunk struct {
+	Op     string   `json:"op"`
+	Pos    int      `json:"pos"`     // index of the first word in the old version
+	NewPos int      `json:"new_pos"` // index of the first word in the new version
+	Old    []string `json:"old,omitempty"`
+	New    []string `json:"new,omitempty"`
+}
+
+// TranscriptDiff is the word-level difference between two transcript versions.
+type TranscriptDiff struct {
+	Hunks    []DiffHunk `json:"hunks"`
+	OldWords int        `json:"old_words"`
+	NewWords int        `json:"new_words"`
+	Edits    int        `json:"edits"` // word substitutions + insertions + deletions
+}
+
+// ApplyWordEdits applies edits to words. Edits index the original words and
+// must be in order and not overlap.
+func ApplyWordEdits(words []string, edits []WordEdit) ([]string, error) {
+	out := make([]string, 0, len(words))
+	next := 0
+	for i, e := range edits {
+		if e.Index < next || e.Count < 0 || e.Index+e.Count > len(words) {
+			return nil, fmt.Errorf("edit %d: index %d count %d out of order or out of range (%d words)", i, e.Index, e.Count, len(words))
+		}
+		out = append(out, words[next:e.Index]...)
+		out = append(out, strings.Fields(e.Text)...)
+		next = e.Index + e.Count
+	}
+	return append(out, words[next:]...), nil
+}
+
+// DiffWords aligns two word sequences with minimum edit distance and groups
+// the changes into hunks.
+func DiffWords(old, new []string) TranscriptDiff {
+	d := TranscriptDiff{Hunks: []DiffHunk{}, OldWords: len(old), NewWords: len(new)}
+
+	// Common prefix and suffix need no alignment.
+	pre := 0
+	for pre < len(old) && pre < len(new) && old[pre] == new[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(old)-pre && suf < len(new)-pre && old[len(old)-1-suf] == new[len(new)-1-suf] {
+		suf++
+	}
+	a, b := old[pre:len(old)-suf], new[pre:len(new)-suf]
+	if len(a) == 0 && len(b) == 0 {
+		return d
+	}
+	if len(a)*len(b) > maxDiffCells {
+		d.Hunks = append(d.Hunks, newHunk(pre, pre, a, b))
+		d.Edits = max(len(a), len(b))
+		return d
+	}
+
+	// dist[i][j] is the edit distance between a[i:] and b[j:].
+	w := len(b) + 1
+	dist := make([]int32, (len(a)+1)*w)
+	for i := len(a); i >= 0; i-- {
+		for j := len(b); j >= 0; j-- {
+			switch {
+			case i == len(a):
+				dist[i*w+j] = int32(len(b) - j)
+			case j == len(b):
+				dist[i*w+j] = int32(len(a) - i)
+			case a[i] == b[j]:
+				dist[i*w+j] = dist[(i+1)*w+j+1]
+			default:
+				dist[i*w+j] = 1 + min(dist[(i+1)*w+j+1], dist[(i+1)*w+j], dist[i*w+j+1])
+			}
+		}
+	}
+	d.Edits = int(dist[0])
+
+	// Walk the table forward, collecting runs of changed words.
+	i, j := 0, 0
+	hi, hj := -1, -1 // start of the open hunk
+	flush := func() {
+		if hi >= 0 {
+			d.Hunks = append(d.Hunks, newHunk(pre+hi, pre+hj, a[hi:i], b[hj:j]))
+			hi, hj = -1, -1
+		}
+	}
+	for i < len(a) || j < len(b) {
+		if i < len(a) && j < len(b) && a[i] == b[j] && dist[i*w+j] == dist[(i+1)*w+j+1] {
+			flush()
+			i, j = i+1, j+1
+			continue
+		}
+		if hi < 0 {
+			hi, hj = i, j
+		}
+		cur := dist[i*w+j]
+		switch {
+		case i < len(a) && j < len(b) && cur == 1+dist[(i+1)*w+j+1]:
+			i, j = i+1, j+1
+		case i < len(a) && cur == 1+dist[(i+1)*w+j]:
+			i++
+		default:
+			j++
+		}
+	}
+	flush()
+	return d
+}
+
+func newHunk(pos, newPos int, old, new []string) DiffHunk {
+	h := DiffHunk{Op: DiffReplace, Pos: pos, NewPos: newPos}
+	if len(old) > 0 {
+		h.Old = append([]string(nil), old...)
+	}
+	if len(new) > 0 {
+		h.New = append([]string(nil), new...)
+	}
+	switch {
+	case len(old) == 0:
+		h.Op = DiffInsert
+	case len(new) == 0:
+		h.Op = DiffDelete
+	}
+	return h
+}
+
+// RemapWords carries word timing and unit attribution from old to a new
+// version of the transcript, given the diff between old's words and
+// newWords. Unchanged words keep their timing; replacement words share the
+// span of the words they replace; inserted words get a zero-length slot at
+// the end of the preceding word.
+func RemapWords(old *TranscriptionWords, diff TranscriptDiff, newWords []string, newText string) *TranscriptionWords {
+	out := make([]AttributedWord, 0, len(newWords))
+	oi := 0
+	keep := func(to int) {
+		for ; oi < to && oi < len(old.Words); oi++ {
+			out = append(out, old.Words[oi])
+		}
+	}
+	for _, h := range diff.Hunks {
+		keep(h.Pos)
+		var start, end float64
+		var src AttributedWord
+		switch {
+		case len(h.Old) > 0 && h.Pos+len(h.Old) <= len(old.Words):
+			src = old.Words[h.Pos]
+			start, end = src.Start, old.Words[h.Pos+len(h.Old)-1].End
+		case len(out) > 0:
+			src = out[len(out)-1]
+			start, end = src.End, src.End
+		case h.Pos < len(old.Words):
+			src = old.Words[h.Pos]
+			start, end = src.Start, src.Start
+		}
+		step := (end - start) / float64(max(len(h.New), 1))
+		for k, word := range h.New {
+			out = append(out, AttributedWord{
+				Word:   word,
+				Start:  start + step*float64(k),
+				End:    start + step*float64(k+1),
+				Src:    src.Src,
+				SrcTag: src.SrcTag,
+			})
+		}
+		oi = h.Pos + len(h.Old)
+	}
+	keep(len(old.Words))
+	return &TranscriptionWords{Words: out, Segments: buildSegments(out, newText)}
+}
+
+// WordErrorRate scores hypothesis against reference: word errors
+// (substitutions, insertions, deletions) and reference length, after
+// lowercasing and stripping punctuation.
+func WordErrorRate(reference, hypothesis string) (errors, referenceWords int) {
+	ref, hyp := normalizeWords(reference), normalizeWords(hypothesis)
+	if len(ref) == 0 {
+		return len(hyp), 0
+	}
+	prev := make([]int, len(hyp)+1)
+	cur := make([]int, len(hyp)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ref); i++ {
+		cur[0] = i
+		for j := 1; j <= len(hyp); j++ {
+			if ref[i-1] == hyp[j-1] {
+				cur[j] = prev[j-1]
+			} else {
+				cur[j] = 1 + min(prev[j-1], prev[j], cur[j-1])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(hyp)], len(ref)
+}
+
+func normalizeWords(text string) []string {
+	var words []string
+	for _, f := range strings.Fields(strings.ToLower(text)) {
+		f = strings.TrimFunc(f, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		if f != "" {
+			words = append(words, f)
+		}
+	}
+	return words
+}
@@ -0,0 +1,111 @@
+package transcribe
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyWordEdits(t *testing.T) {
+	words := strings.Fields("engine 5 responding to main street")
+	got, err := ApplyWordEdits(words, []WordEdit{
+		{Index: 1, Count: 1, Text: "51"},
+		{Index: 4, Count: 0, Text: "north"},
+		{Index: 5, Count: 1, Text: ""},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "engine 51 responding to north main"; strings.Join(got, " ") != want {
+		t.Errorf("got %q, want %q", strings.Join(got, " "), want)
+	}
+
+	for name, edits := range map[string][]WordEdit{
+		"overlap":      {{Index: 1, Count: 2}, {Index: 2, Count: 1}},
+		"out of order": {{Index: 3, Count: 1}, {Index: 1, Count: 1}},
+		"past end":     {{Index: 5, Count: 2}},
+		"negative":     {{Index: 1, Count: -1}},
+	} {
+		if _, err := ApplyWordEdits(words, edits); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestDiffWords(t *testing.T) {
+	tests := []struct {
+		name  string
+		old   string
+		new   string
+		hunks []DiffHunk
+		edits int
+	}{
+		{"same", "engine 5 on scene", "engine 5 on scene", []DiffHunk{}, 0},
+		{
+			"replace", "engine 5 on scene", "engine 51 on scene",
+			[]DiffHunk{{Op: DiffReplace, Pos: 1, NewPos: 1, Old: []string{"5"}, New: []string{"51"}}}, 1,
+		},
+		{
+			"insert and delete", "medic one to county", "medic one en route county general",
+			[]DiffHunk{
+				{Op: DiffReplace, Pos: 2, NewPos: 2, Old: []string{"to"}, New: []string{"en", "route"}},
+				{Op: DiffInsert, Pos: 4, NewPos: 5, New: []string{"general"}},
+			}, 3,
+		},
+		{
+			"delete", "copy copy that", "copy that",
+			[]DiffHunk{{Op: DiffDelete, Pos: 1, NewPos: 1, Old: []string{"copy"}}}, 1,
+		},
+	}
+	for _, tt := range tests {
+		d := DiffWords(strings.Fields(tt.old), strings.Fields(tt.new))
+		if !reflect.DeepEqual(d.Hunks, tt.hunks) {
+			t.Errorf("%s: hunks = %+v, want %+v", tt.name, d.Hunks, tt.hunks)
+		}
+		if d.Edits != tt.edits {
+			t.Errorf("%s: edits = %d, want %d", tt.name, d.Edits, tt.edits)
+		}
+	}
+}
+
+func TestRemapWords(t *testing.T) {
+	old := &TranscriptionWords{Words: []AttributedWord{
+		{Word: "engine", Start: 0, End: 0.5, Src: 100},
+		{Word: "5", Start: 0.5, End: 1.0, Src: 100},
+		{Word: "copy", Start: 2.0, End: 2.4, Src: 200},
+	}}
+	newWords := []string{"engine", "fifty", "one", "copy", "that"}
+	d := DiffWords([]string{"engine", "5", "copy"}, newWords)
+	got := RemapWords(old, d, newWords, "engine fifty one copy that")
+
+	if len(got.Words) != len(newWords) {
+		t.Fatalf("got %d words, want %d", len(got.Words), len(newWords))
+	}
+	// "fifty one" splits the span of "5".
+	if w := got.Words[1]; w.Start != 0.5 || w.End != 0.75 || w.Src != 100 {
+		t.Errorf("fifty = %+v", w)
+	}
+	if w := got.Words[2]; w.Start != 0.75 || w.End != 1.0 {
+		t.Errorf("one = %+v", w)
+	}
+	// Unchanged words keep their timing; "that" is inserted after "copy".
+	if w := got.Words[3]; w != old.Words[2] {
+		t.Errorf("copy = %+v", w)
+	}
+	if w := got.Words[4]; w.Start != 2.4 || w.End != 2.4 || w.Src != 200 {
+		t.Errorf("that = %+v", w)
+	}
+	if len(got.Segments) != 2 || got.Segments[0].Text != "engine fifty one" || got.Segments[1].Text != "copy that" {
+		t.Errorf("segments = %+v", got.Segments)
+	}
+}
+
+func TestWordErrorRate(t *testing.T) {
+	errs, n := WordErrorRate("Engine 51, respond to Main Street.", "engine 5 respond to main street")
+	if errs != 1 || n != 6 {
+		t.Errorf("WordErrorRate = %d/%d, want 1/6", errs, n)
+	}
+	if errs, n := WordErrorRate("", "noise"); errs != 1 || n != 0 {
+		t.Errorf("empty reference = %d/%d", errs, n)
+	}
+}
@@ -0,0 +1,141 @@
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultPromptTemplate builds a prompt from the global prompt, the
+// talkgroup, the units on the call and recent transcripts on the talkgroup.
+const DefaultPromptTemplate = `{{with .Prompt}}{{.}} {{end}}{{with .AlphaTag}}{{.}}{{end}}{{with .Description}} ({{.}}){{end}}{{with .Tag}}, {{.}}{{end}}.{{with .Units}} Units: {{join . ", "}}.{{end}}{{range .Recent}} {{.}}{{end}}`
+
+// maxPromptChars keeps prompts within Whisper's 224-token prompt window
+// (about four characters per token).
+const maxPromptChars = 896
+
+// PromptData is what prompt templates are rendered with.
+type PromptData struct {
+	Prompt      string   // global WHISPER_PROMPT
+	AlphaTag    string   // talkgroup alpha tag
+	Description string   // talkgroup description
+	Tag         string   // talkgroup category
+	Group       string   // talkgroup group
+	Units       []string // unit alpha tags on the call, in order of first transmission
+	Recent      []string // recent transcripts on the talkgroup, oldest first
+}
+
+var promptFuncs = template.FuncMap{"join": strings.Join}
+
+// ParsePromptTemplate parses a prompt template. Templates see PromptData
+// and may use join (strings.Join).
+func ParsePromptTemplate(text string) (*template.Template, error) {
+	t, err := template.New("prompt").Funcs(promptFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	// Catch references to unknown fields now rather than on the first call.
+	if err := t.Execute(&bytes.Buffer{}, PromptData{}); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// RenderPrompt renders t with data and fits the result to Whisper's prompt
+// window: the oldest recent transcripts are dropped first, then the front of
+// the prompt is cut, since Whisper weighs the end of its prompt most.
+func RenderPrompt(t *template.Template, data PromptData) (string, error) {
+	for {
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, data); err != nil {
+			return "", err
+		}
+		prompt := strings.Join(strings.Fields(buf.String()), " ")
+		if len(prompt) <= maxPromptChars {
+			return prompt, nil
+		}
+		if len(data.Recent) == 0 {
+			prompt = prompt[len(prompt)-maxPromptChars:]
+			if i := strings.IndexByte(prompt, ' '); i >= 0 {
+				prompt = prompt[i+1:]
+			}
+			return prompt, nil
+		}
+		data.Recent = data.Recent[1:]
+	}
+}
+
+// promptUnits returns the unique unit alpha tags in a call's src_list.
+func promptUnits(srcList []Transmission) []string {
+	var units []string
+	seen := make(map[string]bool)
+	for _, tx := range srcList {
+		tag := strings.TrimSpace(tx.Tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		units = append(units, tag)
+	}
+	return units
+}
+
+// jobPrompt returns the STT prompt for a job: the talkgroup or system
+// override if one is set, else the auto-built prompt when PromptAuto is on,
+// else the global prompt. Failures fall back to the global prompt.
+func (wp *WorkerPool) jobPrompt(ctx context.Context, log zerolog.Logger, job Job) string {
+	if wp.db == nil {
+		return wp.opts.Prompt
+	}
+	limit := 0
+	if wp.opts.PromptAuto {
+		limit = wp.opts.PromptContext
+	}
+	pc, err := wp.db.GetSTTPromptContext(ctx, job.SystemID, job.Tgid, job.CallStartTime, wp.opts.PromptContextWindow, limit)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to load prompt context, using global prompt")
+		return wp.opts.Prompt
+	}
+
+	t := wp.promptTmpl
+	if pc.Override != "" {
+		if t, err = wp.overrideTemplate(pc.Override); err != nil {
+			log.Warn().Err(err).Msg("invalid prompt override, using global prompt")
+			return wp.opts.Prompt
+		}
+	} else if !wp.opts.PromptAuto {
+		return wp.opts.Prompt
+	}
+
+	prompt, err := RenderPrompt(t, PromptData{
+		Prompt:      wp.opts.Prompt,
+		AlphaTag:    job.TgAlphaTag,
+		Description: job.TgDescription,
+		Tag:         job.TgTag,
+		Group:       job.TgGroup,
+		Units:       promptUnits(ParseSrcList(job.SrcList, 0)),
+		Recent:      pc.Recent,
+	})
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to render prompt, using global prompt")
+		return wp.opts.Prompt
+	}
+	return prompt
+}
+
+// overrideTemplate parses an override prompt, caching by text.
+func (wp *WorkerPool) overrideTemplate(text string) (*template.Template, error) {
+	if t, ok := wp.overrides.Load(text); ok {
+		return t.(*template.Template), nil
+	}
+	t, err := ParsePromptTemplate(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse override: %w", err)
+	}
+	wp.overrides.Store(text, t)
+	return t, nil
+}
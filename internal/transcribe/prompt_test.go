@@ -0,0 +1,84 @@
+package transcribe
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenderPromptDefault(t *testing.T) {
+	tmpl, err := ParsePromptTemplate(DefaultPromptTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := RenderPrompt(tmpl, PromptData{
+		Prompt:      "Butler County dispatch.",
+		AlphaTag:    "FD Dispatch",
+		Description: "Fire Dispatch",
+		Tag:         "Fire-Talk",
+		Units:       []string{"Engine 7", "Medic 23"},
+		Recent:      []string{"Engine 7 en route.", "  Medic 23\ncopy. "},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Butler County dispatch. FD Dispatch (Fire Dispatch), Fire-Talk. Units: Engine 7, Medic 23. Engine 7 en route. Medic 23 copy."
+	if got != want {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+
+	got, _ = RenderPrompt(tmpl, PromptData{AlphaTag: "TAC 1"})
+	if got != "TAC 1." {
+		t.Errorf("sparse = %q", got)
+	}
+}
+
+func TestParsePromptTemplateErrors(t *testing.T) {
+	for _, text := range []string{"{{.AlphaTag", "{{.Nope}}", "{{frob .Units}}"} {
+		if _, err := ParsePromptTemplate(text); err == nil {
+			t.Errorf("%q: expected an error", text)
+		}
+	}
+	if _, err := ParsePromptTemplate("Plain prompt, no fields."); err != nil {
+		t.Errorf("plain text: %v", err)
+	}
+}
+
+func TestRenderPromptFits(t *testing.T) {
+	tmpl, _ := ParsePromptTemplate(DefaultPromptTemplate)
+	long := strings.Repeat("word ", 100) // 500 chars each
+	got, err := RenderPrompt(tmpl, PromptData{
+		AlphaTag: "PD Main",
+		Recent:   []string{"oldest " + long, "newer " + long, "newest"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) > maxPromptChars {
+		t.Fatalf("len = %d", len(got))
+	}
+	// The oldest transcript goes first; the talkgroup is kept.
+	if strings.Contains(got, "oldest") || !strings.HasPrefix(got, "PD Main.") || !strings.HasSuffix(got, "newest") {
+		t.Errorf("got %q", got)
+	}
+
+	// Without context to drop, the front is cut at a word boundary.
+	got, _ = RenderPrompt(tmpl, PromptData{Prompt: strings.Repeat("abc ", 300), AlphaTag: "PD Main"})
+	if len(got) > maxPromptChars || !strings.HasPrefix(got, "abc ") || !strings.HasSuffix(got, "PD Main.") {
+		t.Errorf("got %d chars: %q", len(got), got)
+	}
+}
+
+func TestPromptUnits(t *testing.T) {
+	srcList := json.RawMessage(`[
+		{"src": 1, "tag": "Engine 7", "pos": 0},
+		{"src": 2, "tag": "", "pos": 1.5},
+		{"src": 3, "tag": "Medic 23", "pos": 3},
+		{"src": 1, "tag": "Engine 7", "pos": 5}
+	]`)
+	got := promptUnits(ParseSrcList(srcList, 0))
+	if want := []string{"Engine 7", "Medic 23"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
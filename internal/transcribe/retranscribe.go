@@ -0,0 +1,350 @@
+package transcribe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+const (
+	retranscribePageSize = 100              // calls per page; progress is saved per page
+	retranscribePoll     = 30 * time.Second // how often to look for a started job
+)
+
+// ErrProviderUnavailable is returned by Retranscriber.Prepare when a job's
+// target provider is unknown or not configured.
+var ErrProviderUnavailable = errors.New("provider unavailable")
+
+// ProviderFactory builds a provider for a re-transcription job's target.
+// An empty model selects the provider's configured model.
+type ProviderFactory func(name, model string) (Provider, error)
+
+// CostRates maps "provider" or "provider:model" to a price per audio minute.
+type CostRates map[string]float64
+
+// ParseCostRates parses a comma-separated list of "provider=price" or
+// "provider:model=price" entries (STT_COST_PER_MINUTE).
+func ParseCostRates(s string) (CostRates, error) {
+	rates := CostRates{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid cost rate %q (expected \"provider[:model]=price\")", entry)
+		}
+		price, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || price < 0 {
+			return nil, fmt.Errorf("invalid price in cost rate %q", entry)
+		}
+		rates[key] = price
+	}
+	return rates, nil
+}
+
+// PerMinute returns the price per audio minute for a provider and model,
+// preferring a model-specific rate.
+func (c CostRates) PerMinute(provider, model string) (float64, bool) {
+	if price, ok := c[provider+":"+model]; ok {
+		return price, true
+	}
+	price, ok := c[provider]
+	return price, ok
+}
+
+// RetranscriberOptions configures bulk re-transcription.
+type RetranscriberOptions struct {
+	Pool           *WorkerPool // audio resolution, prompts, decoding options, duration bounds
+	DB             *database.DB
+	NewProvider    ProviderFactory
+	CostPerMinute  CostRates
+	MaxConcurrency int // upper bound on a job's concurrency
+	Log            zerolog.Logger
+}
+
+// Retranscriber runs re-transcription jobs: the calls a job selects are
+// re-run through its target provider and model, oldest first, outside the
+// live transcription queue. One job runs at a time, in the order started;
+// progress is saved after every page of calls, so a running job resumes
+// after a restart.
+type Retranscriber struct {
+	opts   RetranscriberOptions
+	log    zerolog.Logger
+	ctx    context.Context
+	cancel context.CancelFunc
+	wake   chan struct{}
+	done   chan struct{}
+
+	mu            sync.Mutex
+	current       int // running job ID, 0 when idle
+	cancelCurrent context.CancelFunc
+
+	calls    *callJobs     // single-call jobs, see TranscribeCall
+	callSem  chan struct{} // bounds single-call jobs in flight
+	callWait sync.WaitGroup
+}
+
+// NewRetranscriber creates a re-transcription runner. Call Start to run jobs.
+func NewRetranscriber(opts RetranscriberOptions) *Retranscriber {
+	if opts.MaxConcurrency < 1 {
+		opts.MaxConcurrency = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Retranscriber{
+		opts:    opts,
+		log:     opts.Log,
+		ctx:     ctx,
+		cancel:  cancel,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		calls:   newCallJobs(),
+		callSem: make(chan struct{}, opts.MaxConcurrency),
+	}
+}
+
+// MaxConcurrency returns the upper bound on a job's concurrency.
+func (r *Retranscriber) MaxConcurrency() int { return r.opts.MaxConcurrency }
+
+// Start launches the runner goroutine.
+func (r *Retranscriber) Start() {
+	go r.run()
+}
+
+// Stop halts the runner and waits for it. A running job's unfinished page
+// is redone on the next start; unfinished single-call jobs are dropped.
+func (r *Retranscriber) Stop() {
+	r.cancel()
+	<-r.done
+	r.callWait.Wait()
+}
+
+// Wake makes the runner look for a started job now.
+func (r *Retranscriber) Wake() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Cancel stops work on job id if it is running. The job's status must
+// already be cancelled in the database.
+func (r *Retranscriber) Cancel(id int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.current == id && r.cancelCurrent != nil {
+		r.cancelCurrent()
+	}
+}
+
+// Prepare validates a new job's target and fills in its estimate: the model
+// the provider reports, the calls selected, their audio duration and, when
+// STT_COST_PER_MINUTE has a rate for the provider, the cost.
+func (r *Retranscriber) Prepare(ctx context.Context, job *database.RetranscribeJob) error {
+	provider, err := r.opts.NewProvider(job.Provider, job.Model)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	}
+	job.Provider, job.Model = provider.Name(), provider.Model()
+	job.TotalCalls, job.AudioSeconds, err = r.opts.DB.CountRetranscribeCalls(ctx, r.filter(job))
+	if err != nil {
+		return fmt.Errorf("count calls: %w", err)
+	}
+	job.EstimatedCost = nil
+	if price, ok := r.opts.CostPerMinute.PerMinute(job.Provider, job.Model); ok {
+		cost := price * job.AudioSeconds / 60
+		job.EstimatedCost = &cost
+	}
+	return nil
+}
+
+// EstimatedSeconds estimates how long a job's remaining calls will take from
+// the provider's recent real-time ratio, or nil without recent completions.
+func (r *Retranscriber) EstimatedSeconds(job *database.RetranscribeJob) *float64 {
+	perf := r.opts.Pool.Performance()
+	if perf == nil || job.TotalCalls == 0 {
+		return nil
+	}
+	m, ok := perf.ByProvider[job.Provider]
+	if !ok || m.AvgRealTimeRatio == nil {
+		return nil
+	}
+	remaining := job.AudioSeconds * float64(job.TotalCalls-job.Processed) / float64(job.TotalCalls)
+	secs := remaining * *m.AvgRealTimeRatio / float64(max(job.Concurrency, 1))
+	return &secs
+}
+
+// filter returns a job's call selection with the pool's duration bounds.
+func (r *Retranscriber) filter(job *database.RetranscribeJob) database.RetranscribeFilter {
+	f := job.Filter()
+	f.MinDuration, f.MaxDuration = r.opts.Pool.MinDuration(), r.opts.Pool.MaxDuration()
+	return f
+}
+
+func (r *Retranscriber) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(retranscribePoll)
+	defer ticker.Stop()
+	for {
+		for r.runNext() {
+		}
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-r.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// runNext runs the next started job. Returns true when a job finished and
+// another may be waiting.
+func (r *Retranscriber) runNext() bool {
+	job, err := r.opts.DB.NextRetranscribeJob(r.ctx)
+	if err != nil {
+		if r.ctx.Err() == nil {
+			r.log.Warn().Err(err).Msg("failed to load re-transcription job")
+		}
+		return false
+	}
+	if job == nil {
+		return false
+	}
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	r.mu.Lock()
+	r.current, r.cancelCurrent = job.ID, cancel
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.current, r.cancelCurrent = 0, nil
+		r.mu.Unlock()
+		cancel()
+	}()
+
+	return r.runJob(ctx, job) && r.ctx.Err() == nil
+}
+
+// runJob works through a job's calls from its cursor. Returns true once the
+// job is no longer running.
+func (r *Retranscriber) runJob(ctx context.Context, job *database.RetranscribeJob) bool {
+	log := r.log.With().Int("job_id", job.ID).Str("provider", job.Provider).Str("model", job.Model).Logger()
+	provider, err := r.opts.NewProvider(job.Provider, job.Model)
+	if err != nil {
+		log.Warn().Err(err).Msg("re-transcription job failed")
+		if err := r.opts.DB.FinishRetranscribeJob(r.ctx, job.ID, database.RetranscribeFailed, err.Error()); err != nil {
+			log.Warn().Err(err).Msg("failed to update re-transcription job")
+			return false
+		}
+		return true
+	}
+	if job.CursorTime == nil {
+		log.Info().Int("calls", job.TotalCalls).Int("concurrency", job.Concurrency).Msg("re-transcription job started")
+	}
+
+	f := r.filter(job)
+	cursorTime, cursorCallID := job.CursorTime, job.CursorCallID
+	for {
+		calls, err := r.opts.DB.ListRetranscribeCalls(ctx, f, cursorTime, cursorCallID, retranscribePageSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Warn().Err(err).Msg("failed to list calls for re-transcription")
+			}
+			return false
+		}
+		if len(calls) == 0 {
+			if err := r.opts.DB.FinishRetranscribeJob(r.ctx, job.ID, database.RetranscribeCompleted, ""); err != nil {
+				log.Warn().Err(err).Msg("failed to update re-transcription job")
+				return false
+			}
+			log.Info().Msg("re-transcription job completed")
+			return true
+		}
+
+		failed, lastErr := r.runPage(ctx, job, provider, calls)
+		if ctx.Err() != nil {
+			// Cancelled, or stopping: then the page is redone on resume
+			return r.ctx.Err() == nil
+		}
+		last := calls[len(calls)-1]
+		status, err := r.opts.DB.AdvanceRetranscribeJob(r.ctx, job.ID, len(calls), failed, last.StartTime, last.CallID, lastErr)
+		if err != nil {
+			log.Warn().Err(err).Msg("failed to save re-transcription progress")
+			return false
+		}
+		if status != database.RetranscribeRunning {
+			log.Info().Str("status", status).Msg("re-transcription job stopped")
+			return true
+		}
+		cursorTime, cursorCallID = &last.StartTime, &last.CallID
+	}
+}
+
+// runPage re-transcribes calls with up to job.Concurrency in flight.
+// Returns the number that failed and the last error.
+func (r *Retranscriber) runPage(ctx context.Context, job *database.RetranscribeJob, provider Provider, calls []database.RetranscribeCall) (int, string) {
+	var (
+		mu      sync.Mutex
+		failed  int
+		lastErr string
+		wg      sync.WaitGroup
+	)
+	work := make(chan database.RetranscribeCall)
+	for i := 0; i < min(max(job.Concurrency, 1), r.opts.MaxConcurrency); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range work {
+				// A human-corrected primary transcript is never replaced
+				primary := job.MakePrimary && !c.HumanPrimary
+				err := r.opts.Pool.Retranscribe(ctx, retranscribeJob(c), provider, primary)
+				if err != nil && ctx.Err() == nil {
+					mu.Lock()
+					failed++
+					lastErr = fmt.Sprintf("call %d: %v", c.CallID, err)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+feed:
+	for _, c := range calls {
+		select {
+		case work <- c:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+	return failed, lastErr
+}
+
+func retranscribeJob(c database.RetranscribeCall) Job {
+	job := Job{
+		CallID:        c.CallID,
+		CallStartTime: c.StartTime,
+		SystemID:      c.SystemID,
+		Tgid:          c.Tgid,
+		AudioFilePath: c.AudioFilePath,
+		CallFilename:  c.CallFilename,
+		SrcList:       c.SrcList,
+		TgAlphaTag:    c.TgAlphaTag,
+		TgDescription: c.TgDescription,
+		TgTag:         c.TgTag,
+		TgGroup:       c.TgGroup,
+	}
+	if c.Duration != nil {
+		job.Duration = *c.Duration
+	}
+	return job
+}
@@ -0,0 +1,50 @@
+package transcribe
+
+import (
+	"testing"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+func TestParseCostRates(t *testing.T) {
+	rates, err := ParseCostRates(" deepinfra=0.0005, elevenlabs=0.0067,elevenlabs:scribe_v1=0.004 ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		provider, model string
+		want            float64
+		ok              bool
+	}{
+		{"deepinfra", "openai/whisper-large-v3", 0.0005, true},
+		{"elevenlabs", "scribe_v2", 0.0067, true},
+		{"elevenlabs", "scribe_v1", 0.004, true},
+		{"whisper", "large-v3", 0, false},
+	} {
+		got, ok := rates.PerMinute(tc.provider, tc.model)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("PerMinute(%q, %q) = %v, %v; want %v, %v", tc.provider, tc.model, got, ok, tc.want, tc.ok)
+		}
+	}
+
+	for _, s := range []string{"deepinfra", "=0.1", "deepinfra=cheap", "deepinfra=-1"} {
+		if _, err := ParseCostRates(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
+
+func TestRetranscribeJob(t *testing.T) {
+	dur := float32(12.5)
+	c := database.RetranscribeCall{CallTranscriptionInfo: database.CallTranscriptionInfo{
+		CallID: 42, SystemID: 1, Tgid: 9044, Duration: &dur, AudioFilePath: "1/2026/03/01/call.m4a", TgAlphaTag: "FD Dispatch",
+	}}
+	job := retranscribeJob(c)
+	if job.CallID != 42 || job.Tgid != 9044 || job.Duration != dur || job.AudioFilePath != c.AudioFilePath || job.TgAlphaTag != "FD Dispatch" {
+		t.Errorf("job = %+v", job)
+	}
+	c.Duration = nil
+	if job := retranscribeJob(c); job.Duration != 0 {
+		t.Errorf("nil duration: %v", job.Duration)
+	}
+}
@@ -0,0 +1,201 @@
+package transcribe
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/snarg/tr-engine/internal/audio"
+)
+
+// segmentSampleRate is the rate long calls are decoded at for chunking.
+// 16 kHz keeps wideband analog audio intact and is what Whisper resamples to.
+const segmentSampleRate = 16000
+
+// Chunk records one piece of a segmented transcription: where it lies in
+// the call's audio and what it contributed to the stitched transcript.
+type Chunk struct {
+	Index      int     `json:"index"`
+	Start      float64 `json:"start"` // seconds into the call audio
+	End        float64 `json:"end"`
+	ProviderMs int     `json:"provider_ms"`
+	Words      int     `json:"words"` // words kept after de-duplicating overlaps
+}
+
+// chunkResult is a provider response for the chunk [start, end).
+type chunkResult struct {
+	start, end float64
+	resp       *Response
+	providerMs int
+}
+
+// segmented reports whether a call of this duration is transcribed in chunks.
+func (wp *WorkerPool) segmented(duration float32) bool {
+	return wp.opts.SegmentLength > 0 && float64(duration) > wp.opts.MaxDuration
+}
+
+// segmentExtra is the time a segmented job gets beyond a whole-call job: one
+// provider timeout per extra chunk.
+func (wp *WorkerPool) segmentExtra(duration float32) time.Duration {
+	if !wp.segmented(duration) {
+		return 0
+	}
+	n := len(chunkBounds(float64(duration), wp.opts.SegmentLength, wp.opts.SegmentOverlap))
+	return time.Duration(n-1) * wp.opts.ProviderTimeout
+}
+
+// chunkBounds splits duration seconds into windows of length seconds, each
+// starting overlap seconds before the previous one ends. A last window that
+// would add no more than the overlap in new audio is folded into the one
+// before it.
+func chunkBounds(duration, length, overlap float64) [][2]float64 {
+	if length <= overlap {
+		overlap = 0
+	}
+	var out [][2]float64
+	for start := 0.0; ; start += length - overlap {
+		end := start + length
+		if end+overlap >= duration {
+			return append(out, [2]float64{start, duration})
+		}
+		out = append(out, [2]float64{start, end})
+	}
+}
+
+// transcribeSegments decodes the audio at path, transcribes it in
+// overlapping chunks and stitches the results into one response.
+func (wp *WorkerPool) transcribeSegments(ctx context.Context, provider Provider, path string, opts TranscribeOpts) (*Response, []Chunk, int, error) {
+	samples, err := audio.DecodeFile(ctx, path, segmentSampleRate)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("decode for segmenting: %w", err)
+	}
+	duration := float64(len(samples)) / segmentSampleRate
+	if duration == 0 {
+		return nil, nil, 0, fmt.Errorf("decode for segmenting: no audio")
+	}
+
+	var parts []chunkResult
+	providerMs := 0
+	for i, b := range chunkBounds(duration, wp.opts.SegmentLength, wp.opts.SegmentOverlap) {
+		lo, hi := int(b[0]*segmentSampleRate), int(b[1]*segmentSampleRate)
+		chunkPath, err := writeTempWAV(samples[lo:min(hi, len(samples))])
+		if err != nil {
+			return nil, nil, providerMs, fmt.Errorf("write chunk %d: %w", i, err)
+		}
+		start := time.Now()
+		resp, err := provider.Transcribe(ctx, chunkPath, opts)
+		ms := int(time.Since(start).Milliseconds())
+		os.Remove(chunkPath)
+		providerMs += ms
+		if err != nil {
+			return nil, nil, providerMs, fmt.Errorf("chunk %d (%.0fs-%.0fs): %w", i, b[0], b[1], err)
+		}
+		parts = append(parts, chunkResult{start: b[0], end: b[1], resp: resp, providerMs: ms})
+	}
+	resp, chunks := stitch(parts)
+	resp.Duration = duration
+	return resp, chunks, providerMs, nil
+}
+
+// writeTempWAV writes samples to a temp WAV file at segmentSampleRate.
+func writeTempWAV(samples []int16) (string, error) {
+	f, err := os.CreateTemp("", "tr-chunk-*.wav")
+	if err != nil {
+		return "", err
+	}
+	if err := audio.WriteWAV(f, samples, segmentSampleRate); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// stitch joins chunk transcripts into one response with word times relative
+// to the whole call. Where every chunk has word timestamps, each overlap is
+// cut at its midpoint: words starting before it come from the earlier chunk,
+// the rest from the later one. Otherwise the longest run of words ending
+// one chunk's text and starting the next's is dropped from the next.
+func stitch(parts []chunkResult) (*Response, []Chunk) {
+	out := &Response{}
+	chunks := make([]Chunk, len(parts))
+	timed := true
+	for i, p := range parts {
+		chunks[i] = Chunk{Index: i, Start: p.start, End: p.end, ProviderMs: p.providerMs}
+		if out.Language == "" {
+			out.Language = p.resp.Language
+		}
+		if len(p.resp.Words) == 0 && strings.TrimSpace(p.resp.Text) != "" {
+			timed = false
+		}
+	}
+
+	if timed {
+		var text []string
+		for i, p := range parts {
+			lo, hi := math.Inf(-1), math.Inf(1)
+			if i > 0 {
+				lo = (p.start + parts[i-1].end) / 2
+			}
+			if i+1 < len(parts) {
+				hi = (parts[i+1].start + p.end) / 2
+			}
+			for _, w := range p.resp.Words {
+				at := p.start + w.Start
+				if at < lo || at >= hi {
+					continue
+				}
+				out.Words = append(out.Words, Word{Word: w.Word, Start: at, End: p.start + w.End})
+				text = append(text, strings.TrimSpace(w.Word))
+				chunks[i].Words++
+			}
+		}
+		out.Text = strings.Join(text, " ")
+		return out, chunks
+	}
+
+	var words []string
+	for i, p := range parts {
+		next := strings.Fields(p.resp.Text)
+		if i > 0 {
+			next = next[overlapWords(words, next):]
+		}
+		words = append(words, next...)
+		chunks[i].Words = len(next)
+	}
+	out.Text = strings.Join(words, " ")
+	return out, chunks
+}
+
+// overlapWords returns how many leading words of next repeat the trailing
+// words of prev, ignoring case and punctuation. Runs shorter than two words
+// are not treated as overlap.
+func overlapWords(prev, next []string) int {
+	for k := min(len(prev), len(next), 50); k >= 2; k-- {
+		match := true
+		for j := 0; j < k; j++ {
+			if normWord(prev[len(prev)-k+j]) != normWord(next[j]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return k
+		}
+	}
+	return 0
+}
+
+func normWord(w string) string {
+	return strings.ToLower(strings.TrimFunc(w, func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSpace(r)
+	}))
+}
@@ -0,0 +1,114 @@
+package transcribe
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/audio"
+)
+
+func TestChunkBounds(t *testing.T) {
+	tests := []struct {
+		duration, length, overlap float64
+		want                      [][2]float64
+	}{
+		{90, 120, 5, [][2]float64{{0, 90}}},
+		{300, 120, 5, [][2]float64{{0, 120}, {115, 235}, {230, 300}}},
+		{238, 120, 5, [][2]float64{{0, 120}, {115, 238}}}, // 3s tail folded in
+		{240, 120, 0, [][2]float64{{0, 120}, {120, 240}}},
+	}
+	for _, tt := range tests {
+		got := chunkBounds(tt.duration, tt.length, tt.overlap)
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("chunkBounds(%v, %v, %v) = %v, want %v", tt.duration, tt.length, tt.overlap, got, tt.want)
+		}
+	}
+}
+
+func TestStitchTimed(t *testing.T) {
+	parts := []chunkResult{
+		{start: 0, end: 20, providerMs: 100, resp: &Response{Language: "en", Words: []Word{
+			{" engine", 1, 1.5}, {" five", 1.6, 2}, {" on", 17, 17.3}, {" scene", 17.5, 18}, {" command", 18.5, 19.5},
+		}}},
+		// The overlap [15, 20) is cut at 17.5: "on" comes from the first chunk, "scene" from the second.
+		{start: 15, end: 30, providerMs: 80, resp: &Response{Words: []Word{
+			{" on", 2, 2.3}, {" scene", 2.5, 3}, {" command", 3.5, 4.5}, {" established", 5, 6},
+		}}},
+	}
+	resp, chunks := stitch(parts)
+	if resp.Text != "engine five on scene command established" {
+		t.Errorf("text = %q", resp.Text)
+	}
+	if resp.Language != "en" || len(resp.Words) != 6 || resp.Words[5].Start != 20 {
+		t.Errorf("words = %+v", resp.Words)
+	}
+	if chunks[0].Words != 3 || chunks[1].Words != 3 || chunks[1].Start != 15 || chunks[1].ProviderMs != 80 {
+		t.Errorf("chunks = %+v", chunks)
+	}
+}
+
+func TestStitchText(t *testing.T) {
+	parts := []chunkResult{
+		{start: 0, end: 20, resp: &Response{Text: "Engine 5 on scene, establishing command."}},
+		{start: 15, end: 30, resp: &Response{Text: "establishing command. Need a second alarm."}},
+		{start: 25, end: 40, resp: &Response{Text: "copy that"}},
+	}
+	resp, chunks := stitch(parts)
+	if resp.Text != "Engine 5 on scene, establishing command. Need a second alarm. copy that" {
+		t.Errorf("text = %q", resp.Text)
+	}
+	if chunks[1].Words != 4 || chunks[2].Words != 2 {
+		t.Errorf("chunks = %+v", chunks)
+	}
+}
+
+// chunkProvider returns each chunk's length and order as its transcript.
+type chunkProvider struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *chunkProvider) Transcribe(ctx context.Context, path string, opts TranscribeOpts) (*Response, error) {
+	samples, err := audio.DecodeFile(ctx, path, segmentSampleRate)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	return &Response{Text: fmt.Sprintf("chunk %d of %ds", p.calls, len(samples)/segmentSampleRate)}, nil
+}
+func (p *chunkProvider) Name() string  { return "test" }
+func (p *chunkProvider) Model() string { return "test" }
+
+func TestTranscribeSegments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "call.wav")
+	f, _ := os.Create(path)
+	audio.WriteWAV(f, make([]int16, 50*segmentSampleRate), segmentSampleRate)
+	f.Close()
+
+	wp := NewWorkerPool(WorkerPoolOptions{
+		MaxDuration: 30, SegmentLength: 20, SegmentOverlap: 2, SegmentMaxDuration: 3600,
+		Log: zerolog.Nop(),
+	})
+	if !wp.segmented(50) || wp.segmented(30) || wp.MaxDuration() != 3600 {
+		t.Fatal("segmenting not enabled for long calls only")
+	}
+	p := &chunkProvider{}
+	resp, chunks, _, err := wp.transcribeSegments(context.Background(), p, path, TranscribeOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 3 || resp.Duration != 50 {
+		t.Fatalf("chunks = %+v, duration %v", chunks, resp.Duration)
+	}
+	if resp.Text != "chunk 1 of 20s chunk 2 of 20s chunk 3 of 14s" {
+		t.Errorf("text = %q", resp.Text)
+	}
+}
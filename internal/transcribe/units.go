@@ -32,9 +32,11 @@ type Segment struct {
 }
 
 // TranscriptionWords is the structure stored in the transcriptions.words JSONB column.
+// Chunks is set for long calls transcribed in segments.
 type TranscriptionWords struct {
 	Words    []AttributedWord `json:"words"`
 	Segments []Segment        `json:"segments"`
+	Chunks   []Chunk          `json:"chunks,omitempty"`
 }
 
 // ParseSrcList parses the src_list JSONB from a call record into Transmission entries.
@@ -0,0 +1,316 @@
+package transcribe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/snarg/tr-engine/internal/metrics"
+)
+
+// Stuck-job watchdog.
+//
+// Every job a worker picks up is tracked in flight with a deadline. A job
+// still running past its deadline (a provider that ignores its context, a
+// hung preprocessing step) is abandoned: its context is cancelled, a
+// replacement worker is started so the pool keeps its size, and the job is
+// re-queued up to MaxRetries times before it lands on the dead-letter list.
+// A worker that panics is recovered the same way. When the abandoned worker
+// eventually returns, its result is discarded and it exits.
+
+// watchdogInterval is how often in-flight jobs are checked.
+var watchdogInterval = 10 * time.Second
+
+// deadLetterSize bounds the dead-letter list; the oldest entries are dropped.
+const deadLetterSize = 200
+
+const (
+	deadlineGrace      = 30 * time.Second // added to the job context timeout
+	defaultJobDeadline = time.Minute      // when no ProviderTimeout is set
+)
+
+// InFlightJob describes a job a worker is processing.
+type InFlightJob struct {
+	CallID   int64     `json:"call_id"`
+	SystemID int       `json:"system_id"`
+	Tgid     int       `json:"tgid"`
+	Worker   int       `json:"worker"`
+	Attempt  int       `json:"attempt"` // 0 = first try
+	Started  time.Time `json:"started"`
+	Deadline time.Time `json:"deadline"`
+}
+
+// DeadLetter is a job given up on after repeated stuck or panicked attempts.
+type DeadLetter struct {
+	CallID        int64     `json:"call_id"`
+	CallStartTime time.Time `json:"call_start_time"`
+	SystemID      int       `json:"system_id"`
+	Tgid          int       `json:"tgid"`
+	Attempts      int       `json:"attempts"`
+	Reason        string    `json:"reason"` // stuck, panic, queue_full, stopped
+	Error         string    `json:"error,omitempty"`
+	Time          time.Time `json:"time"`
+}
+
+// inflight is the tracking record for a job being processed.
+type inflight struct {
+	id        uint64
+	job       Job
+	slot      *workerSlot
+	started   time.Time
+	deadline  time.Time
+	cancel    context.CancelFunc
+	abandoned bool // guarded by WorkerPool.mu
+}
+
+// workerSlot is one worker's share of the pool WaitGroup. Released exactly
+// once: by the worker when it exits, or by the watchdog when it abandons the
+// worker's job.
+type workerSlot struct {
+	id       int
+	released atomic.Bool
+}
+
+func (wp *WorkerPool) release(s *workerSlot) {
+	if s.released.CompareAndSwap(false, true) {
+		wp.wg.Done()
+	}
+}
+
+// deadLetters is a bounded list of dead-lettered jobs, at most one per call.
+type deadLetters struct {
+	mu    sync.Mutex
+	items []DeadLetter
+}
+
+func (d *deadLetters) add(dl DeadLetter) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.removeLocked(dl.CallID)
+	d.items = append(d.items, dl)
+	if len(d.items) > deadLetterSize {
+		d.items = d.items[len(d.items)-deadLetterSize:]
+	}
+}
+
+func (d *deadLetters) remove(callID int64) {
+	d.mu.Lock()
+	d.removeLocked(callID)
+	d.mu.Unlock()
+}
+
+func (d *deadLetters) removeLocked(callID int64) {
+	for i, dl := range d.items {
+		if dl.CallID == callID {
+			d.items = append(d.items[:i], d.items[i+1:]...)
+			return
+		}
+	}
+}
+
+// snapshot returns the list newest first.
+func (d *deadLetters) snapshot() []DeadLetter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]DeadLetter, len(d.items))
+	for i, dl := range d.items {
+		out[len(out)-1-i] = dl
+	}
+	return out
+}
+
+// jobDeadline is how long a job may run before the watchdog abandons it.
+func (wp *WorkerPool) jobDeadline() time.Duration {
+	if wp.opts.JobDeadline > 0 {
+		return wp.opts.JobDeadline
+	}
+	if wp.opts.ProviderTimeout > 0 {
+		return wp.opts.ProviderTimeout + 10*time.Second + deadlineGrace
+	}
+	return defaultJobDeadline
+}
+
+// begin registers job as in flight on slot and returns its tracking record,
+// whose context the job must run under.
+func (wp *WorkerPool) begin(slot *workerSlot, job Job) (*inflight, context.Context) {
+	// Segmented jobs get a provider timeout per extra chunk.
+	extra := wp.segmentExtra(job.Duration)
+	ctx, cancel := context.WithTimeout(wp.ctx, wp.opts.ProviderTimeout+10*time.Second+extra)
+	now := time.Now()
+	f := &inflight{
+		job:      job,
+		slot:     slot,
+		started:  now,
+		deadline: now.Add(wp.jobDeadline() + extra),
+		cancel:   cancel,
+	}
+	wp.mu.Lock()
+	wp.nextJobID++
+	f.id = wp.nextJobID
+	wp.inflight[f.id] = f
+	wp.mu.Unlock()
+	return f, ctx
+}
+
+// finish removes f from the in-flight set. Returns false if the watchdog
+// already abandoned it, in which case the result must be discarded.
+func (wp *WorkerPool) finish(f *inflight) bool {
+	wp.mu.Lock()
+	abandoned := f.abandoned
+	delete(wp.inflight, f.id)
+	wp.mu.Unlock()
+	f.cancel()
+	return !abandoned
+}
+
+// errWorkerPanic wraps the error runJob returns for a panicked job.
+var errWorkerPanic = errors.New("worker panic")
+
+// runJob runs processJob, converting a panic into an errWorkerPanic error.
+func (wp *WorkerPool) runJob(ctx context.Context, log zerolog.Logger, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().
+				Int64("call_id", job.CallID).
+				Int("tgid", job.Tgid).
+				Interface("panic", r).
+				Bytes("stack", debug.Stack()).
+				Msg("transcription worker panicked")
+			err = fmt.Errorf("%w: %v", errWorkerPanic, r)
+		}
+	}()
+	return wp.processJob(ctx, log, job)
+}
+
+// watchdog periodically abandons jobs past their deadline until stop closes.
+func (wp *WorkerPool) watchdog(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			wp.checkStuck(now)
+		}
+	}
+}
+
+// checkStuck abandons every in-flight job past its deadline at now and
+// returns how many it abandoned.
+func (wp *WorkerPool) checkStuck(now time.Time) int {
+	wp.mu.Lock()
+	var stuck []*inflight
+	for id, f := range wp.inflight {
+		if now.After(f.deadline) {
+			f.abandoned = true
+			delete(wp.inflight, id)
+			stuck = append(stuck, f)
+		}
+	}
+	wp.mu.Unlock()
+
+	for _, f := range stuck {
+		f.cancel()
+		wp.log.Warn().
+			Int64("call_id", f.job.CallID).
+			Int("tgid", f.job.Tgid).
+			Int("worker", f.slot.id).
+			Dur("running", now.Sub(f.started)).
+			Msg("transcription job stuck past deadline, abandoning worker")
+		wp.replaceWorker(f.slot)
+		wp.retry(f.job, "stuck", fmt.Errorf("no result after %s", now.Sub(f.started).Round(time.Second)))
+	}
+	return len(stuck)
+}
+
+// replaceWorker releases a stuck worker's slot and starts a new worker in
+// its place, unless the pool is stopping.
+func (wp *WorkerPool) replaceWorker(old *workerSlot) {
+	wp.closeMu.RLock()
+	defer wp.closeMu.RUnlock()
+	if !wp.stopped.Load() {
+		wp.wg.Add(1)
+		go wp.worker(&workerSlot{id: int(wp.nextWorker.Add(1))})
+	}
+	wp.release(old)
+}
+
+// retry re-queues a job that got stuck or panicked, or dead-letters it once
+// it has used up MaxRetries.
+func (wp *WorkerPool) retry(job Job, reason string, cause error) {
+	dl := DeadLetter{
+		CallID:        job.CallID,
+		CallStartTime: job.CallStartTime,
+		SystemID:      job.SystemID,
+		Tgid:          job.Tgid,
+		Attempts:      job.Attempt + 1,
+		Reason:        reason,
+		Time:          time.Now(),
+	}
+	if cause != nil {
+		dl.Error = cause.Error()
+	}
+
+	if job.Attempt < wp.maxRetries() {
+		job.Attempt++
+		if wp.Enqueue(job) {
+			wp.requeued.Add(1)
+			metrics.TranscriptionJobsRecoveredTotal.WithLabelValues(reason, "requeued").Inc()
+			wp.log.Info().Int64("call_id", job.CallID).Int("attempt", job.Attempt).Str("reason", reason).
+				Msg("transcription job re-queued")
+			return
+		}
+		if wp.stopped.Load() {
+			dl.Reason = "stopped"
+		} else {
+			dl.Reason = "queue_full"
+		}
+	}
+
+	wp.dead.add(dl)
+	wp.deadLettered.Add(1)
+	metrics.TranscriptionJobsRecoveredTotal.WithLabelValues(reason, "dead_lettered").Inc()
+	wp.log.Error().Int64("call_id", job.CallID).Int("attempts", dl.Attempts).Str("reason", dl.Reason).
+		Msg("transcription job dead-lettered")
+}
+
+func (wp *WorkerPool) maxRetries() int {
+	if wp.opts.MaxRetries < 0 {
+		return 0
+	}
+	return wp.opts.MaxRetries
+}
+
+// InFlight returns the jobs currently being processed, oldest first.
+func (wp *WorkerPool) InFlight() []InFlightJob {
+	wp.mu.Lock()
+	out := make([]InFlightJob, 0, len(wp.inflight))
+	for _, f := range wp.inflight {
+		out = append(out, InFlightJob{
+			CallID:   f.job.CallID,
+			SystemID: f.job.SystemID,
+			Tgid:     f.job.Tgid,
+			Worker:   f.slot.id,
+			Attempt:  f.job.Attempt,
+			Started:  f.started,
+			Deadline: f.deadline,
+		})
+	}
+	wp.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Started.Before(out[j].Started) })
+	return out
+}
+
+// DeadLetters returns dead-lettered jobs, newest first. The list is kept in
+// memory only; a call that later transcribes successfully is removed.
+func (wp *WorkerPool) DeadLetters() []DeadLetter {
+	return wp.dead.snapshot()
+}
@@ -0,0 +1,122 @@
+package transcribe
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// scriptedProvider blocks on its first call (ignoring ctx) until release is
+// closed, panics on its second, and returns empty text after that.
+type scriptedProvider struct {
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (p *scriptedProvider) Transcribe(ctx context.Context, path string, opts TranscribeOpts) (*Response, error) {
+	switch p.calls.Add(1) {
+	case 1:
+		<-p.release
+	case 2:
+		panic("decoder exploded")
+	}
+	return &Response{}, nil
+}
+
+func (p *scriptedProvider) Name() string  { return "scripted" }
+func (p *scriptedProvider) Model() string { return "test" }
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWatchdogStuckAndPanic(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "call.wav"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	prov := &scriptedProvider{release: make(chan struct{})}
+	wp := NewWorkerPool(WorkerPoolOptions{
+		AudioDir:    dir,
+		Provider:    prov,
+		Workers:     1,
+		QueueSize:   10,
+		JobDeadline: time.Minute,
+		MaxRetries:  1,
+		Log:         zerolog.Nop(),
+	})
+	wp.Start()
+	job := Job{CallID: 42, Tgid: 100, AudioFilePath: "call.wav"}
+	wp.Enqueue(job)
+
+	waitFor(t, "job in flight", func() bool { return len(wp.InFlight()) == 1 })
+	if n := wp.checkStuck(time.Now()); n != 0 {
+		t.Fatalf("abandoned %d jobs before their deadline", n)
+	}
+	if n := wp.checkStuck(time.Now().Add(2 * time.Minute)); n != 1 {
+		t.Fatalf("abandoned %d jobs, want 1", n)
+	}
+
+	// The replacement worker retries it; the provider panics, and with
+	// MaxRetries=1 the job is dead-lettered.
+	waitFor(t, "dead letter", func() bool { return len(wp.DeadLetters()) == 1 })
+	dl := wp.DeadLetters()[0]
+	if dl.CallID != 42 || dl.Attempts != 2 || dl.Reason != "panic" {
+		t.Errorf("dead letter = %+v", dl)
+	}
+	stats := wp.Stats()
+	if stats.Requeued != 1 || stats.DeadLettered != 1 || stats.InFlight != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	// The stuck worker's late result is dropped and it exits.
+	close(prov.release)
+
+	// A later successful run clears the dead letter.
+	wp.Enqueue(job)
+	waitFor(t, "dead letter cleared", func() bool { return len(wp.DeadLetters()) == 0 })
+	if got := wp.Stats().Completed; got != 1 {
+		t.Errorf("completed = %d, want 1", got)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wp.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop() did not return")
+	}
+}
+
+func TestDeadLettersBounded(t *testing.T) {
+	var d deadLetters
+	for i := 0; i < deadLetterSize+5; i++ {
+		d.add(DeadLetter{CallID: int64(i)})
+	}
+	d.add(DeadLetter{CallID: 10, Reason: "stuck"})
+	got := d.snapshot()
+	if len(got) != deadLetterSize {
+		t.Fatalf("len = %d, want %d", len(got), deadLetterSize)
+	}
+	if got[0].CallID != 10 || got[0].Reason != "stuck" {
+		t.Errorf("newest = %+v", got[0])
+	}
+	if got[len(got)-1].CallID != 5 {
+		t.Errorf("oldest = %+v (entries 0-4 should be dropped)", got[len(got)-1])
+	}
+}
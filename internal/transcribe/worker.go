@@ -3,18 +3,22 @@ package transcribe
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"text/template"
 	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/snarg/tr-engine/internal/audio"
 	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/metrics"
 	"github.com/snarg/tr-engine/internal/storage"
+	"github.com/snarg/tr-engine/pkg/events"
 )
 
 // Job represents a transcription job enqueued by the ingest pipeline.
@@ -26,18 +30,27 @@ type Job struct {
 	Duration      float32
 	AudioFilePath string          // relative path from audioDir
 	CallFilename  string          // TR's absolute path
+	Audio         []byte          // audio held in memory, for systems that store none (ingest policy transcript)
+	AudioType     string          // Audio's format, e.g. "m4a"
 	SrcList       json.RawMessage // for unit attribution
 	TgAlphaTag    string
 	TgDescription string
 	TgTag         string
 	TgGroup       string
+	Attempt       int      // retries after a stuck or panicked worker; 0 = first try
+	Emergency     bool     // queued ahead of other jobs; latency tracked in EmergencyLatency
+	Temperature   *float64 // overrides WHISPER_TEMPERATURE for this call
 }
 
 // QueueStats reports the current state of the transcription queue.
 type QueueStats struct {
-	Pending   int   `json:"pending"`
-	Completed int64 `json:"completed"`
-	Failed    int64 `json:"failed"`
+	Pending          int   `json:"pending"`
+	PendingEmergency int   `json:"pending_emergency"` // of Pending, emergency jobs
+	InFlight         int   `json:"in_flight"`
+	Completed        int64 `json:"completed"`
+	Failed           int64 `json:"failed"`
+	Requeued         int64 `json:"requeued"`
+	DeadLettered     int64 `json:"dead_lettered"`
 }
 
 // ProviderPerformance reports aggregate STT provider performance.
@@ -159,7 +172,7 @@ func (r *perfRing) performance() *ProviderPerformance {
 }
 
 // EventPublishFunc is a callback for publishing SSE events.
-type EventPublishFunc func(eventType string, systemID, tgid int, payload map[string]any)
+type EventPublishFunc func(eventType string, systemID, tgid int, payload any)
 
 // WorkerPoolOptions configures the transcription worker pool.
 type WorkerPoolOptions struct {
@@ -173,6 +186,16 @@ type WorkerPoolOptions struct {
 	Language        string
 	Prompt          string
 	Hotwords        string
+
+	// PromptAuto builds each job's prompt from PromptTemplate (default
+	// DefaultPromptTemplate) with the talkgroup, the units on the call and
+	// up to PromptContext transcripts from the talkgroup within
+	// PromptContextWindow. Per-talkgroup overrides apply either way.
+	PromptAuto          bool
+	PromptTemplate      string
+	PromptContext       int
+	PromptContextWindow time.Duration
+
 	BeamSize        int
 	PreprocessAudio bool
 	Workers         int
@@ -180,7 +203,25 @@ type WorkerPoolOptions struct {
 	MinDuration     float64
 	MaxDuration     float64
 	PublishEvent    EventPublishFunc
-	Log             zerolog.Logger
+
+	// SegmentLength enables segmented transcription of calls longer than
+	// MaxDuration, up to SegmentMaxDuration: the audio is decoded, split
+	// into SegmentLength-second chunks overlapping by SegmentOverlap seconds,
+	// and the chunk transcripts stitched into one. 0 skips longer calls.
+	SegmentLength      float64
+	SegmentOverlap     float64
+	SegmentMaxDuration float64
+
+	// JobDeadline is how long a job may run before the watchdog abandons
+	// it and re-queues it (default ProviderTimeout + 40s). MaxRetries bounds
+	// re-queues of stuck or panicked jobs before they are dead-lettered.
+	JobDeadline time.Duration
+	MaxRetries  int
+	Log         zerolog.Logger
+
+	// Classifier scores each transcript for urgency before it is stored.
+	// nil disables classification.
+	Classifier Classifier
 
 	// Anti-hallucination (Whisper-specific; ignored by other providers)
 	RepetitionPenalty             float64
@@ -195,6 +236,7 @@ type WorkerPoolOptions struct {
 // WorkerPool manages transcription workers.
 type WorkerPool struct {
 	jobs     chan Job
+	urgent   chan Job // emergency jobs, taken before jobs
 	db       *database.DB
 	provider Provider
 	opts     WorkerPoolOptions
@@ -203,23 +245,49 @@ type WorkerPool struct {
 	cancel   context.CancelFunc
 	wg       sync.WaitGroup
 
+	closeMu   sync.RWMutex // held for writing while closing jobs
 	stopped   atomic.Bool
 	completed atomic.Int64
 	failed    atomic.Int64
 	perf      perfRing
+
+	promptTmpl *template.Template
+	overrides  sync.Map // override prompt text -> *template.Template
+
+	// Watchdog state (see watchdog.go)
+	mu           sync.Mutex
+	inflight     map[uint64]*inflight
+	nextJobID    uint64
+	nextWorker   atomic.Int32
+	requeued     atomic.Int64
+	deadLettered atomic.Int64
+	dead         deadLetters
+	watchdogStop chan struct{}
+	watchdogDone chan struct{}
 }
 
 // NewWorkerPool creates a new transcription worker pool.
 func NewWorkerPool(opts WorkerPoolOptions) *WorkerPool {
 	ctx, cancel := context.WithCancel(context.Background())
+	if opts.PromptTemplate == "" {
+		opts.PromptTemplate = DefaultPromptTemplate
+	}
+	tmpl, err := ParsePromptTemplate(opts.PromptTemplate)
+	if err != nil {
+		opts.Log.Warn().Err(err).Msg("invalid prompt template, using default")
+		tmpl, _ = ParsePromptTemplate(DefaultPromptTemplate)
+	}
 	return &WorkerPool{
-		jobs:     make(chan Job, opts.QueueSize),
-		db:       opts.DB,
-		provider: opts.Provider,
-		opts:     opts,
-		log:      opts.Log,
-		ctx:      ctx,
-		cancel:   cancel,
+		jobs:       make(chan Job, opts.QueueSize),
+		urgent:     make(chan Job, opts.QueueSize),
+		db:         opts.DB,
+		provider:   opts.Provider,
+		opts:       opts,
+		log:        opts.Log,
+		ctx:        ctx,
+		cancel:     cancel,
+		inflight:   make(map[uint64]*inflight),
+		promptTmpl: tmpl,
 	}
 }
 
@@ -236,16 +304,33 @@ func (wp *WorkerPool) Start() {
 
 	for i := 0; i < wp.opts.Workers; i++ {
 		wp.wg.Add(1)
-		go wp.worker(i)
+		go wp.worker(&workerSlot{id: i})
 	}
-	wp.log.Info().Int("workers", wp.opts.Workers).Int("queue_size", wp.opts.QueueSize).Msg("transcription worker pool started")
+	wp.nextWorker.Store(int32(wp.opts.Workers - 1))
+	wp.watchdogStop = make(chan struct{})
+	wp.watchdogDone = make(chan struct{})
+	go wp.watchdog(wp.watchdogStop, wp.watchdogDone)
+	wp.log.Info().
+		Int("workers", wp.opts.Workers).
+		Int("queue_size", wp.opts.QueueSize).
+		Dur("job_deadline", wp.jobDeadline()).
+		Msg("transcription worker pool started")
 }
 
-// Stop signals workers to drain and waits for completion.
+// Stop signals workers to drain and waits for completion. The watchdog keeps
+// running while draining so a stuck worker can't hold up shutdown past its
+// job deadline.
 func (wp *WorkerPool) Stop() {
+	wp.closeMu.Lock()
 	wp.stopped.Store(true)
 	close(wp.jobs)
+	close(wp.urgent)
+	wp.closeMu.Unlock()
 	wp.wg.Wait()
+	if wp.watchdogStop != nil {
+		close(wp.watchdogStop)
+		<-wp.watchdogDone
+	}
 	wp.cancel()
 	wp.log.Info().
 		Int64("completed", wp.completed.Load()).
@@ -253,26 +338,64 @@ func (wp *WorkerPool) Stop() {
 		Msg("transcription worker pool stopped")
 }
 
-// Enqueue adds a job to the transcription queue. Returns false if the queue is full
-// or the pool has been stopped.
+// Enqueue adds a job to the transcription queue. Emergency jobs go to the
+// front: workers take them before any other pending job. Returns false if the
+// queue is full or the pool has been stopped.
 func (wp *WorkerPool) Enqueue(j Job) bool {
+	wp.closeMu.RLock()
+	defer wp.closeMu.RUnlock()
 	if wp.stopped.Load() {
 		return false
 	}
+	queue := wp.jobs
+	if j.Emergency {
+		queue = wp.urgent
+	}
 	select {
-	case wp.jobs <- j:
+	case queue <- j:
 		return true
 	default:
 		return false
 	}
 }
 
+// next returns the next job, emergency jobs first. ok is false once both
+// queues are closed and drained.
+func (wp *WorkerPool) next() (job Job, ok bool) {
+	select {
+	case job, ok = <-wp.urgent:
+		if ok {
+			return job, true
+		}
+	default:
+	}
+	select {
+	case job, ok = <-wp.urgent:
+		if ok {
+			return job, true
+		}
+		job, ok = <-wp.jobs
+	case job, ok = <-wp.jobs:
+		if !ok {
+			job, ok = <-wp.urgent
+		}
+	}
+	return job, ok
+}
+
 // Stats returns current queue statistics.
 func (wp *WorkerPool) Stats() QueueStats {
+	wp.mu.Lock()
+	inFlight := len(wp.inflight)
+	wp.mu.Unlock()
 	return QueueStats{
-		Pending:   len(wp.jobs),
-		Completed: wp.completed.Load(),
-		Failed:    wp.failed.Load(),
+		Pending:          len(wp.jobs) + len(wp.urgent),
+		PendingEmergency: len(wp.urgent),
+		InFlight:         inFlight,
+		Completed:        wp.completed.Load(),
+		Failed:           wp.failed.Load(),
+		Requeued:         wp.requeued.Load(),
+		DeadLettered:     wp.deadLettered.Load(),
 	}
 }
 
@@ -284,41 +407,115 @@ func (wp *WorkerPool) Performance() *ProviderPerformance {
 // MinDuration returns the minimum call duration for transcription.
 func (wp *WorkerPool) MinDuration() float64 { return wp.opts.MinDuration }
 
-// MaxDuration returns the maximum call duration for transcription.
-func (wp *WorkerPool) MaxDuration() float64 { return wp.opts.MaxDuration }
+// MaxDuration returns the maximum call duration for transcription: the
+// segmented limit when long calls are transcribed in chunks.
+func (wp *WorkerPool) MaxDuration() float64 {
+	if wp.opts.SegmentLength > 0 && wp.opts.SegmentMaxDuration > wp.opts.MaxDuration {
+		return wp.opts.SegmentMaxDuration
+	}
+	return wp.opts.MaxDuration
+}
 
 // Model returns the configured STT model name.
 func (wp *WorkerPool) Model() string { return wp.provider.Model() }
 
+// ProviderName returns the configured STT provider's name.
+func (wp *WorkerPool) ProviderName() string { return wp.provider.Name() }
+
 // Workers returns the number of worker goroutines.
 func (wp *WorkerPool) Workers() int { return wp.opts.Workers }
 
-func (wp *WorkerPool) worker(id int) {
-	defer wp.wg.Done()
-	log := wp.log.With().Int("worker", id).Logger()
+func (wp *WorkerPool) worker(slot *workerSlot) {
+	defer wp.release(slot)
+	log := wp.log.With().Int("worker", slot.id).Logger()
 
-	for job := range wp.jobs {
-		if err := wp.processJob(log, job); err != nil {
+	for {
+		job, ok := wp.next()
+		if !ok {
+			return
+		}
+		f, ctx := wp.begin(slot, job)
+		err := wp.runJob(ctx, log, job)
+		if !wp.finish(f) {
+			// The watchdog gave up on this job and started a replacement
+			// worker; drop the late result and exit.
+			log.Warn().Err(err).Int64("call_id", job.CallID).Msg("abandoned transcription job returned, worker exiting")
+			return
+		}
+		switch {
+		case errors.Is(err, errWorkerPanic):
+			wp.failed.Add(1)
+			wp.retry(job, "panic", err)
+		case err != nil:
 			wp.failed.Add(1)
 			log.Warn().Err(err).
 				Int64("call_id", job.CallID).
 				Int("tgid", job.Tgid).
 				Msg("transcription failed")
-		} else {
+		default:
 			wp.completed.Add(1)
+			wp.dead.remove(job.CallID)
+			if job.Emergency {
+				audioEnd := job.CallStartTime.Add(time.Duration(job.Duration * float32(time.Second)))
+				metrics.EmergencyLatency.WithLabelValues("transcription").Observe(time.Since(audioEnd).Seconds())
+			}
 		}
 	}
 }
 
-func (wp *WorkerPool) processJob(log zerolog.Logger, job Job) error {
-	start := time.Now()
-	ctx, cancel := context.WithTimeout(wp.ctx, wp.opts.ProviderTimeout+10*time.Second)
+// writeTempAudio writes in-memory audio to a temp file named for its type,
+// which providers use to detect the format.
+func writeTempAudio(data []byte, audioType string) (string, error) {
+	if audioType == "" {
+		audioType = "m4a"
+	}
+	f, err := os.CreateTemp("", "tr-audio-*."+audioType)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func (wp *WorkerPool) processJob(ctx context.Context, log zerolog.Logger, job Job) error {
+	return wp.transcribe(ctx, log, job, wp.provider, true)
+}
+
+// Retranscribe transcribes a call with provider outside the queue (see
+// Retranscriber), using the pool's audio resolution, prompt and decoding
+// options. A non-primary result is stored as a variant alongside the current
+// primary transcript and publishes no transcription event.
+func (wp *WorkerPool) Retranscribe(ctx context.Context, job Job, provider Provider, primary bool) error {
+	ctx, cancel := context.WithTimeout(ctx, wp.jobDeadline()+wp.segmentExtra(job.Duration))
 	defer cancel()
+	log := wp.log.With().Int64("call_id", job.CallID).Str("provider", provider.Name()).Logger()
+	return wp.transcribe(ctx, log, job, provider, primary)
+}
+
+// transcribe runs one call through provider and stores the result.
+func (wp *WorkerPool) transcribe(ctx context.Context, log zerolog.Logger, job Job, provider Provider, primary bool) error {
+	start := time.Now()
 
 	// 1. Resolve audio file
 	var audioPath string
 
-	if wp.opts.Store != nil && job.AudioFilePath != "" {
+	if len(job.Audio) > 0 {
+		// Never stored: write to a temp file for preprocessing/STT
+		tmp, err := writeTempAudio(job.Audio, job.AudioType)
+		if err != nil {
+			return errorf("write audio to temp: %w", err)
+		}
+		audioPath = tmp
+		defer os.Remove(audioPath)
+	} else if wp.opts.Store != nil && job.AudioFilePath != "" {
 		// Use storage abstraction — tries local cache first, then S3
 		if localPath := wp.opts.Store.LocalPath(job.AudioFilePath); localPath != "" {
 			audioPath = localPath
@@ -362,12 +559,12 @@ func (wp *WorkerPool) processJob(log zerolog.Logger, job Job) error {
 		}
 	}
 
-	// 3. Send to STT provider
-	providerStart := time.Now()
-	resp, err := wp.provider.Transcribe(ctx, transcribePath, TranscribeOpts{
+	// 3. Send to STT provider, in chunks for long calls
+	prompt := wp.jobPrompt(ctx, log, job)
+	sttOpts := TranscribeOpts{
 		Temperature:                   wp.opts.Temperature,
 		Language:                      wp.opts.Language,
-		Prompt:                        wp.opts.Prompt,
+		Prompt:                        prompt,
 		Hotwords:                      wp.opts.Hotwords,
 		BeamSize:                      wp.opts.BeamSize,
 		RepetitionPenalty:             wp.opts.RepetitionPenalty,
@@ -377,10 +574,23 @@ func (wp *WorkerPool) processJob(log zerolog.Logger, job Job) error {
 		HallucinationSilenceThreshold: wp.opts.HallucinationSilenceThreshold,
 		MaxNewTokens:                  wp.opts.MaxNewTokens,
 		VadFilter:                     wp.opts.VadFilter,
-	})
-	providerMs := int(time.Since(providerStart).Milliseconds())
+	}
+	if job.Temperature != nil {
+		sttOpts.Temperature = *job.Temperature
+	}
+	var resp *Response
+	var chunks []Chunk
+	var providerMs int
+	var err error
+	if wp.segmented(job.Duration) {
+		resp, chunks, providerMs, err = wp.transcribeSegments(ctx, provider, transcribePath, sttOpts)
+	} else {
+		providerStart := time.Now()
+		resp, err = provider.Transcribe(ctx, transcribePath, sttOpts)
+		providerMs = int(time.Since(providerStart).Milliseconds())
+	}
 	if err != nil {
-		return errorf("%s: %w", wp.provider.Name(), err)
+		return errorf("%s: %w", provider.Name(), err)
 	}
 
 	text := strings.TrimSpace(resp.Text)
@@ -396,6 +606,7 @@ func (wp *WorkerPool) processJob(log zerolog.Logger, job Job) error {
 	}
 	transmissions := ParseSrcList(job.SrcList, totalDuration)
 	tw := AttributeWords(resp.Words, transmissions, text)
+	tw.Chunks = chunks
 
 	wordsJSON, err := json.Marshal(tw)
 	if err != nil {
@@ -408,22 +619,32 @@ func (wp *WorkerPool) processJob(log zerolog.Logger, job Job) error {
 		wordCount = len(strings.Fields(text))
 	}
 
+	// 5. Urgency classification (optional)
+	urgency := wp.Classify(ctx, ClassifyInput{
+		Text:       text,
+		SystemID:   job.SystemID,
+		Tgid:       job.Tgid,
+		TgAlphaTag: job.TgAlphaTag,
+		TgTag:      job.TgTag,
+	})
+
 	durationMs := int(time.Since(start).Milliseconds())
 
-	// 5. Store in DB
+	// 6. Store in DB
 	row := &database.TranscriptionRow{
 		CallID:        job.CallID,
 		CallStartTime: job.CallStartTime,
 		Text:          text,
 		Source:        "auto",
-		IsPrimary:     true,
+		IsPrimary:     primary,
 		Language:      resp.Language,
-		Model:         wp.provider.Model(),
-		Provider:      wp.provider.Name(),
+		Model:         provider.Model(),
+		Provider:      provider.Name(),
 		WordCount:     wordCount,
 		DurationMs:    durationMs,
 		ProviderMs:    &providerMs,
 		Words:         wordsJSON,
+		Urgency:       urgency,
 	}
 
 	_, err = wp.db.InsertTranscription(ctx, row)
@@ -435,25 +656,34 @@ func (wp *WorkerPool) processJob(log zerolog.Logger, job Job) error {
 	wp.perf.push(completionRecord{
 		providerMs:   int64(providerMs),
 		callDuration: job.Duration,
-		provider:     wp.provider.Name(),
-		model:        wp.provider.Model(),
+		provider:     provider.Name(),
+		model:        provider.Model(),
 	})
 
-	// 6. Publish SSE event
-	if wp.opts.PublishEvent != nil {
-		payload := map[string]any{
-			"call_id":     job.CallID,
-			"system_id":   job.SystemID,
-			"tgid":        job.Tgid,
-			"text":        text,
-			"word_count":  wordCount,
-			"segments":    len(tw.Segments),
-			"model":       wp.provider.Model(),
-			"duration_ms": durationMs,
-			"provider_ms": providerMs,
+	// 7. Publish SSE event
+	if wp.opts.PublishEvent != nil && primary {
+		payload := &events.Transcription{
+			CallID:     job.CallID,
+			SystemID:   job.SystemID,
+			Tgid:       job.Tgid,
+			StartTime:  job.CallStartTime,
+			Text:       text,
+			WordCount:  wordCount,
+			Segments:   len(tw.Segments),
+			Model:      provider.Model(),
+			DurationMs: durationMs,
+			ProviderMs: providerMs,
+		}
+		if urgency != nil {
+			payload.Urgency = &events.Urgency{
+				Score:      urgency.Score,
+				Label:      urgency.Label,
+				Signals:    urgency.Signals,
+				Classifier: urgency.Classifier,
+			}
 		}
 		if job.Duration > 0 {
-			payload["real_time_ratio"] = float64(providerMs) / (float64(job.Duration) * 1000)
+			payload.RealTimeRatio = float64(providerMs) / (float64(job.Duration) * 1000)
 		}
 		wp.opts.PublishEvent("transcription", job.SystemID, job.Tgid, payload)
 	}
@@ -465,11 +695,30 @@ func (wp *WorkerPool) processJob(log zerolog.Logger, job Job) error {
 		Int("segments", len(tw.Segments)).
 		Int("duration_ms", durationMs).
 		Int("provider_ms", providerMs).
+		Int("chunks", len(chunks)).
 		Msg("transcription complete")
 
 	return nil
 }
 
+// Classify runs the configured urgency classifier on a transcript. Returns nil
+// when classification is disabled or produced no result; classifier errors are
+// logged, and any fallback result is still returned.
+func (wp *WorkerPool) Classify(ctx context.Context, in ClassifyInput) *database.TranscriptUrgency {
+	if wp.opts.Classifier == nil || strings.TrimSpace(in.Text) == "" {
+		return nil
+	}
+	u, err := wp.opts.Classifier.Classify(ctx, in)
+	if err != nil {
+		wp.log.Warn().Err(err).Str("classifier", wp.opts.Classifier.Name()).Int("tgid", in.Tgid).
+			Msg("urgency classification failed")
+	}
+	if u != nil {
+		metrics.TranscriptionUrgencyTotal.WithLabelValues(u.Label).Inc()
+	}
+	return u
+}
+
 func errorf(format string, args ...any) error {
 	return fmt.Errorf(format, args...)
 }
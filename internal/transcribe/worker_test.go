@@ -113,3 +113,27 @@ func TestWorkerPool_Workers(t *testing.T) {
 		t.Errorf("Workers = %d, want 4", wp.Workers())
 	}
 }
+
+func TestWorkerPool_EmergencyFirst(t *testing.T) {
+	wp := newTestPool(0, 10) // 0 workers so nothing drains
+
+	wp.Enqueue(Job{CallID: 1})
+	wp.Enqueue(Job{CallID: 2})
+	wp.Enqueue(Job{CallID: 3, Emergency: true})
+
+	if s := wp.Stats(); s.Pending != 3 || s.PendingEmergency != 1 {
+		t.Errorf("Pending = %d, PendingEmergency = %d, want 3, 1", s.Pending, s.PendingEmergency)
+	}
+	wp.Stop()
+	var order []int64
+	for {
+		job, ok := wp.next()
+		if !ok {
+			break
+		}
+		order = append(order, job.CallID)
+	}
+	if len(order) != 3 || order[0] != 3 || order[1] != 1 || order[2] != 2 {
+		t.Errorf("order = %v, want [3 1 2]", order)
+	}
+}
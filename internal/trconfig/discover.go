@@ -11,6 +11,7 @@ import (
 type DiscoveryResult struct {
 	CaptureDir string             // host path to TR's audio output directory
 	Systems    []DiscoveredSystem // systems found in config.json
+	Sources    []TRSource         // SDR sources, in source number order
 }
 
 // DiscoveredSystem is a system from TR's config with its parsed talkgroup CSV.
@@ -37,6 +38,7 @@ func Discover(trDir string, log zerolog.Logger) (*DiscoveryResult, error) {
 	log.Info().
 		Str("capture_dir", cfg.CaptureDir).
 		Int("systems", len(cfg.Systems)).
+		Int("sources", len(cfg.Sources)).
 		Msg("loaded trunk-recorder config")
 
 	// Try to load docker-compose volume mappings for path translation
@@ -67,6 +69,7 @@ func Discover(trDir string, log zerolog.Logger) (*DiscoveryResult, error) {
 	// Process each system
 	result := &DiscoveryResult{
 		CaptureDir: captureDir,
+		Sources:    cfg.Sources,
 	}
 
 	for _, sys := range cfg.Systems {
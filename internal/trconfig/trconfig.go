@@ -16,6 +16,7 @@ import (
 type TRConfig struct {
 	CaptureDir string     `json:"captureDir"`
 	Systems    []TRSystem `json:"systems"`
+	Sources    []TRSource `json:"sources"`
 }
 
 // TRSystem is a system entry in trunk-recorder's config.
@@ -26,6 +27,39 @@ type TRSystem struct {
 	UnitTagsFile   string `json:"unitTagsFile"`
 }
 
+// TRSource is a source (SDR) entry in trunk-recorder's config. Trunk-recorder
+// numbers sources by their position in the list.
+type TRSource struct {
+	Center           float64         `json:"center"`
+	Rate             float64         `json:"rate"`
+	Error            float64         `json:"error"`
+	Gain             json.RawMessage `json:"gain"` // number, or a string like "LNA:32,MIX:10"
+	Driver           string          `json:"driver"`
+	Device           string          `json:"device"`
+	Antenna          string          `json:"antenna"`
+	DigitalRecorders int             `json:"digitalRecorders"`
+	AnalogRecorders  int             `json:"analogRecorders"`
+}
+
+// Band returns the lowest and highest frequencies (Hz) the source samples.
+func (s TRSource) Band() (minHz, maxHz int64) {
+	return int64(s.Center - s.Rate/2), int64(s.Center + s.Rate/2)
+}
+
+// FormatGain renders a gain setting, which trunk-recorder accepts as either a
+// number or a string, as text. Empty or null gain gives "".
+func FormatGain(raw json.RawMessage) string {
+	var str string
+	if err := json.Unmarshal(raw, &str); err == nil {
+		return str
+	}
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err == nil {
+		return n.String()
+	}
+	return ""
+}
+
 // LoadConfig reads and parses a trunk-recorder config.json file.
 func LoadConfig(path string) (*TRConfig, error) {
 	data, err := os.ReadFile(path)
@@ -230,105 +264,22 @@ type UnitEntry struct {
 	AlphaTag string
 }
 
-// LoadUnitCSV reads a trunk-recorder unit tags CSV file (two-column, headerless: unit_id,alpha_tag).
-// Skips the first row if the first column is non-numeric (header row).
+// LoadUnitCSV reads a trunk-recorder unit tags CSV file. The documented format is
+// two-column and headerless (unit_id,alpha_tag); header rows, reordered or extra
+// columns, and semicolon/tab delimiters are also accepted (see ParseUnitCSV).
 func LoadUnitCSV(path string) ([]UnitEntry, error) {
-	f, err := os.Open(path)
+	u, err := ReadUnitCSV(path)
 	if err != nil {
-		return nil, fmt.Errorf("open %s: %w", path, err)
-	}
-	defer f.Close()
-
-	r := csv.NewReader(f)
-	r.TrimLeadingSpace = true
-	r.LazyQuotes = true
-	r.FieldsPerRecord = -1 // allow variable fields
-
-	var entries []UnitEntry
-	first := true
-	for {
-		record, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			continue // skip malformed rows
-		}
-		if len(record) < 2 {
-			continue
-		}
-
-		unitID, parseErr := strconv.Atoi(strings.TrimSpace(record[0]))
-		if parseErr != nil {
-			if first {
-				first = false
-				continue // skip header row
-			}
-			continue
-		}
-		first = false
-
-		entries = append(entries, UnitEntry{
-			UnitID:   unitID,
-			AlphaTag: strings.TrimSpace(record[1]),
-		})
+		return nil, err
 	}
-
-	return entries, nil
+	return u.Entries(), nil
 }
 
-// UpdateUnitCSV updates or appends a unit's alpha_tag in a TR unit tags CSV file.
-// If the file doesn't exist, it creates it with a single row.
+// UpdateUnitCSV updates or appends a unit's alpha_tag in a TR unit tags CSV file,
+// preserving the file's layout. If the file doesn't exist, it creates it with a single row.
 func UpdateUnitCSV(path string, unitID int, alphaTag string) error {
-	unitIDStr := strconv.Itoa(unitID)
-
-	data, err := os.ReadFile(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			// Create new file with single row
-			var buf strings.Builder
-			w := csv.NewWriter(&buf)
-			w.Write([]string{unitIDStr, alphaTag})
-			w.Flush()
-			return os.WriteFile(path, []byte(buf.String()), 0644)
-		}
-		return fmt.Errorf("read %s: %w", path, err)
-	}
-
-	r := csv.NewReader(strings.NewReader(string(data)))
-	r.TrimLeadingSpace = true
-	r.LazyQuotes = true
-	r.FieldsPerRecord = -1
-
-	records, err := r.ReadAll()
-	if err != nil {
-		return fmt.Errorf("parse %s: %w", path, err)
-	}
-
-	// Find and update the matching row
-	found := false
-	for i, record := range records {
-		if len(record) >= 2 && strings.TrimSpace(record[0]) == unitIDStr {
-			records[i][1] = alphaTag
-			found = true
-			break
-		}
-	}
-
-	if !found {
-		records = append(records, []string{unitIDStr, alphaTag})
-	}
-
-	var buf strings.Builder
-	w := csv.NewWriter(&buf)
-	if err := w.WriteAll(records); err != nil {
-		return fmt.Errorf("encode CSV: %w", err)
-	}
-
-	if err := os.WriteFile(path, []byte(buf.String()), 0644); err != nil {
-		return fmt.Errorf("write %s: %w", path, err)
-	}
-	return nil
+	_, err := UpdateUnitCSVTags(path, map[int]string{unitID: alphaTag})
+	return err
 }
 
 // CSVParseResult holds the result of parsing a talkgroup CSV.
@@ -0,0 +1,318 @@
+package trconfig
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// UnitCSV is a parsed trunk-recorder unit tags file that remembers its layout
+// (delimiter, header, column positions, extra columns) so it can be rewritten
+// without disturbing anything tr-engine doesn't manage.
+//
+// Accepted variants:
+//   - headerless "unit_id,alpha_tag" (TR's documented format)
+//   - a header row naming the columns in any order, e.g. "Alias,Radio ID,Color"
+//   - comma, semicolon, or tab delimiters
+//   - extra columns, which are preserved on write
+type UnitCSV struct {
+	Comma   rune
+	Header  []string // nil when the file has no header row
+	IDCol   int
+	TagCol  int
+	Records [][]string // data rows, excluding the header
+
+	lines []int // source line of each parsed record
+}
+
+var (
+	unitIDHeaders  = []string{"unit_id", "unit id", "unitid", "unit", "radio id", "radio_id", "radioid", "decimal", "uid", "id"}
+	unitTagHeaders = []string{"alpha_tag", "alpha tag", "alphatag", "alias", "name", "tag", "label"}
+)
+
+// unitCSVMu serializes read-modify-write cycles on unit CSV files between the
+// API's on-edit writeback and the scheduled sync.
+var unitCSVMu sync.Mutex
+
+// ParseUnitCSV parses a unit tags CSV, detecting delimiter, header, and column layout.
+// Rows that are malformed or lack a numeric unit ID are dropped.
+func ParseUnitCSV(rd io.Reader) (*UnitCSV, error) {
+	return parseUnitCSV(rd, nil)
+}
+
+// parseUnitCSV is ParseUnitCSV, calling skip (if set) with the line number
+// and reason for each dropped row.
+func parseUnitCSV(rd io.Reader, skip func(line int, reason string)) (*UnitCSV, error) {
+	data, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+	u := &UnitCSV{Comma: detectDelimiter(data), IDCol: 0, TagCol: 1}
+	if skip == nil {
+		skip = func(int, string) {}
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = u.Comma
+	r.TrimLeadingSpace = true
+	r.LazyQuotes = true
+	r.FieldsPerRecord = -1
+
+	first := true
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var pe *csv.ParseError
+			if errors.As(err, &pe) {
+				skip(pe.StartLine, "malformed row: "+pe.Err.Error())
+			}
+			continue // skip malformed rows
+		}
+		line, _ := r.FieldPos(0)
+		if first {
+			first = false
+			if _, numErr := strconv.Atoi(strings.TrimSpace(record[0])); numErr != nil {
+				u.setHeader(record)
+				continue
+			}
+		}
+		if len(record) <= u.IDCol || len(record) <= u.TagCol {
+			skip(line, "missing unit ID or alpha tag column")
+			continue
+		}
+		if _, err := strconv.Atoi(strings.TrimSpace(record[u.IDCol])); err != nil {
+			skip(line, fmt.Sprintf("unit ID %q is not a number", strings.TrimSpace(record[u.IDCol])))
+			continue
+		}
+		u.Records = append(u.Records, record)
+		u.lines = append(u.lines, line)
+	}
+	return u, nil
+}
+
+// MaxUnitID is the largest radio ID on the systems TR decodes (24-bit P25).
+const MaxUnitID = 1<<24 - 1
+
+// UnitCSVIssue is a row of an imported unit CSV that was not accepted.
+type UnitCSVIssue struct {
+	Line    int    `json:"line"`
+	UnitID  int    `json:"unit_id,omitempty"`
+	Message string `json:"message"`
+}
+
+// UnitCSVImport is a unit CSV checked for import.
+type UnitCSVImport struct {
+	Entries    []UnitEntry    // accepted rows, one per unit, in file order
+	Issues     []UnitCSVIssue // rejected rows
+	Duplicates int            // rows repeating an earlier unit ID; the later row wins
+}
+
+// ParseUnitCSVImport parses a unit CSV in any layout ParseUnitCSV accepts
+// and validates each row: the unit ID must be in 1..MaxUnitID and the alpha
+// tag non-empty. Rejected rows are reported by line rather than dropped
+// silently, so an import can be previewed.
+func ParseUnitCSVImport(rd io.Reader) (*UnitCSVImport, error) {
+	res := &UnitCSVImport{}
+	u, err := parseUnitCSV(rd, func(line int, reason string) {
+		res.Issues = append(res.Issues, UnitCSVIssue{Line: line, Message: reason})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[int]int) // unit ID → position in Entries
+	for i, e := range u.Entries() {
+		line := u.lines[i]
+		switch {
+		case e.UnitID <= 0 || e.UnitID > MaxUnitID:
+			res.Issues = append(res.Issues, UnitCSVIssue{Line: line, UnitID: e.UnitID,
+				Message: fmt.Sprintf("unit ID out of range 1-%d", MaxUnitID)})
+			continue
+		case e.AlphaTag == "":
+			res.Issues = append(res.Issues, UnitCSVIssue{Line: line, UnitID: e.UnitID, Message: "empty alpha tag"})
+			continue
+		}
+		if j, ok := index[e.UnitID]; ok {
+			res.Entries[j] = e
+			res.Duplicates++
+			continue
+		}
+		index[e.UnitID] = len(res.Entries)
+		res.Entries = append(res.Entries, e)
+	}
+	sort.SliceStable(res.Issues, func(i, j int) bool { return res.Issues[i].Line < res.Issues[j].Line })
+	return res, nil
+}
+
+// ReadUnitCSV reads and parses a unit tags CSV file.
+func ReadUnitCSV(path string) (*UnitCSV, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	defer f.Close()
+	return ParseUnitCSV(f)
+}
+
+// detectDelimiter picks the most frequent of comma, semicolon, and tab in the
+// first non-empty line, defaulting to comma.
+func detectDelimiter(data []byte) rune {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		best, bestN := ',', strings.Count(line, ",")
+		for _, d := range []rune{';', '\t'} {
+			if n := strings.Count(line, string(d)); n > bestN {
+				best, bestN = d, n
+			}
+		}
+		return best
+	}
+	return ','
+}
+
+// setHeader records the header row and locates the ID and tag columns by name.
+func (u *UnitCSV) setHeader(record []string) {
+	u.Header = record
+	find := func(names []string, fallback int) int {
+		for _, name := range names {
+			for i, h := range record {
+				if strings.EqualFold(strings.TrimSpace(h), name) {
+					return i
+				}
+			}
+		}
+		return fallback
+	}
+	u.IDCol = find(unitIDHeaders, 0)
+	u.TagCol = find(unitTagHeaders, 1)
+	if u.TagCol == u.IDCol {
+		u.TagCol = 1 - u.IDCol
+		if u.TagCol < 0 {
+			u.TagCol = 1
+		}
+	}
+}
+
+// Entries returns the unit ID → alpha tag rows. Later duplicates win.
+func (u *UnitCSV) Entries() []UnitEntry {
+	entries := make([]UnitEntry, 0, len(u.Records))
+	for _, rec := range u.Records {
+		id, _ := strconv.Atoi(strings.TrimSpace(rec[u.IDCol]))
+		entries = append(entries, UnitEntry{UnitID: id, AlphaTag: strings.TrimSpace(rec[u.TagCol])})
+	}
+	return entries
+}
+
+// Tags returns the file's contents as a unit ID → alpha tag map.
+func (u *UnitCSV) Tags() map[int]string {
+	tags := make(map[int]string, len(u.Records))
+	for _, e := range u.Entries() {
+		tags[e.UnitID] = e.AlphaTag
+	}
+	return tags
+}
+
+// Set updates the tag of every row for unitID, or appends a row if none exists.
+// Returns false if the file already had that tag.
+func (u *UnitCSV) Set(unitID int, alphaTag string) bool {
+	idStr := strconv.Itoa(unitID)
+	found, changed := false, false
+	for _, rec := range u.Records {
+		if strings.TrimSpace(rec[u.IDCol]) == idStr {
+			found = true
+			if strings.TrimSpace(rec[u.TagCol]) != alphaTag {
+				rec[u.TagCol] = alphaTag
+				changed = true
+			}
+		}
+	}
+	if found {
+		return changed
+	}
+
+	width := len(u.Header)
+	if width < u.IDCol+1 {
+		width = u.IDCol + 1
+	}
+	if width < u.TagCol+1 {
+		width = u.TagCol + 1
+	}
+	rec := make([]string, width)
+	rec[u.IDCol] = idStr
+	rec[u.TagCol] = alphaTag
+	u.Records = append(u.Records, rec)
+	return true
+}
+
+// Encode renders the file in its original layout.
+func (u *UnitCSV) Encode() ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = u.Comma
+	if u.Header != nil {
+		if err := w.Write(u.Header); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.WriteAll(u.Records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UpdateUnitCSVTags writes tags into a unit CSV file, preserving its layout.
+// If the file doesn't exist it is created in the headerless two-column format.
+// Returns the number of rows changed or added.
+func UpdateUnitCSVTags(path string, tags map[int]string) (int, error) {
+	unitCSVMu.Lock()
+	defer unitCSVMu.Unlock()
+
+	u, err := ReadUnitCSV(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return 0, err
+		}
+		u = &UnitCSV{Comma: ',', IDCol: 0, TagCol: 1}
+	}
+
+	// Deterministic append order for new rows
+	ids := make([]int, 0, len(tags))
+	for id := range tags {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	changed := 0
+	for _, id := range ids {
+		if u.Set(id, tags[id]) {
+			changed++
+		}
+	}
+	if changed == 0 {
+		return 0, nil
+	}
+
+	data, err := u.Encode()
+	if err != nil {
+		return 0, fmt.Errorf("encode CSV: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return 0, fmt.Errorf("write %s: %w", path, err)
+	}
+	return changed, nil
+}
@@ -0,0 +1,122 @@
+package trconfig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseUnitCSVVariants(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want map[int]string
+	}{
+		{
+			name: "headerless",
+			data: "101,Engine 1\n102, Medic 2\n",
+			want: map[int]string{101: "Engine 1", 102: "Medic 2"},
+		},
+		{
+			name: "header_reordered",
+			data: "Alias,Radio ID,Color\nEngine 1,101,red\nMedic 2,102,blue\n",
+			want: map[int]string{101: "Engine 1", 102: "Medic 2"},
+		},
+		{
+			name: "semicolon",
+			data: "Decimal;Alpha Tag\n101;Engine 1\n",
+			want: map[int]string{101: "Engine 1"},
+		},
+		{
+			name: "tab",
+			data: "101\tEngine 1\n102\tMedic 2\n",
+			want: map[int]string{101: "Engine 1", 102: "Medic 2"},
+		},
+		{
+			name: "skips_bad_rows",
+			data: "unit,tag\nabc,Nope\n103\n104,Ladder 4\n",
+			want: map[int]string{104: "Ladder 4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := ParseUnitCSV(strings.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("ParseUnitCSV: %v", err)
+			}
+			if got := u.Tags(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateUnitCSVTagsPreservesLayout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "units.csv")
+	if err := os.WriteFile(path, []byte("Alias;Radio ID;Color\nEngine 1;101;red\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := UpdateUnitCSVTags(path, map[int]string{101: "Engine One", 102: "Medic 2"})
+	if err != nil {
+		t.Fatalf("UpdateUnitCSVTags: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("changed = %d, want 2", n)
+	}
+
+	got, _ := os.ReadFile(path)
+	want := "Alias;Radio ID;Color\nEngine One;101;red\nMedic 2;102;\n"
+	if string(got) != want {
+		t.Errorf("file = %q, want %q", got, want)
+	}
+
+	// No-op update leaves the file alone
+	if n, err := UpdateUnitCSVTags(path, map[int]string{102: "Medic 2"}); err != nil || n != 0 {
+		t.Errorf("no-op update = %d, %v; want 0, nil", n, err)
+	}
+}
+
+func TestUpdateUnitCSVCreatesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "units.csv")
+	if err := UpdateUnitCSV(path, 7, "Chief"); err != nil {
+		t.Fatalf("UpdateUnitCSV: %v", err)
+	}
+	got, _ := os.ReadFile(path)
+	if string(got) != "7,Chief\n" {
+		t.Errorf("file = %q", got)
+	}
+}
+
+func TestParseUnitCSVImport(t *testing.T) {
+	data := "Radio ID,Alias\n" +
+		"101,Engine 1\n" +
+		"abc,Nope\n" +
+		"\n" +
+		"102,\n" +
+		"0,Zero\n" +
+		"103\n" +
+		"101,Engine One\n" +
+		"16777216,Too Big\n" +
+		"104,\"Ladder 4, Tiller\"\n"
+	res, err := ParseUnitCSVImport(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantEntries := []UnitEntry{{101, "Engine One"}, {104, "Ladder 4, Tiller"}}
+	if !reflect.DeepEqual(res.Entries, wantEntries) {
+		t.Errorf("entries = %v, want %v", res.Entries, wantEntries)
+	}
+	if res.Duplicates != 1 {
+		t.Errorf("duplicates = %d, want 1", res.Duplicates)
+	}
+	var lines []int
+	for _, is := range res.Issues {
+		lines = append(lines, is.Line)
+	}
+	if want := []int{3, 5, 6, 7, 9}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("issue lines = %v, want %v (%+v)", lines, want, res.Issues)
+	}
+}
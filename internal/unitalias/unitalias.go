@@ -0,0 +1,229 @@
+// Package unitalias proposes alpha tags for untagged units from what they say
+// about themselves on the air.
+//
+// Transcripts are attributed to the transmitting unit word by word (see
+// transcribe.AttributeWords). A segment like "Engine 31 on scene" or
+// "Dispatch, Medic 12" spoken by an untagged unit is evidence that the unit is
+// Engine 31 or Medic 12. Evidence is stored per transcript and aggregated into
+// suggestions with a confidence; nothing is applied until an admin accepts it.
+package unitalias
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/transcribe"
+)
+
+// Candidate is an alias a segment's speaker appears to identify as.
+type Candidate struct {
+	Alias  string
+	Weight float64 // 0–1: how strongly the phrasing implies self-identification
+}
+
+// designator matches apparatus/unit call signs: a kind word and a number.
+const designator = `(engine|medic|ladder|truck|rescue|squad|tanker|tender|brush|battalion|chief|car|unit|ambulance|tower|quint|marine|hazmat|patrol|sergeant|lieutenant|captain|deputy|trooper)[ -]?(\d{1,4}[a-z]?)\b`
+
+// addressee matches words used to hail dispatch rather than a unit.
+const addressee = `(?:dispatch|command|control|county|communications|comms|central|fire|ems)`
+
+var (
+	// "This is Engine 31" anywhere in the segment.
+	thisIsRe = regexp.MustCompile(`(?i)\bthis is ` + designator)
+
+	// "Engine 31 on scene", "Medic 12 responding" at the start of a segment.
+	statusRe = regexp.MustCompile(`(?i)^` + designator + `[,.]?\s+(?:is\s+)?(?:on scene|on location|responding|en ?route|arriving|available|in service|out of service|in quarters|returning|staged|staging|on the air)\b`)
+
+	// "Dispatch, Engine 31" or "Medic 12, Engine 31": radio convention is
+	// called party first, caller second.
+	callerRe = regexp.MustCompile(`(?i)^(?:` + addressee + `|(?:engine|medic|ladder|truck|rescue|squad|tanker|tender|brush|battalion|chief|car|unit|ambulance|tower|quint|marine|hazmat|patrol|sergeant|lieutenant|captain|deputy|trooper)[ -]?\d{1,4}[a-z]?)[,.]?\s+` + designator)
+)
+
+// Extract returns the aliases the speaker of text appears to identify as, at
+// most one per alias (with its strongest weight).
+func Extract(text string) []Candidate {
+	text = strings.TrimLeft(strings.TrimSpace(text), `"'.,-– `)
+	if text == "" {
+		return nil
+	}
+
+	var out []Candidate
+	add := func(m []string, weight float64) {
+		if m == nil {
+			return
+		}
+		alias := normalize(m[1], m[2])
+		for i := range out {
+			if out[i].Alias == alias {
+				if weight > out[i].Weight {
+					out[i].Weight = weight
+				}
+				return
+			}
+		}
+		out = append(out, Candidate{Alias: alias, Weight: weight})
+	}
+
+	add(thisIsRe.FindStringSubmatch(text), 1.0)
+	add(statusRe.FindStringSubmatch(text), 1.0)
+	add(callerRe.FindStringSubmatch(text), 0.8)
+	return out
+}
+
+// normalize renders a call sign as "Engine 31" / "Medic 12A".
+func normalize(kind, num string) string {
+	kind = strings.ToLower(kind)
+	return strings.ToUpper(kind[:1]) + kind[1:] + " " + strings.ToUpper(num)
+}
+
+// snippet trims segment text for storage as evidence.
+func snippet(text string) string {
+	text = strings.TrimSpace(text)
+	const max = 160
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	r := []rune(text)
+	return string(r[:max-1]) + "…"
+}
+
+// Result summarizes one inference run.
+type Result struct {
+	Scanned  int // transcriptions examined
+	Evidence int // new evidence rows stored
+}
+
+const batchSize = 500
+
+// Inferrer periodically scans new transcripts for alias evidence.
+type Inferrer struct {
+	db       *database.DB
+	interval time.Duration
+	lookback time.Duration
+	log      zerolog.Logger
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	mu     sync.Mutex
+	lastID int // highest transcription id scanned; 0 until the first run
+}
+
+// NewInferrer creates an inferrer. The first run scans transcripts from the
+// last lookback; later runs only scan transcripts added since.
+func NewInferrer(db *database.DB, interval, lookback time.Duration, log zerolog.Logger) *Inferrer {
+	return &Inferrer{
+		db:       db,
+		interval: interval,
+		lookback: lookback,
+		log:      log.With().Str("component", "unit-alias").Logger(),
+		stop:     make(chan struct{}),
+	}
+}
+
+func (inf *Inferrer) Start() {
+	if inf.interval > 0 {
+		go inf.loop()
+	}
+}
+
+func (inf *Inferrer) Stop() { inf.stopOnce.Do(func() { close(inf.stop) }) }
+
+func (inf *Inferrer) loop() {
+	inf.runAndLog()
+	ticker := time.NewTicker(inf.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			inf.runAndLog()
+		case <-inf.stop:
+			return
+		}
+	}
+}
+
+func (inf *Inferrer) runAndLog() {
+	res, err := inf.Run(context.Background())
+	if err != nil {
+		inf.log.Warn().Err(err).Msg("unit alias inference failed")
+		return
+	}
+	if res.Evidence > 0 {
+		inf.log.Info().Int("scanned", res.Scanned).Int("evidence", res.Evidence).Msg("unit alias evidence recorded")
+	}
+}
+
+// Run scans transcriptions added since the previous run. Evidence is keyed by
+// transcription, so rescanning (e.g. after a restart) never double-counts.
+func (inf *Inferrer) Run(ctx context.Context) (Result, error) {
+	inf.mu.Lock()
+	defer inf.mu.Unlock()
+
+	var res Result
+	var since time.Time
+	if inf.lastID == 0 && inf.lookback > 0 {
+		since = time.Now().Add(-inf.lookback)
+	}
+
+	for {
+		batch, err := inf.db.ListAttributedTranscriptions(ctx, inf.lastID, since, batchSize)
+		if err != nil {
+			return res, err
+		}
+		for _, t := range batch {
+			n, err := inf.scan(ctx, t)
+			if err != nil {
+				return res, err
+			}
+			res.Evidence += n
+			res.Scanned++
+			inf.lastID = t.ID
+		}
+		if len(batch) < batchSize {
+			return res, nil
+		}
+	}
+}
+
+// scan records evidence from one transcription's attributed segments.
+func (inf *Inferrer) scan(ctx context.Context, t database.AttributedTranscription) (int, error) {
+	var tw transcribe.TranscriptionWords
+	if err := json.Unmarshal(t.Words, &tw); err != nil {
+		return 0, nil // malformed words JSON: nothing to learn
+	}
+
+	stored := 0
+	for _, seg := range tw.Segments {
+		// Tagged at the time of the call: nothing to infer.
+		if seg.Src <= 0 || seg.SrcTag != "" {
+			continue
+		}
+		for _, c := range Extract(seg.Text) {
+			ok, err := inf.db.RecordUnitAliasEvidence(ctx, database.UnitAliasEvidence{
+				SystemID:        t.SystemID,
+				UnitID:          seg.Src,
+				Alias:           c.Alias,
+				TranscriptionID: t.ID,
+				CallID:          t.CallID,
+				CallStartTime:   t.CallStartTime,
+				Snippet:         snippet(seg.Text),
+				Weight:          c.Weight,
+			})
+			if err != nil {
+				return stored, err
+			}
+			if ok {
+				stored++
+			}
+		}
+	}
+	return stored, nil
+}
@@ -0,0 +1,54 @@
+package unitalias
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		text string
+		want []Candidate
+	}{
+		{"Engine 31 on scene, nothing showing", []Candidate{{"Engine 31", 1.0}}},
+		{"medic 12a responding", []Candidate{{"Medic 12A", 1.0}}},
+		{"Battalion 2 is en route", []Candidate{{"Battalion 2", 1.0}}},
+		{"Dispatch, Truck 7", []Candidate{{"Truck 7", 0.8}}},
+		{"Medic 4, Engine 31, go ahead", []Candidate{{"Engine 31", 0.8}}},
+		{"County, this is Rescue-5 requesting a tone", []Candidate{{"Rescue 5", 1.0}}},
+		{"Engine31 available", []Candidate{{"Engine 31", 1.0}}},
+
+		// Dispatch acknowledging or assigning units: no self-identification.
+		{"Engine 31 copy", nil},
+		{"Engine 31 and Medic 12 respond to 400 Main", nil},
+		{"All units be advised", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got := Extract(tt.text)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Extract(%q) = %+v, want %+v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestExtract_StrongestWeightWins(t *testing.T) {
+	got := Extract("Dispatch, Engine 31, this is Engine 31 on scene")
+	want := []Candidate{{"Engine 31", 1.0}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	long := strings.Repeat("é", 200)
+	s := snippet(long)
+	if n := utf8.RuneCountInString(s); n != 160 {
+		t.Errorf("snippet length = %d runes, want 160", n)
+	}
+	if s := snippet("  short  "); s != "short" {
+		t.Errorf("snippet = %q", s)
+	}
+}
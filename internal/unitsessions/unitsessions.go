@@ -0,0 +1,266 @@
+// Package unitsessions folds raw unit events into unit sessions.
+//
+// A session is a unit on one talkgroup from its first event there until the
+// unit goes off, registers again, shows up on another talkgroup, or sends
+// nothing for the idle timeout. Sessions are far fewer rows than the
+// on/off/join/call events they summarize, so timelines and analytics keep
+// working after raw unit_events are purged on a shorter retention.
+//
+// Compaction is incremental: each run folds events from the stored watermark
+// up to a settle delay before now, an hour at a time, carrying sessions still
+// open across runs.
+package unitsessions
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+const (
+	// chunk is how much event time one compaction transaction covers.
+	chunk = time.Hour
+	// settle keeps compaction behind live ingest so batched unit event
+	// inserts have landed before their time range is folded.
+	settle = 5 * time.Minute
+)
+
+type unitKey struct {
+	systemID int
+	unitRID  int
+}
+
+// folder holds open sessions and folds events into them.
+type folder struct {
+	idle  time.Duration
+	open  map[unitKey]*database.UnitSession
+	dirty map[*database.UnitSession]bool // changed since the last save
+}
+
+func newFolder(idle time.Duration, open []database.UnitSession) *folder {
+	f := &folder{
+		idle:  idle,
+		open:  make(map[unitKey]*database.UnitSession),
+		dirty: make(map[*database.UnitSession]bool),
+	}
+	// open is oldest last event first, so a later session for the same unit
+	// (e.g. left by a unit merge) replaces an earlier one, which is closed.
+	for i := range open {
+		s := &open[i]
+		key := unitKey{s.SystemID, s.UnitID}
+		if prev := f.open[key]; prev != nil {
+			f.close(prev, database.UnitSessionEndIdle, prev.EndTime)
+		}
+		f.open[key] = s
+	}
+	return f
+}
+
+func (f *folder) close(s *database.UnitSession, endedBy string, at time.Time) {
+	s.EndedBy = endedBy
+	s.EndTime = at
+	s.Open = false
+	f.dirty[s] = true
+	if f.open[unitKey{s.SystemID, s.UnitID}] == s {
+		delete(f.open, unitKey{s.SystemID, s.UnitID})
+	}
+}
+
+// add folds one event. Events must arrive in time order per unit.
+func (f *folder) add(e database.UnitSessionEvent) {
+	key := unitKey{e.SystemID, e.UnitRID}
+	cur := f.open[key]
+	if cur != nil && e.Time.Sub(cur.EndTime) > f.idle {
+		f.close(cur, database.UnitSessionEndIdle, cur.EndTime)
+		cur = nil
+	}
+
+	switch {
+	case e.EventType == "off":
+		if cur != nil {
+			cur.Events++
+			f.close(cur, database.UnitSessionEndOff, e.Time)
+		}
+	case e.EventType == "on":
+		if cur != nil {
+			f.close(cur, database.UnitSessionEndOn, e.Time)
+		}
+	case e.Tgid == 0:
+		// No talkgroup (e.g. a location or signal event): the unit is still
+		// around, so its session stays current.
+		if cur != nil {
+			cur.Events++
+			cur.EndTime = e.Time
+			f.dirty[cur] = true
+		}
+	case cur != nil && cur.Tgid == e.Tgid:
+		cur.Events++
+		cur.EndTime = e.Time
+		if e.EventType == "call" {
+			cur.Calls++
+		}
+		f.dirty[cur] = true
+	default:
+		if cur != nil {
+			f.close(cur, database.UnitSessionEndTgidChange, e.Time)
+		}
+		s := &database.UnitSession{
+			SystemID:  e.SystemID,
+			UnitID:    e.UnitRID,
+			Tgid:      e.Tgid,
+			StartTime: e.Time,
+			EndTime:   e.Time,
+			Events:    1,
+			Open:      true,
+		}
+		if e.EventType == "call" {
+			s.Calls = 1
+		}
+		f.open[key] = s
+		f.dirty[s] = true
+	}
+}
+
+// expire closes open sessions with no event for the idle timeout before
+// through.
+func (f *folder) expire(through time.Time) {
+	for _, s := range f.open {
+		if through.Sub(s.EndTime) > f.idle {
+			f.close(s, database.UnitSessionEndIdle, s.EndTime)
+		}
+	}
+}
+
+// flush returns the sessions changed since the last flush.
+func (f *folder) flush() []database.UnitSession {
+	out := make([]database.UnitSession, 0, len(f.dirty))
+	for s := range f.dirty {
+		out = append(out, *s)
+	}
+	clear(f.dirty)
+	return out
+}
+
+// Result summarizes one compaction run.
+type Result struct {
+	Events   int       // unit events folded
+	Sessions int       // sessions created or updated
+	Through  time.Time // new watermark
+}
+
+// Compactor periodically folds new unit events into sessions.
+type Compactor struct {
+	db       *database.DB
+	interval time.Duration
+	idle     time.Duration
+	backfill time.Duration
+	log      zerolog.Logger
+	stop     chan struct{}
+	stopOnce sync.Once
+	mu       sync.Mutex
+}
+
+// NewCompactor creates a compactor. The first run, with no watermark stored,
+// starts backfill before now.
+func NewCompactor(db *database.DB, interval, idle, backfill time.Duration, log zerolog.Logger) *Compactor {
+	return &Compactor{
+		db:       db,
+		interval: interval,
+		idle:     idle,
+		backfill: backfill,
+		log:      log.With().Str("component", "unit-sessions").Logger(),
+		stop:     make(chan struct{}),
+	}
+}
+
+func (c *Compactor) Start() {
+	if c.interval > 0 {
+		go c.loop()
+	}
+}
+
+func (c *Compactor) Stop() { c.stopOnce.Do(func() { close(c.stop) }) }
+
+func (c *Compactor) loop() {
+	c.runAndLog()
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.runAndLog()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+func (c *Compactor) runAndLog() {
+	res, err := c.Run(context.Background())
+	if err != nil {
+		c.log.Warn().Err(err).Msg("unit session compaction failed")
+		return
+	}
+	if res.Events > 0 {
+		c.log.Info().Int("events", res.Events).Int("sessions", res.Sessions).Time("through", res.Through).Msg("unit events compacted")
+	}
+}
+
+// Run folds unit events from the watermark up to the settle delay before
+// now. Each hour of events is saved with the new watermark in one
+// transaction, so a failed run resumes where it stopped. Events stored with
+// a time already behind the watermark are not folded.
+func (c *Compactor) Run(ctx context.Context) (Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	through := time.Now().Add(-settle)
+	wm, err := c.db.UnitSessionWatermark(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	from := through.Add(-c.backfill)
+	if wm != nil {
+		from = *wm
+	}
+	res := Result{Through: from}
+	if !from.Before(through) {
+		return res, nil
+	}
+
+	open, err := c.db.ListOpenUnitSessions(ctx)
+	if err != nil {
+		return res, err
+	}
+	f := newFolder(c.idle, open)
+	for from.Before(through) {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		to := from.Add(chunk)
+		if to.After(through) {
+			to = through
+		}
+		events, err := c.db.ListUnitEventsForSessions(ctx, from, to)
+		if err != nil {
+			return res, err
+		}
+		for _, e := range events {
+			f.add(e)
+		}
+		f.expire(to)
+		sessions := f.flush()
+		if err := c.db.SaveUnitSessions(ctx, sessions, to); err != nil {
+			return res, err
+		}
+		res.Events += len(events)
+		res.Sessions += len(sessions)
+		res.Through = to
+		from = to
+	}
+	return res, nil
+}
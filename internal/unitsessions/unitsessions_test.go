@@ -0,0 +1,107 @@
+package unitsessions
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+var t0 = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func ev(sec int, typ string, tgid int) database.UnitSessionEvent {
+	return database.UnitSessionEvent{SystemID: 1, UnitRID: 100, EventType: typ, Tgid: tgid, Time: t0.Add(time.Duration(sec) * time.Second)}
+}
+
+func sorted(sessions []database.UnitSession) []database.UnitSession {
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].StartTime.Before(sessions[j].StartTime) })
+	return sessions
+}
+
+func TestFolderTgidChangeAndOff(t *testing.T) {
+	f := newFolder(time.Hour, nil)
+	for _, e := range []database.UnitSessionEvent{
+		ev(0, "on", 0),
+		ev(1, "join", 10),
+		ev(5, "call", 10),
+		ev(8, "location", 0),
+		ev(20, "join", 20),
+		ev(30, "call", 20),
+		ev(40, "off", 0),
+	} {
+		f.add(e)
+	}
+	got := sorted(f.flush())
+	if len(got) != 2 {
+		t.Fatalf("sessions = %+v", got)
+	}
+	a, b := got[0], got[1]
+	if a.Tgid != 10 || a.Events != 3 || a.Calls != 1 || a.EndedBy != database.UnitSessionEndTgidChange ||
+		!a.EndTime.Equal(t0.Add(20*time.Second)) || a.Open {
+		t.Errorf("first = %+v", a)
+	}
+	if b.Tgid != 20 || b.Events != 3 || b.Calls != 1 || b.EndedBy != database.UnitSessionEndOff ||
+		!b.EndTime.Equal(t0.Add(40*time.Second)) || b.Open {
+		t.Errorf("second = %+v", b)
+	}
+	if len(f.open) != 0 {
+		t.Errorf("open = %+v", f.open)
+	}
+}
+
+func TestFolderIdleAndOn(t *testing.T) {
+	f := newFolder(time.Minute, nil)
+	f.add(ev(0, "join", 10))
+	f.add(ev(30, "call", 10))
+	f.add(ev(200, "call", 10)) // idle gap: new session
+	f.add(ev(210, "on", 0))
+	got := sorted(f.flush())
+	if len(got) != 2 {
+		t.Fatalf("sessions = %+v", got)
+	}
+	if got[0].EndedBy != database.UnitSessionEndIdle || !got[0].EndTime.Equal(t0.Add(30*time.Second)) || got[0].Calls != 1 {
+		t.Errorf("idle = %+v", got[0])
+	}
+	if got[1].EndedBy != database.UnitSessionEndOn || got[1].Calls != 1 || !got[1].EndTime.Equal(t0.Add(210*time.Second)) {
+		t.Errorf("on = %+v", got[1])
+	}
+}
+
+func TestFolderExpireAndFlush(t *testing.T) {
+	f := newFolder(time.Minute, nil)
+	f.add(ev(0, "join", 10))
+	f.expire(t0.Add(30 * time.Second))
+	got := f.flush()
+	if len(got) != 1 || !got[0].Open || got[0].EndedBy != "" {
+		t.Fatalf("open session = %+v", got)
+	}
+	if again := f.flush(); len(again) != 0 {
+		t.Errorf("flush not cleared: %+v", again)
+	}
+	f.expire(t0.Add(2 * time.Minute))
+	got = f.flush()
+	if len(got) != 1 || got[0].Open || got[0].EndedBy != database.UnitSessionEndIdle || !got[0].EndTime.Equal(t0) {
+		t.Errorf("expired = %+v", got)
+	}
+}
+
+func TestFolderCarriesOpenSessions(t *testing.T) {
+	open := []database.UnitSession{
+		{ID: 1, SystemID: 1, UnitID: 100, Tgid: 10, StartTime: t0, EndTime: t0, Events: 1, Open: true},
+		{ID: 2, SystemID: 1, UnitID: 100, Tgid: 20, StartTime: t0.Add(time.Second), EndTime: t0.Add(time.Second), Events: 1, Open: true},
+	}
+	f := newFolder(time.Hour, open)
+	f.add(ev(5, "call", 20))
+	got := f.flush()
+	byID := map[int64]database.UnitSession{}
+	for _, s := range got {
+		byID[s.ID] = s
+	}
+	if len(got) != 2 || byID[1].EndedBy != database.UnitSessionEndIdle {
+		t.Errorf("duplicate open not closed: %+v", got)
+	}
+	if s := byID[2]; !s.Open || s.Events != 2 || s.Calls != 1 || !s.EndTime.Equal(t0.Add(5*time.Second)) {
+		t.Errorf("carried = %+v", s)
+	}
+}
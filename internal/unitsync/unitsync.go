@@ -0,0 +1,230 @@
+// Package unitsync keeps unit alpha tags in sync between the database and
+// trunk-recorder's unit tags CSV files.
+//
+// Each sync compares three versions of every unit's tag: the CSV as it is now,
+// the CSV as it was at the last sync (unit_csv_state), and the database. That
+// tells us which side changed:
+//
+//   - CSV row new or edited, no manual edit in the DB → imported.
+//   - CSV row edited while the DB holds a different manual edit → conflict,
+//     recorded in unit_csv_conflicts and left for an admin to resolve.
+//   - DB tag differs from an unchanged CSV row, or the unit isn't in the CSV
+//     → written back to the CSV when writeback is enabled.
+package unitsync
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/trconfig"
+)
+
+// Result summarizes one system's sync.
+type Result struct {
+	SystemID    int
+	Imported    int
+	Conflicts   int
+	Converged   int
+	WrittenBack int
+}
+
+// Plan is the set of changes a sync will make.
+type Plan struct {
+	Import    map[int]string    // unit_id → CSV tag to apply to the DB
+	Conflicts map[int][2]string // unit_id → {db tag, csv tag}
+	Converged []int             // units whose open conflict no longer applies
+	Writeback map[int]string    // unit_id → DB tag to write to the CSV
+}
+
+// BuildPlan decides what to sync. csvNow is the file's current contents, csvPrev
+// the contents at the last sync, dbTags the DB's tagged units, and open the units
+// with an unresolved conflict (never written back while open).
+func BuildPlan(csvNow, csvPrev map[int]string, dbTags map[int]database.UnitTagState, open map[int]bool, writeback bool) Plan {
+	plan := Plan{
+		Import:    make(map[int]string),
+		Conflicts: make(map[int][2]string),
+		Writeback: make(map[int]string),
+	}
+
+	for id, tag := range csvNow {
+		d, inDB := dbTags[id]
+		if inDB && d.AlphaTag == tag {
+			if open[id] {
+				plan.Converged = append(plan.Converged, id)
+			}
+			continue
+		}
+		prev, hadPrev := csvPrev[id]
+		csvChanged := !hadPrev || prev != tag
+
+		switch {
+		case csvChanged && inDB && d.Source == "manual":
+			plan.Conflicts[id] = [2]string{d.AlphaTag, tag}
+		case csvChanged:
+			if tag != "" {
+				plan.Import[id] = tag
+			}
+		case !inDB || open[id]:
+			// Unchanged row for a unit we no longer track (e.g. archived), or
+			// waiting on conflict resolution: leave both sides alone.
+		case d.Source == "manual":
+			if writeback {
+				plan.Writeback[id] = d.AlphaTag
+			}
+		case tag != "":
+			// The CSV outranks tags learned from MQTT.
+			plan.Import[id] = tag
+		case writeback:
+			plan.Writeback[id] = d.AlphaTag
+		}
+	}
+
+	if writeback {
+		for id, d := range dbTags {
+			if _, inCSV := csvNow[id]; inCSV || open[id] {
+				continue
+			}
+			// A row that was in the CSV last time was deleted there; respect that.
+			if _, hadPrev := csvPrev[id]; hadPrev {
+				continue
+			}
+			if d.Source == "manual" || d.Source == "mqtt" {
+				plan.Writeback[id] = d.AlphaTag
+			}
+		}
+	}
+
+	sort.Ints(plan.Converged)
+	return plan
+}
+
+// Syncer periodically syncs each system's unit CSV.
+type Syncer struct {
+	db        *database.DB
+	files     map[int]string // system_id → unit CSV path
+	writeback bool
+	interval  time.Duration
+	log       zerolog.Logger
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewSyncer creates a syncer for files. interval <= 0 disables the periodic
+// loop (SyncAll can still be called directly).
+func NewSyncer(db *database.DB, files map[int]string, writeback bool, interval time.Duration, log zerolog.Logger) *Syncer {
+	return &Syncer{
+		db:        db,
+		files:     files,
+		writeback: writeback,
+		interval:  interval,
+		log:       log.With().Str("component", "unit-csv-sync").Logger(),
+		stop:      make(chan struct{}),
+	}
+}
+
+func (s *Syncer) Start() {
+	if s.interval > 0 {
+		go s.loop()
+	}
+}
+
+func (s *Syncer) Stop() { s.stopOnce.Do(func() { close(s.stop) }) }
+
+func (s *Syncer) loop() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.SyncAll(context.Background())
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// SyncAll syncs every configured system, logging failures and any changes.
+func (s *Syncer) SyncAll(ctx context.Context) {
+	for systemID, path := range s.files {
+		res, err := s.SyncSystem(ctx, systemID, path)
+		if err != nil {
+			s.log.Warn().Err(err).Int("system_id", systemID).Str("path", path).Msg("unit CSV sync failed")
+			continue
+		}
+		if res.Imported+res.Conflicts+res.Converged+res.WrittenBack == 0 {
+			continue
+		}
+		s.log.Info().
+			Int("system_id", systemID).
+			Int("imported", res.Imported).
+			Int("conflicts", res.Conflicts).
+			Int("converged", res.Converged).
+			Int("written_back", res.WrittenBack).
+			Msg("unit CSV synced")
+	}
+}
+
+// SyncSystem runs one sync of systemID against the CSV at path.
+func (s *Syncer) SyncSystem(ctx context.Context, systemID int, path string) (Result, error) {
+	res := Result{SystemID: systemID}
+
+	csvFile, err := trconfig.ReadUnitCSV(path)
+	if err != nil {
+		return res, err
+	}
+	csvNow := csvFile.Tags()
+
+	csvPrev, err := s.db.GetUnitCSVState(ctx, systemID)
+	if err != nil {
+		return res, err
+	}
+	dbTags, err := s.db.GetUnitTagStates(ctx, systemID)
+	if err != nil {
+		return res, err
+	}
+	open, err := s.db.OpenUnitCSVConflictUnits(ctx, systemID)
+	if err != nil {
+		return res, err
+	}
+
+	plan := BuildPlan(csvNow, csvPrev, dbTags, open, s.writeback)
+
+	for id, tag := range plan.Import {
+		if err := s.db.ApplyCSVUnitTag(ctx, systemID, id, tag); err != nil {
+			s.log.Warn().Err(err).Int("system_id", systemID).Int("unit_id", id).Msg("failed to import unit tag")
+			continue
+		}
+		res.Imported++
+	}
+	for id, tags := range plan.Conflicts {
+		if err := s.db.RecordUnitCSVConflict(ctx, systemID, id, tags[0], tags[1]); err != nil {
+			s.log.Warn().Err(err).Int("system_id", systemID).Int("unit_id", id).Msg("failed to record unit CSV conflict")
+			continue
+		}
+		res.Conflicts++
+	}
+	for _, id := range plan.Converged {
+		if err := s.db.ConvergeUnitCSVConflict(ctx, systemID, id); err != nil {
+			s.log.Warn().Err(err).Int("system_id", systemID).Int("unit_id", id).Msg("failed to close unit CSV conflict")
+			continue
+		}
+		res.Converged++
+	}
+	if len(plan.Writeback) > 0 {
+		if _, err := trconfig.UpdateUnitCSVTags(path, plan.Writeback); err != nil {
+			return res, err
+		}
+		res.WrittenBack = len(plan.Writeback)
+		for id, tag := range plan.Writeback {
+			csvNow[id] = tag
+		}
+	}
+
+	// The file as it stands now (including our writes) is the next baseline.
+	return res, s.db.ReplaceUnitCSVState(ctx, systemID, csvNow)
+}
@@ -0,0 +1,69 @@
+package unitsync
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+func TestBuildPlan(t *testing.T) {
+	csvPrev := map[int]string{
+		1: "Engine 1", // unchanged in CSV
+		2: "Medic 2",  // edited in CSV
+		3: "Ladder 3", // edited in CSV, manual edit in DB
+		4: "Chief",    // unchanged, manual edit in DB
+		5: "Tanker 5", // deleted from CSV
+		8: "Utility",  // unchanged, learned tag in DB
+		9: "Rescue 9", // unchanged, open conflict
+	}
+	csvNow := map[int]string{
+		1: "Engine 1",
+		2: "Medic Two",
+		3: "Ladder Three",
+		4: "Chief",
+		6: "Brush 6", // new row
+		7: "Squad 7", // new row already matching DB
+		8: "Utility",
+		9: "Rescue 9",
+	}
+	dbTags := map[int]database.UnitTagState{
+		1:  {AlphaTag: "Engine 1", Source: "csv"},
+		2:  {AlphaTag: "Medic 2", Source: "csv"},
+		3:  {AlphaTag: "Ladder 3 (spare)", Source: "manual"},
+		4:  {AlphaTag: "Battalion Chief", Source: "manual"},
+		5:  {AlphaTag: "Tanker 5", Source: "csv"},
+		7:  {AlphaTag: "Squad 7", Source: "manual"},
+		8:  {AlphaTag: "UTIL-8", Source: "mqtt"},
+		9:  {AlphaTag: "Rescue Nine", Source: "manual"},
+		10: {AlphaTag: "Learned 10", Source: "mqtt"}, // not in CSV
+		11: {AlphaTag: "Old CSV", Source: "csv"},     // not in CSV, came from it
+	}
+	open := map[int]bool{7: true, 9: true}
+
+	t.Run("import_only", func(t *testing.T) {
+		p := BuildPlan(csvNow, csvPrev, dbTags, open, false)
+		wantImport := map[int]string{2: "Medic Two", 6: "Brush 6", 8: "Utility"}
+		if !reflect.DeepEqual(p.Import, wantImport) {
+			t.Errorf("Import = %v, want %v", p.Import, wantImport)
+		}
+		wantConflicts := map[int][2]string{3: {"Ladder 3 (spare)", "Ladder Three"}}
+		if !reflect.DeepEqual(p.Conflicts, wantConflicts) {
+			t.Errorf("Conflicts = %v, want %v", p.Conflicts, wantConflicts)
+		}
+		if !reflect.DeepEqual(p.Converged, []int{7}) {
+			t.Errorf("Converged = %v, want [7]", p.Converged)
+		}
+		if len(p.Writeback) != 0 {
+			t.Errorf("Writeback = %v, want none", p.Writeback)
+		}
+	})
+
+	t.Run("writeback", func(t *testing.T) {
+		p := BuildPlan(csvNow, csvPrev, dbTags, open, true)
+		want := map[int]string{4: "Battalion Chief", 10: "Learned 10"}
+		if !reflect.DeepEqual(p.Writeback, want) {
+			t.Errorf("Writeback = %v, want %v", p.Writeback, want)
+		}
+	})
+}
@@ -0,0 +1,373 @@
+// Package warehouse exports completed days of history to Parquet files for
+// long-term storage in a data lake.
+//
+// Each dataset (calls, unit_events, transcriptions, call_annotations) is
+// written one UTC day per file in a Hive-style layout, {dataset}/date=YYYY-MM-DD/{dataset}-YYYY-MM-DD.parquet,
+// on local disk or S3, so DuckDB, Athena and Spark can query years of history
+// while Postgres only keeps recent data. A day is exported once
+// WAREHOUSE_EXPORT_DELAY has passed since it ended (late calls and
+// transcriptions have landed); warehouse_exports records what has been
+// written so each day is exported once. The column schema is documented in
+// docs/warehouse.md.
+package warehouse
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/internal/storage"
+)
+
+// SchemaVersion is stored in each file's key/value metadata. Bump it when a
+// dataset's columns change.
+const SchemaVersion = "1"
+
+// Sink stores exported files.
+type Sink interface {
+	Put(ctx context.Context, key string, data []byte) error
+	// Location returns where key is stored (a path or s3:// URL).
+	Location(key string) string
+	Type() string
+}
+
+// LocalSink writes files under a directory.
+type LocalSink struct {
+	dir string
+}
+
+func NewLocalSink(dir string) *LocalSink { return &LocalSink{dir: dir} }
+
+func (s *LocalSink) Put(ctx context.Context, key string, data []byte) error {
+	path := s.Location(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Write then rename so readers never see a partial file.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *LocalSink) Location(key string) string { return filepath.Join(s.dir, filepath.FromSlash(key)) }
+
+func (s *LocalSink) Type() string { return "local" }
+
+// S3Sink writes files to a bucket under a prefix.
+type S3Sink struct {
+	store  *storage.S3Store
+	bucket string
+	prefix string
+}
+
+func NewS3Sink(store *storage.S3Store, bucket, prefix string) *S3Sink {
+	return &S3Sink{store: store, bucket: bucket, prefix: strings.Trim(prefix, "/")}
+}
+
+func (s *S3Sink) objectKey(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return s.prefix + "/" + key
+}
+
+func (s *S3Sink) Put(ctx context.Context, key string, data []byte) error {
+	return s.store.PutObject(ctx, s.objectKey(key), data, "application/vnd.apache.parquet")
+}
+
+func (s *S3Sink) Location(key string) string { return "s3://" + s.bucket + "/" + s.objectKey(key) }
+
+func (s *S3Sink) Type() string { return "s3" }
+
+// Options configures an Exporter.
+type Options struct {
+	Delay        time.Duration // wait after a UTC day ends before exporting it
+	BackfillDays int           // how many past days to export; 0 = all in the database
+	Interval     time.Duration // how often to look for completed days (default 1h)
+}
+
+// RunResult summarizes one export run.
+type RunResult struct {
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Day        string     `json:"day,omitempty"` // set for a single-day re-export
+	Files      int        `json:"files"`
+	Rows       int64      `json:"rows"`
+	Bytes      int64      `json:"bytes"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Status reports the exporter's configuration and progress.
+type Status struct {
+	Sink         string     `json:"sink"`
+	Location     string     `json:"location"`
+	Delay        string     `json:"delay"`
+	BackfillDays int        `json:"backfill_days"`
+	Datasets     []string   `json:"datasets"`
+	Running      bool       `json:"running"`
+	LastRun      *RunResult `json:"last_run"`
+}
+
+// Exporter writes completed days to the warehouse in the background.
+type Exporter struct {
+	db   *database.DB
+	sink Sink
+	opts Options
+	log  zerolog.Logger
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+
+	running atomic.Bool
+
+	mu      sync.Mutex
+	lastRun *RunResult
+}
+
+// New creates an exporter.
+func New(db *database.DB, sink Sink, opts Options, log zerolog.Logger) *Exporter {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Hour
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Exporter{
+		db:     db,
+		sink:   sink,
+		opts:   opts,
+		log:    log.With().Str("component", "warehouse").Logger(),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (e *Exporter) Start() { go e.loop() }
+
+// Stop ends background work, including an export in progress.
+func (e *Exporter) Stop() { e.stopOnce.Do(e.cancel) }
+
+func (e *Exporter) loop() {
+	e.tick() // catch up on startup
+	ticker := time.NewTicker(e.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.tick()
+		case <-e.ctx.Done():
+			return
+		}
+	}
+}
+
+func (e *Exporter) tick() {
+	if !e.running.CompareAndSwap(false, true) {
+		return
+	}
+	defer e.running.Store(false)
+	e.run(nil)
+}
+
+// Run starts an export in the background: of every pending completed day, or
+// with day set, a re-export of that UTC day's datasets (replacing existing
+// files). Returns an error if an export is already in progress.
+func (e *Exporter) Run(day *time.Time) error {
+	if !e.running.CompareAndSwap(false, true) {
+		return fmt.Errorf("warehouse export already in progress")
+	}
+	go func() {
+		defer e.running.Store(false)
+		e.run(day)
+	}()
+	return nil
+}
+
+// run exports pending days, or just day. The caller holds running.
+func (e *Exporter) run(day *time.Time) {
+	res := &RunResult{StartedAt: time.Now()}
+	if day != nil {
+		res.Day = day.UTC().Format("2006-01-02")
+	}
+	e.mu.Lock()
+	e.lastRun = res
+	e.mu.Unlock()
+
+	var err error
+	if day != nil {
+		for _, ds := range database.WarehouseDatasets {
+			if err = e.exportDay(ds, day.UTC().Truncate(24*time.Hour), res); err != nil {
+				break
+			}
+		}
+	} else {
+		err = e.exportPending(res)
+	}
+
+	now := time.Now()
+	e.mu.Lock()
+	res.FinishedAt = &now
+	if err != nil {
+		res.Error = err.Error()
+	}
+	e.mu.Unlock()
+
+	switch {
+	case err != nil:
+		e.log.Warn().Err(err).Int("files", res.Files).Msg("warehouse export failed")
+	case res.Files > 0:
+		e.log.Info().Int("files", res.Files).Int64("rows", res.Rows).Int64("bytes", res.Bytes).Msg("warehouse export complete")
+	}
+}
+
+// exportPending exports every completed day not yet in warehouse_exports.
+func (e *Exporter) exportPending(res *RunResult) error {
+	first, last, ok, err := e.pendingRange(time.Now())
+	if err != nil || !ok {
+		return err
+	}
+	for _, ds := range database.WarehouseDatasets {
+		done, err := e.db.WarehouseExportedDays(e.ctx, ds.Name, first)
+		if err != nil {
+			return fmt.Errorf("list exported days: %w", err)
+		}
+		for d := first; !d.After(last); d = d.Add(24 * time.Hour) {
+			if done[d.Format("2006-01-02")] {
+				continue
+			}
+			if err := e.exportDay(ds, d, res); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pendingRange returns the first and last UTC days eligible for export at
+// now. ok is false when there is nothing to export.
+func (e *Exporter) pendingRange(now time.Time) (first, last time.Time, ok bool, err error) {
+	start, err := e.db.WarehouseDataStart(e.ctx)
+	if err != nil {
+		return first, last, false, fmt.Errorf("find oldest call: %w", err)
+	}
+	if start == nil {
+		return first, last, false, nil
+	}
+	first, last, ok = completedDays(*start, now, e.opts.Delay, e.opts.BackfillDays)
+	return first, last, ok, nil
+}
+
+// completedDays returns the UTC days from dataStart's day through the last
+// day that ended at least delay before now, limited to the backfillDays most
+// recent when backfillDays > 0.
+func completedDays(dataStart, now time.Time, delay time.Duration, backfillDays int) (first, last time.Time, ok bool) {
+	const day = 24 * time.Hour
+	last = now.Add(-delay).UTC().Truncate(day).Add(-day)
+	first = dataStart.UTC().Truncate(day)
+	if backfillDays > 0 {
+		if min := last.Add(-time.Duration(backfillDays-1) * day); first.Before(min) {
+			first = min
+		}
+	}
+	return first, last, !first.After(last)
+}
+
+// exportDay writes one dataset's rows for the UTC day starting at day.
+func (e *Exporter) exportDay(ds database.WarehouseDataset, day time.Time, res *RunResult) error {
+	ctx, cancel := context.WithTimeout(e.ctx, 30*time.Minute)
+	defer cancel()
+
+	d := day.Format("2006-01-02")
+	var buf bytes.Buffer
+	pw, err := NewWriter(&buf, columns(ds), map[string]string{
+		"tr_engine.dataset":        ds.Name,
+		"tr_engine.day":            d,
+		"tr_engine.schema_version": SchemaVersion,
+	})
+	if err != nil {
+		return err
+	}
+	if err := e.db.ScanWarehouseDay(ctx, ds, day, day.Add(24*time.Hour), pw.Write); err != nil {
+		return fmt.Errorf("%s %s: %w", ds.Name, d, err)
+	}
+	if err := pw.Close(); err != nil {
+		return fmt.Errorf("%s %s: %w", ds.Name, d, err)
+	}
+
+	rec := database.WarehouseExport{Dataset: ds.Name, Day: d, Rows: pw.Rows()}
+	if pw.Rows() > 0 {
+		key := fmt.Sprintf("%s/date=%s/%s-%s.parquet", ds.Name, d, ds.Name, d)
+		if err := e.sink.Put(ctx, key, buf.Bytes()); err != nil {
+			return fmt.Errorf("%s %s: write: %w", ds.Name, d, err)
+		}
+		rec.Bytes = int64(buf.Len())
+		rec.Path = e.sink.Location(key)
+	}
+	if err := e.db.RecordWarehouseExport(ctx, rec); err != nil {
+		return fmt.Errorf("%s %s: record export: %w", ds.Name, d, err)
+	}
+
+	e.mu.Lock()
+	if rec.Path != "" {
+		res.Files++
+	}
+	res.Rows += rec.Rows
+	res.Bytes += rec.Bytes
+	e.mu.Unlock()
+	e.log.Debug().Str("dataset", ds.Name).Str("day", d).Int64("rows", rec.Rows).Msg("exported warehouse day")
+	return nil
+}
+
+var columnKinds = map[string]Kind{
+	"int32":     KindInt32,
+	"int64":     KindInt64,
+	"float64":   KindFloat64,
+	"bool":      KindBool,
+	"string":    KindString,
+	"json":      KindJSON,
+	"timestamp": KindTimestamp,
+}
+
+// columns returns the Parquet columns for a dataset.
+func columns(ds database.WarehouseDataset) []Column {
+	cols := make([]Column, len(ds.Columns))
+	for i, c := range ds.Columns {
+		kind, ok := columnKinds[c.Type]
+		if !ok {
+			panic("warehouse: unknown column type " + c.Type)
+		}
+		cols[i] = Column{Name: c.Name, Kind: kind}
+	}
+	return cols
+}
+
+// Status returns the exporter's configuration and last run.
+func (e *Exporter) Status() Status {
+	s := Status{
+		Sink:         e.sink.Type(),
+		Location:     e.sink.Location(""),
+		Delay:        e.opts.Delay.String(),
+		BackfillDays: e.opts.BackfillDays,
+		Running:      e.running.Load(),
+	}
+	for _, ds := range database.WarehouseDatasets {
+		s.Datasets = append(s.Datasets, ds.Name)
+	}
+	e.mu.Lock()
+	if e.lastRun != nil {
+		r := *e.lastRun
+		s.LastRun = &r
+	}
+	e.mu.Unlock()
+	return s
+}
@@ -0,0 +1,73 @@
+package warehouse
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+)
+
+func TestCompletedDays(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	dataStart := time.Date(2026, 2, 20, 17, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		now         time.Time
+		delay       time.Duration
+		backfill    int
+		first, last string
+		ok          bool
+	}{
+		{"before delay passes", time.Date(2026, 3, 1, 1, 0, 0, 0, time.UTC), 2 * time.Hour, 0, "2026-02-20", "2026-02-27", true},
+		{"after delay passes", time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC), 2 * time.Hour, 0, "2026-02-20", "2026-02-28", true},
+		{"backfill limit", time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC), 2 * time.Hour, 3, "2026-02-26", "2026-02-28", true},
+		{"first day not complete", time.Date(2026, 2, 21, 1, 0, 0, 0, time.UTC), 2 * time.Hour, 0, "2026-02-20", "2026-02-19", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, last, ok := completedDays(dataStart, tt.now, tt.delay, tt.backfill)
+			if ok != tt.ok || !first.Equal(day(tt.first)) || !last.Equal(day(tt.last)) {
+				t.Errorf("completedDays = %s, %s, %v; want %s, %s, %v",
+					first.Format("2006-01-02"), last.Format("2006-01-02"), ok, tt.first, tt.last, tt.ok)
+			}
+		})
+	}
+}
+
+func TestDatasetColumns(t *testing.T) {
+	for _, ds := range database.WarehouseDatasets {
+		seen := map[string]bool{}
+		for _, c := range columns(ds) {
+			if seen[c.Name] {
+				t.Errorf("%s: duplicate column %s", ds.Name, c.Name)
+			}
+			seen[c.Name] = true
+		}
+	}
+}
+
+func TestLocalSink(t *testing.T) {
+	dir := t.TempDir()
+	s := NewLocalSink(dir)
+	key := "calls/date=2026-03-01/calls-2026-03-01.parquet"
+	if err := s.Put(context.Background(), key, []byte("PAR1")); err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(dir, "calls", "date=2026-03-01", "calls-2026-03-01.parquet")
+	if s.Location(key) != want {
+		t.Errorf("Location = %q, want %q", s.Location(key), want)
+	}
+	if got, err := os.ReadFile(want); err != nil || string(got) != "PAR1" {
+		t.Errorf("file = %q, %v", got, err)
+	}
+	if _, err := os.Stat(want + ".tmp"); !os.IsNotExist(err) {
+		t.Error("temp file left behind")
+	}
+}
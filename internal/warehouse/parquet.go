@@ -0,0 +1,567 @@
+package warehouse
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// A minimal Parquet writer: flat schemas of nullable columns, PLAIN-encoded
+// values with RLE definition levels in v1 data pages, one GZIP page per
+// column chunk. That is all the exporter needs, and it keeps the format
+// readable by DuckDB, Athena/Trino, Spark and pyarrow without pulling in an
+// Arrow dependency.
+
+// Kind is a column's logical type.
+type Kind int
+
+const (
+	KindInt32     Kind = iota // INT32
+	KindInt64                 // INT64
+	KindFloat64               // DOUBLE
+	KindBool                  // BOOLEAN
+	KindString                // BYTE_ARRAY, UTF-8 string
+	KindJSON                  // BYTE_ARRAY, JSON text
+	KindTimestamp             // INT64 microseconds since the epoch, UTC
+	KindDate                  // INT32 days since the epoch
+)
+
+// Column describes one column of a Parquet file.
+type Column struct {
+	Name string
+	Kind Kind
+}
+
+// parquet.thrift enum values
+const (
+	ptBoolean   = 0
+	ptInt32     = 1
+	ptInt64     = 2
+	ptDouble    = 5
+	ptByteArray = 6
+
+	ctUTF8            = 0
+	ctDate            = 6
+	ctTimestampMicros = 10
+	ctJSON            = 19
+
+	encPlain    = 0
+	encRLE      = 3
+	codecGzip   = 2
+	pageData    = 0
+	repOptional = 1
+)
+
+func (k Kind) physical() int32 {
+	switch k {
+	case KindInt32, KindDate:
+		return ptInt32
+	case KindInt64, KindTimestamp:
+		return ptInt64
+	case KindFloat64:
+		return ptDouble
+	case KindBool:
+		return ptBoolean
+	default:
+		return ptByteArray
+	}
+}
+
+// columnBuffer accumulates one column of the current row group.
+type columnBuffer struct {
+	col    Column
+	defs   []bool // per row: value present
+	values bytes.Buffer
+	bits   []bool // KindBool values
+}
+
+// Writer writes rows to a Parquet file. Rows are buffered and written out a
+// row group at a time; Close writes the footer.
+type Writer struct {
+	w         *countingWriter
+	columns   []*columnBuffer
+	groupRows int
+	rows      int64
+	groups    []rowGroupMeta
+	meta      map[string]string
+
+	// RowGroupSize is the number of rows per row group (default 50000).
+	RowGroupSize int
+}
+
+type rowGroupMeta struct {
+	rows    int64
+	offset  int64
+	chunks  []chunkMeta
+	rawSize int64
+	size    int64
+}
+
+type chunkMeta struct {
+	offset   int64
+	values   int64
+	rawSize  int64
+	compSize int64
+}
+
+// NewWriter starts a Parquet file on w. meta is stored as key/value metadata
+// in the footer.
+func NewWriter(w io.Writer, columns []Column, meta map[string]string) (*Writer, error) {
+	cw := &countingWriter{w: w}
+	if _, err := cw.Write([]byte("PAR1")); err != nil {
+		return nil, err
+	}
+	pw := &Writer{w: cw, meta: meta, RowGroupSize: 50000}
+	for _, c := range columns {
+		pw.columns = append(pw.columns, &columnBuffer{col: c})
+	}
+	return pw, nil
+}
+
+// Write appends a row. values must line up with the columns; nil is NULL.
+// Accepted Go types per kind: integers for Int32/Int64, floats for Float64,
+// bool, string or []byte for String/JSON, time.Time for Timestamp/Date.
+// After an error the file is unusable.
+func (pw *Writer) Write(values []any) error {
+	if len(values) != len(pw.columns) {
+		return fmt.Errorf("parquet: row has %d values, want %d", len(values), len(pw.columns))
+	}
+	for i, v := range values {
+		if err := pw.columns[i].append(v); err != nil {
+			return fmt.Errorf("parquet: column %s: %w", pw.columns[i].col.Name, err)
+		}
+	}
+	pw.groupRows++
+	pw.rows++
+	if pw.groupRows >= pw.RowGroupSize {
+		return pw.flush()
+	}
+	return nil
+}
+
+// Rows returns the number of rows written so far.
+func (pw *Writer) Rows() int64 { return pw.rows }
+
+func (c *columnBuffer) append(v any) error {
+	if v == nil {
+		c.defs = append(c.defs, false)
+		return nil
+	}
+	var b [8]byte
+	switch c.col.Kind {
+	case KindInt32:
+		n, ok := toInt64(v)
+		if !ok || n < math.MinInt32 || n > math.MaxInt32 {
+			return fmt.Errorf("cannot store %T %v as int32", v, v)
+		}
+		binary.LittleEndian.PutUint32(b[:4], uint32(int32(n)))
+		c.values.Write(b[:4])
+	case KindInt64:
+		n, ok := toInt64(v)
+		if !ok {
+			return fmt.Errorf("cannot store %T as int64", v)
+		}
+		binary.LittleEndian.PutUint64(b[:], uint64(n))
+		c.values.Write(b[:])
+	case KindFloat64:
+		var f float64
+		switch x := v.(type) {
+		case float64:
+			f = x
+		case float32:
+			f = float64(x)
+		default:
+			n, ok := toInt64(v)
+			if !ok {
+				return fmt.Errorf("cannot store %T as double", v)
+			}
+			f = float64(n)
+		}
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(f))
+		c.values.Write(b[:])
+	case KindBool:
+		x, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("cannot store %T as boolean", v)
+		}
+		c.bits = append(c.bits, x)
+	case KindString, KindJSON:
+		var s []byte
+		switch x := v.(type) {
+		case string:
+			s = []byte(x)
+		case []byte:
+			s = x
+		default:
+			return fmt.Errorf("cannot store %T as string", v)
+		}
+		binary.LittleEndian.PutUint32(b[:4], uint32(len(s)))
+		c.values.Write(b[:4])
+		c.values.Write(s)
+	case KindTimestamp:
+		t, ok := v.(time.Time)
+		if !ok {
+			return fmt.Errorf("cannot store %T as timestamp", v)
+		}
+		binary.LittleEndian.PutUint64(b[:], uint64(t.UnixMicro()))
+		c.values.Write(b[:])
+	case KindDate:
+		t, ok := v.(time.Time)
+		if !ok {
+			return fmt.Errorf("cannot store %T as date", v)
+		}
+		y, m, d := t.Date()
+		days := time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400
+		binary.LittleEndian.PutUint32(b[:4], uint32(int32(days)))
+		c.values.Write(b[:4])
+	}
+	c.defs = append(c.defs, true)
+	return nil
+}
+
+func toInt64(v any) (int64, bool) {
+	switch x := v.(type) {
+	case int:
+		return int64(x), true
+	case int16:
+		return int64(x), true
+	case int32:
+		return int64(x), true
+	case int64:
+		return x, true
+	case uint32:
+		return int64(x), true
+	}
+	return 0, false
+}
+
+// flush writes the buffered rows as a row group.
+func (pw *Writer) flush() error {
+	if pw.groupRows == 0 {
+		return nil
+	}
+	rg := rowGroupMeta{rows: int64(pw.groupRows), offset: pw.w.n}
+	for _, c := range pw.columns {
+		cm, err := pw.writeChunk(c)
+		if err != nil {
+			return err
+		}
+		rg.chunks = append(rg.chunks, cm)
+		rg.rawSize += cm.rawSize
+		rg.size += cm.compSize
+		c.defs = c.defs[:0]
+		c.values.Reset()
+		c.bits = c.bits[:0]
+	}
+	pw.groups = append(pw.groups, rg)
+	pw.groupRows = 0
+	return nil
+}
+
+// writeChunk writes a column chunk as a single data page.
+func (pw *Writer) writeChunk(c *columnBuffer) (chunkMeta, error) {
+	var page bytes.Buffer
+	levels := encodeLevels(c.defs)
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(len(levels)))
+	page.Write(n[:])
+	page.Write(levels)
+	if c.col.Kind == KindBool {
+		page.Write(packBits(c.bits))
+	} else {
+		page.Write(c.values.Bytes())
+	}
+
+	var comp bytes.Buffer
+	zw := gzip.NewWriter(&comp)
+	if _, err := zw.Write(page.Bytes()); err != nil {
+		return chunkMeta{}, err
+	}
+	if err := zw.Close(); err != nil {
+		return chunkMeta{}, err
+	}
+
+	var hdr thriftWriter
+	hdr.i32(1, pageData)
+	hdr.i32(2, int32(page.Len()))
+	hdr.i32(3, int32(comp.Len()))
+	hdr.beginStruct(5) // DataPageHeader
+	hdr.i32(1, int32(len(c.defs)))
+	hdr.i32(2, encPlain)
+	hdr.i32(3, encRLE)
+	hdr.i32(4, encRLE)
+	hdr.endStruct()
+	hdr.stop()
+
+	cm := chunkMeta{
+		offset:   pw.w.n,
+		values:   int64(len(c.defs)),
+		rawSize:  int64(hdr.buf.Len() + page.Len()),
+		compSize: int64(hdr.buf.Len() + comp.Len()),
+	}
+	if _, err := pw.w.Write(hdr.buf.Bytes()); err != nil {
+		return chunkMeta{}, err
+	}
+	if _, err := pw.w.Write(comp.Bytes()); err != nil {
+		return chunkMeta{}, err
+	}
+	return cm, nil
+}
+
+// encodeLevels RLE-encodes definition levels (bit width 1) as runs in the
+// RLE/bit-packed hybrid encoding.
+func encodeLevels(defs []bool) []byte {
+	var out []byte
+	for i := 0; i < len(defs); {
+		j := i
+		for j < len(defs) && defs[j] == defs[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		if defs[i] {
+			out = append(out, 1)
+		} else {
+			out = append(out, 0)
+		}
+		i = j
+	}
+	return out
+}
+
+// packBits PLAIN-encodes booleans, LSB first.
+func packBits(bits []bool) []byte {
+	out := make([]byte, (len(bits)+7)/8)
+	for i, b := range bits {
+		if b {
+			out[i/8] |= 1 << (i % 8)
+		}
+	}
+	return out
+}
+
+// Close flushes the last row group and writes the footer. It does not close
+// the underlying writer.
+func (pw *Writer) Close() error {
+	if err := pw.flush(); err != nil {
+		return err
+	}
+
+	var t thriftWriter
+	t.i32(1, 1) // version
+	t.beginList(2, thriftStruct, len(pw.columns)+1)
+	// Root schema element
+	t.beginElem()
+	t.str(4, "schema")
+	t.i32(5, int32(len(pw.columns)))
+	t.endElem()
+	for _, c := range pw.columns {
+		t.beginElem()
+		t.i32(1, c.col.Kind.physical())
+		t.i32(3, repOptional)
+		t.str(4, c.col.Name)
+		switch c.col.Kind {
+		case KindString:
+			t.i32(6, ctUTF8)
+			t.beginStruct(10)
+			t.beginStruct(1) // STRING
+			t.endStruct()
+			t.endStruct()
+		case KindJSON:
+			t.i32(6, ctJSON)
+			t.beginStruct(10)
+			t.beginStruct(12) // JSON
+			t.endStruct()
+			t.endStruct()
+		case KindDate:
+			t.i32(6, ctDate)
+			t.beginStruct(10)
+			t.beginStruct(6) // DATE
+			t.endStruct()
+			t.endStruct()
+		case KindTimestamp:
+			t.i32(6, ctTimestampMicros)
+			t.beginStruct(10)
+			t.beginStruct(8) // TIMESTAMP
+			t.boolean(1, true)
+			t.beginStruct(2) // unit
+			t.beginStruct(2) // MICROS
+			t.endStruct()
+			t.endStruct()
+			t.endStruct()
+			t.endStruct()
+		}
+		t.endElem()
+	}
+	t.i64(3, pw.rows)
+	t.beginList(4, thriftStruct, len(pw.groups))
+	for _, rg := range pw.groups {
+		t.beginElem()
+		t.beginList(1, thriftStruct, len(rg.chunks))
+		for i, cm := range rg.chunks {
+			c := pw.columns[i]
+			t.beginElem()
+			t.i64(2, cm.offset)
+			t.beginStruct(3) // ColumnMetaData
+			t.i32(1, c.col.Kind.physical())
+			t.beginList(2, thriftI32, 2)
+			t.listI32(encPlain)
+			t.listI32(encRLE)
+			t.beginList(3, thriftBinary, 1)
+			t.listStr(c.col.Name)
+			t.i32(4, codecGzip)
+			t.i64(5, cm.values)
+			t.i64(6, cm.rawSize)
+			t.i64(7, cm.compSize)
+			t.i64(9, cm.offset)
+			t.endStruct()
+			t.endElem()
+		}
+		t.i64(2, rg.rawSize)
+		t.i64(3, rg.rows)
+		t.i64(5, rg.offset)
+		t.i64(6, rg.size)
+		t.endElem()
+	}
+	if len(pw.meta) > 0 {
+		keys := sortedKeys(pw.meta)
+		t.beginList(5, thriftStruct, len(keys))
+		for _, k := range keys {
+			t.beginElem()
+			t.str(1, k)
+			t.str(2, pw.meta[k])
+			t.endElem()
+		}
+	}
+	t.str(6, "tr-engine")
+	t.stop()
+
+	if _, err := pw.w.Write(t.buf.Bytes()); err != nil {
+		return err
+	}
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(t.buf.Len()))
+	if _, err := pw.w.Write(n[:]); err != nil {
+		return err
+	}
+	_, err := pw.w.Write([]byte("PAR1"))
+	return err
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Thrift compact protocol, just enough for Parquet metadata.
+
+const (
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+type thriftWriter struct {
+	buf   bytes.Buffer
+	last  int16
+	stack []int16
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	if d := id - t.last; d > 0 && d <= 15 {
+		t.buf.WriteByte(byte(d)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(int64(id))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) varint(v int64) {
+	t.buf.Write(binary.AppendUvarint(nil, uint64((v<<1)^(v>>63))))
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(v)
+}
+
+func (t *thriftWriter) boolean(id int16, v bool) {
+	if v {
+		t.field(id, thriftTrue)
+	} else {
+		t.field(id, thriftFalse)
+	}
+}
+
+func (t *thriftWriter) str(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.listStr(s)
+}
+
+func (t *thriftWriter) beginList(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elem)
+	} else {
+		t.buf.WriteByte(0xf0 | elem)
+		t.buf.Write(binary.AppendUvarint(nil, uint64(n)))
+	}
+}
+
+func (t *thriftWriter) listI32(v int32) { t.varint(int64(v)) }
+
+func (t *thriftWriter) listStr(s string) {
+	t.buf.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	t.buf.WriteString(s)
+}
+
+// beginStruct starts a struct-typed field; beginElem starts a struct list
+// element. Both are closed by the matching end call.
+func (t *thriftWriter) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.beginElem()
+}
+
+func (t *thriftWriter) beginElem() {
+	t.stack = append(t.stack, t.last)
+	t.last = 0
+}
+
+func (t *thriftWriter) endStruct() { t.endElem() }
+
+func (t *thriftWriter) endElem() {
+	t.stop()
+	t.last = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *thriftWriter) stop() { t.buf.WriteByte(0) }
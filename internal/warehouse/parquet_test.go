@@ -0,0 +1,197 @@
+package warehouse
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+)
+
+// thriftReader decodes compact-protocol structs into field-id maps, enough
+// to check what Writer produced.
+type thriftReader struct {
+	r *bytes.Reader
+}
+
+func (t *thriftReader) uvarint() uint64 {
+	v, _ := binary.ReadUvarint(t.r)
+	return v
+}
+
+func (t *thriftReader) zigzag() int64 {
+	v := t.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (t *thriftReader) value(typ byte) any {
+	switch typ {
+	case thriftTrue:
+		return true
+	case thriftFalse:
+		return false
+	case thriftI32, thriftI64, 4:
+		return t.zigzag()
+	case thriftBinary:
+		b := make([]byte, t.uvarint())
+		io.ReadFull(t.r, b)
+		return string(b)
+	case thriftList:
+		h, _ := t.r.ReadByte()
+		n := int(h >> 4)
+		if n == 15 {
+			n = int(t.uvarint())
+		}
+		list := make([]any, n)
+		for i := range list {
+			list[i] = t.value(h & 0x0f)
+		}
+		return list
+	case thriftStruct:
+		return t.structure()
+	}
+	panic("unsupported thrift type")
+}
+
+func (t *thriftReader) structure() map[int16]any {
+	m := map[int16]any{}
+	var last int16
+	for {
+		h, err := t.r.ReadByte()
+		if err != nil || h == 0 {
+			return m
+		}
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			id = int16(t.zigzag())
+		}
+		m[id] = t.value(h & 0x0f)
+		last = id
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	columns := []Column{
+		{"call_id", KindInt64},
+		{"tgid", KindInt32},
+		{"encrypted", KindBool},
+		{"tg_alpha_tag", KindString},
+		{"start_time", KindTimestamp},
+		{"duration", KindFloat64},
+	}
+	start := time.Date(2026, 3, 1, 14, 0, 0, 0, time.UTC)
+	rows := [][]any{
+		{int64(1), int32(9131), false, "Fire Dispatch", start, float64(12.5)},
+		{int64(2), int32(9132), true, nil, start.Add(time.Minute), nil},
+		{int64(3), nil, nil, "EMS", start.Add(2 * time.Minute), float32(3)},
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, columns, map[string]string{"schema_version": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.RowGroupSize = 2 // two row groups
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	if string(data[:4]) != "PAR1" || string(data[len(data)-4:]) != "PAR1" {
+		t.Fatal("missing PAR1 magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := data[len(data)-8-footerLen : len(data)-8]
+	meta := (&thriftReader{bytes.NewReader(footer)}).structure()
+
+	if meta[3] != int64(3) {
+		t.Errorf("num_rows = %v, want 3", meta[3])
+	}
+	schema := meta[2].([]any)
+	if len(schema) != len(columns)+1 || schema[0].(map[int16]any)[5] != int64(len(columns)) {
+		t.Fatalf("schema = %v", schema)
+	}
+	for i, c := range columns {
+		if name := schema[i+1].(map[int16]any)[4]; name != c.Name {
+			t.Errorf("schema[%d] = %v, want %s", i+1, name, c.Name)
+		}
+	}
+	groups := meta[4].([]any)
+	if len(groups) != 2 {
+		t.Fatalf("row groups = %d, want 2", len(groups))
+	}
+	if kv := meta[5].([]any)[0].(map[int16]any); kv[1] != "schema_version" || kv[2] != "1" {
+		t.Errorf("key/value metadata = %v", kv)
+	}
+
+	// Read back tgid from the second row group: one row, NULL.
+	// And from the first: 9131, 9132.
+	readInt32 := func(group int) []any {
+		chunk := groups[group].(map[int16]any)[1].([]any)[1].(map[int16]any)[3].(map[int16]any)
+		r := bytes.NewReader(data[chunk[9].(int64):])
+		hdr := (&thriftReader{r}).structure()
+		comp := make([]byte, hdr[3].(int64))
+		io.ReadFull(r, comp)
+		zr, err := gzip.NewReader(bytes.NewReader(comp))
+		if err != nil {
+			t.Fatal(err)
+		}
+		page, _ := io.ReadAll(zr)
+		if int64(len(page)) != hdr[2].(int64) {
+			t.Fatalf("uncompressed size = %d, header says %d", len(page), hdr[2])
+		}
+		n := int(hdr[5].(map[int16]any)[1].(int64))
+		lr := bytes.NewReader(page[4 : 4+binary.LittleEndian.Uint32(page)])
+		values := page[4+binary.LittleEndian.Uint32(page):]
+		var out []any
+		for len(out) < n {
+			h, _ := binary.ReadUvarint(lr)
+			def, _ := lr.ReadByte()
+			for i := 0; i < int(h>>1); i++ {
+				if def == 0 {
+					out = append(out, nil)
+					continue
+				}
+				out = append(out, int32(binary.LittleEndian.Uint32(values)))
+				values = values[4:]
+			}
+		}
+		return out
+	}
+	if got := readInt32(0); len(got) != 2 || got[0] != int32(9131) || got[1] != int32(9132) {
+		t.Errorf("row group 0 tgid = %v", got)
+	}
+	if got := readInt32(1); len(got) != 1 || got[0] != nil {
+		t.Errorf("row group 1 tgid = %v", got)
+	}
+}
+
+func TestWriterRejectsBadValues(t *testing.T) {
+	w, err := NewWriter(io.Discard, []Column{{"tgid", KindInt32}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write([]any{"9131"}); err == nil {
+		t.Error("expected error storing string in int32 column")
+	}
+	if err := w.Write([]any{int64(1) << 40}); err == nil {
+		t.Error("expected error storing out-of-range int32")
+	}
+	if err := w.Write([]any{1, 2}); err == nil {
+		t.Error("expected error for wrong value count")
+	}
+}
+
+func TestEncodeLevels(t *testing.T) {
+	got := encodeLevels([]bool{true, true, false, true})
+	want := []byte{2 << 1, 1, 1 << 1, 0, 1 << 1, 1}
+	if !bytes.Equal(got, want) {
+		t.Errorf("encodeLevels = %v, want %v", got, want)
+	}
+}
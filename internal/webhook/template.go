@@ -0,0 +1,96 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"text/template"
+	"time"
+
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/pkg/events"
+)
+
+// TemplateData is what payload templates are rendered with: the envelope's
+// fields, its data decoded (keys as in the event JSON, e.g.
+// {{.Data.tg_alpha_tag}}), and the line Discord and Slack webhooks send.
+type TemplateData struct {
+	ID        string
+	Type      string
+	SubType   string
+	Timestamp time.Time
+	SystemID  int
+	SiteID    int
+	Tgid      int
+	UnitID    int
+	Emergency bool
+	Data      map[string]any
+	Summary   string
+}
+
+var templateFuncs = template.FuncMap{
+	// json encodes a value, so strings land in the body quoted and escaped.
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"truncate": func(n int, s string) string {
+		if n < 1 {
+			return ""
+		}
+		return truncate(s, n)
+	},
+}
+
+// ParseTemplate parses a "template" format body. Templates see TemplateData
+// and may use json (JSON-encode a value) and truncate (cut a string to n
+// characters). The result must be JSON; it is checked against an empty event
+// of each type, so unknown fields and non-JSON output are caught here.
+func ParseTemplate(text string) (*template.Template, error) {
+	t, err := template.New("payload").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	for _, typ := range database.WebhookEventTypes {
+		if _, err := Render(t, events.Envelope{Type: typ}); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// CheckTemplate reports why text is not a usable payload template.
+func CheckTemplate(text string) error {
+	_, err := ParseTemplate(text)
+	return err
+}
+
+// Render renders a payload template for an event.
+func Render(t *template.Template, env events.Envelope) ([]byte, error) {
+	data := TemplateData{
+		ID:        env.ID,
+		Type:      env.Type,
+		SubType:   env.SubType,
+		Timestamp: env.Timestamp,
+		SystemID:  env.SystemID,
+		SiteID:    env.SiteID,
+		Tgid:      env.Tgid,
+		UnitID:    env.UnitID,
+		Emergency: env.Emergency,
+		Data:      map[string]any{},
+		Summary:   Summary(env),
+	}
+	if len(env.Data) > 0 {
+		if err := json.Unmarshal(env.Data, &data.Data); err != nil {
+			return nil, err
+		}
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, errors.New("template did not produce valid JSON")
+	}
+	return buf.Bytes(), nil
+}
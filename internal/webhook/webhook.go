@@ -0,0 +1,439 @@
+// Package webhook POSTs call_end, transcription and alert events to
+// configured HTTP endpoints: generic JSON receivers, Discord, Slack, or a
+// body built from a per-webhook template (see ParseTemplate).
+//
+// Events from the ingest event bus are matched against the enabled webhooks
+// (event type, system, talkgroups) and each match is stored in
+// webhook_deliveries with the request body, so delivery survives restarts and
+// every attempt is logged. A sender sends due deliveries: 2xx marks one
+// delivered, a 4xx other than 408/429 fails it at once, anything else is
+// retried with exponential backoff until MaxAttempts. Events held back by a
+// talkgroup embargo are delivered when the embargo ends; events restricted by
+// an encryption policy are never sent.
+//
+// A webhook with a secret is signed: X-TR-Engine-Signature is
+// "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body)), where
+// timestamp is the X-TR-Engine-Timestamp header (Unix seconds).
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/api"
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/pkg/events"
+)
+
+// Options configures a Dispatcher.
+type Options struct {
+	Timeout     time.Duration // per request (default 10s)
+	MaxAttempts int           // attempts before a delivery fails (default 8)
+	Interval    time.Duration // how often due deliveries are looked for (default 5s)
+	Buffer      int           // events waiting to be matched (default 1000)
+}
+
+// Dispatcher matches events to webhooks and delivers them.
+type Dispatcher struct {
+	db     *database.DB
+	opts   Options
+	client *http.Client
+	log    zerolog.Logger
+
+	events chan api.SSEEvent
+	wake   chan struct{}
+
+	mu        sync.RWMutex
+	hooks     []database.Webhook
+	templates map[int]*template.Template // by webhook ID, "template" format only
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// New creates a dispatcher. Call Reload and Start before enqueueing events.
+func New(db *database.DB, opts Options, log zerolog.Logger) *Dispatcher {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 8
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 5 * time.Second
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = 1000
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{
+		db:     db,
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+		log:    log.With().Str("component", "webhook").Logger(),
+		events: make(chan api.SSEEvent, opts.Buffer),
+		wake:   make(chan struct{}, 1),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Reload re-reads the enabled webhooks.
+func (d *Dispatcher) Reload(ctx context.Context) error {
+	all, err := d.db.ListWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+	hooks := all[:0]
+	templates := make(map[int]*template.Template)
+	for _, h := range all {
+		if !h.Enabled {
+			continue
+		}
+		if h.Format == "template" {
+			t, err := ParseTemplate(h.Template)
+			if err != nil {
+				d.log.Warn().Err(err).Int("webhook_id", h.ID).Msg("invalid webhook template, webhook skipped")
+				continue
+			}
+			templates[h.ID] = t
+		}
+		hooks = append(hooks, h)
+	}
+	d.mu.Lock()
+	d.hooks, d.templates = hooks, templates
+	d.mu.Unlock()
+	d.Wake()
+	return nil
+}
+
+func (d *Dispatcher) Start() {
+	d.wg.Add(2)
+	go d.matchLoop()
+	go d.sendLoop()
+}
+
+// Stop ends background work. Queued deliveries stay in the database.
+func (d *Dispatcher) Stop() {
+	d.stopOnce.Do(d.cancel)
+	d.wg.Wait()
+}
+
+// Wake makes the sender look for due deliveries now.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Enqueue hands an event bus event to the dispatcher. It never blocks: when
+// the buffer is full the event is dropped and logged.
+func (d *Dispatcher) Enqueue(e api.SSEEvent) {
+	if e.Restricted || !isWebhookEvent(e.Type) {
+		return
+	}
+	d.mu.RLock()
+	n := len(d.hooks)
+	d.mu.RUnlock()
+	if n == 0 {
+		return
+	}
+	select {
+	case d.events <- e:
+	default:
+		d.log.Warn().Str("event_type", e.Type).Str("event_id", e.ID).Msg("webhook queue full, event dropped")
+	}
+}
+
+func isWebhookEvent(typ string) bool {
+	for _, t := range database.WebhookEventTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLoop stores a delivery for each webhook an event matches.
+func (d *Dispatcher) matchLoop() {
+	defer d.wg.Done()
+	for {
+		select {
+		case e := <-d.events:
+			if d.queue(e) > 0 {
+				d.Wake()
+			}
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// queue stores the deliveries for one event and returns how many.
+func (d *Dispatcher) queue(e api.SSEEvent) int {
+	d.mu.RLock()
+	var matched []database.Webhook
+	for _, h := range d.hooks {
+		if h.Matches(e.Type, e.SystemID, e.Tgid) {
+			matched = append(matched, h)
+		}
+	}
+	templates := d.templates
+	d.mu.RUnlock()
+
+	env := Envelope(e)
+	n := 0
+	for _, h := range matched {
+		var body []byte
+		var err error
+		if t := templates[h.ID]; t != nil {
+			body, err = Render(t, env)
+		} else {
+			body, err = Payload(h.Format, env)
+		}
+		if err != nil {
+			d.log.Warn().Err(err).Int("webhook_id", h.ID).Str("event_id", e.ID).Msg("failed to build webhook payload")
+			continue
+		}
+		del := &database.WebhookDelivery{
+			WebhookID:     h.ID,
+			EventID:       e.ID,
+			EventType:     e.Type,
+			Payload:       body,
+			NextAttemptAt: e.VisibleAt, // embargoed events wait for it
+		}
+		ctx, cancel := context.WithTimeout(d.ctx, 10*time.Second)
+		err = d.db.InsertWebhookDelivery(ctx, del)
+		cancel()
+		if err != nil {
+			d.log.Warn().Err(err).Int("webhook_id", h.ID).Str("event_id", e.ID).Msg("failed to queue webhook delivery")
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// sendLoop sends due deliveries when woken and every Interval.
+func (d *Dispatcher) sendLoop() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(d.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-d.wake:
+		case <-d.ctx.Done():
+			return
+		}
+		d.sendDue()
+	}
+}
+
+func (d *Dispatcher) sendDue() {
+	for d.ctx.Err() == nil {
+		due, err := d.db.ListDueWebhookDeliveries(d.ctx, 50)
+		if err != nil {
+			if d.ctx.Err() == nil {
+				d.log.Warn().Err(err).Msg("failed to list due webhook deliveries")
+			}
+			return
+		}
+		for _, del := range due {
+			d.deliver(del)
+		}
+		if len(due) < 50 {
+			return
+		}
+	}
+}
+
+// deliver makes one attempt and records its outcome.
+func (d *Dispatcher) deliver(del database.DueWebhookDelivery) {
+	code, err := d.post(del)
+	attempts := del.Attempts + 1
+	status, next := database.WebhookDelivered, time.Now()
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+		switch {
+		case !Retryable(code) || attempts >= d.opts.MaxAttempts:
+			status = database.WebhookFailed
+			d.log.Warn().Err(err).Int("webhook_id", del.WebhookID).Int64("delivery_id", del.ID).
+				Int("attempts", attempts).Msg("webhook delivery failed")
+		default:
+			status = database.WebhookPending
+			next = next.Add(Backoff(attempts))
+		}
+	}
+	var resp *int
+	if code != 0 {
+		resp = &code
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := d.db.RecordWebhookAttempt(ctx, del.ID, status, resp, errMsg, next); err != nil {
+		d.log.Warn().Err(err).Int64("delivery_id", del.ID).Msg("failed to record webhook attempt")
+	}
+}
+
+// post sends a delivery and returns the response status (0 without a
+// response) and an error unless it was 2xx.
+func (d *Dispatcher) post(del database.DueWebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(d.ctx, d.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, del.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tr-engine-webhook")
+	req.Header.Set("X-TR-Engine-Event", del.EventType)
+	req.Header.Set("X-TR-Engine-Delivery", strconv.FormatInt(del.ID, 10))
+	if del.Secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-TR-Engine-Timestamp", ts)
+		req.Header.Set("X-TR-Engine-Signature", Sign(del.Secret, ts, del.Payload))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	if resp.StatusCode/100 != 2 {
+		msg := strings.TrimSpace(string(body))
+		if msg == "" {
+			return resp.StatusCode, fmt.Errorf("endpoint returned %d", resp.StatusCode)
+		}
+		return resp.StatusCode, fmt.Errorf("endpoint returned %d: %s", resp.StatusCode, msg)
+	}
+	return resp.StatusCode, nil
+}
+
+// Retryable reports whether a failed attempt with this response status (0 =
+// no response) is worth retrying: 4xx responses other than 408 and 429 mean
+// the endpoint refused the request itself.
+func Retryable(code int) bool {
+	if code < 400 || code >= 500 {
+		return true
+	}
+	return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+}
+
+// Backoff is the wait after a delivery's nth failed attempt: 30s doubling
+// each time, at most an hour.
+func Backoff(attempts int) time.Duration {
+	wait := 30 * time.Second
+	for i := 1; i < attempts && wait < time.Hour; i++ {
+		wait *= 2
+	}
+	return min(wait, time.Hour)
+}
+
+// Sign returns the X-TR-Engine-Signature header for a body.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Envelope wraps an event bus event the way the event bridge publishes it.
+func Envelope(e api.SSEEvent) events.Envelope {
+	ts, err := time.Parse(time.RFC3339, e.Timestamp)
+	if err != nil {
+		ts = time.Now().UTC()
+	}
+	return events.Envelope{
+		ID:            e.ID,
+		Type:          e.Type,
+		SubType:       e.SubType,
+		Timestamp:     ts,
+		SchemaVersion: events.SchemaVersion,
+		SystemID:      e.SystemID,
+		SiteID:        e.SiteID,
+		Tgid:          e.Tgid,
+		UnitID:        e.UnitID,
+		Emergency:     e.Emergency,
+		Data:          json.RawMessage(e.Data),
+	}
+}
+
+// Payload builds the request body for a webhook format: the envelope itself
+// for "generic", a one-line message for Discord ("content") and Slack
+// ("text").
+func Payload(format string, env events.Envelope) ([]byte, error) {
+	switch format {
+	case "discord":
+		return json.Marshal(map[string]string{"content": truncate(Summary(env), 2000)})
+	case "slack":
+		return json.Marshal(map[string]string{"text": Summary(env)})
+	default:
+		return json.Marshal(env)
+	}
+}
+
+// Summary describes an event in one line for chat webhooks.
+func Summary(env events.Envelope) string {
+	tg := func(alphaTag string) string {
+		if alphaTag == "" {
+			return fmt.Sprintf("TG %d", env.Tgid)
+		}
+		return fmt.Sprintf("%s (TG %d)", alphaTag, env.Tgid)
+	}
+	emergency := ""
+	if env.Emergency {
+		emergency = "EMERGENCY: "
+	}
+	switch env.Type {
+	case events.TypeCallEnd:
+		var c events.CallEnd
+		if json.Unmarshal(env.Data, &c) == nil {
+			s := fmt.Sprintf("%sCall on %s, %.0fs", emergency, tg(c.TgAlphaTag), c.Duration)
+			if c.UnitAlphaTag != "" {
+				s += " from " + c.UnitAlphaTag
+			} else if c.Unit != 0 {
+				s += fmt.Sprintf(" from unit %d", c.Unit)
+			}
+			return s
+		}
+	case events.TypeTranscription:
+		var t events.Transcription
+		if json.Unmarshal(env.Data, &t) == nil {
+			return fmt.Sprintf("%s%s: %s", emergency, tg(""), t.Text)
+		}
+	case events.TypeAlert:
+		var a events.Alert
+		if json.Unmarshal(env.Data, &a) == nil {
+			return fmt.Sprintf("%sAlert %q on %s: %s", emergency, a.RuleName, tg(a.TgAlphaTag), a.Text)
+		}
+	}
+	return fmt.Sprintf("%s%s on %s", emergency, env.Type, tg(""))
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,144 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/snarg/tr-engine/internal/api"
+	"github.com/snarg/tr-engine/internal/database"
+	"github.com/snarg/tr-engine/pkg/events"
+)
+
+func testEvent(typ string, emergency bool, data any) api.SSEEvent {
+	b, _ := json.Marshal(data)
+	return api.SSEEvent{
+		ID:        "1700000000000-1",
+		Type:      typ,
+		Timestamp: "2026-03-01T12:00:00Z",
+		SystemID:  1,
+		Tgid:      9178,
+		Emergency: emergency,
+		Data:      b,
+	}
+}
+
+func TestPayload(t *testing.T) {
+	env := Envelope(testEvent("call_end", true, events.CallEnd{CallID: 7, TgAlphaTag: "Fire Disp", Duration: 12.4, Unit: 4021}))
+
+	body, err := Payload("generic", env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got events.Envelope
+	if err := json.Unmarshal(body, &got); err != nil || got.ID != "1700000000000-1" || got.Tgid != 9178 ||
+		got.SchemaVersion != events.SchemaVersion {
+		t.Errorf("generic = %s (%v)", body, err)
+	}
+
+	body, _ = Payload("discord", env)
+	if want := `{"content":"EMERGENCY: Call on Fire Disp (TG 9178), 12s from unit 4021"}`; string(body) != want {
+		t.Errorf("discord = %s, want %s", body, want)
+	}
+
+	alert := Envelope(testEvent("alert", false, events.Alert{RuleName: "structure fire", Text: "working fire"}))
+	body, _ = Payload("slack", alert)
+	if want := `{"text":"Alert \"structure fire\" on TG 9178: working fire"}`; string(body) != want {
+		t.Errorf("slack = %s, want %s", body, want)
+	}
+
+	long := Envelope(testEvent("transcription", false, events.Transcription{Text: strings.Repeat("a", 3000)}))
+	body, _ = Payload("discord", long)
+	var msg struct{ Content string }
+	if json.Unmarshal(body, &msg); len([]rune(msg.Content)) != 2000 {
+		t.Errorf("discord message is %d characters, want 2000", len([]rune(msg.Content)))
+	}
+}
+
+func TestBackoffAndRetryable(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{1: 30 * time.Second, 2: time.Minute, 4: 4 * time.Minute, 20: time.Hour} {
+		if got := Backoff(attempts); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+	for code, want := range map[int]bool{0: true, 400: false, 404: false, 408: true, 429: true, 500: true, 503: true} {
+		if got := Retryable(code); got != want {
+			t.Errorf("Retryable(%d) = %v, want %v", code, got, want)
+		}
+	}
+}
+
+func TestPostSigns(t *testing.T) {
+	var gotSig, gotTS, gotEvent string
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSig, gotTS, gotEvent = r.Header.Get("X-TR-Engine-Signature"), r.Header.Get("X-TR-Engine-Timestamp"),
+			r.Header.Get("X-TR-Engine-Event")
+		gotBody, _ = io.ReadAll(r.Body)
+		if strings.Contains(string(gotBody), "refuse") {
+			http.Error(w, "bad payload", http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	d := New(nil, Options{}, zerolog.Nop())
+	del := database.DueWebhookDelivery{ID: 3, EventType: "call_end", Payload: []byte(`{"a":1}`), URL: srv.URL, Secret: "s3cret"}
+	if code, err := d.post(del); code != 200 || err != nil {
+		t.Fatalf("post = %d, %v", code, err)
+	}
+	if gotEvent != "call_end" || gotTS == "" || gotSig != Sign("s3cret", gotTS, gotBody) || !strings.HasPrefix(gotSig, "sha256=") {
+		t.Errorf("event %q, timestamp %q, signature %q", gotEvent, gotTS, gotSig)
+	}
+
+	del.Payload, del.Secret = []byte(`{"refuse":true}`), ""
+	code, err := d.post(del)
+	if code != 400 || err == nil || !strings.Contains(err.Error(), "bad payload") || gotSig != "" {
+		t.Errorf("refused post = %d, %v, signature %q", code, err, gotSig)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	sys := 1
+	h := database.Webhook{EventTypes: []string{"alert", "call_end"}, SystemID: &sys, Tgids: []int{9178}}
+	if !h.Matches("alert", 1, 9178) || h.Matches("transcription", 1, 9178) || h.Matches("alert", 2, 9178) ||
+		h.Matches("alert", 1, 9179) {
+		t.Error("filters not applied")
+	}
+	h.SystemID, h.Tgids = nil, nil
+	if !h.Matches("call_end", 5, 1) {
+		t.Error("unfiltered hook did not match")
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	// ntfy JSON publish, priority from the emergency flag
+	tmpl, err := ParseTemplate(`{"topic":"scanner","priority":{{if .Emergency}}5{{else}}3{{end}},` +
+		`"title":{{json .Data.tg_alpha_tag}},"message":{{json (truncate 10 .Summary)}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := Envelope(testEvent("call_end", true, events.CallEnd{CallID: 7, TgAlphaTag: "Fire \"Disp\"", Duration: 12.4}))
+	body, err := Render(tmpl, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"topic":"scanner","priority":5,"title":"Fire \"Disp\"","message":"EMERGENCY…"}`; string(body) != want {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+
+	for name, text := range map[string]string{
+		"syntax":        `{"a":{{.Tgid}`,
+		"unknown_field": `{"a":{{.Talkgroup}}}`,
+		"not_json":      `tg {{.Tgid}}`,
+	} {
+		if _, err := ParseTemplate(text); err == nil {
+			t.Errorf("%s: template accepted", name)
+		}
+	}
+}
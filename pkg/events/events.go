@@ -0,0 +1,345 @@
+// Package events defines the payloads tr-engine publishes on its event stream
+// (GET /api/v1/events/stream), for consumers that bridge events into other
+// systems (Kafka, stream processors, alerting).
+//
+// Each SSE message carries an event type in its "event:" line and one of the
+// payload structs below, JSON-encoded, in its "data:" line. Decode turns the
+// pair back into a typed value:
+//
+//	payload, err := events.Decode(msg.Event, msg.Data)
+//	if end, ok := payload.(*events.CallEnd); ok { ... }
+//
+// Payloads are versioned by SchemaVersion. Within a version, fields are only
+// ever added; consumers should ignore fields they don't know. Removing or
+// retyping a field bumps the version. Schema returns the JSON Schema for the
+// current version (also served at GET /api/v1/events/schema).
+//
+// This package has no dependencies outside the standard library so external
+// consumers can import it on its own.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SchemaVersion is the version of the event payload schema.
+const SchemaVersion = 1
+
+// Event types, as sent in the SSE "event:" line.
+const (
+	TypeCallStart       = "call_start"
+	TypeCallEnd         = "call_end"
+	TypeTranscription   = "transcription"
+	TypeUnitEvent       = "unit_event"
+	TypeRecorderUpdate  = "recorder_update"
+	TypeRateUpdate      = "rate_update"
+	TypeTrunkingMessage = "trunking_message"
+	TypeConsole         = "console"
+	TypeStuckMic        = "stuck_mic"
+	TypeDiscovery       = "discovery"
+	TypeControlChannel  = "control_channel"
+	TypeOccupancy       = "occupancy"
+	TypeEmergency       = "emergency"
+	TypeAlert           = "alert"
+)
+
+// Envelope wraps a payload with its stream metadata for transports that have
+// no SSE framing (e.g. the Kafka/NATS event bridge). Data holds the payload
+// for Type; decode it with Decode(Type, Data).
+type Envelope struct {
+	ID            string          `json:"event_id" desc:"Unique per event; use it to deduplicate redeliveries"`
+	Type          string          `json:"event_type"`
+	SubType       string          `json:"sub_type,omitempty" desc:"unit_event type (on, off, call, ...)"`
+	Timestamp     time.Time       `json:"timestamp"`
+	SchemaVersion int             `json:"schema_version"`
+	SystemID      int             `json:"system_id,omitempty"`
+	SiteID        int             `json:"site_id,omitempty"`
+	Tgid          int             `json:"tgid,omitempty"`
+	UnitID        int             `json:"unit_id,omitempty"`
+	Emergency     bool            `json:"emergency,omitempty"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// CallStart is published when trunk-recorder starts recording a call.
+type CallStart struct {
+	CallID        int64           `json:"call_id"`
+	SystemID      int             `json:"system_id"`
+	Tgid          int             `json:"tgid"`
+	TgAlphaTag    string          `json:"tg_alpha_tag"`
+	TgTag         string          `json:"tg_tag"`
+	TgGroup       string          `json:"tg_group"`
+	TgDescription string          `json:"tg_description"`
+	Unit          int             `json:"unit" desc:"Radio ID of the initiating unit; 0 if unknown"`
+	UnitAlphaTag  string          `json:"unit_alpha_tag"`
+	Freq          int64           `json:"freq" desc:"Hz"`
+	StartTime     time.Time       `json:"start_time"`
+	Emergency     bool            `json:"emergency"`
+	Encrypted     bool            `json:"encrypted"`
+	Analog        bool            `json:"analog"`
+	Conventional  bool            `json:"conventional"`
+	Phase2TDMA    bool            `json:"phase2_tdma"`
+	AudioType     string          `json:"audio_type"`
+	IncidentData  json.RawMessage `json:"incident_data" desc:"Opaque CAD/incident data passed through from trunk-recorder; null if none"`
+}
+
+// CallEnd is published when a call completes: on MQTT call_end, audio upload,
+// file-watch ingest, or when a stale active call is expired.
+type CallEnd struct {
+	CallID        int64           `json:"call_id"`
+	SystemID      int             `json:"system_id"`
+	Tgid          int             `json:"tgid"`
+	TgAlphaTag    string          `json:"tg_alpha_tag"`
+	Unit          int             `json:"unit" desc:"Radio ID of the initiating unit; 0 if unknown"`
+	UnitAlphaTag  string          `json:"unit_alpha_tag"`
+	Freq          int64           `json:"freq" desc:"Hz"`
+	StartTime     time.Time       `json:"start_time"`
+	StopTime      time.Time       `json:"stop_time"`
+	Duration      float64         `json:"duration" desc:"Seconds"`
+	Emergency     bool            `json:"emergency"`
+	Encrypted     bool            `json:"encrypted"`
+	CallFilename  string          `json:"call_filename" desc:"Audio path as reported by trunk-recorder; empty if unknown"`
+	IncidentData  json.RawMessage `json:"incident_data" desc:"Opaque CAD/incident data passed through from trunk-recorder; null if none"`
+	AudioFilePath string          `json:"audio_file_path,omitempty" desc:"Stored audio path (HTTP upload only)"`
+	Source        string          `json:"source,omitempty" desc:"Ingest path when not trunk-recorder MQTT: upload, file_watch or issi"`
+	Enrichment    json.RawMessage `json:"enrichment,omitempty" desc:"Fields enrichment hooks merged into the call's metadata_json"`
+}
+
+// Urgency is the post-transcription urgency classification.
+type Urgency struct {
+	Score      float32  `json:"score" desc:"0..1"`
+	Label      string   `json:"label" enum:"routine,urgent,emergency_language"`
+	Signals    []string `json:"signals,omitempty"`
+	Classifier string   `json:"classifier,omitempty" enum:"keyword,model"`
+}
+
+// Transcription is published when a call's transcript is stored, either from
+// the STT worker pool or from a transcript supplied by trunk-recorder.
+type Transcription struct {
+	CallID        int64     `json:"call_id"`
+	SystemID      int       `json:"system_id"`
+	Tgid          int       `json:"tgid"`
+	StartTime     time.Time `json:"start_time" desc:"The call's start time"`
+	Text          string    `json:"text"`
+	WordCount     int       `json:"word_count"`
+	Segments      int       `json:"segments,omitempty" desc:"Unit-attributed segments (STT only)"`
+	Model         string    `json:"model,omitempty" desc:"STT model (STT only)"`
+	DurationMs    int       `json:"duration_ms,omitempty" desc:"Total job time (STT only)"`
+	ProviderMs    int       `json:"provider_ms,omitempty" desc:"Time spent in the STT provider (STT only)"`
+	RealTimeRatio float64   `json:"real_time_ratio,omitempty" desc:"provider_ms / call duration (STT only)"`
+	Urgency       *Urgency  `json:"urgency,omitempty"`
+	Source        string    `json:"source,omitempty" desc:"\"source\" when the transcript came from trunk-recorder rather than STT"`
+}
+
+// UnitEvent is published for unit activity on the control channel. EventType
+// is also sent as the SSE sub-type (filter with types=unit_event:<type>).
+type UnitEvent struct {
+	EventType     string          `json:"event_type" enum:"on,off,call,end,join,location,ackresp,data,signal"`
+	SystemID      int             `json:"system_id"`
+	UnitID        int             `json:"unit_id"`
+	UnitAlphaTag  string          `json:"unit_alpha_tag"`
+	Tgid          int             `json:"tgid"`
+	TgAlphaTag    string          `json:"tg_alpha_tag"`
+	Time          time.Time       `json:"time"`
+	IncidentData  json.RawMessage `json:"incident_data" desc:"Opaque CAD/incident data passed through from trunk-recorder; null if none"`
+	SignalingType string          `json:"signaling_type,omitempty" desc:"signal events only"`
+	SignalType    string          `json:"signal_type,omitempty" desc:"signal events only"`
+}
+
+// RecorderUpdate is published for each recorder state report. The call
+// fields are filled when the recorder's frequency matches an active call (or
+// a conventional channel).
+type RecorderUpdate struct {
+	ID           string  `json:"id"`
+	InstanceID   string  `json:"instance_id"`
+	SrcNum       int     `json:"src_num"`
+	RecNum       int     `json:"rec_num"`
+	Type         string  `json:"type"`
+	RecState     string  `json:"rec_state"`
+	Freq         int64   `json:"freq" desc:"Hz"`
+	Duration     float64 `json:"duration"`
+	Count        int     `json:"count"`
+	Squelched    bool    `json:"squelched"`
+	SystemID     int     `json:"system_id,omitempty"`
+	Tgid         int     `json:"tgid,omitempty"`
+	TgAlphaTag   string  `json:"tg_alpha_tag,omitempty"`
+	UnitID       int     `json:"unit_id,omitempty"`
+	UnitAlphaTag string  `json:"unit_alpha_tag,omitempty"`
+}
+
+// RateUpdate is published for each system's control channel decode rate.
+type RateUpdate struct {
+	SystemID           int       `json:"system_id" desc:"0 if the system is not yet known"`
+	SysName            string    `json:"sys_name"`
+	DecodeRate         float32   `json:"decode_rate"`
+	DecodeRateInterval float32   `json:"decode_rate_interval"`
+	ControlChannel     int64     `json:"control_channel" desc:"Hz"`
+	Time               time.Time `json:"time"`
+}
+
+// TrunkingMessage is published for each control channel message.
+type TrunkingMessage struct {
+	SystemID     int       `json:"system_id"`
+	SysName      string    `json:"sys_name"`
+	TrunkMsg     int       `json:"trunk_msg"`
+	TrunkMsgType string    `json:"trunk_msg_type"`
+	Opcode       string    `json:"opcode"`
+	OpcodeType   string    `json:"opcode_type"`
+	OpcodeDesc   string    `json:"opcode_desc"`
+	Time         time.Time `json:"time"`
+}
+
+// Console is published for each trunk-recorder console log line.
+type Console struct {
+	InstanceID string    `json:"instance_id"`
+	Severity   string    `json:"severity"`
+	LogMsg     string    `json:"log_msg"`
+	Time       time.Time `json:"time"`
+}
+
+// StuckMic is published when an active call looks like a stuck microphone:
+// one unit keyed continuously for longer than STUCK_MIC_MIN_DURATION. It is
+// sent once per call, while the call is still in progress.
+type StuckMic struct {
+	CallID       int64     `json:"call_id"`
+	SystemID     int       `json:"system_id"`
+	Tgid         int       `json:"tgid"`
+	TgAlphaTag   string    `json:"tg_alpha_tag"`
+	Unit         int       `json:"unit" desc:"Radio ID of the keyed unit; 0 if unknown"`
+	UnitAlphaTag string    `json:"unit_alpha_tag"`
+	Freq         int64     `json:"freq" desc:"Hz"`
+	StartTime    time.Time `json:"start_time"`
+	Elapsed      float64   `json:"elapsed" desc:"Seconds keyed so far"`
+}
+
+// Discovery is published the first time a talkgroup or unit is heard on a
+// system. Kind is also sent as the SSE sub-type (filter with
+// types=discovery:talkgroup).
+type Discovery struct {
+	Kind      string    `json:"kind" enum:"talkgroup,unit"`
+	SystemID  int       `json:"system_id"`
+	Tgid      int       `json:"tgid" desc:"The new talkgroup, or the talkgroup a new unit was first heard on (0 if none)"`
+	UnitID    int       `json:"unit_id,omitempty" desc:"unit only"`
+	AlphaTag  string    `json:"alpha_tag"`
+	FirstSeen time.Time `json:"first_seen"`
+	Source    string    `json:"source" desc:"Message that introduced it: call_start, call_end, audio, upload, file_watch, issi, or a unit event type"`
+}
+
+// ControlChannel is published when a site's control channel frequency
+// changes, e.g. a failover to an alternate control channel.
+type ControlChannel struct {
+	SystemID int       `json:"system_id"`
+	SiteID   int       `json:"site_id"`
+	SysName  string    `json:"sys_name"`
+	Freq     int64     `json:"freq" desc:"Hz"`
+	PrevFreq int64     `json:"prev_freq" desc:"Hz"`
+	Source   string    `json:"source" enum:"rates,system,trunking_message"`
+	Time     time.Time `json:"time"`
+}
+
+// Occupancy is published when a talkgroup goes active (its first in-progress
+// call starts) or idle (its last in-progress call ends), for occupancy
+// boards. Idle is also sent for completed calls that were never seen in
+// progress (uploads, file watch), so time since the last call stays current.
+type Occupancy struct {
+	SystemID    int       `json:"system_id"`
+	Tgid        int       `json:"tgid"`
+	TgAlphaTag  string    `json:"tg_alpha_tag"`
+	State       string    `json:"state" enum:"active,idle"`
+	ActiveCalls int       `json:"active_calls" desc:"In-progress calls on the talkgroup (several sites may carry it)"`
+	CallID      int64     `json:"call_id" desc:"The call that started (active) or ended (idle)"`
+	Since       time.Time `json:"since" desc:"Start of the call (active) or its stop time (idle)"`
+	Emergency   bool      `json:"emergency"`
+}
+
+// Emergency is published as soon as a call_start or unit event flagged
+// emergency arrives, before it is written to the database, so alerting
+// consumers don't wait on ingest. It carries the names trunk-recorder sent;
+// the call_start that follows has the call_id. Repeats for the same unit and
+// talkgroup within 30 seconds are not sent again.
+type Emergency struct {
+	SystemID     int       `json:"system_id"`
+	Tgid         int       `json:"tgid"`
+	TgAlphaTag   string    `json:"tg_alpha_tag"`
+	Unit         int       `json:"unit" desc:"Radio ID of the unit in emergency; 0 if unknown"`
+	UnitAlphaTag string    `json:"unit_alpha_tag"`
+	Freq         int64     `json:"freq" desc:"Hz; 0 if unknown"`
+	Source       string    `json:"source" desc:"Message that raised it: call_start or a unit event type"`
+	TrCallID     string    `json:"tr_call_id,omitempty" desc:"trunk-recorder call ID (call_start only)"`
+	Time         time.Time `json:"time" desc:"When trunk-recorder reported it"`
+}
+
+// Alert is published when a call's transcript matches an alert rule
+// (GET /api/v1/alert-rules), once per rule matched, after the transcription
+// event. The alert is also stored in the alert history (GET /api/v1/alerts).
+type Alert struct {
+	AlertID    int64     `json:"alert_id"`
+	RuleID     int       `json:"rule_id"`
+	RuleName   string    `json:"rule_name"`
+	CallID     int64     `json:"call_id"`
+	SystemID   int       `json:"system_id"`
+	Tgid       int       `json:"tgid"`
+	TgAlphaTag string    `json:"tg_alpha_tag"`
+	Unit       int       `json:"unit" desc:"Radio ID of the initiating unit; 0 if unknown"`
+	Emergency  bool      `json:"emergency"`
+	Matches    []string  `json:"matches" desc:"Transcript text that matched the rule's keywords or pattern"`
+	Text       string    `json:"text" desc:"The transcript"`
+	StartTime  time.Time `json:"start_time" desc:"The call's start time"`
+}
+
+// registry maps event types to payload constructors and descriptions, in the
+// order they appear in the schema.
+var registry = []struct {
+	typ  string
+	desc string
+	new  func() any
+}{
+	{TypeCallStart, "A call started recording", func() any { return new(CallStart) }},
+	{TypeCallEnd, "A call completed", func() any { return new(CallEnd) }},
+	{TypeTranscription, "A call transcript was stored", func() any { return new(Transcription) }},
+	{TypeUnitEvent, "Unit activity (on, off, call, end, join, location, ackresp, data, signal)", func() any { return new(UnitEvent) }},
+	{TypeRecorderUpdate, "Recorder state report", func() any { return new(RecorderUpdate) }},
+	{TypeRateUpdate, "Control channel decode rate", func() any { return new(RateUpdate) }},
+	{TypeTrunkingMessage, "Control channel message", func() any { return new(TrunkingMessage) }},
+	{TypeConsole, "trunk-recorder console log line", func() any { return new(Console) }},
+	{TypeStuckMic, "An active call looks like a stuck (continuously keyed) microphone", func() any { return new(StuckMic) }},
+	{TypeDiscovery, "A talkgroup or unit was heard on a system for the first time", func() any { return new(Discovery) }},
+	{TypeControlChannel, "A site's control channel frequency changed", func() any { return new(ControlChannel) }},
+	{TypeOccupancy, "A talkgroup went active or idle", func() any { return new(Occupancy) }},
+	{TypeEmergency, "A call or unit declared an emergency (sent ahead of ingest)", func() any { return new(Emergency) }},
+	{TypeAlert, "A call transcript matched an alert rule", func() any { return new(Alert) }},
+}
+
+// Types returns all event types in schema order.
+func Types() []string {
+	out := make([]string, len(registry))
+	for i, r := range registry {
+		out[i] = r.typ
+	}
+	return out
+}
+
+// New returns a pointer to a zero payload for eventType, or nil if the type
+// is unknown.
+func New(eventType string) any {
+	for _, r := range registry {
+		if r.typ == eventType {
+			return r.new()
+		}
+	}
+	return nil
+}
+
+// Decode unmarshals an event's data into its typed payload (a pointer, e.g.
+// *CallEnd).
+func Decode(eventType string, data []byte) (any, error) {
+	v := New(eventType)
+	if v == nil {
+		return nil, fmt.Errorf("unknown event type %q", eventType)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", eventType, err)
+	}
+	return v, nil
+}
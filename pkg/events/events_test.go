@@ -0,0 +1,92 @@
+package events
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSchema_CoversAllTypes(t *testing.T) {
+	s := Schema()
+	evts := s["events"].(map[string]any)
+	defs := s["$defs"].(map[string]any)
+	for _, typ := range Types() {
+		e, ok := evts[typ].(map[string]any)
+		if !ok {
+			t.Errorf("schema missing event %q", typ)
+			continue
+		}
+		ref := e["payload"].(map[string]any)["$ref"].(string)
+		if _, ok := defs[ref[len("#/$defs/"):]]; !ok {
+			t.Errorf("%s: dangling ref %s", typ, ref)
+		}
+	}
+	if _, ok := defs["Urgency"]; !ok {
+		t.Error("nested Urgency struct not in $defs")
+	}
+	if _, err := json.Marshal(s); err != nil {
+		t.Fatalf("schema does not marshal: %v", err)
+	}
+}
+
+// Every key a payload marshals to must be declared in the schema, and every
+// required key must be present.
+func TestSchema_MatchesMarshaledPayloads(t *testing.T) {
+	defs := Schema()["$defs"].(map[string]any)
+	for _, typ := range Types() {
+		v := New(typ)
+		data, _ := json.Marshal(v)
+		var got map[string]any
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+
+		name := reflect.TypeOf(v).Elem().Name()
+		def := defs[name].(map[string]any)
+		props := def["properties"].(map[string]any)
+		for k := range got {
+			if _, ok := props[k]; !ok {
+				t.Errorf("%s: marshaled key %q not in schema", typ, k)
+			}
+		}
+		for _, k := range def["required"].([]string) {
+			if _, ok := got[k]; !ok {
+				t.Errorf("%s: required key %q missing from zero payload", typ, k)
+			}
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	data, _ := json.Marshal(&CallEnd{CallID: 42, SystemID: 1, Tgid: 9178, StartTime: start, Duration: 4.5})
+
+	v, err := Decode(TypeCallEnd, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	end, ok := v.(*CallEnd)
+	if !ok {
+		t.Fatalf("Decode returned %T, want *CallEnd", v)
+	}
+	if end.CallID != 42 || end.Tgid != 9178 || !end.StartTime.Equal(start) || end.Duration != 4.5 {
+		t.Errorf("decoded %+v", end)
+	}
+
+	if _, err := Decode("call_update", data); err == nil {
+		t.Error("expected error for unknown event type")
+	}
+}
+
+func TestTypes_Unique(t *testing.T) {
+	types := Types()
+	sorted := append([]string(nil), types...)
+	sort.Strings(sorted)
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i] == sorted[i-1] {
+			t.Errorf("duplicate event type %q", sorted[i])
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// Schema returns the JSON Schema (draft 2020-12) for all event payloads at
+// SchemaVersion. It is generated from the payload structs, so it always
+// matches what is published. "events" maps each event type to its payload
+// definition under "$defs"; "envelope" describes the bridge wrapper.
+func Schema() map[string]any {
+	defs := map[string]any{}
+	evts := map[string]any{}
+	for _, r := range registry {
+		t := reflect.TypeOf(r.new()).Elem()
+		evts[r.typ] = map[string]any{
+			"description": r.desc,
+			"payload":     map[string]any{"$ref": "#/$defs/" + t.Name()},
+		}
+		structSchema(t, defs)
+	}
+	return map[string]any{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"$id":         fmt.Sprintf("tr-engine/events/v%d", SchemaVersion),
+		"title":       "tr-engine event payloads",
+		"description": "Payloads sent in the data line of GET /api/v1/events/stream, keyed by the SSE event type. Fields may be added within a version; consumers should ignore unknown fields.",
+		"version":     SchemaVersion,
+		"events":      evts,
+		"envelope":    structSchema(reflect.TypeOf(Envelope{}), defs),
+		"$defs":       defs,
+	}
+}
+
+// structSchema adds t (a struct type) and any struct types it references to
+// defs and returns a $ref to it.
+func structSchema(t reflect.Type, defs map[string]any) map[string]any {
+	ref := map[string]any{"$ref": "#/$defs/" + t.Name()}
+	if _, ok := defs[t.Name()]; ok {
+		return ref
+	}
+	props := map[string]any{}
+	var required []string
+	def := map[string]any{"type": "object", "additionalProperties": true}
+	defs[t.Name()] = def // placeholder first, in case of recursion
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		p := typeSchema(f.Type, defs)
+		if d := f.Tag.Get("desc"); d != "" {
+			p["description"] = d
+		}
+		if e := f.Tag.Get("enum"); e != "" {
+			p["enum"] = strings.Split(e, ",")
+		}
+		props[name] = p
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	def["properties"] = props
+	def["required"] = required
+	return ref
+}
+
+func typeSchema(t reflect.Type, defs map[string]any) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawType:
+		return map[string]any{} // any JSON value
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem(), defs)
+	case reflect.Struct:
+		return structSchema(t, defs)
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem(), defs)}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	}
+	return map[string]any{}
+}
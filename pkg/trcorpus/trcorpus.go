@@ -0,0 +1,158 @@
+// Package trcorpus is a versioned corpus of real trunk-recorder MQTT messages
+// with the parse result tr-engine is expected to produce for each, so handler
+// parsing regressions across trunk-recorder versions are caught in tests.
+//
+// The corpus lives in corpus/, one directory per trunk-recorder version
+// (tr-4.7, tr-5.0, ...). Each message is a JSON file:
+//
+//	{
+//	  "topic": "trengine/feeds/call_end",
+//	  "description": "P25 phase 2 call with signal/noise",
+//	  "payload": { ...the MQTT payload as received... }
+//	}
+//
+// next to its golden file, <name>.golden.json, holding the expected parse
+// result. Payloads from other trunk-recorder versions can be checked without
+// rebuilding: lay them out the same way and run
+//
+//	tr-engine conformance -dir ./my-corpus [-update]
+//
+// See docs/conformance.md.
+//
+// This package has no dependencies outside the standard library.
+package trcorpus
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// GoldenSuffix is appended to a case's name to give its golden file.
+const GoldenSuffix = ".golden.json"
+
+//go:embed corpus
+var embedded embed.FS
+
+// Case is one corpus message.
+type Case struct {
+	Name        string          `json:"-"` // path without ".json", e.g. "tr-5.0/call_end"
+	Version     string          `json:"-"` // top-level directory, e.g. "tr-5.0"
+	Topic       string          `json:"topic"`
+	Description string          `json:"description,omitempty"`
+	Payload     json.RawMessage `json:"payload"`
+	Golden      []byte          `json:"-"` // expected parse result; nil without a golden file
+}
+
+// Corpus returns the corpus shipped with this package.
+func Corpus() fs.FS {
+	sub, err := fs.Sub(embedded, "corpus")
+	if err != nil {
+		panic(err) // the embed pattern guarantees the directory
+	}
+	return sub
+}
+
+// Embedded loads the corpus shipped with this package.
+func Embedded() ([]Case, error) { return Load(Corpus()) }
+
+// Load reads every case under fsys, sorted by name.
+func Load(fsys fs.FS) ([]Case, error) {
+	var cases []Case
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(p, ".json") || strings.HasSuffix(p, GoldenSuffix) {
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		var c Case
+		if err := json.Unmarshal(data, &c); err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		if c.Topic == "" || len(c.Payload) == 0 {
+			return fmt.Errorf("%s: topic and payload are required", p)
+		}
+		c.Name = strings.TrimSuffix(p, ".json")
+		if i := strings.IndexByte(c.Name, '/'); i > 0 {
+			c.Version = c.Name[:i]
+		}
+		c.Golden, err = fs.ReadFile(fsys, c.Name+GoldenSuffix)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		cases = append(cases, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(cases, func(i, j int) bool { return cases[i].Name < cases[j].Name })
+	return cases, nil
+}
+
+// Compare reports how got differs from the golden JSON, ignoring formatting
+// and key order; "" when they are equal.
+func Compare(golden, got []byte) string {
+	var want, have any
+	if err := json.Unmarshal(golden, &want); err != nil {
+		return "invalid golden file: " + err.Error()
+	}
+	if err := json.Unmarshal(got, &have); err != nil {
+		return "invalid result: " + err.Error()
+	}
+	if reflect.DeepEqual(want, have) {
+		return ""
+	}
+	wl := strings.Split(string(Format(golden)), "\n")
+	hl := strings.Split(string(Format(got)), "\n")
+	for i := 0; i < len(wl) || i < len(hl); i++ {
+		var w, h string
+		if i < len(wl) {
+			w = wl[i]
+		}
+		if i < len(hl) {
+			h = hl[i]
+		}
+		if w != h {
+			return fmt.Sprintf("line %d:\n- %s\n+ %s", i+1, strings.TrimSpace(w), strings.TrimSpace(h))
+		}
+	}
+	return "results differ"
+}
+
+// Format re-indents JSON the way golden files are written, with sorted
+// keys. Invalid JSON is returned unchanged.
+func Format(data []byte) []byte {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return data
+	}
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return data
+	}
+	return append(out, '\n')
+}
+
+// WriteGolden writes a case's golden file under dir, the directory its
+// corpus was loaded from.
+func WriteGolden(dir string, c Case, result []byte) error {
+	p := filepath.Join(dir, filepath.FromSlash(path.Clean(c.Name)+GoldenSuffix))
+	return os.WriteFile(p, Format(result), 0o644)
+}
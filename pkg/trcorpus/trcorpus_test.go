@@ -0,0 +1,53 @@
+package trcorpus
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"tr-5.1/call_end.json":        {Data: []byte(`{"topic":"a/call_end","payload":{"timestamp":1}}`)},
+		"tr-5.1/call_end.golden.json": {Data: []byte(`{"handler":"call_end"}`)},
+		"tr-4.9/rates.json":           {Data: []byte(`{"topic":"a/rates","description":"d","payload":{}}`)},
+		"README.md":                   {Data: []byte("not a case")},
+	}
+	cases, err := Load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) != 2 || cases[0].Name != "tr-4.9/rates" || cases[1].Name != "tr-5.1/call_end" {
+		t.Fatalf("cases = %+v", cases)
+	}
+	if cases[0].Version != "tr-4.9" || cases[0].Golden != nil || string(cases[1].Golden) != `{"handler":"call_end"}` {
+		t.Errorf("cases = %+v", cases)
+	}
+
+	fsys["tr-5.1/bad.json"] = &fstest.MapFile{Data: []byte(`{"payload":{}}`)}
+	if _, err := Load(fsys); err == nil || !strings.Contains(err.Error(), "tr-5.1/bad.json") {
+		t.Errorf("missing topic: err = %v", err)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	if d := Compare([]byte(`{"a":1,"b":[2,3]}`), []byte("{\n \"b\": [2, 3],\n \"a\": 1\n}")); d != "" {
+		t.Errorf("equal results differ: %s", d)
+	}
+	d := Compare([]byte(`{"a":1,"b":"x"}`), []byte(`{"a":1,"b":"y"}`))
+	if !strings.Contains(d, `- "b": "x"`) || !strings.Contains(d, `+ "b": "y"`) {
+		t.Errorf("diff = %q", d)
+	}
+}
+
+func TestEmbedded(t *testing.T) {
+	cases, err := Embedded()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		if c.Version == "" || c.Golden == nil {
+			t.Errorf("%s: version %q, golden file present %v", c.Name, c.Version, c.Golden != nil)
+		}
+	}
+}
@@ -65,6 +65,14 @@ CREATE TABLE systems (
     name         text,
     sysid        text         NOT NULL DEFAULT '0',
     wacn         text         NOT NULL DEFAULT '0',
+    boundary_geojson jsonb,   -- GeoJSON coverage polygons (e.g. county outlines) for map views
+    ingest_policy text        NOT NULL DEFAULT 'full'   -- how much of each call ingest keeps: audio, transcripts, metadata
+                              CHECK (ingest_policy IN ('full', 'transcript', 'metadata')),
+    -- Display metadata shared by every client (see 68. talkgroup_groups)
+    color         text,                              -- '#rrggbb'
+    icon          text,                              -- icon name; the set is up to clients
+    short_label   text,
+    display_order int          NOT NULL DEFAULT 0,   -- ascending; ties keep the default order
     deleted_at   timestamptz,
     created_at   timestamptz  NOT NULL DEFAULT now(),
     updated_at   timestamptz  NOT NULL DEFAULT now()
@@ -97,6 +105,13 @@ CREATE TABLE sites (
     rfss             smallint,
     p25_site_id      smallint,
     system_type_raw  text,
+    latitude         double precision,
+    longitude        double precision,
+    range_rings_km   real[],
+    color            text,                           -- display metadata, as on systems
+    icon             text,
+    short_label      text,
+    display_order    int          NOT NULL DEFAULT 0,
     first_seen       timestamptz,
     last_seen        timestamptz,
     created_at       timestamptz  NOT NULL DEFAULT now(),
@@ -286,6 +301,16 @@ CREATE TABLE calls (
     audio_type            text,
     audio_file_path       text,
     audio_file_size       int,
+    audio_duration        real,                -- measured from the saved audio (duration is TR's call_length)
+    duration_mismatch     boolean,             -- |duration - audio_duration| exceeded AUDIO_DURATION_TOLERANCE
+    original_duration     real,                -- duration before AUDIO_DURATION_CORRECT replaced it with audio_duration
+    original_stop_time    timestamptz,         -- stop_time before the same correction
+    duration_corrected_at timestamptz,
+    interconnect          boolean,             -- telephone interconnect (phone patch) call, from control channel grants
+    stuck_mic             boolean,             -- continuously-keyed (stuck microphone) call, see STUCK_MIC_*
+    speech_ratio          real,                -- share of the audio containing speech, measured for stuck_mic suspects
+    audio_unusable        text,                -- 'empty' or 'silent' recording, not transcribed (AUDIO_UNUSABLE_CHECK)
+    merged_into           bigint,              -- call_id this call continues (split call rejoined, see call_merges)
     call_filename         text,
     phase2_tdma           boolean,
     tdma_slot             smallint,
@@ -335,6 +360,10 @@ CREATE INDEX idx_calls_call_group       ON calls (call_group_id);
 CREATE INDEX idx_calls_start_time       ON calls (start_time DESC);
 CREATE INDEX idx_calls_emergency        ON calls (start_time DESC) WHERE emergency;
 CREATE INDEX idx_calls_encrypted        ON calls (start_time DESC) WHERE encrypted;
+CREATE INDEX idx_calls_duration_mismatch ON calls (start_time DESC) WHERE duration_mismatch;
+CREATE INDEX idx_calls_interconnect     ON calls (start_time DESC) WHERE interconnect;
+CREATE INDEX idx_calls_stuck_mic        ON calls (start_time DESC) WHERE stuck_mic;
+CREATE INDEX idx_calls_audio_unusable   ON calls (start_time DESC) WHERE audio_unusable IS NOT NULL;
 CREATE INDEX idx_calls_has_transcription ON calls (start_time DESC) WHERE has_transcription;
 CREATE INDEX idx_calls_transcription_status ON calls (transcription_status, start_time DESC)
     WHERE transcription_status <> 'none';
@@ -442,6 +471,7 @@ CREATE INDEX idx_unit_events_system_tgid_time ON unit_events (system_id, tgid, "
     WHERE tgid IS NOT NULL;
 CREATE INDEX idx_unit_events_type_time        ON unit_events (event_type, "time" DESC);
 CREATE INDEX idx_unit_events_unit_time        ON unit_events (unit_rid, "time" DESC);
+CREATE INDEX idx_unit_events_time_brin        ON unit_events USING brin ("time");  -- session compaction scans by time
 
 -- ============================================================
 -- 11. transcriptions (NOT partitioned — lower volume)
@@ -463,6 +493,15 @@ CREATE TABLE transcriptions (
     provider_ms     int,
     words           jsonb,
     search_vector   tsvector,
+    -- Post-transcription urgency classification (URGENCY_CLASSIFIER); NULL when not classified
+    urgency_score       real,
+    urgency_label       text         CHECK (urgency_label IN ('routine', 'urgent', 'emergency_language')),
+    urgency_signals     text[],
+    urgency_classifier  text,
+    -- Human edits (PATCH /calls/{id}/transcript): the primary version edited
+    -- and the word-level diff against it
+    parent_id       int          REFERENCES transcriptions (id) ON DELETE SET NULL,
+    diff            jsonb,
     created_at      timestamptz  NOT NULL DEFAULT now(),
 
     FOREIGN KEY (call_id, call_start_time) REFERENCES calls (call_id, start_time)
@@ -471,6 +510,8 @@ CREATE TABLE transcriptions (
 CREATE INDEX idx_transcriptions_call ON transcriptions (call_id, call_start_time);
 CREATE INDEX idx_transcriptions_search_vector ON transcriptions USING gin (search_vector);
 CREATE INDEX idx_transcriptions_primary ON transcriptions (call_id) WHERE is_primary;
+CREATE INDEX idx_transcriptions_urgency ON transcriptions (urgency_label, call_start_time DESC)
+    WHERE urgency_label IN ('urgent', 'emergency_language');
 
 -- Trigger: auto-update search_vector from text
 CREATE OR REPLACE FUNCTION transcriptions_search_vector_update()
@@ -656,6 +697,1389 @@ CREATE TABLE system_merge_log (
     performed_by      text
 );
 
+-- ============================================================
+-- 21. units_archive (units purged for inactivity)
+-- ============================================================
+
+CREATE TABLE units_archive (
+    system_id         int          NOT NULL,
+    unit_id           int          NOT NULL,
+    alpha_tag         text,
+    alpha_tag_source  text,
+    first_seen        timestamptz,
+    last_seen         timestamptz,
+    archived_at       timestamptz  NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (system_id, unit_id)
+);
+
+-- A unit seen again after archiving gets its archived row back: the tag is
+-- restored when the new row has none or the archived one came from a CSV or
+-- manual edit, and first_seen keeps the earlier time.
+CREATE OR REPLACE FUNCTION units_restore_archived()
+RETURNS TRIGGER AS $$
+DECLARE
+    a units_archive%ROWTYPE;
+BEGIN
+    DELETE FROM units_archive WHERE system_id = NEW.system_id AND unit_id = NEW.unit_id
+    RETURNING * INTO a;
+    IF FOUND THEN
+        IF NULLIF(a.alpha_tag, '') IS NOT NULL
+           AND (COALESCE(a.alpha_tag_source, '') IN ('manual', 'csv') OR NULLIF(NEW.alpha_tag, '') IS NULL) THEN
+            NEW.alpha_tag := a.alpha_tag;
+            NEW.alpha_tag_source := a.alpha_tag_source;
+        END IF;
+        NEW.first_seen := LEAST(NEW.first_seen, a.first_seen);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_units_restore_archived
+    BEFORE INSERT ON units
+    FOR EACH ROW EXECUTE FUNCTION units_restore_archived();
+
+-- ============================================================
+-- 22. unit_merge_log (permanent audit trail)
+-- ============================================================
+
+CREATE TABLE unit_merge_log (
+    id                  serial       PRIMARY KEY,
+    system_id           int          NOT NULL,
+    source_unit_id      int          NOT NULL,
+    target_unit_id      int          NOT NULL,
+    events_moved        int,
+    transmissions_moved int,
+    calls_updated       int,
+    alpha_tag_kept      text,
+    performed_at        timestamptz  NOT NULL DEFAULT now(),
+    performed_by        text
+);
+
+-- ============================================================
+-- 23. ingest_quarantine (MQTT payloads rejected by validation)
+-- ============================================================
+
+CREATE TABLE ingest_quarantine (
+    id               bigserial    PRIMARY KEY,
+    received_at      timestamptz  NOT NULL DEFAULT now(),
+    handler          text         NOT NULL,
+    topic            text         NOT NULL,
+    instance_id      text,
+    payload          bytea        NOT NULL,
+    reasons          text[]       NOT NULL,
+    status           text         NOT NULL DEFAULT 'pending'
+                                  CHECK (status IN ('pending', 'reprocessed', 'failed')),
+    reprocessed_at   timestamptz,
+    reprocess_error  text
+);
+
+CREATE INDEX idx_ingest_quarantine_received ON ingest_quarantine (received_at DESC);
+CREATE INDEX idx_ingest_quarantine_handler  ON ingest_quarantine (handler, received_at DESC);
+
+-- ============================================================
+-- 24. feeds (public talkgroup feeds rendered as JSON/RSS/Atom)
+-- ============================================================
+
+CREATE TABLE feeds (
+    id             serial       PRIMARY KEY,
+    slug           text         NOT NULL UNIQUE,
+    title          text         NOT NULL,
+    description    text,
+    system_id      int          REFERENCES systems (system_id),
+    tgids          int[]        NOT NULL,
+    delay_seconds  int          NOT NULL DEFAULT 0 CHECK (delay_seconds >= 0),
+    max_items      int          NOT NULL DEFAULT 50 CHECK (max_items BETWEEN 1 AND 500),
+    created_at     timestamptz  NOT NULL DEFAULT now(),
+    updated_at     timestamptz  NOT NULL DEFAULT now()
+);
+
+-- ============================================================
+-- 25. unit_csv_state (unit CSV contents as of the last sync)
+--
+-- The baseline for three-way comparison: a CSV row that differs
+-- from its state row was edited in the file since the last sync.
+-- ============================================================
+
+CREATE TABLE unit_csv_state (
+    system_id      int          NOT NULL,
+    unit_id        int          NOT NULL,
+    csv_alpha_tag  text         NOT NULL,
+    synced_at      timestamptz  NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (system_id, unit_id)
+);
+
+-- ============================================================
+-- 26. unit_csv_conflicts (CSV edits that collide with manual edits)
+-- ============================================================
+
+CREATE TABLE unit_csv_conflicts (
+    id             serial       PRIMARY KEY,
+    system_id      int          NOT NULL,
+    unit_id        int          NOT NULL,
+    db_alpha_tag   text,
+    csv_alpha_tag  text,
+    detected_at    timestamptz  NOT NULL DEFAULT now(),
+    resolved_at    timestamptz,
+    resolution     text         CHECK (resolution IN ('kept_db', 'kept_csv', 'converged'))
+);
+
+-- At most one open conflict per unit
+CREATE UNIQUE INDEX uq_unit_csv_conflicts_open ON unit_csv_conflicts (system_id, unit_id) WHERE resolved_at IS NULL;
+
+-- ============================================================
+-- 27. call_index (call_id → start_time for partition pruning)
+--
+-- calls is partitioned by start_time, so a lookup by call_id
+-- alone probes every partition. Single-call lookups resolve the
+-- start_time here first (a PK hit) and then query calls with the
+-- full (call_id, start_time) key, touching one partition.
+-- Maintained by trigger; not partitioned, so it never needs
+-- retention of its own beyond following deletes from calls.
+-- ============================================================
+
+CREATE TABLE call_index (
+    call_id     bigint       PRIMARY KEY,
+    start_time  timestamptz  NOT NULL
+);
+
+CREATE OR REPLACE FUNCTION call_index_sync()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        DELETE FROM call_index WHERE call_id = OLD.call_id AND start_time = OLD.start_time;
+    ELSE
+        INSERT INTO call_index (call_id, start_time) VALUES (NEW.call_id, NEW.start_time)
+        ON CONFLICT (call_id) DO UPDATE SET start_time = EXCLUDED.start_time;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_calls_index
+    AFTER INSERT OR DELETE OR UPDATE OF start_time ON calls
+    FOR EACH ROW EXECUTE FUNCTION call_index_sync();
+
+-- ============================================================
+-- 28. unit_alias_suggestions (unit names inferred from transcripts)
+--
+-- The alias inference job scans attributed transcript segments for
+-- self-identifications ("Engine 31 on scene") by untagged units.
+-- Each (unit, alias) pair is proposed once; unit_alias_evidence
+-- keeps one row per transcription that supports it, so rescans
+-- are idempotent and confidence is computed from the evidence.
+-- ============================================================
+
+CREATE TABLE unit_alias_suggestions (
+    id           serial       PRIMARY KEY,
+    system_id    int          NOT NULL,
+    unit_id      int          NOT NULL,
+    alias        text         NOT NULL,
+    status       text         NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'rejected')),
+    first_seen   timestamptz  NOT NULL,
+    last_seen    timestamptz  NOT NULL,
+    reviewed_at  timestamptz,
+
+    UNIQUE (system_id, unit_id, alias)
+);
+
+CREATE INDEX idx_unit_alias_suggestions_status ON unit_alias_suggestions (status, system_id);
+
+CREATE TABLE unit_alias_evidence (
+    suggestion_id     int          NOT NULL REFERENCES unit_alias_suggestions (id) ON DELETE CASCADE,
+    transcription_id  int          NOT NULL REFERENCES transcriptions (id) ON DELETE CASCADE,
+    call_id           bigint       NOT NULL,
+    call_start_time   timestamptz  NOT NULL,
+    snippet           text         NOT NULL,
+    weight            real         NOT NULL,
+
+    PRIMARY KEY (suggestion_id, transcription_id)
+);
+
+-- ============================================================
+-- 29. ask_audit_log ("ask the archive" questions and answers)
+--
+-- One row per question to POST /api/v1/ask, including failed
+-- ones: who asked (client IP), how many transcripts were
+-- retrieved, what the LLM answered, and which calls it cited.
+-- ============================================================
+
+CREATE TABLE ask_audit_log (
+    id           bigserial    PRIMARY KEY,
+    time         timestamptz  NOT NULL DEFAULT now(),
+    client_ip    text         NOT NULL,
+    question     text         NOT NULL,
+    filters      jsonb,
+    model        text         NOT NULL DEFAULT '',
+    retrieved    int          NOT NULL DEFAULT 0,
+    cited_calls  bigint[]     NOT NULL DEFAULT '{}',
+    answer       text,
+    error        text,
+    latency_ms   int          NOT NULL
+);
+
+CREATE INDEX idx_ask_audit_log_time ON ask_audit_log (time DESC);
+
+-- ============================================================
+-- 30. transcript_embeddings (semantic search; optional)
+--
+-- Not created here: it needs the pgvector extension and the
+-- vector size of the configured model, so tr-engine creates it
+-- at startup only when EMBED_URL is set (see
+-- database.EnsureEmbeddingSchema):
+--
+--   transcription_id int  REFERENCES transcriptions ON DELETE CASCADE
+--   call_id, call_start_time, system_id, tgid   (denormalized)
+--   model            text
+--   embedding        vector(EMBED_DIMENSIONS)
+--   PRIMARY KEY (transcription_id, call_start_time)
+--   PARTITION BY RANGE (call_start_time), monthly, each
+--   partition with an HNSW index (vector_cosine_ops).
+-- ============================================================
+
+-- ============================================================
+-- 31. instance_policies / pending_identities (ingest ACL)
+--
+-- instance_policies overrides INGEST_AUTO_CREATE per instance:
+-- whether messages from that instance_id may auto-create a
+-- system and site for a sys_name not seen from it before.
+-- Denied sys_names are staged in pending_identities (with a
+-- message count) until an admin approves them, maps them to an
+-- existing system, or rejects them.
+-- ============================================================
+
+CREATE TABLE instance_policies (
+    instance_id  text         PRIMARY KEY,
+    auto_create  boolean      NOT NULL,
+    note         text,
+    updated_at   timestamptz  NOT NULL DEFAULT now()
+);
+
+CREATE TABLE pending_identities (
+    id           serial       PRIMARY KEY,
+    instance_id  text         NOT NULL,
+    sys_name     text         NOT NULL,
+    status       text         NOT NULL DEFAULT 'pending'
+                              CHECK (status IN ('pending', 'approved', 'mapped', 'rejected')),
+    messages     bigint       NOT NULL DEFAULT 0,
+    first_seen   timestamptz  NOT NULL,
+    last_seen    timestamptz  NOT NULL,
+    system_id    int,
+    site_id      int,
+    resolved_at  timestamptz,
+
+    UNIQUE (instance_id, sys_name)
+);
+
+CREATE INDEX idx_pending_identities_status ON pending_identities (status, last_seen DESC);
+
+-- ============================================================
+-- 32. unit_encryption_daily (per-unit encryption usage rollup)
+--
+-- Calls grouped by initiating unit, UTC day, and talkgroup,
+-- split into encrypted and clear. The initiating unit is the
+-- first src_list entry, or the unit stored in unit_ids at
+-- call_start for encrypted calls (which never get a src_list).
+-- Rebuilt for recent days hourly by ingest; older days can be
+-- rebuilt via POST /admin/rollups/unit-encryption.
+-- ============================================================
+
+CREATE TABLE unit_encryption_daily (
+    system_id          int      NOT NULL,
+    unit_id            int      NOT NULL,
+    day                date     NOT NULL,
+    tgid               int      NOT NULL,
+    encrypted_calls    int      NOT NULL DEFAULT 0,
+    encrypted_seconds  real     NOT NULL DEFAULT 0,
+    clear_calls        int      NOT NULL DEFAULT 0,
+    clear_seconds      real     NOT NULL DEFAULT 0,
+
+    PRIMARY KEY (system_id, unit_id, day, tgid)
+);
+
+CREATE INDEX idx_unit_encryption_daily_day ON unit_encryption_daily (day, system_id);
+
+-- ============================================================
+-- 33. audio_archive_failures (TR_AUDIO_ARCHIVE copy failures)
+--
+-- With TR_AUDIO_DIR, audio is served from trunk-recorder's
+-- capture directory, which TR may purge. TR_AUDIO_ARCHIVE
+-- copies it into tr-engine's store (setting audio_file_path);
+-- calls whose copy failed are tracked here and retried until
+-- they give up or TR's purge window passes.
+-- ============================================================
+
+CREATE TABLE audio_archive_failures (
+    call_id          bigint       NOT NULL,
+    call_start_time  timestamptz  NOT NULL,
+    attempts         int          NOT NULL DEFAULT 0,
+    last_error       text,
+    last_attempt     timestamptz  NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (call_id, call_start_time)
+);
+
+-- ============================================================
+-- 34. warehouse_exports (WAREHOUSE_EXPORT Parquet files)
+--
+-- One row per dataset and UTC day written to the data
+-- warehouse. Days listed here are not exported again unless
+-- re-exported from the admin API. Empty days are recorded with
+-- row_count = 0 and no file.
+-- ============================================================
+
+CREATE TABLE warehouse_exports (
+    dataset      text         NOT NULL,
+    day          date         NOT NULL,
+    row_count    bigint       NOT NULL DEFAULT 0,
+    size_bytes   bigint       NOT NULL DEFAULT 0,
+    path         text         NOT NULL DEFAULT '',
+    exported_at  timestamptz  NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (dataset, day)
+);
+
+-- ============================================================
+-- 35. subscription_profiles (saved event stream filters)
+--
+-- Named EventFilters that clients subscribe to with
+-- /events/stream?profile=name instead of re-sending query
+-- strings. Profiles are private to the API token that created
+-- them: owner is a hash of the token ('' when auth is off).
+-- ============================================================
+
+CREATE TABLE subscription_profiles (
+    id           serial       PRIMARY KEY,
+    owner        text         NOT NULL,
+    name         text         NOT NULL,
+    description  text,
+    filter       jsonb        NOT NULL DEFAULT '{}',
+    created_at   timestamptz  NOT NULL DEFAULT now(),
+    updated_at   timestamptz  NOT NULL DEFAULT now(),
+
+    UNIQUE (owner, name)
+);
+
+-- ============================================================
+-- 36. encryption_policies (encrypted traffic retention)
+--
+-- What to keep for encrypted calls, per system (tgid 0) or per
+-- talkgroup (overrides the system row):
+--   metadata   — record call metadata (the default with no row)
+--   suppress   — record nothing; calls, audio and encrypted unit
+--                events are dropped at ingest
+--   restricted — record, but only admins (WRITE_TOKEN) can see them
+-- ============================================================
+
+CREATE TABLE encryption_policies (
+    system_id   int          NOT NULL REFERENCES systems (system_id),
+    tgid        int          NOT NULL DEFAULT 0,
+    policy      text         NOT NULL CHECK (policy IN ('metadata', 'suppress', 'restricted')),
+    note        text,
+    updated_at  timestamptz  NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (system_id, tgid)
+);
+
+-- ============================================================
+-- 37. system_timeseries (internal counters at 1-minute resolution)
+--
+-- One row per metric per minute, written by the ingest pipeline:
+-- per-minute rates (calls, messages per handler, batcher rows) and
+-- gauges (queue depths, active calls). label distinguishes series of
+-- the same metric, e.g. the handler name. Purged after
+-- TIMESERIES_RETENTION (default 30 days).
+-- ============================================================
+
+CREATE TABLE system_timeseries (
+    ts      timestamptz       NOT NULL,
+    metric  text              NOT NULL,
+    label   text              NOT NULL DEFAULT '',
+    value   double precision  NOT NULL,
+
+    PRIMARY KEY (metric, label, ts)
+);
+CREATE INDEX idx_system_timeseries_ts ON system_timeseries (ts);
+
+-- ============================================================
+-- 38. talkgroup_storage_policies (per-talkgroup audio storage)
+--
+-- How a talkgroup's call audio is stored at ingest (no row = full):
+--   full       — keep the audio as received
+--   transcode  — re-encode to mono Opus at bitrate bps (needs ffmpeg;
+--                falls back to the original when unavailable)
+--   metadata   — keep no audio; the call record is still created and
+--                the call is not transcribed
+-- Applies to audio tr-engine stores (MQTT audio and HTTP uploads);
+-- watched files stay where trunk-recorder wrote them.
+-- ============================================================
+
+CREATE TABLE talkgroup_storage_policies (
+    system_id   int          NOT NULL REFERENCES systems (system_id),
+    tgid        int          NOT NULL,
+    policy      text         NOT NULL CHECK (policy IN ('full', 'transcode', 'metadata')),
+    bitrate     int,
+    note        text,
+    updated_at  timestamptz  NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (system_id, tgid)
+);
+
+-- ============================================================
+-- 39. control_channel_history (control channel per site)
+--
+-- One row each time a site's control channel frequency changes
+-- (failover to an alternate channel, or back). prev_freq is NULL for
+-- the first channel recorded for a site. source is the message that
+-- reported it: rates, system or trunking_message.
+-- ============================================================
+
+CREATE TABLE control_channel_history (
+    id          bigserial    PRIMARY KEY,
+    site_id     int          NOT NULL REFERENCES sites (site_id) ON DELETE CASCADE,
+    freq        bigint       NOT NULL,
+    prev_freq   bigint,
+    source      text         NOT NULL,
+    "time"      timestamptz  NOT NULL
+);
+
+CREATE INDEX idx_control_channel_history_site_time ON control_channel_history (site_id, "time" DESC);
+CREATE INDEX idx_control_channel_history_time ON control_channel_history ("time" DESC);
+
+-- ============================================================
+-- 40. call_merges (split calls rejoined, see SPLIT_CALL_MAX_GAP)
+--     TR sometimes splits one transmission into two calls seconds
+--     apart. The continuation (merged_call_id) gets calls.merged_into
+--     set and drops out of call lists; neither call is otherwise
+--     changed, so undo just clears merged_into and sets undone_at.
+-- ============================================================
+
+CREATE TABLE call_merges (
+    id                 bigserial    PRIMARY KEY,
+    system_id          int          NOT NULL REFERENCES systems (system_id),
+    tgid               int          NOT NULL,
+    unit_id            int          NOT NULL,
+    call_id            bigint       NOT NULL,
+    call_start_time    timestamptz  NOT NULL,
+    merged_call_id     bigint       NOT NULL,
+    merged_start_time  timestamptz  NOT NULL,
+    gap                real         NOT NULL,   -- seconds from call's stop to merged call's start
+    merged_at          timestamptz  NOT NULL DEFAULT now(),
+    undone_at          timestamptz,
+    undone_by          text
+);
+
+CREATE INDEX idx_call_merges_merged_at ON call_merges (merged_at DESC);
+CREATE INDEX idx_call_merges_call ON call_merges (call_id);
+CREATE UNIQUE INDEX uq_call_merges_active ON call_merges (merged_call_id, merged_start_time)
+    WHERE undone_at IS NULL;
+
+-- ============================================================
+-- 41. status_daily (public status page uptime, GET /api/v1/status)
+--     Once a minute the status sampler adds one minute to each
+--     component's bucket for the current UTC day. Kept 90 days.
+-- ============================================================
+
+CREATE TABLE status_daily (
+    day                  date  NOT NULL,
+    component            text  NOT NULL,
+    operational_minutes  int   NOT NULL DEFAULT 0,
+    degraded_minutes     int   NOT NULL DEFAULT 0,
+    down_minutes         int   NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, component)
+);
+
+-- ============================================================
+-- 42. call_audio_variants (original / transcoded / redacted audio)
+--     One row per stored version of a call's audio. The default
+--     variant is served by /calls/{id}/audio and mirrored in
+--     calls.audio_file_path. Calls without rows serve
+--     audio_file_path as their only ("original") variant.
+-- ============================================================
+
+CREATE TABLE call_audio_variants (
+    id               bigserial    PRIMARY KEY,
+    call_id          bigint       NOT NULL,
+    call_start_time  timestamptz  NOT NULL,
+    variant          text         NOT NULL,
+    audio_path       text         NOT NULL,
+    audio_type       text,
+    audio_size       int,
+    source           text         NOT NULL,
+    is_default       boolean      NOT NULL DEFAULT false,
+    created_at       timestamptz  NOT NULL DEFAULT now(),
+    UNIQUE (call_id, call_start_time, variant)
+);
+
+CREATE UNIQUE INDEX idx_call_audio_variants_default ON call_audio_variants (call_id, call_start_time) WHERE is_default;
+
+-- ============================================================
+-- 43. cad_incidents / cad_incident_calls (CAD pages by email)
+--     Dispatch pages received on CAD_SMTP_LISTEN and parsed with
+--     CAD_PAGE_FORMATS. Each incident is linked to the calls on its
+--     dispatch talkgroups that started within its time window.
+-- ============================================================
+
+CREATE TABLE cad_incidents (
+    id               bigserial    PRIMARY KEY,
+    received_at      timestamptz  NOT NULL DEFAULT now(),
+    format           text         NOT NULL,
+    incident_number  text,
+    incident_type    text,
+    address          text,
+    units            text,
+    dispatched_at    timestamptz  NOT NULL,
+    system_id        int          NOT NULL,
+    tgids            int[]        NOT NULL,
+    window_before_s  int          NOT NULL,
+    window_after_s   int          NOT NULL,
+    fields           jsonb,
+    sender           text,
+    subject          text,
+    body             text         NOT NULL,
+    message_id       text
+);
+
+CREATE UNIQUE INDEX idx_cad_incidents_message_id ON cad_incidents (message_id) WHERE message_id IS NOT NULL;
+CREATE INDEX idx_cad_incidents_dispatched ON cad_incidents (dispatched_at DESC);
+
+CREATE TABLE cad_incident_calls (
+    incident_id      bigint       NOT NULL REFERENCES cad_incidents (id) ON DELETE CASCADE,
+    call_id          bigint       NOT NULL,
+    call_start_time  timestamptz  NOT NULL,
+    PRIMARY KEY (incident_id, call_id)
+);
+
+CREATE INDEX idx_cad_incident_calls_call ON cad_incident_calls (call_id, call_start_time);
+
+-- ============================================================
+-- 44. watch_backfill_files (file-watch backfill report)
+--     One row per file seen by WATCH_BACKFILL_DAYS backfill, with
+--     the outcome of its last attempt. day is the UTC date of the
+--     call's start time. Failed files can be retried via
+--     POST /api/v1/admin/watch-backfill/retry.
+-- ============================================================
+
+CREATE TABLE watch_backfill_files (
+    path         text         PRIMARY KEY,
+    day          date         NOT NULL,
+    instance_id  text         NOT NULL,
+    status       text         NOT NULL,  -- imported, skipped, error
+    reason       text         NOT NULL DEFAULT '',
+    detail       text,
+    attempts     int          NOT NULL DEFAULT 1,
+    first_seen   timestamptz  NOT NULL DEFAULT now(),
+    updated_at   timestamptz  NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_watch_backfill_files_day ON watch_backfill_files (day, status);
+
+-- ============================================================
+-- 45. instance_config_versions (TR config change history)
+--     One row per distinct configuration an instance has reported;
+--     repeats of the latest only update last_seen/times_seen.
+--     Served at GET /api/v1/instances/{id}/config-history.
+-- ============================================================
+
+CREATE TABLE instance_config_versions (
+    instance_id  text         NOT NULL,
+    version      int          NOT NULL,
+    config_hash  text         NOT NULL,  -- sha256 of the canonical config JSON
+    config       jsonb        NOT NULL,  -- the "config" object of the TR config message
+    changes      jsonb        NOT NULL,  -- [{path, old, new}] versus the previous version
+    first_seen   timestamptz  NOT NULL,
+    last_seen    timestamptz  NOT NULL,
+    times_seen   int          NOT NULL DEFAULT 1,
+    PRIMARY KEY (instance_id, version)
+);
+
+-- ============================================================
+-- 46. external_events / external_correlation_rules / external_event_calls
+--     Events from other receivers (ADS-B, AIS, ACARS, ...) posted to
+--     POST /api/v1/external-events. Enabled correlation rules link
+--     each event to the calls on their system and talkgroups that
+--     started within the rule's window, optionally only when the
+--     event's position is near the call's site.
+-- ============================================================
+
+CREATE TABLE external_events (
+    id           bigserial    PRIMARY KEY,
+    source       text         NOT NULL,  -- e.g. adsb, ais, acars
+    kind         text         NOT NULL DEFAULT '',  -- e.g. position, message
+    event_key    text,                   -- client idempotency key, unique per source
+    subject      text         NOT NULL DEFAULT '',  -- aircraft hex, MMSI, ...
+    label        text         NOT NULL DEFAULT '',  -- callsign, vessel name, ...
+    event_time   timestamptz  NOT NULL,
+    latitude     double precision,
+    longitude    double precision,
+    data         jsonb,
+    received_at  timestamptz  NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX idx_external_events_key ON external_events (source, event_key) WHERE event_key IS NOT NULL;
+CREATE INDEX idx_external_events_time ON external_events (event_time DESC);
+CREATE INDEX idx_external_events_received ON external_events (received_at);
+
+CREATE TABLE external_correlation_rules (
+    id               serial       PRIMARY KEY,
+    name             text         NOT NULL,
+    source           text         NOT NULL,
+    kind             text         NOT NULL DEFAULT '',  -- '' = any kind
+    system_id        int          NOT NULL,
+    tgids            int[]        NOT NULL DEFAULT '{}',  -- empty = every talkgroup
+    window_before_s  int          NOT NULL,  -- calls starting this long before the event ...
+    window_after_s   int          NOT NULL,  -- ... to this long after it
+    max_distance_km  double precision,       -- event within this distance of the call's site
+    enabled          boolean      NOT NULL DEFAULT true,
+    created_at       timestamptz  NOT NULL DEFAULT now(),
+    updated_at       timestamptz  NOT NULL DEFAULT now()
+);
+
+CREATE TABLE external_event_calls (
+    event_id         bigint       NOT NULL REFERENCES external_events (id) ON DELETE CASCADE,
+    call_id          bigint       NOT NULL,
+    call_start_time  timestamptz  NOT NULL,
+    rule_id          int          NOT NULL REFERENCES external_correlation_rules (id) ON DELETE CASCADE,
+    PRIMARY KEY (event_id, call_id)
+);
+
+CREATE INDEX idx_external_event_calls_call ON external_event_calls (call_id, call_start_time);
+CREATE INDEX idx_external_event_calls_rule ON external_event_calls (rule_id);
+
+-- ============================================================
+-- 47. maintenance_runs / maintenance_audit / maintenance_task_settings
+--     Every maintenance run, and a write-ahead record of each
+--     destructive action: what it would delete (rows, time range) is
+--     written before it runs, then updated with what it did. Dry runs
+--     (MAINTENANCE_DRY_RUN, MAINTENANCE_OBSERVE_CYCLES, per-task
+--     dry_run) only write the preview. maintenance_task_settings holds
+--     admin overrides of MAINTENANCE_DISABLED_TASKS.
+-- ============================================================
+
+CREATE TABLE maintenance_runs (
+    id           bigserial    PRIMARY KEY,
+    trigger      text         NOT NULL,  -- schedule, manual, disk_usage
+    dry_run      boolean      NOT NULL,
+    started_at   timestamptz  NOT NULL DEFAULT now(),
+    finished_at  timestamptz
+);
+
+CREATE TABLE maintenance_audit (
+    id           bigserial    PRIMARY KEY,
+    run_id       bigint       NOT NULL REFERENCES maintenance_runs (id),
+    task         text         NOT NULL,
+    target       text         NOT NULL,  -- table or partitioned table
+    status       text         NOT NULL,  -- planned, observed, applied, failed
+    planned      bigint       NOT NULL,  -- rows the preview counted
+    affected     bigint,                 -- rows the action changed (applied only)
+    range_start  timestamptz,            -- time span of the planned rows
+    range_end    timestamptz,
+    detail       jsonb,                  -- e.g. partition names
+    error        text,
+    created_at   timestamptz  NOT NULL DEFAULT now(),
+    finished_at  timestamptz
+);
+
+CREATE INDEX idx_maintenance_audit_run ON maintenance_audit (run_id);
+CREATE INDEX idx_maintenance_audit_created ON maintenance_audit (created_at DESC);
+
+CREATE TABLE maintenance_task_settings (
+    task        text         PRIMARY KEY,
+    enabled     boolean      NOT NULL,
+    dry_run     boolean      NOT NULL DEFAULT false,
+    updated_at  timestamptz  NOT NULL DEFAULT now()
+);
+
+-- ============================================================
+-- 48. stt_eval_pairs
+--     Human transcript edits submitted with "eval": true, paired with the
+--     STT output they correct and scored by word error rate. Reported per
+--     provider/model at GET /transcriptions/eval.
+-- ============================================================
+
+CREATE TABLE stt_eval_pairs (
+    id                bigserial    PRIMARY KEY,
+    transcription_id  int          NOT NULL UNIQUE REFERENCES transcriptions (id) ON DELETE CASCADE,  -- human reference
+    hypothesis_id     int          NOT NULL REFERENCES transcriptions (id) ON DELETE CASCADE,         -- STT output
+    call_id           bigint       NOT NULL,
+    call_start_time   timestamptz  NOT NULL,
+    provider          text,
+    model             text,
+    reference         text         NOT NULL,
+    hypothesis        text         NOT NULL,
+    reference_words   int          NOT NULL,  -- normalized words in reference
+    word_errors       int          NOT NULL,  -- substitutions + insertions + deletions
+    created_at        timestamptz  NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_stt_eval_pairs_created ON stt_eval_pairs (created_at DESC);
+CREATE INDEX idx_stt_eval_pairs_model ON stt_eval_pairs (provider, model);
+
+-- ============================================================
+-- 49. stt_prompt_overrides
+--     STT prompt per system (tgid 0) or talkgroup (overrides the system
+--     row), replacing the prompt built from WHISPER_PROMPT_TEMPLATE. The
+--     prompt is itself a template with the same fields.
+-- ============================================================
+
+CREATE TABLE stt_prompt_overrides (
+    system_id   int          NOT NULL REFERENCES systems (system_id),
+    tgid        int          NOT NULL DEFAULT 0,
+    prompt      text         NOT NULL,
+    updated_at  timestamptz  NOT NULL DEFAULT now(),
+    PRIMARY KEY (system_id, tgid)
+);
+
+-- ============================================================
+-- 50. s3_upload_queue (S3_UPLOAD_MODE=async backlog)
+--     Audio saved to the local cache and waiting for its S3 copy.
+--     Highest priority (emergency calls) first, then newest call.
+--     Rows are deleted once uploaded; failed rows have given up and
+--     stay until retried from the admin API.
+-- ============================================================
+
+CREATE TABLE s3_upload_queue (
+    key            text         PRIMARY KEY,
+    content_type   text         NOT NULL DEFAULT '',
+    priority       smallint     NOT NULL DEFAULT 0,
+    call_time      timestamptz  NOT NULL,
+    attempts       int          NOT NULL DEFAULT 0,
+    last_error     text,
+    next_attempt   timestamptz  NOT NULL DEFAULT now(),
+    claimed_until  timestamptz,
+    failed         boolean      NOT NULL DEFAULT false,
+    created_at     timestamptz  NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_s3_upload_queue_next ON s3_upload_queue (priority DESC, call_time DESC) WHERE NOT failed;
+
+-- ============================================================
+-- 51. retranscribe_jobs (bulk re-transcription, /admin/retranscribe-jobs)
+--     Calls in [start_time, end_time) re-run through provider/model,
+--     oldest first; the cursor is the last call finished, so a running
+--     job resumes after a restart. Calls that already have a
+--     transcript from the target provider and model are not counted.
+-- ============================================================
+
+CREATE TABLE retranscribe_jobs (
+    id              serial       PRIMARY KEY,
+    status          text         NOT NULL DEFAULT 'pending'
+                                 CHECK (status IN ('pending', 'running', 'completed', 'cancelled', 'failed')),
+    start_time      timestamptz  NOT NULL,
+    end_time        timestamptz  NOT NULL,
+    system_ids      int[],
+    tgids           int[],
+    provider        text         NOT NULL,
+    model           text         NOT NULL,
+    make_primary    boolean      NOT NULL DEFAULT true,
+    concurrency     int          NOT NULL DEFAULT 1,
+    total_calls     int          NOT NULL DEFAULT 0,
+    audio_seconds   double precision NOT NULL DEFAULT 0,
+    estimated_cost  double precision,
+    processed       int          NOT NULL DEFAULT 0,
+    failed          int          NOT NULL DEFAULT 0,
+    cursor_time     timestamptz,
+    cursor_call_id  bigint,
+    last_error      text,
+    created_at      timestamptz  NOT NULL DEFAULT now(),
+    started_at      timestamptz,
+    finished_at     timestamptz
+);
+
+-- ============================================================
+-- 52. legal_holds (/admin/legal-holds)
+--     A hold covers one call (call_id), or every call matching its
+--     system, talkgroup and [start_time, end_time) range, NULL
+--     matching anything. Calls under an active hold are skipped by
+--     retention purges, unusable-audio deletion and storage policies.
+--     Released holds are kept with who released them.
+-- ============================================================
+
+CREATE TABLE legal_holds (
+    id               serial       PRIMARY KEY,
+    name             text         NOT NULL,
+    reason           text,
+    system_id        int,
+    tgid             int,
+    call_id          bigint,
+    call_start_time  timestamptz,
+    start_time       timestamptz,
+    end_time         timestamptz,
+    created_by       text         NOT NULL,
+    created_at       timestamptz  NOT NULL DEFAULT now(),
+    updated_by       text,
+    updated_at       timestamptz  NOT NULL DEFAULT now(),
+    released_by      text,
+    released_at      timestamptz
+);
+
+-- ============================================================
+-- 53. enrichment_hooks (/admin/enrichment-hooks)
+--     External URLs called synchronously on call_end. Each hook is
+--     POSTed the call and the JSON object it returns is merged into
+--     calls.metadata_json before the call_end event is published.
+-- ============================================================
+
+CREATE TABLE enrichment_hooks (
+    id                 serial       PRIMARY KEY,
+    name               text         NOT NULL UNIQUE,
+    url                text         NOT NULL,
+    headers            jsonb        NOT NULL DEFAULT '{}',  -- header name -> value
+    system_id          int,                                 -- NULL = every system
+    tgids              int[]        NOT NULL DEFAULT '{}',  -- empty = every talkgroup
+    condition          text         NOT NULL DEFAULT '',    -- filter expression on the call_end payload (internal/expr); '' = always
+    timeout_ms         int          NOT NULL DEFAULT 2000,
+    failure_threshold  int          NOT NULL DEFAULT 5,     -- consecutive failures that open the circuit
+    cooldown_s         int          NOT NULL DEFAULT 60,    -- how long an open circuit skips the hook
+    enabled            boolean      NOT NULL DEFAULT true,
+    created_at         timestamptz  NOT NULL DEFAULT now(),
+    updated_at         timestamptz  NOT NULL DEFAULT now()
+);
+
+-- ============================================================
+-- 54. unit_sessions (/unit-sessions, /units/{id}/sessions)
+--     unit_events folded into sessions: a unit on a talkgroup from
+--     start_time to end_time, ended by the unit going off, registering
+--     again, moving to another talkgroup, or going quiet for
+--     UNIT_SESSION_IDLE. Built incrementally by the compactor
+--     (internal/unitsessions) up to unit_session_state.compacted_through;
+--     RETENTION_UNIT_EVENTS only purges raw events before that point.
+-- ============================================================
+
+CREATE TABLE unit_sessions (
+    id          bigserial    PRIMARY KEY,
+    system_id   int          NOT NULL,
+    unit_rid    int          NOT NULL,
+    tgid        int          NOT NULL,
+    start_time  timestamptz  NOT NULL,
+    end_time    timestamptz  NOT NULL,   -- last event so far while open
+    events      int          NOT NULL,   -- unit events folded in
+    calls       int          NOT NULL,   -- of which 'call' events
+    ended_by    text         CHECK (ended_by IN ('off', 'on', 'tgid_change', 'idle')),  -- NULL = open
+
+    UNIQUE (system_id, unit_rid, tgid, start_time)
+);
+CREATE INDEX idx_unit_sessions_unit_time ON unit_sessions (system_id, unit_rid, start_time DESC);
+CREATE INDEX idx_unit_sessions_tgid_time ON unit_sessions (system_id, tgid, start_time DESC);
+CREATE INDEX idx_unit_sessions_end_time  ON unit_sessions (end_time);
+CREATE INDEX idx_unit_sessions_open      ON unit_sessions (system_id, unit_rid) WHERE ended_by IS NULL;
+CREATE TABLE unit_session_state (
+    id                boolean      PRIMARY KEY DEFAULT true CHECK (id),  -- single row
+    compacted_through timestamptz  NOT NULL,  -- unit_events before this are folded in
+    updated_at        timestamptz  NOT NULL DEFAULT now()
+);
+
+-- ============================================================
+-- 55. share_links (/admin/share-links)
+--     Temporary links to a talkgroup's calls, audio and live events
+--     for people without a token. The scope and expiry travel in the
+--     HMAC-signed ?share= token; the row records who issued it and
+--     lets an admin revoke it early.
+-- ============================================================
+
+CREATE TABLE share_links (
+    id               serial       PRIMARY KEY,
+    label            text         NOT NULL,
+    system_id        int,                       -- NULL = the talkgroups on any system
+    tgids            int[]        NOT NULL,
+    history_seconds  int          NOT NULL,     -- calls this far before creation are visible
+    expires_at       timestamptz  NOT NULL,
+    created_by       text         NOT NULL,
+    created_at       timestamptz  NOT NULL DEFAULT now(),
+    revoked_by       text,
+    revoked_at       timestamptz
+);
+
+-- ============================================================
+-- 56. sources (/instances/{id}/sources)
+--     SDR sources from each TR instance's config: the MQTT config
+--     message, or config.json under TR_DIR (as WATCH_INSTANCE_ID).
+--     Calls and recorders name their source by src_num, so per-source
+--     call counts and error rates join on (instance_id, src_num).
+--     Sources dropped from the config are kept with active = false.
+-- ============================================================
+
+CREATE TABLE sources (
+    instance_id        text         NOT NULL,
+    source_num         int          NOT NULL,               -- TR's source index (calls.src_num, recorder src_num)
+    device             text         NOT NULL DEFAULT '',    -- e.g. rtl=0, serial=...
+    driver             text         NOT NULL DEFAULT '',
+    antenna            text         NOT NULL DEFAULT '',
+    center_hz          bigint       NOT NULL,
+    rate_hz            bigint       NOT NULL,
+    min_hz             bigint       NOT NULL,               -- coverage: center -/+ half the sample rate
+    max_hz             bigint       NOT NULL,
+    error_hz           int          NOT NULL DEFAULT 0,     -- configured tuning correction
+    gain               text         NOT NULL DEFAULT '',
+    analog_recorders   int          NOT NULL DEFAULT 0,
+    digital_recorders  int          NOT NULL DEFAULT 0,
+    origin             text         NOT NULL CHECK (origin IN ('mqtt', 'tr_dir')),
+    active             boolean      NOT NULL DEFAULT true,  -- in the instance's latest config
+    first_seen         timestamptz  NOT NULL DEFAULT now(),
+    last_seen          timestamptz  NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (instance_id, source_num)
+);
+
+-- ============================================================
+-- 57. call_annotations (/calls/{id}/annotations)
+--     Typed, machine-written notes on calls posted by bots and
+--     external decoders ("tone detected", "plate matched"), kept
+--     apart from transcripts and human edits. type is a short slug
+--     for filtering (GET /calls?annotation_type=); data holds the
+--     poster's own payload.
+-- ============================================================
+
+CREATE TABLE call_annotations (
+    id               bigserial    PRIMARY KEY,
+    call_id          bigint       NOT NULL,
+    call_start_time  timestamptz  NOT NULL,
+    type             text         NOT NULL,               -- e.g. tone_detected, plate_match
+    source           text         NOT NULL,               -- poster, e.g. a bot name
+    label            text         NOT NULL DEFAULT '',    -- human-readable summary
+    confidence       real,                                -- 0..1 when the poster has one
+    data             jsonb,
+    created_at       timestamptz  NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_call_annotations_call ON call_annotations (call_id, call_start_time);
+CREATE INDEX idx_call_annotations_type ON call_annotations (type, call_start_time DESC);
+
+-- ============================================================
+-- 58. retention_settings (/admin/retention)
+--     Admin overrides of the RETENTION_* settings, one row per
+--     retention target (a table, or call_audio). Read at the start
+--     of each maintenance run; deleting a row returns the target to
+--     its configured retention. 0 keeps data forever where the
+--     target allows it (calls, call_audio, ...).
+-- ============================================================
+
+CREATE TABLE retention_settings (
+    target             text         PRIMARY KEY,
+    retention_seconds  bigint       NOT NULL CHECK (retention_seconds >= 0),
+    updated_at         timestamptz  NOT NULL DEFAULT now()
+);
+
+-- ============================================================
+-- 59. metrics_talkgroups (/admin/metrics/talkgroups)
+--     Talkgroups exported as per-talkgroup Prometheus series
+--     (tr_engine_talkgroup_*). An allowlist keeps label cardinality
+--     bounded; at most METRICS_TALKGROUP_LIMIT rows are accepted.
+-- ============================================================
+
+CREATE TABLE metrics_talkgroups (
+    system_id   int          NOT NULL,
+    tgid        int          NOT NULL,
+    created_at  timestamptz  NOT NULL DEFAULT now(),
+    PRIMARY KEY (system_id, tgid)
+);
+
+-- ============================================================
+-- 60. call_pins (/calls/{id}/pin)
+--     Calls an operator has pinned. With AUDIO_RETENTION_KEEP_PINNED
+--     (default) audio retention, the disk-usage audio purge and the
+--     call purge skip pinned calls.
+-- ============================================================
+
+CREATE TABLE call_pins (
+    call_id          bigint       NOT NULL,
+    call_start_time  timestamptz  NOT NULL,
+    note             text         NOT NULL DEFAULT '',
+    pinned_by        text         NOT NULL DEFAULT '',
+    created_at       timestamptz  NOT NULL DEFAULT now(),
+    PRIMARY KEY (call_id, call_start_time)
+);
+
+-- ============================================================
+-- 61. call_replications (REPLICATE_URL edge-to-central sync)
+--     Per-call replication state on an edge instance pushing its
+--     calls to a central tr-engine's upload API. A row appears when
+--     a call is first attempted: pending (in progress or failing;
+--     session_path is the central upload session to resume), done
+--     (the central stored it, or already had it: remote_call_id is
+--     its call_id there) or rejected (refused by the central, not
+--     retried until reset).
+-- ============================================================
+
+CREATE TABLE call_replications (
+    call_id          bigint       NOT NULL,
+    call_start_time  timestamptz  NOT NULL,
+    state            text         NOT NULL DEFAULT 'pending',  -- pending, done, rejected
+    session_path     text,
+    remote_call_id   bigint,
+    bytes_sent       bigint       NOT NULL DEFAULT 0,
+    attempts         int          NOT NULL DEFAULT 0,
+    last_error       text,
+    updated_at       timestamptz  NOT NULL DEFAULT now(),
+    PRIMARY KEY (call_id, call_start_time)
+);
+
+CREATE INDEX idx_call_replications_state ON call_replications (state, call_start_time);
+
+-- ============================================================
+-- 62. saved_queries (/saved-queries)
+--
+--     Named call/search filters run by ID with ?saved_query= on
+--     /calls, /calls/timeline and /transcriptions/search. filter
+--     maps query parameter names to values; start_time/end_time
+--     may be durations relative to when the query runs ("-12h").
+--     Owned by the API token that created them (owner is a hash
+--     of the token, '' when auth is off, as in
+--     subscription_profiles); shared queries are visible to all.
+-- ============================================================
+
+CREATE TABLE saved_queries (
+    id           serial       PRIMARY KEY,
+    owner        text         NOT NULL,
+    name         text         NOT NULL,
+    description  text,
+    filter       jsonb        NOT NULL DEFAULT '{}',
+    shared       boolean      NOT NULL DEFAULT false,
+    created_at   timestamptz  NOT NULL DEFAULT now(),
+    updated_at   timestamptz  NOT NULL DEFAULT now(),
+
+    UNIQUE (owner, name)
+);
+
+-- ============================================================
+-- 63. talkgroup_embargoes (listener delay for compliance)
+--
+--     Calls on an embargoed talkgroup, with their audio and
+--     transcripts, reach non-admin tokens (and public feeds) only
+--     delay_minutes after the call ends; live events for the
+--     talkgroup are held back as long. Admins (WRITE_TOKEN) are
+--     unaffected. Evaluated at query time, so adding or removing
+--     an embargo applies to calls already recorded.
+-- ============================================================
+
+CREATE TABLE talkgroup_embargoes (
+    system_id      int          NOT NULL REFERENCES systems (system_id),
+    tgid           int          NOT NULL,
+    delay_minutes  int          NOT NULL CHECK (delay_minutes BETWEEN 1 AND 10080),
+    note           text,
+    updated_at     timestamptz  NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (system_id, tgid)
+);
+
+-- ============================================================
+-- 64. forward_systems / call_forwards (FORWARD_URL rdio-scanner relay)
+--
+--     forward_systems lists the systems whose calls are forwarded
+--     to a downstream rdio-scanner; remote_system is the system ID
+--     there (NULL = the same system_id). Only calls that start
+--     after enabled_at are sent. call_forwards is the per-call
+--     retry queue: pending (failing, retried with backoff), done
+--     (accepted downstream) or rejected (refused, not retried
+--     until reset).
+-- ============================================================
+
+CREATE TABLE forward_systems (
+    system_id      int          PRIMARY KEY REFERENCES systems (system_id),
+    enabled        boolean      NOT NULL DEFAULT true,
+    remote_system  int,
+    enabled_at     timestamptz  NOT NULL DEFAULT now(),
+    updated_at     timestamptz  NOT NULL DEFAULT now()
+);
+
+CREATE TABLE call_forwards (
+    call_id          bigint       NOT NULL,
+    call_start_time  timestamptz  NOT NULL,
+    state            text         NOT NULL DEFAULT 'pending',  -- pending, done, rejected
+    attempts         int          NOT NULL DEFAULT 0,
+    last_error       text,
+    updated_at       timestamptz  NOT NULL DEFAULT now(),
+    PRIMARY KEY (call_id, call_start_time)
+);
+
+CREATE INDEX idx_call_forwards_state ON call_forwards (state, call_start_time);
+
+-- ============================================================
+-- 65. duplicate_call_findings / duplicate_call_actions
+--     (nightly duplicate call audit, /admin/duplicate-audit)
+--
+--     Pairs of calls on the same system and talkgroup that overlap
+--     in time but aren't in the same call group, scored by how
+--     likely they are the same transmission. Grouping moves the
+--     duplicate (dup_call_id) into the call's group; ungroup
+--     restores previous_group_id. duplicate_call_actions is the
+--     audit trail of every grouping, dismissal and ungrouping,
+--     automatic or by API.
+-- ============================================================
+
+CREATE TABLE duplicate_call_findings (
+    id                 bigserial    PRIMARY KEY,
+    system_id          int          NOT NULL REFERENCES systems (system_id),
+    tgid               int          NOT NULL,
+    call_id            bigint       NOT NULL,
+    call_start_time    timestamptz  NOT NULL,
+    dup_call_id        bigint       NOT NULL,
+    dup_start_time     timestamptz  NOT NULL,
+    confidence         real         NOT NULL,
+    start_gap          real         NOT NULL,   -- seconds between the two starts
+    overlap_ratio      real         NOT NULL,   -- overlap / longer call's duration
+    duration_ratio     real         NOT NULL,   -- shorter / longer duration
+    same_unit          boolean,                 -- initiating units match (NULL = unknown)
+    status             text         NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'grouped', 'dismissed')),
+    group_id           int,                     -- call group the duplicate was moved into
+    previous_group_id  int,                     -- the duplicate's group before, restored by ungroup
+    found_at           timestamptz  NOT NULL DEFAULT now(),
+    resolved_at        timestamptz,
+    resolved_by        text,                    -- auto or api
+    UNIQUE (call_id, call_start_time, dup_call_id, dup_start_time)
+);
+
+CREATE INDEX idx_duplicate_call_findings_status ON duplicate_call_findings (status, call_start_time DESC);
+
+CREATE TABLE duplicate_call_actions (
+    id                 bigserial    PRIMARY KEY,
+    finding_id         bigint       NOT NULL REFERENCES duplicate_call_findings (id) ON DELETE CASCADE,
+    action             text         NOT NULL,   -- grouped, dismissed, ungrouped
+    actor              text         NOT NULL,   -- auto or api
+    from_group_id      int,
+    to_group_id        int,
+    created_at         timestamptz  NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_duplicate_call_actions_finding ON duplicate_call_actions (finding_id);
+
+-- ============================================================
+-- 66. alert_rules / alerts (/alert-rules, /alerts)
+--
+--     Rules each new transcript is checked against: keywords
+--     and/or a regular expression on the text, narrowed by system,
+--     talkgroups, units (any unit on the call) and the emergency
+--     flag. A match is stored in alerts and published as an
+--     "alert" event. Alerts keep the rule name, so history
+--     survives deleting the rule.
+-- ============================================================
+
+CREATE TABLE alert_rules (
+    id              serial       PRIMARY KEY,
+    name            text         NOT NULL UNIQUE,
+    keywords        text[]       NOT NULL DEFAULT '{}',   -- any of these words/phrases, case-insensitive
+    pattern         text         NOT NULL DEFAULT '',     -- RE2 regular expression; '' = none
+    system_id       int,                                  -- NULL = every system
+    tgids           int[]        NOT NULL DEFAULT '{}',   -- empty = every talkgroup
+    unit_ids        int[]        NOT NULL DEFAULT '{}',   -- empty = any unit
+    emergency_only  boolean      NOT NULL DEFAULT false,
+    enabled         boolean      NOT NULL DEFAULT true,
+    created_at      timestamptz  NOT NULL DEFAULT now(),
+    updated_at      timestamptz  NOT NULL DEFAULT now()
+)
+;
+
+CREATE TABLE alerts (
+    id               bigserial    PRIMARY KEY,
+    rule_id          int          REFERENCES alert_rules (id) ON DELETE SET NULL,
+    rule_name        text         NOT NULL,               -- kept after the rule is deleted
+    call_id          bigint       NOT NULL,
+    call_start_time  timestamptz  NOT NULL,
+    system_id        int          NOT NULL,
+    tgid             int          NOT NULL,
+    unit_id          int          NOT NULL DEFAULT 0,     -- initiating unit, 0 = unknown
+    emergency        boolean      NOT NULL DEFAULT false,
+    matches          text[]       NOT NULL DEFAULT '{}',
+    text             text         NOT NULL,               -- the transcript that matched
+    created_at       timestamptz  NOT NULL DEFAULT now()
+)
+;
+
+CREATE INDEX idx_alerts_created ON alerts (created_at DESC);
+CREATE INDEX idx_alerts_rule ON alerts (rule_id, created_at DESC);
+CREATE INDEX idx_alerts_call ON alerts (call_id, call_start_time);
+
+-- ============================================================
+-- 67. webhooks / webhook_deliveries (/webhooks, /webhook-deliveries)
+--
+--     HTTP endpoints (generic JSON, Discord or Slack) that are
+--     POSTed call_end, transcription and alert events, narrowed
+--     by system and talkgroups. Each matching event becomes a
+--     webhook_deliveries row: pending until the endpoint answers
+--     2xx (delivered), retried with backoff, failed after the
+--     last attempt or a 4xx refusal. The payload is kept so a
+--     failed delivery can be retried as it was.
+-- ============================================================
+
+CREATE TABLE webhooks (
+    id           serial       PRIMARY KEY,
+    name         text         NOT NULL UNIQUE,
+    url          text         NOT NULL,
+    format       text         NOT NULL DEFAULT 'generic' CHECK (format IN ('generic', 'discord', 'slack', 'template')),
+    template     text         NOT NULL DEFAULT '',     -- Go template for the body, format 'template'
+    event_types  text[]       NOT NULL,                -- call_end, transcription, alert
+    system_id    int,                                  -- NULL = every system
+    tgids        int[]        NOT NULL DEFAULT '{}',   -- empty = every talkgroup
+    secret       text         NOT NULL DEFAULT '',     -- HMAC-SHA256 signing key; '' = unsigned
+    enabled      boolean      NOT NULL DEFAULT true,
+    created_at   timestamptz  NOT NULL DEFAULT now(),
+    updated_at   timestamptz  NOT NULL DEFAULT now()
+)
+;
+
+CREATE TABLE webhook_deliveries (
+    id               bigserial    PRIMARY KEY,
+    webhook_id       int          NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event_id         text         NOT NULL,
+    event_type       text         NOT NULL,
+    payload          jsonb        NOT NULL,             -- the request body
+    status           text         NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts         int          NOT NULL DEFAULT 0,
+    next_attempt_at  timestamptz  NOT NULL DEFAULT now(),
+    response_status  int,                               -- last HTTP status, NULL = no response
+    last_error       text,
+    created_at       timestamptz  NOT NULL DEFAULT now(),
+    delivered_at     timestamptz
+)
+;
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_hook ON webhook_deliveries (webhook_id, created_at DESC);
+CREATE INDEX idx_webhook_deliveries_created ON webhook_deliveries (created_at);
+
+-- ============================================================
+-- 68. talkgroup_groups (/talkgroup-groups)
+--
+--     Display metadata for talkgroup groups (the talkgroup
+--     "group", TR's CSV Category), matched by name without
+--     regard to case across systems. Systems and sites carry
+--     the same columns.
+-- ============================================================
+
+CREATE TABLE talkgroup_groups (
+    name          text         NOT NULL,
+    color         text,                              -- '#rrggbb'
+    icon          text,
+    short_label   text,
+    display_order int          NOT NULL DEFAULT 0,
+    updated_at    timestamptz  NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX uq_talkgroup_groups_name ON talkgroup_groups (lower(name));
+
+-- ============================================================
+-- 69. audio_reconciliation (AUDIO_RECONCILE outcomes)
+--
+--     Calls that ended without audio (the audio MQTT message
+--     was lost) are looked up in WATCH_DIR/TR_AUDIO_DIR by
+--     talkgroup and start time. One row per call checked:
+--     searching until a file is found (recovered) or every
+--     attempt missed (missing); skipped when the call's ingest
+--     or storage policy keeps audio off the call.
+-- ============================================================
+
+CREATE TABLE audio_reconciliation (
+    call_id          bigint       NOT NULL,
+    call_start_time  timestamptz  NOT NULL,
+    system_id        int          NOT NULL,
+    tgid             int          NOT NULL,
+    status           text         NOT NULL,  -- 'searching', 'recovered', 'missing', 'skipped'
+    attempts         int          NOT NULL DEFAULT 0,
+    audio_path       text,                   -- the file attached, when recovered
+    checked_at       timestamptz  NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (call_id, call_start_time)
+);
+
+CREATE INDEX idx_audio_reconciliation_status ON audio_reconciliation (status, call_start_time DESC);
+
+-- ============================================================
+-- 70. api_keys (/admin/api-keys)
+--     Named bearer tokens alongside AUTH_TOKEN/WRITE_TOKEN, each
+--     with a scope (stream, read, write, admin) and optionally its
+--     own rate limit. Only the SHA-256 of a key is stored; the key
+--     itself is returned once, at creation. Revoked keys are kept
+--     as a record of who revoked them.
+-- ============================================================
+
+CREATE TABLE api_keys (
+    id                serial       PRIMARY KEY,
+    name              text         NOT NULL,
+    prefix            text         NOT NULL,               -- first characters of the key, to tell keys apart
+    key_hash          text         NOT NULL UNIQUE,        -- hex SHA-256 of the key
+    scope             text         NOT NULL CHECK (scope IN ('stream', 'read', 'write', 'admin')),
+    rate_limit_rps    double precision,                    -- NULL = RATE_LIMIT_TOKEN_RPS
+    rate_limit_burst  int,
+    created_by        text         NOT NULL,
+    created_at        timestamptz  NOT NULL DEFAULT now(),
+    last_used_at      timestamptz,                         -- updated at most once a minute
+    last_used_ip      text,
+    revoked_by        text,
+    revoked_at        timestamptz
+);
+
+CREATE UNIQUE INDEX uq_api_keys_active_name ON api_keys (lower(name)) WHERE revoked_at IS NULL;
+
+-- ============================================================
+-- 71. unit_census_daily (radio census rollup)
+--
+-- Unit events grouped by radio and UTC day: any event marks the
+-- radio as seen that day; registrations and deregistrations are
+-- the 'on' and 'off' events. Feeds /systems/{id}/unit-census,
+-- together with units.first_seen (new radios) and
+-- units.last_seen (radios gone silent). Rebuilt for recent days
+-- hourly by ingest; older days can be rebuilt via
+-- POST /admin/rollups/unit-census. Rows are upserted, so days
+-- whose unit_events were purged keep their counts.
+-- ============================================================
+
+CREATE TABLE unit_census_daily (
+    system_id        int   NOT NULL,
+    unit_id          int   NOT NULL,
+    day              date  NOT NULL,
+    events           int   NOT NULL DEFAULT 0,
+    registrations    int   NOT NULL DEFAULT 0,
+    deregistrations  int   NOT NULL DEFAULT 0,
+
+    PRIMARY KEY (system_id, unit_id, day)
+);
+
+CREATE INDEX idx_unit_census_daily_day ON unit_census_daily (system_id, day);
+
+-- ============================================================
+-- 72. users, user_sessions (web UI accounts, /auth, /admin/users)
+--     People who sign in to the web UI with a username and
+--     password instead of sharing a bearer token. A user's role
+--     (read, write, admin) grants what the API key scope of the
+--     same name does. Passwords are stored as bcrypt hashes;
+--     sessions by the SHA-256 of their cookie, with a CSRF token
+--     required on changes. preferences holds pinned talkgroups
+--     and per-page default filters.
+-- ============================================================
+
+CREATE TABLE users (
+    id                   serial       PRIMARY KEY,
+    username             text         NOT NULL,
+    display_name         text         NOT NULL DEFAULT '',
+    password_hash        text         NOT NULL,               -- bcrypt
+    role                 text         NOT NULL CHECK (role IN ('read', 'write', 'admin')),
+    preferences          jsonb        NOT NULL DEFAULT '{}',  -- {"pinned_talkgroups": [...], "default_filters": {...}}
+    disabled             boolean      NOT NULL DEFAULT false,
+    created_by           text         NOT NULL,
+    created_at           timestamptz  NOT NULL DEFAULT now(),
+    updated_at           timestamptz  NOT NULL DEFAULT now(),
+    password_changed_at  timestamptz  NOT NULL DEFAULT now(),
+    last_login_at        timestamptz,
+    last_login_ip        text
+);
+
+CREATE UNIQUE INDEX uq_users_username ON users (lower(username));
+
+CREATE TABLE user_sessions (
+    id            text         PRIMARY KEY,                   -- hex SHA-256 of the session cookie
+    user_id       int          NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    csrf_token    text         NOT NULL,
+    created_at    timestamptz  NOT NULL DEFAULT now(),
+    expires_at    timestamptz  NOT NULL,                      -- moved forward as the session is used
+    last_seen_at  timestamptz  NOT NULL DEFAULT now(),
+    ip            text,
+    user_agent    text
+);
+
+CREATE INDEX idx_user_sessions_user ON user_sessions (user_id);
+CREATE INDEX idx_user_sessions_expires ON user_sessions (expires_at);
+
 -- ============================================================
 -- Helper: create_monthly_partition()
 --
@@ -932,6 +2356,10 @@ CREATE INDEX IF NOT EXISTS idx_call_groups_updated_at ON call_groups (updated_at
 CREATE INDEX IF NOT EXISTS idx_transcriptions_primary_lookup
     ON transcriptions (call_id, is_primary) WHERE is_primary;
 
+-- Speed up "newly discovered talkgroups/units" queries (GET /discoveries)
+CREATE INDEX IF NOT EXISTS idx_talkgroups_first_seen ON talkgroups (first_seen DESC);
+CREATE INDEX IF NOT EXISTS idx_units_first_seen ON units (first_seen DESC);
+
 -- ============================================================
 -- Check Constraints (added post-initial schema)
 -- ============================================================
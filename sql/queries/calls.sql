@@ -149,11 +149,10 @@ LIMIT 1;
 
 -- name: GetCallAudioPath :one
 SELECT COALESCE(audio_file_path, '') AS audio_file_path, COALESCE(call_filename, '') AS call_filename
-FROM calls WHERE call_id = $1
-ORDER BY start_time DESC LIMIT 1;
+FROM calls WHERE call_id = $1 AND start_time = $2;
 
 -- name: GetCallFreqList :one
-SELECT freq_list FROM calls WHERE call_id = $1 ORDER BY start_time DESC LIMIT 1;
+SELECT freq_list FROM calls WHERE call_id = $1 AND start_time = $2;
 
 -- name: GetCallSrcList :one
-SELECT src_list FROM calls WHERE call_id = $1 ORDER BY start_time DESC LIMIT 1;
+SELECT src_list FROM calls WHERE call_id = $1 AND start_time = $2;
@@ -54,9 +54,7 @@ SELECT call_id, start_time, system_id, tgid, duration,
     COALESCE(tg_tag, '') AS tg_tag,
     COALESCE(tg_group, '') AS tg_group
 FROM calls
-WHERE call_id = $1
-ORDER BY start_time DESC
-LIMIT 1;
+WHERE call_id = $1 AND start_time = $2;
 
 -- name: UpdateCallTranscriptionStatus :exec
 UPDATE calls SET transcription_status = $3
@@ -1,5 +1,8 @@
 // Main thread audio coordinator for live radio streaming.
-// Manages WebSocket connection, per-TG audio nodes, mixing, and compression.
+// Manages the server connection, per-TG audio nodes, mixing, and compression.
+// Audio arrives over WebRTC when the server has it enabled (lower latency,
+// jitter buffered by the browser) and over the WebSocket otherwise, or when
+// the peer connection can't be established.
 // Usage: const engine = new AudioEngine(); await engine.start(); engine.subscribe({tgids: [1234]});
 
 class AudioEngine {
@@ -8,8 +11,14 @@ class AudioEngine {
     this.wsPath = wsPath || '/api/v1/audio/live';
     this.options = {
       reconnectMaxMs: options.reconnectMaxMs || 30000,
+      webrtc: options.webrtc !== false,
+      webrtcPath: options.webrtcPath || '/api/v1/audio/webrtc/sessions',
+      webrtcConnectMs: options.webrtcConnectMs || 5000,
     };
     this.ws = null;
+    this.pc = null;
+    this.rtcSession = null; // WebRTC session id while the peer is open
+    this.lanes = [];        // lane index -> { track, receiver, source, el, tgid }
     this.audioCtx = null;
     this.masterGain = null;
     this.masterCompressor = null;
@@ -58,7 +67,7 @@ class AudioEngine {
 
     this._loadSettings();
     this._intentionalClose = false;
-    this._connect();
+    this._open();
   }
 
   stop() {
@@ -67,6 +76,7 @@ class AudioEngine {
       this.ws.close(1000);
       this.ws = null;
     }
+    this._closeWebRTC();
     var self = this;
     this.tgNodes.forEach(function (nodes, tgid) {
       self._removeTG(tgid);
@@ -83,6 +93,7 @@ class AudioEngine {
     if (this.ws && this.ws.readyState === WebSocket.OPEN) {
       this.ws.send(JSON.stringify({ type: 'subscribe', ...filter }));
     }
+    this._updateWebRTCFilter(filter);
   }
 
   unsubscribe() {
@@ -90,6 +101,7 @@ class AudioEngine {
     if (this.ws && this.ws.readyState === WebSocket.OPEN) {
       this.ws.send(JSON.stringify({ type: 'unsubscribe' }));
     }
+    this._updateWebRTCFilter(null);
   }
 
   setVolume(tgid, value) {
@@ -136,6 +148,7 @@ class AudioEngine {
   }
 
   getActiveTGs() {
+    this._refreshLaneActivity();
     var result = [];
     this.tgNodes.forEach(function (nodes, tgid) {
       result.push({
@@ -150,11 +163,203 @@ class AudioEngine {
   }
 
   isConnected() {
+    if (this.pc && this.pc.connectionState === 'connected') return true;
     return this.ws && this.ws.readyState === WebSocket.OPEN;
   }
 
+  // 'webrtc', 'websocket', or null before the first connection.
+  getTransport() {
+    if (this.pc) return 'webrtc';
+    return this.ws ? 'websocket' : null;
+  }
+
   // --- Internal ---
 
+  // Try WebRTC first; any failure (disabled on the server, no
+  // RTCPeerConnection, ICE not connected in time) falls back to the WebSocket.
+  _open() {
+    var self = this;
+    if (!this.options.webrtc || typeof RTCPeerConnection === 'undefined') {
+      this._connect();
+      return;
+    }
+    this._connectWebRTC().catch(function (e) {
+      console.warn('WebRTC audio unavailable, using WebSocket:', e.message);
+      self._closeWebRTC();
+      if (!self._intentionalClose) self._connect();
+    });
+  }
+
+  async _connectWebRTC() {
+    var self = this;
+    // Like the WebSocket, receive nothing until subscribe() names a filter.
+    var resp = await fetch(this.options.webrtcPath, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(this.lastSubscription || { tgids: [-1] }),
+    });
+    if (!resp.ok) throw new Error('session request failed: HTTP ' + resp.status);
+    var session = await resp.json();
+    if (this._intentionalClose) {
+      fetch(this.options.webrtcPath + '/' + session.session_id, { method: 'DELETE' }).catch(function () {});
+      return;
+    }
+
+    var pc = new RTCPeerConnection({ iceServers: session.ice_servers });
+    this.pc = pc;
+    this.rtcSession = session.session_id;
+
+    pc.ontrack = function (ev) {
+      var i = session.lanes.indexOf(ev.transceiver.mid);
+      if (i >= 0) self._attachLane(i, ev.track, ev.receiver);
+    };
+    pc.ondatachannel = function (ev) {
+      ev.channel.onmessage = function (m) {
+        try {
+          self._handleLaneMessage(JSON.parse(m.data));
+        } catch (e) {
+          // ignore bad JSON
+        }
+      };
+    };
+
+    await pc.setRemoteDescription({ type: 'offer', sdp: session.sdp });
+    await pc.setLocalDescription(await pc.createAnswer());
+    // The server doesn't trickle, so send the answer with every candidate.
+    await this._waitForICEGathering(pc);
+    resp = await fetch(this.options.webrtcPath + '/' + session.session_id + '/answer', {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ sdp: pc.localDescription.sdp }),
+    });
+    if (!resp.ok) throw new Error('answer rejected: HTTP ' + resp.status);
+
+    await this._waitForConnected(pc);
+    this.reconnectDelay = 1000;
+    this.emit('status', { connected: true, transport: 'webrtc' });
+    // subscribe() may have run while the session was being set up
+    this._updateWebRTCFilter(this.lastSubscription);
+
+    pc.onconnectionstatechange = function () {
+      if (pc !== self.pc || pc.connectionState !== 'failed') return;
+      self.emit('status', { connected: false, transport: 'webrtc' });
+      self._closeWebRTC();
+      if (!self._intentionalClose) {
+        setTimeout(function () { self._open(); }, self.reconnectDelay);
+        self.reconnectDelay = Math.min(self.reconnectDelay * 2, self.options.reconnectMaxMs);
+      }
+    };
+  }
+
+  _waitForICEGathering(pc) {
+    if (pc.iceGatheringState === 'complete') return Promise.resolve();
+    return new Promise(function (resolve) {
+      var timer = setTimeout(resolve, 2000); // send what we have
+      pc.addEventListener('icegatheringstatechange', function () {
+        if (pc.iceGatheringState === 'complete') {
+          clearTimeout(timer);
+          resolve();
+        }
+      });
+    });
+  }
+
+  _waitForConnected(pc) {
+    var ms = this.options.webrtcConnectMs;
+    return new Promise(function (resolve, reject) {
+      var timer = setTimeout(function () {
+        reject(new Error('peer not connected after ' + ms + 'ms'));
+      }, ms);
+      var check = function () {
+        if (pc.connectionState === 'connected') {
+          clearTimeout(timer);
+          resolve();
+        } else if (pc.connectionState === 'failed' || pc.connectionState === 'closed') {
+          clearTimeout(timer);
+          reject(new Error('peer connection ' + pc.connectionState));
+        }
+      };
+      pc.addEventListener('connectionstatechange', check);
+      check();
+    });
+  }
+
+  _closeWebRTC() {
+    for (var i = 0; i < this.lanes.length; i++) {
+      var lane = this.lanes[i];
+      if (!lane) continue;
+      lane.source.disconnect();
+      lane.el.srcObject = null;
+    }
+    this.lanes = [];
+    if (this.pc) {
+      this.pc.close();
+      this.pc = null;
+    }
+    if (this.rtcSession) {
+      fetch(this.options.webrtcPath + '/' + this.rtcSession, { method: 'DELETE' }).catch(function () {});
+      this.rtcSession = null;
+    }
+  }
+
+  _updateWebRTCFilter(filter) {
+    if (!this.rtcSession) return;
+    fetch(this.options.webrtcPath + '/' + this.rtcSession, {
+      method: 'PATCH',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(filter || { tgids: [-1] }),
+    }).catch(function () {});
+  }
+
+  // Each lane is one server track; the data channel says which talkgroup is
+  // on it, and the lane's source is moved to that talkgroup's nodes.
+  _attachLane(i, track, receiver) {
+    var stream = new MediaStream([track]);
+    // Chrome only feeds a remote stream to Web Audio while a media element
+    // is playing it.
+    var el = new Audio();
+    el.muted = true;
+    el.srcObject = stream;
+    el.play().catch(function () {});
+    this.lanes[i] = {
+      track: track,
+      receiver: receiver,
+      source: this.audioCtx.createMediaStreamSource(stream),
+      el: el,
+      tgid: null,
+    };
+  }
+
+  _handleLaneMessage(msg) {
+    if (msg.type !== 'lane') return;
+    var lane = this.lanes[msg.lane];
+    if (!lane) return;
+    if (lane.tgid !== msg.tgid) {
+      lane.source.disconnect();
+      if (!this.tgNodes.has(msg.tgid)) {
+        this._createTG(msg.tgid);
+      }
+      lane.source.connect(this.tgNodes.get(msg.tgid).compressor);
+      lane.tgid = msg.tgid;
+    }
+    var nodes = this.tgNodes.get(msg.tgid);
+    if (nodes) nodes.lastActivity = Date.now();
+  }
+
+  // WebRTC audio skips the worklet, so take activity from the time each
+  // lane's receiver last got a packet.
+  _refreshLaneActivity() {
+    for (var i = 0; i < this.lanes.length; i++) {
+      var lane = this.lanes[i];
+      if (!lane || lane.tgid === null) continue;
+      var nodes = this.tgNodes.get(lane.tgid);
+      var sources = lane.receiver.getSynchronizationSources();
+      if (nodes && sources.length > 0) {
+        nodes.lastActivity = Math.max(nodes.lastActivity, Math.round(sources[0].timestamp));
+      }
+    }
+  }
+
   _connect() {
     var self = this;
     var protocol = location.protocol === 'https:' ? 'wss:' : 'ws:';
@@ -166,7 +371,7 @@ class AudioEngine {
 
     this.ws.onopen = function () {
       self.reconnectDelay = 1000;
-      self.emit('status', { connected: true });
+      self.emit('status', { connected: true, transport: 'websocket' });
       if (self.lastSubscription) {
         self.subscribe(self.lastSubscription);
       }
@@ -9,6 +9,12 @@
  * 3. Patches window.fetch to inject Authorization header on same-origin /api/ calls
  * 4. Patches EventSource to append ?token= on same-origin URLs
  * 5. On 401 response, shows a token prompt modal → saves → reloads
+ * 6. trAuth.capabilities() resolves to GET /api/v1/capabilities (permissions
+ *    and enabled features) so pages can hide actions the token can't perform
+ * 7. Signed-in users (POST /api/v1/auth/login) use their session cookie
+ *    instead of a token: the fetch patch adds X-CSRF-Token to changes, the
+ *    prompt offers username/password sign-in, and trAuth.user(),
+ *    trAuth.savePreferences() and trAuth.logout() manage the account
  *
  * Caddy interaction: When behind a reverse proxy (e.g. tr-dashboard domain),
  * Caddy conditionally injects the read token for unauthenticated requests.
@@ -23,9 +29,19 @@
 
   const STORAGE_KEY = 'tr-engine-token';
 
+  // Signed in? The session cookie is HttpOnly, so ask the server (synchronous
+  // XHR, like auth-init below). A session takes the place of the token.
+  let session = null; // {user, csrf_token, expires_at}
+  try {
+    var meXHR = new XMLHttpRequest();
+    meXHR.open('GET', '/api/v1/auth/me', false);
+    meXHR.send();
+    if (meXHR.status === 200) session = JSON.parse(meXHR.responseText);
+  } catch (e) { /* older server or network error — fall through to tokens */ }
+
   // Load token from server (synchronous XHR so it's ready before page scripts run).
   // The /api/v1/auth-init endpoint has no file extension, so CDNs won't cache it.
-  if (!window.__TR_AUTH_TOKEN__) {
+  if (!session && !window.__TR_AUTH_TOKEN__) {
     try {
       var xhr = new XMLHttpRequest();
       xhr.open('GET', '/api/v1/auth-init', false);
@@ -37,13 +53,14 @@
     } catch (e) { /* server may not support auth-init — fall through */ }
   }
 
-  let token = window.__TR_AUTH_TOKEN__ || localStorage.getItem(STORAGE_KEY) || '';
+  let token = session ? '' : (window.__TR_AUTH_TOKEN__ || localStorage.getItem(STORAGE_KEY) || '');
   let prompted = false; // prevent multiple prompts
 
   // ── Patch fetch ──────────────────────────────────────────────────
   const _fetch = window.fetch;
   window.fetch = function (input, init) {
     const url = typeof input === 'string' ? input : input instanceof Request ? input.url : '';
+    const method = ((init && init.method) || (input instanceof Request ? input.method : 'GET')).toUpperCase();
     if (token && isLocalAPI(url)) {
       init = init || {};
       const headers = new Headers(init.headers || {});
@@ -51,6 +68,13 @@
         headers.set('Authorization', 'Bearer ' + token);
       }
       init.headers = headers;
+    } else if (session && isLocalAPI(url) && method !== 'GET' && method !== 'HEAD') {
+      init = init || {};
+      const headers = new Headers(init.headers || {});
+      if (!headers.has('X-CSRF-Token')) {
+        headers.set('X-CSRF-Token', session.csrf_token);
+      }
+      init.headers = headers;
     }
     return _fetch.call(this, input, init).then(function (resp) {
       if (resp.status === 401 && isLocalAPI(url)) showPrompt();
@@ -106,14 +130,50 @@
     title.style.cssText = 'margin:0 0 8px;font-size:16px;color:#fff';
 
     const desc = document.createElement('p');
-    desc.textContent = 'This instance requires an API token. Enter it below — it will be saved in your browser.';
+    desc.textContent = 'Sign in with your account, or enter an API token — it will be saved in your browser.';
     desc.style.cssText = 'margin:0 0 16px;font-size:13px;color:#999;line-height:1.4';
 
+    const fieldCSS = 'width:100%;box-sizing:border-box;padding:8px 12px;background:#0d0d1a;border:1px solid #444;border-radius:4px;color:#fff;font-size:14px;margin-bottom:12px';
+    const user = document.createElement('input');
+    user.type = 'text';
+    user.placeholder = 'Username';
+    user.autocomplete = 'username';
+    user.style.cssText = fieldCSS;
+
+    const pass = document.createElement('input');
+    pass.type = 'password';
+    pass.placeholder = 'Password';
+    pass.autocomplete = 'current-password';
+    pass.style.cssText = fieldCSS;
+
+    const err = document.createElement('p');
+    err.style.cssText = 'margin:0 0 12px;font-size:13px;color:#f77;display:none';
+
+    const signIn = document.createElement('button');
+    signIn.textContent = 'Sign In';
+    signIn.style.cssText = 'width:100%;padding:8px;background:#4a6cf7;color:#fff;border:none;border-radius:4px;cursor:pointer;font-size:14px;margin-bottom:16px';
+
+    function doSignIn() {
+      err.style.display = 'none';
+      login(user.value.trim(), pass.value).then(function () {
+        location.reload();
+      }, function (e) {
+        err.textContent = e.message;
+        err.style.display = 'block';
+      });
+    }
+    signIn.onclick = doSignIn;
+    pass.onkeydown = function (e) { if (e.key === 'Enter') doSignIn(); };
+
+    const or = document.createElement('p');
+    or.textContent = 'or use an API token';
+    or.style.cssText = 'margin:0 0 8px;font-size:12px;color:#777;text-align:center';
+
     const input = document.createElement('input');
     input.type = 'password';
     input.placeholder = 'Bearer token';
     input.value = token;
-    input.style.cssText = 'width:100%;box-sizing:border-box;padding:8px 12px;background:#0d0d1a;border:1px solid #444;border-radius:4px;color:#fff;font-size:14px;margin-bottom:12px';
+    input.style.cssText = fieldCSS;
 
     const btnRow = document.createElement('div');
     btnRow.style.cssText = 'display:flex;gap:8px';
@@ -148,11 +208,72 @@
     btnRow.appendChild(clear);
     box.appendChild(title);
     box.appendChild(desc);
+    box.appendChild(user);
+    box.appendChild(pass);
+    box.appendChild(err);
+    box.appendChild(signIn);
+    box.appendChild(or);
     box.appendChild(input);
     box.appendChild(btnRow);
     overlay.appendChild(box);
     document.body.appendChild(overlay);
-    input.focus();
+    user.focus();
+  }
+
+  // ── Accounts ─────────────────────────────────────────────────────
+  function jsonError(resp) {
+    return resp.json().then(function (b) { return b.error; }, function () { return null; })
+      .then(function (msg) { throw new Error(msg || ('HTTP ' + resp.status)); });
+  }
+
+  // Resolves once the session cookie is set; the page should reload.
+  function login(username, password) {
+    return _fetch('/api/v1/auth/login', {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ username: username, password: password }),
+    }).then(function (resp) {
+      if (!resp.ok) return jsonError(resp);
+      return resp.json();
+    });
+  }
+
+  function logout() {
+    return _fetch('/api/v1/auth/logout', { method: 'POST' }).then(function () {
+      location.reload();
+    });
+  }
+
+  // Replaces the signed-in user's preferences
+  // ({pinned_talkgroups: ["system_id:tgid"], default_filters: {page: {...}}}).
+  function savePreferences(prefs) {
+    if (!session) return Promise.reject(new Error('not signed in'));
+    return window.fetch('/api/v1/auth/me/preferences', {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(prefs),
+    }).then(function (resp) {
+      if (!resp.ok) return jsonError(resp);
+      return resp.json();
+    }).then(function (saved) {
+      session.user.preferences = saved;
+      return saved;
+    });
+  }
+
+  // ── Capabilities ─────────────────────────────────────────────────
+  // Cached per token. Pass a write token to ask what it may do; resolves to
+  // null if the server doesn't support /capabilities.
+  const capsCache = {};
+  function capabilities(withToken) {
+    const t = withToken || token;
+    if (!capsCache[t]) {
+      const init = t ? { headers: { 'Authorization': 'Bearer ' + t } } : {};
+      capsCache[t] = window.fetch('/api/v1/capabilities', init)
+        .then(function (r) { return r.ok ? r.json() : null; })
+        .catch(function () { return null; });
+    }
+    return capsCache[t];
   }
 
   // ── Expose for pages that need programmatic access ───────────────
@@ -164,5 +285,12 @@
       else localStorage.removeItem(STORAGE_KEY);
     },
     showPrompt: showPrompt,
+    capabilities: capabilities,
+    // Signed-in user ({id, username, display_name, role, preferences}) or null
+    user: function () { return session ? session.user : null; },
+    preferences: function () { return session ? session.user.preferences : null; },
+    savePreferences: savePreferences,
+    login: login,
+    logout: logout,
   };
 })();